@@ -0,0 +1,111 @@
+// Project Calico BPF dataplane programs.
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// This program is free software; you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation; either version 2 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License along
+// with this program; if not, write to the Free Software Foundation, Inc.,
+// 51 Franklin Street, Fifth Floor, Boston, MA 02110-1301 USA.
+
+#ifndef __CALI_COUNTERS_H__
+#define __CALI_COUNTERS_H__
+
+#include "bpf.h"
+#include "log.h"
+#include "reasons.h"
+
+/* The counters map holds per-interface, per-hook packet counters, which Felix scrapes and
+ * exports as Prometheus metrics.  The layout must be kept in sync with bpf/counters in Felix.
+ */
+
+enum cali_counters_hook {
+	CALI_COUNTERS_HOOK_INGRESS,
+	CALI_COUNTERS_HOOK_EGRESS,
+};
+
+struct counters_key {
+	__u32 ifindex;
+	__u32 hook;
+};
+
+struct counters_value {
+	__u64 accepted;
+	__u64 dropped_policy;
+	__u64 dropped_rpf;
+	__u64 dropped_unauth_source;
+	__u64 dropped_other;
+	/* New connections that were DNATed to a service backend. */
+	__u64 nat;
+};
+
+CALI_MAP(cali_counters, 1,
+		BPF_MAP_TYPE_HASH,
+		struct counters_key, struct counters_value,
+		20000, 0, MAP_PIN_GLOBAL)
+
+static CALI_BPF_INLINE struct counters_value *counters_get(struct __sk_buff *skb)
+{
+	struct counters_key key = {
+		.ifindex = skb->ifindex,
+		.hook = CALI_F_INGRESS ? CALI_COUNTERS_HOOK_INGRESS : CALI_COUNTERS_HOOK_EGRESS,
+	};
+
+	struct counters_value *v = cali_counters_lookup_elem(&key);
+	if (v) {
+		return v;
+	}
+
+	/* First packet on this interface; BPF_NOEXIST so that we don't reset counters that
+	 * another CPU has just created. */
+	struct counters_value zero = {};
+	cali_counters_update_elem(&key, &zero, BPF_NOEXIST);
+	return cali_counters_lookup_elem(&key);
+}
+
+/* counters_record_result counts the final verdict of a TC program.  rc is the return code that
+ * the program is about to return and reason is the reason for a drop. */
+static CALI_BPF_INLINE void counters_record_result(struct __sk_buff *skb, int rc, enum calico_reason reason)
+{
+	struct counters_value *v = counters_get(skb);
+	if (!v) {
+		CALI_DEBUG("Failed to get counters for ifindex %d\n", skb->ifindex);
+		return;
+	}
+
+	if (rc != TC_ACT_SHOT) {
+		__sync_fetch_and_add(&v->accepted, 1);
+		return;
+	}
+
+	switch (reason) {
+	case CALI_REASON_POL:
+		__sync_fetch_and_add(&v->dropped_policy, 1);
+		break;
+	case CALI_REASON_RPF_FAIL:
+		__sync_fetch_and_add(&v->dropped_rpf, 1);
+		break;
+	case CALI_REASON_UNAUTH_SOURCE:
+		__sync_fetch_and_add(&v->dropped_unauth_source, 1);
+		break;
+	default:
+		__sync_fetch_and_add(&v->dropped_other, 1);
+	}
+}
+
+static CALI_BPF_INLINE void counters_record_nat(struct __sk_buff *skb)
+{
+	struct counters_value *v = counters_get(skb);
+	if (v) {
+		__sync_fetch_and_add(&v->nat, 1);
+	}
+}
+
+#endif /* __CALI_COUNTERS_H__ */
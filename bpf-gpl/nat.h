@@ -64,9 +64,35 @@ static CALI_BPF_INLINE int skb_nat_l4_csum_ipv4(struct __sk_buff *skb, size_t of
 	return ret;
 }
 
+static CALI_BPF_INLINE __u32 nat_hash_mix(__u32 h)
+{
+	/* murmur3 finalizer */
+	h ^= h >> 16;
+	h *= 0x85ebca6b;
+	h ^= h >> 13;
+	h *= 0xc2b2ae35;
+	h ^= h >> 16;
+	return h;
+}
+
+/* nat_flow_hash must give the same result on every node for the same flow so
+ * that a node that receives a nodeport flow through the tunnel picks the same
+ * backend as the node that forwarded it.
+ */
+static CALI_BPF_INLINE __u32 nat_flow_hash(__be32 ip_src, __be32 ip_dst,
+					   __u8 ip_proto, __u16 sport, __u16 dport)
+{
+	__u32 h = nat_hash_mix(ip_src);
+
+	h = nat_hash_mix(h ^ ip_dst);
+	h = nat_hash_mix(h ^ (((__u32)sport << 16) | dport));
+	return nat_hash_mix(h ^ ip_proto);
+}
+
 static CALI_BPF_INLINE struct calico_nat_dest* calico_v4_nat_lookup2(__be32 ip_src,
 								     __be32 ip_dst,
 								     __u8 ip_proto,
+								     __u16 sport,
 								     __u16 dport,
 								     bool from_tun,
 								     nat_lookup_result *res)
@@ -191,6 +217,28 @@ skip_affinity:
 	nat_lv2_key.ordinal = bpf_get_prandom_u32();
 	nat_lv2_key.ordinal %= count;
 
+	/* At connect time we know neither the source IP nor the port so every
+	 * connection would hash to the same backend, stick with random.
+	 */
+	if (!CALI_F_CGROUP) {
+		__u32 hash = nat_flow_hash(ip_src, ip_dst, ip_proto, sport, dport);
+		struct calico_nat_maglev_key mkey = {
+			.id = nat_lv1_val->id,
+			.slot = hash % NAT_MAGLEV_TABLE_SIZE,
+		};
+		__u32 *ordinal = cali_v4_nat_mag_lookup_elem(&mkey);
+
+		if (ordinal) {
+			/* The table covers all backends, if it points at one
+			 * that we cannot use (a remote one when we must pick
+			 * a local one or while the table is being updated),
+			 * pick a usable one consistently.
+			 */
+			nat_lv2_key.ordinal = *ordinal < count ? *ordinal : hash % count;
+			CALI_DEBUG("NAT: maglev slot %d\n", mkey.slot);
+		}
+	}
+
 	CALI_DEBUG("NAT: 1st level hit; id=%d ordinal=%d\n", nat_lv2_key.id, nat_lv2_key.ordinal);
 
 	if (!(nat_lv2_val = cali_v4_nat_be_lookup_elem(&nat_lv2_key))) {
@@ -221,7 +269,7 @@ skip_affinity:
 static CALI_BPF_INLINE struct calico_nat_dest* calico_v4_nat_lookup(__be32 ip_src, __be32 ip_dst,
 								    __u8 ip_proto, __u16 dport, nat_lookup_result *res)
 {
-	return calico_v4_nat_lookup2(ip_src, ip_dst, ip_proto, dport, false, res);
+	return calico_v4_nat_lookup2(ip_src, ip_dst, ip_proto, 0, dport, false, res);
 }
 
 static CALI_BPF_INLINE int vxlan_v4_encap(struct cali_tc_ctx *ctx,  __be32 ip_src, __be32 ip_dst)
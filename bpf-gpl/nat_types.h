@@ -92,6 +92,24 @@ CALI_MAP_V1(cali_v4_nat_be,
 		struct calico_nat_secondary_v4_key, struct calico_nat_dest,
 		510000, BPF_F_NO_PREALLOC, MAP_PIN_GLOBAL)
 
+// Map: NAT Maglev lookup tables.  ID and slot -> ordinal of the backend in cali_v4_nat_be.
+//
+// Services that have a table get a backend by hashing the flow to one of the
+// NAT_MAGLEV_TABLE_SIZE slots so that, unlike with random selection, a flow
+// keeps its backend when other backends come and go.
+
+#define NAT_MAGLEV_TABLE_SIZE 1021
+
+struct calico_nat_maglev_key {
+	__u32 id;
+	__u32 slot;
+};
+
+CALI_MAP_V1(cali_v4_nat_mag,
+		BPF_MAP_TYPE_HASH,
+		struct calico_nat_maglev_key, __u32,
+		510000, BPF_F_NO_PREALLOC, MAP_PIN_GLOBAL)
+
 struct calico_nat_v4_affinity_key {
 	struct calico_nat_v4 nat_key;
 	__u32 client_ip;
@@ -37,6 +37,7 @@ enum calico_reason {
 	CALI_REASON_IP_OPTIONS = 0xeb,
 	CALI_REASON_IP_MALFORMED = 0xec,
 	CALI_REASON_UNAUTH_SOURCE = 0xed,
+	CALI_REASON_RPF_FAIL = 0xee,
 	CALI_REASON_RT_UNKNOWN = 0xdead,
 };
 
@@ -48,6 +48,16 @@
 #include "policy_program.h"
 #include "parsing.h"
 #include "failsafe.h"
+#include "counters.h"
+
+/* forward_or_drop_counted forwards or drops the packet, as forward_or_drop, and counts the
+ * result in the counters map. */
+static CALI_BPF_INLINE int forward_or_drop_counted(struct cali_tc_ctx *ctx)
+{
+	int rc = forward_or_drop(ctx);
+	counters_record_result(ctx->skb, rc, ctx->fwd.reason);
+	return rc;
+}
 
 /* calico_tc is the main function used in all of the tc programs.  It is specialised
  * for particular hook at build time based on the CALI_F build flags.
@@ -203,6 +213,12 @@ static CALI_BPF_INLINE int calico_tc(struct __sk_buff *skb)
 			}
 		}
 		break;
+	case IPPROTO_SCTP:
+		/* The SCTP common header starts with the source and dest ports, laid out as for UDP. */
+		ctx.state->sport = bpf_ntohs(ctx.udp_header->source);
+		ctx.state->dport = bpf_ntohs(ctx.udp_header->dest);
+		CALI_DEBUG("SCTP; ports: s=%d d=%d\n", ctx.state->sport, ctx.state->dport);
+		break;
 	case IPPROTO_ICMP:
 		CALI_DEBUG("ICMP; type=%d code=%d\n",
 				ctx.icmp_header->type, ctx.icmp_header->code);
@@ -252,6 +268,7 @@ static CALI_BPF_INLINE int calico_tc(struct __sk_buff *skb)
 	/* Check if someone is trying to spoof a tunnel packet */
 	if (CALI_F_FROM_HEP && ct_result_tun_src_changed(ctx.state->ct_result.rc)) {
 		CALI_DEBUG("dropping tunnel pkt with changed source node\n");
+		ctx.fwd.reason = CALI_REASON_RPF_FAIL;
 		goto deny;
 	}
 
@@ -309,8 +326,12 @@ static CALI_BPF_INLINE int calico_tc(struct __sk_buff *skb)
 
 	/* No conntrack entry, check if we should do NAT */
 	nat_lookup_result nat_res = NAT_LOOKUP_ALLOW;
+	/* SCTP's CRC32c checksum covers the ports and we have no way to recalculate it here so
+	 * we don't attempt to NAT SCTP; the ports are only extracted for policy. */
 	ctx.nat_dest = calico_v4_nat_lookup2(ctx.state->ip_src, ctx.state->ip_dst,
-					     ctx.state->ip_proto, ctx.state->dport,
+					     ctx.state->ip_proto,
+					     ctx.state->ip_proto == IPPROTO_SCTP ? 0 : ctx.state->sport,
+					     ctx.state->ip_proto == IPPROTO_SCTP ? 0 : ctx.state->dport,
 					     ctx.state->tun_ip != 0, &nat_res);
 
 	if (nat_res == NAT_FE_LOOKUP_DROP) {
@@ -319,6 +340,7 @@ static CALI_BPF_INLINE int calico_tc(struct __sk_buff *skb)
 		goto deny;
 	}
 	if (ctx.nat_dest != NULL) {
+		counters_record_nat(skb);
 		ctx.state->post_nat_ip_dst = ctx.nat_dest->addr;
 		ctx.state->post_nat_dport = ctx.nat_dest->port;
 	} else if (nat_res == NAT_NO_BACKEND) {
@@ -349,15 +371,18 @@ static CALI_BPF_INLINE int calico_tc(struct __sk_buff *skb)
 		struct cali_rt *r = cali_rt_lookup(ctx.state->ip_src);
 		if (!r) {
 			CALI_INFO("Workload RPF fail: missing route.\n");
+			ctx.fwd.reason = CALI_REASON_RPF_FAIL;
 			goto deny;
 		}
 		if (!cali_rt_flags_local_workload(r->flags)) {
 			CALI_INFO("Workload RPF fail: not a local workload.\n");
+			ctx.fwd.reason = CALI_REASON_RPF_FAIL;
 			goto deny;
 		}
 		if (r->if_index != skb->ifindex) {
 			CALI_INFO("Workload RPF fail skb iface (%d) != route iface (%d)\n",
 					skb->ifindex, r->if_index);
+			ctx.fwd.reason = CALI_REASON_RPF_FAIL;
 			goto deny;
 		}
 
@@ -483,7 +508,7 @@ skip_policy:
 
 allow:
 finalize:
-	return forward_or_drop(&ctx);
+	return forward_or_drop_counted(&ctx);
 deny:
 	ctx.fwd.res = TC_ACT_SHOT;
 	goto finalize;
@@ -524,9 +549,10 @@ int calico_tc_skb_accepted_entrypoint(struct __sk_buff *skb)
 	}
 
 	ctx.fwd = calico_tc_skb_accepted(&ctx, nat_dest);
-	return forward_or_drop(&ctx);
+	return forward_or_drop_counted(&ctx);
 
 deny:
+	counters_record_result(skb, TC_ACT_SHOT, ctx.fwd.reason);
 	return TC_ACT_SHOT;
 }
 
@@ -693,9 +719,11 @@ static CALI_BPF_INLINE struct fwd calico_tc_skb_accepted(struct cali_tc_ctx *ctx
 		switch (state->pol_rc) {
 		case CALI_POL_NO_MATCH:
 			CALI_DEBUG("Implicitly denied by policy: DROP\n");
+			reason = CALI_REASON_POL;
 			goto deny;
 		case CALI_POL_DENY:
 			CALI_DEBUG("Denied by policy: DROP\n");
+			reason = CALI_REASON_POL;
 			goto deny;
 		case CALI_POL_ALLOW:
 			CALI_DEBUG("Allowed by policy: ACCEPT\n");
@@ -1154,8 +1182,9 @@ int calico_tc_skb_send_icmp_replies(struct __sk_buff *skb)
 
 	tc_state_fill_from_iphdr(ctx.state, ctx.ip_header);
 	ctx.state->sport = ctx.state->dport = 0;
-	return forward_or_drop(&ctx);
+	return forward_or_drop_counted(&ctx);
 deny:
+	counters_record_result(skb, TC_ACT_SHOT, ctx.fwd.reason);
 	return TC_ACT_SHOT;
 }
 
@@ -231,9 +231,12 @@ func MaybeMountBPFfs() (string, error) {
 	return bpffsPath, err
 }
 
+// cgroupV2PrivatePath is where we mount cgroup v2 if we need our own mount.
+const cgroupV2PrivatePath = "/run/calico/cgroup"
+
 func MaybeMountCgroupV2() (string, error) {
 	var err error
-	cgroupV2Path := "/run/calico/cgroup"
+	cgroupV2Path := cgroupV2PrivatePath
 
 	if err := os.MkdirAll(cgroupV2Path, 0700); err != nil {
 		return "", err
@@ -259,7 +262,15 @@ func MaybeMountCgroupV2() (string, error) {
 }
 
 func mountCgroupV2(path string) error {
-	return syscall.Mount(path, path, "cgroup2", 0, "")
+	if err := syscall.Mount(path, path, "cgroup2", 0, ""); err != nil {
+		return err
+	}
+	return makeMountPrivate(path)
+}
+
+// makeMountPrivate stops the mount from propagating out of our mount namespace.
+func makeMountPrivate(path string) error {
+	return syscall.Mount("", path, "", syscall.MS_PRIVATE, "")
 }
 
 func isMount(path string) (bool, error) {
@@ -312,7 +323,10 @@ func isCgroupV2(path string) (bool, error) {
 }
 
 func mountBPFfs(path string) error {
-	return syscall.Mount(path, path, "bpf", 0, "")
+	if err := syscall.Mount(path, path, "bpf", 0, ""); err != nil {
+		return err
+	}
+	return makeMountPrivate(path)
 }
 
 type BPFDataplane interface {
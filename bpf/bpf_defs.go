@@ -49,6 +49,7 @@ type ProgResult struct {
 }
 
 type MapInfo struct {
+	ID        int
 	Type      int
 	KeySize   int
 	ValueSize int
@@ -62,3 +63,7 @@ var ErrIterationFinished = errors.New("iteration finished")
 // ErrVisitedTooManyKeys is returned by the MapIterator's Next() method if it sees many more keys than there should
 // be in the map.
 var ErrVisitedTooManyKeys = errors.New("visited 10x the max size of the map keys")
+
+// ErrBatchOpsNotSupported is returned by the batched map operations if the kernel doesn't support them for
+// the map (BPF_MAP_LOOKUP_BATCH and friends need kernel 5.6+ and aren't implemented for all map types).
+var ErrBatchOpsNotSupported = errors.New("BPF map batch operations not supported")
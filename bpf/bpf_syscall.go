@@ -289,6 +289,7 @@ func GetMapInfo(fd MapFD) (*MapInfo, error) {
 		return nil, errno
 	}
 	return &MapInfo{
+		ID:        int(bpfMapInfo.id),
 		Type:      int(bpfMapInfo._type),
 		KeySize:   int(bpfMapInfo.key_size),
 		ValueSize: int(bpfMapInfo.value_size),
@@ -473,3 +474,124 @@ func (m *MapIterator) Close() error {
 
 	return nil
 }
+
+// MapBatchSize is the number of entries that a MapBatchIterator asks the kernel for at a time.  Unlike the
+// MapIterator, which needs a couple of syscalls per entry, the batch size is limited only by memory.
+const MapBatchSize = 1024
+
+// errnoENOTSUPP is the kernel-internal "operation not supported" errno, which leaks out of the bpf syscall.
+const errnoENOTSUPP = 524
+
+// MapBatchIterator iterates over a map using BPF_MAP_LOOKUP_BATCH, which loads a whole batch of entries per
+// syscall.  Entries that have been returned can be deleted without disturbing the iteration.
+type MapBatchIterator struct {
+	mapFD     MapFD
+	keySize   int
+	valueSize int
+
+	batchSize int
+	keys      []byte
+	values    []byte
+
+	// inBatch and outBatch hold the opaque position in the map, which the kernel gives us after each
+	// batch so that we can ask for the next one.
+	inBatch  []byte
+	outBatch []byte
+	started  bool
+	finished bool
+}
+
+func NewMapBatchIterator(mapFD MapFD, keySize, valueSize int) (*MapBatchIterator, error) {
+	err := checkMapIfDebug(mapFD, keySize, valueSize)
+	if err != nil {
+		return nil, err
+	}
+	m := &MapBatchIterator{
+		mapFD:     mapFD,
+		keySize:   keySize,
+		valueSize: valueSize,
+		// The position token is a bucket index for hash maps and a key for other map types.
+		inBatch:  make([]byte, keySize+8),
+		outBatch: make([]byte, keySize+8),
+	}
+	m.allocBuffers(MapBatchSize)
+	return m, nil
+}
+
+func (m *MapBatchIterator) allocBuffers(batchSize int) {
+	m.batchSize = batchSize
+	m.keys = make([]byte, m.keySize*batchSize)
+	m.values = make([]byte, m.valueSize*batchSize)
+}
+
+// NextBatch loads the next batch of entries from the kernel.  The keys and values are packed back-to-back in
+// the returned slices, which belong to the iterator and are only valid until the next call.  Returns
+// ErrIterationFinished at the end of the iteration, or ErrBatchOpsNotSupported if the first batch can't be
+// loaded because the kernel doesn't support batch operations on the map.
+func (m *MapBatchIterator) NextBatch() (keys, values []byte, count int, err error) {
+	if m.finished {
+		err = ErrIterationFinished
+		return
+	}
+	for {
+		var inBatch unsafe.Pointer
+		if m.started {
+			inBatch = unsafe.Pointer(&m.inBatch[0])
+		}
+		n := C.__u32(m.batchSize)
+		errno := C.bpf_map_batch_call(unix.BPF_MAP_LOOKUP_BATCH, C.uint(m.mapFD), inBatch,
+			unsafe.Pointer(&m.outBatch[0]), unsafe.Pointer(&m.keys[0]), unsafe.Pointer(&m.values[0]), &n)
+		count = int(n)
+		switch unix.Errno(errno) {
+		case 0:
+		case unix.ENOENT:
+			// End of the map, n may still be non-zero for the final, partial, batch.
+			m.finished = true
+			if count == 0 {
+				err = ErrIterationFinished
+				return
+			}
+		case unix.ENOSPC:
+			// A hash bucket has more entries than we have space for; retry with bigger buffers.
+			m.allocBuffers(m.batchSize * 2)
+			continue
+		case unix.EINVAL, unix.EOPNOTSUPP, errnoENOTSUPP:
+			if !m.started {
+				err = ErrBatchOpsNotSupported
+				return
+			}
+			err = unix.Errno(errno)
+			return
+		default:
+			err = unix.Errno(errno)
+			return
+		}
+		m.started = true
+		copy(m.inBatch, m.outBatch)
+		keys = m.keys[:count*m.keySize]
+		values = m.values[:count*m.valueSize]
+		return
+	}
+}
+
+// DeleteMapEntriesBatch deletes the given keys, which are packed back-to-back, with BPF_MAP_DELETE_BATCH.  Keys
+// that don't exist are skipped.  Returns ErrBatchOpsNotSupported if the kernel doesn't support batch deletes.
+func DeleteMapEntriesBatch(mapFD MapFD, keys []byte, keySize int) error {
+	for len(keys) > 0 {
+		n := C.__u32(len(keys) / keySize)
+		errno := C.bpf_map_batch_call(unix.BPF_MAP_DELETE_BATCH, C.uint(mapFD), nil, nil,
+			unsafe.Pointer(&keys[0]), nil, &n)
+		switch unix.Errno(errno) {
+		case 0:
+			return nil
+		case unix.ENOENT:
+			// The kernel stops at the first key that doesn't exist; skip it and carry on.
+			keys = keys[(int(n)+1)*keySize:]
+		case unix.EINVAL, unix.EOPNOTSUPP, errnoENOTSUPP:
+			return ErrBatchOpsNotSupported
+		default:
+			return unix.Errno(errno)
+		}
+	}
+	return nil
+}
@@ -177,3 +177,22 @@ int bpf_map_load_multi(__u32 map_fd,
    }
    return count;
 }
+
+// bpf_map_batch_call calls BPF_MAP_LOOKUP_BATCH or BPF_MAP_DELETE_BATCH.  On the way in, count is
+// the number of entries that the keys/values buffers can hold (or the number of keys to delete).
+// On the way out, it is the number of entries that the kernel processed, which may be non-zero
+// even if the call returns an error.
+int bpf_map_batch_call(int cmd, __u32 map_fd, void *in_batch, void *out_batch, void *keys, void *values, __u32 *count) {
+   union bpf_attr attr = {};
+
+   attr.batch.map_fd = map_fd;
+   attr.batch.in_batch = (__u64)(unsigned long)in_batch;
+   attr.batch.out_batch = (__u64)(unsigned long)out_batch;
+   attr.batch.keys = (__u64)(unsigned long)keys;
+   attr.batch.values = (__u64)(unsigned long)values;
+   attr.batch.count = *count;
+
+   int rc = syscall(SYS_bpf, cmd, &attr, sizeof(attr));
+   *count = attr.batch.count;
+   return rc == 0 ? 0 : errno;
+}
@@ -84,3 +84,20 @@ func (m *MapIterator) Next() (k, v []byte, err error) {
 func (m *MapIterator) Close() error {
 	return nil
 }
+
+const MapBatchSize = 1024
+
+type MapBatchIterator struct {
+}
+
+func NewMapBatchIterator(mapFD MapFD, keySize, valueSize int) (*MapBatchIterator, error) {
+	panic("BPF syscall stub")
+}
+
+func (m *MapBatchIterator) NextBatch() (keys, values []byte, count int, err error) {
+	return
+}
+
+func DeleteMapEntriesBatch(mapFD MapFD, keys []byte, keySize int) error {
+	panic("BPF syscall stub")
+}
@@ -0,0 +1,151 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// CgroupV2RootAuto tells FindCgroupV2Root to use an existing cgroup2 mount of the whole
+	// hierarchy, falling back to a private mount if there isn't one.
+	CgroupV2RootAuto = "auto"
+	// CgroupV2RootPrivate tells FindCgroupV2Root to always use our private mount.
+	CgroupV2RootPrivate = "private"
+
+	// workloadCgroupPrefix is the prefix of the kubelet's cgroups for pods, both with the
+	// cgroupfs ("kubepods/") and systemd ("kubepods.slice/") drivers.
+	workloadCgroupPrefix = "kubepods"
+	// maxWorkloadCgroupDepth is how deep we look below the root for the pods' cgroup.  Typically,
+	// it is a direct child of the root but some setups nest it, e.g. under a per-node slice.
+	maxWorkloadCgroupDepth = 3
+)
+
+// FindCgroupV2Root returns the cgroup v2 mount point to attach cgroup programs to.  root is
+// CgroupV2RootAuto, CgroupV2RootPrivate or the path of a cgroup2 mount to use as is.
+//
+// Our private mount, at /run/calico/cgroup, only covers the workloads if Felix shares the host's
+// cgroup namespace.  With "auto", we prefer an existing mount of the whole hierarchy (such as
+// /sys/fs/cgroup or /sys/fs/cgroup/unified, typically bind-mounted from the host), which works
+// when Felix's container has its own cgroup namespace.
+func FindCgroupV2Root(root string) (string, error) {
+	switch root {
+	case "", CgroupV2RootPrivate:
+		return MaybeMountCgroupV2()
+	case CgroupV2RootAuto:
+		mountPoint, err := findOurCgroupV2Mount()
+		if err != nil {
+			return "", err
+		}
+		if mountPoint == "" {
+			log.Info("No existing cgroup v2 mount of the whole hierarchy, using a private mount.")
+			return MaybeMountCgroupV2()
+		}
+		log.WithField("path", mountPoint).Info("Found existing cgroup v2 mount.")
+		return mountPoint, nil
+	default:
+		isCgroup, err := isCgroupV2(root)
+		if err != nil {
+			return "", err
+		}
+		if !isCgroup {
+			return "", fmt.Errorf("%s is not a cgroup v2 mount", root)
+		}
+		return root, nil
+	}
+}
+
+// findOurCgroupV2Mount is findCgroupV2Mount for our own mountinfo.
+func findOurCgroupV2Mount() (string, error) {
+	mi, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return "", err
+	}
+	defer mi.Close()
+	return findCgroupV2Mount(mi)
+}
+
+// findCgroupV2Mount parses the given mountinfo and returns the first cgroup2 mount point that
+// exposes the root of the hierarchy, ignoring our own private mount.  Returns "" if there isn't one.
+func findCgroupV2Mount(mountinfo io.Reader) (string, error) {
+	sc := bufio.NewScanner(mountinfo)
+	for sc.Scan() {
+		// Format: <id> <parent> <major:minor> <root> <mount point> <options> <optional fields>... - <fs type> <source> <super options>
+		line := sc.Text()
+		parts := strings.SplitN(line, " - ", 2)
+		if len(parts) != 2 {
+			return "", fmt.Errorf("failed to parse mountinfo line %q", line)
+		}
+		columns := strings.Split(parts[0], " ")
+		fsColumns := strings.Split(parts[1], " ")
+		if len(columns) < 5 || len(fsColumns) < 1 {
+			return "", fmt.Errorf("not enough fields from mountinfo line %q", line)
+		}
+		if fsColumns[0] != "cgroup2" {
+			continue
+		}
+		root, mountPoint := columns[3], columns[4]
+		if root != "/" {
+			// Mount of a sub-tree, which won't include the workloads.
+			continue
+		}
+		if filepath.Clean(mountPoint) == cgroupV2PrivatePath {
+			continue
+		}
+		return mountPoint, nil
+	}
+	return "", sc.Err()
+}
+
+// CgroupContainsWorkloads returns true if there is a Kubernetes pod cgroup somewhere below the
+// given cgroup.  Programs attached to a cgroup that doesn't contain the pods' cgroups silently
+// have no effect on the pods.
+func CgroupContainsWorkloads(cgroupPath string) (bool, error) {
+	return containsWorkloadCgroup(cgroupPath, maxWorkloadCgroupDepth)
+}
+
+func containsWorkloadCgroup(dir string, depth int) (bool, error) {
+	if strings.HasPrefix(filepath.Base(dir), workloadCgroupPrefix) {
+		return true, nil
+	}
+	if depth == 0 {
+		return false, nil
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return false, err
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		found, err := containsWorkloadCgroup(filepath.Join(dir, e.Name()), depth-1)
+		if err != nil {
+			return false, err
+		}
+		if found {
+			return true, nil
+		}
+	}
+	return false, nil
+}
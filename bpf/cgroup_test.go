@@ -0,0 +1,69 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestFindCgroupV2Mount(t *testing.T) {
+	RegisterTestingT(t)
+
+	mountinfo := strings.Join([]string{
+		"22 1 0:21 / /proc rw,nosuid,nodev,noexec,relatime shared:12 - proc proc rw",
+		"25 22 0:23 / /sys/fs/cgroup ro,nosuid,nodev,noexec shared:4 - tmpfs tmpfs ro,mode=755",
+		"30 25 0:26 /kubepods/pod1234 /sys/fs/cgroup/pod rw,nosuid,nodev,noexec,relatime - cgroup2 cgroup2 rw",
+		"31 25 0:26 / /run/calico/cgroup rw,relatime - cgroup2 none rw",
+		"32 25 0:26 / /sys/fs/cgroup/unified rw,nosuid,nodev,noexec,relatime shared:5 - cgroup2 cgroup2 rw,nsdelegate",
+	}, "\n")
+	mountPoint, err := findCgroupV2Mount(strings.NewReader(mountinfo))
+	Expect(err).NotTo(HaveOccurred())
+	Expect(mountPoint).To(Equal("/sys/fs/cgroup/unified"), "should skip sub-tree mounts and our private mount")
+
+	mountPoint, err = findCgroupV2Mount(strings.NewReader(mountinfo[:strings.LastIndex(mountinfo, "\n")]))
+	Expect(err).NotTo(HaveOccurred())
+	Expect(mountPoint).To(Equal(""), "should return empty if there's no suitable mount")
+
+	_, err = findCgroupV2Mount(strings.NewReader("bad line"))
+	Expect(err).To(HaveOccurred())
+}
+
+func TestCgroupContainsWorkloads(t *testing.T) {
+	RegisterTestingT(t)
+
+	root, err := ioutil.TempDir("", "cgroup")
+	Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(root)
+
+	Expect(os.MkdirAll(filepath.Join(root, "system.slice", "kubelet.service"), 0700)).To(Succeed())
+	found, err := CgroupContainsWorkloads(root)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(found).To(BeFalse())
+
+	Expect(os.MkdirAll(filepath.Join(root, "node.slice", "kubepods.slice", "kubepods-besteffort.slice"), 0700)).To(Succeed())
+	found, err = CgroupContainsWorkloads(root)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(found).To(BeTrue())
+
+	found, err = CgroupContainsWorkloads(filepath.Join(root, "system.slice"))
+	Expect(err).NotTo(HaveOccurred())
+	Expect(found).To(BeFalse())
+}
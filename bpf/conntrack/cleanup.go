@@ -86,55 +86,42 @@ func WithTimeShim(shim timeshim.Interface) LivenessScannerOpt {
 }
 
 func (l *LivenessScanner) Check(ctKey Key, ctVal Value, get EntryGet) ScanVerdict {
-	if l.cachedKTime == 0 || l.time.Since(l.goTimeOfLastKTimeLookup) > time.Second {
-		l.cachedKTime = l.time.KTimeNanos()
-		l.goTimeOfLastKTimeLookup = l.time.Now()
-	}
-	now := l.cachedKTime
-
-	debug := log.GetLevel() >= log.DebugLevel
+	now := l.kTimeNanos()
 
 	switch ctVal.Type() {
 	case TypeNATForward:
 		// Look up the reverse entry, where we do the book-keeping.
 		revEntry, err := get(ctVal.ReverseNATKey())
-		if err != nil && bpf.IsNotExists(err) {
-			// Forward entry exists but no reverse entry. We might have come across the reverse
-			// entry first and removed it. It is useless on its own, so delete it now.
-			//
-			// N.B. BPF code always creates REV entry before FWD entry, therefore if the REV
-			// entry does not exist now, we are not racing with the BPF code, we must have
-			// removed the entry or there is some external inconsistency. In either case, the
-			// FWD entry should be removed.
-			log.Debug("Found a forward NAT conntrack entry with no reverse entry, removing...")
-			return ScanVerdictDelete
-		} else if err != nil {
-			log.WithError(err).Warn("Failed to look up conntrack entry.")
-			return ScanVerdictOK
+		if err != nil {
+			return reverseEntryLookupFailed(err)
 		}
-		if reason, expired := l.timeouts.EntryExpired(now, ctKey.Proto(), revEntry); expired {
-			if debug {
-				log.WithField("reason", reason).Debug("Deleting expired conntrack forward-NAT entry")
-			}
-			return ScanVerdictDelete
-			// do not delete the reverse entry yet to avoid breaking the iterating
-			// over the map.  We must not delete other than the current key. We remove
-			// it once we come across it again.
-		}
-	case TypeNATReverse:
-		if reason, expired := l.timeouts.EntryExpired(now, ctKey.Proto(), ctVal); expired {
-			if debug {
-				log.WithField("reason", reason).Debug("Deleting expired conntrack reverse-NAT entry")
-			}
-			return ScanVerdictDelete
-		}
-	case TypeNormal:
-		if reason, expired := l.timeouts.EntryExpired(now, ctKey.Proto(), ctVal); expired {
-			if debug {
-				log.WithField("reason", reason).Debug("Deleting expired normal conntrack entry")
-			}
-			return ScanVerdictDelete
+		reason, expired := l.timeouts.EntryExpired(now, ctKey.Proto(), revEntry)
+		return expiredVerdict(ctVal.Type(), reason, expired)
+	case TypeNATReverse, TypeNormal:
+		reason, expired := l.timeouts.EntryExpired(now, ctKey.Proto(), ctVal)
+		return expiredVerdict(ctVal.Type(), reason, expired)
+	default:
+		log.WithField("type", ctVal.Type()).Warn("Unknown conntrack entry type!")
+	}
+
+	return ScanVerdictOK
+}
+
+// CheckV6 is the IPv6 equivalent of Check.
+func (l *LivenessScanner) CheckV6(ctKey KeyV6, ctVal ValueV6, get EntryGetV6) ScanVerdict {
+	now := l.kTimeNanos()
+
+	switch ctVal.Type() {
+	case TypeNATForward:
+		revEntry, err := get(ctVal.ReverseNATKey())
+		if err != nil {
+			return reverseEntryLookupFailed(err)
 		}
+		reason, expired := l.timeouts.EntryExpiredV6(now, ctKey.Proto(), revEntry)
+		return expiredVerdict(ctVal.Type(), reason, expired)
+	case TypeNATReverse, TypeNormal:
+		reason, expired := l.timeouts.EntryExpiredV6(now, ctKey.Proto(), ctVal)
+		return expiredVerdict(ctVal.Type(), reason, expired)
 	default:
 		log.WithField("type", ctVal.Type()).Warn("Unknown conntrack entry type!")
 	}
@@ -142,19 +129,76 @@ func (l *LivenessScanner) Check(ctKey Key, ctVal Value, get EntryGet) ScanVerdic
 	return ScanVerdictOK
 }
 
+func (l *LivenessScanner) kTimeNanos() int64 {
+	if l.cachedKTime == 0 || l.time.Since(l.goTimeOfLastKTimeLookup) > time.Second {
+		l.cachedKTime = l.time.KTimeNanos()
+		l.goTimeOfLastKTimeLookup = l.time.Now()
+	}
+	return l.cachedKTime
+}
+
+// reverseEntryLookupFailed returns the verdict for a forward NAT entry whose reverse entry we
+// failed to look up.
+func reverseEntryLookupFailed(err error) ScanVerdict {
+	if bpf.IsNotExists(err) {
+		// Forward entry exists but no reverse entry. We might have come across the reverse
+		// entry first and removed it. It is useless on its own, so delete it now.
+		//
+		// N.B. BPF code always creates REV entry before FWD entry, therefore if the REV
+		// entry does not exist now, we are not racing with the BPF code, we must have
+		// removed the entry or there is some external inconsistency. In either case, the
+		// FWD entry should be removed.
+		log.Debug("Found a forward NAT conntrack entry with no reverse entry, removing...")
+		return ScanVerdictDelete
+	}
+	log.WithError(err).Warn("Failed to look up conntrack entry.")
+	return ScanVerdictOK
+}
+
+// expiredVerdict returns the verdict for an entry of the given type from the result of
+// EntryExpired.  For a forward NAT entry, that's the result for its reverse entry.
+func expiredVerdict(entryType uint8, reason string, expired bool) ScanVerdict {
+	if !expired {
+		return ScanVerdictOK
+	}
+	if log.GetLevel() >= log.DebugLevel {
+		log.WithFields(log.Fields{
+			"reason": reason,
+			"type":   entryType,
+		}).Debug("Deleting expired conntrack entry")
+	}
+	// For a forward NAT entry, do not delete the reverse entry yet to avoid breaking the
+	// iterating over the map.  We must not delete other than the current key. We remove it
+	// once we come across it again.
+	return ScanVerdictDelete
+}
+
 // EntryExpired checks whether a given conntrack table entry for a given
 // protocol and time, is expired.
 func (t *Timeouts) EntryExpired(nowNanos int64, proto uint8, entry Value) (reason string, expired bool) {
-	sinceCreation := time.Duration(nowNanos - entry.Created())
+	return t.entryExpired(nowNanos, proto, entry.Created(), entry.LastSeen(), entry.IsForwardDSR(), entry.Data())
+}
+
+// EntryExpiredV6 is the IPv6 equivalent of EntryExpired.
+func (t *Timeouts) EntryExpiredV6(nowNanos int64, proto uint8, entry ValueV6) (reason string, expired bool) {
+	return t.entryExpired(nowNanos, proto, entry.Created(), entry.LastSeen(), entry.IsForwardDSR(), entry.Data())
+}
+
+func (t *Timeouts) entryExpired(
+	nowNanos int64,
+	proto uint8,
+	created, lastSeen int64,
+	dsr bool,
+	data EntryData,
+) (reason string, expired bool) {
+	sinceCreation := time.Duration(nowNanos - created)
 	if sinceCreation < t.CreationGracePeriod {
 		log.Debug("Conntrack entry in creation grace period. Ignoring.")
 		return
 	}
-	age := time.Duration(nowNanos - entry.LastSeen())
+	age := time.Duration(nowNanos - lastSeen)
 	switch proto {
 	case ProtoTCP:
-		dsr := entry.IsForwardDSR()
-		data := entry.Data()
 		rstSeen := data.RSTSeen()
 		if rstSeen && age > t.TCPResetSeen {
 			return "RST seen", true
@@ -213,94 +257,126 @@ func NewStaleNATScanner(frontendHasBackend NATChecker) *StaleNATScanner {
 
 // Check checks the conntrack entry
 func (sns *StaleNATScanner) Check(k Key, v Value, _ EntryGet) ScanVerdict {
-	debug := log.GetLevel() >= log.DebugLevel
+	switch v.Type() {
+	case TypeNormal:
+		// skip non-NAT entry
+
+	case TypeNATReverse:
+		return sns.checkNATReverse(k, v.OrigIP(), v.OrigPort())
 
+	case TypeNATForward:
+		return sns.checkNATForward(k, v.ReverseNATKey())
+
+	default:
+		log.WithField("conntrack.Value.Type()", v.Type()).Warn("Unknown type")
+	}
+
+	return ScanVerdictOK
+}
+
+// CheckV6 is the IPv6 equivalent of Check.
+func (sns *StaleNATScanner) CheckV6(k KeyV6, v ValueV6, _ EntryGetV6) ScanVerdict {
 	switch v.Type() {
 	case TypeNormal:
 		// skip non-NAT entry
 
 	case TypeNATReverse:
-		proto := k.Proto()
-		ipA := k.AddrA()
-		ipB := k.AddrB()
-
-		portA := k.PortA()
-		portB := k.PortB()
-
-		svcIP := v.OrigIP()
-		svcPort := v.OrigPort()
-
-		// We cannot tell which leg is EP and which is the client, we must
-		// try both. If there is a record for one of them, it is still most
-		// likely an active entry.
-		if !sns.natChecker.ConntrackFrontendHasBackend(svcIP, svcPort, ipA, portA, proto) &&
-			!sns.natChecker.ConntrackFrontendHasBackend(svcIP, svcPort, ipB, portB, proto) {
-			if debug {
-				log.WithField("key", k).Debugf("TypeNATReverse is stale")
-			}
-			return ScanVerdictDelete
-		}
-		if debug {
-			log.WithField("key", k).Debugf("TypeNATReverse still active")
-		}
+		return sns.checkNATReverse(k, v.OrigIP(), v.OrigPort())
 
 	case TypeNATForward:
-		proto := k.Proto()
-		kA := k.AddrA()
-		kAport := k.PortA()
-		kB := k.AddrB()
-		kBport := k.PortB()
-		revKey := v.ReverseNATKey()
-		revA := revKey.AddrA()
-		revAport := revKey.PortA()
-		revB := revKey.AddrB()
-		revBport := revKey.PortB()
-
-		var (
-			svcIP, epIP     net.IP
-			svcPort, epPort uint16
-		)
-
-		// Because client IP/Port are both in fwd key and rev key, we can
-		// can tell which one it is and thus determine exactly meaning of
-		// the other values.
-		if kA.Equal(revA) && kAport == revAport {
-			epIP = revB
-			epPort = revBport
-			svcIP = kB
-			svcPort = kBport
-		} else if kB.Equal(revA) && kBport == revAport {
-			epIP = revB
-			epPort = revBport
-			svcIP = kA
-			svcPort = kAport
-		} else if kA.Equal(revB) && kAport == revBport {
-			epIP = revA
-			epPort = revAport
-			svcIP = kB
-			svcPort = kBport
-		} else if kB.Equal(revB) && kBport == revBport {
-			epIP = revA
-			epPort = revAport
-			svcIP = kA
-			svcPort = kAport
-		} else {
-			log.WithFields(log.Fields{"key": k, "value": v}).Error("Mismatch between key and rev key")
-			return ScanVerdictOK // don't touch, will get deleted when expired
-		}
+		return sns.checkNATForward(k, v.ReverseNATKey())
 
-		if !sns.natChecker.ConntrackFrontendHasBackend(svcIP, svcPort, epIP, epPort, proto) {
-			if debug {
-				log.WithField("key", k).Debugf("TypeNATForward is stale")
-			}
-			return ScanVerdictDelete
-		}
+	default:
+		log.WithField("conntrack.Value.Type()", v.Type()).Warn("Unknown type")
+	}
+
+	return ScanVerdictOK
+}
+
+// checkNATReverse checks a reverse NAT entry, which records the service that the connection was
+// NATted from.
+func (sns *StaleNATScanner) checkNATReverse(k KeyInterface, svcIP net.IP, svcPort uint16) ScanVerdict {
+	debug := log.GetLevel() >= log.DebugLevel
+
+	proto := k.Proto()
+	ipA := k.AddrA()
+	ipB := k.AddrB()
+
+	portA := k.PortA()
+	portB := k.PortB()
+
+	// We cannot tell which leg is EP and which is the client, we must
+	// try both. If there is a record for one of them, it is still most
+	// likely an active entry.
+	if !sns.natChecker.ConntrackFrontendHasBackend(svcIP, svcPort, ipA, portA, proto) &&
+		!sns.natChecker.ConntrackFrontendHasBackend(svcIP, svcPort, ipB, portB, proto) {
 		if debug {
-			log.WithField("key", k).Debugf("TypeNATForward still active")
+			log.WithField("key", k).Debugf("TypeNATReverse is stale")
 		}
+		return ScanVerdictDelete
+	}
+	if debug {
+		log.WithField("key", k).Debugf("TypeNATReverse still active")
+	}
 
-	default:
-		log.WithField("conntrack.Value.Type()", v.Type()).Warn("Unknown type")
+	return ScanVerdictOK
+}
+
+// checkNATForward checks a forward NAT entry against the key of its reverse entry.
+func (sns *StaleNATScanner) checkNATForward(k, revKey KeyInterface) ScanVerdict {
+	debug := log.GetLevel() >= log.DebugLevel
+
+	proto := k.Proto()
+	kA := k.AddrA()
+	kAport := k.PortA()
+	kB := k.AddrB()
+	kBport := k.PortB()
+	revA := revKey.AddrA()
+	revAport := revKey.PortA()
+	revB := revKey.AddrB()
+	revBport := revKey.PortB()
+
+	var (
+		svcIP, epIP     net.IP
+		svcPort, epPort uint16
+	)
+
+	// Because client IP/Port are both in fwd key and rev key, we can
+	// can tell which one it is and thus determine exactly meaning of
+	// the other values.
+	if kA.Equal(revA) && kAport == revAport {
+		epIP = revB
+		epPort = revBport
+		svcIP = kB
+		svcPort = kBport
+	} else if kB.Equal(revA) && kBport == revAport {
+		epIP = revB
+		epPort = revBport
+		svcIP = kA
+		svcPort = kAport
+	} else if kA.Equal(revB) && kAport == revBport {
+		epIP = revA
+		epPort = revAport
+		svcIP = kB
+		svcPort = kBport
+	} else if kB.Equal(revB) && kBport == revBport {
+		epIP = revA
+		epPort = revAport
+		svcIP = kA
+		svcPort = kAport
+	} else {
+		log.WithFields(log.Fields{"key": k, "revKey": revKey}).Error("Mismatch between key and rev key")
+		return ScanVerdictOK // don't touch, will get deleted when expired
+	}
+
+	if !sns.natChecker.ConntrackFrontendHasBackend(svcIP, svcPort, epIP, epPort, proto) {
+		if debug {
+			log.WithField("key", k).Debugf("TypeNATForward is stale")
+		}
+		return ScanVerdictDelete
+	}
+	if debug {
+		log.WithField("key", k).Debugf("TypeNATForward still active")
 	}
 
 	return ScanVerdictOK
@@ -315,3 +391,14 @@ func (sns *StaleNATScanner) IterationStart() {
 func (sns *StaleNATScanner) IterationEnd() {
 	sns.natChecker.ConntrackScanEnd()
 }
+
+// IterationPause satisfies EntryScannerPausable.  It lets the proxy update the NAT maps while a
+// rate-limited scan sleeps.
+func (sns *StaleNATScanner) IterationPause() {
+	sns.natChecker.ConntrackScanEnd()
+}
+
+// IterationResume satisfies EntryScannerPausable
+func (sns *StaleNATScanner) IterationResume() {
+	sns.natChecker.ConntrackScanStart()
+}
@@ -18,6 +18,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"net"
+	"sync"
 	"time"
 
 	"github.com/projectcalico/felix/bpf"
@@ -236,3 +237,224 @@ var _ = Describe("BPF Conntrack StaleNATScanner", func() {
 		),
 	)
 })
+
+type countingScanner struct {
+	lock  sync.Mutex
+	iters int
+}
+
+func (c *countingScanner) Check(conntrack.Key, conntrack.Value, conntrack.EntryGet) conntrack.ScanVerdict {
+	return conntrack.ScanVerdictOK
+}
+
+func (c *countingScanner) IterationStart() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.iters++
+}
+
+func (c *countingScanner) IterationEnd() {}
+
+func (c *countingScanner) Iterations() int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.iters
+}
+
+// pausingScanner records how often it is paused and whether it's asked to check an entry while
+// paused.
+type pausingScanner struct {
+	paused        bool
+	pauses        int
+	checkedPaused bool
+}
+
+func (p *pausingScanner) Check(conntrack.Key, conntrack.Value, conntrack.EntryGet) conntrack.ScanVerdict {
+	if p.paused {
+		p.checkedPaused = true
+	}
+	return conntrack.ScanVerdictOK
+}
+
+func (p *pausingScanner) IterationPause() {
+	p.paused = true
+	p.pauses++
+}
+
+func (p *pausingScanner) IterationResume() {
+	p.paused = false
+}
+
+var _ = Describe("BPF Conntrack Scanner", func() {
+	var (
+		counter *countingScanner
+		scanner *conntrack.Scanner
+	)
+
+	BeforeEach(func() {
+		counter = &countingScanner{}
+		scanner = conntrack.NewScanner(mock.NewMockMap(conntrack.MapParams), counter)
+	})
+
+	AfterEach(func() {
+		scanner.Stop()
+	})
+
+	It("should scan on demand", func() {
+		scanner.Start()
+		Eventually(counter.Iterations).Should(Equal(1))
+
+		scanner.TriggerScan()
+		Eventually(counter.Iterations, 2*conntrack.MinTriggeredScanInterval).Should(Equal(2))
+	})
+
+	It("should coalesce pending triggers without blocking", func() {
+		for i := 0; i < 10; i++ {
+			scanner.TriggerScan()
+		}
+		Expect(counter.Iterations()).To(Equal(0))
+
+		scanner.Start()
+		Eventually(counter.Iterations, 2*conntrack.MinTriggeredScanInterval).Should(Equal(2))
+		Consistently(counter.Iterations, conntrack.MinTriggeredScanInterval+100*time.Millisecond).Should(Equal(2))
+	})
+
+	It("should still do triggered scans when the pacer defers periodic ones", func() {
+		scanner.SetPacer(func() bool { return true })
+		scanner.Start()
+		Eventually(counter.Iterations).Should(Equal(1))
+
+		scanner.TriggerScan()
+		Eventually(counter.Iterations, 2*conntrack.MinTriggeredScanInterval).Should(Equal(2))
+	})
+
+	It("should include scanners that are added while it's running", func() {
+		scanner.Start()
+		Eventually(counter.Iterations).Should(Equal(1))
+
+		added := &countingScanner{}
+		scanner.Add(added)
+		scanner.TriggerScan()
+		Eventually(added.Iterations, 2*conntrack.MinTriggeredScanInterval).Should(Equal(1))
+	})
+
+	It("should limit the rate that it visits entries", func() {
+		ctMap := mock.NewMockMap(conntrack.MapParams)
+		for port := 0; port < 3000; port++ {
+			k := conntrack.NewKey(conntrack.ProtoTCP, ip1, uint16(port), ip2, 80)
+			err := ctMap.Update(k.AsBytes(), genericJustCreated.AsBytes())
+			Expect(err).NotTo(HaveOccurred())
+		}
+		scanner = conntrack.NewScanner(ctMap, counter)
+		scanner.SetRateLimit(10000)
+
+		start := time.Now()
+		scanner.Scan()
+		Expect(time.Since(start)).To(BeNumerically(">=", 300*time.Millisecond))
+	})
+
+	It("should pause pausable scanners while it sleeps", func() {
+		ctMap := mock.NewMockMap(conntrack.MapParams)
+		for port := 0; port < 3000; port++ {
+			k := conntrack.NewKey(conntrack.ProtoTCP, ip1, uint16(port), ip2, 80)
+			err := ctMap.Update(k.AsBytes(), genericJustCreated.AsBytes())
+			Expect(err).NotTo(HaveOccurred())
+		}
+		pauser := &pausingScanner{}
+		scanner = conntrack.NewScanner(ctMap, pauser)
+		scanner.SetRateLimit(10000)
+
+		scanner.Scan()
+		Expect(pauser.pauses).To(BeNumerically(">", 0))
+		Expect(pauser.paused).To(BeFalse())
+		Expect(pauser.checkedPaused).To(BeFalse())
+	})
+})
+
+var _ = Describe("BPF Conntrack IPv6", func() {
+	clientIP := net.ParseIP("fd00::1")
+	clientPort := uint16(1111)
+
+	svcIP := net.ParseIP("fd00:10::1")
+	svcPort := uint16(4321)
+
+	backendIP := net.ParseIP("fd00:20::2")
+	backendPort := uint16(2222)
+
+	fwdKey := conntrack.NewKeyV6(conntrack.ProtoTCP, clientIP, clientPort, svcIP, svcPort)
+	revKey := conntrack.NewKeyV6(conntrack.ProtoTCP, clientIP, clientPort, backendIP, backendPort)
+
+	It("should round trip keys", func() {
+		Expect(fwdKey.Proto()).To(Equal(uint8(conntrack.ProtoTCP)))
+		Expect(fwdKey.AddrA().Equal(clientIP)).To(BeTrue())
+		Expect(fwdKey.PortA()).To(Equal(clientPort))
+		Expect(fwdKey.AddrB().Equal(svcIP)).To(BeTrue())
+		Expect(fwdKey.PortB()).To(Equal(svcPort))
+	})
+
+	It("should round trip NAT values", func() {
+		established := conntrack.Leg{SynSeen: true, AckSeen: true}
+		rev := conntrack.NewValueV6NATReverse(now-time.Second, now, 0, established, established,
+			nil, svcIP, svcPort)
+		Expect(rev.Type()).To(Equal(conntrack.TypeNATReverse))
+		Expect(rev.OrigIP().Equal(svcIP)).To(BeTrue())
+		Expect(rev.OrigPort()).To(Equal(svcPort))
+		Expect(rev.Data().Established()).To(BeTrue())
+
+		fwd := conntrack.NewValueV6NATForward(now-time.Second, now, 0, revKey)
+		Expect(fwd.Type()).To(Equal(conntrack.TypeNATForward))
+		Expect(fwd.ReverseNATKey()).To(Equal(revKey))
+	})
+
+	Describe("with a liveness scanner", func() {
+		var (
+			ctMap    *mock.Map
+			scanner  *conntrack.Scanner
+			mockTime *mocktime.MockTime
+		)
+
+		BeforeEach(func() {
+			mockTime = mocktime.New()
+			ctMap = mock.NewMockMap(conntrack.MapParamsV6)
+			scanner = conntrack.NewScannerV6(ctMap,
+				conntrack.NewLivenessScanner(timeouts, false, conntrack.WithTimeShim(mockTime)))
+		})
+
+		It("should keep live NAT entries and remove expired ones", func() {
+			leg := conntrack.Leg{SynSeen: true, AckSeen: true}
+			rev := conntrack.NewValueV6NATReverse(now-time.Minute, now-time.Second, 0, leg, leg, nil, svcIP, svcPort)
+			fwd := conntrack.NewValueV6NATForward(now-time.Minute, now-time.Second, 0, revKey)
+			Expect(ctMap.Update(revKey.AsBytes(), rev.AsBytes())).To(Succeed())
+			Expect(ctMap.Update(fwdKey.AsBytes(), fwd.AsBytes())).To(Succeed())
+
+			scanner.Scan()
+			Expect(ctMap.Contents).To(HaveLen(2))
+
+			mockTime.IncrementTime(2 * time.Hour)
+			scanner.Scan()
+			Expect(ctMap.Contents).To(BeEmpty())
+		})
+
+		It("should remove a forward entry without its reverse entry", func() {
+			fwd := conntrack.NewValueV6NATForward(now-time.Minute, now-time.Second, 0, revKey)
+			Expect(ctMap.Update(fwdKey.AsBytes(), fwd.AsBytes())).To(Succeed())
+
+			scanner.Scan()
+			Expect(ctMap.Contents).To(BeEmpty())
+		})
+	})
+
+	It("should check both families' entries with the StaleNATScanner", func() {
+		var checked []string
+		staleNATScanner := conntrack.NewStaleNATScanner(dummyNATChecker{
+			check: func(fIP net.IP, fPort uint16, bIP net.IP, bPort uint16, proto uint8) bool {
+				checked = append(checked, fmt.Sprintf("%v:%d -> %v:%d", fIP, fPort, bIP, bPort))
+				return false
+			},
+		})
+
+		verdict := staleNATScanner.CheckV6(fwdKey, conntrack.NewValueV6NATForward(0, 0, 0, revKey), nil)
+		Expect(verdict).To(Equal(conntrack.ScanVerdictDelete))
+		Expect(checked).To(Equal([]string{"fd00:10::1:4321 -> fd00:20::2:2222"}))
+	})
+})
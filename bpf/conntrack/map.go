@@ -26,6 +26,33 @@ import (
 	"github.com/projectcalico/felix/bpf"
 )
 
+// KeyInterface is implemented by the conntrack keys of both IP families so that the scanners
+// and dumping tools can handle either.
+type KeyInterface interface {
+	Proto() uint8
+	AddrA() net.IP
+	PortA() uint16
+	AddrB() net.IP
+	PortB() uint16
+	AsBytes() []byte
+	String() string
+}
+
+// ValueInterface is implemented by the conntrack values of both IP families.  ReverseNATKey isn't
+// included because it returns the key type of the family.
+type ValueInterface interface {
+	Created() int64
+	LastSeen() int64
+	Type() uint8
+	Flags() uint8
+	OrigIP() net.IP
+	OrigPort() uint16
+	Data() EntryData
+	IsForwardDSR() bool
+	AsBytes() []byte
+	String() string
+}
+
 // struct calico_ct_key {
 //   uint32_t protocol;
 //   __be32 addr_a, addr_b; // NBO
@@ -178,7 +205,7 @@ func NewValueNormal(created, lastSeen time.Duration, flags uint8, legA, legB Leg
 	initValue(&v, created, lastSeen, TypeNormal, flags)
 
 	copy(v[24:36], legA.AsBytes())
-	copy(v[36:48], legA.AsBytes())
+	copy(v[36:48], legB.AsBytes())
 
 	return v
 }
@@ -204,7 +231,7 @@ func NewValueNATReverse(created, lastSeen time.Duration, flags uint8, legA, legB
 	initValue(&v, created, lastSeen, TypeNATReverse, flags)
 
 	copy(v[24:36], legA.AsBytes())
-	copy(v[36:48], legA.AsBytes())
+	copy(v[36:48], legB.AsBytes())
 
 	copy(v[48:52], origIP.To4())
 	binary.LittleEndian.PutUint16(v[52:54], origPort)
@@ -328,9 +355,8 @@ func (e Value) Data() EntryData {
 	}
 }
 
-func (e Value) String() string {
+func flagsString(flags uint8) string {
 	flagsStr := ""
-	flags := e.Flags()
 
 	if flags == 0 {
 		flagsStr = " <none>"
@@ -356,8 +382,12 @@ func (e Value) String() string {
 		}
 	}
 
+	return flagsStr
+}
+
+func (e Value) String() string {
 	ret := fmt.Sprintf("Entry{Type:%d, Created:%d, LastSeen:%d, Flags:%s ",
-		e.Type(), e.Created(), e.LastSeen(), flagsStr)
+		e.Type(), e.Created(), e.LastSeen(), flagsString(e.Flags()))
 
 	switch e.Type() {
 	case TypeNATForward:
@@ -0,0 +1,271 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conntrack
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+
+	"github.com/projectcalico/felix/bpf"
+)
+
+// struct calico_ct_v6_key {
+//   uint32_t protocol;
+//   ipv6_addr_t addr_a, addr_b; // NBO
+//   uint16_t port_a, port_b; // HBO
+// };
+const KeyV6Size = 40
+const ValueV6Size = 88
+
+type KeyV6 [KeyV6Size]byte
+
+func (k KeyV6) AsBytes() []byte {
+	return k[:]
+}
+
+func (k KeyV6) Proto() uint8 {
+	return uint8(binary.LittleEndian.Uint32(k[:4]))
+}
+
+func (k KeyV6) AddrA() net.IP {
+	return k[4:20]
+}
+
+func (k KeyV6) PortA() uint16 {
+	return binary.LittleEndian.Uint16(k[36:38])
+}
+
+func (k KeyV6) AddrB() net.IP {
+	return k[20:36]
+}
+
+func (k KeyV6) PortB() uint16 {
+	return binary.LittleEndian.Uint16(k[38:40])
+}
+
+func (k KeyV6) String() string {
+	return fmt.Sprintf("ConntrackKey{proto=%v %v:%v <-> %v:%v}",
+		k.Proto(), k.AddrA(), k.PortA(), k.AddrB(), k.PortB())
+}
+
+func NewKeyV6(proto uint8, ipA net.IP, portA uint16, ipB net.IP, portB uint16) KeyV6 {
+	var k KeyV6
+	binary.LittleEndian.PutUint32(k[:4], uint32(proto))
+	copy(k[4:20], ipA.To16())
+	copy(k[20:36], ipB.To16())
+	binary.LittleEndian.PutUint16(k[36:38], portA)
+	binary.LittleEndian.PutUint16(k[38:40], portB)
+	return k
+}
+
+// struct calico_ct_v6_value {
+//  __u64 created;
+//  __u64 last_seen; // 8
+//  __u8 type;     // 16
+//  __u8 flags;     // 17
+//  __u8 pad0[6];
+//  union {
+//    // CALI_CT_TYPE_NORMAL and CALI_CT_TYPE_NAT_REV.
+//    struct {
+//      struct calico_ct_leg a_to_b; // 24
+//      struct calico_ct_leg b_to_a; // 36
+//
+//      // CALI_CT_TYPE_NAT_REV only.
+//      ipv6_addr_t orig_dst;              // 48
+//      __u16 orig_port;                   // 64
+//      __u8 pad1[2];                      // 66
+//      ipv6_addr_t tun_ip;                // 68
+//      __u32 pad3;                        // 84
+//    };
+//
+//    // CALI_CT_TYPE_NAT_FWD; key for the CALI_CT_TYPE_NAT_REV entry.
+//    struct {
+//      struct calico_ct_v6_key nat_rev_key;  // 24
+//      __u8 pad2[24];
+//    };
+//  };
+// };
+//
+// The header (timestamps, type and flags) and the legs have the same layout as in the IPv4
+// Value.
+type ValueV6 [ValueV6Size]byte
+
+func (e ValueV6) Created() int64 {
+	return int64(binary.LittleEndian.Uint64(e[:8]))
+}
+
+func (e ValueV6) LastSeen() int64 {
+	return int64(binary.LittleEndian.Uint64(e[8:16]))
+}
+
+func (e ValueV6) Type() uint8 {
+	return e[16]
+}
+
+func (e ValueV6) Flags() uint8 {
+	return e[17]
+}
+
+// OrigIP returns the original destination IP, valid only if Type() is TypeNormal or TypeNATReverse
+func (e ValueV6) OrigIP() net.IP {
+	return e[48:64]
+}
+
+// OrigPort returns the original destination port, valid only if Type() is TypeNormal or TypeNATReverse
+func (e ValueV6) OrigPort() uint16 {
+	return binary.LittleEndian.Uint16(e[64:66])
+}
+
+func (e ValueV6) ReverseNATKey() KeyV6 {
+	var ret KeyV6
+	copy(ret[:], e[24:24+KeyV6Size])
+	return ret
+}
+
+// AsBytes returns the value as slice of bytes
+func (e ValueV6) AsBytes() []byte {
+	return e[:]
+}
+
+func (e ValueV6) IsForwardDSR() bool {
+	return e.Flags()&FlagNATFwdDsr != 0
+}
+
+func (e ValueV6) Data() EntryData {
+	return EntryData{
+		A2B:      readConntrackLeg(e[24:36]),
+		B2A:      readConntrackLeg(e[36:48]),
+		OrigDst:  e[48:64],
+		OrigPort: binary.LittleEndian.Uint16(e[64:66]),
+		TunIP:    e[68:84],
+	}
+}
+
+func (e ValueV6) String() string {
+	ret := fmt.Sprintf("Entry{Type:%d, Created:%d, LastSeen:%d, Flags:%s ",
+		e.Type(), e.Created(), e.LastSeen(), flagsString(e.Flags()))
+
+	switch e.Type() {
+	case TypeNATForward:
+		ret += fmt.Sprintf("REVKey : %s", e.ReverseNATKey().String())
+	case TypeNormal, TypeNATReverse:
+		ret += fmt.Sprintf("Data: %+v", e.Data())
+	default:
+		ret += "TYPE INVALID"
+	}
+
+	return ret + "}"
+}
+
+func initValueV6(v *ValueV6, created, lastSeen time.Duration, typ, flags uint8) {
+	binary.LittleEndian.PutUint64(v[:8], uint64(created))
+	binary.LittleEndian.PutUint64(v[8:16], uint64(lastSeen))
+	v[16] = typ
+	v[17] = flags
+}
+
+// NewValueV6Normal creates a new ValueV6 of type TypeNormal based on the given parameters
+func NewValueV6Normal(created, lastSeen time.Duration, flags uint8, legA, legB Leg) ValueV6 {
+	v := ValueV6{}
+
+	initValueV6(&v, created, lastSeen, TypeNormal, flags)
+
+	copy(v[24:36], legA.AsBytes())
+	copy(v[36:48], legB.AsBytes())
+
+	return v
+}
+
+// NewValueV6NATForward creates a new ValueV6 of type TypeNATForward for the given
+// arguments and the reverse key
+func NewValueV6NATForward(created, lastSeen time.Duration, flags uint8, revKey KeyV6) ValueV6 {
+	v := ValueV6{}
+
+	initValueV6(&v, created, lastSeen, TypeNATForward, flags)
+
+	copy(v[24:24+KeyV6Size], revKey.AsBytes())
+
+	return v
+}
+
+// NewValueV6NATReverse creates a new ValueV6 of type TypeNATReverse for the given
+// arguments and reverse parameters
+func NewValueV6NATReverse(created, lastSeen time.Duration, flags uint8, legA, legB Leg,
+	tunnelIP, origIP net.IP, origPort uint16) ValueV6 {
+	v := ValueV6{}
+
+	initValueV6(&v, created, lastSeen, TypeNATReverse, flags)
+
+	copy(v[24:36], legA.AsBytes())
+	copy(v[36:48], legB.AsBytes())
+
+	copy(v[48:64], origIP.To16())
+	binary.LittleEndian.PutUint16(v[64:66], origPort)
+
+	copy(v[68:84], tunnelIP.To16())
+
+	return v
+}
+
+var MapParamsV6 = bpf.MapParameters{
+	Filename:   "/sys/fs/bpf/tc/globals/cali_v6_ct",
+	Type:       "hash",
+	KeySize:    KeyV6Size,
+	ValueSize:  ValueV6Size,
+	MaxEntries: MaxEntries,
+	Name:       "cali_v6_ct",
+	Flags:      unix.BPF_F_NO_PREALLOC,
+}
+
+func MapV6(mc *bpf.MapContext) bpf.Map {
+	return mc.NewPinnedMap(MapParamsV6)
+}
+
+func KeyV6FromBytes(k []byte) KeyV6 {
+	var ctKey KeyV6
+	if len(k) != len(ctKey) {
+		log.Panic("Key has unexpected length")
+	}
+	copy(ctKey[:], k[:])
+	return ctKey
+}
+
+func ValueV6FromBytes(v []byte) ValueV6 {
+	var ctVal ValueV6
+	if len(v) != len(ctVal) {
+		log.Panic("Value has unexpected length")
+	}
+	copy(ctVal[:], v[:])
+	return ctVal
+}
+
+type MapMemV6 map[KeyV6]ValueV6
+
+// LoadMapMemV6 loads the IPv6 conntrack map into memory
+func LoadMapMemV6(m bpf.Map) (MapMemV6, error) {
+	ret := make(MapMemV6)
+
+	err := m.Iter(func(k, v []byte) bpf.IteratorAction {
+		ret[KeyV6FromBytes(k)] = ValueV6FromBytes(v)
+		return bpf.IterNone
+	})
+
+	return ret, err
+}
@@ -0,0 +1,129 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conntrack
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	gaugeEntries = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "felix_bpf_conntrack_entries",
+		Help: "Number of entries in the BPF conntrack map at the end of the last scan.",
+	})
+	gaugeVecEntriesByProto = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "felix_bpf_conntrack_entries_by_protocol",
+		Help: "Number of entries in the BPF conntrack map at the end of the last scan, by protocol.",
+	}, []string{"protocol"})
+	gaugeVecTCPEntriesByState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "felix_bpf_conntrack_tcp_entries_by_state",
+		Help: "Number of TCP connections in the BPF conntrack map at the end of the last scan, by state.",
+	}, []string{"state"})
+	gaugeFillPercent = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "felix_bpf_conntrack_map_fill_percent",
+		Help: "Percentage of the BPF conntrack map's capacity in use at the end of the last scan.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(gaugeEntries)
+	prometheus.MustRegister(gaugeVecEntriesByProto)
+	prometheus.MustRegister(gaugeVecTCPEntriesByState)
+	prometheus.MustRegister(gaugeFillPercent)
+}
+
+// The label values of the metrics.  We always export every value, so that a count that drops to
+// zero reads as zero rather than as the last non-zero value.
+var (
+	metricsProtos    = []string{"tcp", "udp", "icmp", "other"}
+	metricsTCPStates = []string{"pre-established", "established", "fin", "rst"}
+)
+
+// MetricsScanner is an EntryScannerSynced that counts the entries in the conntrack map and exports
+// the counts as Prometheus gauges at the end of each scan.  It should run after the
+// LivenessScanner so that it doesn't count entries that the scan deletes.  The gauges are global,
+// so there should only be one MetricsScanner.
+type MetricsScanner struct {
+	maxEntries int
+
+	numEntries int
+	byProto    map[string]int
+	tcpByState map[string]int
+}
+
+// NewMetricsScanner returns a MetricsScanner for a conntrack map with the given capacity.
+func NewMetricsScanner(maxEntries int) *MetricsScanner {
+	return &MetricsScanner{
+		maxEntries: maxEntries,
+	}
+}
+
+func (s *MetricsScanner) IterationStart() {
+	s.numEntries = 0
+	s.byProto = map[string]int{}
+	s.tcpByState = map[string]int{}
+}
+
+func (s *MetricsScanner) Check(k Key, v Value, _ EntryGet) ScanVerdict {
+	s.numEntries++
+	proto := metricsProtoName(k.Proto())
+	s.byProto[proto]++
+	// The NAT forward entry only points at the reverse entry, which tracks the connection.
+	if proto == "tcp" && v.Type() != TypeNATForward {
+		s.tcpByState[tcpState(v)]++
+	}
+	return ScanVerdictOK
+}
+
+func (s *MetricsScanner) IterationEnd() {
+	gaugeEntries.Set(float64(s.numEntries))
+	for _, p := range metricsProtos {
+		gaugeVecEntriesByProto.WithLabelValues(p).Set(float64(s.byProto[p]))
+	}
+	for _, state := range metricsTCPStates {
+		gaugeVecTCPEntriesByState.WithLabelValues(state).Set(float64(s.tcpByState[state]))
+	}
+	if s.maxEntries > 0 {
+		gaugeFillPercent.Set(float64(s.numEntries) * 100 / float64(s.maxEntries))
+	}
+}
+
+func metricsProtoName(proto uint8) string {
+	switch proto {
+	case ProtoTCP:
+		return "tcp"
+	case ProtoUDP:
+		return "udp"
+	case ProtoICMP:
+		return "icmp"
+	}
+	return "other"
+}
+
+// tcpState classifies a TCP entry in the same way as Timeouts.EntryExpired, which decides which
+// timeout applies to it.
+func tcpState(v Value) string {
+	data := v.Data()
+	dsr := v.IsForwardDSR()
+	switch {
+	case data.RSTSeen():
+		return "rst"
+	case data.FINsSeen() || (dsr && data.FINsSeenDSR()):
+		return "fin"
+	case data.Established() || dsr:
+		return "established"
+	}
+	return "pre-established"
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conntrack_test
+
+import (
+	"net"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/projectcalico/felix/bpf/conntrack"
+	"github.com/projectcalico/felix/bpf/mock"
+)
+
+// gaugeValue returns the value of the named gauge, with the given label if non-empty.
+func gaugeValue(name, label, value string) float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	Expect(err).NotTo(HaveOccurred())
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			if label == "" || hasLabel(m, label, value) {
+				return m.GetGauge().GetValue()
+			}
+		}
+	}
+	Fail("gauge " + name + " not found")
+	return 0
+}
+
+func hasLabel(m *dto.Metric, name, value string) bool {
+	for _, l := range m.GetLabel() {
+		if l.GetName() == name && l.GetValue() == value {
+			return true
+		}
+	}
+	return false
+}
+
+var _ = Describe("BPF conntrack metrics scanner", func() {
+	var (
+		ctMap   *mock.Map
+		scanner *conntrack.Scanner
+	)
+
+	BeforeEach(func() {
+		ctMap = mock.NewMockMap(conntrack.MapParams)
+		scanner = conntrack.NewScanner(ctMap, conntrack.NewMetricsScanner(100))
+	})
+
+	add := func(k conntrack.Key, v conntrack.Value) {
+		Expect(ctMap.Update(k.AsBytes(), v[:])).To(Succeed())
+	}
+
+	It("should count the entries by protocol and TCP state", func() {
+		ip3 := net.ParseIP("10.0.0.3")
+		add(tcpKey, tcpJustCreated)
+		add(conntrack.NewKey(conntrack.ProtoTCP, ip1, 1, ip2, 80), tcpEstablished)
+		add(conntrack.NewKey(conntrack.ProtoTCP, ip1, 2, ip2, 80), tcpBothFin)
+		add(conntrack.NewKey(conntrack.ProtoTCP, ip1, 3, ip2, 80), makeValue(now-1, now-1,
+			conntrack.Leg{SynSeen: true, RstSeen: true}, conntrack.Leg{}))
+		// A NAT forward entry doesn't have a state of its own.
+		add(conntrack.NewKey(conntrack.ProtoTCP, ip1, 4, ip3, 80),
+			conntrack.NewValueNATForward(now-1, now-1, 0, tcpKey))
+		add(udpKey, udpJustCreated)
+		add(icmpKey, icmpJustCreated)
+		add(genericKey, genericJustCreated)
+
+		scanner.Scan()
+
+		Expect(gaugeValue("felix_bpf_conntrack_entries", "", "")).To(Equal(8.0))
+		Expect(gaugeValue("felix_bpf_conntrack_map_fill_percent", "", "")).To(Equal(8.0))
+		Expect(gaugeValue("felix_bpf_conntrack_entries_by_protocol", "protocol", "tcp")).To(Equal(5.0))
+		Expect(gaugeValue("felix_bpf_conntrack_entries_by_protocol", "protocol", "udp")).To(Equal(1.0))
+		Expect(gaugeValue("felix_bpf_conntrack_entries_by_protocol", "protocol", "icmp")).To(Equal(1.0))
+		Expect(gaugeValue("felix_bpf_conntrack_entries_by_protocol", "protocol", "other")).To(Equal(1.0))
+		Expect(gaugeValue("felix_bpf_conntrack_tcp_entries_by_state", "state", "pre-established")).To(Equal(1.0))
+		Expect(gaugeValue("felix_bpf_conntrack_tcp_entries_by_state", "state", "established")).To(Equal(1.0))
+		Expect(gaugeValue("felix_bpf_conntrack_tcp_entries_by_state", "state", "fin")).To(Equal(1.0))
+		Expect(gaugeValue("felix_bpf_conntrack_tcp_entries_by_state", "state", "rst")).To(Equal(1.0))
+	})
+
+	It("should report zero once the entries are gone", func() {
+		add(udpKey, udpJustCreated)
+		scanner.Scan()
+		Expect(gaugeValue("felix_bpf_conntrack_entries_by_protocol", "protocol", "udp")).To(Equal(1.0))
+
+		Expect(ctMap.Delete(udpKey.AsBytes())).To(Succeed())
+		scanner.Scan()
+		Expect(gaugeValue("felix_bpf_conntrack_entries", "", "")).To(Equal(0.0))
+		Expect(gaugeValue("felix_bpf_conntrack_entries_by_protocol", "protocol", "udp")).To(Equal(0.0))
+	})
+})
@@ -35,24 +35,62 @@ const (
 
 	// ScanPeriod determines how often we iterate over the conntrack table.
 	ScanPeriod = 10 * time.Second
+	// MinTriggeredScanInterval limits how often TriggerScan() can cause an extra iteration
+	// over the conntrack table.  Scanning excludes the proxy from updating the NAT maps so we
+	// must not let a burst of service updates keep the scanner spinning.
+	MinTriggeredScanInterval = time.Second
+
+	// rateLimitCheckInterval is how many entries the scanner visits between rate limit checks.
+	rateLimitCheckInterval = 1000
 )
 
 // EntryGet is a function prototype provided to EntryScanner in case it needs to
 // evaluate other entries to make a verdict
 type EntryGet func(Key) (Value, error)
 
+// EntryGetV6 is the IPv6 equivalent of EntryGet.
+type EntryGetV6 func(KeyV6) (ValueV6, error)
+
 // EntryScanner is a function prototype to be called on every entry by the scanner
 type EntryScanner interface {
 	Check(Key, Value, EntryGet) ScanVerdict
 }
 
-// EntryScannerSynced is a scaner synchronized with the iteration start/end.
+// EntryScannerV6 is the IPv6 equivalent of EntryScanner.
+type EntryScannerV6 interface {
+	CheckV6(KeyV6, ValueV6, EntryGetV6) ScanVerdict
+}
+
+// EntryScannerSynced is a scaner synchronized with the iteration start/end.  An EntryScannerV6
+// may implement the iteration methods too.
 type EntryScannerSynced interface {
 	EntryScanner
 	IterationStart()
 	IterationEnd()
 }
 
+// EntryScannerPausable is a scanner that must be told when a rate-limited scan sleeps between
+// batches of entries so that it doesn't hold up others while the scan sleeps.  For example, the
+// StaleNATScanner excludes the proxy from updating the NAT maps during an iteration but lets it
+// run while the scan is paused.
+type EntryScannerPausable interface {
+	EntryScanner
+	IterationPause()
+	IterationResume()
+}
+
+// iterationSynced and iterationPausable are the iteration methods of EntryScannerSynced and
+// EntryScannerPausable, which the scanners of either IP family may implement.
+type iterationSynced interface {
+	IterationStart()
+	IterationEnd()
+}
+
+type iterationPausable interface {
+	IterationPause()
+	IterationResume()
+}
+
 // Scanner iterates over a provided conntrack map and call a set of EntryScanner
 // functions on each entry in the order as they were passed to NewScanner. If
 // any of the EntryScanner returns ScanVerdictDelete, it deletes the entry, does
@@ -61,59 +99,180 @@ type EntryScannerSynced interface {
 // It provides a delete-save iteration over the conntrack table for multiple
 // evaluation functions, to keep their implementation simpler.
 type Scanner struct {
-	ctMap    bpf.Map
-	scanners []EntryScanner
+	ctMap bpf.Map
+	// ipv6 is true if ctMap is an IPv6 conntrack map, which is checked by scannersV6 rather than
+	// scanners.
+	ipv6 bool
+
+	scannersLock sync.Mutex
+	scanners     []EntryScanner
+	scannersV6   []EntryScannerV6
+
+	// maxEntriesPerSec limits how fast Scan visits entries, 0 means no limit.
+	maxEntriesPerSec int
+	// shouldDefer, if non-nil, is asked before each periodic scan whether to skip it.
+	shouldDefer func() bool
 
 	wg       sync.WaitGroup
 	stopCh   chan struct{}
 	stopOnce sync.Once
+	triggerC chan struct{}
 }
 
-// NewScanner returns a scanner for the given conntrack map and the set of
+// NewScanner returns a scanner for the given IPv4 conntrack map and the set of
 // EntryScanner. They are executed in the provided order on each entry.
 func NewScanner(ctMap bpf.Map, scanners ...EntryScanner) *Scanner {
 	return &Scanner{
 		ctMap:    ctMap,
 		scanners: scanners,
 		stopCh:   make(chan struct{}),
+		triggerC: make(chan struct{}, 1),
 	}
 }
 
+// NewScannerV6 is the IPv6 equivalent of NewScanner.
+func NewScannerV6(ctMap bpf.Map, scanners ...EntryScannerV6) *Scanner {
+	return &Scanner{
+		ctMap:      ctMap,
+		ipv6:       true,
+		scannersV6: scanners,
+		stopCh:     make(chan struct{}),
+		triggerC:   make(chan struct{}, 1),
+	}
+}
+
+// SetRateLimit limits the number of entries per second that each Scan visits so that scanning a
+// large table doesn't hog a CPU.  0 (the default) means no limit.  Must be called before Start.
+func (s *Scanner) SetRateLimit(maxEntriesPerSec int) {
+	s.maxEntriesPerSec = maxEntriesPerSec
+}
+
+// SetPacer sets a function that the scanner asks, before each periodic scan, whether it should
+// skip the scan, for example because Felix is over its CPU budget.  Triggered scans are never
+// skipped.  Must be called before Start.
+func (s *Scanner) SetPacer(shouldDefer func() bool) {
+	s.shouldDefer = shouldDefer
+}
+
 // Scan executes a scanning iteration
 func (s *Scanner) Scan() {
-	s.iterStart()
-	defer s.iterEnd()
+	s.scannersLock.Lock()
+	scanners := s.scanners
+	scannersV6 := s.scannersV6
+	s.scannersLock.Unlock()
+
+	// The scanners of either family may hook into the iteration.
+	hooks := make([]interface{}, 0, len(scanners)+len(scannersV6))
+	for _, scanner := range scanners {
+		hooks = append(hooks, scanner)
+	}
+	for _, scanner := range scannersV6 {
+		hooks = append(hooks, scanner)
+	}
+
+	s.iterStart(hooks)
+	defer s.iterEnd(hooks)
 
 	debug := log.GetLevel() >= log.DebugLevel
 
-	var ctKey Key
-	var ctVal Value
+	start := time.Now()
+	numVisited := 0
+
+	var iter bpf.IterCallback
+	if s.ipv6 {
+		var ctKey KeyV6
+		var ctVal ValueV6
+		get := s.getV6
+
+		iter = func(k, v []byte) bpf.IteratorAction {
+			copy(ctKey[:], k[:])
+			copy(ctVal[:], v[:])
 
-	err := s.ctMap.Iter(func(k, v []byte) bpf.IteratorAction {
-		copy(ctKey[:], k[:])
-		copy(ctVal[:], v[:])
+			numVisited++
+			if s.maxEntriesPerSec > 0 && numVisited%rateLimitCheckInterval == 0 {
+				s.rateLimit(hooks, start, numVisited)
+			}
+
+			if debug {
+				log.WithFields(log.Fields{
+					"key":   ctKey,
+					"entry": ctVal,
+				}).Debug("Examining conntrack entry")
+			}
 
-		if debug {
-			log.WithFields(log.Fields{
-				"key":   ctKey,
-				"entry": ctVal,
-			}).Debug("Examining conntrack entry")
+			for _, scanner := range scannersV6 {
+				if verdict := scanner.CheckV6(ctKey, ctVal, get); verdict == ScanVerdictDelete {
+					if debug {
+						log.Debug("Deleting conntrack entry.")
+					}
+					return bpf.IterDelete
+				}
+			}
+			return bpf.IterNone
 		}
+	} else {
+		var ctKey Key
+		var ctVal Value
+		get := s.get
+
+		iter = func(k, v []byte) bpf.IteratorAction {
+			copy(ctKey[:], k[:])
+			copy(ctVal[:], v[:])
+
+			numVisited++
+			if s.maxEntriesPerSec > 0 && numVisited%rateLimitCheckInterval == 0 {
+				s.rateLimit(hooks, start, numVisited)
+			}
+
+			if debug {
+				log.WithFields(log.Fields{
+					"key":   ctKey,
+					"entry": ctVal,
+				}).Debug("Examining conntrack entry")
+			}
 
-		for _, scanner := range s.scanners {
-			if verdict := scanner.Check(ctKey, ctVal, s.get); verdict == ScanVerdictDelete {
-				if debug {
-					log.Debug("Deleting conntrack entry.")
+			for _, scanner := range scanners {
+				if verdict := scanner.Check(ctKey, ctVal, get); verdict == ScanVerdictDelete {
+					if debug {
+						log.Debug("Deleting conntrack entry.")
+					}
+					return bpf.IterDelete
 				}
-				return bpf.IterDelete
 			}
+			return bpf.IterNone
 		}
-		return bpf.IterNone
-	})
+	}
+
+	err := s.ctMap.Iter(iter)
 
 	if err != nil {
 		log.WithError(err).Warn("Failed to iterate over conntrack map")
 	}
+	log.WithFields(log.Fields{
+		"numVisited": numVisited,
+		"duration":   time.Since(start),
+	}).Debug("Conntrack scan finished")
+}
+
+// rateLimit sleeps for long enough that the scan doesn't exceed the rate limit.  The pausable
+// scanners are paused while it sleeps.
+func (s *Scanner) rateLimit(hooks []interface{}, start time.Time, numVisited int) {
+	minDuration := time.Duration(numVisited) * time.Second / time.Duration(s.maxEntriesPerSec)
+	elapsed := time.Since(start)
+	if elapsed >= minDuration {
+		return
+	}
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if pausable, ok := hooks[i].(iterationPausable); ok {
+			pausable.IterationPause()
+		}
+	}
+	time.Sleep(minDuration - elapsed)
+	for _, hook := range hooks {
+		if pausable, ok := hook.(iterationPausable); ok {
+			pausable.IterationResume()
+		}
+	}
 }
 
 func (s *Scanner) get(k Key) (Value, error) {
@@ -126,6 +285,16 @@ func (s *Scanner) get(k Key) (Value, error) {
 	return ValueFromBytes(v), nil
 }
 
+func (s *Scanner) getV6(k KeyV6) (ValueV6, error) {
+	v, err := s.ctMap.Get(k.AsBytes())
+
+	if err != nil {
+		return ValueV6{}, err
+	}
+
+	return ValueV6FromBytes(v), nil
+}
+
 // Start the periodic scanner
 func (s *Scanner) Start() {
 	s.wg.Add(1)
@@ -137,12 +306,32 @@ func (s *Scanner) Start() {
 
 		ticker := jitter.NewTicker(ScanPeriod, 100*time.Millisecond)
 
+		var lastScan time.Time
+		scan := true
 		for {
-			s.Scan()
+			if scan {
+				lastScan = time.Now()
+				s.Scan()
+			}
+			scan = true
 
 			select {
 			case <-ticker.C:
 				log.Debug("Conntrack cleanup timer popped")
+				if s.shouldDefer != nil && s.shouldDefer() {
+					log.Debug("Skipping periodic conntrack scan.")
+					scan = false
+				}
+			case <-s.triggerC:
+				log.Debug("Conntrack cleanup triggered")
+				if wait := MinTriggeredScanInterval - time.Since(lastScan); wait > 0 {
+					select {
+					case <-time.After(wait):
+					case <-s.stopCh:
+						log.Debug("Conntrack cleanup got stop signal")
+						return
+					}
+				}
 			case <-s.stopCh:
 				log.Debug("Conntrack cleanup got stop signal")
 				return
@@ -151,18 +340,30 @@ func (s *Scanner) Start() {
 	}()
 }
 
-func (s *Scanner) iterStart() {
-	for _, scanner := range s.scanners {
-		if synced, ok := scanner.(EntryScannerSynced); ok {
+// TriggerScan asks the running scanner to do an iteration as soon as possible rather than
+// waiting for the next tick, for example, because some NAT backends were removed and the
+// connections to them should not linger.  It never blocks; triggers that arrive before the
+// scanner gets to them are coalesced into a single iteration.
+func (s *Scanner) TriggerScan() {
+	select {
+	case s.triggerC <- struct{}{}:
+	default:
+		// There is already a pending trigger.
+	}
+}
+
+func (s *Scanner) iterStart(hooks []interface{}) {
+	for _, hook := range hooks {
+		if synced, ok := hook.(iterationSynced); ok {
 			synced.IterationStart()
 		}
 	}
 }
 
-func (s *Scanner) iterEnd() {
-	for i := len(s.scanners) - 1; i >= 0; i-- {
-		scanner := s.scanners[i]
-		if synced, ok := scanner.(EntryScannerSynced); ok {
+func (s *Scanner) iterEnd(hooks []interface{}) {
+	for i := len(hooks) - 1; i >= 0; i-- {
+		hook := hooks[i]
+		if synced, ok := hook.(iterationSynced); ok {
 			synced.IterationEnd()
 		}
 	}
@@ -176,7 +377,16 @@ func (s *Scanner) Stop() {
 	})
 }
 
-// AddUnlocked adds an additional EntryScanner to a non-running Scanner
+// AddUnlocked adds an additional EntryScanner to a non-running IPv4 Scanner
 func (s *Scanner) AddUnlocked(scanner EntryScanner) {
 	s.scanners = append(s.scanners, scanner)
 }
+
+// Add adds an additional EntryScanner to an IPv4 Scanner, which may be running.  The new
+// EntryScanner takes part from the next iteration.
+func (s *Scanner) Add(scanner EntryScanner) {
+	s.scannersLock.Lock()
+	defer s.scannersLock.Unlock()
+	// Copy so that an in-progress iteration keeps its own slice.
+	s.scanners = append(s.scanners[:len(s.scanners):len(s.scanners)], scanner)
+}
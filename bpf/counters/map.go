@@ -0,0 +1,174 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package counters describes the BPF map in which the TC programs count the packets that they
+// accept and drop on each interface.
+package counters
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/projectcalico/felix/bpf"
+)
+
+var MapParams = bpf.MapParameters{
+	Filename:   "/sys/fs/bpf/tc/globals/cali_counters",
+	Type:       "hash",
+	KeySize:    KeySize,
+	ValueSize:  ValueSize,
+	MaxEntries: 20000, // Two hooks per interface.
+	Name:       "cali_counters",
+	Version:    1,
+}
+
+func Map(mc *bpf.MapContext) bpf.Map {
+	return mc.NewPinnedMap(MapParams)
+}
+
+// Hook is the TC hook that a program is attached to.
+type Hook uint32
+
+const (
+	HookIngress Hook = iota
+	HookEgress
+)
+
+func (h Hook) String() string {
+	switch h {
+	case HookIngress:
+		return "ingress"
+	case HookEgress:
+		return "egress"
+	}
+	return fmt.Sprintf("unknown(%d)", uint32(h))
+}
+
+// struct counters_key {
+//     __u32 ifindex;
+//     __u32 hook;
+// };
+const KeySize = 8
+
+type Key [KeySize]byte
+
+func NewKey(ifIndex uint32, hook Hook) Key {
+	var k Key
+	binary.LittleEndian.PutUint32(k[0:4], ifIndex)
+	binary.LittleEndian.PutUint32(k[4:8], uint32(hook))
+	return k
+}
+
+func (k Key) IfIndex() uint32 {
+	return binary.LittleEndian.Uint32(k[0:4])
+}
+
+func (k Key) Hook() Hook {
+	return Hook(binary.LittleEndian.Uint32(k[4:8]))
+}
+
+func (k Key) String() string {
+	return fmt.Sprintf("ifindex %d hook %s", k.IfIndex(), k.Hook())
+}
+
+// struct counters_value {
+//     __u64 accepted;
+//     __u64 dropped_policy;
+//     __u64 dropped_rpf;
+//     __u64 dropped_unauth_source;
+//     __u64 dropped_other;
+//     __u64 nat;
+// };
+const ValueSize = 8 * numCounters
+
+// Counter identifies one of the counters in a Value.
+type Counter int
+
+const (
+	Accepted Counter = iota
+	DroppedPolicy
+	DroppedRPF
+	DroppedUnauthSource
+	DroppedOther
+	// NAT counts the new connections that were DNATed to a service backend.
+	NAT
+
+	numCounters = 6
+)
+
+// Counters lists all the counters, in the order that they appear in a Value.
+var Counters = []Counter{Accepted, DroppedPolicy, DroppedRPF, DroppedUnauthSource, DroppedOther, NAT}
+
+func (c Counter) String() string {
+	switch c {
+	case Accepted:
+		return "accepted"
+	case DroppedPolicy:
+		return "dropped-policy"
+	case DroppedRPF:
+		return "dropped-rpf"
+	case DroppedUnauthSource:
+		return "dropped-unauth-source"
+	case DroppedOther:
+		return "dropped-other"
+	case NAT:
+		return "nat"
+	}
+	return fmt.Sprintf("unknown(%d)", int(c))
+}
+
+type Value [ValueSize]byte
+
+func NewValue(counts map[Counter]uint64) Value {
+	var v Value
+	for c, n := range counts {
+		binary.LittleEndian.PutUint64(v[8*c:8*c+8], n)
+	}
+	return v
+}
+
+func (v Value) Get(c Counter) uint64 {
+	return binary.LittleEndian.Uint64(v[8*c : 8*c+8])
+}
+
+func (v Value) String() string {
+	s := ""
+	for _, c := range Counters {
+		if s != "" {
+			s += " "
+		}
+		s += fmt.Sprintf("%s=%d", c, v.Get(c))
+	}
+	return s
+}
+
+type MapMem map[Key]Value
+
+// LoadMapMem loads the counters map into memory.
+func LoadMapMem(m bpf.Map) (MapMem, error) {
+	ret := make(MapMem)
+
+	err := m.Iter(func(k, v []byte) bpf.IteratorAction {
+		var key Key
+		copy(key[:], k[:KeySize])
+
+		var val Value
+		copy(val[:], v[:ValueSize])
+
+		ret[key] = val
+		return bpf.IterNone
+	})
+
+	return ret, err
+}
@@ -75,6 +75,7 @@ func (m *Manager) ResyncFailsafes() error {
 	}
 
 	syncFailed := false
+	numWrites := 0
 	unknownKeys := set.New()
 	err = m.failsafesMap.Iter(func(rawKey, _ []byte) bpf.IteratorAction {
 		key := KeyFromSlice(rawKey)
@@ -133,7 +134,9 @@ func (m *Manager) ResyncFailsafes() error {
 		if err != nil {
 			log.WithError(err).Error("Failed to update failsafe port.")
 			syncFailed = true
+			return
 		}
+		numWrites++
 	}
 
 	for _, p := range m.failsafesIn {
@@ -149,9 +152,12 @@ func (m *Manager) ResyncFailsafes() error {
 		if err != nil {
 			log.WithError(err).WithField("key", k).Warn("Failed to remove failsafe port from map.")
 			syncFailed = true
+			return nil
 		}
+		numWrites++
 		return nil
 	})
+	m.opReporter.RecordCount(logutils.CountBPFMapWrites, numWrites)
 
 	m.failsafesInSync = !syncFailed
 	if syncFailed {
@@ -0,0 +1,58 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipsets
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// IDRecordFile is where Felix records the string ID of each IP set in the BPF IP sets map.  The
+// map only holds the 64-bit IDs that Felix allocates so "calico-bpf ipsets compare" uses the record
+// to find the kernel IP set that is equivalent to each BPF IP set.
+const IDRecordFile = "/var/run/calico/bpf/ipsets/ids"
+
+// WriteIDRecord writes the given mapping from 64-bit ID to string ID.
+func WriteIDRecord(path string, ids map[uint64]string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+	// Write then rename so that a reader never sees a partial record.
+	if err := ioutil.WriteFile(path+".tmp", data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// ReadIDRecord reads the mapping written by WriteIDRecord.  Returns an error that satisfies
+// os.IsNotExist if there is no record.
+func ReadIDRecord(path string) (map[uint64]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var ids map[uint64]string
+	if err := json.Unmarshal(data, &ids); err != nil {
+		return nil, fmt.Errorf("failed to parse IP set ID record: %w", err)
+	}
+	return ids, nil
+}
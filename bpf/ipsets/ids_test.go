@@ -0,0 +1,39 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipsets
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestIDRecord(t *testing.T) {
+	RegisterTestingT(t)
+	dir, err := ioutil.TempDir("", "bpfipsets")
+	Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "ipsets", "ids")
+
+	_, err = ReadIDRecord(path)
+	Expect(os.IsNotExist(err)).To(BeTrue())
+
+	ids := map[uint64]string{0xdeadbeef12345678: "s:abcd", 1: "n:efgh"}
+	Expect(WriteIDRecord(path, ids)).To(Succeed())
+	Expect(ReadIDRecord(path)).To(Equal(ids))
+}
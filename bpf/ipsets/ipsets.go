@@ -53,6 +53,10 @@ type bpfIPSets struct {
 	dirtyIPSetIDs   set.Set
 	resyncScheduled bool
 
+	// idRecordPath is where we record the string ID of each IP set, or "" to skip the record.
+	idRecordPath string
+	idsChanged   bool
+
 	opRecorder logutils.OpRecorder
 }
 
@@ -61,6 +65,7 @@ func NewBPFIPSets(
 	ipSetIDAllocator *idalloc.IDAllocator,
 	ipSetsMap bpf.Map,
 	opRecorder logutils.OpRecorder,
+	idRecordPath string,
 ) *bpfIPSets {
 	return &bpfIPSets{
 		IPVersionConfig:  ipVersionConfig,
@@ -70,6 +75,8 @@ func NewBPFIPSets(
 		resyncScheduled:  true,
 		ipSetIDAllocator: ipSetIDAllocator,
 		opRecorder:       opRecorder,
+		idRecordPath:     idRecordPath,
+		idsChanged:       true,
 	}
 }
 
@@ -105,6 +112,7 @@ func (m *bpfIPSets) getOrCreateIPSet(setID string) *bpfIPSet {
 			PendingRemoves: set.New(),
 		}
 		m.ipSets[id] = ipSet
+		m.idsChanged = true
 	} else {
 		// Possible that this IP set was queued for deletion but it just got recreated.
 		ipSet.Deleted = false
@@ -115,6 +123,7 @@ func (m *bpfIPSets) getOrCreateIPSet(setID string) *bpfIPSet {
 // deleteIPSetAndReleaseID deleted the IP set tracking struct from the map and releases the ID.
 func (m *bpfIPSets) deleteIPSetAndReleaseID(ipSet *bpfIPSet) {
 	delete(m.ipSets, ipSet.ID)
+	m.idsChanged = true
 	err := m.ipSetIDAllocator.ReleaseUintID(ipSet.ID)
 	if err != nil {
 		log.WithField("id", ipSet.ID).WithError(err).Panic("Failed to release IP set UID")
@@ -349,6 +358,7 @@ func (m *bpfIPSets) ApplyUpdates() {
 	})
 
 	duration := time.Since(startTime)
+	m.opRecorder.RecordCount(logutils.CountBPFMapWrites, int(numAdds+numDels))
 	if numDels > 0 || numAdds > 0 {
 		log.WithFields(log.Fields{
 			"timeTaken": duration,
@@ -357,9 +367,26 @@ func (m *bpfIPSets) ApplyUpdates() {
 		}).Info("Completed updates to BPF IP sets.")
 	}
 
+	if m.idsChanged && m.idRecordPath != "" {
+		m.writeIDRecord()
+	}
+
 	bpfIPSetsGauge.Set(float64(len(m.ipSets)))
 }
 
+func (m *bpfIPSets) writeIDRecord() {
+	ids := map[uint64]string{}
+	for id, ipSet := range m.ipSets {
+		ids[id] = ipSet.OriginalID
+	}
+	if err := WriteIDRecord(m.idRecordPath, ids); err != nil {
+		// Only used for diagnostics so we just try again next time.
+		log.WithError(err).Warn("Failed to record BPF IP set IDs.")
+		return
+	}
+	m.idsChanged = false
+}
+
 // ApplyDeletions tries to delete any IP sets that are no longer needed.
 // Failures are ignored, deletions will be retried the next time we do a resync.
 func (m *bpfIPSets) ApplyDeletions() {
@@ -26,6 +26,7 @@ import (
 
 	"github.com/projectcalico/felix/bpf"
 	"github.com/projectcalico/felix/ip"
+	"github.com/projectcalico/felix/labelindex"
 )
 
 // WARNING: must be kept in sync with the definitions in bpf/polprog/pol_prog_builder.go.
@@ -100,11 +101,13 @@ func ProtoIPSetMemberToBPFEntry(id uint64, member string) *IPSetEntry {
 		parts := strings.Split(member, ",")
 		cidrStr = parts[0]
 		parts = strings.Split(parts[1], ":")
-		switch parts[0] {
+		switch strings.ToLower(parts[0]) {
 		case "tcp":
-			protocol = 6
+			protocol = uint8(labelindex.ProtocolTCP)
 		case "udp":
-			protocol = 17
+			protocol = uint8(labelindex.ProtocolUDP)
+		case "sctp":
+			protocol = uint8(labelindex.ProtocolSCTP)
 		default:
 			logrus.WithField("member", member).Warn("Unknown protocol in named port member")
 			return nil
@@ -103,6 +103,8 @@ type PinnedMap struct {
 	fdLoaded bool
 	fd       MapFD
 	perCPU   bool
+	// noBatchOps is set once we find that the kernel doesn't support batch operations on the map.
+	noBatchOps bool
 }
 
 func (b *PinnedMap) GetName() string {
@@ -201,8 +203,19 @@ func IterMapCmdOutput(output []byte, f IterCallback) error {
 
 // Iter iterates over the map, passing each key/value pair to the provided callback function.  Warning:
 // The key and value are owned by the iterator and will be clobbered by the next iteration so they must not be
-// retained or modified.
+// retained or modified.  If the kernel supports batch operations, deletions are done in batches so an entry
+// that the callback asks to delete may still be present while the rest of its batch is visited.
 func (b *PinnedMap) Iter(f IterCallback) error {
+	if !b.perCPU && !b.noBatchOps {
+		err := b.iterBatched(f)
+		if err != ErrBatchOpsNotSupported {
+			return err
+		}
+		logrus.WithField("map", b.Name).Info(
+			"Kernel doesn't support batch operations on map, falling back to iterating one entry at a time.")
+		b.noBatchOps = true
+	}
+
 	it, err := NewMapIterator(b.MapFD(), b.KeySize, b.ValueSize, b.MaxEntries)
 	if err != nil {
 		return fmt.Errorf("failed to create BPF map iterator: %w", err)
@@ -244,6 +257,49 @@ func (b *PinnedMap) Iter(f IterCallback) error {
 	}
 }
 
+// iterBatched is the fast path of Iter; it loads entries in large batches and deletes the entries that the
+// callback asks it to delete after each batch, also in one go.
+func (b *PinnedMap) iterBatched(f IterCallback) error {
+	it, err := NewMapBatchIterator(b.MapFD(), b.KeySize, b.ValueSize)
+	if err != nil {
+		return fmt.Errorf("failed to create BPF map iterator: %w", err)
+	}
+
+	var keysToDelete []byte
+	for {
+		keys, values, count, err := it.NextBatch()
+		if err == ErrIterationFinished {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		keysToDelete = keysToDelete[:0]
+		for i := 0; i < count; i++ {
+			k := keys[i*b.KeySize : (i+1)*b.KeySize]
+			v := values[i*b.ValueSize : (i+1)*b.ValueSize]
+			if f(k, v) == IterDelete {
+				keysToDelete = append(keysToDelete, k...)
+			}
+		}
+		if len(keysToDelete) == 0 {
+			continue
+		}
+
+		err = DeleteMapEntriesBatch(b.MapFD(), keysToDelete, b.KeySize)
+		if err == ErrBatchOpsNotSupported {
+			for i := 0; i < len(keysToDelete); i += b.KeySize {
+				err = DeleteMapEntry(b.MapFD(), keysToDelete[i:i+b.KeySize], b.ValueSize)
+				if err != nil && !IsNotExists(err) {
+					return fmt.Errorf("failed to delete map entry: %w", err)
+				}
+			}
+		} else if err != nil {
+			return fmt.Errorf("failed to delete map entries: %w", err)
+		}
+	}
+}
+
 func (b *PinnedMap) Update(k, v []byte) error {
 	if b.perCPU {
 		// Per-CPU maps need a buffer of value-size * num-CPUs.
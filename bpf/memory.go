@@ -0,0 +1,282 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpf
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// MemoryDebugPath is the path of the debug HTTP endpoint that serves the BPF memory estimates.
+// For example:
+//
+//	curl http://localhost:9091/debug/bpf-memory
+const MemoryDebugPath = "/debug/bpf-memory"
+
+// The features that the BPF maps and programs are accounted to.
+const (
+	MemoryFeatureConntrack = "conntrack"
+	MemoryFeatureNAT       = "nat"
+	MemoryFeatureIPSets    = "ipsets"
+	MemoryFeatureRoutes    = "routes"
+	MemoryFeatureState     = "state"
+	MemoryFeatureARP       = "arp"
+	MemoryFeatureFailsafes = "failsafes"
+	MemoryFeatureCounters  = "counters"
+	// MemoryFeatureEndpointPrograms is the main TC programs that are attached to each interface.
+	MemoryFeatureEndpointPrograms = "endpoint-programs"
+	// MemoryFeaturePolicyPrograms is the per-interface policy programs.
+	MemoryFeaturePolicyPrograms = "policy-programs"
+)
+
+var (
+	gaugeVecMapMemory = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "felix_bpf_map_memory_bytes",
+		Help: "Estimated kernel memory used by each BPF map, by feature.",
+	}, []string{"feature", "map"})
+	gaugeVecProgramMemory = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "felix_bpf_program_memory_bytes",
+		Help: "Estimated kernel memory used by BPF programs, by feature.",
+	}, []string{"feature"})
+	gaugeVecNumPrograms = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "felix_bpf_num_programs",
+		Help: "Number of BPF programs loaded by Felix, by feature.",
+	}, []string{"feature"})
+)
+
+func init() {
+	prometheus.MustRegister(gaugeVecMapMemory)
+	prometheus.MustRegister(gaugeVecProgramMemory)
+	prometheus.MustRegister(gaugeVecNumPrograms)
+}
+
+// MemoryEstimate returns an estimate of the kernel memory, in bytes, that a map with these
+// parameters uses.  Hash maps preallocate their entries so the estimate is based on MaxEntries
+// rather than the number of entries in use; for maps that don't preallocate (such as LPM tries) it
+// is an upper bound.  The kernel's per-entry bookkeeping isn't included.
+func (mp *MapParameters) MemoryEstimate(numCPUs int) int64 {
+	valueSize := align8(mp.ValueSize)
+	if strings.Contains(mp.Type, "percpu") {
+		valueSize *= numCPUs
+	}
+	return int64(mp.MaxEntries) * int64(align8(mp.KeySize)+valueSize)
+}
+
+func align8(size int) int {
+	return (size + 7) &^ 7
+}
+
+// MemoryAccountant keeps track of the BPF maps and programs that Felix has created, grouped by
+// feature (conntrack, NAT, routes and so on), and estimates how much kernel memory they use.
+// The estimates are exported as Prometheus gauges and served as JSON on MemoryDebugPath so that
+// the memlock rlimit and kernel memory requirements can be worked out before rollout.
+//
+// All methods may be called on a nil MemoryAccountant, in which case they do nothing.
+type MemoryAccountant struct {
+	lock     sync.Mutex
+	numCPUs  int
+	maps     map[string]mapMemory
+	programs map[programKey]int64
+	// programFeatures records every feature that has had a program so that we can zero its
+	// gauges when its last program is removed.
+	programFeatures map[string]bool
+}
+
+type mapMemory struct {
+	feature string
+	params  MapParameters
+}
+
+type programKey struct {
+	feature string
+	// owner is the interface (or other object) that the program belongs to, used to remove all of
+	// its programs in one go.
+	owner string
+	name  string
+}
+
+func NewMemoryAccountant() *MemoryAccountant {
+	return &MemoryAccountant{
+		numCPUs:         runtime.NumCPU(),
+		maps:            map[string]mapMemory{},
+		programs:        map[programKey]int64{},
+		programFeatures: map[string]bool{},
+	}
+}
+
+// AddMap records a map that belongs to the given feature.  Maps of unknown type, which don't
+// expose their parameters, are ignored.
+func (a *MemoryAccountant) AddMap(feature string, m Map) {
+	if a == nil {
+		return
+	}
+	pm, ok := m.(*PinnedMap)
+	if !ok {
+		log.WithField("map", m.GetName()).Debug("Map doesn't expose its parameters, not accounting for it.")
+		return
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.maps[pm.GetName()] = mapMemory{feature: feature, params: pm.MapParameters}
+	gaugeVecMapMemory.WithLabelValues(feature, pm.GetName()).Set(float64(pm.MemoryEstimate(a.numCPUs)))
+}
+
+// SetProgram records (or updates) the size of a program that belongs to the given feature and
+// owner.
+func (a *MemoryAccountant) SetProgram(feature, owner, name string, size int64) {
+	if a == nil {
+		return
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.programs[programKey{feature: feature, owner: owner, name: name}] = size
+	a.programFeatures[feature] = true
+	a.updateProgramGauges()
+}
+
+// RemoveProgram forgets a single program.
+func (a *MemoryAccountant) RemoveProgram(feature, owner, name string) {
+	if a == nil {
+		return
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	delete(a.programs, programKey{feature: feature, owner: owner, name: name})
+	a.updateProgramGauges()
+}
+
+// RemovePrograms forgets all the programs that belong to the given owner.
+func (a *MemoryAccountant) RemovePrograms(owner string) {
+	if a == nil {
+		return
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for k := range a.programs {
+		if k.owner == owner {
+			delete(a.programs, k)
+		}
+	}
+	a.updateProgramGauges()
+}
+
+func (a *MemoryAccountant) updateProgramGauges() {
+	usage := a.featureUsage()
+	for feature := range a.programFeatures {
+		var bytes int64
+		var num int
+		if f, ok := usage[feature]; ok {
+			bytes, num = f.ProgramBytes, f.NumPrograms
+		}
+		gaugeVecProgramMemory.WithLabelValues(feature).Set(float64(bytes))
+		gaugeVecNumPrograms.WithLabelValues(feature).Set(float64(num))
+	}
+}
+
+// MemoryUsage is the estimated memory usage of all the recorded maps and programs.
+type MemoryUsage struct {
+	TotalBytes int64           `json:"totalBytes"`
+	Features   []FeatureMemory `json:"features"`
+}
+
+// FeatureMemory is the estimated memory usage of a single feature's maps and programs.
+type FeatureMemory struct {
+	Feature      string      `json:"feature"`
+	TotalBytes   int64       `json:"totalBytes"`
+	MapBytes     int64       `json:"mapBytes"`
+	ProgramBytes int64       `json:"programBytes"`
+	NumPrograms  int         `json:"numPrograms"`
+	Maps         []MapMemory `json:"maps,omitempty"`
+}
+
+// MapMemory is the estimated memory usage of a single map, along with the parameters that the
+// estimate is based on.
+type MapMemory struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	KeySize    int    `json:"keySize"`
+	ValueSize  int    `json:"valueSize"`
+	MaxEntries int    `json:"maxEntries"`
+	Bytes      int64  `json:"bytes"`
+}
+
+// Usage returns the current estimates, sorted by feature and map name.
+func (a *MemoryAccountant) Usage() MemoryUsage {
+	usage := MemoryUsage{Features: []FeatureMemory{}}
+	if a == nil {
+		return usage
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	for _, f := range a.featureUsage() {
+		sort.Slice(f.Maps, func(i, j int) bool {
+			return f.Maps[i].Name < f.Maps[j].Name
+		})
+		f.TotalBytes = f.MapBytes + f.ProgramBytes
+		usage.TotalBytes += f.TotalBytes
+		usage.Features = append(usage.Features, *f)
+	}
+	sort.Slice(usage.Features, func(i, j int) bool {
+		return usage.Features[i].Feature < usage.Features[j].Feature
+	})
+	return usage
+}
+
+// featureUsage totals the maps and programs by feature.  Must be called with the lock held.
+func (a *MemoryAccountant) featureUsage() map[string]*FeatureMemory {
+	features := map[string]*FeatureMemory{}
+	get := func(feature string) *FeatureMemory {
+		f, ok := features[feature]
+		if !ok {
+			f = &FeatureMemory{Feature: feature}
+			features[feature] = f
+		}
+		return f
+	}
+	for name, m := range a.maps {
+		f := get(m.feature)
+		bytes := m.params.MemoryEstimate(a.numCPUs)
+		f.MapBytes += bytes
+		f.Maps = append(f.Maps, MapMemory{
+			Name:       name,
+			Type:       m.params.Type,
+			KeySize:    m.params.KeySize,
+			ValueSize:  m.params.ValueSize,
+			MaxEntries: m.params.MaxEntries,
+			Bytes:      bytes,
+		})
+	}
+	for k, size := range a.programs {
+		f := get(k.feature)
+		f.ProgramBytes += size
+		f.NumPrograms++
+	}
+	return features
+}
+
+// ServeHTTP serves the current estimates as JSON.
+func (a *MemoryAccountant) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(a.Usage()); err != nil {
+		log.WithError(err).Warn("Failed to write BPF memory debug JSON.")
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpf
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestMapMemoryEstimate(t *testing.T) {
+	RegisterTestingT(t)
+
+	hash := MapParameters{Type: "hash", KeySize: 12, ValueSize: 20, MaxEntries: 100}
+	Expect(hash.MemoryEstimate(4)).To(Equal(int64(100*(16+24))), "should round key and value up to 8 bytes")
+
+	perCPU := MapParameters{Type: "percpu_array", KeySize: 4, ValueSize: 8, MaxEntries: 2}
+	Expect(perCPU.MemoryEstimate(4)).To(Equal(int64(2*(8+4*8))), "should count a value per CPU")
+}
+
+func TestMemoryAccountant(t *testing.T) {
+	RegisterTestingT(t)
+
+	a := NewMemoryAccountant()
+	a.numCPUs = 1
+	mc := &MapContext{}
+	a.AddMap("conntrack", mc.NewPinnedMap(MapParameters{
+		Name: "cali_v4_ct", Type: "hash", KeySize: 16, ValueSize: 64, MaxEntries: 10,
+	}))
+	a.AddMap("nat", mc.NewPinnedMap(MapParameters{
+		Name: "cali_v4_nat_fe", Type: "hash", KeySize: 16, ValueSize: 16, MaxEntries: 10,
+	}))
+	a.SetProgram("endpoint-policy", "cali1", "ingress", 800)
+	a.SetProgram("endpoint-policy", "cali1", "egress", 400)
+	a.SetProgram("endpoint-policy", "cali2", "ingress", 80)
+
+	usage := a.Usage()
+	Expect(usage.TotalBytes).To(Equal(int64(800 + 320 + 1280)))
+	Expect(usage.Features).To(HaveLen(3))
+	Expect(usage.Features[0].Feature).To(Equal("conntrack"))
+	Expect(usage.Features[0].MapBytes).To(Equal(int64(800)))
+	Expect(usage.Features[1].Feature).To(Equal("endpoint-policy"))
+	Expect(usage.Features[1].ProgramBytes).To(Equal(int64(1280)))
+	Expect(usage.Features[1].NumPrograms).To(Equal(3))
+
+	a.SetProgram("endpoint-policy", "cali1", "ingress", 200)
+	a.RemovePrograms("cali2")
+	usage = a.Usage()
+	Expect(usage.Features[1].ProgramBytes).To(Equal(int64(600)))
+	Expect(usage.Features[1].NumPrograms).To(Equal(2))
+
+	var nilAccountant *MemoryAccountant
+	nilAccountant.SetProgram("endpoint-policy", "cali1", "ingress", 200)
+	Expect(nilAccountant.Usage().Features).To(BeEmpty())
+}
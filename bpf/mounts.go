@@ -0,0 +1,134 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package bpf
+
+import (
+	"errors"
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	FSTypeBPF     = "bpf"
+	FSTypeCgroup2 = "cgroup2"
+)
+
+// Mount describes one of the filesystems that the BPF dataplane uses.
+type Mount struct {
+	FSType string
+	Path   string
+	// Private is true if Felix mounted the filesystem itself, privately in its own mount
+	// namespace, rather than using an existing mount.
+	Private bool
+}
+
+func (m Mount) String() string {
+	source := "existing"
+	if m.Private {
+		source = "private"
+	}
+	return fmt.Sprintf("%s at %s (%s mount)", m.FSType, m.Path, source)
+}
+
+// EnsureMounts checks that the filesystems that the BPF dataplane needs are mounted: bpffs and,
+// if needCgroupV2 is set, cgroup v2 (at the root chosen by cgroupV2Root, see FindCgroupV2Root).
+// If autoMount is set, it mounts any that are missing privately in our mount namespace.
+// Otherwise, a missing filesystem is an error that says what needs to be mounted on the host.
+func EnsureMounts(autoMount, needCgroupV2 bool, cgroupV2Root string) ([]Mount, error) {
+	bpffs, err := ensureBPFfs(autoMount)
+	if err != nil {
+		return nil, err
+	}
+	mounts := []Mount{bpffs}
+	if needCgroupV2 {
+		cgroup, err := ensureCgroupV2(autoMount, cgroupV2Root)
+		if err != nil {
+			return nil, err
+		}
+		mounts = append(mounts, cgroup)
+	}
+	for _, m := range mounts {
+		log.WithFields(log.Fields{
+			"type":    m.FSType,
+			"path":    m.Path,
+			"private": m.Private,
+		}).Info("BPF dataplane filesystem is mounted.")
+	}
+	return mounts, nil
+}
+
+func ensureBPFfs(autoMount bool) (Mount, error) {
+	mnt, err := isMount(defaultBPFfsPath)
+	if err != nil {
+		return Mount{}, err
+	}
+	if mnt {
+		fsBPF, err := isBPF(defaultBPFfsPath)
+		if err != nil {
+			return Mount{}, err
+		}
+		if fsBPF {
+			return Mount{FSType: FSTypeBPF, Path: defaultBPFfsPath}, nil
+		}
+	}
+	if !autoMount {
+		return Mount{}, fmt.Errorf("bpffs is not mounted at %s and mounting it automatically is disabled; "+
+			"mount it on the host with 'mount -t bpf bpffs %s'", defaultBPFfsPath, defaultBPFfsPath)
+	}
+	path, err := MaybeMountBPFfs()
+	if err != nil {
+		return Mount{}, fmt.Errorf("failed to mount bpffs: %w", err)
+	}
+	return Mount{FSType: FSTypeBPF, Path: path, Private: true}, nil
+}
+
+func ensureCgroupV2(autoMount bool, root string) (Mount, error) {
+	switch root {
+	case "", CgroupV2RootPrivate:
+	case CgroupV2RootAuto:
+		mountPoint, err := findOurCgroupV2Mount()
+		if err != nil {
+			return Mount{}, err
+		}
+		if mountPoint != "" {
+			return Mount{FSType: FSTypeCgroup2, Path: mountPoint}, nil
+		}
+	default:
+		path, err := FindCgroupV2Root(root)
+		if err != nil {
+			return Mount{}, err
+		}
+		return Mount{FSType: FSTypeCgroup2, Path: path}, nil
+	}
+
+	// We need our private mount.  It may still be there from a previous run.
+	if !autoMount {
+		mnt, err := isMount(cgroupV2PrivatePath)
+		if err == nil && mnt {
+			if isCgroup, err := isCgroupV2(cgroupV2PrivatePath); err == nil && isCgroup {
+				return Mount{FSType: FSTypeCgroup2, Path: cgroupV2PrivatePath, Private: true}, nil
+			}
+		}
+		return Mount{}, errors.New("there is no cgroup v2 mount of the whole hierarchy and mounting one " +
+			"automatically is disabled; make the host's cgroup v2 mount available (for example, by " +
+			"mounting the host's /sys/fs/cgroup into Felix's container)")
+	}
+	path, err := MaybeMountCgroupV2()
+	if err != nil {
+		return Mount{}, fmt.Errorf("failed to mount cgroup v2: %w", err)
+	}
+	return Mount{FSType: FSTypeCgroup2, Path: path, Private: true}, nil
+}
@@ -36,31 +36,26 @@ type cgroupProgs struct {
 	Name        string `json:"name"`
 }
 
-func RemoveConnectTimeLoadBalancer(cgroupv2 string) error {
+// connectTimeAttachTypes are the cgroup attach types of the programs that make up the connect-time
+// load balancer.
+var connectTimeAttachTypes = []string{"connect4", "sendmsg4", "recvmsg4", "sendmsg6", "recvmsg6"}
+
+// RemoveConnectTimeLoadBalancer detaches the connect-time load balancer from the cgroup at path
+// cgroupv2 below the cgroup v2 root; see bpf.FindCgroupV2Root for the values of cgroupv2Root.
+func RemoveConnectTimeLoadBalancer(cgroupv2Root, cgroupv2 string) error {
 	if os.Getenv("FELIX_DebugSkipCTLBCleanup") == "true" {
 		log.Info("FV special case: skipping CTLB cleanup")
 		return nil
 	}
 
-	cgroupPath, err := ensureCgroupPath(cgroupv2)
+	cgroupPath, err := ensureCgroupPath(cgroupv2Root, cgroupv2)
 	if err != nil {
 		return errors.Wrap(err, "failed to set-up cgroupv2")
 	}
 
-	cmd := exec.Command("bpftool", "-j", "-p", "cgroup", "show", cgroupPath)
-	log.WithField("args", cmd.Args).Info("Running bpftool to look up programs attached to cgroup")
-	out, err := cmd.Output()
+	progs, err := listCgroupProgs(cgroupPath)
 	if err != nil {
-		log.WithError(err).WithField("output", string(out)).Info(
-			"Failed to list BPF programs.  Assuming not supported/nothing to clean up.")
-		return err
-	}
-
-	var progs []cgroupProgs
-
-	err = json.Unmarshal(out, &progs)
-	if err != nil {
-		log.WithError(err).WithField("output", string(out)).Error("BPF program list not json.")
+		log.WithError(err).Info("Failed to list BPF programs.  Assuming not supported/nothing to clean up.")
 		return err
 	}
 
@@ -69,9 +64,9 @@ func RemoveConnectTimeLoadBalancer(cgroupv2 string) error {
 			continue
 		}
 
-		cmd = exec.Command("bpftool", "cgroup", "detach", cgroupPath, p.AttachType, "id", strconv.Itoa(p.ID))
+		cmd := exec.Command("bpftool", "cgroup", "detach", cgroupPath, p.AttachType, "id", strconv.Itoa(p.ID))
 		log.WithField("args", cmd.Args).Info("Running bpftool to detach program")
-		out, err = cmd.CombinedOutput()
+		out, err := cmd.CombinedOutput()
 		if err != nil {
 			log.WithError(err).WithField("output", string(out)).Error(
 				"Failed to detach connect-time load balancing program.")
@@ -84,6 +79,79 @@ func RemoveConnectTimeLoadBalancer(cgroupv2 string) error {
 	return nil
 }
 
+func listCgroupProgs(cgroupPath string) ([]cgroupProgs, error) {
+	cmd := exec.Command("bpftool", "-j", "-p", "cgroup", "show", cgroupPath)
+	log.WithField("args", cmd.Args).Info("Running bpftool to look up programs attached to cgroup")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list programs attached to %s", cgroupPath)
+	}
+
+	var progs []cgroupProgs
+	err = json.Unmarshal(out, &progs)
+	if err != nil {
+		log.WithError(err).WithField("output", string(out)).Error("BPF program list not json.")
+		return nil, err
+	}
+	return progs, nil
+}
+
+// VerifyConnectTimeLoadBalancer checks that all the connect-time load balancer's programs are
+// attached and that the cgroup they're attached to contains the workloads (unless the cgroup was
+// explicitly configured via cgroupv2).  If either check fails,
+// connect-time load balancing silently doesn't happen for (some) workloads.
+func VerifyConnectTimeLoadBalancer(cgroupv2Root, cgroupv2 string) error {
+	cgroupPath, err := ensureCgroupPath(cgroupv2Root, cgroupv2)
+	if err != nil {
+		return errors.Wrap(err, "failed to set-up cgroupv2")
+	}
+
+	progs, err := listCgroupProgs(cgroupPath)
+	if err != nil {
+		return err
+	}
+	if missing := missingAttachTypes(progs); len(missing) > 0 {
+		return fmt.Errorf("connect-time load balancer programs not attached to %s: %s",
+			cgroupPath, strings.Join(missing, ", "))
+	}
+
+	if cgroupv2 != "" {
+		// Explicitly configured (debug) sub-cgroup, which is only expected to contain what was
+		// moved into it.
+		return nil
+	}
+	if os.Getenv("FELIX_DebugSkipCTLBWorkloadCheck") == "true" {
+		log.Info("FV special case: skipping CTLB workload cgroup check")
+		return nil
+	}
+	containsWorkloads, err := bpf.CgroupContainsWorkloads(cgroupPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to check for workload cgroups under %s", cgroupPath)
+	}
+	if !containsWorkloads {
+		return fmt.Errorf("connect-time load balancer is attached to cgroup %s, which doesn't contain "+
+			"the pods' cgroups; Felix may be in its own cgroup namespace, set BPFCgroupV2Root "+
+			"to a mount of the host's cgroup v2 hierarchy", cgroupPath)
+	}
+	return nil
+}
+
+func missingAttachTypes(progs []cgroupProgs) []string {
+	attached := map[string]bool{}
+	for _, p := range progs {
+		if strings.HasPrefix(p.Name, "cali_") {
+			attached[p.AttachType] = true
+		}
+	}
+	var missing []string
+	for _, t := range connectTimeAttachTypes {
+		if !attached[t] {
+			missing = append(missing, t)
+		}
+	}
+	return missing
+}
+
 func installProgram(name, ipver, bpfMount, cgroupPath, logLevel string, maps ...bpf.Map) error {
 
 	progPinDir := path.Join(bpfMount, "calico_connect4")
@@ -127,14 +195,14 @@ out:
 	return nil
 }
 
-func InstallConnectTimeLoadBalancer(frontendMap, backendMap, rtMap bpf.Map, cgroupv2 string, logLevel string) error {
+func InstallConnectTimeLoadBalancer(frontendMap, backendMap, rtMap bpf.Map, cgroupv2Root, cgroupv2 string, logLevel string) error {
 	bpfMount, err := bpf.MaybeMountBPFfs()
 	if err != nil {
 		log.WithError(err).Error("Failed to mount bpffs, unable to do connect-time load balancing")
 		return err
 	}
 
-	cgroupPath, err := ensureCgroupPath(cgroupv2)
+	cgroupPath, err := ensureCgroupPath(cgroupv2Root, cgroupv2)
 	if err != nil {
 		return errors.Wrap(err, "failed to set-up cgroupv2")
 	}
@@ -206,8 +274,8 @@ func ProgFileName(logLevel string, ipver int) string {
 	return ""
 }
 
-func ensureCgroupPath(cgroupv2 string) (string, error) {
-	cgroupRoot, err := bpf.MaybeMountCgroupV2()
+func ensureCgroupPath(cgroupv2Root, cgroupv2 string) (string, error) {
+	cgroupRoot, err := bpf.FindCgroupV2Root(cgroupv2Root)
 	if err != nil {
 		return "", err
 	}
@@ -0,0 +1,90 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nat
+
+import (
+	"hash/fnv"
+	"sort"
+)
+
+// MaglevTableSize is the number of slots in each Maglev lookup table, it must match
+// NAT_MAGLEV_TABLE_SIZE in the BPF programs.  It is a prime so that every backend's permutation
+// visits every slot.  The backends get an even share of the slots (to within 1%) as long as a
+// service has no more than about 10 backends, with more backends the shares get less even.
+const MaglevTableSize = 1021
+
+// MaglevTable computes a Maglev lookup table (as described in "Maglev: A Fast and Reliable
+// Software Network Load Balancer", NSDI 2016) for the backends, which are identified by strings
+// such as "ip:port".  Each slot of the returned table holds the index of a backend in backends.
+//
+// The table depends only on the set of backends, not on their order, so that all nodes compute
+// the same table.  When a backend is added or removed, only about 1/len(backends) of the slots
+// change to a different backend other than the removed one.
+func MaglevTable(backends []string) []uint32 {
+	return maglevTable(backends, MaglevTableSize)
+}
+
+func maglevTable(backends []string, size uint32) []uint32 {
+	if len(backends) == 0 {
+		return nil
+	}
+
+	// Fill the table in the order of the backends' names rather than the order we were given.
+	order := make([]int, len(backends))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool {
+		return backends[order[a]] < backends[order[b]]
+	})
+
+	offsets := make([]uint32, len(backends))
+	skips := make([]uint32, len(backends))
+	for i, name := range backends {
+		offsets[i] = maglevHash(name, "offset") % size
+		skips[i] = maglevHash(name, "skip")%(size-1) + 1
+	}
+
+	const empty = ^uint32(0)
+	table := make([]uint32, size)
+	for i := range table {
+		table[i] = empty
+	}
+	next := make([]uint32, len(backends))
+	filled := uint32(0)
+	for {
+		for _, i := range order {
+			// Take the next slot in the backend's permutation that is still free.
+			slot := (offsets[i] + next[i]*skips[i]) % size
+			for table[slot] != empty {
+				next[i]++
+				slot = (offsets[i] + next[i]*skips[i]) % size
+			}
+			table[slot] = uint32(i)
+			next[i]++
+			filled++
+			if filled == size {
+				return table
+			}
+		}
+	}
+}
+
+func maglevHash(name, seed string) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(seed))
+	_, _ = h.Write([]byte(name))
+	return h.Sum32()
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nat
+
+import (
+	"fmt"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func maglevBackends(n int) []string {
+	var backends []string
+	for i := 0; i < n; i++ {
+		backends = append(backends, fmt.Sprintf("10.65.%d.%d:8080", i/200, i%200+1))
+	}
+	return backends
+}
+
+func TestMaglevTableEmpty(t *testing.T) {
+	RegisterTestingT(t)
+	Expect(MaglevTable(nil)).To(BeEmpty())
+}
+
+func TestMaglevTableSingleBackend(t *testing.T) {
+	RegisterTestingT(t)
+	table := MaglevTable(maglevBackends(1))
+	Expect(table).To(HaveLen(MaglevTableSize))
+	for _, b := range table {
+		Expect(b).To(BeZero())
+	}
+}
+
+func TestMaglevTableEvenShares(t *testing.T) {
+	RegisterTestingT(t)
+	for _, n := range []int{2, 3, 7, 10} {
+		table := MaglevTable(maglevBackends(n))
+		Expect(table).To(HaveLen(MaglevTableSize))
+		shares := make([]int, n)
+		for _, b := range table {
+			Expect(b).To(BeNumerically("<", n))
+			shares[b]++
+		}
+		for _, s := range shares {
+			Expect(s).To(BeNumerically("~", MaglevTableSize/n, 1+MaglevTableSize/n/100),
+				"uneven shares %v for %d backends", shares, n)
+		}
+	}
+}
+
+func TestMaglevTableOrderIndependent(t *testing.T) {
+	RegisterTestingT(t)
+	backends := maglevBackends(5)
+	reversed := make([]string, len(backends))
+	for i, b := range backends {
+		reversed[len(backends)-1-i] = b
+	}
+
+	table := MaglevTable(backends)
+	revTable := MaglevTable(reversed)
+	for slot := range table {
+		Expect(reversed[revTable[slot]]).To(Equal(backends[table[slot]]))
+	}
+}
+
+func TestMaglevTableMinimalDisruption(t *testing.T) {
+	RegisterTestingT(t)
+	backends := maglevBackends(10)
+	table := MaglevTable(backends)
+
+	// Remove a backend from the middle, which also changes the indices of those after it.
+	removed := backends[4]
+	fewer := append(append([]string{}, backends[:4]...), backends[5:]...)
+	newTable := MaglevTable(fewer)
+
+	moved := 0
+	for slot := range table {
+		before := backends[table[slot]]
+		after := fewer[newTable[slot]]
+		if before == removed {
+			continue
+		}
+		if before != after {
+			moved++
+		}
+	}
+	// Only the removed backend's slots have to move; Maglev moves a few more but far fewer than
+	// the ~90% that a modulo-based mapping would.
+	Expect(moved).To(BeNumerically("<", MaglevTableSize/10))
+}
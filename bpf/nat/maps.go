@@ -259,6 +259,76 @@ func BackendMap(mc *bpf.MapContext) bpf.Map {
 }
 
 // NATMapMem represents FrontendMap loaded into memory
+// struct calico_nat_maglev_key {
+//   uint32_t id;
+//   uint32_t slot;
+// };
+const maglevKeySize = 8
+
+// The value is the ordinal of the backend in the backend map.
+const maglevValueSize = 4
+
+type MaglevKey [maglevKeySize]byte
+
+func NewMaglevKey(id, slot uint32) MaglevKey {
+	var k MaglevKey
+	binary.LittleEndian.PutUint32(k[:4], id)
+	binary.LittleEndian.PutUint32(k[4:8], slot)
+	return k
+}
+
+func (k MaglevKey) ID() uint32 {
+	return binary.LittleEndian.Uint32(k[:4])
+}
+
+func (k MaglevKey) Slot() uint32 {
+	return binary.LittleEndian.Uint32(k[4:8])
+}
+
+func (k MaglevKey) String() string {
+	return fmt.Sprintf("MaglevKey{ID:%d,Slot:%d}", k.ID(), k.Slot())
+}
+
+func (k MaglevKey) AsBytes() []byte {
+	return k[:]
+}
+
+type MaglevValue [maglevValueSize]byte
+
+func NewMaglevValue(ordinal uint32) MaglevValue {
+	var v MaglevValue
+	binary.LittleEndian.PutUint32(v[:], ordinal)
+	return v
+}
+
+func (v MaglevValue) Ordinal() uint32 {
+	return binary.LittleEndian.Uint32(v[:])
+}
+
+func (v MaglevValue) String() string {
+	return fmt.Sprintf("MaglevValue{Ordinal:%d}", v.Ordinal())
+}
+
+func (v MaglevValue) AsBytes() []byte {
+	return v[:]
+}
+
+// MaglevMapParameters describe the map of per-service Maglev lookup tables.  Each table takes
+// MaglevTableSize entries so the map has room for tables for about 500 services.
+var MaglevMapParameters = bpf.MapParameters{
+	Filename:   "/sys/fs/bpf/tc/globals/cali_v4_nat_mag",
+	Type:       "hash",
+	KeySize:    maglevKeySize,
+	ValueSize:  maglevValueSize,
+	MaxEntries: 510000,
+	Name:       "cali_v4_nat_mag",
+	Flags:      unix.BPF_F_NO_PREALLOC,
+}
+
+func MaglevMap(mc *bpf.MapContext) bpf.Map {
+	return mc.NewPinnedMap(MaglevMapParameters)
+}
+
 type MapMem map[FrontendKey]FrontendValue
 
 // Equal compares keys and values of the NATMapMem
@@ -0,0 +1,300 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nat
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+
+	"golang.org/x/sys/unix"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/bpf"
+	"github.com/projectcalico/felix/ip"
+)
+
+// FrontendKeyInterface is implemented by both FrontendKey and FrontendKeyV6 so that code that
+// only inspects the keys does not need to care about the IP family.
+type FrontendKeyInterface interface {
+	Proto() uint8
+	Addr() net.IP
+	Port() uint16
+	SrcCIDR() ip.CIDR
+	PrefixLen() uint32
+	SrcPrefixLen() uint32
+	AsBytes() []byte
+	String() string
+}
+
+// BackendValueInterface is implemented by both BackendValue and BackendValueV6.
+type BackendValueInterface interface {
+	Addr() net.IP
+	Port() uint16
+	AsBytes() []byte
+	String() string
+}
+
+var (
+	_ FrontendKeyInterface  = FrontendKey{}
+	_ FrontendKeyInterface  = FrontendKeyV6{}
+	_ BackendValueInterface = BackendValue{}
+	_ BackendValueInterface = BackendValueV6{}
+)
+
+// struct calico_nat_v6_key {
+//    uint32_t prefixLen;
+//    ipv6_addr_t addr; // NBO
+//    uint16_t port; // HBO
+//    uint8_t protocol;
+//    ipv6_addr_t saddr;
+//    uint8_t pad;
+// };
+const frontendKeyV6Size = 40
+
+// The frontend value and the backend key do not contain any addresses so the IPv6 maps use the
+// same FrontendValue and BackendKey as the IPv4 ones.
+
+// struct calico_nat_v6_dest {
+//    ipv6_addr_t addr;
+//    uint16_t port;
+//    uint8_t pad[2];
+// };
+const backendValueV6Size = 20
+
+//(sizeof(addr) + sizeof(port) + sizeof(proto)) in bits
+const ZeroCIDRPrefixLenV6 = 152
+
+var ZeroCIDRV6 = ip.MustParseCIDROrIP("::/0").(ip.V6CIDR)
+
+type FrontendKeyV6 [frontendKeyV6Size]byte
+
+func NewNATKeyV6(addr net.IP, port uint16, protocol uint8) FrontendKeyV6 {
+	return NewNATKeySrcV6(addr, port, protocol, ZeroCIDRV6)
+}
+
+func NewNATKeySrcV6(addr net.IP, port uint16, protocol uint8, cidr ip.V6CIDR) FrontendKeyV6 {
+	var k FrontendKeyV6
+	prefixlen := ZeroCIDRPrefixLenV6
+	if addr.To4() != nil || len(addr) != 16 {
+		log.WithField("ip", addr).Panic("Bad IP")
+	}
+	binary.LittleEndian.PutUint32(k[:4], uint32(prefixlen)+uint32(cidr.Prefix()))
+	copy(k[4:20], addr)
+	binary.LittleEndian.PutUint16(k[20:22], port)
+	k[22] = protocol
+	copy(k[23:39], cidr.Addr().AsNetIP().To16())
+	return k
+}
+
+func (k FrontendKeyV6) Proto() uint8 {
+	return k[22]
+}
+
+func (k FrontendKeyV6) Addr() net.IP {
+	return k[4:20]
+}
+
+func (k FrontendKeyV6) srcAddr() ip.Addr {
+	var addr ip.V6Addr
+	copy(addr[:], k[23:39])
+	return addr
+}
+
+// This function returns the Prefix length of the source CIDR
+func (k FrontendKeyV6) SrcPrefixLen() uint32 {
+	return k.PrefixLen() - ZeroCIDRPrefixLenV6
+}
+
+func (k FrontendKeyV6) SrcCIDR() ip.CIDR {
+	return ip.CIDRFromAddrAndPrefix(k.srcAddr(), int(k.SrcPrefixLen()))
+}
+
+func (k FrontendKeyV6) PrefixLen() uint32 {
+	return binary.LittleEndian.Uint32(k[0:4])
+}
+
+func (k FrontendKeyV6) Port() uint16 {
+	return binary.LittleEndian.Uint16(k[20:22])
+}
+
+func (k FrontendKeyV6) AsBytes() []byte {
+	return k[:]
+}
+
+func (k FrontendKeyV6) String() string {
+	return fmt.Sprintf("NATKeyV6{Proto:%v Addr:%v Port:%v SrcAddr:%v}", k.Proto(), k.Addr(), k.Port(), k.SrcCIDR())
+}
+
+type BackendValueV6 [backendValueV6Size]byte
+
+func NewNATBackendValueV6(addr net.IP, port uint16) BackendValueV6 {
+	var k BackendValueV6
+	if addr.To4() != nil || len(addr) != 16 {
+		log.WithField("ip", addr).Panic("Bad IP")
+	}
+	copy(k[:16], addr)
+	binary.LittleEndian.PutUint16(k[16:18], port)
+	return k
+}
+
+func (k BackendValueV6) Addr() net.IP {
+	return k[:16]
+}
+
+func (k BackendValueV6) Port() uint16 {
+	return binary.LittleEndian.Uint16(k[16:18])
+}
+
+func (k BackendValueV6) String() string {
+	return fmt.Sprintf("NATBackendValueV6{Addr:%v Port:%v}", k.Addr(), k.Port())
+}
+
+func (k BackendValueV6) AsBytes() []byte {
+	return k[:]
+}
+
+var FrontendMapV6Parameters = bpf.MapParameters{
+	Filename:   "/sys/fs/bpf/tc/globals/cali_v6_nat_fe",
+	Type:       "lpm_trie",
+	KeySize:    frontendKeyV6Size,
+	ValueSize:  frontendValueSize,
+	MaxEntries: 511000,
+	Name:       "cali_v6_nat_fe",
+	Flags:      unix.BPF_F_NO_PREALLOC,
+}
+
+func FrontendMapV6(mc *bpf.MapContext) bpf.Map {
+	return mc.NewPinnedMap(FrontendMapV6Parameters)
+}
+
+var BackendMapV6Parameters = bpf.MapParameters{
+	Filename:   "/sys/fs/bpf/tc/globals/cali_v6_nat_be",
+	Type:       "hash",
+	KeySize:    backendKeySize,
+	ValueSize:  backendValueV6Size,
+	MaxEntries: 510000,
+	Name:       "cali_v6_nat_be",
+	Flags:      unix.BPF_F_NO_PREALLOC,
+}
+
+func BackendMapV6(mc *bpf.MapContext) bpf.Map {
+	return mc.NewPinnedMap(BackendMapV6Parameters)
+}
+
+// MapMemV6 represents FrontendMapV6 loaded into memory
+type MapMemV6 map[FrontendKeyV6]FrontendValue
+
+// Equal compares keys and values of the MapMemV6
+func (m MapMemV6) Equal(cmp MapMemV6) bool {
+	if len(m) != len(cmp) {
+		return false
+	}
+
+	for k, v := range m {
+		v2, ok := cmp[k]
+		if !ok || v != v2 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// LoadFrontendMapV6 loads the IPv6 NAT map into a go map or returns an error
+func LoadFrontendMapV6(m bpf.Map) (MapMemV6, error) {
+	ret := make(MapMemV6)
+
+	if err := m.Open(); err != nil {
+		return nil, err
+	}
+
+	err := m.Iter(MapMemV6Iter(ret))
+	if err != nil {
+		ret = nil
+	}
+
+	return ret, err
+}
+
+// MapMemV6Iter returns bpf.MapIter that loads the provided MapMemV6
+func MapMemV6Iter(m MapMemV6) bpf.IterCallback {
+	ks := len(FrontendKeyV6{})
+	vs := len(FrontendValue{})
+
+	return func(k, v []byte) bpf.IteratorAction {
+		var key FrontendKeyV6
+		copy(key[:ks], k[:ks])
+
+		var val FrontendValue
+		copy(val[:vs], v[:vs])
+
+		m[key] = val
+		return bpf.IterNone
+	}
+}
+
+// BackendMapMemV6 represents BackendMapV6 loaded into memory
+type BackendMapMemV6 map[BackendKey]BackendValueV6
+
+// Equal compares keys and values of the BackendMapMemV6
+func (m BackendMapMemV6) Equal(cmp BackendMapMemV6) bool {
+	if len(m) != len(cmp) {
+		return false
+	}
+
+	for k, v := range m {
+		v2, ok := cmp[k]
+		if !ok || v != v2 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// LoadBackendMapV6 loads the IPv6 NATBackend map into a go map or returns an error
+func LoadBackendMapV6(m bpf.Map) (BackendMapMemV6, error) {
+	ret := make(BackendMapMemV6)
+
+	if err := m.Open(); err != nil {
+		return nil, err
+	}
+
+	err := m.Iter(BackendMapMemV6Iter(ret))
+	if err != nil {
+		ret = nil
+	}
+
+	return ret, err
+}
+
+// BackendMapMemV6Iter returns bpf.MapIter that loads the provided BackendMapMemV6
+func BackendMapMemV6Iter(m BackendMapMemV6) bpf.IterCallback {
+	ks := len(BackendKey{})
+	vs := len(BackendValueV6{})
+
+	return func(k, v []byte) bpf.IteratorAction {
+		var key BackendKey
+		copy(key[:ks], k[:ks])
+
+		var val BackendValueV6
+		copy(val[:vs], v[:vs])
+
+		m[key] = val
+		return bpf.IterNone
+	}
+}
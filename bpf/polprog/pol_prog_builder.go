@@ -18,6 +18,8 @@ import (
 	"fmt"
 	"math"
 	"math/bits"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/projectcalico/felix/bpf/ipsets"
@@ -126,6 +128,9 @@ type Rule struct {
 type Policy struct {
 	Name  string
 	Rules []Rule
+	// DefaultAction, if set, is the action for packets that reach the end of the rules: "allow",
+	// "deny" or "next-tier".  Otherwise, such packets continue to the next policy.
+	DefaultAction string
 }
 
 type Tier struct {
@@ -226,7 +231,11 @@ func (p *Builder) Instructions(rules Rules) (Insns, error) {
 	}
 
 	p.writeProgramFooter()
-	return p.b.Assemble()
+	insns, err := p.b.Assemble()
+	if err != nil {
+		return nil, err
+	}
+	return withPolicyHash(insns)
 }
 
 // writeProgramHeader emits instructions to load the state from the state map, leaving
@@ -247,7 +256,7 @@ func (p *Builder) writeProgramHeader() {
 	p.b.LoadMapFD(R1, uint32(p.stateMapFD)) // R1 = 0 (64-bit immediate)
 	p.b.Call(HelperMapLookupElem)           // Call helper
 	// Check return value for NULL.
-	p.b.JumpEqImm64(R0, 0, "deny")
+	p.b.JumpEqImm64(R0, 0, "exit")
 	// Save state pointer in R9.
 	p.b.Mov64(R9, R0)
 	p.b.LabelNextInsn("policy")
@@ -275,8 +284,15 @@ func (p *Builder) writeJumpIfToOrFromHost(label string) {
 
 // writeProgramFooter emits the program exit jump targets.
 func (p *Builder) writeProgramFooter() {
-	// Fall through here if there's no match.  Also used when we hit an error or if policy rejects packet.
+	// Fall through here if there's no match.  Also used if policy rejects packet.  The epilogue
+	// does the drop so that it is counted along with the program's other drops.
 	p.b.LabelNextInsn("deny")
+	p.b.MovImm32(R1, int32(state.PolicyDeny))
+	p.b.Store32(R9, R1, stateOffPolResult)
+	p.writeJumpToEpilogue()
+
+	// Fall through if the tail call fails or if we hit an error before loading the state.
+	p.b.LabelNextInsn("exit")
 	p.b.MovImm64(R0, 2 /* TC_ACT_SHOT */)
 	p.b.Exit()
 
@@ -285,11 +301,7 @@ func (p *Builder) writeProgramFooter() {
 		// Store the policy result in the state for the next program to see.
 		p.b.MovImm32(R1, int32(state.PolicyAllow))
 		p.b.Store32(R9, R1, stateOffPolResult)
-		// Execute the tail call.
-		p.b.Mov64(R1, R6)                      // First arg is the context.
-		p.b.LoadMapFD(R2, uint32(p.jumpMapFD)) // Second arg is the map.
-		p.b.MovImm32(R3, jumpIdxEpilogue)      // Third arg is the index (rather than a pointer to the index).
-		p.b.Call(HelperTailCall)
+		p.writeJumpToEpilogue()
 
 		// Fall through if tail call fails.
 		p.b.MovImm32(R1, state.PolicyTailCallFailed)
@@ -299,6 +311,15 @@ func (p *Builder) writeProgramFooter() {
 	}
 }
 
+// writeJumpToEpilogue emits a tail call to the epilogue program.  Execution continues with the
+// next instruction if the tail call fails.
+func (p *Builder) writeJumpToEpilogue() {
+	p.b.Mov64(R1, R6)                      // First arg is the context.
+	p.b.LoadMapFD(R2, uint32(p.jumpMapFD)) // Second arg is the map.
+	p.b.MovImm32(R3, jumpIdxEpilogue)      // Third arg is the index (rather than a pointer to the index).
+	p.b.Call(HelperTailCall)
+}
+
 func (p *Builder) setUpIPSetKey(ipsetID uint64, keyOffset, ipOffset, portOffset int16) {
 	// TODO track whether we've already done an initialisation and skip the parts that don't change.
 	// Zero the padding.
@@ -381,6 +402,12 @@ func (p *Builder) writePolicyRules(policy Policy, actionLabels map[string]string
 func (p *Builder) writePolicy(policy Policy, actionLabels map[string]string, destLeg matchLeg) {
 	log.Debugf("Start of policy %q %d", policy.Name, p.policyID)
 	p.writePolicyRules(policy, actionLabels, destLeg)
+	if policy.DefaultAction != "" {
+		log.Debugf("Default action for policy %q: %s", policy.Name, policy.DefaultAction)
+		p.writeRule(Rule{
+			Rule: &proto.Rule{},
+		}, actionLabels[strings.ToLower(policy.DefaultAction)], destLeg)
+	}
 	log.Debugf("End of policy %q %d", policy.Name, p.policyID)
 	p.policyID++
 }
@@ -680,7 +707,7 @@ func (p *Builder) writePortsMatch(negate bool, leg matchLeg, ports []*proto.Port
 	// R1 = port to test against.
 	p.b.Load16(R1, R9, leg.offsetToStatePortField())
 
-	for _, portRange := range ports {
+	for _, portRange := range mergePortRanges(ports) {
 		if portRange.First == portRange.Last {
 			// Optimisation, single port, just do a comparison.
 			p.b.JumpEqImm64(R1, portRange.First, onMatchLabel)
@@ -724,6 +751,33 @@ func (p *Builder) writePortsMatch(negate bool, leg matchLeg, ports []*proto.Port
 	}
 }
 
+// mergePortRanges returns the given port ranges sorted and with overlapping and adjacent ranges
+// merged.  A rule may list the same port several times or mix single ports with ranges that cover
+// them (for example, after a named port has been resolved); merging them means that we test each
+// port at most once and that the ranges can be checked in order.
+func mergePortRanges(ports []*proto.PortRange) []*proto.PortRange {
+	if len(ports) < 2 {
+		return ports
+	}
+	sorted := make([]*proto.PortRange, len(ports))
+	copy(sorted, ports)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].First < sorted[j].First
+	})
+	merged := []*proto.PortRange{{First: sorted[0].First, Last: sorted[0].Last}}
+	for _, r := range sorted[1:] {
+		last := merged[len(merged)-1]
+		if r.First <= last.Last+1 {
+			if r.Last > last.Last {
+				last.Last = r.Last
+			}
+			continue
+		}
+		merged = append(merged, &proto.PortRange{First: r.First, Last: r.Last})
+	}
+	return merged
+}
+
 func (p *Builder) freshPerRuleLabel() string {
 	part := p.rulePartID
 	p.rulePartID++
@@ -734,6 +788,8 @@ func (p *Builder) endOfRuleLabel() string {
 	return fmt.Sprintf("rule_%d_no_match", p.ruleID)
 }
 
+// protocolToNumber converts a protocol to its IANA number.  Names are limited to those accepted
+// by the v3 API; numbers (including numbers that arrive as strings) are passed through.
 func protocolToNumber(protocol *proto.Protocol) uint8 {
 	var pcol uint8
 	switch p := protocol.NumberOrName.(type) {
@@ -745,11 +801,23 @@ func protocolToNumber(protocol *proto.Protocol) uint8 {
 			pcol = 17
 		case "icmp":
 			pcol = 1
+		case "icmpv6":
+			pcol = 58
 		case "sctp":
 			pcol = 132
+		case "udplite":
+			pcol = 136
+		default:
+			num, err := strconv.ParseUint(p.Name, 10, 8)
+			if err != nil {
+				log.WithField("protocol", p.Name).Panic("Unknown protocol name")
+			}
+			pcol = uint8(num)
 		}
 	case *proto.Protocol_Number:
 		pcol = uint8(p.Number)
+	default:
+		log.WithField("protocol", protocol).Panic("Unknown protocol type")
 	}
 	return pcol
 }
@@ -15,10 +15,13 @@
 package polprog
 
 import (
+	"io/ioutil"
+	"os"
 	"testing"
 
 	. "github.com/onsi/gomega"
 
+	. "github.com/projectcalico/felix/bpf/asm"
 	"github.com/projectcalico/felix/idalloc"
 	"github.com/projectcalico/felix/proto"
 )
@@ -99,3 +102,120 @@ func TestLogActionIgnored(t *testing.T) {
 	Expect(err).NotTo(HaveOccurred())
 	Expect(noOpInsns).To(Equal(insns))
 }
+
+func TestProtocolToNumber(t *testing.T) {
+	RegisterTestingT(t)
+
+	for _, tc := range []struct {
+		protocol *proto.Protocol
+		expected uint8
+	}{
+		{&proto.Protocol{NumberOrName: &proto.Protocol_Name{Name: "TCP"}}, 6},
+		{&proto.Protocol{NumberOrName: &proto.Protocol_Name{Name: "udp"}}, 17},
+		{&proto.Protocol{NumberOrName: &proto.Protocol_Name{Name: "ICMP"}}, 1},
+		{&proto.Protocol{NumberOrName: &proto.Protocol_Name{Name: "ICMPv6"}}, 58},
+		{&proto.Protocol{NumberOrName: &proto.Protocol_Name{Name: "SCTP"}}, 132},
+		{&proto.Protocol{NumberOrName: &proto.Protocol_Name{Name: "UDPLite"}}, 136},
+		{&proto.Protocol{NumberOrName: &proto.Protocol_Name{Name: "112"}}, 112},
+		{&proto.Protocol{NumberOrName: &proto.Protocol_Number{Number: 112}}, 112},
+		{&proto.Protocol{NumberOrName: &proto.Protocol_Number{Number: 253}}, 253},
+	} {
+		Expect(protocolToNumber(tc.protocol)).To(Equal(tc.expected), "Wrong number for %v", tc.protocol)
+	}
+
+	Expect(func() {
+		protocolToNumber(&proto.Protocol{NumberOrName: &proto.Protocol_Name{Name: "foo"}})
+	}).To(Panic())
+}
+
+func TestPolicyHashEmbedded(t *testing.T) {
+	RegisterTestingT(t)
+	alloc := idalloc.New()
+
+	build := func(action string) (Insns, string) {
+		pg := NewBuilder(alloc, 1, 2, 3)
+		insns, err := pg.Instructions(Rules{
+			Tiers: []Tier{{
+				Policies: []Policy{{
+					Rules: []Rule{{Rule: &proto.Rule{Action: action}}},
+				}},
+			}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		hash, ok := EmbeddedPolicyHash(insns)
+		Expect(ok).To(BeTrue())
+		return insns, hash
+	}
+
+	insns, hash := build("Allow")
+	Expect(hash).To(HaveLen(16))
+	_, sameHash := build("Allow")
+	Expect(sameHash).To(Equal(hash))
+	_, otherHash := build("Deny")
+	Expect(otherHash).NotTo(Equal(hash))
+
+	// Read the hash back from the raw program.
+	parsed, err := ParseInsns(insns.AsBytes())
+	Expect(err).NotTo(HaveOccurred())
+	parsedHash, ok := EmbeddedPolicyHash(parsed)
+	Expect(ok).To(BeTrue())
+	Expect(parsedHash).To(Equal(hash))
+	_, err = ParseInsns(insns.AsBytes()[1:])
+	Expect(err).To(HaveOccurred())
+
+	// A program without a hash.
+	_, ok = EmbeddedPolicyHash(insns[policyHashInsns:])
+	Expect(ok).To(BeFalse())
+}
+
+func TestAttachmentRecords(t *testing.T) {
+	RegisterTestingT(t)
+	dir, err := ioutil.TempDir("", "polprog")
+	Expect(err).NotTo(HaveOccurred())
+	defer os.RemoveAll(dir)
+
+	_, err = ReadAttachmentRecord(dir, "eth0", "ingress")
+	Expect(os.IsNotExist(err)).To(BeTrue())
+
+	rec := AttachmentRecord{JumpMapID: 12, PolicyHash: "0123456789abcdef"}
+	Expect(WriteAttachmentRecord(dir, "eth0", "ingress", rec)).To(Succeed())
+	Expect(ReadAttachmentRecord(dir, "eth0", "ingress")).To(Equal(&rec))
+	_, err = ReadAttachmentRecord(dir, "eth0", "egress")
+	Expect(os.IsNotExist(err)).To(BeTrue())
+
+	Expect(RemoveAttachmentRecords(dir, "eth0")).To(Succeed())
+	_, err = ReadAttachmentRecord(dir, "eth0", "ingress")
+	Expect(os.IsNotExist(err)).To(BeTrue())
+}
+
+func TestMergePortRanges(t *testing.T) {
+	RegisterTestingT(t)
+
+	for _, tc := range []struct {
+		ports    []*proto.PortRange
+		expected []*proto.PortRange
+	}{
+		{nil, nil},
+		{[]*proto.PortRange{{First: 80, Last: 80}}, []*proto.PortRange{{First: 80, Last: 80}}},
+		{
+			[]*proto.PortRange{{First: 8080, Last: 8081}, {First: 80, Last: 81}},
+			[]*proto.PortRange{{First: 80, Last: 81}, {First: 8080, Last: 8081}},
+		},
+		{
+			// Overlapping, contained and adjacent ranges.
+			[]*proto.PortRange{{First: 90, Last: 100}, {First: 80, Last: 95}, {First: 85, Last: 85}, {First: 101, Last: 110}},
+			[]*proto.PortRange{{First: 80, Last: 110}},
+		},
+		{
+			[]*proto.PortRange{{First: 443, Last: 443}, {First: 0, Last: 80}, {First: 443, Last: 443}},
+			[]*proto.PortRange{{First: 0, Last: 80}, {First: 443, Last: 443}},
+		},
+	} {
+		Expect(mergePortRanges(tc.ports)).To(Equal(tc.expected), "Wrong merge for %v", tc.ports)
+	}
+
+	// The input must not be modified.
+	ports := []*proto.PortRange{{First: 80, Last: 90}, {First: 85, Last: 100}}
+	mergePortRanges(ports)
+	Expect(ports).To(Equal([]*proto.PortRange{{First: 80, Last: 90}, {First: 85, Last: 100}}))
+}
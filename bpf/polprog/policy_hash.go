@@ -0,0 +1,143 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package polprog
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/projectcalico/felix/bpf/asm"
+)
+
+// policyHashMarker is loaded into R0 by the first instruction of every policy program and the
+// program's hash by the second, so that the hash of an attached program can be read back from its
+// instructions.  The low byte is the version of the marker.  R0 is overwritten before it is used.
+const policyHashMarker = 0x63616c69706f6c01 // "calipol" + version 1.
+
+// policyHashInsns is the number of instructions that the marker and hash take.
+const policyHashInsns = 4
+
+// withPolicyHash prepends the marker and the hash of the program to the program.  Jumps are
+// relative so prepending doesn't affect the rest of the program.
+func withPolicyHash(insns Insns) (Insns, error) {
+	sum := sha256.Sum256(insns.AsBytes())
+	b := NewBlock()
+	b.LoadImm64(R0, int64(policyHashMarker))
+	b.LoadImm64(R0, int64(binary.LittleEndian.Uint64(sum[:8])))
+	header, err := b.Assemble()
+	if err != nil {
+		return nil, err
+	}
+	return append(header, insns...), nil
+}
+
+// EmbeddedPolicyHash returns the hash that the policy program embeds in its first instructions, or
+// false if the program doesn't start with a hash (for example, because it was generated by an
+// older version of Felix).
+func EmbeddedPolicyHash(insns Insns) (string, bool) {
+	if len(insns) < policyHashInsns {
+		return "", false
+	}
+	imm64 := func(i int) (uint64, bool) {
+		if insns[i].OpCode() != LoadImm64 || insns[i].Dst() != R0 || insns[i].Src() != 0 {
+			return 0, false
+		}
+		return uint64(uint32(insns[i].Imm())) | uint64(uint32(insns[i+1].Imm()))<<32, true
+	}
+	if marker, ok := imm64(0); !ok || marker != policyHashMarker {
+		return "", false
+	}
+	hash, ok := imm64(2)
+	if !ok {
+		return "", false
+	}
+	return FormatPolicyHash(hash), true
+}
+
+// ParseInsns splits raw program bytes, such as the output of "bpftool prog dump xlated ...
+// file ...", into instructions.
+func ParseInsns(raw []byte) (Insns, error) {
+	if len(raw)%len(Insn{}) != 0 {
+		return nil, fmt.Errorf("program length %d is not a multiple of the instruction size", len(raw))
+	}
+	insns := make(Insns, len(raw)/len(Insn{}))
+	for i := range insns {
+		copy(insns[i][:], raw[i*len(Insn{}):])
+	}
+	return insns, nil
+}
+
+func FormatPolicyHash(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+// AttachmentRecordDir is where Felix records the policy program that it has put in the jump map
+// of each of its TC programs.  "calico-bpf policy verify" compares the records with the programs
+// that are actually in the jump maps.
+const AttachmentRecordDir = "/var/run/calico/bpf/policy"
+
+// AttachmentRecord is Felix's record of the policy program for one TC hook of an interface.
+type AttachmentRecord struct {
+	// JumpMapID is the ID of the jump map of the TC program that Felix attached to the hook.
+	JumpMapID int `json:"jumpMapID"`
+	// PolicyHash is the hash embedded in the policy program that Felix put in the jump map or ""
+	// if Felix removed the policy program.
+	PolicyHash string `json:"policyHash"`
+}
+
+func attachmentRecordPath(dir, iface, hook string) string {
+	return filepath.Join(dir, iface, hook)
+}
+
+// WriteAttachmentRecord writes the record for the hook of the interface.
+func WriteAttachmentRecord(dir, iface, hook string, rec AttachmentRecord) error {
+	path := attachmentRecordPath(dir, iface, hook)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	// Write then rename so that a reader never sees a partial record.
+	if err := ioutil.WriteFile(path+".tmp", data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// ReadAttachmentRecord reads the record for the hook of the interface.  Returns an error that
+// satisfies os.IsNotExist if there is no record.
+func ReadAttachmentRecord(dir, iface, hook string) (*AttachmentRecord, error) {
+	data, err := ioutil.ReadFile(attachmentRecordPath(dir, iface, hook))
+	if err != nil {
+		return nil, err
+	}
+	var rec AttachmentRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("failed to parse attachment record: %w", err)
+	}
+	return &rec, nil
+}
+
+// RemoveAttachmentRecords removes the records for all hooks of the interface.
+func RemoveAttachmentRecords(dir, iface string) error {
+	return os.RemoveAll(filepath.Join(dir, iface))
+}
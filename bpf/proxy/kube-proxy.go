@@ -34,7 +34,7 @@ import (
 // configuration, restarting etc.
 type KubeProxy struct {
 	proxy  Proxy
-	syncer DPSyncer
+	syncer *Syncer
 
 	hostIPUpdates chan []net.IP
 	stopOnce      sync.Once
@@ -47,16 +47,22 @@ type KubeProxy struct {
 	frontendMap bpf.Map
 	backendMap  bpf.Map
 	affinityMap bpf.Map
+	maglevMap   bpf.Map
 	ctMap       bpf.Map
 	rt          *RTCache
 	opts        []Option
 
-	dsrEnabled bool
+	dsrEnabled      bool
+	maglevEnabled   bool
+	ctScanTriggerFn func()
+	localLBIPsFn    func(ips []net.IP)
+
+	nodePortSrcRanges []string
 }
 
 // StartKubeProxy start a new kube-proxy if there was no error
 func StartKubeProxy(k8s kubernetes.Interface, hostname string,
-	frontendMap, backendMap, affinityMap, ctMap bpf.Map, opts ...Option) (*KubeProxy, error) {
+	frontendMap, backendMap, affinityMap, maglevMap, ctMap bpf.Map, opts ...Option) (*KubeProxy, error) {
 
 	kp := &KubeProxy{
 		k8s:         k8s,
@@ -64,6 +70,7 @@ func StartKubeProxy(k8s kubernetes.Interface, hostname string,
 		frontendMap: frontendMap,
 		backendMap:  backendMap,
 		affinityMap: affinityMap,
+		maglevMap:   maglevMap,
 		ctMap:       ctMap,
 		opts:        opts,
 		rt:          NewRTCache(),
@@ -106,17 +113,17 @@ func (kp *KubeProxy) run(hostIPs []net.IP) error {
 	kp.lock.Lock()
 	defer kp.lock.Unlock()
 
-	withLocalNP := make([]net.IP, len(hostIPs), len(hostIPs)+1)
-	copy(withLocalNP, hostIPs)
-	withLocalNP = append(withLocalNP, podNPIP)
-
 	feCache := cachingmap.New(nat.FrontendMapParameters, kp.frontendMap)
 	beCache := cachingmap.New(nat.BackendMapParameters, kp.backendMap)
 
-	syncer, err := NewSyncer(withLocalNP, feCache, beCache, kp.affinityMap, kp.rt)
+	syncer, err := NewSyncer(nodePortIPs(hostIPs), feCache, beCache, kp.affinityMap, kp.rt)
 	if err != nil {
 		return errors.WithMessage(err, "new bpf syncer")
 	}
+	syncer.SetMaglevMap(cachingmap.New(nat.MaglevMapParameters, kp.maglevMap), kp.maglevEnabled)
+	syncer.SetConntrackScanTriggerFn(kp.ctScanTriggerFn)
+	syncer.SetLocalLBIPsFn(kp.localLBIPsFn)
+	syncer.SetNodePortSourceRanges(kp.nodePortSrcRanges)
 
 	proxy, err := New(kp.k8s, syncer, kp.hostname, kp.opts...)
 	if err != nil {
@@ -141,43 +148,24 @@ func (kp *KubeProxy) start() error {
 		return err
 	}
 
+	// Later updates change the NodePort frontends in place, without restarting the proxy, so
+	// that services keep working while the host gains or loses addresses (for example, when a
+	// VIP fails over).
 	kp.wg.Add(1)
 	go func() {
 		defer kp.wg.Done()
 		for {
-			hostIPs, ok := <-kp.hostIPUpdates
-			if !ok {
-				defer log.Error("kube-proxy stopped since hostIPUpdates closed")
-				kp.proxy.Stop()
-				return
-			}
-
-			stopped := make(chan struct{})
-
-			go func() {
-				defer close(stopped)
-				defer log.Info("kube-proxy stopped to restart with updated host IPs")
-				kp.proxy.Stop()
-			}()
-
-		waitforstop:
-			for {
-				select {
-				case hostIPs, ok = <-kp.hostIPUpdates:
-					if !ok {
-						log.Error("kube-proxy: hostIPUpdates closed")
-						return
-					}
-				case <-kp.exiting:
-					log.Info("kube-proxy: exiting")
+			select {
+			case hostIPs, ok := <-kp.hostIPUpdates:
+				if !ok {
+					log.Info("kube-proxy: hostIPUpdates closed")
 					return
-				case <-stopped:
-					err = kp.run(hostIPs)
-					if err != nil {
-						log.Panic("kube-proxy failed to start after host IPs update")
-					}
-					break waitforstop
 				}
+				log.Infof("kube-proxy updating NodePorts for new host IPs %+v", hostIPs)
+				kp.syncer.SetNodePortIPs(nodePortIPs(hostIPs))
+			case <-kp.exiting:
+				log.Info("kube-proxy: exiting")
+				return
 			}
 		}
 	}()
@@ -185,6 +173,13 @@ func (kp *KubeProxy) start() error {
 	return nil
 }
 
+// nodePortIPs returns the IPs that NodePorts should be reachable on, given the host's IPs.
+func nodePortIPs(hostIPs []net.IP) []net.IP {
+	ips := make([]net.IP, len(hostIPs), len(hostIPs)+1)
+	copy(ips, hostIPs)
+	return append(ips, podNPIP)
+}
+
 // OnHostIPsUpdate should be used by an external user to update the proxy's list
 // of host IPs
 func (kp *KubeProxy) OnHostIPsUpdate(IPs []net.IP) {
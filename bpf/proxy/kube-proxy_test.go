@@ -25,6 +25,7 @@ import (
 
 	"github.com/projectcalico/felix/bpf/conntrack"
 	"github.com/projectcalico/felix/bpf/mock"
+	"github.com/projectcalico/felix/bpf/nat"
 	proxy "github.com/projectcalico/felix/bpf/proxy"
 )
 
@@ -34,6 +35,7 @@ var _ = Describe("BPF kube-proxy", func() {
 	front := newMockNATMap()
 	back := newMockNATBackendMap()
 	aff := newMockAffinityMap()
+	mag := mock.NewMockMap(nat.MaglevMapParameters)
 	ct := mock.NewMockMap(conntrack.MapParams)
 
 	var p *proxy.KubeProxy
@@ -78,7 +80,7 @@ var _ = Describe("BPF kube-proxy", func() {
 		}
 
 		k8s := fake.NewSimpleClientset(testSvc, testSvcEps)
-		p, _ = proxy.StartKubeProxy(k8s, "test-node", front, back, aff, ct, proxy.WithImmediateSync())
+		p, _ = proxy.StartKubeProxy(k8s, "test-node", front, back, aff, mag, ct, proxy.WithImmediateSync())
 	})
 
 	AfterEach(func() {
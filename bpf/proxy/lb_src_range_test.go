@@ -251,3 +251,90 @@ var _ = Describe("BPF Load Balancer source range", func() {
 		testfn(proxy.K8sSvcWithLoadBalancerIPs)
 	})
 })
+
+var _ = Describe("BPF NodePort source range", func() {
+	var (
+		svcs *mockNATMap
+		s    *proxy.Syncer
+	)
+
+	nodeIP := net.IPv4(192, 168, 0, 1)
+	proto := proxy.ProtoV1ToIntPanic(v1.ProtocolTCP)
+	saddr1 := ip.MustParseCIDROrIP("35.0.1.0/24").(ip.V4CIDR)
+	saddr2 := ip.MustParseCIDROrIP("33.0.0.0/16").(ip.V4CIDR)
+
+	svcKey := k8sp.ServicePortName{
+		NamespacedName: types.NamespacedName{
+			Namespace: "default",
+			Name:      "test-service",
+		},
+	}
+	state := proxy.DPSyncerState{
+		SvcMap: k8sp.ServiceMap{
+			svcKey: proxy.NewK8sServicePort(
+				net.IPv4(10, 0, 0, 2),
+				2222,
+				v1.ProtocolTCP,
+				proxy.K8sSvcWithNodePort(30333),
+			),
+		},
+		EpsMap: k8sp.EndpointsMap{
+			svcKey: []k8sp.Endpoint{&k8sp.BaseEndpointInfo{Endpoint: "10.1.0.1:5555"}},
+		},
+	}
+
+	newSyncer := func(srcRanges []string) {
+		feCache := cachingmap.New(nat.FrontendMapParameters, svcs)
+		beCache := cachingmap.New(nat.BackendMapParameters, newMockNATBackendMap())
+		s, _ = proxy.NewSyncer([]net.IP{nodeIP}, feCache, beCache, newMockAffinityMap(), proxy.NewRTCache())
+		s.SetNodePortSourceRanges(srcRanges)
+	}
+
+	BeforeEach(func() {
+		svcs = newMockNATMap()
+	})
+
+	It("should program the NodePort normally without source ranges", func() {
+		newSyncer(nil)
+		Expect(s.Apply(state)).NotTo(HaveOccurred())
+
+		Expect(svcs.m).To(HaveLen(2))
+		val, ok := svcs.m[nat.NewNATKey(nodeIP, 30333, proto)]
+		Expect(ok).To(BeTrue())
+		Expect(val.Count()).To(Equal(uint32(1)))
+	})
+
+	It("should only allow the source ranges to reach the NodePort", func() {
+		newSyncer([]string{"35.0.1.0/24", "33.0.0.0/16", "fd00::/64"})
+		Expect(s.Apply(state)).NotTo(HaveOccurred())
+
+		// ClusterIP, NodePort blackhole and one entry per IPv4 source range.
+		Expect(svcs.m).To(HaveLen(4))
+		Expect(svcs.m).To(HaveKey(nat.NewNATKeySrc(nodeIP, 30333, proto, saddr1)))
+		Expect(svcs.m).To(HaveKey(nat.NewNATKeySrc(nodeIP, 30333, proto, saddr2)))
+		Expect(svcs.m[nat.NewNATKey(nodeIP, 30333, proto)]).To(Equal(nat.NewNATValue(0, nat.BlackHoleCount, 0, 0)))
+
+		By("not restricting the ClusterIP")
+		val, ok := svcs.m[nat.NewNATKey(net.IPv4(10, 0, 0, 2), 2222, proto)]
+		Expect(ok).To(BeTrue())
+		Expect(val.Count()).To(Equal(uint32(1)))
+	})
+
+	It("should remove stale source ranges after a restart", func() {
+		newSyncer([]string{"35.0.1.0/24", "33.0.0.0/16"})
+		Expect(s.Apply(state)).NotTo(HaveOccurred())
+		Expect(svcs.m).To(HaveLen(4))
+		s.Stop()
+
+		newSyncer([]string{"35.0.1.0/24"})
+		Expect(s.Apply(state)).NotTo(HaveOccurred())
+		Expect(svcs.m).To(HaveLen(3))
+		Expect(svcs.m).NotTo(HaveKey(nat.NewNATKeySrc(nodeIP, 30333, proto, saddr2)))
+
+		s.Stop()
+		newSyncer(nil)
+		Expect(s.Apply(state)).NotTo(HaveOccurred())
+		Expect(svcs.m).To(HaveLen(2))
+		Expect(svcs.m[nat.NewNATKey(nodeIP, 30333, proto)].Count()).To(Equal(uint32(1)))
+	})
+})
@@ -0,0 +1,64 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proxy
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The metrics below use the same names and buckets as kube-proxy's (see
+// k8s.io/kubernetes/pkg/proxy/metrics) so that dashboards and alerts written for kube-proxy keep
+// working when the BPF proxy replaces it.  We can't use kube-proxy's metrics directly because they
+// are registered with the Kubernetes component registry, which Felix doesn't serve.
+const kubeProxySubsystem = "kubeproxy"
+
+var (
+	histogramSyncDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Subsystem: kubeProxySubsystem,
+		Name:      "sync_proxy_rules_duration_seconds",
+		Help:      "SyncProxyRules latency in seconds",
+		Buckets:   prometheus.ExponentialBuckets(0.001, 2, 15),
+	})
+	gaugeLastSyncTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Subsystem: kubeProxySubsystem,
+		Name:      "sync_proxy_rules_last_timestamp_seconds",
+		Help:      "The last time proxy rules were successfully synced",
+	})
+	countEndpointChanges = prometheus.NewCounter(prometheus.CounterOpts{
+		Subsystem: kubeProxySubsystem,
+		Name:      "sync_proxy_rules_endpoint_changes_total",
+		Help:      "Cumulative proxy rules Endpoint changes",
+	})
+	countServiceChanges = prometheus.NewCounter(prometheus.CounterOpts{
+		Subsystem: kubeProxySubsystem,
+		Name:      "sync_proxy_rules_service_changes_total",
+		Help:      "Cumulative proxy rules Service changes",
+	})
+	// kube-proxy counts its iptables-restore failures; the equivalent for us is a failure to
+	// program the BPF maps.
+	countSyncFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Subsystem: kubeProxySubsystem,
+		Name:      "sync_proxy_rules_iptables_restore_failures_total",
+		Help:      "Cumulative proxy dataplane programming failures",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(histogramSyncDuration)
+	prometheus.MustRegister(gaugeLastSyncTimestamp)
+	prometheus.MustRegister(countEndpointChanges)
+	prometheus.MustRegister(countServiceChanges)
+	prometheus.MustRegister(countSyncFailures)
+}
@@ -15,6 +15,7 @@
 package proxy
 
 import (
+	"net"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -76,3 +77,43 @@ func WithDSREnabled() Option {
 		return nil
 	})
 }
+
+// WithMaglev makes the proxy write a Maglev lookup table for each service so that the BPF
+// programs pick the same backend for a flow even as other backends come and go.
+func WithMaglev() Option {
+	return makeKubeProxyOption(func(kp *KubeProxy) error {
+		kp.maglevEnabled = true
+		return nil
+	})
+}
+
+// WithConntrackScanTrigger sets a function that the proxy calls when a service update
+// removed some frontends or backends so that the conntrack scanner can clean up the
+// connections to them without waiting for its next periodic scan.  The function must
+// not block.
+func WithConntrackScanTrigger(f func()) Option {
+	return makeKubeProxyOption(func(kp *KubeProxy) error {
+		kp.ctScanTriggerFn = f
+		return nil
+	})
+}
+
+// WithLocalLBIPsCallback sets a function that the proxy calls with the LoadBalancer status IPs
+// that have local backends, whenever that set changes.  It allows a BGP layer to advertise only
+// the IPs that this node can serve.  The function must not block.
+func WithLocalLBIPsCallback(f func(ips []net.IP)) Option {
+	return makeKubeProxyOption(func(kp *KubeProxy) error {
+		kp.localLBIPsFn = f
+		return nil
+	})
+}
+
+// WithNodePortSourceRanges restricts the sources that can reach NodePorts to the given CIDRs.
+// Traffic from other sources is dropped by the NAT lookup, before DNAT.  IPv6 CIDRs are
+// ignored.
+func WithNodePortSourceRanges(cidrs []string) Option {
+	return makeKubeProxyOption(func(kp *KubeProxy) error {
+		kp.nodePortSrcRanges = cidrs
+		return nil
+	})
+}
@@ -231,6 +231,11 @@ func (p *proxy) invokeDPSyncer() {
 	p.runnerLck.Lock()
 	defer p.runnerLck.Unlock()
 
+	start := time.Now()
+	defer func() {
+		histogramSyncDuration.Observe(time.Since(start).Seconds())
+	}()
+
 	svcUpdateResult := p.svcMap.Update(p.svcChanges)
 	epsUpdateResult := p.epsMap.Update(p.epsChanges)
 
@@ -248,8 +253,11 @@ func (p *proxy) invokeDPSyncer() {
 
 	if err != nil {
 		log.WithError(err).Errorf("applying changes failed")
+		countSyncFailures.Inc()
 		// TODO log the error or panic as the best might be to restart
 		// completely to wipe out the loaded bpf maps
+	} else {
+		gaugeLastSyncTimestamp.SetToCurrentTime()
 	}
 
 	if p.healthzServer != nil {
@@ -262,6 +270,7 @@ func (p *proxy) OnServiceAdd(svc *v1.Service) {
 }
 
 func (p *proxy) OnServiceUpdate(old, curr *v1.Service) {
+	countServiceChanges.Inc()
 	if p.svcChanges.Update(old, curr) && p.isInitialized() {
 		p.syncDP()
 	}
@@ -281,6 +290,7 @@ func (p *proxy) OnEndpointsAdd(eps *v1.Endpoints) {
 }
 
 func (p *proxy) OnEndpointsUpdate(old, curr *v1.Endpoints) {
+	countEndpointChanges.Inc()
 	if p.epsChanges.Update(old, curr) && p.isInitialized() {
 		p.syncDP()
 	}
@@ -296,18 +306,21 @@ func (p *proxy) OnEndpointsSynced() {
 }
 
 func (p *proxy) OnEndpointSliceAdd(eps *discovery.EndpointSlice) {
+	countEndpointChanges.Inc()
 	if p.epsChanges.EndpointSliceUpdate(eps, false) && p.isInitialized() {
 		p.syncDP()
 	}
 }
 
 func (p *proxy) OnEndpointSliceUpdate(_, eps *discovery.EndpointSlice) {
+	countEndpointChanges.Inc()
 	if p.epsChanges.EndpointSliceUpdate(eps, false) && p.isInitialized() {
 		p.syncDP()
 	}
 }
 
 func (p *proxy) OnEndpointSliceDelete(eps *discovery.EndpointSlice) {
+	countEndpointChanges.Inc()
 	if p.epsChanges.EndpointSliceUpdate(eps, true) && p.isInitialized() {
 		p.syncDP()
 	}
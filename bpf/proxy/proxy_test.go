@@ -22,6 +22,7 @@ import (
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
 
 	v1 "k8s.io/api/core/v1"
 	discovery "k8s.io/api/discovery/v1beta1"
@@ -152,6 +153,54 @@ var _ = Describe("BPF Proxy", func() {
 		},
 	}
 
+	It("should export kube-proxy's sync metrics", func() {
+		metricValue := func(name string) float64 {
+			mfs, err := prometheus.DefaultGatherer.Gather()
+			Expect(err).NotTo(HaveOccurred())
+			for _, mf := range mfs {
+				if mf.GetName() != name {
+					continue
+				}
+				m := mf.GetMetric()[0]
+				switch {
+				case m.Counter != nil:
+					return m.Counter.GetValue()
+				case m.Gauge != nil:
+					return m.Gauge.GetValue()
+				case m.Histogram != nil:
+					return float64(m.Histogram.GetSampleCount())
+				}
+			}
+			Fail("metric not found: " + name)
+			return 0
+		}
+		numSyncs := metricValue("kubeproxy_sync_proxy_rules_duration_seconds")
+		numSvcChanges := metricValue("kubeproxy_sync_proxy_rules_service_changes_total")
+		numEpsChanges := metricValue("kubeproxy_sync_proxy_rules_endpoint_changes_total")
+
+		k8s := fake.NewSimpleClientset(testSvc, testSvcEps)
+		syncStop = make(chan struct{})
+		dp := newMockSyncer(syncStop)
+
+		p, err := proxy.New(k8s, dp, "testnode", proxy.WithImmediateSync())
+		Expect(err).NotTo(HaveOccurred())
+
+		defer func() {
+			close(syncStop)
+			p.Stop()
+		}()
+
+		dp.checkState(func(s proxy.DPSyncerState) {
+			Expect(len(s.SvcMap)).To(Equal(1))
+		})
+
+		Expect(metricValue("kubeproxy_sync_proxy_rules_duration_seconds")).To(BeNumerically(">", numSyncs))
+		Expect(metricValue("kubeproxy_sync_proxy_rules_service_changes_total")).To(Equal(numSvcChanges + 1))
+		Expect(metricValue("kubeproxy_sync_proxy_rules_endpoint_changes_total")).To(Equal(numEpsChanges + 1))
+		Expect(metricValue("kubeproxy_sync_proxy_rules_last_timestamp_seconds")).To(
+			BeNumerically("~", time.Now().Unix(), 60))
+	})
+
 	proxyTransitionsTest := func(endpointSlicesEnabled bool) {
 		var p proxy.Proxy
 		var dp *mockSyncer
@@ -87,6 +87,7 @@ var _ = Describe("BPF service type change", func() {
 	front := newMockNATMap()
 	back := newMockNATBackendMap()
 	aff := newMockAffinityMap()
+	mag := mock.NewMockMap(nat.MaglevMapParameters)
 	ct := mock.NewMockMap(conntrack.MapParams)
 
 	keyClusterIP := nat.NewNATKey(clusterIP, port, proxy.ProtoV1ToIntPanic(proto))
@@ -97,7 +98,7 @@ var _ = Describe("BPF service type change", func() {
 	var p *proxy.KubeProxy
 
 	BeforeEach(func() {
-		p, _ = proxy.StartKubeProxy(k8s, "test-node", front, back, aff, ct, proxy.WithImmediateSync())
+		p, _ = proxy.StartKubeProxy(k8s, "test-node", front, back, aff, mag, ct, proxy.WithImmediateSync())
 		p.OnHostIPsUpdate([]net.IP{initIP})
 	})
 
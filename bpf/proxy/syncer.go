@@ -15,10 +15,12 @@
 package proxy
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"net"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -111,6 +113,11 @@ type Syncer struct {
 	nodePortIPs []net.IP
 	rt          Routes
 
+	// pendingNodePortIPs holds the NodePort IPs that the next Apply() should switch to, if
+	// they have changed.  Protected by nodePortIPsLck since they can change at any time.
+	pendingNodePortIPs []net.IP
+	nodePortIPsLck     sync.Mutex
+
 	// new maps are valid during the Apply()'s runtime to provide easy access
 	// to updating them. They become prev at the end of it to be compared
 	// against in the next iteration
@@ -140,6 +147,27 @@ type Syncer struct {
 	// triggerFn is called when one of the syncer's background threads needs to trigger an Apply().
 	// The proxy sets this to the runner's Run() method.  We assume that the method doesn't block.
 	triggerFn func()
+
+	// ctScanTriggerFn, if set, is called after an Apply() that removed some frontends or
+	// backends so that conntrack entries that NAT to them get cleaned up without waiting
+	// for the next periodic conntrack scan.  It must not block.
+	ctScanTriggerFn func()
+
+	// localLBIPsFn, if set, is called after an Apply() that changed the set of LoadBalancer
+	// status IPs that have local backends, with the new set.  localLBIPs holds the set that we
+	// last reported.
+	localLBIPsFn func(ips []net.IP)
+	localLBIPs   map[string]net.IP
+
+	// bpfMaglev, if set, holds the Maglev lookup tables that the BPF programs use to pick a
+	// backend consistently for each flow.  We write a table for each service if maglevEnabled,
+	// otherwise we keep the map empty so that the programs pick backends at random.
+	bpfMaglev     *cachingmap.CachingMap
+	maglevEnabled bool
+
+	// nodePortSrcRanges, if not empty, are the only source CIDRs that may reach NodePorts.  We
+	// enforce them in the NAT lookup, before DNAT, the same way as LoadBalancer source ranges.
+	nodePortSrcRanges []string
 }
 
 type ipPort struct {
@@ -209,6 +237,20 @@ func NewSyncer(nodePortIPs []net.IP, svcsmap, epsmap *cachingmap.CachingMap, aff
 	return s, nil
 }
 
+// SetMaglevMap sets the map for the Maglev lookup tables and whether services should have a
+// table.  It must be called before the first Apply().
+func (s *Syncer) SetMaglevMap(m *cachingmap.CachingMap, enabled bool) {
+	s.bpfMaglev = m
+	s.maglevEnabled = enabled
+}
+
+// SetNodePortSourceRanges sets the source CIDRs that may reach NodePorts; traffic from other
+// sources is dropped.  An empty list allows all sources.  It must be called before the first
+// Apply().
+func (s *Syncer) SetNodePortSourceRanges(cidrs []string) {
+	s.nodePortSrcRanges = cidrs
+}
+
 func (s *Syncer) loadOrigs() error {
 	err := s.bpfEps.LoadCacheFromDataplane()
 	if err != nil {
@@ -506,10 +548,15 @@ func (s *Syncer) applyDerived(
 		return err
 	}
 	if svcTypeLoadBalancer == t || svcTypeExternalIP == t {
-		err := s.writeLBSrcRangeSvcNATKeys(sinfo, svc.id, count, local)
+		err := s.writeSrcRangeSvcNATKeys(sinfo, sinfo.LoadBalancerSourceRanges(), svc.id, count, local)
 		if err != nil {
 			log.Debug("Failed to write LB source range NAT keys")
 		}
+	} else if svcTypeNodePort == t {
+		err := s.writeSrcRangeSvcNATKeys(sinfo, s.nodePortSrcRanges, svc.id, count, local)
+		if err != nil {
+			log.Debug("Failed to write NodePort source range NAT keys")
+		}
 	}
 
 	s.newSvcMap[skey] = newInfo
@@ -537,6 +584,9 @@ func (s *Syncer) apply(state DPSyncerState) error {
 	// let CachingMap calculate deltas...
 	s.bpfSvcs.DeleteAllDesired()
 	s.bpfEps.DeleteAllDesired()
+	if s.bpfMaglev != nil {
+		s.bpfMaglev.DeleteAllDesired()
+	}
 
 	// insert or update existing services
 	for sname, sinfo := range state.SvcMap {
@@ -606,6 +656,14 @@ func (s *Syncer) apply(state DPSyncerState) error {
 	if err != nil {
 		return err
 	}
+	// The BPF programs only use a Maglev table entry if it points at a valid backend and fall
+	// back to picking at random if there is no table so failing to write the tables only
+	// loses the consistency; we carry on.
+	if s.bpfMaglev != nil {
+		if err := s.bpfMaglev.ApplyUpdatesOnly(); err != nil {
+			log.WithError(err).Error("Failed to write Maglev tables, some services will pick backends at random.")
+		}
+	}
 	// Update the frontends, after this is done we should be handling packets correctly.
 	err = s.bpfSvcs.ApplyUpdatesOnly()
 	if err != nil {
@@ -616,6 +674,11 @@ func (s *Syncer) apply(state DPSyncerState) error {
 	if err != nil {
 		return err
 	}
+	if s.bpfMaglev != nil {
+		if err := s.bpfMaglev.ApplyDeletionsOnly(); err != nil {
+			log.WithError(err).Error("Failed to remove stale Maglev tables.")
+		}
+	}
 
 	log.Info("new state written")
 
@@ -627,6 +690,7 @@ func (s *Syncer) apply(state DPSyncerState) error {
 // Apply applies the new state
 func (s *Syncer) Apply(state DPSyncerState) error {
 	if !s.synced {
+		s.updateNodePortIPs()
 		log.Infof("Loading BPF map state from dataplane")
 		if err := s.startupSync(state); err != nil {
 			return errors.WithMessage(err, "startup sync")
@@ -635,6 +699,7 @@ func (s *Syncer) Apply(state DPSyncerState) error {
 	} else {
 		// if we were not synced yet, the fixer cannot run yet
 		s.stopExpandNPFixup()
+		s.updateNodePortIPs()
 
 		s.prevSvcMap = s.newSvcMap
 		s.prevEpsMap = s.newEpsMap
@@ -664,6 +729,18 @@ func (s *Syncer) Apply(state DPSyncerState) error {
 		s.synced = true
 	}
 
+	if s.ctScanTriggerFn != nil {
+		if n := s.numStaleFrontends(); n > 0 {
+			log.WithField("numFrontends", n).Debug(
+				"Frontends or backends removed, triggering conntrack scan")
+			s.ctScanTriggerFn()
+		}
+	}
+
+	if s.localLBIPsFn != nil {
+		s.reportLocalLBIPs()
+	}
+
 	// We wrote all updates, noone will create new records in affinity table
 	// that we would clean up now, so do it!
 	return s.cleanupSticky()
@@ -707,6 +784,10 @@ func (s *Syncer) updateService(sname k8sp.ServicePortName, sinfo k8sp.ServicePor
 		cnt++
 	}
 
+	if s.bpfMaglev != nil && s.maglevEnabled {
+		s.writeSvcMaglevTable(id, cpEps)
+	}
+
 	if err := s.writeSvc(sinfo, id, cnt, local); err != nil {
 		return 0, 0, err
 	}
@@ -742,6 +823,20 @@ func (s *Syncer) writeSvcBackend(svcID uint32, idx uint32, ep k8sp.Endpoint) err
 	return nil
 }
 
+// writeSvcMaglevTable writes the Maglev lookup table for the backends of the service, which must
+// be in the order of their ordinals.
+func (s *Syncer) writeSvcMaglevTable(svcID uint32, eps []k8sp.Endpoint) {
+	names := make([]string, len(eps))
+	for i, ep := range eps {
+		names[i] = ep.String()
+	}
+	for slot, ordinal := range nat.MaglevTable(names) {
+		key := nat.NewMaglevKey(svcID, uint32(slot))
+		val := nat.NewMaglevValue(ordinal)
+		s.bpfMaglev.SetDesired(key[:], val[:])
+	}
+}
+
 func getSvcNATKey(svc k8sp.ServicePort) (nat.FrontendKey, error) {
 	ip := svc.ClusterIP()
 	port := svc.Port()
@@ -754,21 +849,20 @@ func getSvcNATKey(svc k8sp.ServicePort) (nat.FrontendKey, error) {
 	return key, nil
 }
 
-func getSvcNATKeyLBSrcRange(svc k8sp.ServicePort) ([]nat.FrontendKey, error) {
+func getSvcNATKeySrcRanges(svc k8sp.ServicePort, srcRanges []string) ([]nat.FrontendKey, error) {
 	ipaddr := svc.ClusterIP()
 	port := svc.Port()
-	loadBalancerSourceRanges := svc.LoadBalancerSourceRanges()
 	if log.GetLevel() >= log.DebugLevel {
-		log.Debugf("loadbalancer %v", loadBalancerSourceRanges)
+		log.Debugf("source ranges %v", srcRanges)
 	}
 	proto, err := ProtoV1ToInt(svc.Protocol())
 	if err != nil {
 		return nil, err
 	}
 
-	keys := make([]nat.FrontendKey, 0, len(loadBalancerSourceRanges))
+	keys := make([]nat.FrontendKey, 0, len(srcRanges))
 
-	for _, src := range loadBalancerSourceRanges {
+	for _, src := range srcRanges {
 		// Ignore IPv6 addresses
 		if strings.Contains(src, ":") {
 			continue
@@ -779,17 +873,20 @@ func getSvcNATKeyLBSrcRange(svc k8sp.ServicePort) ([]nat.FrontendKey, error) {
 	return keys, nil
 }
 
-func (s *Syncer) writeLBSrcRangeSvcNATKeys(svc k8sp.ServicePort, svcID uint32, count, local int) error {
+// writeSrcRangeSvcNATKeys writes a NAT frontend for each of the source ranges and replaces the
+// frontend that matches any source with a blackhole so that traffic from other sources is
+// dropped in the NAT lookup.  It does nothing if there are no source ranges.
+func (s *Syncer) writeSrcRangeSvcNATKeys(svc k8sp.ServicePort, srcRanges []string, svcID uint32, count, local int) error {
 	var key nat.FrontendKey
 	affinityTimeo := uint32(0)
 	if svc.SessionAffinityType() == v1.ServiceAffinityClientIP {
 		affinityTimeo = uint32(svc.StickyMaxAgeSeconds())
 	}
 
-	if len(svc.LoadBalancerSourceRanges()) == 0 {
+	if len(srcRanges) == 0 {
 		return nil
 	}
-	keys, err := getSvcNATKeyLBSrcRange(svc)
+	keys, err := getSvcNATKeySrcRanges(svc, srcRanges)
 	if err != nil {
 		return err
 	}
@@ -873,8 +970,32 @@ func (s *Syncer) newSvcID() uint32 {
 }
 
 func (s *Syncer) matchBpfSvc(bpfSvc nat.FrontendKey, k8sSvc k8sp.ServicePortName, k8sInfo k8sp.ServicePort) *svcKey {
+	matchSrcRange := func(srcRanges []string) bool {
+		// An entry with zero Src CIDR is a valid entry and should not be considered
+		// as stale
+		if bpfSvc.SrcCIDR() == nat.ZeroCIDR {
+			return true
+		}
+		// If there isn't any source address range, treat all the entries with src cidr
+		// as stale.
+		if len(srcRanges) == 0 {
+			return false
+		}
+		// If there are source ranges, look for a match
+		for _, srcip := range srcRanges {
+			if strings.Contains(srcip, ":") {
+				continue
+			}
+			cidr := ip.MustParseCIDROrIP(srcip).(ip.V4CIDR)
+			if cidr == bpfSvc.SrcCIDR() {
+				return true
+			}
+		}
+		return false
+	}
+
 	matchNP := func() *svcKey {
-		if bpfSvc.Port() == uint16(k8sInfo.NodePort()) {
+		if bpfSvc.Port() == uint16(k8sInfo.NodePort()) && matchSrcRange(s.nodePortSrcRanges) {
 			for _, nip := range s.nodePortIPs {
 				if bpfSvc.Addr().Equal(nip) {
 					skey := &svcKey{
@@ -899,27 +1020,7 @@ func (s *Syncer) matchBpfSvc(bpfSvc nat.FrontendKey, k8sSvc k8sp.ServicePortName
 		return nil
 	}
 	matchLBSrcIP := func() bool {
-		// External IP with zero Src CIDR is a valid entry and should not be considered
-		// as stale
-		if bpfSvc.SrcCIDR() == nat.ZeroCIDR {
-			return true
-		}
-		// If the service does not have any source address range, treat all the entries with
-		// src cidr as stale.
-		if len(k8sInfo.LoadBalancerSourceRanges()) == 0 {
-			return false
-		}
-		// If the service does have source range specified, look for a match
-		for _, srcip := range k8sInfo.LoadBalancerSourceRanges() {
-			if strings.Contains(srcip, ":") {
-				continue
-			}
-			cidr := ip.MustParseCIDROrIP(srcip).(ip.V4CIDR)
-			if cidr == bpfSvc.SrcCIDR() {
-				return true
-			}
-		}
-		return false
+		return matchSrcRange(k8sInfo.LoadBalancerSourceRanges())
 	}
 
 	if bpfSvc.Addr().String() == k8sInfo.ClusterIP().String() {
@@ -1039,10 +1140,123 @@ func (s *Syncer) runExpandNPFixup(misses []*expandMiss) {
 	}()
 }
 
+// SetNodePortIPs changes the IPs that NodePorts are reachable on, for example after the host
+// gains or loses an address.  Unlike the other setters, it may be called at any time; the
+// frontends are updated by the next Apply(), which it triggers.
+func (s *Syncer) SetNodePortIPs(ips []net.IP) {
+	s.nodePortIPsLck.Lock()
+	s.pendingNodePortIPs = uniqueIPs(ips)
+	s.nodePortIPsLck.Unlock()
+
+	if s.triggerFn != nil {
+		s.triggerFn()
+	}
+}
+
+// updateNodePortIPs switches to the pending NodePort IPs, if there are any.  It must be called
+// while the expanded NodePort fixup isn't running since that reads the NodePort IPs.
+func (s *Syncer) updateNodePortIPs() {
+	s.nodePortIPsLck.Lock()
+	defer s.nodePortIPsLck.Unlock()
+
+	if s.pendingNodePortIPs == nil {
+		return
+	}
+	log.WithField("ips", s.pendingNodePortIPs).Info("NodePort IPs changed")
+	s.nodePortIPs = s.pendingNodePortIPs
+	s.pendingNodePortIPs = nil
+}
+
 func (s *Syncer) SetTriggerFn(f func()) {
 	s.triggerFn = f
 }
 
+// SetConntrackScanTriggerFn sets the function that is called when an Apply() removes
+// frontends or backends that may still have conntrack entries NATting to them.
+func (s *Syncer) SetConntrackScanTriggerFn(f func()) {
+	s.ctScanTriggerFn = f
+}
+
+// SetLocalLBIPsFn sets the function that is called when the set of LoadBalancer status IPs that
+// have local backends changes.  A BGP layer can use it to advertise only the IPs that this node
+// can serve locally.  The function is called from Apply(), with the complete new set, and must
+// not block.
+func (s *Syncer) SetLocalLBIPsFn(f func(ips []net.IP)) {
+	s.localLBIPsFn = f
+}
+
+// reportLocalLBIPs calculates the LoadBalancer status IPs that have local backends as of the last
+// apply and reports them if they changed since we last reported.  The first call always reports.
+func (s *Syncer) reportLocalLBIPs() {
+	newIPs := map[string]net.IP{}
+	for skey, info := range s.newSvcMap {
+		if !hasSvcKeyExtra(skey, svcTypeLoadBalancer) || info.localCount == 0 {
+			continue
+		}
+		lbIP := info.svc.ClusterIP()
+		newIPs[lbIP.String()] = lbIP
+	}
+
+	if s.localLBIPs != nil && len(newIPs) == len(s.localLBIPs) {
+		changed := false
+		for k := range newIPs {
+			if _, ok := s.localLBIPs[k]; !ok {
+				changed = true
+				break
+			}
+		}
+		if !changed {
+			return
+		}
+	}
+	s.localLBIPs = newIPs
+
+	ips := make([]net.IP, 0, len(newIPs))
+	for _, ip := range newIPs {
+		ips = append(ips, ip)
+	}
+	sort.Slice(ips, func(i, j int) bool {
+		return bytes.Compare(ips[i], ips[j]) < 0
+	})
+	log.WithField("ips", ips).Debug("LoadBalancer IPs with local backends changed")
+	s.localLBIPsFn(ips)
+}
+
+// numStaleFrontends compares the state before and after the last apply and returns
+// the number of frontends that either disappeared, were given a new ID, or lost some
+// of their backends.  Conntrack entries for those may now be stale.
+func (s *Syncer) numStaleFrontends() int {
+	n := 0
+	for skey, prev := range s.prevSvcMap {
+		if prev.count == 0 {
+			// There cannot be any connections through a frontend without backends.
+			continue
+		}
+		cur, ok := s.newSvcMap[skey]
+		if !ok || cur.id != prev.id ||
+			endpointsRemoved(s.prevEpsMap[skey.sname], s.newEpsMap[skey.sname]) {
+			n++
+		}
+	}
+	return n
+}
+
+func endpointsRemoved(prev, cur []k8sp.Endpoint) bool {
+	if len(prev) == 0 {
+		return false
+	}
+	curSet := make(map[string]struct{}, len(cur))
+	for _, ep := range cur {
+		curSet[ep.String()] = struct{}{}
+	}
+	for _, ep := range prev {
+		if _, ok := curSet[ep.String()]; !ok {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *Syncer) stopExpandNPFixup() {
 	// If there was an error before we started ExpandNPFixup, there is nothing to stop
 	if s.expFixupStop != nil {
@@ -935,6 +935,256 @@ var _ = Describe("BPF Syncer", func() {
 	})
 })
 
+var _ = Describe("BPF Syncer conntrack scan trigger", func() {
+	var (
+		s        *proxy.Syncer
+		state    proxy.DPSyncerState
+		triggers int
+	)
+
+	svcKey := k8sp.ServicePortName{
+		NamespacedName: types.NamespacedName{
+			Namespace: "default",
+			Name:      "test-service",
+		},
+	}
+
+	BeforeEach(func() {
+		feCache := cachingmap.New(nat.FrontendMapParameters, newMockNATMap())
+		beCache := cachingmap.New(nat.BackendMapParameters, newMockNATBackendMap())
+
+		var err error
+		s, err = proxy.NewSyncer([]net.IP{net.IPv4(192, 168, 0, 1)}, feCache, beCache,
+			newMockAffinityMap(), proxy.NewRTCache())
+		Expect(err).NotTo(HaveOccurred())
+
+		triggers = 0
+		s.SetConntrackScanTriggerFn(func() { triggers++ })
+
+		state = proxy.DPSyncerState{
+			SvcMap: k8sp.ServiceMap{
+				svcKey: proxy.NewK8sServicePort(net.IPv4(10, 0, 0, 1), 1234, v1.ProtocolTCP),
+			},
+			EpsMap: k8sp.EndpointsMap{
+				svcKey: []k8sp.Endpoint{
+					&k8sp.BaseEndpointInfo{Endpoint: "10.1.0.1:5555"},
+					&k8sp.BaseEndpointInfo{Endpoint: "10.1.0.2:5555"},
+				},
+			},
+		}
+		Expect(s.Apply(state)).To(Succeed())
+	})
+
+	It("should not trigger a scan when backends are only added", func() {
+		Expect(triggers).To(Equal(0))
+
+		state.EpsMap[svcKey] = append(state.EpsMap[svcKey], &k8sp.BaseEndpointInfo{Endpoint: "10.1.0.3:5555"})
+		Expect(s.Apply(state)).To(Succeed())
+		Expect(triggers).To(Equal(0))
+
+		By("reapplying the same state")
+		Expect(s.Apply(state)).To(Succeed())
+		Expect(triggers).To(Equal(0))
+	})
+
+	It("should trigger a scan when a backend is removed", func() {
+		state.EpsMap[svcKey] = state.EpsMap[svcKey][1:]
+		Expect(s.Apply(state)).To(Succeed())
+		Expect(triggers).To(Equal(1))
+	})
+
+	It("should trigger a scan when a service is removed", func() {
+		delete(state.SvcMap, svcKey)
+		delete(state.EpsMap, svcKey)
+		Expect(s.Apply(state)).To(Succeed())
+		Expect(triggers).To(Equal(1))
+	})
+
+	It("should trigger a scan when a service changes its port", func() {
+		state.SvcMap[svcKey] = proxy.NewK8sServicePort(net.IPv4(10, 0, 0, 1), 4321, v1.ProtocolTCP)
+		Expect(s.Apply(state)).To(Succeed())
+		Expect(triggers).To(Equal(1))
+	})
+})
+
+var _ = Describe("BPF Syncer Maglev tables", func() {
+	var (
+		s     *proxy.Syncer
+		state proxy.DPSyncerState
+		eps   *mockNATBackendMap
+		mag   *mock.Map
+	)
+
+	svcKey := k8sp.ServicePortName{
+		NamespacedName: types.NamespacedName{
+			Namespace: "default",
+			Name:      "test-service",
+		},
+	}
+	natKey := nat.NewNATKey(net.IPv4(10, 0, 0, 1), 1234, proxy.ProtoV1ToIntPanic(v1.ProtocolTCP))
+
+	newSyncer := func(svcs *mockNATMap, enabled bool) {
+		var err error
+		s, err = proxy.NewSyncer([]net.IP{net.IPv4(192, 168, 0, 1)},
+			cachingmap.New(nat.FrontendMapParameters, svcs),
+			cachingmap.New(nat.BackendMapParameters, eps),
+			newMockAffinityMap(), proxy.NewRTCache())
+		Expect(err).NotTo(HaveOccurred())
+		s.SetMaglevMap(cachingmap.New(nat.MaglevMapParameters, mag), enabled)
+	}
+
+	// backendForSlot returns the backend that the BPF programs would pick for the slot.
+	backendForSlot := func(id uint32, slot int) nat.BackendValue {
+		k := nat.NewMaglevKey(id, uint32(slot))
+		v, ok := mag.Contents[string(k[:])]
+		ExpectWithOffset(1, ok).To(BeTrue(), "missing slot %d", slot)
+		var val nat.MaglevValue
+		copy(val[:], v)
+		be, ok := eps.m[nat.NewNATBackendKey(id, val.Ordinal())]
+		ExpectWithOffset(1, ok).To(BeTrue(), "slot %d points at a missing backend", slot)
+		return be
+	}
+
+	BeforeEach(func() {
+		eps = newMockNATBackendMap()
+		mag = mock.NewMockMap(nat.MaglevMapParameters)
+		state = proxy.DPSyncerState{
+			SvcMap: k8sp.ServiceMap{
+				svcKey: proxy.NewK8sServicePort(net.IPv4(10, 0, 0, 1), 1234, v1.ProtocolTCP),
+			},
+			EpsMap: k8sp.EndpointsMap{
+				svcKey: []k8sp.Endpoint{
+					&k8sp.BaseEndpointInfo{Endpoint: "10.1.0.1:5555"},
+					&k8sp.BaseEndpointInfo{Endpoint: "10.1.0.2:5555"},
+					&k8sp.BaseEndpointInfo{Endpoint: "10.1.0.3:5555"},
+				},
+			},
+		}
+	})
+
+	It("should write a table per service and keep most flows on surviving backends", func() {
+		svcs := newMockNATMap()
+		newSyncer(svcs, true)
+		Expect(s.Apply(state)).To(Succeed())
+		id := svcs.m[natKey].ID()
+		Expect(mag.Contents).To(HaveLen(nat.MaglevTableSize))
+
+		before := make([]nat.BackendValue, nat.MaglevTableSize)
+		for slot := range before {
+			before[slot] = backendForSlot(id, slot)
+		}
+
+		By("removing the first backend, which changes the ordinals of the others")
+		removed := nat.NewNATBackendValue(net.IPv4(10, 1, 0, 1), 5555)
+		state.EpsMap[svcKey] = state.EpsMap[svcKey][1:]
+		Expect(s.Apply(state)).To(Succeed())
+		Expect(svcs.m[natKey].ID()).To(Equal(id))
+		Expect(mag.Contents).To(HaveLen(nat.MaglevTableSize))
+		moved := 0
+		for slot, be := range before {
+			if be != removed && backendForSlot(id, slot) != be {
+				moved++
+			}
+		}
+		Expect(moved).To(BeNumerically("<", nat.MaglevTableSize/10))
+
+		By("removing the service")
+		delete(state.SvcMap, svcKey)
+		delete(state.EpsMap, svcKey)
+		Expect(s.Apply(state)).To(Succeed())
+		Expect(mag.Contents).To(BeEmpty())
+	})
+
+	It("should clean up tables when disabled", func() {
+		newSyncer(newMockNATMap(), true)
+		Expect(s.Apply(state)).To(Succeed())
+		Expect(mag.Contents).To(HaveLen(nat.MaglevTableSize))
+
+		By("restarting with Maglev disabled")
+		newSyncer(newMockNATMap(), false)
+		Expect(s.Apply(state)).To(Succeed())
+		Expect(mag.Contents).To(BeEmpty())
+	})
+})
+
+var _ = Describe("BPF Syncer LoadBalancer IPs with local backends", func() {
+	var (
+		s        *proxy.Syncer
+		state    proxy.DPSyncerState
+		reported [][]net.IP
+	)
+
+	svcKey1 := k8sp.ServicePortName{
+		NamespacedName: types.NamespacedName{Namespace: "default", Name: "lb-1"},
+	}
+	svcKey2 := k8sp.ServicePortName{
+		NamespacedName: types.NamespacedName{Namespace: "default", Name: "lb-2"},
+	}
+
+	BeforeEach(func() {
+		feCache := cachingmap.New(nat.FrontendMapParameters, newMockNATMap())
+		beCache := cachingmap.New(nat.BackendMapParameters, newMockNATBackendMap())
+
+		var err error
+		s, err = proxy.NewSyncer([]net.IP{net.IPv4(192, 168, 0, 1)}, feCache, beCache,
+			newMockAffinityMap(), proxy.NewRTCache())
+		Expect(err).NotTo(HaveOccurred())
+
+		reported = nil
+		s.SetLocalLBIPsFn(func(ips []net.IP) { reported = append(reported, ips) })
+
+		state = proxy.DPSyncerState{
+			SvcMap: k8sp.ServiceMap{
+				svcKey1: proxy.NewK8sServicePort(net.IPv4(10, 0, 0, 1), 1234, v1.ProtocolTCP,
+					proxy.K8sSvcWithLoadBalancerIPs([]string{"35.0.0.1"})),
+				svcKey2: proxy.NewK8sServicePort(net.IPv4(10, 0, 0, 2), 1234, v1.ProtocolTCP,
+					proxy.K8sSvcWithLoadBalancerIPs([]string{"35.0.0.2"})),
+			},
+			EpsMap: k8sp.EndpointsMap{
+				svcKey1: []k8sp.Endpoint{
+					&k8sp.BaseEndpointInfo{Endpoint: "10.1.0.1:5555", IsLocal: true},
+					&k8sp.BaseEndpointInfo{Endpoint: "10.1.0.2:5555"},
+				},
+				svcKey2: []k8sp.Endpoint{
+					&k8sp.BaseEndpointInfo{Endpoint: "10.1.0.3:5555"},
+				},
+			},
+		}
+		Expect(s.Apply(state)).To(Succeed())
+	})
+
+	It("should report only the IPs with local backends", func() {
+		Expect(reported).To(Equal([][]net.IP{{net.ParseIP("35.0.0.1")}}))
+	})
+
+	It("should not report again if the set doesn't change", func() {
+		state.EpsMap[svcKey1] = state.EpsMap[svcKey1][:1]
+		Expect(s.Apply(state)).To(Succeed())
+		Expect(reported).To(HaveLen(1))
+	})
+
+	It("should report when a service gains or loses its local backends", func() {
+		state.EpsMap[svcKey2] = append(state.EpsMap[svcKey2],
+			&k8sp.BaseEndpointInfo{Endpoint: "10.1.0.4:5555", IsLocal: true})
+		Expect(s.Apply(state)).To(Succeed())
+		Expect(reported).To(HaveLen(2))
+		Expect(reported[1]).To(Equal([]net.IP{net.ParseIP("35.0.0.1"), net.ParseIP("35.0.0.2")}))
+
+		state.EpsMap[svcKey1] = state.EpsMap[svcKey1][1:]
+		Expect(s.Apply(state)).To(Succeed())
+		Expect(reported).To(HaveLen(3))
+		Expect(reported[2]).To(Equal([]net.IP{net.ParseIP("35.0.0.2")}))
+	})
+
+	It("should report an empty set when the service is removed", func() {
+		delete(state.SvcMap, svcKey1)
+		delete(state.EpsMap, svcKey1)
+		Expect(s.Apply(state)).To(Succeed())
+		Expect(reported).To(HaveLen(2))
+		Expect(reported[1]).To(BeEmpty())
+	})
+})
+
 type mockNATMap struct {
 	mock.DummyMap
 	sync.Mutex
@@ -1230,3 +1480,55 @@ func ctEntriesForSvc(ct bpf.Map, proto v1.Protocol,
 	err = ct.Update(revKey.AsBytes(), val.AsBytes())
 	Expect(err).NotTo(HaveOccurred(), "Test failed to populate ct map with REV")
 }
+
+var _ = Describe("BPF Syncer NodePort IP changes", func() {
+	var (
+		s         *proxy.Syncer
+		svcs      *mockNATMap
+		state     proxy.DPSyncerState
+		triggered int
+	)
+
+	svcKey := k8sp.ServicePortName{
+		NamespacedName: types.NamespacedName{Namespace: "default", Name: "np"},
+	}
+	tcp := proxy.ProtoV1ToIntPanic(v1.ProtocolTCP)
+
+	BeforeEach(func() {
+		svcs = newMockNATMap()
+		feCache := cachingmap.New(nat.FrontendMapParameters, svcs)
+		beCache := cachingmap.New(nat.BackendMapParameters, newMockNATBackendMap())
+
+		var err error
+		s, err = proxy.NewSyncer([]net.IP{net.IPv4(192, 168, 0, 1)}, feCache, beCache,
+			newMockAffinityMap(), proxy.NewRTCache())
+		Expect(err).NotTo(HaveOccurred())
+
+		triggered = 0
+		s.SetTriggerFn(func() { triggered++ })
+
+		state = proxy.DPSyncerState{
+			SvcMap: k8sp.ServiceMap{
+				svcKey: proxy.NewK8sServicePort(net.IPv4(10, 0, 0, 1), 1234, v1.ProtocolTCP,
+					proxy.K8sSvcWithNodePort(30333)),
+			},
+			EpsMap: k8sp.EndpointsMap{
+				svcKey: []k8sp.Endpoint{&k8sp.BaseEndpointInfo{Endpoint: "10.1.0.1:5555"}},
+			},
+		}
+		Expect(s.Apply(state)).To(Succeed())
+		Expect(svcs.m).To(HaveKey(nat.NewNATKey(net.IPv4(192, 168, 0, 1), 30333, tcp)))
+	})
+
+	It("should move the NodePort frontends to the new IPs on the next Apply", func() {
+		s.SetNodePortIPs([]net.IP{net.IPv4(192, 168, 0, 2), net.IPv4(192, 168, 0, 3)})
+		Expect(triggered).To(Equal(1))
+		Expect(svcs.m).To(HaveKey(nat.NewNATKey(net.IPv4(192, 168, 0, 1), 30333, tcp)))
+
+		Expect(s.Apply(state)).To(Succeed())
+		Expect(svcs.m).NotTo(HaveKey(nat.NewNATKey(net.IPv4(192, 168, 0, 1), 30333, tcp)))
+		Expect(svcs.m).To(HaveKey(nat.NewNATKey(net.IPv4(192, 168, 0, 2), 30333, tcp)))
+		Expect(svcs.m).To(HaveKey(nat.NewNATKey(net.IPv4(192, 168, 0, 3), 30333, tcp)))
+		Expect(svcs.m).To(HaveKey(nat.NewNATKey(net.IPv4(10, 0, 0, 1), 1234, tcp)))
+	})
+})
@@ -18,6 +18,7 @@ package tc
 
 import (
 	"bytes"
+	"debug/elf"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -53,6 +54,9 @@ type AttachPoint struct {
 	TunnelMTU            uint16
 	VXLANPort            uint16
 	ExtToServiceConnmark uint32
+	// PinOwner, if non-nil, is used to label the program's pinned maps so that they can be
+	// garbage collected by CollectPinGarbage().
+	PinOwner *PinOwner
 }
 
 var tcLock sync.RWMutex
@@ -102,6 +106,12 @@ func (ap AttachPoint) AttachProgram() error {
 	if err != nil {
 		return err
 	}
+	if ap.PinOwner != nil {
+		err = ap.PinOwner.labelPinDir(tcPinDir, tempBinary, ap.Hook, ap.Iface)
+		if err != nil {
+			logCxt.WithError(err).Warn("Failed to label BPF pins; they won't be garbage collected.")
+		}
+	}
 
 	// Success: clean up the old programs.
 	var progErrs []error
@@ -253,6 +263,24 @@ func (ap AttachPoint) FileName() string {
 	return ProgFilename(ap.Type, ap.ToOrFrom, ap.ToHostDrop, ap.FIB, ap.DSR, ap.LogLevel)
 }
 
+// ProgramSize returns the size, in bytes, of the BPF programs that AttachProgram() loads.  It's
+// the total size of the program sections in the pre-compiled object file, since tc loads the
+// tail-called programs along with the entry point.
+func (ap AttachPoint) ProgramSize() (int64, error) {
+	f, err := elf.Open(path.Join(bpf.ObjectDir, ap.FileName()))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	var size int64
+	for _, sec := range f.Sections {
+		if sec.Type == elf.SHT_PROGBITS && sec.Flags&elf.SHF_EXECINSTR != 0 {
+			size += int64(sec.Size)
+		}
+	}
+	return size, nil
+}
+
 func (ap AttachPoint) IsAttached() (bool, error) {
 	hasQ, err := HasQdisc(ap.Iface)
 	if err != nil {
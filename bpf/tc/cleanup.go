@@ -28,8 +28,9 @@ import (
 	"github.com/projectcalico/felix/bpf"
 )
 
-// CleanUpProgramsAndPins makes a best effort to remove all our TC BPF programs.
-func CleanUpProgramsAndPins() {
+// CleanUpProgramsAndPins makes a best effort to remove all our TC BPF programs.  If owner is
+// non-nil, pins that belong to other owners are left alone.
+func CleanUpProgramsAndPins(owner *PinOwner) {
 	log.Debug("Trying to clean up any left-over BPF state from a previous run.")
 	bpftool := exec.Command("bpftool", "map", "list", "--json")
 	mapsJSON, err := bpftool.Output()
@@ -120,7 +121,11 @@ func CleanUpProgramsAndPins() {
 		return nil
 	})
 
-	bpf.CleanUpCalicoPins("/sys/fs/bpf/tc")
+	if owner != nil {
+		cleanUpPins(tcPinDir, owner)
+		return
+	}
+	bpf.CleanUpCalicoPins(tcPinDir)
 }
 
 var tcFiltRegex = regexp.MustCompile(`filter .*? bpf .*? id (\d+)`)
@@ -0,0 +1,259 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tc
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/libcalico-go/lib/set"
+
+	"github.com/projectcalico/felix/bpf"
+)
+
+// tcPinDir is where tc pins the maps of the programs that it loads.  Maps with object scope are
+// pinned in a subdirectory named after the hash of the object file; since we patch each object
+// file for its interface, each attached program gets its own subdirectory.
+const tcPinDir = "/sys/fs/bpf/tc"
+
+// pinOwnerMarkerPrefix is the prefix of the empty directories that label a pin directory with its
+// owner.  The BPF filesystem can't hold regular files, so we encode the label in the name:
+// "owner:<instance ID>:<config hash>:<hook>:<interface>".
+const pinOwnerMarkerPrefix = "owner:"
+
+// PinOwner identifies the Felix instance that pinned a set of BPF maps, and the configuration that
+// it was running with.  Felix instances with different owners (such as those of different
+// tenants) leave each other's pins alone.
+type PinOwner struct {
+	InstanceID string
+	ConfigHash string
+}
+
+// NewPinOwner returns a PinOwner for the given instance.  Colons are replaced since they separate
+// the fields of the label.
+func NewPinOwner(instanceID, configHash string) *PinOwner {
+	return &PinOwner{
+		InstanceID: strings.ReplaceAll(instanceID, ":", "_"),
+		ConfigHash: strings.ReplaceAll(configHash, ":", "_"),
+	}
+}
+
+func (o *PinOwner) markerName(hook Hook, iface string) string {
+	return pinOwnerMarkerPrefix + strings.Join([]string{o.InstanceID, o.ConfigHash, string(hook), iface}, ":")
+}
+
+type pinLabel struct {
+	owner PinOwner
+	hook  Hook
+	iface string
+	// labelledAt is when the directory was last labelled, that is, when the program that uses
+	// it was attached.
+	labelledAt time.Time
+}
+
+func parsePinOwnerMarker(info os.FileInfo) (pinLabel, bool) {
+	if !info.IsDir() || !strings.HasPrefix(info.Name(), pinOwnerMarkerPrefix) {
+		return pinLabel{}, false
+	}
+	// The interface name goes last in case it contains a colon.
+	parts := strings.SplitN(strings.TrimPrefix(info.Name(), pinOwnerMarkerPrefix), ":", 4)
+	if len(parts) != 4 {
+		return pinLabel{}, false
+	}
+	return pinLabel{
+		owner:      PinOwner{InstanceID: parts[0], ConfigHash: parts[1]},
+		hook:       Hook(parts[2]),
+		iface:      parts[3],
+		labelledAt: info.ModTime(),
+	}, true
+}
+
+// pinDirLabels returns the labels of the given pin directory.
+func pinDirLabels(path string) ([]pinLabel, error) {
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var labels []pinLabel
+	for _, e := range entries {
+		if label, ok := parsePinOwnerMarker(e); ok {
+			labels = append(labels, label)
+		}
+	}
+	return labels, nil
+}
+
+// ownedByOther returns true if any of the labels belong to a different owner.
+func (o *PinOwner) ownedByOther(labels []pinLabel) bool {
+	for _, l := range labels {
+		if l.owner.InstanceID != o.InstanceID {
+			return true
+		}
+	}
+	return false
+}
+
+// objectPinDir returns the directory where tc pins the object-scoped maps of the given object
+// file.
+func objectPinDir(root, objFile string) (string, error) {
+	obj, err := ioutil.ReadFile(objFile)
+	if err != nil {
+		return "", err
+	}
+	hash := sha1.Sum(obj)
+	return filepath.Join(root, hex.EncodeToString(hash[:])), nil
+}
+
+// labelPinDir labels the pin directory of the program that was just loaded from objFile.  If the
+// directory was already labelled (because tc reused the pins of an identical program), the label
+// is refreshed so that it counts as the most recent.
+func (o *PinOwner) labelPinDir(root, objFile string, hook Hook, iface string) error {
+	dir, err := objectPinDir(root, objFile)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		// The program doesn't have any object-scoped maps, so there's nothing to label.
+		return nil
+	}
+	marker := filepath.Join(dir, o.markerName(hook, iface))
+	err = os.Mkdir(marker, 0700)
+	if os.IsExist(err) {
+		now := time.Now()
+		return os.Chtimes(marker, now, now)
+	}
+	return err
+}
+
+// CollectPinGarbage removes the pin directories that the given owner labelled for programs that
+// are no longer in use: those for interfaces that aren't in liveIfaces and those that have been
+// superseded by a later program on the same interface and hook.  Directories that are unlabelled
+// or that belong to another owner are left alone.  Once their pins are gone, the kernel frees the
+// maps and programs as soon as nothing else references them.
+func CollectPinGarbage(owner *PinOwner, liveIfaces set.Set) {
+	// So that we serialise with AttachProgram()
+	tcLock.Lock()
+	defer tcLock.Unlock()
+	collectPinGarbage(tcPinDir, owner, liveIfaces)
+}
+
+func collectPinGarbage(root string, owner *PinOwner, liveIfaces set.Set) {
+	type hookAndIface struct {
+		hook  Hook
+		iface string
+	}
+	type labelledDir struct {
+		path  string
+		label pinLabel
+	}
+
+	dirs, err := ioutil.ReadDir(root)
+	if os.IsNotExist(err) {
+		log.WithError(err).Warn("tc directory missing from BPF file system?")
+		return
+	} else if err != nil {
+		log.WithError(err).Error("Failed to list pin directories.")
+		return
+	}
+
+	var garbage []labelledDir
+	newest := map[hookAndIface]labelledDir{}
+	for _, d := range dirs {
+		if !d.IsDir() || !tcDirRegex.MatchString(d.Name()) {
+			continue
+		}
+		path := filepath.Join(root, d.Name())
+		labels, err := pinDirLabels(path)
+		if err != nil {
+			log.WithError(err).WithField("path", path).Warn("Failed to list pin directory.")
+			continue
+		}
+		if owner.ownedByOther(labels) {
+			log.WithField("path", path).Debug("Pin directory belongs to another instance, skipping.")
+			continue
+		}
+		var ours *labelledDir
+		for _, label := range labels {
+			if ours == nil || label.labelledAt.After(ours.label.labelledAt) {
+				ours = &labelledDir{path: path, label: label}
+			}
+		}
+		if ours == nil {
+			log.WithField("path", path).Debug("Pin directory isn't labelled, skipping.")
+			continue
+		}
+		if !liveIfaces.Contains(ours.label.iface) {
+			garbage = append(garbage, *ours)
+			continue
+		}
+		key := hookAndIface{ours.label.hook, ours.label.iface}
+		if prev, ok := newest[key]; !ok {
+			newest[key] = *ours
+		} else if ours.label.labelledAt.After(prev.label.labelledAt) {
+			garbage = append(garbage, prev)
+			newest[key] = *ours
+		} else {
+			garbage = append(garbage, *ours)
+		}
+	}
+
+	for _, d := range garbage {
+		logCxt := log.WithFields(log.Fields{
+			"path":       d.path,
+			"iface":      d.label.iface,
+			"hook":       d.label.hook,
+			"configHash": d.label.owner.ConfigHash,
+		})
+		if err := os.RemoveAll(d.path); err != nil {
+			logCxt.WithError(err).Warn("Failed to remove stale BPF pins.")
+			continue
+		}
+		logCxt.Info("Removed stale BPF pins.")
+	}
+}
+
+// cleanUpPins removes our pins from the given directory, as for bpf.CleanUpCalicoPins(), but
+// leaves alone the pin directories that belong to other owners.
+func cleanUpPins(root string, owner *PinOwner) {
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.WithError(err).Warn("Failed to list pinned BPF progs/maps. Ignoring.")
+		}
+		return
+	}
+	for _, e := range entries {
+		path := filepath.Join(root, e.Name())
+		if e.IsDir() {
+			labels, err := pinDirLabels(path)
+			if err != nil {
+				log.WithError(err).WithField("path", path).Warn("Failed to list pin directory.")
+				continue
+			}
+			if owner.ownedByOther(labels) {
+				log.WithField("path", path).Debug("Pin directory belongs to another instance, skipping.")
+				continue
+			}
+		}
+		bpf.CleanUpCalicoPins(path)
+	}
+}
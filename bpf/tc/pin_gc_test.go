@@ -0,0 +1,143 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tc
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/libcalico-go/lib/set"
+)
+
+// makePinDir creates a fake tc pin directory, with a pinned jump map, for the given object
+// contents and returns its path.
+func makePinDir(root, obj string) string {
+	hash := sha1.Sum([]byte(obj))
+	dir := filepath.Join(root, hex.EncodeToString(hash[:]))
+	Expect(os.Mkdir(dir, 0700)).To(Succeed())
+	Expect(ioutil.WriteFile(filepath.Join(dir, "cali_jump"), nil, 0600)).To(Succeed())
+	return dir
+}
+
+// labelAt labels the pin directory for the given object as if its program was attached at the
+// given time.
+func labelAt(root, obj string, owner *PinOwner, hook Hook, iface string, t time.Time) {
+	objFile := filepath.Join(root, "..", "obj-"+iface+string(hook))
+	Expect(ioutil.WriteFile(objFile, []byte(obj), 0600)).To(Succeed())
+	Expect(owner.labelPinDir(root, objFile, hook, iface)).To(Succeed())
+	dir, err := objectPinDir(root, objFile)
+	Expect(err).NotTo(HaveOccurred())
+	marker := filepath.Join(dir, owner.markerName(hook, iface))
+	Expect(os.Chtimes(marker, t, t)).To(Succeed())
+}
+
+func setupPinRoot(t *testing.T) (root string, cleanup func()) {
+	RegisterTestingT(t)
+	base, err := ioutil.TempDir("", "calico-pin-gc")
+	Expect(err).NotTo(HaveOccurred())
+	root = filepath.Join(base, "tc")
+	Expect(os.Mkdir(root, 0700)).To(Succeed())
+	return root, func() { _ = os.RemoveAll(base) }
+}
+
+func TestPinOwnerMarkerRoundTrip(t *testing.T) {
+	root, cleanup := setupPinRoot(t)
+	defer cleanup()
+
+	owner := NewPinOwner("node:1", "abcd")
+	Expect(owner.InstanceID).To(Equal("node_1"))
+	dir := makePinDir(root, "prog")
+	labelAt(root, "prog", owner, HookIngress, "eth0:1", time.Now())
+
+	labels, err := pinDirLabels(dir)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(labels).To(HaveLen(1))
+	Expect(labels[0].owner).To(Equal(*owner))
+	Expect(labels[0].hook).To(Equal(HookIngress))
+	Expect(labels[0].iface).To(Equal("eth0:1"))
+}
+
+func TestLabelPinDirWithoutPins(t *testing.T) {
+	root, cleanup := setupPinRoot(t)
+	defer cleanup()
+
+	objFile := filepath.Join(root, "..", "obj")
+	Expect(ioutil.WriteFile(objFile, []byte("no maps"), 0600)).To(Succeed())
+	Expect(NewPinOwner("node", "abcd").labelPinDir(root, objFile, HookIngress, "eth0")).To(Succeed())
+	entries, err := ioutil.ReadDir(root)
+	Expect(err).NotTo(HaveOccurred())
+	Expect(entries).To(BeEmpty())
+}
+
+func TestCollectPinGarbage(t *testing.T) {
+	root, cleanup := setupPinRoot(t)
+	defer cleanup()
+
+	us := NewPinOwner("node", "abcd")
+	other := NewPinOwner("other-tenant", "abcd")
+	t0 := time.Now().Add(-time.Hour)
+
+	// Superseded by a later program on the same interface and hook.
+	oldDir := makePinDir(root, "cali1 ingress v1")
+	labelAt(root, "cali1 ingress v1", us, HookIngress, "cali1", t0)
+	newDir := makePinDir(root, "cali1 ingress v2")
+	labelAt(root, "cali1 ingress v2", NewPinOwner("node", "efgh"), HookIngress, "cali1", t0.Add(time.Minute))
+	// Same interface, other hook.
+	egressDir := makePinDir(root, "cali1 egress v1")
+	labelAt(root, "cali1 egress v1", us, HookEgress, "cali1", t0)
+	// Interface is gone.
+	goneDir := makePinDir(root, "cali2 ingress v1")
+	labelAt(root, "cali2 ingress v1", us, HookIngress, "cali2", t0)
+	// Other owners' and unlabelled pins are left alone.
+	otherDir := makePinDir(root, "cali3 ingress v1")
+	labelAt(root, "cali3 ingress v1", other, HookIngress, "cali3", t0)
+	unlabelledDir := makePinDir(root, "cali4 ingress v1")
+	globalsDir := filepath.Join(root, "globals")
+	Expect(os.Mkdir(globalsDir, 0700)).To(Succeed())
+
+	collectPinGarbage(root, us, set.From("cali1", "cali3", "cali4"))
+
+	for _, d := range []string{newDir, egressDir, otherDir, unlabelledDir, globalsDir} {
+		Expect(d).To(BeADirectory())
+	}
+	for _, d := range []string{oldDir, goneDir} {
+		Expect(d).NotTo(BeADirectory())
+	}
+}
+
+func TestCleanUpPinsSkipsOtherOwners(t *testing.T) {
+	root, cleanup := setupPinRoot(t)
+	defer cleanup()
+
+	us := NewPinOwner("node", "abcd")
+	ourDir := makePinDir(root, "ours")
+	labelAt(root, "ours", us, HookIngress, "cali1", time.Now())
+	otherDir := makePinDir(root, "theirs")
+	labelAt(root, "theirs", NewPinOwner("other-tenant", "abcd"), HookIngress, "cali2", time.Now())
+	Expect(ioutil.WriteFile(filepath.Join(root, "cali_top_level"), nil, 0600)).To(Succeed())
+
+	cleanUpPins(root, us)
+
+	Expect(filepath.Join(ourDir, "cali_jump")).NotTo(BeAnExistingFile())
+	Expect(filepath.Join(otherDir, "cali_jump")).To(BeAnExistingFile())
+	Expect(filepath.Join(root, "cali_top_level")).NotTo(BeAnExistingFile())
+}
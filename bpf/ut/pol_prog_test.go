@@ -172,10 +172,7 @@ func TestLoadGarbageProgram(t *testing.T) {
 	Expect(fd).To(BeZero())
 }
 
-const (
-	RCDrop            = 2
-	RCEpilogueReached = 123
-)
+const RCEpilogueReached = 123
 
 func packetWithPorts(proto int, src, dst string) packet {
 	parts := strings.Split(src, ":")
@@ -207,6 +204,10 @@ func udpPkt(src, dst string) packet {
 	return packetWithPorts(17, src, dst)
 }
 
+func sctpPkt(src, dst string) packet {
+	return packetWithPorts(132, src, dst)
+}
+
 func icmpPkt(src, dst string) packet {
 	return packetWithPorts(1, src+":0", dst+":0")
 }
@@ -315,6 +316,66 @@ var polProgramTests = []polProgramTest{
 			tcpPkt("10.0.0.2:80", "10.0.0.1:31245"),
 			icmpPkt("10.0.0.1", "10.0.0.2")},
 	},
+	{
+		PolicyName: "default action allow",
+		Policy: polprog.Rules{
+			Tiers: []polprog.Tier{
+				{
+					Name: "audit",
+					Policies: []polprog.Policy{
+						{
+							Name: "deny 10.0.0.1",
+							Rules: []polprog.Rule{{Rule: &proto.Rule{
+								Action: "Deny",
+								SrcNet: []string{"10.0.0.1/32"},
+							}}},
+							DefaultAction: "allow",
+						},
+						{
+							Name:  "unreachable",
+							Rules: []polprog.Rule{{Rule: &proto.Rule{Action: "Deny"}}},
+						},
+					},
+				},
+			},
+		},
+		AllowedPackets: []packet{
+			tcpPkt("10.0.0.2:31245", "10.0.0.1:80"),
+			icmpPkt("10.0.0.2", "10.0.0.1")},
+		DroppedPackets: []packet{
+			tcpPkt("10.0.0.1:31245", "10.0.0.2:80"),
+			icmpPkt("10.0.0.1", "10.0.0.2")},
+	},
+	{
+		PolicyName: "default action pass",
+		Policy: polprog.Rules{
+			Tiers: []polprog.Tier{
+				{
+					Name: "pass",
+					Policies: []polprog.Policy{
+						{
+							Name:          "no rules",
+							DefaultAction: "next-tier",
+						},
+						{
+							Name:  "unreachable",
+							Rules: []polprog.Rule{{Rule: &proto.Rule{Action: "Deny"}}},
+						},
+					},
+				},
+				{
+					Name: "allow",
+					Policies: []polprog.Policy{{
+						Name:  "allow all",
+						Rules: []polprog.Rule{{Rule: &proto.Rule{Action: "Allow"}}},
+					}},
+				},
+			},
+		},
+		AllowedPackets: []packet{
+			tcpPkt("10.0.0.1:31245", "10.0.0.2:80"),
+			icmpPkt("10.0.0.1", "10.0.0.2")},
+	},
 	{
 		PolicyName: "pass to allow",
 		Policy: polprog.Rules{
@@ -748,6 +809,69 @@ var polProgramTests = []polProgramTest{
 			udpPkt("10.0.0.1:31245", "10.0.0.2:80"),
 			icmpPkt("10.0.0.1", "10.0.0.2")},
 	},
+	{
+		PolicyName: "allow from tcp:ranges",
+		Policy: makeRulesSingleTier([]*proto.Rule{{
+			Action:   "Allow",
+			Protocol: &proto.Protocol{NumberOrName: &proto.Protocol_Name{Name: "tcp"}},
+			SrcPorts: []*proto.PortRange{
+				{First: 8080, Last: 8081},
+				{First: 80, Last: 81},
+				{First: 90, Last: 90},
+				{First: 81, Last: 85},
+			},
+		}}),
+		AllowedPackets: []packet{
+			tcpPkt("10.0.0.2:80", "10.0.0.1:31245"),
+			tcpPkt("10.0.0.2:85", "10.0.0.1:31245"),
+			tcpPkt("10.0.0.2:90", "10.0.0.1:31245"),
+			tcpPkt("10.0.0.2:8081", "10.0.0.1:31245")},
+		DroppedPackets: []packet{
+			packetNoPorts(253, "10.0.0.2", "10.0.0.1"),
+			tcpPkt("10.0.0.2:79", "10.0.0.1:31245"),
+			tcpPkt("10.0.0.2:86", "10.0.0.1:31245"),
+			tcpPkt("10.0.0.2:91", "10.0.0.1:31245"),
+			tcpPkt("10.0.0.2:8082", "10.0.0.1:31245"),
+			tcpPkt("10.0.0.1:31245", "10.0.0.2:80"),
+			udpPkt("10.0.0.2:80", "10.0.0.1:31245")},
+	},
+	{
+		PolicyName: "allow from tcp:!ranges",
+		Policy: makeRulesSingleTier([]*proto.Rule{{
+			Action:   "Allow",
+			Protocol: &proto.Protocol{NumberOrName: &proto.Protocol_Name{Name: "tcp"}},
+			NotSrcPorts: []*proto.PortRange{
+				{First: 0, Last: 79},
+				{First: 90, Last: 90},
+			},
+		}}),
+		AllowedPackets: []packet{
+			tcpPkt("10.0.0.2:80", "10.0.0.1:31245"),
+			tcpPkt("10.0.0.2:89", "10.0.0.1:31245"),
+			tcpPkt("10.0.0.2:91", "10.0.0.1:31245")},
+		DroppedPackets: []packet{
+			packetNoPorts(253, "10.0.0.2", "10.0.0.1"),
+			tcpPkt("10.0.0.2:0", "10.0.0.1:31245"),
+			tcpPkt("10.0.0.2:79", "10.0.0.1:31245"),
+			tcpPkt("10.0.0.2:90", "10.0.0.1:31245"),
+			udpPkt("10.0.0.2:80", "10.0.0.1:31245")},
+	},
+	{
+		PolicyName: "allow from tcp:ranges to tcp:ranges",
+		Policy: makeRulesSingleTier([]*proto.Rule{{
+			Action:   "Allow",
+			Protocol: &proto.Protocol{NumberOrName: &proto.Protocol_Name{Name: "tcp"}},
+			SrcPorts: []*proto.PortRange{{First: 1024, Last: 65535}},
+			DstPorts: []*proto.PortRange{{First: 80, Last: 81}, {First: 443, Last: 443}},
+		}}),
+		AllowedPackets: []packet{
+			tcpPkt("10.0.0.1:31245", "10.0.0.2:80"),
+			tcpPkt("10.0.0.1:1024", "10.0.0.2:443")},
+		DroppedPackets: []packet{
+			tcpPkt("10.0.0.1:1023", "10.0.0.2:80"),
+			tcpPkt("10.0.0.1:31245", "10.0.0.2:82"),
+			tcpPkt("10.0.0.2:80", "10.0.0.1:31245")},
+	},
 	{
 		PolicyName: "allow to tcp:80",
 		Policy: makeRulesSingleTier([]*proto.Rule{{
@@ -957,6 +1081,23 @@ var polProgramTests = []polProgramTest{
 			"setB": {"123.0.0.1/32,udp:1024"},
 		},
 	},
+	{
+		PolicyName: "allow to named SCTP port",
+		Policy: makeRulesSingleTier([]*proto.Rule{{
+			Action:               "Allow",
+			DstNamedPortIpSetIds: []string{"setA"},
+		}}),
+		AllowedPackets: []packet{
+			sctpPkt("10.0.0.1:31245", "10.0.0.2:3868")},
+		DroppedPackets: []packet{
+			tcpPkt("10.0.0.1:31245", "10.0.0.2:3868"),  // Wrong proto
+			sctpPkt("10.0.0.1:31245", "10.0.0.2:3869"), // Wrong port
+			sctpPkt("10.0.0.2:3868", "10.0.0.1:31245"), // Src/dest confusion
+		},
+		IPSets: map[string][]string{
+			"setA": {"10.0.0.2/32,sctp:3868"},
+		},
+	},
 	{
 		PolicyName: "allow to mixed ports",
 		Policy: makeRulesSingleTier([]*proto.Rule{{
@@ -1026,6 +1167,60 @@ var polProgramTests = []polProgramTest{
 			"setB": {"123.0.0.1/32,udp:1024"},
 		},
 	},
+	{
+		PolicyName: "allow from mixed ports",
+		Policy: makeRulesSingleTier([]*proto.Rule{{
+			Action: "Allow",
+			// Should match either port or named port
+			SrcPorts: []*proto.PortRange{
+				{First: 81, Last: 82},
+				{First: 90, Last: 90},
+			},
+			SrcNamedPortIpSetIds: []string{"setA", "setB"},
+		}}),
+		AllowedPackets: []packet{
+			udpPkt("123.0.0.1:1024", "10.0.0.2:12345"),
+			tcpPkt("10.0.0.2:80", "10.0.0.1:31245"),
+			tcpPkt("10.0.0.2:90", "10.0.0.1:31245"),
+			tcpPkt("10.0.0.2:82", "10.0.0.1:31245")},
+		DroppedPackets: []packet{
+			packetNoPorts(253, "10.0.0.2", "11.0.0.2"), // Wrong proto, no ports
+			tcpPkt("10.0.0.2:8080", "11.0.0.1:12345"),  // Wrong port
+			udpPkt("10.0.0.2:80", "10.0.0.1:31245"),    // Wrong proto
+			tcpPkt("10.0.0.1:31245", "10.0.0.2:80"),    // Src/dest confusion
+			tcpPkt("10.0.0.1:80", "10.0.0.2:31245"),    // Wrong src
+		},
+		IPSets: map[string][]string{
+			"setA": {"10.0.0.2/32,tcp:80"},
+			"setB": {"123.0.0.1/32,udp:1024"},
+		},
+	},
+	{
+		PolicyName: "allow from not mixed ports",
+		Policy: makeRulesSingleTier([]*proto.Rule{{
+			Action:   "Allow",
+			Protocol: &proto.Protocol{NumberOrName: &proto.Protocol_Name{Name: "tcp"}},
+			// Should match neither port nor named port
+			NotSrcPorts: []*proto.PortRange{
+				{First: 81, Last: 82},
+				{First: 90, Last: 90},
+			},
+			NotSrcNamedPortIpSetIds: []string{"setA"},
+		}}),
+		AllowedPackets: []packet{
+			tcpPkt("10.0.0.2:83", "10.0.0.1:31245"),
+			tcpPkt("10.0.0.1:80", "10.0.0.2:31245"), // Named port but different IP
+			tcpPkt("10.0.0.2:8080", "11.0.0.1:12345")},
+		DroppedPackets: []packet{
+			tcpPkt("10.0.0.2:80", "10.0.0.1:31245"),
+			tcpPkt("10.0.0.2:81", "10.0.0.1:31245"),
+			tcpPkt("10.0.0.2:82", "10.0.0.1:31245"),
+			tcpPkt("10.0.0.2:90", "10.0.0.1:31245"),
+			udpPkt("10.0.0.2:83", "10.0.0.1:31245")}, // Wrong proto
+		IPSets: map[string][]string{
+			"setA": {"10.0.0.2/32,tcp:80"},
+		},
+	},
 	// ICMP tests
 	{
 		PolicyName: "allow icmp packet with type 8",
@@ -1092,6 +1287,51 @@ var polProgramTests = []polProgramTest{
 			packetNoPorts(254, "11.0.0.2", "10.0.0.2"),
 		},
 	},
+	{
+		PolicyName: "Protocol name match with ports",
+		Policy: makeRulesSingleTier([]*proto.Rule{{
+			Action:   "Allow",
+			Protocol: &proto.Protocol{NumberOrName: &proto.Protocol_Name{Name: "SCTP"}},
+			DstPorts: []*proto.PortRange{{First: 9000, Last: 9000}},
+		}}),
+		AllowedPackets: []packet{
+			packetWithPorts(132, "10.0.0.1:31245", "10.0.0.2:9000"),
+		},
+		DroppedPackets: []packet{
+			packetWithPorts(132, "10.0.0.1:31245", "10.0.0.2:9001"),
+			tcpPkt("10.0.0.1:31245", "10.0.0.2:9000"),
+			udpPkt("10.0.0.1:31245", "10.0.0.2:9000"),
+		},
+	},
+	{
+		PolicyName: "Numeric protocol sent as name",
+		Policy: makeRulesSingleTier([]*proto.Rule{{
+			Action:   "Allow",
+			Protocol: &proto.Protocol{NumberOrName: &proto.Protocol_Name{Name: "112"}},
+		}}),
+		AllowedPackets: []packet{
+			packetNoPorts(112, "10.0.0.1", "10.0.0.2"),
+		},
+		DroppedPackets: []packet{
+			packetNoPorts(253, "10.0.0.1", "10.0.0.2"),
+			tcpPkt("10.0.0.1:31245", "10.0.0.2:80"),
+		},
+	},
+	{
+		PolicyName: "Negated numeric protocol match",
+		Policy: makeRulesSingleTier([]*proto.Rule{{
+			Action:      "Allow",
+			NotProtocol: &proto.Protocol{NumberOrName: &proto.Protocol_Number{Number: 112}},
+		}}),
+		AllowedPackets: []packet{
+			packetNoPorts(253, "10.0.0.1", "10.0.0.2"),
+			tcpPkt("10.0.0.1:31245", "10.0.0.2:80"),
+			packetWithPorts(132, "10.0.0.1:31245", "10.0.0.2:9000"),
+		},
+		DroppedPackets: []packet{
+			packetNoPorts(112, "10.0.0.1", "10.0.0.2"),
+		},
+	},
 }
 
 var hostPolProgramTests = []polProgramTest{
@@ -1467,6 +1707,8 @@ func (p packet) String() string {
 		protoName = "tcp"
 	case 17:
 		protoName = "udp"
+	case 132:
+		protoName = "sctp"
 	case 1:
 		protoName = "icmp"
 	}
@@ -1608,7 +1850,8 @@ func runTest(t *testing.T, tp testPolicy) {
 	for _, tc := range tp.DroppedPackets() {
 		t.Run(fmt.Sprintf("should drop %s", tc), func(t *testing.T) {
 			RegisterTestingT(t)
-			runProgram(tc, testStateMap, polProgFD, RCDrop, state.PolicyNoMatch)
+			// The policy program hands denied packets to the epilogue to drop.
+			runProgram(tc, testStateMap, polProgFD, RCEpilogueReached, state.PolicyDeny)
 		})
 	}
 }
@@ -45,7 +45,7 @@ func init() {
 }
 
 type ipSetUpdateCallbacks interface {
-	OnIPSetAdded(setID string, ipSetType proto.IPSetUpdate_IPSetType)
+	OnIPSetAdded(setID string, ipSetType proto.IPSetUpdate_IPSetType, selector string)
 	OnIPSetMemberAdded(setID string, ip labelindex.IPSetMember)
 	OnIPSetMemberRemoved(setID string, ip labelindex.IPSetMember)
 	OnIPSetRemoved(setID string)
@@ -72,13 +72,15 @@ type configCallbacks interface {
 type passthruCallbacks interface {
 	OnHostIPUpdate(hostname string, ip *net.IP)
 	OnHostIPRemove(hostname string)
+	OnHostIPv6Update(hostname string, ip *net.IP)
+	OnHostIPv6Remove(hostname string)
 	OnIPPoolUpdate(model.IPPoolKey, *model.IPPool)
 	OnIPPoolRemove(model.IPPoolKey)
 	OnServiceAccountUpdate(*proto.ServiceAccountUpdate)
 	OnServiceAccountRemove(proto.ServiceAccountID)
 	OnNamespaceUpdate(*proto.NamespaceUpdate)
 	OnNamespaceRemove(proto.NamespaceID)
-	OnWireguardUpdate(string, *model.Wireguard)
+	OnWireguardUpdate(nodename string, wg *model.Wireguard, hasEncryptionLabel bool)
 	OnWireguardRemove(string)
 	OnGlobalBGPConfigUpdate(*v3.BGPConfiguration)
 }
@@ -93,6 +95,11 @@ type vxlanCallbacks interface {
 	OnVTEPRemove(node string)
 }
 
+type staticRouteCallbacks interface {
+	OnStaticRouteUpdate(update *proto.StaticRouteUpdate)
+	OnStaticRouteRemove(id string)
+}
+
 type PipelineCallbacks interface {
 	ipSetUpdateCallbacks
 	rulesUpdateCallbacks
@@ -101,6 +108,7 @@ type PipelineCallbacks interface {
 	passthruCallbacks
 	routeCallbacks
 	vxlanCallbacks
+	staticRouteCallbacks
 }
 
 type CalcGraph struct {
@@ -227,7 +235,7 @@ func NewCalculationGraph(callbacks PipelineCallbacks, conf *config.Config) *Calc
 	ipsetMemberIndex.RegisterWith(allUpdDispatcher)
 	ruleScanner.OnIPSetActive = func(ipSet *IPSetData) {
 		log.WithField("ipSet", ipSet).Info("IPSet now active")
-		callbacks.OnIPSetAdded(ipSet.UniqueID(), ipSet.DataplaneProtocolType())
+		callbacks.OnIPSetAdded(ipSet.UniqueID(), ipSet.DataplaneProtocolType(), ipSet.Selector.String())
 		ipsetMemberIndex.UpdateIPSet(ipSet.UniqueID(), ipSet.Selector, ipSet.NamedPortProtocol, ipSet.NamedPort)
 		gaugeNumActiveSelectors.Inc()
 	}
@@ -298,7 +306,11 @@ func NewCalculationGraph(callbacks PipelineCallbacks, conf *config.Config) *Calc
 	//         |
 	//      <dataplane>
 	//
-	hostIPPassthru := NewDataplanePassthru(callbacks)
+	var wireguardEncryptionLabel string
+	if conf.WireguardEnabled && conf.WireguardEncryptionScope == "Labelled" {
+		wireguardEncryptionLabel = conf.WireguardEncryptionLabel
+	}
+	hostIPPassthru := NewDataplanePassthru(callbacks, wireguardEncryptionLabel)
 	hostIPPassthru.RegisterWith(allUpdDispatcher)
 
 	if conf.BPFEnabled || conf.VXLANEnabled || conf.WireguardEnabled {
@@ -351,6 +363,21 @@ func NewCalculationGraph(callbacks PipelineCallbacks, conf *config.Config) *Calc
 	configBatcher := NewConfigBatcher(hostname, callbacks)
 	configBatcher.RegisterWith(allUpdDispatcher)
 
+	// Calculate operator-defined static routes.
+	//        ...
+	//     Dispatcher (all updates)
+	//         |
+	//         | StaticRoutes global/host config
+	//         |
+	//       static route calculator
+	//         |
+	//         | static routes
+	//         |
+	//      <dataplane>
+	//
+	staticRouteCalc := NewStaticRouteCalculator(hostname, callbacks)
+	staticRouteCalc.RegisterWith(allUpdDispatcher)
+
 	// The profile decoder identifies objects with special dataplane significance which have
 	// been encoded as profiles by libcalico-go. At present this includes Kubernetes Service
 	// Accounts and Kubernetes Namespaces.
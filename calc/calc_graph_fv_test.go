@@ -95,6 +95,9 @@ var baseTests = []StateList{
 	{hostEp1WithUntrackedPolicy, hostEp1WithPolicy, hostEp1WithIngressPolicy},
 	{hostEp1WithUntrackedPolicy, hostEp1WithTrackedAndUntrackedPolicy, hostEp1WithPolicy},
 
+	// Untracked policy applied to a workload endpoint, then switched to tracked.
+	{localEp1WithUntrackedPolicy, localEp1WithPolicy},
+
 	// Pre-DNAT policy, then egress-only policy.
 	{hostEp1WithPreDNATPolicy, hostEp1WithEgressPolicy},
 
@@ -29,6 +29,7 @@ import (
 	"github.com/projectcalico/felix/dataplane/mock"
 	"github.com/projectcalico/felix/dispatcher"
 	"github.com/projectcalico/felix/proto"
+	libapiv3 "github.com/projectcalico/libcalico-go/lib/apis/v3"
 	"github.com/projectcalico/libcalico-go/lib/backend/api"
 	"github.com/projectcalico/libcalico-go/lib/backend/model"
 	"github.com/projectcalico/libcalico-go/lib/net"
@@ -257,6 +258,68 @@ var _ = Describe("Host IP duplicate squashing test", func() {
 			},
 		))
 	})
+	It("should pass on IPv6 addresses from the node resource", func() {
+		nodeUpdate := func(ipv4, ipv6 string) api.Update {
+			node := libapiv3.NewNode()
+			node.Name = "foo"
+			node.Spec.BGP = &libapiv3.NodeBGPSpec{IPv4Address: ipv4, IPv6Address: ipv6}
+			return api.Update{
+				UpdateType: api.UpdateTypeKVUpdated,
+				KVPair: model.KVPair{
+					Key:   model.ResourceKey{Kind: libapiv3.KindNode, Name: "foo"},
+					Value: node,
+				},
+			}
+		}
+		// IPv6-only node.
+		cg.OnUpdate(nodeUpdate("", "fd00::1/64"))
+		eb.Flush()
+		// Node gains an IPv4 address.
+		cg.OnUpdate(nodeUpdate("10.0.0.1/24", "fd00::1/64"))
+		cg.OnUpdate(api.Update{
+			UpdateType: api.UpdateTypeKVNew,
+			KVPair: model.KVPair{
+				Key:   model.HostIPKey{Hostname: "foo"},
+				Value: &testIPAs4,
+			},
+		})
+		eb.Flush()
+		// Node loses its IPv6 address.
+		cg.OnUpdate(nodeUpdate("10.0.0.1/24", ""))
+		eb.Flush()
+		// Node deleted.
+		cg.OnUpdate(api.Update{
+			UpdateType: api.UpdateTypeKVDeleted,
+			KVPair: model.KVPair{
+				Key: model.HostIPKey{Hostname: "foo"},
+			},
+		})
+		cg.OnUpdate(api.Update{
+			UpdateType: api.UpdateTypeKVDeleted,
+			KVPair: model.KVPair{
+				Key: model.ResourceKey{Kind: libapiv3.KindNode, Name: "foo"},
+			},
+		})
+		eb.Flush()
+		Expect(messagesReceived).To(Equal([]interface{}{
+			&proto.HostMetadataUpdate{
+				Hostname: "foo",
+				Ipv6Addr: "fd00::1",
+			},
+			&proto.HostMetadataUpdate{
+				Hostname: "foo",
+				Ipv4Addr: "10.0.0.1",
+				Ipv6Addr: "fd00::1",
+			},
+			&proto.HostMetadataUpdate{
+				Hostname: "foo",
+				Ipv4Addr: "10.0.0.1",
+			},
+			&proto.HostMetadataRemove{
+				Hostname: "foo",
+			},
+		}))
+	})
 })
 
 var _ = Describe("specific scenario tests", func() {
@@ -15,10 +15,13 @@
 package calc
 
 import (
+	"strings"
+
 	log "github.com/sirupsen/logrus"
 
 	v3 "github.com/projectcalico/api/pkg/apis/projectcalico/v3"
 	"github.com/projectcalico/felix/dispatcher"
+	libapiv3 "github.com/projectcalico/libcalico-go/lib/apis/v3"
 	"github.com/projectcalico/libcalico-go/lib/backend/api"
 	"github.com/projectcalico/libcalico-go/lib/backend/model"
 	"github.com/projectcalico/libcalico-go/lib/net"
@@ -27,17 +30,43 @@ import (
 // DataplanePassthru passes through some datamodel updates to the dataplane layer, removing some
 // duplicates along the way.  It maps OnUpdate() calls to dedicated method calls for consistency
 // with the rest of the dataplane API.
+//
+// Node resources are also used to pass through each host's IPv6 address (from its BGP spec), so
+// that IPv6-only hosts are known to the dataplane.
+//
+// If a wireguard encryption label is configured (in the form "key" or "key=value"), it also
+// tracks which Node resources carry that label and annotates the wireguard updates accordingly,
+// resending them when a node's label changes.
 type DataplanePassthru struct {
 	callbacks passthruCallbacks
 
-	hostIPs map[string]*net.IP
+	hostIPs   map[string]*net.IP
+	hostIPv6s map[string]*net.IP
+
+	encryptionLabelKey      string
+	encryptionLabelValue    string
+	encryptionLabelHasValue bool
+	wireguard               map[string]*model.Wireguard
+	nodesWithLabel          map[string]bool
 }
 
-func NewDataplanePassthru(callbacks passthruCallbacks) *DataplanePassthru {
-	return &DataplanePassthru{
-		callbacks: callbacks,
-		hostIPs:   map[string]*net.IP{},
+func NewDataplanePassthru(callbacks passthruCallbacks, wireguardEncryptionLabel string) *DataplanePassthru {
+	p := &DataplanePassthru{
+		callbacks:      callbacks,
+		hostIPs:        map[string]*net.IP{},
+		hostIPv6s:      map[string]*net.IP{},
+		wireguard:      map[string]*model.Wireguard{},
+		nodesWithLabel: map[string]bool{},
+	}
+	if wireguardEncryptionLabel != "" {
+		parts := strings.SplitN(wireguardEncryptionLabel, "=", 2)
+		p.encryptionLabelKey = parts[0]
+		if len(parts) == 2 {
+			p.encryptionLabelValue = parts[1]
+			p.encryptionLabelHasValue = true
+		}
 	}
+	return p
 }
 
 func (h *DataplanePassthru) RegisterWith(dispatcher *dispatcher.Dispatcher) {
@@ -81,20 +110,79 @@ func (h *DataplanePassthru) OnUpdate(update api.Update) (filterOut bool) {
 	case model.WireguardKey:
 		if update.Value == nil {
 			log.WithField("update", update).Debug("Passing-through Wireguard deletion")
+			delete(h.wireguard, key.NodeName)
 			h.callbacks.OnWireguardRemove(key.NodeName)
 		} else {
 			log.WithField("update", update).Debug("Passing-through Wireguard update")
 			wg := update.Value.(*model.Wireguard)
-			h.callbacks.OnWireguardUpdate(key.NodeName, wg)
+			h.wireguard[key.NodeName] = wg
+			h.callbacks.OnWireguardUpdate(key.NodeName, wg, h.nodesWithLabel[key.NodeName])
 		}
 	case model.ResourceKey:
 		if key.Kind == v3.KindBGPConfiguration && key.Name == "default" {
 			log.WithField("update", update).Debug("Passing through global BGPConfiguration")
 			bgpConfig, _ := update.Value.(*v3.BGPConfiguration)
 			h.callbacks.OnGlobalBGPConfigUpdate(bgpConfig)
+		} else if key.Kind == libapiv3.KindNode {
+			node, _ := update.Value.(*libapiv3.Node)
+			h.onNodeIPv6Update(key.Name, node)
+			if h.encryptionLabelKey != "" {
+				h.onNodeUpdate(key.Name, node)
+			}
 		} else {
-			log.WithField("key", key).Debug("Ignoring v3 resource other than global BGPConfiguration")
+			log.WithField("key", key).Debug("Ignoring v3 resource other than global BGPConfiguration or Node")
 		}
 	}
 	return
 }
+
+// onNodeIPv6Update passes through changes to the node's IPv6 address.
+func (h *DataplanePassthru) onNodeIPv6Update(name string, node *libapiv3.Node) {
+	var addr *net.IP
+	if node != nil && node.Spec.BGP != nil && node.Spec.BGP.IPv6Address != "" {
+		ip, _, err := net.ParseCIDROrIP(node.Spec.BGP.IPv6Address)
+		if err != nil || ip.Version() != 6 {
+			log.WithField("node", name).WithError(err).Warn("Ignoring invalid node IPv6 address")
+		} else {
+			addr = ip
+		}
+	}
+
+	oldAddr := h.hostIPv6s[name]
+	if addr == nil {
+		if oldAddr != nil {
+			log.WithField("node", name).Debug("Passing-through HostIPv6 deletion")
+			delete(h.hostIPv6s, name)
+			h.callbacks.OnHostIPv6Remove(name)
+		}
+		return
+	}
+	if oldAddr != nil && addr.IP.Equal(oldAddr.IP) {
+		return
+	}
+	log.WithFields(log.Fields{"node": name, "ip": addr}).Debug("Passing-through HostIPv6 update")
+	h.hostIPv6s[name] = addr
+	h.callbacks.OnHostIPv6Update(name, addr)
+}
+
+// onNodeUpdate updates whether the node carries the wireguard encryption label, resending the
+// node's wireguard update if that has changed.
+func (h *DataplanePassthru) onNodeUpdate(name string, node *libapiv3.Node) {
+	hasLabel := false
+	if node != nil {
+		value, ok := node.Labels[h.encryptionLabelKey]
+		hasLabel = ok && (!h.encryptionLabelHasValue || value == h.encryptionLabelValue)
+	}
+	if hasLabel == h.nodesWithLabel[name] {
+		return
+	}
+	log.WithFields(log.Fields{"node": name, "hasLabel": hasLabel}).Debug("Wireguard encryption label changed")
+	if hasLabel {
+		h.nodesWithLabel[name] = true
+	} else {
+		delete(h.nodesWithLabel, name)
+	}
+	if wg, ok := h.wireguard[name]; ok {
+		h.callbacks.OnWireguardUpdate(name, wg, hasLabel)
+	}
+}
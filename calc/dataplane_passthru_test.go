@@ -0,0 +1,133 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calc_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/calc"
+	apiv3 "github.com/projectcalico/libcalico-go/lib/apis/v3"
+	"github.com/projectcalico/libcalico-go/lib/backend/api"
+	"github.com/projectcalico/libcalico-go/lib/backend/model"
+)
+
+type wireguardUpdate struct {
+	nodename           string
+	publicKey          string
+	hasEncryptionLabel bool
+}
+
+type wireguardCallbackRecorder struct {
+	passthruCallbackRecorder
+	wgUpdates []wireguardUpdate
+	wgRemoves []string
+}
+
+func (r *wireguardCallbackRecorder) OnWireguardUpdate(nodename string, wg *model.Wireguard, hasEncryptionLabel bool) {
+	r.wgUpdates = append(r.wgUpdates, wireguardUpdate{nodename, wg.PublicKey, hasEncryptionLabel})
+}
+
+func (r *wireguardCallbackRecorder) OnWireguardRemove(nodename string) {
+	r.wgRemoves = append(r.wgRemoves, nodename)
+}
+
+var _ = Describe("DataplanePassthru wireguard encryption label", func() {
+	var uut *calc.DataplanePassthru
+	var callbacks *wireguardCallbackRecorder
+
+	wgUpdate := func(name, key string) api.Update {
+		return api.Update{
+			KVPair: model.KVPair{
+				Key:   model.WireguardKey{NodeName: name},
+				Value: &model.Wireguard{PublicKey: key},
+			},
+			UpdateType: api.UpdateTypeKVNew,
+		}
+	}
+	nodeUpdate := func(name string, labels map[string]string) api.Update {
+		node := apiv3.NewNode()
+		node.Name = name
+		node.Labels = labels
+		return api.Update{
+			KVPair: model.KVPair{
+				Key:   model.ResourceKey{Kind: apiv3.KindNode, Name: name},
+				Value: node,
+			},
+			UpdateType: api.UpdateTypeKVUpdated,
+		}
+	}
+
+	Describe("with a key=value label", func() {
+		BeforeEach(func() {
+			callbacks = &wireguardCallbackRecorder{}
+			uut = calc.NewDataplanePassthru(callbacks, "zone=a")
+		})
+
+		It("should flag nodes with a matching label", func() {
+			uut.OnUpdate(nodeUpdate("node1", map[string]string{"zone": "a"}))
+			uut.OnUpdate(nodeUpdate("node2", map[string]string{"zone": "b"}))
+			uut.OnUpdate(wgUpdate("node1", "key1"))
+			uut.OnUpdate(wgUpdate("node2", "key2"))
+			Expect(callbacks.wgUpdates).To(Equal([]wireguardUpdate{
+				{"node1", "key1", true},
+				{"node2", "key2", false},
+			}))
+		})
+
+		It("should resend the wireguard update when the label changes", func() {
+			uut.OnUpdate(wgUpdate("node1", "key1"))
+			uut.OnUpdate(nodeUpdate("node1", map[string]string{"zone": "a"}))
+			uut.OnUpdate(nodeUpdate("node1", map[string]string{"zone": "a", "other": "x"}))
+			uut.OnUpdate(nodeUpdate("node1", nil))
+			Expect(callbacks.wgUpdates).To(Equal([]wireguardUpdate{
+				{"node1", "key1", false},
+				{"node1", "key1", true},
+				{"node1", "key1", false},
+			}))
+		})
+
+		It("should not send updates for nodes without wireguard", func() {
+			uut.OnUpdate(nodeUpdate("node1", map[string]string{"zone": "a"}))
+			Expect(callbacks.wgUpdates).To(BeEmpty())
+		})
+	})
+
+	Describe("with a key-only label", func() {
+		BeforeEach(func() {
+			callbacks = &wireguardCallbackRecorder{}
+			uut = calc.NewDataplanePassthru(callbacks, "encrypt")
+		})
+
+		It("should flag nodes with the label key, whatever its value", func() {
+			uut.OnUpdate(nodeUpdate("node1", map[string]string{"encrypt": ""}))
+			uut.OnUpdate(wgUpdate("node1", "key1"))
+			Expect(callbacks.wgUpdates).To(Equal([]wireguardUpdate{{"node1", "key1", true}}))
+		})
+	})
+
+	Describe("with no label", func() {
+		BeforeEach(func() {
+			callbacks = &wireguardCallbackRecorder{}
+			uut = calc.NewDataplanePassthru(callbacks, "")
+		})
+
+		It("should ignore node labels", func() {
+			uut.OnUpdate(nodeUpdate("node1", map[string]string{"encrypt": ""}))
+			uut.OnUpdate(wgUpdate("node1", "key1"))
+			Expect(callbacks.wgUpdates).To(Equal([]wireguardUpdate{{"node1", "key1", false}}))
+		})
+	})
+})
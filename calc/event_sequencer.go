@@ -46,7 +46,7 @@ type EventSequencer struct {
 
 	// Buffers used to hold data that we haven't flushed yet so we can coalesce multiple
 	// updates and generate updates in dependency order.
-	pendingAddedIPSets           map[string]proto.IPSetUpdate_IPSetType
+	pendingAddedIPSets           map[string]pendingIPSet
 	pendingRemovedIPSets         set.Set
 	pendingAddedIPSetMembers     multidict.StringToIface
 	pendingRemovedIPSetMembers   multidict.StringToIface
@@ -57,7 +57,7 @@ type EventSequencer struct {
 	pendingEndpointUpdates       map[model.Key]interface{}
 	pendingEndpointTierUpdates   map[model.Key][]tierInfo
 	pendingEndpointDeletes       set.Set
-	pendingHostIPUpdates         map[string]*net.IP
+	pendingHostIPUpdates         set.Set
 	pendingHostIPDeletes         set.Set
 	pendingIPPoolUpdates         map[ip.CIDR]*model.IPPool
 	pendingIPPoolDeletes         set.Set
@@ -72,9 +72,11 @@ type EventSequencer struct {
 	pendingRouteDeletes          set.Set
 	pendingVTEPUpdates           map[string]*proto.VXLANTunnelEndpointUpdate
 	pendingVTEPDeletes           set.Set
-	pendingWireguardUpdates      map[string]*model.Wireguard
+	pendingWireguardUpdates      map[string]*proto.WireguardEndpointUpdate
 	pendingWireguardDeletes      set.Set
 	pendingGlobalBGPConfig       *proto.GlobalBGPConfigUpdate
+	pendingStaticRouteUpdates    map[string]*proto.StaticRouteUpdate
+	pendingStaticRouteDeletes    set.Set
 
 	// Sets to record what we've sent downstream.  Updated whenever we flush.
 	sentIPSets          set.Set
@@ -88,6 +90,11 @@ type EventSequencer struct {
 	sentRoutes          set.Set
 	sentVTEPs           set.Set
 	sentWireguard       set.Set
+	sentStaticRoutes    set.Set
+
+	// Current IPv4 and IPv6 addresses of each host; a HostMetadataUpdate carries both.
+	hostIPv4s map[string]*net.IP
+	hostIPv6s map[string]*net.IP
 
 	Callback EventHandler
 }
@@ -105,7 +112,7 @@ type EventSequencer struct {
 func NewEventSequencer(conf configInterface) *EventSequencer {
 	buf := &EventSequencer{
 		config:                     conf,
-		pendingAddedIPSets:         map[string]proto.IPSetUpdate_IPSetType{},
+		pendingAddedIPSets:         map[string]pendingIPSet{},
 		pendingRemovedIPSets:       set.New(),
 		pendingAddedIPSetMembers:   multidict.NewStringToIface(),
 		pendingRemovedIPSetMembers: multidict.NewStringToIface(),
@@ -117,7 +124,7 @@ func NewEventSequencer(conf configInterface) *EventSequencer {
 		pendingEndpointUpdates:       map[model.Key]interface{}{},
 		pendingEndpointTierUpdates:   map[model.Key][]tierInfo{},
 		pendingEndpointDeletes:       set.New(),
-		pendingHostIPUpdates:         map[string]*net.IP{},
+		pendingHostIPUpdates:         set.New(),
 		pendingHostIPDeletes:         set.New(),
 		pendingIPPoolUpdates:         map[ip.CIDR]*model.IPPool{},
 		pendingIPPoolDeletes:         set.New(),
@@ -129,8 +136,10 @@ func NewEventSequencer(conf configInterface) *EventSequencer {
 		pendingRouteDeletes:          set.New(),
 		pendingVTEPUpdates:           map[string]*proto.VXLANTunnelEndpointUpdate{},
 		pendingVTEPDeletes:           set.New(),
-		pendingWireguardUpdates:      map[string]*model.Wireguard{},
+		pendingWireguardUpdates:      map[string]*proto.WireguardEndpointUpdate{},
 		pendingWireguardDeletes:      set.New(),
+		pendingStaticRouteUpdates:    map[string]*proto.StaticRouteUpdate{},
+		pendingStaticRouteDeletes:    set.New(),
 
 		// Sets to record what we've sent downstream.  Updated whenever we flush.
 		sentIPSets:          set.New(),
@@ -138,12 +147,15 @@ func NewEventSequencer(conf configInterface) *EventSequencer {
 		sentProfiles:        set.New(),
 		sentEndpoints:       set.New(),
 		sentHostIPs:         set.New(),
+		hostIPv4s:           map[string]*net.IP{},
+		hostIPv6s:           map[string]*net.IP{},
 		sentIPPools:         set.New(),
 		sentServiceAccounts: set.New(),
 		sentNamespaces:      set.New(),
 		sentRoutes:          set.New(),
 		sentVTEPs:           set.New(),
 		sentWireguard:       set.New(),
+		sentStaticRoutes:    set.New(),
 	}
 	return buf
 }
@@ -152,12 +164,17 @@ type routeID struct {
 	dst string
 }
 
-func (buf *EventSequencer) OnIPSetAdded(setID string, ipSetType proto.IPSetUpdate_IPSetType) {
+type pendingIPSet struct {
+	ipSetType proto.IPSetUpdate_IPSetType
+	selector  string
+}
+
+func (buf *EventSequencer) OnIPSetAdded(setID string, ipSetType proto.IPSetUpdate_IPSetType, selector string) {
 	log.Debugf("IP set %v now active", setID)
 	if buf.sentIPSets.Contains(setID) && !buf.pendingRemovedIPSets.Contains(setID) {
 		log.Panic("OnIPSetAdded called for existing IP set")
 	}
-	buf.pendingAddedIPSets[setID] = ipSetType
+	buf.pendingAddedIPSets[setID] = pendingIPSet{ipSetType: ipSetType, selector: selector}
 	buf.pendingRemovedIPSets.Discard(setID)
 	// An add implicitly means that the set is now empty.
 	buf.pendingAddedIPSetMembers.DiscardKey(setID)
@@ -280,8 +297,11 @@ func ParsedRulesToActivePolicyUpdate(key model.PolicyKey, rules *ParsedRules) *p
 				rules.OutboundRules,
 				"pol-out-default/"+key.Name,
 			),
-			Untracked: rules.Untracked,
-			PreDnat:   rules.PreDNAT,
+			Untracked:         rules.Untracked,
+			PreDnat:           rules.PreDNAT,
+			DefaultAction:     rules.DefaultAction,
+			LogDefaultAction:  rules.LogDefaultAction,
+			ActivationWindows: rules.ActivationWindows,
 		},
 	}
 }
@@ -351,21 +371,49 @@ func (buf *EventSequencer) flushProfileDeletes() {
 	})
 }
 
-func ModelWorkloadEndpointToProto(ep *model.WorkloadEndpoint, tiers []*proto.TierInfo) *proto.WorkloadEndpoint {
+// EgressSNATAddressLabel is the workload label that requests a specific source address for the
+// workload's SNATed egress traffic.  The address must be one of the node's configured
+// EgressSNATAddresses.
+const EgressSNATAddressLabel = "projectcalico.org/egress-snat-address"
+
+// ConnectionRateLimitLabel is the workload label that overrides the default rate limit for new
+// connections from the workload, in connections per second.  "0" disables the limit.
+const ConnectionRateLimitLabel = "projectcalico.org/connection-rate-limit"
+
+// EnforceMACLabel is the workload label that overrides whether frames from the workload must
+// come from the workload's MAC address ("true" or "false").  See WorkloadMACEnforcement.
+const EnforceMACLabel = "projectcalico.org/enforce-mac"
+
+// EndpointToHostActionLabel is the workload label that overrides DefaultEndpointToHostAction for
+// traffic from the workload to its host ("DROP", "RETURN" or "ACCEPT").
+const EndpointToHostActionLabel = "projectcalico.org/endpoint-to-host-action"
+
+func ModelWorkloadEndpointToProto(ep *model.WorkloadEndpoint, tiers, untrackedTiers []*proto.TierInfo) *proto.WorkloadEndpoint {
 	mac := ""
 	if ep.Mac != nil {
 		mac = ep.Mac.String()
 	}
 	return &proto.WorkloadEndpoint{
-		State:      ep.State,
-		Name:       ep.Name,
-		Mac:        mac,
-		ProfileIds: ep.ProfileIDs,
-		Ipv4Nets:   netsToStrings(ep.IPv4Nets),
-		Ipv6Nets:   netsToStrings(ep.IPv6Nets),
-		Tiers:      tiers,
-		Ipv4Nat:    natsToProtoNatInfo(ep.IPv4NAT),
-		Ipv6Nat:    natsToProtoNatInfo(ep.IPv6NAT),
+		State:          ep.State,
+		Name:           ep.Name,
+		Mac:            mac,
+		ProfileIds:     ep.ProfileIDs,
+		Ipv4Nets:       netsToStrings(ep.IPv4Nets),
+		Ipv6Nets:       netsToStrings(ep.IPv6Nets),
+		Tiers:          tiers,
+		Ipv4Nat:        natsToProtoNatInfo(ep.IPv4NAT),
+		Ipv6Nat:        natsToProtoNatInfo(ep.IPv6NAT),
+		UntrackedTiers: untrackedTiers,
+
+		EgressSnatAddress:    ep.Labels[EgressSNATAddressLabel],
+		ConnectionRateLimit:  ep.Labels[ConnectionRateLimitLabel],
+		EnforceMac:           ep.Labels[EnforceMACLabel],
+		EndpointToHostAction: ep.Labels[EndpointToHostActionLabel],
+		Namespace:            ep.Labels[v3.LabelNamespace],
+		ServiceAccount:       ep.Labels[v3.LabelServiceAccount],
+		// AllowedSourcePrefixes is left empty: the datamodel's WorkloadEndpoint doesn't carry
+		// allowed source prefixes yet (and label values can't hold CIDRs) so, for now, only
+		// dataplane drivers that build the proto themselves can set it.
 	}
 }
 
@@ -414,7 +462,7 @@ func (buf *EventSequencer) flushEndpointTierUpdates() {
 					WorkloadId:     key.WorkloadID,
 					EndpointId:     key.EndpointID,
 				},
-				Endpoint: ModelWorkloadEndpointToProto(wlep, tiers),
+				Endpoint: ModelWorkloadEndpointToProto(wlep, tiers, untrackedTiers),
 			})
 		case model.HostEndpointKey:
 			hep := endpoint.(*model.HostEndpoint)
@@ -461,28 +509,63 @@ func (buf *EventSequencer) OnHostIPUpdate(hostname string, ip *net.IP) {
 		"hostname": hostname,
 		"ip":       ip,
 	}).Debug("HostIP update")
-	buf.pendingHostIPDeletes.Discard(hostname)
-	buf.pendingHostIPUpdates[hostname] = ip
-}
-
-func (buf *EventSequencer) flushHostIPUpdates() {
-	for hostname, hostIP := range buf.pendingHostIPUpdates {
-		buf.Callback(&proto.HostMetadataUpdate{
-			Hostname: hostname,
-			Ipv4Addr: hostIP.IP.String(),
-		})
-		buf.sentHostIPs.Add(hostname)
-		delete(buf.pendingHostIPUpdates, hostname)
-	}
+	buf.hostIPv4s[hostname] = ip
+	buf.onHostIPsChanged(hostname)
 }
 
 func (buf *EventSequencer) OnHostIPRemove(hostname string) {
 	log.WithField("hostname", hostname).Debug("HostIP removed")
-	delete(buf.pendingHostIPUpdates, hostname)
+	delete(buf.hostIPv4s, hostname)
+	buf.onHostIPsChanged(hostname)
+}
+
+func (buf *EventSequencer) OnHostIPv6Update(hostname string, ip *net.IP) {
+	log.WithFields(log.Fields{
+		"hostname": hostname,
+		"ip":       ip,
+	}).Debug("HostIPv6 update")
+	buf.hostIPv6s[hostname] = ip
+	buf.onHostIPsChanged(hostname)
+}
+
+func (buf *EventSequencer) OnHostIPv6Remove(hostname string) {
+	log.WithField("hostname", hostname).Debug("HostIPv6 removed")
+	delete(buf.hostIPv6s, hostname)
+	buf.onHostIPsChanged(hostname)
+}
+
+// onHostIPsChanged queues an update for the host if it still has an address of either family,
+// or a removal if it has neither.
+func (buf *EventSequencer) onHostIPsChanged(hostname string) {
+	if buf.hostIPv4s[hostname] != nil || buf.hostIPv6s[hostname] != nil {
+		buf.pendingHostIPDeletes.Discard(hostname)
+		buf.pendingHostIPUpdates.Add(hostname)
+		return
+	}
+	buf.pendingHostIPUpdates.Discard(hostname)
 	if buf.sentHostIPs.Contains(hostname) {
 		buf.pendingHostIPDeletes.Add(hostname)
 	}
 }
+
+func (buf *EventSequencer) flushHostIPUpdates() {
+	buf.pendingHostIPUpdates.Iter(func(item interface{}) error {
+		hostname := item.(string)
+		msg := &proto.HostMetadataUpdate{
+			Hostname: hostname,
+		}
+		if hostIP := buf.hostIPv4s[hostname]; hostIP != nil {
+			msg.Ipv4Addr = hostIP.IP.String()
+		}
+		if hostIP := buf.hostIPv6s[hostname]; hostIP != nil {
+			msg.Ipv6Addr = hostIP.IP.String()
+		}
+		buf.Callback(msg)
+		buf.sentHostIPs.Add(hostname)
+		return set.RemoveItem
+	})
+}
+
 func (buf *EventSequencer) flushHostIPDeletes() {
 	buf.pendingHostIPDeletes.Iter(func(item interface{}) error {
 		buf.Callback(&proto.HostMetadataRemove{
@@ -518,16 +601,8 @@ func (buf *EventSequencer) flushIPPoolUpdates() {
 }
 
 func (buf *EventSequencer) flushHostWireguardUpdates() {
-	for nodename, wg := range buf.pendingWireguardUpdates {
-		var ipstr string
-		if wg.InterfaceIPv4Addr != nil {
-			ipstr = wg.InterfaceIPv4Addr.String()
-		}
-		buf.Callback(&proto.WireguardEndpointUpdate{
-			Hostname:          nodename,
-			PublicKey:         wg.PublicKey,
-			InterfaceIpv4Addr: ipstr,
-		})
+	for nodename, update := range buf.pendingWireguardUpdates {
+		buf.Callback(update)
 		buf.sentWireguard.Add(nodename)
 		delete(buf.pendingWireguardUpdates, nodename)
 	}
@@ -567,7 +642,7 @@ func (buf *EventSequencer) flushHostWireguardDeletes() {
 }
 
 func (buf *EventSequencer) flushAddedIPSets() {
-	for setID, setType := range buf.pendingAddedIPSets {
+	for setID, pending := range buf.pendingAddedIPSets {
 		log.WithField("setID", setID).Debug("Flushing added IP set")
 		members := make([]string, 0)
 		buf.pendingAddedIPSetMembers.Iter(setID, func(value interface{}) {
@@ -576,9 +651,10 @@ func (buf *EventSequencer) flushAddedIPSets() {
 		})
 		buf.pendingAddedIPSetMembers.DiscardKey(setID)
 		buf.Callback(&proto.IPSetUpdate{
-			Id:      setID,
-			Members: members,
-			Type:    setType,
+			Id:       setID,
+			Members:  members,
+			Type:     pending.ipSetType,
+			Selector: pending.selector,
 		})
 		buf.sentIPSets.Add(setID)
 		delete(buf.pendingAddedIPSets, setID)
@@ -640,6 +716,8 @@ func (buf *EventSequencer) Flush() {
 	buf.flushHostIPUpdates()
 	buf.flushIPPoolDeletes()
 	buf.flushIPPoolUpdates()
+	buf.flushStaticRouteDeletes()
+	buf.flushStaticRouteUpdates()
 
 	// Flush global BGPConfiguration updates.
 	if buf.pendingGlobalBGPConfig != nil {
@@ -751,12 +829,22 @@ func (buf *EventSequencer) OnNamespaceRemove(id proto.NamespaceID) {
 	}
 }
 
-func (buf *EventSequencer) OnWireguardUpdate(nodename string, wg *model.Wireguard) {
+func (buf *EventSequencer) OnWireguardUpdate(nodename string, wg *model.Wireguard, hasEncryptionLabel bool) {
 	log.WithFields(log.Fields{
-		"nodename": nodename,
+		"nodename":           nodename,
+		"hasEncryptionLabel": hasEncryptionLabel,
 	}).Debug("Wireguard updated")
+	var ipstr string
+	if wg.InterfaceIPv4Addr != nil {
+		ipstr = wg.InterfaceIPv4Addr.String()
+	}
 	buf.pendingWireguardDeletes.Discard(nodename)
-	buf.pendingWireguardUpdates[nodename] = wg
+	buf.pendingWireguardUpdates[nodename] = &proto.WireguardEndpointUpdate{
+		Hostname:           nodename,
+		PublicKey:          wg.PublicKey,
+		InterfaceIpv4Addr:  ipstr,
+		HasEncryptionLabel: hasEncryptionLabel,
+	}
 }
 
 func (buf *EventSequencer) OnWireguardRemove(nodename string) {
@@ -804,6 +892,40 @@ func (buf *EventSequencer) flushNamespaces() {
 	log.Debug("Done flushing Namespaces")
 }
 
+func (buf *EventSequencer) OnStaticRouteUpdate(update *proto.StaticRouteUpdate) {
+	log.WithField("id", update.Id).Debug("Static route update")
+	buf.pendingStaticRouteDeletes.Discard(update.Id)
+	buf.pendingStaticRouteUpdates[update.Id] = update
+}
+
+func (buf *EventSequencer) OnStaticRouteRemove(id string) {
+	log.WithField("id", id).Debug("Static route removed")
+	delete(buf.pendingStaticRouteUpdates, id)
+	if buf.sentStaticRoutes.Contains(id) {
+		buf.pendingStaticRouteDeletes.Add(id)
+	}
+}
+
+func (buf *EventSequencer) flushStaticRouteDeletes() {
+	buf.pendingStaticRouteDeletes.Iter(func(item interface{}) error {
+		id := item.(string)
+		buf.Callback(&proto.StaticRouteRemove{Id: id})
+		buf.sentStaticRoutes.Discard(id)
+		return nil
+	})
+	buf.pendingStaticRouteDeletes.Clear()
+	log.Debug("Done flushing static route removes")
+}
+
+func (buf *EventSequencer) flushStaticRouteUpdates() {
+	for id, msg := range buf.pendingStaticRouteUpdates {
+		buf.Callback(msg)
+		buf.sentStaticRoutes.Add(id)
+	}
+	buf.pendingStaticRouteUpdates = map[string]*proto.StaticRouteUpdate{}
+	log.Debug("Done flushing static route updates")
+}
+
 func (buf *EventSequencer) OnVTEPUpdate(update *proto.VXLANTunnelEndpointUpdate) {
 	node := update.Node
 	log.WithFields(log.Fields{"id": node}).Debug("VTEP update")
@@ -22,6 +22,7 @@ import (
 	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 
+	v3 "github.com/projectcalico/api/pkg/apis/projectcalico/v3"
 	"github.com/projectcalico/felix/calc"
 	"github.com/projectcalico/felix/config"
 	"github.com/projectcalico/felix/proto"
@@ -31,7 +32,7 @@ import (
 
 var _ = DescribeTable("ModelWorkloadEndpointToProto",
 	func(in model.WorkloadEndpoint, expected proto.WorkloadEndpoint) {
-		out := calc.ModelWorkloadEndpointToProto(&in, []*proto.TierInfo{})
+		out := calc.ModelWorkloadEndpointToProto(&in, []*proto.TierInfo{}, nil)
 		Expect(*out).To(Equal(expected))
 	},
 	Entry("workload endpoint with NAT", model.WorkloadEndpoint{
@@ -64,6 +65,108 @@ var _ = DescribeTable("ModelWorkloadEndpointToProto",
 		},
 		Ipv6Nat: []*proto.NatInfo{},
 	}),
+	Entry("workload endpoint with egress SNAT address", model.WorkloadEndpoint{
+		State:      "up",
+		Name:       "bill",
+		ProfileIDs: []string{},
+		IPv4Nets:   []net.IPNet{mustParseNet("10.28.0.13/32")},
+		Labels: map[string]string{
+			"app":                       "bill",
+			calc.EgressSNATAddressLabel: "172.16.1.10",
+		},
+	}, proto.WorkloadEndpoint{
+		State:             "up",
+		Name:              "bill",
+		ProfileIds:        []string{},
+		Ipv4Nets:          []string{"10.28.0.13/32"},
+		Ipv6Nets:          []string{},
+		Tiers:             []*proto.TierInfo{},
+		Ipv4Nat:           []*proto.NatInfo{},
+		Ipv6Nat:           []*proto.NatInfo{},
+		EgressSnatAddress: "172.16.1.10",
+	}),
+	Entry("workload endpoint with connection rate limit", model.WorkloadEndpoint{
+		State:      "up",
+		Name:       "bill",
+		ProfileIDs: []string{},
+		IPv4Nets:   []net.IPNet{mustParseNet("10.28.0.13/32")},
+		Labels: map[string]string{
+			"app":                         "bill",
+			calc.ConnectionRateLimitLabel: "50",
+		},
+	}, proto.WorkloadEndpoint{
+		State:               "up",
+		Name:                "bill",
+		ProfileIds:          []string{},
+		Ipv4Nets:            []string{"10.28.0.13/32"},
+		Ipv6Nets:            []string{},
+		Tiers:               []*proto.TierInfo{},
+		Ipv4Nat:             []*proto.NatInfo{},
+		Ipv6Nat:             []*proto.NatInfo{},
+		ConnectionRateLimit: "50",
+	}),
+	Entry("workload endpoint with MAC enforcement override", model.WorkloadEndpoint{
+		State:      "up",
+		Name:       "bill",
+		ProfileIDs: []string{},
+		IPv4Nets:   []net.IPNet{mustParseNet("10.28.0.13/32")},
+		Labels: map[string]string{
+			"app":                "bill",
+			calc.EnforceMACLabel: "false",
+		},
+	}, proto.WorkloadEndpoint{
+		State:      "up",
+		Name:       "bill",
+		ProfileIds: []string{},
+		Ipv4Nets:   []string{"10.28.0.13/32"},
+		Ipv6Nets:   []string{},
+		Tiers:      []*proto.TierInfo{},
+		Ipv4Nat:    []*proto.NatInfo{},
+		Ipv6Nat:    []*proto.NatInfo{},
+		EnforceMac: "false",
+	}),
+	Entry("workload endpoint with endpoint-to-host action override", model.WorkloadEndpoint{
+		State:      "up",
+		Name:       "bill",
+		ProfileIDs: []string{},
+		IPv4Nets:   []net.IPNet{mustParseNet("10.28.0.13/32")},
+		Labels: map[string]string{
+			"app":                          "bill",
+			calc.EndpointToHostActionLabel: "ACCEPT",
+		},
+	}, proto.WorkloadEndpoint{
+		State:                "up",
+		Name:                 "bill",
+		ProfileIds:           []string{},
+		Ipv4Nets:             []string{"10.28.0.13/32"},
+		Ipv6Nets:             []string{},
+		Tiers:                []*proto.TierInfo{},
+		Ipv4Nat:              []*proto.NatInfo{},
+		Ipv6Nat:              []*proto.NatInfo{},
+		EndpointToHostAction: "ACCEPT",
+	}),
+	Entry("workload endpoint with namespace and service account", model.WorkloadEndpoint{
+		State:      "up",
+		Name:       "bill",
+		ProfileIDs: []string{},
+		IPv4Nets:   []net.IPNet{mustParseNet("10.28.0.13/32")},
+		Labels: map[string]string{
+			"app":                  "bill",
+			v3.LabelNamespace:      "accounts",
+			v3.LabelServiceAccount: "billing",
+		},
+	}, proto.WorkloadEndpoint{
+		State:          "up",
+		Name:           "bill",
+		ProfileIds:     []string{},
+		Ipv4Nets:       []string{"10.28.0.13/32"},
+		Ipv6Nets:       []string{},
+		Tiers:          []*proto.TierInfo{},
+		Ipv4Nat:        []*proto.NatInfo{},
+		Ipv6Nat:        []*proto.NatInfo{},
+		Namespace:      "accounts",
+		ServiceAccount: "billing",
+	}),
 )
 
 var _ = Describe("ParsedRulesToActivePolicyUpdate", func() {
@@ -76,8 +179,11 @@ var _ = Describe("ParsedRulesToActivePolicyUpdate", func() {
 			InboundRules: []*calc.ParsedRule{
 				{Action: "Deny"},
 			},
-			PreDNAT:   true,
-			Untracked: true,
+			PreDNAT:           true,
+			Untracked:         true,
+			DefaultAction:     "allow",
+			LogDefaultAction:  true,
+			ActivationWindows: []string{"0 2 * * 6 4h"},
 		}
 		fullyLoadedProtoRules = proto.ActivePolicyUpdate{
 			Id: &proto.PolicyID{
@@ -85,11 +191,14 @@ var _ = Describe("ParsedRulesToActivePolicyUpdate", func() {
 				Name: "a-policy",
 			},
 			Policy: &proto.Policy{
-				Namespace:     "namespace",
-				InboundRules:  []*proto.Rule{{Action: "Deny"}},
-				OutboundRules: []*proto.Rule{{Action: "Allow"}},
-				Untracked:     true,
-				PreDnat:       true,
+				Namespace:         "namespace",
+				InboundRules:      []*proto.Rule{{Action: "Deny"}},
+				OutboundRules:     []*proto.Rule{{Action: "Allow"}},
+				Untracked:         true,
+				PreDnat:           true,
+				DefaultAction:     "allow",
+				LogDefaultAction:  true,
+				ActivationWindows: []string{"0 2 * * 6 4h"},
 			},
 		}
 	)
@@ -15,6 +15,7 @@ package calc
 
 import (
 	"fmt"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 
@@ -141,9 +142,73 @@ func (rs *RuleScanner) OnProfileInactive(key model.ProfileRulesKey) {
 
 func (rs *RuleScanner) OnPolicyActive(key model.PolicyKey, policy *model.Policy) {
 	parsedRules := rs.updateRules(key, policy.InboundRules, policy.OutboundRules, policy.DoNotTrack, policy.PreDNAT, policy.Namespace)
+	if annotation, ok := policy.Annotations[DefaultActionAnnotation]; ok {
+		action, logFirst, err := ParseDefaultAction(annotation)
+		if err != nil {
+			log.WithError(err).WithField("policy", key.Name).Warn(
+				"Ignoring invalid default action annotation on policy.")
+		} else {
+			parsedRules.DefaultAction = action
+			parsedRules.LogDefaultAction = logFirst
+		}
+	}
+	if annotation, ok := policy.Annotations[ActivationScheduleAnnotation]; ok {
+		parsedRules.ActivationWindows = ParseActivationSchedule(annotation)
+	}
 	rs.RulesUpdateCallbacks.OnPolicyActive(key, parsedRules)
 }
 
+// ActivationScheduleAnnotation is the policy annotation that limits the policy to a set of
+// activation windows, separated by ";".  Each window is of the form
+// "<minute> <hour> <day-of-month> <month> <day-of-week> <duration>"; the dataplane validates the
+// windows and treats the policy as if it had no rules outside them.
+const ActivationScheduleAnnotation = "projectcalico.org/activation-schedule"
+
+// ParseActivationSchedule splits the value of an ActivationScheduleAnnotation into its windows.
+// A policy whose schedule has no valid windows is never active, so an annotation with no windows
+// at all still returns a (single, invalid) window.
+func ParseActivationSchedule(value string) []string {
+	var windows []string
+	for _, w := range strings.Split(value, ";") {
+		if w = strings.TrimSpace(w); w != "" {
+			windows = append(windows, w)
+		}
+	}
+	if len(windows) == 0 {
+		windows = []string{value}
+	}
+	return windows
+}
+
+// DefaultActionAnnotation is the policy annotation that sets the action for packets that reach the
+// end of the policy's rules without matching one.  See ParseDefaultAction for the values.
+const DefaultActionAnnotation = "projectcalico.org/default-action"
+
+// ParseDefaultAction parses the value of a DefaultActionAnnotation: "Allow", "Deny" or "Pass", or
+// one of those prefixed with "LogAnd" to log packets before applying the action, or just "Log" to
+// log packets and then continue to the next policy.  For example, "LogAndAllow" gives an "audit
+// mode" policy.  Returns the equivalent rule action and whether to log first.
+func ParseDefaultAction(value string) (action string, logFirst bool, err error) {
+	if strings.EqualFold(value, "Log") {
+		return "", true, nil
+	}
+	if len(value) > len("LogAnd") && strings.EqualFold(value[:len("LogAnd")], "LogAnd") {
+		logFirst = true
+		value = value[len("LogAnd"):]
+	}
+	switch strings.ToLower(value) {
+	case "allow":
+		action = "allow"
+	case "deny":
+		action = "deny"
+	case "pass":
+		action = "next-tier"
+	default:
+		return "", false, fmt.Errorf("unknown default action %q", value)
+	}
+	return
+}
+
 func (rs *RuleScanner) OnPolicyInactive(key model.PolicyKey) {
 	rs.updateRules(key, nil, nil, false, false, "")
 	rs.RulesUpdateCallbacks.OnPolicyInactive(key)
@@ -254,6 +319,16 @@ type ParsedRules struct {
 
 	// PreDNAT is true if these rules should be applied before any DNAT.
 	PreDNAT bool
+
+	// DefaultAction is the action for packets that reach the end of the rules without matching
+	// one; "" to continue to the next policy.  LogDefaultAction is true if such packets should be
+	// logged first.  Only set for policies.
+	DefaultAction    string
+	LogDefaultAction bool
+
+	// ActivationWindows, if not empty, limits the policy to the given windows.  Only set for
+	// policies.
+	ActivationWindows []string
 }
 
 // ParsedRule is like a backend.model.Rule, except the tag and selector matches and named ports are
@@ -374,3 +374,108 @@ func ipSetIDForTag(tagID string) string {
 	// Tags are now implemented as a has(tagName) selector:
 	return hash.MakeUniqueID("s", fmt.Sprintf("has(%s)", tagID))
 }
+
+var _ = DescribeTable("ParseDefaultAction",
+	func(value string, expAction string, expLog bool, expValid bool) {
+		action, logFirst, err := ParseDefaultAction(value)
+		if !expValid {
+			Expect(err).To(HaveOccurred())
+			return
+		}
+		Expect(err).NotTo(HaveOccurred())
+		Expect(action).To(Equal(expAction))
+		Expect(logFirst).To(Equal(expLog))
+	},
+	Entry("Allow", "Allow", "allow", false, true),
+	Entry("Deny", "Deny", "deny", false, true),
+	Entry("Pass", "Pass", "next-tier", false, true),
+	Entry("lower case", "deny", "deny", false, true),
+	Entry("Log", "Log", "", true, true),
+	Entry("LogAndAllow", "LogAndAllow", "allow", true, true),
+	Entry("LogAndDeny", "logandDeny", "deny", true, true),
+	Entry("LogAndPass", "LogAndPass", "next-tier", true, true),
+	Entry("LogAnd", "LogAnd", "", false, false),
+	Entry("unknown", "Drop", "", false, false),
+	Entry("empty", "", "", false, false),
+)
+
+var _ = Describe("RuleScanner default actions", func() {
+	var (
+		rs     *RuleScanner
+		active map[model.PolicyKey]*ParsedRules
+	)
+
+	BeforeEach(func() {
+		rs = NewRuleScanner()
+		recorder := &scannerRecorder{active: map[model.PolicyKey]*ParsedRules{}}
+		active = recorder.active
+		rs.RulesUpdateCallbacks = recorder
+		rs.OnIPSetActive = func(*IPSetData) {}
+		rs.OnIPSetInactive = func(*IPSetData) {}
+	})
+
+	It("should parse the default action annotation", func() {
+		key := model.PolicyKey{Name: "audit"}
+		rs.OnPolicyActive(key, &model.Policy{
+			Annotations: map[string]string{DefaultActionAnnotation: "LogAndAllow"},
+		})
+		Expect(active[key].DefaultAction).To(Equal("allow"))
+		Expect(active[key].LogDefaultAction).To(BeTrue())
+
+		update := ParsedRulesToActivePolicyUpdate(key, active[key])
+		Expect(update.Policy.DefaultAction).To(Equal("allow"))
+		Expect(update.Policy.LogDefaultAction).To(BeTrue())
+	})
+
+	It("should ignore an invalid annotation", func() {
+		key := model.PolicyKey{Name: "typo"}
+		rs.OnPolicyActive(key, &model.Policy{
+			Annotations: map[string]string{DefaultActionAnnotation: "Alow"},
+		})
+		Expect(active[key].DefaultAction).To(BeEmpty())
+		Expect(active[key].LogDefaultAction).To(BeFalse())
+	})
+})
+
+var _ = DescribeTable("ParseActivationSchedule",
+	func(value string, expected []string) {
+		Expect(ParseActivationSchedule(value)).To(Equal(expected))
+	},
+	Entry("single window", "0 2 * * 6 4h", []string{"0 2 * * 6 4h"}),
+	Entry("multiple windows", " 0 2 * * 6 4h; 30 1 * * 0 1h ;",
+		[]string{"0 2 * * 6 4h", "30 1 * * 0 1h"}),
+	Entry("no windows", " ; ", []string{" ; "}),
+)
+
+var _ = Describe("RuleScanner activation schedules", func() {
+	It("should pass the activation windows to the dataplane", func() {
+		rs := NewRuleScanner()
+		recorder := &scannerRecorder{active: map[model.PolicyKey]*ParsedRules{}}
+		rs.RulesUpdateCallbacks = recorder
+		rs.OnIPSetActive = func(*IPSetData) {}
+		rs.OnIPSetInactive = func(*IPSetData) {}
+
+		key := model.PolicyKey{Name: "maintenance"}
+		rs.OnPolicyActive(key, &model.Policy{
+			Annotations: map[string]string{ActivationScheduleAnnotation: "0 2 * * 6 4h"},
+		})
+		update := ParsedRulesToActivePolicyUpdate(key, recorder.active[key])
+		Expect(update.Policy.ActivationWindows).To(Equal([]string{"0 2 * * 6 4h"}))
+	})
+})
+
+type scannerRecorder struct {
+	active map[model.PolicyKey]*ParsedRules
+}
+
+func (r *scannerRecorder) OnPolicyActive(key model.PolicyKey, rules *ParsedRules) {
+	r.active[key] = rules
+}
+
+func (r *scannerRecorder) OnPolicyInactive(key model.PolicyKey) {
+	delete(r.active, key)
+}
+
+func (r *scannerRecorder) OnProfileActive(model.ProfileRulesKey, *ParsedRules) {}
+
+func (r *scannerRecorder) OnProfileInactive(model.ProfileRulesKey) {}
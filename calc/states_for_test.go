@@ -143,6 +143,20 @@ var localEp1WithPolicy = withPolicy.withKVUpdates(
 	routelocalWlTenDotTwo,
 ).withName("ep1 local, policy")
 
+// localEp1WithUntrackedPolicy as above but with the policy marked as untracked.
+var localEp1WithUntrackedPolicy = localEp1WithPolicy.withKVUpdates(
+	KVPair{Key: PolicyKey{Name: "pol-1"}, Value: &policy1_order20_untracked},
+).withUntrackedPolicies(
+	proto.PolicyID{Tier: "default", Name: "pol-1"},
+).withEndpointUntracked(
+	localWlEp1Id,
+	[]mock.TierInfo{},
+	[]mock.TierInfo{
+		{Name: "default", IngressPolicyNames: []string{"pol-1"}, EgressPolicyNames: []string{"pol-1"}},
+	},
+	[]mock.TierInfo{},
+).withName("ep1 local, untracked policy")
+
 // localEp1WithNamedPortPolicy as above but with named port in the policy.
 var localEp1WithNamedPortPolicy = localEp1WithPolicy.withKVUpdates(
 	KVPair{Key: PolicyKey{Name: "pol-1"}, Value: &policy1_order20_with_selector_and_named_port_tcpport},
@@ -0,0 +1,119 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calc
+
+import (
+	"reflect"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/config"
+	"github.com/projectcalico/felix/dispatcher"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/stringutils"
+	"github.com/projectcalico/libcalico-go/lib/backend/api"
+	"github.com/projectcalico/libcalico-go/lib/backend/model"
+)
+
+const staticRoutesConfigName = "StaticRoutes"
+
+// StaticRouteCalculator turns the StaticRoutes config parameter into StaticRouteUpdate and
+// StaticRouteRemove messages for the dataplane.  The host-specific value of the parameter takes
+// precedence over the global value, as for any other parameter.  Sending the routes as
+// individual messages means that the dataplane can apply changes incrementally, without a
+// restart.
+type StaticRouteCalculator struct {
+	hostname  string
+	callbacks staticRouteCallbacks
+
+	globalValue, hostValue       string
+	globalValueSet, hostValueSet bool
+
+	routes map[string]config.StaticRoute
+}
+
+func NewStaticRouteCalculator(hostname string, callbacks staticRouteCallbacks) *StaticRouteCalculator {
+	return &StaticRouteCalculator{
+		hostname:  hostname,
+		callbacks: callbacks,
+		routes:    map[string]config.StaticRoute{},
+	}
+}
+
+func (c *StaticRouteCalculator) RegisterWith(allUpdDispatcher *dispatcher.Dispatcher) {
+	allUpdDispatcher.Register(model.GlobalConfigKey{}, c.OnUpdate)
+	allUpdDispatcher.Register(model.HostConfigKey{}, c.OnUpdate)
+}
+
+func (c *StaticRouteCalculator) OnUpdate(update api.Update) (filterOut bool) {
+	value, valueSet := update.Value.(string)
+	switch key := update.Key.(type) {
+	case model.GlobalConfigKey:
+		if key.Name != staticRoutesConfigName {
+			return
+		}
+		c.globalValue, c.globalValueSet = value, valueSet
+	case model.HostConfigKey:
+		if key.Name != staticRoutesConfigName || key.Hostname != c.hostname {
+			return
+		}
+		c.hostValue, c.hostValueSet = value, valueSet
+	default:
+		return
+	}
+	c.recalculate()
+	return
+}
+
+func (c *StaticRouteCalculator) recalculate() {
+	raw := c.globalValue
+	if c.hostValueSet {
+		raw = c.hostValue
+	} else if !c.globalValueSet {
+		raw = ""
+	}
+
+	routes := map[string]config.StaticRoute{}
+	specs, err := stringutils.ParseKeyValueList(raw)
+	if err != nil {
+		log.WithError(err).Warn("Failed to parse StaticRoutes, ignoring it.")
+	} else {
+		routes, err = config.ParseStaticRoutes(specs)
+		if err != nil {
+			log.WithError(err).Warn("Ignoring invalid static routes.")
+		}
+	}
+
+	for name := range c.routes {
+		if _, ok := routes[name]; !ok {
+			log.WithField("name", name).Info("Static route removed.")
+			c.callbacks.OnStaticRouteRemove(name)
+		}
+	}
+	for name, route := range routes {
+		if old, ok := c.routes[name]; ok && reflect.DeepEqual(old, route) {
+			continue
+		}
+		log.WithField("route", route).Info("Static route updated.")
+		c.callbacks.OnStaticRouteUpdate(&proto.StaticRouteUpdate{
+			Id:      name,
+			Dst:     route.CIDR,
+			NextHop: route.NextHop,
+			Device:  route.Device,
+			Table:   uint32(route.Table),
+		})
+	}
+	c.routes = routes
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package calc_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/calc"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/libcalico-go/lib/backend/api"
+	"github.com/projectcalico/libcalico-go/lib/backend/model"
+)
+
+type staticRouteRecorder struct {
+	updates []*proto.StaticRouteUpdate
+	removes []string
+}
+
+func (r *staticRouteRecorder) OnStaticRouteUpdate(update *proto.StaticRouteUpdate) {
+	r.updates = append(r.updates, update)
+}
+
+func (r *staticRouteRecorder) OnStaticRouteRemove(id string) {
+	r.removes = append(r.removes, id)
+}
+
+var _ = Describe("StaticRouteCalculator", func() {
+	var uut *calc.StaticRouteCalculator
+	var callbacks *staticRouteRecorder
+
+	configUpdate := func(key model.Key, value interface{}) api.Update {
+		return api.Update{
+			KVPair: model.KVPair{Key: key, Value: value},
+		}
+	}
+	globalRoutes := func(value interface{}) api.Update {
+		return configUpdate(model.GlobalConfigKey{Name: "StaticRoutes"}, value)
+	}
+	hostRoutes := func(hostname string, value interface{}) api.Update {
+		return configUpdate(model.HostConfigKey{Hostname: hostname, Name: "StaticRoutes"}, value)
+	}
+
+	BeforeEach(func() {
+		callbacks = &staticRouteRecorder{}
+		uut = calc.NewStaticRouteCalculator("host1", callbacks)
+	})
+
+	It("should send an update for each valid route", func() {
+		uut.OnUpdate(globalRoutes("dc2=10.1.0.0/16 via 192.168.0.1 table 100,bad=10.2.0.0/16"))
+		Expect(callbacks.updates).To(Equal([]*proto.StaticRouteUpdate{
+			{Id: "dc2", Dst: "10.1.0.0/16", NextHop: "192.168.0.1", Table: 100},
+		}))
+	})
+
+	It("should ignore other config and other hosts", func() {
+		uut.OnUpdate(configUpdate(model.GlobalConfigKey{Name: "LogSeverityScreen"}, "Debug"))
+		uut.OnUpdate(hostRoutes("host2", "dc2=10.1.0.0/16 dev eth1"))
+		Expect(callbacks.updates).To(BeEmpty())
+	})
+
+	It("should only send changes", func() {
+		uut.OnUpdate(globalRoutes("dc2=10.1.0.0/16 dev eth1,mgmt=172.16.0.0/12 dev eth2"))
+		callbacks.updates = nil
+		uut.OnUpdate(globalRoutes("dc2=10.1.0.0/16 dev eth1,mgmt=172.16.0.0/12 dev eth3"))
+		Expect(callbacks.updates).To(Equal([]*proto.StaticRouteUpdate{
+			{Id: "mgmt", Dst: "172.16.0.0/12", Device: "eth3"},
+		}))
+		Expect(callbacks.removes).To(BeEmpty())
+	})
+
+	It("should prefer the host's own value and fall back to the global value", func() {
+		uut.OnUpdate(globalRoutes("dc2=10.1.0.0/16 dev eth1"))
+		uut.OnUpdate(hostRoutes("host1", "mgmt=172.16.0.0/12 dev eth2"))
+		Expect(callbacks.removes).To(Equal([]string{"dc2"}))
+		Expect(callbacks.updates[len(callbacks.updates)-1].Id).To(Equal("mgmt"))
+
+		uut.OnUpdate(hostRoutes("host1", nil))
+		Expect(callbacks.removes).To(Equal([]string{"dc2", "mgmt"}))
+		Expect(callbacks.updates[len(callbacks.updates)-1].Id).To(Equal("dc2"))
+
+		uut.OnUpdate(globalRoutes(nil))
+		Expect(callbacks.removes).To(Equal([]string{"dc2", "mgmt", "dc2"}))
+	})
+})
@@ -53,12 +53,14 @@ var conntrackCmd = &cobra.Command{
 
 type conntrackDumpCmd struct {
 	*cobra.Command
+
+	IPv6 bool `docopt:"--ipv6"`
 }
 
 func newConntrackDumpCmd() *cobra.Command {
 	cmd := &conntrackDumpCmd{
 		Command: &cobra.Command{
-			Use:   "dump",
+			Use:   "dump [--ipv6]",
 			Short: "Dumps connection tracking table",
 		},
 	}
@@ -86,21 +88,19 @@ func (cmd *conntrackDumpCmd) Args(c *cobra.Command, args []string) error {
 func (cmd *conntrackDumpCmd) Run(c *cobra.Command, _ []string) {
 	mc := &bpf.MapContext{}
 	ctMap := conntrack.Map(mc)
+	keyFromBytes := func(k []byte) conntrack.KeyInterface { return conntrack.KeyFromBytes(k) }
+	valueFromBytes := func(v []byte) conntrack.ValueInterface { return conntrack.ValueFromBytes(v) }
+	if cmd.IPv6 {
+		ctMap = conntrack.MapV6(mc)
+		keyFromBytes = func(k []byte) conntrack.KeyInterface { return conntrack.KeyV6FromBytes(k) }
+		valueFromBytes = func(v []byte) conntrack.ValueInterface { return conntrack.ValueV6FromBytes(v) }
+	}
 	if err := ctMap.Open(); err != nil {
 		log.WithError(err).Fatal("Failed to access ConntrackMap")
 	}
 	err := ctMap.Iter(func(k, v []byte) bpf.IteratorAction {
-		var ctKey conntrack.Key
-		if len(k) != len(ctKey) {
-			log.Panic("Key has unexpected length")
-		}
-		copy(ctKey[:], k[:])
-
-		var ctVal conntrack.Value
-		if len(v) != len(ctVal) {
-			log.Panic("Value has unexpected length")
-		}
-		copy(ctVal[:], v[:])
+		ctKey := keyFromBytes(k)
+		ctVal := valueFromBytes(v)
 
 		fmt.Printf("%v -> %v", ctKey, ctVal)
 		dumpExtra(ctKey, ctVal)
@@ -112,7 +112,7 @@ func (cmd *conntrackDumpCmd) Run(c *cobra.Command, _ []string) {
 	}
 }
 
-func dumpExtra(k conntrack.Key, v conntrack.Value) {
+func dumpExtra(k conntrack.KeyInterface, v conntrack.ValueInterface) {
 	now := bpf.KTimeNanos()
 
 	fmt.Printf(" Age: %s Active ago %s",
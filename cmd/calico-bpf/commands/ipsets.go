@@ -15,11 +15,18 @@
 package commands
 
 import (
+	"bufio"
 	"fmt"
+	"io"
+	"os"
+	"os/exec"
 	"sort"
+	"strings"
 
 	"github.com/projectcalico/felix/bpf"
 	"github.com/projectcalico/felix/bpf/ipsets"
+	kernelipsets "github.com/projectcalico/felix/ipsets"
+	"github.com/projectcalico/felix/rules"
 
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
@@ -28,6 +35,7 @@ import (
 
 func init() {
 	ipsetsCmd.AddCommand(ipsetsDumpCmd)
+	ipsetsCmd.AddCommand(ipsetsCompareCmd)
 	rootCmd.AddCommand(ipsetsCmd)
 }
 
@@ -41,6 +49,23 @@ var ipsetsDumpCmd = &cobra.Command{
 	},
 }
 
+var ipsetsCompareCmd = &cobra.Command{
+	Use:   "compare",
+	Short: "compares the BPF IP sets with the equivalent kernel IP sets",
+	Long: "Compares the membership of each BPF IP set with the kernel IP set that Felix programs for " +
+		"the same set in iptables mode.  Useful for verifying a migration from iptables to BPF mode.",
+	Run: func(cmd *cobra.Command, args []string) {
+		ok, err := compareIPSets()
+		if err != nil {
+			log.WithError(err).Error("Failed to compare IP sets.")
+			os.Exit(2)
+		}
+		if !ok {
+			os.Exit(1)
+		}
+	},
+}
+
 // ipsetsCmd represents the ipsets command
 var ipsetsCmd = &cobra.Command{
 	Use:   "ipsets",
@@ -48,25 +73,7 @@ var ipsetsCmd = &cobra.Command{
 }
 
 func dumpIPSets() error {
-	ipsetMap := ipsets.Map(&bpf.MapContext{})
-
-	if err := ipsetMap.Open(); err != nil {
-		return errors.WithMessage(err, "failed to open map")
-	}
-
-	membersBySet := map[uint64][]string{}
-	err := ipsetMap.Iter(func(k, v []byte) bpf.IteratorAction {
-		var entry ipsets.IPSetEntry
-		copy(entry[:], k[:])
-		var member string
-		if entry.Protocol() == 0 {
-			member = fmt.Sprintf("%s/%d", entry.Addr(), entry.PrefixLen()-64)
-		} else {
-			member = fmt.Sprintf("%s:%d (proto %d)", entry.Addr(), entry.Port(), entry.Protocol())
-		}
-		membersBySet[entry.SetID()] = append(membersBySet[entry.SetID()], member)
-		return bpf.IterNone
-	})
+	membersBySet, err := loadBPFIPSets()
 	if err != nil {
 		return err
 	}
@@ -91,3 +98,206 @@ func dumpIPSets() error {
 
 	return nil
 }
+
+// loadBPFIPSets reads the BPF IP sets map and returns the formatted members of each IP set.
+func loadBPFIPSets() (map[uint64][]string, error) {
+	ipsetMap := ipsets.Map(&bpf.MapContext{})
+
+	if err := ipsetMap.Open(); err != nil {
+		return nil, errors.WithMessage(err, "failed to open map")
+	}
+
+	membersBySet := map[uint64][]string{}
+	err := ipsetMap.Iter(func(k, v []byte) bpf.IteratorAction {
+		var entry ipsets.IPSetEntry
+		copy(entry[:], k[:])
+		membersBySet[entry.SetID()] = append(membersBySet[entry.SetID()], formatIPSetEntry(entry))
+		return bpf.IterNone
+	})
+	if err != nil {
+		return nil, err
+	}
+	return membersBySet, nil
+}
+
+func formatIPSetEntry(entry ipsets.IPSetEntry) string {
+	if entry.Protocol() == 0 {
+		return fmt.Sprintf("%s/%d", entry.Addr(), entry.PrefixLen()-64)
+	}
+	return fmt.Sprintf("%s:%d (proto %d)", entry.Addr(), entry.Port(), entry.Protocol())
+}
+
+func compareIPSets() (bool, error) {
+	ids, err := ipsets.ReadIDRecord(ipsets.IDRecordFile)
+	if os.IsNotExist(err) {
+		return false, errors.New("no record of the BPF IP set IDs; is Felix running in BPF mode?")
+	} else if err != nil {
+		return false, err
+	}
+	bpfSets, err := loadBPFIPSets()
+	if err != nil {
+		return false, err
+	}
+
+	out, err := exec.Command("ipset", "save").Output()
+	if err != nil {
+		return false, errors.WithMessage(err, "failed to list kernel IP sets")
+	}
+	kernelSets, err := parseIPSetSave(strings.NewReader(string(out)))
+	if err != nil {
+		return false, err
+	}
+
+	ok := true
+	for _, c := range diffIPSets(ids, bpfSets, kernelSets) {
+		fmt.Println(c)
+		ok = ok && c.OK()
+	}
+	return ok, nil
+}
+
+// parseIPSetSave parses the output of "ipset save" and returns the members of each IP set.
+func parseIPSetSave(r io.Reader) (map[string][]string, error) {
+	membersBySet := map[string][]string{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[0] {
+		case "create":
+			if _, ok := membersBySet[fields[1]]; !ok {
+				membersBySet[fields[1]] = nil
+			}
+		case "add":
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("failed to parse ipset save line %q", scanner.Text())
+			}
+			membersBySet[fields[1]] = append(membersBySet[fields[1]], fields[2])
+		}
+	}
+	return membersBySet, scanner.Err()
+}
+
+// ipSetComparison is the result of comparing one BPF IP set with the equivalent kernel IP set.
+type ipSetComparison struct {
+	// ID is Felix's string ID for the IP set; "" if there is a BPF IP set that Felix didn't record.
+	ID         string
+	BPFID      uint64
+	KernelName string
+	InBPF      bool
+	InKernel   bool
+
+	OnlyInBPF    []string
+	OnlyInKernel []string
+	NumMembers   int
+}
+
+func (c ipSetComparison) OK() bool {
+	return c.InBPF && c.InKernel && len(c.OnlyInBPF) == 0 && len(c.OnlyInKernel) == 0
+}
+
+func (c ipSetComparison) String() string {
+	var desc string
+	switch {
+	case c.ID == "":
+		desc = fmt.Sprintf("BPF IP set %#x: no recorded ID", c.BPFID)
+	case !c.InBPF:
+		desc = fmt.Sprintf("IP set %s (kernel %s): not in BPF", c.ID, c.KernelName)
+	case !c.InKernel:
+		desc = fmt.Sprintf("IP set %s (BPF %#x): not in kernel, expected %s", c.ID, c.BPFID, c.KernelName)
+	case c.OK():
+		desc = fmt.Sprintf("IP set %s (BPF %#x, kernel %s): OK, %d members", c.ID, c.BPFID, c.KernelName, c.NumMembers)
+	default:
+		desc = fmt.Sprintf("IP set %s (BPF %#x, kernel %s): %d differences", c.ID, c.BPFID, c.KernelName,
+			len(c.OnlyInBPF)+len(c.OnlyInKernel))
+	}
+	for _, m := range c.OnlyInBPF {
+		desc += "\n   only in BPF:    " + m
+	}
+	for _, m := range c.OnlyInKernel {
+		desc += "\n   only in kernel: " + m
+	}
+	return desc
+}
+
+// diffIPSets compares the BPF IP sets with the kernel IP sets that have the same string IDs.  The
+// kernel members are converted to BPF entries before comparing so that both sides are formatted
+// the same way.  Kernel IP sets that don't correspond to a BPF IP set are only reported if they
+// look like they were created for a calculation graph IP set; iptables mode has other IP sets,
+// such as the all-hosts set, that BPF mode doesn't need.
+func diffIPSets(ids map[uint64]string, bpfSets map[uint64][]string, kernelSets map[string][]string) []ipSetComparison {
+	ipVersionConfig := kernelipsets.NewIPVersionConfig(kernelipsets.IPFamilyV4, rules.IPSetNamePrefix, nil, nil)
+	mainSetPrefix := ipVersionConfig.NameForMainIPSet("")
+
+	var comparisons []ipSetComparison
+	seenKernelSets := map[string]bool{}
+	for bpfID, id := range ids {
+		// Felix records the IDs of all the IP sets that it has in the map, even the empty ones,
+		// which have no entries.
+		c := ipSetComparison{
+			ID:         id,
+			BPFID:      bpfID,
+			KernelName: ipVersionConfig.NameForMainIPSet(id),
+			InBPF:      true,
+		}
+		bpfMembers := bpfSets[bpfID]
+		var kernelMembers []string
+		kernelMembers, c.InKernel = kernelSets[c.KernelName]
+		seenKernelSets[c.KernelName] = true
+
+		if c.InKernel {
+			kernelEntries := map[string]bool{}
+			for _, m := range kernelMembers {
+				entry := ipsets.ProtoIPSetMemberToBPFEntry(bpfID, m)
+				if entry == nil {
+					continue
+				}
+				kernelEntries[formatIPSetEntry(*entry)] = true
+			}
+			bpfEntries := map[string]bool{}
+			for _, m := range bpfMembers {
+				bpfEntries[m] = true
+				if !kernelEntries[m] {
+					c.OnlyInBPF = append(c.OnlyInBPF, m)
+				}
+			}
+			for m := range kernelEntries {
+				if !bpfEntries[m] {
+					c.OnlyInKernel = append(c.OnlyInKernel, m)
+				}
+			}
+			sort.Strings(c.OnlyInBPF)
+			sort.Strings(c.OnlyInKernel)
+			c.NumMembers = len(bpfEntries)
+		}
+		comparisons = append(comparisons, c)
+	}
+
+	for bpfID := range bpfSets {
+		if _, ok := ids[bpfID]; !ok {
+			comparisons = append(comparisons, ipSetComparison{BPFID: bpfID, InBPF: true})
+		}
+	}
+
+	for name := range kernelSets {
+		if seenKernelSets[name] || !strings.HasPrefix(name, mainSetPrefix) {
+			continue
+		}
+		rest := strings.TrimPrefix(name, mainSetPrefix)
+		if len(rest) < 2 || rest[1] != ':' {
+			// Not a calculation graph IP set ID.
+			continue
+		}
+		comparisons = append(comparisons, ipSetComparison{ID: rest, KernelName: name, InKernel: true})
+	}
+
+	sort.Slice(comparisons, func(i, j int) bool {
+		if comparisons[i].ID != comparisons[j].ID {
+			return comparisons[i].ID < comparisons[j].ID
+		}
+		return comparisons[i].BPFID < comparisons[j].BPFID
+	})
+	return comparisons
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+const ipsetSaveOutput = `create cali40s:abcdefghijklmnopqrstuvw hash:net family inet hashsize 1024 maxelem 1048576
+add cali40s:abcdefghijklmnopqrstuvw 10.65.0.0/26
+add cali40s:abcdefghijklmnopqrstuvw 10.65.1.1
+create cali40n:namedportabcdefghijklmn hash:ip,port family inet hashsize 1024 maxelem 1048576
+add cali40n:namedportabcdefghijklmn 10.65.0.2,tcp:8080
+create cali40s:emptyabcdefghijklmnopqr hash:net family inet hashsize 1024 maxelem 1048576
+create cali40s:kernelonlyabcdefghijkl hash:net family inet hashsize 1024 maxelem 1048576
+create cali40all-ipam-pools hash:net family inet hashsize 1024 maxelem 1048576
+add cali40all-ipam-pools 10.65.0.0/16
+`
+
+func TestParseIPSetSave(t *testing.T) {
+	RegisterTestingT(t)
+	sets, err := parseIPSetSave(strings.NewReader(ipsetSaveOutput))
+	Expect(err).NotTo(HaveOccurred())
+	Expect(sets).To(Equal(map[string][]string{
+		"cali40s:abcdefghijklmnopqrstuvw": {"10.65.0.0/26", "10.65.1.1"},
+		"cali40n:namedportabcdefghijklmn": {"10.65.0.2,tcp:8080"},
+		"cali40s:emptyabcdefghijklmnopqr": nil,
+		"cali40s:kernelonlyabcdefghijkl":  nil,
+		"cali40all-ipam-pools":            {"10.65.0.0/16"},
+	}))
+}
+
+func TestDiffIPSets(t *testing.T) {
+	RegisterTestingT(t)
+	kernelSets, err := parseIPSetSave(strings.NewReader(ipsetSaveOutput))
+	Expect(err).NotTo(HaveOccurred())
+
+	// The string IDs are longer than the kernel names, which are truncated.
+	ids := map[uint64]string{
+		1: "s:abcdefghijklmnopqrstuvwxyz0123456789AB",
+		2: "n:namedportabcdefghijklmnopqrstuvwxyz012",
+		3: "s:emptyabcdefghijklmnopqrstuvwxyz0123456",
+		4: "s:bpfonlyabcdefghijklmnopqrstuvwxyz01234",
+	}
+	bpfSets := map[uint64][]string{
+		1: {"10.65.0.0/26", "10.65.2.2/32"},
+		2: {"10.65.0.2:8080 (proto 6)"},
+		4: {"10.65.3.3/32"},
+		5: {"10.65.4.4/32"},
+	}
+
+	comparisons := diffIPSets(ids, bpfSets, kernelSets)
+	Expect(comparisons).To(Equal([]ipSetComparison{
+		{BPFID: 5, InBPF: true},
+		{
+			ID:         "n:namedportabcdefghijklmnopqrstuvwxyz012",
+			BPFID:      2,
+			KernelName: "cali40n:namedportabcdefghijklmn",
+			InBPF:      true,
+			InKernel:   true,
+			NumMembers: 1,
+		},
+		{
+			ID:           "s:abcdefghijklmnopqrstuvwxyz0123456789AB",
+			BPFID:        1,
+			KernelName:   "cali40s:abcdefghijklmnopqrstuvw",
+			InBPF:        true,
+			InKernel:     true,
+			OnlyInBPF:    []string{"10.65.2.2/32"},
+			OnlyInKernel: []string{"10.65.1.1/32"},
+			NumMembers:   2,
+		},
+		{
+			ID:         "s:bpfonlyabcdefghijklmnopqrstuvwxyz01234",
+			BPFID:      4,
+			KernelName: "cali40s:bpfonlyabcdefghijklmnop",
+			InBPF:      true,
+		},
+		{
+			ID:         "s:emptyabcdefghijklmnopqrstuvwxyz0123456",
+			BPFID:      3,
+			KernelName: "cali40s:emptyabcdefghijklmnopqr",
+			InBPF:      true,
+			InKernel:   true,
+		},
+		{
+			ID:         "s:kernelonlyabcdefghijkl",
+			KernelName: "cali40s:kernelonlyabcdefghijkl",
+			InKernel:   true,
+		},
+	}))
+
+	var ok []bool
+	for _, c := range comparisons {
+		ok = append(ok, c.OK())
+	}
+	Expect(ok).To(Equal([]bool{false, true, false, false, true, false}))
+	Expect(comparisons[2].String()).To(Equal(
+		"IP set s:abcdefghijklmnopqrstuvwxyz0123456789AB (BPF 0x1, kernel cali40s:abcdefghijklmnopqrstuvw): 2 differences\n" +
+			"   only in BPF:    10.65.2.2/32\n" +
+			"   only in kernel: 10.65.1.1/32"))
+}
@@ -30,7 +30,7 @@ import (
 )
 
 func init() {
-	natCmd.AddCommand(natDumpCmd)
+	natCmd.AddCommand(newNatDumpCmd())
 
 	natSetCmd.AddCommand(newNatSetFrontend())
 	natSetCmd.AddCommand(newNatSetBackend())
@@ -51,14 +51,48 @@ var natCmd = &cobra.Command{
 		"which implements the bpf-based replacement for kube-proxy",
 }
 
-var natDumpCmd = &cobra.Command{
-	Use:   "dump",
-	Short: "dumps the nat tables",
-	Run: func(cmd *cobra.Command, args []string) {
-		if err := dump(cmd); err != nil {
-			log.WithError(err).Error("Failed to dump NAT maps")
-		}
-	},
+type natDumpCmd struct {
+	*cobra.Command
+
+	IPv6 bool `docopt:"--ipv6"`
+}
+
+func newNatDumpCmd() *cobra.Command {
+	cmd := &natDumpCmd{
+		Command: &cobra.Command{
+			Use:   "dump [--ipv6]",
+			Short: "dumps the nat tables",
+		},
+	}
+
+	cmd.Command.Args = cmd.Args
+	cmd.Command.Run = cmd.Run
+
+	return cmd.Command
+}
+
+func (cmd *natDumpCmd) Args(c *cobra.Command, args []string) error {
+	a, err := docopt.ParseArgs(makeDocUsage(c), args, "")
+	if err != nil {
+		return errors.New(err.Error())
+	}
+
+	err = a.Bind(cmd)
+	if err != nil {
+		return errors.New(err.Error())
+	}
+
+	return nil
+}
+
+func (cmd *natDumpCmd) Run(c *cobra.Command, _ []string) {
+	dumpFn := dump
+	if cmd.IPv6 {
+		dumpFn = dumpV6
+	}
+	if err := dumpFn(c); err != nil {
+		log.WithError(err).Error("Failed to dump NAT maps")
+	}
 }
 
 var natSetCmd = &cobra.Command{
@@ -87,24 +121,60 @@ func dump(cmd *cobra.Command) error {
 	return nil
 }
 
+func dumpV6(cmd *cobra.Command) error {
+	mc := &bpf.MapContext{}
+	natMap, err := nat.LoadFrontendMapV6(nat.FrontendMapV6(mc))
+	if err != nil {
+		return err
+	}
+
+	back, err := nat.LoadBackendMapV6(nat.BackendMapV6(mc))
+	if err != nil {
+		return err
+	}
+
+	dumpNiceV6(cmd.Printf, natMap, back)
+	return nil
+}
+
 type printfFn func(format string, i ...interface{})
 
+type backendLookupFn func(k nat.BackendKey) (nat.BackendValueInterface, bool)
+
 func dumpNice(printf printfFn, natMap nat.MapMem, back nat.BackendMapMem) {
+	lookup := func(k nat.BackendKey) (nat.BackendValueInterface, bool) {
+		v, ok := back[k]
+		return v, ok
+	}
+	for nk, nv := range natMap {
+		dumpFrontend(printf, nk, nv, lookup)
+	}
+}
+
+func dumpNiceV6(printf printfFn, natMap nat.MapMemV6, back nat.BackendMapMemV6) {
+	lookup := func(k nat.BackendKey) (nat.BackendValueInterface, bool) {
+		v, ok := back[k]
+		return v, ok
+	}
 	for nk, nv := range natMap {
-		count := nv.Count()
-		local := nv.LocalCount()
-		id := nv.ID()
-		printf("%s port %d proto %d id %d count %d local %d\n",
-			nk.Addr(), nk.Port(), nk.Proto(), id, count, local)
-		for i := uint32(0); i < count; i++ {
-			bk := nat.NewNATBackendKey(id, uint32(i))
-			bv, ok := back[bk]
-			printf("\t%d:%d\t ", id, i)
-			if !ok {
-				printf("is missing\n")
-			} else {
-				printf("%s:%d\n", bv.Addr(), bv.Port())
-			}
+		dumpFrontend(printf, nk, nv, lookup)
+	}
+}
+
+func dumpFrontend(printf printfFn, nk nat.FrontendKeyInterface, nv nat.FrontendValue, back backendLookupFn) {
+	count := nv.Count()
+	local := nv.LocalCount()
+	id := nv.ID()
+	printf("%s port %d proto %d id %d count %d local %d\n",
+		nk.Addr(), nk.Port(), nk.Proto(), id, count, local)
+	for i := uint32(0); i < count; i++ {
+		bk := nat.NewNATBackendKey(id, uint32(i))
+		bv, ok := back(bk)
+		printf("\t%d:%d\t ", id, i)
+		if !ok {
+			printf("is missing\n")
+		} else {
+			printf("%s:%d\n", bv.Addr(), bv.Port())
 		}
 	}
 }
@@ -37,3 +37,23 @@ func TestNATDump(t *testing.T) {
 
 	dumpNice(func(format string, i ...interface{}) { fmt.Printf(format, i...) }, nat, back)
 }
+
+func TestNATDumpV6(t *testing.T) {
+	nat := nat2.MapMemV6{
+		nat2.NewNATKeyV6(net.ParseIP("fd00::1"), 80, 6): nat2.NewNATValue(35, 2, 0, 0),
+	}
+
+	back := nat2.BackendMapMemV6{
+		nat2.NewNATBackendKey(35, 0): nat2.NewNATBackendValueV6(net.ParseIP("fd00::5"), 8080),
+	}
+
+	var out string
+	dumpNiceV6(func(format string, i ...interface{}) { out += fmt.Sprintf(format, i...) }, nat, back)
+
+	expected := "fd00::1 port 80 proto 6 id 35 count 2 local 0\n" +
+		"\t35:0\t fd00::5:8080\n" +
+		"\t35:1\t is missing\n"
+	if out != expected {
+		t.Errorf("Unexpected dump output:\n%s\nexpected:\n%s", out, expected)
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package commands
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
+
+	"github.com/projectcalico/felix/bpf"
+	"github.com/projectcalico/felix/bpf/polprog"
+	"github.com/projectcalico/felix/bpf/tc"
+)
+
+func init() {
+	policyCmd.AddCommand(policyVerifyCmd)
+	rootCmd.AddCommand(policyCmd)
+}
+
+// policyCmd represents the policy command
+var policyCmd = &cobra.Command{
+	Use:   "policy",
+	Short: "Inspects policy programs",
+}
+
+var policyVerifyCmd = &cobra.Command{
+	Use:   "verify <interface>",
+	Short: "checks that the policy programs attached to an interface are the ones Felix last programmed",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		stale, err := verifyPolicy(args[0])
+		if err != nil {
+			log.WithError(err).Error("Failed to verify policy programs.")
+			os.Exit(2)
+		}
+		if stale {
+			os.Exit(1)
+		}
+	},
+}
+
+// verifyPolicy compares the policy program in the jump map of each of the interface's TC programs
+// with Felix's record of the program it put there.  Returns true if any of them are stale.
+func verifyPolicy(iface string) (bool, error) {
+	stale := false
+	for _, hook := range []tc.Hook{tc.HookIngress, tc.HookEgress} {
+		status, ok, err := verifyPolicyHook(iface, hook)
+		if err != nil {
+			return false, errors.WithMessagef(err, "failed to verify %s policy program", hook)
+		}
+		fmt.Printf("%s %-7s: %s\n", iface, hook, status)
+		if !ok {
+			stale = true
+		}
+	}
+	return stale, nil
+}
+
+func verifyPolicyHook(iface string, hook tc.Hook) (status string, ok bool, err error) {
+	rec, err := polprog.ReadAttachmentRecord(polprog.AttachmentRecordDir, iface, string(hook))
+	if os.IsNotExist(err) {
+		// Felix doesn't program policy on this hook (or this interface).
+		return "OK (no policy program recorded)", true, nil
+	} else if err != nil {
+		return "", false, err
+	}
+
+	jumpMapFD, err := bpf.GetMapFDByID(rec.JumpMapID)
+	if err != nil {
+		return fmt.Sprintf("STALE (jump map %d no longer exists)", rec.JumpMapID), false, nil
+	}
+	defer jumpMapFD.Close()
+
+	attached, err := jumpMapIsAttached(iface, hook, rec.JumpMapID)
+	if err != nil {
+		return "", false, err
+	}
+	if !attached {
+		return fmt.Sprintf("STALE (no program on the hook uses jump map %d)", rec.JumpMapID), false, nil
+	}
+
+	// From userspace, the value of a program array entry is the ID of the program.
+	v, err := bpf.GetMapEntry(jumpMapFD, make([]byte, 4), 4)
+	if err == unix.ENOENT {
+		if rec.PolicyHash == "" {
+			return "OK (no policy program)", true, nil
+		}
+		return fmt.Sprintf("STALE (expected policy %s but jump map is empty)", rec.PolicyHash), false, nil
+	} else if err != nil {
+		return "", false, errors.WithMessage(err, "failed to read jump map")
+	}
+	progID := int(binary.LittleEndian.Uint32(v))
+
+	hash, err := attachedPolicyHash(progID)
+	if err != nil {
+		return "", false, err
+	}
+	switch {
+	case hash == rec.PolicyHash:
+		return fmt.Sprintf("OK (policy %s, program %d)", hash, progID), true, nil
+	case rec.PolicyHash == "":
+		return fmt.Sprintf("STALE (expected no policy but found program %d)", progID), false, nil
+	case hash == "":
+		return fmt.Sprintf("STALE (expected policy %s but program %d has no policy hash)",
+			rec.PolicyHash, progID), false, nil
+	default:
+		return fmt.Sprintf("STALE (expected policy %s but program %d has policy %s)",
+			rec.PolicyHash, progID, hash), false, nil
+	}
+}
+
+// attachedPolicyHash dumps the program and returns the policy hash that it embeds or "" if it
+// doesn't have one.
+func attachedPolicyHash(progID int) (string, error) {
+	dir, err := ioutil.TempDir("", "calico-bpf")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "prog")
+	out, err := exec.Command("bpftool", "prog", "dump", "xlated", "id", strconv.Itoa(progID),
+		"file", file).CombinedOutput()
+	if err != nil {
+		return "", errors.Errorf("failed to dump program %d: %v: %s", progID, err, out)
+	}
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return "", err
+	}
+	insns, err := polprog.ParseInsns(raw)
+	if err != nil {
+		return "", err
+	}
+	hash, _ := polprog.EmbeddedPolicyHash(insns)
+	return hash, nil
+}
+
+// jumpMapIsAttached returns true if one of the TC programs on the hook of the interface uses the
+// jump map.
+func jumpMapIsAttached(iface string, hook tc.Hook, jumpMapID int) (bool, error) {
+	out, err := exec.Command("bpftool", "net", "show", "dev", iface, "-j").Output()
+	if err != nil {
+		return false, errors.WithMessage(err, "failed to list attached programs")
+	}
+	var attached []struct {
+		TC []struct {
+			Kind string `json:"kind"`
+			ID   int    `json:"id"`
+		} `json:"tc"`
+	}
+	if err := json.Unmarshal(out, &attached); err != nil {
+		return false, errors.WithMessage(err, "failed to parse list of attached programs")
+	}
+
+	for _, a := range attached {
+		for _, p := range a.TC {
+			if p.Kind != "clsact/"+string(hook) {
+				continue
+			}
+			out, err := exec.Command("bpftool", "prog", "show", "id", strconv.Itoa(p.ID), "--json").Output()
+			if err != nil {
+				return false, errors.WithMessagef(err, "failed to show program %d", p.ID)
+			}
+			var prog struct {
+				Maps []int `json:"map_ids"`
+			}
+			if err := json.Unmarshal(out, &prog); err != nil {
+				return false, errors.WithMessagef(err, "failed to parse program %d", p.ID)
+			}
+			for _, id := range prog.Maps {
+				if id == jumpMapID {
+					return true, nil
+				}
+			}
+		}
+	}
+	return false, nil
+}
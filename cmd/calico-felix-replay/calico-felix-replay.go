@@ -0,0 +1,168 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	docopt "github.com/docopt/docopt-go"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/libcalico-go/lib/health"
+
+	"github.com/projectcalico/felix/buildinfo"
+	"github.com/projectcalico/felix/config"
+	dp "github.com/projectcalico/felix/dataplane"
+	"github.com/projectcalico/felix/dataplane/recorder"
+	"github.com/projectcalico/felix/logutils"
+	"github.com/projectcalico/felix/proto"
+)
+
+const usage = `Replays a recording of the messages that Felix sent to its dataplane driver
+(see the DebugDataplaneRecordFile config parameter) into a fresh dataplane driver.
+
+The driver programs the dataplane of the network namespace that this command runs in so
+run it in a scratch namespace, for example:
+
+  ip netns add replay
+  ip netns exec replay calico-felix-replay felix.rec
+
+The driver is configured from the config in the recording, overridden by any FELIX_...
+environment variables.
+
+Usage:
+  calico-felix-replay [options] <recording>
+
+Options:
+  --speed=<factor>     Replay at this multiple of the recorded rate; 0 replays as fast
+                       as possible [default: 1].
+  --allow-host-netns   Allow replaying into the host's network namespace.
+  --exit               Exit once the recording has been sent instead of leaving the
+                       dataplane driver running until interrupted.
+  --version            Print the version and exit.
+`
+
+func main() {
+	logutils.ConfigureEarlyLogging()
+
+	version := "Version:            " + buildinfo.GitVersion + "\n" +
+		"Full git commit ID: " + buildinfo.GitRevision + "\n" +
+		"Build date:         " + buildinfo.BuildDate + "\n"
+	arguments, err := docopt.ParseArgs(usage, nil, version)
+	if err != nil {
+		println(usage)
+		log.Fatalf("Failed to parse usage, exiting: %v", err)
+	}
+	speed, err := strconv.ParseFloat(arguments["--speed"].(string), 64)
+	if err != nil || speed < 0 {
+		log.Fatalf("Invalid --speed %q, expected a non-negative number", arguments["--speed"])
+	}
+
+	if !arguments["--allow-host-netns"].(bool) && inHostNetns() {
+		log.Fatal("Refusing to replay into the host's network namespace; run under " +
+			"'ip netns exec' or pass --allow-host-netns.")
+	}
+
+	fileName := arguments["<recording>"].(string)
+	f, err := os.Open(fileName)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to open recording")
+	}
+	defer f.Close()
+	reader, err := recorder.NewReader(f)
+	if err != nil {
+		log.WithError(err).Fatal("Failed to read recording")
+	}
+
+	// Felix always sends its config first; we need it to create the driver.
+	first, err := reader.Next()
+	if err != nil {
+		log.WithError(err).Fatal("Failed to read first message from recording")
+	}
+	configUpdate, ok := first.Msg.(*proto.ConfigUpdate)
+	if !ok {
+		log.WithField("msg", first.Msg).Fatal("Recording doesn't start with a config update")
+	}
+	configParams := config.New()
+	if _, err := configParams.UpdateFrom(configUpdate.Config, config.ConfigFile); err != nil {
+		log.WithError(err).Fatal("Failed to load config from recording")
+	}
+	envConfig := config.LoadConfigFromEnvironment(os.Environ())
+	if _, err := configParams.UpdateFrom(envConfig, config.EnvironmentVariable); err != nil {
+		log.WithError(err).Fatal("Failed to load config from environment")
+	}
+
+	driver, driverCmd := dp.StartDataplaneDriver(
+		configParams,
+		health.NewHealthAggregator(),
+		func() {
+			log.Warn("Dataplane driver asked for a restart due to a config change; ignoring during replay.")
+		},
+		func(err error) {
+			log.WithError(err).Fatal("Dataplane driver reported a fatal error")
+		},
+		nil,
+	)
+	if driverCmd != nil {
+		defer func() { _ = driverCmd.Process.Kill() }()
+	}
+	go func() {
+		for {
+			msg, err := driver.RecvMessage()
+			if err != nil {
+				log.WithError(err).Fatal("Failed to read from dataplane driver")
+			}
+			log.WithField("msg", msg).Info("Message from dataplane driver.")
+		}
+	}()
+
+	log.WithFields(log.Fields{
+		"file":     fileName,
+		"recorded": first.Time,
+		"speed":    speed,
+	}).Info("Replaying recording.")
+	if err := driver.SendMessage(configUpdate); err != nil {
+		log.WithError(err).Fatal("Failed to send config to dataplane driver")
+	}
+	numSent, err := recorder.NewReplayer(reader, driver, speed).Replay()
+	if err != nil {
+		log.WithError(err).WithField("numSent", numSent+1).Fatal("Replay failed")
+	}
+	log.WithField("numSent", numSent+1).Info("Finished replaying recording.")
+
+	if arguments["--exit"].(bool) {
+		return
+	}
+	log.Info("Dataplane driver still running, interrupt to exit.")
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	<-sigChan
+}
+
+// inHostNetns returns true if we share the network namespace of PID 1.
+func inHostNetns() bool {
+	ours, err := os.Readlink("/proc/self/ns/net")
+	if err != nil {
+		log.WithError(err).Fatal("Failed to read our network namespace")
+	}
+	host, err := os.Readlink("/proc/1/ns/net")
+	if err != nil {
+		log.WithError(err).Fatal("Failed to read the host's network namespace")
+	}
+	return ours == host
+}
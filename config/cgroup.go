@@ -0,0 +1,121 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// These must match the constants in bpf/cgroup.go; see bpfMarksMask for why we can't import them.
+const (
+	cgroupV2RootAuto    = "auto"
+	cgroupV2RootPrivate = "private"
+	cgroupV2PrivatePath = "/run/calico/cgroup"
+)
+
+// cgroupV2Check is a shim to allow the tests to avoid depending on the host's mounts.
+var cgroupV2Check = checkCgroupV2
+
+// checkCgroupV2 returns an error if the connect-time load balancer won't be able to find a
+// cgroup v2 mount, given the BPFCgroupV2Root and BPFAutoMountEnabled settings.
+func checkCgroupV2(root string, autoMount bool) error {
+	mountinfo, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return err
+	}
+	defer mountinfo.Close()
+	filesystems, err := os.Open("/proc/filesystems")
+	if err != nil {
+		return err
+	}
+	defer filesystems.Close()
+	return cgroupV2Problem(root, autoMount, mountinfo, filesystems)
+}
+
+// cgroupV2Problem is the testable core of checkCgroupV2; it takes the content of
+// /proc/self/mountinfo and /proc/filesystems.
+func cgroupV2Problem(root string, autoMount bool, mountinfo, filesystems io.Reader) error {
+	mountPoints, err := cgroupV2MountPoints(mountinfo)
+	if err != nil {
+		return err
+	}
+
+	switch root {
+	case "", cgroupV2RootAuto, cgroupV2RootPrivate:
+		if root == cgroupV2RootAuto && len(mountPoints) > 0 {
+			// FindCgroupV2Root will use (or at least consider) an existing mount.
+			return nil
+		}
+		if mountPoints[cgroupV2PrivatePath] {
+			return nil
+		}
+		if !autoMount {
+			return fmt.Errorf("no cgroup v2 mount found and BPFAutoMountEnabled is false")
+		}
+		supported, err := kernelSupportsCgroupV2(filesystems)
+		if err != nil {
+			return err
+		}
+		if !supported {
+			return fmt.Errorf("kernel does not support cgroup v2")
+		}
+		return nil
+	default:
+		if !mountPoints[filepath.Clean(root)] {
+			return fmt.Errorf("%s is not a cgroup v2 mount", root)
+		}
+		return nil
+	}
+}
+
+// cgroupV2MountPoints parses the given mountinfo and returns the set of cgroup2 mount points.
+func cgroupV2MountPoints(mountinfo io.Reader) (map[string]bool, error) {
+	mountPoints := map[string]bool{}
+	sc := bufio.NewScanner(mountinfo)
+	for sc.Scan() {
+		// Format: <id> <parent> <major:minor> <root> <mount point> <options> <optional fields>... - <fs type> <source> <super options>
+		line := sc.Text()
+		parts := strings.SplitN(line, " - ", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("failed to parse mountinfo line %q", line)
+		}
+		columns := strings.Split(parts[0], " ")
+		if len(columns) < 5 {
+			return nil, fmt.Errorf("not enough fields from mountinfo line %q", line)
+		}
+		if strings.Split(parts[1], " ")[0] != "cgroup2" {
+			continue
+		}
+		mountPoints[filepath.Clean(columns[4])] = true
+	}
+	return mountPoints, sc.Err()
+}
+
+// kernelSupportsCgroupV2 parses the given /proc/filesystems and returns true if it lists cgroup2.
+func kernelSupportsCgroupV2(filesystems io.Reader) (bool, error) {
+	sc := bufio.NewScanner(filesystems)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) > 0 && fields[len(fields)-1] == "cgroup2" {
+			return true, nil
+		}
+	}
+	return false, sc.Err()
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/config"
+)
+
+const (
+	mountinfoNoCgroupV2 = `22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw
+25 22 0:23 / /sys rw,nosuid,nodev,noexec,relatime shared:7 - sysfs sysfs rw
+`
+	mountinfoHostCgroupV2 = mountinfoNoCgroupV2 +
+		`30 25 0:26 / /sys/fs/cgroup rw,nosuid,nodev,noexec,relatime shared:9 - cgroup2 cgroup2 rw
+`
+	mountinfoPrivateCgroupV2 = mountinfoNoCgroupV2 +
+		`40 22 0:26 / /run/calico/cgroup rw,relatime - cgroup2 none rw
+`
+	filesystemsNoCgroupV2 = "nodev\tsysfs\nnodev\tcgroup\n\text4\n"
+	filesystemsCgroupV2   = filesystemsNoCgroupV2 + "nodev\tcgroup2\n"
+)
+
+var _ = DescribeTable("cgroup v2 check",
+	func(root string, autoMount bool, mountinfo, filesystems string, expectedErr string) {
+		err := config.CgroupV2Problem(root, autoMount, strings.NewReader(mountinfo), strings.NewReader(filesystems))
+		if expectedErr == "" {
+			Expect(err).NotTo(HaveOccurred())
+		} else {
+			Expect(err).To(MatchError(expectedErr))
+		}
+	},
+	Entry("auto with host mount", "auto", false, mountinfoHostCgroupV2, filesystemsNoCgroupV2, ""),
+	Entry("auto, no mount, auto-mount", "auto", true, mountinfoNoCgroupV2, filesystemsCgroupV2, ""),
+	Entry("auto, no mount, no auto-mount", "auto", false, mountinfoNoCgroupV2, filesystemsCgroupV2,
+		"no cgroup v2 mount found and BPFAutoMountEnabled is false"),
+	Entry("auto, no mount, no kernel support", "auto", true, mountinfoNoCgroupV2, filesystemsNoCgroupV2,
+		"kernel does not support cgroup v2"),
+	Entry("private with host mount only", "private", false, mountinfoHostCgroupV2, filesystemsCgroupV2,
+		"no cgroup v2 mount found and BPFAutoMountEnabled is false"),
+	Entry("private, already mounted", "private", false, mountinfoPrivateCgroupV2, filesystemsNoCgroupV2, ""),
+	Entry("private, auto-mount", "private", true, mountinfoNoCgroupV2, filesystemsCgroupV2, ""),
+	Entry("explicit path", "/sys/fs/cgroup/", false, mountinfoHostCgroupV2, filesystemsNoCgroupV2, ""),
+	Entry("explicit path that isn't cgroup v2", "/sys", true, mountinfoHostCgroupV2, filesystemsCgroupV2,
+		"/sys is not a cgroup v2 mount"),
+)
@@ -48,8 +48,13 @@ var (
 	HostnameRegexp           = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
 	StringRegexp             = regexp.MustCompile(`^.*$`)
 	IfaceParamRegexp         = regexp.MustCompile(`^[a-zA-Z0-9:._+-]{1,15}$`)
+	// NamePrefixRegexp matches a chain, rule hash or IP set name prefix; it must be short so that
+	// the longest names that Felix generates still fit within the kernel's limits.
+	NamePrefixRegexp = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9:_-]{0,4}$`)
 	// Hostname  have to be valid ipv4, ipv6 or strings up to 64 characters.
 	HostAddressRegexp = regexp.MustCompile(`^[a-zA-Z0-9:._+-]{1,64}$`)
+	// LabelRegexp matches a Kubernetes-style label key, optionally followed by "=" and a value.
+	LabelRegexp = regexp.MustCompile(`^[a-zA-Z0-9]([-a-zA-Z0-9_./]*[a-zA-Z0-9])?(=([a-zA-Z0-9]([-a-zA-Z0-9_.]*[a-zA-Z0-9])?)?)?$`)
 )
 
 const (
@@ -157,6 +162,174 @@ type Config struct {
 	// Configuration parameters.
 	UseInternalDataplaneDriver bool   `config:"bool;true"`
 	DataplaneDriver            string `config:"file(must-exist,executable);calico-iptables-plugin;non-zero,die-on-fail,skip-default-validation"`
+	// DataplaneDriverHandshakeTimeout is how long to wait for an external dataplane driver to reply
+	// to the capabilities handshake before assuming that it predates the handshake.  0 disables the
+	// handshake.
+	DataplaneDriverHandshakeTimeout time.Duration `config:"seconds;5"`
+
+	// CNIReadinessGateEnabled makes Felix hold off reporting ready, and keep blocking new
+	// connections from workload interfaces, until the CNI plugin has been installed; i.e. until
+	// CNINetDir contains a network config and CNIBinDir contains all of CNIPluginBinaries.
+	CNIReadinessGateEnabled bool   `config:"bool;false"`
+	CNINetDir               string `config:"file;/etc/cni/net.d"`
+	CNIBinDir               string `config:"file;/opt/cni/bin"`
+	// CNIPluginBinaries is a comma-separated list of the plugin binaries to look for in CNIBinDir.
+	CNIPluginBinaries string `config:"string;calico,calico-ipam"`
+
+	// WorkloadDrainEnabled puts the node's workloads into drain mode: new connections to workloads
+	// are rejected while established connections are allowed to complete.  Drain mode is also
+	// entered while WorkloadDrainFile exists, allowing it to be toggled without a config change.
+	// While draining, Felix polls conntrack every WorkloadDrainPollInterval and reports the number
+	// of connections that are still active.
+	WorkloadDrainEnabled      bool          `config:"bool;false"`
+	WorkloadDrainFile         string        `config:"file;;"`
+	WorkloadDrainPollInterval time.Duration `config:"seconds;5"`
+
+	// DropCaptureEnabled makes Felix log the headers of packets that are dropped by policy to
+	// NFLOG group DropCaptureNFLOGGroup.  Felix keeps the last DropCaptureBufferSize packets,
+	// truncated to DropCaptureSnapLength bytes, and serves them on the Prometheus metrics port at
+	// /debug/drop-capture, annotated with the policy rule that dropped them.
+	DropCaptureEnabled    bool `config:"bool;false"`
+	DropCaptureNFLOGGroup int  `config:"int(1,65535);20"`
+	DropCaptureBufferSize int  `config:"int(1,100000);1000"`
+	DropCaptureSnapLength int  `config:"int(20,65535);128"`
+
+	// ConntrackAccountingEnabled makes Felix count the conntrack entries for each local workload
+	// endpoint every ConntrackAccountingInterval and report the counts, and those of the
+	// ConntrackAccountingTopN busiest endpoints, as Prometheus gauges.
+	ConntrackAccountingEnabled  bool          `config:"bool;false"`
+	ConntrackAccountingInterval time.Duration `config:"seconds;30"`
+	ConntrackAccountingTopN     int           `config:"int(1,1000);10"`
+
+	// FlowLogsEnabled makes Felix sample the conntrack table every FlowLogsFlushInterval and write
+	// a summary of the connections to and from local workloads to FlowLogsSink: either
+	// FlowLogsFileName, as JSON lines, the local syslog daemon, or a gRPC collector at
+	// FlowLogsGRPCAddr.  In BPF mode, the connections are read from the BPF conntrack map, which
+	// doesn't count packets and bytes.
+	FlowLogsEnabled       bool          `config:"bool;false"`
+	FlowLogsFlushInterval time.Duration `config:"seconds;300"`
+	FlowLogsSink          string        `config:"oneof(file,syslog,grpc);file"`
+	FlowLogsFileName      string        `config:"file;/var/log/calico/flowlogs/flows.log"`
+	FlowLogsGRPCAddr      string        `config:"authority;"`
+
+	// DebugRouteTablesEnabled makes Felix serve, on the Prometheus metrics port at
+	// /debug/route-tables, the desired and programmed routes for each interface that it manages,
+	// along with the reason that any outstanding route changes haven't been applied.
+	DebugRouteTablesEnabled bool `config:"bool;false"`
+
+	// DebugBPFMemoryEnabled makes Felix serve, on the Prometheus metrics port at
+	// /debug/bpf-memory, its estimate of the kernel memory used by each BPF map and by the BPF
+	// programs, grouped by feature.  The same estimates are always exported as metrics in BPF mode.
+	DebugBPFMemoryEnabled bool `config:"bool;false"`
+
+	// DataplaneManagerFailureBudget is the number of consecutive times that one of the internal
+	// dataplane's managers may fail to program its part of the dataplane before Felix reports
+	// itself non-ready, logging the name of the manager.  0 disables the check.
+	DataplaneManagerFailureBudget int `config:"int(0,1000000);0"`
+
+	// DataplaneMaxMsgBatchSize is the maximum number of updates that the internal dataplane reads
+	// from one of its input channels before applying them; it is also the capacity of those
+	// channels.  The batch size starts at 100 and grows towards this limit while a backlog
+	// persists, for example during the initial sync of a large cluster, then shrinks again once
+	// the dataplane has caught up.
+	DataplaneMaxMsgBatchSize int `config:"int(100,100000);1000"`
+
+	// DataplaneCPUBudget, if non-zero, is the share of a core (for example, 0.5 for half a core)
+	// that Felix aims to stay under.  While Felix's CPU usage is over the budget, the internal
+	// dataplane puts off non-urgent work, such as the periodic IP set and route resyncs and
+	// conntrack scans, for a limited number of times.  Urgent work, such as applying policy
+	// changes, is never deferred.
+	DataplaneCPUBudget float64 `config:"float;0"`
+
+	// The following parameters tune the OS thread that runs the internal dataplane's main loop,
+	// and the iptables-restore, nft and ipset processes that the dataplane starts, so that Felix doesn't
+	// contend with latency-critical work, such as DPDK, on real-time nodes.  DataplaneNice is the
+	// nice value.  DataplaneIOPriorityClass and DataplaneIOPriorityLevel set the I/O priority, as
+	// with ionice; Default leaves it alone.  DataplaneSchedPolicy and DataplaneSchedPriority set
+	// the scheduling policy; the FIFO and RR real-time policies need a priority of 1-99, the
+	// others a priority of 0.  DataplaneCPUAffinity, if set, is the list of CPUs (for example,
+	// "0-3,8") that the thread may run on.
+	DataplaneNice            int    `config:"int(-20,19);0"`
+	DataplaneIOPriorityClass string `config:"oneof(Default,RealTime,BestEffort,Idle);Default"`
+	DataplaneIOPriorityLevel int    `config:"int(0,7);4"`
+	DataplaneSchedPolicy     string `config:"oneof(Other,Batch,Idle,FIFO,RR);Other"`
+	DataplaneSchedPriority   int    `config:"int(0,99);0"`
+	DataplaneCPUAffinity     []int  `config:"cpu-list;"`
+
+	// DataplaneDryRunReportFile, if set, puts the dataplane in dry-run mode: Felix calculates the
+	// changes that it would make to iptables, IP sets and routes, but writes them to this file
+	// (one JSON object per change) instead of applying them, so that operators can preview
+	// Felix's changes to a node before letting it enforce policy.  Other changes, such as
+	// sysctls and tunnel devices, are still made.  "<timestamp>" in the name is replaced with
+	// Felix's start time.  Not supported in BPF or nftables mode.
+	DataplaneDryRunReportFile string `config:"file;;local"`
+
+	// WorkloadConnRateLimitEnabled limits the rate at which each workload can open new
+	// connections to WorkloadConnRateLimit per second, after an initial burst of
+	// WorkloadConnRateLimitBurst.  A workload can override the rate with the
+	// projectcalico.org/connection-rate-limit label.  A rate of 0 means no limit so, by default,
+	// only workloads with the label are limited.
+	WorkloadConnRateLimitEnabled bool `config:"bool;false"`
+	WorkloadConnRateLimit        int  `config:"int(0,10000);0"`
+	WorkloadConnRateLimitBurst   int  `config:"int(1,10000);50"`
+
+	// WorkloadAllowedSourcesEnabled lets workloads send from the additional source prefixes in
+	// their endpoint's AllowedSourcePrefixes (for example, VMs that own extra addresses), which
+	// the anti-spoofing RPF check would otherwise drop.
+	WorkloadAllowedSourcesEnabled bool `config:"bool;false"`
+
+	// WorkloadEgressAllowlistEnabled gives integrations, such as DNS policy controllers, a
+	// supported way to allow workloads' egress traffic to extra destinations.  Felix creates an
+	// IP set for each local workload, named cali40ea:<interface name> (cali60ea: for IPv6),
+	// with room for WorkloadEgressAllowlistMaxSize entries; it deletes the IP set when the
+	// workload goes away.  The integration manages the IP set's members.  Traffic from the
+	// workload to a member is allowed if policy would otherwise drop it by default; explicit
+	// deny rules still apply.  Not supported in BPF or nftables mode.
+	WorkloadEgressAllowlistEnabled bool `config:"bool;false"`
+	WorkloadEgressAllowlistMaxSize int  `config:"int(1,1048576);1024"`
+
+	// EndpointToHostActionOverridesEnabled lets workloads override DefaultEndpointToHostAction
+	// with the projectcalico.org/endpoint-to-host-action label; for example, to accept traffic
+	// from a trusted logging agent while dropping traffic from other workloads.
+	EndpointToHostActionOverridesEnabled bool `config:"bool;false"`
+
+	// WorkloadMACEnforcement drops frames from workloads that don't come from their workload's
+	// MAC address: "Enabled" checks all workloads, "PerEndpoint" only those with the
+	// projectcalico.org/enforce-mac=true label.  In "Enabled" mode, workloads can opt out with
+	// projectcalico.org/enforce-mac=false.
+	WorkloadMACEnforcement string `config:"oneof(Disabled,PerEndpoint,Enabled);Disabled"`
+
+	// WorkloadInterfaceRPFilter, WorkloadInterfaceAcceptRA and WorkloadInterfaceTXChecksumOffload
+	// choose which settings of the host side of workload interfaces Felix owns, rather than
+	// leaving them to the CNI plugin.  Felix reapplies the settings that it owns whenever the
+	// workload's endpoint changes.  "Auto" turns off TX checksum offload only on kernels whose
+	// offload corrupts VXLAN packets.
+	WorkloadInterfaceRPFilter          string `config:"oneof(DoNothing,Disabled,Strict,Loose);DoNothing"`
+	WorkloadInterfaceAcceptRA          string `config:"oneof(DoNothing,Disabled);Disabled"`
+	WorkloadInterfaceTXChecksumOffload string `config:"oneof(DoNothing,Disabled,Auto);DoNothing"`
+
+	// WorkloadLinkLocalAccess controls workloads' access to the link-local services in
+	// WorkloadLinkLocalServices, such as the cloud metadata service, regardless of policy:
+	// "Allow" accepts the traffic and "Deny" drops it before any policy is applied.  Workloads
+	// in the namespaces in the comma-separated WorkloadLinkLocalExceptionNamespaces are left to
+	// their policy.  Host endpoint policy still applies.
+	WorkloadLinkLocalAccess              string   `config:"oneof(DoNothing,Allow,Deny);DoNothing"`
+	WorkloadLinkLocalServices            []string `config:"cidr-list;169.254.169.254/32"`
+	WorkloadLinkLocalExceptionNamespaces string   `config:"string;"`
+
+	// NeighborGCTuningEnabled sizes the kernel's neighbor (ARP/NDP) table garbage collection
+	// thresholds (gc_thresh1/2/3) from the number of local workloads and other hosts, so that the
+	// table doesn't overflow on dense nodes.  Felix takes over those settings when enabled.  If
+	// NeighborGCStaleTime is non-zero, Felix also sets gc_stale_time on workload interfaces.
+	NeighborGCTuningEnabled bool          `config:"bool;false"`
+	NeighborGCStaleTime     time.Duration `config:"seconds;0"`
+
+	// PolicyRuleCountersEnabled exports the number of packets and bytes that matched each rule of
+	// each active policy as the felix_policy_packets_total and felix_policy_bytes_total metrics.
+	// The counters are read from iptables at most once per PolicyRuleCountersInterval.  Not
+	// supported in BPF or nftables mode.
+	PolicyRuleCountersEnabled  bool          `config:"bool;false"`
+	PolicyRuleCountersInterval time.Duration `config:"seconds;10"`
 
 	// Wireguard configuration
 	WireguardEnabled               bool   `config:"bool;false"`
@@ -165,6 +338,28 @@ type Config struct {
 	WireguardInterfaceName         string `config:"iface-param;wireguard.cali;non-zero"`
 	WireguardMTU                   int    `config:"int;0"`
 	WireguardHostEncryptionEnabled bool   `config:"bool;false"`
+	// WireguardEncryptionScope limits which peers traffic is encrypted to: "All" peers,
+	// "CrossSubnet" peers (those whose node IP is outside this host's subnet) or "Labelled"
+	// peers (all peers if this host's Node resource carries WireguardEncryptionLabel, otherwise
+	// those whose Node resource carries it).  Traffic to other peers uses plain routes.
+	WireguardEncryptionScope string `config:"oneof(All,CrossSubnet,Labelled);All"`
+	// WireguardEncryptionLabel is the label, in the form "key" or "key=value", that selects
+	// the peers to encrypt to when WireguardEncryptionScope is "Labelled".
+	WireguardEncryptionLabel string `config:"label;"`
+	// WireguardPersistentKeepAlive is the persistent-keepalive interval for all wireguard peers;
+	// 0 disables keepalives.
+	WireguardPersistentKeepAlive time.Duration `config:"seconds;0"`
+	// WireguardNATPersistentKeepAlive is the persistent-keepalive interval for peers that appear
+	// to be behind NAT because we are sending them traffic but our handshakes with them are timing
+	// out.  It overrides WireguardPersistentKeepAlive for those peers so that the NAT gateway keeps
+	// the tunnel's mapping alive; 0 disables NAT detection.
+	WireguardNATPersistentKeepAlive time.Duration `config:"seconds;25"`
+	// WireguardRouteMTUs is a comma-separated list of <cidr>=<mtu> entries that set the MTU of the
+	// routes to destinations, via the wireguard device, within each CIDR; the most specific CIDR
+	// wins.  It's for destinations whose underlay path has a smaller MTU than the wireguard
+	// device, where lost ICMP "fragmentation needed" messages would otherwise blackhole large
+	// packets.  If WireguardMTU is 0, the device MTU follows the MTU of the host's interfaces.
+	WireguardRouteMTUs string `config:"string;"`
 
 	BPFEnabled                         bool           `config:"bool;false"`
 	BPFDisableUnprivileged             bool           `config:"bool;true"`
@@ -177,6 +372,70 @@ type Config struct {
 	BPFKubeProxyEndpointSlicesEnabled  bool           `config:"bool;false"`
 	BPFExtToServiceConnmark            int            `config:"int;0"`
 
+	// BPFCgroupV2Root controls which cgroup v2 mount the connect-time load balancer is attached
+	// through: "auto" uses an existing mount of the whole hierarchy (such as /sys/fs/cgroup) if
+	// there is one and a private mount otherwise, "private" always uses a private mount, and any
+	// other value is the path of a cgroup v2 mount to use.  The programs need to be attached to a
+	// cgroup that contains the pods; Felix reports non-ready if they aren't.
+	BPFCgroupV2Root string `config:"string;auto;local"`
+	// BPFAutoMountEnabled allows Felix to mount bpffs, and cgroup v2 for the connect-time load
+	// balancer, if they aren't mounted already.  Felix mounts them privately, in its own mount
+	// namespace.  If disabled, Felix fails to start the BPF dataplane with an error that says
+	// what needs to be mounted.
+	BPFAutoMountEnabled bool `config:"bool;true;local"`
+
+	// BPFMaglevEnabled gives each service a Maglev consistent hashing table so that new flows are
+	// spread by a hash of their addresses and ports rather than at random.  A flow then keeps
+	// its backend if its conntrack entry expires, or if it arrives on another node, as long as
+	// that backend is still there; backend churn only moves the flows of the affected backends.
+	// This matters for long-lived UDP (for example, QUIC) traffic through NodePorts.
+	BPFMaglevEnabled bool `config:"bool;false"`
+	// BPFInterfaceDampingWindow is how long an interface must go without interface events before
+	// Felix attaches its BPF programs.  Interface events that arrive within the window, for example
+	// while kubelet restarts many pods or an interface flaps, are batched into a single attach.
+	// 0 disables damping.
+	BPFInterfaceDampingWindow time.Duration `config:"millis;100"`
+	// BPFMaxParallelAttaches limits how many interfaces Felix attaches BPF programs to in parallel.
+	// 0 means the number of CPUs available to Felix.
+	BPFMaxParallelAttaches int `config:"int(0,1024);0"`
+	// BPFPinGCEnabled labels the BPF maps that Felix pins with its instance ID and a hash of its
+	// BPF configuration, and periodically removes the pins of programs that no longer belong to
+	// one of its interfaces.  Pins labelled by other instances (for example, other tenants'
+	// Felixes on the same host) are left alone, including by the cleanup in iptables mode.
+	// BPFInstanceID defaults to the hostname.
+	BPFPinGCEnabled bool   `config:"bool;false"`
+	BPFInstanceID   string `config:"string;"`
+	// BPFConntrackScanRateLimit limits how many conntrack entries per second Felix's conntrack
+	// cleanup visits, so that cleaning up a very large table doesn't hog a CPU.  0 means no limit.
+	BPFConntrackScanRateLimit int `config:"int(0,100000000);0"`
+	// BPFKubeProxyMigrationMode controls what Felix does if it finds a live kube-proxy when it
+	// enables the BPF dataplane, to avoid NATing service traffic twice while the cluster is
+	// migrated off kube-proxy.  "Defer" leaves kube-proxy's rules alone and doesn't program
+	// the BPF NAT maps until kube-proxy has stopped; "Precedence" programs the BPF NAT maps
+	// straight away and adds a rule that skips kube-proxy's NAT rules for packets that the BPF
+	// programs have already handled.  In either mode, Felix logs and reports (via the
+	// felix_bpf_kube_proxy_migration_pending metric) whether migration is still pending.
+	// "Disabled" keeps the previous behaviour.
+	BPFKubeProxyMigrationMode string `config:"oneof(Disabled,Defer,Precedence);Disabled"`
+	// BPFNodePortSourceRanges, if not empty, is the list of source CIDRs that may reach NodePorts.
+	// It is the BPF dataplane's equivalent of a pre-DNAT policy that only allows those sources
+	// to the NodePorts: the NAT lookup drops traffic from other sources before DNAT, the same way
+	// as for a LoadBalancer service's source ranges.  It applies to all sources, including pods
+	// and other nodes.  IPv6 CIDRs are ignored.
+	BPFNodePortSourceRanges []string `config:"cidr-list;;"`
+	// BPFRouteClassifications is a comma-separated list of <cidr>=<classification> entries that
+	// tell the BPF dataplane how to treat destinations that Calico doesn't otherwise know about,
+	// such as on-prem VPN ranges, when it makes SNAT, RPF and FIB decisions.  The classification
+	// is "host" (treat as other nodes' host IPs), "workload-remote" (treat as remote workloads) or
+	// "external" (the default for unknown destinations, useful to carve a range out of a larger
+	// classified CIDR).  Routes that Calico knows about, such as those of local and remote
+	// workloads, take precedence over a classification of the same CIDR.  IPv6 CIDRs are ignored.
+	BPFRouteClassifications string `config:"string;"`
+	// BPFCountersScrapeInterval is how often Felix reads the per-interface packet counters that
+	// the BPF programs keep (packets accepted, dropped by policy, dropped by RPF and so on) and
+	// exports them as Prometheus metrics.  Zero disables the metrics.
+	BPFCountersScrapeInterval time.Duration `config:"seconds;10"`
+
 	// DebugBPFCgroupV2 controls the cgroup v2 path that we apply the connect-time load balancer to.  Most distros
 	// are configured for cgroup v1, which prevents all but hte root cgroup v2 from working so this is only useful
 	// for development right now.
@@ -214,6 +473,19 @@ type Config struct {
 	TyphaURISAN   string `config:"string;;local"`
 
 	Ipv6Support bool `config:"bool;true"`
+	// PreferredHostAddressFamily selects which of a host's addresses to use for node-to-node
+	// traffic (such as wireguard endpoints) when the host has both an IPv4 and an IPv6 address.
+	// Hosts with only one address always use that address.
+	PreferredHostAddressFamily string `config:"oneof(IPv4,IPv6);IPv4"`
+
+	// NFTablesMode, if Enabled, makes Felix program its rules with nft directly, rather than
+	// through iptables-restore, and store its IP sets as nftables sets.  Ignored in BPF mode.
+	NFTablesMode string `config:"oneof(Disabled,Enabled);Disabled"`
+
+	// InterfacePollingFallbackEnabled makes Felix poll the interfaces every
+	// InterfaceRefreshInterval, rather than exit, if it can't subscribe to netlink updates.
+	// The felix_iface_monitor_polled_changes metric counts the changes that only polling found.
+	InterfacePollingFallbackEnabled bool `config:"bool;false"`
 
 	IptablesBackend                    string            `config:"oneof(legacy,nft,auto);auto"`
 	RouteRefreshInterval               time.Duration     `config:"seconds;90"`
@@ -226,13 +498,25 @@ type Config struct {
 	IptablesLockFilePath               string            `config:"file;/run/xtables.lock"`
 	IptablesLockTimeoutSecs            time.Duration     `config:"seconds;0"`
 	IptablesLockProbeIntervalMillis    time.Duration     `config:"millis;50"`
+	IptablesMaxChainsPerRestore        int               `config:"int(0,1000000);0"`
+	IptablesRestoreDeadlineSecs        time.Duration     `config:"seconds;0"`
 	FeatureDetectOverride              map[string]string `config:"keyvaluelist;;"`
 	IpsetsRefreshInterval              time.Duration     `config:"seconds;10"`
 	MaxIpsetSize                       int               `config:"int;1048576;non-zero"`
 	XDPRefreshInterval                 time.Duration     `config:"seconds;90"`
 
+	// DeferredStartupEnabled makes Felix program policy first after a restart and only then start
+	// the tunnel devices and routes, wireguard routing and the XDP cleanup.  Until those have
+	// started (usually a few seconds), traffic that would use a new tunnel route or wireguard
+	// is routed (unencrypted) by the existing routes of the host.
+	DeferredStartupEnabled bool `config:"bool;false"`
+
 	PolicySyncPathPrefix string `config:"file;;"`
 
+	// PolicyAppliedSocketPath, if set, is the path of a Unix socket on which Felix serves an API
+	// that lets the CNI plugin wait until a new workload endpoint's policy has been programmed.
+	PolicyAppliedSocketPath string `config:"file;;"`
+
 	NetlinkTimeoutSecs time.Duration `config:"seconds;10"`
 
 	MetadataAddr string `config:"hostname;127.0.0.1;die-on-fail"`
@@ -249,6 +533,15 @@ type Config struct {
 	IptablesMangleAllowAction   string `config:"oneof(ACCEPT,RETURN);ACCEPT;non-zero,die-on-fail"`
 	LogPrefix                   string `config:"string;calico-packet"`
 
+	// IptablesChainNamePrefix, IptablesRuleHashPrefix and IPSetNamePrefix are the prefixes of
+	// the iptables chains, rule hash comments and IP sets that Felix owns.  Felix only cleans up
+	// chains and IP sets that have its own prefixes, so changing all three allows it to coexist
+	// with another Calico instance, or tool, that uses the default names.  The internal dataplane
+	// driver rejects prefixes that overlap with the default ones; see rules.ValidateNamePrefixes().
+	IptablesChainNamePrefix string `config:"name-prefix;cali-;non-zero,die-on-fail"`
+	IptablesRuleHashPrefix  string `config:"name-prefix;cali:;non-zero,die-on-fail"`
+	IPSetNamePrefix         string `config:"name-prefix;cali;non-zero,die-on-fail"`
+
 	LogFilePath string `config:"file;/var/log/calico/felix.log;die-on-fail"`
 
 	LogSeverityFile   string `config:"oneof(DEBUG,INFO,WARNING,ERROR,FATAL);INFO"`
@@ -261,10 +554,19 @@ type Config struct {
 	VXLANMTU            int    `config:"int;0"`
 	IPv4VXLANTunnelAddr net.IP `config:"ipv4;"`
 	VXLANTunnelMACAddr  string `config:"string;"`
+	// VXLANPoolVNIs is a comma-separated list of <pool cidr>=<vni> entries that give IP pools their
+	// own VNI, to segregate their traffic from that of other pools.  Each VNI other than VXLANVNI
+	// gets its own VXLAN device, named vx<vni>.cali, and the routes to the pool's blocks go via
+	// that device.
+	VXLANPoolVNIs string `config:"string;"`
 
 	IpInIpEnabled    bool   `config:"bool;false"`
 	IpInIpMtu        int    `config:"int;0"`
 	IpInIpTunnelAddr net.IP `config:"ipv4;"`
+	// IpInIpRoutesEnabled tells Felix to program the routes to remote IPIP workloads itself,
+	// rather than leaving that to BGP.  For CrossSubnet pools, peers in the same subnet as this
+	// host are routed directly and other peers through the tunnel.
+	IpInIpRoutesEnabled bool `config:"bool;false"`
 
 	// Knobs provided to explicitly control whether we add rules to drop encap traffic
 	// from workloads. We always add them unless explicitly requested not to add them.
@@ -275,6 +577,13 @@ type Config struct {
 
 	ServiceLoopPrevention string `config:"oneof(Drop,Reject,Disabled);Drop"`
 
+	// ServiceRoutesEnabled enables programming of local routes, via the ServiceRoutesDevice, for the
+	// service CIDRs (as configured in the BGPConfiguration) and for the individual services that have
+	// endpoints on this host.  This allows service advertisement to work without kube-proxy binding the
+	// service IPs to kube-ipvs0.
+	ServiceRoutesEnabled bool   `config:"bool;false"`
+	ServiceRoutesDevice  string `config:"iface-param;calico-svc;non-zero"`
+
 	ReportingIntervalSecs time.Duration `config:"seconds;30"`
 	ReportingTTLSecs      time.Duration `config:"seconds;90"`
 
@@ -282,9 +591,20 @@ type Config struct {
 	EndpointReportingDelaySecs time.Duration `config:"seconds;1"`
 
 	IptablesMarkMask uint32 `config:"mark-bitmask;0xffff0000;non-zero,die-on-fail"`
+	// IptablesMarkMaskAutoAdjust, if set, makes Felix avoid the bits of IptablesMarkMask that
+	// collide with other configured marks or with the marks of well-known third-party software,
+	// rather than refusing to start.
+	IptablesMarkMaskAutoAdjust bool `config:"bool;false"`
 
 	DisableConntrackInvalidCheck bool `config:"bool;false"`
 
+	// WorkloadUntrackedPolicyEnabled allows untracked (doNotTrack) policy to apply to workload
+	// endpoints as well as to host endpoints.  It is off by default because it adds rules to the
+	// raw table that every packet has to traverse.  It covers both forwarded and host-originated
+	// traffic in the iptables dataplane; the BPF dataplane has no conntrack bypass so the
+	// setting is rejected there.
+	WorkloadUntrackedPolicyEnabled bool `config:"bool;false"`
+
 	HealthEnabled                     bool   `config:"bool;false"`
 	HealthPort                        int    `config:"int(0,65535);9099"`
 	HealthHost                        string `config:"host-address;localhost"`
@@ -301,6 +621,16 @@ type Config struct {
 	KubeNodePortRanges []numorstring.Port `config:"portrange-list;30000:32767"`
 	NATPortRange       numorstring.Port   `config:"portrange;"`
 	NATOutgoingAddress net.IP             `config:"ipv4;"`
+	// NATOutgoingAddressV6 is the IPv6 counterpart of NATOutgoingAddress, for traffic from IPv6
+	// pools.  By default, that traffic is masqueraded.
+	NATOutgoingAddressV6 net.IP `config:"ipv6;"`
+
+	// EgressSNATAddresses is the pool of (IPv4) addresses on this node that workloads may use as
+	// the source address of their egress traffic, instead of NAT outgoing's.  A workload selects
+	// one with the projectcalico.org/egress-snat-address label; EgressSNATNamespaceAddresses maps
+	// from namespace to the address to use for that namespace's other workloads.
+	EgressSNATAddresses          []string          `config:"cidr-list;;"`
+	EgressSNATNamespaceAddresses map[string]string `config:"keyvaluelist;;"`
 
 	UsageReportingEnabled          bool          `config:"bool;true"`
 	UsageReportingInitialDelaySecs time.Duration `config:"seconds;300"`
@@ -311,6 +641,17 @@ type Config struct {
 
 	ExternalNodesCIDRList []string `config:"cidr-list;;die-on-fail"`
 
+	// ExternalNetworksEnabled adds rules that give named groups of external CIDRs forwarding
+	// treatments of their own.  ExternalNetworks maps from network name to a ";"-separated list
+	// of CIDRs and ExternalNetworkTreatments maps from network name to a ";"-separated list of
+	// treatments: "tunnel" (accept IPIP and VXLAN packets from the network, as for
+	// ExternalNodesCIDRList), "rpf-exempt" (let workloads send from the network's addresses) and
+	// "no-masquerade" (don't NAT outgoing traffic to the network).  Unlike most parameters,
+	// changes to ExternalNetworks and ExternalNetworkTreatments are applied without a restart.
+	ExternalNetworksEnabled   bool              `config:"bool;false"`
+	ExternalNetworks          map[string]string `config:"keyvaluelist;;"`
+	ExternalNetworkTreatments map[string]string `config:"keyvaluelist;;"`
+
 	DebugMemoryProfilePath          string        `config:"file;;"`
 	DebugCPUProfilePath             string        `config:"file;/tmp/felix-cpu-<timestamp>.pprof;"`
 	DebugDisableLogDropping         bool          `config:"bool;false"`
@@ -319,12 +660,58 @@ type Config struct {
 	DebugPanicAfter                 time.Duration `config:"seconds;0"`
 	DebugSimulateDataRace           bool          `config:"bool;false"`
 
+	// DebugNetlinkTimeoutRate and DebugNetlinkBusyRate make the given fraction (between 0 and
+	// 1) of the netlink calls that Felix's dataplane makes (for example, to program routes and
+	// WireGuard) fail with a timeout or with EBUSY, respectively, so that tests can exercise its
+	// retry logic.  For use in tests only.
+	DebugNetlinkTimeoutRate float64 `config:"float;0;local"`
+	DebugNetlinkBusyRate    float64 `config:"float;0;local"`
+
+	// DebugDataplaneRecordFile, if set, makes Felix record the messages that it sends to its
+	// dataplane driver to the given file (overwriting it), for replay with calico-felix-replay.
+	// "<timestamp>" in the name is replaced with Felix's start time.
+	DebugDataplaneRecordFile string `config:"file;;local"`
+
+	// DebugProtoInjectionSocket, if set, makes Felix listen on the given unix socket for
+	// dataplane messages (in the same format as a dataplane recording) and send them to its
+	// dataplane driver in place of the output of the calculation graph.  For use in tests only.
+	DebugProtoInjectionSocket string `config:"file;;local"`
+
+	// DebugCrashDumpDir, if set, makes Felix write a snapshot of the dataplane state (iptables
+	// rules, IP sets, routes and, in BPF mode, a summary of the BPF maps) to a file in the given
+	// directory if its dataplane loop panics or it logs a fatal error.  Each snapshot is limited
+	// to DebugCrashDumpMaxBytes and only the five most recent snapshots are kept.
+	DebugCrashDumpDir      string `config:"file;;local"`
+	DebugCrashDumpMaxBytes int    `config:"int(4096,1073741824);10485760"`
+
 	// Configure where Felix gets its routing information.
 	// - workloadIPs: use workload endpoints to construct routes.
 	// - calicoIPAM: use IPAM data to contruct routes.
 	RouteSource string `config:"oneof(WorkloadIPs,CalicoIPAM);CalicoIPAM"`
 
 	RouteTableRange idalloc.IndexRange `config:"route-table-range;1-250;die-on-fail"`
+	// RouteTableRangeExclusions lists indices (or ranges of indices, such as "100-110") within
+	// RouteTableRange that Felix must not use for its own routing tables.  Felix also excludes
+	// any tables that are named in /etc/iproute2/rt_tables (or rt_tables.d), and reports them
+	// as conflicts.
+	RouteTableRangeExclusions []idalloc.IndexRange `config:"route-table-range-list;;die-on-fail"`
+	// RouteTableIndexStateFile is where Felix records which routing table index it gave to each of
+	// its components, so that they keep the same table over restart.  Empty disables persistence.
+	RouteTableIndexStateFile string `config:"file;/var/lib/calico/route-table-indices;local"`
+	// RouteTableMaxParallelUpdates is the number of netlink sockets that each routing table may
+	// use in parallel to add or delete routes.  Values above 1 speed up the programming of large
+	// numbers of routes, such as the tens of thousands of routes of a large cluster, at the cost
+	// of a netlink socket per extra worker.
+	RouteTableMaxParallelUpdates int `config:"int(1,64);1"`
+
+	// StaticRoutes maps from route name to an operator-defined route that Felix programs on the
+	// node, in a similar syntax to "ip route": "<cidr> [via <next hop>] [dev <device>] [table
+	// <index>]".  At least one of the next hop and device is required.  Set it in a node's own
+	// FelixConfiguration for per-node routes.  Changes are applied without a restart.
+	// StaticRouteProtocol is the route protocol that Felix marks the routes with; Felix only
+	// removes routes with that protocol so it should differ from DeviceRouteProtocol.
+	StaticRoutes        map[string]string `config:"keyvaluelist;;"`
+	StaticRouteProtocol int               `config:"int(1,255);80"`
 
 	IptablesNATOutgoingInterfaceFilter string `config:"iface-param;"`
 
@@ -421,6 +808,38 @@ func (config *Config) InterfacePrefixes() []string {
 	return strings.Split(config.InterfacePrefix, ",")
 }
 
+func (config *Config) WorkloadLinkLocalExceptionNamespaceList() (namespaces []string) {
+	for _, ns := range strings.Split(config.WorkloadLinkLocalExceptionNamespaces, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return
+}
+
+func (config *Config) CNIPluginBinaryList() (binaries []string) {
+	for _, b := range strings.Split(config.CNIPluginBinaries, ",") {
+		if b = strings.TrimSpace(b); b != "" {
+			binaries = append(binaries, b)
+		}
+	}
+	return
+}
+
+// PreferredHostIPVersion returns the IP version that corresponds to PreferredHostAddressFamily.
+func (config *Config) PreferredHostIPVersion() int {
+	if config.PreferredHostAddressFamily == "IPv6" {
+		return 6
+	}
+	return 4
+}
+
+// WorkloadDrainConfigured returns true if the workload drain mode can be triggered, either by
+// config or by the drain file.
+func (config *Config) WorkloadDrainConfigured() bool {
+	return config.WorkloadDrainEnabled || config.WorkloadDrainFile != ""
+}
+
 func (config *Config) OpenstackActive() bool {
 	if strings.Contains(strings.ToLower(config.ClusterType), "openstack") {
 		// OpenStack is explicitly known to be present.  Newer versions of the OpenStack plugin
@@ -628,45 +1047,6 @@ func (config *Config) DatastoreConfig() apiconfig.CalicoAPIConfig {
 	return *cfg
 }
 
-// Validate() performs cross-field validation.
-func (config *Config) Validate() (err error) {
-	if config.FelixHostname == "" {
-		err = errors.New("Failed to determine hostname")
-	}
-
-	if config.DatastoreType == "etcdv3" && len(config.EtcdEndpoints) == 0 {
-		if config.EtcdScheme == "" {
-			err = errors.New("EtcdEndpoints and EtcdScheme both missing")
-		}
-		if config.EtcdAddr == "" {
-			err = errors.New("EtcdEndpoints and EtcdAddr both missing")
-		}
-	}
-
-	// If any client-side TLS config parameters are specified, they _all_ must be - except that
-	// either TyphaCN or TyphaURISAN may be left unset.
-	if config.TyphaCAFile != "" ||
-		config.TyphaCertFile != "" ||
-		config.TyphaKeyFile != "" ||
-		config.TyphaCN != "" ||
-		config.TyphaURISAN != "" {
-		// Some TLS config specified.
-		if config.TyphaKeyFile == "" ||
-			config.TyphaCertFile == "" ||
-			config.TyphaCAFile == "" ||
-			(config.TyphaCN == "" && config.TyphaURISAN == "") {
-			err = errors.New("If any Felix-Typha TLS config parameters are specified," +
-				" they _all_ must be" +
-				" - except that either TyphaCN or TyphaURISAN may be left unset.")
-		}
-	}
-
-	if err != nil {
-		config.Err = err
-	}
-	return
-}
-
 var knownParams map[string]param
 
 func loadParams() {
@@ -736,6 +1116,9 @@ func loadParams() {
 		case "iface-param":
 			param = &RegexpParam{Regexp: IfaceParamRegexp,
 				Msg: "invalid Linux interface parameter"}
+		case "name-prefix":
+			param = &RegexpParam{Regexp: NamePrefixRegexp,
+				Msg: "invalid name prefix, must be 1-5 characters long, start with a letter and contain only letters, digits, ':', '_' or '-'"}
 		case "file":
 			param = &FileParam{
 				MustExist:  strings.Contains(kindParams, "must-exist"),
@@ -746,6 +1129,8 @@ func loadParams() {
 				Msg: "invalid URL authority"}
 		case "ipv4":
 			param = &Ipv4Param{}
+		case "ipv6":
+			param = &Ipv6Param{}
 		case "endpoint-list":
 			param = &EndpointListParam{}
 		case "port-list":
@@ -760,6 +1145,9 @@ func loadParams() {
 		case "host-address":
 			param = &RegexpParam{Regexp: HostAddressRegexp,
 				Msg: "invalid host address"}
+		case "label":
+			param = &RegexpParam{Regexp: LabelRegexp,
+				Msg: "invalid label"}
 		case "region":
 			param = &RegionParam{}
 		case "oneof":
@@ -777,8 +1165,12 @@ func loadParams() {
 			param = &CIDRListParam{}
 		case "route-table-range":
 			param = &RouteTableRangeParam{}
+		case "route-table-range-list":
+			param = &RouteTableRangeListParam{}
 		case "keyvaluelist":
 			param = &KeyValueListParam{}
+		case "cpu-list":
+			param = &CPUListParam{}
 		default:
 			log.Panicf("Unknown type of parameter: %v", kind)
 		}
@@ -870,6 +1262,30 @@ func New() *Config {
 	return p
 }
 
+// ParseRawValue parses a raw value for the named parameter, such as one from a ConfigUpdate
+// message, in the same way as the values from Felix's config sources.  An empty value gives the
+// parameter's default.
+func ParseRawValue(name, rawValue string) (interface{}, error) {
+	if knownParams == nil {
+		loadParams()
+	}
+	param, ok := knownParams[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("unknown config parameter %q", name)
+	}
+	metadata := param.GetMetadata()
+	if rawValue == "" {
+		return metadata.Default, nil
+	}
+	if strings.ToLower(rawValue) == "none" {
+		if metadata.NonZero {
+			return nil, errors.New("non-zero field cannot be set to none")
+		}
+		return metadata.ZeroValue, nil
+	}
+	return param.Parse(rawValue)
+}
+
 type param interface {
 	GetMetadata() *Metadata
 	Parse(raw string) (result interface{}, err error)
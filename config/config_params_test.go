@@ -15,9 +15,13 @@
 package config_test
 
 import (
+	"errors"
+	"fmt"
 	"regexp"
 
+	"github.com/projectcalico/felix/bpf/tc"
 	"github.com/projectcalico/felix/config"
+	"github.com/projectcalico/felix/idalloc"
 	"github.com/projectcalico/felix/testutils"
 	"github.com/projectcalico/libcalico-go/lib/apiconfig"
 
@@ -61,6 +65,108 @@ var _ = Describe("FelixConfig vs ConfigParams parity", func() {
 		"loadClientConfigFromEnvironment",
 		"useNodeResourceUpdates",
 		"internalOverrides",
+
+		// Not yet exposed in the FelixConfiguration API.
+		"ServiceRoutesEnabled",
+		"ServiceRoutesDevice",
+		"WorkloadUntrackedPolicyEnabled",
+		"IpInIpRoutesEnabled",
+		"WireguardEncryptionScope",
+		"WireguardEncryptionLabel",
+		"WireguardPersistentKeepAlive",
+		"WireguardNATPersistentKeepAlive",
+		"CNIReadinessGateEnabled",
+		"CNINetDir",
+		"CNIBinDir",
+		"CNIPluginBinaries",
+		"WorkloadDrainEnabled",
+		"WorkloadDrainFile",
+		"WorkloadDrainPollInterval",
+		"PreferredHostAddressFamily",
+		"DropCaptureEnabled",
+		"DropCaptureNFLOGGroup",
+		"DropCaptureBufferSize",
+		"DropCaptureSnapLength",
+		"EgressSNATAddresses",
+		"EgressSNATNamespaceAddresses",
+		"ConntrackAccountingEnabled",
+		"ConntrackAccountingInterval",
+		"ConntrackAccountingTopN",
+		"FlowLogsEnabled",
+		"FlowLogsFlushInterval",
+		"FlowLogsSink",
+		"FlowLogsFileName",
+		"FlowLogsGRPCAddr",
+		"DebugRouteTablesEnabled",
+		"DebugBPFMemoryEnabled",
+		"DataplaneManagerFailureBudget",
+		"DataplaneDryRunReportFile",
+		"BPFCgroupV2Root",
+		"WorkloadConnRateLimitEnabled",
+		"WorkloadConnRateLimit",
+		"WorkloadConnRateLimitBurst",
+		"WorkloadAllowedSourcesEnabled",
+		"WorkloadEgressAllowlistEnabled",
+		"WorkloadEgressAllowlistMaxSize",
+		"EndpointToHostActionOverridesEnabled",
+		"DebugDataplaneRecordFile",
+		"DebugProtoInjectionSocket",
+		"DebugNetlinkTimeoutRate",
+		"DebugNetlinkBusyRate",
+		"BPFMaglevEnabled",
+		"BPFInterfaceDampingWindow",
+		"BPFMaxParallelAttaches",
+		"BPFPinGCEnabled",
+		"BPFInstanceID",
+		"BPFKubeProxyMigrationMode",
+		"BPFConntrackScanRateLimit",
+		"RouteTableRangeExclusions",
+		"RouteTableIndexStateFile",
+		"StaticRoutes",
+		"StaticRouteProtocol",
+		"IptablesChainNamePrefix",
+		"IptablesRuleHashPrefix",
+		"IPSetNamePrefix",
+		"BPFAutoMountEnabled",
+		"IptablesMaxChainsPerRestore",
+		"IptablesRestoreDeadlineSecs",
+		"NeighborGCTuningEnabled",
+		"NeighborGCStaleTime",
+		"PolicyRuleCountersEnabled",
+		"PolicyRuleCountersInterval",
+		"WorkloadMACEnforcement",
+		"DeferredStartupEnabled",
+		"ExternalNetworksEnabled",
+		"ExternalNetworks",
+		"ExternalNetworkTreatments",
+		"DataplaneMaxMsgBatchSize",
+		"BPFNodePortSourceRanges",
+		"WireguardRouteMTUs",
+		"BPFRouteClassifications",
+		"PolicyAppliedSocketPath",
+		"IptablesMarkMaskAutoAdjust",
+		"VXLANPoolVNIs",
+		"DebugCrashDumpDir",
+		"DebugCrashDumpMaxBytes",
+		"NFTablesMode",
+		"NATOutgoingAddressV6",
+		"DataplaneCPUBudget",
+		"DataplaneNice",
+		"DataplaneIOPriorityClass",
+		"DataplaneIOPriorityLevel",
+		"DataplaneSchedPolicy",
+		"DataplaneSchedPriority",
+		"DataplaneCPUAffinity",
+		"RouteTableMaxParallelUpdates",
+		"BPFCountersScrapeInterval",
+		"DataplaneDriverHandshakeTimeout",
+		"WorkloadInterfaceRPFilter",
+		"WorkloadInterfaceAcceptRA",
+		"WorkloadInterfaceTXChecksumOffload",
+		"WorkloadLinkLocalAccess",
+		"WorkloadLinkLocalServices",
+		"WorkloadLinkLocalExceptionNamespaces",
+		"InterfacePollingFallbackEnabled",
 	}
 	cpFieldNameToFC := map[string]string{
 		"IpInIpEnabled":                      "IPIPEnabled",
@@ -262,6 +368,10 @@ var _ = DescribeTable("Config parsing",
 	Entry("ChainInsertMode append", "ChainInsertMode", "append", "append"),
 	Entry("ChainInsertMode append", "ChainInsertMode", "Append", "append"),
 
+	Entry("IptablesChainNamePrefix", "IptablesChainNamePrefix", "blu-", "blu-"),
+	Entry("IptablesRuleHashPrefix", "IptablesRuleHashPrefix", "blu:", "blu:"),
+	Entry("IPSetNamePrefix", "IPSetNamePrefix", "blu", "blu"),
+
 	Entry("IptablesPostWriteCheckIntervalSecs", "IptablesPostWriteCheckIntervalSecs",
 		"1.5", 1500*time.Millisecond),
 	Entry("IptablesLockFilePath", "IptablesLockFilePath",
@@ -272,6 +382,36 @@ var _ = DescribeTable("Config parsing",
 		"123", 123*time.Millisecond),
 	Entry("IptablesLockProbeIntervalMillis garbage", "IptablesLockProbeIntervalMillis",
 		"garbage", 50*time.Millisecond),
+	Entry("IptablesMaxChainsPerRestore", "IptablesMaxChainsPerRestore",
+		"500", 500),
+	Entry("IptablesMaxChainsPerRestore negative", "IptablesMaxChainsPerRestore",
+		"-1", 0),
+	Entry("IptablesRestoreDeadlineSecs", "IptablesRestoreDeadlineSecs",
+		"30", 30*time.Second),
+	Entry("IptablesRestoreDeadlineSecs default", "IptablesRestoreDeadlineSecs",
+		"", time.Duration(0)),
+	Entry("NeighborGCTuningEnabled", "NeighborGCTuningEnabled",
+		"true", true),
+	Entry("NeighborGCStaleTime", "NeighborGCStaleTime",
+		"120", 120*time.Second),
+	Entry("PolicyRuleCountersEnabled", "PolicyRuleCountersEnabled",
+		"true", true),
+	Entry("PolicyRuleCountersInterval", "PolicyRuleCountersInterval",
+		"30", 30*time.Second),
+	Entry("PolicyRuleCountersInterval default", "PolicyRuleCountersInterval",
+		"", 10*time.Second),
+	Entry("DeferredStartupEnabled", "DeferredStartupEnabled",
+		"true", true),
+	Entry("ExternalNetworks", "ExternalNetworks",
+		"dc2=10.1.0.0/16;10.2.0.0/16,peers=192.168.0.0/24",
+		map[string]string{"dc2": "10.1.0.0/16;10.2.0.0/16", "peers": "192.168.0.0/24"}),
+	Entry("ExternalNetworkTreatments", "ExternalNetworkTreatments",
+		"dc2=tunnel;no-masquerade", map[string]string{"dc2": "tunnel;no-masquerade"}),
+	Entry("StaticRoutes", "StaticRoutes",
+		"dc2=10.1.0.0/16 via 192.168.0.1,mgmt=172.16.0.0/12 dev eth1 table 100",
+		map[string]string{"dc2": "10.1.0.0/16 via 192.168.0.1", "mgmt": "172.16.0.0/12 dev eth1 table 100"}),
+	Entry("StaticRouteProtocol", "StaticRouteProtocol", "90", 90),
+	Entry("StaticRouteProtocol default", "StaticRouteProtocol", "", 80),
 
 	Entry("DefaultEndpointToHostAction", "DefaultEndpointToHostAction",
 		"RETURN", "RETURN"),
@@ -284,6 +424,8 @@ var _ = DescribeTable("Config parsing",
 		"RETURN", "RETURN"),
 
 	Entry("LogFilePath", "LogFilePath", "/tmp/felix.log", "/tmp/felix.log"),
+	Entry("PolicyAppliedSocketPath", "PolicyAppliedSocketPath",
+		"/var/run/calico/policyapplied.sock", "/var/run/calico/policyapplied.sock"),
 
 	Entry("LogSeverityFile", "LogSeverityFile", "debug", "DEBUG"),
 	Entry("LogSeverityFile", "LogSeverityFile", "warning", "WARNING"),
@@ -305,6 +447,136 @@ var _ = DescribeTable("Config parsing",
 	Entry("IpInIpEnabled", "IpInIpEnabled", "True", true),
 
 	Entry("IpInIpMtu", "IpInIpMtu", "1234", int(1234)),
+
+	Entry("WireguardEncryptionScope", "WireguardEncryptionScope", "crosssubnet", "CrossSubnet"),
+	Entry("WireguardEncryptionScope bad", "WireguardEncryptionScope", "Some", "All"),
+	Entry("WireguardEncryptionLabel key", "WireguardEncryptionLabel", "example.com/encrypt", "example.com/encrypt"),
+	Entry("WireguardEncryptionLabel key=value", "WireguardEncryptionLabel", "zone=a-1", "zone=a-1"),
+	Entry("WireguardEncryptionLabel bad", "WireguardEncryptionLabel", "zone=a b", ""),
+	Entry("WireguardPersistentKeepAlive", "WireguardPersistentKeepAlive", "10", 10*time.Second),
+	Entry("WireguardNATPersistentKeepAlive", "WireguardNATPersistentKeepAlive", "15", 15*time.Second),
+	Entry("WireguardNATPersistentKeepAlive disabled", "WireguardNATPersistentKeepAlive", "0", time.Duration(0)),
+
+	Entry("CNIReadinessGateEnabled", "CNIReadinessGateEnabled", "true", true),
+	Entry("CNIPluginBinaries", "CNIPluginBinaries", "calico", "calico"),
+	Entry("WorkloadDrainEnabled", "WorkloadDrainEnabled", "true", true),
+	Entry("WorkloadDrainFile", "WorkloadDrainFile", "/var/run/calico/drain", "/var/run/calico/drain"),
+	Entry("WorkloadDrainPollInterval", "WorkloadDrainPollInterval", "10", 10*time.Second),
+	Entry("PreferredHostAddressFamily", "PreferredHostAddressFamily", "ipv6", "IPv6"),
+	Entry("PreferredHostAddressFamily bad", "PreferredHostAddressFamily", "IPv5", "IPv4"),
+	Entry("DropCaptureEnabled", "DropCaptureEnabled", "true", true),
+	Entry("DropCaptureNFLOGGroup", "DropCaptureNFLOGGroup", "30", 30),
+	Entry("DropCaptureNFLOGGroup out of range", "DropCaptureNFLOGGroup", "0", 20),
+	Entry("DropCaptureBufferSize", "DropCaptureBufferSize", "50", 50),
+	Entry("DropCaptureSnapLength", "DropCaptureSnapLength", "256", 256),
+	Entry("EgressSNATAddresses", "EgressSNATAddresses", "192.168.0.10, 192.168.0.11",
+		[]string{"192.168.0.10/32", "192.168.0.11/32"}),
+	Entry("EgressSNATNamespaceAddresses", "EgressSNATNamespaceAddresses", "tenant-a=192.168.0.10,tenant-b=192.168.0.11",
+		map[string]string{"tenant-a": "192.168.0.10", "tenant-b": "192.168.0.11"}),
+	Entry("ConntrackAccountingEnabled", "ConntrackAccountingEnabled", "true", true),
+	Entry("ConntrackAccountingInterval", "ConntrackAccountingInterval", "60", 60*time.Second),
+	Entry("ConntrackAccountingTopN", "ConntrackAccountingTopN", "5", 5),
+	Entry("ConntrackAccountingTopN out of range", "ConntrackAccountingTopN", "0", 10),
+	Entry("FlowLogsEnabled", "FlowLogsEnabled", "true", true),
+	Entry("FlowLogsFlushInterval", "FlowLogsFlushInterval", "60", 60*time.Second),
+	Entry("FlowLogsSink", "FlowLogsSink", "grpc", "grpc"),
+	Entry("FlowLogsSink invalid", "FlowLogsSink", "kafka", "file"),
+	Entry("FlowLogsFileName", "FlowLogsFileName", "/tmp/flows.log", "/tmp/flows.log"),
+	Entry("FlowLogsGRPCAddr", "FlowLogsGRPCAddr", "collector:5000", "collector:5000"),
+	Entry("DebugRouteTablesEnabled", "DebugRouteTablesEnabled", "true", true),
+	Entry("DebugBPFMemoryEnabled", "DebugBPFMemoryEnabled", "true", true),
+	Entry("DataplaneManagerFailureBudget", "DataplaneManagerFailureBudget", "20", 20),
+	Entry("DataplaneManagerFailureBudget negative", "DataplaneManagerFailureBudget", "-1", 0),
+	Entry("DataplaneDryRunReportFile", "DataplaneDryRunReportFile", "/var/log/calico/dry-run.json",
+		"/var/log/calico/dry-run.json"),
+	Entry("DataplaneMaxMsgBatchSize", "DataplaneMaxMsgBatchSize", "5000", 5000),
+	Entry("DataplaneMaxMsgBatchSize too low", "DataplaneMaxMsgBatchSize", "10", 1000),
+	Entry("DataplaneCPUBudget", "DataplaneCPUBudget", "0.5", 0.5),
+	Entry("DataplaneNice", "DataplaneNice", "-5", -5),
+	Entry("DataplaneNice too low", "DataplaneNice", "-21", 0),
+	Entry("DataplaneIOPriorityClass", "DataplaneIOPriorityClass", "realtime", "RealTime"),
+	Entry("DataplaneIOPriorityLevel", "DataplaneIOPriorityLevel", "0", 0),
+	Entry("DataplaneIOPriorityLevel too high", "DataplaneIOPriorityLevel", "8", 4),
+	Entry("DataplaneSchedPolicy", "DataplaneSchedPolicy", "FIFO", "FIFO"),
+	Entry("DataplaneSchedPriority", "DataplaneSchedPriority", "50", 50),
+	Entry("DataplaneCPUAffinity", "DataplaneCPUAffinity", "8, 0-2,1", []int{0, 1, 2, 8}),
+	Entry("DataplaneCPUAffinity bad range", "DataplaneCPUAffinity", "3-1", []int(nil)),
+	Entry("DataplaneCPUAffinity too high", "DataplaneCPUAffinity", "1024", []int(nil)),
+	Entry("RouteTableMaxParallelUpdates", "RouteTableMaxParallelUpdates", "8", 8),
+	Entry("RouteTableMaxParallelUpdates zero", "RouteTableMaxParallelUpdates", "0", 1),
+	Entry("BPFNodePortSourceRanges", "BPFNodePortSourceRanges", "10.0.0.0/8, 192.168.1.1",
+		[]string{"10.0.0.0/8", "192.168.1.1/32"}),
+	Entry("WireguardRouteMTUs", "WireguardRouteMTUs", "10.0.0.0/16=1380", "10.0.0.0/16=1380"),
+	Entry("VXLANPoolVNIs", "VXLANPoolVNIs", "10.0.0.0/16=4097", "10.0.0.0/16=4097"),
+	Entry("BPFRouteClassifications", "BPFRouteClassifications", "10.0.0.0/16=host", "10.0.0.0/16=host"),
+	Entry("BPFCgroupV2Root", "BPFCgroupV2Root", "/sys/fs/cgroup", "/sys/fs/cgroup"),
+	Entry("BPFCgroupV2Root default", "BPFCgroupV2Root", "", "auto"),
+	Entry("BPFMaglevEnabled", "BPFMaglevEnabled", "true", true),
+	Entry("BPFMaglevEnabled default", "BPFMaglevEnabled", "", false),
+	Entry("BPFInterfaceDampingWindow", "BPFInterfaceDampingWindow", "250", 250*time.Millisecond),
+	Entry("BPFInterfaceDampingWindow default", "BPFInterfaceDampingWindow", "", 100*time.Millisecond),
+	Entry("BPFMaxParallelAttaches", "BPFMaxParallelAttaches", "8", 8),
+	Entry("BPFMaxParallelAttaches too high", "BPFMaxParallelAttaches", "2000", 0),
+	Entry("BPFPinGCEnabled", "BPFPinGCEnabled", "true", true),
+	Entry("BPFInstanceID", "BPFInstanceID", "tenant-a", "tenant-a"),
+	Entry("BPFInstanceID default", "BPFInstanceID", "", ""),
+	Entry("BPFConntrackScanRateLimit", "BPFConntrackScanRateLimit", "100000", 100000),
+	Entry("BPFConntrackScanRateLimit default", "BPFConntrackScanRateLimit", "", 0),
+	Entry("BPFCountersScrapeInterval", "BPFCountersScrapeInterval", "30", 30*time.Second),
+	Entry("BPFCountersScrapeInterval default", "BPFCountersScrapeInterval", "", 10*time.Second),
+	Entry("DataplaneDriverHandshakeTimeout", "DataplaneDriverHandshakeTimeout", "2.5", 2500*time.Millisecond),
+	Entry("DataplaneDriverHandshakeTimeout default", "DataplaneDriverHandshakeTimeout", "", 5*time.Second),
+	Entry("BPFKubeProxyMigrationMode", "BPFKubeProxyMigrationMode", "Defer", "Defer"),
+	Entry("BPFKubeProxyMigrationMode default", "BPFKubeProxyMigrationMode", "", "Disabled"),
+	Entry("BPFKubeProxyMigrationMode invalid", "BPFKubeProxyMigrationMode", "Sometimes", "Disabled"),
+	Entry("RouteTableRangeExclusions", "RouteTableRangeExclusions", "100, 200-210",
+		[]idalloc.IndexRange{{Min: 100, Max: 100}, {Min: 200, Max: 210}}),
+	Entry("RouteTableIndexStateFile default", "RouteTableIndexStateFile", "",
+		"/var/lib/calico/route-table-indices"),
+	Entry("BPFAutoMountEnabled", "BPFAutoMountEnabled", "false", false),
+	Entry("BPFAutoMountEnabled default", "BPFAutoMountEnabled", "", true),
+	Entry("WorkloadConnRateLimitEnabled", "WorkloadConnRateLimitEnabled", "true", true),
+	Entry("WorkloadConnRateLimit", "WorkloadConnRateLimit", "100", 100),
+	Entry("WorkloadConnRateLimit out of range", "WorkloadConnRateLimit", "100000", 0),
+	Entry("WorkloadConnRateLimitBurst", "WorkloadConnRateLimitBurst", "200", 200),
+	Entry("WorkloadAllowedSourcesEnabled", "WorkloadAllowedSourcesEnabled", "true", true),
+	Entry("WorkloadEgressAllowlistEnabled", "WorkloadEgressAllowlistEnabled", "true", true),
+	Entry("WorkloadEgressAllowlistMaxSize", "WorkloadEgressAllowlistMaxSize", "4096", 4096),
+	Entry("WorkloadEgressAllowlistMaxSize too small", "WorkloadEgressAllowlistMaxSize", "0", 1024),
+	Entry("EndpointToHostActionOverridesEnabled", "EndpointToHostActionOverridesEnabled", "true", true),
+	Entry("WorkloadMACEnforcement", "WorkloadMACEnforcement", "PerEndpoint", "PerEndpoint"),
+	Entry("WorkloadMACEnforcement default", "WorkloadMACEnforcement", "", "Disabled"),
+	Entry("WorkloadMACEnforcement garbage", "WorkloadMACEnforcement", "sometimes", "Disabled"),
+
+	Entry("WorkloadInterfaceRPFilter", "WorkloadInterfaceRPFilter", "loose", "Loose"),
+	Entry("WorkloadInterfaceRPFilter default", "WorkloadInterfaceRPFilter", "", "DoNothing"),
+	Entry("WorkloadInterfaceRPFilter garbage", "WorkloadInterfaceRPFilter", "2", "DoNothing"),
+	Entry("WorkloadInterfaceAcceptRA", "WorkloadInterfaceAcceptRA", "DoNothing", "DoNothing"),
+	Entry("WorkloadInterfaceAcceptRA default", "WorkloadInterfaceAcceptRA", "", "Disabled"),
+	Entry("WorkloadInterfaceTXChecksumOffload", "WorkloadInterfaceTXChecksumOffload", "Auto", "Auto"),
+	Entry("WorkloadInterfaceTXChecksumOffload default", "WorkloadInterfaceTXChecksumOffload", "", "DoNothing"),
+	Entry("WorkloadLinkLocalAccess", "WorkloadLinkLocalAccess", "deny", "Deny"),
+	Entry("WorkloadLinkLocalAccess default", "WorkloadLinkLocalAccess", "", "DoNothing"),
+	Entry("WorkloadLinkLocalServices", "WorkloadLinkLocalServices", "169.254.169.254,169.254.170.2/32",
+		[]string{"169.254.169.254/32", "169.254.170.2/32"}),
+	Entry("WorkloadLinkLocalServices default", "WorkloadLinkLocalServices", "", []string{"169.254.169.254/32"}),
+	Entry("InterfacePollingFallbackEnabled", "InterfacePollingFallbackEnabled", "true", true),
+	Entry("WorkloadLinkLocalExceptionNamespaces", "WorkloadLinkLocalExceptionNamespaces",
+		"kube-system,monitoring", "kube-system,monitoring"),
+	Entry("DebugDataplaneRecordFile", "DebugDataplaneRecordFile", "/var/log/calico/dp-<timestamp>.rec",
+		"/var/log/calico/dp-<timestamp>.rec"),
+	Entry("DebugProtoInjectionSocket", "DebugProtoInjectionSocket", "/tmp/felix-inject.sock",
+		"/tmp/felix-inject.sock"),
+	Entry("DebugNetlinkTimeoutRate", "DebugNetlinkTimeoutRate", "0.25", 0.25),
+	Entry("DebugNetlinkBusyRate", "DebugNetlinkBusyRate", "0.1", 0.1),
+	Entry("DebugNetlinkBusyRate default", "DebugNetlinkBusyRate", "", 0.0),
+	Entry("DebugCrashDumpDir", "DebugCrashDumpDir", "/var/log/calico/crash", "/var/log/calico/crash"),
+	Entry("DebugCrashDumpMaxBytes", "DebugCrashDumpMaxBytes", "65536", 65536),
+	Entry("DebugCrashDumpMaxBytes too low", "DebugCrashDumpMaxBytes", "10", 10485760),
+	Entry("NFTablesMode", "NFTablesMode", "Enabled", "Enabled"),
+	Entry("NFTablesMode invalid", "NFTablesMode", "foo", "Disabled"),
+	Entry("NATOutgoingAddressV6", "NATOutgoingAddressV6", "fd00::1", net.ParseIP("fd00::1")),
+	Entry("NATOutgoingAddressV6 IPv4", "NATOutgoingAddressV6", "10.0.0.1", net.IP(nil)),
 	Entry("IpInIpTunnelAddr", "IpInIpTunnelAddr",
 		"10.0.0.1", net.ParseIP("10.0.0.1")),
 
@@ -320,6 +592,7 @@ var _ = DescribeTable("Config parsing",
 
 	Entry("MaxIpsetSize", "MaxIpsetSize", "12345", int(12345)),
 	Entry("IptablesMarkMask", "IptablesMarkMask", "0xf0f0", uint32(0xf0f0)),
+	Entry("IptablesMarkMaskAutoAdjust", "IptablesMarkMaskAutoAdjust", "true", true),
 
 	Entry("HealthEnabled", "HealthEnabled", "true", true),
 	Entry("HealthHost", "HealthHost", "127.0.0.1", "127.0.0.1"),
@@ -470,6 +743,25 @@ var _ = DescribeTable("Config parsing",
 	Entry("IptablesNATOutgoingInterfaceFilter", "IptablesNATOutgoingInterfaceFilter", "cali@123", "", false),
 )
 
+var _ = DescribeTable("ParseRawValue",
+	func(name, raw string, expected interface{}, expectOK bool) {
+		value, err := config.ParseRawValue(name, raw)
+		if expectOK {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(value).To(Equal(expected))
+		} else {
+			Expect(err).To(HaveOccurred())
+		}
+	},
+	Entry("seconds", "RouteRefreshInterval", "30", 30*time.Second, true),
+	Entry("case-insensitive name", "routerefreshinterval", "30", 30*time.Second, true),
+	Entry("empty value gives default", "RouteRefreshInterval", "", 90*time.Second, true),
+	Entry("none gives zero value", "RouteRefreshInterval", "none", time.Duration(0), true),
+	Entry("none for non-zero param", "IptablesMarkMask", "none", nil, false),
+	Entry("invalid value", "RouteRefreshInterval", "foo", nil, false),
+	Entry("unknown param", "NotAParam", "1", nil, false),
+)
+
 var _ = DescribeTable("OpenStack heuristic tests",
 	func(clusterType, metadataAddr, metadataPort, ifacePrefixes interface{}, expected bool) {
 		c := config.New()
@@ -706,8 +998,577 @@ var _ = DescribeTable("Config validation",
 	Entry("invalid RouteTableRange", map[string]string{
 		"RouteTableRange": "abcde",
 	}, false),
+	Entry("valid RouteTableRangeExclusions", map[string]string{
+		"RouteTableRangeExclusions": "1,100-110",
+	}, true),
+	Entry("invalid RouteTableRangeExclusions", map[string]string{
+		"RouteTableRangeExclusions": "100-300",
+	}, false),
+	Entry("invalid RouteTableRangeExclusions", map[string]string{
+		"RouteTableRangeExclusions": "abc",
+	}, false),
+	Entry("BPF mode with default IptablesMarkMask", map[string]string{
+		"BPFEnabled": "true",
+	}, true),
+	Entry("BPF mode with IptablesMarkMask that doesn't cover BPF bits", map[string]string{
+		"BPFEnabled":       "true",
+		"IptablesMarkMask": "0x000f0000",
+	}, false),
+	Entry("IptablesMarkMask with too few bits", map[string]string{
+		"IptablesMarkMask": "0x7",
+	}, false),
+	Entry("IptablesMarkMask with too few bits for WireGuard", map[string]string{
+		"IptablesMarkMask": "0xf",
+		"WireguardEnabled": "true",
+	}, false),
+	Entry("IptablesMarkMask that collides with kube-proxy", map[string]string{
+		"IptablesMarkMask": "0xffffc000",
+	}, false),
+	Entry("IptablesMarkMask that collides with kube-proxy, auto-adjusted", map[string]string{
+		"IptablesMarkMask":           "0xffffc000",
+		"IptablesMarkMaskAutoAdjust": "true",
+	}, true),
+	Entry("IptablesMarkMask that collides with Istio, too few bits left", map[string]string{
+		"IptablesMarkMask":           "0x53f",
+		"IptablesMarkMaskAutoAdjust": "true",
+	}, false),
+	Entry("BPFExtToServiceConnmark that collides with the BPF marks", map[string]string{
+		"BPFEnabled":              "true",
+		"BPFExtToServiceConnmark": "0x100000",
+	}, false),
+	Entry("BPFExtToServiceConnmark that collides with IptablesMarkMask, auto-adjusted", map[string]string{
+		"BPFEnabled":                 "true",
+		"BPFExtToServiceConnmark":    "0x10000",
+		"IptablesMarkMaskAutoAdjust": "true",
+	}, true),
+	Entry("DSR without BPF mode", map[string]string{
+		"BPFExternalServiceMode": "dsr",
+	}, false),
+	Entry("DSR in BPF mode", map[string]string{
+		"BPFEnabled":             "true",
+		"BPFExternalServiceMode": "dsr",
+	}, true),
+	Entry("IptablesMarkMask with too few bits, external dataplane", map[string]string{
+		"IptablesMarkMask":           "0x7",
+		"UseInternalDataplaneDriver": "false",
+	}, true),
 )
 
+var _ = Describe("Config validation errors", func() {
+	var cfg *config.Config
+	BeforeEach(func() {
+		cfg = config.New()
+	})
+
+	It("should report all problems in a ValidationError", func() {
+		_, err := cfg.UpdateFrom(map[string]string{
+			"BPFEnabled":       "true",
+			"IptablesMarkMask": "0xfff00000",
+			"TyphaKeyFile":     "/usr",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+
+		err = cfg.Validate()
+		Expect(err).To(BeAssignableToTypeOf(&config.ValidationError{}))
+		var params [][]string
+		for _, p := range err.(*config.ValidationError).Problems {
+			params = append(params, p.Params)
+		}
+		Expect(params).To(ConsistOf(
+			[]string{"TyphaCAFile", "TyphaCertFile", "TyphaKeyFile", "TyphaCN", "TyphaURISAN"},
+			[]string{"IptablesMarkMask", "BPFEnabled"},
+		))
+		Expect(cfg.Err).To(Equal(err))
+	})
+
+	It("should reject DSR without BPF mode", func() {
+		_, err := cfg.UpdateFrom(map[string]string{
+			"BPFExternalServiceMode": "dsr",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+		cfg.FelixHostname = "hostname"
+
+		err = cfg.Validate()
+		Expect(err).To(BeAssignableToTypeOf(&config.ValidationError{}))
+		Expect(err.(*config.ValidationError).Problems).To(Equal([]*config.ConfigProblem{{
+			Params:  []string{"BPFExternalServiceMode", "BPFEnabled"},
+			Message: "BPFExternalServiceMode dsr requires BPF mode to be enabled",
+		}}))
+	})
+
+	It("should reject connect-time load balancing without cgroup v2", func() {
+		config.SetCgroupV2Check(func(root string, autoMount bool) error {
+			Expect(root).To(Equal("auto"))
+			Expect(autoMount).To(BeFalse())
+			return errors.New("no cgroup v2 mount found and BPFAutoMountEnabled is false")
+		})
+		_, err := cfg.UpdateFrom(map[string]string{
+			"BPFEnabled":          "true",
+			"BPFAutoMountEnabled": "false",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+		cfg.FelixHostname = "hostname"
+
+		err = cfg.Validate()
+		Expect(err).To(BeAssignableToTypeOf(&config.ValidationError{}))
+		Expect(err.(*config.ValidationError).Problems).To(Equal([]*config.ConfigProblem{{
+			Params: []string{"BPFConnectTimeLoadBalancingEnabled", "BPFCgroupV2Root", "BPFAutoMountEnabled"},
+			Message: "BPFConnectTimeLoadBalancingEnabled requires cgroup v2: " +
+				"no cgroup v2 mount found and BPFAutoMountEnabled is false",
+		}}))
+	})
+
+	It("should not check cgroup v2 if connect-time load balancing is disabled", func() {
+		config.SetCgroupV2Check(func(string, bool) error {
+			return errors.New("no cgroup v2")
+		})
+		_, err := cfg.UpdateFrom(map[string]string{
+			"BPFEnabled":                         "true",
+			"BPFConnectTimeLoadBalancingEnabled": "false",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+		cfg.FelixHostname = "hostname"
+
+		Expect(cfg.Validate()).NotTo(HaveOccurred())
+	})
+
+	It("should reject untracked policy for workloads in BPF mode", func() {
+		_, err := cfg.UpdateFrom(map[string]string{
+			"BPFEnabled":                     "true",
+			"WorkloadUntrackedPolicyEnabled": "true",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+		cfg.FelixHostname = "hostname"
+
+		err = cfg.Validate()
+		Expect(err).To(BeAssignableToTypeOf(&config.ValidationError{}))
+		Expect(err.(*config.ValidationError).Problems).To(Equal([]*config.ConfigProblem{{
+			Params:  []string{"WorkloadUntrackedPolicyEnabled", "BPFEnabled"},
+			Message: "Untracked policy for workloads is not supported in BPF mode",
+		}}))
+	})
+
+	It("should require a label when wireguard encryption is limited to labelled peers", func() {
+		_, err := cfg.UpdateFrom(map[string]string{
+			"WireguardEnabled":         "true",
+			"WireguardEncryptionScope": "Labelled",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+		cfg.FelixHostname = "hostname"
+
+		err = cfg.Validate()
+		Expect(err).To(BeAssignableToTypeOf(&config.ValidationError{}))
+		Expect(err.(*config.ValidationError).Problems[0].Params).To(Equal(
+			[]string{"WireguardEncryptionScope", "WireguardEncryptionLabel"}))
+	})
+
+	It("should require an address for the gRPC flow log sink", func() {
+		_, err := cfg.UpdateFrom(map[string]string{
+			"FlowLogsEnabled": "true",
+			"FlowLogsSink":    "grpc",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+		cfg.FelixHostname = "hostname"
+
+		err = cfg.Validate()
+		Expect(err).To(BeAssignableToTypeOf(&config.ValidationError{}))
+		Expect(err.(*config.ValidationError).Problems[0].Params).To(Equal(
+			[]string{"FlowLogsSink", "FlowLogsGRPCAddr"}))
+	})
+
+	It("should reject a negative CPU budget", func() {
+		_, err := cfg.UpdateFrom(map[string]string{
+			"DataplaneCPUBudget": "-0.5",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+		cfg.FelixHostname = "hostname"
+
+		err = cfg.Validate()
+		Expect(err).To(BeAssignableToTypeOf(&config.ValidationError{}))
+		Expect(err.(*config.ValidationError).Problems[0].Params).To(Equal(
+			[]string{"DataplaneCPUBudget"}))
+	})
+
+	It("should require a priority for a real-time scheduling policy", func() {
+		_, err := cfg.UpdateFrom(map[string]string{
+			"DataplaneSchedPolicy": "RR",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+		cfg.FelixHostname = "hostname"
+
+		err = cfg.Validate()
+		Expect(err).To(BeAssignableToTypeOf(&config.ValidationError{}))
+		Expect(err.(*config.ValidationError).Problems[0].Params).To(Equal(
+			[]string{"DataplaneSchedPolicy", "DataplaneSchedPriority"}))
+	})
+
+	It("should reject a priority for a normal scheduling policy", func() {
+		_, err := cfg.UpdateFrom(map[string]string{
+			"DataplaneSchedPriority": "10",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+		cfg.FelixHostname = "hostname"
+
+		err = cfg.Validate()
+		Expect(err).To(BeAssignableToTypeOf(&config.ValidationError{}))
+		Expect(err.(*config.ValidationError).Problems[0].Params).To(Equal(
+			[]string{"DataplaneSchedPolicy", "DataplaneSchedPriority"}))
+	})
+
+	It("should warn about a wireguard encryption label that has no effect", func() {
+		_, err := cfg.UpdateFrom(map[string]string{
+			"WireguardEnabled":         "true",
+			"WireguardEncryptionLabel": "encrypt",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cfg.ValidationWarnings()).To(Equal([]*config.ConfigProblem{{
+			Params:  []string{"WireguardEncryptionLabel", "WireguardEncryptionScope"},
+			Message: "WireguardEncryptionLabel has no effect unless WireguardEncryptionScope is Labelled",
+		}}))
+	})
+
+	It("should warn about invalid wireguard route MTUs", func() {
+		_, err := cfg.UpdateFrom(map[string]string{
+			"WireguardEnabled":   "true",
+			"WireguardRouteMTUs": "10.0.0.0/16=1380,10.1.0.0/16=10",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cfg.ValidationWarnings()).To(Equal([]*config.ConfigProblem{{
+			Params: []string{"WireguardRouteMTUs"},
+			Message: `invalid route MTUs: invalid MTU "10" for 10.1.0.0/16, must be between 68 and 65535, ` +
+				"ignoring them",
+		}}))
+	})
+
+	It("should warn about invalid VXLAN pool VNIs", func() {
+		_, err := cfg.UpdateFrom(map[string]string{
+			"VXLANEnabled":  "true",
+			"VXLANPoolVNIs": "10.0.0.0/16=4097,10.1.0.0/16=0",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cfg.ValidationWarnings()).To(Equal([]*config.ConfigProblem{{
+			Params: []string{"VXLANPoolVNIs"},
+			Message: `invalid pool VNIs: invalid VNI "0" for 10.1.0.0/16, must be between 1 and 16777215, ` +
+				"ignoring them",
+		}}))
+	})
+
+	It("should warn that VXLAN pool VNIs have no effect without VXLAN", func() {
+		_, err := cfg.UpdateFrom(map[string]string{
+			"VXLANPoolVNIs": "10.0.0.0/16=4097",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cfg.ValidationWarnings()).To(Equal([]*config.ConfigProblem{{
+			Params:  []string{"VXLANPoolVNIs", "VXLANEnabled"},
+			Message: "VXLANPoolVNIs has no effect unless VXLAN is enabled",
+		}}))
+	})
+
+	It("should warn that nftables mode is ignored in BPF mode", func() {
+		_, err := cfg.UpdateFrom(map[string]string{
+			"BPFEnabled":   "true",
+			"NFTablesMode": "Enabled",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cfg.ValidationWarnings()).To(Equal([]*config.ConfigProblem{{
+			Params:  []string{"NFTablesMode", "BPFEnabled"},
+			Message: "nftables mode is not supported in BPF mode, ignoring NFTablesMode",
+		}}))
+	})
+
+	It("should warn about invalid BPF route classifications", func() {
+		_, err := cfg.UpdateFrom(map[string]string{
+			"BPFEnabled":              "true",
+			"BPFRouteClassifications": "10.0.0.0/16=host,10.1.0.0/16=vpn",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cfg.ValidationWarnings()).To(Equal([]*config.ConfigProblem{{
+			Params: []string{"BPFRouteClassifications"},
+			Message: `invalid route classifications: invalid classification "vpn" for 10.1.0.0/16, ` +
+				"must be one of host, workload-remote or external, ignoring them",
+		}}))
+	})
+
+	It("should warn that the CNI readiness gate needs the internal dataplane driver", func() {
+		_, err := cfg.UpdateFrom(map[string]string{
+			"UseInternalDataplaneDriver": "false",
+			"CNIReadinessGateEnabled":    "true",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cfg.ValidationWarnings()).To(Equal([]*config.ConfigProblem{{
+			Params:  []string{"CNIReadinessGateEnabled", "UseInternalDataplaneDriver"},
+			Message: "CNI readiness gate requires the internal dataplane driver, ignoring CNIReadinessGateEnabled",
+		}}))
+	})
+
+	It("should warn that BPF mode doesn't support IPv6 host addresses", func() {
+		_, err := cfg.UpdateFrom(map[string]string{
+			"BPFEnabled":                 "true",
+			"PreferredHostAddressFamily": "IPv6",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cfg.ValidationWarnings()).To(Equal([]*config.ConfigProblem{{
+			Params:  []string{"PreferredHostAddressFamily", "BPFEnabled"},
+			Message: "BPF mode only supports IPv4 host addresses",
+		}}))
+		Expect(cfg.PreferredHostIPVersion()).To(Equal(6))
+	})
+
+	It("should warn that workload MAC enforcement is not supported in BPF mode", func() {
+		_, err := cfg.UpdateFrom(map[string]string{
+			"BPFEnabled":             "true",
+			"WorkloadMACEnforcement": "Enabled",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cfg.ValidationWarnings()).To(Equal([]*config.ConfigProblem{{
+			Params:  []string{"WorkloadMACEnforcement", "BPFEnabled"},
+			Message: "Workload MAC enforcement is not supported in BPF mode",
+		}}))
+	})
+
+	It("should warn that workload drain mode is not supported in BPF mode", func() {
+		_, err := cfg.UpdateFrom(map[string]string{
+			"BPFEnabled":        "true",
+			"WorkloadDrainFile": "/var/run/calico/drain",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cfg.ValidationWarnings()).To(Equal([]*config.ConfigProblem{{
+			Params:  []string{"WorkloadDrainEnabled", "WorkloadDrainFile", "BPFEnabled"},
+			Message: "Workload drain mode is not supported in BPF mode",
+		}}))
+	})
+
+	It("should warn that drop capture is not supported in BPF mode", func() {
+		_, err := cfg.UpdateFrom(map[string]string{
+			"BPFEnabled":               "true",
+			"DropCaptureEnabled":       "true",
+			"PrometheusMetricsEnabled": "true",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cfg.ValidationWarnings()).To(Equal([]*config.ConfigProblem{{
+			Params:  []string{"DropCaptureEnabled", "BPFEnabled"},
+			Message: "Drop capture is not supported in BPF mode",
+		}}))
+	})
+
+	It("should warn that drop capture needs the Prometheus metrics server", func() {
+		_, err := cfg.UpdateFrom(map[string]string{
+			"DropCaptureEnabled": "true",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cfg.ValidationWarnings()).To(Equal([]*config.ConfigProblem{{
+			Params:  []string{"DropCaptureEnabled", "PrometheusMetricsEnabled"},
+			Message: "Captured drops are served on the Prometheus metrics port, which is disabled",
+		}}))
+	})
+
+	It("should warn about egress SNAT namespace addresses that aren't in the pool", func() {
+		_, err := cfg.UpdateFrom(map[string]string{
+			"EgressSNATAddresses":          "192.168.0.10",
+			"EgressSNATNamespaceAddresses": "tenant-a=192.168.0.10,tenant-b=192.168.0.11",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cfg.ValidationWarnings()).To(Equal([]*config.ConfigProblem{{
+			Params:  []string{"EgressSNATNamespaceAddresses", "EgressSNATAddresses"},
+			Message: "EgressSNATNamespaceAddresses refers to addresses that aren't in EgressSNATAddresses, ignoring them",
+		}}))
+	})
+
+	It("should warn that egress SNAT is not supported in BPF mode", func() {
+		_, err := cfg.UpdateFrom(map[string]string{
+			"BPFEnabled":          "true",
+			"EgressSNATAddresses": "192.168.0.10",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cfg.ValidationWarnings()).To(Equal([]*config.ConfigProblem{{
+			Params:  []string{"EgressSNATAddresses", "BPFEnabled"},
+			Message: "Egress SNAT is not supported in BPF mode",
+		}}))
+	})
+
+	It("should warn that conntrack accounting is not supported in BPF mode", func() {
+		_, err := cfg.UpdateFrom(map[string]string{
+			"BPFEnabled":                 "true",
+			"ConntrackAccountingEnabled": "true",
+			"PrometheusMetricsEnabled":   "true",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cfg.ValidationWarnings()).To(Equal([]*config.ConfigProblem{{
+			Params:  []string{"ConntrackAccountingEnabled", "BPFEnabled"},
+			Message: "Conntrack accounting is not supported in BPF mode",
+		}}))
+	})
+
+	It("should warn that conntrack accounting needs the Prometheus metrics server", func() {
+		_, err := cfg.UpdateFrom(map[string]string{
+			"ConntrackAccountingEnabled": "true",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cfg.ValidationWarnings()).To(Equal([]*config.ConfigProblem{{
+			Params:  []string{"ConntrackAccountingEnabled", "PrometheusMetricsEnabled"},
+			Message: "Conntrack accounting is reported via Prometheus metrics, which are disabled",
+		}}))
+	})
+
+	It("should warn that route table debug needs the Prometheus metrics server", func() {
+		_, err := cfg.UpdateFrom(map[string]string{
+			"DebugRouteTablesEnabled": "true",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cfg.ValidationWarnings()).To(Equal([]*config.ConfigProblem{{
+			Params:  []string{"DebugRouteTablesEnabled", "PrometheusMetricsEnabled"},
+			Message: "Route table state is served on the Prometheus metrics port, which is disabled",
+		}}))
+	})
+
+	It("should warn that BPF memory accounting needs the BPF dataplane", func() {
+		_, err := cfg.UpdateFrom(map[string]string{
+			"DebugBPFMemoryEnabled":    "true",
+			"PrometheusMetricsEnabled": "true",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cfg.ValidationWarnings()).To(Equal([]*config.ConfigProblem{{
+			Params:  []string{"DebugBPFMemoryEnabled", "BPFEnabled"},
+			Message: "BPF memory accounting only applies to the BPF dataplane",
+		}}))
+	})
+
+	It("should warn that workload connection rate limiting is not supported in BPF mode", func() {
+		_, err := cfg.UpdateFrom(map[string]string{
+			"BPFEnabled":                   "true",
+			"WorkloadConnRateLimitEnabled": "true",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cfg.ValidationWarnings()).To(Equal([]*config.ConfigProblem{{
+			Params:  []string{"WorkloadConnRateLimitEnabled", "BPFEnabled"},
+			Message: "Workload connection rate limiting is not supported in BPF mode",
+		}}))
+	})
+
+	It("should warn about invalid external networks", func() {
+		_, err := cfg.UpdateFrom(map[string]string{
+			"ExternalNetworksEnabled":   "true",
+			"ExternalNetworks":          "dc2=10.1.0.0/16;garbage",
+			"ExternalNetworkTreatments": "dc2=tunnel;teleport",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cfg.ValidationWarnings()).To(Equal([]*config.ConfigProblem{{
+			Params: []string{"ExternalNetworks", "ExternalNetworkTreatments"},
+			Message: `invalid external network config: invalid CIDR "garbage" in external network "dc2"; ` +
+				`unknown treatment "teleport" for external network "dc2", ignoring the invalid parts`,
+		}}))
+	})
+
+	It("should warn about invalid static routes", func() {
+		_, err := cfg.UpdateFrom(map[string]string{
+			"StaticRoutes": "dc2=10.1.0.0/16 via 192.168.0.1,bad=10.2.0.0/16 via fd00::1,nowhere=10.3.0.0/16",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cfg.ValidationWarnings()).To(Equal([]*config.ConfigProblem{{
+			Params: []string{"StaticRoutes"},
+			Message: `invalid static routes: invalid next hop "fd00::1" in static route "bad"; ` +
+				`static route "nowhere" needs a next hop or a device, ignoring them`,
+		}}))
+	})
+
+	It("should warn that external networks need ExternalNetworksEnabled", func() {
+		_, err := cfg.UpdateFrom(map[string]string{
+			"ExternalNetworks": "dc2=10.1.0.0/16",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cfg.ValidationWarnings()).To(Equal([]*config.ConfigProblem{{
+			Params:  []string{"ExternalNetworks", "ExternalNetworkTreatments", "ExternalNetworksEnabled"},
+			Message: "ExternalNetworks has no effect unless ExternalNetworksEnabled is set",
+		}}))
+	})
+
+	It("should have no warnings by default", func() {
+		Expect(cfg.ValidationWarnings()).To(BeEmpty())
+	})
+
+	It("should accept non-overlapping name prefixes", func() {
+		_, err := cfg.UpdateFrom(map[string]string{
+			"IptablesChainNamePrefix": "blu-",
+			"IptablesRuleHashPrefix":  "blu:",
+			"IPSetNamePrefix":         "blu",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+		cfg.FelixHostname = "hostname"
+
+		Expect(cfg.Validate()).To(Succeed())
+		Expect(cfg.ValidationWarnings()).To(BeEmpty())
+	})
+
+	It("should report mark collisions clearly", func() {
+		_, err := cfg.UpdateFrom(map[string]string{
+			"IptablesMarkMask": "0xffff4000",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+		cfg.FelixHostname = "hostname"
+
+		err = cfg.Validate()
+		Expect(err).To(BeAssignableToTypeOf(&config.ValidationError{}))
+		Expect(err.(*config.ValidationError).Problems).To(Equal([]*config.ConfigProblem{{
+			Params: []string{"IptablesMarkMask"},
+			Message: "IptablesMarkMask (0xffff4000) overlaps with kube-proxy masquerade mark (0x4000) in bits 0x4000; " +
+				"change IptablesMarkMask or set IptablesMarkMaskAutoAdjust to avoid the colliding bits",
+		}}))
+	})
+
+	It("should avoid colliding mark bits if allowed", func() {
+		_, err := cfg.UpdateFrom(map[string]string{
+			"IptablesMarkMask":           "0xffff4000",
+			"IptablesMarkMaskAutoAdjust": "true",
+		}, config.ConfigFile)
+		Expect(err).NotTo(HaveOccurred())
+
+		Expect(cfg.UsableIptablesMarkBits()).To(Equal(uint32(0xffff0000)))
+		Expect(cfg.ValidationWarnings()).To(Equal([]*config.ConfigProblem{{
+			Params: []string{"IptablesMarkMask", "IptablesMarkMaskAutoAdjust"},
+			Message: "IptablesMarkMask (0xffff4000) overlaps with kube-proxy masquerade mark (0x4000) in bits 0x4000, " +
+				"Felix will not use those bits",
+		}}))
+	})
+
+	It("should require exactly the BPF dataplane's mark bits in BPF mode", func() {
+		validate := func(mask uint32) error {
+			cfg := config.New()
+			_, err := cfg.UpdateFrom(map[string]string{
+				"BPFEnabled":       "true",
+				"IptablesMarkMask": fmt.Sprintf("%#x", mask),
+			}, config.ConfigFile)
+			Expect(err).NotTo(HaveOccurred())
+			cfg.FelixHostname = "hostname"
+			return cfg.Validate()
+		}
+
+		Expect(validate(tc.MarksMask | 0xf0000)).To(Succeed())
+		Expect(validate((tc.MarksMask &^ 0x80000000) | 0xf0000)).To(HaveOccurred())
+	})
+})
+
 var _ = DescribeTable("Config InterfaceExclude",
 	func(excludeList string, expected []*regexp.Regexp) {
 		cfg := config.New()
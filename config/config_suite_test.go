@@ -23,12 +23,26 @@ import (
 	"github.com/onsi/ginkgo/reporters"
 
 	"github.com/projectcalico/libcalico-go/lib/testutils"
+
+	"github.com/projectcalico/felix/config"
 )
 
 func init() {
 	testutils.HookLogrusForGinkgo()
 }
 
+// Validation in BPF mode checks the host's cgroup v2 mounts; pretend that they're usable so that
+// the tests don't depend on the test environment.  Tests of that check install their own.
+var restoreCgroupV2Check func()
+
+var _ = BeforeEach(func() {
+	restoreCgroupV2Check = config.SetCgroupV2Check(func(string, bool) error { return nil })
+})
+
+var _ = AfterEach(func() {
+	restoreCgroupV2Check()
+})
+
 func TestConfig(t *testing.T) {
 	RegisterFailHandler(Fail)
 	junitReporter := reporters.NewJUnitReporter("../report/config_suite.xml")
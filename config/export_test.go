@@ -0,0 +1,27 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+// CgroupV2Problem exposes cgroupV2Problem to the config_test package.
+var CgroupV2Problem = cgroupV2Problem
+
+// SetCgroupV2Check replaces the check that validation uses to find a cgroup v2 mount.  Returns
+// a function that restores the real check.
+func SetCgroupV2Check(check func(root string, autoMount bool) error) (restore func()) {
+	cgroupV2Check = check
+	return func() {
+		cgroupV2Check = checkCgroupV2
+	}
+}
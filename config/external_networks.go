@@ -0,0 +1,102 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// The forwarding treatments that can be given to an external network, see ExternalNetworksEnabled.
+const (
+	ExternalNetworkTreatmentTunnel       = "tunnel"
+	ExternalNetworkTreatmentRPFExempt    = "rpf-exempt"
+	ExternalNetworkTreatmentNoMasquerade = "no-masquerade"
+)
+
+// ExternalNetworkTreatments are all the forwarding treatments that can be given to an external
+// network.
+var ExternalNetworkTreatments = []string{
+	ExternalNetworkTreatmentTunnel,
+	ExternalNetworkTreatmentRPFExempt,
+	ExternalNetworkTreatmentNoMasquerade,
+}
+
+// ExternalNetworkCIDRsByTreatment combines the ExternalNetworks and ExternalNetworkTreatments
+// parameters into the CIDRs that have each treatment.  Every treatment is present in the result,
+// possibly with no CIDRs.  Invalid CIDRs, unknown treatments and treatments for networks that
+// don't exist are skipped and reported in the returned error.
+func ExternalNetworkCIDRsByTreatment(networks, treatments map[string]string) (map[string][]string, error) {
+	var problems []string
+	cidrsByTreatment := map[string][]string{}
+	seen := map[string]map[string]bool{}
+	for _, t := range ExternalNetworkTreatments {
+		cidrsByTreatment[t] = []string{}
+		seen[t] = map[string]bool{}
+	}
+
+	var names []string
+	for name := range treatments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		rawCIDRs, ok := networks[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("treatments for unknown external network %q", name))
+			continue
+		}
+		var cidrs []string
+		for _, raw := range splitExternalNetworkList(rawCIDRs) {
+			_, ipNet, err := net.ParseCIDR(raw)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("invalid CIDR %q in external network %q", raw, name))
+				continue
+			}
+			cidrs = append(cidrs, ipNet.String())
+		}
+		for _, t := range splitExternalNetworkList(treatments[name]) {
+			if _, ok := cidrsByTreatment[t]; !ok {
+				problems = append(problems, fmt.Sprintf("unknown treatment %q for external network %q", t, name))
+				continue
+			}
+			for _, cidr := range cidrs {
+				if seen[t][cidr] {
+					continue
+				}
+				seen[t][cidr] = true
+				cidrsByTreatment[t] = append(cidrsByTreatment[t], cidr)
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return cidrsByTreatment, fmt.Errorf("invalid external network config: %s", strings.Join(problems, "; "))
+	}
+	return cidrsByTreatment, nil
+}
+
+func splitExternalNetworkList(raw string) []string {
+	var items []string
+	for _, item := range strings.Split(raw, ";") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
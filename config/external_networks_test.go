@@ -0,0 +1,63 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/config"
+)
+
+var _ = Describe("ExternalNetworkCIDRsByTreatment", func() {
+	It("should return every treatment when there are no networks", func() {
+		cidrs, err := config.ExternalNetworkCIDRsByTreatment(nil, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cidrs).To(Equal(map[string][]string{
+			config.ExternalNetworkTreatmentTunnel:       {},
+			config.ExternalNetworkTreatmentRPFExempt:    {},
+			config.ExternalNetworkTreatmentNoMasquerade: {},
+		}))
+	})
+
+	It("should combine the networks' CIDRs by treatment", func() {
+		cidrs, err := config.ExternalNetworkCIDRsByTreatment(map[string]string{
+			"dc2":    "10.1.0.0/16; 10.2.0.0/16",
+			"peers":  "192.168.0.0/24;10.1.0.0/16",
+			"unused": "172.16.0.0/12",
+		}, map[string]string{
+			"dc2":   "tunnel;no-masquerade",
+			"peers": "no-masquerade;rpf-exempt",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cidrs).To(Equal(map[string][]string{
+			config.ExternalNetworkTreatmentTunnel:       {"10.1.0.0/16", "10.2.0.0/16"},
+			config.ExternalNetworkTreatmentRPFExempt:    {"192.168.0.0/24", "10.1.0.0/16"},
+			config.ExternalNetworkTreatmentNoMasquerade: {"10.1.0.0/16", "10.2.0.0/16", "192.168.0.0/24"},
+		}))
+	})
+
+	It("should skip and report invalid parts", func() {
+		cidrs, err := config.ExternalNetworkCIDRsByTreatment(map[string]string{
+			"dc2": "10.1.0.0/16;10.2.0.1",
+		}, map[string]string{
+			"dc2":     "tunnel",
+			"missing": "tunnel",
+		})
+		Expect(err).To(MatchError(`invalid external network config: invalid CIDR "10.2.0.1" in external network "dc2"; ` +
+			`treatments for unknown external network "missing"`))
+		Expect(cidrs[config.ExternalNetworkTreatmentTunnel]).To(Equal([]string{"10.1.0.0/16"}))
+	})
+})
@@ -23,6 +23,7 @@ import (
 	"os/exec"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -299,6 +300,20 @@ func (p *Ipv4Param) Parse(raw string) (result interface{}, err error) {
 	return
 }
 
+type Ipv6Param struct {
+	Metadata
+}
+
+func (p *Ipv6Param) Parse(raw string) (result interface{}, err error) {
+	res := net.ParseIP(raw)
+	if res == nil || res.To4() != nil {
+		err = p.parseFailed(raw, "invalid IPv6 address")
+		return
+	}
+	result = res
+	return
+}
+
 type PortListParam struct {
 	Metadata
 }
@@ -571,6 +586,81 @@ func (p *RouteTableRangeParam) Parse(raw string) (result interface{}, err error)
 	return
 }
 
+type RouteTableRangeListParam struct {
+	Metadata
+}
+
+func (p *RouteTableRangeListParam) Parse(raw string) (result interface{}, err error) {
+	var ranges []idalloc.IndexRange
+	for _, r := range strings.Split(raw, ",") {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+		m := regexp.MustCompile(`^(\d+)(?:-(\d+))?$`).FindStringSubmatch(r)
+		if m == nil {
+			err = p.parseFailed(raw, "must be a list of route table indices or ranges within 1-250")
+			return
+		}
+		min, _ := strconv.Atoi(m[1])
+		max := min
+		if m[2] != "" {
+			max, _ = strconv.Atoi(m[2])
+		}
+		if min < 1 || max < min || max > 250 {
+			err = p.parseFailed(raw, "must be a list of route table indices or ranges within 1-250")
+			return
+		}
+		ranges = append(ranges, idalloc.IndexRange{Min: min, Max: max})
+	}
+	result = ranges
+	return
+}
+
+// maxCPU is the highest CPU number that a CPUListParam accepts; it matches the size of the CPU
+// set that the kernel's scheduler calls take.
+const maxCPU = 1023
+
+// CPUListParam parses a list of CPUs and ranges of CPUs, such as "0-3,8", as used by taskset.
+// The result is sorted and has no duplicates.
+type CPUListParam struct {
+	Metadata
+}
+
+func (p *CPUListParam) Parse(raw string) (result interface{}, err error) {
+	cpus := map[int]bool{}
+	for _, r := range strings.Split(raw, ",") {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+		m := regexp.MustCompile(`^(\d+)(?:-(\d+))?$`).FindStringSubmatch(r)
+		if m == nil {
+			err = p.parseFailed(raw, fmt.Sprintf("must be a list of CPUs or ranges of CPUs within 0-%d", maxCPU))
+			return
+		}
+		min, _ := strconv.Atoi(m[1])
+		max := min
+		if m[2] != "" {
+			max, _ = strconv.Atoi(m[2])
+		}
+		if max < min || max > maxCPU {
+			err = p.parseFailed(raw, fmt.Sprintf("must be a list of CPUs or ranges of CPUs within 0-%d", maxCPU))
+			return
+		}
+		for cpu := min; cpu <= max; cpu++ {
+			cpus[cpu] = true
+		}
+	}
+	var list []int
+	for cpu := range cpus {
+		list = append(list, cpu)
+	}
+	sort.Ints(list)
+	result = list
+	return
+}
+
 type KeyValueListParam struct {
 	Metadata
 }
@@ -0,0 +1,61 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// maxVXLANVNI is the largest 24-bit VXLAN network identifier.
+const maxVXLANVNI = 1<<24 - 1
+
+// ParsePoolVNIs parses a comma-separated list of <pool cidr>=<vni> entries, such as the value of
+// the VXLANPoolVNIs parameter, into a map from CIDR, in canonical form, to VNI.  Invalid entries
+// are skipped and reported in the returned error.
+func ParsePoolVNIs(raw string) (map[string]int, error) {
+	var problems []string
+	parsed := map[string]int{}
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			problems = append(problems, fmt.Sprintf("%q isn't of the form <cidr>=<vni>", entry))
+			continue
+		}
+		cidr, rawVNI := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		_, pool, err := net.ParseCIDR(cidr)
+		if err != nil || pool.IP.To4() == nil {
+			problems = append(problems, fmt.Sprintf("invalid IPv4 CIDR %q", cidr))
+			continue
+		}
+		vni, err := strconv.Atoi(rawVNI)
+		if err != nil || vni < 1 || vni > maxVXLANVNI {
+			problems = append(problems, fmt.Sprintf("invalid VNI %q for %s, must be between 1 and %d",
+				rawVNI, cidr, maxVXLANVNI))
+			continue
+		}
+		parsed[pool.String()] = vni
+	}
+	if len(problems) > 0 {
+		return parsed, fmt.Errorf("invalid pool VNIs: %s", strings.Join(problems, "; "))
+	}
+	return parsed, nil
+}
@@ -0,0 +1,43 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/config"
+)
+
+var _ = DescribeTable("ParsePoolVNIs",
+	func(raw string, expected map[string]int, expectedErr string) {
+		vnis, err := config.ParsePoolVNIs(raw)
+		if expectedErr != "" {
+			Expect(err).To(MatchError(expectedErr))
+		} else {
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(vnis).To(Equal(expected))
+	},
+	Entry("empty", "", map[string]int{}, ""),
+	Entry("non-canonical CIDRs", "10.1.2.3/16=4097, 10.2.0.0/16=4098",
+		map[string]int{"10.1.0.0/16": 4097, "10.2.0.0/16": 4098}, ""),
+	Entry("missing VNI", "10.1.0.0/16", map[string]int{},
+		`invalid pool VNIs: "10.1.0.0/16" isn't of the form <cidr>=<vni>`),
+	Entry("IPv6 pool, keeping the valid entries", "fd00:10::/64=4097,10.2.0.0/16=4098",
+		map[string]int{"10.2.0.0/16": 4098}, `invalid pool VNIs: invalid IPv4 CIDR "fd00:10::/64"`),
+	Entry("VNI too big", "10.1.0.0/16=16777216", map[string]int{},
+		`invalid pool VNIs: invalid VNI "16777216" for 10.1.0.0/16, must be between 1 and 16777215`),
+)
@@ -0,0 +1,65 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// The classifications that BPFRouteClassifications can give a CIDR.
+const (
+	RouteClassHost           = "host"
+	RouteClassWorkloadRemote = "workload-remote"
+	RouteClassExternal       = "external"
+)
+
+// ParseRouteClassifications parses a comma-separated list of <cidr>=<classification> entries,
+// such as the value of the BPFRouteClassifications parameter, into a map from CIDR, in canonical
+// form, to classification.  Invalid entries are skipped and reported in the returned error.
+func ParseRouteClassifications(raw string) (map[string]string, error) {
+	var problems []string
+	parsed := map[string]string{}
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			problems = append(problems, fmt.Sprintf("%q isn't of the form <cidr>=<classification>", entry))
+			continue
+		}
+		cidr, class := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		_, dst, err := net.ParseCIDR(cidr)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("invalid CIDR %q", cidr))
+			continue
+		}
+		switch class {
+		case RouteClassHost, RouteClassWorkloadRemote, RouteClassExternal:
+		default:
+			problems = append(problems, fmt.Sprintf("invalid classification %q for %s, must be one of %s, %s or %s",
+				class, cidr, RouteClassHost, RouteClassWorkloadRemote, RouteClassExternal))
+			continue
+		}
+		parsed[dst.String()] = class
+	}
+	if len(problems) > 0 {
+		return parsed, fmt.Errorf("invalid route classifications: %s", strings.Join(problems, "; "))
+	}
+	return parsed, nil
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/config"
+)
+
+var _ = DescribeTable("ParseRouteClassifications",
+	func(raw string, expected map[string]string, expectedErr string) {
+		classes, err := config.ParseRouteClassifications(raw)
+		if expectedErr != "" {
+			Expect(err).To(MatchError(expectedErr))
+		} else {
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(classes).To(Equal(expected))
+	},
+	Entry("empty", "", map[string]string{}, ""),
+	Entry("all classifications, non-canonical CIDR",
+		"10.1.2.3/16=host, 10.2.0.0/16=workload-remote, 10.3.0.0/24=external",
+		map[string]string{
+			"10.1.0.0/16": config.RouteClassHost,
+			"10.2.0.0/16": config.RouteClassWorkloadRemote,
+			"10.3.0.0/24": config.RouteClassExternal,
+		}, ""),
+	Entry("missing classification", "10.1.0.0/16", map[string]string{},
+		`invalid route classifications: "10.1.0.0/16" isn't of the form <cidr>=<classification>`),
+	Entry("bad CIDR, keeping the valid entries", "10.1.0.0=host,10.2.0.0/16=external",
+		map[string]string{"10.2.0.0/16": config.RouteClassExternal},
+		`invalid route classifications: invalid CIDR "10.1.0.0"`),
+	Entry("unknown classification", "10.1.0.0/16=vpn", map[string]string{},
+		`invalid route classifications: invalid classification "vpn" for 10.1.0.0/16, `+
+			`must be one of host, workload-remote or external`),
+)
@@ -0,0 +1,68 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+const (
+	minIPv4RouteMTU = 68
+	minIPv6RouteMTU = 1280
+	maxRouteMTU     = 65535
+)
+
+// ParseRouteMTUs parses a comma-separated list of <cidr>=<mtu> entries, such as the value of the
+// WireguardRouteMTUs parameter, into a map from CIDR, in canonical form, to MTU.  Invalid entries
+// are skipped and reported in the returned error.
+func ParseRouteMTUs(raw string) (map[string]int, error) {
+	var problems []string
+	parsed := map[string]int{}
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 {
+			problems = append(problems, fmt.Sprintf("%q isn't of the form <cidr>=<mtu>", entry))
+			continue
+		}
+		cidr, rawMTU := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		_, dst, err := net.ParseCIDR(cidr)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("invalid CIDR %q", cidr))
+			continue
+		}
+		minMTU := minIPv4RouteMTU
+		if dst.IP.To4() == nil {
+			minMTU = minIPv6RouteMTU
+		}
+		mtu, err := strconv.Atoi(rawMTU)
+		if err != nil || mtu < minMTU || mtu > maxRouteMTU {
+			problems = append(problems, fmt.Sprintf("invalid MTU %q for %s, must be between %d and %d",
+				rawMTU, cidr, minMTU, maxRouteMTU))
+			continue
+		}
+		parsed[dst.String()] = mtu
+	}
+	if len(problems) > 0 {
+		return parsed, fmt.Errorf("invalid route MTUs: %s", strings.Join(problems, "; "))
+	}
+	return parsed, nil
+}
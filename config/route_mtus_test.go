@@ -0,0 +1,45 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/config"
+)
+
+var _ = DescribeTable("ParseRouteMTUs",
+	func(raw string, expected map[string]int, expectedErr string) {
+		mtus, err := config.ParseRouteMTUs(raw)
+		if expectedErr != "" {
+			Expect(err).To(MatchError(expectedErr))
+		} else {
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(mtus).To(Equal(expected))
+	},
+	Entry("empty", "", map[string]int{}, ""),
+	Entry("IPv4 and IPv6, non-canonical CIDR", "10.1.2.3/16=1380, fd00:10::/64=1360",
+		map[string]int{"10.1.0.0/16": 1380, "fd00:10::/64": 1360}, ""),
+	Entry("missing MTU", "10.1.0.0/16", map[string]int{},
+		`invalid route MTUs: "10.1.0.0/16" isn't of the form <cidr>=<mtu>`),
+	Entry("bad CIDR, keeping the valid entries", "10.1.0.0=1380,10.2.0.0/16=1400",
+		map[string]int{"10.2.0.0/16": 1400}, `invalid route MTUs: invalid CIDR "10.1.0.0"`),
+	Entry("MTU too small for IPv6", "fd00:10::/64=1000", map[string]int{},
+		`invalid route MTUs: invalid MTU "1000" for fd00:10::/64, must be between 1280 and 65535`),
+	Entry("MTU not a number", "10.1.0.0/16=big", map[string]int{},
+		`invalid route MTUs: invalid MTU "big" for 10.1.0.0/16, must be between 68 and 65535`),
+)
@@ -0,0 +1,111 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// StaticRoute is an operator-defined route from the StaticRoutes parameter.
+type StaticRoute struct {
+	Name string
+	// CIDR is the route's destination, in canonical form.
+	CIDR    string
+	NextHop string
+	Device  string
+	// Table is the routing table index; 0 means the main table.
+	Table int
+}
+
+// IPVersion returns 4 or 6, depending on the route's destination.
+func (r StaticRoute) IPVersion() uint8 {
+	if strings.Contains(r.CIDR, ":") {
+		return 6
+	}
+	return 4
+}
+
+// ParseStaticRoutes parses the value of the StaticRoutes parameter.  Invalid routes are skipped
+// and reported in the returned error.
+func ParseStaticRoutes(routes map[string]string) (map[string]StaticRoute, error) {
+	var names []string
+	for name := range routes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []string
+	parsed := map[string]StaticRoute{}
+	for _, name := range names {
+		route, err := ParseStaticRoute(name, routes[name])
+		if err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		parsed[name] = route
+	}
+	if len(problems) > 0 {
+		return parsed, fmt.Errorf("invalid static routes: %s", strings.Join(problems, "; "))
+	}
+	return parsed, nil
+}
+
+// ParseStaticRoute parses a route of the form "<cidr> [via <next hop>] [dev <device>] [table
+// <index>]".
+func ParseStaticRoute(name, spec string) (StaticRoute, error) {
+	route := StaticRoute{Name: name}
+	fields := strings.Fields(spec)
+	if len(fields) == 0 {
+		return route, fmt.Errorf("static route %q is empty", name)
+	}
+	_, dst, err := net.ParseCIDR(fields[0])
+	if err != nil {
+		return route, fmt.Errorf("invalid CIDR %q in static route %q", fields[0], name)
+	}
+	route.CIDR = dst.String()
+
+	for i := 1; i < len(fields); i += 2 {
+		if i+1 >= len(fields) {
+			return route, fmt.Errorf("missing value for %q in static route %q", fields[i], name)
+		}
+		value := fields[i+1]
+		switch fields[i] {
+		case "via":
+			gw := net.ParseIP(value)
+			if gw == nil || (gw.To4() == nil) != (dst.IP.To4() == nil) {
+				return route, fmt.Errorf("invalid next hop %q in static route %q", value, name)
+			}
+			route.NextHop = gw.String()
+		case "dev":
+			route.Device = value
+		case "table":
+			table, err := strconv.Atoi(value)
+			if err != nil || table < 0 || table > 0xffffffff {
+				return route, fmt.Errorf("invalid table %q in static route %q", value, name)
+			}
+			route.Table = table
+		default:
+			return route, fmt.Errorf("unknown option %q in static route %q", fields[i], name)
+		}
+	}
+	if route.NextHop == "" && route.Device == "" {
+		return route, fmt.Errorf("static route %q needs a next hop or a device", name)
+	}
+	return route, nil
+}
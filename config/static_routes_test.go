@@ -0,0 +1,56 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config_test
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/config"
+)
+
+var _ = DescribeTable("ParseStaticRoute",
+	func(spec string, expected config.StaticRoute, expectedErr string) {
+		route, err := config.ParseStaticRoute("r", spec)
+		if expectedErr != "" {
+			Expect(err).To(MatchError(expectedErr))
+			return
+		}
+		Expect(err).NotTo(HaveOccurred())
+		expected.Name = "r"
+		Expect(route).To(Equal(expected))
+	},
+	Entry("next hop", "10.1.0.0/16 via 192.168.0.1",
+		config.StaticRoute{CIDR: "10.1.0.0/16", NextHop: "192.168.0.1"}, ""),
+	Entry("device and table", "172.16.0.0/12 dev eth1 table 100",
+		config.StaticRoute{CIDR: "172.16.0.0/12", Device: "eth1", Table: 100}, ""),
+	Entry("all options, non-canonical CIDR", "10.1.2.3/16 table 5 dev eth1 via 10.0.0.1",
+		config.StaticRoute{CIDR: "10.1.0.0/16", NextHop: "10.0.0.1", Device: "eth1", Table: 5}, ""),
+	Entry("IPv6", "fd00:10::/64 via fd00::1",
+		config.StaticRoute{CIDR: "fd00:10::/64", NextHop: "fd00::1"}, ""),
+	Entry("empty", "", config.StaticRoute{}, `static route "r" is empty`),
+	Entry("bad CIDR", "10.1.0.0 via 10.0.0.1", config.StaticRoute{},
+		`invalid CIDR "10.1.0.0" in static route "r"`),
+	Entry("next hop of the wrong family", "10.1.0.0/16 via fd00::1", config.StaticRoute{},
+		`invalid next hop "fd00::1" in static route "r"`),
+	Entry("bad table", "10.1.0.0/16 dev eth1 table main", config.StaticRoute{},
+		`invalid table "main" in static route "r"`),
+	Entry("missing value", "10.1.0.0/16 dev", config.StaticRoute{},
+		`missing value for "dev" in static route "r"`),
+	Entry("unknown option", "10.1.0.0/16 dev eth1 metric 10", config.StaticRoute{},
+		`unknown option "metric" in static route "r"`),
+	Entry("no next hop or device", "10.1.0.0/16 table 100", config.StaticRoute{},
+		`static route "r" needs a next hop or a device`),
+)
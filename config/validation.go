@@ -0,0 +1,488 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/markbits"
+)
+
+// bpfMarksMask is the set of mark bits reserved by the BPF dataplane.  It must match
+// tc.MarksMask; we can't import the bpf packages here because they depend (in their tests)
+// on packages that depend on this one.
+const bpfMarksMask uint32 = 0xfff00000
+
+// ConfigProblem describes a single inconsistency in the config, along with the parameters that
+// contribute to it.
+type ConfigProblem struct {
+	Params  []string
+	Message string
+}
+
+func (p *ConfigProblem) Error() string {
+	if len(p.Params) == 0 {
+		return p.Message
+	}
+	return fmt.Sprintf("%s (%s)", p.Message, strings.Join(p.Params, ", "))
+}
+
+// ValidationError is returned by Config.Validate() when the config can't be used.  It lists all
+// the problems that were found, rather than just the first.
+type ValidationError struct {
+	Problems []*ConfigProblem
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Problems))
+	for i, p := range e.Problems {
+		msgs[i] = p.Error()
+	}
+	return "invalid configuration: " + strings.Join(msgs, "; ")
+}
+
+// validationErrors returns the problems that make the config unusable.
+func (config *Config) validationErrors() (problems []*ConfigProblem) {
+	addProblem := func(msg string, params ...string) {
+		problems = append(problems, &ConfigProblem{Params: params, Message: msg})
+	}
+
+	if config.FelixHostname == "" {
+		addProblem("Failed to determine hostname", "FelixHostname")
+	}
+
+	if config.DatastoreType == "etcdv3" && len(config.EtcdEndpoints) == 0 {
+		if config.EtcdScheme == "" {
+			addProblem("EtcdEndpoints and EtcdScheme both missing", "EtcdEndpoints", "EtcdScheme")
+		}
+		if config.EtcdAddr == "" {
+			addProblem("EtcdEndpoints and EtcdAddr both missing", "EtcdEndpoints", "EtcdAddr")
+		}
+	}
+
+	// If any client-side TLS config parameters are specified, they _all_ must be - except that
+	// either TyphaCN or TyphaURISAN may be left unset.
+	if config.TyphaCAFile != "" ||
+		config.TyphaCertFile != "" ||
+		config.TyphaKeyFile != "" ||
+		config.TyphaCN != "" ||
+		config.TyphaURISAN != "" {
+		// Some TLS config specified.
+		if config.TyphaKeyFile == "" ||
+			config.TyphaCertFile == "" ||
+			config.TyphaCAFile == "" ||
+			(config.TyphaCN == "" && config.TyphaURISAN == "") {
+			addProblem("If any Felix-Typha TLS config parameters are specified,"+
+				" they _all_ must be"+
+				" - except that either TyphaCN or TyphaURISAN may be left unset.",
+				"TyphaCAFile", "TyphaCertFile", "TyphaKeyFile", "TyphaCN", "TyphaURISAN")
+		}
+	}
+
+	if config.WireguardEnabled && config.WireguardEncryptionScope == "Labelled" && config.WireguardEncryptionLabel == "" {
+		addProblem("WireguardEncryptionLabel must be set when WireguardEncryptionScope is Labelled",
+			"WireguardEncryptionScope", "WireguardEncryptionLabel")
+	}
+
+	if config.DataplaneCPUBudget < 0 {
+		addProblem("DataplaneCPUBudget must not be negative", "DataplaneCPUBudget")
+	}
+	switch config.DataplaneSchedPolicy {
+	case "FIFO", "RR":
+		if config.DataplaneSchedPriority < 1 {
+			addProblem("DataplaneSchedPriority must be 1-99 for a real-time DataplaneSchedPolicy",
+				"DataplaneSchedPolicy", "DataplaneSchedPriority")
+		}
+	default:
+		if config.DataplaneSchedPriority != 0 {
+			addProblem("DataplaneSchedPriority must be 0 unless DataplaneSchedPolicy is FIFO or RR",
+				"DataplaneSchedPolicy", "DataplaneSchedPriority")
+		}
+	}
+	if config.FlowLogsEnabled && config.FlowLogsSink == "grpc" && config.FlowLogsGRPCAddr == "" {
+		addProblem("FlowLogsGRPCAddr must be set when FlowLogsSink is grpc",
+			"FlowLogsSink", "FlowLogsGRPCAddr")
+	}
+
+	if config.UseInternalDataplaneDriver {
+		// The remaining checks mirror the assumptions that the internal dataplane driver makes
+		// at start of day.  Catching them here means that we report not-ready with a clear
+		// reason rather than panicking inside the driver.
+		if config.BPFEnabled && config.IptablesMarkMask&bpfMarksMask != bpfMarksMask {
+			addProblem(fmt.Sprintf("IptablesMarkMask (%#x) must include the bits used by the BPF dataplane (%#x)",
+				config.IptablesMarkMask, bpfMarksMask), "IptablesMarkMask", "BPFEnabled")
+		}
+		if config.BPFEnabled && config.WorkloadUntrackedPolicyEnabled {
+			// The BPF programs always track connections; the untracked policy would be
+			// silently ignored.
+			addProblem("Untracked policy for workloads is not supported in BPF mode",
+				"WorkloadUntrackedPolicyEnabled", "BPFEnabled")
+		}
+		if !config.BPFEnabled && config.BPFExternalServiceMode == "dsr" {
+			addProblem("BPFExternalServiceMode dsr requires BPF mode to be enabled",
+				"BPFExternalServiceMode", "BPFEnabled")
+		}
+		if config.BPFEnabled && config.BPFConnectTimeLoadBalancingEnabled {
+			if err := cgroupV2Check(config.BPFCgroupV2Root, config.BPFAutoMountEnabled); err != nil {
+				addProblem(fmt.Sprintf("BPFConnectTimeLoadBalancingEnabled requires cgroup v2: %v", err),
+					"BPFConnectTimeLoadBalancingEnabled", "BPFCgroupV2Root", "BPFAutoMountEnabled")
+			}
+		}
+		for _, c := range config.markCollisions() {
+			msg := c.String()
+			if c.A.Owner == iptablesMarkOwner {
+				if config.IptablesMarkMaskAutoAdjust {
+					// Felix will avoid the colliding bits; reported as a warning.
+					continue
+				}
+				msg += "; change IptablesMarkMask or set IptablesMarkMaskAutoAdjust to avoid the colliding bits"
+			}
+			addProblem(msg, append(markParams(c.A), markParams(c.B)...)...)
+		}
+
+		if avail, needed := bits.OnesCount32(config.UsableIptablesMarkBits()), config.requiredMarkBits(); avail < needed {
+			params := []string{"IptablesMarkMask"}
+			if config.BPFEnabled {
+				params = append(params, "BPFEnabled")
+			}
+			if config.WireguardEnabled {
+				params = append(params, "WireguardEnabled")
+			}
+			if config.IptablesMarkMaskAutoAdjust {
+				params = append(params, "IptablesMarkMaskAutoAdjust")
+			}
+			addProblem(fmt.Sprintf("IptablesMarkMask has %d usable bits but %d are required", avail, needed),
+				params...)
+		}
+	}
+
+	return
+}
+
+const (
+	iptablesMarkOwner = "IptablesMarkMask"
+	bpfMarksOwner     = "BPF dataplane marks"
+	extToSvcMarkOwner = "BPFExtToServiceConnmark"
+)
+
+// configuredMarks returns the marks that the config asks Felix to use, starting with the bits of
+// IptablesMarkMask that the internal dataplane driver allocates from.  In BPF mode, the BPF
+// dataplane's bits are reserved out of IptablesMarkMask so they're listed separately.
+func (config *Config) configuredMarks() []markbits.Mark {
+	iptablesMarkBits := config.IptablesMarkMask
+	if config.BPFEnabled {
+		iptablesMarkBits &^= bpfMarksMask
+	}
+	marks := []markbits.Mark{markbits.MaskMark(iptablesMarkOwner, iptablesMarkBits)}
+	if config.BPFEnabled {
+		marks = append(marks, markbits.MaskMark(bpfMarksOwner, bpfMarksMask))
+		if config.BPFExtToServiceConnmark != 0 {
+			marks = append(marks, markbits.MaskMark(extToSvcMarkOwner, uint32(config.BPFExtToServiceConnmark)))
+		}
+	}
+	return marks
+}
+
+// markCollisions returns the collisions between the configured marks and between them and the
+// marks of well-known third-party software.
+func (config *Config) markCollisions() []markbits.Collision {
+	return markbits.FindCollisions(config.configuredMarks(), markbits.ThirdPartyMarks)
+}
+
+// markParams returns the config parameters that control the given mark.
+func markParams(m markbits.Mark) []string {
+	switch m.Owner {
+	case iptablesMarkOwner, extToSvcMarkOwner:
+		return []string{m.Owner}
+	case bpfMarksOwner:
+		return []string{"BPFEnabled"}
+	}
+	return nil
+}
+
+// UsableIptablesMarkBits returns the bits of IptablesMarkMask that the internal dataplane driver
+// can allocate from.  In BPF mode, that excludes the BPF dataplane's bits and, if
+// IptablesMarkMaskAutoAdjust is set, it excludes any bits that collide with other marks.
+func (config *Config) UsableIptablesMarkBits() uint32 {
+	usable := config.configuredMarks()[0].Bits()
+	if config.IptablesMarkMaskAutoAdjust {
+		for _, c := range config.markCollisions() {
+			if c.A.Owner == iptablesMarkOwner {
+				usable &^= c.Bits()
+			}
+		}
+	}
+	return usable
+}
+
+// requiredMarkBits returns the number of single-bit marks that the internal dataplane driver
+// allocates at start of day.
+func (config *Config) requiredMarkBits() int {
+	// Accept and scratch-0 bits are always needed.
+	n := 2
+	if !config.BPFEnabled {
+		// Pass and scratch-1 bits are only used in iptables mode.
+		n += 2
+	}
+	if config.WireguardEnabled {
+		n++
+	}
+	return n
+}
+
+// ValidationWarnings returns the problems that don't prevent the config from being used but
+// which are likely to be mistakes; for example, parameters that have no effect in combination
+// with other parameters.
+func (config *Config) ValidationWarnings() (problems []*ConfigProblem) {
+	addProblem := func(msg string, params ...string) {
+		problems = append(problems, &ConfigProblem{Params: params, Message: msg})
+	}
+
+	if !config.UseInternalDataplaneDriver {
+		if config.BPFEnabled {
+			addProblem("BPF mode requires the internal dataplane driver, ignoring BPFEnabled",
+				"BPFEnabled", "UseInternalDataplaneDriver")
+		}
+		if config.CNIReadinessGateEnabled {
+			addProblem("CNI readiness gate requires the internal dataplane driver, ignoring CNIReadinessGateEnabled",
+				"CNIReadinessGateEnabled", "UseInternalDataplaneDriver")
+		}
+		if config.WorkloadDrainConfigured() {
+			addProblem("Workload drain mode requires the internal dataplane driver, ignoring WorkloadDrainEnabled and WorkloadDrainFile",
+				"WorkloadDrainEnabled", "WorkloadDrainFile", "UseInternalDataplaneDriver")
+		}
+		if config.DropCaptureEnabled {
+			addProblem("Drop capture requires the internal dataplane driver, ignoring DropCaptureEnabled",
+				"DropCaptureEnabled", "UseInternalDataplaneDriver")
+		}
+		if len(config.EgressSNATAddresses) > 0 {
+			addProblem("Egress SNAT requires the internal dataplane driver, ignoring EgressSNATAddresses",
+				"EgressSNATAddresses", "UseInternalDataplaneDriver")
+		}
+		if config.ConntrackAccountingEnabled {
+			addProblem("Conntrack accounting requires the internal dataplane driver, ignoring ConntrackAccountingEnabled",
+				"ConntrackAccountingEnabled", "UseInternalDataplaneDriver")
+		}
+		if config.FlowLogsEnabled {
+			addProblem("Flow logs require the internal dataplane driver, ignoring FlowLogsEnabled",
+				"FlowLogsEnabled", "UseInternalDataplaneDriver")
+		}
+		if config.WorkloadConnRateLimitEnabled {
+			addProblem("Workload connection rate limiting requires the internal dataplane driver, ignoring WorkloadConnRateLimitEnabled",
+				"WorkloadConnRateLimitEnabled", "UseInternalDataplaneDriver")
+		}
+		if config.WorkloadAllowedSourcesEnabled {
+			addProblem("Workload allowed sources require the internal dataplane driver, ignoring WorkloadAllowedSourcesEnabled",
+				"WorkloadAllowedSourcesEnabled", "UseInternalDataplaneDriver")
+		}
+		if config.WorkloadMACEnforcement != "Disabled" {
+			addProblem("Workload MAC enforcement requires the internal dataplane driver, ignoring WorkloadMACEnforcement",
+				"WorkloadMACEnforcement", "UseInternalDataplaneDriver")
+		}
+		if config.ExternalNetworksEnabled {
+			addProblem("External networks require the internal dataplane driver, ignoring ExternalNetworksEnabled",
+				"ExternalNetworksEnabled", "UseInternalDataplaneDriver")
+		}
+		if len(config.StaticRoutes) > 0 {
+			addProblem("Static routes require the internal dataplane driver, ignoring StaticRoutes",
+				"StaticRoutes", "UseInternalDataplaneDriver")
+		}
+		if config.VXLANPoolVNIs != "" {
+			addProblem("Per-pool VXLAN VNIs require the internal dataplane driver, ignoring VXLANPoolVNIs",
+				"VXLANPoolVNIs", "UseInternalDataplaneDriver")
+		}
+		if config.DebugCrashDumpDir != "" {
+			addProblem("Crash dumps require the internal dataplane driver, ignoring DebugCrashDumpDir",
+				"DebugCrashDumpDir", "UseInternalDataplaneDriver")
+		}
+		if config.NFTablesMode == "Enabled" {
+			addProblem("nftables mode requires the internal dataplane driver, ignoring NFTablesMode",
+				"NFTablesMode", "UseInternalDataplaneDriver")
+		}
+		if config.DataplaneCPUBudget != 0 {
+			addProblem("The CPU budget requires the internal dataplane driver, ignoring DataplaneCPUBudget",
+				"DataplaneCPUBudget", "UseInternalDataplaneDriver")
+		}
+		if config.DataplaneNice != 0 || config.DataplaneIOPriorityClass != "Default" ||
+			config.DataplaneSchedPolicy != "Other" || len(config.DataplaneCPUAffinity) > 0 {
+			addProblem("Dataplane thread scheduling settings require the internal dataplane driver, ignoring them",
+				"DataplaneNice", "DataplaneIOPriorityClass", "DataplaneSchedPolicy", "DataplaneCPUAffinity",
+				"UseInternalDataplaneDriver")
+		}
+		return
+	}
+
+	if config.IptablesMarkMaskAutoAdjust {
+		for _, c := range config.markCollisions() {
+			if c.A.Owner == iptablesMarkOwner {
+				params := append(markParams(c.A), markParams(c.B)...)
+				addProblem(c.String()+", Felix will not use those bits",
+					append(params, "IptablesMarkMaskAutoAdjust")...)
+			}
+		}
+	}
+
+	if config.IpInIpRoutesEnabled && !config.IpInIpEnabled {
+		addProblem("IpInIpRoutesEnabled has no effect unless IPIP is enabled",
+			"IpInIpRoutesEnabled", "IpInIpEnabled")
+	}
+
+	if config.WireguardEncryptionScope != "All" && !config.WireguardEnabled {
+		addProblem("WireguardEncryptionScope has no effect unless Wireguard is enabled",
+			"WireguardEncryptionScope", "WireguardEnabled")
+	}
+	if config.WireguardEncryptionLabel != "" && config.WireguardEncryptionScope != "Labelled" {
+		addProblem("WireguardEncryptionLabel has no effect unless WireguardEncryptionScope is Labelled",
+			"WireguardEncryptionLabel", "WireguardEncryptionScope")
+	}
+	if config.WireguardRouteMTUs != "" {
+		if !config.WireguardEnabled {
+			addProblem("WireguardRouteMTUs has no effect unless Wireguard is enabled",
+				"WireguardRouteMTUs", "WireguardEnabled")
+		} else if _, err := ParseRouteMTUs(config.WireguardRouteMTUs); err != nil {
+			addProblem(err.Error()+", ignoring them", "WireguardRouteMTUs")
+		}
+	}
+
+	if config.VXLANPoolVNIs != "" {
+		if !config.VXLANEnabled {
+			addProblem("VXLANPoolVNIs has no effect unless VXLAN is enabled",
+				"VXLANPoolVNIs", "VXLANEnabled")
+		} else if _, err := ParsePoolVNIs(config.VXLANPoolVNIs); err != nil {
+			addProblem(err.Error()+", ignoring them", "VXLANPoolVNIs")
+		}
+	}
+
+	if config.BPFRouteClassifications != "" {
+		if !config.BPFEnabled {
+			addProblem("BPFRouteClassifications has no effect unless BPF mode is enabled",
+				"BPFRouteClassifications", "BPFEnabled")
+		} else if _, err := ParseRouteClassifications(config.BPFRouteClassifications); err != nil {
+			addProblem(err.Error()+", ignoring them", "BPFRouteClassifications")
+		}
+	}
+
+	pool := map[string]bool{}
+	for _, cidr := range config.EgressSNATAddresses {
+		pool[strings.TrimSuffix(cidr, "/32")] = true
+	}
+	for _, addr := range config.EgressSNATNamespaceAddresses {
+		if !pool[addr] {
+			addProblem("EgressSNATNamespaceAddresses refers to addresses that aren't in EgressSNATAddresses, ignoring them",
+				"EgressSNATNamespaceAddresses", "EgressSNATAddresses")
+			break
+		}
+	}
+
+	if config.ExternalNetworksEnabled {
+		if _, err := ExternalNetworkCIDRsByTreatment(config.ExternalNetworks, config.ExternalNetworkTreatments); err != nil {
+			addProblem(err.Error()+", ignoring the invalid parts",
+				"ExternalNetworks", "ExternalNetworkTreatments")
+		}
+	} else if len(config.ExternalNetworks) > 0 || len(config.ExternalNetworkTreatments) > 0 {
+		addProblem("ExternalNetworks has no effect unless ExternalNetworksEnabled is set",
+			"ExternalNetworks", "ExternalNetworkTreatments", "ExternalNetworksEnabled")
+	}
+
+	if _, err := ParseStaticRoutes(config.StaticRoutes); err != nil {
+		addProblem(err.Error()+", ignoring them", "StaticRoutes")
+	}
+
+	if config.DropCaptureEnabled && !config.PrometheusMetricsEnabled {
+		addProblem("Captured drops are served on the Prometheus metrics port, which is disabled",
+			"DropCaptureEnabled", "PrometheusMetricsEnabled")
+	}
+	if config.ConntrackAccountingEnabled && !config.PrometheusMetricsEnabled {
+		addProblem("Conntrack accounting is reported via Prometheus metrics, which are disabled",
+			"ConntrackAccountingEnabled", "PrometheusMetricsEnabled")
+	}
+	if config.DebugRouteTablesEnabled && !config.PrometheusMetricsEnabled {
+		addProblem("Route table state is served on the Prometheus metrics port, which is disabled",
+			"DebugRouteTablesEnabled", "PrometheusMetricsEnabled")
+	}
+	if config.DebugBPFMemoryEnabled {
+		if !config.BPFEnabled {
+			addProblem("BPF memory accounting only applies to the BPF dataplane",
+				"DebugBPFMemoryEnabled", "BPFEnabled")
+		} else if !config.PrometheusMetricsEnabled {
+			addProblem("BPF memory usage is served on the Prometheus metrics port, which is disabled",
+				"DebugBPFMemoryEnabled", "PrometheusMetricsEnabled")
+		}
+	}
+
+	if config.BPFEnabled {
+		if config.PreferredHostAddressFamily == "IPv6" {
+			addProblem("BPF mode only supports IPv4 host addresses",
+				"PreferredHostAddressFamily", "BPFEnabled")
+		}
+		if config.WorkloadMACEnforcement != "Disabled" {
+			addProblem("Workload MAC enforcement is not supported in BPF mode",
+				"WorkloadMACEnforcement", "BPFEnabled")
+		}
+		if config.WorkloadDrainConfigured() {
+			addProblem("Workload drain mode is not supported in BPF mode",
+				"WorkloadDrainEnabled", "WorkloadDrainFile", "BPFEnabled")
+		}
+		if config.DropCaptureEnabled {
+			addProblem("Drop capture is not supported in BPF mode",
+				"DropCaptureEnabled", "BPFEnabled")
+		}
+		if len(config.EgressSNATAddresses) > 0 {
+			addProblem("Egress SNAT is not supported in BPF mode",
+				"EgressSNATAddresses", "BPFEnabled")
+		}
+		if config.ConntrackAccountingEnabled {
+			addProblem("Conntrack accounting is not supported in BPF mode",
+				"ConntrackAccountingEnabled", "BPFEnabled")
+		}
+		if config.ExternalNetworksEnabled {
+			addProblem("External networks are not supported in BPF mode",
+				"ExternalNetworksEnabled", "BPFEnabled")
+		}
+		if config.WorkloadConnRateLimitEnabled {
+			addProblem("Workload connection rate limiting is not supported in BPF mode",
+				"WorkloadConnRateLimitEnabled", "BPFEnabled")
+		}
+		if config.VXLANPoolVNIs != "" {
+			addProblem("Per-pool VXLAN VNIs are not supported in BPF mode",
+				"VXLANPoolVNIs", "BPFEnabled")
+		}
+		if config.NFTablesMode == "Enabled" {
+			addProblem("nftables mode is not supported in BPF mode, ignoring NFTablesMode",
+				"NFTablesMode", "BPFEnabled")
+		}
+	}
+
+	return
+}
+
+// Validate performs cross-field validation.  If the config can't be used, it returns a
+// *ValidationError that lists every problem found.  Problems that don't prevent the config from
+// being used are logged as warnings; see ValidationWarnings().
+func (config *Config) Validate() (err error) {
+	for _, p := range config.ValidationWarnings() {
+		log.WithField("params", p.Params).Warn(p.Message)
+	}
+
+	if problems := config.validationErrors(); len(problems) > 0 {
+		err = &ValidationError{Problems: problems}
+		config.Err = err
+	}
+	return
+}
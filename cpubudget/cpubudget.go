@@ -0,0 +1,132 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cpubudget measures the CPU that Felix uses against a budget so that non-urgent work,
+// such as periodic resyncs, can be put off while Felix is using more than its share.
+package cpubudget
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// MaxConsecutiveDeferrals is the number of times in a row that a piece of work can be deferred
+// before it is allowed to go ahead anyway.  It stops work being starved indefinitely if Felix
+// stays over budget.
+const MaxConsecutiveDeferrals = 10
+
+var (
+	gaugeCPUUsage = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "felix_cpu_budget_usage",
+		Help: "Share of a core that Felix used over the last CPU budget sample.",
+	})
+	countVecDeferrals = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "felix_cpu_budget_deferrals",
+		Help: "Number of times that non-urgent work was deferred because Felix was over its CPU budget.",
+	}, []string{"work"})
+)
+
+func init() {
+	prometheus.MustRegister(gaugeCPUUsage)
+	prometheus.MustRegister(countVecDeferrals)
+}
+
+// Controller tracks the CPU time that the process uses against a budget, expressed as a share of
+// one core.  Sample must be called periodically to measure the usage; ShouldDefer can then be
+// used to pace non-urgent work.  Thread safe.
+type Controller struct {
+	budget      float64
+	readCPUTime func() (time.Duration, error)
+	timeNow     func() time.Time
+
+	lock           sync.Mutex
+	lastSampleTime time.Time
+	lastCPUTime    time.Duration
+	usage          float64
+	numDeferrals   map[string]int
+}
+
+// New creates a Controller with the given budget.  readCPUTime returns the total CPU time that
+// the process has used so far; see ProcessCPUTime.
+func New(budget float64, readCPUTime func() (time.Duration, error), timeNow func() time.Time) *Controller {
+	c := &Controller{
+		budget:       budget,
+		readCPUTime:  readCPUTime,
+		timeNow:      timeNow,
+		numDeferrals: map[string]int{},
+	}
+	c.lastSampleTime = timeNow()
+	c.lastCPUTime, _ = readCPUTime()
+	return c
+}
+
+// Sample measures the CPU usage since the previous sample.
+func (c *Controller) Sample() {
+	cpuTime, err := c.readCPUTime()
+	if err != nil {
+		log.WithError(err).Warn("Failed to read CPU usage, keeping previous value.")
+		return
+	}
+	now := c.timeNow()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	elapsed := now.Sub(c.lastSampleTime)
+	if elapsed <= 0 {
+		return
+	}
+	c.usage = float64(cpuTime-c.lastCPUTime) / float64(elapsed)
+	c.lastSampleTime = now
+	c.lastCPUTime = cpuTime
+	gaugeCPUUsage.Set(c.usage)
+}
+
+// Usage returns the share of a core that the process used between the last two samples.
+func (c *Controller) Usage() float64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.usage
+}
+
+// OverBudget returns true if the last sample was over budget.
+func (c *Controller) OverBudget() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.usage > c.budget
+}
+
+// ShouldDefer returns true if the named piece of non-urgent work should be put off because the
+// process is over budget.  After MaxConsecutiveDeferrals of the same work, it returns false so that
+// the work isn't starved.
+func (c *Controller) ShouldDefer(work string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.usage <= c.budget || c.numDeferrals[work] >= MaxConsecutiveDeferrals {
+		delete(c.numDeferrals, work)
+		return false
+	}
+	c.numDeferrals[work]++
+	countVecDeferrals.WithLabelValues(work).Inc()
+	log.WithFields(log.Fields{
+		"work":   work,
+		"usage":  c.usage,
+		"budget": c.budget,
+	}).Debug("Over CPU budget, deferring work.")
+	return true
+}
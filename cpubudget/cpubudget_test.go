@@ -0,0 +1,98 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cpubudget_test
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/projectcalico/felix/cpubudget"
+)
+
+var _ = Describe("CPU budget controller", func() {
+	var (
+		c       *Controller
+		now     time.Time
+		cpuTime time.Duration
+		readErr error
+	)
+
+	BeforeEach(func() {
+		now = time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+		cpuTime = 10 * time.Second
+		readErr = nil
+		c = New(0.5,
+			func() (time.Duration, error) { return cpuTime, readErr },
+			func() time.Time { return now })
+	})
+
+	advance := func(wall, cpu time.Duration) {
+		now = now.Add(wall)
+		cpuTime += cpu
+		c.Sample()
+	}
+
+	It("should start under budget", func() {
+		Expect(c.OverBudget()).To(BeFalse())
+		Expect(c.ShouldDefer("resync")).To(BeFalse())
+	})
+
+	It("should measure usage as a share of a core", func() {
+		advance(time.Second, 250*time.Millisecond)
+		Expect(c.Usage()).To(BeNumerically("~", 0.25))
+		Expect(c.OverBudget()).To(BeFalse())
+	})
+
+	It("should keep the previous usage if reading the CPU time fails", func() {
+		advance(time.Second, 750*time.Millisecond)
+		readErr = errors.New("bang")
+		advance(time.Second, 0)
+		Expect(c.Usage()).To(BeNumerically("~", 0.75))
+	})
+
+	Describe("when over budget", func() {
+		BeforeEach(func() {
+			advance(time.Second, 750*time.Millisecond)
+		})
+
+		It("should be over budget", func() {
+			Expect(c.OverBudget()).To(BeTrue())
+		})
+
+		It("should defer work", func() {
+			Expect(c.ShouldDefer("resync")).To(BeTrue())
+		})
+
+		It("should stop deferring work after too many deferrals", func() {
+			for i := 0; i < MaxConsecutiveDeferrals; i++ {
+				Expect(c.ShouldDefer("resync")).To(BeTrue())
+			}
+			Expect(c.ShouldDefer("resync")).To(BeFalse())
+			// The count starts again once the work has gone ahead.
+			Expect(c.ShouldDefer("resync")).To(BeTrue())
+			// Other work has its own count.
+			Expect(c.ShouldDefer("scan")).To(BeTrue())
+		})
+
+		It("should stop deferring work when back under budget", func() {
+			advance(time.Second, 100*time.Millisecond)
+			Expect(c.OverBudget()).To(BeFalse())
+			Expect(c.ShouldDefer("resync")).To(BeFalse())
+		})
+	})
+})
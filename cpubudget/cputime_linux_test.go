@@ -0,0 +1,40 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cpubudget_test
+
+import (
+	"os/exec"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/projectcalico/felix/cpubudget"
+)
+
+var _ = Describe("ProcessCPUTime", func() {
+	It("should include the CPU time of child processes", func() {
+		before, err := ProcessCPUTime()
+		Expect(err).NotTo(HaveOccurred())
+
+		cmd := exec.Command("sh", "-c", "i=0; while [ $i -lt 100000 ]; do i=$((i+1)); done")
+		Expect(cmd.Run()).To(Succeed())
+		childTime := cmd.ProcessState.UserTime() + cmd.ProcessState.SystemTime()
+		Expect(childTime).To(BeNumerically(">", 0))
+
+		after, err := ProcessCPUTime()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(after - before).To(BeNumerically(">=", childTime))
+	})
+})
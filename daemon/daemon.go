@@ -58,8 +58,10 @@ import (
 	"github.com/projectcalico/felix/config"
 	_ "github.com/projectcalico/felix/config"
 	dp "github.com/projectcalico/felix/dataplane"
+	"github.com/projectcalico/felix/dataplane/recorder"
 	"github.com/projectcalico/felix/jitter"
 	"github.com/projectcalico/felix/logutils"
+	"github.com/projectcalico/felix/policyapplied"
 	"github.com/projectcalico/felix/policysync"
 	"github.com/projectcalico/felix/proto"
 	"github.com/projectcalico/felix/statusrep"
@@ -83,6 +85,11 @@ const (
 
 	// Grace period we allow for graceful shutdown before panicking.
 	gracefulShutdownTimeout = 30 * time.Second
+
+	// Name of the health reporter that we use to flag invalid config, and how often we re-check
+	// the datastore for a fix.
+	configValidationHealthName    = "felix-config-validation"
+	configValidationRetryInterval = 5 * time.Second
 )
 
 // Run is the entry point to run a Felix instance.
@@ -155,6 +162,9 @@ func Run(configFile string, gitVersion string, buildDate string, gitRevision str
 
 	// Register this function as a reporter of liveness and readiness, with no timeout.
 	healthAggregator.RegisterReporter(healthName, &health.HealthReport{Live: true, Ready: true}, 0)
+	// Config validation failures are reported separately so that they show up by name in the
+	// health logs.
+	healthAggregator.RegisterReporter(configValidationHealthName, &health.HealthReport{Live: true, Ready: true}, 0)
 
 	// Log out the kubernetes server details that we use in BPF mode.
 	log.WithFields(log.Fields{
@@ -258,14 +268,19 @@ configRetry:
 				time.Sleep(1 * time.Second)
 				continue configRetry
 			}
+			err = configParams.Validate()
+			if err != nil {
+				// Stay live but not ready until the config is fixed.  We keep polling with the
+				// same client rather than going round the outer loop, which would eventually
+				// restart Felix to avoid leaking connections.
+				logValidationError(err)
+				healthAggregator.Report(configValidationHealthName, &health.HealthReport{Live: true, Ready: false})
+				time.Sleep(configValidationRetryInterval)
+				continue
+			}
+			healthAggregator.Report(configValidationHealthName, &health.HealthReport{Live: true, Ready: true})
 			break
 		}
-		err = configParams.Validate()
-		if err != nil {
-			log.WithError(err).Error("Failed to parse/validate configuration from datastore.")
-			time.Sleep(1 * time.Second)
-			continue configRetry
-		}
 
 		// We now have some config flags that affect how we configure the syncer.
 		// After loading the config from the datastore, reconnect, possibly with new
@@ -393,6 +408,17 @@ configRetry:
 		fatalErrorCallback,
 		k8sClientSet)
 
+	if configParams.DebugDataplaneRecordFile != "" {
+		fileName := logutils.RenderFileName(configParams.DebugDataplaneRecordFile)
+		recordingDriver, err := recorder.NewRecordingDriver(dpDriver, fileName)
+		if err != nil {
+			log.WithError(err).WithField("file", fileName).Error(
+				"Failed to create dataplane recording, continuing without recording.")
+		} else {
+			dpDriver = recordingDriver
+		}
+	}
+
 	// Initialise the glue logic that connects the calculation graph to/from the dataplane driver.
 	log.Info("Connect to the dataplane driver.")
 
@@ -416,6 +442,12 @@ configRetry:
 	var policySyncProcessor *policysync.Processor
 	var policySyncAPIBinder binder.Binder
 	calcGraphClientChannels := []chan<- interface{}{dpConnector.ToDataplane}
+	if configParams.DebugProtoInjectionSocket != "" {
+		// The test harness drives the dataplane instead; the calculation graph still runs (for
+		// its stats and the policy sync API) but we don't send its output to the dataplane.
+		log.Warn("DebugProtoInjectionSocket is set, dataplane will be driven by injected messages.")
+		calcGraphClientChannels = nil
+	}
 	if configParams.IsLeader() && configParams.PolicySyncPathPrefix != "" {
 		log.WithField("policySyncPathPrefix", configParams.PolicySyncPathPrefix).Info(
 			"Policy sync API enabled.  Creating the policy sync server.")
@@ -604,6 +636,13 @@ configRetry:
 		dpConnector.statusReporter.Start()
 	}
 
+	if configParams.PolicyAppliedSocketPath != "" {
+		log.WithField("path", configParams.PolicyAppliedSocketPath).Info(
+			"Policy applied API enabled, starting server.")
+		dpConnector.policyAppliedServer = policyapplied.NewServer()
+		go servePolicyApplied(dpConnector.policyAppliedServer, configParams.PolicyAppliedSocketPath)
+	}
+
 	// Start communicating with the dataplane driver.
 	dpConnector.Start()
 
@@ -622,6 +661,11 @@ configRetry:
 		Config: configParams.RawValues(),
 	}
 
+	if configParams.DebugProtoInjectionSocket != "" {
+		// Only start accepting messages now so that they follow the ConfigUpdate.
+		go serveProtoInjection(configParams.DebugProtoInjectionSocket, dpConnector.ToDataplane)
+	}
+
 	if configParams.PrometheusMetricsEnabled {
 		log.Info("Prometheus metrics enabled.  Starting server.")
 		gaugeHost := prometheus.NewGauge(prometheus.GaugeOpts{
@@ -642,6 +686,14 @@ configRetry:
 	monitorAndManageShutdown(failureReportChan, dpDriverCmd, stopSignalChans)
 }
 
+func servePolicyApplied(server *policyapplied.Server, socketPath string) {
+	for {
+		err := server.ListenAndServe(socketPath)
+		log.WithError(err).Error("Policy applied API server failed, trying to restart it...")
+		time.Sleep(1 * time.Second)
+	}
+}
+
 func monitorAndManageShutdown(failureReportChan <-chan string, driverCmd *exec.Cmd, stopSignalChans []chan<- *sync.WaitGroup) {
 	// Ask the runtime to tell us if we get a term/int signal.
 	signalChan := make(chan os.Signal, 1)
@@ -767,6 +819,16 @@ func exitWithCustomRC(rc int, message string) {
 	os.Exit(rc)
 }
 
+func logValidationError(err error) {
+	if vErr, ok := err.(*config.ValidationError); ok {
+		for _, p := range vErr.Problems {
+			log.WithField("params", p.Params).Error("Invalid configuration: " + p.Message)
+		}
+		return
+	}
+	log.WithError(err).Error("Failed to parse/validate configuration from datastore.")
+}
+
 var (
 	ErrNotReady = errors.New("datastore is not ready or has not been initialised")
 )
@@ -899,6 +961,7 @@ type DataplaneConnector struct {
 	datastore                  bapi.Client
 	datastorev3                client.Interface
 	statusReporter             *statusrep.EndpointStatusReporter
+	policyAppliedServer        *policyapplied.Server
 
 	datastoreInSync bool
 
@@ -950,10 +1013,16 @@ func (fc *DataplaneConnector) readMessagesFromDataplane() {
 		case *proto.ProcessStatusUpdate:
 			fc.handleProcessStatusUpdate(ctx, msg)
 		case *proto.WorkloadEndpointStatusUpdate:
+			if fc.policyAppliedServer != nil {
+				fc.policyAppliedServer.OnEndpointStatusUpdate(msg)
+			}
 			if fc.statusReporter != nil {
 				fc.StatusUpdatesFromDataplane <- msg
 			}
 		case *proto.WorkloadEndpointStatusRemove:
+			if fc.policyAppliedServer != nil {
+				fc.policyAppliedServer.OnEndpointStatusUpdate(msg)
+			}
 			if fc.statusReporter != nil {
 				fc.StatusUpdatesFromDataplane <- msg
 			}
@@ -1092,13 +1161,23 @@ func (fc *DataplaneConnector) handleWireguardStatUpdateFromDataplane() {
 	}
 }
 
-var handledConfigChanges = set.From("CalicoVersion", "ClusterGUID", "ClusterType")
+var handledConfigChanges = set.From("CalicoVersion", "ClusterGUID", "ClusterType",
+	// Applied by the internal dataplane's external networks manager.
+	"ExternalNetworks", "ExternalNetworkTreatments",
+	// Sent to the dataplane as StaticRouteUpdate/Remove messages by the calculation graph.
+	"StaticRoutes")
 
 func (fc *DataplaneConnector) sendMessagesToDataplaneDriver() {
 	defer func() {
 		fc.shutDownProcess("Failed to send messages to dataplane")
 	}()
 
+	// The dataplane driver may also apply some parameters in place.
+	handledChanges := handledConfigChanges.Copy()
+	for _, name := range dp.ReloadableConfigParams(fc.config) {
+		handledChanges.Add(name)
+	}
+
 	var config map[string]string
 	for {
 		msg := <-fc.ToDataplane
@@ -1126,7 +1205,7 @@ func (fc *DataplaneConnector) sendMessagesToDataplaneDriver() {
 					} else {
 						continue
 					}
-					if handledConfigChanges.Contains(kNew) {
+					if handledChanges.Contains(kNew) {
 						logCxt.Info("Config change can be handled without restart")
 						continue
 					}
@@ -1139,7 +1218,7 @@ func (fc *DataplaneConnector) sendMessagesToDataplaneDriver() {
 						// Key was present in the message so we've handled above.
 						continue
 					}
-					if handledConfigChanges.Contains(kOld) {
+					if handledChanges.Contains(kOld) {
 						logCxt.Info("Config change can be handled without restart")
 						continue
 					}
@@ -1166,6 +1245,11 @@ func (fc *DataplaneConnector) sendMessagesToDataplaneDriver() {
 			log.Warn("Datastore became unready, need to restart.")
 			fc.shutDownProcess("datastore became unready")
 		}
+		if fc.policyAppliedServer != nil {
+			// Invalidate the status of any endpoint that the message changes before the
+			// dataplane can start programming the change.
+			fc.policyAppliedServer.OnDataplaneUpdate(msg)
+		}
 		if err := fc.dataplane.SendMessage(msg); err != nil {
 			fc.shutDownProcess("Failed to write to dataplane driver")
 		}
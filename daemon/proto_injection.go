@@ -0,0 +1,88 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"io"
+	"net"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/dataplane/recorder"
+)
+
+// serveProtoInjection listens on the given unix socket and sends the messages that its clients
+// write to the dataplane driver.  It's used by the FVs, in place of the calculation graph, to
+// drive the dataplane with precise sequences of updates.  Clients write the same format as a
+// dataplane recording (see recorder.Writer); connections are served one at a time, in order, so
+// a test can reconnect to send further messages.
+func serveProtoInjection(socketPath string, toDataplane chan<- interface{}) {
+	for {
+		err := listenForProtoInjection(socketPath, toDataplane)
+		log.WithError(err).Error("Proto injection server failed, trying to restart it...")
+		time.Sleep(1 * time.Second)
+	}
+}
+
+func listenForProtoInjection(socketPath string, toDataplane chan<- interface{}) error {
+	// Remove any socket left over from a previous run.
+	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	l, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+	log.WithField("path", socketPath).Warn("Listening for injected dataplane messages.")
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		n, err := injectProtoMessages(conn, toDataplane)
+		logCxt := log.WithField("numMessages", n)
+		if err != nil {
+			logCxt.WithError(err).Error("Failed to read injected dataplane messages.")
+		} else {
+			logCxt.Info("Injection client disconnected.")
+		}
+		_ = conn.Close()
+	}
+}
+
+// injectProtoMessages reads messages in the recording format from r and sends them to the
+// dataplane driver until r is exhausted.  Returns the number of messages sent.
+func injectProtoMessages(r io.Reader, toDataplane chan<- interface{}) (int, error) {
+	reader, err := recorder.NewReader(r)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for {
+		rec, err := reader.Next()
+		if err == io.EOF {
+			return n, nil
+		} else if err != nil {
+			return n, err
+		}
+		log.WithField("msg", rec.Msg).Debug("Injecting dataplane message.")
+		toDataplane <- rec.Msg
+		n++
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package daemon
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/dataplane/recorder"
+	"github.com/projectcalico/felix/proto"
+)
+
+var _ = Describe("Proto injection", func() {
+	msgs := []interface{}{
+		&proto.IPSetUpdate{Id: "s:abcd", Members: []string{"10.0.0.1/32"}},
+		&proto.IPSetRemove{Id: "s:abcd"},
+		&proto.InSync{},
+	}
+
+	writeMsgs := func(w io.Writer) {
+		writer, err := recorder.NewWriter(w)
+		Expect(err).NotTo(HaveOccurred())
+		for _, m := range msgs {
+			Expect(writer.Write(time.Now(), m)).To(Succeed())
+		}
+	}
+
+	It("should send the messages to the dataplane in order", func() {
+		var buf bytes.Buffer
+		writeMsgs(&buf)
+		toDataplane := make(chan interface{}, 10)
+		n, err := injectProtoMessages(&buf, toDataplane)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(Equal(3))
+		for _, m := range msgs {
+			Expect(<-toDataplane).To(Equal(m))
+		}
+	})
+
+	It("should reject a stream without the header", func() {
+		_, err := injectProtoMessages(bytes.NewBufferString("garbage that's long enough to be a header\n"),
+			make(chan interface{}))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should accept messages over the socket", func() {
+		dir, err := ioutil.TempDir("", "felix-inject")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		socketPath := filepath.Join(dir, "inject.sock")
+		toDataplane := make(chan interface{}, 10)
+		go func() {
+			defer GinkgoRecover()
+			_ = listenForProtoInjection(socketPath, toDataplane)
+		}()
+
+		var conn net.Conn
+		Eventually(func() error {
+			conn, err = net.Dial("unix", socketPath)
+			return err
+		}).Should(Succeed())
+		writeMsgs(conn)
+		Expect(conn.Close()).To(Succeed())
+		for _, m := range msgs {
+			Eventually(toDataplane).Should(Receive(Equal(m)))
+		}
+	})
+})
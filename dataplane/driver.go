@@ -17,6 +17,7 @@
 package dataplane
 
 import (
+	"fmt"
 	"math/bits"
 	"net"
 	"net/http"
@@ -41,16 +42,24 @@ import (
 	extdataplane "github.com/projectcalico/felix/dataplane/external"
 	"github.com/projectcalico/felix/dataplane/inactive"
 	intdataplane "github.com/projectcalico/felix/dataplane/linux"
+	"github.com/projectcalico/felix/dropcapture"
+	"github.com/projectcalico/felix/flowlog"
 	"github.com/projectcalico/felix/idalloc"
 	"github.com/projectcalico/felix/ifacemonitor"
+	"github.com/projectcalico/felix/ip"
 	"github.com/projectcalico/felix/ipsets"
 	"github.com/projectcalico/felix/logutils"
 	"github.com/projectcalico/felix/markbits"
+	"github.com/projectcalico/felix/netlinkshim"
+	"github.com/projectcalico/felix/routetable"
 	"github.com/projectcalico/felix/rules"
 	"github.com/projectcalico/felix/wireguard"
 	"github.com/projectcalico/libcalico-go/lib/health"
 )
 
+// iproute2ConfigDir is where iproute2 keeps its configuration, including the routing table names.
+const iproute2ConfigDir = "/etc/iproute2"
+
 func StartDataplaneDriver(configParams *config.Config,
 	healthAggregator *health.HealthAggregator,
 	configChangedRestartCallback func(),
@@ -65,6 +74,13 @@ func StartDataplaneDriver(configParams *config.Config,
 
 	if configParams.UseInternalDataplaneDriver {
 		log.Info("Using internal (linux) dataplane driver.")
+		if err := rules.ValidateNamePrefixes(
+			configParams.IptablesChainNamePrefix,
+			configParams.IptablesRuleHashPrefix,
+			configParams.IPSetNamePrefix,
+		); err != nil {
+			log.WithError(err).Panic("Invalid chain, rule hash or IP set name prefix.")
+		}
 		// If kube ipvs interface is present, enable ipvs support.  In BPF mode, we bypass kube-proxy so IPVS
 		// is irrelevant.
 		kubeIPVSSupportEnabled := false
@@ -79,6 +95,10 @@ func StartDataplaneDriver(configParams *config.Config,
 		if configChangedRestartCallback == nil || fatalErrorCallback == nil {
 			log.Panic("Starting dataplane with nil callback func.")
 		}
+		if configParams.DebugNetlinkTimeoutRate > 0 || configParams.DebugNetlinkBusyRate > 0 {
+			// Must come before anything creates a netlink handle.
+			netlinkshim.EnableFaultInjection(configParams.DebugNetlinkTimeoutRate, configParams.DebugNetlinkBusyRate)
+		}
 
 		allowedMarkBits := configParams.IptablesMarkMask
 		if configParams.BPFEnabled {
@@ -95,6 +115,12 @@ func StartDataplaneDriver(configParams *config.Config,
 			log.WithField("updatedBits", allowedMarkBits).Info(
 				"Removed BPF program bits from available mark bits.")
 		}
+		if usableBits := configParams.UsableIptablesMarkBits(); usableBits != allowedMarkBits {
+			// IptablesMarkMaskAutoAdjust is enabled and some bits collide with other marks.
+			allowedMarkBits = usableBits
+			log.WithField("updatedBits", allowedMarkBits).Info(
+				"Removed bits that collide with other marks from available mark bits.")
+		}
 
 		markBitsManager := markbits.NewMarkBitsManager(allowedMarkBits, "felix-iptables")
 
@@ -156,19 +182,52 @@ func StartDataplaneDriver(configParams *config.Config,
 
 		// Create a routing table manager. There are certain components that should take specific indices in the range
 		// to simplify table tidy-up.
-		routeTableIndexAllocator := idalloc.NewIndexAllocator(configParams.RouteTableRange)
+		routeTableExclusions := append([]idalloc.IndexRange(nil), configParams.RouteTableRangeExclusions...)
+		routeTableExclusions = append(routeTableExclusions, findRouteTableConflicts(configParams)...)
+		routeTableIndexAllocator := idalloc.NewIndexAllocator(configParams.RouteTableRange, routeTableExclusions...)
+		if configParams.RouteTableIndexStateFile != "" {
+			if err := routeTableIndexAllocator.LoadState(configParams.RouteTableIndexStateFile); err != nil {
+				log.WithError(err).WithField("file", configParams.RouteTableIndexStateFile).Warn(
+					"Failed to load previous route table allocations, tables may be renumbered.")
+			}
+		}
 
 		// Always allocate the wireguard table index (even when not enabled). This ensures we can tidy up entries
 		// if wireguard is disabled after being previously enabled.
 		var wireguardEnabled bool
 		var wireguardTableIndex int
-		if idx, err := routeTableIndexAllocator.GrabIndex(); err == nil {
+		if idx, err := routeTableIndexAllocator.GrabIndexFor("wireguard"); err == nil {
 			log.Debugf("Assigned wireguard table index: %d", idx)
 			wireguardEnabled = configParams.WireguardEnabled
 			wireguardTableIndex = idx
 		} else {
 			log.WithError(err).Warning("Unable to assign table index for wireguard")
 		}
+		// Invalid entries are reported by config validation.
+		vxlanPoolVNIs := map[ip.V4CIDR]int{}
+		poolVNIs, _ := config.ParsePoolVNIs(configParams.VXLANPoolVNIs)
+		for cidr, vni := range poolVNIs {
+			vxlanPoolVNIs[ip.MustParseCIDROrIP(cidr).(ip.V4CIDR)] = vni
+		}
+		// Invalid entries are reported by config validation.
+		wireguardRouteMTUs := map[ip.CIDR]int{}
+		routeMTUs, _ := config.ParseRouteMTUs(configParams.WireguardRouteMTUs)
+		for cidr, mtu := range routeMTUs {
+			wireguardRouteMTUs[ip.MustParseCIDROrIP(cidr)] = mtu
+		}
+
+		// Invalid entries are reported by config validation.
+		bpfRouteClassifications, _ := config.ParseRouteClassifications(configParams.BPFRouteClassifications)
+
+		// Similarly, always allocate the table index used for BPF host NAT so that we can tidy up if the
+		// connect-time load balancer is enabled after being disabled.
+		var bpfHostNATTableIndex int
+		if idx, err := routeTableIndexAllocator.GrabIndexFor("bpf-host-nat"); err == nil {
+			log.Debugf("Assigned BPF host NAT table index: %d", idx)
+			bpfHostNATTableIndex = idx
+		} else {
+			log.WithError(err).Warning("Unable to assign table index for BPF host NAT")
+		}
 
 		// If wireguard is enabled, update the failsafe ports to include the wireguard port.
 		failsafeInboundHostPorts := configParams.FailsafeInboundHostPorts
@@ -219,27 +278,65 @@ func StartDataplaneDriver(configParams *config.Config,
 			}
 		}
 
+		var dropCapture *dropcapture.Ring
+		if configParams.DropCaptureEnabled && !configParams.BPFEnabled {
+			dropCapture = dropcapture.NewRing(configParams.DropCaptureBufferSize)
+			// Served by the Prometheus metrics server, which uses the default mux.
+			http.Handle(dropcapture.DebugPath, dropCapture)
+		}
+
+		var flowLogsSink flowlog.Sink
+		if configParams.FlowLogsEnabled {
+			sink, err := flowlog.NewSink(configParams.FlowLogsSink, configParams.FlowLogsFileName,
+				configParams.FlowLogsGRPCAddr)
+			if err != nil {
+				log.WithError(err).Panic("Failed to create flow log sink.")
+			}
+			// Write from a background goroutine so that a slow sink can't hold up the dataplane.
+			asyncSink := flowlog.NewAsyncSink(sink)
+			asyncSink.Start()
+			flowLogsSink = asyncSink
+		}
+
+		var routeTableDebug *routetable.DebugRegistry
+		if configParams.DebugRouteTablesEnabled {
+			routeTableDebug = routetable.NewDebugRegistry()
+			http.Handle(routetable.DebugPath, routeTableDebug)
+		}
+
+		var bpfMemoryAccountant *bpf.MemoryAccountant
+		if configParams.BPFEnabled {
+			bpfMemoryAccountant = bpf.NewMemoryAccountant()
+			if configParams.DebugBPFMemoryEnabled {
+				http.Handle(bpf.MemoryDebugPath, bpfMemoryAccountant)
+			}
+		}
+
+		historicIPSetNamePrefixes, legacyV4IPSetNames := rules.HistoricIPSetNames(configParams.IPSetNamePrefix)
 		dpConfig := intdataplane.Config{
 			Hostname: configParams.FelixHostname,
 			IfaceMonitorConfig: ifacemonitor.Config{
-				InterfaceExcludes: configParams.InterfaceExclude,
-				ResyncInterval:    configParams.InterfaceRefreshInterval,
+				InterfaceExcludes:      configParams.InterfaceExclude,
+				ResyncInterval:         configParams.InterfaceRefreshInterval,
+				PollingFallbackEnabled: configParams.InterfacePollingFallbackEnabled,
 			},
 			RulesConfig: rules.Config{
 				WorkloadIfacePrefixes: configParams.InterfacePrefixes(),
 
 				IPSetConfigV4: ipsets.NewIPVersionConfig(
 					ipsets.IPFamilyV4,
-					rules.IPSetNamePrefix,
-					rules.AllHistoricIPSetNamePrefixes,
-					rules.LegacyV4IPSetNames,
+					configParams.IPSetNamePrefix,
+					historicIPSetNamePrefixes,
+					legacyV4IPSetNames,
 				),
 				IPSetConfigV6: ipsets.NewIPVersionConfig(
 					ipsets.IPFamilyV6,
-					rules.IPSetNamePrefix,
-					rules.AllHistoricIPSetNamePrefixes,
+					configParams.IPSetNamePrefix,
+					historicIPSetNamePrefixes,
 					nil,
 				),
+				IptablesChainNamePrefix: configParams.IptablesChainNamePrefix,
+				IptablesRuleHashPrefix:  configParams.IptablesRuleHashPrefix,
 
 				KubeNodePortRanges:     configParams.KubeNodePortRanges,
 				KubeIPVSSupportEnabled: kubeIPVSSupportEnabled,
@@ -255,9 +352,10 @@ func StartDataplaneDriver(configParams *config.Config,
 				IptablesMarkEndpoint:        markEndpointMark,
 				IptablesMarkNonCaliEndpoint: markEndpointNonCaliEndpoint,
 
-				VXLANEnabled: configParams.VXLANEnabled,
-				VXLANPort:    configParams.VXLANPort,
-				VXLANVNI:     configParams.VXLANVNI,
+				VXLANEnabled:  configParams.VXLANEnabled,
+				VXLANPort:     configParams.VXLANPort,
+				VXLANVNI:      configParams.VXLANVNI,
+				VXLANPoolVNIs: vxlanPoolVNIs,
 
 				IPIPEnabled:        configParams.IpInIpEnabled,
 				IPIPTunnelAddress:  configParams.IpInIpTunnelAddr,
@@ -280,39 +378,67 @@ func StartDataplaneDriver(configParams *config.Config,
 				FailsafeInboundHostPorts:  failsafeInboundHostPorts,
 				FailsafeOutboundHostPorts: failsafeOutboundHostPorts,
 
-				DisableConntrackInvalid: configParams.DisableConntrackInvalidCheck,
+				DisableConntrackInvalid:        configParams.DisableConntrackInvalidCheck,
+				WorkloadUntrackedPolicyEnabled: configParams.WorkloadUntrackedPolicyEnabled,
 
 				NATPortRange:                       configParams.NATPortRange,
 				IptablesNATOutgoingInterfaceFilter: configParams.IptablesNATOutgoingInterfaceFilter,
 				NATOutgoingAddress:                 configParams.NATOutgoingAddress,
+				NATOutgoingAddressV6:               configParams.NATOutgoingAddressV6,
 				BPFEnabled:                         configParams.BPFEnabled,
 				ServiceLoopPrevention:              configParams.ServiceLoopPrevention,
+				CNIReadinessGateEnabled:            configParams.CNIReadinessGateEnabled,
+				WorkloadDrainSupportEnabled:        configParams.WorkloadDrainConfigured() && !configParams.BPFEnabled,
+				DropCaptureEnabled:                 dropCapture != nil,
+				DropCaptureNFLOGGroup:              uint16(configParams.DropCaptureNFLOGGroup),
+				EgressSNATEnabled:                  len(configParams.EgressSNATAddresses) > 0 && !configParams.BPFEnabled,
+				ExternalNetworksEnabled:            configParams.ExternalNetworksEnabled && !configParams.BPFEnabled,
+				KubeProxyPrecedenceEnabled:         configParams.BPFEnabled && configParams.BPFKubeProxyMigrationMode == "Precedence",
+				WorkloadConnRateLimitEnabled:       configParams.WorkloadConnRateLimitEnabled && !configParams.BPFEnabled,
+				WorkloadAllowedSourcesEnabled:      configParams.WorkloadAllowedSourcesEnabled && !configParams.BPFEnabled,
+				WorkloadMACEnforcementEnabled:      configParams.WorkloadMACEnforcement != "Disabled" && !configParams.BPFEnabled,
+				PolicyRuleCountersEnabled: configParams.PolicyRuleCountersEnabled && !configParams.BPFEnabled &&
+					configParams.NFTablesMode != "Enabled",
+				WorkloadEgressAllowlistEnabled: configParams.WorkloadEgressAllowlistEnabled && !configParams.BPFEnabled &&
+					configParams.NFTablesMode != "Enabled",
+				WorkloadLinkLocalAccess:              configParams.WorkloadLinkLocalAccess,
+				WorkloadLinkLocalServices:            configParams.WorkloadLinkLocalServices,
+				EndpointToHostActionOverridesEnabled: configParams.EndpointToHostActionOverridesEnabled,
 			},
 			Wireguard: wireguard.Config{
-				Enabled:             wireguardEnabled,
-				ListeningPort:       configParams.WireguardListeningPort,
-				FirewallMark:        int(markWireguard),
-				RoutingRulePriority: configParams.WireguardRoutingRulePriority,
-				RoutingTableIndex:   wireguardTableIndex,
-				InterfaceName:       configParams.WireguardInterfaceName,
-				MTU:                 configParams.WireguardMTU,
-				RouteSource:         configParams.RouteSource,
+				Enabled:                wireguardEnabled,
+				ListeningPort:          configParams.WireguardListeningPort,
+				FirewallMark:           int(markWireguard),
+				RoutingRulePriority:    configParams.WireguardRoutingRulePriority,
+				RoutingTableIndex:      wireguardTableIndex,
+				InterfaceName:          configParams.WireguardInterfaceName,
+				MTU:                    configParams.WireguardMTU,
+				RouteMTUs:              wireguardRouteMTUs,
+				RouteSource:            configParams.RouteSource,
+				EncryptionScope:        configParams.WireguardEncryptionScope,
+				PersistentKeepAlive:    configParams.WireguardPersistentKeepAlive,
+				NATPersistentKeepAlive: configParams.WireguardNATPersistentKeepAlive,
 			},
 			IPIPMTU:                        configParams.IpInIpMtu,
+			IPIPRoutesEnabled:              configParams.IpInIpRoutesEnabled,
 			VXLANMTU:                       configParams.VXLANMTU,
 			VXLANPort:                      configParams.VXLANPort,
 			IptablesBackend:                configParams.IptablesBackend,
 			IptablesRefreshInterval:        configParams.IptablesRefreshInterval,
 			RouteRefreshInterval:           configParams.RouteRefreshInterval,
+			RouteTableMaxParallelUpdates:   configParams.RouteTableMaxParallelUpdates,
 			DeviceRouteSourceAddress:       configParams.DeviceRouteSourceAddress,
 			DeviceRouteProtocol:            configParams.DeviceRouteProtocol,
 			RemoveExternalRoutes:           configParams.RemoveExternalRoutes,
+			StaticRouteProtocol:            configParams.StaticRouteProtocol,
 			IPSetsRefreshInterval:          configParams.IpsetsRefreshInterval,
 			IptablesPostWriteCheckInterval: configParams.IptablesPostWriteCheckIntervalSecs,
 			IptablesInsertMode:             configParams.ChainInsertMode,
 			IptablesLockFilePath:           configParams.IptablesLockFilePath,
 			IptablesLockTimeout:            configParams.IptablesLockTimeoutSecs,
 			IptablesLockProbeInterval:      configParams.IptablesLockProbeIntervalMillis,
+			IptablesMaxChainsPerRestore:    configParams.IptablesMaxChainsPerRestore,
+			IptablesRestoreDeadline:        configParams.IptablesRestoreDeadlineSecs,
 			MaxIPSetSize:                   configParams.MaxIpsetSize,
 			IPv6Enabled:                    configParams.Ipv6Support,
 			StatusReportingInterval:        configParams.ReportingIntervalSecs,
@@ -335,15 +461,64 @@ func StartDataplaneDriver(configParams *config.Config,
 			},
 			HealthAggregator:                   healthAggregator,
 			DebugSimulateDataplaneHangAfter:    configParams.DebugSimulateDataplaneHangAfter,
+			DeferredStartupEnabled:             configParams.DeferredStartupEnabled,
 			ExternalNodesCidrs:                 configParams.ExternalNodesCIDRList,
+			PreferredHostIPVersion:             configParams.PreferredHostIPVersion(),
+			CNIReadinessGateEnabled:            configParams.CNIReadinessGateEnabled,
+			CNINetDir:                          configParams.CNINetDir,
+			CNIBinDir:                          configParams.CNIBinDir,
+			CNIPluginBinaries:                  configParams.CNIPluginBinaryList(),
+			WorkloadDrainEnabled:               configParams.WorkloadDrainEnabled,
+			WorkloadDrainFile:                  configParams.WorkloadDrainFile,
+			WorkloadDrainPollInterval:          configParams.WorkloadDrainPollInterval,
+			DropCapture:                        dropCapture,
+			DropCaptureSnapLength:              configParams.DropCaptureSnapLength,
+			RouteTableDebug:                    routeTableDebug,
+			BPFMemoryAccountant:                bpfMemoryAccountant,
+			CrashDumpDir:                       configParams.DebugCrashDumpDir,
+			CrashDumpMaxBytes:                  configParams.DebugCrashDumpMaxBytes,
+			NFTablesEnabled:                    configParams.NFTablesMode == "Enabled",
+			ManagerFailureBudget:               configParams.DataplaneManagerFailureBudget,
+			MaxMsgBatchSize:                    configParams.DataplaneMaxMsgBatchSize,
+			CPUBudget:                          configParams.DataplaneCPUBudget,
+			EgressSNATAddresses:                configParams.EgressSNATAddresses,
+			EgressSNATNamespaceAddresses:       configParams.EgressSNATNamespaceAddresses,
+			ExternalNetworks:                   configParams.ExternalNetworks,
+			ExternalNetworkTreatments:          configParams.ExternalNetworkTreatments,
+			ConntrackAccountingEnabled:         configParams.ConntrackAccountingEnabled && !configParams.BPFEnabled,
+			ConntrackAccountingInterval:        configParams.ConntrackAccountingInterval,
+			ConntrackAccountingTopN:            configParams.ConntrackAccountingTopN,
+			FlowLogsSink:                       flowLogsSink,
+			FlowLogsFlushInterval:              configParams.FlowLogsFlushInterval,
+			WorkloadConnRateLimit:              configParams.WorkloadConnRateLimit,
+			WorkloadConnRateLimitBurst:         configParams.WorkloadConnRateLimitBurst,
+			WorkloadEgressAllowlistMaxSize:     configParams.WorkloadEgressAllowlistMaxSize,
+			WorkloadLinkLocalExceptNS:          configParams.WorkloadLinkLocalExceptionNamespaceList(),
+			BPFWorkloadAllowedSourcesEnabled:   configParams.WorkloadAllowedSourcesEnabled && configParams.BPFEnabled,
+			WorkloadMACEnforcementByDefault:    configParams.WorkloadMACEnforcement == "Enabled",
+			WorkloadIfaceSettings:              workloadIfaceSettings(configParams),
+			NeighborGCTuningEnabled:            configParams.NeighborGCTuningEnabled,
+			NeighborGCStaleTime:                configParams.NeighborGCStaleTime,
+			PolicyRuleCountersInterval:         configParams.PolicyRuleCountersInterval,
+			DryRunReportFile:                   dryRunReportFile(configParams),
 			SidecarAccelerationEnabled:         configParams.SidecarAccelerationEnabled,
 			BPFEnabled:                         configParams.BPFEnabled,
 			BPFDisableUnprivileged:             configParams.BPFDisableUnprivileged,
 			BPFConnTimeLBEnabled:               configParams.BPFConnectTimeLoadBalancingEnabled,
+			BPFHostNATTableIndex:               bpfHostNATTableIndex,
 			BPFKubeProxyIptablesCleanupEnabled: configParams.BPFKubeProxyIptablesCleanupEnabled,
+			BPFKubeProxyMigrationMode:          configParams.BPFKubeProxyMigrationMode,
 			BPFLogLevel:                        configParams.BPFLogLevel,
 			BPFExtToServiceConnmark:            configParams.BPFExtToServiceConnmark,
 			BPFDataIfacePattern:                configParams.BPFDataIfacePattern,
+			BPFCgroupV2Root:                    configParams.BPFCgroupV2Root,
+			BPFAutoMountEnabled:                configParams.BPFAutoMountEnabled,
+			BPFMaglevEnabled:                   configParams.BPFMaglevEnabled,
+			BPFNodePortSourceRanges:            configParams.BPFNodePortSourceRanges,
+			BPFRouteClassifications:            bpfRouteClassifications,
+			BPFInterfaceDampingWindow:          configParams.BPFInterfaceDampingWindow,
+			BPFMaxParallelAttaches:             configParams.BPFMaxParallelAttaches,
+			BPFPinOwnerID:                      bpfPinOwnerID(configParams),
 			BPFCgroupV2:                        configParams.DebugBPFCgroupV2,
 			BPFMapRepin:                        configParams.DebugBPFMapRepinEnabled,
 			KubeProxyMinSyncPeriod:             configParams.BPFKubeProxyMinSyncPeriod,
@@ -351,6 +526,8 @@ func StartDataplaneDriver(configParams *config.Config,
 			XDPEnabled:                         configParams.XDPEnabled,
 			XDPAllowGeneric:                    configParams.GenericXDPEnabled,
 			BPFConntrackTimeouts:               conntrack.DefaultTimeouts(), // FIXME make timeouts configurable
+			BPFConntrackScanRateLimit:          configParams.BPFConntrackScanRateLimit,
+			BPFCountersScrapeInterval:          configParams.BPFCountersScrapeInterval,
 			RouteTableManager:                  routeTableIndexAllocator,
 			MTUIfacePattern:                    configParams.MTUIfacePattern,
 
@@ -361,6 +538,18 @@ func StartDataplaneDriver(configParams *config.Config,
 			RouteSource: configParams.RouteSource,
 
 			KubernetesProvider: configParams.KubernetesProvider(),
+
+			ServiceRoutesEnabled: configParams.ServiceRoutesEnabled,
+			ServiceRoutesDevice:  configParams.ServiceRoutesDevice,
+
+			ThreadSched: intdataplane.ThreadSchedConfig{
+				Nice:            configParams.DataplaneNice,
+				IOPriorityClass: configParams.DataplaneIOPriorityClass,
+				IOPriorityLevel: configParams.DataplaneIOPriorityLevel,
+				Policy:          configParams.DataplaneSchedPolicy,
+				Priority:        configParams.DataplaneSchedPriority,
+				CPUs:            configParams.DataplaneCPUAffinity,
+			},
 		}
 
 		if configParams.BPFExternalServiceMode == "dsr" {
@@ -382,8 +571,83 @@ func StartDataplaneDriver(configParams *config.Config,
 		log.WithField("driver", configParams.DataplaneDriver).Info(
 			"Using external dataplane driver.")
 
-		return extdataplane.StartExtDataplaneDriver(configParams.DataplaneDriver)
+		return extdataplane.StartExtDataplaneDriver(configParams.DataplaneDriver, configParams.DataplaneDriverHandshakeTimeout)
+	}
+}
+
+// findRouteTableConflicts returns the routing tables that the user has named in iproute2's
+// rt_tables files and that fall within Felix's RouteTableRange.  Felix would otherwise share (and
+// clean up) those tables so we report each one and exclude it from allocation.
+func findRouteTableConflicts(configParams *config.Config) (conflicts []idalloc.IndexRange) {
+	tables, err := idalloc.ReadRTTables(iproute2ConfigDir)
+	if err != nil {
+		log.WithError(err).Warn("Failed to read iproute2 routing table names, unable to check for conflicts.")
+		return
+	}
+	for _, t := range tables {
+		if !configParams.RouteTableRange.Contains(t.Index) {
+			continue
+		}
+		log.WithFields(log.Fields{
+			"index":           t.Index,
+			"name":            t.Name,
+			"file":            t.File,
+			"routeTableRange": fmt.Sprintf("%d-%d", configParams.RouteTableRange.Min, configParams.RouteTableRange.Max),
+		}).Error("Routing table is configured in iproute2 but is within Felix's RouteTableRange; Felix will not " +
+			"use it.  Change RouteTableRange (or add the table to RouteTableRangeExclusions) to silence this error.")
+		conflicts = append(conflicts, idalloc.IndexRange{Min: t.Index, Max: t.Index})
+	}
+	return
+}
+
+// bpfPinOwnerID returns the instance ID to label our BPF pins with, or "" if pin garbage
+// collection is disabled.
+func bpfPinOwnerID(configParams *config.Config) string {
+	if !configParams.BPFPinGCEnabled {
+		return ""
+	}
+	if configParams.BPFInstanceID != "" {
+		return configParams.BPFInstanceID
+	}
+	return configParams.FelixHostname
+}
+
+// workloadIfaceSettings translates the WorkloadInterface* config parameters into the settings that
+// the endpoint managers apply to workload interfaces.
+func workloadIfaceSettings(configParams *config.Config) intdataplane.WorkloadIfaceSettings {
+	rpFilterValues := map[string]string{
+		"Disabled": "0",
+		"Strict":   "1",
+		"Loose":    "2",
+	}
+	return intdataplane.WorkloadIfaceSettings{
+		RPFilter:                         rpFilterValues[configParams.WorkloadInterfaceRPFilter],
+		DisableAcceptRA:                  configParams.WorkloadInterfaceAcceptRA == "Disabled",
+		DisableTXChecksumOffload:         configParams.WorkloadInterfaceTXChecksumOffload == "Disabled",
+		DisableTXChecksumOffloadIfBroken: configParams.WorkloadInterfaceTXChecksumOffload == "Auto",
+	}
+}
+
+// dryRunReportFile returns the file to write the dry-run report to, or "" if dry-run mode is
+// disabled or not supported by the configured dataplane.
+func dryRunReportFile(configParams *config.Config) string {
+	if configParams.DataplaneDryRunReportFile == "" {
+		return ""
+	}
+	if configParams.BPFEnabled || configParams.NFTablesMode == "Enabled" {
+		// Refuse to start rather than program a dataplane that the operator wanted to preview.
+		log.Fatal("DataplaneDryRunReportFile is set but dry-run mode isn't supported in BPF or nftables mode.")
+	}
+	return logutils.RenderFileName(configParams.DataplaneDryRunReportFile)
+}
+
+// ReloadableConfigParams returns the names of the config parameters that the dataplane driver
+// applies in place, so that Felix doesn't need to restart when they change.
+func ReloadableConfigParams(configParams *config.Config) []string {
+	if !configParams.UseInternalDataplaneDriver {
+		return nil
 	}
+	return intdataplane.ReloadableConfigParams()
 }
 
 func SupportsBPF() error {
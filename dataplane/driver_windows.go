@@ -58,6 +58,10 @@ func StartDataplaneDriver(configParams *config.Config,
 	return winDP, nil
 }
 
+func ReloadableConfigParams(configParams *config.Config) []string {
+	return nil
+}
+
 func SupportsBPF() error {
 	return fmt.Errorf("BPF dataplane is not supported on Windows")
 }
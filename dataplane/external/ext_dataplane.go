@@ -22,17 +22,23 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"reflect"
+	"time"
 
 	pb "github.com/gogo/protobuf/proto"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/projectcalico/libcalico-go/lib/set"
+
 	_ "github.com/projectcalico/felix/config"
 	"github.com/projectcalico/felix/proto"
 )
 
 // StartExtDataplaneDriver starts the given driver as a child process and returns a
-// connection to it along with the command itself so that it may be monitored.
-func StartExtDataplaneDriver(driverFilename string) (*extDataplaneConn, *exec.Cmd) {
+// connection to it along with the command itself so that it may be monitored.  handshakeTimeout
+// is how long to wait for the driver to reply to the capabilities handshake; 0 disables the
+// handshake.
+func StartExtDataplaneDriver(driverFilename string, handshakeTimeout time.Duration) (*extDataplaneConn, *exec.Cmd) {
 	// Create a pair of pipes, one for sending messages to the dataplane
 	// driver, the other for receiving.
 	toDriverR, toDriverW, err := os.Pipe()
@@ -76,10 +82,7 @@ func StartExtDataplaneDriver(driverFilename string) (*extDataplaneConn, *exec.Cm
 		_ = cmd.Process.Kill()
 		log.WithError(err).Fatal("Failed to close parent's copy of pipe")
 	}
-	dataplaneConnection := &extDataplaneConn{
-		toDataplane:   toDriverW,
-		fromDataplane: fromDriverR,
-	}
+	dataplaneConnection := newExtDataplaneConn(fromDriverR, toDriverW, handshakeTimeout)
 
 	return dataplaneConnection, cmd
 }
@@ -88,58 +91,189 @@ type extDataplaneConn struct {
 	fromDataplane io.Reader
 	toDataplane   io.Writer
 	nextSeqNumber uint64
+
+	handshakeTimeout time.Duration
+	// driverCapsC carries the driver's reply to the handshake from the receiving goroutine to
+	// the sending goroutine.
+	driverCapsC chan *proto.ProtocolCapabilities
+	// capabilities holds the capabilities that the driver supports.  It is nil until the
+	// handshake is done.
+	capabilities set.Set
+	// skippedCapabilities holds the unsupported capabilities that we've already logged about.
+	skippedCapabilities set.Set
+}
+
+func newExtDataplaneConn(fromDataplane io.Reader, toDataplane io.Writer, handshakeTimeout time.Duration) *extDataplaneConn {
+	return &extDataplaneConn{
+		fromDataplane:       fromDataplane,
+		toDataplane:         toDataplane,
+		handshakeTimeout:    handshakeTimeout,
+		driverCapsC:         make(chan *proto.ProtocolCapabilities, 1),
+		skippedCapabilities: set.New(),
+	}
 }
 
 func (c *extDataplaneConn) RecvMessage() (msg interface{}, err error) {
+	for {
+		var envelope *proto.FromDataplane
+		envelope, err = c.recvEnvelope()
+		if err != nil {
+			return
+		}
+		if caps := envelope.GetCapabilities(); caps != nil {
+			// The driver's reply to the handshake is for us, not for our caller.
+			c.onDriverCapabilities(caps)
+			continue
+		}
+
+		switch payload := envelope.Payload.(type) {
+		case *proto.FromDataplane_ProcessStatusUpdate:
+			msg = payload.ProcessStatusUpdate
+		case *proto.FromDataplane_WorkloadEndpointStatusUpdate:
+			msg = payload.WorkloadEndpointStatusUpdate
+		case *proto.FromDataplane_WorkloadEndpointStatusRemove:
+			msg = payload.WorkloadEndpointStatusRemove
+		case *proto.FromDataplane_HostEndpointStatusUpdate:
+			msg = payload.HostEndpointStatusUpdate
+		case *proto.FromDataplane_HostEndpointStatusRemove:
+			msg = payload.HostEndpointStatusRemove
+		case *proto.FromDataplane_WireguardStatusUpdate:
+			msg = payload.WireguardStatusUpdate
+
+		default:
+			log.WithField("payload", payload).Warn("Ignoring unknown message from dataplane")
+		}
+
+		return
+	}
+}
+
+func (c *extDataplaneConn) recvEnvelope() (*proto.FromDataplane, error) {
 	buf := make([]byte, 8)
-	_, err = io.ReadFull(c.fromDataplane, buf)
+	_, err := io.ReadFull(c.fromDataplane, buf)
 	if err != nil {
-		return
+		return nil, err
 	}
 	length := binary.LittleEndian.Uint64(buf)
 
 	data := make([]byte, length)
 	_, err = io.ReadFull(c.fromDataplane, data)
 	if err != nil {
-		return
+		return nil, err
 	}
 
-	envelope := proto.FromDataplane{}
-	err = pb.Unmarshal(data, &envelope)
+	envelope := &proto.FromDataplane{}
+	err = pb.Unmarshal(data, envelope)
 	if err != nil {
-		return
+		return nil, err
 	}
 	log.WithField("envelope", envelope).Debug("Received message from dataplane.")
+	return envelope, nil
+}
 
-	switch payload := envelope.Payload.(type) {
-	case *proto.FromDataplane_ProcessStatusUpdate:
-		msg = payload.ProcessStatusUpdate
-	case *proto.FromDataplane_WorkloadEndpointStatusUpdate:
-		msg = payload.WorkloadEndpointStatusUpdate
-	case *proto.FromDataplane_WorkloadEndpointStatusRemove:
-		msg = payload.WorkloadEndpointStatusRemove
-	case *proto.FromDataplane_HostEndpointStatusUpdate:
-		msg = payload.HostEndpointStatusUpdate
-	case *proto.FromDataplane_HostEndpointStatusRemove:
-		msg = payload.HostEndpointStatusRemove
-	case *proto.FromDataplane_WireguardStatusUpdate:
-		msg = payload.WireguardStatusUpdate
-
+func (c *extDataplaneConn) onDriverCapabilities(caps *proto.ProtocolCapabilities) {
+	select {
+	case c.driverCapsC <- caps:
 	default:
-		log.WithField("payload", payload).Warn("Ignoring unknown message from dataplane")
+		log.WithField("capabilities", caps).Warn("Ignoring unexpected capabilities message from dataplane driver")
 	}
-
-	return
 }
 
+// SendMessage sends the given message to the driver, unless the driver doesn't support the
+// message's capability, in which case the message is dropped.  The first call does the
+// handshake with the driver.
 func (fc *extDataplaneConn) SendMessage(msg interface{}) error {
+	if fc.capabilities == nil {
+		if err := fc.handshake(); err != nil {
+			return err
+		}
+	}
+	if capability := proto.MessageCapability(msg); !fc.capabilities.Contains(capability) {
+		if !fc.skippedCapabilities.Contains(capability) {
+			log.WithField("capability", capability).Warn(
+				"Dataplane driver doesn't support capability, not sending it the related updates")
+			fc.skippedCapabilities.Add(capability)
+		}
+		return nil
+	}
+	return fc.sendMessage(msg)
+}
+
+// handshake sends our capabilities to the driver and waits for the driver to reply with the
+// subset that it supports.  A driver that doesn't reply in time is assumed to predate the
+// handshake.
+func (fc *extDataplaneConn) handshake() error {
+	if fc.handshakeTimeout <= 0 {
+		log.Info("Dataplane driver handshake disabled, assuming legacy capabilities.")
+		fc.capabilities = set.FromArray(proto.LegacyCapabilities)
+		return nil
+	}
+
+	err := fc.sendMessage(&proto.ProtocolCapabilities{
+		ProtocolVersion: proto.ProtocolVersion,
+		Capabilities:    proto.AllCapabilities,
+	})
+	if err != nil {
+		return err
+	}
+
+	select {
+	case caps := <-fc.driverCapsC:
+		log.WithFields(log.Fields{
+			"protocolVersion": caps.ProtocolVersion,
+			"capabilities":    caps.Capabilities,
+		}).Info("Dataplane driver replied to handshake.")
+		fc.capabilities = set.FromArray(caps.Capabilities)
+		// Every driver has to handle the core messages, whether it lists them or not.
+		fc.capabilities.Add(proto.CapabilityCore)
+	case <-time.After(fc.handshakeTimeout):
+		log.WithField("timeout", fc.handshakeTimeout).Warn(
+			"Dataplane driver didn't reply to handshake, assuming legacy capabilities.")
+		fc.capabilities = set.FromArray(proto.LegacyCapabilities)
+	}
+	return nil
+}
+
+func (fc *extDataplaneConn) sendMessage(msg interface{}) error {
 	log.Debugf("Writing msg (%v) to felix: %#v", fc.nextSeqNumber, msg)
-	// Wrap the payload message in an envelope so that protobuf takes care of deserialising
-	// it as the correct type.
-	envelope := &proto.ToDataplane{
-		SequenceNumber: fc.nextSeqNumber,
+	envelope, ok := WrapToDataplane(msg)
+	if !ok {
+		log.WithField("msg", msg).Panic("Unknown message type")
 	}
+	envelope.SequenceNumber = fc.nextSeqNumber
 	fc.nextSeqNumber += 1
+	data, err := pb.Marshal(envelope)
+
+	if err != nil {
+		log.WithError(err).WithField("msg", msg).Panic(
+			"Failed to marshal data to front end")
+	}
+
+	lengthBytes := make([]byte, 8)
+	binary.LittleEndian.PutUint64(lengthBytes, uint64(len(data)))
+	var messageBuf bytes.Buffer
+	messageBuf.Write(lengthBytes)
+	messageBuf.Write(data)
+	for {
+		_, err := messageBuf.WriteTo(fc.toDataplane)
+		if err == io.ErrShortWrite {
+			log.Warn("Short write to dataplane driver; buffer full?")
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		log.Debug("Wrote message to dataplane driver")
+		break
+	}
+	return nil
+}
+
+// WrapToDataplane wraps the given message in a ToDataplane envelope so that protobuf takes care of
+// deserialising it as the correct type.  Returns false if the message isn't one of the envelope's
+// payload types.
+func WrapToDataplane(msg interface{}) (*proto.ToDataplane, bool) {
+	envelope := &proto.ToDataplane{}
 	switch msg := msg.(type) {
 	case *proto.ConfigUpdate:
 		envelope.Payload = &proto.ToDataplane_ConfigUpdate{ConfigUpdate: msg}
@@ -197,33 +331,25 @@ func (fc *extDataplaneConn) SendMessage(msg interface{}) error {
 		envelope.Payload = &proto.ToDataplane_WireguardEndpointRemove{WireguardEndpointRemove: msg}
 	case *proto.GlobalBGPConfigUpdate:
 		envelope.Payload = &proto.ToDataplane_GlobalBgpConfigUpdate{GlobalBgpConfigUpdate: msg}
+	case *proto.StaticRouteUpdate:
+		envelope.Payload = &proto.ToDataplane_StaticRouteUpdate{StaticRouteUpdate: msg}
+	case *proto.StaticRouteRemove:
+		envelope.Payload = &proto.ToDataplane_StaticRouteRemove{StaticRouteRemove: msg}
+	case *proto.ProtocolCapabilities:
+		envelope.Payload = &proto.ToDataplane_Capabilities{Capabilities: msg}
 
 	default:
-		log.WithField("msg", msg).Panic("Unknown message type")
+		return nil, false
 	}
-	data, err := pb.Marshal(envelope)
+	return envelope, true
+}
 
-	if err != nil {
-		log.WithError(err).WithField("msg", msg).Panic(
-			"Failed to marshal data to front end")
+// UnwrapToDataplane is the inverse of WrapToDataplane; it returns the message inside the envelope,
+// or nil if the envelope is empty.
+func UnwrapToDataplane(envelope *proto.ToDataplane) interface{} {
+	if envelope.Payload == nil {
+		return nil
 	}
-
-	lengthBytes := make([]byte, 8)
-	binary.LittleEndian.PutUint64(lengthBytes, uint64(len(data)))
-	var messageBuf bytes.Buffer
-	messageBuf.Write(lengthBytes)
-	messageBuf.Write(data)
-	for {
-		_, err := messageBuf.WriteTo(fc.toDataplane)
-		if err == io.ErrShortWrite {
-			log.Warn("Short write to dataplane driver; buffer full?")
-			continue
-		}
-		if err != nil {
-			return err
-		}
-		log.Debug("Wrote message to dataplane driver")
-		break
-	}
-	return nil
+	// Each oneof payload type is a struct with the message as its only field.
+	return reflect.ValueOf(envelope.Payload).Elem().Field(0).Interface()
 }
@@ -0,0 +1,177 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package extdataplane
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+
+	pb "github.com/gogo/protobuf/proto"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/proto"
+)
+
+// fakeDriver is the driver's end of a connection: it decodes everything that Felix sends and
+// lets the test write messages back.
+type fakeDriver struct {
+	fromFelix io.Reader
+	toFelix   io.Writer
+	received  chan interface{}
+}
+
+func (d *fakeDriver) readLoop() {
+	defer GinkgoRecover()
+	for {
+		buf := make([]byte, 8)
+		if _, err := io.ReadFull(d.fromFelix, buf); err != nil {
+			return
+		}
+		data := make([]byte, binary.LittleEndian.Uint64(buf))
+		if _, err := io.ReadFull(d.fromFelix, data); err != nil {
+			return
+		}
+		envelope := &proto.ToDataplane{}
+		Expect(pb.Unmarshal(data, envelope)).To(Succeed())
+		d.received <- UnwrapToDataplane(envelope)
+	}
+}
+
+func (d *fakeDriver) send(envelope *proto.FromDataplane) {
+	data, err := pb.Marshal(envelope)
+	Expect(err).NotTo(HaveOccurred())
+	lengthBytes := make([]byte, 8)
+	binary.LittleEndian.PutUint64(lengthBytes, uint64(len(data)))
+	_, err = d.toFelix.Write(append(lengthBytes, data...))
+	Expect(err).NotTo(HaveOccurred())
+}
+
+var _ = Describe("External dataplane connection", func() {
+	var (
+		conn      *extDataplaneConn
+		driver    *fakeDriver
+		recvdMsgs chan interface{}
+	)
+
+	configUpdate := &proto.ConfigUpdate{Config: map[string]string{"LogSeverityScreen": "Info"}}
+	wgUpdate := &proto.WireguardEndpointUpdate{Hostname: "host1"}
+	staticRoute := &proto.StaticRouteUpdate{Id: "r1", Dst: "10.0.0.0/8"}
+	routeUpdate := &proto.RouteUpdate{Dst: "10.65.0.0/26"}
+
+	connect := func(handshakeTimeout time.Duration) {
+		toDriverR, toDriverW := io.Pipe()
+		fromDriverR, fromDriverW := io.Pipe()
+		driver = &fakeDriver{
+			fromFelix: toDriverR,
+			toFelix:   fromDriverW,
+			received:  make(chan interface{}, 10),
+		}
+		go driver.readLoop()
+		conn = newExtDataplaneConn(fromDriverR, toDriverW, handshakeTimeout)
+
+		recvdMsgs = make(chan interface{}, 10)
+		go func() {
+			for {
+				msg, err := conn.RecvMessage()
+				if err != nil {
+					return
+				}
+				recvdMsgs <- msg
+			}
+		}()
+	}
+
+	sendAsync := func(msg interface{}) chan error {
+		done := make(chan error, 1)
+		go func() {
+			done <- conn.SendMessage(msg)
+		}()
+		return done
+	}
+
+	Describe("with a driver that supports the handshake", func() {
+		BeforeEach(func() {
+			connect(10 * time.Second)
+			done := sendAsync(configUpdate)
+
+			var hello interface{}
+			Eventually(driver.received).Should(Receive(&hello))
+			Expect(hello).To(Equal(&proto.ProtocolCapabilities{
+				ProtocolVersion: proto.ProtocolVersion,
+				Capabilities:    proto.AllCapabilities,
+			}))
+			driver.send(&proto.FromDataplane{Payload: &proto.FromDataplane_Capabilities{
+				Capabilities: &proto.ProtocolCapabilities{
+					ProtocolVersion: 1,
+					Capabilities:    []string{proto.CapabilityRoutes, proto.CapabilityStaticRoutes},
+				},
+			}})
+			Eventually(done).Should(Receive(BeNil()))
+		})
+
+		It("should send the config after the handshake", func() {
+			Eventually(driver.received).Should(Receive(Equal(configUpdate)))
+		})
+
+		It("should only send messages for the driver's capabilities", func() {
+			Expect(conn.SendMessage(wgUpdate)).To(Succeed())
+			Expect(conn.SendMessage(staticRoute)).To(Succeed())
+			Expect(conn.SendMessage(routeUpdate)).To(Succeed())
+
+			Eventually(driver.received).Should(Receive(Equal(configUpdate)))
+			Eventually(driver.received).Should(Receive(Equal(staticRoute)))
+			Eventually(driver.received).Should(Receive(Equal(routeUpdate)))
+			Consistently(driver.received).ShouldNot(Receive())
+		})
+
+		It("should pass on other messages from the driver", func() {
+			status := &proto.ProcessStatusUpdate{IsoTimestamp: "2021-01-01T00:00:00Z"}
+			driver.send(&proto.FromDataplane{Payload: &proto.FromDataplane_ProcessStatusUpdate{
+				ProcessStatusUpdate: status,
+			}})
+			Eventually(recvdMsgs).Should(Receive(Equal(status)))
+		})
+	})
+
+	Describe("with a driver that doesn't reply to the handshake", func() {
+		BeforeEach(func() {
+			connect(10 * time.Millisecond)
+			Expect(conn.SendMessage(configUpdate)).To(Succeed())
+			Eventually(driver.received).Should(Receive(BeAssignableToTypeOf(&proto.ProtocolCapabilities{})))
+			Eventually(driver.received).Should(Receive(Equal(configUpdate)))
+		})
+
+		It("should only send the legacy messages", func() {
+			Expect(conn.SendMessage(staticRoute)).To(Succeed())
+			Expect(conn.SendMessage(wgUpdate)).To(Succeed())
+
+			Eventually(driver.received).Should(Receive(Equal(wgUpdate)))
+			Consistently(driver.received).ShouldNot(Receive())
+		})
+	})
+
+	Describe("with the handshake disabled", func() {
+		BeforeEach(func() {
+			connect(0)
+		})
+
+		It("should send the config without a handshake", func() {
+			Expect(conn.SendMessage(configUpdate)).To(Succeed())
+			Eventually(driver.received).Should(Receive(Equal(configUpdate)))
+		})
+	})
+})
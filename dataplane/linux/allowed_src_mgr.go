@@ -0,0 +1,103 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/ip"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/rules"
+)
+
+// The workload allowed sources manager lets workloads send from source prefixes other than their
+// own IPs, which VMs (for example, KubeVirt guests) need when the guest owns extra addresses.
+// Normally, the RPF check in the raw table drops such traffic as spoofed.  The manager maintains
+// a chain in the raw table that marks packets from each workload interface that come from one
+// of the workload's AllowedSourcePrefixes, which exempts them from the RPF check.
+//
+// (In BPF mode, the BPF route manager adds routes for the prefixes to the workload's interface
+// instead, which is what the BPF workload RPF check looks for.)
+type workloadAllowedSourcesManager struct {
+	// Our dependencies.
+	rawTable     iptablesTable
+	ruleRenderer rules.RuleRenderer
+	ipVersion    uint8
+
+	// Internal state.
+	workloads map[proto.WorkloadEndpointID]*proto.WorkloadEndpoint
+	dirty     bool
+}
+
+func newWorkloadAllowedSourcesManager(
+	rawTable iptablesTable,
+	ruleRenderer rules.RuleRenderer,
+	ipVersion uint8,
+) *workloadAllowedSourcesManager {
+	return &workloadAllowedSourcesManager{
+		rawTable:     rawTable,
+		ruleRenderer: ruleRenderer,
+		ipVersion:    ipVersion,
+		workloads:    map[proto.WorkloadEndpointID]*proto.WorkloadEndpoint{},
+		dirty:        true,
+	}
+}
+
+func (m *workloadAllowedSourcesManager) OnUpdate(protoBufMsg interface{}) {
+	switch msg := protoBufMsg.(type) {
+	case *proto.WorkloadEndpointUpdate:
+		m.workloads[*msg.Id] = msg.Endpoint
+		m.dirty = true
+	case *proto.WorkloadEndpointRemove:
+		if _, ok := m.workloads[*msg.Id]; ok {
+			delete(m.workloads, *msg.Id)
+			m.dirty = true
+		}
+	}
+}
+
+func (m *workloadAllowedSourcesManager) CompleteDeferredWork() error {
+	if !m.dirty {
+		return nil
+	}
+	var allowed []rules.WorkloadAllowedSources
+	for id, ep := range m.workloads {
+		var cidrs []string
+		for _, s := range ep.AllowedSourcePrefixes {
+			cidr, err := ip.ParseCIDROrIP(s)
+			if err != nil {
+				log.WithError(err).WithFields(log.Fields{
+					"workload": id,
+					"cidr":     s,
+				}).Warn("Ignoring workload's unparsable allowed source prefix.")
+				continue
+			}
+			if cidr.Version() != m.ipVersion {
+				continue
+			}
+			cidrs = append(cidrs, cidr.String())
+		}
+		if len(cidrs) == 0 {
+			continue
+		}
+		allowed = append(allowed, rules.WorkloadAllowedSources{
+			IfaceName: ep.Name,
+			CIDRs:     cidrs,
+		})
+	}
+	m.rawTable.UpdateChain(m.ruleRenderer.WorkloadAllowedSourcesChain(allowed))
+	m.dirty = false
+	return nil
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/ipsets"
+	"github.com/projectcalico/felix/iptables"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/rules"
+)
+
+var _ = Describe("Workload allowed sources manager", func() {
+	var (
+		allowedMgr   *workloadAllowedSourcesManager
+		rawTable     *mockTable
+		ruleRenderer rules.RuleRenderer
+	)
+
+	wlID1 := proto.WorkloadEndpointID{
+		OrchestratorId: "k8s",
+		WorkloadId:     "pod-11",
+		EndpointId:     "endpoint-id-11",
+	}
+	wlID2 := proto.WorkloadEndpointID{
+		OrchestratorId: "k8s",
+		WorkloadId:     "pod-12",
+		EndpointId:     "endpoint-id-12",
+	}
+
+	allowRule := func(iface, cidr string) iptables.Rule {
+		return iptables.Rule{
+			Match:  iptables.Match().InInterface(iface).SourceNet(cidr),
+			Action: iptables.SetMarkAction{Mark: 0x8},
+		}
+	}
+
+	addWorkload := func(id proto.WorkloadEndpointID, iface string, prefixes ...string) {
+		allowedMgr.OnUpdate(&proto.WorkloadEndpointUpdate{
+			Id: &id,
+			Endpoint: &proto.WorkloadEndpoint{
+				Name:                  iface,
+				Ipv4Nets:              []string{"10.0.240.10/32"},
+				AllowedSourcePrefixes: prefixes,
+			},
+		})
+	}
+
+	BeforeEach(func() {
+		rawTable = newMockTable("raw")
+		ruleRenderer = rules.NewRenderer(rules.Config{
+			IPSetConfigV4: ipsets.NewIPVersionConfig(
+				ipsets.IPFamilyV4,
+				"cali",
+				nil,
+				nil,
+			),
+			IptablesMarkPass:              0x1,
+			IptablesMarkAccept:            0x2,
+			IptablesMarkScratch0:          0x4,
+			IptablesMarkScratch1:          0x8,
+			IptablesMarkEndpoint:          0x11110000,
+			WorkloadAllowedSourcesEnabled: true,
+		})
+		allowedMgr = newWorkloadAllowedSourcesManager(rawTable, ruleRenderer, 4)
+	})
+
+	It("should program an empty chain with no workloads", func() {
+		Expect(allowedMgr.CompleteDeferredWork()).To(Succeed())
+		rawTable.checkChains([][]*iptables.Chain{{{
+			Name: rules.ChainWorkloadAllowedSources,
+		}}})
+	})
+
+	Describe("with workloads", func() {
+		BeforeEach(func() {
+			addWorkload(wlID1, "cali1", "10.65.0.0/24", "10.66.0.1", "fd00::1/128", "bogus")
+			addWorkload(wlID2, "cali2")
+			Expect(allowedMgr.CompleteDeferredWork()).To(Succeed())
+		})
+
+		It("should only allow the valid prefixes of the right IP version", func() {
+			rawTable.checkChains([][]*iptables.Chain{{{
+				Name: rules.ChainWorkloadAllowedSources,
+				Rules: []iptables.Rule{
+					allowRule("cali1", "10.65.0.0/24"),
+					allowRule("cali1", "10.66.0.1/32"),
+				},
+			}}})
+		})
+
+		It("should handle a workload gaining prefixes", func() {
+			addWorkload(wlID2, "cali2", "10.67.0.0/16")
+			Expect(allowedMgr.CompleteDeferredWork()).To(Succeed())
+			rawTable.checkChains([][]*iptables.Chain{{{
+				Name: rules.ChainWorkloadAllowedSources,
+				Rules: []iptables.Rule{
+					allowRule("cali1", "10.65.0.0/24"),
+					allowRule("cali1", "10.66.0.1/32"),
+					allowRule("cali2", "10.67.0.0/16"),
+				},
+			}}})
+		})
+
+		It("should remove the prefixes when the workload is removed", func() {
+			allowedMgr.OnUpdate(&proto.WorkloadEndpointRemove{Id: &wlID1})
+			Expect(allowedMgr.CompleteDeferredWork()).To(Succeed())
+			rawTable.checkChains([][]*iptables.Chain{{{
+				Name: rules.ChainWorkloadAllowedSources,
+			}}})
+		})
+	})
+})
@@ -0,0 +1,149 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/bpf"
+	"github.com/projectcalico/felix/bpf/counters"
+	"github.com/projectcalico/felix/ifacemonitor"
+)
+
+var (
+	countVecBPFPackets = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "felix_bpf_packets_total",
+		Help: "Number of packets that the BPF programs accepted or dropped, by interface, hook and result.",
+	}, []string{"interface", "hook", "result"})
+	countVecBPFNATConns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "felix_bpf_nat_new_connections_total",
+		Help: "Number of new connections that the BPF programs DNATed to a service backend, by interface and hook.",
+	}, []string{"interface", "hook"})
+)
+
+func init() {
+	prometheus.MustRegister(countVecBPFPackets)
+	prometheus.MustRegister(countVecBPFNATConns)
+}
+
+// The BPF counters manager exports the per-interface packet counters that the BPF programs keep in
+// the counters map as Prometheus metrics.  Each scrape adds the increase since the previous scrape
+// to the metrics.  When an interface goes down, the manager removes its map entries and its
+// metrics so that neither grows without bound as workloads come and go.
+type bpfCountersManager struct {
+	countersMap bpf.Map
+
+	ifaceNames map[uint32]string
+	// lastValues holds the counters that we saw at the last scrape.
+	lastValues map[counters.Key]counters.Value
+	// ifacesToClean holds the interfaces that have gone down since the last scrape.
+	ifacesToClean map[uint32]string
+	scrapePending bool
+}
+
+func newBPFCountersManager(countersMap bpf.Map) *bpfCountersManager {
+	return &bpfCountersManager{
+		countersMap:   countersMap,
+		ifaceNames:    map[uint32]string{},
+		lastValues:    map[counters.Key]counters.Value{},
+		ifacesToClean: map[uint32]string{},
+	}
+}
+
+func (m *bpfCountersManager) OnUpdate(protoBufMsg interface{}) {
+	msg, ok := protoBufMsg.(*ifaceUpdate)
+	if !ok {
+		return
+	}
+	ifIndex := uint32(msg.Index)
+	if msg.State == ifacemonitor.StateUp {
+		m.ifaceNames[ifIndex] = msg.Name
+		delete(m.ifacesToClean, ifIndex)
+		return
+	}
+	if _, known := m.ifaceNames[ifIndex]; known {
+		m.ifacesToClean[ifIndex] = msg.Name
+		delete(m.ifaceNames, ifIndex)
+	}
+}
+
+// QueueScrape asks the manager to read the counters map on the next call to
+// CompleteDeferredWork.
+func (m *bpfCountersManager) QueueScrape() {
+	m.scrapePending = true
+}
+
+func (m *bpfCountersManager) CompleteDeferredWork() error {
+	for ifIndex, name := range m.ifacesToClean {
+		m.cleanUpIface(ifIndex, name)
+		delete(m.ifacesToClean, ifIndex)
+	}
+
+	if !m.scrapePending {
+		return nil
+	}
+	m.scrapePending = false
+
+	values, err := counters.LoadMapMem(m.countersMap)
+	if err != nil {
+		// Not worth retrying early; the next scrape picks up the increase since the last one.
+		log.WithError(err).Warn("Failed to read BPF counters map.")
+		return nil
+	}
+	for k, v := range values {
+		name, ok := m.ifaceNames[k.IfIndex()]
+		if !ok {
+			// We haven't heard about the interface yet (or it has just gone); the next scrape
+			// will catch up.
+			continue
+		}
+		last, seen := m.lastValues[k]
+		for _, c := range counters.Counters {
+			n := v.Get(c)
+			if seen && last.Get(c) <= n {
+				n -= last.Get(c)
+			}
+			if n == 0 {
+				continue
+			}
+			if c == counters.NAT {
+				countVecBPFNATConns.WithLabelValues(name, k.Hook().String()).Add(float64(n))
+			} else {
+				countVecBPFPackets.WithLabelValues(name, k.Hook().String(), c.String()).Add(float64(n))
+			}
+		}
+		m.lastValues[k] = v
+	}
+	return nil
+}
+
+func (m *bpfCountersManager) cleanUpIface(ifIndex uint32, name string) {
+	log.WithFields(log.Fields{"iface": name, "ifIndex": ifIndex}).Debug("Removing BPF counters for interface.")
+	for _, hook := range []counters.Hook{counters.HookIngress, counters.HookEgress} {
+		k := counters.NewKey(ifIndex, hook)
+		if err := m.countersMap.Delete(k[:]); err != nil && !bpf.IsNotExists(err) {
+			log.WithError(err).WithField("key", k).Warn("Failed to delete BPF counters.")
+		}
+		delete(m.lastValues, k)
+		for _, c := range counters.Counters {
+			if c == counters.NAT {
+				countVecBPFNATConns.DeleteLabelValues(name, hook.String())
+			} else {
+				countVecBPFPackets.DeleteLabelValues(name, hook.String(), c.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/projectcalico/felix/bpf/counters"
+	"github.com/projectcalico/felix/bpf/mock"
+	"github.com/projectcalico/felix/ifacemonitor"
+)
+
+var _ = Describe("BPF counters manager", func() {
+	var (
+		mgr         *bpfCountersManager
+		countersMap *mock.Map
+	)
+
+	packets := func(iface, hook, result string) float64 {
+		return testutil.ToFloat64(countVecBPFPackets.WithLabelValues(iface, hook, result))
+	}
+	setCounts := func(ifIndex uint32, hook counters.Hook, counts map[counters.Counter]uint64) {
+		k := counters.NewKey(ifIndex, hook)
+		v := counters.NewValue(counts)
+		countersMap.Contents[string(k[:])] = string(v[:])
+	}
+	scrape := func() {
+		mgr.QueueScrape()
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		countVecBPFPackets.Reset()
+		countVecBPFNATConns.Reset()
+		countersMap = mock.NewMockMap(counters.MapParams)
+		mgr = newBPFCountersManager(countersMap)
+		mgr.OnUpdate(&ifaceUpdate{Name: "eth0", State: ifacemonitor.StateUp, Index: 2})
+		mgr.OnUpdate(&ifaceUpdate{Name: "cali1234", State: ifacemonitor.StateUp, Index: 10})
+	})
+
+	It("should only read the map when a scrape is queued", func() {
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(countersMap.IterCount).To(Equal(0))
+		scrape()
+		Expect(countersMap.IterCount).To(Equal(1))
+	})
+
+	It("should export the increase in each counter", func() {
+		setCounts(2, counters.HookIngress, map[counters.Counter]uint64{
+			counters.Accepted:      100,
+			counters.DroppedPolicy: 3,
+		})
+		setCounts(10, counters.HookEgress, map[counters.Counter]uint64{
+			counters.DroppedRPF: 5,
+			counters.NAT:        7,
+		})
+		scrape()
+		Expect(packets("eth0", "ingress", "accepted")).To(Equal(100.0))
+		Expect(packets("eth0", "ingress", "dropped-policy")).To(Equal(3.0))
+		Expect(packets("cali1234", "egress", "dropped-rpf")).To(Equal(5.0))
+		Expect(testutil.ToFloat64(countVecBPFNATConns.WithLabelValues("cali1234", "egress"))).To(Equal(7.0))
+
+		setCounts(2, counters.HookIngress, map[counters.Counter]uint64{
+			counters.Accepted:      150,
+			counters.DroppedPolicy: 3,
+		})
+		scrape()
+		Expect(packets("eth0", "ingress", "accepted")).To(Equal(150.0))
+		Expect(packets("eth0", "ingress", "dropped-policy")).To(Equal(3.0))
+	})
+
+	It("should handle the counters being reset", func() {
+		setCounts(2, counters.HookIngress, map[counters.Counter]uint64{counters.Accepted: 100})
+		scrape()
+		setCounts(2, counters.HookIngress, map[counters.Counter]uint64{counters.Accepted: 10})
+		scrape()
+		Expect(packets("eth0", "ingress", "accepted")).To(Equal(110.0))
+	})
+
+	It("should ignore interfaces that it doesn't know about", func() {
+		setCounts(99, counters.HookIngress, map[counters.Counter]uint64{counters.Accepted: 100})
+		scrape()
+		Expect(testutil.CollectAndCount(countVecBPFPackets)).To(Equal(0))
+	})
+
+	It("should clean up when an interface goes down", func() {
+		setCounts(10, counters.HookIngress, map[counters.Counter]uint64{counters.Accepted: 100})
+		setCounts(2, counters.HookIngress, map[counters.Counter]uint64{counters.Accepted: 100})
+		scrape()
+		Expect(testutil.CollectAndCount(countVecBPFPackets)).To(Equal(2))
+
+		mgr.OnUpdate(&ifaceUpdate{Name: "cali1234", State: ifacemonitor.StateDown, Index: 10})
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(testutil.CollectAndCount(countVecBPFPackets)).To(Equal(1))
+		Expect(countersMap.Contents).To(HaveLen(1))
+		k := counters.NewKey(2, counters.HookIngress)
+		Expect(countersMap.Contents).To(HaveKey(string(k[:])))
+	})
+})
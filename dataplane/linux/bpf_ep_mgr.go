@@ -1,3 +1,4 @@
+//go:build !windows
 // +build !windows
 
 // Copyright (c) 2020-2021 Tigera, Inc. All rights reserved.
@@ -19,7 +20,9 @@ package intdataplane
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -36,14 +39,17 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
 	"golang.org/x/sync/semaphore"
 	"golang.org/x/sys/unix"
 
 	"github.com/projectcalico/felix/logutils"
 
+	cprometheus "github.com/projectcalico/libcalico-go/lib/prometheus"
 	"github.com/projectcalico/libcalico-go/lib/set"
 
 	"github.com/projectcalico/felix/bpf"
+	"github.com/projectcalico/felix/bpf/asm"
 	"github.com/projectcalico/felix/bpf/polprog"
 	"github.com/projectcalico/felix/bpf/tc"
 	"github.com/projectcalico/felix/idalloc"
@@ -68,21 +74,34 @@ var (
 		Name: "felix_bpf_happy_dataplane_endpoints",
 		Help: "Number of BPF endpoints that are successfully programmed.",
 	})
+	bpfAttachQueueDepthGauge = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "felix_bpf_attach_queue_depth",
+		Help: "Number of interfaces waiting for BPF programs to be attached, including those " +
+			"that are waiting for their damping window to pass.",
+	})
+	summaryBPFAttachTime = cprometheus.NewSummary(prometheus.SummaryOpts{
+		Name: "felix_bpf_attach_time_seconds",
+		Help: "Time in seconds that it took to attach BPF programs to an interface.",
+	})
 )
 
 func init() {
 	prometheus.MustRegister(bpfEndpointsGauge)
 	prometheus.MustRegister(bpfDirtyEndpointsGauge)
 	prometheus.MustRegister(bpfHappyEndpointsGauge)
+	prometheus.MustRegister(bpfAttachQueueDepthGauge)
+	prometheus.MustRegister(summaryBPFAttachTime)
 }
 
 type bpfDataplane interface {
 	ensureStarted()
 	ensureProgramAttached(ap *tc.AttachPoint, polDirection PolDirection) (bpf.MapFD, error)
 	ensureQdisc(iface string) error
-	updatePolicyProgram(jumpMapFD bpf.MapFD, rules polprog.Rules) error
-	removePolicyProgram(jumpMapFD bpf.MapFD) error
+	updatePolicyProgram(ap *tc.AttachPoint, jumpMapFD bpf.MapFD, rules polprog.Rules) error
+	removePolicyProgram(ap *tc.AttachPoint, jumpMapFD bpf.MapFD) error
+	removePolicyRecords(iface string) error
 	setAcceptLocal(iface string, val bool) error
+	getIfaceLinkType(iface string) (string, error)
 }
 
 type bpfInterface struct {
@@ -96,7 +115,11 @@ type bpfInterface struct {
 }
 
 type bpfInterfaceInfo struct {
-	ifaceIsUp  bool
+	ifaceIsUp bool
+	// ifIndex is the kernel's index for the interface.  Devices are sometimes recreated with the
+	// same name (for example, when a bond is reconfigured); the new index tells us that the
+	// programs that we attached went away with the old device.
+	ifIndex    int
 	endpointID *proto.WorkloadEndpointID
 }
 
@@ -121,6 +144,16 @@ type bpfEndpointManager struct {
 
 	dirtyIfaceNames set.Set
 
+	// Interface event damping.  Dirty interfaces that have had an interface event within the
+	// damping window are left dirty until the window passes without further events, so that a
+	// storm of events for an interface results in a single attach.
+	ifaceDampingWindow  time.Duration
+	ifaceLastEventTime  map[string]time.Time
+	dampedIfaceNames    set.Set
+	nextDampingExpiry   time.Duration
+	maxParallelAttaches int
+	now                 func() time.Time
+
 	bpfLogLevel             string
 	hostname                string
 	hostIP                  net.IP
@@ -129,11 +162,17 @@ type bpfEndpointManager struct {
 	workloadIfaceRegex      *regexp.Regexp
 	ipSetIDAlloc            *idalloc.IDAllocator
 	epToHostAction          string
+	epToHostActionOverrides bool
 	vxlanMTU                int
 	vxlanPort               uint16
 	dsrEnabled              bool
 	bpfExtToServiceConnmark int
 
+	// linkLocalTier, if non-nil, is the tier that applies WorkloadLinkLocalAccess ahead of the
+	// policy of workloads that aren't in one of the linkLocalExceptNS namespaces.
+	linkLocalTier     *polprog.Tier
+	linkLocalExceptNS set.Set
+
 	ipSetMap bpf.Map
 	stateMap bpf.Map
 
@@ -142,6 +181,9 @@ type bpfEndpointManager struct {
 
 	startupOnce      sync.Once
 	mapCleanupRunner *ratelimited.Runner
+	// pinOwner, if non-nil, labels our programs' pins, which mapCleanupRunner then garbage
+	// collects by label rather than by scanning all the jump maps.
+	pinOwner *tc.PinOwner
 
 	// onStillAlive is called from loops to reset the watchdog.
 	onStillAlive func()
@@ -156,6 +198,8 @@ type bpfEndpointManager struct {
 
 	ifaceToIpMap map[string]net.IP
 	opReporter   logutils.OpRecorder
+	// memAccountant, if non-nil, keeps track of the size of the programs that we load.
+	memAccountant *bpf.MemoryAccountant
 }
 
 type bpfAllowChainRenderer interface {
@@ -167,6 +211,10 @@ func newBPFEndpointManager(
 	hostname string,
 	fibLookupEnabled bool,
 	epToHostAction string,
+	epToHostActionOverrides bool,
+	linkLocalAccess string,
+	linkLocalServices []string,
+	linkLocalExceptNamespaces []string,
 	dataIfaceRegex *regexp.Regexp,
 	workloadIfaceRegex *regexp.Regexp,
 	ipSetIDAlloc *idalloc.IDAllocator,
@@ -174,16 +222,25 @@ func newBPFEndpointManager(
 	vxlanPort uint16,
 	dsrEnabled bool,
 	bpfExtToServiceConnmark int,
+	ifaceDampingWindow time.Duration,
+	maxParallelAttaches int,
+	pinOwnerID string,
 	ipSetMap bpf.Map,
 	stateMap bpf.Map,
 	iptablesRuleRenderer bpfAllowChainRenderer,
 	iptablesFilterTable iptablesTable,
 	livenessCallback func(),
 	opReporter logutils.OpRecorder,
+	memAccountant *bpf.MemoryAccountant,
 ) *bpfEndpointManager {
 	if livenessCallback == nil {
 		livenessCallback = func() {}
 	}
+	if maxParallelAttaches <= 0 {
+		// Without a limit, all the workers vie for CPU and complete slowly.  On a constrained
+		// system, we can end up taking too long and going non-ready.
+		maxParallelAttaches = runtime.GOMAXPROCS(0)
+	}
 	m := &bpfEndpointManager{
 		allWEPs:                 map[proto.WorkloadEndpointID]*proto.WorkloadEndpoint{},
 		happyWEPs:               map[proto.WorkloadEndpointID]*proto.WorkloadEndpoint{},
@@ -194,6 +251,11 @@ func newBPFEndpointManager(
 		policiesToWorkloads:     map[proto.PolicyID]set.Set{},
 		profilesToWorkloads:     map[proto.ProfileID]set.Set{},
 		dirtyIfaceNames:         set.New(),
+		ifaceDampingWindow:      ifaceDampingWindow,
+		ifaceLastEventTime:      map[string]time.Time{},
+		dampedIfaceNames:        set.New(),
+		maxParallelAttaches:     maxParallelAttaches,
+		now:                     time.Now,
 		bpfLogLevel:             bpfLogLevel,
 		hostname:                hostname,
 		fibLookupEnabled:        fibLookupEnabled,
@@ -201,6 +263,9 @@ func newBPFEndpointManager(
 		workloadIfaceRegex:      workloadIfaceRegex,
 		ipSetIDAlloc:            ipSetIDAlloc,
 		epToHostAction:          epToHostAction,
+		epToHostActionOverrides: epToHostActionOverrides,
+		linkLocalTier:           linkLocalTier(linkLocalAccess, linkLocalServices),
+		linkLocalExceptNS:       set.FromArray(linkLocalExceptNamespaces),
 		vxlanMTU:                vxlanMTU,
 		vxlanPort:               vxlanPort,
 		dsrEnabled:              dsrEnabled,
@@ -209,14 +274,24 @@ func newBPFEndpointManager(
 		stateMap:                stateMap,
 		ruleRenderer:            iptablesRuleRenderer,
 		iptablesFilterTable:     iptablesFilterTable,
-		mapCleanupRunner: ratelimited.NewRunner(jumpMapCleanupInterval, func(ctx context.Context) {
+		onStillAlive:            livenessCallback,
+		hostIfaceToEpMap:        map[string]proto.HostEndpoint{},
+		ifaceToIpMap:            map[string]net.IP{},
+		opReporter:              opReporter,
+		memAccountant:           memAccountant,
+	}
+
+	if pinOwnerID != "" {
+		m.pinOwner = tc.NewPinOwner(pinOwnerID, m.programConfigHash())
+		m.mapCleanupRunner = ratelimited.NewRunner(jumpMapCleanupInterval, func(ctx context.Context) {
+			log.Debug("BPF pin garbage collection triggered.")
+			tc.CollectPinGarbage(m.pinOwner, m.ifaceNames())
+		})
+	} else {
+		m.mapCleanupRunner = ratelimited.NewRunner(jumpMapCleanupInterval, func(ctx context.Context) {
 			log.Debug("Jump map cleanup triggered.")
 			tc.CleanUpJumpMaps()
-		}),
-		onStillAlive:     livenessCallback,
-		hostIfaceToEpMap: map[string]proto.HostEndpoint{},
-		ifaceToIpMap:     map[string]net.IP{},
-		opReporter:       opReporter,
+		})
 	}
 
 	// Normally this endpoint manager uses its own dataplane implementation, but we have an
@@ -225,6 +300,26 @@ func newBPFEndpointManager(
 	return m
 }
 
+// programConfigHash returns a hash of the configuration that we patch into our programs, for
+// labelling their pins.
+func (m *bpfEndpointManager) programConfigHash() string {
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "%s|%v|%s|%d|%d|%v|%d", m.bpfLogLevel, m.fibLookupEnabled,
+		m.epToHostAction, m.vxlanMTU, m.vxlanPort, m.dsrEnabled, m.bpfExtToServiceConnmark)
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// ifaceNames returns the names of the interfaces that we know about.
+func (m *bpfEndpointManager) ifaceNames() set.Set {
+	m.ifacesLock.Lock()
+	defer m.ifacesLock.Unlock()
+	names := set.New()
+	for name := range m.nameToIface {
+		names.Add(name)
+	}
+	return names
+}
+
 // withIface handles the bookkeeping for working with a particular bpfInterface value.  It
 // * creates the value if needed
 // * calls the giving callback with the value so it can be edited
@@ -283,7 +378,10 @@ func (m *bpfEndpointManager) OnUpdate(msg interface{}) {
 		m.onProfileRemove(msg)
 
 	case *proto.HostMetadataUpdate:
-		if msg.Hostname == m.hostname {
+		if msg.Hostname == m.hostname && msg.Ipv4Addr == "" {
+			// The BPF dataplane only uses the host's IPv4 address.
+			log.WithField("HostMetadataUpdate", msg).Debug("Host has no IPv4 address, ignoring")
+		} else if msg.Hostname == m.hostname {
 			log.WithField("HostMetadataUpdate", msg).Info("Host IP changed")
 			ip := net.ParseIP(msg.Ipv4Addr)
 			if ip != nil {
@@ -324,6 +422,7 @@ func (m *bpfEndpointManager) onInterfaceAddrsUpdate(update *ifaceAddrsUpdate) {
 			if !ok || !ip.Equal(ipAddrs[0]) {
 				m.ifaceToIpMap[update.Name] = ipAddrs[0]
 				m.dirtyIfaceNames.Add(update.Name)
+				m.recordIfaceEvent(update.Name)
 			}
 
 		}
@@ -332,6 +431,7 @@ func (m *bpfEndpointManager) onInterfaceAddrsUpdate(update *ifaceAddrsUpdate) {
 		if ok {
 			delete(m.ifaceToIpMap, update.Name)
 			m.dirtyIfaceNames.Add(update.Name)
+			m.recordIfaceEvent(update.Name)
 		}
 	}
 }
@@ -351,6 +451,17 @@ func (m *bpfEndpointManager) onInterfaceUpdate(update *ifaceUpdate) {
 
 	m.withIface(update.Name, func(iface *bpfInterface) bool {
 		iface.info.ifaceIsUp = update.State == ifacemonitor.StateUp
+		if update.Index != 0 {
+			if iface.info.ifIndex != 0 && iface.info.ifIndex != update.Index {
+				log.WithFields(log.Fields{
+					"iface":    update.Name,
+					"oldIndex": iface.info.ifIndex,
+					"newIndex": update.Index,
+				}).Info("Interface recreated with a new index, will reattach BPF programs.")
+				m.forgetAttachedPrograms(update.Name, iface)
+			}
+			iface.info.ifIndex = update.Index
+		}
 		// Note, only need to handle the mapping and unmapping of the host-* endpoint here.
 		// For specific host endpoints OnHEPUpdate doesn't depend on iface state, and has
 		// already stored and mapped as needed.
@@ -369,6 +480,64 @@ func (m *bpfEndpointManager) onInterfaceUpdate(update *ifaceUpdate) {
 		}
 		return true // Force interface to be marked dirty in case we missed a transition during a resync.
 	})
+	m.recordIfaceEvent(update.Name)
+}
+
+// forgetAttachedPrograms closes the interface's jump maps and removes the records of its policy
+// programs so that the next update attaches fresh programs.  Must be called with the ifacesLock
+// held.
+func (m *bpfEndpointManager) forgetAttachedPrograms(ifaceName string, iface *bpfInterface) {
+	if err := m.dp.removePolicyRecords(ifaceName); err != nil {
+		log.WithError(err).Warn("Failed to remove policy program records.")
+	}
+	m.memAccountant.RemovePrograms(ifaceName)
+	for i := range iface.dpState.jumpMapFDs {
+		if iface.dpState.jumpMapFDs[i] > 0 {
+			err := iface.dpState.jumpMapFDs[i].Close()
+			if err != nil {
+				log.WithError(err).Error("Failed to close jump map.")
+			}
+			iface.dpState.jumpMapFDs[i] = 0
+		}
+	}
+}
+
+// recordIfaceEvent starts (or restarts) the damping window for an interface that has just had an
+// interface event.
+func (m *bpfEndpointManager) recordIfaceEvent(ifaceName string) {
+	if m.ifaceDampingWindow == 0 {
+		return
+	}
+	m.ifaceLastEventTime[ifaceName] = m.now()
+}
+
+// updateDampedIfaces works out which interfaces are still within their damping window and when
+// the next window expires.  Interfaces whose window has passed are forgotten.
+func (m *bpfEndpointManager) updateDampedIfaces() {
+	m.dampedIfaceNames = set.New()
+	m.nextDampingExpiry = 0
+	now := m.now()
+	for ifaceName, lastEvent := range m.ifaceLastEventTime {
+		remaining := m.ifaceDampingWindow - now.Sub(lastEvent)
+		if remaining <= 0 {
+			delete(m.ifaceLastEventTime, ifaceName)
+			continue
+		}
+		log.WithFields(log.Fields{
+			"iface":     ifaceName,
+			"remaining": remaining,
+		}).Debug("Interface is within its damping window, deferring attach.")
+		m.dampedIfaceNames.Add(ifaceName)
+		if m.nextDampingExpiry == 0 || remaining < m.nextDampingExpiry {
+			m.nextDampingExpiry = remaining
+		}
+	}
+}
+
+// RescheduleAfter asks the dataplane to call CompleteDeferredWork again once the next damping
+// window has passed, so that the damped interfaces get attached.
+func (m *bpfEndpointManager) RescheduleAfter() time.Duration {
+	return m.nextDampingExpiry
 }
 
 // onWorkloadEndpointUpdate adds/updates the workload in the cache along with the index from active policy to
@@ -483,6 +652,9 @@ func (m *bpfEndpointManager) CompleteDeferredWork() error {
 	// Do one-off initialisation.
 	m.dp.ensureStarted()
 
+	m.updateDampedIfaces()
+	bpfAttachQueueDepthGauge.Set(float64(m.dirtyIfaceNames.Len()))
+
 	m.applyProgramsToDirtyDataInterfaces()
 	m.updateWEPsInDataplane()
 
@@ -503,6 +675,7 @@ func (m *bpfEndpointManager) applyProgramsToDirtyDataInterfaces() {
 	var mutex sync.Mutex
 	errs := map[string]error{}
 	var wg sync.WaitGroup
+	sem := semaphore.NewWeighted(int64(m.maxParallelAttaches))
 	m.dirtyIfaceNames.Iter(func(item interface{}) error {
 		iface := item.(string)
 		if !m.isDataIface(iface) {
@@ -510,6 +683,9 @@ func (m *bpfEndpointManager) applyProgramsToDirtyDataInterfaces() {
 				"Ignoring interface that doesn't match the host data interface regex")
 			return nil
 		}
+		if m.dampedIfaceNames.Contains(iface) {
+			return nil
+		}
 		if !m.ifaceIsUp(iface) {
 			log.WithField("iface", iface).Debug("Ignoring interface that is down")
 			return set.RemoveItem
@@ -517,9 +693,20 @@ func (m *bpfEndpointManager) applyProgramsToDirtyDataInterfaces() {
 
 		m.opReporter.RecordOperation("update-data-iface")
 
+		if err := sem.Acquire(context.Background(), 1); err != nil {
+			// Should only happen if the context finishes.
+			log.WithError(err).Panic("Failed to acquire semaphore")
+		}
+		m.onStillAlive()
+
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
+			defer sem.Release(1)
+			startTime := time.Now()
+			defer func() {
+				summaryBPFAttachTime.Observe(time.Since(startTime).Seconds())
+			}()
 
 			// Attach the qdisc first; it is shared between the directions.
 			err := m.dp.ensureQdisc(iface)
@@ -566,6 +753,9 @@ func (m *bpfEndpointManager) applyProgramsToDirtyDataInterfaces() {
 				"Ignoring interface that doesn't match the host data interface regex")
 			return nil
 		}
+		if m.dampedIfaceNames.Contains(iface) {
+			return nil
+		}
 		err := errs[iface]
 		if err == nil {
 			log.WithField("id", iface).Info("Applied program to host interface")
@@ -587,15 +777,13 @@ func (m *bpfEndpointManager) updateWEPsInDataplane() {
 	errs := map[string]error{}
 	var wg sync.WaitGroup
 
-	// Limit the number of parallel workers.  Without this, all the workers vie for CPU and complete slowly.
-	// On a constrained system, we can end up taking too long and going non-ready.
-	maxWorkers := runtime.GOMAXPROCS(0)
-	sem := semaphore.NewWeighted(int64(maxWorkers))
+	// Limit the number of parallel workers.
+	sem := semaphore.NewWeighted(int64(m.maxParallelAttaches))
 
 	m.dirtyIfaceNames.Iter(func(item interface{}) error {
 		ifaceName := item.(string)
 
-		if !m.isWorkloadIface(ifaceName) {
+		if !m.isWorkloadIface(ifaceName) || m.dampedIfaceNames.Contains(ifaceName) {
 			return nil
 		}
 
@@ -628,7 +816,7 @@ func (m *bpfEndpointManager) updateWEPsInDataplane() {
 	m.dirtyIfaceNames.Iter(func(item interface{}) error {
 		ifaceName := item.(string)
 
-		if !m.isWorkloadIface(ifaceName) {
+		if !m.isWorkloadIface(ifaceName) || m.dampedIfaceNames.Contains(ifaceName) {
 			return nil
 		}
 
@@ -681,15 +869,7 @@ func (m *bpfEndpointManager) applyPolicy(ifaceName string) error {
 		endpointID = iface.info.endpointID
 		if !ifaceUp {
 			log.WithField("iface", ifaceName).Debug("Interface is down/gone, closing jump maps.")
-			for i := range iface.dpState.jumpMapFDs {
-				if iface.dpState.jumpMapFDs[i] > 0 {
-					err := iface.dpState.jumpMapFDs[i].Close()
-					if err != nil {
-						log.WithError(err).Error("Failed to close jump map.")
-					}
-					iface.dpState.jumpMapFDs[i] = 0
-				}
-			}
+			m.forgetAttachedPrograms(ifaceName, iface)
 		}
 		return false
 	})
@@ -718,6 +898,18 @@ func (m *bpfEndpointManager) applyPolicy(ifaceName string) error {
 		return err
 	}
 
+	// The programs depend on whether the workload's device is a veth or one of the devices
+	// that some CNIs use instead.
+	linkType, err := m.dp.getIfaceLinkType(ifaceName)
+	if err != nil {
+		if isLinkNotFoundError(err) {
+			log.WithField("ifaceName", ifaceName).Debug(
+				"Ignoring request to program interface that is not present.")
+			return nil
+		}
+		return err
+	}
+
 	var ingressErr, egressErr error
 	var wg sync.WaitGroup
 	var wep *proto.WorkloadEndpoint
@@ -728,11 +920,11 @@ func (m *bpfEndpointManager) applyPolicy(ifaceName string) error {
 	wg.Add(2)
 	go func() {
 		defer wg.Done()
-		ingressErr = m.attachWorkloadProgram(ifaceName, wep, PolDirnIngress)
+		ingressErr = m.attachWorkloadProgram(ifaceName, linkType, endpointID, wep, PolDirnIngress)
 	}()
 	go func() {
 		defer wg.Done()
-		egressErr = m.attachWorkloadProgram(ifaceName, wep, PolDirnEgress)
+		egressErr = m.attachWorkloadProgram(ifaceName, linkType, endpointID, wep, PolDirnEgress)
 	}()
 	wg.Wait()
 
@@ -744,6 +936,7 @@ func (m *bpfEndpointManager) applyPolicy(ifaceName string) error {
 	}
 
 	applyTime := time.Since(startTime)
+	summaryBPFAttachTime.Observe(applyTime.Seconds())
 	log.WithField("timeTaken", applyTime).Info("Finished applying BPF programs for workload")
 	return nil
 }
@@ -760,8 +953,31 @@ func isLinkNotFoundError(err error) bool {
 
 var calicoRouterIP = net.IPv4(169, 254, 1, 1).To4()
 
-func (m *bpfEndpointManager) attachWorkloadProgram(ifaceName string, endpoint *proto.WorkloadEndpoint, polDirection PolDirection) error {
+// isPeerlessLinkType returns true for the types of workload device that have no host-side peer.
+// Workload devices are normally veths, whose host-side end acts as the workload's 169.254.1.1
+// gateway.  Some CNIs attach workloads with ipvlan or macvlan devices instead; those hang off a
+// parent device, whose MAC they share, and the workload's gateway is a real router.
+func isPeerlessLinkType(linkType string) bool {
+	switch linkType {
+	case "ipvlan", "ipvtap", "macvlan", "macvtap":
+		return true
+	}
+	return false
+}
+
+func (m *bpfEndpointManager) attachWorkloadProgram(
+	ifaceName string,
+	linkType string,
+	endpointID *proto.WorkloadEndpointID,
+	endpoint *proto.WorkloadEndpoint,
+	polDirection PolDirection,
+) error {
 	ap := m.calculateTCAttachPoint(polDirection, ifaceName)
+	epToHostAction := m.epToHostAction
+	if m.epToHostActionOverrides {
+		epToHostAction = endpointToHostAction(endpoint, m.epToHostAction)
+	}
+	ap.ToHostDrop = (epToHostAction == "DROP")
 	// Host side of the veth is always configured as 169.254.1.1.
 	ap.HostIP = calicoRouterIP
 	// * VXLAN MTU should be the host ifaces MTU -50, in order to allow space for VXLAN.
@@ -772,6 +988,18 @@ func (m *bpfEndpointManager) attachWorkloadProgram(ifaceName string, endpoint *p
 	ap.TunnelMTU = uint16(m.vxlanMTU - 50)
 	ap.IntfIP = calicoRouterIP
 	ap.ExtToServiceConnmark = uint32(m.bpfExtToServiceConnmark)
+	if isPeerlessLinkType(linkType) {
+		// With no 169.254.1.1 peer, the workload reaches the host on its real address, as
+		// traffic through a host interface does.  The FIB short-circuit is off too: it writes
+		// the MACs of the next hop as seen from a veth, whereas the kernel routes traffic from
+		// these devices via their parent.
+		ap.HostIP = m.hostIP
+		ap.IntfIP = m.hostIP
+		if ip, err := m.getInterfaceIP(ifaceName); err == nil {
+			ap.IntfIP = *ip
+		}
+		ap.FIB = false
+	}
 
 	jumpMapFD, err := m.dp.ensureProgramAttached(&ap, polDirection)
 	if err != nil {
@@ -794,14 +1022,21 @@ func (m *bpfEndpointManager) attachWorkloadProgram(ifaceName string, endpoint *p
 	// drop rule, giving us default drop behaviour in that case.
 	rules := m.extractRules(tier, profileIDs, polDirection)
 
+	// Traffic from the workload to link-local services is accepted or dropped ahead of the
+	// workload's own policy.
+	if polDirection == PolDirnEgress && endpoint != nil && m.linkLocalTier != nil &&
+		!m.linkLocalExceptNS.Contains(workloadNamespace(*endpointID)) {
+		rules.Tiers = append([]polprog.Tier{*m.linkLocalTier}, rules.Tiers...)
+	}
+
 	// If host-* endpoint is configured, add in its policy.
 	if m.wildcardExists {
 		m.addHostPolicy(&rules, &m.wildcardHostEndpoint, polDirection.Inverse())
 	}
 
-	// If workload egress and DefaultEndpointToHostAction is ACCEPT or DROP, suppress the normal
-	// host-* endpoint policy.
-	if polDirection == PolDirnEgress && m.epToHostAction != "RETURN" {
+	// If workload egress and the workload's EndpointToHostAction is ACCEPT or DROP, suppress the
+	// normal host-* endpoint policy.
+	if polDirection == PolDirnEgress && epToHostAction != "RETURN" {
 		rules.SuppressNormalHostPolicy = true
 	}
 
@@ -810,7 +1045,7 @@ func (m *bpfEndpointManager) attachWorkloadProgram(ifaceName string, endpoint *p
 		rules.SuppressNormalHostPolicy = true
 	}
 
-	return m.dp.updatePolicyProgram(jumpMapFD, rules)
+	return m.dp.updatePolicyProgram(&ap, jumpMapFD, rules)
 }
 
 func (m *bpfEndpointManager) addHostPolicy(rules *polprog.Rules, hostEndpoint *proto.HostEndpoint, polDirection PolDirection) {
@@ -868,10 +1103,10 @@ func (m *bpfEndpointManager) attachDataIfaceProgram(ifaceName string, ep *proto.
 			ForHostInterface: true,
 		}
 		m.addHostPolicy(&rules, ep, polDirection)
-		return m.dp.updatePolicyProgram(jumpMapFD, rules)
+		return m.dp.updatePolicyProgram(&ap, jumpMapFD, rules)
 	}
 
-	return m.dp.removePolicyProgram(jumpMapFD)
+	return m.dp.removePolicyProgram(&ap, jumpMapFD)
 }
 
 // PolDirection is the Calico datamodel direction of policy.  On a host endpoint, ingress is towards the host.
@@ -938,6 +1173,7 @@ func (m *bpfEndpointManager) calculateTCAttachPoint(policyDirection PolDirection
 	ap.DSR = m.dsrEnabled
 	ap.LogLevel = m.bpfLogLevel
 	ap.VXLANPort = m.vxlanPort
+	ap.PinOwner = m.pinOwner
 
 	return ap
 }
@@ -970,8 +1206,12 @@ func (m *bpfEndpointManager) extractTiers(tier *proto.TierInfo, direction PolDir
 				prules = pol.OutboundRules
 			}
 			policy := polprog.Policy{
-				Name:  polName,
-				Rules: make([]polprog.Rule, len(prules)),
+				Name:          polName,
+				Rules:         make([]polprog.Rule, len(prules)),
+				DefaultAction: pol.DefaultAction,
+			}
+			if pol.LogDefaultAction {
+				log.WithField("policy", polName).Debug("Ignoring default action logging, not supported in BPF mode.")
 			}
 
 			for ri, r := range prules {
@@ -1023,6 +1263,34 @@ func (m *bpfEndpointManager) extractProfiles(profileNames []string, direction Po
 	return
 }
 
+// linkLocalTier returns the tier that applies the given WorkloadLinkLocalAccess to the given
+// link-local services, or nil if there's nothing to apply.  Other traffic passes the tier.
+func linkLocalTier(access string, services []string) *polprog.Tier {
+	var action string
+	switch access {
+	case "Allow":
+		action = "allow"
+	case "Deny":
+		action = "deny"
+	default:
+		return nil
+	}
+	if len(services) == 0 {
+		return nil
+	}
+	return &polprog.Tier{
+		Name:      "link-local",
+		EndAction: polprog.TierEndPass,
+		Policies: []polprog.Policy{{
+			Name: "link-local-services",
+			Rules: []polprog.Rule{{Rule: &proto.Rule{
+				Action: action,
+				DstNet: services,
+			}}},
+		}},
+	}
+}
+
 func (m *bpfEndpointManager) extractRules(tier *proto.TierInfo, profileNames []string, direction PolDirection) polprog.Rules {
 	var r polprog.Rules
 
@@ -1257,6 +1525,14 @@ func (m *bpfEndpointManager) ensureStarted() {
 	})
 }
 
+func (m *bpfEndpointManager) getIfaceLinkType(iface string) (string, error) {
+	link, err := netlink.LinkByName(iface)
+	if err != nil {
+		return "", err
+	}
+	return link.Type(), nil
+}
+
 func (m *bpfEndpointManager) ensureQdisc(iface string) error {
 	return tc.EnsureQdisc(iface)
 }
@@ -1287,6 +1563,11 @@ func (m *bpfEndpointManager) ensureProgramAttached(ap *tc.AttachPoint, polDirect
 		if err != nil {
 			return 0, err
 		}
+		if size, err := ap.ProgramSize(); err != nil {
+			log.WithError(err).WithField("iface", ap.Iface).Debug("Failed to read program size.")
+		} else {
+			m.memAccountant.SetProgram(bpf.MemoryFeatureEndpointPrograms, ap.Iface, string(ap.Hook), size)
+		}
 
 		jumpMapFD, err = FindJumpMap(ap)
 		if err != nil {
@@ -1318,7 +1599,7 @@ func (m *bpfEndpointManager) setJumpMapFD(name string, direction PolDirection, f
 	})
 }
 
-func (m *bpfEndpointManager) updatePolicyProgram(jumpMapFD bpf.MapFD, rules polprog.Rules) error {
+func (m *bpfEndpointManager) updatePolicyProgram(ap *tc.AttachPoint, jumpMapFD bpf.MapFD, rules polprog.Rules) error {
 	pg := polprog.NewBuilder(m.ipSetIDAlloc, m.ipSetMap.MapFD(), m.stateMap.MapFD(), jumpMapFD)
 	insns, err := pg.Instructions(rules)
 	if err != nil {
@@ -1342,18 +1623,45 @@ func (m *bpfEndpointManager) updatePolicyProgram(jumpMapFD bpf.MapFD, rules polp
 	if err != nil {
 		return fmt.Errorf("failed to update jump map: %w", err)
 	}
+	hash, _ := polprog.EmbeddedPolicyHash(insns)
+	recordPolicyProgram(ap, jumpMapFD, hash)
+	m.memAccountant.SetProgram(bpf.MemoryFeaturePolicyPrograms, ap.Iface, string(ap.Hook),
+		int64(len(insns)*len(asm.Insn{})))
 	return nil
 }
 
-func (m *bpfEndpointManager) removePolicyProgram(jumpMapFD bpf.MapFD) error {
+func (m *bpfEndpointManager) removePolicyProgram(ap *tc.AttachPoint, jumpMapFD bpf.MapFD) error {
 	k := make([]byte, 4)
 	err := bpf.DeleteMapEntryIfExists(jumpMapFD, k, 4)
 	if err != nil {
 		return fmt.Errorf("failed to update jump map: %w", err)
 	}
+	recordPolicyProgram(ap, jumpMapFD, "")
+	m.memAccountant.RemoveProgram(bpf.MemoryFeaturePolicyPrograms, ap.Iface, string(ap.Hook))
 	return nil
 }
 
+func (m *bpfEndpointManager) removePolicyRecords(iface string) error {
+	return polprog.RemoveAttachmentRecords(polprog.AttachmentRecordDir, iface)
+}
+
+// recordPolicyProgram records the policy program that we put in the jump map, for
+// "calico-bpf policy verify".  The record is only a debugging aid so we don't fail if we can't
+// write it.
+func recordPolicyProgram(ap *tc.AttachPoint, jumpMapFD bpf.MapFD, hash string) {
+	logCtx := log.WithFields(log.Fields{"iface": ap.Iface, "hook": ap.Hook})
+	info, err := bpf.GetMapInfo(jumpMapFD)
+	if err != nil {
+		logCtx.WithError(err).Warn("Failed to look up jump map ID, not recording policy program.")
+		return
+	}
+	err = polprog.WriteAttachmentRecord(polprog.AttachmentRecordDir, ap.Iface, string(ap.Hook),
+		polprog.AttachmentRecord{JumpMapID: info.ID, PolicyHash: hash})
+	if err != nil {
+		logCtx.WithError(err).Warn("Failed to record policy program.")
+	}
+}
+
 func FindJumpMap(ap *tc.AttachPoint) (mapFD bpf.MapFD, err error) {
 	logCtx := log.WithField("iface", ap.Iface)
 	logCtx.Debug("Looking up jump map.")
@@ -19,11 +19,14 @@ package intdataplane
 import (
 	"regexp"
 	"sync"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/projectcalico/libcalico-go/lib/set"
+
 	"github.com/projectcalico/felix/logutils"
 
 	"github.com/projectcalico/felix/bpf"
@@ -39,17 +42,21 @@ import (
 )
 
 type mockDataplane struct {
-	mutex  sync.Mutex
-	lastFD uint32
-	fds    map[string]uint32
-	state  map[uint32]polprog.Rules
+	mutex     sync.Mutex
+	lastFD    uint32
+	fds       map[string]uint32
+	state     map[uint32]polprog.Rules
+	aps       map[string]tc.AttachPoint
+	linkTypes map[string]string
 }
 
 func newMockDataplane() *mockDataplane {
 	return &mockDataplane{
-		lastFD: 5,
-		fds:    map[string]uint32{},
-		state:  map[uint32]polprog.Rules{},
+		lastFD:    5,
+		fds:       map[string]uint32{},
+		state:     map[uint32]polprog.Rules{},
+		aps:       map[string]tc.AttachPoint{},
+		linkTypes: map[string]string{},
 	}
 }
 
@@ -61,6 +68,7 @@ func (m *mockDataplane) ensureProgramAttached(ap *tc.AttachPoint, polDirection P
 	defer m.mutex.Unlock()
 	suffixes := []string{"-I", "-E"}
 	key := ap.Iface + suffixes[int(polDirection)]
+	m.aps[key] = *ap
 	if fd, exists := m.fds[key]; exists {
 		return bpf.MapFD(fd), nil
 	}
@@ -73,24 +81,49 @@ func (m *mockDataplane) ensureQdisc(iface string) error {
 	return nil
 }
 
-func (m *mockDataplane) updatePolicyProgram(jumpMapFD bpf.MapFD, rules polprog.Rules) error {
+func (m *mockDataplane) updatePolicyProgram(ap *tc.AttachPoint, jumpMapFD bpf.MapFD, rules polprog.Rules) error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 	m.state[uint32(jumpMapFD)] = rules
 	return nil
 }
 
-func (m *mockDataplane) removePolicyProgram(jumpMapFD bpf.MapFD) error {
+func (m *mockDataplane) removePolicyProgram(ap *tc.AttachPoint, jumpMapFD bpf.MapFD) error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 	delete(m.state, uint32(jumpMapFD))
 	return nil
 }
 
+func (m *mockDataplane) removePolicyRecords(iface string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	// The manager closes the interface's jump maps along with the records so forget the FDs
+	// too; the next ensureProgramAttached() then "attaches" a new program.
+	delete(m.fds, iface+"-I")
+	delete(m.fds, iface+"-E")
+	return nil
+}
+
 func (m *mockDataplane) setAcceptLocal(iface string, val bool) error {
 	return nil
 }
 
+func (m *mockDataplane) getIfaceLinkType(iface string) (string, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if linkType, ok := m.linkTypes[iface]; ok {
+		return linkType, nil
+	}
+	return "veth", nil
+}
+
+func (m *mockDataplane) getAttachPoint(key string) tc.AttachPoint {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.aps[key]
+}
+
 func (m *mockDataplane) getRules(key string) *polprog.Rules {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
@@ -118,6 +151,7 @@ var _ = Describe("BPF Endpoint Manager", func() {
 		dp                   *mockDataplane
 		fibLookupEnabled     bool
 		endpointToHostAction string
+		linkLocalAccess      string
 		dataIfacePattern     string
 		workloadIfaceRegex   string
 		ipSetIDAllocator     *idalloc.IDAllocator
@@ -134,6 +168,7 @@ var _ = Describe("BPF Endpoint Manager", func() {
 	BeforeEach(func() {
 		fibLookupEnabled = true
 		endpointToHostAction = "DROP"
+		linkLocalAccess = "DoNothing"
 		dataIfacePattern = "^((en|wl|ww|sl|ib)[opsx].*|(eth|wlan|wwan).*|tunl0$|wireguard.cali$)"
 		workloadIfaceRegex = "cali"
 		ipSetIDAllocator = idalloc.New()
@@ -171,6 +206,10 @@ var _ = Describe("BPF Endpoint Manager", func() {
 			"uthost",
 			fibLookupEnabled,
 			endpointToHostAction,
+			false,
+			linkLocalAccess,
+			[]string{"169.254.169.254/32"},
+			[]string{"kube-system"},
 			regexp.MustCompile(dataIfacePattern),
 			regexp.MustCompile(workloadIfaceRegex),
 			ipSetIDAllocator,
@@ -178,12 +217,16 @@ var _ = Describe("BPF Endpoint Manager", func() {
 			uint16(rrConfigNormal.VXLANPort),
 			nodePortDSR,
 			0,
+			0, // No interface damping.
+			0,
+			"",
 			ipSetsMap,
 			stateMap,
 			ruleRenderer,
 			filterTableV4,
 			nil,
 			logutils.NewSummarizer("test"),
+			nil,
 		)
 		bpfEpMgr.dp = dp
 	})
@@ -261,6 +304,184 @@ var _ = Describe("BPF Endpoint Manager", func() {
 		},
 	}
 
+	Context("with interface damping", func() {
+		var now time.Time
+
+		JustBeforeEach(func() {
+			now = time.Unix(1000, 0)
+			bpfEpMgr.now = func() time.Time { return now }
+			bpfEpMgr.ifaceDampingWindow = 100 * time.Millisecond
+		})
+
+		isAttached := func(key string) bool {
+			dp.mutex.Lock()
+			defer dp.mutex.Unlock()
+			_, ok := dp.fds[key]
+			return ok
+		}
+
+		It("attaches to a flapping data interface once the events stop", func() {
+			genIfaceUpdate("eth0", ifacemonitor.StateUp, 10)()
+			Expect(isAttached("eth0-I")).To(BeFalse())
+			Expect(bpfEpMgr.RescheduleAfter()).To(Equal(100 * time.Millisecond))
+
+			now = now.Add(60 * time.Millisecond)
+			genIfaceUpdate("eth0", ifacemonitor.StateDown, 10)()
+			genIfaceUpdate("eth0", ifacemonitor.StateUp, 10)()
+			Expect(isAttached("eth0-I")).To(BeFalse())
+			Expect(bpfEpMgr.RescheduleAfter()).To(Equal(100 * time.Millisecond))
+
+			now = now.Add(60 * time.Millisecond)
+			Expect(bpfEpMgr.CompleteDeferredWork()).To(Succeed())
+			Expect(isAttached("eth0-I")).To(BeFalse())
+			Expect(bpfEpMgr.RescheduleAfter()).To(Equal(40 * time.Millisecond))
+
+			now = now.Add(40 * time.Millisecond)
+			Expect(bpfEpMgr.CompleteDeferredWork()).To(Succeed())
+			Expect(isAttached("eth0-I")).To(BeTrue())
+			Expect(isAttached("eth0-E")).To(BeTrue())
+			Expect(bpfEpMgr.RescheduleAfter()).To(BeZero())
+			Expect(bpfEpMgr.dirtyIfaceNames.Len()).To(BeZero())
+		})
+
+		It("attaches to a new workload interface once the window passes", func() {
+			genWLUpdate("cali12345")()
+			genIfaceUpdate("cali12345", ifacemonitor.StateUp, 15)()
+			Expect(isAttached("cali12345-I")).To(BeFalse())
+
+			now = now.Add(100 * time.Millisecond)
+			Expect(bpfEpMgr.CompleteDeferredWork()).To(Succeed())
+			Expect(isAttached("cali12345-I")).To(BeTrue())
+			Expect(isAttached("cali12345-E")).To(BeTrue())
+			Expect(bpfEpMgr.happyWEPs).To(HaveLen(1))
+		})
+
+		It("doesn't damp updates that aren't interface events", func() {
+			genPolicy("default", "mypolicy")()
+			genIfaceUpdate("eth0", ifacemonitor.StateUp, 10)()
+			now = now.Add(100 * time.Millisecond)
+			Expect(bpfEpMgr.CompleteDeferredWork()).To(Succeed())
+			Expect(isAttached("eth0-I")).To(BeTrue())
+
+			genHEPUpdate("eth0", hostEpNorm)()
+			Expect(dp.getRules("eth0-I")).NotTo(BeNil())
+			Expect(bpfEpMgr.RescheduleAfter()).To(BeZero())
+		})
+	})
+
+	Context("with eth0 and a workload interface up", func() {
+		var origFDs map[string]uint32
+
+		getFDs := func() map[string]uint32 {
+			dp.mutex.Lock()
+			defer dp.mutex.Unlock()
+			fds := map[string]uint32{}
+			for k, v := range dp.fds {
+				fds[k] = v
+			}
+			return fds
+		}
+
+		JustBeforeEach(func() {
+			genWLUpdate("cali12345")()
+			genIfaceUpdate("eth0", ifacemonitor.StateUp, 10)()
+			genIfaceUpdate("cali12345", ifacemonitor.StateUp, 15)()
+			origFDs = getFDs()
+			Expect(origFDs).To(HaveKey("eth0-I"))
+			Expect(origFDs).To(HaveKey("cali12345-E"))
+		})
+
+		It("keeps the programs when the interface flaps", func() {
+			genIfaceUpdate("eth0", ifacemonitor.StateUp, 10)()
+			genIfaceUpdate("cali12345", ifacemonitor.StateUp, 15)()
+			Expect(getFDs()).To(Equal(origFDs))
+		})
+
+		It("reattaches to a data interface that is recreated with a new index", func() {
+			genIfaceUpdate("eth0", ifacemonitor.StateUp, 11)()
+			fds := getFDs()
+			Expect(fds["eth0-I"]).NotTo(Equal(origFDs["eth0-I"]))
+			Expect(fds["eth0-E"]).NotTo(Equal(origFDs["eth0-E"]))
+			Expect(fds["cali12345-I"]).To(Equal(origFDs["cali12345-I"]))
+			Expect(bpfEpMgr.nameToIface["eth0"].info.ifIndex).To(Equal(11))
+		})
+
+		It("reattaches to a workload interface that is recreated with a new index", func() {
+			genIfaceUpdate("cali12345", ifacemonitor.StateUp, 16)()
+			fds := getFDs()
+			Expect(fds["cali12345-I"]).NotTo(Equal(origFDs["cali12345-I"]))
+			Expect(fds["cali12345-E"]).NotTo(Equal(origFDs["cali12345-E"]))
+			Expect(fds["eth0-I"]).To(Equal(origFDs["eth0-I"]))
+			Expect(bpfEpMgr.happyWEPs).To(HaveLen(1))
+		})
+	})
+
+	Context("with link-local access denied", func() {
+		BeforeEach(func() {
+			linkLocalAccess = "Deny"
+		})
+
+		It("denies link-local services ahead of the policy of workloads outside the exception namespaces", func() {
+			genWLUpdate("cali12345")()
+			genIfaceUpdate("cali12345", ifacemonitor.StateUp, 15)()
+			bpfEpMgr.OnUpdate(&proto.WorkloadEndpointUpdate{
+				Id: &proto.WorkloadEndpointID{
+					OrchestratorId: "k8s",
+					WorkloadId:     "kube-system/coredns",
+					EndpointId:     "eth0",
+				},
+				Endpoint: &proto.WorkloadEndpoint{Name: "cali67890"},
+			})
+			genIfaceUpdate("cali67890", ifacemonitor.StateUp, 16)()
+
+			var caliI, caliE, exceptE *polprog.Rules
+			Eventually(dp.setAndReturn(&caliE, "cali12345-E")).ShouldNot(BeNil())
+			Expect(caliE.Tiers).To(Equal([]polprog.Tier{{
+				Name:      "link-local",
+				EndAction: polprog.TierEndPass,
+				Policies: []polprog.Policy{{
+					Name: "link-local-services",
+					Rules: []polprog.Rule{{Rule: &proto.Rule{
+						Action: "deny",
+						DstNet: []string{"169.254.169.254/32"},
+					}}},
+				}},
+			}}))
+			Eventually(dp.setAndReturn(&caliI, "cali12345-I")).ShouldNot(BeNil())
+			Expect(caliI.Tiers).To(BeEmpty())
+			Eventually(dp.setAndReturn(&exceptE, "cali67890-E")).ShouldNot(BeNil())
+			Expect(exceptE.Tiers).To(BeEmpty())
+		})
+	})
+
+	Context("with an ipvlan workload interface", func() {
+		JustBeforeEach(func() {
+			dp.linkTypes["cali67890"] = "ipvlan"
+			bpfEpMgr.OnUpdate(&proto.HostMetadataUpdate{Hostname: "uthost", Ipv4Addr: "10.0.0.1"})
+			bpfEpMgr.OnUpdate(&ifaceAddrsUpdate{Name: "cali67890", Addrs: set.From("10.0.0.2")})
+			genWLUpdate("cali12345")()
+			genIfaceUpdate("cali12345", ifacemonitor.StateUp, 15)()
+			genWLUpdate("cali67890")()
+			genIfaceUpdate("cali67890", ifacemonitor.StateUp, 16)()
+		})
+
+		It("uses the host's addresses and skips the FIB for the ipvlan device", func() {
+			ap := dp.getAttachPoint("cali67890-E")
+			Expect(ap.HostIP.String()).To(Equal("10.0.0.1"))
+			Expect(ap.IntfIP.String()).To(Equal("10.0.0.2"))
+			Expect(ap.FIB).To(BeFalse())
+			Expect(dp.getAttachPoint("cali67890-I").HostIP.String()).To(Equal("10.0.0.1"))
+			Expect(bpfEpMgr.happyWEPs).To(HaveLen(2))
+		})
+
+		It("keeps the veth's gateway address and FIB lookup", func() {
+			ap := dp.getAttachPoint("cali12345-E")
+			Expect(ap.HostIP.String()).To(Equal("169.254.1.1"))
+			Expect(ap.IntfIP.String()).To(Equal("169.254.1.1"))
+			Expect(ap.FIB).To(BeTrue())
+		})
+	})
+
 	It("does not have HEP in initial state", func() {
 		Expect(bpfEpMgr.hostIfaceToEpMap["eth0"]).NotTo(Equal(hostEp))
 	})
@@ -0,0 +1,287 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+
+	"github.com/projectcalico/felix/ifacemonitor"
+	"github.com/projectcalico/felix/ip"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/routerule"
+	"github.com/projectcalico/felix/routetable"
+)
+
+const (
+	// bpfHostNATIfaceIn is the end of the host NAT veth pair that host-originated service traffic is
+	// routed into; it re-appears on bpfHostNATIfaceOut, where the from-HEP program NATs it.
+	bpfHostNATIfaceIn  = "bpfnatin"
+	bpfHostNATIfaceOut = "bpfnatout"
+
+	bpfHostNATRulePriority = 100
+)
+
+// bpfHostNATGateway is the (otherwise unused) next hop for the host NAT routing table.  We pin its
+// ARP entry on bpfHostNATIfaceIn to the MAC of bpfHostNATIfaceOut.
+var bpfHostNATGateway = ip.FromString("169.254.1.1")
+
+// bpfHostNATDataplane is a shim interface for mocking netlink in the BPF host NAT manager.
+type bpfHostNATDataplane interface {
+	LinkByName(name string) (netlink.Link, error)
+	LinkAdd(link netlink.Link) error
+	LinkDel(link netlink.Link) error
+	LinkSetUp(link netlink.Link) error
+}
+
+type realBPFHostNATNetlink struct{}
+
+func (r realBPFHostNATNetlink) LinkByName(name string) (netlink.Link, error) {
+	return netlink.LinkByName(name)
+}
+
+func (r realBPFHostNATNetlink) LinkAdd(link netlink.Link) error {
+	return netlink.LinkAdd(link)
+}
+
+func (r realBPFHostNATNetlink) LinkDel(link netlink.Link) error {
+	return netlink.LinkDel(link)
+}
+
+func (r realBPFHostNATNetlink) LinkSetUp(link netlink.Link) error {
+	return netlink.LinkSetUp(link)
+}
+
+// routeRules is the interface provided by the routerule module.
+type routeRules interface {
+	SetRule(rule *routerule.Rule)
+	RemoveRule(rule *routerule.Rule)
+	QueueResync()
+	Apply() error
+}
+
+// The BPF host NAT manager gives host-originated traffic (including traffic from hostNetwork pods)
+// a path to service cluster IPs when the connect-time load balancer is disabled.  Without CTLB,
+// such traffic leaves the host stack still addressed to the cluster IP and never passes through a
+// BPF program that could NAT it.
+//
+// To fix that, the manager maintains a veth pair and a dedicated routing table whose default route
+// sends traffic into one end of the pair.  The other end is treated as a BPF data interface, so the
+// packet re-enters the host via a from-HEP program, which does the service NAT, just as it would
+// for traffic arriving from outside the node.  Routing rules send locally-originated traffic for
+// the service cluster CIDRs (taken from the BGP configuration) to the table.
+//
+// When CTLB is enabled the manager removes the veth pair and routing rules.
+type bpfHostNATManager struct {
+	enabled    bool
+	tableIndex int
+
+	// Our dependencies.
+	dataplane    bpfHostNATDataplane
+	routeTable   routeTable
+	routeRules   routeRules
+	writeProcSys procSysWriter
+
+	// Internal state.
+	linkDirty        bool
+	rulesDirty       bool
+	serviceCIDRs     []ip.CIDR
+	activeRuleCIDRs  []ip.CIDR
+	pendingBGPConfig *proto.GlobalBGPConfigUpdate
+}
+
+func newBPFHostNATManager(
+	enabled bool,
+	tableIndex int,
+	routeTable routeTable,
+	routeRules routeRules,
+) *bpfHostNATManager {
+	return newBPFHostNATManagerWithShims(enabled, tableIndex, routeTable, routeRules,
+		realBPFHostNATNetlink{}, writeProcSys)
+}
+
+func newBPFHostNATManagerWithShims(
+	enabled bool,
+	tableIndex int,
+	routeTable routeTable,
+	routeRules routeRules,
+	dataplane bpfHostNATDataplane,
+	procSysWriter procSysWriter,
+) *bpfHostNATManager {
+	return &bpfHostNATManager{
+		enabled:      enabled,
+		tableIndex:   tableIndex,
+		dataplane:    dataplane,
+		routeTable:   routeTable,
+		routeRules:   routeRules,
+		writeProcSys: procSysWriter,
+		linkDirty:    true,
+		rulesDirty:   true,
+	}
+}
+
+func (m *bpfHostNATManager) OnUpdate(protoBufMsg interface{}) {
+	switch msg := protoBufMsg.(type) {
+	case *proto.GlobalBGPConfigUpdate:
+		m.pendingBGPConfig = msg
+	case *ifaceUpdate:
+		if msg.Name == bpfHostNATIfaceIn || msg.Name == bpfHostNATIfaceOut {
+			if msg.State != ifacemonitor.StateUp {
+				log.WithField("iface", msg.Name).Info("BPF host NAT interface went down, will re-check.")
+				m.linkDirty = true
+			}
+		}
+	}
+}
+
+func (m *bpfHostNATManager) GetRouteTableSyncers() []routeTableSyncer {
+	return []routeTableSyncer{m.routeTable}
+}
+
+func (m *bpfHostNATManager) CompleteDeferredWork() error {
+	if m.pendingBGPConfig != nil {
+		var cidrs []ip.CIDR
+		for _, s := range m.pendingBGPConfig.GetServiceClusterCidrs() {
+			cidr, err := ip.ParseCIDROrIP(s)
+			if err != nil {
+				log.WithError(err).WithField("cidr", s).Warn("Ignoring unparsable service cluster CIDR.")
+				continue
+			}
+			if cidr.Version() != 4 {
+				continue
+			}
+			cidrs = append(cidrs, cidr)
+		}
+		sort.Slice(cidrs, func(i, j int) bool {
+			return cidrs[i].String() < cidrs[j].String()
+		})
+		if !reflect.DeepEqual(cidrs, m.serviceCIDRs) {
+			log.WithField("cidrs", cidrs).Info("Service cluster CIDRs changed, updating BPF host NAT rules.")
+			m.serviceCIDRs = cidrs
+			m.rulesDirty = true
+		}
+		m.pendingBGPConfig = nil
+	}
+
+	if m.linkDirty {
+		var err error
+		if m.enabled {
+			err = m.ensureLink()
+		} else {
+			err = m.ensureNoLink()
+		}
+		if err != nil {
+			return err
+		}
+		m.linkDirty = false
+	}
+
+	if m.rulesDirty {
+		var wanted []ip.CIDR
+		if m.enabled {
+			wanted = m.serviceCIDRs
+		}
+		for _, cidr := range m.activeRuleCIDRs {
+			m.routeRules.RemoveRule(m.ruleForCIDR(cidr))
+		}
+		for _, cidr := range wanted {
+			m.routeRules.SetRule(m.ruleForCIDR(cidr))
+		}
+		m.activeRuleCIDRs = wanted
+		m.rulesDirty = false
+	}
+
+	return m.routeRules.Apply()
+}
+
+func (m *bpfHostNATManager) ruleForCIDR(cidr ip.CIDR) *routerule.Rule {
+	// Only match locally-originated traffic.  Traffic that arrives from elsewhere has already been
+	// through a BPF program, and matching traffic that comes back out of the veth pair would loop.
+	return routerule.NewRule(4, bpfHostNATRulePriority).
+		MatchDstAddress(cidr.ToIPNet()).
+		MatchIncomingInterface("lo").
+		GoToTable(m.tableIndex)
+}
+
+func (m *bpfHostNATManager) ensureLink() error {
+	inLink, err := m.dataplane.LinkByName(bpfHostNATIfaceIn)
+	if _, ok := err.(netlink.LinkNotFoundError); ok {
+		log.Info("BPF host NAT veth pair missing, creating it.")
+		veth := &netlink.Veth{
+			LinkAttrs: netlink.LinkAttrs{Name: bpfHostNATIfaceIn},
+			PeerName:  bpfHostNATIfaceOut,
+		}
+		if err := m.dataplane.LinkAdd(veth); err != nil {
+			return fmt.Errorf("failed to create BPF host NAT veth pair: %w", err)
+		}
+		inLink, err = m.dataplane.LinkByName(bpfHostNATIfaceIn)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up %s: %w", bpfHostNATIfaceIn, err)
+	}
+	outLink, err := m.dataplane.LinkByName(bpfHostNATIfaceOut)
+	if err != nil {
+		return fmt.Errorf("failed to look up %s: %w", bpfHostNATIfaceOut, err)
+	}
+
+	for _, link := range []netlink.Link{inLink, outLink} {
+		if link.Attrs().Flags&net.FlagUp == 0 {
+			if err := m.dataplane.LinkSetUp(link); err != nil {
+				return fmt.Errorf("failed to set %s up: %w", link.Attrs().Name, err)
+			}
+		}
+	}
+
+	// The packets that re-enter the host on bpfnatout have one of our own IPs as their source.
+	// The BPF endpoint manager sets accept_local on data interfaces; we also need to disable
+	// RPF, which would otherwise drop them.
+	if err := m.writeProcSys(fmt.Sprintf("/proc/sys/net/ipv4/conf/%s/rp_filter", bpfHostNATIfaceOut), "0"); err != nil {
+		return err
+	}
+
+	m.routeTable.SetRoutes(bpfHostNATIfaceIn, []routetable.Target{
+		{
+			Type:    routetable.TargetTypeNoEncap,
+			CIDR:    bpfHostNATGateway.AsCIDR(),
+			DestMAC: outLink.Attrs().HardwareAddr,
+		},
+		{
+			Type: routetable.TargetTypeOnLink,
+			CIDR: ip.MustParseCIDROrIP("0.0.0.0/0"),
+			GW:   bpfHostNATGateway,
+		},
+	})
+	return nil
+}
+
+func (m *bpfHostNATManager) ensureNoLink() error {
+	link, err := m.dataplane.LinkByName(bpfHostNATIfaceIn)
+	if _, ok := err.(netlink.LinkNotFoundError); ok {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to look up %s: %w", bpfHostNATIfaceIn, err)
+	}
+	log.Info("BPF host NAT not needed, removing veth pair.")
+	// Deleting one end of the pair removes both, along with the routes via them.
+	if err := m.dataplane.LinkDel(link); err != nil {
+		return fmt.Errorf("failed to delete BPF host NAT veth pair: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"errors"
+	"net"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vishvananda/netlink"
+
+	"github.com/projectcalico/felix/ifacemonitor"
+	"github.com/projectcalico/felix/ip"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/routerule"
+	"github.com/projectcalico/felix/routetable"
+)
+
+var _ = Describe("BPF host NAT manager", func() {
+	var (
+		mgr      *bpfHostNATManager
+		dp       *mockBPFHostNATDataplane
+		rt       *mockRouteTable
+		rules    *mockRouteRules
+		sysctls  map[string]string
+		outMAC   net.HardwareAddr
+		newMgrFn func(enabled bool) *bpfHostNATManager
+	)
+
+	BeforeEach(func() {
+		dp = &mockBPFHostNATDataplane{links: map[string]*netlink.Veth{}}
+		rt = &mockRouteTable{
+			currentRoutes:   map[string][]routetable.Target{},
+			currentL2Routes: map[string][]routetable.L2Target{},
+		}
+		rules = &mockRouteRules{}
+		sysctls = map[string]string{}
+		outMAC, _ = net.ParseMAC("ee:ee:ee:ee:ee:ee")
+		dp.macs = map[string]net.HardwareAddr{bpfHostNATIfaceOut: outMAC}
+		newMgrFn = func(enabled bool) *bpfHostNATManager {
+			return newBPFHostNATManagerWithShims(enabled, 10, rt, rules, dp,
+				func(path, value string) error {
+					sysctls[path] = value
+					return nil
+				})
+		}
+	})
+
+	ruleFor := func(cidr string) *routerule.Rule {
+		return routerule.NewRule(4, bpfHostNATRulePriority).
+			MatchDstAddress(ip.MustParseCIDROrIP(cidr).ToIPNet()).
+			MatchIncomingInterface("lo").
+			GoToTable(10)
+	}
+
+	Describe("with CTLB disabled", func() {
+		BeforeEach(func() {
+			mgr = newMgrFn(true)
+			Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		})
+
+		It("should create the veth pair and bring it up", func() {
+			Expect(dp.links).To(HaveKey(bpfHostNATIfaceIn))
+			Expect(dp.links[bpfHostNATIfaceIn].PeerName).To(Equal(bpfHostNATIfaceOut))
+			Expect(dp.up).To(ConsistOf(bpfHostNATIfaceIn, bpfHostNATIfaceOut))
+			Expect(sysctls).To(HaveKeyWithValue("/proc/sys/net/ipv4/conf/bpfnatout/rp_filter", "0"))
+		})
+
+		It("should route via the veth pair", func() {
+			rt.checkRoutes(bpfHostNATIfaceIn, []routetable.Target{
+				{
+					Type:    routetable.TargetTypeNoEncap,
+					CIDR:    ip.MustParseCIDROrIP("169.254.1.1/32"),
+					DestMAC: outMAC,
+				},
+				{
+					Type: routetable.TargetTypeOnLink,
+					CIDR: ip.MustParseCIDROrIP("0.0.0.0/0"),
+					GW:   ip.FromString("169.254.1.1"),
+				},
+			})
+		})
+
+		It("should recreate the veth pair if it is removed", func() {
+			dp.links = map[string]*netlink.Veth{}
+			dp.up = nil
+			mgr.OnUpdate(&ifaceUpdate{Name: bpfHostNATIfaceIn, State: ifacemonitor.StateDown})
+			Expect(mgr.CompleteDeferredWork()).To(Succeed())
+			Expect(dp.links).To(HaveKey(bpfHostNATIfaceIn))
+		})
+
+		It("should retry if creating the veth fails", func() {
+			dp.links = map[string]*netlink.Veth{}
+			dp.addErr = errors.New("dummy error")
+			mgr.OnUpdate(&ifaceUpdate{Name: bpfHostNATIfaceOut, State: ifacemonitor.StateDown})
+			Expect(mgr.CompleteDeferredWork()).NotTo(Succeed())
+			dp.addErr = nil
+			Expect(mgr.CompleteDeferredWork()).To(Succeed())
+			Expect(dp.links).To(HaveKey(bpfHostNATIfaceIn))
+		})
+
+		It("should add and remove rules for the IPv4 service cluster CIDRs", func() {
+			mgr.OnUpdate(&proto.GlobalBGPConfigUpdate{
+				ServiceClusterCidrs: []string{"10.96.0.0/12", "fd00:96::/112", "10.101.0.0/16"},
+			})
+			Expect(mgr.CompleteDeferredWork()).To(Succeed())
+			Expect(rules.rules).To(ConsistOf(ruleFor("10.96.0.0/12"), ruleFor("10.101.0.0/16")))
+
+			mgr.OnUpdate(&proto.GlobalBGPConfigUpdate{
+				ServiceClusterCidrs: []string{"10.96.0.0/12"},
+			})
+			Expect(mgr.CompleteDeferredWork()).To(Succeed())
+			Expect(rules.rules).To(ConsistOf(ruleFor("10.96.0.0/12")))
+		})
+	})
+
+	Describe("with CTLB enabled", func() {
+		BeforeEach(func() {
+			dp.links[bpfHostNATIfaceIn] = &netlink.Veth{
+				LinkAttrs: netlink.LinkAttrs{Name: bpfHostNATIfaceIn},
+				PeerName:  bpfHostNATIfaceOut,
+			}
+			mgr = newMgrFn(false)
+			mgr.OnUpdate(&proto.GlobalBGPConfigUpdate{
+				ServiceClusterCidrs: []string{"10.96.0.0/12"},
+			})
+			Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		})
+
+		It("should remove the veth pair", func() {
+			Expect(dp.links).To(BeEmpty())
+		})
+
+		It("should not add any rules or routes", func() {
+			Expect(rules.rules).To(BeEmpty())
+			Expect(rules.applied).To(BeTrue())
+			Expect(rt.currentRoutes).To(BeEmpty())
+		})
+	})
+})
+
+type mockBPFHostNATDataplane struct {
+	links  map[string]*netlink.Veth
+	macs   map[string]net.HardwareAddr
+	up     []string
+	addErr error
+}
+
+func (d *mockBPFHostNATDataplane) LinkByName(name string) (netlink.Link, error) {
+	for _, l := range d.links {
+		if l.Name == name || l.PeerName == name {
+			return &netlink.Veth{LinkAttrs: netlink.LinkAttrs{
+				Name:         name,
+				HardwareAddr: d.macs[name],
+			}}, nil
+		}
+	}
+	return nil, netlink.LinkNotFoundError{}
+}
+
+func (d *mockBPFHostNATDataplane) LinkAdd(link netlink.Link) error {
+	if d.addErr != nil {
+		return d.addErr
+	}
+	d.links[link.Attrs().Name] = link.(*netlink.Veth)
+	return nil
+}
+
+func (d *mockBPFHostNATDataplane) LinkDel(link netlink.Link) error {
+	delete(d.links, link.Attrs().Name)
+	return nil
+}
+
+func (d *mockBPFHostNATDataplane) LinkSetUp(link netlink.Link) error {
+	d.up = append(d.up, link.Attrs().Name)
+	return nil
+}
+
+type mockRouteRules struct {
+	rules   []*routerule.Rule
+	applied bool
+}
+
+func (r *mockRouteRules) SetRule(rule *routerule.Rule) {
+	r.rules = append(r.rules, rule)
+}
+
+func (r *mockRouteRules) RemoveRule(rule *routerule.Rule) {
+	for i, p := range r.rules {
+		if routerule.RulesMatchDstIifTable(p, rule) {
+			r.rules = append(r.rules[:i], r.rules[i+1:]...)
+			return
+		}
+	}
+}
+
+func (r *mockRouteRules) QueueResync() {}
+
+func (r *mockRouteRules) Apply() error {
+	r.applied = true
+	return nil
+}
@@ -24,6 +24,7 @@ import (
 
 	"github.com/projectcalico/felix/bpf"
 	"github.com/projectcalico/felix/bpf/routes"
+	"github.com/projectcalico/felix/config"
 	"github.com/projectcalico/felix/ifacemonitor"
 	"github.com/projectcalico/felix/ip"
 	"github.com/projectcalico/felix/logutils"
@@ -54,6 +55,12 @@ type bpfRouteManager struct {
 	localIfaceToCIDRs map[string]set.Set
 	// cidrToWEPIDs maps from (/32) CIDR to the set of local proto.WorkloadEndpointIDs that have that CIDR.
 	cidrToWEPIDs map[ip.V4CIDR]set.Set
+	// allowedSourcesEnabled is true if we should route workloads' additional allowed source
+	// prefixes to the workloads.
+	allowedSourcesEnabled bool
+	// allowedSrcCIDRToWEPIDs maps from a workload's additional allowed source CIDR to the set of
+	// local proto.WorkloadEndpointIDs that are allowed to use it.
+	allowedSrcCIDRToWEPIDs map[ip.V4CIDR]set.Set
 	// wepIDToWorklaod contains all the local workloads.
 	wepIDToWorklaod map[proto.WorkloadEndpointID]*proto.WorkloadEndpoint
 	// ifaceNameToIdx maps local interface name to interface ID.
@@ -64,6 +71,10 @@ type bpfRouteManager struct {
 	// externalNodeCIDRs is a set of CIDRs that should be treated as external nodes (and hence we should allow
 	// IPIP and VXLAN to/from them).
 	externalNodeCIDRs set.Set
+	// cidrClassifications maps from operator-configured CIDR to the flags that the CIDR should be
+	// classified with in the routes map (so that, for example, an on-prem VPN range is treated as
+	// remote hosts rather than as the outside world).
+	cidrClassifications map[ip.V4CIDR]routes.Flags
 	// Set of CIDRs for which we need to update the BPF routes.
 	dirtyCIDRs set.Set
 
@@ -83,8 +94,8 @@ type bpfRouteManager struct {
 	opReporter logutils.OpRecorder
 }
 
-func newBPFRouteManager(myNodename string, externalCIDRs []string, mc *bpf.MapContext,
-	opReporter logutils.OpRecorder) *bpfRouteManager {
+func newBPFRouteManager(myNodename string, externalCIDRs []string, classifications map[string]string,
+	allowedSourcesEnabled bool, mc *bpf.MapContext, opReporter logutils.OpRecorder) *bpfRouteManager {
 	// Record the external node CIDRs and pre-mark them as dirty.  These can only change with a config update,
 	// which would restart Felix.
 	extCIDRs := set.New()
@@ -103,6 +114,36 @@ func newBPFRouteManager(myNodename string, externalCIDRs []string, mc *bpf.MapCo
 		dirtyCIDRs.Add(cidr)
 	}
 
+	// Similarly for the configured CIDR classifications.
+	cidrClasses := map[ip.V4CIDR]routes.Flags{}
+	for cidrStr, class := range classifications {
+		if strings.Contains(cidrStr, ":") {
+			log.WithField("cidr", cidrStr).Debug("Ignoring IPv6 route classification")
+			continue
+		}
+		cidr, err := ip.ParseCIDROrIP(cidrStr)
+		if err != nil {
+			log.WithError(err).WithField("cidr", cidrStr).Error(
+				"Failed to parse classified CIDR (which should have been validated already).")
+			continue
+		}
+		var flags routes.Flags
+		switch class {
+		case config.RouteClassHost:
+			flags = routes.FlagsRemoteHost
+		case config.RouteClassWorkloadRemote:
+			flags = routes.FlagsRemoteWorkload
+		case config.RouteClassExternal:
+			flags = routes.FlagsUnknown
+		default:
+			log.WithFields(log.Fields{"cidr": cidrStr, "class": class}).Error(
+				"Unknown route classification (which should have been validated already).")
+			continue
+		}
+		cidrClasses[cidr.(ip.V4CIDR)] = flags
+		dirtyCIDRs.Add(cidr)
+	}
+
 	return &bpfRouteManager{
 		myNodename:        myNodename,
 		cidrToRoute:       map[ip.V4CIDR]proto.RouteUpdate{},
@@ -113,7 +154,12 @@ func newBPFRouteManager(myNodename string, externalCIDRs []string, mc *bpf.MapCo
 		ifaceNameToIdx:    map[string]int{},
 		ifaceNameToWEPIDs: map[string]set.Set{},
 		externalNodeCIDRs: extCIDRs,
-		dirtyCIDRs:        dirtyCIDRs,
+
+		cidrClassifications: cidrClasses,
+
+		allowedSourcesEnabled:  allowedSourcesEnabled,
+		allowedSrcCIDRToWEPIDs: map[ip.V4CIDR]set.Set{},
+		dirtyCIDRs:             dirtyCIDRs,
 
 		desiredRoutes: map[routes.Key]routes.Value{},
 		routeMap:      routes.Map(mc),
@@ -167,6 +213,7 @@ func (m *bpfRouteManager) CompleteDeferredWork() error {
 	numDels, numAdds := m.applyUpdates()
 
 	duration := time.Since(startTime)
+	m.opReporter.RecordCount(logutils.CountBPFMapWrites, int(numDels+numAdds))
 	if numDels > 0 || numAdds > 0 {
 		m.opReporter.RecordOperation("update-bpf-routes")
 		log.WithFields(log.Fields{
@@ -249,6 +296,12 @@ func (m *bpfRouteManager) calculateRoute(cidr ip.V4CIDR) *routes.Value {
 		}
 	}
 
+	if wepIDs, ok := m.allowedSrcCIDRToWEPIDs[cidr]; ok && cgRoute.Type != proto.RouteType_LOCAL_WORKLOAD {
+		// The CIDR is one of a local workload's additional allowed source prefixes.  Route it to
+		// the workload, which is what the workload RPF check looks for.
+		return m.calculateLocalWorkloadRoute(cidr, flags, wepIDs)
+	}
+
 	var route *routes.Value
 
 	switch cgRoute.Type {
@@ -258,32 +311,7 @@ func (m *bpfRouteManager) calculateRoute(cidr ip.V4CIDR) *routes.Value {
 			return nil
 		}
 		if wepIDs, ok := m.cidrToWEPIDs[cidr]; ok {
-			bestWepScore := -1
-			var bestWepID proto.WorkloadEndpointID
-			if wepIDs.Len() > 1 {
-				log.WithField("cidr", cidr).Warn(
-					"Multiple local workloads with same IP but BPF dataplane only supports single route. " +
-						"Will choose one route.")
-			}
-			wepIDs.Iter(func(item interface{}) error {
-				wepScore := 0
-				wepID := item.(proto.WorkloadEndpointID)
-				// Route is a local workload look up its name and interface details.
-				wep := m.wepIDToWorklaod[wepID]
-				ifaceName := wep.Name
-				ifaceIdx, ok := m.ifaceNameToIdx[ifaceName]
-				if ok {
-					wepScore++
-				}
-				if wepScore > bestWepScore || wepScore == bestWepScore && wepID.String() > bestWepID.String() {
-					flags |= routes.FlagsLocalWorkload
-					routeVal := routes.NewValueWithIfIndex(flags, ifaceIdx)
-					route = &routeVal
-					bestWepID = wepID
-					bestWepScore = wepScore
-				}
-				return nil
-			})
+			route = m.calculateLocalWorkloadRoute(cidr, flags, wepIDs)
 		}
 	case proto.RouteType_REMOTE_WORKLOAD:
 		flags |= routes.FlagsRemoteWorkload
@@ -312,7 +340,12 @@ func (m *bpfRouteManager) calculateRoute(cidr ip.V4CIDR) *routes.Value {
 		flags |= routes.FlagsLocalHost
 		fallthrough
 	default: // proto.RouteType_CIDR_INFO / LOCAL_HOST or no route at all
-		if flags != 0 {
+		if classFlags, ok := m.cidrClassifications[cidr]; ok && flags&routes.FlagLocal == 0 {
+			// The operator has told us how to treat this CIDR.  We program it even if it's
+			// classified as external so that it overrides any less specific route.
+			routeVal := routes.NewValue(flags | classFlags)
+			route = &routeVal
+		} else if flags != 0 {
 			// We have something to say about this route.
 			routeVal := routes.NewValue(flags)
 			route = &routeVal
@@ -322,6 +355,36 @@ func (m *bpfRouteManager) calculateRoute(cidr ip.V4CIDR) *routes.Value {
 	return route
 }
 
+func (m *bpfRouteManager) calculateLocalWorkloadRoute(cidr ip.V4CIDR, flags routes.Flags, wepIDs set.Set) *routes.Value {
+	var route *routes.Value
+	bestWepScore := -1
+	var bestWepID proto.WorkloadEndpointID
+	if wepIDs.Len() > 1 {
+		log.WithField("cidr", cidr).Warn(
+			"Multiple local workloads with same IP but BPF dataplane only supports single route. " +
+				"Will choose one route.")
+	}
+	wepIDs.Iter(func(item interface{}) error {
+		wepScore := 0
+		wepID := item.(proto.WorkloadEndpointID)
+		// Route is a local workload look up its name and interface details.
+		wep := m.wepIDToWorklaod[wepID]
+		ifaceName := wep.Name
+		ifaceIdx, ok := m.ifaceNameToIdx[ifaceName]
+		if ok {
+			wepScore++
+		}
+		if wepScore > bestWepScore || wepScore == bestWepScore && wepID.String() > bestWepID.String() {
+			routeVal := routes.NewValueWithIfIndex(flags|routes.FlagsLocalWorkload, ifaceIdx)
+			route = &routeVal
+			bestWepID = wepID
+			bestWepScore = wepScore
+		}
+		return nil
+	})
+	return route
+}
+
 func (m *bpfRouteManager) applyUpdates() (numDels uint, numAdds uint) {
 
 	debug := log.GetLevel() >= log.DebugLevel
@@ -432,8 +495,8 @@ func (m *bpfRouteManager) onIfaceIdxChanged(name string) {
 	wepIDs.Iter(func(item interface{}) error {
 		wepID := item.(proto.WorkloadEndpointID)
 		wep := m.wepIDToWorklaod[wepID]
-		cidrs := getV4WorkloadCIDRs(wep)
-		m.markCIDRsDirty(cidrs...)
+		m.markCIDRsDirty(getV4WorkloadCIDRs(wep)...)
+		m.markCIDRsDirty(m.getV4AllowedSourceCIDRs(wep)...)
 		return nil
 	})
 }
@@ -568,6 +631,16 @@ func (m *bpfRouteManager) addWEP(update *proto.WorkloadEndpointUpdate) {
 		wepIDs.Add(*update.Id)
 	}
 	m.markCIDRsDirty(newCIDRs...)
+	allowedCIDRs := m.getV4AllowedSourceCIDRs(update.Endpoint)
+	for _, cidr := range allowedCIDRs {
+		wepIDs := m.allowedSrcCIDRToWEPIDs[cidr]
+		if wepIDs == nil {
+			wepIDs = set.New()
+			m.allowedSrcCIDRToWEPIDs[cidr] = wepIDs
+		}
+		wepIDs.Add(*update.Id)
+	}
+	m.markCIDRsDirty(allowedCIDRs...)
 	wepIDs := m.ifaceNameToWEPIDs[update.Endpoint.Name]
 	if wepIDs == nil {
 		wepIDs = set.New()
@@ -594,6 +667,14 @@ func (m *bpfRouteManager) removeWEP(id *proto.WorkloadEndpointID) {
 		}
 	}
 	m.markCIDRsDirty(oldCIDRs...)
+	oldAllowedCIDRs := m.getV4AllowedSourceCIDRs(oldWEP)
+	for _, cidr := range oldAllowedCIDRs {
+		m.allowedSrcCIDRToWEPIDs[cidr].Discard(*id)
+		if m.allowedSrcCIDRToWEPIDs[cidr].Len() == 0 {
+			delete(m.allowedSrcCIDRToWEPIDs, cidr)
+		}
+	}
+	m.markCIDRsDirty(oldAllowedCIDRs...)
 	m.ifaceNameToWEPIDs[oldWEP.Name].Discard(*id)
 	if m.ifaceNameToWEPIDs[oldWEP.Name].Len() == 0 {
 		delete(m.ifaceNameToWEPIDs, oldWEP.Name)
@@ -610,6 +691,25 @@ func getV4WorkloadCIDRs(wep *proto.WorkloadEndpoint) (cidrs []ip.V4CIDR) {
 	return
 }
 
+// getV4AllowedSourceCIDRs returns the workload's additional allowed source CIDRs that are IPv4,
+// skipping any that are unparsable.  Returns nil if allowed sources are disabled.
+func (m *bpfRouteManager) getV4AllowedSourceCIDRs(wep *proto.WorkloadEndpoint) (cidrs []ip.V4CIDR) {
+	if wep == nil || !m.allowedSourcesEnabled {
+		return
+	}
+	for _, s := range wep.AllowedSourcePrefixes {
+		cidr, err := ip.ParseCIDROrIP(s)
+		if err != nil {
+			log.WithError(err).WithField("cidr", s).Warn("Ignoring unparsable allowed source prefix.")
+			continue
+		}
+		if v4CIDR, ok := cidr.(ip.V4CIDR); ok {
+			cidrs = append(cidrs, v4CIDR)
+		}
+	}
+	return
+}
+
 func (m *bpfRouteManager) setHostIPUpdatesCallBack(cb func([]net.IP)) {
 	m.cbLck.Lock()
 	defer m.cbLck.Unlock()
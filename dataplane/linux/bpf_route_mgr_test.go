@@ -0,0 +1,76 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/bpf"
+	"github.com/projectcalico/felix/bpf/routes"
+	"github.com/projectcalico/felix/ip"
+	"github.com/projectcalico/felix/logutils"
+	"github.com/projectcalico/felix/proto"
+)
+
+var _ = Describe("BPF route manager route classifications", func() {
+	var manager *bpfRouteManager
+
+	BeforeEach(func() {
+		manager = newBPFRouteManager("node1", nil, map[string]string{
+			"10.10.0.0/16": "host",
+			"10.20.0.0/16": "workload-remote",
+			"10.10.5.0/24": "external",
+			"fd00::/64":    "host",
+		}, false, &bpf.MapContext{}, logutils.NewSummarizer("test"))
+		manager.recalculateRoutesForDirtyCIDRs()
+	})
+
+	It("should program a route for each IPv4 classification", func() {
+		Expect(manager.desiredRoutes).To(Equal(map[routes.Key]routes.Value{
+			routes.NewKey(ip.MustParseCIDROrIP("10.10.0.0/16").(ip.V4CIDR)): routes.NewValue(routes.FlagsRemoteHost),
+			routes.NewKey(ip.MustParseCIDROrIP("10.20.0.0/16").(ip.V4CIDR)): routes.NewValue(routes.FlagsRemoteWorkload),
+			routes.NewKey(ip.MustParseCIDROrIP("10.10.5.0/24").(ip.V4CIDR)): routes.NewValue(routes.FlagsUnknown),
+		}))
+	})
+
+	It("should give a calculation graph route precedence over a classification", func() {
+		manager.OnUpdate(&proto.RouteUpdate{
+			Type:        proto.RouteType_REMOTE_WORKLOAD,
+			Dst:         "10.20.0.0/16",
+			DstNodeName: "node2",
+			DstNodeIp:   "192.168.0.2",
+		})
+		manager.recalculateRoutesForDirtyCIDRs()
+
+		cidr := ip.MustParseCIDROrIP("10.20.0.0/16").(ip.V4CIDR)
+		Expect(manager.desiredRoutes[routes.NewKey(cidr)]).To(Equal(routes.NewValueWithNextHop(
+			routes.FlagsRemoteWorkload, ip.FromString("192.168.0.2").(ip.V4Addr))))
+	})
+
+	It("should keep the classification for an IP pool's CIDR", func() {
+		manager.OnUpdate(&proto.RouteUpdate{
+			Type:        proto.RouteType_CIDR_INFO,
+			IpPoolType:  proto.IPPoolType_VXLAN,
+			Dst:         "10.10.0.0/16",
+			NatOutgoing: true,
+		})
+		manager.recalculateRoutesForDirtyCIDRs()
+
+		cidr := ip.MustParseCIDROrIP("10.10.0.0/16").(ip.V4CIDR)
+		Expect(manager.desiredRoutes[routes.NewKey(cidr)]).To(Equal(routes.NewValue(
+			routes.FlagsRemoteHost | routes.FlagInIPAMPool | routes.FlagNATOutgoing)))
+	})
+})
@@ -0,0 +1,171 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/rules"
+)
+
+// cniConfExtensions are the file extensions that the CNI library recognises as network configs.
+var cniConfExtensions = []string{".conf", ".conflist", ".json"}
+
+// cniGatePollInterval is how often we look for the CNI plugin while the gate is closed.
+const cniGatePollInterval = 2 * time.Second
+
+// The CNI gate manager holds the dataplane "closed" until the CNI plugin has been installed on
+// the host.  While the gate is closed, it maintains a chain in the filter table that drops new
+// connections from workload interfaces, and it reports that it isn't ready, which holds off
+// Felix's readiness.  It polls for the plugin by asking the main loop to reschedule it.  This closes the window during node bootstrap where pods could be networked
+// by a CNI plugin before Felix is in a position to police them.
+//
+// Once the CNI config and binaries are present the gate opens and stays open; we don't close it
+// again if the plugin is later removed.
+type cniGateManager struct {
+	// Our dependencies.
+	filterTables []iptablesTable
+	ruleRenderer rules.RuleRenderer
+
+	netDir         string
+	binDir         string
+	pluginBinaries []string
+
+	// Internal state.
+	open         bool
+	chainsDirty  bool
+	lastProblems string
+
+	// Shims for testing.
+	readDir func(dirname string) ([]os.FileInfo, error)
+	stat    func(name string) (os.FileInfo, error)
+}
+
+func newCNIGateManager(
+	filterTables []iptablesTable,
+	ruleRenderer rules.RuleRenderer,
+	netDir string,
+	binDir string,
+	pluginBinaries []string,
+) *cniGateManager {
+	return newCNIGateManagerWithShims(filterTables, ruleRenderer, netDir, binDir, pluginBinaries,
+		ioutil.ReadDir, os.Stat)
+}
+
+func newCNIGateManagerWithShims(
+	filterTables []iptablesTable,
+	ruleRenderer rules.RuleRenderer,
+	netDir string,
+	binDir string,
+	pluginBinaries []string,
+	readDir func(dirname string) ([]os.FileInfo, error),
+	stat func(name string) (os.FileInfo, error),
+) *cniGateManager {
+	return &cniGateManager{
+		filterTables:   filterTables,
+		ruleRenderer:   ruleRenderer,
+		netDir:         netDir,
+		binDir:         binDir,
+		pluginBinaries: pluginBinaries,
+		chainsDirty:    true,
+		readDir:        readDir,
+		stat:           stat,
+	}
+}
+
+func (m *cniGateManager) OnUpdate(protoBufMsg interface{}) {
+}
+
+// IsOpen returns true once the CNI plugin has been found.
+func (m *cniGateManager) IsOpen() bool {
+	return m.open
+}
+
+func (m *cniGateManager) CompleteDeferredWork() error {
+	if !m.open {
+		if problems := m.checkCNI(); len(problems) > 0 {
+			if msg := strings.Join(problems, "; "); msg != m.lastProblems {
+				log.WithField("problems", msg).Info("Waiting for CNI plugin before reporting ready")
+				m.lastProblems = msg
+			}
+		} else {
+			log.Info("CNI plugin found, opening CNI gate.")
+			m.open = true
+			m.chainsDirty = true
+		}
+	}
+
+	if m.chainsDirty {
+		chain := m.ruleRenderer.CNIGateChain(m.open)
+		for _, t := range m.filterTables {
+			t.UpdateChain(chain)
+		}
+		m.chainsDirty = false
+	}
+
+	return nil
+}
+
+// RescheduleAfter asks for another CompleteDeferredWork call, to check for the CNI plugin again,
+// while the gate is closed.
+func (m *cniGateManager) RescheduleAfter() time.Duration {
+	if m.open {
+		return 0
+	}
+	return cniGatePollInterval
+}
+
+// checkCNI returns a list of reasons that the CNI plugin isn't ready, or an empty list if it is.
+func (m *cniGateManager) checkCNI() (problems []string) {
+	files, err := m.readDir(m.netDir)
+	if err != nil {
+		problems = append(problems, fmt.Sprintf("failed to read CNI config dir: %v", err))
+	} else {
+		foundConf := false
+		for _, f := range files {
+			if f.IsDir() {
+				continue
+			}
+			for _, ext := range cniConfExtensions {
+				if path.Ext(f.Name()) == ext {
+					foundConf = true
+				}
+			}
+		}
+		if !foundConf {
+			problems = append(problems, fmt.Sprintf("no CNI config in %s", m.netDir))
+		}
+	}
+
+	for _, b := range m.pluginBinaries {
+		fileName := path.Join(m.binDir, b)
+		info, err := m.stat(fileName)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("CNI plugin binary %s missing: %v", fileName, err))
+			continue
+		}
+		if info.IsDir() || info.Mode()&0111 == 0 {
+			problems = append(problems, fmt.Sprintf("CNI plugin binary %s is not executable", fileName))
+		}
+	}
+	return
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"os"
+	"path"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/ipsets"
+	"github.com/projectcalico/felix/iptables"
+	"github.com/projectcalico/felix/rules"
+)
+
+var _ = Describe("CNI gate manager", func() {
+	var (
+		gateMgr      *cniGateManager
+		filterTable  *mockTable
+		ruleRenderer rules.RuleRenderer
+		files        map[string]os.FileInfo
+	)
+
+	closedChain := &iptables.Chain{
+		Name: rules.ChainCNIGate,
+		Rules: []iptables.Rule{{
+			Match:   iptables.Match().ConntrackState("NEW"),
+			Action:  iptables.DropAction{},
+			Comment: []string{"Drop new workload connections until CNI plugin is ready"},
+		}},
+	}
+	openChain := &iptables.Chain{
+		Name: rules.ChainCNIGate,
+	}
+
+	addFile := func(dir, name string, mode os.FileMode) {
+		files[path.Join(dir, name)] = fakeFileInfo{name: name, mode: mode}
+	}
+
+	BeforeEach(func() {
+		files = map[string]os.FileInfo{}
+		filterTable = newMockTable("filter")
+		ruleRenderer = rules.NewRenderer(rules.Config{
+			IPSetConfigV4: ipsets.NewIPVersionConfig(
+				ipsets.IPFamilyV4,
+				"cali",
+				nil,
+				nil,
+			),
+			IptablesMarkPass:     0x1,
+			IptablesMarkAccept:   0x2,
+			IptablesMarkScratch0: 0x4,
+			IptablesMarkScratch1: 0x8,
+			IptablesMarkEndpoint: 0x11110000,
+		})
+		readDir := func(dirname string) (infos []os.FileInfo, err error) {
+			for name, info := range files {
+				if path.Dir(name) == dirname {
+					infos = append(infos, info)
+				}
+			}
+			return
+		}
+		stat := func(name string) (os.FileInfo, error) {
+			if info, ok := files[name]; ok {
+				return info, nil
+			}
+			return nil, os.ErrNotExist
+		}
+		gateMgr = newCNIGateManagerWithShims([]iptablesTable{filterTable}, ruleRenderer,
+			"/etc/cni/net.d", "/opt/cni/bin", []string{"calico", "calico-ipam"}, readDir, stat)
+	})
+
+	It("should block new connections and report not ready before the CNI plugin is installed", func() {
+		Expect(gateMgr.CompleteDeferredWork()).To(Succeed())
+		Expect(gateMgr.IsOpen()).To(BeFalse())
+		Expect(gateMgr.RescheduleAfter()).To(Equal(cniGatePollInterval))
+		filterTable.checkChains([][]*iptables.Chain{{closedChain}})
+	})
+
+	It("should stay closed with config but only some of the binaries", func() {
+		addFile("/etc/cni/net.d", "10-calico.conflist", 0644)
+		addFile("/opt/cni/bin", "calico", 0755)
+		Expect(gateMgr.CompleteDeferredWork()).To(Succeed())
+		Expect(gateMgr.IsOpen()).To(BeFalse())
+	})
+
+	It("should stay closed if a binary isn't executable", func() {
+		addFile("/etc/cni/net.d", "10-calico.conflist", 0644)
+		addFile("/opt/cni/bin", "calico", 0755)
+		addFile("/opt/cni/bin", "calico-ipam", 0644)
+		Expect(gateMgr.CompleteDeferredWork()).To(Succeed())
+		Expect(gateMgr.IsOpen()).To(BeFalse())
+	})
+
+	It("should ignore files that aren't CNI configs", func() {
+		addFile("/etc/cni/net.d", "calico-kubeconfig", 0600)
+		addFile("/opt/cni/bin", "calico", 0755)
+		addFile("/opt/cni/bin", "calico-ipam", 0755)
+		Expect(gateMgr.CompleteDeferredWork()).To(Succeed())
+		Expect(gateMgr.IsOpen()).To(BeFalse())
+	})
+
+	Describe("after the CNI plugin is installed", func() {
+		BeforeEach(func() {
+			Expect(gateMgr.CompleteDeferredWork()).To(Succeed())
+			addFile("/etc/cni/net.d", "10-calico.conflist", 0644)
+			addFile("/opt/cni/bin", "calico", 0755)
+			addFile("/opt/cni/bin", "calico-ipam", 0755)
+			Expect(gateMgr.CompleteDeferredWork()).To(Succeed())
+		})
+
+		It("should open the gate", func() {
+			Expect(gateMgr.IsOpen()).To(BeTrue())
+			Expect(gateMgr.RescheduleAfter()).To(BeZero())
+			filterTable.checkChains([][]*iptables.Chain{{openChain}})
+		})
+
+		It("should stay open if the plugin is removed", func() {
+			files = map[string]os.FileInfo{}
+			Expect(gateMgr.CompleteDeferredWork()).To(Succeed())
+			Expect(gateMgr.IsOpen()).To(BeTrue())
+			filterTable.checkChains([][]*iptables.Chain{{openChain}})
+		})
+	})
+})
+
+type fakeFileInfo struct {
+	name string
+	mode os.FileMode
+}
+
+func (f fakeFileInfo) Name() string       { return f.name }
+func (f fakeFileInfo) Size() int64        { return 0 }
+func (f fakeFileInfo) Mode() os.FileMode  { return f.mode }
+func (f fakeFileInfo) ModTime() time.Time { return time.Time{} }
+func (f fakeFileInfo) IsDir() bool        { return f.mode.IsDir() }
+func (f fakeFileInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,129 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/config"
+	"github.com/projectcalico/felix/jitter"
+	"github.com/projectcalico/felix/proto"
+)
+
+// reloadableConfigParams maps the config parameters that the dataplane can apply in place to the
+// functions that apply their parsed values.  Felix restarts when any other parameter changes.
+//
+// Other parameters stay out of this list because something that we can't update in place depends
+// on them.  For example, the MTUs are baked into the pod MTU file that the CNI plugin reads, and
+// into the BPF programs.
+var reloadableConfigParams = map[string]func(d *InternalDataplane, value interface{}){
+	"IptablesRefreshInterval": func(d *InternalDataplane, value interface{}) {
+		d.config.IptablesRefreshInterval = value.(time.Duration)
+		for _, t := range d.allIptablesTables {
+			t.SetRefreshInterval(d.config.IptablesRefreshInterval)
+		}
+	},
+	"IptablesPostWriteCheckIntervalSecs": func(d *InternalDataplane, value interface{}) {
+		d.config.IptablesPostWriteCheckInterval = value.(time.Duration)
+		for _, t := range d.allIptablesTables {
+			// Only the iptables backend re-checks after writes.
+			if t, ok := t.(postWriteIntervalSetter); ok {
+				t.SetPostWriteInterval(d.config.IptablesPostWriteCheckInterval)
+			}
+		}
+	},
+	"IpsetsRefreshInterval": func(d *InternalDataplane, value interface{}) {
+		d.config.IPSetsRefreshInterval = value.(time.Duration)
+		d.ipSetsRefreshTimer.Reset("IP sets", d.config.IPSetsRefreshInterval)
+	},
+	"RouteRefreshInterval": func(d *InternalDataplane, value interface{}) {
+		d.config.RouteRefreshInterval = value.(time.Duration)
+		d.routeRefreshTimer.Reset("routes", d.config.RouteRefreshInterval)
+	},
+	"XDPRefreshInterval": func(d *InternalDataplane, value interface{}) {
+		d.config.XDPRefreshInterval = value.(time.Duration)
+		if d.xdpState != nil {
+			d.xdpRefreshTimer.Reset("XDP", d.config.XDPRefreshInterval)
+		}
+	},
+}
+
+type postWriteIntervalSetter interface {
+	SetPostWriteInterval(interval time.Duration)
+}
+
+// ReloadableConfigParams returns the names of the config parameters that the internal dataplane
+// applies in place when they change, so that Felix doesn't need to restart for them.
+func ReloadableConfigParams() []string {
+	var names []string
+	for name := range reloadableConfigParams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// onConfigUpdate applies any changes to the reloadable config parameters.  The first ConfigUpdate
+// carries the config that we were started with, so it only records the baseline.
+func (d *InternalDataplane) onConfigUpdate(msg *proto.ConfigUpdate) {
+	if d.lastRawConfig != nil {
+		for name, apply := range reloadableConfigParams {
+			rawValue := msg.Config[name]
+			if rawValue == d.lastRawConfig[name] {
+				continue
+			}
+			logCxt := log.WithFields(log.Fields{
+				"param": name,
+				"old":   d.lastRawConfig[name],
+				"new":   rawValue,
+			})
+			value, err := config.ParseRawValue(name, rawValue)
+			if err != nil {
+				logCxt.WithError(err).Warn("Ignoring invalid value for reloaded config parameter.")
+				continue
+			}
+			logCxt.Info("Reloading config parameter.")
+			apply(d, value)
+		}
+	}
+	d.lastRawConfig = msg.Config
+}
+
+// refreshTimer is a jittered ticker whose interval can be changed.  C is nil while the timer is
+// disabled.
+type refreshTimer struct {
+	ticker *jitter.Ticker
+	C      <-chan time.Time
+}
+
+// Reset (re)starts the timer with the given interval; a zero interval disables it.
+func (t *refreshTimer) Reset(what string, interval time.Duration) {
+	if t.ticker != nil {
+		// Stop() blocks until the ticker's next tick so don't wait for it.
+		go t.ticker.Stop()
+		t.ticker = nil
+		t.C = nil
+	}
+	if interval <= 0 {
+		log.Infof("Not refreshing %s on timer", what)
+		return
+	}
+	log.WithField("interval", interval).Infof("Will refresh %s on timer", what)
+	t.ticker = jitter.NewTicker(interval, interval/10)
+	t.C = t.ticker.C
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/proto"
+)
+
+var _ = Describe("Config reload", func() {
+	var d *InternalDataplane
+
+	BeforeEach(func() {
+		d = &InternalDataplane{}
+		d.config.RouteRefreshInterval = 90 * time.Second
+		d.routeRefreshTimer.Reset("routes", d.config.RouteRefreshInterval)
+	})
+
+	AfterEach(func() {
+		d.routeRefreshTimer.Reset("routes", 0)
+	})
+
+	It("should list the reloadable params", func() {
+		Expect(ReloadableConfigParams()).To(ContainElements("IptablesRefreshInterval", "RouteRefreshInterval"))
+	})
+
+	It("should not reload anything from the first ConfigUpdate", func() {
+		d.onConfigUpdate(&proto.ConfigUpdate{Config: map[string]string{"RouteRefreshInterval": "30"}})
+		Expect(d.config.RouteRefreshInterval).To(Equal(90 * time.Second))
+	})
+
+	Describe("after the first ConfigUpdate", func() {
+		BeforeEach(func() {
+			d.onConfigUpdate(&proto.ConfigUpdate{Config: map[string]string{}})
+		})
+
+		It("should reload a changed refresh interval", func() {
+			oldC := d.routeRefreshTimer.C
+			d.onConfigUpdate(&proto.ConfigUpdate{Config: map[string]string{"RouteRefreshInterval": "30"}})
+			Expect(d.config.RouteRefreshInterval).To(Equal(30 * time.Second))
+			Expect(d.routeRefreshTimer.C).NotTo(BeNil())
+			Expect(d.routeRefreshTimer.C).NotTo(Equal(oldC))
+		})
+
+		It("should stop the timer if the refresh is disabled", func() {
+			d.onConfigUpdate(&proto.ConfigUpdate{Config: map[string]string{"RouteRefreshInterval": "0"}})
+			Expect(d.config.RouteRefreshInterval).To(BeZero())
+			Expect(d.routeRefreshTimer.C).To(BeNil())
+		})
+
+		It("should revert to the default when the param is removed", func() {
+			d.onConfigUpdate(&proto.ConfigUpdate{Config: map[string]string{"RouteRefreshInterval": "30"}})
+			d.onConfigUpdate(&proto.ConfigUpdate{Config: map[string]string{}})
+			Expect(d.config.RouteRefreshInterval).To(Equal(90 * time.Second))
+		})
+
+		It("should ignore an invalid value", func() {
+			d.onConfigUpdate(&proto.ConfigUpdate{Config: map[string]string{"RouteRefreshInterval": "foo"}})
+			Expect(d.config.RouteRefreshInterval).To(Equal(90 * time.Second))
+		})
+	})
+})
@@ -0,0 +1,216 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/rules"
+)
+
+// connRateLimitReportInterval is how often we read the rate limit rules' counters.
+const connRateLimitReportInterval = 10 * time.Second
+
+var (
+	countVecConnRateLimitDrops = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "felix_workload_conn_rate_limit_drops",
+		Help: "Number of new connections from each local workload endpoint that were dropped by its rate limit.",
+	}, []string{"orchestrator", "workload", "endpoint"})
+	gaugeConnRateLimitedEndpoints = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "felix_workload_conn_rate_limited_endpoints",
+		Help: "Number of local workload endpoints that hit their connection rate limit in the last interval.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(countVecConnRateLimitDrops)
+	prometheus.MustRegister(gaugeConnRateLimitedEndpoints)
+}
+
+// ruleCounterTable is an iptablesTable that can also report the packet counts of its rules.
+type ruleCounterTable interface {
+	iptablesTable
+	ReadRuleCounters(chainName string) (map[string]uint64, error)
+}
+
+// The workload connection rate limit manager contains misbehaving workloads by limiting the rate
+// at which each workload can open new connections.  The limit comes from config, and can be
+// overridden per-endpoint via a label (which the calculation graph passes down as the endpoint's
+// ConnectionRateLimit); a limit of 0 means no limit.
+//
+// The manager maintains a chain in the filter table with a hashlimit rule per limited workload
+// interface.  It periodically reads the rules' counters to find out which workloads are being
+// limited and reports them via Prometheus and the log.
+type workloadConnRateLimitManager struct {
+	// Our dependencies.
+	filterTables []ruleCounterTable
+	ruleRenderer rules.RuleRenderer
+
+	// Config.
+	defaultRate int
+	burst       int
+
+	// Internal state.
+	workloads       map[proto.WorkloadEndpointID]*proto.WorkloadEndpoint
+	dirty           bool
+	countersPending bool
+	// lastDrops holds the drop count that we last read for each workload interface, so that we
+	// can calculate the increase.
+	lastDrops map[string]uint64
+	// limitedIfaces holds the workload interfaces that dropped connections in the last interval.
+	limitedIfaces map[string]bool
+}
+
+func newWorkloadConnRateLimitManager(
+	filterTables []ruleCounterTable,
+	ruleRenderer rules.RuleRenderer,
+	defaultRate int,
+	burst int,
+) *workloadConnRateLimitManager {
+	return &workloadConnRateLimitManager{
+		filterTables:  filterTables,
+		ruleRenderer:  ruleRenderer,
+		defaultRate:   defaultRate,
+		burst:         burst,
+		workloads:     map[proto.WorkloadEndpointID]*proto.WorkloadEndpoint{},
+		dirty:         true,
+		lastDrops:     map[string]uint64{},
+		limitedIfaces: map[string]bool{},
+	}
+}
+
+func (m *workloadConnRateLimitManager) OnUpdate(protoBufMsg interface{}) {
+	switch msg := protoBufMsg.(type) {
+	case *proto.WorkloadEndpointUpdate:
+		m.workloads[*msg.Id] = msg.Endpoint
+		m.dirty = true
+	case *proto.WorkloadEndpointRemove:
+		if _, ok := m.workloads[*msg.Id]; ok {
+			delete(m.workloads, *msg.Id)
+			countVecConnRateLimitDrops.DeleteLabelValues(msg.Id.OrchestratorId, msg.Id.WorkloadId, msg.Id.EndpointId)
+			m.dirty = true
+		}
+	}
+}
+
+// QueueCounterRead asks the manager to read the rate limit rules' counters on the next call to
+// CompleteDeferredWork.
+func (m *workloadConnRateLimitManager) QueueCounterRead() {
+	m.countersPending = true
+}
+
+// IsLimited returns true if the given workload interface dropped new connections in the last
+// interval.
+func (m *workloadConnRateLimitManager) IsLimited(ifaceName string) bool {
+	return m.limitedIfaces[ifaceName]
+}
+
+func (m *workloadConnRateLimitManager) CompleteDeferredWork() error {
+	if m.dirty {
+		chain := m.ruleRenderer.WorkloadConnRateLimitChain(m.calculateLimits())
+		for _, t := range m.filterTables {
+			t.UpdateChain(chain)
+		}
+		m.dirty = false
+	}
+	if m.countersPending {
+		m.readCounters()
+		m.countersPending = false
+	}
+	return nil
+}
+
+func (m *workloadConnRateLimitManager) calculateLimits() (limits []rules.WorkloadConnRateLimit) {
+	for id, ep := range m.workloads {
+		rate := m.defaultRate
+		if ep.ConnectionRateLimit != "" {
+			override, err := strconv.Atoi(ep.ConnectionRateLimit)
+			if err != nil || override < 0 {
+				log.WithFields(log.Fields{
+					"workload": id,
+					"limit":    ep.ConnectionRateLimit,
+				}).Warn("Ignoring workload's invalid connection rate limit, using the default.")
+			} else {
+				rate = override
+			}
+		}
+		if rate == 0 {
+			continue
+		}
+		limits = append(limits, rules.WorkloadConnRateLimit{
+			IfaceName:  ep.Name,
+			RatePerSec: rate,
+			Burst:      m.burst,
+		})
+	}
+	return
+}
+
+func (m *workloadConnRateLimitManager) readCounters() {
+	drops := map[string]uint64{}
+	for _, t := range m.filterTables {
+		counters, err := t.ReadRuleCounters(m.ruleRenderer.ChainName(rules.ChainWorkloadConnRateLimit))
+		if err != nil {
+			// Not worth retrying early; we'll try again on the next interval.
+			log.WithError(err).Warn("Failed to read connection rate limit counters.")
+			return
+		}
+		for comment, packets := range counters {
+			drops[comment] += packets
+		}
+	}
+
+	newLastDrops := map[string]uint64{}
+	newLimited := map[string]bool{}
+	for id, ep := range m.workloads {
+		comment := rules.WorkloadConnRateLimitComment(ep.Name)
+		count, ok := drops[comment]
+		if !ok {
+			continue
+		}
+		newLastDrops[ep.Name] = count
+		increase := count - m.lastDrops[ep.Name]
+		if count < m.lastDrops[ep.Name] {
+			// The counter was reset by a rule update.
+			increase = count
+		}
+		if increase == 0 {
+			continue
+		}
+		countVecConnRateLimitDrops.WithLabelValues(id.OrchestratorId, id.WorkloadId, id.EndpointId).Add(
+			float64(increase))
+		newLimited[ep.Name] = true
+		if !m.limitedIfaces[ep.Name] {
+			log.WithFields(log.Fields{
+				"workload": id,
+				"iface":    ep.Name,
+				"dropped":  increase,
+			}).Info("Workload hit its connection rate limit.")
+		}
+	}
+	for iface := range m.limitedIfaces {
+		if !newLimited[iface] {
+			log.WithField("iface", iface).Info("Workload no longer hitting its connection rate limit.")
+		}
+	}
+	m.lastDrops = newLastDrops
+	m.limitedIfaces = newLimited
+	gaugeConnRateLimitedEndpoints.Set(float64(len(newLimited)))
+}
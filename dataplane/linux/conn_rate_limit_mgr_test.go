@@ -0,0 +1,175 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/ipsets"
+	"github.com/projectcalico/felix/iptables"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/rules"
+)
+
+var _ = Describe("Workload connection rate limit manager", func() {
+	var (
+		limitMgr     *workloadConnRateLimitManager
+		filterTable  *mockTable
+		ruleRenderer rules.RuleRenderer
+	)
+
+	wlID1 := proto.WorkloadEndpointID{
+		OrchestratorId: "k8s",
+		WorkloadId:     "pod-11",
+		EndpointId:     "endpoint-id-11",
+	}
+	wlID2 := proto.WorkloadEndpointID{
+		OrchestratorId: "k8s",
+		WorkloadId:     "pod-12",
+		EndpointId:     "endpoint-id-12",
+	}
+
+	limitRule := func(iface string, rate, burst int) iptables.Rule {
+		return iptables.Rule{
+			Match: iptables.Match().InInterface(iface).ConntrackState("NEW").
+				HashLimitAbove(iface, rate, burst),
+			Action:  iptables.DropAction{},
+			Comment: []string{"Rate limit new connections from " + iface},
+		}
+	}
+
+	addWorkload := func(id proto.WorkloadEndpointID, iface, limit string) {
+		limitMgr.OnUpdate(&proto.WorkloadEndpointUpdate{
+			Id: &id,
+			Endpoint: &proto.WorkloadEndpoint{
+				Name:                iface,
+				Ipv4Nets:            []string{"10.0.240.10/32"},
+				ConnectionRateLimit: limit,
+			},
+		})
+	}
+
+	setDrops := func(drops map[string]uint64) {
+		filterTable.RuleCounters = map[string]map[string]uint64{}
+		filterTable.RuleCounters[rules.ChainWorkloadConnRateLimit] = map[string]uint64{}
+		for iface, n := range drops {
+			filterTable.RuleCounters[rules.ChainWorkloadConnRateLimit][rules.WorkloadConnRateLimitComment(iface)] = n
+		}
+	}
+
+	readCounters := func() {
+		limitMgr.QueueCounterRead()
+		Expect(limitMgr.CompleteDeferredWork()).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		filterTable = newMockTable("filter")
+		ruleRenderer = rules.NewRenderer(rules.Config{
+			IPSetConfigV4: ipsets.NewIPVersionConfig(
+				ipsets.IPFamilyV4,
+				"cali",
+				nil,
+				nil,
+			),
+			IptablesMarkPass:             0x1,
+			IptablesMarkAccept:           0x2,
+			IptablesMarkScratch0:         0x4,
+			IptablesMarkScratch1:         0x8,
+			IptablesMarkEndpoint:         0x11110000,
+			WorkloadConnRateLimitEnabled: true,
+		})
+	})
+
+	Describe("with no default limit", func() {
+		BeforeEach(func() {
+			limitMgr = newWorkloadConnRateLimitManager([]ruleCounterTable{filterTable}, ruleRenderer, 0, 50)
+			addWorkload(wlID1, "cali1", "")
+			addWorkload(wlID2, "cali2", "20")
+			Expect(limitMgr.CompleteDeferredWork()).To(Succeed())
+		})
+
+		It("should only limit workloads with an override", func() {
+			filterTable.checkChains([][]*iptables.Chain{{{
+				Name:  rules.ChainWorkloadConnRateLimit,
+				Rules: []iptables.Rule{limitRule("cali2", 20, 50)},
+			}}})
+		})
+
+		It("should remove the limit when the workload is removed", func() {
+			limitMgr.OnUpdate(&proto.WorkloadEndpointRemove{Id: &wlID2})
+			Expect(limitMgr.CompleteDeferredWork()).To(Succeed())
+			filterTable.checkChains([][]*iptables.Chain{{{
+				Name: rules.ChainWorkloadConnRateLimit,
+			}}})
+		})
+	})
+
+	Describe("with a default limit", func() {
+		BeforeEach(func() {
+			limitMgr = newWorkloadConnRateLimitManager([]ruleCounterTable{filterTable}, ruleRenderer, 100, 50)
+			addWorkload(wlID1, "cali1", "")
+			addWorkload(wlID2, "cali2", "0")
+			Expect(limitMgr.CompleteDeferredWork()).To(Succeed())
+		})
+
+		It("should limit workloads without an override and allow them to opt out", func() {
+			filterTable.checkChains([][]*iptables.Chain{{{
+				Name:  rules.ChainWorkloadConnRateLimit,
+				Rules: []iptables.Rule{limitRule("cali1", 100, 50)},
+			}}})
+		})
+
+		It("should use the default for an invalid override", func() {
+			addWorkload(wlID2, "cali2", "lots")
+			Expect(limitMgr.CompleteDeferredWork()).To(Succeed())
+			filterTable.checkChains([][]*iptables.Chain{{{
+				Name: rules.ChainWorkloadConnRateLimit,
+				Rules: []iptables.Rule{
+					limitRule("cali1", 100, 50),
+					limitRule("cali2", 100, 50),
+				},
+			}}})
+		})
+
+		It("should only report workloads as limited while their drop counts increase", func() {
+			setDrops(map[string]uint64{"cali1": 0})
+			readCounters()
+			Expect(limitMgr.IsLimited("cali1")).To(BeFalse())
+
+			setDrops(map[string]uint64{"cali1": 5})
+			readCounters()
+			Expect(limitMgr.IsLimited("cali1")).To(BeTrue())
+
+			readCounters()
+			Expect(limitMgr.IsLimited("cali1")).To(BeFalse())
+
+			// A lower count means the rule was replaced, resetting its counter.
+			setDrops(map[string]uint64{"cali1": 2})
+			readCounters()
+			Expect(limitMgr.IsLimited("cali1")).To(BeTrue())
+		})
+
+		It("should keep its state if the counters can't be read", func() {
+			setDrops(map[string]uint64{"cali1": 5})
+			readCounters()
+			filterTable.CountersErr = errors.New("dummy error")
+			readCounters()
+			Expect(limitMgr.IsLimited("cali1")).To(BeTrue())
+		})
+	})
+})
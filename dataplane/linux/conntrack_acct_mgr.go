@@ -0,0 +1,247 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"net"
+	"sort"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+
+	"github.com/projectcalico/felix/ip"
+	"github.com/projectcalico/felix/proto"
+)
+
+var (
+	gaugeConntrackWorkloadFlows = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "felix_conntrack_workload_flows",
+		Help: "Number of conntrack entries for local workload IPs, as of the last sample.",
+	})
+	gaugeVecConntrackEndpointFlows = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "felix_conntrack_endpoint_flows",
+		Help: "Number of conntrack entries for each local workload endpoint, as of the last sample.",
+	}, []string{"orchestrator", "workload", "endpoint"})
+	gaugeVecConntrackTopEndpointFlows = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "felix_conntrack_top_endpoint_flows",
+		Help: "Number of conntrack entries for the local workload endpoints with the most entries, by rank.",
+	}, []string{"rank", "orchestrator", "workload", "endpoint"})
+)
+
+func init() {
+	prometheus.MustRegister(gaugeConntrackWorkloadFlows)
+	prometheus.MustRegister(gaugeVecConntrackEndpointFlows)
+	prometheus.MustRegister(gaugeVecConntrackTopEndpointFlows)
+}
+
+// endpointFlowCount is the number of conntrack entries that were attributed to an endpoint.
+type endpointFlowCount struct {
+	ID    proto.WorkloadEndpointID
+	Flows int
+}
+
+// The conntrack accounting manager periodically counts the conntrack entries for each local
+// workload endpoint.  It reports the count for every endpoint, and for the top N endpoints, via
+// Prometheus gauges, which makes it possible to spot a "noisy neighbour" that is using up the
+// node's conntrack table.
+//
+// An entry is attributed to an endpoint if the source of either direction of the flow is one of
+// the endpoint's IPs, so connections to a workload via a DNAT are counted as well as connections
+// from the workload.  A flow between two local workloads counts towards both.
+type conntrackAccountingManager struct {
+	ipVersions []uint8
+	topN       int
+
+	// Internal state.
+	samplePending bool
+	workloadIPs   map[proto.WorkloadEndpointID][]ip.Addr
+	ipToEndpoint  map[ip.Addr]proto.WorkloadEndpointID
+	flowCounts    map[proto.WorkloadEndpointID]int
+	topEndpoints  []endpointFlowCount
+	// reportedIDs holds the endpoints that we've set a per-endpoint gauge for, so that we can
+	// clean up the gauges of endpoints that have gone away.
+	reportedIDs map[proto.WorkloadEndpointID]bool
+
+	// Shims for testing.
+	listConntrack func(family netlink.InetFamily) ([]*netlink.ConntrackFlow, error)
+}
+
+func newConntrackAccountingManager(ipVersions []uint8, topN int) *conntrackAccountingManager {
+	return newConntrackAccountingManagerWithShims(ipVersions, topN,
+		func(family netlink.InetFamily) ([]*netlink.ConntrackFlow, error) {
+			return netlink.ConntrackTableList(netlink.ConntrackTable, family)
+		})
+}
+
+func newConntrackAccountingManagerWithShims(
+	ipVersions []uint8,
+	topN int,
+	listConntrack func(family netlink.InetFamily) ([]*netlink.ConntrackFlow, error),
+) *conntrackAccountingManager {
+	return &conntrackAccountingManager{
+		ipVersions:    ipVersions,
+		topN:          topN,
+		workloadIPs:   map[proto.WorkloadEndpointID][]ip.Addr{},
+		ipToEndpoint:  map[ip.Addr]proto.WorkloadEndpointID{},
+		flowCounts:    map[proto.WorkloadEndpointID]int{},
+		reportedIDs:   map[proto.WorkloadEndpointID]bool{},
+		listConntrack: listConntrack,
+	}
+}
+
+func (m *conntrackAccountingManager) OnUpdate(protoBufMsg interface{}) {
+	switch msg := protoBufMsg.(type) {
+	case *proto.WorkloadEndpointUpdate:
+		var addrs []ip.Addr
+		for _, nets := range [][]string{msg.Endpoint.Ipv4Nets, msg.Endpoint.Ipv6Nets} {
+			for _, s := range nets {
+				cidr, err := ip.ParseCIDROrIP(s)
+				if err != nil {
+					log.WithError(err).WithField("cidr", s).Warn("Ignoring unparsable workload IP.")
+					continue
+				}
+				addrs = append(addrs, cidr.Addr())
+			}
+		}
+		m.workloadIPs[*msg.Id] = addrs
+		m.recalculateIPIndex()
+	case *proto.WorkloadEndpointRemove:
+		delete(m.workloadIPs, *msg.Id)
+		m.recalculateIPIndex()
+	}
+}
+
+func (m *conntrackAccountingManager) recalculateIPIndex() {
+	m.ipToEndpoint = map[ip.Addr]proto.WorkloadEndpointID{}
+	for id, addrs := range m.workloadIPs {
+		for _, a := range addrs {
+			m.ipToEndpoint[a] = id
+		}
+	}
+}
+
+// QueueSample asks the manager to count the conntrack entries on the next call to
+// CompleteDeferredWork.
+func (m *conntrackAccountingManager) QueueSample() {
+	m.samplePending = true
+}
+
+// FlowCount returns the number of conntrack entries for the given endpoint as of the last sample.
+func (m *conntrackAccountingManager) FlowCount(id proto.WorkloadEndpointID) int {
+	return m.flowCounts[id]
+}
+
+// TopEndpoints returns the (up to) N endpoints with the most conntrack entries as of the last
+// sample, busiest first.
+func (m *conntrackAccountingManager) TopEndpoints() []endpointFlowCount {
+	return m.topEndpoints
+}
+
+func (m *conntrackAccountingManager) CompleteDeferredWork() error {
+	if !m.samplePending {
+		return nil
+	}
+	m.samplePending = false
+
+	counts := map[proto.WorkloadEndpointID]int{}
+	total := 0
+	for _, v := range m.ipVersions {
+		family := netlink.InetFamily(netlink.FAMILY_V4)
+		if v == 6 {
+			family = netlink.FAMILY_V6
+		}
+		flows, err := m.listConntrack(family)
+		if err != nil {
+			// Not worth retrying early; we'll try again on the next sample.
+			log.WithError(err).WithField("ipVersion", v).Warn("Failed to list conntrack entries.")
+			return nil
+		}
+		for _, f := range flows {
+			fwdID, fwdOK := m.lookupEndpoint(f.Forward.SrcIP)
+			revID, revOK := m.lookupEndpoint(f.Reverse.SrcIP)
+			if fwdOK {
+				counts[fwdID]++
+			}
+			if revOK && (!fwdOK || revID != fwdID) {
+				counts[revID]++
+			}
+			if fwdOK || revOK {
+				total++
+			}
+		}
+	}
+
+	m.flowCounts = counts
+	m.updateGauges(total)
+	return nil
+}
+
+func (m *conntrackAccountingManager) updateGauges(total int) {
+	gaugeConntrackWorkloadFlows.Set(float64(total))
+
+	// Report every current endpoint, including those with no entries, and clean up the gauges of
+	// endpoints that have gone away.
+	for id := range m.reportedIDs {
+		if _, ok := m.workloadIPs[id]; !ok {
+			gaugeVecConntrackEndpointFlows.DeleteLabelValues(id.OrchestratorId, id.WorkloadId, id.EndpointId)
+			delete(m.reportedIDs, id)
+		}
+	}
+	var all []endpointFlowCount
+	for id := range m.workloadIPs {
+		gaugeVecConntrackEndpointFlows.WithLabelValues(id.OrchestratorId, id.WorkloadId, id.EndpointId).Set(
+			float64(m.flowCounts[id]))
+		m.reportedIDs[id] = true
+		if m.flowCounts[id] > 0 {
+			all = append(all, endpointFlowCount{ID: id, Flows: m.flowCounts[id]})
+		}
+	}
+
+	// Sort busiest first, breaking ties by ID so that the ranking is stable.
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Flows != all[j].Flows {
+			return all[i].Flows > all[j].Flows
+		}
+		return all[i].ID.String() < all[j].ID.String()
+	})
+	if len(all) > m.topN {
+		all = all[:m.topN]
+	}
+	m.topEndpoints = all
+
+	// The endpoint at each rank changes from sample to sample so we start afresh each time.
+	gaugeVecConntrackTopEndpointFlows.Reset()
+	for i, c := range all {
+		gaugeVecConntrackTopEndpointFlows.WithLabelValues(strconv.Itoa(i+1),
+			c.ID.OrchestratorId, c.ID.WorkloadId, c.ID.EndpointId).Set(float64(c.Flows))
+	}
+
+	if log.GetLevel() >= log.DebugLevel {
+		log.WithFields(log.Fields{
+			"total":        total,
+			"topEndpoints": all,
+		}).Debug("Sampled conntrack entries for local workloads.")
+	}
+}
+
+func (m *conntrackAccountingManager) lookupEndpoint(addr net.IP) (proto.WorkloadEndpointID, bool) {
+	if len(addr) == 0 {
+		return proto.WorkloadEndpointID{}, false
+	}
+	id, ok := m.ipToEndpoint[ip.FromNetIP(addr)]
+	return id, ok
+}
@@ -0,0 +1,151 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"errors"
+	"net"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vishvananda/netlink"
+
+	"github.com/projectcalico/felix/proto"
+)
+
+var _ = Describe("Conntrack accounting manager", func() {
+	var (
+		acctMgr      *conntrackAccountingManager
+		flows        []*netlink.ConntrackFlow
+		conntrackErr error
+	)
+
+	wlID1 := proto.WorkloadEndpointID{
+		OrchestratorId: "k8s",
+		WorkloadId:     "pod-11",
+		EndpointId:     "endpoint-id-11",
+	}
+	wlID2 := proto.WorkloadEndpointID{
+		OrchestratorId: "k8s",
+		WorkloadId:     "pod-12",
+		EndpointId:     "endpoint-id-12",
+	}
+	wlID3 := proto.WorkloadEndpointID{
+		OrchestratorId: "k8s",
+		WorkloadId:     "pod-13",
+		EndpointId:     "endpoint-id-13",
+	}
+
+	flow := func(src, dst, replySrc string) *netlink.ConntrackFlow {
+		f := &netlink.ConntrackFlow{}
+		f.Forward.SrcIP = net.ParseIP(src)
+		f.Forward.DstIP = net.ParseIP(dst)
+		f.Reverse.SrcIP = net.ParseIP(replySrc)
+		f.Reverse.DstIP = net.ParseIP(src)
+		return f
+	}
+
+	addWorkload := func(id proto.WorkloadEndpointID, ipv4Nets ...string) {
+		acctMgr.OnUpdate(&proto.WorkloadEndpointUpdate{
+			Id: &id,
+			Endpoint: &proto.WorkloadEndpoint{
+				Ipv4Nets: ipv4Nets,
+			},
+		})
+	}
+
+	sample := func() {
+		acctMgr.QueueSample()
+		Expect(acctMgr.CompleteDeferredWork()).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		conntrackErr = nil
+		acctMgr = newConntrackAccountingManagerWithShims([]uint8{4}, 2,
+			func(family netlink.InetFamily) ([]*netlink.ConntrackFlow, error) {
+				Expect(family).To(BeEquivalentTo(netlink.FAMILY_V4))
+				return flows, conntrackErr
+			})
+		addWorkload(wlID1, "10.0.240.10/32")
+		addWorkload(wlID2, "10.0.240.11/32", "10.0.240.12/32")
+		addWorkload(wlID3, "10.0.240.13/32")
+		flows = []*netlink.ConntrackFlow{
+			// Connection to workload 1 via a service IP.
+			flow("10.0.0.1", "10.96.0.10", "10.0.240.10"),
+			// Connection from workload 1.
+			flow("10.0.240.10", "8.8.8.8", "8.8.8.8"),
+			// Connection from workload 1 to workload 2.
+			flow("10.0.240.10", "10.0.240.11", "10.0.240.11"),
+			// Connections from both of workload 2's IPs.
+			flow("10.0.240.11", "8.8.8.8", "8.8.8.8"),
+			flow("10.0.240.12", "8.8.8.8", "8.8.8.8"),
+			// Hairpin connection from workload 2 to itself via a service IP.
+			flow("10.0.240.11", "10.96.0.11", "10.0.240.12"),
+			// Unrelated connection.
+			flow("10.0.0.1", "10.0.0.2", "10.0.0.2"),
+		}
+	})
+
+	It("should only sample when asked", func() {
+		Expect(acctMgr.CompleteDeferredWork()).To(Succeed())
+		Expect(acctMgr.FlowCount(wlID1)).To(Equal(0))
+		Expect(acctMgr.TopEndpoints()).To(BeEmpty())
+	})
+
+	Describe("after a sample", func() {
+		BeforeEach(sample)
+
+		It("should count the flows for each endpoint", func() {
+			Expect(acctMgr.FlowCount(wlID1)).To(Equal(3))
+			Expect(acctMgr.FlowCount(wlID2)).To(Equal(4))
+			Expect(acctMgr.FlowCount(wlID3)).To(Equal(0))
+		})
+
+		It("should rank the busiest endpoints", func() {
+			Expect(acctMgr.TopEndpoints()).To(Equal([]endpointFlowCount{
+				{ID: wlID2, Flows: 4},
+				{ID: wlID1, Flows: 3},
+			}))
+		})
+
+		It("should update the counts on the next sample", func() {
+			flows = flows[:2]
+			sample()
+			Expect(acctMgr.FlowCount(wlID1)).To(Equal(2))
+			Expect(acctMgr.FlowCount(wlID2)).To(Equal(0))
+			Expect(acctMgr.TopEndpoints()).To(Equal([]endpointFlowCount{
+				{ID: wlID1, Flows: 2},
+			}))
+		})
+
+		It("should stop counting flows for removed workloads", func() {
+			acctMgr.OnUpdate(&proto.WorkloadEndpointRemove{Id: &wlID2})
+			sample()
+			Expect(acctMgr.FlowCount(wlID1)).To(Equal(3))
+			Expect(acctMgr.FlowCount(wlID2)).To(Equal(0))
+			Expect(acctMgr.TopEndpoints()).To(Equal([]endpointFlowCount{
+				{ID: wlID1, Flows: 3},
+			}))
+		})
+
+		It("should keep the previous counts if conntrack can't be read", func() {
+			conntrackErr = errors.New("dummy error")
+			flows = nil
+			sample()
+			Expect(acctMgr.FlowCount(wlID1)).To(Equal(3))
+			Expect(acctMgr.FlowCount(wlID2)).To(Equal(4))
+		})
+	})
+})
@@ -0,0 +1,54 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// cpuBudgetSampleInterval is how often we measure our CPU usage against the CPU budget.
+const cpuBudgetSampleInterval = time.Second
+
+// paceWork runs the given non-urgent work (typically, queueing a refresh for the next apply) and
+// marks the dataplane as needing a sync.  If we're over the CPU budget, the work is put off
+// instead, until we're back under budget or until its next tick comes round too many times.
+func (d *InternalDataplane) paceWork(name string, work func()) {
+	if d.cpuBudget != nil {
+		if d.cpuBudget.ShouldDefer(name) {
+			d.deferredWork[name] = work
+			return
+		}
+		delete(d.deferredWork, name)
+	}
+	work()
+	d.dataplaneNeedsSync = true
+}
+
+// onCPUBudgetSample measures our CPU usage and, if we're now under budget, runs any work that
+// was put off.
+func (d *InternalDataplane) onCPUBudgetSample() {
+	d.cpuBudget.Sample()
+	if d.cpuBudget.OverBudget() || len(d.deferredWork) == 0 {
+		return
+	}
+	log.WithField("numDeferred", len(d.deferredWork)).Debug("Back under CPU budget, running deferred work.")
+	for name, work := range d.deferredWork {
+		work()
+		delete(d.deferredWork, name)
+	}
+	d.dataplaneNeedsSync = true
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/cpubudget"
+)
+
+var _ = Describe("CPU budget pacing", func() {
+	var (
+		d        *InternalDataplane
+		now      time.Time
+		cpuTime  time.Duration
+		numCalls int
+	)
+
+	work := func() { numCalls++ }
+
+	// sample advances the clock by a second, during which we used the given CPU time.
+	sample := func(used time.Duration) {
+		now = now.Add(time.Second)
+		cpuTime += used
+		d.onCPUBudgetSample()
+	}
+
+	BeforeEach(func() {
+		now = time.Now()
+		cpuTime = 0
+		numCalls = 0
+		d = &InternalDataplane{
+			cpuBudget: cpubudget.New(0.5,
+				func() (time.Duration, error) { return cpuTime, nil },
+				func() time.Time { return now },
+			),
+			deferredWork: map[string]func(){},
+		}
+	})
+
+	It("should run work straight away when under budget", func() {
+		sample(100 * time.Millisecond)
+		d.paceWork("refresh", work)
+		Expect(numCalls).To(Equal(1))
+		Expect(d.dataplaneNeedsSync).To(BeTrue())
+	})
+
+	It("should run work straight away with no budget", func() {
+		d = &InternalDataplane{}
+		d.paceWork("refresh", work)
+		Expect(numCalls).To(Equal(1))
+		Expect(d.dataplaneNeedsSync).To(BeTrue())
+	})
+
+	Describe("when over budget", func() {
+		BeforeEach(func() {
+			sample(900 * time.Millisecond)
+			d.paceWork("refresh", work)
+		})
+
+		It("should defer the work", func() {
+			Expect(numCalls).To(Equal(0))
+			Expect(d.dataplaneNeedsSync).To(BeFalse())
+		})
+
+		It("should run the deferred work once back under budget", func() {
+			sample(900 * time.Millisecond)
+			Expect(numCalls).To(Equal(0))
+			sample(100 * time.Millisecond)
+			Expect(numCalls).To(Equal(1))
+			Expect(d.dataplaneNeedsSync).To(BeTrue())
+			Expect(d.deferredWork).To(BeEmpty())
+		})
+
+		It("should stop deferring after the maximum number of deferrals", func() {
+			for i := 1; i < cpubudget.MaxConsecutiveDeferrals; i++ {
+				d.paceWork("refresh", work)
+			}
+			Expect(numCalls).To(Equal(0))
+			d.paceWork("refresh", work)
+			Expect(numCalls).To(Equal(1))
+			Expect(d.deferredWork).To(BeEmpty())
+		})
+	})
+})
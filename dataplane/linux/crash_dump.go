@@ -0,0 +1,202 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/bpf"
+)
+
+const (
+	// crashDumpPrefix is the prefix of the names of the files that we write crash dumps to.
+	crashDumpPrefix = "felix-crash-"
+	// maxCrashDumps is the number of crash dumps that we keep in the crash dump directory; older
+	// dumps are removed when we write a new one.
+	maxCrashDumps = 5
+	// crashDumpCmdTimeout bounds the time that we spend on each command, so that a wedged
+	// command can't stop us from exiting.
+	crashDumpCmdTimeout = 10 * time.Second
+)
+
+// crashDumper writes a snapshot of the dataplane state to a file when Felix panics or exits with
+// a fatal error so that one-off crashes in production carry enough context to diagnose them.
+type crashDumper struct {
+	dir      string
+	maxBytes int
+
+	// commands are the commands that we run to capture the dataplane state, in order.
+	commands [][]string
+	// bpfMemoryAccountant, if non-nil, provides a summary of the BPF maps.
+	bpfMemoryAccountant *bpf.MemoryAccountant
+
+	runCmd func(ctx context.Context, name string, args ...string) ([]byte, error)
+	now    func() time.Time
+
+	once sync.Once
+}
+
+// newCrashDumper returns a crashDumper that captures the state of the dataplane that the given
+// config describes.  iptablesSaveCmds are the iptables-save commands for each IP version in use.
+func newCrashDumper(config Config, iptablesSaveCmds []string, useNFTSets bool) *crashDumper {
+	var commands [][]string
+	for _, saveCmd := range iptablesSaveCmds {
+		commands = append(commands, []string{saveCmd, "-c"})
+	}
+	if config.NFTablesEnabled && !config.BPFEnabled {
+		// Our rules and sets are all in nftables.
+		commands = append(commands, []string{"nft", "list", "ruleset"})
+	} else if useNFTSets {
+		commands = append(commands, []string{"nft", "list", "sets"})
+	} else {
+		commands = append(commands, []string{"ipset", "list"})
+	}
+	commands = append(commands, []string{"ip", "-4", "route", "show", "table", "all"})
+	if config.IPv6Enabled {
+		commands = append(commands, []string{"ip", "-6", "route", "show", "table", "all"})
+	}
+	if config.BPFEnabled {
+		commands = append(commands, []string{"bpftool", "map", "show"})
+	}
+	return &crashDumper{
+		dir:                 config.CrashDumpDir,
+		maxBytes:            config.CrashDumpMaxBytes,
+		commands:            commands,
+		bpfMemoryAccountant: config.BPFMemoryAccountant,
+		runCmd:              runCrashDumpCmd,
+		now:                 time.Now,
+	}
+}
+
+func runCrashDumpCmd(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, name, args...).CombinedOutput()
+}
+
+// DumpOnPanic is intended to be deferred by the dataplane goroutines.  If the goroutine is
+// panicking, it writes a crash dump and then continues to panic.
+func (c *crashDumper) DumpOnPanic() {
+	if r := recover(); r != nil {
+		if c != nil {
+			c.Dump(fmt.Sprintf("panic: %v\n\n%s", r, debug.Stack()))
+		}
+		panic(r)
+	}
+}
+
+// Dump writes a crash dump, with the given reason at the top.  Only the first call has any effect
+// so that, for example, a fatal error that results from a panic doesn't write a second dump.
+func (c *crashDumper) Dump(reason string) {
+	c.once.Do(func() {
+		path, err := c.writeDump(reason)
+		if err != nil {
+			log.WithError(err).Error("Failed to write crash dump.")
+			return
+		}
+		log.WithField("file", path).Error("Wrote dataplane crash dump.")
+		c.removeOldDumps()
+	})
+}
+
+func (c *crashDumper) writeDump(reason string) (string, error) {
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return "", err
+	}
+	path := filepath.Join(c.dir, crashDumpPrefix+c.now().UTC().Format("20060102T150405.000Z")+".txt")
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	w := &limitedWriter{w: f, remaining: c.maxBytes}
+	writeSection(w, "Reason", []byte(reason))
+	for _, cmd := range c.commands {
+		ctx, cancel := context.WithTimeout(context.Background(), crashDumpCmdTimeout)
+		out, err := c.runCmd(ctx, cmd[0], cmd[1:]...)
+		cancel()
+		if err != nil {
+			out = append(out, fmt.Sprintf("\n(command failed: %v)\n", err)...)
+		}
+		writeSection(w, strings.Join(cmd, " "), out)
+	}
+	if c.bpfMemoryAccountant != nil {
+		usage, err := json.MarshalIndent(c.bpfMemoryAccountant.Usage(), "", "  ")
+		if err != nil {
+			usage = []byte(err.Error())
+		}
+		writeSection(w, "BPF memory estimates", usage)
+	}
+	if w.truncated {
+		_, _ = fmt.Fprintf(f, "\n(crash dump truncated at %d bytes)\n", c.maxBytes)
+	}
+	return path, nil
+}
+
+func writeSection(w io.Writer, title string, body []byte) {
+	_, _ = fmt.Fprintf(w, "=== %s ===\n", title)
+	_, _ = w.Write(body)
+	_, _ = fmt.Fprint(w, "\n")
+}
+
+// removeOldDumps removes all but the most recent maxCrashDumps crash dumps.
+func (c *crashDumper) removeOldDumps() {
+	dumps, err := filepath.Glob(filepath.Join(c.dir, crashDumpPrefix+"*.txt"))
+	if err != nil {
+		return
+	}
+	// The timestamps in the names sort chronologically.
+	sort.Strings(dumps)
+	for len(dumps) > maxCrashDumps {
+		if err := os.Remove(dumps[0]); err != nil {
+			log.WithError(err).WithField("file", dumps[0]).Warn("Failed to remove old crash dump.")
+		}
+		dumps = dumps[1:]
+	}
+}
+
+// limitedWriter writes to w until remaining bytes have been written and then silently discards
+// the rest.
+type limitedWriter struct {
+	w         io.Writer
+	remaining int
+	truncated bool
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if n > l.remaining {
+		p = p[:l.remaining]
+		l.truncated = true
+	}
+	written, err := l.w.Write(p)
+	l.remaining -= written
+	if err != nil {
+		return written, err
+	}
+	return n, nil
+}
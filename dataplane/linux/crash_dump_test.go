@@ -0,0 +1,143 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Crash dumper", func() {
+	var dir string
+	var dumper *crashDumper
+	var now time.Time
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "felix-crash-dump-test")
+		Expect(err).NotTo(HaveOccurred())
+		now = time.Date(2021, 3, 4, 5, 6, 7, 0, time.UTC)
+
+		dumper = newCrashDumper(Config{
+			CrashDumpDir:      dir,
+			CrashDumpMaxBytes: 4096,
+			BPFEnabled:        true,
+		}, []string{"iptables-legacy-save"}, false)
+		dumper.runCmd = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+			if name == "bpftool" {
+				return []byte("no bpftool"), errors.New("exit status 1")
+			}
+			return []byte("output of " + strings.Join(append([]string{name}, args...), " ")), nil
+		}
+		dumper.now = func() time.Time {
+			now = now.Add(time.Second)
+			return now
+		}
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(dir)
+	})
+
+	readDumps := func() []string {
+		files, err := filepath.Glob(filepath.Join(dir, crashDumpPrefix+"*.txt"))
+		Expect(err).NotTo(HaveOccurred())
+		var dumps []string
+		for _, f := range files {
+			data, err := ioutil.ReadFile(f)
+			Expect(err).NotTo(HaveOccurred())
+			dumps = append(dumps, string(data))
+		}
+		return dumps
+	}
+
+	It("captures the reason and the output of each command", func() {
+		dumper.Dump("something broke")
+
+		dumps := readDumps()
+		Expect(dumps).To(HaveLen(1))
+		Expect(dumps[0]).To(HavePrefix("=== Reason ===\nsomething broke\n"))
+		Expect(dumps[0]).To(ContainSubstring(
+			"=== iptables-legacy-save -c ===\noutput of iptables-legacy-save -c\n"))
+		Expect(dumps[0]).To(ContainSubstring("=== ipset list ===\n"))
+		Expect(dumps[0]).To(ContainSubstring("=== ip -4 route show table all ===\n"))
+		Expect(dumps[0]).NotTo(ContainSubstring("ip -6"))
+		Expect(dumps[0]).To(ContainSubstring("no bpftool\n(command failed: exit status 1)\n"))
+	})
+
+	It("captures the nftables ruleset in nftables mode", func() {
+		dumper = newCrashDumper(Config{NFTablesEnabled: true}, nil, true)
+		Expect(dumper.commands).To(ContainElement([]string{"nft", "list", "ruleset"}))
+		Expect(dumper.commands).NotTo(ContainElement([]string{"nft", "list", "sets"}))
+	})
+
+	It("only writes one dump", func() {
+		dumper.Dump("first")
+		dumper.Dump("second")
+
+		dumps := readDumps()
+		Expect(dumps).To(HaveLen(1))
+		Expect(dumps[0]).To(ContainSubstring("first"))
+	})
+
+	It("truncates large dumps", func() {
+		dumper.Dump(strings.Repeat("x", 10000))
+
+		dumps := readDumps()
+		Expect(dumps).To(HaveLen(1))
+		Expect(dumps[0]).To(HaveSuffix("\n(crash dump truncated at 4096 bytes)\n"))
+		Expect(len(dumps[0])).To(BeNumerically("<", 4200))
+	})
+
+	It("keeps only the most recent dumps", func() {
+		for i := 0; i < maxCrashDumps+2; i++ {
+			dumper.once = sync.Once{}
+			dumper.Dump(strconv.Itoa(i))
+		}
+
+		dumps := readDumps()
+		Expect(dumps).To(HaveLen(maxCrashDumps))
+		Expect(dumps[0]).To(HavePrefix("=== Reason ===\n2\n"))
+	})
+
+	It("dumps and re-panics on panic", func() {
+		Expect(func() {
+			defer dumper.DumpOnPanic()
+			panic("oops")
+		}).To(PanicWith("oops"))
+
+		dumps := readDumps()
+		Expect(dumps).To(HaveLen(1))
+		Expect(dumps[0]).To(HavePrefix("=== Reason ===\npanic: oops\n"))
+	})
+
+	It("re-panics without a dumper", func() {
+		var nilDumper *crashDumper
+		Expect(func() {
+			defer nilDumper.DumpOnPanic()
+			panic("oops")
+		}).To(PanicWith("oops"))
+	})
+})
@@ -0,0 +1,90 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/libcalico-go/lib/set"
+)
+
+var _ = Describe("Deferred startup", func() {
+	var (
+		d           *InternalDataplane
+		policyRT    *mockRouteTable
+		deferredRT  *mockRouteTable
+		releaseSlow chan struct{}
+		tasksRun    chan string
+	)
+
+	BeforeEach(func() {
+		d = &InternalDataplane{
+			config:              Config{DeferredStartupEnabled: true},
+			deferredManagers:    set.New(),
+			deferredStartupDone: make(chan struct{}, 1),
+		}
+		policyRT = &mockRouteTable{}
+		deferredRT = &mockRouteTable{}
+		d.RegisterManager(&routeTableManager{rts: []routeTableSyncer{policyRT}})
+		d.registerDeferredManager(&routeTableManager{rts: []routeTableSyncer{deferredRT}})
+
+		releaseSlow = make(chan struct{})
+		tasksRun = make(chan string, 2)
+		d.addStartupTask("quick", func() {
+			tasksRun <- "quick"
+		})
+		d.addStartupTask("slow", func() {
+			<-releaseSlow
+			tasksRun <- "slow"
+		})
+	})
+
+	It("should defer the startup tasks", func() {
+		Expect(d.deferredStartupTasks).To(HaveLen(2))
+		Expect(tasksRun).NotTo(Receive())
+	})
+
+	It("should only apply deferred managers' route tables once the tasks have finished", func() {
+		Expect(d.activeRouteTableSyncers()).To(ConsistOf(BeIdenticalTo(policyRT)))
+
+		go d.runDeferredStartupTasks(d.deferredStartupTasks)
+		Eventually(tasksRun).Should(Receive(Equal("quick")))
+		Consistently(d.deferredStartupDone).ShouldNot(Receive())
+
+		close(releaseSlow)
+		Eventually(tasksRun).Should(Receive(Equal("slow")))
+		Eventually(d.deferredStartupDone).Should(Receive())
+
+		// The main loop sets deferredStarted when it receives the signal.
+		d.deferredStarted = true
+		Expect(d.activeRouteTableSyncers()).To(ConsistOf(BeIdenticalTo(policyRT), BeIdenticalTo(deferredRT)))
+	})
+})
+
+// routeTableManager is a do-nothing manager that owns the given route tables.
+type routeTableManager struct {
+	rts []routeTableSyncer
+}
+
+func (m *routeTableManager) OnUpdate(interface{}) {}
+
+func (m *routeTableManager) CompleteDeferredWork() error {
+	return nil
+}
+
+func (m *routeTableManager) GetRouteTableSyncers() []routeTableSyncer {
+	return m.rts
+}
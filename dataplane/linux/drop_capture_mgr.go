@@ -0,0 +1,111 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package intdataplane
+
+import (
+	"fmt"
+
+	"github.com/projectcalico/felix/dropcapture"
+	"github.com/projectcalico/felix/proto"
+)
+
+// dropCaptureAnnotator is the interface provided by dropcapture.Ring.
+type dropCaptureAnnotator interface {
+	SetRuleAnnotation(ruleID, annotation string)
+	RemoveRuleAnnotation(ruleID string)
+	SetIfaceEndpoint(ifaceName string, ep dropcapture.Endpoint)
+	RemoveIfaceEndpoint(ifaceName string)
+}
+
+// The drop capture manager keeps the drop capture ring's rule annotations in sync with the active
+// policies and profiles so that captured packets can be traced back to the rule that dropped
+// them, and its interface annotations in sync with the local workloads so that they can be traced
+// back to the workloads involved.  The NFLOG rules themselves are rendered along with the policy.
+type dropCaptureManager struct {
+	annotator dropCaptureAnnotator
+
+	// ruleIDsByOwner maps from policy or profile ID to the rule IDs that we've annotated for it.
+	ruleIDsByOwner map[interface{}][]string
+	// ifaceByWorkload maps from workload endpoint ID to the interface that we've annotated for it.
+	ifaceByWorkload map[proto.WorkloadEndpointID]string
+}
+
+func newDropCaptureManager(annotator dropCaptureAnnotator) *dropCaptureManager {
+	return &dropCaptureManager{
+		annotator:       annotator,
+		ruleIDsByOwner:  map[interface{}][]string{},
+		ifaceByWorkload: map[proto.WorkloadEndpointID]string{},
+	}
+}
+
+func (m *dropCaptureManager) OnUpdate(msg interface{}) {
+	switch msg := msg.(type) {
+	case *proto.ActivePolicyUpdate:
+		name := fmt.Sprintf("policy %s/%s", msg.Id.Tier, msg.Id.Name)
+		m.updateOwner(*msg.Id, name, msg.Policy.InboundRules, msg.Policy.OutboundRules)
+	case *proto.ActivePolicyRemove:
+		m.removeOwner(*msg.Id)
+	case *proto.ActiveProfileUpdate:
+		name := fmt.Sprintf("profile %s", msg.Id.Name)
+		m.updateOwner(*msg.Id, name, msg.Profile.InboundRules, msg.Profile.OutboundRules)
+	case *proto.ActiveProfileRemove:
+		m.removeOwner(*msg.Id)
+	case *proto.WorkloadEndpointUpdate:
+		m.removeWorkload(*msg.Id)
+		m.annotator.SetIfaceEndpoint(msg.Endpoint.Name, dropcapture.Endpoint{
+			Workload:       msg.Id.WorkloadId,
+			Namespace:      msg.Endpoint.Namespace,
+			ServiceAccount: msg.Endpoint.ServiceAccount,
+		})
+		m.ifaceByWorkload[*msg.Id] = msg.Endpoint.Name
+	case *proto.WorkloadEndpointRemove:
+		m.removeWorkload(*msg.Id)
+	}
+}
+
+func (m *dropCaptureManager) removeWorkload(id proto.WorkloadEndpointID) {
+	if iface, ok := m.ifaceByWorkload[id]; ok {
+		m.annotator.RemoveIfaceEndpoint(iface)
+		delete(m.ifaceByWorkload, id)
+	}
+}
+
+func (m *dropCaptureManager) updateOwner(owner interface{}, name string, inbound, outbound []*proto.Rule) {
+	m.removeOwner(owner)
+	var ruleIDs []string
+	for _, dir := range []struct {
+		name  string
+		rules []*proto.Rule
+	}{{"inbound", inbound}, {"outbound", outbound}} {
+		for i, r := range dir.rules {
+			if r.RuleId == "" {
+				continue
+			}
+			m.annotator.SetRuleAnnotation(r.RuleId, fmt.Sprintf("%s %s rule %d", name, dir.name, i+1))
+			ruleIDs = append(ruleIDs, r.RuleId)
+		}
+	}
+	m.ruleIDsByOwner[owner] = ruleIDs
+}
+
+func (m *dropCaptureManager) removeOwner(owner interface{}) {
+	for _, id := range m.ruleIDsByOwner[owner] {
+		m.annotator.RemoveRuleAnnotation(id)
+	}
+	delete(m.ruleIDsByOwner, owner)
+}
+
+func (m *dropCaptureManager) CompleteDeferredWork() error {
+	return nil
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package intdataplane
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/dropcapture"
+	"github.com/projectcalico/felix/proto"
+)
+
+var _ = Describe("Drop capture manager", func() {
+	var (
+		ring *dropcapture.Ring
+		mgr  *dropCaptureManager
+	)
+
+	annotationFor := func(ruleID string) string {
+		ring.Add(dropcapture.Record{Reason: ruleID})
+		records := ring.Records()
+		return records[len(records)-1].Annotation
+	}
+
+	BeforeEach(func() {
+		ring = dropcapture.NewRing(10)
+		mgr = newDropCaptureManager(ring)
+		mgr.OnUpdate(&proto.ActivePolicyUpdate{
+			Id: &proto.PolicyID{Tier: "default", Name: "pol-1"},
+			Policy: &proto.Policy{
+				InboundRules:  []*proto.Rule{{Action: "allow", RuleId: "in-1"}, {Action: "deny", RuleId: "in-2"}},
+				OutboundRules: []*proto.Rule{{Action: "deny", RuleId: "out-1"}},
+			},
+		})
+		mgr.OnUpdate(&proto.ActiveProfileUpdate{
+			Id: &proto.ProfileID{Name: "prof-1"},
+			Profile: &proto.Profile{
+				InboundRules: []*proto.Rule{{Action: "deny", RuleId: "prof-in-1"}},
+			},
+		})
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+	})
+
+	It("should annotate policy and profile rules", func() {
+		Expect(annotationFor("in-2")).To(Equal("policy default/pol-1 inbound rule 2"))
+		Expect(annotationFor("out-1")).To(Equal("policy default/pol-1 outbound rule 1"))
+		Expect(annotationFor("prof-in-1")).To(Equal("profile prof-1 inbound rule 1"))
+	})
+
+	It("should remove annotations for rules that are removed from a policy", func() {
+		mgr.OnUpdate(&proto.ActivePolicyUpdate{
+			Id: &proto.PolicyID{Tier: "default", Name: "pol-1"},
+			Policy: &proto.Policy{
+				InboundRules: []*proto.Rule{{Action: "deny", RuleId: "in-2"}},
+			},
+		})
+		Expect(annotationFor("in-2")).To(Equal("policy default/pol-1 inbound rule 1"))
+		Expect(annotationFor("out-1")).To(BeEmpty())
+	})
+
+	It("should remove annotations when a policy or profile is removed", func() {
+		mgr.OnUpdate(&proto.ActivePolicyRemove{Id: &proto.PolicyID{Tier: "default", Name: "pol-1"}})
+		mgr.OnUpdate(&proto.ActiveProfileRemove{Id: &proto.ProfileID{Name: "prof-1"}})
+		Expect(annotationFor("in-2")).To(BeEmpty())
+		Expect(annotationFor("prof-in-1")).To(BeEmpty())
+	})
+	It("should attach workload identities to the workloads' interfaces", func() {
+		id := proto.WorkloadEndpointID{OrchestratorId: "k8s", WorkloadId: "accounts/billing-1", EndpointId: "eth0"}
+		mgr.OnUpdate(&proto.WorkloadEndpointUpdate{
+			Id:       &id,
+			Endpoint: &proto.WorkloadEndpoint{Name: "cali1234", Namespace: "accounts", ServiceAccount: "billing"},
+		})
+		ring.Add(dropcapture.Record{InIface: "cali1234"})
+		records := ring.Records()
+		Expect(records[len(records)-1].InEndpoint).To(Equal(&dropcapture.Endpoint{
+			Workload:       "accounts/billing-1",
+			Namespace:      "accounts",
+			ServiceAccount: "billing",
+		}))
+
+		mgr.OnUpdate(&proto.WorkloadEndpointRemove{Id: &id})
+		ring.Add(dropcapture.Record{InIface: "cali1234"})
+		records = ring.Records()
+		Expect(records[len(records)-1].InEndpoint).To(BeNil())
+	})
+})
@@ -0,0 +1,137 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/ipsets"
+	"github.com/projectcalico/felix/iptables"
+	"github.com/projectcalico/felix/routetable"
+)
+
+// dryRunChange is one entry in the dry-run report: a change that we would have made to the
+// dataplane.  The report is a stream of these, one JSON object per line.
+type dryRunChange struct {
+	Time time.Time `json:"time"`
+	// Component is "iptables", "ipsets" or "routes".
+	Component string `json:"component"`
+	IPVersion uint8  `json:"ipVersion"`
+	// Table is the iptables table or the name of the route table.
+	Table string `json:"table,omitempty"`
+	// Op is the kind of route change ("route-add", "route-del", "arp-add" or "conntrack-flush").
+	Op string `json:"op,omitempty"`
+	// Lines holds the iptables-restore or ipset restore input, or the target of the route
+	// change.
+	Lines []string `json:"lines"`
+}
+
+// dryRunReport collects the changes that the iptables tables, IP sets and route tables would
+// have made to the dataplane if we weren't in dry-run mode.  A nil *dryRunReport means that
+// dry-run mode is disabled; its methods then leave the components unchanged.
+type dryRunReport struct {
+	lock    sync.Mutex
+	encoder *json.Encoder
+	timeNow func() time.Time
+}
+
+func openDryRunReport(fileName string) (*dryRunReport, error) {
+	f, err := os.Create(fileName)
+	if err != nil {
+		return nil, err
+	}
+	return newDryRunReport(f, time.Now), nil
+}
+
+func newDryRunReport(w io.Writer, timeNow func() time.Time) *dryRunReport {
+	return &dryRunReport{
+		encoder: json.NewEncoder(w),
+		timeNow: timeNow,
+	}
+}
+
+// record adds a change to the report.  It's called concurrently by the IPv4 and IPv6 tables.
+func (r *dryRunReport) record(change dryRunChange) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	change.Time = r.timeNow()
+	if err := r.encoder.Encode(&change); err != nil {
+		log.WithError(err).Warn("Failed to write to dry-run report.")
+	}
+}
+
+// iptablesOptions returns the options for the given iptables table, set up to report to us.
+func (r *dryRunReport) iptablesOptions(table string, ipVersion uint8, options iptables.TableOptions) iptables.TableOptions {
+	if r == nil {
+		return options
+	}
+	options.DryRun = func(restoreInput []byte) {
+		r.record(dryRunChange{
+			Component: "iptables",
+			IPVersion: ipVersion,
+			Table:     table,
+			Lines:     splitLines(restoreInput),
+		})
+	}
+	return options
+}
+
+// ipSets sets up the given IP sets to report to us.  Only needed for real IP sets; dry-run mode
+// isn't supported with nftables.
+func (r *dryRunReport) ipSets(ipVersion uint8, dataplane ipsetsDataplane) ipsetsDataplane {
+	if r == nil {
+		return dataplane
+	}
+	if s, ok := dataplane.(*ipsets.IPSets); ok {
+		s.EnableDryRun(func(commands []byte) {
+			r.record(dryRunChange{
+				Component: "ipsets",
+				IPVersion: ipVersion,
+				Lines:     splitLines(commands),
+			})
+		})
+	} else {
+		log.Warn("Dry-run mode not supported for nftables sets, they will be programmed.")
+	}
+	return dataplane
+}
+
+// routeTable sets up the given route table to report to us.
+func (r *dryRunReport) routeTable(name string, ipVersion uint8, rt *routetable.RouteTable) *routetable.RouteTable {
+	if r == nil {
+		return rt
+	}
+	rt.EnableDryRun(func(op, target string) {
+		r.record(dryRunChange{
+			Component: "routes",
+			IPVersion: ipVersion,
+			Table:     name,
+			Op:        op,
+			Lines:     []string{target},
+		})
+	})
+	return rt
+}
+
+func splitLines(input []byte) []string {
+	return strings.Split(strings.TrimRight(string(input), "\n"), "\n")
+}
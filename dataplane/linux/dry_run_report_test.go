@@ -0,0 +1,70 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/iptables"
+)
+
+var _ = Describe("Dry-run report", func() {
+	var (
+		buf    bytes.Buffer
+		report *dryRunReport
+	)
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	BeforeEach(func() {
+		buf.Reset()
+		report = newDryRunReport(&buf, func() time.Time { return now })
+	})
+
+	readChanges := func() []dryRunChange {
+		var changes []dryRunChange
+		dec := json.NewDecoder(&buf)
+		for dec.More() {
+			var c dryRunChange
+			Expect(dec.Decode(&c)).To(Succeed())
+			changes = append(changes, c)
+		}
+		return changes
+	}
+
+	It("should write the iptables updates as JSON lines", func() {
+		options := report.iptablesOptions("filter", 6, iptables.TableOptions{})
+		options.DryRun([]byte("*filter\n:cali-foo - -\nCOMMIT\n"))
+		Expect(buf.String()).To(HaveSuffix("}\n"))
+		Expect(readChanges()).To(Equal([]dryRunChange{{
+			Time:      now,
+			Component: "iptables",
+			IPVersion: 6,
+			Table:     "filter",
+			Lines:     []string{"*filter", ":cali-foo - -", "COMMIT"},
+		}}))
+	})
+
+	It("should leave the components alone when disabled", func() {
+		var disabled *dryRunReport
+		options := disabled.iptablesOptions("filter", 4, iptables.TableOptions{})
+		Expect(options.DryRun).To(BeNil())
+		Expect(disabled.routeTable("ipv4", 4, nil)).To(BeNil())
+	})
+})
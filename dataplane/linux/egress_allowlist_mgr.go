@@ -0,0 +1,118 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/ipsets"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/rules"
+)
+
+// The workload egress allowlist manager provides the hook that integrations, such as DNS policy
+// controllers, use to allow workloads to send to extra destinations without patching our chains.
+// For each local workload interface, the manager creates an egress allowlist IP set (see
+// rules.EgressAllowlistIPSetID()) with the configured size limit, and it deletes the IP set when
+// the workload goes away.  The integration owns the IP set's members; we never touch them, even
+// on resync.
+//
+// The manager also maintains a chain in the filter table with a rule per workload interface that
+// accepts traffic to the members of the interface's IP set.  The workload egress chains jump to
+// it before they drop traffic by default.
+type workloadEgressAllowlistManager struct {
+	// Our dependencies.
+	filterTable  iptablesTable
+	ipSets       ipsetsDataplane
+	ruleRenderer rules.RuleRenderer
+	ipVersion    uint8
+
+	// Config.
+	maxSize int
+
+	// Internal state.
+	ifaceNames map[proto.WorkloadEndpointID]string
+	dirty      bool
+}
+
+func newWorkloadEgressAllowlistManager(
+	filterTable iptablesTable,
+	ipSets ipsetsDataplane,
+	ruleRenderer rules.RuleRenderer,
+	maxSize int,
+	ipVersion uint8,
+) *workloadEgressAllowlistManager {
+	return &workloadEgressAllowlistManager{
+		filterTable:  filterTable,
+		ipSets:       ipSets,
+		ruleRenderer: ruleRenderer,
+		ipVersion:    ipVersion,
+		maxSize:      maxSize,
+		ifaceNames:   map[proto.WorkloadEndpointID]string{},
+		dirty:        true,
+	}
+}
+
+func (m *workloadEgressAllowlistManager) OnUpdate(protoBufMsg interface{}) {
+	switch msg := protoBufMsg.(type) {
+	case *proto.WorkloadEndpointUpdate:
+		oldName := m.ifaceNames[*msg.Id]
+		newName := msg.Endpoint.Name
+		if oldName == newName {
+			// Only (re)create the IP set when the interface changes; replacing it would wipe
+			// out the members that the integration has added.
+			return
+		}
+		if oldName != "" {
+			m.removeIPSet(oldName)
+		}
+		log.WithFields(log.Fields{
+			"workload": msg.Id,
+			"iface":    newName,
+		}).Debug("Creating workload egress allowlist IP set.")
+		m.ipSets.AddOrReplaceIPSet(ipsets.IPSetMetadata{
+			SetID:           rules.EgressAllowlistIPSetID(newName),
+			Type:            ipsets.IPSetTypeHashNet,
+			MaxSize:         m.maxSize,
+			ExternalMembers: true,
+		}, nil)
+		m.ifaceNames[*msg.Id] = newName
+		m.dirty = true
+	case *proto.WorkloadEndpointRemove:
+		if name, ok := m.ifaceNames[*msg.Id]; ok {
+			m.removeIPSet(name)
+			delete(m.ifaceNames, *msg.Id)
+			m.dirty = true
+		}
+	}
+}
+
+func (m *workloadEgressAllowlistManager) removeIPSet(ifaceName string) {
+	log.WithField("iface", ifaceName).Debug("Removing workload egress allowlist IP set.")
+	m.ipSets.RemoveIPSet(rules.EgressAllowlistIPSetID(ifaceName))
+}
+
+func (m *workloadEgressAllowlistManager) CompleteDeferredWork() error {
+	if !m.dirty {
+		return nil
+	}
+	var names []string
+	for _, name := range m.ifaceNames {
+		names = append(names, name)
+	}
+	m.filterTable.UpdateChain(m.ruleRenderer.WorkloadEgressAllowlistChain(m.ipVersion, names))
+	m.dirty = false
+	return nil
+}
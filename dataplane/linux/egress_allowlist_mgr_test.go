@@ -0,0 +1,138 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/ipsets"
+	"github.com/projectcalico/felix/iptables"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/rules"
+)
+
+var _ = Describe("Workload egress allowlist manager", func() {
+	var (
+		allowlistMgr *workloadEgressAllowlistManager
+		filterTable  *mockTable
+		ipSets       *mockIPSets
+		ruleRenderer rules.RuleRenderer
+	)
+
+	wlID1 := proto.WorkloadEndpointID{
+		OrchestratorId: "k8s",
+		WorkloadId:     "pod-11",
+		EndpointId:     "endpoint-id-11",
+	}
+	wlID2 := proto.WorkloadEndpointID{
+		OrchestratorId: "k8s",
+		WorkloadId:     "pod-12",
+		EndpointId:     "endpoint-id-12",
+	}
+
+	allowRule := func(iface string) iptables.Rule {
+		return iptables.Rule{
+			Match:  iptables.Match().InInterface(iface).DestIPSet("cali40ea:" + iface),
+			Action: iptables.SetMarkAction{Mark: 0x2},
+		}
+	}
+
+	addWorkload := func(id proto.WorkloadEndpointID, iface string) {
+		allowlistMgr.OnUpdate(&proto.WorkloadEndpointUpdate{
+			Id: &id,
+			Endpoint: &proto.WorkloadEndpoint{
+				Name:     iface,
+				Ipv4Nets: []string{"10.0.240.10/32"},
+			},
+		})
+	}
+
+	BeforeEach(func() {
+		filterTable = newMockTable("filter")
+		ipSets = newMockIPSets()
+		ruleRenderer = rules.NewRenderer(rules.Config{
+			IPSetConfigV4: ipsets.NewIPVersionConfig(
+				ipsets.IPFamilyV4,
+				"cali",
+				nil,
+				nil,
+			),
+			IptablesMarkPass:               0x1,
+			IptablesMarkAccept:             0x2,
+			IptablesMarkScratch0:           0x4,
+			IptablesMarkScratch1:           0x8,
+			IptablesMarkEndpoint:           0x11110000,
+			WorkloadEgressAllowlistEnabled: true,
+		})
+		allowlistMgr = newWorkloadEgressAllowlistManager(filterTable, ipSets, ruleRenderer, 500, 4)
+	})
+
+	It("should program an empty chain with no workloads", func() {
+		Expect(allowlistMgr.CompleteDeferredWork()).To(Succeed())
+		filterTable.checkChains([][]*iptables.Chain{{{
+			Name: rules.ChainWorkloadEgressAllowlist,
+		}}})
+	})
+
+	Describe("with workloads", func() {
+		BeforeEach(func() {
+			addWorkload(wlID1, "cali1")
+			addWorkload(wlID2, "cali2")
+			Expect(allowlistMgr.CompleteDeferredWork()).To(Succeed())
+		})
+
+		It("should create an externally-managed IP set per workload", func() {
+			Expect(ipSets.Metadata).To(Equal(map[string]ipsets.IPSetMetadata{
+				"ea:cali1": {SetID: "ea:cali1", Type: ipsets.IPSetTypeHashNet, MaxSize: 500, ExternalMembers: true},
+				"ea:cali2": {SetID: "ea:cali2", Type: ipsets.IPSetTypeHashNet, MaxSize: 500, ExternalMembers: true},
+			}))
+		})
+
+		It("should allow traffic to each workload's IP set", func() {
+			filterTable.checkChains([][]*iptables.Chain{{{
+				Name:  rules.ChainWorkloadEgressAllowlist,
+				Rules: []iptables.Rule{allowRule("cali1"), allowRule("cali2")},
+			}}})
+		})
+
+		It("should leave the IP set alone when the workload is updated", func() {
+			ipSets.AddOrReplaceCalled = false
+			addWorkload(wlID1, "cali1")
+			Expect(ipSets.AddOrReplaceCalled).To(BeFalse())
+		})
+
+		It("should replace the IP set when the workload's interface changes", func() {
+			addWorkload(wlID1, "cali3")
+			Expect(allowlistMgr.CompleteDeferredWork()).To(Succeed())
+			Expect(ipSets.Metadata).To(HaveKey("ea:cali3"))
+			Expect(ipSets.Metadata).NotTo(HaveKey("ea:cali1"))
+			filterTable.checkChains([][]*iptables.Chain{{{
+				Name:  rules.ChainWorkloadEgressAllowlist,
+				Rules: []iptables.Rule{allowRule("cali2"), allowRule("cali3")},
+			}}})
+		})
+
+		It("should remove the IP set and rule when the workload is removed", func() {
+			allowlistMgr.OnUpdate(&proto.WorkloadEndpointRemove{Id: &wlID1})
+			Expect(allowlistMgr.CompleteDeferredWork()).To(Succeed())
+			Expect(ipSets.Metadata).NotTo(HaveKey("ea:cali1"))
+			filterTable.checkChains([][]*iptables.Chain{{{
+				Name:  rules.ChainWorkloadEgressAllowlist,
+				Rules: []iptables.Rule{allowRule("cali2")},
+			}}})
+		})
+	})
+})
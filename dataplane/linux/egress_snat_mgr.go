@@ -0,0 +1,129 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package intdataplane
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/ip"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/rules"
+	"github.com/projectcalico/libcalico-go/lib/set"
+)
+
+// The egress SNAT manager gives workloads their own egress identity by SNATing their traffic to
+// a source address chosen from a pool of addresses on this node, rather than to the address
+// chosen by NAT outgoing.  A workload can request an address via a label (which the calculation
+// graph passes down as the endpoint's EgressSnatAddress) or inherit one from its namespace via
+// config.  Addresses that aren't in the pool are ignored.
+type egressSNATManager struct {
+	// Our dependencies.
+	natTable     iptablesTable
+	ruleRenderer rules.RuleRenderer
+
+	// Config.
+	pool           set.Set
+	namespaceAddrs map[string]string
+
+	// Internal state.
+	workloads map[proto.WorkloadEndpointID]*proto.WorkloadEndpoint
+	dirty     bool
+}
+
+func newEgressSNATManager(
+	natTable iptablesTable,
+	ruleRenderer rules.RuleRenderer,
+	poolCIDRs []string,
+	namespaceAddrs map[string]string,
+) *egressSNATManager {
+	pool := set.New()
+	for _, s := range poolCIDRs {
+		cidr, err := ip.ParseCIDROrIP(s)
+		if err != nil {
+			log.WithError(err).WithField("addr", s).Warn("Ignoring unparsable egress SNAT address.")
+			continue
+		}
+		pool.Add(cidr.Addr().String())
+	}
+	return &egressSNATManager{
+		natTable:       natTable,
+		ruleRenderer:   ruleRenderer,
+		pool:           pool,
+		namespaceAddrs: namespaceAddrs,
+		workloads:      map[proto.WorkloadEndpointID]*proto.WorkloadEndpoint{},
+		dirty:          true,
+	}
+}
+
+func (m *egressSNATManager) OnUpdate(protoBufMsg interface{}) {
+	switch msg := protoBufMsg.(type) {
+	case *proto.WorkloadEndpointUpdate:
+		m.workloads[*msg.Id] = msg.Endpoint
+		m.dirty = true
+	case *proto.WorkloadEndpointRemove:
+		if _, ok := m.workloads[*msg.Id]; ok {
+			delete(m.workloads, *msg.Id)
+			m.dirty = true
+		}
+	}
+}
+
+func (m *egressSNATManager) CompleteDeferredWork() error {
+	if !m.dirty {
+		return nil
+	}
+	snatAddrs := map[string]string{}
+	for id, ep := range m.workloads {
+		addr := ep.EgressSnatAddress
+		if addr == "" {
+			addr = m.namespaceAddrs[workloadNamespace(id)]
+		}
+		if addr == "" {
+			continue
+		}
+		if !m.pool.Contains(addr) {
+			log.WithFields(log.Fields{
+				"workload": id,
+				"addr":     addr,
+			}).Warn("Workload's egress SNAT address isn't in EgressSNATAddresses, ignoring it.")
+			continue
+		}
+		for _, s := range ep.Ipv4Nets {
+			cidr, err := ip.ParseCIDROrIP(s)
+			if err != nil {
+				log.WithError(err).WithField("cidr", s).Warn("Ignoring unparsable workload IP.")
+				continue
+			}
+			snatAddrs[cidr.Addr().String()] = addr
+		}
+	}
+	m.natTable.UpdateChain(m.ruleRenderer.EgressSNATChain(snatAddrs, 4))
+	m.dirty = false
+	return nil
+}
+
+// workloadNamespace returns the Kubernetes namespace of the given workload, or "" if it isn't a
+// Kubernetes workload.  Kubernetes workload IDs have the form "<namespace>/<pod name>".
+func workloadNamespace(id proto.WorkloadEndpointID) string {
+	if id.OrchestratorId != "k8s" {
+		return ""
+	}
+	parts := strings.SplitN(id.WorkloadId, "/", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+	return parts[0]
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package intdataplane
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/ipsets"
+	"github.com/projectcalico/felix/iptables"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/rules"
+)
+
+var _ = Describe("Egress SNAT manager", func() {
+	var (
+		mgr      *egressSNATManager
+		natTable *mockTable
+	)
+
+	wlID := func(ns, pod string) proto.WorkloadEndpointID {
+		return proto.WorkloadEndpointID{
+			OrchestratorId: "k8s",
+			WorkloadId:     ns + "/" + pod,
+			EndpointId:     "eth0",
+		}
+	}
+	addWorkload := func(id proto.WorkloadEndpointID, ipv4 string, snatAddr string) {
+		mgr.OnUpdate(&proto.WorkloadEndpointUpdate{
+			Id: &id,
+			Endpoint: &proto.WorkloadEndpoint{
+				Ipv4Nets:          []string{ipv4 + "/32"},
+				EgressSnatAddress: snatAddr,
+			},
+		})
+	}
+	snatRule := func(src, addr string) iptables.Rule {
+		return iptables.Rule{
+			Match: iptables.Match().
+				SourceNet(src).
+				NotDestIPSet("cali40all-ipam-pools"),
+			Action: iptables.SNATAction{ToAddr: addr},
+		}
+	}
+
+	BeforeEach(func() {
+		natTable = newMockTable("nat")
+		ruleRenderer := rules.NewRenderer(rules.Config{
+			IPSetConfigV4: ipsets.NewIPVersionConfig(
+				ipsets.IPFamilyV4,
+				"cali",
+				nil,
+				nil,
+			),
+			IptablesMarkPass:     0x1,
+			IptablesMarkAccept:   0x2,
+			IptablesMarkScratch0: 0x4,
+			IptablesMarkScratch1: 0x8,
+			IptablesMarkEndpoint: 0x11110000,
+			EgressSNATEnabled:    true,
+		})
+		mgr = newEgressSNATManager(natTable, ruleRenderer,
+			[]string{"192.168.0.10/32", "192.168.0.11/32"},
+			map[string]string{"tenant-a": "192.168.0.10", "tenant-c": "192.168.0.99"})
+	})
+
+	It("should program an empty chain at start of day", func() {
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		natTable.checkChains([][]*iptables.Chain{{{Name: rules.ChainNATEgressSNAT}}})
+	})
+
+	It("should SNAT workloads to their requested address or their namespace's", func() {
+		addWorkload(wlID("tenant-a", "pod-1"), "10.65.0.1", "")
+		addWorkload(wlID("tenant-a", "pod-2"), "10.65.0.2", "192.168.0.11")
+		addWorkload(wlID("tenant-b", "pod-3"), "10.65.0.3", "")
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		natTable.checkChains([][]*iptables.Chain{{{
+			Name: rules.ChainNATEgressSNAT,
+			Rules: []iptables.Rule{
+				snatRule("10.65.0.1", "192.168.0.10"),
+				snatRule("10.65.0.2", "192.168.0.11"),
+			},
+		}}})
+	})
+
+	It("should ignore addresses that aren't in the pool", func() {
+		addWorkload(wlID("tenant-b", "pod-1"), "10.65.0.1", "192.168.0.99")
+		addWorkload(wlID("tenant-c", "pod-2"), "10.65.0.2", "")
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		natTable.checkChains([][]*iptables.Chain{{{Name: rules.ChainNATEgressSNAT}}})
+	})
+
+	It("should remove the rule when the workload is removed", func() {
+		id := wlID("tenant-a", "pod-1")
+		addWorkload(id, "10.65.0.1", "")
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		mgr.OnUpdate(&proto.WorkloadEndpointRemove{Id: &id})
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		natTable.checkChains([][]*iptables.Chain{{{Name: rules.ChainNATEgressSNAT}}})
+	})
+})
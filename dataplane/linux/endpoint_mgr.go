@@ -25,6 +25,7 @@ import (
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/projectcalico/felix/ethtool"
 	"github.com/projectcalico/felix/ifacemonitor"
 	"github.com/projectcalico/felix/ip"
 	"github.com/projectcalico/felix/iptables"
@@ -135,6 +136,10 @@ type endpointManager struct {
 	ipVersion              uint8
 	wlIfacesRegexp         *regexp.Regexp
 	kubeIPVSSupportEnabled bool
+	// wlUntrackedPolicyEnabled enables programming of raw chains for workloads that have
+	// untracked policy.
+	wlUntrackedPolicyEnabled bool
+	wlIfaceSettings          WorkloadIfaceSettings
 
 	// Our dependencies.
 	rawTable     iptablesTable
@@ -144,6 +149,7 @@ type endpointManager struct {
 	routeTable   routeTable
 	writeProcSys procSysWriter
 	osStat       func(path string) (os.FileInfo, error)
+	txCsumOff    func(name string) error
 	epMarkMapper rules.EndpointMarkMapper
 
 	// Pending updates, cleared in CompleteDeferredWork as the data is copied to the activeXYZ
@@ -156,13 +162,25 @@ type endpointManager struct {
 	activeWlIfaceNameToID      map[string]proto.WorkloadEndpointID
 	activeUpIfaces             set.Set
 	activeWlIDToChains         map[proto.WorkloadEndpointID][]*iptables.Chain
+	activeWlIDToRawChains      map[proto.WorkloadEndpointID][]*iptables.Chain
 	activeWlDispatchChains     map[string]*iptables.Chain
+	activeWlRawDispatchChains  map[string]*iptables.Chain
 	activeEPMarkDispatchChains map[string]*iptables.Chain
 
 	// Workload endpoints that would be locally active but are 'shadowed' by other endpoints
 	// with the same interface name.
 	shadowedWlEndpoints map[proto.WorkloadEndpointID]*proto.WorkloadEndpoint
 
+	// activeWlIPToID records which workload endpoint owns each of the local workload IPs and
+	// activeWlIDToIPs is its inverse.  wlIDsWaitingForIPs contains the endpoints that are being
+	// held down because one of their IPs is still owned by another endpoint.  This happens when
+	// an IP is reused before the old endpoint has been cleaned up; we hold the new endpoint down
+	// until the old one is gone so that it can't briefly pick up the old endpoint's ipset
+	// memberships or conntrack entries.
+	activeWlIPToID     map[ip.CIDR]proto.WorkloadEndpointID
+	activeWlIDToIPs    map[proto.WorkloadEndpointID][]ip.CIDR
+	wlIDsWaitingForIPs set.Set
+
 	// wlIfaceNamesToReconfigure contains names of workload interfaces that need to have
 	// their configuration (sysctls etc.) refreshed.
 	wlIfaceNamesToReconfigure set.Set
@@ -207,6 +225,23 @@ type EndpointStatusUpdateCallback func(ipVersion uint8, id interface{}, status s
 
 type procSysWriter func(path, value string) error
 
+// WorkloadIfaceSettings says which settings of the host side of workload interfaces the endpoint
+// manager owns, on top of the ones that it always sets.  The CNI plugin sets these too; owning
+// them means that Felix puts them back if something else changes them.
+type WorkloadIfaceSettings struct {
+	// RPFilter is the value to write to the interface's rp_filter sysctl, or "" to leave the
+	// sysctl alone.
+	RPFilter string
+	// DisableAcceptRA turns off router advertisements on the interface.
+	DisableAcceptRA bool
+	// DisableTXChecksumOffload turns off TX checksum offload on the interface.
+	DisableTXChecksumOffload bool
+	// DisableTXChecksumOffloadIfBroken asks for DisableTXChecksumOffload to be set if the
+	// kernel's checksum offload is broken.  The dataplane resolves it before creating the
+	// endpoint managers.
+	DisableTXChecksumOffloadIfBroken bool
+}
+
 func newEndpointManager(
 	rawTable iptablesTable,
 	mangleTable iptablesTable,
@@ -216,7 +251,9 @@ func newEndpointManager(
 	ipVersion uint8,
 	epMarkMapper rules.EndpointMarkMapper,
 	kubeIPVSSupportEnabled bool,
+	wlUntrackedPolicyEnabled bool,
 	wlInterfacePrefixes []string,
+	wlIfaceSettings WorkloadIfaceSettings,
 	onWorkloadEndpointStatusUpdate EndpointStatusUpdateCallback,
 	bpfEnabled bool,
 	bpfEndpointManager hepListener,
@@ -231,10 +268,13 @@ func newEndpointManager(
 		ipVersion,
 		epMarkMapper,
 		kubeIPVSSupportEnabled,
+		wlUntrackedPolicyEnabled,
 		wlInterfacePrefixes,
+		wlIfaceSettings,
 		onWorkloadEndpointStatusUpdate,
 		writeProcSys,
 		os.Stat,
+		ethtool.EthtoolTXOff,
 		bpfEnabled,
 		bpfEndpointManager,
 		callbacks,
@@ -250,10 +290,13 @@ func newEndpointManagerWithShims(
 	ipVersion uint8,
 	epMarkMapper rules.EndpointMarkMapper,
 	kubeIPVSSupportEnabled bool,
+	wlUntrackedPolicyEnabled bool,
 	wlInterfacePrefixes []string,
+	wlIfaceSettings WorkloadIfaceSettings,
 	onWorkloadEndpointStatusUpdate EndpointStatusUpdateCallback,
 	procSysWriter procSysWriter,
 	osStat func(name string) (os.FileInfo, error),
+	txCsumOff func(name string) error,
 	bpfEnabled bool,
 	bpfEndpointManager hepListener,
 	callbacks *callbacks,
@@ -262,11 +305,13 @@ func newEndpointManagerWithShims(
 	wlIfacesRegexp := regexp.MustCompile(wlIfacesPattern)
 
 	return &endpointManager{
-		ipVersion:              ipVersion,
-		wlIfacesRegexp:         wlIfacesRegexp,
-		kubeIPVSSupportEnabled: kubeIPVSSupportEnabled,
-		bpfEnabled:             bpfEnabled,
-		bpfEndpointManager:     bpfEndpointManager,
+		ipVersion:                ipVersion,
+		wlIfacesRegexp:           wlIfacesRegexp,
+		kubeIPVSSupportEnabled:   kubeIPVSSupportEnabled,
+		wlUntrackedPolicyEnabled: wlUntrackedPolicyEnabled,
+		wlIfaceSettings:          wlIfaceSettings,
+		bpfEnabled:               bpfEnabled,
+		bpfEndpointManager:       bpfEndpointManager,
 
 		rawTable:     rawTable,
 		mangleTable:  mangleTable,
@@ -275,6 +320,7 @@ func newEndpointManagerWithShims(
 		routeTable:   routeTable,
 		writeProcSys: procSysWriter,
 		osStat:       osStat,
+		txCsumOff:    txCsumOff,
 		epMarkMapper: epMarkMapper,
 
 		// Pending updates, we store these up as OnUpdate is called, then process them
@@ -287,9 +333,14 @@ func newEndpointManagerWithShims(
 		activeWlEndpoints:     map[proto.WorkloadEndpointID]*proto.WorkloadEndpoint{},
 		activeWlIfaceNameToID: map[string]proto.WorkloadEndpointID{},
 		activeWlIDToChains:    map[proto.WorkloadEndpointID][]*iptables.Chain{},
+		activeWlIDToRawChains: map[proto.WorkloadEndpointID][]*iptables.Chain{},
 
 		shadowedWlEndpoints: map[proto.WorkloadEndpointID]*proto.WorkloadEndpoint{},
 
+		activeWlIPToID:     map[ip.CIDR]proto.WorkloadEndpointID{},
+		activeWlIDToIPs:    map[proto.WorkloadEndpointID][]ip.CIDR{},
+		wlIDsWaitingForIPs: set.New(),
+
 		wlIfaceNamesToReconfigure: set.New(),
 
 		epIDsToUpdateStatus: set.New(),
@@ -306,6 +357,7 @@ func newEndpointManagerWithShims(
 		// Caches of the current dispatch chains indexed by chain name.  We use these to
 		// calculate deltas when we need to update the chains.
 		activeWlDispatchChains:         map[string]*iptables.Chain{},
+		activeWlRawDispatchChains:      map[string]*iptables.Chain{},
 		activeHostFilterDispatchChains: map[string]*iptables.Chain{},
 		activeHostMangleDispatchChains: map[string]*iptables.Chain{},
 		activeHostRawDispatchChains:    map[string]*iptables.Chain{},
@@ -446,12 +498,13 @@ func (m *endpointManager) updateEndpointStatuses() {
 func (m *endpointManager) calculateWorkloadEndpointStatus(id proto.WorkloadEndpointID) string {
 	logCxt := log.WithField("workloadEndpointID", id)
 	logCxt.Debug("Re-evaluating workload endpoint status")
-	var operUp, adminUp, failed bool
+	var operUp, adminUp, failed, waiting bool
 	workload, known := m.activeWlEndpoints[id]
 	if known {
 		adminUp = workload.State == "active"
 		operUp = m.activeUpIfaces.Contains(workload.Name)
 		failed = m.wlIfaceNamesToReconfigure.Contains(workload.Name)
+		waiting = m.wlIDsWaitingForIPs.Contains(id)
 	}
 
 	// Note: if endpoint is not known (i.e. has been deleted), status will be "", which signals
@@ -460,10 +513,14 @@ func (m *endpointManager) calculateWorkloadEndpointStatus(id proto.WorkloadEndpo
 	if known {
 		if failed {
 			status = "error"
-		} else if operUp && adminUp {
-			status = "up"
-		} else {
+		} else if !operUp || !adminUp {
 			status = "down"
+		} else if waiting {
+			// Up as far as we're concerned but held down until its IPs are released by
+			// another endpoint.
+			status = "waiting"
+		} else {
+			status = "up"
 		}
 	}
 	logCxt = logCxt.WithFields(log.Fields{
@@ -471,6 +528,7 @@ func (m *endpointManager) calculateWorkloadEndpointStatus(id proto.WorkloadEndpo
 		"failed":  failed,
 		"operUp":  operUp,
 		"adminUp": adminUp,
+		"waiting": waiting,
 		"status":  status,
 	})
 	logCxt.Info("Re-evaluated workload endpoint status")
@@ -537,6 +595,8 @@ func (m *endpointManager) resolveWorkloadEndpoints() {
 		m.callbacks.InvokeRemoveWorkload(oldWorkload)
 		m.filterTable.RemoveChains(m.activeWlIDToChains[id])
 		delete(m.activeWlIDToChains, id)
+		m.rawTable.RemoveChains(m.activeWlIDToRawChains[id])
+		delete(m.activeWlIDToRawChains, id)
 		if oldWorkload != nil {
 			m.epMarkMapper.ReleaseEndpointMark(oldWorkload.Name)
 			// Remove any routes from the routing table.  The RouteTable will remove any
@@ -547,6 +607,8 @@ func (m *endpointManager) resolveWorkloadEndpoints() {
 			delete(m.activeWlIfaceNameToID, oldWorkload.Name)
 		}
 		delete(m.activeWlEndpoints, id)
+		m.wlIDsWaitingForIPs.Discard(id)
+		m.releaseWorkloadIPs(logCxt, id, nil)
 	}
 
 	// Repeat the following loop until the pending update map is empty.  Note that it's possible
@@ -558,6 +620,12 @@ func (m *endpointManager) resolveWorkloadEndpoints() {
 			logCxt := log.WithField("id", id)
 			oldWorkload := m.activeWlEndpoints[id]
 			if workload != nil {
+				if workload.Namespace != "" || workload.ServiceAccount != "" {
+					logCxt = logCxt.WithFields(log.Fields{
+						"namespace":      workload.Namespace,
+						"serviceAccount": workload.ServiceAccount,
+					})
+				}
 				// Check if there is already an active workload endpoint with the same
 				// interface name.
 				if existingId, ok := m.activeWlIfaceNameToID[workload.Name]; ok && existingId != id {
@@ -586,6 +654,8 @@ func (m *endpointManager) resolveWorkloadEndpoints() {
 					m.epMarkMapper.ReleaseEndpointMark(oldWorkload.Name)
 					if !m.bpfEnabled {
 						m.filterTable.RemoveChains(m.activeWlIDToChains[id])
+						m.rawTable.RemoveChains(m.activeWlIDToRawChains[id])
+						delete(m.activeWlIDToRawChains, id)
 					}
 					m.routeTable.SetRoutes(oldWorkload.Name, nil)
 					m.wlIfaceNamesToReconfigure.Discard(oldWorkload.Name)
@@ -597,21 +667,8 @@ func (m *endpointManager) resolveWorkloadEndpoints() {
 					egressPolicyNames = workload.Tiers[0].EgressPolicies
 				}
 				adminUp := workload.State == "active"
-				if !m.bpfEnabled {
-					chains := m.ruleRenderer.WorkloadEndpointToIptablesChains(
-						workload.Name,
-						m.epMarkMapper,
-						adminUp,
-						ingressPolicyNames,
-						egressPolicyNames,
-						workload.ProfileIds,
-					)
-					m.filterTable.UpdateChains(chains)
-					m.activeWlIDToChains[id] = chains
-				}
 
 				// Collect the IP prefixes that we want to route locally to this endpoint:
-				logCxt.Info("Updating endpoint routes.")
 				var (
 					ipStrings  []string
 					natInfos   []*proto.NatInfo
@@ -634,7 +691,61 @@ func (m *endpointManager) resolveWorkloadEndpoints() {
 						ipStrings[len(old)+ii] = natInfo.ExtIp + addrSuffix
 					}
 				}
+				cidrs := make([]ip.CIDR, len(ipStrings))
+				for ii, s := range ipStrings {
+					cidrs[ii] = ip.MustParseCIDROrIP(s)
+				}
+
+				// If any of the IPs are still owned by another endpoint then the IP is being
+				// reused before the old endpoint has been cleaned up.  Hold this endpoint down
+				// until the old one goes away; it'll be requeued at that point.
+				if owner, ok := m.findWorkloadIPConflict(id, cidrs); ok {
+					if !m.wlIDsWaitingForIPs.Contains(id) {
+						logCxt.WithField("ownerID", owner).Info(
+							"Endpoint IP still in use by another endpoint, holding endpoint down.")
+						m.wlIDsWaitingForIPs.Add(id)
+					}
+					m.releaseWorkloadIPs(logCxt, id, nil)
+					adminUp = false
+				} else {
+					m.wlIDsWaitingForIPs.Discard(id)
+					m.releaseWorkloadIPs(logCxt, id, cidrs)
+					for _, cidr := range cidrs {
+						m.activeWlIPToID[cidr] = id
+					}
+					m.activeWlIDToIPs[id] = cidrs
+				}
 
+				if !m.bpfEnabled {
+					untrackedIngressPolicyNames, untrackedEgressPolicyNames := m.untrackedPolicyNames(logCxt, workload, adminUp)
+					chains := m.ruleRenderer.WorkloadEndpointToIptablesChains(
+						workload.Name,
+						m.epMarkMapper,
+						adminUp,
+						ingressPolicyNames,
+						egressPolicyNames,
+						workload.ProfileIds,
+						untrackedIngressPolicyNames,
+						untrackedEgressPolicyNames,
+					)
+					m.filterTable.UpdateChains(chains)
+					m.activeWlIDToChains[id] = chains
+
+					if len(untrackedIngressPolicyNames) > 0 || len(untrackedEgressPolicyNames) > 0 {
+						rawChains := m.ruleRenderer.WorkloadEndpointToRawChains(
+							workload.Name,
+							untrackedIngressPolicyNames,
+							untrackedEgressPolicyNames,
+						)
+						m.rawTable.UpdateChains(rawChains)
+						m.activeWlIDToRawChains[id] = rawChains
+					} else if rawChains, ok := m.activeWlIDToRawChains[id]; ok {
+						m.rawTable.RemoveChains(rawChains)
+						delete(m.activeWlIDToRawChains, id)
+					}
+				}
+
+				logCxt.Info("Updating endpoint routes.")
 				var mac net.HardwareAddr
 				if workload.Mac != "" {
 					var err error
@@ -647,9 +758,9 @@ func (m *endpointManager) resolveWorkloadEndpoints() {
 				var routeTargets []routetable.Target
 				if adminUp {
 					logCxt.Debug("Endpoint up, adding routes")
-					for _, s := range ipStrings {
+					for _, cidr := range cidrs {
 						routeTargets = append(routeTargets, routetable.Target{
-							CIDR:    ip.MustParseCIDROrIP(s),
+							CIDR:    cidr,
 							DestMAC: mac,
 						})
 					}
@@ -698,6 +809,14 @@ func (m *endpointManager) resolveWorkloadEndpoints() {
 		// Rewrite the dispatch chains if they've changed.
 		newDispatchChains := m.ruleRenderer.WorkloadDispatchChains(m.activeWlEndpoints)
 		m.updateDispatchChains(m.activeWlDispatchChains, newDispatchChains, m.filterTable)
+		if m.wlUntrackedPolicyEnabled {
+			untrackedEndpoints := map[proto.WorkloadEndpointID]*proto.WorkloadEndpoint{}
+			for id := range m.activeWlIDToRawChains {
+				untrackedEndpoints[id] = m.activeWlEndpoints[id]
+			}
+			newRawDispatchChains := m.ruleRenderer.WorkloadRawDispatchChains(untrackedEndpoints, m.ipVersion)
+			m.updateDispatchChains(m.activeWlRawDispatchChains, newRawDispatchChains, m.rawTable)
+		}
 		m.needToCheckDispatchChains = false
 
 		// Set flag to update endpoint mark chains.
@@ -720,6 +839,80 @@ func (m *endpointManager) resolveWorkloadEndpoints() {
 	})
 }
 
+// findWorkloadIPConflict checks whether any of the given IPs are owned by a workload endpoint other
+// than the one with the given ID.  If so, it returns the ID of the owner.
+func (m *endpointManager) findWorkloadIPConflict(
+	id proto.WorkloadEndpointID,
+	cidrs []ip.CIDR,
+) (proto.WorkloadEndpointID, bool) {
+	for _, cidr := range cidrs {
+		if owner, ok := m.activeWlIPToID[cidr]; ok && owner != id {
+			return owner, true
+		}
+	}
+	return proto.WorkloadEndpointID{}, false
+}
+
+// releaseWorkloadIPs releases the IPs owned by the given workload endpoint, apart from those in
+// keep.  If any IPs are released, endpoints that are waiting for an IP are requeued so that they
+// get re-evaluated in the same batch; that way, the old endpoint's routes (and hence conntrack
+// entries) are removed in the same apply as the new endpoint's are added.
+func (m *endpointManager) releaseWorkloadIPs(
+	logCxt *log.Entry,
+	id proto.WorkloadEndpointID,
+	keep []ip.CIDR,
+) {
+	released := false
+	for _, cidr := range m.activeWlIDToIPs[id] {
+		kept := false
+		for _, k := range keep {
+			if k == cidr {
+				kept = true
+				break
+			}
+		}
+		if !kept && m.activeWlIPToID[cidr] == id {
+			delete(m.activeWlIPToID, cidr)
+			released = true
+		}
+	}
+	delete(m.activeWlIDToIPs, id)
+	if !released {
+		return
+	}
+	m.wlIDsWaitingForIPs.Iter(func(item interface{}) error {
+		waitingID := item.(proto.WorkloadEndpointID)
+		if _, ok := m.pendingWlEpUpdates[waitingID]; !ok {
+			logCxt.WithField("waitingID", waitingID).Info(
+				"Endpoint IP released, re-evaluating endpoint that was waiting for it.")
+			m.pendingWlEpUpdates[waitingID] = m.activeWlEndpoints[waitingID]
+		}
+		return nil
+	})
+}
+
+// untrackedPolicyNames returns the untracked policies that should be applied to the given
+// workload, or nil if untracked policy can't be applied to it.
+func (m *endpointManager) untrackedPolicyNames(
+	logCxt *log.Entry,
+	workload *proto.WorkloadEndpoint,
+	adminUp bool,
+) (ingress, egress []string) {
+	if !m.wlUntrackedPolicyEnabled || !adminUp || len(workload.UntrackedTiers) == 0 {
+		return nil, nil
+	}
+	natInfos := workload.Ipv4Nat
+	if m.ipVersion == 6 {
+		natInfos = workload.Ipv6Nat
+	}
+	if len(natInfos) > 0 {
+		// Floating IPs are implemented with DNAT, which relies on conntrack.
+		logCxt.Warn("Workload has floating IPs, ignoring its untracked policy.")
+		return nil, nil
+	}
+	return workload.UntrackedTiers[0].IngressPolicies, workload.UntrackedTiers[0].EgressPolicies
+}
+
 func wlIdsAscending(id1, id2 *proto.WorkloadEndpointID) bool {
 	if id1.OrchestratorId == id2.OrchestratorId {
 		// Need to compare WorkloadId.
@@ -1131,14 +1324,24 @@ func (m *endpointManager) configureInterface(name string) error {
 	}
 
 	// Special case: for security, even if our IPv6 support is disabled, try to disable RAs on the interface.
-	acceptRAPath := fmt.Sprintf("/proc/sys/net/ipv6/conf/%s/accept_ra", name)
-	err := m.writeProcSys(acceptRAPath, "0")
-	if err != nil {
-		if exists, err := m.interfaceExistsInProcSys(name); err == nil && !exists {
-			log.WithField("file", acceptRAPath).Debug(
-				"Failed to set accept_ra flag. Interface is missing in /proc/sys.")
-		} else {
-			log.WithField("ifaceName", name).Warnf("Could not set accept_ra: %v", err)
+	if m.wlIfaceSettings.DisableAcceptRA {
+		acceptRAPath := fmt.Sprintf("/proc/sys/net/ipv6/conf/%s/accept_ra", name)
+		err := m.writeProcSys(acceptRAPath, "0")
+		if err != nil {
+			if exists, err := m.interfaceExistsInProcSys(name); err == nil && !exists {
+				log.WithField("file", acceptRAPath).Debug(
+					"Failed to set accept_ra flag. Interface is missing in /proc/sys.")
+			} else {
+				log.WithField("ifaceName", name).Warnf("Could not set accept_ra: %v", err)
+			}
+		}
+	}
+
+	if m.wlIfaceSettings.DisableTXChecksumOffload {
+		// Some kernels compute the wrong checksum for VXLAN packets that leave through an
+		// interface with TX checksum offload.
+		if err := m.txCsumOff(name); err != nil {
+			return fmt.Errorf("failed to disable checksum offload: %w", err)
 		}
 	}
 
@@ -1184,6 +1387,13 @@ func (m *endpointManager) configureInterface(name string) error {
 		if err != nil {
 			return err
 		}
+		// Reverse path filtering has no IPv6 equivalent sysctl.
+		if m.wlIfaceSettings.RPFilter != "" {
+			err = m.writeProcSys(fmt.Sprintf("/proc/sys/net/ipv4/conf/%s/rp_filter", name), m.wlIfaceSettings.RPFilter)
+			if err != nil {
+				return err
+			}
+		}
 	} else {
 		// Enable proxy NDP, similarly to proxy ARP, described above.
 		err := m.writeProcSys(fmt.Sprintf("/proc/sys/net/ipv6/conf/%s/proxy_ndp", name), "1")
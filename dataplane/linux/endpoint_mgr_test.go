@@ -648,6 +648,8 @@ func endpointManagerTests(ipVersion uint8) func() {
 			mockProcSys     *testProcSys
 			statusReportRec *statusReportRecorder
 			hepListener     *testHEPListener
+			wlIfaceSettings WorkloadIfaceSettings
+			txCsumOffIfaces []string
 		)
 
 		BeforeEach(func() {
@@ -675,6 +677,8 @@ func endpointManagerTests(ipVersion uint8) func() {
 			loAddrs.Add("::1")
 			eth1Addrs = set.New()
 			eth1Addrs.Add(ipv4Eth1)
+			wlIfaceSettings = WorkloadIfaceSettings{DisableAcceptRA: true}
+			txCsumOffIfaces = nil
 		})
 
 		JustBeforeEach(func() {
@@ -697,10 +701,16 @@ func endpointManagerTests(ipVersion uint8) func() {
 				ipVersion,
 				rules.NewEndpointMarkMapper(rrConfigNormal.IptablesMarkEndpoint, rrConfigNormal.IptablesMarkNonCaliEndpoint),
 				rrConfigNormal.KubeIPVSSupportEnabled,
+				rrConfigNormal.WorkloadUntrackedPolicyEnabled,
 				[]string{"cali"},
+				wlIfaceSettings,
 				statusReportRec.endpointStatusUpdateCallback,
 				mockProcSys.write,
 				mockProcSys.stat,
+				func(name string) error {
+					txCsumOffIfaces = append(txCsumOffIfaces, name)
+					return nil
+				},
 				false,
 				hepListener,
 				newCallbacks(),
@@ -1477,23 +1487,25 @@ func endpointManagerTests(ipVersion uint8) func() {
 					WorkloadId:     "pod-11",
 					EndpointId:     "endpoint-id-11",
 				}
-				var tiers []*proto.TierInfo
+				var tiers, untrackedTiers []*proto.TierInfo
 
 				BeforeEach(func() {
 					tiers = []*proto.TierInfo{}
+					untrackedTiers = nil
 				})
 
 				JustBeforeEach(func() {
 					epMgr.OnUpdate(&proto.WorkloadEndpointUpdate{
 						Id: &wlEPID1,
 						Endpoint: &proto.WorkloadEndpoint{
-							State:      "active",
-							Mac:        "01:02:03:04:05:06",
-							Name:       "cali12345-ab",
-							ProfileIds: []string{},
-							Tiers:      tiers,
-							Ipv4Nets:   []string{"10.0.240.2/24"},
-							Ipv6Nets:   []string{"2001:db8:2::2/128"},
+							State:          "active",
+							Mac:            "01:02:03:04:05:06",
+							Name:           "cali12345-ab",
+							ProfileIds:     []string{},
+							Tiers:          tiers,
+							UntrackedTiers: untrackedTiers,
+							Ipv4Nets:       []string{"10.0.240.2/24"},
+							Ipv6Nets:       []string{"2001:db8:2::2/128"},
 						},
 					})
 					err := epMgr.ResolveUpdateBatch()
@@ -1660,6 +1672,84 @@ func endpointManagerTests(ipVersion uint8) func() {
 					It("should have expected chains", expectWlChainsFor("cali12345-ab_policy1_egress"))
 				})
 
+				Context("with untracked policy", func() {
+					BeforeEach(func() {
+						untrackedTiers = []*proto.TierInfo{{
+							Name:            "default",
+							IngressPolicies: []string{"policy1"},
+							EgressPolicies:  []string{"policy1"},
+						}}
+					})
+
+					It("should ignore the untracked policy by default", func() {
+						Expect(rawTable.currentChains).NotTo(HaveKey("cali-fw-cali12345-ab"))
+						Expect(rawTable.currentChains).NotTo(HaveKey("cali-from-wl-dispatch"))
+					})
+
+					Context("with workload untracked policy enabled", func() {
+						BeforeEach(func() {
+							rrConfigNormal.WorkloadUntrackedPolicyEnabled = true
+						})
+
+						It("should program the raw chains", func() {
+							renderer := rules.NewRenderer(rrConfigNormal)
+							for _, chain := range renderer.WorkloadEndpointToRawChains(
+								"cali12345-ab", []string{"policy1"}, []string{"policy1"},
+							) {
+								Expect(rawTable.currentChains[chain.Name]).To(Equal(chain))
+							}
+							Expect(rawTable.currentChains["cali-from-wl-dispatch"].Rules).To(Equal([]iptables.Rule{{
+								Match:  iptables.Match().InInterface("cali12345-ab"),
+								Action: iptables.GotoAction{Target: "cali-fw-cali12345-ab"},
+							}}))
+							dest := "10.0.240.2/24"
+							if ipVersion == 6 {
+								dest = "2001:db8:2::2/128"
+							}
+							Expect(rawTable.currentChains["cali-to-wl-dispatch"].Rules).To(Equal([]iptables.Rule{{
+								Match:  iptables.Match().DestNet(dest),
+								Action: iptables.GotoAction{Target: "cali-tw-cali12345-ab"},
+							}}))
+						})
+
+						It("should re-check the untracked policy in the filter chains", func() {
+							Expect(filterTable.currentChains["cali-tw-cali12345-ab"].Rules).To(ContainElement(iptables.Rule{
+								Match:  iptables.Match().ConntrackState("UNTRACKED").MarkClear(0x10),
+								Action: iptables.JumpAction{Target: "cali-pi-policy1"},
+							}))
+						})
+
+						Context("after the untracked policy is removed", func() {
+							JustBeforeEach(func() {
+								epMgr.OnUpdate(&proto.WorkloadEndpointUpdate{
+									Id: &wlEPID1,
+									Endpoint: &proto.WorkloadEndpoint{
+										State:      "active",
+										Mac:        "01:02:03:04:05:06",
+										Name:       "cali12345-ab",
+										ProfileIds: []string{},
+										Tiers:      tiers,
+										Ipv4Nets:   []string{"10.0.240.2/24"},
+										Ipv6Nets:   []string{"2001:db8:2::2/128"},
+									},
+								})
+								err := epMgr.ResolveUpdateBatch()
+								Expect(err).ToNot(HaveOccurred())
+								err = epMgr.CompleteDeferredWork()
+								Expect(err).ToNot(HaveOccurred())
+							})
+
+							It("should remove the raw chains", func() {
+								Expect(rawTable.currentChains).NotTo(HaveKey("cali-tw-cali12345-ab"))
+								Expect(rawTable.currentChains).NotTo(HaveKey("cali-fw-cali12345-ab"))
+								Expect(rawTable.currentChains["cali-from-wl-dispatch"].Rules).To(BeEmpty())
+								Expect(rawTable.currentChains["cali-to-wl-dispatch"].Rules).To(BeEmpty())
+							})
+							It("should have expected chains", expectWlChainsFor("cali12345-ab"))
+						})
+					})
+				})
+
 				It("should have expected chains", expectWlChainsFor("cali12345-ab"))
 
 				It("should set routes", func() {
@@ -1743,6 +1833,58 @@ func endpointManagerTests(ipVersion uint8) func() {
 								"/proc/sys/net/ipv4/neigh/cali12345-ab/proxy_delay":   "0",
 							})
 						}
+						Expect(txCsumOffIfaces).To(BeEmpty())
+					})
+
+					Context("with Felix owning rp_filter and TX checksum offload but not accept_ra", func() {
+						BeforeEach(func() {
+							wlIfaceSettings = WorkloadIfaceSettings{
+								RPFilter:                 "2",
+								DisableTXChecksumOffload: true,
+							}
+						})
+
+						It("should write the owned settings", func() {
+							if ipVersion == 6 {
+								mockProcSys.checkState(map[string]string{
+									"/proc/sys/net/ipv6/conf/cali12345-ab/proxy_ndp":  "1",
+									"/proc/sys/net/ipv6/conf/cali12345-ab/forwarding": "1",
+								})
+							} else {
+								mockProcSys.checkState(map[string]string{
+									"/proc/sys/net/ipv4/conf/cali12345-ab/forwarding":     "1",
+									"/proc/sys/net/ipv4/conf/cali12345-ab/route_localnet": "1",
+									"/proc/sys/net/ipv4/conf/cali12345-ab/proxy_arp":      "1",
+									"/proc/sys/net/ipv4/neigh/cali12345-ab/proxy_delay":   "0",
+									"/proc/sys/net/ipv4/conf/cali12345-ab/rp_filter":      "2",
+								})
+							}
+							Expect(txCsumOffIfaces).To(Equal([]string{"cali12345-ab"}))
+						})
+
+						It("should reapply the settings when the endpoint is updated", func() {
+							mockProcSys.state["/proc/sys/net/ipv4/conf/cali12345-ab/rp_filter"] = "1"
+							epMgr.OnUpdate(&proto.WorkloadEndpointUpdate{
+								Id: &wlEPID1,
+								Endpoint: &proto.WorkloadEndpoint{
+									State:      "active",
+									Mac:        "01:02:03:04:05:06",
+									Name:       "cali12345-ab",
+									ProfileIds: []string{},
+									Tiers:      []*proto.TierInfo{},
+									Ipv4Nets:   []string{"10.0.240.2/24"},
+									Ipv6Nets:   []string{"2001:db8:2::2/128"},
+								},
+							})
+							Expect(epMgr.ResolveUpdateBatch()).To(Succeed())
+							Expect(epMgr.CompleteDeferredWork()).To(Succeed())
+
+							if ipVersion == 4 {
+								Expect(mockProcSys.state).To(HaveKeyWithValue(
+									"/proc/sys/net/ipv4/conf/cali12345-ab/rp_filter", "2"))
+							}
+							Expect(txCsumOffIfaces).To(Equal([]string{"cali12345-ab", "cali12345-ab"}))
+						})
 					})
 
 					Context("with floating IPs added to the endpoint", func() {
@@ -1831,6 +1973,77 @@ func endpointManagerTests(ipVersion uint8) func() {
 						})
 					})
 
+					Context("with a new endpoint reusing the endpoint's IPs", func() {
+						wlEPID2 := proto.WorkloadEndpointID{
+							OrchestratorId: "k8s",
+							WorkloadId:     "pod-12",
+							EndpointId:     "endpoint-id-12",
+						}
+
+						JustBeforeEach(func() {
+							epMgr.OnUpdate(&ifaceUpdate{
+								Name:  "cali23456-cd",
+								State: "up",
+							})
+							epMgr.OnUpdate(&proto.WorkloadEndpointUpdate{
+								Id: &wlEPID2,
+								Endpoint: &proto.WorkloadEndpoint{
+									State:      "active",
+									Mac:        "01:02:03:04:05:07",
+									Name:       "cali23456-cd",
+									ProfileIds: []string{},
+									Tiers:      []*proto.TierInfo{},
+									Ipv4Nets:   []string{"10.0.240.2/24"},
+									Ipv6Nets:   []string{"2001:db8:2::2/128"},
+								},
+							})
+							err := epMgr.ResolveUpdateBatch()
+							Expect(err).ToNot(HaveOccurred())
+							err = epMgr.CompleteDeferredWork()
+							Expect(err).ToNot(HaveOccurred())
+						})
+
+						It("should hold the new endpoint down", func() {
+							routeTable.checkRoutes("cali23456-cd", nil)
+							Expect(statusReportRec.currentState).To(Equal(map[interface{}]string{
+								wlEPID1: "up",
+								wlEPID2: "waiting",
+							}))
+						})
+
+						Context("with the old endpoint removed", func() {
+							JustBeforeEach(func() {
+								epMgr.OnUpdate(&proto.WorkloadEndpointRemove{
+									Id: &wlEPID1,
+								})
+								err := epMgr.ResolveUpdateBatch()
+								Expect(err).ToNot(HaveOccurred())
+								err = epMgr.CompleteDeferredWork()
+								Expect(err).ToNot(HaveOccurred())
+							})
+
+							It("should move the routes to the new endpoint in the same batch", func() {
+								routeTable.checkRoutes("cali12345-ab", nil)
+								if ipVersion == 6 {
+									routeTable.checkRoutes("cali23456-cd", []routetable.Target{{
+										CIDR:    ip.MustParseCIDROrIP("2001:db8:2::2/128"),
+										DestMAC: testutils.MustParseMAC("01:02:03:04:05:07"),
+									}})
+								} else {
+									routeTable.checkRoutes("cali23456-cd", []routetable.Target{{
+										CIDR:    ip.MustParseCIDROrIP("10.0.240.0/24"),
+										DestMAC: testutils.MustParseMAC("01:02:03:04:05:07"),
+									}})
+								}
+							})
+							It("should report the new endpoint up", func() {
+								Expect(statusReportRec.currentState).To(Equal(map[interface{}]string{
+									wlEPID2: "up",
+								}))
+							})
+						})
+					})
+
 					Context("changing the endpoint to another up interface", func() {
 						JustBeforeEach(func() {
 							epMgr.OnUpdate(&ifaceUpdate{
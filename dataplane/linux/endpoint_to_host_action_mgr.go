@@ -0,0 +1,105 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/rules"
+)
+
+// endpointToHostAction returns the action to apply to traffic from the given workload to its host
+// once policy has allowed it: the workload's override, if it has a valid one, otherwise
+// defaultAction.
+func endpointToHostAction(ep *proto.WorkloadEndpoint, defaultAction string) string {
+	if ep == nil || ep.EndpointToHostAction == "" {
+		return defaultAction
+	}
+	action := strings.ToUpper(ep.EndpointToHostAction)
+	switch action {
+	case "DROP", "RETURN", "ACCEPT":
+		return action
+	}
+	log.WithFields(log.Fields{
+		"workload": ep.Name,
+		"value":    ep.EndpointToHostAction,
+	}).Warn("Ignoring workload's invalid EndpointToHostAction override.")
+	return defaultAction
+}
+
+// The endpoint to host action manager maintains the filter chain that applies
+// DefaultEndpointToHostAction to workload to host traffic that policy has allowed.  Workloads can
+// override the action with the EndpointToHostActionLabel label; for example, to accept traffic
+// from a trusted logging agent while dropping traffic from other workloads.
+type endpointToHostActionManager struct {
+	// Our dependencies.
+	filterTable   iptablesTable
+	ruleRenderer  rules.RuleRenderer
+	defaultAction string
+
+	// Internal state.
+	workloads map[proto.WorkloadEndpointID]*proto.WorkloadEndpoint
+	dirty     bool
+}
+
+func newEndpointToHostActionManager(
+	filterTable iptablesTable,
+	ruleRenderer rules.RuleRenderer,
+	defaultAction string,
+) *endpointToHostActionManager {
+	return &endpointToHostActionManager{
+		filterTable:   filterTable,
+		ruleRenderer:  ruleRenderer,
+		defaultAction: defaultAction,
+		workloads:     map[proto.WorkloadEndpointID]*proto.WorkloadEndpoint{},
+		dirty:         true,
+	}
+}
+
+func (m *endpointToHostActionManager) OnUpdate(protoBufMsg interface{}) {
+	switch msg := protoBufMsg.(type) {
+	case *proto.WorkloadEndpointUpdate:
+		m.workloads[*msg.Id] = msg.Endpoint
+		m.dirty = true
+	case *proto.WorkloadEndpointRemove:
+		if _, ok := m.workloads[*msg.Id]; ok {
+			delete(m.workloads, *msg.Id)
+			m.dirty = true
+		}
+	}
+}
+
+func (m *endpointToHostActionManager) CompleteDeferredWork() error {
+	if !m.dirty {
+		return nil
+	}
+	var overrides []rules.WorkloadToHostAction
+	for _, ep := range m.workloads {
+		action := endpointToHostAction(ep, m.defaultAction)
+		if action == m.defaultAction {
+			continue
+		}
+		overrides = append(overrides, rules.WorkloadToHostAction{
+			IfaceName: ep.Name,
+			Action:    action,
+		})
+	}
+	m.filterTable.UpdateChain(m.ruleRenderer.WorkloadToHostActionChain(overrides))
+	m.dirty = false
+	return nil
+}
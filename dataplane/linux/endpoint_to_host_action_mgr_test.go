@@ -0,0 +1,117 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/ipsets"
+	"github.com/projectcalico/felix/iptables"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/rules"
+)
+
+var _ = Describe("Endpoint to host action manager", func() {
+	var (
+		actionMgr   *endpointToHostActionManager
+		filterTable *mockTable
+	)
+
+	wlID1 := proto.WorkloadEndpointID{
+		OrchestratorId: "k8s",
+		WorkloadId:     "pod-11",
+		EndpointId:     "endpoint-id-11",
+	}
+	wlID2 := proto.WorkloadEndpointID{
+		OrchestratorId: "k8s",
+		WorkloadId:     "pod-12",
+		EndpointId:     "endpoint-id-12",
+	}
+	wlID3 := proto.WorkloadEndpointID{
+		OrchestratorId: "k8s",
+		WorkloadId:     "pod-13",
+		EndpointId:     "endpoint-id-13",
+	}
+
+	addWorkload := func(id proto.WorkloadEndpointID, iface, action string) {
+		actionMgr.OnUpdate(&proto.WorkloadEndpointUpdate{
+			Id: &id,
+			Endpoint: &proto.WorkloadEndpoint{
+				Name:                 iface,
+				Ipv4Nets:             []string{"10.0.240.10/32"},
+				EndpointToHostAction: action,
+			},
+		})
+	}
+
+	defaultRule := iptables.Rule{
+		Action:  iptables.DropAction{},
+		Comment: []string{"Configured DefaultEndpointToHostAction"},
+	}
+
+	BeforeEach(func() {
+		filterTable = newMockTable("filter")
+		ruleRenderer := rules.NewRenderer(rules.Config{
+			IPSetConfigV4: ipsets.NewIPVersionConfig(
+				ipsets.IPFamilyV4,
+				"cali",
+				nil,
+				nil,
+			),
+			IptablesMarkPass:     0x1,
+			IptablesMarkAccept:   0x2,
+			IptablesMarkScratch0: 0x4,
+			IptablesMarkScratch1: 0x8,
+			IptablesMarkEndpoint: 0x11110000,
+			EndpointToHostAction: "DROP",
+		})
+		actionMgr = newEndpointToHostActionManager(filterTable, ruleRenderer, "DROP")
+	})
+
+	It("should program only the default action with no overrides", func() {
+		addWorkload(wlID1, "cali1", "")
+		Expect(actionMgr.CompleteDeferredWork()).To(Succeed())
+		filterTable.checkChains([][]*iptables.Chain{{{
+			Name:  rules.ChainWorkloadToHostAction,
+			Rules: []iptables.Rule{defaultRule},
+		}}})
+	})
+
+	It("should apply valid overrides ahead of the default", func() {
+		addWorkload(wlID1, "cali1", "accept")
+		addWorkload(wlID2, "cali2", "DROP")
+		addWorkload(wlID3, "cali3", "bogus")
+		Expect(actionMgr.CompleteDeferredWork()).To(Succeed())
+		filterTable.checkChains([][]*iptables.Chain{{{
+			Name: rules.ChainWorkloadToHostAction,
+			Rules: []iptables.Rule{
+				{
+					Match:   iptables.Match().InInterface("cali1"),
+					Action:  iptables.AcceptAction{},
+					Comment: []string{"Workload's EndpointToHostAction override"},
+				},
+				defaultRule,
+			},
+		}}})
+
+		actionMgr.OnUpdate(&proto.WorkloadEndpointRemove{Id: &wlID1})
+		Expect(actionMgr.CompleteDeferredWork()).To(Succeed())
+		filterTable.checkChains([][]*iptables.Chain{{{
+			Name:  rules.ChainWorkloadToHostAction,
+			Rules: []iptables.Rule{defaultRule},
+		}}})
+	})
+})
@@ -0,0 +1,130 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"reflect"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/config"
+	"github.com/projectcalico/felix/ipsets"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/rules"
+	"github.com/projectcalico/felix/stringutils"
+)
+
+// externalNetworkIPSets maps from each external network treatment to the IP set that holds the
+// CIDRs with that treatment.  The rules that implement the treatments are static; see
+// rules.Config.ExternalNetworksEnabled.
+var externalNetworkIPSets = map[string]string{
+	config.ExternalNetworkTreatmentTunnel:       rules.IPSetIDExternalNetsTunnel,
+	config.ExternalNetworkTreatmentRPFExempt:    rules.IPSetIDExternalNetsRPFExempt,
+	config.ExternalNetworkTreatmentNoMasquerade: rules.IPSetIDExternalNetsNoMasquerade,
+}
+
+// externalNetworksManager keeps the external network IP sets in sync with the ExternalNetworks and
+// ExternalNetworkTreatments config parameters.  Since the rules only refer to the IP sets, the
+// manager picks up changes to the parameters from ConfigUpdate messages and Felix doesn't need to
+// restart for them.
+type externalNetworksManager struct {
+	ipVersion       uint8
+	ipsetsDataplane ipsetsDataplane
+	maxIPSetSize    int
+
+	networks   map[string]string
+	treatments map[string]string
+	dirty      bool
+
+	logCxt *log.Entry
+}
+
+func newExternalNetworksManager(
+	ipsetsDataplane ipsetsDataplane,
+	maxIPSetSize int,
+	networks map[string]string,
+	treatments map[string]string,
+	ipVersion uint8,
+) *externalNetworksManager {
+	// Normalise so that an empty parameter compares equal to the parsed value from a ConfigUpdate.
+	if networks == nil {
+		networks = map[string]string{}
+	}
+	if treatments == nil {
+		treatments = map[string]string{}
+	}
+	return &externalNetworksManager{
+		ipVersion:       ipVersion,
+		ipsetsDataplane: ipsetsDataplane,
+		maxIPSetSize:    maxIPSetSize,
+		networks:        networks,
+		treatments:      treatments,
+		dirty:           true,
+		logCxt:          log.WithField("ipVersion", ipVersion),
+	}
+}
+
+func (m *externalNetworksManager) OnUpdate(msg interface{}) {
+	update, ok := msg.(*proto.ConfigUpdate)
+	if !ok {
+		return
+	}
+	networks := m.parseParam(update.Config, "ExternalNetworks")
+	treatments := m.parseParam(update.Config, "ExternalNetworkTreatments")
+	if !reflect.DeepEqual(networks, m.networks) || !reflect.DeepEqual(treatments, m.treatments) {
+		m.logCxt.WithFields(log.Fields{
+			"networks":   networks,
+			"treatments": treatments,
+		}).Info("External networks updated.")
+		m.networks = networks
+		m.treatments = treatments
+		m.dirty = true
+	}
+}
+
+func (m *externalNetworksManager) parseParam(rawConfig map[string]string, name string) map[string]string {
+	value, err := stringutils.ParseKeyValueList(rawConfig[name])
+	if err != nil {
+		m.logCxt.WithError(err).WithField("param", name).Warn("Ignoring unparsable external network config.")
+		return map[string]string{}
+	}
+	return value
+}
+
+func (m *externalNetworksManager) CompleteDeferredWork() error {
+	if !m.dirty {
+		return nil
+	}
+	cidrsByTreatment, err := config.ExternalNetworkCIDRsByTreatment(m.networks, m.treatments)
+	if err != nil {
+		m.logCxt.WithError(err).Warn("Ignoring invalid parts of external network config.")
+	}
+	for treatment, setID := range externalNetworkIPSets {
+		var members []string
+		for _, cidr := range cidrsByTreatment[treatment] {
+			if strings.Contains(cidr, ":") == (m.ipVersion == 6) {
+				members = append(members, cidr)
+			}
+		}
+		m.ipsetsDataplane.AddOrReplaceIPSet(ipsets.IPSetMetadata{
+			MaxSize: m.maxIPSetSize,
+			SetID:   setID,
+			Type:    ipsets.IPSetTypeHashNet,
+		}, members)
+	}
+	m.dirty = false
+	return nil
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/libcalico-go/lib/set"
+)
+
+var _ = Describe("External networks manager", func() {
+	var (
+		mgr    *externalNetworksManager
+		ipSets *mockIPSets
+	)
+
+	BeforeEach(func() {
+		ipSets = newMockIPSets()
+		mgr = newExternalNetworksManager(ipSets, 1024,
+			map[string]string{
+				"dc2":   "10.1.0.0/16;10.2.0.0/16;fd00:1::/64",
+				"peers": "192.168.0.0/24",
+			},
+			map[string]string{
+				"dc2":   "tunnel;no-masquerade",
+				"peers": "rpf-exempt",
+			},
+			4)
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+	})
+
+	It("should program the start-of-day networks", func() {
+		Expect(ipSets.Members).To(Equal(map[string]set.Set{
+			"extnets-tunnel": set.From("10.1.0.0/16", "10.2.0.0/16"),
+			"extnets-rpf":    set.From("192.168.0.0/24"),
+			"extnets-nomasq": set.From("10.1.0.0/16", "10.2.0.0/16"),
+		}))
+	})
+
+	It("should ignore a ConfigUpdate that doesn't change the networks", func() {
+		ipSets.AddOrReplaceCalled = false
+		mgr.OnUpdate(&proto.ConfigUpdate{Config: map[string]string{
+			"ExternalNetworks":          "dc2=10.1.0.0/16;10.2.0.0/16;fd00:1::/64,peers=192.168.0.0/24",
+			"ExternalNetworkTreatments": "dc2=tunnel;no-masquerade,peers=rpf-exempt",
+			"LogSeverityScreen":         "Debug",
+		}})
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(ipSets.AddOrReplaceCalled).To(BeFalse())
+	})
+
+	It("should apply a ConfigUpdate that changes the networks", func() {
+		mgr.OnUpdate(&proto.ConfigUpdate{Config: map[string]string{
+			"ExternalNetworks":          "dc2=10.1.0.0/16,dc3=10.3.0.0/16",
+			"ExternalNetworkTreatments": "dc2=tunnel,dc3=tunnel;rpf-exempt",
+		}})
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(ipSets.Members).To(Equal(map[string]set.Set{
+			"extnets-tunnel": set.From("10.1.0.0/16", "10.3.0.0/16"),
+			"extnets-rpf":    set.From("10.3.0.0/16"),
+			"extnets-nomasq": set.New(),
+		}))
+	})
+
+	It("should empty the IP sets when the networks are removed", func() {
+		mgr.OnUpdate(&proto.ConfigUpdate{Config: map[string]string{}})
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(ipSets.Members).To(Equal(map[string]set.Set{
+			"extnets-tunnel": set.New(),
+			"extnets-rpf":    set.New(),
+			"extnets-nomasq": set.New(),
+		}))
+	})
+
+	Describe("for IPv6", func() {
+		BeforeEach(func() {
+			ipSets = newMockIPSets()
+			mgr = newExternalNetworksManager(ipSets, 1024,
+				map[string]string{"dc2": "10.1.0.0/16;fd00:1::/64"},
+				map[string]string{"dc2": "tunnel"},
+				6)
+			Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		})
+
+		It("should only program IPv6 CIDRs", func() {
+			Expect(ipSets.Members["extnets-tunnel"]).To(Equal(set.From("fd00:1::/64")))
+		})
+	})
+})
@@ -0,0 +1,235 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+
+	"github.com/projectcalico/felix/bpf/conntrack"
+	"github.com/projectcalico/felix/flowlog"
+	"github.com/projectcalico/felix/ip"
+	"github.com/projectcalico/felix/proto"
+)
+
+// The flow log manager periodically samples the conntrack table, summarises the connections to and
+// from local workloads as flow log records, and writes them to the flow log sink.  Each flush
+// covers the traffic since the previous one.
+//
+// In BPF mode, the connections come from our BPF conntrack map, via a bpfConntrackFlowReader that
+// is plugged into the conntrack scanner, rather than from the kernel's conntrack table.
+type flowLogManager struct {
+	sink       flowlog.Sink
+	aggregator *flowlog.Aggregator
+
+	// Internal state.
+	flushPending bool
+	lastFlush    time.Time
+	workloadIPs  map[proto.WorkloadEndpointID][]ip.Addr
+	ipToEndpoint map[ip.Addr]*flowlog.Endpoint
+	endpoints    map[proto.WorkloadEndpointID]*flowlog.Endpoint
+
+	// Shims for testing.
+	listFlows func() ([]flowlog.Flow, error)
+	timeNow   func() time.Time
+}
+
+func newFlowLogManager(
+	sink flowlog.Sink,
+	listFlows func() ([]flowlog.Flow, error),
+	timeNow func() time.Time,
+) *flowLogManager {
+	m := &flowLogManager{
+		sink:         sink,
+		lastFlush:    timeNow(),
+		workloadIPs:  map[proto.WorkloadEndpointID][]ip.Addr{},
+		ipToEndpoint: map[ip.Addr]*flowlog.Endpoint{},
+		endpoints:    map[proto.WorkloadEndpointID]*flowlog.Endpoint{},
+		listFlows:    listFlows,
+		timeNow:      timeNow,
+	}
+	m.aggregator = flowlog.NewAggregator(m.lookupEndpoint)
+	return m
+}
+
+// linuxConntrackFlows returns a function that lists the flows in the kernel's conntrack table for
+// each of the given IP versions.
+func linuxConntrackFlows(
+	ipVersions []uint8,
+	listConntrack func(family netlink.InetFamily) ([]*netlink.ConntrackFlow, error),
+) func() ([]flowlog.Flow, error) {
+	return func() ([]flowlog.Flow, error) {
+		var flows []flowlog.Flow
+		for _, v := range ipVersions {
+			family := netlink.InetFamily(netlink.FAMILY_V4)
+			if v == 6 {
+				family = netlink.FAMILY_V6
+			}
+			ctFlows, err := listConntrack(family)
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range ctFlows {
+				// The source of the reply is the destination after any DNAT.
+				flows = append(flows, flowlog.Flow{
+					Proto:      f.Forward.Protocol,
+					SrcIP:      f.Forward.SrcIP,
+					SrcPort:    f.Forward.SrcPort,
+					DstIP:      f.Reverse.SrcIP,
+					DstPort:    f.Reverse.SrcPort,
+					PacketsOut: f.Forward.Packets,
+					BytesOut:   f.Forward.Bytes,
+					PacketsIn:  f.Reverse.Packets,
+					BytesIn:    f.Reverse.Bytes,
+				})
+			}
+		}
+		return flows, nil
+	}
+}
+
+func (m *flowLogManager) OnUpdate(protoBufMsg interface{}) {
+	switch msg := protoBufMsg.(type) {
+	case *proto.WorkloadEndpointUpdate:
+		var addrs []ip.Addr
+		for _, nets := range [][]string{msg.Endpoint.Ipv4Nets, msg.Endpoint.Ipv6Nets} {
+			for _, s := range nets {
+				cidr, err := ip.ParseCIDROrIP(s)
+				if err != nil {
+					log.WithError(err).WithField("cidr", s).Warn("Ignoring unparsable workload IP.")
+					continue
+				}
+				addrs = append(addrs, cidr.Addr())
+			}
+		}
+		m.workloadIPs[*msg.Id] = addrs
+		m.endpoints[*msg.Id] = &flowlog.Endpoint{
+			Workload:  msg.Id.WorkloadId,
+			Namespace: msg.Endpoint.Namespace,
+		}
+		m.recalculateIPIndex()
+	case *proto.WorkloadEndpointRemove:
+		delete(m.workloadIPs, *msg.Id)
+		delete(m.endpoints, *msg.Id)
+		m.recalculateIPIndex()
+	}
+}
+
+func (m *flowLogManager) recalculateIPIndex() {
+	m.ipToEndpoint = map[ip.Addr]*flowlog.Endpoint{}
+	for id, addrs := range m.workloadIPs {
+		for _, a := range addrs {
+			m.ipToEndpoint[a] = m.endpoints[id]
+		}
+	}
+}
+
+func (m *flowLogManager) lookupEndpoint(addr net.IP) *flowlog.Endpoint {
+	if len(addr) == 0 {
+		return nil
+	}
+	return m.ipToEndpoint[ip.FromNetIP(addr)]
+}
+
+// QueueFlush asks the manager to write the flow logs for the interval since the last flush on
+// the next call to CompleteDeferredWork.
+func (m *flowLogManager) QueueFlush() {
+	m.flushPending = true
+}
+
+func (m *flowLogManager) CompleteDeferredWork() error {
+	if !m.flushPending {
+		return nil
+	}
+	m.flushPending = false
+
+	flows, err := m.listFlows()
+	if err != nil {
+		// Not worth retrying early; the next flush will cover this interval too.
+		log.WithError(err).Warn("Failed to list conntrack entries for flow logs.")
+		return nil
+	}
+	now := m.timeNow()
+	records := m.aggregator.Aggregate(flows, m.lastFlush, now)
+	m.lastFlush = now
+	log.WithField("numRecords", len(records)).Debug("Writing flow logs.")
+	if err := m.sink.Write(records); err != nil {
+		log.WithError(err).Warn("Failed to write flow logs.")
+	}
+	return nil
+}
+
+// bpfConntrackFlowReader is a conntrack.EntryScannerSynced that collects the connections in our
+// BPF conntrack map each time the conntrack scanner iterates over it, for the flow log manager.
+type bpfConntrackFlowReader struct {
+	// pending is only accessed from the scanner's goroutine.
+	pending []flowlog.Flow
+
+	lock  sync.Mutex
+	flows []flowlog.Flow
+}
+
+func newBPFConntrackFlowReader() *bpfConntrackFlowReader {
+	return &bpfConntrackFlowReader{}
+}
+
+func (r *bpfConntrackFlowReader) IterationStart() {
+	r.pending = nil
+}
+
+func (r *bpfConntrackFlowReader) Check(
+	k conntrack.Key,
+	v conntrack.Value,
+	_ conntrack.EntryGet,
+) conntrack.ScanVerdict {
+	switch v.Type() {
+	case conntrack.TypeNormal, conntrack.TypeNATReverse:
+		// The NAT reverse entry is keyed on the connection to the backend; the NAT forward
+		// entry only refers to it.
+	default:
+		return conntrack.ScanVerdictOK
+	}
+	f := flowlog.Flow{
+		Proto:   k.Proto(),
+		SrcIP:   k.AddrA(),
+		SrcPort: k.PortA(),
+		DstIP:   k.AddrB(),
+		DstPort: k.PortB(),
+	}
+	if v.Data().B2A.Opener {
+		f.SrcIP, f.DstIP = f.DstIP, f.SrcIP
+		f.SrcPort, f.DstPort = f.DstPort, f.SrcPort
+	}
+	r.pending = append(r.pending, f)
+	return conntrack.ScanVerdictOK
+}
+
+func (r *bpfConntrackFlowReader) IterationEnd() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.flows = r.pending
+	r.pending = nil
+}
+
+// Flows returns the connections that were found by the last complete iteration.
+func (r *bpfConntrackFlowReader) Flows() ([]flowlog.Flow, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.flows, nil
+}
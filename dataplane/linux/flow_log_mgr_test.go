@@ -0,0 +1,205 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vishvananda/netlink"
+
+	"github.com/projectcalico/felix/bpf/conntrack"
+	"github.com/projectcalico/felix/flowlog"
+	"github.com/projectcalico/felix/proto"
+)
+
+type mockFlowLogSink struct {
+	batches [][]flowlog.Record
+}
+
+func (s *mockFlowLogSink) Write(records []flowlog.Record) error {
+	s.batches = append(s.batches, records)
+	return nil
+}
+
+var _ = Describe("Flow log manager", func() {
+	var (
+		flowLogMgr *flowLogManager
+		sink       *mockFlowLogSink
+		ctFlows    []*netlink.ConntrackFlow
+		ctErr      error
+		now        time.Time
+	)
+
+	wlID := proto.WorkloadEndpointID{
+		OrchestratorId: "k8s",
+		WorkloadId:     "default/pod-11",
+		EndpointId:     "endpoint-id-11",
+	}
+	pod := &flowlog.Endpoint{Workload: "default/pod-11", Namespace: "default"}
+
+	ctFlow := func(src string, srcPort uint16, dst string, dstPort uint16, replySrc string, replySrcPort uint16) *netlink.ConntrackFlow {
+		f := &netlink.ConntrackFlow{}
+		f.Forward.Protocol = 6
+		f.Forward.SrcIP = net.ParseIP(src)
+		f.Forward.SrcPort = srcPort
+		f.Forward.DstIP = net.ParseIP(dst)
+		f.Forward.DstPort = dstPort
+		f.Forward.Packets = 3
+		f.Forward.Bytes = 300
+		f.Reverse.Protocol = 6
+		f.Reverse.SrcIP = net.ParseIP(replySrc)
+		f.Reverse.SrcPort = replySrcPort
+		f.Reverse.DstIP = net.ParseIP(src)
+		f.Reverse.DstPort = srcPort
+		f.Reverse.Packets = 2
+		f.Reverse.Bytes = 200
+		return f
+	}
+
+	flush := func() {
+		flowLogMgr.QueueFlush()
+		Expect(flowLogMgr.CompleteDeferredWork()).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		sink = &mockFlowLogSink{}
+		ctFlows = nil
+		ctErr = nil
+		now = time.Unix(1000, 0)
+		listFlows := linuxConntrackFlows([]uint8{4}, func(family netlink.InetFamily) ([]*netlink.ConntrackFlow, error) {
+			Expect(family).To(BeEquivalentTo(netlink.FAMILY_V4))
+			return ctFlows, ctErr
+		})
+		flowLogMgr = newFlowLogManager(sink, listFlows, func() time.Time { return now })
+		flowLogMgr.OnUpdate(&proto.WorkloadEndpointUpdate{
+			Id: &wlID,
+			Endpoint: &proto.WorkloadEndpoint{
+				Namespace: "default",
+				Ipv4Nets:  []string{"10.65.0.1/32"},
+			},
+		})
+	})
+
+	It("should do nothing until a flush is queued", func() {
+		Expect(flowLogMgr.CompleteDeferredWork()).To(Succeed())
+		Expect(sink.batches).To(BeEmpty())
+	})
+
+	It("should write a record for a workload's connection, using the post-DNAT destination", func() {
+		ctFlows = []*netlink.ConntrackFlow{
+			ctFlow("10.65.0.1", 40000, "10.96.0.10", 53, "10.65.1.5", 5353),
+			ctFlow("10.0.0.1", 40000, "10.0.0.2", 80, "10.0.0.2", 80),
+		}
+		now = now.Add(time.Minute)
+		flush()
+		Expect(sink.batches).To(Equal([][]flowlog.Record{{{
+			StartTime:   time.Unix(1000, 0),
+			EndTime:     time.Unix(1060, 0),
+			Proto:       "tcp",
+			SrcEndpoint: pod,
+			DstIP:       "10.65.1.5",
+			DstPort:     5353,
+			Action:      flowlog.ActionAllow,
+			NumFlows:    1,
+			PacketsOut:  3,
+			BytesOut:    300,
+			PacketsIn:   2,
+			BytesIn:     200,
+		}}}))
+	})
+
+	It("should start each interval where the last one ended", func() {
+		now = now.Add(time.Minute)
+		flush()
+		ctFlows = []*netlink.ConntrackFlow{ctFlow("10.0.0.1", 40000, "10.65.0.1", 80, "10.65.0.1", 80)}
+		now = now.Add(time.Minute)
+		flush()
+		Expect(sink.batches).To(HaveLen(2))
+		Expect(sink.batches[1]).To(HaveLen(1))
+		Expect(sink.batches[1][0].StartTime).To(Equal(time.Unix(1060, 0)))
+		Expect(sink.batches[1][0].DstEndpoint).To(Equal(pod))
+	})
+
+	It("should stop attributing flows to a removed workload", func() {
+		flowLogMgr.OnUpdate(&proto.WorkloadEndpointRemove{Id: &wlID})
+		ctFlows = []*netlink.ConntrackFlow{ctFlow("10.65.0.1", 40000, "10.0.0.2", 80, "10.0.0.2", 80)}
+		flush()
+		Expect(sink.batches).To(Equal([][]flowlog.Record{{}}))
+	})
+
+	It("should skip the flush if conntrack can't be listed", func() {
+		ctErr = errors.New("dummy error")
+		flush()
+		Expect(sink.batches).To(BeEmpty())
+	})
+})
+
+var _ = Describe("BPF conntrack flow reader", func() {
+	var reader *bpfConntrackFlowReader
+
+	clientIP := net.ParseIP("10.65.0.1").To4()
+	serverIP := net.ParseIP("10.65.0.2").To4()
+
+	BeforeEach(func() {
+		reader = newBPFConntrackFlowReader()
+	})
+
+	scan := func(entries map[conntrack.Key]conntrack.Value) {
+		reader.IterationStart()
+		for k, v := range entries {
+			Expect(reader.Check(k, v, nil)).To(Equal(conntrack.ScanVerdictOK))
+		}
+		reader.IterationEnd()
+	}
+
+	It("should orient each flow from the side that opened it", func() {
+		// The key puts the lower IP first, so the server is leg A here.
+		scan(map[conntrack.Key]conntrack.Value{
+			conntrack.NewKey(6, clientIP, 40000, serverIP, 80): conntrack.NewValueNormal(0, 0, 0,
+				conntrack.Leg{Opener: true}, conntrack.Leg{}),
+			conntrack.NewKey(6, serverIP, 8080, clientIP, 40001): conntrack.NewValueNormal(0, 0, 0,
+				conntrack.Leg{}, conntrack.Leg{Opener: true}),
+		})
+		flows, err := reader.Flows()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(flows).To(ConsistOf(
+			flowlog.Flow{Proto: 6, SrcIP: clientIP, SrcPort: 40000, DstIP: serverIP, DstPort: 80},
+			flowlog.Flow{Proto: 6, SrcIP: clientIP, SrcPort: 40001, DstIP: serverIP, DstPort: 8080},
+		))
+	})
+
+	It("should ignore NAT forward entries", func() {
+		revKey := conntrack.NewKey(6, clientIP, 40000, serverIP, 80)
+		scan(map[conntrack.Key]conntrack.Value{
+			conntrack.NewKey(6, clientIP, 40000, net.ParseIP("10.96.0.10").To4(), 80): conntrack.NewValueNATForward(0, 0, 0, revKey),
+		})
+		Expect(reader.Flows()).To(BeEmpty())
+	})
+
+	It("should only replace the flows at the end of an iteration", func() {
+		scan(map[conntrack.Key]conntrack.Value{
+			conntrack.NewKey(6, clientIP, 40000, serverIP, 80): conntrack.NewValueNormal(0, 0, 0,
+				conntrack.Leg{Opener: true}, conntrack.Leg{}),
+		})
+		reader.IterationStart()
+		Expect(reader.Flows()).To(HaveLen(1))
+		reader.IterationEnd()
+		Expect(reader.Flows()).To(BeEmpty())
+	})
+})
@@ -21,6 +21,8 @@ import (
 	"os"
 	"reflect"
 	"regexp"
+	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -35,6 +37,7 @@ import (
 	"github.com/projectcalico/felix/bpf"
 	"github.com/projectcalico/felix/bpf/arp"
 	"github.com/projectcalico/felix/bpf/conntrack"
+	"github.com/projectcalico/felix/bpf/counters"
 	"github.com/projectcalico/felix/bpf/failsafes"
 	bpfipsets "github.com/projectcalico/felix/bpf/ipsets"
 	"github.com/projectcalico/felix/bpf/nat"
@@ -43,14 +46,21 @@ import (
 	"github.com/projectcalico/felix/bpf/state"
 	"github.com/projectcalico/felix/bpf/tc"
 	"github.com/projectcalico/felix/config"
+	"github.com/projectcalico/felix/cpubudget"
+	"github.com/projectcalico/felix/dropcapture"
+	"github.com/projectcalico/felix/flowlog"
 	"github.com/projectcalico/felix/idalloc"
 	"github.com/projectcalico/felix/ifacemonitor"
+	"github.com/projectcalico/felix/ip"
 	"github.com/projectcalico/felix/ipsets"
 	"github.com/projectcalico/felix/iptables"
 	"github.com/projectcalico/felix/jitter"
 	"github.com/projectcalico/felix/labelindex"
 	"github.com/projectcalico/felix/logutils"
+	"github.com/projectcalico/felix/netlinkshim"
+	"github.com/projectcalico/felix/nftables"
 	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/routerule"
 	"github.com/projectcalico/felix/routetable"
 	"github.com/projectcalico/felix/rules"
 	"github.com/projectcalico/felix/throttle"
@@ -62,11 +72,6 @@ import (
 )
 
 const (
-	// msgPeekLimit is the maximum number of messages we'll try to grab from the to-dataplane
-	// channel before we apply the changes.  Higher values allow us to batch up more work on
-	// the channel for greater throughput when we're under load (at cost of higher latency).
-	msgPeekLimit = 100
-
 	// Interface name used by kube-proxy to bind service ips.
 	KubeIPVSInterface = "kube-ipvs0"
 )
@@ -99,6 +104,11 @@ var (
 		Help: "Number of interface address messages processed in each batch. Higher " +
 			"values indicate we're doing more batching to try to keep up.",
 	})
+	gaugeVecBPFMounts = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "felix_bpf_filesystem_mounts",
+		Help: "Filesystems that the BPF dataplane is using; private is \"true\" if Felix mounted " +
+			"the filesystem in its own mount namespace.",
+	}, []string{"type", "path", "private"})
 
 	processStartTime time.Time
 	zeroKey          = wgtypes.Key{}
@@ -111,6 +121,7 @@ func init() {
 	prometheus.MustRegister(summaryBatchSize)
 	prometheus.MustRegister(summaryIfaceBatchSize)
 	prometheus.MustRegister(summaryAddrBatchSize)
+	prometheus.MustRegister(gaugeVecBPFMounts)
 	processStartTime = time.Now()
 }
 
@@ -120,6 +131,7 @@ type Config struct {
 	IPv6Enabled          bool
 	RuleRendererOverride rules.RuleRenderer
 	IPIPMTU              int
+	IPIPRoutesEnabled    bool
 	VXLANMTU             int
 	VXLANPort            int
 
@@ -128,15 +140,19 @@ type Config struct {
 	IptablesBackend                string
 	IPSetsRefreshInterval          time.Duration
 	RouteRefreshInterval           time.Duration
+	RouteTableMaxParallelUpdates   int
 	DeviceRouteSourceAddress       net.IP
 	DeviceRouteProtocol            int
 	RemoveExternalRoutes           bool
+	StaticRouteProtocol            int
 	IptablesRefreshInterval        time.Duration
 	IptablesPostWriteCheckInterval time.Duration
 	IptablesInsertMode             string
 	IptablesLockFilePath           string
 	IptablesLockTimeout            time.Duration
 	IptablesLockProbeInterval      time.Duration
+	IptablesMaxChainsPerRestore    int
+	IptablesRestoreDeadline        time.Duration
 	XDPRefreshInterval             time.Duration
 
 	Wireguard wireguard.Config
@@ -158,23 +174,152 @@ type Config struct {
 
 	DebugSimulateDataplaneHangAfter time.Duration
 
+	// DeferredStartupEnabled defers the start-of-day work of subsystems that policy doesn't
+	// depend on (tunnel devices, wireguard and XDP cleanup) until after the first apply, so
+	// that policy is programmed sooner after a restart.  See the Manager interface.
+	DeferredStartupEnabled bool
+
 	ExternalNodesCidrs []string
 
+	// PreferredHostIPVersion is the IP version of the host addresses to use for node-to-node
+	// traffic, such as wireguard endpoints, when a host has both an IPv4 and an IPv6 address.
+	PreferredHostIPVersion int
+
+	CNIReadinessGateEnabled bool
+	CNINetDir               string
+	CNIBinDir               string
+	CNIPluginBinaries       []string
+
+	WorkloadDrainEnabled      bool
+	WorkloadDrainFile         string
+	WorkloadDrainPollInterval time.Duration
+
+	// DropCapture, if non-nil, receives the packets that are dropped by policy.  The NFLOG group
+	// is taken from the rules config.
+	DropCapture           *dropcapture.Ring
+	DropCaptureSnapLength int
+
+	// RouteTableDebug, if non-nil, is the registry that the route tables register with so that
+	// their state can be served on the debug endpoint.
+	RouteTableDebug *routetable.DebugRegistry
+	// BPFMemoryAccountant, if non-nil, is told about the BPF maps and programs that we create so
+	// that it can estimate their memory usage.
+	BPFMemoryAccountant *bpf.MemoryAccountant
+
+	// CrashDumpDir, if non-empty, is the directory that we write a snapshot of the dataplane
+	// state to if the dataplane loop panics or we log a fatal error.  Each snapshot is limited to
+	// CrashDumpMaxBytes.
+	CrashDumpDir      string
+	CrashDumpMaxBytes int
+
+	// NFTablesEnabled causes our rules to be programmed with nft directly, rather than through
+	// iptables-restore, and our IP sets to be stored as nftables sets.  Ignored in BPF mode.
+	NFTablesEnabled bool
+
+	EgressSNATAddresses          []string
+	EgressSNATNamespaceAddresses map[string]string
+
+	// ExternalNetworks and ExternalNetworkTreatments are the start-of-day values of the external
+	// network config, which can then change via ConfigUpdate messages.
+	ExternalNetworks          map[string]string
+	ExternalNetworkTreatments map[string]string
+
+	ConntrackAccountingEnabled  bool
+	ConntrackAccountingInterval time.Duration
+	ConntrackAccountingTopN     int
+
+	// FlowLogsSink, if non-nil, enables flow logs: every FlowLogsFlushInterval, the connections to
+	// and from local workloads are summarised and written to the sink.
+	FlowLogsSink          flowlog.Sink
+	FlowLogsFlushInterval time.Duration
+
+	// WorkloadConnRateLimit is the default new connection rate limit for workloads; the limit
+	// is only applied if enabled in the rules config.
+	WorkloadConnRateLimit      int
+	WorkloadConnRateLimitBurst int
+
+	// WorkloadEgressAllowlistMaxSize is the size limit of each workload's egress allowlist IP
+	// set; the IP sets are only created if the allowlist is enabled in the rules config.
+	WorkloadEgressAllowlistMaxSize int
+
+	// WorkloadLinkLocalExceptNS lists the namespaces of the workloads whose traffic
+	// to link-local services is left to policy, rather than to the WorkloadLinkLocalAccess of
+	// the rules config.
+	WorkloadLinkLocalExceptNS []string
+
+	// ManagerFailureBudget is the number of consecutive times that a manager may fail to complete
+	// its deferred work before Felix reports non-ready; 0 disables the check.
+	ManagerFailureBudget int
+
+	// MaxMsgBatchSize is the upper bound of the adaptive limit on the number of messages that the
+	// main loop reads from an input channel before applying them, and the capacity of those
+	// channels.  0 means the default.
+	MaxMsgBatchSize int
+
+	// CPUBudget is the share of a core that Felix aims to stay under by putting off non-urgent
+	// work, such as periodic refreshes and conntrack scans; 0 disables pacing.
+	CPUBudget float64
+
+	// ThreadSched holds the scheduling settings (nice value, CPU affinity and so on) for the
+	// thread that runs the main loop.
+	ThreadSched ThreadSchedConfig
+
+	// BPFWorkloadAllowedSourcesEnabled makes the BPF dataplane accept traffic from workloads'
+	// additional allowed source prefixes.  (The iptables dataplane is controlled by the rules
+	// config.)
+	BPFWorkloadAllowedSourcesEnabled bool
+
+	// WorkloadMACEnforcementByDefault makes MAC enforcement (if enabled in the rules config)
+	// apply to all workloads that don't opt out, rather than only to those that opt in.
+	WorkloadMACEnforcementByDefault bool
+
+	// WorkloadIfaceSettings says which settings of the host side of workload interfaces the
+	// endpoint managers own.
+	WorkloadIfaceSettings WorkloadIfaceSettings
+
+	// NeighborGCTuningEnabled sizes the neighbor table's GC thresholds from the number of
+	// workloads and hosts.  If NeighborGCStaleTime is non-zero, it is also set as the stale time
+	// of each workload interface's neighbor entries.
+	NeighborGCTuningEnabled bool
+	NeighborGCStaleTime     time.Duration
+
+	// PolicyRuleCountersInterval is the minimum interval between reads of the policy rule
+	// counters, if they're enabled in the rules config.
+	PolicyRuleCountersInterval time.Duration
+
+	// DryRunReportFile, if non-empty, enables dry-run mode: the changes that we would make to
+	// iptables, IP sets and routes are written to this file, as JSON, instead of to the kernel.
+	DryRunReportFile string
+
 	BPFEnabled                         bool
 	BPFDisableUnprivileged             bool
 	BPFKubeProxyIptablesCleanupEnabled bool
+	BPFKubeProxyMigrationMode          string
 	BPFLogLevel                        string
 	BPFExtToServiceConnmark            int
 	BPFDataIfacePattern                *regexp.Regexp
 	XDPEnabled                         bool
 	XDPAllowGeneric                    bool
 	BPFConntrackTimeouts               conntrack.Timeouts
+	BPFConntrackScanRateLimit          int
+	BPFCountersScrapeInterval          time.Duration
+	BPFCgroupV2Root                    string
+	BPFAutoMountEnabled                bool
 	BPFCgroupV2                        string
 	BPFConnTimeLBEnabled               bool
+	BPFHostNATTableIndex               int
 	BPFMapRepin                        bool
 	BPFNodePortDSREnabled              bool
-	KubeProxyMinSyncPeriod             time.Duration
-	KubeProxyEndpointSlicesEnabled     bool
+	BPFMaglevEnabled                   bool
+	BPFNodePortSourceRanges            []string
+	BPFRouteClassifications            map[string]string
+	BPFInterfaceDampingWindow          time.Duration
+	BPFMaxParallelAttaches             int
+	// BPFPinOwnerID, if non-empty, is the instance ID that our BPF pins are labelled with, so that
+	// stale pins can be garbage collected without touching other instances' pins.
+	BPFPinOwnerID                  string
+	KubeProxyMinSyncPeriod         time.Duration
+	KubeProxyEndpointSlicesEnabled bool
 
 	SidecarAccelerationEnabled bool
 
@@ -187,10 +332,16 @@ type Config struct {
 	// Populated with the smallest host MTU based on auto-detection.
 	hostMTU         int
 	MTUIfacePattern *regexp.Regexp
+	// wireguardMTUAuto is set if the wireguard MTU was derived from the host MTU, rather than
+	// configured, in which case we update it when the host MTU changes.
+	wireguardMTUAuto bool
 
 	RouteSource string
 
 	KubernetesProvider config.Provider
+
+	ServiceRoutesEnabled bool
+	ServiceRoutesDevice  string
 }
 
 type UpdateBatchResolver interface {
@@ -201,6 +352,13 @@ type UpdateBatchResolver interface {
 	ResolveUpdateBatch() error
 }
 
+type ManagerWithReschedule interface {
+	// RescheduleAfter returns how long the manager would like to wait before its
+	// CompleteDeferredWork is called again, even if no updates arrive, or 0 if it doesn't need
+	// another call.  It's called after each CompleteDeferredWork call.
+	RescheduleAfter() time.Duration
+}
+
 // InternalDataplane implements an in-process Felix dataplane driver based on iptables
 // and ipsets.  It communicates with the datastore-facing part of Felix via the
 // Send/RecvMessage methods, which operate on the protobuf-defined API objects.
@@ -233,13 +391,17 @@ type InternalDataplane struct {
 	toDataplane   chan interface{}
 	fromDataplane chan interface{}
 
-	allIptablesTables    []*iptables.Table
-	iptablesMangleTables []*iptables.Table
-	iptablesNATTables    []*iptables.Table
-	iptablesRawTables    []*iptables.Table
-	iptablesFilterTables []*iptables.Table
+	allIptablesTables    []dataplaneTable
+	iptablesMangleTables []dataplaneTable
+	iptablesNATTables    []dataplaneTable
+	iptablesRawTables    []dataplaneTable
+	iptablesFilterTables []dataplaneTable
 	ipSets               []ipsetsDataplane
 
+	// numQuarantinedChains is the number of iptables chains that were quarantined after the
+	// last apply; used to log changes.
+	numQuarantinedChains int
+
 	ipipManager *ipipManager
 
 	wireguardManager *wireguardManager
@@ -248,12 +410,46 @@ type InternalDataplane struct {
 	ifaceUpdates     chan *ifaceUpdate
 	ifaceAddrUpdates chan *ifaceAddrsUpdate
 
+	localServiceUpdates chan *localServiceIPsUpdate
+	kubeIPVSAddrUpdates chan *kubeIPVSAddrsUpdate
+	ifaceMTUUpdates     chan *ifaceMTUUpdate
+
 	endpointStatusCombiner *endpointStatusCombiner
 
 	allManagers             []Manager
 	managersWithRouteTables []ManagerWithRouteTables
+	managerFailures         *managerFailureBudget
 	ruleRenderer            rules.RuleRenderer
 
+	// cniGate is non-nil if the CNI readiness gate is enabled.
+	cniGate *cniGateManager
+	// workloadDrain is non-nil if workload drain mode is configured.
+	workloadDrain *workloadDrainManager
+	// conntrackAcct is non-nil if conntrack accounting is enabled.
+	conntrackAcct *conntrackAccountingManager
+	// flowLogs is non-nil if flow logs are enabled.
+	flowLogs *flowLogManager
+	// bpfCounters is non-nil if BPF mode is enabled and its counters are exported as metrics.
+	bpfCounters *bpfCountersManager
+	// connRateLimit is non-nil if workload connection rate limiting is enabled.
+	connRateLimit *workloadConnRateLimitManager
+	// dropCaptureReader is non-nil if drop capture is enabled.
+	dropCaptureReader *dropcapture.NFLOGReader
+	// crashDumper is non-nil if crash dumps are enabled.
+	crashDumper *crashDumper
+	// cpuBudget is non-nil if a CPU budget is configured.  deferredWork holds the non-urgent
+	// work that was put off because we were over budget, keyed on the name of the work.
+	cpuBudget    *cpubudget.Controller
+	deferredWork map[string]func()
+
+	// kubeProxyCleaner is non-nil if we're in BPF mode and cleaning up after kube-proxy.
+	kubeProxyCleaner *kubeProxyCleaner
+
+	// kubeProxyMigrationGuard is non-nil if we're in BPF mode and watching for a live kube-proxy.
+	// kubeProxyNATDeferred is set if we found one at start of day and so left service NAT to it.
+	kubeProxyMigrationGuard *kubeProxyMigrationGuard
+	kubeProxyNATDeferred    bool
+
 	// dataplaneNeedsSync is set if the dataplane is dirty in some way, i.e. we need to
 	// call apply().
 	dataplaneNeedsSync bool
@@ -266,6 +462,13 @@ type InternalDataplane struct {
 	// forceXDPRefresh is set by the XDP refresh timer to indicate that we should
 	// check the XDP state in the dataplane.
 	forceXDPRefresh bool
+	// The refresh timers; they are restarted when their intervals are reloaded.
+	ipSetsRefreshTimer refreshTimer
+	routeRefreshTimer  refreshTimer
+	xdpRefreshTimer    refreshTimer
+	// lastRawConfig is the config from the last ConfigUpdate, which we compare against to find
+	// the parameters to reload.  Nil until the first ConfigUpdate.
+	lastRawConfig map[string]string
 	// doneFirstApply is set after we finish the first update to the dataplane. It indicates
 	// that the dataplane should now be in sync.
 	doneFirstApply bool
@@ -273,12 +476,27 @@ type InternalDataplane struct {
 	reschedTimer *time.Timer
 	reschedC     <-chan time.Time
 
+	// ipFamilies tracks failures of the per-IP-family parts of the dataplane (IP sets, iptables
+	// and route tables) so that a failing family is retried, with backoff, on its own rather than
+	// forcing a full apply.
+	ipFamilies         map[uint8]*ipFamilyApplyState
+	ipFamilyRetryTimer *time.Timer
+	ipFamilyRetryC     <-chan time.Time
+
 	applyThrottle *throttle.Throttle
 
 	config Config
 
 	debugHangC <-chan time.Time
 
+	// deferredManagers are the managers whose route tables aren't applied until the deferred
+	// startup tasks have finished.  deferredStartupTasks holds those tasks until the first apply
+	// and deferredStartupDone is signalled when they have all finished.
+	deferredManagers     set.Set
+	deferredStartupTasks []startupTask
+	deferredStartupDone  chan struct{}
+	deferredStarted      bool
+
 	xdpState          *xdpState
 	sockmapState      *sockmapState
 	endpointsSourceV4 endpointsSource
@@ -291,6 +509,11 @@ type InternalDataplane struct {
 const (
 	healthName     = "int_dataplane"
 	healthInterval = 10 * time.Second
+	// ctlbHealthName is the name that we report the connect-time load balancer's health under.
+	ctlbHealthName = "bpf_connect_time_lb"
+	// iptablesHealthName is the name that we report non-ready under while any iptables chains
+	// are quarantined because iptables-restore rejected their rules.
+	iptablesHealthName = "iptables_rejected_rules"
 
 	ipipMTUOverhead      = 20
 	vxlanMTUOverhead     = 50
@@ -320,42 +543,90 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 		log.WithError(err).Error("Failed to write MTU file, pod MTU may not be properly set")
 	}
 
+	if config.MaxMsgBatchSize <= 0 {
+		config.MaxMsgBatchSize = defaultMaxMsgBatchSize
+	}
 	dp := &InternalDataplane{
-		toDataplane:      make(chan interface{}, msgPeekLimit),
+		toDataplane:      make(chan interface{}, config.MaxMsgBatchSize),
 		fromDataplane:    make(chan interface{}, 100),
 		ruleRenderer:     ruleRenderer,
 		ifaceMonitor:     ifacemonitor.New(config.IfaceMonitorConfig, config.FatalErrorRestartCallback),
-		ifaceUpdates:     make(chan *ifaceUpdate, 100),
-		ifaceAddrUpdates: make(chan *ifaceAddrsUpdate, 100),
+		ifaceUpdates:     make(chan *ifaceUpdate, config.MaxMsgBatchSize),
+		ifaceAddrUpdates: make(chan *ifaceAddrsUpdate, config.MaxMsgBatchSize),
 		config:           config,
 		applyThrottle:    throttle.New(10),
 		loopSummarizer:   logutils.NewSummarizer("dataplane reconciliation loops"),
+		managerFailures:  newManagerFailureBudget(config.ManagerFailureBudget),
+
+		localServiceUpdates: make(chan *localServiceIPsUpdate, 1),
+		kubeIPVSAddrUpdates: make(chan *kubeIPVSAddrsUpdate, 10),
+		ifaceMTUUpdates:     make(chan *ifaceMTUUpdate, 10),
+
+		deferredManagers:    set.New(),
+		deferredStartupDone: make(chan struct{}, 1),
+		deferredStarted:     !config.DeferredStartupEnabled,
 	}
 	dp.applyThrottle.Refill() // Allow the first apply() immediately.
+	if config.CPUBudget > 0 {
+		log.WithField("budget", config.CPUBudget).Info("CPU budget enabled, will pace non-urgent work.")
+		dp.cpuBudget = cpubudget.New(config.CPUBudget, cpubudget.ProcessCPUTime, time.Now)
+		dp.deferredWork = map[string]func(){}
+	}
 	dp.ifaceMonitor.StateCallback = dp.onIfaceStateChange
 	dp.ifaceMonitor.AddrCallback = dp.onIfaceAddrsChange
+	if config.RulesConfig.KubeIPVSSupportEnabled {
+		dp.ifaceMonitor.ExcludedAddrCallback = dp.onExcludedIfaceAddrsChange
+	}
+	if config.Wireguard.Enabled && config.wireguardMTUAuto {
+		// Keep the wireguard MTU in line with the MTU of the host's interfaces.
+		dp.ifaceMonitor.MTUCallback = dp.onIfaceMTUChange
+	}
 
-	backendMode := iptables.DetectBackend(config.LookPathOverride, iptables.NewRealCmd, config.IptablesBackend)
+	useNFTables := config.NFTablesEnabled && !config.BPFEnabled
+	var backendMode string
+	if !useNFTables {
+		backendMode = iptables.DetectBackend(config.LookPathOverride, iptables.NewRealCmd, config.IptablesBackend)
+	}
 
 	// Most iptables tables need the same options.
 	iptablesOptions := iptables.TableOptions{
-		HistoricChainPrefixes: rules.AllHistoricChainNamePrefixes,
+		HistoricChainPrefixes: config.RulesConfig.HistoricChainNamePrefixes(),
 		InsertMode:            config.IptablesInsertMode,
 		RefreshInterval:       config.IptablesRefreshInterval,
 		PostWriteInterval:     config.IptablesPostWriteCheckInterval,
 		LockTimeout:           config.IptablesLockTimeout,
 		LockProbeInterval:     config.IptablesLockProbeInterval,
+		MaxChainsPerRestore:   config.IptablesMaxChainsPerRestore,
+		RestoreDeadline:       config.IptablesRestoreDeadline,
 		BackendMode:           backendMode,
 		LookPathOverride:      config.LookPathOverride,
 		OnStillAlive:          dp.reportHealth,
 		OpRecorder:            dp.loopSummarizer,
 	}
 
-	if config.BPFEnabled && config.BPFKubeProxyIptablesCleanupEnabled {
+	if config.BPFEnabled && config.BPFKubeProxyMigrationMode != "" &&
+		config.BPFKubeProxyMigrationMode != KubeProxyMigrationDisabled {
+		dp.kubeProxyMigrationGuard = newKubeProxyMigrationGuard()
+		if config.BPFKubeProxyMigrationMode == KubeProxyMigrationDefer && dp.kubeProxyMigrationGuard.KubeProxyLive() {
+			// Leave kube-proxy's rules alone and don't program the BPF NAT maps; we restart once
+			// kube-proxy has gone and then take over as normal.
+			log.Info("Found live kube-proxy, deferring BPF NAT until it has been removed.")
+			dp.kubeProxyNATDeferred = true
+		}
+	}
+
+	if config.BPFEnabled && config.BPFKubeProxyIptablesCleanupEnabled && !dp.kubeProxyNATDeferred {
 		// If BPF-mode is enabled, clean up kube-proxy's rules too.
 		log.Info("BPF enabled, configuring iptables layer to clean up kube-proxy's rules.")
 		iptablesOptions.ExtraCleanupRegexPattern = rules.KubeProxyInsertRuleRegex
 		iptablesOptions.HistoricChainPrefixes = append(iptablesOptions.HistoricChainPrefixes, rules.KubeProxyChainPrefixes...)
+		// kube-proxy's IPVS and nftables modes leave state outside of iptables.
+		dp.kubeProxyCleaner = newKubeProxyCleaner()
+	}
+
+	if !config.ThreadSched.IsDefault() {
+		// Run iptables-restore and friends with the same scheduling settings as the main loop.
+		iptablesOptions.NewCmdOverride = config.ThreadSched.iptablesCmdFactory()
 	}
 
 	// However, the NAT tables need an extra cleanup regex.
@@ -366,11 +637,31 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 		iptablesNATOptions.ExtraCleanupRegexPattern += "|" + rules.HistoricInsertedNATRuleRegex
 	}
 
+	var dryRun *dryRunReport
+	if config.DryRunReportFile != "" {
+		var err error
+		dryRun, err = openDryRunReport(config.DryRunReportFile)
+		if err != nil {
+			// Better not to start than to program a dataplane that the operator wanted to preview.
+			log.WithError(err).Panic("Failed to open dry-run report.")
+		}
+		log.WithField("file", config.DryRunReportFile).Warn("Dry-run mode enabled, iptables, IP set and " +
+			"route changes will be written to the report instead of the dataplane.")
+	}
+
 	featureDetector := iptables.NewFeatureDetector(config.FeatureDetectOverrides)
 	iptablesFeatures := featureDetector.GetFeatures()
 
 	var iptablesLock sync.Locker
-	if iptablesFeatures.RestoreSupportsLock {
+	if useNFTables {
+		log.Debug("Calico implementation of iptables lock disabled (because we're programming " +
+			"nftables directly).")
+		iptablesLock = dummyLock{}
+	} else if iptablesFeatures.RestoreLockFree && backendMode == "nft" {
+		log.Debug("Calico implementation of iptables lock disabled (because iptables-nft-restore " +
+			"doesn't need the xtables lock).")
+		iptablesLock = dummyLock{}
+	} else if iptablesFeatures.RestoreSupportsLock {
 		log.Debug("Calico implementation of iptables lock disabled (because detected version of " +
 			"iptables-restore will use its own implementation).")
 		iptablesLock = dummyLock{}
@@ -390,37 +681,50 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 		)
 	}
 
-	mangleTableV4 := iptables.NewTable(
-		"mangle",
-		4,
-		rules.RuleHashPrefix,
-		iptablesLock,
-		featureDetector,
-		iptablesOptions)
-	natTableV4 := iptables.NewTable(
-		"nat",
-		4,
-		rules.RuleHashPrefix,
-		iptablesLock,
-		featureDetector,
-		iptablesNATOptions,
-	)
-	rawTableV4 := iptables.NewTable(
-		"raw",
-		4,
-		rules.RuleHashPrefix,
-		iptablesLock,
-		featureDetector,
-		iptablesOptions)
-	filterTableV4 := iptables.NewTable(
-		"filter",
-		4,
-		rules.RuleHashPrefix,
-		iptablesLock,
-		featureDetector,
-		iptablesOptions)
+	nftablesOptions := nftables.TableOptions{
+		RefreshInterval: config.IptablesRefreshInterval,
+		OnStillAlive:    dp.reportHealth,
+		OpRecorder:      dp.loopSummarizer,
+		NewCmdOverride:  iptablesOptions.NewCmdOverride,
+	}
+	// newTable creates the Table that programs the given iptables table, or its nftables
+	// equivalent.
+	newTable := func(name string, ipVersion uint8, options iptables.TableOptions) dataplaneTable {
+		if useNFTables {
+			return nftables.NewTable(name, ipVersion, config.RulesConfig.HashPrefix(), nftablesOptions)
+		}
+		return iptables.NewTable(name, ipVersion, config.RulesConfig.HashPrefix(), iptablesLock, featureDetector,
+			dryRun.iptablesOptions(name, ipVersion, options))
+	}
+
+	// The filter tables also read back the policy rule counters, if enabled.
+	var policyCountersMgr *policyCountersManager
+	filterOptions := func(ipVersion uint8) iptables.TableOptions {
+		options := iptablesOptions
+		if policyCountersMgr != nil {
+			options.OnRuleCounters = policyCountersMgr.OnRuleCounters(ipVersion)
+			options.RuleCountersInterval = config.PolicyRuleCountersInterval
+		}
+		return options
+	}
+	if config.RulesConfig.PolicyRuleCountersEnabled {
+		policyCountersMgr = newPolicyCountersManager(ruleRenderer)
+		dp.RegisterManager(policyCountersMgr)
+	}
+
+	mangleTableV4 := newTable("mangle", 4, iptablesOptions)
+	natTableV4 := newTable("nat", 4, iptablesNATOptions)
+	rawTableV4 := newTable("raw", 4, iptablesOptions)
+	filterTableV4 := newTable("filter", 4, filterOptions(4))
+	useNFTSets := useNFTables || !ipsets.KernelSupportsIPSets()
+	if useNFTSets && !useNFTables {
+		// Note: iptables has no match for nftables sets; the "set" match needs the ipset modules.
+		// The sets are kept up to date so that they can be used by nftables rules.
+		log.Warn("Kernel doesn't support IP sets, storing them as nftables sets instead.  " +
+			"iptables rules that match on IP sets will fail to load.")
+	}
 	ipSetsConfigV4 := config.RulesConfig.IPSetConfigV4
-	ipSetsV4 := ipsets.NewIPSets(ipSetsConfigV4, dp.loopSummarizer)
+	ipSetsV4 := dryRun.ipSets(4, newIPSetsDataplane(ipSetsConfigV4, useNFTSets, config.ThreadSched, dp.loopSummarizer))
 	dp.iptablesNATTables = append(dp.iptablesNATTables, natTableV4)
 	dp.iptablesRawTables = append(dp.iptablesRawTables, rawTableV4)
 	dp.iptablesMangleTables = append(dp.iptablesMangleTables, mangleTableV4)
@@ -428,9 +732,13 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 	dp.ipSets = append(dp.ipSets, ipSetsV4)
 
 	if config.RulesConfig.VXLANEnabled {
-		routeTableVXLAN := routetable.New([]string{"^vxlan.calico$"}, 4, true, config.NetlinkTimeout,
+		routeTableVXLAN := dryRun.routeTable("vxlan", 4, routetable.New(
+			[]string{"^vxlan.calico$", vxlanPoolDeviceRegexp.String()}, 4, true, config.NetlinkTimeout,
 			config.DeviceRouteSourceAddress, config.DeviceRouteProtocol, true, 0,
-			dp.loopSummarizer)
+			dp.loopSummarizer))
+		if config.RouteTableDebug != nil {
+			config.RouteTableDebug.Register("vxlan", routeTableVXLAN)
+		}
 
 		vxlanManager := newVXLANManager(
 			ipSetsV4,
@@ -439,10 +747,12 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 			config,
 			dp.loopSummarizer,
 		)
-		go vxlanManager.KeepVXLANDeviceInSync(config.VXLANMTU, iptablesFeatures.ChecksumOffloadBroken, 10*time.Second)
-		dp.RegisterManager(vxlanManager)
+		dp.addStartupTask("vxlan-device", func() {
+			go vxlanManager.KeepVXLANDeviceInSync(config.VXLANMTU, iptablesFeatures.ChecksumOffloadBroken, 10*time.Second)
+		})
+		dp.registerDeferredManager(vxlanManager)
 	} else {
-		cleanUpVXLANDevice()
+		dp.addStartupTask("vxlan-cleanup", cleanUpVXLANDevice)
 	}
 
 	dp.endpointStatusCombiner = newEndpointStatusCombiner(dp.fromDataplane, config.IPv6Enabled)
@@ -469,14 +779,16 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 
 	// TODO Integrate XDP and BPF infra.
 	if !config.BPFEnabled && dp.xdpState == nil {
-		xdpState, err := NewXDPState(config.XDPAllowGeneric)
-		if err == nil {
-			if err := xdpState.WipeXDP(); err != nil {
-				log.WithError(err).Warn("Failed to cleanup preexisting XDP state")
+		dp.addStartupTask("xdp-cleanup", func() {
+			xdpState, err := NewXDPState(config.XDPAllowGeneric)
+			if err == nil {
+				if err := xdpState.WipeXDP(); err != nil {
+					log.WithError(err).Warn("Failed to cleanup preexisting XDP state")
+				}
 			}
-		}
-		// if we can't create an XDP state it means we couldn't get a working
-		// bpffs so there's nothing to clean up
+			// if we can't create an XDP state it means we couldn't get a working
+			// bpffs so there's nothing to clean up
+		})
 	}
 
 	if config.SidecarAccelerationEnabled {
@@ -520,14 +832,22 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 			rules.IPSetIDThisHostIPs,
 			ipSetsV4,
 			config.MaxIPSetSize))
+		if config.RulesConfig.KubeIPVSSupportEnabled {
+			dp.RegisterManager(newKubeIPVSServiceIPManager(rules.IPSetIDKubeIPVSServiceIPs, ipSetsV4, config.MaxIPSetSize))
+		}
 		dp.RegisterManager(newPolicyManager(rawTableV4, mangleTableV4, filterTableV4, ruleRenderer, 4))
+		dp.RegisterManager(newPolicyScheduleManager(rawTableV4, mangleTableV4, filterTableV4, ruleRenderer, 4))
 
 		// Clean up any leftover BPF state.
-		err := nat.RemoveConnectTimeLoadBalancer("")
+		err := nat.RemoveConnectTimeLoadBalancer(config.BPFCgroupV2Root, "")
 		if err != nil {
 			log.WithError(err).Info("Failed to remove BPF connect-time load balancer, ignoring.")
 		}
-		tc.CleanUpProgramsAndPins()
+		var pinOwner *tc.PinOwner
+		if config.BPFPinOwnerID != "" {
+			pinOwner = tc.NewPinOwner(config.BPFPinOwnerID, "")
+		}
+		tc.CleanUpProgramsAndPins(pinOwner)
 	}
 
 	interfaceRegexes := make([]string, len(config.RulesConfig.WorkloadIfacePrefixes))
@@ -540,28 +860,44 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 
 	var (
 		bpfEndpointManager *bpfEndpointManager
+		// bpfFlowReader is non-nil if flow logs are enabled in BPF mode.
+		bpfFlowReader *bpfConntrackFlowReader
 	)
 
 	if config.BPFEnabled {
 		log.Info("BPF enabled, starting BPF endpoint manager and map manager.")
+		// Check for bpffs and cgroup v2 up front; without them, we'd fail later with an obscure
+		// error from the first map or program that we tried to pin.
+		bpfMounts, err := bpf.EnsureMounts(config.BPFAutoMountEnabled, config.BPFConnTimeLBEnabled,
+			config.BPFCgroupV2Root)
+		if err != nil {
+			log.WithError(err).Panic("Filesystems needed by the BPF dataplane are not available.")
+		}
+		for _, m := range bpfMounts {
+			gaugeVecBPFMounts.WithLabelValues(m.FSType, m.Path, strconv.FormatBool(m.Private)).Set(1)
+		}
 		// Register map managers first since they create the maps that will be used by the endpoint manager.
 		// Important that we create the maps before we load a BPF program with TC since we make sure the map
 		// metadata name is set whereas TC doesn't set that field.
 		ipSetIDAllocator := idalloc.New()
 		ipSetsMap := bpfipsets.Map(bpfMapContext)
-		err := ipSetsMap.EnsureExists()
+		err = ipSetsMap.EnsureExists()
 		if err != nil {
 			log.WithError(err).Panic("Failed to create ipsets BPF map.")
 		}
+		config.BPFMemoryAccountant.AddMap(bpf.MemoryFeatureIPSets, ipSetsMap)
 		ipSetsV4 := bpfipsets.NewBPFIPSets(
 			ipSetsConfigV4,
 			ipSetIDAllocator,
 			ipSetsMap,
 			dp.loopSummarizer,
+			bpfipsets.IDRecordFile,
 		)
 		dp.ipSets = append(dp.ipSets, ipSetsV4)
 		dp.RegisterManager(newIPSetsManager(ipSetsV4, config.MaxIPSetSize))
-		bpfRTMgr := newBPFRouteManager(config.Hostname, config.ExternalNodesCidrs, bpfMapContext, dp.loopSummarizer)
+		bpfRTMgr := newBPFRouteManager(config.Hostname, config.ExternalNodesCidrs,
+			config.BPFRouteClassifications, config.BPFWorkloadAllowedSourcesEnabled, bpfMapContext,
+			dp.loopSummarizer)
 		dp.RegisterManager(bpfRTMgr)
 
 		// Forwarding into an IPIP tunnel fails silently because IPIP tunnels are L3 devices and support for
@@ -572,12 +908,25 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 		if err != nil {
 			log.WithError(err).Panic("Failed to create state BPF map.")
 		}
+		config.BPFMemoryAccountant.AddMap(bpf.MemoryFeatureState, stateMap)
 
 		arpMap := arp.Map(bpfMapContext)
 		err = arpMap.EnsureExists()
 		if err != nil {
 			log.WithError(err).Panic("Failed to create ARP BPF map.")
 		}
+		config.BPFMemoryAccountant.AddMap(bpf.MemoryFeatureARP, arpMap)
+
+		countersMap := counters.Map(bpfMapContext)
+		err = countersMap.EnsureExists()
+		if err != nil {
+			log.WithError(err).Panic("Failed to create counters BPF map.")
+		}
+		config.BPFMemoryAccountant.AddMap(bpf.MemoryFeatureCounters, countersMap)
+		if config.BPFCountersScrapeInterval > 0 {
+			dp.bpfCounters = newBPFCountersManager(countersMap)
+			dp.RegisterManager(dp.bpfCounters)
+		}
 
 		// The failsafe manager sets up the failsafe port map.  It's important that it is registered before the
 		// endpoint managers so that the map is brought up to date before they run for the first time.
@@ -586,6 +935,7 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 		if err != nil {
 			log.WithError(err).Panic("Failed to create failsafe port BPF map.")
 		}
+		config.BPFMemoryAccountant.AddMap(bpf.MemoryFeatureFailsafes, failsafesMap)
 		failsafeMgr := failsafes.NewManager(
 			failsafesMap,
 			config.RulesConfig.FailsafeInboundHostPorts,
@@ -595,24 +945,38 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 		dp.RegisterManager(failsafeMgr)
 
 		workloadIfaceRegex := regexp.MustCompile(strings.Join(interfaceRegexes, "|"))
+		dataIfaceRegex := config.BPFDataIfacePattern
+		if !config.BPFConnTimeLBEnabled {
+			// Without CTLB, host-originated service traffic is NATted by the from-HEP program on the
+			// host NAT veth; see bpfHostNATManager.
+			dataIfaceRegex = regexp.MustCompile(dataIfaceRegex.String() + "|^" + bpfHostNATIfaceOut + "$")
+		}
 		bpfEndpointManager = newBPFEndpointManager(
 			config.BPFLogLevel,
 			config.Hostname,
 			fibLookupEnabled,
 			config.RulesConfig.EndpointToHostAction,
-			config.BPFDataIfacePattern,
+			config.RulesConfig.EndpointToHostActionOverridesEnabled,
+			config.RulesConfig.WorkloadLinkLocalAccess,
+			config.RulesConfig.WorkloadLinkLocalServices,
+			config.WorkloadLinkLocalExceptNS,
+			dataIfaceRegex,
 			workloadIfaceRegex,
 			ipSetIDAllocator,
 			config.VXLANMTU,
 			uint16(config.VXLANPort),
 			config.BPFNodePortDSREnabled,
 			config.BPFExtToServiceConnmark,
+			config.BPFInterfaceDampingWindow,
+			config.BPFMaxParallelAttaches,
+			config.BPFPinOwnerID,
 			ipSetsMap,
 			stateMap,
 			ruleRenderer,
 			filterTableV4,
 			dp.reportHealth,
 			dp.loopSummarizer,
+			config.BPFMemoryAccountant,
 		)
 		dp.RegisterManager(bpfEndpointManager)
 
@@ -622,39 +986,67 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 		if err != nil {
 			log.WithError(err).Panic("Failed to create NAT frontend BPF map.")
 		}
+		config.BPFMemoryAccountant.AddMap(bpf.MemoryFeatureNAT, frontendMap)
 		backendMap := nat.BackendMap(bpfMapContext)
 		err = backendMap.EnsureExists()
 		if err != nil {
 			log.WithError(err).Panic("Failed to create NAT backend BPF map.")
 		}
+		config.BPFMemoryAccountant.AddMap(bpf.MemoryFeatureNAT, backendMap)
 		backendAffinityMap := nat.AffinityMap(bpfMapContext)
 		err = backendAffinityMap.EnsureExists()
 		if err != nil {
 			log.WithError(err).Panic("Failed to create NAT backend affinity BPF map.")
 		}
+		config.BPFMemoryAccountant.AddMap(bpf.MemoryFeatureNAT, backendAffinityMap)
+		maglevMap := nat.MaglevMap(bpfMapContext)
+		err = maglevMap.EnsureExists()
+		if err != nil {
+			log.WithError(err).Panic("Failed to create NAT Maglev BPF map.")
+		}
+		config.BPFMemoryAccountant.AddMap(bpf.MemoryFeatureNAT, maglevMap)
 
 		routeMap := routes.Map(bpfMapContext)
 		err = routeMap.EnsureExists()
 		if err != nil {
 			log.WithError(err).Panic("Failed to create routes BPF map.")
 		}
+		config.BPFMemoryAccountant.AddMap(bpf.MemoryFeatureRoutes, routeMap)
 
 		ctMap := conntrack.Map(bpfMapContext)
 		err = ctMap.EnsureExists()
 		if err != nil {
 			log.WithError(err).Panic("Failed to create conntrack BPF map.")
 		}
+		config.BPFMemoryAccountant.AddMap(bpf.MemoryFeatureConntrack, ctMap)
 
-		conntrackScanner := conntrack.NewScanner(ctMap,
-			conntrack.NewLivenessScanner(config.BPFConntrackTimeouts, config.BPFNodePortDSREnabled))
+		ctScanners := []conntrack.EntryScanner{
+			conntrack.NewLivenessScanner(config.BPFConntrackTimeouts, config.BPFNodePortDSREnabled),
+			// Runs after the liveness scanner so that it doesn't count expired entries.
+			conntrack.NewMetricsScanner(conntrack.MapParams.MaxEntries),
+		}
+		if config.FlowLogsSink != nil {
+			// Runs after the liveness scanner so that it only sees the entries that remain.
+			bpfFlowReader = newBPFConntrackFlowReader()
+			ctScanners = append(ctScanners, bpfFlowReader)
+		}
+		conntrackScanner := conntrack.NewScanner(ctMap, ctScanners...)
 
-		// Before we start, scan for all finished / timed out connections to
-		// free up the conntrack table asap as it may take time to sync up the
-		// proxy and kick off the first full cleaner scan.
-		conntrackScanner.Scan()
+		conntrackScanner.SetRateLimit(config.BPFConntrackScanRateLimit)
+		if dp.cpuBudget != nil {
+			conntrackScanner.SetPacer(func() bool {
+				return dp.cpuBudget.ShouldDefer("conntrack-scan")
+			})
+		}
+		// Start scanning for finished / timed out connections straight away to free
+		// up the conntrack table asap as it may take time to sync up the proxy.  The
+		// scanner runs in the background so a large table doesn't hold up start-up.
+		conntrackScanner.Start()
 
 		bpfproxyOpts := []bpfproxy.Option{
 			bpfproxy.WithMinSyncPeriod(config.KubeProxyMinSyncPeriod),
+			// Clean up connections to removed backends promptly rather than on the next tick.
+			bpfproxy.WithConntrackScanTrigger(conntrackScanner.TriggerScan),
 		}
 
 		if config.KubeProxyEndpointSlicesEnabled {
@@ -665,7 +1057,17 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 			bpfproxyOpts = append(bpfproxyOpts, bpfproxy.WithDSREnabled())
 		}
 
-		if config.KubeClientSet != nil {
+		if config.BPFMaglevEnabled {
+			bpfproxyOpts = append(bpfproxyOpts, bpfproxy.WithMaglev())
+		}
+
+		if len(config.BPFNodePortSourceRanges) > 0 {
+			bpfproxyOpts = append(bpfproxyOpts, bpfproxy.WithNodePortSourceRanges(config.BPFNodePortSourceRanges))
+		}
+
+		if dp.kubeProxyNATDeferred {
+			log.Info("Live kube-proxy still handling service NAT, not starting kube-proxy module.")
+		} else if config.KubeClientSet != nil {
 			// We have a Kubernetes connection, start watching services and populating the NAT maps.
 			kp, err := bpfproxy.StartKubeProxy(
 				config.KubeClientSet,
@@ -673,6 +1075,7 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 				frontendMap,
 				backendMap,
 				backendAffinityMap,
+				maglevMap,
 				ctMap,
 				bpfproxyOpts...,
 			)
@@ -681,30 +1084,70 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 			}
 			bpfRTMgr.setHostIPUpdatesCallBack(kp.OnHostIPsUpdate)
 			bpfRTMgr.setRoutesCallBacks(kp.OnRouteUpdate, kp.OnRouteDelete)
-			conntrackScanner.AddUnlocked(conntrack.NewStaleNATScanner(kp))
-			conntrackScanner.Start()
+			conntrackScanner.Add(conntrack.NewStaleNATScanner(kp))
+			conntrackScanner.TriggerScan()
 		} else {
 			log.Info("BPF enabled but no Kubernetes client available, unable to run kube-proxy module.")
 		}
 
 		if config.BPFConnTimeLBEnabled {
 			// Activate the connect-time load balancer.
-			err = nat.InstallConnectTimeLoadBalancer(frontendMap, backendMap, routeMap, config.BPFCgroupV2, config.BPFLogLevel)
+			err = nat.InstallConnectTimeLoadBalancer(frontendMap, backendMap, routeMap,
+				config.BPFCgroupV2Root, config.BPFCgroupV2, config.BPFLogLevel)
 			if err != nil {
 				log.WithError(err).Panic("BPFConnTimeLBEnabled but failed to attach connect-time load balancer, bailing out.")
 			}
+			dp.verifyConnectTimeLoadBalancer()
 		} else {
 			// Deactivate the connect-time load balancer.
-			err = nat.RemoveConnectTimeLoadBalancer(config.BPFCgroupV2)
+			err = nat.RemoveConnectTimeLoadBalancer(config.BPFCgroupV2Root, config.BPFCgroupV2)
 			if err != nil {
 				log.WithError(err).Warn("Failed to detach connect-time load balancer. Ignoring.")
 			}
 		}
+
+		// Without CTLB, host-originated traffic to cluster IPs needs to be routed via the host NAT veth
+		// pair.  With CTLB, the manager cleans up.
+		hostNATRouteRules, err := routerule.New(
+			4,
+			bpfHostNATRulePriority,
+			set.From(config.BPFHostNATTableIndex),
+			routerule.RulesMatchDstIifTable,
+			routerule.RulesMatchDstIifTable,
+			config.NetlinkTimeout,
+			func() (routerule.HandleIface, error) {
+				return netlinkshim.NewRealNetlink()
+			},
+			dp.loopSummarizer,
+		)
+		if err != nil {
+			log.WithError(err).Warn("Unable to create routing rules for BPF host NAT; host access to " +
+				"cluster IPs will require the connect-time load balancer.")
+		} else {
+			hostNATRouteTable := routetable.New([]string{"^" + bpfHostNATIfaceIn + "$"}, 4, false,
+				config.NetlinkTimeout, nil, config.DeviceRouteProtocol, true, config.BPFHostNATTableIndex,
+				dp.loopSummarizer)
+			dp.RegisterManager(newBPFHostNATManager(
+				!config.BPFConnTimeLBEnabled,
+				config.BPFHostNATTableIndex,
+				hostNATRouteTable,
+				hostNATRouteRules,
+			))
+		}
 	}
 
-	routeTableV4 := routetable.New(interfaceRegexes, 4, false, config.NetlinkTimeout,
+	routeTableV4 := dryRun.routeTable("ipv4", 4, routetable.New(interfaceRegexes, 4, false, config.NetlinkTimeout,
 		config.DeviceRouteSourceAddress, config.DeviceRouteProtocol, config.RemoveExternalRoutes, 0,
-		dp.loopSummarizer)
+		dp.loopSummarizer))
+	if config.RouteTableDebug != nil {
+		config.RouteTableDebug.Register("ipv4", routeTableV4)
+	}
+
+	wlIfaceSettings := config.WorkloadIfaceSettings
+	if wlIfaceSettings.DisableTXChecksumOffloadIfBroken && iptablesFeatures.ChecksumOffloadBroken {
+		log.Info("Kernel has broken checksum offload, disabling TX checksum offload on workload interfaces.")
+		wlIfaceSettings.DisableTXChecksumOffload = true
+	}
 
 	epManager := newEndpointManager(
 		rawTableV4,
@@ -715,18 +1158,50 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 		4,
 		epMarkMapper,
 		config.RulesConfig.KubeIPVSSupportEnabled,
+		config.RulesConfig.WorkloadUntrackedPolicyEnabled,
 		config.RulesConfig.WorkloadIfacePrefixes,
+		wlIfaceSettings,
 		dp.endpointStatusCombiner.OnEndpointStatusUpdate,
 		config.BPFEnabled,
 		bpfEndpointManager,
 		callbacks)
 	dp.RegisterManager(epManager)
 	dp.endpointsSourceV4 = epManager
+	if config.RulesConfig.EndpointToHostActionOverridesEnabled {
+		dp.RegisterManager(newEndpointToHostActionManager(filterTableV4, ruleRenderer,
+			config.RulesConfig.EndpointToHostAction))
+	}
 	dp.RegisterManager(newFloatingIPManager(natTableV4, ruleRenderer, 4))
 	dp.RegisterManager(newMasqManager(ipSetsV4, natTableV4, ruleRenderer, config.MaxIPSetSize, 4))
+	if config.RulesConfig.EgressSNATEnabled {
+		dp.RegisterManager(newEgressSNATManager(natTableV4, ruleRenderer,
+			config.EgressSNATAddresses, config.EgressSNATNamespaceAddresses))
+	}
+	if config.RulesConfig.WorkloadAllowedSourcesEnabled {
+		dp.RegisterManager(newWorkloadAllowedSourcesManager(rawTableV4, ruleRenderer, 4))
+	}
+	if config.RulesConfig.WorkloadEgressAllowlistEnabled {
+		dp.RegisterManager(newWorkloadEgressAllowlistManager(filterTableV4, ipSetsV4, ruleRenderer,
+			config.WorkloadEgressAllowlistMaxSize, 4))
+	}
+	if workloadLinkLocalEnabled(config) {
+		dp.RegisterManager(newWorkloadLinkLocalManager(filterTableV4, ruleRenderer,
+			config.WorkloadLinkLocalExceptNS, 4))
+	}
+	if config.RulesConfig.ExternalNetworksEnabled {
+		dp.RegisterManager(newExternalNetworksManager(ipSetsV4, config.MaxIPSetSize,
+			config.ExternalNetworks, config.ExternalNetworkTreatments, 4))
+	}
+	if config.RulesConfig.WorkloadMACEnforcementEnabled {
+		dp.RegisterManager(newWorkloadMACManager(rawTableV4, ruleRenderer, config.WorkloadMACEnforcementByDefault))
+	}
+	if config.NeighborGCTuningEnabled {
+		dp.RegisterManager(newNeighGCManager(4, config.RulesConfig.WorkloadIfacePrefixes, config.NeighborGCStaleTime))
+	}
 	if config.RulesConfig.IPIPEnabled {
-		// Add a manger to keep the all-hosts IP set up to date.
-		dp.ipipManager = newIPIPManager(ipSetsV4, config.MaxIPSetSize, config.ExternalNodesCidrs)
+		// Add a manger to keep the all-hosts IP set up to date and, optionally, to program
+		// the IPIP routes.
+		dp.ipipManager = newIPIPManager(ipSetsV4, config, dp.loopSummarizer)
 		dp.RegisterManager(dp.ipipManager) // IPv4-only
 	}
 
@@ -743,56 +1218,66 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 		},
 		dp.loopSummarizer)
 	dp.wireguardManager = newWireguardManager(cryptoRouteTableWireguard, config)
-	dp.RegisterManager(dp.wireguardManager) // IPv4-only
+	dp.registerDeferredManager(dp.wireguardManager) // IPv4-only
+
+	if !config.BPFEnabled {
+		// In BPF mode, the BPF programs handle service traffic and nothing hooks the FORWARD chain.
+		dp.RegisterManager(newServiceLoopManager(filterTableV4, ruleRenderer, 4))
+	}
+
+	if config.ServiceRoutesEnabled {
+		routeTableServices := dryRun.routeTable("services", 4, routetable.New(
+			[]string{"^" + config.ServiceRoutesDevice + "$"}, 4, false,
+			config.NetlinkTimeout, config.DeviceRouteSourceAddress, config.DeviceRouteProtocol, true, 0,
+			dp.loopSummarizer))
+		serviceRouteManager := newServiceRouteManager(routeTableServices, config.ServiceRoutesDevice, 4)
+		go serviceRouteManager.KeepServiceRouteDeviceInSync(10 * time.Second)
+		dp.RegisterManager(serviceRouteManager) // IPv4-only
+
+		if config.KubeClientSet != nil {
+			// Watch services and endpoints so that we can program routes for the services that have
+			// local endpoints.
+			opts := []bpfproxy.Option{bpfproxy.WithMinSyncPeriod(config.KubeProxyMinSyncPeriod)}
+			if config.KubeProxyEndpointSlicesEnabled {
+				opts = append(opts, bpfproxy.WithEndpointsSlices())
+			}
+			_, err := bpfproxy.New(config.KubeClientSet, newLocalServiceIPsSyncer(dp.onLocalServiceIPsChange),
+				config.Hostname, opts...)
+			if err != nil {
+				// Service routes are optional; don't take down the rest of the dataplane.
+				log.WithError(err).Error(
+					"Failed to start service watcher for service routes, only programming service CIDRs.")
+			}
+		} else {
+			log.Info("Service routes enabled but no Kubernetes client available, only programming service CIDRs.")
+		}
+	} else {
+		cleanUpServiceRouteDevice(config.ServiceRoutesDevice)
+	}
 
-	dp.RegisterManager(newServiceLoopManager(filterTableV4, ruleRenderer, 4))
+	dp.RegisterManager(newStaticRouteManager(4, config, dp.loopSummarizer))
 
 	if config.IPv6Enabled {
-		mangleTableV6 := iptables.NewTable(
-			"mangle",
-			6,
-			rules.RuleHashPrefix,
-			iptablesLock,
-			featureDetector,
-			iptablesOptions,
-		)
-		natTableV6 := iptables.NewTable(
-			"nat",
-			6,
-			rules.RuleHashPrefix,
-			iptablesLock,
-			featureDetector,
-			iptablesNATOptions,
-		)
-		rawTableV6 := iptables.NewTable(
-			"raw",
-			6,
-			rules.RuleHashPrefix,
-			iptablesLock,
-			featureDetector,
-			iptablesOptions,
-		)
-		filterTableV6 := iptables.NewTable(
-			"filter",
-			6,
-			rules.RuleHashPrefix,
-			iptablesLock,
-			featureDetector,
-			iptablesOptions,
-		)
+		mangleTableV6 := newTable("mangle", 6, iptablesOptions)
+		natTableV6 := newTable("nat", 6, iptablesNATOptions)
+		rawTableV6 := newTable("raw", 6, iptablesOptions)
+		filterTableV6 := newTable("filter", 6, filterOptions(6))
 
 		ipSetsConfigV6 := config.RulesConfig.IPSetConfigV6
-		ipSetsV6 := ipsets.NewIPSets(ipSetsConfigV6, dp.loopSummarizer)
+		ipSetsV6 := dryRun.ipSets(6, newIPSetsDataplane(ipSetsConfigV6, useNFTSets, config.ThreadSched, dp.loopSummarizer))
 		dp.ipSets = append(dp.ipSets, ipSetsV6)
 		dp.iptablesNATTables = append(dp.iptablesNATTables, natTableV6)
 		dp.iptablesRawTables = append(dp.iptablesRawTables, rawTableV6)
 		dp.iptablesMangleTables = append(dp.iptablesMangleTables, mangleTableV6)
 		dp.iptablesFilterTables = append(dp.iptablesFilterTables, filterTableV6)
 
-		routeTableV6 := routetable.New(
+		routeTableV6 := dryRun.routeTable("ipv6", 6, routetable.New(
 			interfaceRegexes, 6, false, config.NetlinkTimeout,
 			config.DeviceRouteSourceAddress, config.DeviceRouteProtocol, config.RemoveExternalRoutes, 0,
-			dp.loopSummarizer)
+			dp.loopSummarizer))
+		if config.RouteTableDebug != nil {
+			config.RouteTableDebug.Register("ipv6", routeTableV6)
+		}
 
 		if !config.BPFEnabled {
 			dp.RegisterManager(newIPSetsManager(ipSetsV6, config.MaxIPSetSize))
@@ -801,7 +1286,11 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 				rules.IPSetIDThisHostIPs,
 				ipSetsV6,
 				config.MaxIPSetSize))
+			if config.RulesConfig.KubeIPVSSupportEnabled {
+				dp.RegisterManager(newKubeIPVSServiceIPManager(rules.IPSetIDKubeIPVSServiceIPs, ipSetsV6, config.MaxIPSetSize))
+			}
 			dp.RegisterManager(newPolicyManager(rawTableV6, mangleTableV6, filterTableV6, ruleRenderer, 6))
+			dp.RegisterManager(newPolicyScheduleManager(rawTableV6, mangleTableV6, filterTableV6, ruleRenderer, 6))
 		}
 		dp.RegisterManager(newEndpointManager(
 			rawTableV6,
@@ -812,14 +1301,114 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 			6,
 			epMarkMapper,
 			config.RulesConfig.KubeIPVSSupportEnabled,
+			config.RulesConfig.WorkloadUntrackedPolicyEnabled,
 			config.RulesConfig.WorkloadIfacePrefixes,
+			wlIfaceSettings,
 			dp.endpointStatusCombiner.OnEndpointStatusUpdate,
 			config.BPFEnabled,
 			nil,
 			callbacks))
+		if config.RulesConfig.EndpointToHostActionOverridesEnabled {
+			dp.RegisterManager(newEndpointToHostActionManager(filterTableV6, ruleRenderer,
+				config.RulesConfig.EndpointToHostAction))
+		}
 		dp.RegisterManager(newFloatingIPManager(natTableV6, ruleRenderer, 6))
+		dp.RegisterManager(newStaticRouteManager(6, config, dp.loopSummarizer))
 		dp.RegisterManager(newMasqManager(ipSetsV6, natTableV6, ruleRenderer, config.MaxIPSetSize, 6))
-		dp.RegisterManager(newServiceLoopManager(filterTableV6, ruleRenderer, 6))
+		if config.RulesConfig.WorkloadAllowedSourcesEnabled {
+			dp.RegisterManager(newWorkloadAllowedSourcesManager(rawTableV6, ruleRenderer, 6))
+		}
+		if config.RulesConfig.WorkloadEgressAllowlistEnabled {
+			dp.RegisterManager(newWorkloadEgressAllowlistManager(filterTableV6, ipSetsV6, ruleRenderer,
+				config.WorkloadEgressAllowlistMaxSize, 6))
+		}
+		if workloadLinkLocalEnabled(config) {
+			dp.RegisterManager(newWorkloadLinkLocalManager(filterTableV6, ruleRenderer,
+				config.WorkloadLinkLocalExceptNS, 6))
+		}
+		if config.RulesConfig.ExternalNetworksEnabled {
+			dp.RegisterManager(newExternalNetworksManager(ipSetsV6, config.MaxIPSetSize,
+				config.ExternalNetworks, config.ExternalNetworkTreatments, 6))
+		}
+		if config.RulesConfig.WorkloadMACEnforcementEnabled {
+			dp.RegisterManager(newWorkloadMACManager(rawTableV6, ruleRenderer, config.WorkloadMACEnforcementByDefault))
+		}
+		if config.NeighborGCTuningEnabled {
+			dp.RegisterManager(newNeighGCManager(6, config.RulesConfig.WorkloadIfacePrefixes, config.NeighborGCStaleTime))
+		}
+		if !config.BPFEnabled {
+			dp.RegisterManager(newServiceLoopManager(filterTableV6, ruleRenderer, 6))
+		}
+	}
+
+	if config.CNIReadinessGateEnabled {
+		// In BPF mode, traffic from workloads is dropped until the BPF programs are attached so
+		// we only need the gate to hold off readiness.
+		var gateTables []iptablesTable
+		if !config.BPFEnabled {
+			for _, t := range dp.iptablesFilterTables {
+				gateTables = append(gateTables, t)
+			}
+		}
+		dp.cniGate = newCNIGateManager(gateTables, ruleRenderer,
+			config.CNINetDir, config.CNIBinDir, config.CNIPluginBinaries)
+		dp.RegisterManager(dp.cniGate)
+	}
+
+	if config.RulesConfig.WorkloadDrainSupportEnabled {
+		var drainTables []iptablesTable
+		for _, t := range dp.iptablesFilterTables {
+			drainTables = append(drainTables, t)
+		}
+		ipVersions := []uint8{4}
+		if config.IPv6Enabled {
+			ipVersions = append(ipVersions, 6)
+		}
+		dp.workloadDrain = newWorkloadDrainManager(drainTables, ruleRenderer, ipVersions,
+			config.WorkloadDrainEnabled, config.WorkloadDrainFile)
+		dp.RegisterManager(dp.workloadDrain)
+	}
+
+	if config.RulesConfig.WorkloadConnRateLimitEnabled {
+		var limitTables []ruleCounterTable
+		for _, t := range dp.iptablesFilterTables {
+			limitTables = append(limitTables, t)
+		}
+		dp.connRateLimit = newWorkloadConnRateLimitManager(limitTables, ruleRenderer,
+			config.WorkloadConnRateLimit, config.WorkloadConnRateLimitBurst)
+		dp.RegisterManager(dp.connRateLimit)
+	}
+
+	if config.ConntrackAccountingEnabled {
+		ipVersions := []uint8{4}
+		if config.IPv6Enabled {
+			ipVersions = append(ipVersions, 6)
+		}
+		dp.conntrackAcct = newConntrackAccountingManager(ipVersions, config.ConntrackAccountingTopN)
+		dp.RegisterManager(dp.conntrackAcct)
+	}
+
+	if config.FlowLogsSink != nil {
+		var listFlows func() ([]flowlog.Flow, error)
+		if bpfFlowReader != nil {
+			listFlows = bpfFlowReader.Flows
+		} else {
+			ipVersions := []uint8{4}
+			if config.IPv6Enabled {
+				ipVersions = append(ipVersions, 6)
+			}
+			listFlows = linuxConntrackFlows(ipVersions, func(family netlink.InetFamily) ([]*netlink.ConntrackFlow, error) {
+				return netlink.ConntrackTableList(netlink.ConntrackTable, family)
+			})
+		}
+		dp.flowLogs = newFlowLogManager(config.FlowLogsSink, listFlows, time.Now)
+		dp.RegisterManager(dp.flowLogs)
+	}
+
+	if config.DropCapture != nil {
+		dp.RegisterManager(newDropCaptureManager(config.DropCapture))
+		dp.dropCaptureReader = dropcapture.NewNFLOGReader(config.RulesConfig.DropCaptureNFLOGGroup,
+			config.DropCaptureSnapLength, config.DropCapture)
 	}
 
 	dp.allIptablesTables = append(dp.allIptablesTables, dp.iptablesMangleTables...)
@@ -835,6 +1424,8 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 			&health.HealthReport{Live: true, Ready: true},
 			healthInterval*2,
 		)
+		config.HealthAggregator.RegisterReporter(iptablesHealthName, &health.HealthReport{Ready: true}, 0)
+		config.HealthAggregator.Report(iptablesHealthName, &health.HealthReport{Ready: true})
 	}
 
 	if config.DebugSimulateDataplaneHangAfter != 0 {
@@ -843,6 +1434,16 @@ func NewIntDataplaneDriver(config Config) *InternalDataplane {
 		dp.debugHangC = time.After(config.DebugSimulateDataplaneHangAfter)
 	}
 
+	if config.CrashDumpDir != "" {
+		var iptablesSaveCmds []string
+		for _, t := range dp.iptablesFilterTables {
+			if t, ok := t.(*iptables.Table); ok {
+				iptablesSaveCmds = append(iptablesSaveCmds, t.SaveCommand())
+			}
+		}
+		dp.crashDumper = newCrashDumper(config, iptablesSaveCmds, useNFTSets)
+	}
+
 	return dp
 }
 
@@ -942,25 +1543,43 @@ func ConfigureDefaultMTUs(hostMTU int, c *Config) {
 		c.VXLANMTU = hostMTU - vxlanMTUOverhead
 	}
 	if c.Wireguard.MTU == 0 {
-		if c.KubernetesProvider == config.ProviderAKS && c.RouteSource == "WorkloadIPs" {
-			// The default MTU on Azure is 1500, but the underlying network stack will fragment packets at 1400 bytes,
-			// see https://docs.microsoft.com/en-us/azure/virtual-network/virtual-network-tcpip-performance-tuning#azure-and-vm-mtu
-			// for details.
-			// Additionally, Wireguard sets the DF bit on its packets, and so if the MTU is set too high large packets
-			// will be dropped. Therefore it is necessary to allow for the difference between the MTU of the host and
-			// the underlying network.
-			log.Debug("Defaulting Wireguard MTU based on host and AKS with WorkloadIPs")
-			c.Wireguard.MTU = hostMTU - aksMTUOverhead - wireguardMTUOverhead
-		} else {
-			log.Debug("Defaulting Wireguard MTU based on host")
-			c.Wireguard.MTU = hostMTU - wireguardMTUOverhead
-		}
+		c.wireguardMTUAuto = true
+		c.Wireguard.MTU = wireguardMTUForHostMTU(hostMTU, c)
 	}
 }
 
+// wireguardMTUForHostMTU returns the wireguard MTU to use when it isn't configured explicitly.
+func wireguardMTUForHostMTU(hostMTU int, c *Config) int {
+	if c.KubernetesProvider == config.ProviderAKS && c.RouteSource == "WorkloadIPs" {
+		// The default MTU on Azure is 1500, but the underlying network stack will fragment packets at 1400 bytes,
+		// see https://docs.microsoft.com/en-us/azure/virtual-network/virtual-network-tcpip-performance-tuning#azure-and-vm-mtu
+		// for details.
+		// Additionally, Wireguard sets the DF bit on its packets, and so if the MTU is set too high large packets
+		// will be dropped. Therefore it is necessary to allow for the difference between the MTU of the host and
+		// the underlying network.
+		log.Debug("Defaulting Wireguard MTU based on host and AKS with WorkloadIPs")
+		return hostMTU - aksMTUOverhead - wireguardMTUOverhead
+	}
+	log.Debug("Defaulting Wireguard MTU based on host")
+	return hostMTU - wireguardMTUOverhead
+}
+
 func cleanUpVXLANDevice() {
-	// If VXLAN is not enabled, check to see if there is a VXLAN device and delete it if there is.
-	log.Debug("Checking if we need to clean up the VXLAN device")
+	// If VXLAN is not enabled, check to see if there are VXLAN devices and delete them if there are.
+	log.Debug("Checking if we need to clean up the VXLAN devices")
+	if links, err := netlink.LinkList(); err != nil {
+		log.WithError(err).Warn("VXLAN disabled and failed to list devices.  Ignoring.")
+	} else {
+		for _, link := range links {
+			if !vxlanPoolDeviceRegexp.MatchString(link.Attrs().Name) {
+				continue
+			}
+			if err := netlink.LinkDel(link); err != nil {
+				log.WithError(err).WithField("device", link.Attrs().Name).Error(
+					"VXLAN disabled and failed to delete unwanted VXLAN device. Ignoring.")
+			}
+		}
+	}
 	link, err := netlink.LinkByName("vxlan.calico")
 	if err != nil {
 		if _, ok := err.(netlink.LinkNotFoundError); ok {
@@ -975,6 +1594,23 @@ func cleanUpVXLANDevice() {
 	}
 }
 
+// Manager is the interface of the dataplane's managers.  The main loop calls OnUpdate and
+// CompleteDeferredWork on all managers, from its own goroutine, in the order that the managers
+// were registered.  In each apply, CompleteDeferredWork is called on all managers before the
+// IP sets, route tables and iptables tables are written, in that order (route tables are written
+// in parallel with IP sets and iptables).
+//
+// With deferred startup enabled, the first apply only programs what policy depends on:
+//
+//   - Deferred managers (see registerDeferredManager) still get OnUpdate and
+//     CompleteDeferredWork calls so that IP sets that they maintain, which rules may refer to,
+//     are programmed in the first apply.  However, their route tables aren't applied until the
+//     deferred startup tasks have finished.
+//   - Startup tasks (see addStartupTask) are run concurrently with each other, and with the main
+//     loop, after the first apply.  They must not touch state that is owned by the main loop.
+//
+// Without deferred startup, startup tasks run synchronously when they are added and deferred
+// managers behave like any other manager.
 type Manager interface {
 	// OnUpdate is called for each protobuf message from the datastore.  May either directly
 	// send updates to the IPSets and iptables.Table objects (which will queue the updates
@@ -1000,6 +1636,64 @@ func (d *InternalDataplane) routeTableSyncers() []routeTableSyncer {
 	return rts
 }
 
+// activeRouteTableSyncers returns the route tables that should be applied, which excludes those
+// of deferred managers until the deferred startup tasks have finished.
+func (d *InternalDataplane) activeRouteTableSyncers() []routeTableSyncer {
+	var rts []routeTableSyncer
+	for _, mrts := range d.managersWithRouteTables {
+		if !d.deferredStarted && d.deferredManagers.Contains(mrts) {
+			continue
+		}
+		rts = append(rts, mrts.GetRouteTableSyncers()...)
+	}
+
+	return rts
+}
+
+// registerDeferredManager registers a manager for a subsystem that policy doesn't depend on, such
+// as a tunnel or encryption.  With deferred startup, its route tables aren't applied until the
+// deferred startup tasks have finished.
+func (d *InternalDataplane) registerDeferredManager(mgr ManagerWithRouteTables) {
+	d.deferredManagers.Add(mgr)
+	d.RegisterManager(mgr)
+}
+
+type startupTask struct {
+	name string
+	run  func()
+}
+
+// addStartupTask runs the start-of-day work of a subsystem that policy doesn't depend on.  With
+// deferred startup, the task is deferred until after the first apply, otherwise it runs now.
+func (d *InternalDataplane) addStartupTask(name string, run func()) {
+	if !d.config.DeferredStartupEnabled {
+		run()
+		return
+	}
+	log.WithField("task", name).Debug("Deferring startup task until after the first apply.")
+	d.deferredStartupTasks = append(d.deferredStartupTasks, startupTask{name: name, run: run})
+}
+
+// runDeferredStartupTasks runs the deferred startup tasks concurrently then signals the main loop.
+func (d *InternalDataplane) runDeferredStartupTasks(tasks []startupTask) {
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, t := range tasks {
+		wg.Add(1)
+		go func(t startupTask) {
+			defer wg.Done()
+			t.run()
+			log.WithField("task", t.name).Debug("Deferred startup task finished.")
+		}(t)
+	}
+	wg.Wait()
+	log.WithFields(log.Fields{
+		"numTasks": len(tasks),
+		"duration": time.Since(start),
+	}).Info("Deferred startup tasks finished.")
+	d.deferredStartupDone <- struct{}{}
+}
+
 func (d *InternalDataplane) RegisterManager(mgr Manager) {
 	switch mgr := mgr.(type) {
 	case ManagerWithRouteTables:
@@ -1015,11 +1709,58 @@ func (d *InternalDataplane) Start() {
 	// Do our start-of-day configuration.
 	d.doStaticDataplaneConfig()
 
+	if d.crashDumper != nil {
+		// Fatal logs don't unwind the stack so they need a separate hook from panics.
+		log.RegisterExitHandler(func() {
+			d.crashDumper.Dump("fatal error logged")
+		})
+	}
+
 	// Then, start the worker threads.
 	go d.loopUpdatingDataplane()
 	go d.loopReportingStatus()
 	go d.ifaceMonitor.MonitorInterfaces()
 	go d.monitorHostMTU()
+	if d.dropCaptureReader != nil {
+		d.dropCaptureReader.Start()
+	}
+	if d.kubeProxyCleaner != nil {
+		go d.kubeProxyCleaner.KeepCleaningUp(kubeProxyCleanupInterval)
+	}
+	if d.kubeProxyMigrationGuard != nil {
+		go d.waitForKubeProxyMigration()
+	}
+}
+
+// waitForKubeProxyMigration reports the kube-proxy migration status until kube-proxy has been
+// removed.  If we deferred service NAT to kube-proxy, we then restart so that we start up with BPF
+// NAT and clean up after kube-proxy.
+func (d *InternalDataplane) waitForKubeProxyMigration() {
+	d.kubeProxyMigrationGuard.WaitForMigration(kubeProxyMigrationCheckInterval)
+	if d.kubeProxyNATDeferred {
+		log.WithFields(log.Fields{lclogutils.FieldForceFlush: true}).Info(
+			"kube-proxy removed, restarting Felix to take over service NAT.")
+		d.config.ConfigChangedRestartCallback()
+	}
+}
+
+// newIPSetsDataplane returns the IP sets implementation for the given IP version, using nftables
+// sets if the kernel lacks ipset support.  The commands that it runs get the given scheduling
+// settings.
+func newIPSetsDataplane(
+	ipVersionConfig *ipsets.IPVersionConfig,
+	useNFTSets bool,
+	threadSched ThreadSchedConfig,
+	recorder logutils.OpRecorder,
+) ipsetsDataplane {
+	newCmd := ipsets.NewRealCmd
+	if !threadSched.IsDefault() {
+		newCmd = threadSched.ipsetsCmdFactory()
+	}
+	if useNFTSets {
+		return ipsets.NewNFTSetsWithShims(ipVersionConfig, recorder, newCmd, time.Sleep)
+	}
+	return ipsets.NewIPSetsWithShims(ipVersionConfig, recorder, newCmd, time.Sleep)
 }
 
 // onIfaceStateChange is our interface monitor callback.  It gets called from the monitor's thread.
@@ -1075,6 +1816,56 @@ type ifaceAddrsUpdate struct {
 	Addrs set.Set
 }
 
+// onExcludedIfaceAddrsChange is our callback for the addresses of the interfaces that the interface
+// monitor excludes.  We only care about kube-ipvs0, which holds the IPVS service IPs.  It gets
+// called from the monitor's thread.
+func (d *InternalDataplane) onExcludedIfaceAddrsChange(ifaceName string, addrs set.Set) {
+	if ifaceName != KubeIPVSInterface {
+		return
+	}
+	log.WithField("addrs", addrs).Debug("kube-ipvs0 addrs changed.")
+	d.kubeIPVSAddrUpdates <- &kubeIPVSAddrsUpdate{Addrs: addrs}
+}
+
+// onIfaceMTUChange is our interface MTU monitor callback.  We only pass on the MTUs of the
+// interfaces that MTU auto-detection looks at.  It gets called from the monitor's thread.
+func (d *InternalDataplane) onIfaceMTUChange(ifaceName string, mtu int) {
+	if d.config.MTUIfacePattern == nil || !d.config.MTUIfacePattern.MatchString(ifaceName) {
+		return
+	}
+	log.WithFields(log.Fields{
+		"ifaceName": ifaceName,
+		"mtu":       mtu,
+	}).Info("Linux interface MTU changed.")
+	d.ifaceMTUUpdates <- &ifaceMTUUpdate{
+		Name: ifaceName,
+		MTU:  mtu,
+	}
+}
+
+// ifaceMTUUpdate reports the MTU of an interface; MTU 0 means that the interface has gone.
+type ifaceMTUUpdate struct {
+	Name string
+	MTU  int
+}
+
+// onLocalServiceIPsChange is our local service IPs callback.  It gets called from the service
+// watcher's thread.
+func (d *InternalDataplane) onLocalServiceIPsChange(ips []ip.Addr) {
+	log.WithField("ips", ips).Info("Service IPs with local endpoints changed.")
+	update := &localServiceIPsUpdate{IPs: ips}
+	select {
+	case d.localServiceUpdates <- update:
+	default:
+		// The main loop hasn't consumed the previous update yet, replace it with the newer one.
+		select {
+		case <-d.localServiceUpdates:
+		default:
+		}
+		d.localServiceUpdates <- update
+	}
+}
+
 func (d *InternalDataplane) SendMessage(msg interface{}) error {
 	d.toDataplane <- msg
 	return nil
@@ -1114,10 +1905,12 @@ func (d *InternalDataplane) doStaticDataplaneConfig() {
 
 	if d.config.RulesConfig.IPIPEnabled {
 		log.Info("IPIP enabled, starting thread to keep tunnel configuration in sync.")
-		go d.ipipManager.KeepIPIPDeviceInSync(
-			d.config.IPIPMTU,
-			d.config.RulesConfig.IPIPTunnelAddress,
-		)
+		d.addStartupTask("ipip-device", func() {
+			go d.ipipManager.KeepIPIPDeviceInSync(
+				d.config.IPIPMTU,
+				d.config.RulesConfig.IPIPTunnelAddress,
+			)
+		})
 	} else {
 		log.Info("IPIP disabled. Not starting tunnel update thread.")
 	}
@@ -1178,7 +1971,15 @@ func (d *InternalDataplane) setUpIptablesBPF() {
 				},
 			)
 
-			if rulesConfig.EndpointToHostAction == "ACCEPT" {
+			if rulesConfig.EndpointToHostActionOverridesEnabled {
+				// Apply the (possibly per-workload) EndpointToHostAction.  Only ACCEPT has any
+				// effect here: DROP gets compiled into the BPF program and RETURN would be a no-op
+				// since there's nothing to RETURN from.
+				inputRules = append(inputRules, iptables.Rule{
+					Match:  iptables.Match().InInterface(prefix+"+").MarkMatchesWithMask(tc.MarkSeen, tc.MarkSeenMask),
+					Action: iptables.JumpAction{Target: d.ruleRenderer.ChainName(rules.ChainWorkloadToHostAction)},
+				})
+			} else if rulesConfig.EndpointToHostAction == "ACCEPT" {
 				// Only need to worry about ACCEPT here.  Drop gets compiled into the BPF program and
 				// RETURN would be a no-op since there's nothing to RETURN from.
 				inputRules = append(inputRules, iptables.Rule{
@@ -1194,7 +1995,7 @@ func (d *InternalDataplane) setUpIptablesBPF() {
 			})
 		}
 
-		if t.IPVersion == 6 {
+		if t.GetIPVersion() == 6 {
 			for _, prefix := range rulesConfig.WorkloadIfacePrefixes {
 				// In BPF mode, we don't support IPv6 yet.  Drop it.
 				fwdRules = append(fwdRules, iptables.Rule{
@@ -1224,7 +2025,7 @@ func (d *InternalDataplane) setUpIptablesBPF() {
 				fwdRules = append(fwdRules,
 					iptables.Rule{
 						Match:   iptables.Match().OutInterface(prefix + "+"),
-						Action:  iptables.JumpAction{Target: rules.ChainToWorkloadDispatch},
+						Action:  iptables.JumpAction{Target: d.ruleRenderer.ChainName(rules.ChainToWorkloadDispatch)},
 						Comment: []string{"To workload, check workload is known."},
 					},
 				)
@@ -1251,9 +2052,9 @@ func (d *InternalDataplane) setUpIptablesBPF() {
 	}
 
 	for _, t := range d.iptablesNATTables {
-		t.UpdateChains(d.ruleRenderer.StaticNATPostroutingChains(t.IPVersion))
+		t.UpdateChains(d.ruleRenderer.StaticNATPostroutingChains(t.GetIPVersion()))
 		t.InsertOrAppendRules("POSTROUTING", []iptables.Rule{{
-			Action: iptables.JumpAction{Target: rules.ChainNATPostrouting},
+			Action: iptables.JumpAction{Target: d.ruleRenderer.ChainName(rules.ChainNATPostrouting)},
 		}})
 	}
 
@@ -1272,24 +2073,24 @@ func (d *InternalDataplane) setUpIptablesBPF() {
 		})
 
 		// Do the full RPF check and dis-allow accept_local for anything else.
-		rpfRules = append(rpfRules, rules.RPFilter(t.IPVersion, tc.MarkSeen, tc.MarkSeenMask,
+		rpfRules = append(rpfRules, rules.RPFilter(t.GetIPVersion(), tc.MarkSeen, tc.MarkSeenMask,
 			rulesConfig.OpenStackSpecialCasesEnabled, false)...)
 
 		rpfChain := []*iptables.Chain{{
-			Name:  rules.ChainNamePrefix + "RPF",
+			Name:  d.ruleRenderer.ChainName(rules.ChainNamePrefix + "RPF"),
 			Rules: rpfRules,
 		}}
 		t.UpdateChains(rpfChain)
 
 		var rawRules []iptables.Rule
-		if t.IPVersion == 4 && rulesConfig.WireguardEnabled && len(rulesConfig.WireguardInterfaceName) > 0 &&
+		if t.GetIPVersion() == 4 && rulesConfig.WireguardEnabled && len(rulesConfig.WireguardInterfaceName) > 0 &&
 			rulesConfig.RouteSource == "WorkloadIPs" {
 			// Set a mark on packets coming from any interface except for lo, wireguard, or pod veths to ensure the RPF
 			// check allows it.
 			log.Debug("Adding Wireguard iptables rule chain")
 			rawRules = append(rawRules, iptables.Rule{
 				Match:  nil,
-				Action: iptables.JumpAction{Target: rules.ChainSetWireguardIncomingMark},
+				Action: iptables.JumpAction{Target: d.ruleRenderer.ChainName(rules.ChainSetWireguardIncomingMark)},
 			})
 			t.UpdateChain(d.ruleRenderer.WireguardIncomingMarkChain())
 		}
@@ -1299,13 +2100,13 @@ func (d *InternalDataplane) setUpIptablesBPF() {
 		})
 
 		rawChains := []*iptables.Chain{{
-			Name:  rules.ChainRawPrerouting,
+			Name:  d.ruleRenderer.ChainName(rules.ChainRawPrerouting),
 			Rules: rawRules,
 		}}
 		t.UpdateChains(rawChains)
 
 		t.InsertOrAppendRules("PREROUTING", []iptables.Rule{{
-			Action: iptables.JumpAction{Target: rules.ChainRawPrerouting},
+			Action: iptables.JumpAction{Target: d.ruleRenderer.ChainName(rules.ChainRawPrerouting)},
 		}})
 	}
 
@@ -1326,50 +2127,50 @@ func (d *InternalDataplane) setUpIptablesBPF() {
 
 func (d *InternalDataplane) setUpIptablesNormal() {
 	for _, t := range d.iptablesRawTables {
-		rawChains := d.ruleRenderer.StaticRawTableChains(t.IPVersion)
+		rawChains := d.ruleRenderer.StaticRawTableChains(t.GetIPVersion())
 		t.UpdateChains(rawChains)
 		t.InsertOrAppendRules("PREROUTING", []iptables.Rule{{
-			Action: iptables.JumpAction{Target: rules.ChainRawPrerouting},
+			Action: iptables.JumpAction{Target: d.ruleRenderer.ChainName(rules.ChainRawPrerouting)},
 		}})
 		t.InsertOrAppendRules("OUTPUT", []iptables.Rule{{
-			Action: iptables.JumpAction{Target: rules.ChainRawOutput},
+			Action: iptables.JumpAction{Target: d.ruleRenderer.ChainName(rules.ChainRawOutput)},
 		}})
 	}
 	for _, t := range d.iptablesFilterTables {
-		filterChains := d.ruleRenderer.StaticFilterTableChains(t.IPVersion)
+		filterChains := d.ruleRenderer.StaticFilterTableChains(t.GetIPVersion())
 		t.UpdateChains(filterChains)
 		t.InsertOrAppendRules("FORWARD", []iptables.Rule{{
-			Action: iptables.JumpAction{Target: rules.ChainFilterForward},
+			Action: iptables.JumpAction{Target: d.ruleRenderer.ChainName(rules.ChainFilterForward)},
 		}})
 		t.InsertOrAppendRules("INPUT", []iptables.Rule{{
-			Action: iptables.JumpAction{Target: rules.ChainFilterInput},
+			Action: iptables.JumpAction{Target: d.ruleRenderer.ChainName(rules.ChainFilterInput)},
 		}})
 		t.InsertOrAppendRules("OUTPUT", []iptables.Rule{{
-			Action: iptables.JumpAction{Target: rules.ChainFilterOutput},
+			Action: iptables.JumpAction{Target: d.ruleRenderer.ChainName(rules.ChainFilterOutput)},
 		}})
 
 		// Include rules which should be appended to the filter table forward chain.
 		t.AppendRules("FORWARD", d.ruleRenderer.StaticFilterForwardAppendRules())
 	}
 	for _, t := range d.iptablesNATTables {
-		t.UpdateChains(d.ruleRenderer.StaticNATTableChains(t.IPVersion))
+		t.UpdateChains(d.ruleRenderer.StaticNATTableChains(t.GetIPVersion()))
 		t.InsertOrAppendRules("PREROUTING", []iptables.Rule{{
-			Action: iptables.JumpAction{Target: rules.ChainNATPrerouting},
+			Action: iptables.JumpAction{Target: d.ruleRenderer.ChainName(rules.ChainNATPrerouting)},
 		}})
 		t.InsertOrAppendRules("POSTROUTING", []iptables.Rule{{
-			Action: iptables.JumpAction{Target: rules.ChainNATPostrouting},
+			Action: iptables.JumpAction{Target: d.ruleRenderer.ChainName(rules.ChainNATPostrouting)},
 		}})
 		t.InsertOrAppendRules("OUTPUT", []iptables.Rule{{
-			Action: iptables.JumpAction{Target: rules.ChainNATOutput},
+			Action: iptables.JumpAction{Target: d.ruleRenderer.ChainName(rules.ChainNATOutput)},
 		}})
 	}
 	for _, t := range d.iptablesMangleTables {
-		t.UpdateChains(d.ruleRenderer.StaticMangleTableChains(t.IPVersion))
+		t.UpdateChains(d.ruleRenderer.StaticMangleTableChains(t.GetIPVersion()))
 		t.InsertOrAppendRules("PREROUTING", []iptables.Rule{{
-			Action: iptables.JumpAction{Target: rules.ChainManglePrerouting},
+			Action: iptables.JumpAction{Target: d.ruleRenderer.ChainName(rules.ChainManglePrerouting)},
 		}})
 		t.InsertOrAppendRules("POSTROUTING", []iptables.Rule{{
-			Action: iptables.JumpAction{Target: rules.ChainManglePostrouting},
+			Action: iptables.JumpAction{Target: d.ruleRenderer.ChainName(rules.ChainManglePostrouting)},
 		}})
 	}
 	if d.xdpState != nil {
@@ -1383,12 +2184,12 @@ func (d *InternalDataplane) setUpIptablesNormal() {
 }
 
 func stringToProtocol(protocol string) (labelindex.IPSetPortProtocol, error) {
-	switch protocol {
-	case "tcp":
+	switch strings.ToLower(protocol) {
+	case "tcp", "6":
 		return labelindex.ProtocolTCP, nil
-	case "udp":
+	case "udp", "17":
 		return labelindex.ProtocolUDP, nil
-	case "sctp":
+	case "sctp", "132":
 		return labelindex.ProtocolSCTP, nil
 	}
 	return labelindex.ProtocolNone, fmt.Errorf("unknown protocol %q", protocol)
@@ -1441,7 +2242,20 @@ func (d *InternalDataplane) shutdownXDPCompletely() error {
 }
 
 func (d *InternalDataplane) loopUpdatingDataplane() {
+	defer d.crashDumper.DumpOnPanic()
 	log.Info("Started internal iptables dataplane driver loop")
+	if !d.config.ThreadSched.IsDefault() {
+		// Pin the loop to its own thread so that the settings stick to it.  The commands that
+		// the tables and IP sets run get the settings via their command factories.  The loop
+		// never returns so there's no need to unlock.
+		runtime.LockOSThread()
+		if err := d.config.ThreadSched.ApplyToCurrentThread(); err != nil {
+			log.WithError(err).Error("Failed to apply scheduling settings to dataplane thread, " +
+				"continuing with the defaults.")
+		} else {
+			log.WithField("config", d.config.ThreadSched).Info("Applied scheduling settings to dataplane thread.")
+		}
+	}
 	healthTicks := time.NewTicker(healthInterval).C
 	d.reportHealth()
 
@@ -1449,35 +2263,63 @@ func (d *InternalDataplane) loopUpdatingDataplane() {
 	retryTicker := time.NewTicker(10 * time.Second)
 
 	// If configured, start tickers to refresh the IP sets and routing table entries.
-	var ipSetsRefreshC <-chan time.Time
-	if d.config.IPSetsRefreshInterval > 0 {
-		log.WithField("interval", d.config.IptablesRefreshInterval).Info(
-			"Will refresh IP sets on timer")
+	d.ipSetsRefreshTimer.Reset("IP sets", d.config.IPSetsRefreshInterval)
+	d.routeRefreshTimer.Reset("routes", d.config.RouteRefreshInterval)
+	if d.xdpState != nil {
+		d.xdpRefreshTimer.Reset("XDP", d.config.XDPRefreshInterval)
+	}
+	var workloadDrainC <-chan time.Time
+	if d.workloadDrain != nil && d.config.WorkloadDrainPollInterval > 0 {
+		log.WithField("interval", d.config.WorkloadDrainPollInterval).Info(
+			"Will poll workload drain state on timer")
 		refreshTicker := jitter.NewTicker(
-			d.config.IPSetsRefreshInterval,
-			d.config.IPSetsRefreshInterval/10,
+			d.config.WorkloadDrainPollInterval,
+			d.config.WorkloadDrainPollInterval/10,
 		)
-		ipSetsRefreshC = refreshTicker.C
+		workloadDrainC = refreshTicker.C
 	}
-	var routeRefreshC <-chan time.Time
-	if d.config.RouteRefreshInterval > 0 {
-		log.WithField("interval", d.config.RouteRefreshInterval).Info(
-			"Will refresh routes on timer")
+	var conntrackAcctC <-chan time.Time
+	if d.conntrackAcct != nil && d.config.ConntrackAccountingInterval > 0 {
+		log.WithField("interval", d.config.ConntrackAccountingInterval).Info(
+			"Will sample conntrack entries for local workloads on timer")
 		refreshTicker := jitter.NewTicker(
-			d.config.RouteRefreshInterval,
-			d.config.RouteRefreshInterval/10,
+			d.config.ConntrackAccountingInterval,
+			d.config.ConntrackAccountingInterval/10,
 		)
-		routeRefreshC = refreshTicker.C
+		conntrackAcctC = refreshTicker.C
 	}
-	var xdpRefreshC <-chan time.Time
-	if d.config.XDPRefreshInterval > 0 && d.xdpState != nil {
-		log.WithField("interval", d.config.XDPRefreshInterval).Info(
-			"Will refresh XDP on timer")
+	var flowLogsC <-chan time.Time
+	if d.flowLogs != nil && d.config.FlowLogsFlushInterval > 0 {
+		log.WithField("interval", d.config.FlowLogsFlushInterval).Info(
+			"Will flush flow logs on timer")
 		refreshTicker := jitter.NewTicker(
-			d.config.XDPRefreshInterval,
-			d.config.XDPRefreshInterval/10,
+			d.config.FlowLogsFlushInterval,
+			d.config.FlowLogsFlushInterval/10,
 		)
-		xdpRefreshC = refreshTicker.C
+		flowLogsC = refreshTicker.C
+	}
+	var bpfCountersC <-chan time.Time
+	if d.bpfCounters != nil {
+		log.WithField("interval", d.config.BPFCountersScrapeInterval).Info(
+			"Will scrape BPF counters on timer")
+		refreshTicker := jitter.NewTicker(
+			d.config.BPFCountersScrapeInterval,
+			d.config.BPFCountersScrapeInterval/10,
+		)
+		bpfCountersC = refreshTicker.C
+	}
+	var connRateLimitC <-chan time.Time
+	if d.connRateLimit != nil {
+		refreshTicker := jitter.NewTicker(
+			connRateLimitReportInterval,
+			connRateLimitReportInterval/10,
+		)
+		connRateLimitC = refreshTicker.C
+	}
+
+	var cpuBudgetC <-chan time.Time
+	if d.cpuBudget != nil {
+		cpuBudgetC = time.NewTicker(cpuBudgetSampleInterval).C
 	}
 
 	// Fill the apply throttle leaky bucket.
@@ -1493,7 +2335,9 @@ func (d *InternalDataplane) loopUpdatingDataplane() {
 		for _, mgr := range d.allManagers {
 			mgr.OnUpdate(msg)
 		}
-		switch msg.(type) {
+		switch msg := msg.(type) {
+		case *proto.ConfigUpdate:
+			d.onConfigUpdate(msg)
 		case *proto.InSync:
 			log.WithField("timeSinceStart", time.Since(processStartTime)).Info(
 				"Datastore in sync, flushing the dataplane for the first time...")
@@ -1526,15 +2370,24 @@ func (d *InternalDataplane) loopUpdatingDataplane() {
 		}
 	}
 
+	// Limits on the number of messages we'll try to grab from each channel before we apply the
+	// changes.  Higher values allow us to batch up more work on the channel for greater
+	// throughput when we're under load (at cost of higher latency) so the limits adapt to the
+	// backlog.
+	calcGraphBatchLimit := newMsgBatchLimit("to-dataplane", d.config.MaxMsgBatchSize)
+	ifaceBatchLimit := newMsgBatchLimit("iface-updates", d.config.MaxMsgBatchSize)
+	ifaceAddrBatchLimit := newMsgBatchLimit("iface-addr-updates", d.config.MaxMsgBatchSize)
+
 	for {
 		select {
 		case msg := <-d.toDataplane:
 			// Process the message we received, then opportunistically process any other
 			// pending messages.
+			calcGraphBatchLimit.OnBatchStart(len(d.toDataplane)+1, cap(d.toDataplane))
 			batchSize := 1
 			processMsgFromCalcGraph(msg)
 		msgLoop1:
-			for i := 0; i < msgPeekLimit; i++ {
+			for i := 0; i < calcGraphBatchLimit.Limit(); i++ {
 				select {
 				case msg := <-d.toDataplane:
 					processMsgFromCalcGraph(msg)
@@ -1544,15 +2397,17 @@ func (d *InternalDataplane) loopUpdatingDataplane() {
 					break msgLoop1
 				}
 			}
+			calcGraphBatchLimit.OnBatchDone(batchSize, len(d.toDataplane))
 			d.dataplaneNeedsSync = true
 			summaryBatchSize.Observe(float64(batchSize))
 		case ifaceUpdate := <-d.ifaceUpdates:
 			// Process the message we received, then opportunistically process any other
 			// pending messages.
+			ifaceBatchLimit.OnBatchStart(len(d.ifaceUpdates)+1, cap(d.ifaceUpdates))
 			batchSize := 1
 			processIfaceUpdate(ifaceUpdate)
 		msgLoop2:
-			for i := 0; i < msgPeekLimit; i++ {
+			for i := 0; i < ifaceBatchLimit.Limit(); i++ {
 				select {
 				case ifaceUpdate := <-d.ifaceUpdates:
 					processIfaceUpdate(ifaceUpdate)
@@ -1562,13 +2417,15 @@ func (d *InternalDataplane) loopUpdatingDataplane() {
 					break msgLoop2
 				}
 			}
+			ifaceBatchLimit.OnBatchDone(batchSize, len(d.ifaceUpdates))
 			d.dataplaneNeedsSync = true
 			summaryIfaceBatchSize.Observe(float64(batchSize))
 		case ifaceAddrsUpdate := <-d.ifaceAddrUpdates:
+			ifaceAddrBatchLimit.OnBatchStart(len(d.ifaceAddrUpdates)+1, cap(d.ifaceAddrUpdates))
 			batchSize := 1
 			processAddrsUpdate(ifaceAddrsUpdate)
 		msgLoop3:
-			for i := 0; i < msgPeekLimit; i++ {
+			for i := 0; i < ifaceAddrBatchLimit.Limit(); i++ {
 				select {
 				case ifaceAddrsUpdate := <-d.ifaceAddrUpdates:
 					processAddrsUpdate(ifaceAddrsUpdate)
@@ -1578,19 +2435,65 @@ func (d *InternalDataplane) loopUpdatingDataplane() {
 					break msgLoop3
 				}
 			}
+			ifaceAddrBatchLimit.OnBatchDone(batchSize, len(d.ifaceAddrUpdates))
 			summaryAddrBatchSize.Observe(float64(batchSize))
 			d.dataplaneNeedsSync = true
-		case <-ipSetsRefreshC:
-			log.Debug("Refreshing IP sets state")
-			d.forceIPSetsRefresh = true
+		case kubeIPVSAddrsUpdate := <-d.kubeIPVSAddrUpdates:
+			log.WithField("msg", kubeIPVSAddrsUpdate).Info("Received kube-ipvs0 addresses update")
+			for _, mgr := range d.allManagers {
+				mgr.OnUpdate(kubeIPVSAddrsUpdate)
+			}
 			d.dataplaneNeedsSync = true
-		case <-routeRefreshC:
-			log.Debug("Refreshing routes")
-			d.forceRouteRefresh = true
+		case ifaceMTUUpdate := <-d.ifaceMTUUpdates:
+			for _, mgr := range d.allManagers {
+				mgr.OnUpdate(ifaceMTUUpdate)
+			}
 			d.dataplaneNeedsSync = true
-		case <-xdpRefreshC:
-			log.Debug("Refreshing XDP")
-			d.forceXDPRefresh = true
+		case localServiceUpdate := <-d.localServiceUpdates:
+			log.WithField("msg", localServiceUpdate).Info("Received local service IPs update")
+			for _, mgr := range d.allManagers {
+				mgr.OnUpdate(localServiceUpdate)
+			}
+			d.dataplaneNeedsSync = true
+		case <-d.ipSetsRefreshTimer.C:
+			d.paceWork("ipsets-refresh", func() {
+				log.Debug("Refreshing IP sets state")
+				d.forceIPSetsRefresh = true
+			})
+		case <-d.routeRefreshTimer.C:
+			d.paceWork("route-refresh", func() {
+				log.Debug("Refreshing routes")
+				d.forceRouteRefresh = true
+			})
+		case <-d.xdpRefreshTimer.C:
+			d.paceWork("xdp-refresh", func() {
+				log.Debug("Refreshing XDP")
+				d.forceXDPRefresh = true
+			})
+		case <-workloadDrainC:
+			log.Debug("Polling workload drain state")
+			d.workloadDrain.QueuePoll()
+			d.dataplaneNeedsSync = true
+		case <-conntrackAcctC:
+			d.paceWork("conntrack-accounting", func() {
+				log.Debug("Sampling conntrack entries for local workloads")
+				d.conntrackAcct.QueueSample()
+			})
+		case <-flowLogsC:
+			d.paceWork("flow-logs", func() {
+				log.Debug("Flushing flow logs")
+				d.flowLogs.QueueFlush()
+			})
+		case <-bpfCountersC:
+			d.paceWork("bpf-counters", func() {
+				log.Debug("Scraping BPF counters")
+				d.bpfCounters.QueueScrape()
+			})
+		case <-cpuBudgetC:
+			d.onCPUBudgetSample()
+		case <-connRateLimitC:
+			log.Debug("Reading workload connection rate limit counters")
+			d.connRateLimit.QueueCounterRead()
 			d.dataplaneNeedsSync = true
 		case <-d.reschedC:
 			log.Debug("Reschedule kick received")
@@ -1602,13 +2505,21 @@ func (d *InternalDataplane) loopUpdatingDataplane() {
 		case <-healthTicks:
 			d.reportHealth()
 		case <-retryTicker.C:
+		case <-d.ipFamilyRetryC:
+			log.Debug("IP family retry kick received")
+			// nil out the channel to record that the timer is now inactive.
+			d.ipFamilyRetryC = nil
+		case <-d.deferredStartupDone:
+			log.Info("Deferred subsystems started, including them in dataplane updates.")
+			d.deferredStarted = true
+			d.dataplaneNeedsSync = true
 		case <-d.debugHangC:
 			log.Warning("Debug hang simulation timer popped, hanging the dataplane!!")
 			time.Sleep(1 * time.Hour)
 			log.Panic("Woke up after 1 hour, something's probably wrong with the test.")
 		}
 
-		if datastoreInSync && d.dataplaneNeedsSync {
+		if datastoreInSync && (d.dataplaneNeedsSync || len(d.ipFamiliesToRetry(time.Now())) > 0) {
 			// Dataplane is out-of-sync, check if we're throttled.
 			if d.applyThrottle.Admit() {
 				if beingThrottled && d.applyThrottle.WouldAdmit() {
@@ -1630,6 +2541,7 @@ func (d *InternalDataplane) loopUpdatingDataplane() {
 					countDataplaneSyncErrors.Inc()
 				}
 
+				logApplySummary(applyTime, d.loopSummarizer.CurrentCounts())
 				d.loopSummarizer.EndOfIteration(applyTime)
 
 				if !d.doneFirstApply {
@@ -1641,6 +2553,10 @@ func (d *InternalDataplane) loopUpdatingDataplane() {
 					if d.config.PostInSyncCallback != nil {
 						d.config.PostInSyncCallback()
 					}
+					if !d.deferredStarted {
+						go d.runDeferredStartupTasks(d.deferredStartupTasks)
+						d.deferredStartupTasks = nil
+					}
 				}
 				d.reportHealth()
 			} else {
@@ -1704,66 +2620,30 @@ func (d *InternalDataplane) apply() {
 	// Creating a rule that references an unknown IP set fails, as does deleting an IP set that
 	// is in use.
 
-	// Unset the needs-sync flag, we'll set it again if something fails.
-	d.dataplaneNeedsSync = false
-
-	// First, give the managers a chance to resolve any state based on the preceding batch of
-	// updates.  In some cases, e.g. EndpointManager, this can result in an update to another
-	// manager (BPFEndpointManager.OnHEPUpdate) that must happen before either of those managers
-	// begins its dataplane programming updates.
-	for _, mgr := range d.allManagers {
-		if handler, ok := mgr.(UpdateBatchResolver); ok {
-			err := handler.ResolveUpdateBatch()
-			if err != nil {
-				log.WithField("manager", reflect.TypeOf(mgr).Name()).WithError(err).Debug(
-					"couldn't resolve update batch for manager, will try again later")
-				d.dataplaneNeedsSync = true
-			}
-			d.reportHealth()
-		}
+	// If only IP families that previously failed need attention, we skip the managers and the
+	// shared parts of the dataplane and re-apply only those families.
+	applyStart := time.Now()
+	fullApply := d.dataplaneNeedsSync
+	retryFamilies := d.ipFamiliesToRetry(applyStart)
+	applyFamily := func(ipVersion uint8) bool {
+		return fullApply || retryFamilies[ipVersion]
 	}
-
-	// Now allow managers to complete the dataplane programming updates that they need.
-	for _, mgr := range d.allManagers {
-		err := mgr.CompleteDeferredWork()
-		if err != nil {
-			log.WithField("manager", reflect.TypeOf(mgr).Name()).WithError(err).Debug(
-				"couldn't complete deferred work for manager, will try again later")
-			d.dataplaneNeedsSync = true
-		}
-		d.reportHealth()
+	if !fullApply {
+		log.WithField("ipVersions", retryFamilies).Debug("Retrying failed IP families only.")
 	}
 
-	if d.xdpState != nil {
-		if d.forceXDPRefresh {
-			// Refresh timer popped.
-			d.xdpState.QueueResync()
-			d.forceXDPRefresh = false
-		}
+	// Unset the needs-sync flag, we'll set it again if something fails.
+	d.dataplaneNeedsSync = false
 
-		var applyXDPError error
-		d.xdpState.ProcessPendingDiffState(d.endpointsSourceV4)
-		if err := d.applyXDPActions(); err != nil {
-			applyXDPError = err
-		} else {
-			err := d.xdpState.ProcessMemberUpdates()
-			d.xdpState.DropPendingDiffState()
-			if err != nil {
-				log.WithError(err).Warning("Failed to process XDP member updates, will resync later...")
-				if err := d.applyXDPActions(); err != nil {
-					applyXDPError = err
-				}
-			}
-			d.xdpState.UpdateState()
-		}
-		if applyXDPError != nil {
-			log.WithError(applyXDPError).Info("Applying XDP actions did not succeed, disabling XDP")
-			if err := d.shutdownXDPCompletely(); err != nil {
-				log.Warnf("failed to disable XDP: %v, will proceed anyway.", err)
-			}
-		}
+	// Failures of the per-family parts of the dataplane are recorded here, rather than in
+	// dataplaneNeedsSync, so that they're retried independently.
+	var familyFailuresMutex sync.Mutex
+	familyFailures := map[uint8]bool{}
+
+	var reschedDelay time.Duration
+	if fullApply {
+		reschedDelay = d.applyManagersAndXDP()
 	}
-	d.reportHealth()
 
 	if d.forceRouteRefresh {
 		// Refresh timer popped.
@@ -1787,6 +2667,9 @@ func (d *InternalDataplane) apply() {
 	// iptables.
 	var ipSetsWG sync.WaitGroup
 	for _, ipSets := range d.ipSets {
+		if !applyFamily(uint8(ipSets.GetIPFamily().Version())) {
+			continue
+		}
 		ipSetsWG.Add(1)
 		go func(ipSets ipsetsDataplane) {
 			ipSets.ApplyUpdates()
@@ -1796,19 +2679,33 @@ func (d *InternalDataplane) apply() {
 	}
 
 	// Update the routing table in parallel with the other updates.  We'll wait for it to finish
-	// before we return.
+	// before we return.  Route tables that don't belong to a single IP family are only applied
+	// as part of a full apply.
 	var routesWG sync.WaitGroup
-	for _, r := range d.routeTableSyncers() {
+	for _, r := range d.activeRouteTableSyncers() {
+		var ipVersion uint8
+		if rv, ok := r.(routeTableWithIPVersion); ok {
+			ipVersion = rv.IPVersion()
+		}
+		if rp, ok := r.(routeTableWithParallelUpdates); ok {
+			// Set on each apply since some managers create their route tables on the fly.
+			rp.SetMaxParallelUpdates(d.config.RouteTableMaxParallelUpdates)
+		}
+		if ipVersion == 0 && !fullApply || ipVersion != 0 && !applyFamily(ipVersion) {
+			continue
+		}
 		routesWG.Add(1)
-		go func(r routeTableSyncer) {
+		go func(r routeTableSyncer, ipVersion uint8) {
 			err := r.Apply()
 			if err != nil {
-				log.Warn("Failed to synchronize routing table, will retry...")
-				d.dataplaneNeedsSync = true
+				log.WithField("ipVersion", ipVersion).Warn("Failed to synchronize routing table, will retry...")
+				familyFailuresMutex.Lock()
+				familyFailures[ipVersion] = true
+				familyFailuresMutex.Unlock()
 			}
 			d.reportHealth()
 			routesWG.Done()
-		}(r)
+		}(r, ipVersion)
 	}
 
 	// Wait for the IP sets update to finish.  We can't update iptables until it has.
@@ -1816,11 +2713,13 @@ func (d *InternalDataplane) apply() {
 
 	// Update iptables, this should sever any references to now-unused IP sets.
 	var reschedDelayMutex sync.Mutex
-	var reschedDelay time.Duration
 	var iptablesWG sync.WaitGroup
 	for _, t := range d.allIptablesTables {
+		if !applyFamily(t.GetIPVersion()) {
+			continue
+		}
 		iptablesWG.Add(1)
-		go func(t *iptables.Table) {
+		go func(t dataplaneTable) {
 			tableReschedAfter := t.Apply()
 
 			reschedDelayMutex.Lock()
@@ -1833,9 +2732,13 @@ func (d *InternalDataplane) apply() {
 		}(t)
 	}
 	iptablesWG.Wait()
+	d.reportQuarantinedChains()
 
 	// Now clean up any left-over IP sets.
 	for _, ipSets := range d.ipSets {
+		if !applyFamily(uint8(ipSets.GetIPFamily().Version())) {
+			continue
+		}
 		ipSetsWG.Add(1)
 		go func(s ipsetsDataplane) {
 			s.ApplyDeletions()
@@ -1848,11 +2751,33 @@ func (d *InternalDataplane) apply() {
 	// Wait for the route updates to finish.
 	routesWG.Wait()
 
+	// Record the outcome for each family that we applied.  A failure of a route table that isn't
+	// tied to a family needs a full apply.
+	if familyFailures[0] {
+		d.dataplaneNeedsSync = true
+	}
+	families := []uint8{4}
+	if d.config.IPv6Enabled {
+		families = append(families, 6)
+	}
+	now := time.Now()
+	for _, v := range families {
+		if applyFamily(v) {
+			d.ipFamilyState(v).OnApplyResult(familyFailures[v], now)
+		}
+	}
+	d.scheduleIPFamilyRetry(now)
+
 	// And publish and status updates.
 	d.endpointStatusCombiner.Apply()
 
-	// Set up any needed rescheduling kick.
-	if d.reschedC != nil {
+	if !fullApply {
+		// The managers haven't been asked whether they need rescheduling so leave any existing
+		// timer alone.
+		if reschedDelay == 0 || d.reschedC != nil {
+			return
+		}
+	} else if d.reschedC != nil {
 		// We have an active rescheduling timer, stop it so we can restart it with a
 		// different timeout below if it is still needed.
 		// This snippet comes from the docs for Timer.Stop().
@@ -1877,6 +2802,98 @@ func (d *InternalDataplane) apply() {
 	}
 }
 
+// logApplySummary logs how long an apply took and how many dataplane objects it changed, so that
+// dataplane churn can be correlated with datastore events.  Applies that changed nothing are only
+// logged at debug level.
+func logApplySummary(applyTime time.Duration, counts map[string]int) {
+	fields := log.Fields{"msecToApply": applyTime.Seconds() * 1000.0}
+	changed := false
+	for _, name := range logutils.AllCounts {
+		fields[name] = counts[name]
+		if counts[name] != 0 {
+			changed = true
+		}
+	}
+	logCxt := log.WithFields(fields)
+	if changed {
+		logCxt.Info("Finished applying updates to dataplane.")
+	} else {
+		logCxt.Debug("Finished applying updates to dataplane.")
+	}
+}
+
+// applyManagersAndXDP lets the managers resolve and complete their pending work and updates the
+// XDP state.  It returns the earliest time that a manager asked to be rescheduled.
+func (d *InternalDataplane) applyManagersAndXDP() time.Duration {
+	// First, give the managers a chance to resolve any state based on the preceding batch of
+	// updates.  In some cases, e.g. EndpointManager, this can result in an update to another
+	// manager (BPFEndpointManager.OnHEPUpdate) that must happen before either of those managers
+	// begins its dataplane programming updates.
+	for _, mgr := range d.allManagers {
+		if handler, ok := mgr.(UpdateBatchResolver); ok {
+			err := handler.ResolveUpdateBatch()
+			if err != nil {
+				log.WithField("manager", reflect.TypeOf(mgr).Name()).WithError(err).Debug(
+					"couldn't resolve update batch for manager, will try again later")
+				d.dataplaneNeedsSync = true
+			}
+			d.reportHealth()
+		}
+	}
+
+	// Now allow managers to complete the dataplane programming updates that they need.
+	var reschedDelay time.Duration
+	for _, mgr := range d.allManagers {
+		err := mgr.CompleteDeferredWork()
+		if err != nil {
+			log.WithField("manager", reflect.TypeOf(mgr).Name()).WithError(err).Debug(
+				"couldn't complete deferred work for manager, will try again later")
+			d.dataplaneNeedsSync = true
+		}
+		d.managerFailures.OnResult(mgr, err)
+		if r, ok := mgr.(ManagerWithReschedule); ok {
+			if mgrReschedAfter := r.RescheduleAfter(); mgrReschedAfter != 0 &&
+				(reschedDelay == 0 || mgrReschedAfter < reschedDelay) {
+				reschedDelay = mgrReschedAfter
+			}
+		}
+		d.reportHealth()
+	}
+
+	if d.xdpState != nil {
+		if d.forceXDPRefresh {
+			// Refresh timer popped.
+			d.xdpState.QueueResync()
+			d.forceXDPRefresh = false
+		}
+
+		var applyXDPError error
+		d.xdpState.ProcessPendingDiffState(d.endpointsSourceV4)
+		if err := d.applyXDPActions(); err != nil {
+			applyXDPError = err
+		} else {
+			err := d.xdpState.ProcessMemberUpdates()
+			d.xdpState.DropPendingDiffState()
+			if err != nil {
+				log.WithError(err).Warning("Failed to process XDP member updates, will resync later...")
+				if err := d.applyXDPActions(); err != nil {
+					applyXDPError = err
+				}
+			}
+			d.xdpState.UpdateState()
+		}
+		if applyXDPError != nil {
+			log.WithError(applyXDPError).Info("Applying XDP actions did not succeed, disabling XDP")
+			if err := d.shutdownXDPCompletely(); err != nil {
+				log.Warnf("failed to disable XDP: %v, will proceed anyway.", err)
+			}
+		}
+	}
+	d.reportHealth()
+
+	return reschedDelay
+}
+
 func (d *InternalDataplane) applyXDPActions() error {
 	var err error = nil
 	for i := 0; i < 10; i++ {
@@ -1917,17 +2934,76 @@ type iptablesTable interface {
 	UpdateChains([]*iptables.Chain)
 	RemoveChains([]*iptables.Chain)
 	RemoveChainByName(name string)
+	UpdateChainFragment(chainName string, owner string, priority int, rules []iptables.Rule)
+	RemoveChainFragment(chainName string, owner string)
+	UpdateInsertGroup(chainName string, group string, position iptables.InsertPosition, priority int, rules []iptables.Rule)
+	RemoveInsertGroup(chainName string, group string)
+}
+
+// dataplaneTable is the interface of the Tables that program a whole iptables table; it is
+// implemented by iptables.Table and by nftables.Table.
+type dataplaneTable interface {
+	iptablesTable
+	InsertOrAppendRules(chainName string, rules []iptables.Rule)
+	AppendRules(chainName string, rules []iptables.Rule)
+	ReadRuleCounters(chainName string) (map[string]uint64, error)
+	QuarantinedChains() []iptables.QuarantinedChain
+	GetIPVersion() uint8
+	SetRefreshInterval(interval time.Duration)
+	Apply() (rescheduleAfter time.Duration)
 }
 
 func (d *InternalDataplane) reportHealth() {
 	if d.config.HealthAggregator != nil {
 		d.config.HealthAggregator.Report(
 			healthName,
-			&health.HealthReport{Live: true, Ready: d.doneFirstApply},
+			&health.HealthReport{
+				Live: true,
+				Ready: d.doneFirstApply &&
+					(d.cniGate == nil || d.cniGate.IsOpen()) &&
+					d.managerFailures.Ready(),
+			},
 		)
 	}
 }
 
+// reportQuarantinedChains reports non-ready under its own name while any of our iptables chains
+// are quarantined, so that a rejected rule shows up as a distinct problem.  The chains and rules
+// that were rejected have already been logged by the Table.
+func (d *InternalDataplane) reportQuarantinedChains() {
+	numQuarantined := 0
+	for _, t := range d.allIptablesTables {
+		numQuarantined += len(t.QuarantinedChains())
+	}
+	if numQuarantined != d.numQuarantinedChains {
+		if numQuarantined > 0 {
+			log.WithField("numChains", numQuarantined).Error(
+				"Some iptables chains are quarantined because iptables-restore rejected their rules.")
+		} else {
+			log.Info("No iptables chains are quarantined.")
+		}
+		d.numQuarantinedChains = numQuarantined
+	}
+	if d.config.HealthAggregator != nil {
+		d.config.HealthAggregator.Report(iptablesHealthName, &health.HealthReport{Ready: numQuarantined == 0})
+	}
+}
+
+// verifyConnectTimeLoadBalancer checks that the connect-time load balancer covers the workloads.
+// If not, services may not work from (some) pods so we report non-ready under our own name.
+func (d *InternalDataplane) verifyConnectTimeLoadBalancer() {
+	err := nat.VerifyConnectTimeLoadBalancer(d.config.BPFCgroupV2Root, d.config.BPFCgroupV2)
+	if err == nil {
+		log.Info("Verified connect-time load balancer programs.")
+		return
+	}
+	log.WithError(err).Error("Connect-time load balancer is not working for workloads.")
+	if d.config.HealthAggregator != nil {
+		d.config.HealthAggregator.RegisterReporter(ctlbHealthName, &health.HealthReport{Ready: true}, 0)
+		d.config.HealthAggregator.Report(ctlbHealthName, &health.HealthReport{Ready: false})
+	}
+}
+
 type dummyLock struct{}
 
 func (d dummyLock) Lock() {
@@ -109,6 +109,15 @@ var _ = Describe("Constructor test", func() {
 		})
 	})
 
+	Context("with deferred startup", func() {
+
+		It("should be constructable", func() {
+			dpConfig.DeferredStartupEnabled = true
+			var dp = intdataplane.NewIntDataplaneDriver(dpConfig)
+			Expect(dp).ToNot(BeNil())
+		})
+	})
+
 	Context("with Wireguard on AKS", func() {
 
 		BeforeEach(func() {
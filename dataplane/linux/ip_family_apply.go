@@ -0,0 +1,158 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	ipFamilyRetryMinBackoff = 100 * time.Millisecond
+	ipFamilyRetryMaxBackoff = 30 * time.Second
+)
+
+var countIPFamilySyncErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "felix_int_dataplane_ip_family_failures",
+	Help: "Number of times the dataplane updates for an IP family failed and will be retried.",
+}, []string{"ip_version"})
+
+func init() {
+	prometheus.MustRegister(countIPFamilySyncErrors)
+}
+
+// routeTableWithIPVersion is implemented by route tables that only hold routes of one IP version.
+// Their failures are retried along with the rest of that IP family; failures of other route
+// tables cause a full re-apply.
+type routeTableWithIPVersion interface {
+	IPVersion() uint8
+}
+
+type routeTableWithParallelUpdates interface {
+	SetMaxParallelUpdates(n int)
+}
+
+// ipFamilyApplyState tracks whether one IP family's IP sets, iptables tables and route tables
+// need to be applied again after a failure.  Each family has its own retry backoff so that a
+// persistent failure in one family only causes retries of that family, rather than re-applies of
+// the whole dataplane.
+type ipFamilyApplyState struct {
+	ipVersion uint8
+
+	// needsSync is set if the last apply of this family failed.
+	needsSync           bool
+	consecutiveFailures int
+	backoff             time.Duration
+	nextRetry           time.Time
+
+	countFailures prometheus.Counter
+}
+
+func newIPFamilyApplyState(ipVersion uint8) *ipFamilyApplyState {
+	return &ipFamilyApplyState{
+		ipVersion:     ipVersion,
+		countFailures: countIPFamilySyncErrors.WithLabelValues(fmt.Sprint(ipVersion)),
+	}
+}
+
+// RetryDue returns true if the family failed and its backoff has passed.
+func (f *ipFamilyApplyState) RetryDue(now time.Time) bool {
+	return f.needsSync && !now.Before(f.nextRetry)
+}
+
+// OnApplyResult records the outcome of an apply of this family and, after a failure, schedules
+// the next retry.
+func (f *ipFamilyApplyState) OnApplyResult(failed bool, now time.Time) {
+	logCxt := log.WithField("ipVersion", f.ipVersion)
+	if !failed {
+		if f.needsSync {
+			logCxt.WithField("numFailures", f.consecutiveFailures).Info(
+				"Dataplane updates for IP family succeeded after earlier failures.")
+		}
+		f.needsSync = false
+		f.consecutiveFailures = 0
+		f.backoff = 0
+		return
+	}
+
+	f.needsSync = true
+	f.consecutiveFailures++
+	f.countFailures.Inc()
+	if f.backoff == 0 {
+		f.backoff = ipFamilyRetryMinBackoff
+	} else if f.backoff *= 2; f.backoff > ipFamilyRetryMaxBackoff {
+		f.backoff = ipFamilyRetryMaxBackoff
+	}
+	f.nextRetry = now.Add(f.backoff)
+	logCxt.WithFields(log.Fields{
+		"numFailures": f.consecutiveFailures,
+		"retryIn":     f.backoff,
+	}).Warn("Dataplane updates for IP family failed, will retry.")
+}
+
+// ipFamilyState returns the apply state of the given IP family, creating it if needed.
+func (d *InternalDataplane) ipFamilyState(ipVersion uint8) *ipFamilyApplyState {
+	if d.ipFamilies == nil {
+		d.ipFamilies = map[uint8]*ipFamilyApplyState{}
+	}
+	f, ok := d.ipFamilies[ipVersion]
+	if !ok {
+		f = newIPFamilyApplyState(ipVersion)
+		d.ipFamilies[ipVersion] = f
+	}
+	return f
+}
+
+// ipFamiliesToRetry returns the IP families that failed and whose backoff has passed.
+func (d *InternalDataplane) ipFamiliesToRetry(now time.Time) map[uint8]bool {
+	due := map[uint8]bool{}
+	for v, f := range d.ipFamilies {
+		if f.RetryDue(now) {
+			due[v] = true
+		}
+	}
+	return due
+}
+
+// scheduleIPFamilyRetry (re)starts the timer that wakes the main loop when the earliest failed
+// IP family is due to be retried.
+func (d *InternalDataplane) scheduleIPFamilyRetry(now time.Time) {
+	if d.ipFamilyRetryC != nil {
+		// This snippet comes from the docs for Timer.Stop().
+		if !d.ipFamilyRetryTimer.Stop() {
+			<-d.ipFamilyRetryC
+		}
+		d.ipFamilyRetryC = nil
+	}
+	var next time.Time
+	for _, f := range d.ipFamilies {
+		if f.needsSync && (next.IsZero() || f.nextRetry.Before(next)) {
+			next = f.nextRetry
+		}
+	}
+	if next.IsZero() {
+		return
+	}
+	delay := next.Sub(now)
+	if d.ipFamilyRetryTimer == nil {
+		d.ipFamilyRetryTimer = time.NewTimer(delay)
+	} else {
+		d.ipFamilyRetryTimer.Reset(delay)
+	}
+	d.ipFamilyRetryC = d.ipFamilyRetryTimer.C
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("IP family apply state", func() {
+	var (
+		f   *ipFamilyApplyState
+		now time.Time
+	)
+
+	BeforeEach(func() {
+		f = newIPFamilyApplyState(6)
+		now = time.Now()
+	})
+
+	It("should not need a retry initially", func() {
+		Expect(f.RetryDue(now)).To(BeFalse())
+	})
+
+	It("should not need a retry after a success", func() {
+		f.OnApplyResult(false, now)
+		Expect(f.RetryDue(now.Add(time.Hour))).To(BeFalse())
+	})
+
+	Describe("after a failure", func() {
+		BeforeEach(func() {
+			f.OnApplyResult(true, now)
+		})
+
+		It("should retry after the minimum backoff", func() {
+			Expect(f.RetryDue(now)).To(BeFalse())
+			Expect(f.RetryDue(now.Add(ipFamilyRetryMinBackoff))).To(BeTrue())
+		})
+
+		It("should double the backoff on each failure, up to the maximum", func() {
+			f.OnApplyResult(true, now)
+			Expect(f.backoff).To(Equal(2 * ipFamilyRetryMinBackoff))
+			for i := 0; i < 20; i++ {
+				f.OnApplyResult(true, now)
+			}
+			Expect(f.backoff).To(Equal(ipFamilyRetryMaxBackoff))
+			Expect(f.RetryDue(now.Add(ipFamilyRetryMaxBackoff - time.Millisecond))).To(BeFalse())
+			Expect(f.RetryDue(now.Add(ipFamilyRetryMaxBackoff))).To(BeTrue())
+		})
+
+		It("should reset the backoff after a success", func() {
+			f.OnApplyResult(true, now)
+			f.OnApplyResult(false, now)
+			Expect(f.RetryDue(now.Add(time.Hour))).To(BeFalse())
+			f.OnApplyResult(true, now)
+			Expect(f.backoff).To(Equal(ipFamilyRetryMinBackoff))
+		})
+	})
+})
@@ -16,13 +16,18 @@ package intdataplane
 
 import (
 	"net"
+	"strings"
+	"syscall"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/vishvananda/netlink"
 
+	"github.com/projectcalico/felix/ip"
 	"github.com/projectcalico/felix/ipsets"
+	"github.com/projectcalico/felix/logutils"
 	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/routetable"
 	"github.com/projectcalico/felix/rules"
 
 	"github.com/projectcalico/libcalico-go/lib/set"
@@ -32,7 +37,10 @@ import (
 // when IPIP is enabled.  It doesn't actually program the rules, because they are part of the
 // top-level static chains.
 //
-// ipipManager also takes care of the configuration of the IPIP tunnel device.
+// ipipManager also takes care of the configuration of the IPIP tunnel device and, if enabled,
+// programs the routes to remote IPIP workloads.  For CrossSubnet pools, routes to peers that
+// are in the same subnet as this host are programmed directly over the host interface; all
+// other routes go through the tunnel.
 type ipipManager struct {
 	ipsetsDataplane ipsetsDataplane
 
@@ -49,14 +57,53 @@ type ipipManager struct {
 
 	// Configured list of external node ip cidr's to be added to the ipset.
 	externalNodeCIDRs []string
+
+	// Route programming state.  routeTable is nil if route programming is disabled, in
+	// which case we leave the IPIP routes to BGP.
+	hostname    string
+	routeTable  routeTable
+	routesByDst map[string]*proto.RouteUpdate
+	routesDirty bool
+
+	// parentIface is the host interface that has this host's IP and parentSubnet is the
+	// subnet of that address.  Peers in that subnet can be reached without encapsulation.
+	parentIface        string
+	parentSubnet       *net.IPNet
+	parentDirty        bool
+	noEncapRTConstruct func(ifaceName string) routeTable
+	// noEncapRouteTables maps host interface name to the route table that manages the
+	// unencapsulated routes on that interface.  We keep the tables for previous parent
+	// interfaces around so that they can clean up their routes.
+	noEncapRouteTables map[string]routeTable
 }
 
+const (
+	ipipTunnelDevice = "tunl0"
+	defaultIPIPProto = 80
+)
+
 func newIPIPManager(
 	ipsetsDataplane ipsetsDataplane,
-	maxIPSetSize int,
-	externalNodeCidrs []string,
+	dpConfig Config,
+	opRecorder logutils.OpRecorder,
 ) *ipipManager {
-	return newIPIPManagerWithShim(ipsetsDataplane, maxIPSetSize, realIPIPNetlink{}, externalNodeCidrs)
+	if !dpConfig.IPIPRoutesEnabled {
+		return newIPIPManagerWithShim(ipsetsDataplane, dpConfig.MaxIPSetSize, realIPIPNetlink{},
+			dpConfig.ExternalNodesCidrs, "", nil, nil)
+	}
+
+	routeProto := defaultIPIPProto
+	if dpConfig.DeviceRouteProtocol != syscall.RTPROT_BOOT {
+		routeProto = dpConfig.DeviceRouteProtocol
+	}
+	newRouteTable := func(ifaceName string) routeTable {
+		// We don't remove routes that we didn't program, in case BGP is still programming
+		// some routes on the same devices.
+		return routetable.New([]string{"^" + ifaceName + "$"}, 4, false, dpConfig.NetlinkTimeout,
+			dpConfig.DeviceRouteSourceAddress, routeProto, false, 0, opRecorder)
+	}
+	return newIPIPManagerWithShim(ipsetsDataplane, dpConfig.MaxIPSetSize, realIPIPNetlink{},
+		dpConfig.ExternalNodesCidrs, dpConfig.Hostname, newRouteTable(ipipTunnelDevice), newRouteTable)
 }
 
 func newIPIPManagerWithShim(
@@ -64,6 +111,9 @@ func newIPIPManagerWithShim(
 	maxIPSetSize int,
 	dataplane ipipDataplane,
 	externalNodeCIDRs []string,
+	hostname string,
+	rt routeTable,
+	noEncapRTConstruct func(ifaceName string) routeTable,
 ) *ipipManager {
 	ipipMgr := &ipipManager{
 		ipsetsDataplane:    ipsetsDataplane,
@@ -74,7 +124,13 @@ func newIPIPManagerWithShim(
 			SetID:   rules.IPSetIDAllHostNets,
 			Type:    ipsets.IPSetTypeHashNet,
 		},
-		externalNodeCIDRs: externalNodeCIDRs,
+		externalNodeCIDRs:  externalNodeCIDRs,
+		hostname:           hostname,
+		routeTable:         rt,
+		routesByDst:        map[string]*proto.RouteUpdate{},
+		routesDirty:        true,
+		noEncapRTConstruct: noEncapRTConstruct,
+		noEncapRouteTables: map[string]routeTable{},
 	}
 	return ipipMgr
 }
@@ -101,7 +157,7 @@ func (d *ipipManager) configureIPIPDevice(mtu int, address net.IP) error {
 		"tunnelAddr": address,
 	})
 	logCxt.Debug("Configuring IPIP tunnel")
-	link, err := d.dataplane.LinkByName("tunl0")
+	link, err := d.dataplane.LinkByName(ipipTunnelDevice)
 	if err != nil {
 		log.WithError(err).Info("Failed to get IPIP tunnel device, assuming it isn't present")
 		// We call out to "ip tunnel", which takes care of loading the kernel module if
@@ -112,7 +168,7 @@ func (d *ipipManager) configureIPIPDevice(mtu int, address net.IP) error {
 			log.WithError(err).Warning("Failed to add IPIP tunnel device")
 			return err
 		}
-		link, err = d.dataplane.LinkByName("tunl0")
+		link, err = d.dataplane.LinkByName(ipipTunnelDevice)
 		if err != nil {
 			log.WithError(err).Warning("Failed to get tunnel device")
 			return err
@@ -138,7 +194,7 @@ func (d *ipipManager) configureIPIPDevice(mtu int, address net.IP) error {
 		logCxt.Info("Set tunnel admin up")
 	}
 
-	if err := d.setLinkAddressV4("tunl0", address); err != nil {
+	if err := d.setLinkAddressV4(ipipTunnelDevice, address); err != nil {
 		log.WithError(err).Warn("Failed to set tunnel device IP")
 		return err
 	}
@@ -203,13 +259,156 @@ func (d *ipipManager) OnUpdate(msg interface{}) {
 	switch msg := msg.(type) {
 	case *proto.HostMetadataUpdate:
 		log.WithField("hostanme", msg.Hostname).Debug("Host update/create")
-		d.activeHostnameToIP[msg.Hostname] = msg.Ipv4Addr
+		if msg.Hostname == d.hostname && d.activeHostnameToIP[msg.Hostname] != msg.Ipv4Addr {
+			d.parentDirty = true
+		}
+		if msg.Ipv4Addr == "" {
+			// IPv6-only host; it doesn't belong in the (IPv4) all-hosts IP set.
+			delete(d.activeHostnameToIP, msg.Hostname)
+		} else {
+			d.activeHostnameToIP[msg.Hostname] = msg.Ipv4Addr
+		}
 		d.ipSetInSync = false
 	case *proto.HostMetadataRemove:
 		log.WithField("hostname", msg.Hostname).Debug("Host removed")
+		if msg.Hostname == d.hostname {
+			d.parentDirty = true
+		}
 		delete(d.activeHostnameToIP, msg.Hostname)
 		d.ipSetInSync = false
 	}
+
+	if d.routeTable == nil {
+		return
+	}
+
+	switch msg := msg.(type) {
+	case *proto.RouteUpdate:
+		if msg.Type == proto.RouteType_REMOTE_WORKLOAD && msg.IpPoolType == proto.IPPoolType_IPIP {
+			log.WithField("msg", msg).Debug("IPIP data plane received route update")
+			d.routesByDst[msg.Dst] = msg
+			d.routesDirty = true
+		} else if _, ok := d.routesByDst[msg.Dst]; ok {
+			// The route changed type to one we no longer care about.
+			delete(d.routesByDst, msg.Dst)
+			d.routesDirty = true
+		}
+	case *proto.RouteRemove:
+		if _, ok := d.routesByDst[msg.Dst]; ok {
+			delete(d.routesByDst, msg.Dst)
+			d.routesDirty = true
+		}
+	case *ifaceAddrsUpdate:
+		// Our host IP may have moved to a different interface, or the subnet of the
+		// interface may have changed; either way, recalculate which peers are reachable
+		// without encapsulation.
+		if msg.Name == d.parentIface || (msg.Addrs != nil && msg.Addrs.Contains(d.activeHostnameToIP[d.hostname])) {
+			log.WithField("iface", msg.Name).Debug("Addresses changed on (possible) IPIP parent interface")
+			d.parentDirty = true
+		}
+	}
+}
+
+func (m *ipipManager) GetRouteTableSyncers() []routeTableSyncer {
+	if m.routeTable == nil {
+		return nil
+	}
+	rts := []routeTableSyncer{m.routeTable}
+	for _, rt := range m.noEncapRouteTables {
+		rts = append(rts, rt)
+	}
+	return rts
+}
+
+// updateParentIface looks up the host interface that has this host's IP, along with the subnet
+// of that address.
+func (m *ipipManager) updateParentIface() {
+	m.parentIface = ""
+	m.parentSubnet = nil
+
+	hostIP := net.ParseIP(m.activeHostnameToIP[m.hostname])
+	if hostIP == nil {
+		log.Debug("Host IP not known yet, can't calculate IPIP parent interface.")
+		return
+	}
+	links, err := m.dataplane.LinkList()
+	if err != nil {
+		log.WithError(err).Warn("Failed to list links, assuming no IPIP parent interface.")
+		return
+	}
+	for _, link := range links {
+		addrs, err := m.dataplane.AddrList(link, netlink.FAMILY_V4)
+		if err != nil {
+			log.WithError(err).WithField("link", link.Attrs().Name).Warn("Failed to list addresses")
+			continue
+		}
+		for _, addr := range addrs {
+			if addr.IPNet != nil && addr.IP.Equal(hostIP) {
+				m.parentIface = link.Attrs().Name
+				m.parentSubnet = &net.IPNet{IP: addr.IP.Mask(addr.Mask), Mask: addr.Mask}
+				log.WithFields(log.Fields{
+					"iface":  m.parentIface,
+					"subnet": m.parentSubnet,
+				}).Info("Found IPIP parent interface.")
+				return
+			}
+		}
+	}
+	log.WithField("hostIP", hostIP).Info("No interface has the host IP, routing all IPIP traffic through the tunnel.")
+}
+
+func (m *ipipManager) updateRoutes() {
+	var tunnelRoutes, noEncapRoutes []routetable.Target
+	for _, r := range m.routesByDst {
+		logCxt := log.WithField("route", r)
+		cidr, err := ip.CIDRFromString(r.Dst)
+		if err != nil {
+			// Don't block programming of other routes if somehow we receive one with a bad dst.
+			logCxt.WithError(err).Warn("Failed to parse IPIP route destination")
+			continue
+		}
+		if r.DstNodeIp == "" {
+			logCxt.Debug("Can't program IPIP route since host IP is not known.")
+			continue
+		}
+		gw := ip.FromString(r.DstNodeIp)
+
+		// SameSubnet is only set for CrossSubnet pools.  Double check against the subnet of
+		// our interface, which may have changed since the datastore was updated.
+		if r.SameSubnet && m.parentSubnet != nil && m.parentSubnet.Contains(gw.AsNetIP()) {
+			noEncapRoutes = append(noEncapRoutes, routetable.Target{
+				Type: routetable.TargetTypeNoEncap,
+				CIDR: cidr,
+				GW:   gw,
+			})
+			continue
+		}
+		tunnelRoutes = append(tunnelRoutes, routetable.Target{
+			Type: routetable.TargetTypeOnLink,
+			CIDR: cidr,
+			GW:   gw,
+		})
+	}
+
+	log.WithField("routes", tunnelRoutes).Debug("IPIP manager sending tunnel routes")
+	m.routeTable.SetRoutes(ipipTunnelDevice, tunnelRoutes)
+
+	if m.parentIface != "" {
+		if _, ok := m.noEncapRouteTables[m.parentIface]; !ok {
+			m.noEncapRouteTables[m.parentIface] = m.noEncapRTConstruct(m.parentIface)
+		}
+	}
+	for ifaceName, rt := range m.noEncapRouteTables {
+		if ifaceName == m.parentIface {
+			log.WithFields(log.Fields{
+				"iface":  ifaceName,
+				"routes": noEncapRoutes,
+			}).Debug("IPIP manager sending unencapsulated routes")
+			rt.SetRoutes(ifaceName, noEncapRoutes)
+		} else {
+			rt.SetRoutes(ifaceName, nil)
+		}
+	}
 }
 
 func (m *ipipManager) CompleteDeferredWork() error {
@@ -224,10 +423,29 @@ func (m *ipipManager) CompleteDeferredWork() error {
 		for _, ip := range m.activeHostnameToIP {
 			members = append(members, ip)
 		}
-		members = append(members, m.externalNodeCIDRs...)
+		for _, cidr := range m.externalNodeCIDRs {
+			if strings.Contains(cidr, ":") {
+				// The all-hosts IP set is IPv4-only.
+				continue
+			}
+			members = append(members, cidr)
+		}
 		m.ipsetsDataplane.AddOrReplaceIPSet(m.ipSetMetadata, members)
 		m.ipSetInSync = true
 	}
+
+	if m.routeTable == nil {
+		return nil
+	}
+	if m.parentDirty {
+		m.updateParentIface()
+		m.parentDirty = false
+		m.routesDirty = true
+	}
+	if m.routesDirty {
+		m.updateRoutes()
+		m.routesDirty = false
+	}
 	return nil
 }
 
@@ -23,6 +23,7 @@ import (
 // ipipDataplane is a shim interface for mocking netlink and os/exec in the IPIP manager.
 type ipipDataplane interface {
 	LinkByName(name string) (netlink.Link, error)
+	LinkList() ([]netlink.Link, error)
 	LinkSetMTU(link netlink.Link, mtu int) error
 	LinkSetUp(link netlink.Link) error
 	AddrList(link netlink.Link, family int) ([]netlink.Addr, error)
@@ -36,6 +37,10 @@ type realIPIPNetlink struct{}
 func (r realIPIPNetlink) LinkByName(name string) (netlink.Link, error) {
 	return netlink.LinkByName(name)
 }
+func (r realIPIPNetlink) LinkList() ([]netlink.Link, error) {
+	return netlink.LinkList()
+}
+
 func (r realIPIPNetlink) LinkSetMTU(link netlink.Link, mtu int) error {
 	return netlink.LinkSetMTU(link, mtu)
 }
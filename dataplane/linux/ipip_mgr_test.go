@@ -25,7 +25,9 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/vishvananda/netlink"
 
+	"github.com/projectcalico/felix/ip"
 	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/routetable"
 	"github.com/projectcalico/libcalico-go/lib/set"
 )
 
@@ -53,7 +55,7 @@ var _ = Describe("IpipMgr (tunnel configuration)", func() {
 	BeforeEach(func() {
 		dataplane = &mockIPIPDataplane{}
 		ipSets = newMockIPSets()
-		ipipMgr = newIPIPManagerWithShim(ipSets, 1024, dataplane, nil)
+		ipipMgr = newIPIPManagerWithShim(ipSets, 1024, dataplane, nil, "", nil, nil)
 	})
 
 	Describe("after calling configureIPIPDevice", func() {
@@ -216,7 +218,7 @@ var _ = Describe("ipipManager IP set updates", func() {
 	BeforeEach(func() {
 		dataplane = &mockIPIPDataplane{}
 		ipSets = newMockIPSets()
-		ipipMgr = newIPIPManagerWithShim(ipSets, 1024, dataplane, []string{externalCIDR})
+		ipipMgr = newIPIPManagerWithShim(ipSets, 1024, dataplane, []string{externalCIDR}, "", nil, nil)
 	})
 
 	It("should not create the IP set until first call to CompleteDeferredWork()", func() {
@@ -300,6 +302,34 @@ var _ = Describe("ipipManager IP set updates", func() {
 			})
 		})
 
+		Describe("after adding an IPv6-only host", func() {
+			BeforeEach(func() {
+				ipipMgr.OnUpdate(&proto.HostMetadataUpdate{
+					Hostname: "host2",
+					Ipv6Addr: "fd00::2",
+				})
+				err := ipipMgr.CompleteDeferredWork()
+				Expect(err).ToNot(HaveOccurred())
+			})
+			It("should leave the host out of the IP set", func() {
+				Expect(allHostsSet()).To(Equal(set.From("10.0.0.1", externalCIDR)))
+			})
+		})
+
+		Describe("after host1 loses its IPv4 address", func() {
+			BeforeEach(func() {
+				ipipMgr.OnUpdate(&proto.HostMetadataUpdate{
+					Hostname: "host1",
+					Ipv6Addr: "fd00::1",
+				})
+				err := ipipMgr.CompleteDeferredWork()
+				Expect(err).ToNot(HaveOccurred())
+			})
+			It("should remove the IP", func() {
+				Expect(allHostsSet()).To(Equal(set.From(externalCIDR)))
+			})
+		})
+
 		Describe("after adding/removing a duplicate IP in one batch", func() {
 			BeforeEach(func() {
 				ipipMgr.OnUpdate(&proto.HostMetadataUpdate{
@@ -344,11 +374,139 @@ var _ = Describe("ipipManager IP set updates", func() {
 	})
 })
 
+var _ = Describe("ipipManager route programming", func() {
+	var (
+		ipipMgr     *ipipManager
+		dataplane   *mockIPIPDataplane
+		rt          *mockRouteTable
+		noEncapRTs  map[string]*mockRouteTable
+		eth0, eth1  *mockLink
+		routeToHost = func(dst, nodeName, nodeIP string, sameSubnet bool) *proto.RouteUpdate {
+			return &proto.RouteUpdate{
+				Type:        proto.RouteType_REMOTE_WORKLOAD,
+				IpPoolType:  proto.IPPoolType_IPIP,
+				Dst:         dst,
+				DstNodeName: nodeName,
+				DstNodeIp:   nodeIP,
+				SameSubnet:  sameSubnet,
+			}
+		}
+		hostAddr = func(cidr string) netlink.Addr {
+			ipAddr, ipNet, err := net.ParseCIDR(cidr)
+			Expect(err).NotTo(HaveOccurred())
+			ipNet.IP = ipAddr
+			return netlink.Addr{IPNet: ipNet}
+		}
+	)
+
+	BeforeEach(func() {
+		eth0 = &mockLink{attrs: netlink.LinkAttrs{Name: "eth0"}}
+		eth1 = &mockLink{attrs: netlink.LinkAttrs{Name: "eth1"}}
+		dataplane = &mockIPIPDataplane{
+			hostLinks: []*mockLink{eth0, eth1},
+			hostAddrs: map[string][]netlink.Addr{
+				"eth0": {hostAddr("172.16.0.1/24")},
+				"eth1": {hostAddr("192.168.0.1/24")},
+			},
+		}
+		rt = &mockRouteTable{
+			currentRoutes:   map[string][]routetable.Target{},
+			currentL2Routes: map[string][]routetable.L2Target{},
+		}
+		noEncapRTs = map[string]*mockRouteTable{}
+		ipipMgr = newIPIPManagerWithShim(newMockIPSets(), 1024, dataplane, nil, "host1", rt,
+			func(ifaceName string) routeTable {
+				Expect(noEncapRTs).NotTo(HaveKey(ifaceName))
+				noEncapRTs[ifaceName] = &mockRouteTable{
+					currentRoutes:   map[string][]routetable.Target{},
+					currentL2Routes: map[string][]routetable.L2Target{},
+				}
+				return noEncapRTs[ifaceName]
+			})
+
+		ipipMgr.OnUpdate(&proto.HostMetadataUpdate{Hostname: "host1", Ipv4Addr: "172.16.0.1"})
+		ipipMgr.OnUpdate(&ifaceAddrsUpdate{Name: "eth0", Addrs: set.From("172.16.0.1")})
+		ipipMgr.OnUpdate(routeToHost("10.0.1.0/26", "host2", "172.16.0.2", true))
+		ipipMgr.OnUpdate(routeToHost("10.0.2.0/26", "host3", "172.17.0.3", false))
+		Expect(ipipMgr.CompleteDeferredWork()).To(Succeed())
+	})
+
+	It("should program same-subnet routes directly and other routes through the tunnel", func() {
+		rt.checkRoutes("tunl0", []routetable.Target{{
+			Type: routetable.TargetTypeOnLink,
+			CIDR: ip.MustParseCIDROrIP("10.0.2.0/26"),
+			GW:   ip.FromString("172.17.0.3"),
+		}})
+		Expect(noEncapRTs).To(HaveLen(1))
+		noEncapRTs["eth0"].checkRoutes("eth0", []routetable.Target{{
+			Type: routetable.TargetTypeNoEncap,
+			CIDR: ip.MustParseCIDROrIP("10.0.1.0/26"),
+			GW:   ip.FromString("172.16.0.2"),
+		}})
+	})
+
+	It("should ignore routes for other pool types", func() {
+		r := routeToHost("10.0.3.0/26", "host4", "172.16.0.4", false)
+		r.IpPoolType = proto.IPPoolType_VXLAN
+		ipipMgr.OnUpdate(r)
+		Expect(ipipMgr.CompleteDeferredWork()).To(Succeed())
+		Expect(rt.currentRoutes["tunl0"]).To(HaveLen(1))
+	})
+
+	It("should remove routes", func() {
+		ipipMgr.OnUpdate(&proto.RouteRemove{Dst: "10.0.1.0/26"})
+		ipipMgr.OnUpdate(&proto.RouteRemove{Dst: "10.0.2.0/26"})
+		Expect(ipipMgr.CompleteDeferredWork()).To(Succeed())
+		rt.checkRoutes("tunl0", nil)
+		noEncapRTs["eth0"].checkRoutes("eth0", nil)
+	})
+
+	It("should fall back to the tunnel when the host IP moves to a different subnet", func() {
+		dataplane.hostAddrs["eth0"] = nil
+		dataplane.hostAddrs["eth1"] = []netlink.Addr{hostAddr("192.168.0.1/24"), hostAddr("172.16.0.1/16")}
+		ipipMgr.OnUpdate(&ifaceAddrsUpdate{Name: "eth0", Addrs: set.New()})
+		ipipMgr.OnUpdate(&ifaceAddrsUpdate{Name: "eth1", Addrs: set.From("192.168.0.1", "172.16.0.1")})
+		Expect(ipipMgr.CompleteDeferredWork()).To(Succeed())
+
+		By("moving the direct route to the new parent interface")
+		noEncapRTs["eth0"].checkRoutes("eth0", nil)
+		noEncapRTs["eth1"].checkRoutes("eth1", []routetable.Target{{
+			Type: routetable.TargetTypeNoEncap,
+			CIDR: ip.MustParseCIDROrIP("10.0.1.0/26"),
+			GW:   ip.FromString("172.16.0.2"),
+		}})
+		Expect(ipipMgr.GetRouteTableSyncers()).To(HaveLen(3))
+
+		By("using the tunnel once the peer is no longer in our subnet")
+		dataplane.hostAddrs["eth1"] = []netlink.Addr{hostAddr("172.16.0.1/31")}
+		ipipMgr.OnUpdate(&ifaceAddrsUpdate{Name: "eth1", Addrs: set.From("172.16.0.1")})
+		Expect(ipipMgr.CompleteDeferredWork()).To(Succeed())
+		noEncapRTs["eth1"].checkRoutes("eth1", nil)
+		Expect(rt.currentRoutes["tunl0"]).To(ConsistOf(
+			routetable.Target{
+				Type: routetable.TargetTypeOnLink,
+				CIDR: ip.MustParseCIDROrIP("10.0.1.0/26"),
+				GW:   ip.FromString("172.16.0.2"),
+			},
+			routetable.Target{
+				Type: routetable.TargetTypeOnLink,
+				CIDR: ip.MustParseCIDROrIP("10.0.2.0/26"),
+				GW:   ip.FromString("172.17.0.3"),
+			},
+		))
+	})
+})
+
 type mockIPIPDataplane struct {
 	tunnelLink      *mockLink
 	tunnelLinkAttrs *netlink.LinkAttrs
 	addrs           []netlink.Addr
 
+	// hostLinks and hostAddrs hold the host interfaces (other than the tunnel) and their
+	// addresses.
+	hostLinks []*mockLink
+	hostAddrs map[string][]netlink.Addr
+
 	RunCmdCalled     bool
 	LinkSetMTUCalled bool
 	LinkSetUpCalled  bool
@@ -388,6 +546,17 @@ func (d *mockIPIPDataplane) LinkByName(name string) (netlink.Link, error) {
 	return d.tunnelLink, nil
 }
 
+func (d *mockIPIPDataplane) LinkList() ([]netlink.Link, error) {
+	if err := d.incCallCount(); err != nil {
+		return nil, err
+	}
+	var links []netlink.Link
+	for _, l := range d.hostLinks {
+		links = append(links, l)
+	}
+	return links, nil
+}
+
 func (d *mockIPIPDataplane) LinkSetMTU(link netlink.Link, mtu int) error {
 	d.LinkSetMTUCalled = true
 	if err := d.incCallCount(); err != nil {
@@ -412,6 +581,9 @@ func (d *mockIPIPDataplane) AddrList(link netlink.Link, family int) ([]netlink.A
 	if err := d.incCallCount(); err != nil {
 		return nil, err
 	}
+	if addrs, ok := d.hostAddrs[link.Attrs().Name]; ok {
+		return addrs, nil
+	}
 	Expect(link.Attrs().Name).To(Equal("tunl0"))
 	return d.addrs, nil
 }
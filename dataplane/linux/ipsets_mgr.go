@@ -65,9 +65,10 @@ func (m *ipSetsManager) OnUpdate(msg interface{}) {
 			log.WithField("type", msg.Type).Panic("Unknown IP set type")
 		}
 		metadata := ipsets.IPSetMetadata{
-			Type:    setType,
-			SetID:   msg.Id,
-			MaxSize: m.maxSize,
+			Type:     setType,
+			SetID:    msg.Id,
+			MaxSize:  m.maxSize,
+			Selector: msg.Selector,
 		}
 		m.ipsetsDataplane.AddOrReplaceIPSet(metadata, msg.Members)
 	case *proto.IPSetRemove:
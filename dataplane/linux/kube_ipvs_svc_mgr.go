@@ -0,0 +1,90 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/ipsets"
+	"github.com/projectcalico/libcalico-go/lib/set"
+)
+
+// kubeIPVSAddrsUpdate is sent to the main loop when the addresses on the kube-ipvs0 interface
+// change.  The interface monitor doesn't report kube-ipvs0 with the other interfaces since its
+// addresses aren't host IPs.
+type kubeIPVSAddrsUpdate struct {
+	Addrs set.Set
+}
+
+// kubeIPVSServiceIPManager maintains an IP set of the service IPs that kube-proxy, in IPVS mode,
+// binds to the kube-ipvs0 interface.  IPVS handles service traffic in the INPUT chain; the IP set
+// exposes the service IPs so that rules can tell that traffic apart from traffic to the host
+// itself.
+type kubeIPVSServiceIPManager struct {
+	ipSetID         string
+	ipsetsDataplane ipsetsDataplane
+	maxSize         int
+
+	serviceIPs set.Set
+	// dirty is initially true so that we create the IP set, which rules may reference, even if
+	// kube-ipvs0 has no addresses.
+	dirty bool
+}
+
+func newKubeIPVSServiceIPManager(ipSetID string, ipsets ipsetsDataplane, maxIPSetSize int) *kubeIPVSServiceIPManager {
+	return &kubeIPVSServiceIPManager{
+		ipSetID:         ipSetID,
+		ipsetsDataplane: ipsets,
+		maxSize:         maxIPSetSize,
+		serviceIPs:      set.New(),
+		dirty:           true,
+	}
+}
+
+func (m *kubeIPVSServiceIPManager) OnUpdate(msg interface{}) {
+	switch msg := msg.(type) {
+	case *kubeIPVSAddrsUpdate:
+		serviceIPs := msg.Addrs
+		if serviceIPs == nil {
+			serviceIPs = set.New()
+		}
+		if serviceIPs.Equals(m.serviceIPs) {
+			return
+		}
+		log.WithField("numIPs", serviceIPs.Len()).Debug("kube-ipvs0 service IPs changed.")
+		m.serviceIPs = serviceIPs
+		m.dirty = true
+	}
+}
+
+func (m *kubeIPVSServiceIPManager) CompleteDeferredWork() error {
+	if !m.dirty {
+		return nil
+	}
+	var members []string
+	m.serviceIPs.Iter(func(item interface{}) error {
+		members = append(members, item.(string))
+		return nil
+	})
+	// Service IPs change relatively rarely so we replace the whole IP set.  The IP sets
+	// dataplane filters out the members of the other IP version.
+	m.ipsetsDataplane.AddOrReplaceIPSet(ipsets.IPSetMetadata{
+		Type:    ipsets.IPSetTypeHashIP,
+		SetID:   m.ipSetID,
+		MaxSize: m.maxSize,
+	}, members)
+	m.dirty = false
+	return nil
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/libcalico-go/lib/set"
+)
+
+var _ = Describe("kube-ipvs0 service IP manager", func() {
+	var (
+		mgr    *kubeIPVSServiceIPManager
+		ipSets *mockIPSets
+	)
+
+	BeforeEach(func() {
+		ipSets = newMockIPSets()
+		mgr = newKubeIPVSServiceIPManager("ipvs-svc-ips", ipSets, 1024)
+	})
+
+	It("should create an empty IP set at start of day", func() {
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(ipSets.AddOrReplaceCalled).To(BeTrue())
+		Expect(ipSets.Members["ipvs-svc-ips"]).To(Equal(set.New()))
+	})
+
+	Describe("after an address update", func() {
+		BeforeEach(func() {
+			mgr.OnUpdate(&kubeIPVSAddrsUpdate{Addrs: set.From("10.96.0.1", "10.96.0.10")})
+			Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		})
+
+		It("should add the service IPs", func() {
+			Expect(ipSets.Members["ipvs-svc-ips"]).To(Equal(set.From("10.96.0.1", "10.96.0.10")))
+		})
+
+		It("should not rewrite the IP set if the addresses haven't changed", func() {
+			ipSets.AddOrReplaceCalled = false
+			mgr.OnUpdate(&kubeIPVSAddrsUpdate{Addrs: set.From("10.96.0.10", "10.96.0.1")})
+			Expect(mgr.CompleteDeferredWork()).To(Succeed())
+			Expect(ipSets.AddOrReplaceCalled).To(BeFalse())
+		})
+
+		It("should empty the IP set when kube-ipvs0 goes away", func() {
+			mgr.OnUpdate(&kubeIPVSAddrsUpdate{})
+			Expect(mgr.CompleteDeferredWork()).To(Succeed())
+			Expect(ipSets.Members["ipvs-svc-ips"]).To(Equal(set.New()))
+		})
+
+		It("should ignore other interfaces' addresses", func() {
+			ipSets.AddOrReplaceCalled = false
+			mgr.OnUpdate(&ifaceAddrsUpdate{Name: "eth0", Addrs: set.From("10.0.0.1")})
+			Expect(mgr.CompleteDeferredWork()).To(Succeed())
+			Expect(ipSets.AddOrReplaceCalled).To(BeFalse())
+		})
+	})
+})
@@ -0,0 +1,186 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"bufio"
+	"bytes"
+	"os/exec"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/netlinkshim"
+)
+
+const (
+	cmdIPVSAdm = "ipvsadm"
+	cmdIPSet   = "ipset"
+	cmdNFT     = "nft"
+
+	// kubeProxyIPSetPrefix is the prefix of the IP sets that kube-proxy creates in IPVS mode.
+	kubeProxyIPSetPrefix = "KUBE-"
+	// kubeProxyNFTTable is the name of the table, in both the ip and ip6 families, that
+	// kube-proxy programs in nftables mode.
+	kubeProxyNFTTable = "kube-proxy"
+
+	kubeProxyCleanupInterval = 30 * time.Second
+)
+
+// kubeProxyCleaner removes the state that kube-proxy leaves behind in its IPVS and nftables modes
+// when our BPF kube-proxy replacement takes over.  (kube-proxy's iptables rules are cleaned up by
+// the iptables tables themselves.)  kube-proxy may still be running while the cluster is switched
+// over so, like the iptables cleanup, we keep checking for its state periodically.
+type kubeProxyCleaner struct {
+	newNetlinkHandle func() (netlinkshim.Interface, error)
+	newCmd           cmdFactory
+	lookPath         func(file string) (string, error)
+}
+
+func newKubeProxyCleaner() *kubeProxyCleaner {
+	return newKubeProxyCleanerWithShims(netlinkshim.NewRealNetlink, newRealCmd, exec.LookPath)
+}
+
+func newKubeProxyCleanerWithShims(
+	newNetlinkHandle func() (netlinkshim.Interface, error),
+	newCmd cmdFactory,
+	lookPath func(file string) (string, error),
+) *kubeProxyCleaner {
+	return &kubeProxyCleaner{
+		newNetlinkHandle: newNetlinkHandle,
+		newCmd:           newCmd,
+		lookPath:         lookPath,
+	}
+}
+
+// KeepCleaningUp runs CleanUp every interval, it never returns.
+func (c *kubeProxyCleaner) KeepCleaningUp(interval time.Duration) {
+	log.WithField("interval", interval).Info("kube-proxy IPVS/nftables cleanup thread started.")
+	for {
+		c.CleanUp()
+		time.Sleep(interval)
+	}
+}
+
+// CleanUp removes any of kube-proxy's IPVS and nftables state that is present.  Failures are
+// logged and the relevant cleanup is retried on the next call.
+func (c *kubeProxyCleaner) CleanUp() {
+	c.cleanUpIPVS()
+	c.cleanUpIPVSIPSets()
+	c.cleanUpNFTables()
+}
+
+// cleanUpIPVS flushes the IPVS virtual services and then removes kube-proxy's dummy interface,
+// which holds the service IPs.  We only flush IPVS if the interface is present since it's our only
+// evidence that the IPVS services belong to kube-proxy.
+func (c *kubeProxyCleaner) cleanUpIPVS() {
+	nlHandle, err := c.newNetlinkHandle()
+	if err != nil {
+		log.WithError(err).Warn("Failed to connect to netlink, will retry kube-proxy IPVS cleanup.")
+		return
+	}
+	defer nlHandle.Delete()
+
+	links, err := nlHandle.LinkList()
+	if err != nil {
+		log.WithError(err).Warn("Failed to list interfaces, will retry kube-proxy IPVS cleanup.")
+		return
+	}
+	for _, link := range links {
+		if link.Attrs().Name != KubeIPVSInterface {
+			continue
+		}
+		log.Info("Found kube-proxy's IPVS interface, cleaning up kube-proxy's IPVS state.")
+		if _, err := c.lookPath(cmdIPVSAdm); err != nil {
+			log.Warn("ipvsadm not available, unable to flush kube-proxy's IPVS services.")
+		} else if out, err := c.newCmd(cmdIPVSAdm, "--clear").Output(); err != nil {
+			log.WithError(err).WithField("output", string(out)).Warn(
+				"Failed to flush IPVS services, will retry.")
+			return
+		}
+		if err := nlHandle.LinkDel(link); err != nil {
+			log.WithError(err).Warn("Failed to remove kube-proxy's IPVS interface, will retry.")
+			return
+		}
+		log.Info("Removed kube-proxy's IPVS interface.")
+	}
+}
+
+// cleanUpIPVSIPSets removes the IP sets that kube-proxy uses in IPVS mode.  The sets can only be
+// deleted once the iptables rules that refer to them are gone so we expect some failures until the
+// iptables cleanup has caught up.
+func (c *kubeProxyCleaner) cleanUpIPVSIPSets() {
+	if _, err := c.lookPath(cmdIPSet); err != nil {
+		log.Debug("ipset not available, skipping cleanup of kube-proxy's IP sets.")
+		return
+	}
+	out, err := c.newCmd(cmdIPSet, "list", "-n").Output()
+	if err != nil {
+		log.WithError(err).Warn("Failed to list IP sets, will retry kube-proxy IP set cleanup.")
+		return
+	}
+	for _, name := range outputLines(out) {
+		if !strings.HasPrefix(name, kubeProxyIPSetPrefix) {
+			continue
+		}
+		if _, err := c.newCmd(cmdIPSet, "destroy", name).Output(); err != nil {
+			log.WithError(err).WithField("name", name).Debug(
+				"Failed to remove kube-proxy IP set, probably still in use; will retry.")
+			continue
+		}
+		log.WithField("name", name).Info("Removed kube-proxy IP set.")
+	}
+}
+
+// cleanUpNFTables removes the tables that kube-proxy programs in nftables mode.
+func (c *kubeProxyCleaner) cleanUpNFTables() {
+	if _, err := c.lookPath(cmdNFT); err != nil {
+		log.Debug("nft not available, skipping cleanup of kube-proxy's nftables tables.")
+		return
+	}
+	out, err := c.newCmd(cmdNFT, "list", "tables").Output()
+	if err != nil {
+		log.WithError(err).Warn("Failed to list nftables tables, will retry kube-proxy nftables cleanup.")
+		return
+	}
+	for _, line := range outputLines(out) {
+		// Format: "table <family> <name>".
+		fields := strings.Fields(line)
+		if len(fields) != 3 || fields[0] != "table" || fields[2] != kubeProxyNFTTable {
+			continue
+		}
+		family := fields[1]
+		if out, err := c.newCmd(cmdNFT, "delete", "table", family, kubeProxyNFTTable).Output(); err != nil {
+			log.WithError(err).WithFields(log.Fields{
+				"family": family,
+				"output": string(out),
+			}).Warn("Failed to remove kube-proxy's nftables table, will retry.")
+			continue
+		}
+		log.WithField("family", family).Info("Removed kube-proxy's nftables table.")
+	}
+}
+
+func outputLines(out []byte) []string {
+	var lines []string
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
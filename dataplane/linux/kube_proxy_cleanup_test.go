@@ -0,0 +1,125 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/netlinkshim/mocknetlink"
+)
+
+var _ = Describe("kube-proxy cleanup", func() {
+	var (
+		dataplane *mocknetlink.MockNetlinkDataplane
+		cmds      *kpcTestCmds
+		missing   map[string]bool
+		cleaner   *kubeProxyCleaner
+	)
+
+	BeforeEach(func() {
+		dataplane = mocknetlink.New()
+		dataplane.AddIface(1, "eth0", true, true)
+		cmds = &kpcTestCmds{
+			outputs: map[string]string{},
+			errs:    map[string]error{},
+		}
+		missing = map[string]bool{}
+		lookPath := func(file string) (string, error) {
+			if missing[file] {
+				return "", errors.New("not found")
+			}
+			return "/usr/sbin/" + file, nil
+		}
+		cleaner = newKubeProxyCleanerWithShims(dataplane.NewMockNetlink, cmds.factory, lookPath)
+	})
+
+	It("should do nothing if kube-proxy left no state", func() {
+		cmds.outputs["nft list tables"] = "table ip filter\ntable inet calico\n"
+		cleaner.CleanUp()
+		Expect(cmds.run).To(Equal([]string{"ipset list -n", "nft list tables"}))
+		Expect(dataplane.NetlinkOpen).To(BeFalse())
+	})
+
+	It("should flush IPVS and remove kube-ipvs0", func() {
+		dataplane.AddIface(2, KubeIPVSInterface, false, false)
+		cleaner.CleanUp()
+		Expect(cmds.run).To(ContainElement("ipvsadm --clear"))
+		Expect(dataplane.DeletedLinks.Contains(KubeIPVSInterface)).To(BeTrue())
+		Expect(dataplane.DeletedLinks.Contains("eth0")).To(BeFalse())
+	})
+
+	It("should keep kube-ipvs0 if flushing IPVS fails", func() {
+		dataplane.AddIface(2, KubeIPVSInterface, false, false)
+		cmds.errs["ipvsadm --clear"] = errors.New("failed")
+		cleaner.CleanUp()
+		Expect(dataplane.DeletedLinks.Contains(KubeIPVSInterface)).To(BeFalse())
+	})
+
+	It("should still remove kube-ipvs0 without ipvsadm", func() {
+		dataplane.AddIface(2, KubeIPVSInterface, false, false)
+		missing[cmdIPVSAdm] = true
+		cleaner.CleanUp()
+		Expect(cmds.run).NotTo(ContainElement("ipvsadm --clear"))
+		Expect(dataplane.DeletedLinks.Contains(KubeIPVSInterface)).To(BeTrue())
+	})
+
+	It("should only remove kube-proxy's IP sets", func() {
+		cmds.outputs["ipset list -n"] = "cali40all-ipam-pools\nKUBE-CLUSTER-IP\nKUBE-LOOP-BACK\n"
+		cmds.errs["ipset destroy KUBE-LOOP-BACK"] = errors.New("in use")
+		cleaner.CleanUp()
+		Expect(cmds.run).To(ContainElement("ipset destroy KUBE-CLUSTER-IP"))
+		Expect(cmds.run).To(ContainElement("ipset destroy KUBE-LOOP-BACK"))
+		Expect(cmds.run).NotTo(ContainElement(ContainSubstring("cali40all-ipam-pools")))
+	})
+
+	It("should remove kube-proxy's nftables tables", func() {
+		cmds.outputs["nft list tables"] = "table ip filter\ntable ip kube-proxy\ntable ip6 kube-proxy\n"
+		cleaner.CleanUp()
+		Expect(cmds.run).To(ContainElement("nft delete table ip kube-proxy"))
+		Expect(cmds.run).To(ContainElement("nft delete table ip6 kube-proxy"))
+		Expect(cmds.run).NotTo(ContainElement("nft delete table ip filter"))
+	})
+
+	It("should skip the nftables cleanup without nft", func() {
+		missing[cmdNFT] = true
+		cleaner.CleanUp()
+		Expect(cmds.run).NotTo(ContainElement(HavePrefix("nft")))
+	})
+})
+
+type kpcTestCmds struct {
+	outputs map[string]string
+	errs    map[string]error
+	run     []string
+}
+
+func (c *kpcTestCmds) factory(name string, args ...string) cmdIface {
+	cmdLine := strings.Join(append([]string{name}, args...), " ")
+	c.run = append(c.run, cmdLine)
+	return &kpcTestCmd{output: []byte(c.outputs[cmdLine]), err: c.errs[cmdLine]}
+}
+
+type kpcTestCmd struct {
+	output []byte
+	err    error
+}
+
+func (c *kpcTestCmd) Output() ([]byte, error) {
+	return c.output, c.err
+}
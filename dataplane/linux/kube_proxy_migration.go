@@ -0,0 +1,136 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	KubeProxyMigrationDisabled   = "Disabled"
+	KubeProxyMigrationDefer      = "Defer"
+	KubeProxyMigrationPrecedence = "Precedence"
+
+	// kubeProxyHealthzURL is kube-proxy's default health endpoint, kube-proxy runs in the host's
+	// network namespace so we can reach it over loopback.
+	kubeProxyHealthzURL     = "http://127.0.0.1:10256/healthz"
+	kubeProxyHealthzTimeout = time.Second
+
+	kubeProxyMigrationCheckInterval = 10 * time.Second
+)
+
+// kubeProxySaveCmds are the commands that we use to read kube-proxy's NAT rules; kube-proxy may
+// be using either iptables backend so we check both.
+var kubeProxySaveCmds = []string{"iptables-legacy-save", "iptables-nft-save"}
+
+var gaugeKubeProxyMigrationPending = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "felix_bpf_kube_proxy_migration_pending",
+	Help: "1 while Felix has detected a live kube-proxy that the BPF dataplane is migrating from, 0 otherwise.",
+})
+
+func init() {
+	prometheus.MustRegister(gaugeKubeProxyMigrationPending)
+}
+
+// kubeProxyMigrationGuard detects a live kube-proxy while the BPF dataplane takes over service
+// NAT from it.  kube-proxy counts as live if its KUBE-SERVICES chain has rules that jump to a
+// service chain and its health endpoint responds.  The second check means that rules left behind
+// by a kube-proxy that has been removed don't hold up the migration.
+type kubeProxyMigrationGuard struct {
+	newCmd       cmdFactory
+	lookPath     func(file string) (string, error)
+	probeHealthz func() bool
+}
+
+func newKubeProxyMigrationGuard() *kubeProxyMigrationGuard {
+	return newKubeProxyMigrationGuardWithShims(newRealCmd, exec.LookPath, probeKubeProxyHealthz)
+}
+
+func newKubeProxyMigrationGuardWithShims(
+	newCmd cmdFactory,
+	lookPath func(file string) (string, error),
+	probeHealthz func() bool,
+) *kubeProxyMigrationGuard {
+	return &kubeProxyMigrationGuard{
+		newCmd:       newCmd,
+		lookPath:     lookPath,
+		probeHealthz: probeHealthz,
+	}
+}
+
+// KubeProxyLive returns true if kube-proxy is still programming service NAT rules.
+func (g *kubeProxyMigrationGuard) KubeProxyLive() bool {
+	live := g.kubeProxyServiceRulesPresent() && g.probeHealthz()
+	if live {
+		gaugeKubeProxyMigrationPending.Set(1)
+	} else {
+		gaugeKubeProxyMigrationPending.Set(0)
+	}
+	return live
+}
+
+// WaitForMigration checks every interval until kube-proxy is no longer live.  It logs the
+// migration status so that the operator can see what Felix is waiting for.
+func (g *kubeProxyMigrationGuard) WaitForMigration(interval time.Duration) {
+	start := time.Now()
+	for g.KubeProxyLive() {
+		log.WithField("waitedFor", time.Since(start).Round(time.Second)).Info(
+			"kube-proxy is still running, waiting for it to be removed before completing migration to BPF NAT.")
+		time.Sleep(interval)
+	}
+	log.WithField("waitedFor", time.Since(start).Round(time.Second)).Info(
+		"No live kube-proxy found, migration to BPF NAT complete.")
+}
+
+// kubeProxyServiceRulesPresent scans the NAT table of each iptables backend for kube-proxy's
+// per-service rules.  If we can't read a backend's rules, we assume that kube-proxy isn't using it.
+func (g *kubeProxyMigrationGuard) kubeProxyServiceRulesPresent() bool {
+	for _, cmd := range kubeProxySaveCmds {
+		if _, err := g.lookPath(cmd); err != nil {
+			log.WithField("cmd", cmd).Debug("iptables backend not available, skipping.")
+			continue
+		}
+		out, err := g.newCmd(cmd, "-t", "nat").Output()
+		if err != nil {
+			log.WithError(err).WithField("cmd", cmd).Warn("Failed to read NAT rules, ignoring backend.")
+			continue
+		}
+		for _, line := range outputLines(out) {
+			if strings.HasPrefix(line, "-A KUBE-SERVICES ") && strings.Contains(line, "-j KUBE-SVC-") {
+				log.WithField("cmd", cmd).Debug("Found kube-proxy service rules.")
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func probeKubeProxyHealthz() bool {
+	client := http.Client{Timeout: kubeProxyHealthzTimeout}
+	resp, err := client.Get(kubeProxyHealthzURL)
+	if err != nil {
+		log.WithError(err).Debug("kube-proxy health endpoint didn't respond.")
+		return false
+	}
+	// kube-proxy reports 503 if its rules are stale but it's still there so any response will do.
+	_ = resp.Body.Close()
+	return true
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+const kubeProxyNATRules = `*nat
+:PREROUTING ACCEPT [0:0]
+:KUBE-SERVICES - [0:0]
+-A PREROUTING -m comment --comment "kubernetes service portals" -j KUBE-SERVICES
+-A KUBE-SERVICES -d 10.96.0.1/32 -p tcp -m comment --comment "default/kubernetes:https cluster IP" -m tcp --dport 443 -j KUBE-SVC-NPX46M4PTMTKRN6Y
+-A KUBE-SERVICES -m comment --comment "kubernetes service nodeports" -m addrtype --dst-type LOCAL -j KUBE-NODEPORTS
+COMMIT
+`
+
+var _ = Describe("kube-proxy migration guard", func() {
+	var (
+		cmds      *kpcTestCmds
+		missing   map[string]bool
+		healthzUp bool
+		guard     *kubeProxyMigrationGuard
+	)
+
+	BeforeEach(func() {
+		cmds = &kpcTestCmds{
+			outputs: map[string]string{},
+			errs:    map[string]error{},
+		}
+		missing = map[string]bool{}
+		healthzUp = true
+		lookPath := func(file string) (string, error) {
+			if missing[file] {
+				return "", errors.New("not found")
+			}
+			return "/usr/sbin/" + file, nil
+		}
+		guard = newKubeProxyMigrationGuardWithShims(cmds.factory, lookPath, func() bool {
+			return healthzUp
+		})
+	})
+
+	It("should report no kube-proxy if there are no KUBE-SERVICES rules", func() {
+		cmds.outputs["iptables-legacy-save -t nat"] = "*nat\n:PREROUTING ACCEPT [0:0]\nCOMMIT\n"
+		Expect(guard.KubeProxyLive()).To(BeFalse())
+		Expect(cmds.run).To(Equal([]string{"iptables-legacy-save -t nat", "iptables-nft-save -t nat"}))
+	})
+
+	It("should detect kube-proxy's rules in the legacy backend", func() {
+		cmds.outputs["iptables-legacy-save -t nat"] = kubeProxyNATRules
+		Expect(guard.KubeProxyLive()).To(BeTrue())
+	})
+
+	It("should detect kube-proxy's rules in the nft backend", func() {
+		cmds.outputs["iptables-nft-save -t nat"] = kubeProxyNATRules
+		Expect(guard.KubeProxyLive()).To(BeTrue())
+	})
+
+	It("should ignore KUBE-SERVICES without any services", func() {
+		cmds.outputs["iptables-nft-save -t nat"] = `-A KUBE-SERVICES -m addrtype --dst-type LOCAL -j KUBE-NODEPORTS`
+		Expect(guard.KubeProxyLive()).To(BeFalse())
+	})
+
+	It("should ignore rules left behind by a kube-proxy that has gone", func() {
+		cmds.outputs["iptables-legacy-save -t nat"] = kubeProxyNATRules
+		healthzUp = false
+		Expect(guard.KubeProxyLive()).To(BeFalse())
+	})
+
+	It("should skip backends that aren't available", func() {
+		missing["iptables-legacy-save"] = true
+		cmds.errs["iptables-nft-save -t nat"] = errors.New("failed")
+		Expect(guard.KubeProxyLive()).To(BeFalse())
+		Expect(cmds.run).To(Equal([]string{"iptables-nft-save -t nat"}))
+	})
+})
@@ -0,0 +1,95 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"github.com/projectcalico/libcalico-go/lib/set"
+
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/rules"
+)
+
+// workloadLinkLocalManager maintains the filter table chain that applies WorkloadLinkLocalAccess
+// to traffic from workloads to the configured link-local services, such as the cloud metadata
+// service.  The workload egress chains jump to it before applying policy.  The manager tracks the
+// interfaces of the workloads in the exception namespaces, which the chain leaves to policy.
+type workloadLinkLocalManager struct {
+	// Our dependencies.
+	filterTable  iptablesTable
+	ruleRenderer rules.RuleRenderer
+	ipVersion    uint8
+
+	// Config.
+	exceptNamespaces set.Set
+
+	// Internal state.
+	exceptIfaceNames map[proto.WorkloadEndpointID]string
+	dirty            bool
+}
+
+func newWorkloadLinkLocalManager(
+	filterTable iptablesTable,
+	ruleRenderer rules.RuleRenderer,
+	exceptNamespaces []string,
+	ipVersion uint8,
+) *workloadLinkLocalManager {
+	return &workloadLinkLocalManager{
+		filterTable:      filterTable,
+		ruleRenderer:     ruleRenderer,
+		ipVersion:        ipVersion,
+		exceptNamespaces: set.FromArray(exceptNamespaces),
+		exceptIfaceNames: map[proto.WorkloadEndpointID]string{},
+		dirty:            true,
+	}
+}
+
+func (m *workloadLinkLocalManager) OnUpdate(protoBufMsg interface{}) {
+	switch msg := protoBufMsg.(type) {
+	case *proto.WorkloadEndpointUpdate:
+		if !m.exceptNamespaces.Contains(workloadNamespace(*msg.Id)) {
+			return
+		}
+		if m.exceptIfaceNames[*msg.Id] != msg.Endpoint.Name {
+			m.exceptIfaceNames[*msg.Id] = msg.Endpoint.Name
+			m.dirty = true
+		}
+	case *proto.WorkloadEndpointRemove:
+		if _, ok := m.exceptIfaceNames[*msg.Id]; ok {
+			delete(m.exceptIfaceNames, *msg.Id)
+			m.dirty = true
+		}
+	}
+}
+
+func (m *workloadLinkLocalManager) CompleteDeferredWork() error {
+	if !m.dirty {
+		return nil
+	}
+	var names []string
+	for _, name := range m.exceptIfaceNames {
+		names = append(names, name)
+	}
+	m.filterTable.UpdateChain(m.ruleRenderer.WorkloadLinkLocalChain(m.ipVersion, names))
+	m.dirty = false
+	return nil
+}
+
+// workloadLinkLocalEnabled returns true if the workload egress chains jump to the workload
+// link-local chain, which therefore needs a manager.  In BPF mode, the policy programs apply
+// WorkloadLinkLocalAccess instead.
+func workloadLinkLocalEnabled(config Config) bool {
+	access := config.RulesConfig.WorkloadLinkLocalAccess
+	return !config.BPFEnabled && (access == "Allow" || access == "Deny")
+}
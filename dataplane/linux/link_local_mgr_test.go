@@ -0,0 +1,127 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/ipsets"
+	"github.com/projectcalico/felix/iptables"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/rules"
+)
+
+var _ = Describe("Workload link-local manager", func() {
+	var (
+		linkLocalMgr *workloadLinkLocalManager
+		filterTable  *mockTable
+		ruleRenderer rules.RuleRenderer
+	)
+
+	wlID1 := proto.WorkloadEndpointID{
+		OrchestratorId: "k8s",
+		WorkloadId:     "default/pod-11",
+		EndpointId:     "endpoint-id-11",
+	}
+	wlID2 := proto.WorkloadEndpointID{
+		OrchestratorId: "k8s",
+		WorkloadId:     "kube-system/pod-12",
+		EndpointId:     "endpoint-id-12",
+	}
+
+	exceptRule := func(iface string) iptables.Rule {
+		return iptables.Rule{
+			Match:  iptables.Match().InInterface(iface),
+			Action: iptables.ReturnAction{},
+		}
+	}
+	denyRule := iptables.Rule{
+		Match:   iptables.Match().DestNet("169.254.169.254/32"),
+		Action:  iptables.DropAction{},
+		Comment: []string{"Drop traffic to link-local service"},
+	}
+
+	addWorkload := func(id proto.WorkloadEndpointID, iface string) {
+		linkLocalMgr.OnUpdate(&proto.WorkloadEndpointUpdate{
+			Id: &id,
+			Endpoint: &proto.WorkloadEndpoint{
+				Name:     iface,
+				Ipv4Nets: []string{"10.0.240.10/32"},
+			},
+		})
+	}
+
+	BeforeEach(func() {
+		filterTable = newMockTable("filter")
+		ruleRenderer = rules.NewRenderer(rules.Config{
+			IPSetConfigV4: ipsets.NewIPVersionConfig(
+				ipsets.IPFamilyV4,
+				"cali",
+				nil,
+				nil,
+			),
+			IptablesMarkPass:          0x1,
+			IptablesMarkAccept:        0x2,
+			IptablesMarkScratch0:      0x4,
+			IptablesMarkScratch1:      0x8,
+			IptablesMarkEndpoint:      0x11110000,
+			WorkloadLinkLocalAccess:   "Deny",
+			WorkloadLinkLocalServices: []string{"169.254.169.254/32"},
+		})
+		linkLocalMgr = newWorkloadLinkLocalManager(filterTable, ruleRenderer, []string{"kube-system"}, 4)
+	})
+
+	It("should program the chain with no workloads", func() {
+		Expect(linkLocalMgr.CompleteDeferredWork()).To(Succeed())
+		filterTable.checkChains([][]*iptables.Chain{{{
+			Name:  rules.ChainWorkloadLinkLocal,
+			Rules: []iptables.Rule{denyRule},
+		}}})
+	})
+
+	Describe("with workloads", func() {
+		BeforeEach(func() {
+			addWorkload(wlID1, "cali1")
+			addWorkload(wlID2, "cali2")
+			Expect(linkLocalMgr.CompleteDeferredWork()).To(Succeed())
+		})
+
+		It("should only except the workloads in the exception namespaces", func() {
+			filterTable.checkChains([][]*iptables.Chain{{{
+				Name:  rules.ChainWorkloadLinkLocal,
+				Rules: []iptables.Rule{exceptRule("cali2"), denyRule},
+			}}})
+		})
+
+		It("should follow the workload's interface", func() {
+			addWorkload(wlID2, "cali3")
+			Expect(linkLocalMgr.CompleteDeferredWork()).To(Succeed())
+			filterTable.checkChains([][]*iptables.Chain{{{
+				Name:  rules.ChainWorkloadLinkLocal,
+				Rules: []iptables.Rule{exceptRule("cali3"), denyRule},
+			}}})
+		})
+
+		It("should remove the exception when the workload is removed", func() {
+			linkLocalMgr.OnUpdate(&proto.WorkloadEndpointRemove{Id: &wlID2})
+			Expect(linkLocalMgr.CompleteDeferredWork()).To(Succeed())
+			filterTable.checkChains([][]*iptables.Chain{{{
+				Name:  rules.ChainWorkloadLinkLocal,
+				Rules: []iptables.Rule{denyRule},
+			}}})
+		})
+	})
+})
@@ -225,3 +225,85 @@ var _ = Describe("Masquerade manager", func() {
 		})
 	})
 })
+
+var _ = Describe("IPv6 masquerade manager", func() {
+	var (
+		masqMgr  *masqManager
+		natTable *mockTable
+		ipSets   *mockIPSets
+	)
+
+	BeforeEach(func() {
+		ipSets = newMockIPSets()
+		natTable = newMockTable("nat")
+		ruleRenderer := rules.NewRenderer(rules.Config{
+			IPSetConfigV6: ipsets.NewIPVersionConfig(
+				ipsets.IPFamilyV6,
+				"cali",
+				nil,
+				nil,
+			),
+			IptablesMarkPass:     0x1,
+			IptablesMarkAccept:   0x2,
+			IptablesMarkScratch0: 0x4,
+			IptablesMarkScratch1: 0x8,
+			IptablesMarkEndpoint: 0x11110000,
+			BPFEnabled:           true,
+		})
+		masqMgr = newMasqManager(ipSets, natTable, ruleRenderer, 1024, 6)
+
+		masqMgr.OnUpdate(&proto.IPAMPoolUpdate{
+			Id: "pool-1",
+			Pool: &proto.IPAMPool{
+				Cidr:       "10.0.0.0/16",
+				Masquerade: true,
+			},
+		})
+		masqMgr.OnUpdate(&proto.IPAMPoolUpdate{
+			Id: "pool-1v6",
+			Pool: &proto.IPAMPool{
+				Cidr:       "feed:beef::/96",
+				Masquerade: true,
+			},
+		})
+		masqMgr.OnUpdate(&proto.IPAMPoolUpdate{
+			Id: "pool-2v6",
+			Pool: &proto.IPAMPool{
+				Cidr: "dead:beef::/96",
+			},
+		})
+		err := masqMgr.CompleteDeferredWork()
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("should only add the IPv6 pools to the IP sets", func() {
+		Expect(ipSets.Members["masq-ipam-pools"]).To(Equal(set.From("feed:beef::/96")))
+		Expect(ipSets.Members["all-ipam-pools"]).To(Equal(set.From("feed:beef::/96", "dead:beef::/96")))
+	})
+
+	It("should program the chain using the IP sets, even in BPF mode", func() {
+		natTable.checkChains([][]*iptables.Chain{{{
+			Name: "cali-nat-outgoing",
+			Rules: []iptables.Rule{
+				{
+					Action: iptables.MasqAction{},
+					Match: iptables.Match().
+						SourceIPSet("cali60masq-ipam-pools").
+						NotDestIPSet("cali60all-ipam-pools"),
+				},
+			},
+		}}})
+	})
+
+	It("should clean up the IP sets when the masq pool is removed", func() {
+		masqMgr.OnUpdate(&proto.IPAMPoolRemove{Id: "pool-1v6"})
+		err := masqMgr.CompleteDeferredWork()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(ipSets.Members["masq-ipam-pools"]).To(Equal(set.New()))
+		Expect(ipSets.Members["all-ipam-pools"]).To(Equal(set.From("dead:beef::/96")))
+		natTable.checkChains([][]*iptables.Chain{{{
+			Name:  "cali-nat-outgoing",
+			Rules: nil,
+		}}})
+	})
+})
@@ -0,0 +1,91 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"reflect"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+var gaugeVecManagerConsecutiveFailures = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "felix_int_dataplane_manager_consecutive_failures",
+	Help: "Number of consecutive times that each dataplane manager has failed to complete its deferred work.",
+}, []string{"manager"})
+
+func init() {
+	prometheus.MustRegister(gaugeVecManagerConsecutiveFailures)
+}
+
+// managerFailureBudget tracks consecutive CompleteDeferredWork failures for each manager.  Without
+// it, a manager that can never succeed (for example, because a kernel module is missing) makes the
+// dataplane retry forever while reporting itself as ready.  Once a manager fails more than budget
+// times in a row, the budget is exhausted: Felix reports non-ready, naming the manager in the log,
+// until the manager succeeds again.
+type managerFailureBudget struct {
+	budget    int
+	failures  map[Manager]int
+	exhausted map[Manager]bool
+}
+
+func newManagerFailureBudget(budget int) *managerFailureBudget {
+	return &managerFailureBudget{
+		budget:    budget,
+		failures:  map[Manager]int{},
+		exhausted: map[Manager]bool{},
+	}
+}
+
+// OnResult records the result of a call to the manager's CompleteDeferredWork.
+func (b *managerFailureBudget) OnResult(mgr Manager, err error) {
+	if b.budget <= 0 {
+		return
+	}
+	name := managerName(mgr)
+	if err == nil {
+		if b.failures[mgr] > 0 {
+			gaugeVecManagerConsecutiveFailures.DeleteLabelValues(name)
+			delete(b.failures, mgr)
+		}
+		if b.exhausted[mgr] {
+			log.WithField("manager", name).Info("Dataplane manager recovered after exhausting its failure budget.")
+			delete(b.exhausted, mgr)
+		}
+		return
+	}
+
+	b.failures[mgr]++
+	failures := b.failures[mgr]
+	gaugeVecManagerConsecutiveFailures.WithLabelValues(name).Set(float64(failures))
+	if failures <= b.budget || b.exhausted[mgr] {
+		return
+	}
+
+	log.WithError(err).WithFields(log.Fields{
+		"manager":  name,
+		"failures": failures,
+	}).Error("Dataplane manager exhausted its failure budget, reporting non-ready until it recovers.")
+	b.exhausted[mgr] = true
+}
+
+// Ready returns false if any manager has exhausted its failure budget.
+func (b *managerFailureBudget) Ready() bool {
+	return len(b.exhausted) == 0
+}
+
+func managerName(mgr Manager) string {
+	return reflect.Indirect(reflect.ValueOf(mgr)).Type().Name()
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type failingManager struct{}
+
+func (m *failingManager) OnUpdate(interface{}) {}
+
+func (m *failingManager) CompleteDeferredWork() error {
+	return errors.New("kernel module missing")
+}
+
+var _ = Describe("Manager failure budget", func() {
+	var (
+		budget *managerFailureBudget
+		mgr    *failingManager
+	)
+
+	failTimes := func(m Manager, n int) {
+		for i := 0; i < n; i++ {
+			budget.OnResult(m, m.CompleteDeferredWork())
+		}
+	}
+
+	BeforeEach(func() {
+		mgr = &failingManager{}
+	})
+
+	Describe("with a budget of 3", func() {
+		BeforeEach(func() {
+			budget = newManagerFailureBudget(3)
+		})
+
+		It("should stay ready within the budget", func() {
+			failTimes(mgr, 3)
+			Expect(budget.Ready()).To(BeTrue())
+		})
+
+		It("should report non-ready once the budget is exhausted", func() {
+			failTimes(mgr, 4)
+			Expect(budget.Ready()).To(BeFalse())
+		})
+
+		It("should recover when the manager succeeds", func() {
+			failTimes(mgr, 4)
+			budget.OnResult(mgr, nil)
+			Expect(budget.Ready()).To(BeTrue())
+		})
+
+		It("should only count consecutive failures", func() {
+			failTimes(mgr, 3)
+			budget.OnResult(mgr, nil)
+			failTimes(mgr, 3)
+			Expect(budget.Ready()).To(BeTrue())
+		})
+	})
+
+	It("should do nothing with a budget of 0", func() {
+		budget = newManagerFailureBudget(0)
+		failTimes(mgr, 100)
+		Expect(budget.Ready()).To(BeTrue())
+	})
+})
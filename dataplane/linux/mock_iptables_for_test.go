@@ -25,7 +25,13 @@ type mockTable struct {
 	Table          string
 	currentChains  map[string]*iptables.Chain
 	expectedChains map[string]*iptables.Chain
-	UpdateCalled   bool
+	fragments      map[string]iptables.ChainFragments
+	// InsertGroups holds the insert groups of each chain.
+	InsertGroups map[string]iptables.InsertGroups
+	UpdateCalled bool
+	// RuleCounters holds the rule counters to return from ReadRuleCounters, by chain name.
+	RuleCounters map[string]map[string]uint64
+	CountersErr  error
 }
 
 func newMockTable(table string) *mockTable {
@@ -33,6 +39,8 @@ func newMockTable(table string) *mockTable {
 		Table:          table,
 		currentChains:  map[string]*iptables.Chain{},
 		expectedChains: map[string]*iptables.Chain{},
+		fragments:      map[string]iptables.ChainFragments{},
+		InsertGroups:   map[string]iptables.InsertGroups{},
 	}
 }
 
@@ -71,6 +79,47 @@ func (t *mockTable) RemoveChainByName(name string) {
 	delete(t.currentChains, name)
 }
 
+func (t *mockTable) UpdateChainFragment(chainName string, owner string, priority int, rules []iptables.Rule) {
+	if t.fragments[chainName] == nil {
+		t.fragments[chainName] = iptables.ChainFragments{}
+	}
+	t.fragments[chainName][owner] = iptables.ChainFragment{Owner: owner, Priority: priority, Rules: rules}
+	t.UpdateChain(t.fragments[chainName].Merge(chainName))
+}
+
+func (t *mockTable) RemoveChainFragment(chainName string, owner string) {
+	fragments := t.fragments[chainName]
+	if _, ok := fragments[owner]; !ok {
+		return
+	}
+	delete(fragments, owner)
+	if len(fragments) == 0 {
+		delete(t.fragments, chainName)
+		t.RemoveChainByName(chainName)
+		return
+	}
+	t.UpdateChain(fragments.Merge(chainName))
+}
+
+func (t *mockTable) UpdateInsertGroup(chainName string, group string, position iptables.InsertPosition, priority int, rules []iptables.Rule) {
+	Expect(iptables.CheckInsertGroupName(group)).To(Succeed())
+	if t.InsertGroups[chainName] == nil {
+		t.InsertGroups[chainName] = iptables.InsertGroups{}
+	}
+	t.InsertGroups[chainName][group] = iptables.InsertGroup{Name: group, Position: position, Priority: priority, Rules: rules}
+}
+
+func (t *mockTable) RemoveInsertGroup(chainName string, group string) {
+	delete(t.InsertGroups[chainName], group)
+}
+
+func (t *mockTable) ReadRuleCounters(chainName string) (map[string]uint64, error) {
+	if t.CountersErr != nil {
+		return nil, t.CountersErr
+	}
+	return t.RuleCounters[chainName], nil
+}
+
 func (t *mockTable) checkChains(expecteds [][]*iptables.Chain) {
 	t.expectedChains = map[string]*iptables.Chain{}
 	for _, expected := range expecteds {
@@ -0,0 +1,120 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// minMsgBatchSize is the initial, and smallest, limit on the number of messages that we'll
+	// read from a channel before we apply the changes.
+	minMsgBatchSize = 100
+	// defaultMaxMsgBatchSize is used if the config doesn't set an upper bound.
+	defaultMaxMsgBatchSize = 1000
+)
+
+var (
+	gaugeVecMsgBatchLimit = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "felix_int_dataplane_msg_batch_limit",
+		Help: "Current limit on the number of messages read from each input channel per batch.",
+	}, []string{"channel"})
+	gaugeVecChannelBacklog = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "felix_int_dataplane_channel_backlog",
+		Help: "Number of messages left on each input channel after the last batch.",
+	}, []string{"channel"})
+	countVecChannelSaturated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "felix_int_dataplane_channel_saturated",
+		Help: "Number of batches that started with the input channel full, i.e. with its senders blocked.",
+	}, []string{"channel"})
+)
+
+func init() {
+	prometheus.MustRegister(gaugeVecMsgBatchLimit)
+	prometheus.MustRegister(gaugeVecChannelBacklog)
+	prometheus.MustRegister(countVecChannelSaturated)
+}
+
+// msgBatchLimit is an adaptive limit on the number of messages that the main loop reads from one
+// of its input channels before it applies them.  Small batches keep latency low when there's
+// little to do but, when updates arrive faster than we apply them (such as during the initial
+// sync of a large cluster), applying after every few messages wastes time and the senders block.
+// So the limit doubles, up to max, each time a batch hits it with messages still queued, and
+// halves, down to minMsgBatchSize, each time a batch is much smaller than it.
+type msgBatchLimit struct {
+	channel string
+	max     int
+	current int
+
+	gaugeLimit     prometheus.Gauge
+	gaugeBacklog   prometheus.Gauge
+	countSaturated prometheus.Counter
+}
+
+func newMsgBatchLimit(channel string, maxSize int) *msgBatchLimit {
+	if maxSize < minMsgBatchSize {
+		maxSize = minMsgBatchSize
+	}
+	l := &msgBatchLimit{
+		channel:        channel,
+		max:            maxSize,
+		current:        minMsgBatchSize,
+		gaugeLimit:     gaugeVecMsgBatchLimit.WithLabelValues(channel),
+		gaugeBacklog:   gaugeVecChannelBacklog.WithLabelValues(channel),
+		countSaturated: countVecChannelSaturated.WithLabelValues(channel),
+	}
+	l.gaugeLimit.Set(float64(l.current))
+	return l
+}
+
+// Limit returns the number of messages to read in the next batch.
+func (l *msgBatchLimit) Limit() int {
+	return l.current
+}
+
+// OnBatchStart is called with the channel's length and capacity before reading a batch.
+func (l *msgBatchLimit) OnBatchStart(chanLen, chanCap int) {
+	if chanCap > 0 && chanLen >= chanCap {
+		l.countSaturated.Inc()
+	}
+}
+
+// OnBatchDone adjusts the limit after a batch of batchSize messages, leaving backlog messages on
+// the channel.
+func (l *msgBatchLimit) OnBatchDone(batchSize, backlog int) {
+	l.gaugeBacklog.Set(float64(backlog))
+	old := l.current
+	if batchSize >= l.current && backlog > 0 {
+		l.current *= 2
+		if l.current > l.max {
+			l.current = l.max
+		}
+	} else if batchSize < l.current/4 {
+		l.current /= 2
+		if l.current < minMsgBatchSize {
+			l.current = minMsgBatchSize
+		}
+	}
+	if l.current != old {
+		log.WithFields(log.Fields{
+			"channel":  l.channel,
+			"oldLimit": old,
+			"newLimit": l.current,
+			"backlog":  backlog,
+		}).Debug("Adjusted message batch limit.")
+		l.gaugeLimit.Set(float64(l.current))
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Adaptive message batch limit", func() {
+	var l *msgBatchLimit
+
+	BeforeEach(func() {
+		l = newMsgBatchLimit("test", 500)
+	})
+
+	It("should start at the minimum", func() {
+		Expect(l.Limit()).To(Equal(minMsgBatchSize))
+	})
+
+	It("should grow, up to the maximum, while a backlog persists", func() {
+		l.OnBatchDone(l.Limit()+1, 10)
+		Expect(l.Limit()).To(Equal(2 * minMsgBatchSize))
+		for i := 0; i < 5; i++ {
+			l.OnBatchDone(l.Limit()+1, 10)
+		}
+		Expect(l.Limit()).To(Equal(500))
+	})
+
+	It("should not grow if the channel was drained", func() {
+		l.OnBatchDone(l.Limit()+1, 0)
+		Expect(l.Limit()).To(Equal(minMsgBatchSize))
+	})
+
+	It("should shrink, down to the minimum, when batches are small", func() {
+		for i := 0; i < 3; i++ {
+			l.OnBatchDone(l.Limit()+1, 10)
+		}
+		Expect(l.Limit()).To(Equal(500))
+		l.OnBatchDone(1, 0)
+		Expect(l.Limit()).To(Equal(250))
+		for i := 0; i < 5; i++ {
+			l.OnBatchDone(1, 0)
+		}
+		Expect(l.Limit()).To(Equal(minMsgBatchSize))
+	})
+
+	It("should keep its limit for medium-sized batches", func() {
+		l.OnBatchDone(l.Limit()+1, 10)
+		l.OnBatchDone(100, 0)
+		Expect(l.Limit()).To(Equal(2 * minMsgBatchSize))
+	})
+
+	It("should not allow a maximum below the minimum", func() {
+		l = newMsgBatchLimit("test2", 10)
+		l.OnBatchDone(l.Limit()+1, 10)
+		Expect(l.Limit()).To(Equal(minMsgBatchSize))
+	})
+})
@@ -0,0 +1,156 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/ifacemonitor"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/libcalico-go/lib/set"
+)
+
+const (
+	// defaultNeighGCThresh3 is the kernel's default hard limit on the size of the neighbor
+	// table; we never size the table below the kernel's defaults.
+	defaultNeighGCThresh3 = 1024
+	// neighEntriesPerPeer is the number of neighbor entries that we allow for each local workload
+	// and each other host.  That covers more than one address per peer (such as IPv6 global and
+	// link-local addresses) with some headroom.
+	neighEntriesPerPeer = 4
+)
+
+// neighGCThresholds calculates the neighbor table garbage collection thresholds for the given
+// number of peers.  gc_thresh3 is the hard limit on the number of entries, gc_thresh2 the soft
+// limit and, below gc_thresh1, the garbage collector doesn't run at all.  For stability, the hard
+// limit is rounded up to a power of two; the other thresholds keep the kernel's default ratios.
+func neighGCThresholds(numPeers int) (thresh1, thresh2, thresh3 int) {
+	thresh3 = defaultNeighGCThresh3
+	for thresh3 < numPeers*neighEntriesPerPeer {
+		thresh3 *= 2
+	}
+	return thresh3 / 8, thresh3 / 2, thresh3
+}
+
+// The neighbor GC manager sizes the kernel's neighbor (ARP/NDP) table so that it doesn't
+// overflow on dense nodes.  When the table is full, the kernel drops packets to new neighbors,
+// which shows up as intermittent connectivity loss to workloads.  The manager sets the garbage
+// collection thresholds from the number of local workloads and other hosts (each of which needs
+// a neighbor entry), adjusting them as those numbers change.  Optionally, it also sets the time
+// after which unused entries become eligible for garbage collection on each workload interface.
+type neighGCManager struct {
+	ipVersion       uint8
+	wlIfacePrefixes []string
+	staleTime       time.Duration
+	writeProcSys    procSysWriter
+
+	workloads         set.Set
+	hosts             set.Set
+	programmedThresh3 int
+	ifacesToConfigure set.Set
+}
+
+func newNeighGCManager(ipVersion uint8, wlIfacePrefixes []string, staleTime time.Duration) *neighGCManager {
+	return newNeighGCManagerWithShims(ipVersion, wlIfacePrefixes, staleTime, writeProcSys)
+}
+
+func newNeighGCManagerWithShims(
+	ipVersion uint8,
+	wlIfacePrefixes []string,
+	staleTime time.Duration,
+	procSysWriter procSysWriter,
+) *neighGCManager {
+	return &neighGCManager{
+		ipVersion:         ipVersion,
+		wlIfacePrefixes:   wlIfacePrefixes,
+		staleTime:         staleTime,
+		writeProcSys:      procSysWriter,
+		workloads:         set.New(),
+		hosts:             set.New(),
+		ifacesToConfigure: set.New(),
+	}
+}
+
+func (m *neighGCManager) OnUpdate(protoBufMsg interface{}) {
+	switch msg := protoBufMsg.(type) {
+	case *proto.WorkloadEndpointUpdate:
+		m.workloads.Add(*msg.Id)
+	case *proto.WorkloadEndpointRemove:
+		m.workloads.Discard(*msg.Id)
+	case *proto.HostMetadataUpdate:
+		m.hosts.Add(msg.Hostname)
+	case *proto.HostMetadataRemove:
+		m.hosts.Discard(msg.Hostname)
+	case *ifaceUpdate:
+		if m.staleTime <= 0 || !m.isWorkloadIface(msg.Name) {
+			return
+		}
+		if msg.State == ifacemonitor.StateUp {
+			m.ifacesToConfigure.Add(msg.Name)
+		} else {
+			m.ifacesToConfigure.Discard(msg.Name)
+		}
+	}
+}
+
+func (m *neighGCManager) isWorkloadIface(name string) bool {
+	for _, prefix := range m.wlIfacePrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *neighGCManager) CompleteDeferredWork() error {
+	numPeers := m.workloads.Len() + m.hosts.Len()
+	thresh1, thresh2, thresh3 := neighGCThresholds(numPeers)
+	if thresh3 != m.programmedThresh3 {
+		log.WithFields(log.Fields{
+			"ipVersion":  m.ipVersion,
+			"numPeers":   numPeers,
+			"gcThresh1":  thresh1,
+			"gcThresh2":  thresh2,
+			"gcThresh3":  thresh3,
+			"oldThresh3": m.programmedThresh3,
+		}).Info("Resizing neighbor table.")
+		for _, t := range []struct {
+			name  string
+			value int
+		}{{"gc_thresh3", thresh3}, {"gc_thresh2", thresh2}, {"gc_thresh1", thresh1}} {
+			path := fmt.Sprintf("/proc/sys/net/ipv%d/neigh/default/%s", m.ipVersion, t.name)
+			if err := m.writeProcSys(path, fmt.Sprint(t.value)); err != nil {
+				log.WithError(err).WithField("path", path).Warn("Failed to set neighbor GC threshold.")
+				return err
+			}
+		}
+		m.programmedThresh3 = thresh3
+	}
+
+	m.ifacesToConfigure.Iter(func(item interface{}) error {
+		name := item.(string)
+		path := fmt.Sprintf("/proc/sys/net/ipv%d/neigh/%s/gc_stale_time", m.ipVersion, name)
+		if err := m.writeProcSys(path, fmt.Sprint(int(m.staleTime.Seconds()))); err != nil {
+			// Most likely, the interface has gone; if it comes back, we'll get another update.
+			log.WithError(err).WithField("path", path).Info("Failed to set neighbor stale time.")
+		}
+		return set.RemoveItem
+	})
+	return nil
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/ifacemonitor"
+	"github.com/projectcalico/felix/proto"
+)
+
+var _ = DescribeTable("Neighbor GC thresholds",
+	func(numPeers, thresh1, thresh2, thresh3 int) {
+		t1, t2, t3 := neighGCThresholds(numPeers)
+		Expect([]int{t1, t2, t3}).To(Equal([]int{thresh1, thresh2, thresh3}))
+	},
+	Entry("no peers: kernel defaults", 0, 128, 512, 1024),
+	Entry("few peers: kernel defaults", 256, 128, 512, 1024),
+	Entry("just over the default", 257, 256, 1024, 2048),
+	Entry("dense node", 3000, 2048, 8192, 16384),
+)
+
+var _ = Describe("Neighbor GC manager", func() {
+	var (
+		mgr     *neighGCManager
+		written map[string]string
+		failAll bool
+	)
+
+	BeforeEach(func() {
+		written = map[string]string{}
+		failAll = false
+		mgr = newNeighGCManagerWithShims(4, []string{"cali"}, 30*time.Second, func(path, value string) error {
+			if failAll {
+				return errors.New("failed")
+			}
+			written[path] = value
+			return nil
+		})
+	})
+
+	addWorkloads := func(n int) {
+		for i := 0; i < n; i++ {
+			mgr.OnUpdate(&proto.WorkloadEndpointUpdate{
+				Id:       &proto.WorkloadEndpointID{WorkloadId: fmt.Sprintf("wl-%d", i)},
+				Endpoint: &proto.WorkloadEndpoint{},
+			})
+		}
+	}
+
+	It("should program the default thresholds at start of day", func() {
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(written).To(Equal(map[string]string{
+			"/proc/sys/net/ipv4/neigh/default/gc_thresh1": "128",
+			"/proc/sys/net/ipv4/neigh/default/gc_thresh2": "512",
+			"/proc/sys/net/ipv4/neigh/default/gc_thresh3": "1024",
+		}))
+	})
+
+	It("should grow the table as workloads and hosts are added, and shrink it again", func() {
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		addWorkloads(200)
+		for i := 0; i < 100; i++ {
+			mgr.OnUpdate(&proto.HostMetadataUpdate{Hostname: fmt.Sprintf("host-%d", i)})
+		}
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(written["/proc/sys/net/ipv4/neigh/default/gc_thresh3"]).To(Equal("2048"))
+		Expect(written["/proc/sys/net/ipv4/neigh/default/gc_thresh1"]).To(Equal("256"))
+
+		for i := 0; i < 100; i++ {
+			mgr.OnUpdate(&proto.HostMetadataRemove{Hostname: fmt.Sprintf("host-%d", i)})
+		}
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(written["/proc/sys/net/ipv4/neigh/default/gc_thresh3"]).To(Equal("1024"))
+	})
+
+	It("should only write the thresholds when they change", func() {
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		written = map[string]string{}
+		addWorkloads(10)
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(written).To(BeEmpty())
+	})
+
+	It("should return an error and retry if the thresholds can't be written", func() {
+		failAll = true
+		Expect(mgr.CompleteDeferredWork()).NotTo(Succeed())
+		failAll = false
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(written).To(HaveLen(3))
+	})
+
+	It("should set the stale time on workload interfaces when they come up", func() {
+		mgr.OnUpdate(&ifaceUpdate{Name: "cali1234", State: ifacemonitor.StateUp})
+		mgr.OnUpdate(&ifaceUpdate{Name: "eth0", State: ifacemonitor.StateUp})
+		mgr.OnUpdate(&ifaceUpdate{Name: "cali5678", State: ifacemonitor.StateUp})
+		mgr.OnUpdate(&ifaceUpdate{Name: "cali5678", State: ifacemonitor.StateDown})
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(written).To(HaveKeyWithValue("/proc/sys/net/ipv4/neigh/cali1234/gc_stale_time", "30"))
+		Expect(written).NotTo(HaveKey("/proc/sys/net/ipv4/neigh/eth0/gc_stale_time"))
+		Expect(written).NotTo(HaveKey("/proc/sys/net/ipv4/neigh/cali5678/gc_stale_time"))
+	})
+})
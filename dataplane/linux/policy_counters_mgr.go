@@ -0,0 +1,155 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/projectcalico/felix/iptables"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/rules"
+)
+
+var (
+	policyCounterLabels = []string{"ip_version", "tier", "policy", "direction", "rule"}
+
+	countVecPolicyPackets = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "felix_policy_packets_total",
+		Help: "Number of packets that matched each rule of each active policy.",
+	}, policyCounterLabels)
+	countVecPolicyBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "felix_policy_bytes_total",
+		Help: "Number of bytes that matched each rule of each active policy.",
+	}, policyCounterLabels)
+)
+
+func init() {
+	prometheus.MustRegister(countVecPolicyPackets)
+	prometheus.MustRegister(countVecPolicyBytes)
+}
+
+type policyChain struct {
+	id        proto.PolicyID
+	direction string
+}
+
+// The policy counters manager exports the packet and byte counts of each policy rule via
+// Prometheus.  The rule renderer tags each policy rule's iptables rules with the rule's index
+// (see rules.PolicyRuleCounterComment()) and the filter tables pass the counts of the tagged
+// rules to OnRuleCounters() after they've been applied.  The manager tracks the active policies
+// so that it can map the (possibly hashed) chain names back to the policies.
+type policyCountersManager struct {
+	ruleRenderer policyRenderer
+
+	// lock protects our state, since the IPv4 and IPv6 tables report their counts concurrently.
+	lock sync.Mutex
+
+	policyChains map[string]policyChain
+	// lastCounts holds the counts that we last read, by IP version, chain name and comment, so
+	// that we can calculate the increases.
+	lastCounts map[uint8]map[string]map[string]iptables.RuleCounts
+}
+
+func newPolicyCountersManager(ruleRenderer policyRenderer) *policyCountersManager {
+	return &policyCountersManager{
+		ruleRenderer: ruleRenderer,
+		policyChains: map[string]policyChain{},
+		lastCounts:   map[uint8]map[string]map[string]iptables.RuleCounts{},
+	}
+}
+
+func (m *policyCountersManager) OnUpdate(protoBufMsg interface{}) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	switch msg := protoBufMsg.(type) {
+	case *proto.ActivePolicyUpdate:
+		m.policyChains[m.ruleRenderer.PolicyChainName(rules.PolicyInboundPfx, msg.Id)] = policyChain{*msg.Id, "inbound"}
+		m.policyChains[m.ruleRenderer.PolicyChainName(rules.PolicyOutboundPfx, msg.Id)] = policyChain{*msg.Id, "outbound"}
+	case *proto.ActivePolicyRemove:
+		m.removeChain(m.ruleRenderer.PolicyChainName(rules.PolicyInboundPfx, msg.Id))
+		m.removeChain(m.ruleRenderer.PolicyChainName(rules.PolicyOutboundPfx, msg.Id))
+	}
+}
+
+func (m *policyCountersManager) removeChain(chainName string) {
+	pc, ok := m.policyChains[chainName]
+	if !ok {
+		return
+	}
+	for ipVersion, chains := range m.lastCounts {
+		for comment := range chains[chainName] {
+			ruleIdx, _ := rules.ParsePolicyRuleCounterComment(comment)
+			labels := pc.labelValues(ipVersion, ruleIdx)
+			countVecPolicyPackets.DeleteLabelValues(labels...)
+			countVecPolicyBytes.DeleteLabelValues(labels...)
+		}
+		delete(chains, chainName)
+	}
+	delete(m.policyChains, chainName)
+}
+
+func (m *policyCountersManager) CompleteDeferredWork() error {
+	return nil
+}
+
+// OnRuleCounters returns the callback for the filter table of the given IP version to report its
+// rule counts to.
+func (m *policyCountersManager) OnRuleCounters(ipVersion uint8) func(map[string]map[string]iptables.RuleCounts) {
+	return func(counts map[string]map[string]iptables.RuleCounts) {
+		m.onRuleCounters(ipVersion, counts)
+	}
+}
+
+func (m *policyCountersManager) onRuleCounters(ipVersion uint8, counts map[string]map[string]iptables.RuleCounts) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	lastCounts := m.lastCounts[ipVersion]
+	newLastCounts := map[string]map[string]iptables.RuleCounts{}
+	for chainName, chainCounts := range counts {
+		pc, ok := m.policyChains[chainName]
+		if !ok {
+			continue
+		}
+		newChainCounts := map[string]iptables.RuleCounts{}
+		for comment, c := range chainCounts {
+			ruleIdx, ok := rules.ParsePolicyRuleCounterComment(comment)
+			if !ok {
+				continue
+			}
+			newChainCounts[comment] = c
+			last := lastCounts[chainName][comment]
+			packets, bytes := c.Packets-last.Packets, c.Bytes-last.Bytes
+			if c.Packets < last.Packets || c.Bytes < last.Bytes {
+				// The counters were reset by a rule update.
+				packets, bytes = c.Packets, c.Bytes
+			}
+			labels := pc.labelValues(ipVersion, ruleIdx)
+			countVecPolicyPackets.WithLabelValues(labels...).Add(float64(packets))
+			countVecPolicyBytes.WithLabelValues(labels...).Add(float64(bytes))
+		}
+		newLastCounts[chainName] = newChainCounts
+	}
+	m.lastCounts[ipVersion] = newLastCounts
+}
+
+func (pc policyChain) labelValues(ipVersion uint8, ruleIdx int) []string {
+	return []string{fmt.Sprint(ipVersion), pc.id.Tier, pc.id.Name, pc.direction, strconv.Itoa(ruleIdx)}
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/projectcalico/felix/iptables"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/rules"
+)
+
+var _ = Describe("Policy counters manager", func() {
+	var mgr *policyCountersManager
+
+	policyID := proto.PolicyID{Tier: "default", Name: "counted-policy"}
+	inboundChain := rules.PolicyChainName(rules.PolicyInboundPfx, &policyID)
+	outboundChain := rules.PolicyChainName(rules.PolicyOutboundPfx, &policyID)
+
+	packets := func(ipVersion, direction, rule string) float64 {
+		return testutil.ToFloat64(countVecPolicyPackets.WithLabelValues(
+			ipVersion, "default", "counted-policy", direction, rule))
+	}
+	bytes := func(ipVersion, direction, rule string) float64 {
+		return testutil.ToFloat64(countVecPolicyBytes.WithLabelValues(
+			ipVersion, "default", "counted-policy", direction, rule))
+	}
+
+	BeforeEach(func() {
+		countVecPolicyPackets.Reset()
+		countVecPolicyBytes.Reset()
+		mgr = newPolicyCountersManager(newMockPolRenderer())
+		mgr.OnUpdate(&proto.ActivePolicyUpdate{Id: &policyID, Policy: &proto.Policy{}})
+	})
+
+	It("should export the increase in each rule's counts", func() {
+		report := mgr.OnRuleCounters(4)
+		report(map[string]map[string]iptables.RuleCounts{
+			inboundChain: {
+				rules.PolicyRuleCounterComment(0): {Packets: 10, Bytes: 1000},
+				"some other comment":              {Packets: 99, Bytes: 9900},
+			},
+			outboundChain: {
+				rules.PolicyRuleCounterComment(1): {Packets: 3, Bytes: 300},
+			},
+			"cali-not-a-policy": {
+				rules.PolicyRuleCounterComment(0): {Packets: 1, Bytes: 100},
+			},
+		})
+		Expect(packets("4", "inbound", "0")).To(Equal(10.0))
+		Expect(bytes("4", "inbound", "0")).To(Equal(1000.0))
+		Expect(packets("4", "outbound", "1")).To(Equal(3.0))
+
+		report(map[string]map[string]iptables.RuleCounts{
+			inboundChain: {
+				rules.PolicyRuleCounterComment(0): {Packets: 15, Bytes: 1500},
+			},
+			outboundChain: {
+				// Reset by a rule update.
+				rules.PolicyRuleCounterComment(1): {Packets: 2, Bytes: 200},
+			},
+		})
+		Expect(packets("4", "inbound", "0")).To(Equal(15.0))
+		Expect(bytes("4", "inbound", "0")).To(Equal(1500.0))
+		Expect(packets("4", "outbound", "1")).To(Equal(5.0))
+		Expect(bytes("4", "outbound", "1")).To(Equal(500.0))
+	})
+
+	It("should track the IP versions separately", func() {
+		mgr.OnRuleCounters(4)(map[string]map[string]iptables.RuleCounts{
+			inboundChain: {rules.PolicyRuleCounterComment(0): {Packets: 10, Bytes: 1000}},
+		})
+		mgr.OnRuleCounters(6)(map[string]map[string]iptables.RuleCounts{
+			inboundChain: {rules.PolicyRuleCounterComment(0): {Packets: 4, Bytes: 400}},
+		})
+		Expect(packets("4", "inbound", "0")).To(Equal(10.0))
+		Expect(packets("6", "inbound", "0")).To(Equal(4.0))
+	})
+
+	It("should remove the policy's metrics when it's removed", func() {
+		mgr.OnRuleCounters(4)(map[string]map[string]iptables.RuleCounts{
+			inboundChain: {rules.PolicyRuleCounterComment(0): {Packets: 10, Bytes: 1000}},
+		})
+		Expect(testutil.CollectAndCount(countVecPolicyPackets)).To(Equal(1))
+
+		mgr.OnUpdate(&proto.ActivePolicyRemove{Id: &policyID})
+		Expect(testutil.CollectAndCount(countVecPolicyPackets)).To(Equal(0))
+		Expect(testutil.CollectAndCount(countVecPolicyBytes)).To(Equal(0))
+
+		// Counts for chains that no longer belong to a policy are ignored.
+		mgr.OnRuleCounters(4)(map[string]map[string]iptables.RuleCounts{
+			inboundChain: {rules.PolicyRuleCounterComment(0): {Packets: 12, Bytes: 1200}},
+		})
+		Expect(testutil.CollectAndCount(countVecPolicyPackets)).To(Equal(0))
+	})
+})
@@ -23,7 +23,10 @@ import (
 )
 
 // policyManager simply renders policy/profile updates into iptables.Chain objects and sends
-// them to the dataplane layer.
+// them to the dataplane layer.  Policies with activation windows are rendered by the
+// policyScheduleManager instead.  Since a policy can move between the two managers, the policy
+// chains are shared: each manager owns a fragment of them and the tables remove a chain once
+// neither manager has a fragment in it.
 type policyManager struct {
 	rawTable     iptablesTable
 	mangleTable  iptablesTable
@@ -32,9 +35,14 @@ type policyManager struct {
 	ipVersion    uint8
 }
 
+// policyChainOwner is the owner of the policyManager's fragments of the policy chains.
+const policyChainOwner = "policy"
+
 type policyRenderer interface {
 	PolicyToIptablesChains(policyID *proto.PolicyID, policy *proto.Policy, ipVersion uint8) []*iptables.Chain
 	ProfileToIptablesChains(profileID *proto.ProfileID, policy *proto.Profile, ipVersion uint8) (inbound, outbound *iptables.Chain)
+	PolicyChainName(prefix rules.PolicyChainNamePrefix, polID *proto.PolicyID) string
+	ProfileChainName(prefix rules.ProfileChainNamePrefix, profID *proto.ProfileID) string
 }
 
 func newPolicyManager(rawTable, mangleTable, filterTable iptablesTable, ruleRenderer policyRenderer, ipVersion uint8) *policyManager {
@@ -50,25 +58,21 @@ func newPolicyManager(rawTable, mangleTable, filterTable iptablesTable, ruleRend
 func (m *policyManager) OnUpdate(msg interface{}) {
 	switch msg := msg.(type) {
 	case *proto.ActivePolicyUpdate:
+		if len(msg.Policy.ActivationWindows) > 0 {
+			log.WithField("id", msg.Id).Debug("Policy has activation windows, leaving it to the schedule manager")
+			removePolicyChainFragments(m.ruleRenderer, msg.Id, policyChainOwner, m.rawTable, m.mangleTable, m.filterTable)
+			return
+		}
 		log.WithField("id", msg.Id).Debug("Updating policy chains")
 		chains := m.ruleRenderer.PolicyToIptablesChains(msg.Id, msg.Policy, m.ipVersion)
 		// We can't easily tell whether the policy is in use in a particular table, and, if the policy
 		// type gets changed it may move between tables.  Hence, we put the policy into all tables.
 		// The iptables layer will avoid programming it if it is not actually used.
-		m.rawTable.UpdateChains(chains)
-		m.mangleTable.UpdateChains(chains)
-		m.filterTable.UpdateChains(chains)
+		updatePolicyChainFragments(chains, policyChainOwner, m.rawTable, m.mangleTable, m.filterTable)
 	case *proto.ActivePolicyRemove:
 		log.WithField("id", msg.Id).Debug("Removing policy chains")
-		inName := rules.PolicyChainName(rules.PolicyInboundPfx, msg.Id)
-		outName := rules.PolicyChainName(rules.PolicyOutboundPfx, msg.Id)
 		// As above, we need to clean up in all the tables.
-		m.filterTable.RemoveChainByName(inName)
-		m.filterTable.RemoveChainByName(outName)
-		m.mangleTable.RemoveChainByName(inName)
-		m.mangleTable.RemoveChainByName(outName)
-		m.rawTable.RemoveChainByName(inName)
-		m.rawTable.RemoveChainByName(outName)
+		removePolicyChainFragments(m.ruleRenderer, msg.Id, policyChainOwner, m.rawTable, m.mangleTable, m.filterTable)
 	case *proto.ActiveProfileUpdate:
 		log.WithField("id", msg.Id).Debug("Updating profile chains")
 		inbound, outbound := m.ruleRenderer.ProfileToIptablesChains(msg.Id, msg.Profile, m.ipVersion)
@@ -76,8 +80,8 @@ func (m *policyManager) OnUpdate(msg interface{}) {
 		m.mangleTable.UpdateChains([]*iptables.Chain{outbound})
 	case *proto.ActiveProfileRemove:
 		log.WithField("id", msg.Id).Debug("Removing profile chains")
-		inName := rules.ProfileChainName(rules.ProfileInboundPfx, msg.Id)
-		outName := rules.ProfileChainName(rules.ProfileOutboundPfx, msg.Id)
+		inName := m.ruleRenderer.ProfileChainName(rules.ProfileInboundPfx, msg.Id)
+		outName := m.ruleRenderer.ProfileChainName(rules.ProfileOutboundPfx, msg.Id)
 		m.filterTable.RemoveChainByName(inName)
 		m.filterTable.RemoveChainByName(outName)
 		m.mangleTable.RemoveChainByName(outName)
@@ -88,3 +92,23 @@ func (m *policyManager) CompleteDeferredWork() error {
 	// Nothing to do, we don't defer any work.
 	return nil
 }
+
+// updatePolicyChainFragments sets the given owner's fragments of the policy chains in each table.
+func updatePolicyChainFragments(chains []*iptables.Chain, owner string, tables ...iptablesTable) {
+	for _, t := range tables {
+		for _, chain := range chains {
+			t.UpdateChainFragment(chain.Name, owner, 0, chain.Rules)
+		}
+	}
+}
+
+// removePolicyChainFragments removes the given owner's fragments of the policy's chains from each
+// table.
+func removePolicyChainFragments(renderer policyRenderer, id *proto.PolicyID, owner string, tables ...iptablesTable) {
+	inName := renderer.PolicyChainName(rules.PolicyInboundPfx, id)
+	outName := renderer.PolicyChainName(rules.PolicyOutboundPfx, id)
+	for _, t := range tables {
+		t.RemoveChainFragment(inName, owner)
+		t.RemoveChainFragment(outName, owner)
+	}
+}
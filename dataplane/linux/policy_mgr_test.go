@@ -245,6 +245,8 @@ var _ = Describe("Policy manager", func() {
 })
 
 type mockPolRenderer struct {
+	// Config provides the chain names, with the default prefixes.
+	rules.Config
 }
 
 func (r *mockPolRenderer) PolicyToIptablesChains(policyID *proto.PolicyID, policy *proto.Policy, ipVersion uint8) []*iptables.Chain {
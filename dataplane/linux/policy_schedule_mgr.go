@@ -0,0 +1,153 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/rules"
+)
+
+// policyScheduleManager renders the policies that have activation windows.  (The policyManager
+// renders all the other policies.)  Inside one of its windows, a policy's chains get its rules;
+// outside them, the chains are rendered as if the policy had no rules so that packets continue to
+// the next policy.  The manager asks to be rescheduled each minute, which is the granularity of
+// the windows, so that it can swap the chains over when a window opens or closes.  It shares the
+// policy chains with the policyManager; see policyChainOwner.
+type policyScheduleManager struct {
+	rawTable     iptablesTable
+	mangleTable  iptablesTable
+	filterTable  iptablesTable
+	ruleRenderer policyRenderer
+	ipVersion    uint8
+
+	policies map[proto.PolicyID]*scheduledPolicy
+
+	// Shim for testing.
+	now func() time.Time
+}
+
+// policyScheduleChainOwner is the owner of the policyScheduleManager's fragments of the policy
+// chains.
+const policyScheduleChainOwner = "policy-schedule"
+
+type scheduledPolicy struct {
+	policy  *proto.Policy
+	windows []*rules.ActivationWindow
+	// rendered is false if the policy's chains need to be rendered, whether or not it is active.
+	rendered bool
+	active   bool
+}
+
+func newPolicyScheduleManager(rawTable, mangleTable, filterTable iptablesTable, ruleRenderer policyRenderer,
+	ipVersion uint8) *policyScheduleManager {
+	return newPolicyScheduleManagerWithShims(rawTable, mangleTable, filterTable, ruleRenderer, ipVersion, time.Now)
+}
+
+func newPolicyScheduleManagerWithShims(rawTable, mangleTable, filterTable iptablesTable, ruleRenderer policyRenderer,
+	ipVersion uint8, now func() time.Time) *policyScheduleManager {
+	return &policyScheduleManager{
+		rawTable:     rawTable,
+		mangleTable:  mangleTable,
+		filterTable:  filterTable,
+		ruleRenderer: ruleRenderer,
+		ipVersion:    ipVersion,
+		policies:     map[proto.PolicyID]*scheduledPolicy{},
+		now:          now,
+	}
+}
+
+func (m *policyScheduleManager) OnUpdate(msg interface{}) {
+	switch msg := msg.(type) {
+	case *proto.ActivePolicyUpdate:
+		if len(msg.Policy.ActivationWindows) == 0 {
+			// Not (or no longer) scheduled, the policyManager takes care of it.
+			m.removePolicy(msg.Id)
+			return
+		}
+		var windows []*rules.ActivationWindow
+		for _, s := range msg.Policy.ActivationWindows {
+			w, err := rules.ParseActivationWindow(s)
+			if err != nil {
+				log.WithError(err).WithField("id", msg.Id).Warn(
+					"Ignoring invalid activation window on policy.")
+				continue
+			}
+			windows = append(windows, w)
+		}
+		if len(windows) == 0 {
+			log.WithField("id", msg.Id).Warn(
+				"Policy has no valid activation windows, it will never be active.")
+		}
+		m.policies[*msg.Id] = &scheduledPolicy{
+			policy:  msg.Policy,
+			windows: windows,
+		}
+	case *proto.ActivePolicyRemove:
+		m.removePolicy(msg.Id)
+	}
+}
+
+func (m *policyScheduleManager) removePolicy(id *proto.PolicyID) {
+	if _, ok := m.policies[*id]; !ok {
+		return
+	}
+	removePolicyChainFragments(m.ruleRenderer, id, policyScheduleChainOwner, m.rawTable, m.mangleTable, m.filterTable)
+	delete(m.policies, *id)
+}
+
+func (m *policyScheduleManager) CompleteDeferredWork() error {
+	now := m.now()
+	for id, p := range m.policies {
+		active := rules.ActivationWindowsContain(p.windows, now)
+		if p.rendered && active == p.active {
+			continue
+		}
+		logCxt := log.WithFields(log.Fields{"id": id, "active": active})
+		if p.rendered {
+			logCxt.Info("Policy activation window opened or closed.")
+		} else {
+			logCxt.Debug("Rendering scheduled policy.")
+		}
+		policy := p.policy
+		if !active {
+			policy = &proto.Policy{
+				Namespace: policy.Namespace,
+				Untracked: policy.Untracked,
+				PreDnat:   policy.PreDnat,
+			}
+		}
+		id := id
+		chains := m.ruleRenderer.PolicyToIptablesChains(&id, policy, m.ipVersion)
+		// As for the policyManager, we put the policy into all tables.
+		updatePolicyChainFragments(chains, policyScheduleChainOwner, m.rawTable, m.mangleTable, m.filterTable)
+		p.rendered = true
+		p.active = active
+	}
+	return nil
+}
+
+// RescheduleAfter asks for another CompleteDeferredWork call at the start of the next minute, when
+// an activation window may open or close.
+func (m *policyScheduleManager) RescheduleAfter() time.Duration {
+	if len(m.policies) == 0 {
+		return 0
+	}
+	now := m.now()
+	return now.Truncate(time.Minute).Add(time.Minute).Sub(now)
+}
@@ -0,0 +1,151 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/iptables"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/rules"
+)
+
+var _ = Describe("Policy schedule manager", func() {
+	var (
+		mgr          *policyScheduleManager
+		policyMgr    *policyManager
+		filterTable  *mockTable
+		ruleRenderer *countingPolRenderer
+		now          time.Time
+	)
+
+	polID := proto.PolicyID{Name: "maintenance", Tier: "default"}
+
+	BeforeEach(func() {
+		// Saturday 16th October 2021, 01:59:30.
+		now = time.Date(2021, time.October, 16, 1, 59, 30, 0, time.Local)
+		filterTable = newMockTable("filter")
+		ruleRenderer = &countingPolRenderer{}
+		mgr = newPolicyScheduleManagerWithShims(newMockTable("raw"), newMockTable("mangle"), filterTable,
+			ruleRenderer, 4, func() time.Time { return now })
+		policyMgr = newPolicyManager(newMockTable("raw"), newMockTable("mangle"), newMockTable("filter"),
+			ruleRenderer, 4)
+	})
+
+	sendUpdate := func(windows ...string) {
+		update := &proto.ActivePolicyUpdate{
+			Id: &polID,
+			Policy: &proto.Policy{
+				InboundRules:      []*proto.Rule{{Action: "allow"}},
+				ActivationWindows: windows,
+			},
+		}
+		mgr.OnUpdate(update)
+		policyMgr.OnUpdate(update)
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+	}
+
+	inboundRules := func() []iptables.Rule {
+		return filterTable.currentChains[rules.PolicyChainName(rules.PolicyInboundPfx, &polID)].Rules
+	}
+
+	It("should leave unscheduled policies to the policy manager", func() {
+		sendUpdate()
+		Expect(ruleRenderer.numRenders).To(Equal(1))
+		Expect(filterTable.currentChains).To(BeEmpty())
+		Expect(mgr.RescheduleAfter()).To(BeZero())
+	})
+
+	It("should render a policy without its rules outside its windows", func() {
+		sendUpdate("0 2 * * 6 4h")
+		Expect(ruleRenderer.numRenders).To(Equal(1))
+		Expect(inboundRules()).To(BeEmpty())
+		Expect(mgr.RescheduleAfter()).To(Equal(30 * time.Second))
+	})
+
+	It("should swap the rules in and out as the window opens and closes", func() {
+		sendUpdate("0 2 * * 6 4h")
+
+		now = now.Add(time.Minute)
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(inboundRules()).To(HaveLen(1))
+
+		now = now.Add(time.Hour)
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(ruleRenderer.numRenders).To(Equal(2), "Policy shouldn't be re-rendered while the window is open")
+
+		now = now.Add(3 * time.Hour)
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(inboundRules()).To(BeEmpty())
+	})
+
+	It("should never activate a policy with only invalid windows", func() {
+		sendUpdate("0 2 * * sat 4h")
+		now = now.Add(time.Minute)
+		Expect(mgr.CompleteDeferredWork()).To(Succeed())
+		Expect(ruleRenderer.numRenders).To(Equal(1))
+		Expect(inboundRules()).To(BeEmpty())
+	})
+
+	It("should forget a removed policy", func() {
+		sendUpdate("0 2 * * 6 4h")
+		mgr.OnUpdate(&proto.ActivePolicyRemove{Id: &polID})
+		Expect(mgr.RescheduleAfter()).To(BeZero())
+	})
+
+	It("should share the policy chains with the policy manager", func() {
+		// Both managers program the same tables, as in the real dataplane.
+		policyMgr = newPolicyManager(newMockTable("raw"), newMockTable("mangle"), filterTable, ruleRenderer, 4)
+		inName := rules.PolicyChainName(rules.PolicyInboundPfx, &polID)
+
+		sendUpdate("0 2 * * 6 4h")
+		Expect(filterTable.fragments[inName]).To(HaveKey(policyScheduleChainOwner))
+		Expect(inboundRules()).To(BeEmpty())
+
+		// Dropping the windows hands the chains to the policy manager, even though the schedule
+		// manager doesn't render anything.
+		sendUpdate()
+		Expect(filterTable.fragments[inName]).To(And(HaveLen(1), HaveKey(policyChainOwner)))
+		Expect(inboundRules()).To(HaveLen(1))
+
+		// And back again.
+		sendUpdate("0 2 * * 6 4h")
+		Expect(filterTable.fragments[inName]).To(And(HaveLen(1), HaveKey(policyScheduleChainOwner)))
+		Expect(inboundRules()).To(BeEmpty())
+
+		update := &proto.ActivePolicyRemove{Id: &polID}
+		mgr.OnUpdate(update)
+		policyMgr.OnUpdate(update)
+		Expect(filterTable.currentChains).To(BeEmpty())
+	})
+})
+
+// countingPolRenderer renders a rule for each of a policy's inbound rules and counts the renders.
+type countingPolRenderer struct {
+	mockPolRenderer
+	numRenders int
+}
+
+func (r *countingPolRenderer) PolicyToIptablesChains(policyID *proto.PolicyID, policy *proto.Policy, ipVersion uint8) []*iptables.Chain {
+	r.numRenders++
+	chains := r.mockPolRenderer.PolicyToIptablesChains(policyID, policy, ipVersion)
+	for _, rule := range policy.InboundRules {
+		chains[0].Rules = append(chains[0].Rules, iptables.Rule{Comment: []string{rule.Action}})
+	}
+	return chains
+}
@@ -31,6 +31,9 @@ import (
 // service CIDRs and IPs over BGP: then the default gateway will have a route back to this node, for
 // the service CIDR, and there could be a loop if we allowed non-existent service traffic to be
 // forwarded on from here.
+//
+// The chain is hooked into the filter FORWARD chain through an insert group that comes after other
+// tools' rules, such as kube-proxy's, but before the rules that Calico appends to the chain.
 type serviceLoopManager struct {
 	ipVersion uint8
 
@@ -41,8 +44,16 @@ type serviceLoopManager struct {
 	// Internal state.
 	activeFilterChains     []*iptables.Chain
 	pendingGlobalBGPConfig *proto.GlobalBGPConfigUpdate
+	forwardHooked          bool
 }
 
+const (
+	// serviceLoopInsertGroup is the name of our insert group in the filter FORWARD chain.  Its
+	// priority puts it before the rules that Calico appends to the chain.
+	serviceLoopInsertGroup         = "cali-cidr-block"
+	serviceLoopInsertGroupPriority = -1
+)
+
 func newServiceLoopManager(
 	filterTable iptablesTable,
 	ruleRenderer rules.RuleRenderer,
@@ -82,5 +93,12 @@ func (m *serviceLoopManager) CompleteDeferredWork() error {
 		}
 		m.pendingGlobalBGPConfig = nil
 	}
+	if !m.forwardHooked {
+		m.filterTable.UpdateInsertGroup("FORWARD", serviceLoopInsertGroup, iptables.InsertAtEnd,
+			serviceLoopInsertGroupPriority, []iptables.Rule{{
+				Action: iptables.JumpAction{Target: m.ruleRenderer.ChainName(rules.ChainCIDRBlock)},
+			}})
+		m.forwardHooked = true
+	}
 	return nil
 }
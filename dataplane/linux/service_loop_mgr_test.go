@@ -0,0 +1,84 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/ipsets"
+	"github.com/projectcalico/felix/iptables"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/rules"
+)
+
+var _ = Describe("Service loop manager", func() {
+	var (
+		serviceLoopMgr *serviceLoopManager
+		filterTable    *mockTable
+	)
+
+	BeforeEach(func() {
+		filterTable = newMockTable("filter")
+		ruleRenderer := rules.NewRenderer(rules.Config{
+			IPSetConfigV4: ipsets.NewIPVersionConfig(
+				ipsets.IPFamilyV4,
+				"cali",
+				nil,
+				nil,
+			),
+			IptablesMarkPass:      0x1,
+			IptablesMarkAccept:    0x2,
+			IptablesMarkScratch0:  0x4,
+			IptablesMarkScratch1:  0x8,
+			IptablesMarkEndpoint:  0x11110000,
+			ServiceLoopPrevention: "Drop",
+		})
+		serviceLoopMgr = newServiceLoopManager(filterTable, ruleRenderer, 4)
+	})
+
+	It("should hook the chain into FORWARD after other tools' rules", func() {
+		Expect(serviceLoopMgr.CompleteDeferredWork()).To(Succeed())
+		Expect(filterTable.InsertGroups["FORWARD"]).To(Equal(iptables.InsertGroups{
+			serviceLoopInsertGroup: {
+				Name:     serviceLoopInsertGroup,
+				Position: iptables.InsertAtEnd,
+				Priority: serviceLoopInsertGroupPriority,
+				Rules: []iptables.Rule{{
+					Action: iptables.JumpAction{Target: rules.ChainCIDRBlock},
+				}},
+			},
+		}))
+		filterTable.checkChains([][]*iptables.Chain{{{
+			Name:  rules.ChainCIDRBlock,
+			Rules: []iptables.Rule{},
+		}}})
+	})
+
+	It("should block the service CIDRs", func() {
+		serviceLoopMgr.OnUpdate(&proto.GlobalBGPConfigUpdate{
+			ServiceClusterCidrs:  []string{"10.96.0.0/12"},
+			ServiceExternalCidrs: []string{"fd00::/64"},
+		})
+		Expect(serviceLoopMgr.CompleteDeferredWork()).To(Succeed())
+		filterTable.checkChains([][]*iptables.Chain{{{
+			Name: rules.ChainCIDRBlock,
+			Rules: []iptables.Rule{{
+				Match:  iptables.Match().DestNet("10.96.0.0/12"),
+				Action: iptables.DropAction{},
+			}},
+		}}})
+	})
+})
@@ -0,0 +1,298 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+
+	bpfproxy "github.com/projectcalico/felix/bpf/proxy"
+	"github.com/projectcalico/felix/ip"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/routetable"
+	"github.com/projectcalico/libcalico-go/lib/set"
+)
+
+// The service route manager programs local routes, via a dummy device, for the service CIDRs that
+// are configured in the BGPConfiguration and for the individual service IPs that have at least one
+// endpoint on this host.  The routes are never used for forwarding (kube-proxy DNATs service traffic
+// before the routing decision); they exist so that the BGP daemon can export them, which makes
+// service advertisement work without kube-proxy binding the service IPs to kube-ipvs0.
+type serviceRouteManager struct {
+	ipVersion uint8
+
+	// Our dependencies.
+	routeTable routeTable
+	nlHandle   netlinkHandle
+
+	deviceName string
+
+	// Internal state.
+	serviceCIDRs    set.Set
+	localServiceIPs set.Set
+	routesDirty     bool
+}
+
+// localServiceIPsUpdate is sent to the main loop when the set of service IPs with local endpoints
+// changes.
+type localServiceIPsUpdate struct {
+	IPs []ip.Addr
+}
+
+func newServiceRouteManager(
+	rt routeTable,
+	deviceName string,
+	ipVersion uint8,
+) *serviceRouteManager {
+	nlHandle, _ := netlink.NewHandle()
+	return newServiceRouteManagerWithShims(rt, deviceName, ipVersion, nlHandle)
+}
+
+func newServiceRouteManagerWithShims(
+	rt routeTable,
+	deviceName string,
+	ipVersion uint8,
+	nlHandle netlinkHandle,
+) *serviceRouteManager {
+	return &serviceRouteManager{
+		ipVersion:       ipVersion,
+		routeTable:      rt,
+		nlHandle:        nlHandle,
+		deviceName:      deviceName,
+		serviceCIDRs:    set.New(),
+		localServiceIPs: set.New(),
+		routesDirty:     true,
+	}
+}
+
+func (m *serviceRouteManager) OnUpdate(protoBufMsg interface{}) {
+	switch msg := protoBufMsg.(type) {
+	case *proto.GlobalBGPConfigUpdate:
+		cidrs := set.New()
+		for _, s := range m.serviceCIDRStrings(msg) {
+			cidr, err := ip.CIDRFromString(s)
+			if err != nil {
+				log.WithError(err).WithField("cidr", s).Warn("Failed to parse service CIDR, ignoring")
+				continue
+			}
+			if cidr.Version() != m.ipVersion {
+				continue
+			}
+			cidrs.Add(cidr)
+		}
+		if !cidrs.Equals(m.serviceCIDRs) {
+			m.serviceCIDRs = cidrs
+			m.routesDirty = true
+		}
+	case *localServiceIPsUpdate:
+		ips := set.New()
+		for _, addr := range msg.IPs {
+			if addr.Version() != m.ipVersion {
+				continue
+			}
+			ips.Add(addr.AsCIDR())
+		}
+		if !ips.Equals(m.localServiceIPs) {
+			m.localServiceIPs = ips
+			m.routesDirty = true
+		}
+	}
+}
+
+func (m *serviceRouteManager) serviceCIDRStrings(msg *proto.GlobalBGPConfigUpdate) []string {
+	var cidrs []string
+	cidrs = append(cidrs, msg.GetServiceClusterCidrs()...)
+	cidrs = append(cidrs, msg.GetServiceExternalCidrs()...)
+	cidrs = append(cidrs, msg.GetServiceLoadbalancerCidrs()...)
+	return cidrs
+}
+
+func (m *serviceRouteManager) CompleteDeferredWork() error {
+	if !m.routesDirty {
+		return nil
+	}
+
+	var targets []routetable.Target
+	addTarget := func(item interface{}) error {
+		targets = append(targets, routetable.Target{CIDR: item.(ip.CIDR)})
+		return nil
+	}
+	m.serviceCIDRs.Iter(addTarget)
+	m.localServiceIPs.Iter(func(item interface{}) error {
+		if m.serviceCIDRs.Contains(item) {
+			// The service IP is also configured as a CIDR; avoid a duplicate route.
+			return nil
+		}
+		return addTarget(item)
+	})
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].CIDR.String() < targets[j].CIDR.String()
+	})
+
+	log.WithField("routes", targets).Debug("Service route manager sending route updates")
+	m.routeTable.SetRoutes(m.deviceName, targets)
+	m.routesDirty = false
+	return nil
+}
+
+func (m *serviceRouteManager) GetRouteTableSyncers() []routeTableSyncer {
+	return []routeTableSyncer{m.routeTable}
+}
+
+// KeepServiceRouteDeviceInSync is a goroutine that creates the dummy device that the service routes
+// point to, then periodically checks that it is still present and up.
+func (m *serviceRouteManager) KeepServiceRouteDeviceInSync(wait time.Duration) {
+	log.WithField("device", m.deviceName).Info("Service route device thread started.")
+	logNextSuccess := true
+	for {
+		if err := m.configureServiceRouteDevice(); err != nil {
+			log.WithError(err).Warn("Failed to configure service route device, retrying...")
+			logNextSuccess = true
+			time.Sleep(1 * time.Second)
+			continue
+		}
+		if logNextSuccess {
+			log.Info("Service route device configured")
+			logNextSuccess = false
+		}
+		time.Sleep(wait)
+	}
+}
+
+// configureServiceRouteDevice ensures the dummy device exists and is up.
+func (m *serviceRouteManager) configureServiceRouteDevice() error {
+	link, err := m.nlHandle.LinkByName(m.deviceName)
+	if err != nil {
+		log.WithError(err).Info("Failed to get service route device, assuming it isn't present")
+		dummy := &netlink.Dummy{
+			LinkAttrs: netlink.LinkAttrs{
+				Name: m.deviceName,
+			},
+		}
+		if err := m.nlHandle.LinkAdd(dummy); err == syscall.EEXIST {
+			log.Debug("Service route device already exists, likely created by someone else.")
+		} else if err != nil {
+			return err
+		}
+		link, err = m.nlHandle.LinkByName(m.deviceName)
+		if err != nil {
+			return fmt.Errorf("can't locate created service route device %v", m.deviceName)
+		}
+	}
+
+	if link.Type() != "dummy" {
+		return fmt.Errorf("%q exists but is of type %v, expected a dummy device", m.deviceName, link.Type())
+	}
+
+	if err := m.nlHandle.LinkSetUp(link); err != nil {
+		return fmt.Errorf("failed to set interface up: %s", err)
+	}
+	return nil
+}
+
+func cleanUpServiceRouteDevice(deviceName string) {
+	log.Debug("Checking if we need to clean up the service route device")
+	link, err := netlink.LinkByName(deviceName)
+	if err != nil {
+		if _, ok := err.(netlink.LinkNotFoundError); ok {
+			log.Debug("Service routes disabled and no service route device found")
+			return
+		}
+		log.WithError(err).Warn("Service routes disabled and failed to query service route device.  Ignoring.")
+		return
+	}
+	if link.Type() != "dummy" {
+		log.WithField("device", deviceName).Warn("Service routes disabled but device is not ours.  Ignoring.")
+		return
+	}
+	if err = netlink.LinkDel(link); err != nil {
+		log.WithError(err).Error("Service routes disabled and failed to delete service route device. Ignoring.")
+	}
+}
+
+// localServiceIPsSyncer is a bpfproxy.DPSyncer that, rather than programming service NAT, calculates
+// the set of service IPs that have endpoints on this host and passes them to a callback.
+type localServiceIPsSyncer struct {
+	onUpdate func([]ip.Addr)
+	lastIPs  set.Set
+}
+
+func newLocalServiceIPsSyncer(onUpdate func([]ip.Addr)) *localServiceIPsSyncer {
+	return &localServiceIPsSyncer{
+		onUpdate: onUpdate,
+	}
+}
+
+func (s *localServiceIPsSyncer) Apply(state bpfproxy.DPSyncerState) error {
+	ips := set.New()
+	for svc, svcInfo := range state.SvcMap {
+		hasLocal := false
+		for _, ep := range state.EpsMap[svc] {
+			if ep.GetIsLocal() && ep.IsReady() {
+				hasLocal = true
+				break
+			}
+		}
+		if !hasLocal {
+			continue
+		}
+		addIP := func(netIP net.IP) {
+			if netIP == nil || netIP.IsUnspecified() {
+				return
+			}
+			ips.Add(ip.FromNetIP(netIP))
+		}
+		addIP(svcInfo.ClusterIP())
+		for _, s := range svcInfo.ExternalIPStrings() {
+			addIP(net.ParseIP(s))
+		}
+		for _, s := range svcInfo.LoadBalancerIPStrings() {
+			addIP(net.ParseIP(s))
+		}
+	}
+
+	if s.lastIPs != nil && s.lastIPs.Equals(ips) {
+		return nil
+	}
+	s.lastIPs = ips
+
+	var addrs []ip.Addr
+	ips.Iter(func(item interface{}) error {
+		addrs = append(addrs, item.(ip.Addr))
+		return nil
+	})
+	s.onUpdate(addrs)
+	return nil
+}
+
+func (s *localServiceIPsSyncer) ConntrackScanStart() {}
+
+func (s *localServiceIPsSyncer) ConntrackScanEnd() {}
+
+func (s *localServiceIPsSyncer) ConntrackFrontendHasBackend(ip net.IP, port uint16, backendIP net.IP,
+	backendPort uint16, proto uint8) bool {
+	// We don't own any conntrack state.
+	return true
+}
+
+func (s *localServiceIPsSyncer) Stop() {}
+
+func (s *localServiceIPsSyncer) SetTriggerFn(_ func()) {}
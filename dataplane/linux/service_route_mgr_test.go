@@ -0,0 +1,122 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vishvananda/netlink"
+
+	"github.com/projectcalico/felix/ip"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/routetable"
+)
+
+type mockServiceRouteDataplane struct {
+	mockVXLANDataplane
+	link       netlink.Link
+	addedLinks []netlink.Link
+	upLinks    []string
+}
+
+func (m *mockServiceRouteDataplane) LinkByName(name string) (netlink.Link, error) {
+	if m.link == nil {
+		return nil, errors.New("not found")
+	}
+	return m.link, nil
+}
+
+func (m *mockServiceRouteDataplane) LinkAdd(l netlink.Link) error {
+	m.addedLinks = append(m.addedLinks, l)
+	m.link = &mockLink{attrs: *l.Attrs(), typ: l.Type()}
+	return nil
+}
+
+func (m *mockServiceRouteDataplane) LinkSetUp(l netlink.Link) error {
+	m.upLinks = append(m.upLinks, l.Attrs().Name)
+	return nil
+}
+
+var _ = Describe("ServiceRouteManager", func() {
+	var (
+		manager *serviceRouteManager
+		rt      *mockRouteTable
+		nl      *mockServiceRouteDataplane
+	)
+
+	BeforeEach(func() {
+		rt = &mockRouteTable{
+			currentRoutes:   map[string][]routetable.Target{},
+			currentL2Routes: map[string][]routetable.L2Target{},
+		}
+		nl = &mockServiceRouteDataplane{}
+		manager = newServiceRouteManagerWithShims(rt, "calico-svc", 4, nl)
+	})
+
+	It("should program no routes initially", func() {
+		Expect(manager.CompleteDeferredWork()).NotTo(HaveOccurred())
+		Expect(rt.currentRoutes["calico-svc"]).To(BeEmpty())
+	})
+
+	It("should program routes for service CIDRs of the right IP version", func() {
+		manager.OnUpdate(&proto.GlobalBGPConfigUpdate{
+			ServiceClusterCidrs:  []string{"10.96.0.0/12", "fd00:96::/112"},
+			ServiceExternalCidrs: []string{"192.168.100.0/24"},
+		})
+		Expect(manager.CompleteDeferredWork()).NotTo(HaveOccurred())
+		Expect(rt.currentRoutes["calico-svc"]).To(Equal([]routetable.Target{
+			{CIDR: ip.MustParseCIDROrIP("10.96.0.0/12")},
+			{CIDR: ip.MustParseCIDROrIP("192.168.100.0/24")},
+		}))
+	})
+
+	It("should program /32 routes for services with local endpoints", func() {
+		manager.OnUpdate(&proto.GlobalBGPConfigUpdate{
+			ServiceClusterCidrs: []string{"10.96.0.0/12"},
+		})
+		manager.OnUpdate(&localServiceIPsUpdate{IPs: []ip.Addr{
+			ip.FromString("10.96.0.10"),
+			ip.FromString("172.16.0.1"),
+		}})
+		Expect(manager.CompleteDeferredWork()).NotTo(HaveOccurred())
+		Expect(rt.currentRoutes["calico-svc"]).To(ConsistOf(
+			routetable.Target{CIDR: ip.MustParseCIDROrIP("10.96.0.0/12")},
+			routetable.Target{CIDR: ip.MustParseCIDROrIP("10.96.0.10/32")},
+			routetable.Target{CIDR: ip.MustParseCIDROrIP("172.16.0.1/32")},
+		))
+
+		By("removing the routes when the services lose their local endpoints")
+		manager.OnUpdate(&localServiceIPsUpdate{})
+		Expect(manager.CompleteDeferredWork()).NotTo(HaveOccurred())
+		Expect(rt.currentRoutes["calico-svc"]).To(Equal([]routetable.Target{
+			{CIDR: ip.MustParseCIDROrIP("10.96.0.0/12")},
+		}))
+	})
+
+	It("should create the dummy device and set it up", func() {
+		Expect(manager.configureServiceRouteDevice()).NotTo(HaveOccurred())
+		Expect(nl.addedLinks).To(HaveLen(1))
+		Expect(nl.addedLinks[0].Type()).To(Equal("dummy"))
+		Expect(nl.upLinks).To(Equal([]string{"calico-svc"}))
+	})
+
+	It("should refuse to use a device of the wrong type", func() {
+		nl.link = &mockLink{attrs: netlink.LinkAttrs{Name: "calico-svc"}, typ: "vxlan"}
+		Expect(manager.configureServiceRouteDevice()).To(HaveOccurred())
+		Expect(nl.upLinks).To(BeEmpty())
+	})
+})
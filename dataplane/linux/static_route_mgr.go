@@ -0,0 +1,215 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+
+	"github.com/projectcalico/felix/ip"
+	"github.com/projectcalico/felix/logutils"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/routetable"
+)
+
+// The static route manager programs the operator-defined routes from the StaticRoutes config
+// parameter.  Routes are programmed with their own protocol (StaticRouteProtocol) so that the route
+// tables only ever touch routes that we added.  There's one route table per routing table index
+// that is in use; the main table is created up front so that stale routes from a previous run are
+// cleaned up on the first resync.  Once created, a route table is kept (with no routes) even if
+// the last static route that used it is removed.
+type staticRouteManager struct {
+	ipVersion uint8
+
+	// Our dependencies.
+	newRouteTable func(tableIndex int) routeTable
+	// lookupDevice finds the interface that the given next hop is reachable through.
+	lookupDevice func(nextHop net.IP) (string, error)
+
+	// Internal state.
+	routeTables map[int]routeTable
+	routes      map[string]*proto.StaticRouteUpdate
+	// programmedIfaces records, for each route table, the interfaces that we've given routes to,
+	// so that we can clear them when their routes go away.
+	programmedIfaces map[int]map[string]bool
+	routesDirty      bool
+}
+
+func newStaticRouteManager(ipVersion uint8, dpConfig Config, opRecorder logutils.OpRecorder) *staticRouteManager {
+	return newStaticRouteManagerWithShims(
+		ipVersion,
+		func(tableIndex int) routeTable {
+			return routetable.New([]string{"^.*$"}, ipVersion, false, dpConfig.NetlinkTimeout,
+				nil, dpConfig.StaticRouteProtocol, false, tableIndex, opRecorder)
+		},
+		lookupNextHopDevice,
+	)
+}
+
+func newStaticRouteManagerWithShims(
+	ipVersion uint8,
+	newRouteTable func(tableIndex int) routeTable,
+	lookupDevice func(nextHop net.IP) (string, error),
+) *staticRouteManager {
+	m := &staticRouteManager{
+		ipVersion:        ipVersion,
+		newRouteTable:    newRouteTable,
+		lookupDevice:     lookupDevice,
+		routeTables:      map[int]routeTable{},
+		routes:           map[string]*proto.StaticRouteUpdate{},
+		programmedIfaces: map[int]map[string]bool{},
+		routesDirty:      true,
+	}
+	m.routeTable(0)
+	return m
+}
+
+func (m *staticRouteManager) OnUpdate(protoBufMsg interface{}) {
+	switch msg := protoBufMsg.(type) {
+	case *proto.StaticRouteUpdate:
+		cidr, err := ip.CIDRFromString(msg.Dst)
+		if err != nil {
+			log.WithError(err).WithField("route", msg).Warn("Failed to parse static route CIDR, ignoring")
+			return
+		}
+		if cidr.Version() != m.ipVersion {
+			// Make sure we forget any route with the same ID from the other IP version.
+			if _, ok := m.routes[msg.Id]; ok {
+				delete(m.routes, msg.Id)
+				m.routesDirty = true
+			}
+			return
+		}
+		m.routes[msg.Id] = msg
+		m.routesDirty = true
+	case *proto.StaticRouteRemove:
+		if _, ok := m.routes[msg.Id]; ok {
+			delete(m.routes, msg.Id)
+			m.routesDirty = true
+		}
+	}
+}
+
+func (m *staticRouteManager) CompleteDeferredWork() error {
+	if !m.routesDirty {
+		return nil
+	}
+
+	var lastErr error
+	desired := map[int]map[string][]routetable.Target{}
+	for id, route := range m.routes {
+		target := routetable.Target{CIDR: ip.MustParseCIDROrIP(route.Dst)}
+		device := route.Device
+		if route.NextHop != "" {
+			target.Type = routetable.TargetTypeGateway
+			target.GW = ip.FromString(route.NextHop)
+			if device == "" {
+				var err error
+				device, err = m.lookupDevice(target.GW.AsNetIP())
+				if err != nil {
+					log.WithError(err).WithField("route", id).Warn(
+						"Failed to find the interface for static route's next hop, will retry.")
+					lastErr = err
+					continue
+				}
+			}
+		}
+		table := int(route.Table)
+		if desired[table] == nil {
+			desired[table] = map[string][]routetable.Target{}
+		}
+		desired[table][device] = append(desired[table][device], target)
+	}
+
+	for table, ifaceToTargets := range desired {
+		rt := m.routeTable(table)
+		for iface, targets := range ifaceToTargets {
+			sort.Slice(targets, func(i, j int) bool {
+				return targets[i].CIDR.String() < targets[j].CIDR.String()
+			})
+			log.WithFields(log.Fields{
+				"table":   table,
+				"iface":   iface,
+				"targets": targets,
+			}).Debug("Static route manager sending route updates")
+			rt.SetRoutes(iface, targets)
+		}
+	}
+	for table, ifaces := range m.programmedIfaces {
+		for iface := range ifaces {
+			if _, ok := desired[table][iface]; !ok {
+				m.routeTables[table].SetRoutes(iface, nil)
+			}
+		}
+	}
+
+	m.programmedIfaces = map[int]map[string]bool{}
+	for table, ifaceToTargets := range desired {
+		m.programmedIfaces[table] = map[string]bool{}
+		for iface := range ifaceToTargets {
+			m.programmedIfaces[table][iface] = true
+		}
+	}
+
+	// If we failed to resolve a next hop, stay dirty so that we try again on the next apply.
+	m.routesDirty = lastErr != nil
+	return lastErr
+}
+
+func (m *staticRouteManager) GetRouteTableSyncers() []routeTableSyncer {
+	var tables []int
+	for table := range m.routeTables {
+		tables = append(tables, table)
+	}
+	sort.Ints(tables)
+	syncers := make([]routeTableSyncer, 0, len(tables))
+	for _, table := range tables {
+		syncers = append(syncers, m.routeTables[table])
+	}
+	return syncers
+}
+
+func (m *staticRouteManager) routeTable(tableIndex int) routeTable {
+	rt, ok := m.routeTables[tableIndex]
+	if !ok {
+		log.WithFields(log.Fields{
+			"ipVersion": m.ipVersion,
+			"table":     tableIndex,
+		}).Info("Creating route table for static routes.")
+		rt = m.newRouteTable(tableIndex)
+		m.routeTables[tableIndex] = rt
+	}
+	return rt
+}
+
+// lookupNextHopDevice asks the kernel which interface it would use to reach the given next hop.
+func lookupNextHopDevice(nextHop net.IP) (string, error) {
+	routes, err := netlink.RouteGet(nextHop)
+	if err != nil {
+		return "", err
+	}
+	if len(routes) == 0 || routes[0].LinkIndex == 0 {
+		return "", fmt.Errorf("no route to next hop %v", nextHop)
+	}
+	link, err := netlink.LinkByIndex(routes[0].LinkIndex)
+	if err != nil {
+		return "", err
+	}
+	return link.Attrs().Name, nil
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"errors"
+	"net"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/ip"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/routetable"
+)
+
+var _ = Describe("StaticRouteManager", func() {
+	var (
+		manager     *staticRouteManager
+		routeTables map[int]*mockRouteTable
+		nextHopDevs map[string]string
+	)
+
+	BeforeEach(func() {
+		routeTables = map[int]*mockRouteTable{}
+		nextHopDevs = map[string]string{"192.168.0.1": "eth0"}
+		manager = newStaticRouteManagerWithShims(
+			4,
+			func(tableIndex int) routeTable {
+				rt := &mockRouteTable{
+					currentRoutes:   map[string][]routetable.Target{},
+					currentL2Routes: map[string][]routetable.L2Target{},
+				}
+				routeTables[tableIndex] = rt
+				return rt
+			},
+			func(nextHop net.IP) (string, error) {
+				if dev, ok := nextHopDevs[nextHop.String()]; ok {
+					return dev, nil
+				}
+				return "", errors.New("no route")
+			},
+		)
+	})
+
+	It("should create the main route table up front", func() {
+		Expect(routeTables).To(HaveKey(0))
+		Expect(manager.GetRouteTableSyncers()).To(HaveLen(1))
+		Expect(manager.CompleteDeferredWork()).NotTo(HaveOccurred())
+		Expect(routeTables[0].currentRoutes).To(BeEmpty())
+	})
+
+	It("should program device and gateway routes of the right IP version", func() {
+		manager.OnUpdate(&proto.StaticRouteUpdate{Id: "a", Dst: "10.1.0.0/16", Device: "eth1"})
+		manager.OnUpdate(&proto.StaticRouteUpdate{Id: "b", Dst: "10.2.0.0/16", NextHop: "192.168.0.1"})
+		manager.OnUpdate(&proto.StaticRouteUpdate{Id: "c", Dst: "fd00::/64", Device: "eth1"})
+		Expect(manager.CompleteDeferredWork()).NotTo(HaveOccurred())
+		routeTables[0].checkRoutes("eth1", []routetable.Target{
+			{CIDR: ip.MustParseCIDROrIP("10.1.0.0/16")},
+		})
+		routeTables[0].checkRoutes("eth0", []routetable.Target{
+			{Type: routetable.TargetTypeGateway, CIDR: ip.MustParseCIDROrIP("10.2.0.0/16"), GW: ip.FromString("192.168.0.1")},
+		})
+	})
+
+	It("should program routes into their own table", func() {
+		manager.OnUpdate(&proto.StaticRouteUpdate{Id: "a", Dst: "10.1.0.0/16", Device: "eth1", Table: 100})
+		Expect(manager.CompleteDeferredWork()).NotTo(HaveOccurred())
+		Expect(manager.GetRouteTableSyncers()).To(HaveLen(2))
+		routeTables[100].checkRoutes("eth1", []routetable.Target{
+			{CIDR: ip.MustParseCIDROrIP("10.1.0.0/16")},
+		})
+		Expect(routeTables[0].currentRoutes).To(BeEmpty())
+	})
+
+	It("should clear routes that are moved or removed", func() {
+		manager.OnUpdate(&proto.StaticRouteUpdate{Id: "a", Dst: "10.1.0.0/16", Device: "eth1"})
+		Expect(manager.CompleteDeferredWork()).NotTo(HaveOccurred())
+
+		manager.OnUpdate(&proto.StaticRouteUpdate{Id: "a", Dst: "10.1.0.0/16", Device: "eth2"})
+		Expect(manager.CompleteDeferredWork()).NotTo(HaveOccurred())
+		routeTables[0].checkRoutes("eth1", nil)
+		routeTables[0].checkRoutes("eth2", []routetable.Target{
+			{CIDR: ip.MustParseCIDROrIP("10.1.0.0/16")},
+		})
+
+		manager.OnUpdate(&proto.StaticRouteRemove{Id: "a"})
+		Expect(manager.CompleteDeferredWork()).NotTo(HaveOccurred())
+		routeTables[0].checkRoutes("eth2", nil)
+	})
+
+	It("should retry if a next hop can't be resolved", func() {
+		manager.OnUpdate(&proto.StaticRouteUpdate{Id: "a", Dst: "10.1.0.0/16", NextHop: "192.168.1.1"})
+		Expect(manager.CompleteDeferredWork()).To(HaveOccurred())
+
+		nextHopDevs["192.168.1.1"] = "eth1"
+		Expect(manager.CompleteDeferredWork()).NotTo(HaveOccurred())
+		routeTables[0].checkRoutes("eth1", []routetable.Target{
+			{Type: routetable.TargetTypeGateway, CIDR: ip.MustParseCIDROrIP("10.1.0.0/16"), GW: ip.FromString("192.168.1.1")},
+		})
+	})
+})
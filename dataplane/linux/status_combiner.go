@@ -77,6 +77,9 @@ func (e *endpointStatusCombiner) Apply() {
 			} else if status == "down" && statusToReport != "error" {
 				logCxt.Info("Endpoint down for at least one IP version")
 				statusToReport = "down"
+			} else if status == "waiting" && (statusToReport == "" || statusToReport == "up") {
+				logCxt.Info("Endpoint waiting for its IPs for at least one IP version")
+				statusToReport = "waiting"
 			} else if status == "up" && statusToReport == "" {
 				logCxt.Info("Endpoint up for at least one IP version")
 				statusToReport = "up"
@@ -96,6 +96,12 @@ var _ = Describe("StatusCombiner", func() {
 			Entry("error, error == error", "error", "error", "error"),
 			Entry("error, up == error", "error", "up", "error"),
 			Entry("error, down == error", "error", "down", "error"),
+
+			Entry("waiting, up == waiting", "waiting", "up", "waiting"),
+			Entry("up, waiting == waiting", "up", "waiting", "waiting"),
+			Entry("waiting, down == down", "waiting", "down", "down"),
+			Entry("down, waiting == down", "down", "waiting", "down"),
+			Entry("waiting, error == error", "waiting", "error", "error"),
 		)
 	})
 
@@ -145,6 +151,7 @@ var _ = Describe("StatusCombiner", func() {
 			Entry("up == up", "up"),
 			Entry("down == down", "down"),
 			Entry("error == error", "error"),
+			Entry("waiting == waiting", "waiting"),
 		)
 	})
 })
@@ -0,0 +1,183 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"fmt"
+	"runtime"
+	"unsafe"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+
+	"github.com/projectcalico/felix/ipsets"
+	"github.com/projectcalico/felix/iptables"
+)
+
+// Scheduling policies and I/O priority classes, from linux/sched.h and linux/ioprio.h.
+var (
+	schedPolicies = map[string]int{
+		"Other": 0,
+		"FIFO":  1,
+		"RR":    2,
+		"Batch": 3,
+		"Idle":  5,
+	}
+	ioPriorityClasses = map[string]int{
+		"RealTime":   1,
+		"BestEffort": 2,
+		"Idle":       3,
+	}
+)
+
+const (
+	ioprioWhoProcess = 1
+	ioprioClassShift = 13
+)
+
+// ThreadSchedConfig holds the scheduling settings for the thread that runs the dataplane's main
+// loop, and for the iptables/nftables/ipset commands that the dataplane runs.  The zero value (or "Default"/"Other" for the string fields) leaves the corresponding
+// setting alone.
+type ThreadSchedConfig struct {
+	Nice            int
+	IOPriorityClass string
+	IOPriorityLevel int
+	Policy          string
+	Priority        int
+	CPUs            []int
+}
+
+func (c ThreadSchedConfig) IsDefault() bool {
+	return c.Nice == 0 &&
+		(c.IOPriorityClass == "" || c.IOPriorityClass == "Default") &&
+		(c.Policy == "" || c.Policy == "Other") &&
+		len(c.CPUs) == 0
+}
+
+// ApplyToCurrentThread applies the settings to the calling OS thread.  The caller should have
+// locked its goroutine to the thread with runtime.LockOSThread(), otherwise the settings apply to
+// whichever goroutines happen to run on the thread.
+func (c ThreadSchedConfig) ApplyToCurrentThread() error {
+	// With a thread ID, these calls act on the thread rather than the whole process.
+	tid := unix.Gettid()
+	logCxt := log.WithFields(log.Fields{"tid": tid, "config": c})
+
+	if len(c.CPUs) > 0 {
+		var set unix.CPUSet
+		for _, cpu := range c.CPUs {
+			set.Set(cpu)
+		}
+		if err := unix.SchedSetaffinity(tid, &set); err != nil {
+			return fmt.Errorf("failed to set CPU affinity to %v: %w", c.CPUs, err)
+		}
+	}
+	if c.Nice != 0 {
+		if err := unix.Setpriority(unix.PRIO_PROCESS, tid, c.Nice); err != nil {
+			return fmt.Errorf("failed to set nice value to %d: %w", c.Nice, err)
+		}
+	}
+	if class, ok := ioPriorityClasses[c.IOPriorityClass]; ok {
+		prio := class<<ioprioClassShift | c.IOPriorityLevel
+		_, _, errno := unix.Syscall(unix.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), uintptr(prio))
+		if errno != 0 {
+			return fmt.Errorf("failed to set I/O priority to %s/%d: %w", c.IOPriorityClass, c.IOPriorityLevel, errno)
+		}
+	}
+	if policy, ok := schedPolicies[c.Policy]; ok && policy != 0 {
+		param := struct{ priority int32 }{int32(c.Priority)}
+		_, _, errno := unix.Syscall(unix.SYS_SCHED_SETSCHEDULER, uintptr(tid), uintptr(policy),
+			uintptr(unsafe.Pointer(&param)))
+		if errno != 0 {
+			return fmt.Errorf("failed to set scheduling policy to %s/%d: %w", c.Policy, c.Priority, errno)
+		}
+	}
+	logCxt.Debug("Applied scheduling settings to thread.")
+	return nil
+}
+
+// runOnThread runs fn on a new OS thread that has the settings applied.  A child process inherits
+// the settings of the thread that forks it, so fn should start any commands that should have the
+// settings.  The thread exits once fn returns, so the settings don't leak to other goroutines.
+func (c ThreadSchedConfig) runOnThread(fn func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// Never unlocked, so the runtime discards the thread when the goroutine exits.
+		runtime.LockOSThread()
+		if err := c.ApplyToCurrentThread(); err != nil {
+			log.WithError(err).Warn("Failed to apply scheduling settings to command thread.")
+		}
+		fn()
+	}()
+	<-done
+}
+
+// iptablesCmdFactory returns a command factory for the iptables and nftables tables that starts
+// the commands with the settings applied.
+func (c ThreadSchedConfig) iptablesCmdFactory() func(name string, arg ...string) iptables.CmdIface {
+	return func(name string, arg ...string) iptables.CmdIface {
+		return &schedIptablesCmd{CmdIface: iptables.NewRealCmd(name, arg...), sched: c}
+	}
+}
+
+// schedIptablesCmd wraps the methods of an iptables command that fork the process.
+type schedIptablesCmd struct {
+	iptables.CmdIface
+	sched ThreadSchedConfig
+}
+
+func (c *schedIptablesCmd) Run() (err error) {
+	c.sched.runOnThread(func() { err = c.CmdIface.Run() })
+	return
+}
+
+func (c *schedIptablesCmd) Start() (err error) {
+	c.sched.runOnThread(func() { err = c.CmdIface.Start() })
+	return
+}
+
+func (c *schedIptablesCmd) Output() (out []byte, err error) {
+	c.sched.runOnThread(func() { out, err = c.CmdIface.Output() })
+	return
+}
+
+// ipsetsCmdFactory returns a command factory for the IP sets that starts the commands with the
+// settings applied.
+func (c ThreadSchedConfig) ipsetsCmdFactory() func(name string, arg ...string) ipsets.CmdIface {
+	return func(name string, arg ...string) ipsets.CmdIface {
+		return &schedIPSetsCmd{CmdIface: ipsets.NewRealCmd(name, arg...), sched: c}
+	}
+}
+
+// schedIPSetsCmd wraps the methods of an ipset command that fork the process.
+type schedIPSetsCmd struct {
+	ipsets.CmdIface
+	sched ThreadSchedConfig
+}
+
+func (c *schedIPSetsCmd) Start() (err error) {
+	c.sched.runOnThread(func() { err = c.CmdIface.Start() })
+	return
+}
+
+func (c *schedIPSetsCmd) Output() (out []byte, err error) {
+	c.sched.runOnThread(func() { out, err = c.CmdIface.Output() })
+	return
+}
+
+func (c *schedIPSetsCmd) CombinedOutput() (out []byte, err error) {
+	c.sched.runOnThread(func() { out, err = c.CmdIface.CombinedOutput() })
+	return
+}
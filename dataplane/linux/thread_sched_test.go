@@ -0,0 +1,89 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"runtime"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"golang.org/x/sys/unix"
+
+	"github.com/projectcalico/felix/iptables"
+)
+
+var _ = Describe("Dataplane thread scheduling settings", func() {
+	It("should treat the config defaults as default", func() {
+		Expect(ThreadSchedConfig{}.IsDefault()).To(BeTrue())
+		Expect(ThreadSchedConfig{
+			IOPriorityClass: "Default",
+			IOPriorityLevel: 4,
+			Policy:          "Other",
+		}.IsDefault()).To(BeTrue())
+		Expect(ThreadSchedConfig{Nice: 5}.IsDefault()).To(BeFalse())
+		Expect(ThreadSchedConfig{CPUs: []int{0}}.IsDefault()).To(BeFalse())
+	})
+
+	It("should apply settings that don't need privileges to the current thread only", func() {
+		type result struct {
+			err          error
+			tid          int
+			threadPrio   int
+			threadPolicy uintptr
+		}
+		results := make(chan result, 1)
+		go func() {
+			// Never unlocked, so the thread exits along with the goroutine.
+			runtime.LockOSThread()
+			var r result
+			r.tid = unix.Gettid()
+			r.err = ThreadSchedConfig{
+				Nice:            5,
+				IOPriorityClass: "BestEffort",
+				IOPriorityLevel: 7,
+				Policy:          "Batch",
+			}.ApplyToCurrentThread()
+			r.threadPrio, _ = unix.Getpriority(unix.PRIO_PROCESS, r.tid)
+			r.threadPolicy, _, _ = unix.Syscall(unix.SYS_SCHED_GETSCHEDULER, uintptr(r.tid), 0, 0)
+			results <- r
+		}()
+		r := <-results
+		Expect(r.err).NotTo(HaveOccurred())
+		// The raw syscall returns 20 - nice.
+		Expect(r.threadPrio).To(Equal(15))
+		Expect(r.threadPolicy).To(BeEquivalentTo(schedPolicies["Batch"]))
+
+		// Our own thread should be unaffected.
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		ourPolicy, _, _ := unix.Syscall(unix.SYS_SCHED_GETSCHEDULER, uintptr(unix.Gettid()), 0, 0)
+		Expect(ourPolicy).To(BeEquivalentTo(schedPolicies["Other"]))
+	})
+
+	It("should start commands with the settings applied", func() {
+		sched := ThreadSchedConfig{Nice: 5}
+		out, err := sched.iptablesCmdFactory()("nice").Output()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(Equal("5\n"))
+		out, err = sched.ipsetsCmdFactory()("nice").Output()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(Equal("5\n"))
+
+		// The threads that started the commands have gone, so our own commands are unaffected.
+		out, err = iptables.NewRealCmd("nice").Output()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(out)).To(Equal("0\n"))
+	})
+})
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"net"
 	"reflect"
+	"regexp"
 	"strings"
 	"sync"
 	"syscall"
@@ -72,6 +73,11 @@ type vxlanManager struct {
 	vxlanDevice string
 	vxlanID     int
 	vxlanPort   int
+	// poolVNIs maps the IP pools that have their own VNI to that VNI and poolDevices maps the
+	// names of the extra VXLAN devices for those VNIs to their VNIs.  Routes to a pool's blocks go
+	// via the device for its VNI.
+	poolVNIs    map[ip.V4CIDR]int
+	poolDevices map[string]int
 
 	// Indicates if configuration has changed since the last apply.
 	routesDirty       bool
@@ -91,6 +97,10 @@ const (
 	defaultVXLANProto = 80
 )
 
+// vxlanPoolDeviceRegexp matches the names of the per-pool VXLAN devices; see
+// rules.VXLANPoolDeviceName().
+var vxlanPoolDeviceRegexp = regexp.MustCompile(`^vx[0-9]+\.cali$`)
+
 func newVXLANManager(
 	ipsetsDataplane ipsetsDataplane,
 	rt routeTable,
@@ -161,6 +171,8 @@ func newVXLANManagerWithShims(
 		vxlanDevice:         deviceName,
 		vxlanID:             dpConfig.RulesConfig.VXLANVNI,
 		vxlanPort:           dpConfig.RulesConfig.VXLANPort,
+		poolVNIs:            dpConfig.RulesConfig.VXLANPoolVNIs,
+		poolDevices:         dpConfig.RulesConfig.VXLANPoolDevices(),
 		externalNodeCIDRs:   dpConfig.ExternalNodesCidrs,
 		routesDirty:         true,
 		vtepsDirty:          true,
@@ -348,14 +360,20 @@ func (m *vxlanManager) CompleteDeferredWork() error {
 			allowedVXLANSources = append(allowedVXLANSources, u.ParentDeviceIp)
 		}
 		logrus.WithField("l2routes", l2routes).Debug("VXLAN manager sending L2 updates")
-		m.routeTable.SetL2Routes(m.vxlanDevice, l2routes)
+		for device := range m.devices() {
+			m.routeTable.SetL2Routes(device, l2routes)
+		}
 		m.ipsetsDataplane.AddOrReplaceIPSet(m.ipSetMetadata, allowedVXLANSources)
 		m.vtepsDirty = false
 	}
 
 	if m.routesDirty {
 		// Iterate through all of our L3 routes and send them through to the route table.
-		var vxlanRoutes []routetable.Target
+		vxlanRoutes := map[string][]routetable.Target{}
+		for device := range m.devices() {
+			// Make sure that we clean up the routes of devices that no longer have any.
+			vxlanRoutes[device] = nil
+		}
 		var noEncapRoutes []routetable.Target
 		for _, r := range m.routesByDest {
 			logCtx := logrus.WithField("route", r)
@@ -395,13 +413,17 @@ func (m *vxlanManager) CompleteDeferredWork() error {
 					GW:   ip.FromString(vtep.Ipv4Addr),
 				}
 
-				vxlanRoutes = append(vxlanRoutes, vxlanRoute)
-				logCtx.WithField("route", vxlanRoute).Debug("adding vxlan route to list for addition")
+				device := m.deviceForRoute(cidr)
+				vxlanRoutes[device] = append(vxlanRoutes[device], vxlanRoute)
+				logCtx.WithFields(logrus.Fields{"route": vxlanRoute, "device": device}).Debug(
+					"adding vxlan route to list for addition")
 			}
 		}
 
 		logrus.WithField("vxlanroutes", vxlanRoutes).Debug("VXLAN manager sending VXLAN L3 updates")
-		m.routeTable.SetRoutes(m.vxlanDevice, vxlanRoutes)
+		for device, routes := range vxlanRoutes {
+			m.routeTable.SetRoutes(device, routes)
+		}
 
 		m.blackholeRouteTable.SetRoutes(routetable.InterfaceNone, m.blackholeRoutes())
 
@@ -428,6 +450,38 @@ func (m *vxlanManager) CompleteDeferredWork() error {
 	return nil
 }
 
+// devices returns the names of all the VXLAN devices, mapped to their VNIs.
+func (m *vxlanManager) devices() map[string]int {
+	devices := map[string]int{m.vxlanDevice: m.vxlanID}
+	for name, vni := range m.poolDevices {
+		devices[name] = vni
+	}
+	return devices
+}
+
+// deviceForRoute returns the VXLAN device for routes to the given CIDR; that's the device for the
+// VNI of the most specific pool that contains the CIDR or, failing that, the main device.
+func (m *vxlanManager) deviceForRoute(cidr ip.CIDR) string {
+	device := m.vxlanDevice
+	v4CIDR, ok := cidr.(ip.V4CIDR)
+	if !ok {
+		return device
+	}
+	bestPrefix := -1
+	for pool, vni := range m.poolVNIs {
+		if pool.Prefix() > v4CIDR.Prefix() || int(pool.Prefix()) <= bestPrefix ||
+			!pool.ContainsV4(v4CIDR.Addr().(ip.V4Addr)) {
+			continue
+		}
+		bestPrefix = int(pool.Prefix())
+		device = m.vxlanDevice
+		if vni != m.vxlanID {
+			device = rules.VXLANPoolDeviceName(vni)
+		}
+	}
+	return device
+}
+
 // KeepVXLANDeviceInSync is a goroutine that configures the VXLAN tunnel device, then periodically
 // checks that it is still correctly configured.
 func (m *vxlanManager) KeepVXLANDeviceInSync(mtu int, xsumBroken bool, wait time.Duration) {
@@ -495,31 +549,72 @@ func (m *vxlanManager) getParentInterface(localVTEP *proto.VXLANTunnelEndpointUp
 	return nil, fmt.Errorf("Unable to find parent interface with address %s", localVTEP.ParentDeviceIp)
 }
 
-// configureVXLANDevice ensures the VXLAN tunnel device is up and configured correctly.
+// configureVXLANDevice ensures the VXLAN tunnel devices are up and configured correctly, and
+// removes the devices of VNIs that are no longer in use.
 func (m *vxlanManager) configureVXLANDevice(mtu int, localVTEP *proto.VXLANTunnelEndpointUpdate, xsumBroken bool) error {
-	logCxt := logrus.WithFields(logrus.Fields{"device": m.vxlanDevice})
-	logCxt.Debug("Configuring VXLAN tunnel device")
 	parent, err := m.getParentInterface(localVTEP)
 	if err != nil {
 		return err
 	}
+	if err := m.configureVXLANDeviceForVNI(m.vxlanDevice, m.vxlanID, mtu, localVTEP, parent, xsumBroken); err != nil {
+		return err
+	}
+	for name, vni := range m.poolDevices {
+		if err := m.configureVXLANDeviceForVNI(name, vni, mtu, localVTEP, parent, xsumBroken); err != nil {
+			return err
+		}
+	}
+	return m.removeUnusedPoolDevices()
+}
+
+// removeUnusedPoolDevices removes the per-pool VXLAN devices of VNIs that are no longer configured.
+func (m *vxlanManager) removeUnusedPoolDevices() error {
+	links, err := m.nlHandle.LinkList()
+	if err != nil {
+		return err
+	}
+	for _, link := range links {
+		name := link.Attrs().Name
+		if _, ok := m.poolDevices[name]; ok || !vxlanPoolDeviceRegexp.MatchString(name) {
+			continue
+		}
+		logrus.WithField("device", name).Info("Removing VXLAN device for VNI that is no longer in use")
+		if err := m.nlHandle.LinkDel(link); err != nil {
+			return fmt.Errorf("failed to delete unused vxlan interface %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// configureVXLANDeviceForVNI ensures that the VXLAN tunnel device for one VNI is up and
+// configured correctly.
+func (m *vxlanManager) configureVXLANDeviceForVNI(
+	name string,
+	vni int,
+	mtu int,
+	localVTEP *proto.VXLANTunnelEndpointUpdate,
+	parent netlink.Link,
+	xsumBroken bool,
+) error {
+	logCxt := logrus.WithFields(logrus.Fields{"device": name})
+	logCxt.Debug("Configuring VXLAN tunnel device")
 	mac, err := net.ParseMAC(localVTEP.Mac)
 	if err != nil {
 		return err
 	}
 	vxlan := &netlink.Vxlan{
 		LinkAttrs: netlink.LinkAttrs{
-			Name:         m.vxlanDevice,
+			Name:         name,
 			HardwareAddr: mac,
 		},
-		VxlanId:      m.vxlanID,
+		VxlanId:      vni,
 		Port:         m.vxlanPort,
 		VtepDevIndex: parent.Attrs().Index,
 		SrcAddr:      ip.FromString(localVTEP.ParentDeviceIp).AsNetIP(),
 	}
 
 	// Try to get the device.
-	link, err := m.nlHandle.LinkByName(m.vxlanDevice)
+	link, err := m.nlHandle.LinkByName(name)
 	if err != nil {
 		logrus.WithError(err).Info("Failed to get VXLAN tunnel device, assuming it isn't present")
 		if err := m.nlHandle.LinkAdd(vxlan); err == syscall.EEXIST {
@@ -531,9 +626,9 @@ func (m *vxlanManager) configureVXLANDevice(mtu int, localVTEP *proto.VXLANTunne
 		}
 
 		// The device now exists - requery it to check that the link exists and is a vxlan device.
-		link, err = m.nlHandle.LinkByName(m.vxlanDevice)
+		link, err = m.nlHandle.LinkByName(name)
 		if err != nil {
-			return fmt.Errorf("can't locate created vxlan device %v", m.vxlanDevice)
+			return fmt.Errorf("can't locate created vxlan device %v", name)
 		}
 	}
 
@@ -576,7 +671,7 @@ func (m *vxlanManager) configureVXLANDevice(mtu int, localVTEP *proto.VXLANTunne
 
 	// If required, disable checksum offload.
 	if xsumBroken {
-		if err := ethtool.EthtoolTXOff(m.vxlanDevice); err != nil {
+		if err := ethtool.EthtoolTXOff(name); err != nil {
 			return fmt.Errorf("failed to disable checksum offload: %s", err)
 		}
 	}
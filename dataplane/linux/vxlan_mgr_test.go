@@ -32,7 +32,8 @@ import (
 )
 
 type mockVXLANDataplane struct {
-	links []netlink.Link
+	links        []netlink.Link
+	deletedLinks []string
 }
 
 func (m *mockVXLANDataplane) LinkByName(name string) (netlink.Link, error) {
@@ -79,7 +80,8 @@ func (m *mockVXLANDataplane) LinkList() ([]netlink.Link, error) {
 func (m *mockVXLANDataplane) LinkAdd(netlink.Link) error {
 	return nil
 }
-func (m *mockVXLANDataplane) LinkDel(netlink.Link) error {
+func (m *mockVXLANDataplane) LinkDel(link netlink.Link) error {
+	m.deletedLinks = append(m.deletedLinks, link.Attrs().Name)
 	return nil
 }
 
@@ -247,4 +249,91 @@ var _ = Describe("VXLANManager", func() {
 		Expect(manager.routesDirty).To(BeFalse())
 		Expect(prt.currentRoutes["eth0"]).To(HaveLen(1))
 	})
+
+	Describe("with per-pool VNIs", func() {
+		var dataplane *mockVXLANDataplane
+
+		BeforeEach(func() {
+			dataplane = &mockVXLANDataplane{
+				links: []netlink.Link{
+					&mockLink{attrs: netlink.LinkAttrs{Name: "eth0"}},
+					&mockLink{attrs: netlink.LinkAttrs{Name: "vx3.cali"}},
+				},
+			}
+			manager = newVXLANManagerWithShims(
+				newMockIPSets(),
+				rt, brt,
+				"vxlan.calico",
+				Config{
+					MaxIPSetSize:       5,
+					Hostname:           "node1",
+					ExternalNodesCidrs: []string{"10.0.0.0/24"},
+					RulesConfig: rules.Config{
+						VXLANVNI:  1,
+						VXLANPort: 20,
+						VXLANPoolVNIs: map[ip.V4CIDR]int{
+							ip.MustParseCIDROrIP("172.1.0.0/16").(ip.V4CIDR): 2,
+						},
+					},
+				},
+				dataplane,
+				func(interfacePrefixes []string, ipVersion uint8, vxlan bool, netlinkTimeout time.Duration,
+					deviceRouteSourceAddress net.IP, deviceRouteProtocol int, removeExternalRoutes bool) routeTable {
+					return prt
+				},
+			)
+			manager.OnUpdate(&proto.VXLANTunnelEndpointUpdate{
+				Node:           "node1",
+				Mac:            "00:0a:74:9d:68:16",
+				Ipv4Addr:       "10.0.0.0",
+				ParentDeviceIp: "172.0.0.2",
+			})
+			manager.OnUpdate(&proto.VXLANTunnelEndpointUpdate{
+				Node:           "node2",
+				Mac:            "00:0a:95:9d:68:16",
+				Ipv4Addr:       "10.0.80.0",
+				ParentDeviceIp: "172.0.12.1",
+			})
+		})
+
+		It("removes the devices of VNIs that are no longer in use", func() {
+			err := manager.configureVXLANDevice(50, manager.getLocalVTEP(), false)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(dataplane.deletedLinks).To(ContainElement("vx3.cali"))
+			Expect(dataplane.deletedLinks).NotTo(ContainElement("vx2.cali"))
+		})
+
+		It("routes to each pool via the device for its VNI", func() {
+			manager.OnUpdate(&proto.RouteUpdate{
+				Type:        proto.RouteType_REMOTE_WORKLOAD,
+				IpPoolType:  proto.IPPoolType_VXLAN,
+				Dst:         "172.0.0.0/26",
+				DstNodeName: "node2",
+				DstNodeIp:   "172.8.8.8",
+			})
+			manager.OnUpdate(&proto.RouteUpdate{
+				Type:        proto.RouteType_REMOTE_WORKLOAD,
+				IpPoolType:  proto.IPPoolType_VXLAN,
+				Dst:         "172.1.0.0/26",
+				DstNodeName: "node2",
+				DstNodeIp:   "172.8.8.8",
+			})
+			manager.noEncapRouteTable = prt
+
+			err := manager.CompleteDeferredWork()
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(rt.currentRoutes["vxlan.calico"]).To(ConsistOf(routetable.Target{
+				Type: routetable.TargetTypeVXLAN,
+				CIDR: ip.MustParseCIDROrIP("172.0.0.0/26"),
+				GW:   ip.FromString("10.0.80.0"),
+			}))
+			Expect(rt.currentRoutes["vx2.cali"]).To(ConsistOf(routetable.Target{
+				Type: routetable.TargetTypeVXLAN,
+				CIDR: ip.MustParseCIDROrIP("172.1.0.0/26"),
+				GW:   ip.FromString("10.0.80.0"),
+			}))
+			Expect(rt.currentL2Routes["vx2.cali"]).To(Equal(rt.currentL2Routes["vxlan.calico"]))
+		})
+	})
 })
@@ -38,6 +38,10 @@ type wireguardManager struct {
 	// Our dependencies.
 	wireguardRouteTable *wireguard.Wireguard
 	dpConfig            Config
+
+	// hostIfaceMTUs holds the MTUs of the host interfaces that MTU auto-detection looks at.  We
+	// only receive them if the wireguard MTU is auto-detected.
+	hostIfaceMTUs map[string]int
 }
 
 type WireguardStatusUpdateCallback func(ipVersion uint8, id interface{}, status string)
@@ -49,7 +53,24 @@ func newWireguardManager(
 	return &wireguardManager{
 		wireguardRouteTable: wireguardRouteTable,
 		dpConfig:            dpConfig,
+		hostIfaceMTUs:       map[string]int{},
+	}
+}
+
+// endpointAddr returns the host address to use as the peer's wireguard endpoint.  We use the
+// address of the preferred family if the host has one, falling back to the other family so that
+// IPv6-only (or IPv4-only) hosts can still be peered with.
+func (m *wireguardManager) endpointAddr(msg *proto.HostMetadataUpdate) ip.Addr {
+	addrs := []string{msg.Ipv4Addr, msg.Ipv6Addr}
+	if m.dpConfig.PreferredHostIPVersion == 6 {
+		addrs[0], addrs[1] = addrs[1], addrs[0]
 	}
+	for _, a := range addrs {
+		if a != "" {
+			return ip.FromString(a)
+		}
+	}
+	return nil
 }
 
 func (m *wireguardManager) OnUpdate(protoBufMsg interface{}) {
@@ -57,7 +78,7 @@ func (m *wireguardManager) OnUpdate(protoBufMsg interface{}) {
 	switch msg := protoBufMsg.(type) {
 	case *proto.HostMetadataUpdate:
 		log.WithField("msg", msg).Debug("HostMetadataUpdate update")
-		m.wireguardRouteTable.EndpointUpdate(msg.Hostname, ip.FromString(msg.Ipv4Addr))
+		m.wireguardRouteTable.EndpointUpdate(msg.Hostname, m.endpointAddr(msg))
 	case *proto.HostMetadataRemove:
 		log.WithField("msg", msg).Debug("HostMetadataRemove update")
 		m.wireguardRouteTable.EndpointRemove(msg.Hostname)
@@ -116,11 +137,37 @@ func (m *wireguardManager) OnUpdate(protoBufMsg interface{}) {
 				ifaceAddr = addr
 			}
 		}
-		m.wireguardRouteTable.EndpointWireguardUpdate(msg.Hostname, key, ifaceAddr)
+		m.wireguardRouteTable.EndpointWireguardUpdate(msg.Hostname, key, ifaceAddr, msg.HasEncryptionLabel)
 	case *proto.WireguardEndpointRemove:
 		log.WithField("msg", msg).Debug("WireguardEndpointRemove update")
 		m.wireguardRouteTable.EndpointWireguardRemove(msg.Hostname)
+	case *ifaceMTUUpdate:
+		m.onHostIfaceMTUUpdate(msg)
+	}
+}
+
+// onHostIfaceMTUUpdate recalculates the wireguard MTU from the smallest MTU of the host's
+// interfaces, as at start of day, so that it tracks changes to the underlying network.
+func (m *wireguardManager) onHostIfaceMTUUpdate(msg *ifaceMTUUpdate) {
+	if !m.dpConfig.wireguardMTUAuto {
+		return
+	}
+	if msg.MTU == 0 {
+		delete(m.hostIfaceMTUs, msg.Name)
+	} else {
+		m.hostIfaceMTUs[msg.Name] = msg.MTU
+	}
+	hostMTU := 0
+	for _, mtu := range m.hostIfaceMTUs {
+		if hostMTU == 0 || mtu < hostMTU {
+			hostMTU = mtu
+		}
+	}
+	if hostMTU == 0 {
+		// No interfaces left; keep the MTU that we have.
+		return
 	}
+	m.wireguardRouteTable.SetMTU(wireguardMTUForHostMTU(hostMTU, &m.dpConfig))
 }
 
 func (m *wireguardManager) CompleteDeferredWork() error {
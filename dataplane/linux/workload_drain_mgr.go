@@ -0,0 +1,239 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"net"
+	"os"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+
+	"github.com/projectcalico/felix/ip"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/rules"
+	"github.com/projectcalico/libcalico-go/lib/set"
+)
+
+var (
+	gaugeWorkloadDrainActive = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "felix_workload_drain_active",
+		Help: "Set to 1 while the node's workloads are being drained, 0 otherwise.",
+	})
+	gaugeWorkloadDrainConnections = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "felix_workload_drain_active_connections",
+		Help: "Number of conntrack entries for local workload IPs, updated while draining.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(gaugeWorkloadDrainActive)
+	prometheus.MustRegister(gaugeWorkloadDrainConnections)
+}
+
+// The workload drain manager supports graceful node maintenance.  While the node is being drained
+// (either because drain mode is enabled in config or because the drain file exists) it programs
+// a chain that rejects new connections to local workloads.  Established connections are left
+// alone.
+//
+// While draining, the manager periodically counts the conntrack entries that involve local
+// workload IPs and reports the count via a Prometheus gauge and the log, so that an operator (or
+// automation) can tell when it's safe to proceed.
+type workloadDrainManager struct {
+	// Our dependencies.
+	filterTables []iptablesTable
+	ruleRenderer rules.RuleRenderer
+	ipVersions   []uint8
+
+	enabled   bool
+	drainFile string
+
+	// Internal state.
+	draining      bool
+	chainsDirty   bool
+	pollPending   bool
+	activeConns   int
+	workloadIPs   map[proto.WorkloadEndpointID][]ip.Addr
+	workloadIPSet set.Set
+
+	// Shims for testing.
+	stat          func(name string) (os.FileInfo, error)
+	listConntrack func(family netlink.InetFamily) ([]*netlink.ConntrackFlow, error)
+}
+
+func newWorkloadDrainManager(
+	filterTables []iptablesTable,
+	ruleRenderer rules.RuleRenderer,
+	ipVersions []uint8,
+	enabled bool,
+	drainFile string,
+) *workloadDrainManager {
+	return newWorkloadDrainManagerWithShims(filterTables, ruleRenderer, ipVersions, enabled, drainFile,
+		os.Stat, func(family netlink.InetFamily) ([]*netlink.ConntrackFlow, error) {
+			return netlink.ConntrackTableList(netlink.ConntrackTable, family)
+		})
+}
+
+func newWorkloadDrainManagerWithShims(
+	filterTables []iptablesTable,
+	ruleRenderer rules.RuleRenderer,
+	ipVersions []uint8,
+	enabled bool,
+	drainFile string,
+	stat func(name string) (os.FileInfo, error),
+	listConntrack func(family netlink.InetFamily) ([]*netlink.ConntrackFlow, error),
+) *workloadDrainManager {
+	return &workloadDrainManager{
+		filterTables:  filterTables,
+		ruleRenderer:  ruleRenderer,
+		ipVersions:    ipVersions,
+		enabled:       enabled,
+		drainFile:     drainFile,
+		chainsDirty:   true,
+		pollPending:   true,
+		workloadIPs:   map[proto.WorkloadEndpointID][]ip.Addr{},
+		workloadIPSet: set.New(),
+		stat:          stat,
+		listConntrack: listConntrack,
+	}
+}
+
+func (m *workloadDrainManager) OnUpdate(protoBufMsg interface{}) {
+	switch msg := protoBufMsg.(type) {
+	case *proto.WorkloadEndpointUpdate:
+		var addrs []ip.Addr
+		for _, nets := range [][]string{msg.Endpoint.Ipv4Nets, msg.Endpoint.Ipv6Nets} {
+			for _, s := range nets {
+				cidr, err := ip.ParseCIDROrIP(s)
+				if err != nil {
+					log.WithError(err).WithField("cidr", s).Warn("Ignoring unparsable workload IP.")
+					continue
+				}
+				addrs = append(addrs, cidr.Addr())
+			}
+		}
+		m.workloadIPs[*msg.Id] = addrs
+		m.recalculateWorkloadIPs()
+	case *proto.WorkloadEndpointRemove:
+		delete(m.workloadIPs, *msg.Id)
+		m.recalculateWorkloadIPs()
+	}
+}
+
+func (m *workloadDrainManager) recalculateWorkloadIPs() {
+	m.workloadIPSet = set.New()
+	for _, addrs := range m.workloadIPs {
+		for _, a := range addrs {
+			m.workloadIPSet.Add(a)
+		}
+	}
+}
+
+// QueuePoll asks the manager to re-check the drain trigger and, if draining, the number of active
+// connections on the next call to CompleteDeferredWork.
+func (m *workloadDrainManager) QueuePoll() {
+	m.pollPending = true
+}
+
+// IsDraining returns true if the node's workloads are being drained.
+func (m *workloadDrainManager) IsDraining() bool {
+	return m.draining
+}
+
+// ActiveConnections returns the number of conntrack entries for local workloads as of the last
+// poll while draining.
+func (m *workloadDrainManager) ActiveConnections() int {
+	return m.activeConns
+}
+
+func (m *workloadDrainManager) CompleteDeferredWork() error {
+	if !m.pollPending {
+		return nil
+	}
+	m.pollPending = false
+
+	draining := m.enabled
+	if !draining && m.drainFile != "" {
+		if _, err := m.stat(m.drainFile); err == nil {
+			draining = true
+		} else if !os.IsNotExist(err) {
+			// Fail safe: keep the current state until we can read the drain file.
+			log.WithError(err).WithField("file", m.drainFile).Warn("Failed to check workload drain file.")
+			draining = m.draining
+		}
+	}
+	if draining != m.draining {
+		log.WithField("draining", draining).Info("Workload drain mode changed.")
+		m.draining = draining
+		m.chainsDirty = true
+		if draining {
+			gaugeWorkloadDrainActive.Set(1)
+		} else {
+			gaugeWorkloadDrainActive.Set(0)
+			m.activeConns = 0
+			gaugeWorkloadDrainConnections.Set(0)
+		}
+	}
+
+	if m.chainsDirty {
+		chain := m.ruleRenderer.WorkloadDrainChain(m.draining)
+		for _, t := range m.filterTables {
+			t.UpdateChain(chain)
+		}
+		m.chainsDirty = false
+	}
+
+	if m.draining {
+		m.updateActiveConnections()
+	}
+	return nil
+}
+
+func (m *workloadDrainManager) updateActiveConnections() {
+	count := 0
+	for _, v := range m.ipVersions {
+		family := netlink.InetFamily(netlink.FAMILY_V4)
+		if v == 6 {
+			family = netlink.FAMILY_V6
+		}
+		flows, err := m.listConntrack(family)
+		if err != nil {
+			// Not worth retrying early; we'll try again on the next poll.
+			log.WithError(err).WithField("ipVersion", v).Warn("Failed to list conntrack entries.")
+			return
+		}
+		for _, f := range flows {
+			// Match on the source of each direction so that we count connections to workloads
+			// via a DNAT as well as connections from workloads.
+			if m.isWorkloadIP(f.Forward.SrcIP) || m.isWorkloadIP(f.Reverse.SrcIP) {
+				count++
+			}
+		}
+	}
+
+	if count != m.activeConns {
+		log.WithField("activeConnections", count).Info("Draining workloads.")
+	}
+	m.activeConns = count
+	gaugeWorkloadDrainConnections.Set(float64(count))
+}
+
+func (m *workloadDrainManager) isWorkloadIP(addr net.IP) bool {
+	if len(addr) == 0 {
+		return false
+	}
+	return m.workloadIPSet.Contains(ip.FromNetIP(addr))
+}
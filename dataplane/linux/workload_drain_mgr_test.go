@@ -0,0 +1,196 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"errors"
+	"net"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vishvananda/netlink"
+
+	"github.com/projectcalico/felix/ipsets"
+	"github.com/projectcalico/felix/iptables"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/rules"
+)
+
+var _ = Describe("Workload drain manager", func() {
+	var (
+		drainMgr     *workloadDrainManager
+		filterTable  *mockTable
+		ruleRenderer rules.RuleRenderer
+		drainFileErr error
+		flows        []*netlink.ConntrackFlow
+		conntrackErr error
+		newMgrFn     func(enabled bool) *workloadDrainManager
+	)
+
+	wlID := proto.WorkloadEndpointID{
+		OrchestratorId: "k8s",
+		WorkloadId:     "pod-11",
+		EndpointId:     "endpoint-id-11",
+	}
+
+	drainingChain := &iptables.Chain{
+		Name: rules.ChainWorkloadDrain,
+		Rules: []iptables.Rule{{
+			Match:   iptables.Match().ConntrackState("NEW"),
+			Action:  iptables.RejectAction{},
+			Comment: []string{"Reject new workload connections while draining"},
+		}},
+	}
+	notDrainingChain := &iptables.Chain{
+		Name: rules.ChainWorkloadDrain,
+	}
+
+	flow := func(src, dst, replySrc string) *netlink.ConntrackFlow {
+		f := &netlink.ConntrackFlow{}
+		f.Forward.SrcIP = net.ParseIP(src)
+		f.Forward.DstIP = net.ParseIP(dst)
+		f.Reverse.SrcIP = net.ParseIP(replySrc)
+		f.Reverse.DstIP = net.ParseIP(src)
+		return f
+	}
+
+	BeforeEach(func() {
+		filterTable = newMockTable("filter")
+		ruleRenderer = rules.NewRenderer(rules.Config{
+			IPSetConfigV4: ipsets.NewIPVersionConfig(
+				ipsets.IPFamilyV4,
+				"cali",
+				nil,
+				nil,
+			),
+			IptablesMarkPass:            0x1,
+			IptablesMarkAccept:          0x2,
+			IptablesMarkScratch0:        0x4,
+			IptablesMarkScratch1:        0x8,
+			IptablesMarkEndpoint:        0x11110000,
+			WorkloadDrainSupportEnabled: true,
+		})
+		drainFileErr = os.ErrNotExist
+		flows = nil
+		conntrackErr = nil
+		newMgrFn = func(enabled bool) *workloadDrainManager {
+			m := newWorkloadDrainManagerWithShims([]iptablesTable{filterTable}, ruleRenderer, []uint8{4},
+				enabled, "/var/run/calico/drain",
+				func(name string) (os.FileInfo, error) {
+					Expect(name).To(Equal("/var/run/calico/drain"))
+					if drainFileErr != nil {
+						return nil, drainFileErr
+					}
+					return fakeFileInfo{name: "drain", mode: 0644}, nil
+				},
+				func(family netlink.InetFamily) ([]*netlink.ConntrackFlow, error) {
+					Expect(family).To(BeEquivalentTo(netlink.FAMILY_V4))
+					return flows, conntrackErr
+				})
+			m.OnUpdate(&proto.WorkloadEndpointUpdate{
+				Id: &wlID,
+				Endpoint: &proto.WorkloadEndpoint{
+					Ipv4Nets: []string{"10.0.240.10/32"},
+				},
+			})
+			return m
+		}
+		flows = []*netlink.ConntrackFlow{
+			// Connection to the workload via a service IP.
+			flow("10.0.0.1", "10.96.0.10", "10.0.240.10"),
+			// Connection from the workload.
+			flow("10.0.240.10", "8.8.8.8", "8.8.8.8"),
+			// Unrelated connection.
+			flow("10.0.0.1", "10.0.0.2", "10.0.0.2"),
+		}
+	})
+
+	Describe("with drain mode disabled", func() {
+		BeforeEach(func() {
+			drainMgr = newMgrFn(false)
+			Expect(drainMgr.CompleteDeferredWork()).To(Succeed())
+		})
+
+		It("should not block new connections", func() {
+			Expect(drainMgr.IsDraining()).To(BeFalse())
+			filterTable.checkChains([][]*iptables.Chain{{notDrainingChain}})
+		})
+
+		It("should drain when the drain file appears, and stop when it is removed", func() {
+			drainFileErr = nil
+			Expect(drainMgr.CompleteDeferredWork()).To(Succeed())
+			Expect(drainMgr.IsDraining()).To(BeFalse(), "should only re-check the file when polled")
+
+			drainMgr.QueuePoll()
+			Expect(drainMgr.CompleteDeferredWork()).To(Succeed())
+			Expect(drainMgr.IsDraining()).To(BeTrue())
+			Expect(drainMgr.ActiveConnections()).To(Equal(2))
+			filterTable.checkChains([][]*iptables.Chain{{drainingChain}})
+
+			drainFileErr = os.ErrNotExist
+			drainMgr.QueuePoll()
+			Expect(drainMgr.CompleteDeferredWork()).To(Succeed())
+			Expect(drainMgr.IsDraining()).To(BeFalse())
+			Expect(drainMgr.ActiveConnections()).To(Equal(0))
+			filterTable.checkChains([][]*iptables.Chain{{notDrainingChain}})
+		})
+
+		It("should keep its state if the drain file can't be checked", func() {
+			drainFileErr = errors.New("dummy error")
+			drainMgr.QueuePoll()
+			Expect(drainMgr.CompleteDeferredWork()).To(Succeed())
+			Expect(drainMgr.IsDraining()).To(BeFalse())
+		})
+	})
+
+	Describe("with drain mode enabled", func() {
+		BeforeEach(func() {
+			drainMgr = newMgrFn(true)
+			Expect(drainMgr.CompleteDeferredWork()).To(Succeed())
+		})
+
+		It("should reject new connections", func() {
+			Expect(drainMgr.IsDraining()).To(BeTrue())
+			filterTable.checkChains([][]*iptables.Chain{{drainingChain}})
+		})
+
+		It("should count the connections for workload IPs", func() {
+			Expect(drainMgr.ActiveConnections()).To(Equal(2))
+		})
+
+		It("should update the count when connections close", func() {
+			flows = flows[:1]
+			drainMgr.QueuePoll()
+			Expect(drainMgr.CompleteDeferredWork()).To(Succeed())
+			Expect(drainMgr.ActiveConnections()).To(Equal(1))
+		})
+
+		It("should stop counting connections for removed workloads", func() {
+			drainMgr.OnUpdate(&proto.WorkloadEndpointRemove{Id: &wlID})
+			drainMgr.QueuePoll()
+			Expect(drainMgr.CompleteDeferredWork()).To(Succeed())
+			Expect(drainMgr.ActiveConnections()).To(Equal(0))
+		})
+
+		It("should keep the previous count if conntrack can't be read", func() {
+			conntrackErr = errors.New("dummy error")
+			flows = nil
+			drainMgr.QueuePoll()
+			Expect(drainMgr.CompleteDeferredWork()).To(Succeed())
+			Expect(drainMgr.ActiveConnections()).To(Equal(2))
+		})
+	})
+})
@@ -0,0 +1,111 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	"net"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/rules"
+)
+
+// The workload MAC manager stops workloads from spoofing MAC addresses.  Workload interfaces are
+// routed rather than bridged but a privileged workload can still send frames from another MAC
+// (for example, to poison the neighbour caches of the host or other workloads).  The manager
+// maintains a chain in the raw table that drops frames from each workload interface that don't
+// come from the MAC address that the datastore has for the workload.
+//
+// Whether a workload is checked defaults to enforceByDefault and can be overridden per workload
+// with the EnforceMACLabel label.  Workloads without a MAC in the datastore aren't checked.
+type workloadMACManager struct {
+	// Our dependencies.
+	rawTable         iptablesTable
+	ruleRenderer     rules.RuleRenderer
+	enforceByDefault bool
+
+	// Internal state.
+	workloads map[proto.WorkloadEndpointID]*proto.WorkloadEndpoint
+	dirty     bool
+}
+
+func newWorkloadMACManager(
+	rawTable iptablesTable,
+	ruleRenderer rules.RuleRenderer,
+	enforceByDefault bool,
+) *workloadMACManager {
+	return &workloadMACManager{
+		rawTable:         rawTable,
+		ruleRenderer:     ruleRenderer,
+		enforceByDefault: enforceByDefault,
+		workloads:        map[proto.WorkloadEndpointID]*proto.WorkloadEndpoint{},
+		dirty:            true,
+	}
+}
+
+func (m *workloadMACManager) OnUpdate(protoBufMsg interface{}) {
+	switch msg := protoBufMsg.(type) {
+	case *proto.WorkloadEndpointUpdate:
+		m.workloads[*msg.Id] = msg.Endpoint
+		m.dirty = true
+	case *proto.WorkloadEndpointRemove:
+		if _, ok := m.workloads[*msg.Id]; ok {
+			delete(m.workloads, *msg.Id)
+			m.dirty = true
+		}
+	}
+}
+
+func (m *workloadMACManager) CompleteDeferredWork() error {
+	if !m.dirty {
+		return nil
+	}
+	var macs []rules.WorkloadMAC
+	for id, ep := range m.workloads {
+		logCxt := log.WithField("workload", id)
+		enforce := m.enforceByDefault
+		if ep.EnforceMac != "" {
+			override, err := strconv.ParseBool(ep.EnforceMac)
+			if err != nil {
+				logCxt.WithField("value", ep.EnforceMac).Warn(
+					"Ignoring workload's unparsable MAC enforcement override.")
+			} else {
+				enforce = override
+			}
+		}
+		if !enforce {
+			continue
+		}
+		if ep.Mac == "" {
+			logCxt.Debug("Workload has no MAC, not enforcing it.")
+			continue
+		}
+		mac, err := net.ParseMAC(ep.Mac)
+		if err != nil {
+			logCxt.WithError(err).WithField("mac", ep.Mac).Warn(
+				"Workload has unparsable MAC, not enforcing it.")
+			continue
+		}
+		macs = append(macs, rules.WorkloadMAC{
+			IfaceName: ep.Name,
+			MAC:       mac.String(),
+		})
+	}
+	m.rawTable.UpdateChain(m.ruleRenderer.WorkloadMACCheckChain(macs))
+	m.dirty = false
+	return nil
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package intdataplane
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/ipsets"
+	"github.com/projectcalico/felix/iptables"
+	"github.com/projectcalico/felix/proto"
+	"github.com/projectcalico/felix/rules"
+)
+
+var _ = Describe("Workload MAC manager", func() {
+	var (
+		macMgr       *workloadMACManager
+		rawTable     *mockTable
+		ruleRenderer rules.RuleRenderer
+	)
+
+	wlID1 := proto.WorkloadEndpointID{
+		OrchestratorId: "k8s",
+		WorkloadId:     "pod-11",
+		EndpointId:     "endpoint-id-11",
+	}
+	wlID2 := proto.WorkloadEndpointID{
+		OrchestratorId: "k8s",
+		WorkloadId:     "pod-12",
+		EndpointId:     "endpoint-id-12",
+	}
+	wlID3 := proto.WorkloadEndpointID{
+		OrchestratorId: "k8s",
+		WorkloadId:     "pod-13",
+		EndpointId:     "endpoint-id-13",
+	}
+
+	dropRule := func(iface, mac string) iptables.Rule {
+		return iptables.Rule{
+			Match:   iptables.Match().InInterface(iface).NotSourceMAC(mac),
+			Action:  iptables.DropAction{},
+			Comment: []string{"Drop frames with spoofed MAC"},
+		}
+	}
+
+	addWorkload := func(id proto.WorkloadEndpointID, iface, mac, enforce string) {
+		macMgr.OnUpdate(&proto.WorkloadEndpointUpdate{
+			Id: &id,
+			Endpoint: &proto.WorkloadEndpoint{
+				Name:       iface,
+				Mac:        mac,
+				Ipv4Nets:   []string{"10.0.240.10/32"},
+				EnforceMac: enforce,
+			},
+		})
+	}
+
+	newManager := func(enforceByDefault bool) {
+		rawTable = newMockTable("raw")
+		ruleRenderer = rules.NewRenderer(rules.Config{
+			IPSetConfigV4: ipsets.NewIPVersionConfig(
+				ipsets.IPFamilyV4,
+				"cali",
+				nil,
+				nil,
+			),
+			IptablesMarkPass:              0x1,
+			IptablesMarkAccept:            0x2,
+			IptablesMarkScratch0:          0x4,
+			IptablesMarkScratch1:          0x8,
+			IptablesMarkEndpoint:          0x11110000,
+			WorkloadMACEnforcementEnabled: true,
+		})
+		macMgr = newWorkloadMACManager(rawTable, ruleRenderer, enforceByDefault)
+	}
+
+	Describe("enforcing by default", func() {
+		BeforeEach(func() {
+			newManager(true)
+		})
+
+		It("should program an empty chain with no workloads", func() {
+			Expect(macMgr.CompleteDeferredWork()).To(Succeed())
+			rawTable.checkChains([][]*iptables.Chain{{{
+				Name: rules.ChainWorkloadMACCheck,
+			}}})
+		})
+
+		It("should check all workloads that have a valid MAC unless they opt out", func() {
+			addWorkload(wlID1, "cali1", "EE:EE:EE:EE:EE:01", "")
+			addWorkload(wlID2, "cali2", "ee:ee:ee:ee:ee:02", "false")
+			addWorkload(wlID3, "cali3", "", "")
+			Expect(macMgr.CompleteDeferredWork()).To(Succeed())
+			rawTable.checkChains([][]*iptables.Chain{{{
+				Name:  rules.ChainWorkloadMACCheck,
+				Rules: []iptables.Rule{dropRule("cali1", "ee:ee:ee:ee:ee:01")},
+			}}})
+		})
+
+		It("should stop checking a workload when it is removed", func() {
+			addWorkload(wlID1, "cali1", "ee:ee:ee:ee:ee:01", "")
+			Expect(macMgr.CompleteDeferredWork()).To(Succeed())
+			macMgr.OnUpdate(&proto.WorkloadEndpointRemove{Id: &wlID1})
+			Expect(macMgr.CompleteDeferredWork()).To(Succeed())
+			rawTable.checkChains([][]*iptables.Chain{{{
+				Name: rules.ChainWorkloadMACCheck,
+			}}})
+		})
+	})
+
+	Describe("enforcing per endpoint", func() {
+		BeforeEach(func() {
+			newManager(false)
+		})
+
+		It("should only check workloads that opt in", func() {
+			addWorkload(wlID1, "cali1", "ee:ee:ee:ee:ee:01", "")
+			addWorkload(wlID2, "cali2", "ee:ee:ee:ee:ee:02", "true")
+			addWorkload(wlID3, "cali3", "ee:ee:ee:ee:ee:03", "bogus")
+			Expect(macMgr.CompleteDeferredWork()).To(Succeed())
+			rawTable.checkChains([][]*iptables.Chain{{{
+				Name:  rules.ChainWorkloadMACCheck,
+				Rules: []iptables.Rule{dropRule("cali2", "ee:ee:ee:ee:ee:02")},
+			}}})
+		})
+	})
+})
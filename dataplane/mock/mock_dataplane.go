@@ -332,7 +332,15 @@ func (d *MockDataplane) OnEvent(event interface{}) {
 		}
 		id := workloadId(*event.Id)
 		d.endpointToPolicyOrder[id.String()] = tierInfos
-		d.endpointToUntrackedPolicyOrder[id.String()] = []TierInfo{}
+
+		uTiers := event.Endpoint.UntrackedTiers
+		uTierInfos := make([]TierInfo, len(uTiers))
+		for i, tier := range uTiers {
+			uTierInfos[i].Name = tier.Name
+			uTierInfos[i].IngressPolicyNames = tier.IngressPolicies
+			uTierInfos[i].EgressPolicyNames = tier.EgressPolicies
+		}
+		d.endpointToUntrackedPolicyOrder[id.String()] = uTierInfos
 		d.endpointToPreDNATPolicyOrder[id.String()] = []TierInfo{}
 		d.endpointToAllPolicyIDs[id.String()] = allPolsIDs
 
@@ -0,0 +1,78 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recorder
+
+import (
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// dataplaneDriver matches dataplane.DataplaneDriver, which we can't import without pulling in
+// all the dataplane implementations.
+type dataplaneDriver interface {
+	SendMessage(msg interface{}) error
+	RecvMessage() (msg interface{}, err error)
+}
+
+// RecordingDriver wraps a dataplane driver, recording the messages that are sent to it.
+// Recording is best-effort: if writing to the recording fails, we log and stop recording but
+// the messages are still passed on to the driver.
+type RecordingDriver struct {
+	dataplaneDriver
+
+	file      *os.File
+	writer    *Writer
+	recording bool
+	now       func() time.Time
+}
+
+// NewRecordingDriver creates the recording file, overwriting any existing file, and returns a
+// driver that records messages to it before passing them on to the given driver.
+func NewRecordingDriver(driver dataplaneDriver, fileName string) (*RecordingDriver, error) {
+	f, err := os.OpenFile(fileName, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	w, err := NewWriter(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	log.WithField("file", fileName).Warn(
+		"Recording messages sent to the dataplane driver; this is a debug feature, the file grows without limit.")
+	return &RecordingDriver{
+		dataplaneDriver: driver,
+		file:            f,
+		writer:          w,
+		recording:       true,
+		now:             time.Now,
+	}, nil
+}
+
+func (d *RecordingDriver) SendMessage(msg interface{}) error {
+	if d.recording {
+		err := d.writer.Write(d.now(), msg)
+		if err == ErrUnknownMessage {
+			log.WithField("msg", msg).Debug("Not recording internal message.")
+		} else if err != nil {
+			log.WithError(err).Error("Failed to write to dataplane recording, stopping recording.")
+			d.recording = false
+			_ = d.file.Close()
+		}
+	}
+	return d.dataplaneDriver.SendMessage(msg)
+}
@@ -0,0 +1,191 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recorder
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/proto"
+)
+
+type mockDriver struct {
+	sent []interface{}
+}
+
+func (d *mockDriver) SendMessage(msg interface{}) error {
+	d.sent = append(d.sent, msg)
+	return nil
+}
+
+func (d *mockDriver) RecvMessage() (interface{}, error) {
+	return nil, io.EOF
+}
+
+var (
+	t0   = time.Unix(1600000000, 0)
+	msgs = []interface{}{
+		&proto.ConfigUpdate{Config: map[string]string{"BPFEnabled": "true"}},
+		&proto.IPSetUpdate{Id: "s:abcd", Members: []string{"10.0.0.1"}},
+		&proto.InSync{},
+	}
+	notAProtoMsg = struct{}{}
+)
+
+var _ = Describe("Dataplane recordings", func() {
+	var buf *bytes.Buffer
+
+	BeforeEach(func() {
+		buf = &bytes.Buffer{}
+		w, err := NewWriter(buf)
+		Expect(err).NotTo(HaveOccurred())
+		for i, msg := range msgs {
+			Expect(w.Write(t0.Add(time.Duration(i)*time.Second), msg)).To(Succeed())
+		}
+	})
+
+	It("should read back what was written", func() {
+		r, err := NewReader(buf)
+		Expect(err).NotTo(HaveOccurred())
+		for i, msg := range msgs {
+			rec, err := r.Next()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rec.Time.Equal(t0.Add(time.Duration(i) * time.Second))).To(BeTrue())
+			Expect(rec.SequenceNumber).To(BeNumerically("==", i))
+			Expect(rec.Msg).To(Equal(msg))
+		}
+		_, err = r.Next()
+		Expect(err).To(Equal(io.EOF))
+	})
+
+	It("should reject messages that aren't part of the dataplane API", func() {
+		w, err := NewWriter(&bytes.Buffer{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(w.Write(t0, notAProtoMsg)).To(Equal(ErrUnknownMessage))
+	})
+
+	It("should reject a file that isn't a recording", func() {
+		_, err := NewReader(bytes.NewBufferString("not a recording, just some text\n"))
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should report a truncated recording", func() {
+		truncated := bytes.NewBuffer(buf.Bytes()[:buf.Len()-1])
+		r, err := NewReader(truncated)
+		Expect(err).NotTo(HaveOccurred())
+		_, err = r.Next()
+		Expect(err).NotTo(HaveOccurred())
+		_, err = r.Next()
+		Expect(err).NotTo(HaveOccurred())
+		_, err = r.Next()
+		Expect(err).To(Equal(io.ErrUnexpectedEOF))
+	})
+
+	Describe("replay", func() {
+		var (
+			driver *mockDriver
+			sleeps []time.Duration
+		)
+
+		replay := func(data []byte, speed float64) int {
+			r, err := NewReader(bytes.NewBuffer(data))
+			Expect(err).NotTo(HaveOccurred())
+			replayer := NewReplayer(r, driver, speed)
+			replayer.sleep = func(d time.Duration) {
+				sleeps = append(sleeps, d)
+			}
+			n, err := replayer.Replay()
+			Expect(err).NotTo(HaveOccurred())
+			return n
+		}
+
+		BeforeEach(func() {
+			driver = &mockDriver{}
+			sleeps = nil
+		})
+
+		It("should send all the messages with the recorded gaps", func() {
+			Expect(replay(buf.Bytes(), 1)).To(Equal(3))
+			Expect(driver.sent).To(Equal(msgs))
+			Expect(sleeps).To(Equal([]time.Duration{time.Second, time.Second}))
+		})
+
+		It("should scale the gaps by the speed", func() {
+			replay(buf.Bytes(), 4)
+			Expect(sleeps).To(Equal([]time.Duration{250 * time.Millisecond, 250 * time.Millisecond}))
+		})
+
+		It("should not sleep with speed 0", func() {
+			replay(buf.Bytes(), 0)
+			Expect(sleeps).To(BeEmpty())
+		})
+
+		It("should replay a truncated recording up to the cut off", func() {
+			Expect(replay(buf.Bytes()[:buf.Len()-1], 0)).To(Equal(2))
+			Expect(driver.sent).To(Equal(msgs[:2]))
+		})
+	})
+
+	Describe("RecordingDriver", func() {
+		var (
+			dir    string
+			driver *mockDriver
+		)
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "recorder")
+			Expect(err).NotTo(HaveOccurred())
+			driver = &mockDriver{}
+		})
+
+		AfterEach(func() {
+			_ = os.RemoveAll(dir)
+		})
+
+		It("should record and pass on messages", func() {
+			fileName := filepath.Join(dir, "felix.rec")
+			rd, err := NewRecordingDriver(driver, fileName)
+			Expect(err).NotTo(HaveOccurred())
+			rd.now = func() time.Time { return t0 }
+			for _, msg := range msgs {
+				Expect(rd.SendMessage(msg)).To(Succeed())
+			}
+			// Internal messages should be passed on but not recorded.
+			Expect(rd.SendMessage(notAProtoMsg)).To(Succeed())
+			Expect(driver.sent).To(Equal(append(msgs, notAProtoMsg)))
+
+			f, err := os.Open(fileName)
+			Expect(err).NotTo(HaveOccurred())
+			defer f.Close()
+			r, err := NewReader(f)
+			Expect(err).NotTo(HaveOccurred())
+			for _, msg := range msgs {
+				rec, err := r.Next()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(rec.Msg).To(Equal(msg))
+			}
+			_, err = r.Next()
+			Expect(err).To(Equal(io.EOF))
+		})
+	})
+})
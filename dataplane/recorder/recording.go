@@ -0,0 +1,140 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package recorder records the stream of messages that Felix sends to its dataplane driver and
+// replays such recordings, so that dataplane problems can be reproduced offline.
+//
+// A recording is a header followed by one frame per message.  Each frame is the time that the
+// message was sent (nanoseconds since the epoch) and the length of the message, both as 64-bit
+// little-endian integers, followed by the message, serialised as a ToDataplane envelope.
+package recorder
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	pb "github.com/gogo/protobuf/proto"
+
+	extdataplane "github.com/projectcalico/felix/dataplane/external"
+	"github.com/projectcalico/felix/proto"
+)
+
+const (
+	header = "calico-felix dataplane recording v1\n"
+
+	// maxMessageLen guards against trying to allocate a huge buffer when reading a corrupt file.
+	maxMessageLen = 256 * 1024 * 1024
+)
+
+// ErrUnknownMessage is returned by Writer.Write for messages that it can't record.
+var ErrUnknownMessage = errors.New("message is not a dataplane message")
+
+// Record is a message read back from a recording.
+type Record struct {
+	Time           time.Time
+	SequenceNumber uint64
+	Msg            interface{}
+}
+
+// Writer writes messages to a recording.
+type Writer struct {
+	w             io.Writer
+	nextSeqNumber uint64
+}
+
+// NewWriter writes the recording header to w and returns a Writer for the messages.
+func NewWriter(w io.Writer) (*Writer, error) {
+	if _, err := io.WriteString(w, header); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w}, nil
+}
+
+// Write records the message, which was sent at time t.  Returns ErrUnknownMessage for messages
+// that aren't part of the dataplane API.
+func (w *Writer) Write(t time.Time, msg interface{}) error {
+	envelope, ok := extdataplane.WrapToDataplane(msg)
+	if !ok {
+		return ErrUnknownMessage
+	}
+	envelope.SequenceNumber = w.nextSeqNumber
+	w.nextSeqNumber++
+	data, err := pb.Marshal(envelope)
+	if err != nil {
+		return err
+	}
+
+	// Write the whole frame at once so that a failure can't leave a partial frame.
+	var frame bytes.Buffer
+	var prefix [16]byte
+	binary.LittleEndian.PutUint64(prefix[:8], uint64(t.UnixNano()))
+	binary.LittleEndian.PutUint64(prefix[8:], uint64(len(data)))
+	frame.Write(prefix[:])
+	frame.Write(data)
+	_, err = frame.WriteTo(w.w)
+	return err
+}
+
+// Reader reads messages back from a recording.
+type Reader struct {
+	r io.Reader
+}
+
+// NewReader checks the recording header in r and returns a Reader for the messages.
+func NewReader(r io.Reader) (*Reader, error) {
+	buf := make([]byte, len(header))
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, fmt.Errorf("failed to read recording header: %w", err)
+	}
+	if string(buf) != header {
+		return nil, errors.New("not a dataplane recording")
+	}
+	return &Reader{r: r}, nil
+}
+
+// Next returns the next message in the recording or io.EOF at the end of the recording.  A
+// recording that was cut off part way through a frame (for example, because Felix was killed)
+// returns io.ErrUnexpectedEOF.
+func (r *Reader) Next() (*Record, error) {
+	var prefix [16]byte
+	if _, err := io.ReadFull(r.r, prefix[:]); err != nil {
+		return nil, err
+	}
+	timestamp := int64(binary.LittleEndian.Uint64(prefix[:8]))
+	length := binary.LittleEndian.Uint64(prefix[8:])
+	if length > maxMessageLen {
+		return nil, fmt.Errorf("message too long (%d bytes), recording is corrupt", length)
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(r.r, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	var envelope proto.ToDataplane
+	if err := pb.Unmarshal(data, &envelope); err != nil {
+		return nil, fmt.Errorf("failed to parse message: %w", err)
+	}
+	return &Record{
+		Time:           time.Unix(0, timestamp),
+		SequenceNumber: envelope.SequenceNumber,
+		Msg:            extdataplane.UnwrapToDataplane(&envelope),
+	}, nil
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package recorder
+
+import (
+	"io"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Replayer sends the messages from a recording to a dataplane driver.
+type Replayer struct {
+	reader *Reader
+	driver dataplaneDriver
+	speed  float64
+	sleep  func(time.Duration)
+}
+
+// NewReplayer returns a Replayer that replays the recording at speed times the recorded rate; a
+// speed of 0 sends the messages as fast as the driver accepts them.
+func NewReplayer(reader *Reader, driver dataplaneDriver, speed float64) *Replayer {
+	return &Replayer{
+		reader: reader,
+		driver: driver,
+		speed:  speed,
+		sleep:  time.Sleep,
+	}
+}
+
+// Replay sends all the messages in the recording to the driver, returning the number of messages
+// that were sent.  A recording that was cut off part way through a message is replayed up to that
+// point without error.
+func (r *Replayer) Replay() (int, error) {
+	var lastTime time.Time
+	numSent := 0
+	for {
+		rec, err := r.reader.Next()
+		if err == io.EOF {
+			return numSent, nil
+		} else if err == io.ErrUnexpectedEOF {
+			log.Warn("Recording ends part way through a message, ignoring the partial message.")
+			return numSent, nil
+		} else if err != nil {
+			return numSent, err
+		}
+		if r.speed > 0 && !lastTime.IsZero() {
+			if gap := rec.Time.Sub(lastTime); gap > 0 {
+				r.sleep(time.Duration(float64(gap) / r.speed))
+			}
+		}
+		lastTime = rec.Time
+		if rec.Msg == nil {
+			log.WithField("seqNo", rec.SequenceNumber).Warn("Skipping empty message in recording.")
+			continue
+		}
+		log.WithFields(log.Fields{
+			"seqNo":    rec.SequenceNumber,
+			"recorded": rec.Time,
+			"msg":      rec.Msg,
+		}).Debug("Replaying message.")
+		if err := r.driver.SendMessage(rec.Msg); err != nil {
+			return numSent, err
+		}
+		numSent++
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dropcapture
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxSnapLen is the snap length that we declare in the pcap header.  Records are truncated by
+// the kernel to the configured snap length so this only needs to be an upper bound.
+const maxSnapLen = 65535
+
+// ServeHTTP serves the captured records as JSON or, with ?format=pcap, as a pcap file of raw IP
+// packets (with the annotations omitted).
+func (r *Ring) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	records := r.Records()
+	if req.URL.Query().Get("format") == "pcap" {
+		w.Header().Set("Content-Type", "application/vnd.tcpdump.pcap")
+		if err := writePcap(w, records); err != nil {
+			log.WithError(err).Warn("Failed to write drop capture pcap.")
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if records == nil {
+		records = []Record{}
+	}
+	if err := json.NewEncoder(w).Encode(records); err != nil {
+		log.WithError(err).Warn("Failed to write drop capture JSON.")
+	}
+}
+
+func writePcap(w http.ResponseWriter, records []Record) error {
+	pw := pcapgo.NewWriter(w)
+	if err := pw.WriteFileHeader(maxSnapLen, layers.LinkTypeRaw); err != nil {
+		return err
+	}
+	for _, rec := range records {
+		ci := gopacket.CaptureInfo{
+			Timestamp:     rec.Timestamp,
+			CaptureLength: len(rec.Headers),
+			Length:        len(rec.Headers),
+		}
+		if err := pw.WritePacket(ci, rec.Headers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dropcapture
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+
+	"github.com/projectcalico/felix/rules"
+)
+
+// Constants from linux/netfilter/nfnetlink_log.h.
+const (
+	nfnlSubsysULOG = 4
+
+	nfulnlMsgPacket = 0
+	nfulnlMsgConfig = 1
+
+	nfulaCfgCmd  = 1
+	nfulaCfgMode = 2
+
+	nfulnlCfgCmdBind     = 1
+	nfulnlCfgCmdPfBind   = 3
+	nfulnlCfgCmdPfUnbind = 4
+
+	nfulnlCopyPacket = 2
+
+	nfulaTimestamp  = 3
+	nfulaIfindexIn  = 4
+	nfulaIfindexOut = 5
+	nfulaPayload    = 9
+	nfulaPrefix     = 10
+
+	nlaTypeMask = 0x3fff
+
+	ifaceCacheTTL = 30 * time.Second
+	retryInterval = 5 * time.Second
+)
+
+// NFLOGReader listens on an NFLOG group for the packets logged by the drop capture rules and
+// adds them to a Ring.
+type NFLOGReader struct {
+	group   uint16
+	snapLen int
+	ring    *Ring
+
+	ifaceNames      map[int]string
+	ifaceCacheReset time.Time
+}
+
+func NewNFLOGReader(group uint16, snapLen int, ring *Ring) *NFLOGReader {
+	return &NFLOGReader{
+		group:   group,
+		snapLen: snapLen,
+		ring:    ring,
+	}
+}
+
+// Start starts a background goroutine that reads from the NFLOG group, reconnecting on failure.
+func (r *NFLOGReader) Start() {
+	go r.loop()
+}
+
+func (r *NFLOGReader) loop() {
+	for {
+		err := r.readUntilError()
+		log.WithError(err).WithField("group", r.group).Warn(
+			"Failed to read dropped packets from NFLOG group, will retry.")
+		time.Sleep(retryInterval)
+	}
+}
+
+func (r *NFLOGReader) readUntilError() error {
+	sock, err := nl.Subscribe(unix.NETLINK_NETFILTER)
+	if err != nil {
+		return err
+	}
+	defer sock.Close()
+
+	// Take over the group for both IP families and ask for the start of each packet.
+	for _, family := range []uint8{unix.AF_INET, unix.AF_INET6} {
+		for _, cmd := range []uint8{nfulnlCfgCmdPfUnbind, nfulnlCfgCmdPfBind} {
+			if err := sock.Send(r.configRequest(family, 0, nfulaCfgCmd, []byte{cmd})); err != nil {
+				return err
+			}
+		}
+	}
+	if err := sock.Send(r.configRequest(unix.AF_UNSPEC, r.group, nfulaCfgCmd, []byte{nfulnlCfgCmdBind})); err != nil {
+		return err
+	}
+	mode := make([]byte, 6)
+	binary.BigEndian.PutUint32(mode, uint32(r.snapLen))
+	mode[4] = nfulnlCopyPacket
+	if err := sock.Send(r.configRequest(unix.AF_UNSPEC, r.group, nfulaCfgMode, mode)); err != nil {
+		return err
+	}
+	log.WithField("group", r.group).Info("Listening for dropped packets.")
+
+	for {
+		msgs, _, err := sock.Receive()
+		if errors.Is(err, unix.ENOBUFS) {
+			// The kernel dropped some messages because we didn't keep up; not fatal.
+			log.Debug("NFLOG socket overflowed, some dropped packets weren't captured.")
+			continue
+		} else if err != nil {
+			return err
+		}
+		for _, m := range msgs {
+			switch m.Header.Type {
+			case unix.NLMSG_ERROR:
+				// Either the ACK for one of our config messages or a failure.
+				if len(m.Data) >= 4 {
+					if errno := int32(nl.NativeEndian().Uint32(m.Data[:4])); errno != 0 {
+						return fmt.Errorf("NFLOG config failed: %w", syscall.Errno(-errno))
+					}
+				}
+			case nfnlSubsysULOG<<8 | nfulnlMsgPacket:
+				rec, err := parsePacketMsg(m.Data)
+				if err != nil {
+					log.WithError(err).Debug("Ignoring unparsable NFLOG message.")
+					continue
+				}
+				if rec == nil {
+					// Not one of ours.
+					continue
+				}
+				rec.InIface = r.ifaceName(int(rec.inIndex))
+				rec.OutIface = r.ifaceName(int(rec.outIndex))
+				r.ring.Add(rec.Record)
+			}
+		}
+	}
+}
+
+func (r *NFLOGReader) configRequest(family uint8, group uint16, attrType int, data []byte) *nl.NetlinkRequest {
+	req := nl.NewNetlinkRequest(nfnlSubsysULOG<<8|nfulnlMsgConfig, unix.NLM_F_ACK)
+	req.AddData(&nl.Nfgenmsg{
+		NfgenFamily: family,
+		Version:     unix.NFNETLINK_V0,
+		ResId:       htons(group),
+	})
+	req.AddData(nl.NewRtAttr(attrType, data))
+	return req
+}
+
+func (r *NFLOGReader) ifaceName(index int) string {
+	if index == 0 {
+		return ""
+	}
+	if r.ifaceNames == nil || time.Since(r.ifaceCacheReset) > ifaceCacheTTL {
+		r.ifaceNames = map[int]string{}
+		r.ifaceCacheReset = time.Now()
+	}
+	if name, ok := r.ifaceNames[index]; ok {
+		return name
+	}
+	name := ""
+	if iface, err := net.InterfaceByIndex(index); err == nil {
+		name = iface.Name
+	}
+	r.ifaceNames[index] = name
+	return name
+}
+
+// htons converts to network byte order, which the kernel expects for the nfgenmsg resource ID.
+func htons(v uint16) uint16 {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, v)
+	return nl.NativeEndian().Uint16(b)
+}
+
+type parsedPacket struct {
+	Record
+	inIndex  uint32
+	outIndex uint32
+}
+
+// parsePacketMsg parses the body of an NFLOG packet message.  It returns nil if the packet wasn't
+// logged by one of our drop capture rules.
+func parsePacketMsg(data []byte) (*parsedPacket, error) {
+	if len(data) < nl.SizeofNfgenmsg {
+		return nil, errors.New("message too short")
+	}
+	attrs, err := nl.ParseRouteAttr(data[nl.SizeofNfgenmsg:])
+	if err != nil {
+		return nil, err
+	}
+	p := &parsedPacket{}
+	var prefix string
+	for _, a := range attrs {
+		switch a.Attr.Type & nlaTypeMask {
+		case nfulaPrefix:
+			prefix = string(bytes.TrimRight(a.Value, "\x00"))
+		case nfulaPayload:
+			p.Headers = a.Value
+		case nfulaTimestamp:
+			if len(a.Value) >= 16 {
+				sec := binary.BigEndian.Uint64(a.Value[:8])
+				usec := binary.BigEndian.Uint64(a.Value[8:16])
+				p.Timestamp = time.Unix(int64(sec), int64(usec)*1000)
+			}
+		case nfulaIfindexIn:
+			if len(a.Value) >= 4 {
+				p.inIndex = binary.BigEndian.Uint32(a.Value)
+			}
+		case nfulaIfindexOut:
+			if len(a.Value) >= 4 {
+				p.outIndex = binary.BigEndian.Uint32(a.Value)
+			}
+		}
+	}
+	if !strings.HasPrefix(prefix, rules.DropCapturePrefix) {
+		return nil, nil
+	}
+	p.Reason = strings.TrimPrefix(prefix, rules.DropCapturePrefix)
+	if p.Timestamp.IsZero() {
+		p.Timestamp = time.Now()
+	}
+	return p, nil
+}
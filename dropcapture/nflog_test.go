@@ -0,0 +1,76 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package dropcapture
+
+import (
+	"encoding/binary"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vishvananda/netlink/nl"
+	"golang.org/x/sys/unix"
+)
+
+var _ = Describe("NFLOG message parsing", func() {
+	be32 := func(v uint32) []byte {
+		b := make([]byte, 4)
+		binary.BigEndian.PutUint32(b, v)
+		return b
+	}
+
+	msg := func(prefix string, withTimestamp bool) []byte {
+		data := (&nl.Nfgenmsg{NfgenFamily: unix.AF_INET}).Serialize()
+		data = append(data, nl.NewRtAttr(nfulaPrefix, nl.ZeroTerminated(prefix)).Serialize()...)
+		data = append(data, nl.NewRtAttr(nfulaIfindexIn, be32(3)).Serialize()...)
+		data = append(data, nl.NewRtAttr(nfulaIfindexOut, be32(7)).Serialize()...)
+		if withTimestamp {
+			ts := make([]byte, 16)
+			binary.BigEndian.PutUint64(ts, 1600000000)
+			binary.BigEndian.PutUint64(ts[8:], 500)
+			data = append(data, nl.NewRtAttr(nfulaTimestamp, ts).Serialize()...)
+		}
+		data = append(data, nl.NewRtAttr(nfulaPayload, []byte{0x45, 0, 0, 20, 1, 2, 3}).Serialize()...)
+		return data
+	}
+
+	It("should parse a drop capture message", func() {
+		p, err := parsePacketMsg(msg("DROP|abcdef", true))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(p).NotTo(BeNil())
+		Expect(p.Reason).To(Equal("abcdef"))
+		Expect(p.Headers).To(Equal([]byte{0x45, 0, 0, 20, 1, 2, 3}))
+		Expect(p.Timestamp).To(Equal(time.Unix(1600000000, 500000)))
+		Expect(p.inIndex).To(BeEquivalentTo(3))
+		Expect(p.outIndex).To(BeEquivalentTo(7))
+	})
+
+	It("should default the timestamp if the kernel didn't supply one", func() {
+		p, err := parsePacketMsg(msg("DROP|no-policy-passed", false))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(p.Reason).To(Equal("no-policy-passed"))
+		Expect(p.Timestamp).To(BeTemporally("~", time.Now(), time.Second))
+	})
+
+	It("should ignore packets logged by other rules", func() {
+		p, err := parsePacketMsg(msg("some-other-prefix", true))
+		Expect(err).NotTo(HaveOccurred())
+		Expect(p).To(BeNil())
+	})
+
+	It("should reject a truncated message", func() {
+		_, err := parsePacketMsg([]byte{2, 0})
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,134 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dropcapture keeps a bounded, in-memory record of the packets that were dropped by
+// policy, along with the policy rule (or default action) that dropped each one.  The records can
+// be fetched as JSON or as a pcap file via a debug HTTP endpoint.
+package dropcapture
+
+import (
+	"sync"
+	"time"
+)
+
+// DebugPath is the path of the debug HTTP endpoint that serves the captured records.
+const DebugPath = "/debug/drop-capture"
+
+// Record describes a single dropped packet.
+type Record struct {
+	Timestamp time.Time `json:"timestamp"`
+	// Reason is the rule ID of the policy rule that dropped the packet, or one of the default-drop
+	// reasons defined in the rules package.
+	Reason string `json:"reason"`
+	// Annotation is a human-readable description of Reason, for example the name of the policy.
+	Annotation string `json:"annotation,omitempty"`
+	InIface    string `json:"inIface,omitempty"`
+	OutIface   string `json:"outIface,omitempty"`
+	// InEndpoint and OutEndpoint identify the workloads behind InIface and OutIface, if known.
+	InEndpoint  *Endpoint `json:"inEndpoint,omitempty"`
+	OutEndpoint *Endpoint `json:"outEndpoint,omitempty"`
+	// Headers holds the start of the packet, starting with the IP header.
+	Headers []byte `json:"headers"`
+}
+
+// Endpoint identifies a workload in terms that a human can relate to.
+type Endpoint struct {
+	Workload       string `json:"workload"`
+	Namespace      string `json:"namespace,omitempty"`
+	ServiceAccount string `json:"serviceAccount,omitempty"`
+}
+
+// Ring is a thread-safe, fixed-size ring buffer of Records.  Once full, new records overwrite
+// the oldest ones.
+type Ring struct {
+	lock        sync.Mutex
+	records     []Record
+	next        int
+	full        bool
+	annotations map[string]string
+	endpoints   map[string]*Endpoint
+}
+
+func NewRing(size int) *Ring {
+	if size < 1 {
+		size = 1
+	}
+	return &Ring{
+		records:     make([]Record, size),
+		annotations: map[string]string{},
+		endpoints:   map[string]*Endpoint{},
+	}
+}
+
+// SetRuleAnnotation records the annotation to attach to packets dropped by the given rule ID.
+func (r *Ring) SetRuleAnnotation(ruleID, annotation string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.annotations[ruleID] = annotation
+}
+
+func (r *Ring) RemoveRuleAnnotation(ruleID string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.annotations, ruleID)
+}
+
+// SetIfaceEndpoint records the endpoint to attach to packets that arrive on or leave through the
+// given interface.
+func (r *Ring) SetIfaceEndpoint(ifaceName string, ep Endpoint) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.endpoints[ifaceName] = &ep
+}
+
+func (r *Ring) RemoveIfaceEndpoint(ifaceName string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	delete(r.endpoints, ifaceName)
+}
+
+// Add adds a record to the ring, annotating it if its Reason is a known rule ID and with the
+// endpoints behind its interfaces.  Since rule IDs and endpoints may be removed shortly after a
+// drop, we annotate at capture time rather than when the records are read.
+func (r *Ring) Add(rec Record) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if rec.Annotation == "" {
+		rec.Annotation = r.annotations[rec.Reason]
+	}
+	if rec.InEndpoint == nil && rec.InIface != "" {
+		rec.InEndpoint = r.endpoints[rec.InIface]
+	}
+	if rec.OutEndpoint == nil && rec.OutIface != "" {
+		rec.OutEndpoint = r.endpoints[rec.OutIface]
+	}
+	r.records[r.next] = rec
+	r.next++
+	if r.next == len(r.records) {
+		r.next = 0
+		r.full = true
+	}
+}
+
+// Records returns a copy of the records in the ring, oldest first.
+func (r *Ring) Records() []Record {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	var out []Record
+	if r.full {
+		out = append(out, r.records[r.next:]...)
+	}
+	out = append(out, r.records[:r.next]...)
+	return out
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package dropcapture
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Ring", func() {
+	var ring *Ring
+
+	rec := func(n byte) Record {
+		return Record{
+			Timestamp: time.Unix(1600000000+int64(n), 0).UTC(),
+			Reason:    "rule-id",
+			Headers:   []byte{0x45, n},
+		}
+	}
+
+	BeforeEach(func() {
+		ring = NewRing(3)
+	})
+
+	It("should start empty", func() {
+		Expect(ring.Records()).To(BeEmpty())
+	})
+
+	It("should return records oldest first", func() {
+		ring.Add(rec(1))
+		ring.Add(rec(2))
+		Expect(ring.Records()).To(Equal([]Record{rec(1), rec(2)}))
+	})
+
+	It("should overwrite the oldest records once full", func() {
+		for i := byte(1); i <= 5; i++ {
+			ring.Add(rec(i))
+		}
+		Expect(ring.Records()).To(Equal([]Record{rec(3), rec(4), rec(5)}))
+	})
+
+	It("should annotate records at capture time", func() {
+		ring.SetRuleAnnotation("rule-id", "default/policy-1 inbound rule 2")
+		ring.Add(rec(1))
+		ring.RemoveRuleAnnotation("rule-id")
+		ring.Add(rec(2))
+		records := ring.Records()
+		Expect(records[0].Annotation).To(Equal("default/policy-1 inbound rule 2"))
+		Expect(records[1].Annotation).To(BeEmpty())
+	})
+
+	It("should attach the endpoints behind the interfaces at capture time", func() {
+		ep := Endpoint{Workload: "accounts/billing-1", Namespace: "accounts", ServiceAccount: "billing"}
+		ring.SetIfaceEndpoint("cali1234", ep)
+		r := rec(1)
+		r.InIface = "cali1234"
+		r.OutIface = "eth0"
+		ring.Add(r)
+		ring.RemoveIfaceEndpoint("cali1234")
+		r = rec(2)
+		r.InIface = "cali1234"
+		ring.Add(r)
+		records := ring.Records()
+		Expect(records[0].InEndpoint).To(Equal(&ep))
+		Expect(records[0].OutEndpoint).To(BeNil())
+		Expect(records[1].InEndpoint).To(BeNil())
+	})
+
+	Describe("ServeHTTP", func() {
+		BeforeEach(func() {
+			ring.Add(rec(1))
+			ring.Add(rec(2))
+		})
+
+		It("should serve JSON by default", func() {
+			w := httptest.NewRecorder()
+			ring.ServeHTTP(w, httptest.NewRequest(http.MethodGet, DebugPath, nil))
+			Expect(w.Header().Get("Content-Type")).To(Equal("application/json"))
+			var records []Record
+			Expect(json.Unmarshal(w.Body.Bytes(), &records)).To(Succeed())
+			Expect(records).To(Equal([]Record{rec(1), rec(2)}))
+		})
+
+		It("should serve an empty list rather than null", func() {
+			w := httptest.NewRecorder()
+			NewRing(3).ServeHTTP(w, httptest.NewRequest(http.MethodGet, DebugPath, nil))
+			Expect(w.Body.String()).To(Equal("[]\n"))
+		})
+
+		It("should serve a pcap file on request", func() {
+			w := httptest.NewRecorder()
+			ring.ServeHTTP(w, httptest.NewRequest(http.MethodGet, DebugPath+"?format=pcap", nil))
+			r, err := pcapgo.NewReader(w.Body)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.LinkType()).To(Equal(layers.LinkTypeRaw))
+			data, ci, err := r.ReadPacketData()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data).To(Equal([]byte{0x45, 1}))
+			Expect(ci.Timestamp.Unix()).To(BeEquivalentTo(1600000001))
+			data, _, err = r.ReadPacketData()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data).To(Equal([]byte{0x45, 2}))
+		})
+	})
+})
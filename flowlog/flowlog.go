@@ -0,0 +1,228 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package flowlog turns periodic samples of a conntrack table into flow log records, which
+// summarise the traffic to and from local workloads, and writes them to a sink.
+package flowlog
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ActionAllow is the policy verdict of flows that were sampled from conntrack; only connections
+// that policy allowed get a conntrack entry.
+const ActionAllow = "allow"
+
+// Flow is a connection, as found in a conntrack table.  The destination is the one that the
+// connection was actually made to, after any DNAT.  The counters are the totals so far; they're
+// zero if the conntrack table doesn't count packets.
+type Flow struct {
+	Proto   uint8
+	SrcIP   net.IP
+	SrcPort uint16
+	DstIP   net.IP
+	DstPort uint16
+
+	PacketsOut uint64
+	BytesOut   uint64
+	PacketsIn  uint64
+	BytesIn    uint64
+}
+
+// Endpoint identifies a local workload in terms that a human can relate to.
+type Endpoint struct {
+	Workload  string `json:"workload"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// Record summarises the flows between a source and a destination over an interval.  Each side is
+// identified by its Endpoint if it's a local workload, otherwise by its IP.  The counters are in
+// the direction of the connection ("out") and its replies ("in").
+type Record struct {
+	StartTime time.Time `json:"startTime"`
+	EndTime   time.Time `json:"endTime"`
+
+	Proto       string    `json:"proto"`
+	SrcIP       string    `json:"srcIP,omitempty"`
+	SrcEndpoint *Endpoint `json:"srcEndpoint,omitempty"`
+	DstIP       string    `json:"dstIP,omitempty"`
+	DstEndpoint *Endpoint `json:"dstEndpoint,omitempty"`
+	DstPort     uint16    `json:"dstPort"`
+	Action      string    `json:"action"`
+
+	// NumFlows is the number of connections that were seen during the interval.
+	NumFlows   int    `json:"numFlows"`
+	PacketsOut uint64 `json:"packetsOut"`
+	BytesOut   uint64 `json:"bytesOut"`
+	PacketsIn  uint64 `json:"packetsIn"`
+	BytesIn    uint64 `json:"bytesIn"`
+}
+
+// EndpointLookup returns the local workload that owns the given IP, or nil.
+type EndpointLookup func(addr net.IP) *Endpoint
+
+// connKey identifies a connection across samples.
+type connKey struct {
+	proto            uint8
+	srcIP, dstIP     string
+	srcPort, dstPort uint16
+}
+
+type counters struct {
+	packetsOut, bytesOut, packetsIn, bytesIn uint64
+}
+
+// recordKey identifies the flows that are aggregated into one Record.
+type recordKey struct {
+	proto        uint8
+	src, dst     string
+	dstPort      uint16
+	srcEP, dstEP Endpoint
+}
+
+// Aggregator turns samples of a conntrack table into Records.  Since conntrack's counters are
+// totals for the life of the connection, it remembers each connection's counters from the
+// previous sample and reports only the traffic since then.  Not thread safe.
+type Aggregator struct {
+	lookupEndpoint EndpointLookup
+	lastCounters   map[connKey]counters
+}
+
+func NewAggregator(lookupEndpoint EndpointLookup) *Aggregator {
+	return &Aggregator{
+		lookupEndpoint: lookupEndpoint,
+		lastCounters:   map[connKey]counters{},
+	}
+}
+
+// Aggregate returns the Records for the interval from start to end, given a sample of the flows
+// that exist at the end of the interval.  Flows that don't involve a local workload are ignored.
+// The Records are sorted so that the output is stable.
+func (a *Aggregator) Aggregate(flows []Flow, start, end time.Time) []Record {
+	newCounters := map[connKey]counters{}
+	records := map[recordKey]*Record{}
+	for _, f := range flows {
+		srcEP := a.lookupEndpoint(f.SrcIP)
+		dstEP := a.lookupEndpoint(f.DstIP)
+		if srcEP == nil && dstEP == nil {
+			continue
+		}
+
+		ck := connKey{
+			proto:   f.Proto,
+			srcIP:   f.SrcIP.String(),
+			srcPort: f.SrcPort,
+			dstIP:   f.DstIP.String(),
+			dstPort: f.DstPort,
+		}
+		cur := counters{packetsOut: f.PacketsOut, bytesOut: f.BytesOut, packetsIn: f.PacketsIn, bytesIn: f.BytesIn}
+		newCounters[ck] = cur
+		delta := cur
+		if last, ok := a.lastCounters[ck]; ok && last.packetsOut <= cur.packetsOut && last.packetsIn <= cur.packetsIn {
+			// Same connection as last time.  (If the counters went backwards, the tuple has been
+			// reused by a new connection.)
+			delta = counters{
+				packetsOut: cur.packetsOut - last.packetsOut,
+				bytesOut:   cur.bytesOut - last.bytesOut,
+				packetsIn:  cur.packetsIn - last.packetsIn,
+				bytesIn:    cur.bytesIn - last.bytesIn,
+			}
+		}
+
+		rk := recordKey{proto: f.Proto, dstPort: f.DstPort}
+		if srcEP != nil {
+			rk.srcEP = *srcEP
+		} else {
+			rk.src = ck.srcIP
+		}
+		if dstEP != nil {
+			rk.dstEP = *dstEP
+		} else {
+			rk.dst = ck.dstIP
+		}
+		r := records[rk]
+		if r == nil {
+			r = &Record{
+				StartTime:   start,
+				EndTime:     end,
+				Proto:       protoName(f.Proto),
+				SrcIP:       rk.src,
+				SrcEndpoint: srcEP,
+				DstIP:       rk.dst,
+				DstEndpoint: dstEP,
+				DstPort:     f.DstPort,
+				Action:      ActionAllow,
+			}
+			records[rk] = r
+		}
+		r.NumFlows++
+		r.PacketsOut += delta.packetsOut
+		r.BytesOut += delta.bytesOut
+		r.PacketsIn += delta.packetsIn
+		r.BytesIn += delta.bytesIn
+	}
+	// Forget the connections that have gone away.
+	a.lastCounters = newCounters
+
+	var keys []recordKey
+	for k := range records {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].less(keys[j])
+	})
+	out := make([]Record, 0, len(keys))
+	for _, k := range keys {
+		out = append(out, *records[k])
+	}
+	return out
+}
+
+func (k recordKey) less(other recordKey) bool {
+	for _, pair := range [][2]string{
+		{k.srcEP.Namespace, other.srcEP.Namespace},
+		{k.srcEP.Workload, other.srcEP.Workload},
+		{k.src, other.src},
+		{k.dstEP.Namespace, other.dstEP.Namespace},
+		{k.dstEP.Workload, other.dstEP.Workload},
+		{k.dst, other.dst},
+	} {
+		if pair[0] != pair[1] {
+			return pair[0] < pair[1]
+		}
+	}
+	if k.proto != other.proto {
+		return k.proto < other.proto
+	}
+	return k.dstPort < other.dstPort
+}
+
+func protoName(proto uint8) string {
+	switch proto {
+	case 1:
+		return "icmp"
+	case 6:
+		return "tcp"
+	case 17:
+		return "udp"
+	case 58:
+		return "icmp6"
+	case 132:
+		return "sctp"
+	}
+	return strconv.Itoa(int(proto))
+}
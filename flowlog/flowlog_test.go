@@ -0,0 +1,114 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flowlog
+
+import (
+	"net"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Aggregator", func() {
+	var (
+		agg   *Aggregator
+		start = time.Unix(1000, 0)
+		end   = time.Unix(1060, 0)
+		pod1  = &Endpoint{Workload: "default/pod1", Namespace: "default"}
+		pod2  = &Endpoint{Workload: "default/pod2", Namespace: "default"}
+	)
+
+	BeforeEach(func() {
+		agg = NewAggregator(func(addr net.IP) *Endpoint {
+			switch addr.String() {
+			case "10.65.0.1":
+				return pod1
+			case "10.65.0.2":
+				return pod2
+			}
+			return nil
+		})
+	})
+
+	flow := func(src string, srcPort uint16, dst string, dstPort uint16, pktsOut, pktsIn uint64) Flow {
+		return Flow{
+			Proto:      6,
+			SrcIP:      net.ParseIP(src),
+			SrcPort:    srcPort,
+			DstIP:      net.ParseIP(dst),
+			DstPort:    dstPort,
+			PacketsOut: pktsOut,
+			BytesOut:   pktsOut * 100,
+			PacketsIn:  pktsIn,
+			BytesIn:    pktsIn * 100,
+		}
+	}
+
+	It("should ignore flows that don't involve a local workload", func() {
+		Expect(agg.Aggregate([]Flow{flow("10.0.0.1", 1234, "10.0.0.2", 80, 1, 1)}, start, end)).To(BeEmpty())
+	})
+
+	It("should aggregate flows by endpoint, destination and port", func() {
+		records := agg.Aggregate([]Flow{
+			flow("10.65.0.1", 1234, "10.0.0.2", 80, 2, 1),
+			flow("10.65.0.1", 1235, "10.0.0.2", 80, 3, 2),
+			flow("10.65.0.1", 1236, "10.0.0.2", 443, 1, 1),
+			flow("10.0.0.3", 5000, "10.65.0.2", 8080, 4, 4),
+		}, start, end)
+		Expect(records).To(Equal([]Record{
+			{
+				StartTime: start, EndTime: end, Proto: "tcp",
+				SrcIP: "10.0.0.3", DstEndpoint: pod2, DstPort: 8080, Action: ActionAllow,
+				NumFlows: 1, PacketsOut: 4, BytesOut: 400, PacketsIn: 4, BytesIn: 400,
+			},
+			{
+				StartTime: start, EndTime: end, Proto: "tcp",
+				SrcEndpoint: pod1, DstIP: "10.0.0.2", DstPort: 80, Action: ActionAllow,
+				NumFlows: 2, PacketsOut: 5, BytesOut: 500, PacketsIn: 3, BytesIn: 300,
+			},
+			{
+				StartTime: start, EndTime: end, Proto: "tcp",
+				SrcEndpoint: pod1, DstIP: "10.0.0.2", DstPort: 443, Action: ActionAllow,
+				NumFlows: 1, PacketsOut: 1, BytesOut: 100, PacketsIn: 1, BytesIn: 100,
+			},
+		}))
+	})
+
+	It("should only count the traffic since the previous sample", func() {
+		agg.Aggregate([]Flow{flow("10.65.0.1", 1234, "10.65.0.2", 80, 2, 1)}, start, end)
+		records := agg.Aggregate([]Flow{flow("10.65.0.1", 1234, "10.65.0.2", 80, 5, 3)}, end, end.Add(time.Minute))
+		Expect(records).To(HaveLen(1))
+		Expect(records[0].SrcEndpoint).To(Equal(pod1))
+		Expect(records[0].DstEndpoint).To(Equal(pod2))
+		Expect(records[0].PacketsOut).To(BeEquivalentTo(3))
+		Expect(records[0].PacketsIn).To(BeEquivalentTo(2))
+	})
+
+	It("should treat a reused tuple as a new connection", func() {
+		agg.Aggregate([]Flow{flow("10.65.0.1", 1234, "10.0.0.2", 80, 10, 10)}, start, end)
+		records := agg.Aggregate([]Flow{flow("10.65.0.1", 1234, "10.0.0.2", 80, 2, 1)}, end, end.Add(time.Minute))
+		Expect(records).To(HaveLen(1))
+		Expect(records[0].PacketsOut).To(BeEquivalentTo(2))
+		Expect(records[0].PacketsIn).To(BeEquivalentTo(1))
+	})
+
+	It("should forget connections that have gone away", func() {
+		agg.Aggregate([]Flow{flow("10.65.0.1", 1234, "10.0.0.2", 80, 10, 10)}, start, end)
+		agg.Aggregate(nil, end, end.Add(time.Minute))
+		records := agg.Aggregate([]Flow{flow("10.65.0.1", 1234, "10.0.0.2", 80, 12, 12)}, end, end.Add(time.Minute))
+		Expect(records[0].PacketsOut).To(BeEquivalentTo(12))
+	})
+})
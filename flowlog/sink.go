@@ -0,0 +1,228 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flowlog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/syslog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+const (
+	SinkFile   = "file"
+	SinkSyslog = "syslog"
+	SinkGRPC   = "grpc"
+
+	// GRPCMethod is the method that the gRPC sink calls with each batch of records.  The
+	// collector should implement
+	//
+	//     package felix;
+	//     service FlowLogCollector {
+	//       rpc Report(google.protobuf.Struct) returns (google.protobuf.Empty);
+	//     }
+	//
+	// The Struct's "records" field holds the list of records, in the same shape as their JSON
+	// encoding.
+	GRPCMethod = "/felix.FlowLogCollector/Report"
+
+	grpcTimeout = 10 * time.Second
+	// asyncQueueLen is the number of batches that an AsyncSink queues up for a slow sink before
+	// it starts dropping them.
+	asyncQueueLen = 10
+)
+
+var (
+	countFlowLogBatchesDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "felix_flow_log_batches_dropped",
+		Help: "Number of batches of flow log records that were dropped because the sink fell behind.",
+	})
+	countFlowLogWriteErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "felix_flow_log_write_errors",
+		Help: "Number of batches of flow log records that the sink failed to write.",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(countFlowLogBatchesDropped)
+	prometheus.MustRegister(countFlowLogWriteErrors)
+}
+
+// Sink writes batches of Records somewhere.
+type Sink interface {
+	Write(records []Record) error
+}
+
+// NewSink creates the Sink of the given type.  fileName is only used by the file sink and
+// grpcAddr by the gRPC sink.
+func NewSink(sinkType, fileName, grpcAddr string) (Sink, error) {
+	switch sinkType {
+	case SinkFile:
+		return NewFileSink(fileName), nil
+	case SinkSyslog:
+		return NewSyslogSink(), nil
+	case SinkGRPC:
+		return NewGRPCSink(grpcAddr)
+	}
+	return nil, fmt.Errorf("unknown flow log sink %q", sinkType)
+}
+
+// FileSink appends records to a file, one JSON object per line.  It reopens the file for each
+// batch so that the file can be rotated by renaming it.
+type FileSink struct {
+	fileName string
+}
+
+func NewFileSink(fileName string) *FileSink {
+	return &FileSink{fileName: fileName}
+}
+
+func (s *FileSink) Write(records []Record) error {
+	if err := os.MkdirAll(filepath.Dir(s.fileName), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.fileName, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, r := range records {
+		if err := enc.Encode(r); err != nil {
+			_ = f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// SyslogSink writes each record to the local syslog daemon as a JSON message.
+type SyslogSink struct {
+	writer *syslog.Writer
+}
+
+func NewSyslogSink() *SyslogSink {
+	return &SyslogSink{}
+}
+
+func (s *SyslogSink) Write(records []Record) error {
+	if s.writer == nil {
+		// Connect lazily so that Felix can start before the syslog daemon.  Once connected,
+		// the writer reconnects by itself.
+		w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, "calico-felix-flows")
+		if err != nil {
+			return err
+		}
+		s.writer = w
+	}
+	for _, r := range records {
+		buf, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		if err := s.writer.Info(string(buf)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GRPCSink sends each batch of records to a collector with a call to GRPCMethod.
+type GRPCSink struct {
+	conn *grpc.ClientConn
+}
+
+func NewGRPCSink(addr string) (*GRPCSink, error) {
+	// Dialing doesn't block; the connection is made (and remade) in the background.
+	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	if err != nil {
+		return nil, err
+	}
+	return &GRPCSink{conn: conn}, nil
+}
+
+func (s *GRPCSink) Write(records []Record) error {
+	req, err := recordsToStruct(records)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), grpcTimeout)
+	defer cancel()
+	return s.conn.Invoke(ctx, GRPCMethod, req, &emptypb.Empty{})
+}
+
+func recordsToStruct(records []Record) (*structpb.Struct, error) {
+	// Go via JSON so that the collector sees the same field names as the other sinks' readers.
+	buf, err := json.Marshal(map[string]interface{}{"records": records})
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(buf, &m); err != nil {
+		return nil, err
+	}
+	return structpb.NewStruct(m)
+}
+
+// AsyncSink wraps a Sink so that writes happen on a background goroutine, so that a slow sink
+// doesn't hold up the caller.  If the wrapped sink falls too far behind, AsyncSink drops whole
+// batches.
+type AsyncSink struct {
+	sink    Sink
+	batches chan []Record
+}
+
+func NewAsyncSink(sink Sink) *AsyncSink {
+	return &AsyncSink{
+		sink:    sink,
+		batches: make(chan []Record, asyncQueueLen),
+	}
+}
+
+func (s *AsyncSink) Start() {
+	go s.loop()
+}
+
+// Write queues a batch of records to be written.  It never blocks and it never fails; errors
+// from the wrapped sink are logged and counted.
+func (s *AsyncSink) Write(records []Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+	select {
+	case s.batches <- records:
+	default:
+		log.WithField("numRecords", len(records)).Warn("Flow log sink fell behind, dropping records.")
+		countFlowLogBatchesDropped.Inc()
+	}
+	return nil
+}
+
+func (s *AsyncSink) loop() {
+	for records := range s.batches {
+		if err := s.sink.Write(records); err != nil {
+			log.WithError(err).WithField("numRecords", len(records)).Warn("Failed to write flow log records.")
+			countFlowLogWriteErrors.Inc()
+		}
+	}
+}
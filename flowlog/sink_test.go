@@ -0,0 +1,117 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package flowlog
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var _ = Describe("Sinks", func() {
+	records := []Record{
+		{Proto: "tcp", SrcEndpoint: &Endpoint{Workload: "default/pod1"}, DstIP: "10.0.0.2", DstPort: 80, Action: ActionAllow, NumFlows: 1},
+		{Proto: "udp", SrcIP: "10.0.0.3", DstEndpoint: &Endpoint{Workload: "default/pod2"}, DstPort: 53, Action: ActionAllow, NumFlows: 2},
+	}
+
+	Describe("file sink", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "flowlog")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			_ = os.RemoveAll(dir)
+		})
+
+		It("should append a JSON line per record, creating the directory", func() {
+			fileName := filepath.Join(dir, "flowlogs", "flows.log")
+			sink := NewFileSink(fileName)
+			Expect(sink.Write(records[:1])).To(Succeed())
+			Expect(sink.Write(records[1:])).To(Succeed())
+
+			data, err := ioutil.ReadFile(fileName)
+			Expect(err).NotTo(HaveOccurred())
+			lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+			Expect(lines).To(HaveLen(2))
+			for i, line := range lines {
+				var r Record
+				Expect(json.Unmarshal([]byte(line), &r)).To(Succeed())
+				Expect(r).To(Equal(records[i]))
+			}
+		})
+	})
+
+	Describe("gRPC sink", func() {
+		var (
+			server   *grpc.Server
+			received chan *structpb.Struct
+			sink     *GRPCSink
+		)
+
+		BeforeEach(func() {
+			received = make(chan *structpb.Struct, 1)
+			server = grpc.NewServer(grpc.UnknownServiceHandler(func(_ interface{}, stream grpc.ServerStream) error {
+				method, _ := grpc.MethodFromServerStream(stream)
+				Expect(method).To(Equal(GRPCMethod))
+				req := &structpb.Struct{}
+				if err := stream.RecvMsg(req); err != nil {
+					return err
+				}
+				received <- req
+				return stream.SendMsg(&emptypb.Empty{})
+			}))
+			lis, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			go func() {
+				_ = server.Serve(lis)
+			}()
+			sink, err = NewGRPCSink(lis.Addr().String())
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			server.Stop()
+		})
+
+		It("should send the records to the collector", func() {
+			Expect(sink.Write(records)).To(Succeed())
+			var req *structpb.Struct
+			Eventually(received, 5*time.Second).Should(Receive(&req))
+			sent := req.Fields["records"].GetListValue().GetValues()
+			Expect(sent).To(HaveLen(2))
+			Expect(sent[0].GetStructValue().Fields["dstIP"].GetStringValue()).To(Equal("10.0.0.2"))
+			Expect(sent[1].GetStructValue().Fields["numFlows"].GetNumberValue()).To(BeEquivalentTo(2))
+		})
+	})
+
+	It("should reject an unknown sink type", func() {
+		_, err := NewSink("carrier-pigeon", "", "")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -1769,7 +1769,8 @@ func describeBPFTests(opts ...bpfTestOpt) bool {
 						})
 					} else {
 						It("should not have connectivity from the local host via a service to workload 0", func() {
-							// Local host is always white-listed (for kubelet health checks).
+							// Without CTLB, the host NAT routing rules are only programmed for the
+							// service cluster CIDRs in the BGP configuration, and there are none yet.
 							ip := testSvc.Spec.ClusterIP
 							port := uint16(testSvc.Spec.Ports[0].Port)
 
@@ -1777,6 +1778,58 @@ func describeBPFTests(opts ...bpfTestOpt) bool {
 							cc.ExpectNone(felixes[1], TargetIP(ip), port)
 							cc.CheckConnectivity()
 						})
+
+						Describe("with the service cluster CIDR in the BGP configuration", func() {
+							BeforeEach(func() {
+								cfg := api.NewBGPConfiguration()
+								cfg.Name = "default"
+								cfg.Spec.ServiceClusterIPs = []api.ServiceClusterIPBlock{{CIDR: "10.101.0.0/16"}}
+								_, err := calicoClient.BGPConfigurations().Create(context.Background(), cfg, options2.SetOptions{})
+								Expect(err).NotTo(HaveOccurred())
+
+								for _, f := range felixes {
+									Eventually(func() string {
+										out, _ := f.ExecOutput("ip", "rule")
+										return out
+									}, "10s", "200ms").Should(MatchRegexp(`to 10\.101\.0\.0/16 iif lo lookup \d+`))
+								}
+							})
+
+							It("should only have connectivity from the local host via a service to workload 0", func() {
+								// Local host is always white-listed (for kubelet health checks).
+								ip := testSvc.Spec.ClusterIP
+								port := uint16(testSvc.Spec.Ports[0].Port)
+
+								cc.ExpectSome(felixes[0], TargetIP(ip), port)
+								cc.ExpectNone(felixes[1], TargetIP(ip), port)
+								cc.CheckConnectivity()
+							})
+
+							Describe("after updating the policy to allow traffic from hosts", func() {
+								BeforeEach(func() {
+									pol.Spec.Ingress = []api.Rule{
+										{
+											Action: "Allow",
+											Source: api.EntityRule{
+												Selector: "ep-type == 'host'",
+											},
+										},
+									}
+									pol = updatePolicy(pol)
+								})
+
+								It("should have connectivity from the hosts via a service to workload 0", func() {
+									ip := testSvc.Spec.ClusterIP
+									port := uint16(testSvc.Spec.Ports[0].Port)
+
+									cc.ExpectSome(felixes[0], TargetIP(ip), port)
+									cc.ExpectSome(felixes[1], TargetIP(ip), port)
+									cc.ExpectNone(w[0][1], TargetIP(ip), port)
+									cc.ExpectNone(w[1][0], TargetIP(ip), port)
+									cc.CheckConnectivity()
+								})
+							})
+						})
 					}
 
 					if testOpts.connTimeEnabled {
@@ -2812,9 +2865,12 @@ func describeBPFTests(opts ...bpfTestOpt) bool {
 					})
 
 					By("having initial connectivity", expectPongs)
+					cc.ExpectConnectionUnbroken(pc)
 					By("enabling BPF mode", enableBPF) // Waits for BPF programs to be installed
 					time.Sleep(2 * time.Second)        // pongs time out after 1s, make sure we look for fresh pongs.
-					By("still having connectivity on the existing connection", expectPongs)
+					By("still having connectivity on the existing connection", func() {
+						cc.CheckConnectivity()
+					})
 				}
 
 				It("should keep a connection up between hosts when BPF is enabled", func() {
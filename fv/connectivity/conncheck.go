@@ -46,14 +46,25 @@ import (
 //     cc.Expect(Some, w[1], w[0], 4321, ExpectWithABC, ExpectWithXYZ)
 //     cc.CheckConnectivity()
 //
+// It can also check that long-lived connections survive (or are broken by) some action:
+//
+//     pc := w[0].StartPersistentConnection(w[1].IP, 8055, workload.PersistentConnectionOpts{
+//         MonitorConnectivity: true,
+//     })
+//     cc.ExpectConnectionUnbroken(pc)
+//     enableWireguard()
+//     cc.CheckConnectivity()
+//
 type Checker struct {
 	ReverseDirection bool
-	Protocol         string // "tcp" or "udp"
+	Protocol         string // "tcp", "udp", "http" or "https", for example
 	expectations     []Expectation
+	persistentConns  []persistentConnExpectation
 	CheckSNAT        bool
 	RetriesDisabled  bool
 
 	// OnFail, if set, will be called instead of ginkgo.Fail().  (Useful for testing the checker itself.)
+	// Diagnostics are only collected when the checker calls ginkgo.Fail().
 	OnFail func(msg string)
 }
 
@@ -101,6 +112,33 @@ func (c *Checker) ExpectLoss(from ConnectionSource, to ConnectionTarget,
 	)
 }
 
+// ExpectConnectionUnbroken asserts that the persistent connection is still passing traffic when
+// the connectivity is next checked: it has had responses since this call and it is still getting
+// them.  Record the expectation before the action that the connection should survive (a policy
+// change, enabling wireguard, restarting felix and so on) and check connectivity after it.  The
+// connection must have been started with MonitorConnectivity.
+func (c *Checker) ExpectConnectionUnbroken(pc *PersistentConnection) {
+	c.expectPersistentConn(pc, false)
+}
+
+// ExpectConnectionBroken asserts that the persistent connection has stopped passing traffic when
+// the connectivity is next checked.  The connection must have been started with
+// MonitorConnectivity.
+func (c *Checker) ExpectConnectionBroken(pc *PersistentConnection) {
+	c.expectPersistentConn(pc, true)
+}
+
+func (c *Checker) expectPersistentConn(pc *PersistentConnection, broken bool) {
+	ExpectWithOffset(2, pc.MonitorConnectivity).To(BeTrue(),
+		"Persistent connection expectations need a connection that monitors connectivity")
+	UnactivatedCheckers.Add(c)
+	c.persistentConns = append(c.persistentConns, persistentConnExpectation{
+		pc:         pc,
+		broken:     broken,
+		startCount: pc.PongCount(),
+	})
+}
+
 func (c *Checker) expect(expected Expected, from ConnectionSource, to ConnectionTarget,
 	opts ...ExpectationOption) {
 
@@ -110,8 +148,9 @@ func (c *Checker) expect(expected Expected, from ConnectionSource, to Connection
 	}
 
 	e := Expectation{
-		From:     from,
-		Expected: expected,
+		From:           from,
+		Expected:       expected,
+		maxLossPercent: -1,
 	}
 
 	if expected {
@@ -130,6 +169,7 @@ func (c *Checker) expect(expected Expected, from ConnectionSource, to Connection
 
 func (c *Checker) ResetExpectations() {
 	c.expectations = nil
+	c.persistentConns = nil
 	c.CheckSNAT = false
 	c.RetriesDisabled = false
 }
@@ -158,10 +198,19 @@ func (c *Checker) ActualConnectivity() ([]*Result, []string) {
 				WithDuration(exp.ExpectedPacketLoss.Duration),
 			}
 
+			if exp.probes > 0 {
+				opts = append(opts, WithProbes(exp.probes))
+			}
+
 			if exp.sendLen > 0 || exp.recvLen > 0 {
 				opts = append(opts, WithSendLen(exp.sendLen), WithRecvLen(exp.recvLen))
 			}
 
+			if exp.httpPath != "" || exp.sni != "" || exp.expectedHTTPStatus != 0 {
+				opts = append(opts, WithHTTPPath(exp.httpPath), WithSNI(exp.sni),
+					WithExpectedHTTPStatus(exp.expectedHTTPStatus))
+			}
+
 			res = exp.From.CanConnectTo(exp.To.IP, exp.To.Port, p, opts...)
 
 			pretty[i] += fmt.Sprintf("%s -> %s = %v", exp.From.SourceName(), exp.To.TargetName, res.HasConnectivity())
@@ -180,6 +229,10 @@ func (c *Checker) ActualConnectivity() ([]*Result, []string) {
 					pct := res.Stats.LostPercent()
 					pretty[i] += fmt.Sprintf(" (sent: %d, lost: %d / %.1f%%)", sent, lost, pct)
 				}
+				if exp.probes > 0 {
+					pretty[i] += fmt.Sprintf(" (probes: %d, lost: %.1f%%, max RTT: %v)",
+						res.Stats.RequestsSent, res.Stats.LostPercent(), res.Stats.MaxRTT())
+				}
 			}
 
 			responses[i] = res
@@ -211,6 +264,12 @@ func (c *Checker) ExpectedConnectivityPretty() []string {
 				result[i] += fmt.Sprintf(" (maxLoss: %.1f%%)", exp.ExpectedPacketLoss.MaxPercent)
 			}
 		}
+		if exp.maxLatency > 0 {
+			result[i] += fmt.Sprintf(" (max RTT < %v)", exp.maxLatency)
+		}
+		if exp.maxLossPercent >= 0 && exp.probes > 0 {
+			result[i] += fmt.Sprintf(" (loss < %.1f%%)", exp.maxLossPercent)
+		}
 		if exp.ErrorStr != "" {
 			result[i] += " " + exp.ErrorStr
 		}
@@ -244,47 +303,102 @@ func (c *Checker) CheckConnectivityWithTimeout(timeout time.Duration, optionalDe
 }
 
 func (c *Checker) CheckConnectivityWithTimeoutOffset(callerSkip int, timeout time.Duration, optionalDescription ...interface{}) {
-	var expConnectivity []string
 	start := time.Now()
 
 	// Track the number of attempts. If the first connectivity check fails, we want to
 	// do at least one retry before we time out.  That covers the case where the first
 	// connectivity check takes longer than the timeout.
 	completedAttempts := 0
-	var actualConn []*Result
-	var actualConnPretty []string
+	var results []CheckResult
 	for !c.RetriesDisabled && time.Since(start) < timeout || completedAttempts < 2 {
-		actualConn, actualConnPretty = c.ActualConnectivity()
-		failed := false
-		expConnectivity = c.ExpectedConnectivityPretty()
+		actualConn, actualConnPretty := c.ActualConnectivity()
+		expConnectivity := c.ExpectedConnectivityPretty()
+		results = results[:0]
 		for i := range c.expectations {
-			exp := c.expectations[i]
-			act := actualConn[i]
-			if !exp.Matches(act, c.CheckSNAT) {
-				failed = true
-				actualConnPretty[i] += " <---- WRONG"
-				expConnectivity[i] += " <---- EXPECTED"
-			}
+			results = append(results, CheckResult{
+				Expected: expConnectivity[i],
+				Actual:   actualConnPretty[i],
+				OK:       c.expectations[i].Matches(actualConn[i], c.CheckSNAT),
+			})
 		}
-		if !failed {
+		for _, pce := range c.persistentConns {
+			act, ok := pce.actual()
+			results = append(results, CheckResult{
+				Expected: pce.expectedPretty(),
+				Actual:   act,
+				OK:       ok,
+			})
+		}
+		completedAttempts++
+		if numFailed(results) == 0 {
 			// Success!
 			return
 		}
-		completedAttempts++
+		if len(c.persistentConns) > 0 {
+			// Persistent connections are checked by looking at their responses so far, give them
+			// time to change.
+			time.Sleep(persistentConnPollInterval)
+		}
 	}
 
-	message := fmt.Sprintf(
-		"Connectivity was incorrect:\n\nExpected\n    %s\nto match\n    %s",
-		strings.Join(actualConnPretty, "\n    "),
-		strings.Join(expConnectivity, "\n    "),
-	)
+	message := formatConnectivityDiff(results)
 	if c.OnFail != nil {
 		c.OnFail(message)
 	} else {
+		if fileName := writeFailureSummary(results, completedAttempts); fileName != "" {
+			message += "\n\nFailure summary written to " + fileName
+		}
+		if dir := c.collectDiags(message); dir != "" {
+			message += "\n\nDiagnostics written to " + dir
+		}
 		ginkgo.Fail(message, callerSkip)
 	}
 }
 
+const (
+	// persistentConnPongTimeout is how long a persistent connection can go without a response
+	// before we consider it broken.  test-connection sends a request every 500ms.
+	persistentConnPongTimeout  = time.Second
+	persistentConnPollInterval = 100 * time.Millisecond
+)
+
+type persistentConnExpectation struct {
+	pc     *PersistentConnection
+	broken bool
+	// startCount is the connection's response count when the expectation was recorded.
+	startCount int
+}
+
+func (e persistentConnExpectation) name() string {
+	return fmt.Sprintf("%s -> %s:%d (persistent)", e.pc.RuntimeName, e.pc.IP, e.pc.Port)
+}
+
+func (e persistentConnExpectation) expectedPretty() string {
+	if e.broken {
+		return e.name() + " = broken"
+	}
+	return e.name() + " = unbroken"
+}
+
+// actual returns a description of the connection's current state and whether it matches the
+// expectation.
+func (e persistentConnExpectation) actual() (string, bool) {
+	newPongs := e.pc.PongCount() - e.startCount
+	sinceLastPong := e.pc.SinceLastPong()
+	unbroken := newPongs > 0 && sinceLastPong < persistentConnPongTimeout
+
+	state := "unbroken"
+	if !unbroken {
+		state = "broken"
+	}
+	desc := fmt.Sprintf("%s = %s (%d new responses", e.name(), state, newPongs)
+	if newPongs > 0 {
+		desc += fmt.Sprintf(", last %v ago", sinceLastPong.Round(time.Millisecond))
+	}
+	desc += ")"
+	return desc, unbroken != e.broken
+}
+
 func NewRequest(payload string) Request {
 	return Request{
 		Timestamp: time.Now(),
@@ -344,6 +458,9 @@ func HaveConnectivityTo(target ConnectionTarget, explicitPort ...uint16) types.G
 
 type Matcher struct {
 	IP, Port, TargetName, Protocol string
+
+	// Diags, if set, is the container that hosts the target, for diagnostics collection.
+	Diags DiagsContainer
 }
 
 type ConnectionSource interface {
@@ -425,6 +542,58 @@ func ExpectWithLoss(duration time.Duration, maxPacketLossPercent float64, maxPac
 	}
 }
 
+// defaultProbes is the number of probes that test-connection sends for the ExpectLatencyBelow and
+// ExpectLossBelow options if ExpectWithProbes doesn't say otherwise.
+const defaultProbes = 20
+
+// ExpectLatencyBelow asserts that every probe that gets a response has a round-trip time below
+// the given duration.  It switches the check to probe mode, in which test-connection sends a
+// series of requests, one at a time, and times each of them.
+func ExpectLatencyBelow(d time.Duration) ExpectationOption {
+	Expect(d).To(BeNumerically(">", 0), "Latency threshold must be positive")
+
+	return func(e *Expectation) {
+		e.maxLatency = d
+		if e.probes == 0 {
+			e.probes = defaultProbes
+		}
+	}
+}
+
+// ExpectLossBelow asserts that less than the given percentage of probes go unanswered.  Like
+// ExpectLatencyBelow, it switches the check to probe mode.
+func ExpectLossBelow(percent float64) ExpectationOption {
+	Expect(percent).To(BeNumerically(">", 0), "Loss threshold must be positive")
+	Expect(percent).To(BeNumerically("<=", 100), "Loss percentage should be <=100")
+
+	return func(e *Expectation) {
+		e.maxLossPercent = percent
+		if e.probes == 0 {
+			e.probes = defaultProbes
+		}
+	}
+}
+
+// ExpectWithProbes sets the number of probes to send in probe mode.  More probes make the loss
+// percentage more precise at the cost of a longer check.
+func ExpectWithProbes(n int) ExpectationOption {
+	Expect(n).To(BeNumerically(">", 0), "Must send at least one probe")
+
+	return func(e *Expectation) {
+		e.probes = n
+	}
+}
+
+// ExpectWithHTTPRequest sets the path to request, the server name to send (for https; may be
+// empty) and the status that counts as success when the checker's protocol is http or https.
+func ExpectWithHTTPRequest(path, sni string, status int) ExpectationOption {
+	return func(e *Expectation) {
+		e.httpPath = path
+		e.sni = sni
+		e.expectedHTTPStatus = status
+	}
+}
+
 func ExpectWithPorts(ports ...uint16) ExpectationOption {
 	return func(e *Expectation) {
 		e.explicitPorts = ports
@@ -446,6 +615,16 @@ type Expectation struct {
 	clientMTUStart int
 	clientMTUEnd   int
 
+	httpPath           string
+	sni                string
+	expectedHTTPStatus int
+
+	// Only used in probe mode, when probes is non-zero.  maxLossPercent is -1 if the loss
+	// isn't checked.
+	probes         int
+	maxLatency     time.Duration
+	maxLossPercent float64
+
 	ErrorStr string
 }
 
@@ -495,6 +674,19 @@ func (e Expectation) Matches(response *Result, checkSNAT bool) bool {
 		} else if response.LastResponse.ErrorStr != "" {
 			return false
 		}
+
+		if e.probes > 0 {
+			if len(response.Stats.Probes) == 0 {
+				// test-connection didn't run in probe mode.
+				return false
+			}
+			if e.maxLatency > 0 && response.Stats.MaxRTT() >= e.maxLatency {
+				return false
+			}
+			if e.maxLossPercent >= 0 && response.Stats.LostPercent() >= e.maxLossPercent {
+				return false
+			}
+		}
 	} else {
 		if response != nil {
 			if e.ErrorStr != "" {
@@ -556,6 +748,15 @@ func (r *Result) HasConnectivity() bool {
 type Stats struct {
 	RequestsSent      int
 	ResponsesReceived int
+
+	// Probes holds the outcome of each request in probe mode, in the order they were sent.
+	Probes []Probe `json:",omitempty"`
+}
+
+// Probe is the outcome of a single request in probe mode.
+type Probe struct {
+	RTT  time.Duration
+	Lost bool
 }
 
 func (s Stats) Lost() int {
@@ -566,6 +767,17 @@ func (s Stats) LostPercent() float64 {
 	return float64(s.Lost()) * 100.0 / float64(s.RequestsSent)
 }
 
+// MaxRTT returns the longest round-trip time of the probes that got a response.
+func (s Stats) MaxRTT() time.Duration {
+	var max time.Duration
+	for _, p := range s.Probes {
+		if !p.Lost && p.RTT > max {
+			max = p.RTT
+		}
+	}
+	return max
+}
+
 // CheckOption is the option format for Check()
 type CheckOption func(cmd *CheckCmd)
 
@@ -578,13 +790,20 @@ type CheckCmd struct {
 	port     string
 	protocol string
 
-	ipSource   string
-	portSource string
+	ipSource    string
+	portSource  string
+	ifaceSource string
 
 	duration time.Duration
+	probes   int
 
 	sendLen int
 	recvLen int
+
+	// Only used by the http and https protocols.
+	httpPath           string
+	sni                string
+	expectedHTTPStatus int
 }
 
 // BinaryName is the name of the binary that the connectivity Check() executes
@@ -613,6 +832,26 @@ func (cmd *CheckCmd) run(cName string, logMsg string) *Result {
 		args = append(args, fmt.Sprintf("--source-port=%s", cmd.portSource))
 	}
 
+	if cmd.ifaceSource != "" {
+		args = append(args, fmt.Sprintf("--source-iface=%s", cmd.ifaceSource))
+	}
+
+	if cmd.probes > 0 {
+		args = append(args, fmt.Sprintf("--probes=%d", cmd.probes))
+	}
+
+	if cmd.httpPath != "" {
+		args = append(args, fmt.Sprintf("--http-path=%s", cmd.httpPath))
+	}
+
+	if cmd.sni != "" {
+		args = append(args, fmt.Sprintf("--sni=%s", cmd.sni))
+	}
+
+	if cmd.expectedHTTPStatus != 0 {
+		args = append(args, fmt.Sprintf("--expected-status=%d", cmd.expectedHTTPStatus))
+	}
+
 	// Run 'test-connection' to the target.
 	connectionCmd := utils.Command("docker", args...)
 
@@ -675,6 +914,14 @@ func WithSourcePort(port string) CheckOption {
 	}
 }
 
+// WithSourceIface tells the check to bind its socket to the given interface so that it egresses
+// through that interface regardless of routing.
+func WithSourceIface(iface string) CheckOption {
+	return func(c *CheckCmd) {
+		c.ifaceSource = iface
+	}
+}
+
 func WithNamespacePath(nsPath string) CheckOption {
 	return func(c *CheckCmd) {
 		c.nsPath = nsPath
@@ -687,6 +934,14 @@ func WithDuration(duration time.Duration) CheckOption {
 	}
 }
 
+// WithProbes tells the check to send n requests, one at a time, and to report the round-trip time
+// of each.
+func WithProbes(n int) CheckOption {
+	return func(c *CheckCmd) {
+		c.probes = n
+	}
+}
+
 func WithSendLen(l int) CheckOption {
 	return func(c *CheckCmd) {
 		c.sendLen = l
@@ -699,6 +954,27 @@ func WithRecvLen(l int) CheckOption {
 	}
 }
 
+// WithHTTPPath tells an http or https check which path to request.
+func WithHTTPPath(path string) CheckOption {
+	return func(c *CheckCmd) {
+		c.httpPath = path
+	}
+}
+
+// WithSNI tells an https check which server name to send in the TLS handshake.
+func WithSNI(serverName string) CheckOption {
+	return func(c *CheckCmd) {
+		c.sni = serverName
+	}
+}
+
+// WithExpectedHTTPStatus tells an http or https check which status counts as success.
+func WithExpectedHTTPStatus(status int) CheckOption {
+	return func(c *CheckCmd) {
+		c.expectedHTTPStatus = status
+	}
+}
+
 // Check executes the connectivity check
 func Check(cName, logMsg, ip, port, protocol string, opts ...CheckOption) *Result {
 
@@ -0,0 +1,154 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connectivity
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/onsi/ginkgo"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/fv/utils"
+)
+
+// DiagsDirEnvVar names the environment variable that controls where the checker writes diagnostics
+// bundles when a connectivity check fails.  Setting it to "none" disables collection.
+const DiagsDirEnvVar = "FELIX_FV_DIAGS_DIR"
+
+// defaultDiagsDir is relative to the fv directory, alongside the JUnit reports.
+const defaultDiagsDir = "../report/diags"
+
+// DiagsContainer is a container that diagnostics can be collected from.
+type DiagsContainer interface {
+	SourceName() string
+	ExecOutput(args ...string) (string, error)
+}
+
+// HasDiagsContainer is implemented by connection sources and targets that run inside a container
+// other than themselves; for example, workloads report the Felix container that hosts them.
+type HasDiagsContainer interface {
+	DiagsContainer() DiagsContainer
+}
+
+// diagsCommands are the commands whose output makes up a diagnostics bundle.  Commands that
+// aren't available in a particular container (for example calico-bpf in a non-Felix container)
+// simply record their error.
+var diagsCommands = [][]string{
+	{"iptables-save", "-c"},
+	{"ip6tables-save", "-c"},
+	{"ipset", "list"},
+	{"ip", "rule"},
+	{"ip", "route", "show", "table", "all"},
+	{"ip", "-6", "rule"},
+	{"ip", "-6", "route", "show", "table", "all"},
+	{"ip", "neigh"},
+	{"ip", "-d", "link"},
+	{"ip", "addr"},
+	{"conntrack", "-L"},
+	{"calico-bpf", "conntrack", "dump"},
+	{"calico-bpf", "nat", "dump"},
+	{"calico-bpf", "ipsets", "dump"},
+	{"calico-bpf", "routes", "dump"},
+	{"calico-bpf", "arp", "dump"},
+}
+
+func diagsDir() string {
+	dir := os.Getenv(DiagsDirEnvVar)
+	if dir == "" {
+		dir = defaultDiagsDir
+	}
+	if dir == "none" {
+		return ""
+	}
+	return dir
+}
+
+// diagsContainers returns the de-duplicated containers involved in the checker's expectations.
+func (c *Checker) diagsContainers() []DiagsContainer {
+	seen := map[string]bool{}
+	var containers []DiagsContainer
+	add := func(dc DiagsContainer) {
+		if dc == nil || seen[dc.SourceName()] {
+			return
+		}
+		seen[dc.SourceName()] = true
+		containers = append(containers, dc)
+	}
+	for _, exp := range c.expectations {
+		if h, ok := exp.From.(HasDiagsContainer); ok {
+			add(h.DiagsContainer())
+		} else if dc, ok := exp.From.(DiagsContainer); ok {
+			add(dc)
+		}
+		if exp.To != nil {
+			add(exp.To.Diags)
+		}
+	}
+	return containers
+}
+
+// collectDiags writes a diagnostics bundle for each container involved in the failed check, along
+// with the failure message, into a per-test directory.  It returns the directory, or "" if
+// collection is disabled or failed.
+func (c *Checker) collectDiags(message string) string {
+	baseDir := diagsDir()
+	if baseDir == "" {
+		return ""
+	}
+	test := ginkgo.CurrentGinkgoTestDescription().FullTestText
+	dir := path.Join(baseDir, utils.SanitizeFileName(test))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.WithError(err).Error("Failed to create connectivity diagnostics directory")
+		return ""
+	}
+	if err := ioutil.WriteFile(path.Join(dir, "failure.txt"), []byte(test+"\n\n"+message+"\n"), 0644); err != nil {
+		log.WithError(err).Error("Failed to write connectivity failure message")
+	}
+
+	var wg sync.WaitGroup
+	for _, dc := range c.diagsContainers() {
+		wg.Add(1)
+		go func(dc DiagsContainer) {
+			defer wg.Done()
+			fileName := path.Join(dir, utils.SanitizeFileName(dc.SourceName())+".txt")
+			if err := ioutil.WriteFile(fileName, collectContainerDiags(dc), 0644); err != nil {
+				log.WithError(err).WithField("file", fileName).Error("Failed to write connectivity diagnostics")
+			}
+		}(dc)
+	}
+	wg.Wait()
+	log.WithField("dir", dir).Info("Wrote connectivity diagnostics")
+	return dir
+}
+
+func collectContainerDiags(dc DiagsContainer) []byte {
+	var buf bytes.Buffer
+	for _, cmd := range diagsCommands {
+		fmt.Fprintf(&buf, "### %s\n", strings.Join(cmd, " "))
+		out, err := dc.ExecOutput(cmd...)
+		buf.WriteString(out)
+		if err != nil {
+			fmt.Fprintf(&buf, "(failed: %v)\n", err)
+		}
+		buf.WriteString("\n")
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package connectivity
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/onsi/ginkgo"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/fv/utils"
+)
+
+// FailureSummaryDirEnvVar names the environment variable that, if set, makes the checker write a
+// JSON summary of each failed connectivity check to <dir>/<test name>.json, for CI dashboards.
+const FailureSummaryDirEnvVar = "FELIX_FV_FAILURE_SUMMARY_DIR"
+
+// CheckResult is the outcome of a single expectation in a connectivity check.
+type CheckResult struct {
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+	OK       bool   `json:"ok"`
+}
+
+// FailureSummary is the machine-readable summary of a failed connectivity check.
+type FailureSummary struct {
+	Test      string        `json:"test"`
+	Time      time.Time     `json:"time"`
+	Attempts  int           `json:"attempts"`
+	NumFailed int           `json:"numFailed"`
+	Checks    []CheckResult `json:"checks"`
+}
+
+func numFailed(results []CheckResult) int {
+	n := 0
+	for _, r := range results {
+		if !r.OK {
+			n++
+		}
+	}
+	return n
+}
+
+// formatConnectivityDiff returns a two-column table of the expected and actual connectivity, one
+// row per expectation, with the rows that don't match marked with a "!".
+func formatConnectivityDiff(results []CheckResult) string {
+	width := len("EXPECTED")
+	for _, r := range results {
+		if len(r.Expected) > width {
+			width = len(r.Expected)
+		}
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Connectivity was incorrect, %d of %d checks failed:\n\n", numFailed(results), len(results))
+	fmt.Fprintf(&sb, "    %-*s    %s\n", width, "EXPECTED", "ACTUAL")
+	for _, r := range results {
+		marker := "  "
+		if !r.OK {
+			marker = "! "
+		}
+		fmt.Fprintf(&sb, "  %s%-*s    %s\n", marker, width, r.Expected, r.Actual)
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+// writeFailureSummary writes the summary of a failed check if FailureSummaryDirEnvVar is set.
+// It returns the file name, or "" if no summary was written.
+func writeFailureSummary(results []CheckResult, attempts int) string {
+	dir := os.Getenv(FailureSummaryDirEnvVar)
+	if dir == "" {
+		return ""
+	}
+	test := ginkgo.CurrentGinkgoTestDescription().FullTestText
+	summary := FailureSummary{
+		Test:      test,
+		Time:      time.Now(),
+		Attempts:  attempts,
+		NumFailed: numFailed(results),
+		Checks:    results,
+	}
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		log.WithError(err).Error("Failed to marshal connectivity failure summary")
+		return ""
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.WithError(err).Error("Failed to create connectivity failure summary directory")
+		return ""
+	}
+	fileName := path.Join(dir, utils.SanitizeFileName(test)+".json")
+	if err := ioutil.WriteFile(fileName, append(data, '\n'), 0644); err != nil {
+		log.WithError(err).WithField("file", fileName).Error("Failed to write connectivity failure summary")
+		return ""
+	}
+	return fileName
+}
@@ -693,6 +693,107 @@ func (c *Container) CanConnectTo(ip, port, protocol string, opts ...connectivity
 	return connectivity.Check(c.Name, "Connection test", ip, port, protocol, opts...)
 }
 
+// Source returns a connectivity source that connects from this container, by default from its
+// own network namespace (i.e. the host namespace of a Felix container).  Use the Source's With...
+// and In... methods to choose the source IP, source port or namespace, for example:
+//
+//	cc.ExpectSome(felix.Source().WithPort(8055), w[0])
+func (c *Container) Source() *Source {
+	return &Source{Container: c}
+}
+
+// Source is a ConnectionSource that connects from a container with specific source options, in
+// the same way that workload.Port does for workloads.
+type Source struct {
+	*Container
+
+	// SrcIP is the source IP to bind to; if empty, the kernel chooses.  (Named so as not to
+	// shadow Container.IP.)
+	SrcIP string
+	// SrcPort is the source port to bind to; if 0, the kernel chooses.
+	SrcPort uint16
+	// NamespacePath is the network namespace to connect from; if empty, the container's own.
+	NamespacePath string
+}
+
+// WithIP returns a copy of the source that binds to the given source IP.
+func (s *Source) WithIP(ip string) *Source {
+	cp := *s
+	cp.SrcIP = ip
+	return &cp
+}
+
+// WithPort returns a copy of the source that binds to the given source port.
+func (s *Source) WithPort(port uint16) *Source {
+	cp := *s
+	cp.SrcPort = port
+	return &cp
+}
+
+// InNamespace returns a copy of the source that connects from the given network namespace, for
+// example a workload's, rather than from the container's own namespace.
+func (s *Source) InNamespace(nsPath string) *Source {
+	cp := *s
+	cp.NamespacePath = nsPath
+	return &cp
+}
+
+// InHostNamespace returns a copy of the source that connects from the container's own network
+// namespace.
+func (s *Source) InHostNamespace() *Source {
+	return s.InNamespace("")
+}
+
+func (s *Source) SourceName() string {
+	name := s.Container.Name
+	if s.NamespacePath != "" {
+		name += "(" + s.NamespacePath + ")"
+	}
+	if s.SrcIP != "" {
+		name += "/" + s.SrcIP
+	}
+	if s.SrcPort != 0 {
+		name += fmt.Sprintf(":%d", s.SrcPort)
+	}
+	return name
+}
+
+func (s *Source) SourceIPs() []string {
+	if s.SrcIP != "" {
+		return []string{s.SrcIP}
+	}
+	return s.Container.SourceIPs()
+}
+
+func (s *Source) CanConnectTo(ip, port, protocol string, opts ...connectivity.CheckOption) *connectivity.Result {
+	if (protocol == "udp" || protocol == "sctp") && s.SrcIP != "" {
+		// As for workloads, remove any stale conntrack entries that could otherwise make a
+		// retry succeed or fail regardless of the current policy.
+		if os.Getenv("FELIX_FV_ENABLE_BPF") == "true" {
+			_ = s.Container.ExecMayFail("calico-bpf", "conntrack", "remove", "udp", s.SrcIP, ip)
+		} else {
+			_ = s.Container.ExecMayFail("conntrack", "-D", "-p", protocol, "-s", s.SrcIP, "-d", ip)
+		}
+	}
+
+	logMsg := "Connection test"
+	if s.SrcIP != "" {
+		opts = append(opts, connectivity.WithSourceIP(s.SrcIP))
+		logMsg += " (with source IP)"
+	}
+	if s.SrcPort != 0 {
+		opts = append(opts, connectivity.WithSourcePort(strconv.Itoa(int(s.SrcPort))))
+		logMsg += " (with source port)"
+	}
+	if s.NamespacePath != "" {
+		opts = append(opts, connectivity.WithNamespacePath(s.NamespacePath))
+		logMsg += " (in namespace " + s.NamespacePath + ")"
+	}
+
+	s.Container.EnsureBinary(connectivity.BinaryName)
+	return connectivity.Check(s.Container.Name, logMsg, ip, port, protocol, opts...)
+}
+
 // AttachTCPDump returns tcpdump attached to the container
 func (c *Container) AttachTCPDump(iface string) *tcpdump.TCPDump {
 	return tcpdump.AttachUnavailable(c.GetID(), iface)
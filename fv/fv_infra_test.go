@@ -139,6 +139,41 @@ func describeConnCheckTests(protocol string) bool {
 						Eventually(func() int { return tcpdF.MatchCount("UDP") }).Should(BeNumerically(">", 0))
 						Eventually(func() int { return tcpdW.MatchCount("UDP") }).Should(BeNumerically(">", 0))
 					})
+
+					It("and probes with a 50% threshold, should tolerate packet loss", func() {
+						cc.RetriesDisabled = true
+						cc.Expect(connectivity.Some, felixes[0], hostW[1],
+							connectivity.ExpectWithProbes(50), connectivity.ExpectLossBelow(50))
+						cc.CheckConnectivity()
+					})
+				})
+
+				Describe("with tc configured to delay packets by 200ms", func() {
+					BeforeEach(func() {
+						cc.ExpectSome(felixes[0], hostW[1])
+						cc.CheckConnectivity()
+						cc.ResetExpectations()
+
+						felixes[0].Exec("tc", "qdisc", "add", "dev", "eth0", "root", "netem", "delay", "200ms")
+					})
+
+					It("and a 100ms threshold, should see the latency", func() {
+						failed := false
+						cc.OnFail = func(msg string) {
+							log.WithField("msg", msg).Info("Connectivity checker failed (as expected)")
+							failed = true
+						}
+						cc.RetriesDisabled = true
+						cc.Expect(connectivity.Some, felixes[0], hostW[1], connectivity.ExpectLatencyBelow(100*time.Millisecond))
+						cc.CheckConnectivity()
+
+						Expect(failed).To(BeTrue(), "Expected the connection checker to detect the latency")
+					})
+
+					It("and a 500ms threshold, should tolerate the latency", func() {
+						cc.Expect(connectivity.Some, felixes[0], hostW[1], connectivity.ExpectLatencyBelow(500*time.Millisecond))
+						cc.CheckConnectivity()
+					})
 				})
 			}
 		},
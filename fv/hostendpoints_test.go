@@ -442,6 +442,32 @@ func describeHostEndpointTests(getInfra infrastructure.InfraFactory, allInterfac
 			cc.CheckConnectivity()
 		})
 
+		It("should only allow felixes[0] => felixes[1] traffic from the allowed source port", func() {
+			// Create a policy selecting felix[0] that denies TCP egress from any other source
+			// port.
+			tcp := numorstring.ProtocolFromString("TCP")
+			policy := api.NewGlobalNetworkPolicy()
+			policy.Name = "f0-egress-src-port"
+			policy.Spec.Egress = []api.Rule{{
+				Action:   api.Deny,
+				Protocol: &tcp,
+				Source: api.EntityRule{
+					NotPorts: []numorstring.Port{numorstring.SinglePort(8066)},
+				},
+			}}
+			policy.Spec.Selector = fmt.Sprintf("hostname == '%s'", felixes[0].Hostname)
+			_, err := client.GlobalNetworkPolicies().Create(utils.Ctx, policy, utils.NoOptions)
+			Expect(err).NotTo(HaveOccurred())
+
+			cc.ExpectSome(felixes[0].Source().WithPort(8066), hostW[1])
+			cc.ExpectNone(felixes[0].Source().WithPort(8067), hostW[1])
+			cc.ExpectSome(felixes[0].Source().WithIP(felixes[0].IP).WithPort(8066), hostW[1])
+
+			// Egress from felixes[1] unaffected.
+			cc.ExpectSome(felixes[1], hostW[0])
+			cc.CheckConnectivity()
+		})
+
 		Context("with a policy denying ingress on felixes[1]", func() {
 			BeforeEach(func() {
 				// Create a policy selecting felix[1] that denies ingress.
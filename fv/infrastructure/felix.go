@@ -16,12 +16,15 @@ package infrastructure
 
 import (
 	"fmt"
+	"net"
 	"os"
 	"path"
+	"time"
 
 	. "github.com/onsi/gomega"
 	log "github.com/sirupsen/logrus"
 
+	"github.com/projectcalico/felix/dataplane/recorder"
 	"github.com/projectcalico/felix/fv/containers"
 	"github.com/projectcalico/felix/fv/tcpdump"
 	"github.com/projectcalico/felix/fv/utils"
@@ -52,6 +55,12 @@ type Felix struct {
 	ExternalIP string
 
 	startupDelayed bool
+
+	// protoInjectionSocket is the path (in the shared /tmp directory) of the socket that Felix
+	// listens on for injected dataplane messages, if TopologyOptions.ProtoInjection is set.
+	protoInjectionSocket string
+
+	resourceAccountant *resourceAccountant
 }
 
 func (f *Felix) GetFelixPID() int {
@@ -76,6 +85,28 @@ func (f *Felix) TriggerDelayedStart() {
 	f.startupDelayed = false
 }
 
+// InjectProtoMessages sends the given dataplane messages (such as *proto.IPSetUpdate) straight to
+// Felix's dataplane driver, in order.  Felix must have been started with
+// TopologyOptions.ProtoInjection; in that mode, the test is responsible for sending everything
+// that the calculation graph would, including the final *proto.InSync.
+func (f *Felix) InjectProtoMessages(msgs ...interface{}) {
+	if f.protoInjectionSocket == "" {
+		log.Panic("InjectProtoMessages() called but proto injection isn't enabled")
+	}
+	var conn net.Conn
+	Eventually(func() (err error) {
+		conn, err = net.Dial("unix", f.protoInjectionSocket)
+		return
+	}, "10s", "100ms").Should(Succeed(), "Failed to connect to Felix's proto injection socket")
+	defer conn.Close()
+
+	w, err := recorder.NewWriter(conn)
+	Expect(err).NotTo(HaveOccurred())
+	for _, msg := range msgs {
+		Expect(w.Write(time.Now(), msg)).To(Succeed())
+	}
+}
+
 func RunFelix(infra DatastoreInfra, id int, options TopologyOptions) *Felix {
 	log.Info("Starting felix")
 	ipv6Enabled := fmt.Sprint(options.EnableIPv6)
@@ -117,8 +148,13 @@ func RunFelix(infra DatastoreInfra, id int, options TopologyOptions) *Felix {
 			log.Info("FELIX_FV_ENABLE_BPF=true but test manages BPF state itself, not using env var")
 		}
 
+		// There's no kubelet, and hence no pod cgroups, in the FV environment.
+		envVars["FELIX_DebugSkipCTLBWorkloadCheck"] = "true"
+
 		if CreateCgroupV2 {
 			envVars["FELIX_DEBUGBPFCGROUPV2"] = containerName
+			// The test-connection binary moves itself into the cgroup via our private mount.
+			envVars["FELIX_BPFCGROUPV2ROOT"] = "private"
 		}
 	}
 
@@ -126,6 +162,13 @@ func RunFelix(infra DatastoreInfra, id int, options TopologyOptions) *Felix {
 		envVars["DELAY_FELIX_START"] = "true"
 	}
 
+	var protoInjectionSocket string
+	if options.ProtoInjection {
+		// /tmp is shared with the test process so it can connect to the socket directly.
+		protoInjectionSocket = fmt.Sprintf("/tmp/%s-inject.sock", containerName)
+		envVars["FELIX_DEBUGPROTOINJECTIONSOCKET"] = protoInjectionSocket
+	}
+
 	for k, v := range options.ExtraEnvVars {
 		envVars[k] = v
 	}
@@ -182,13 +225,22 @@ func RunFelix(infra DatastoreInfra, id int, options TopologyOptions) *Felix {
 		"-W", "100000", // How often to probe the lock in microsecs.
 		"-P", "FORWARD", "DROP")
 
-	return &Felix{
-		Container:      c,
-		startupDelayed: options.DelayFelixStart,
+	f := &Felix{
+		Container:            c,
+		startupDelayed:       options.DelayFelixStart,
+		protoInjectionSocket: protoInjectionSocket,
 	}
+	if resourceReportDir() != "" {
+		f.resourceAccountant = startResourceAccounting(f)
+	}
+	return f
 }
 
 func (f *Felix) Stop() {
+	if f.resourceAccountant != nil {
+		f.resourceAccountant.StopAndWriteReport(resourceReportDir())
+		f.resourceAccountant = nil
+	}
 	if CreateCgroupV2 {
 		_ = f.ExecMayFail("rmdir", path.Join("/run/calico/cgroup/", f.Name))
 	}
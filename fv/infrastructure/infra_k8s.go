@@ -485,6 +485,7 @@ func (kds *K8sDatastoreInfra) CleanUp() {
 		cleanupAllNetworkPolicies,
 		cleanupAllHostEndpoints,
 		cleanupAllFelixConfigurations,
+		cleanupAllBGPConfigurations,
 		cleanupAllServices,
 	} {
 		f(kds.K8sClient, kds.calicoClient)
@@ -963,6 +964,23 @@ func cleanupAllFelixConfigurations(clientset *kubernetes.Clientset, client clien
 	log.Info("Cleaned up felix configurations")
 }
 
+func cleanupAllBGPConfigurations(clientset *kubernetes.Clientset, client client.Interface) {
+	log.Info("Cleaning up BGP configurations")
+	ctx := context.Background()
+	bcs, err := client.BGPConfigurations().List(ctx, options.ListOptions{})
+	if err != nil {
+		panic(err)
+	}
+	log.WithField("count", len(bcs.Items)).Info("BGPConfigurations present")
+	for _, bc := range bcs.Items {
+		_, err = client.BGPConfigurations().Delete(ctx, bc.Name, options.DeleteOptions{})
+		if err != nil {
+			panic(err)
+		}
+	}
+	log.Info("Cleaned up BGP configurations")
+}
+
 func cleanupAllServices(clientset *kubernetes.Clientset, calicoClient client.Interface) {
 	log.Info("Cleaning up services")
 	coreV1 := clientset.CoreV1()
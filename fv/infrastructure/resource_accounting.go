@@ -0,0 +1,297 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package infrastructure
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/onsi/ginkgo"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/fv/utils"
+)
+
+// ResourceReportDirEnvVar names the environment variable that enables per-test resource accounting.
+// When set, each Felix container is sampled periodically while it runs and a JSON report is written
+// into the named directory when the container is stopped.
+const ResourceReportDirEnvVar = "FELIX_FV_RESOURCE_REPORT_DIR"
+
+const resourceSampleInterval = 2 * time.Second
+
+// ResourceSample is a single point-in-time measurement of a Felix container.
+type ResourceSample struct {
+	Time          time.Time `json:"time"`
+	CPUPercent    float64   `json:"cpuPercent"`
+	MemoryBytes   uint64    `json:"memoryBytes"`
+	IptablesRules int       `json:"iptablesRules"`
+	IPSets        int       `json:"ipSets"`
+	IPSetMembers  int       `json:"ipSetMembers"`
+}
+
+// ResourceReport is the per-test, per-container report that is written to disk.
+type ResourceReport struct {
+	Test      string           `json:"test"`
+	Container string           `json:"container"`
+	Start     time.Time        `json:"start"`
+	End       time.Time        `json:"end"`
+	Samples   []ResourceSample `json:"samples"`
+
+	MaxCPUPercent    float64 `json:"maxCPUPercent"`
+	MaxMemoryBytes   uint64  `json:"maxMemoryBytes"`
+	MaxIptablesRules int     `json:"maxIptablesRules"`
+	MaxIPSetMembers  int     `json:"maxIPSetMembers"`
+}
+
+// resourceAccountant samples a Felix container in the background until it is stopped.
+type resourceAccountant struct {
+	felix    *Felix
+	test     string
+	start    time.Time
+	stopC    chan struct{}
+	wg       sync.WaitGroup
+	lock     sync.Mutex
+	samples  []ResourceSample
+	interval time.Duration
+}
+
+func resourceReportDir() string {
+	return os.Getenv(ResourceReportDirEnvVar)
+}
+
+func startResourceAccounting(f *Felix) *resourceAccountant {
+	ra := &resourceAccountant{
+		felix:    f,
+		test:     ginkgo.CurrentGinkgoTestDescription().FullTestText,
+		start:    time.Now(),
+		stopC:    make(chan struct{}),
+		interval: resourceSampleInterval,
+	}
+	ra.wg.Add(1)
+	go ra.loop()
+	return ra
+}
+
+func (ra *resourceAccountant) loop() {
+	defer ra.wg.Done()
+	ticker := time.NewTicker(ra.interval)
+	defer ticker.Stop()
+	for {
+		ra.takeSample()
+		select {
+		case <-ra.stopC:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (ra *resourceAccountant) takeSample() {
+	s := ResourceSample{Time: time.Now()}
+	logCxt := log.WithField("container", ra.felix.Name)
+
+	// Skip the whole sample if any measurement fails, rather than recording zeros that would
+	// look like real (and suspiciously good) numbers in the report.
+	out, err := utils.Command("docker", "stats", "--no-stream",
+		"--format", "{{.CPUPerc}};{{.MemUsage}}", ra.felix.Name).Output()
+	if err != nil {
+		logCxt.WithError(err).Debug("docker stats failed, skipping sample")
+		return
+	}
+	s.CPUPercent, s.MemoryBytes, err = parseDockerStats(string(out))
+	if err != nil {
+		logCxt.WithError(err).Warn("Failed to parse docker stats, skipping sample")
+		return
+	}
+
+	for _, cmd := range []string{"iptables-save", "ip6tables-save"} {
+		out, err := ra.felix.ExecOutput(cmd)
+		if err != nil {
+			logCxt.WithError(err).Warnf("%s failed, skipping sample", cmd)
+			return
+		}
+		n, err := countIptablesRules(out)
+		if err != nil {
+			logCxt.WithError(err).Warnf("Failed to parse %s output, skipping sample", cmd)
+			return
+		}
+		s.IptablesRules += n
+	}
+
+	ipsetOut, err := ra.felix.ExecOutput("ipset", "save")
+	if err != nil {
+		logCxt.WithError(err).Warn("ipset save failed, skipping sample")
+		return
+	}
+	s.IPSets, s.IPSetMembers, err = countIPSets(ipsetOut)
+	if err != nil {
+		logCxt.WithError(err).Warn("Failed to parse ipset save output, skipping sample")
+		return
+	}
+
+	ra.lock.Lock()
+	ra.samples = append(ra.samples, s)
+	ra.lock.Unlock()
+}
+
+// StopAndWriteReport stops sampling and writes the report into the given directory.
+func (ra *resourceAccountant) StopAndWriteReport(dir string) {
+	close(ra.stopC)
+	ra.wg.Wait()
+
+	ra.lock.Lock()
+	defer ra.lock.Unlock()
+	report := ResourceReport{
+		Test:      ra.test,
+		Container: ra.felix.Name,
+		Start:     ra.start,
+		End:       time.Now(),
+		Samples:   ra.samples,
+	}
+	for _, s := range ra.samples {
+		if s.CPUPercent > report.MaxCPUPercent {
+			report.MaxCPUPercent = s.CPUPercent
+		}
+		if s.MemoryBytes > report.MaxMemoryBytes {
+			report.MaxMemoryBytes = s.MemoryBytes
+		}
+		if s.IptablesRules > report.MaxIptablesRules {
+			report.MaxIptablesRules = s.IptablesRules
+		}
+		if s.IPSetMembers > report.MaxIPSetMembers {
+			report.MaxIPSetMembers = s.IPSetMembers
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		log.WithError(err).Error("Failed to create resource report directory")
+		return
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		log.WithError(err).Error("Failed to marshal resource report")
+		return
+	}
+	fileName := path.Join(dir, fmt.Sprintf("%s-%s.json", utils.SanitizeFileName(ra.test), ra.felix.Name))
+	if err := ioutil.WriteFile(fileName, data, 0644); err != nil {
+		log.WithError(err).WithField("file", fileName).Error("Failed to write resource report")
+		return
+	}
+	log.WithField("file", fileName).Info("Wrote resource report")
+}
+
+// parseDockerStats parses the output of docker stats with the format "{{.CPUPerc}};{{.MemUsage}}",
+// for example "1.23%;45.6MiB / 7.7GiB".
+func parseDockerStats(out string) (cpuPercent float64, memBytes uint64, err error) {
+	parts := strings.SplitN(strings.TrimSpace(out), ";", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("unexpected docker stats output %q", out)
+	}
+	cpu := strings.TrimSpace(parts[0])
+	if !strings.HasSuffix(cpu, "%") {
+		return 0, 0, fmt.Errorf("unexpected CPU percentage %q", cpu)
+	}
+	cpuPercent, err = strconv.ParseFloat(strings.TrimSuffix(cpu, "%"), 64)
+	if err != nil || cpuPercent < 0 {
+		return 0, 0, fmt.Errorf("unexpected CPU percentage %q", cpu)
+	}
+	mem := strings.SplitN(parts[1], "/", 2)
+	if len(mem) != 2 {
+		return 0, 0, fmt.Errorf("unexpected memory usage %q", parts[1])
+	}
+	memBytes, err = parseByteSize(strings.TrimSpace(mem[0]))
+	if err != nil {
+		return 0, 0, err
+	}
+	return cpuPercent, memBytes, nil
+}
+
+var byteSizeUnits = []struct {
+	suffix string
+	mult   float64
+}{
+	// Longest suffixes first so that "MiB" doesn't match "B".
+	{"KiB", 1 << 10},
+	{"MiB", 1 << 20},
+	{"GiB", 1 << 30},
+	{"TiB", 1 << 40},
+	{"kB", 1e3},
+	{"KB", 1e3},
+	{"MB", 1e6},
+	{"GB", 1e9},
+	{"TB", 1e12},
+	{"B", 1},
+}
+
+func parseByteSize(s string) (uint64, error) {
+	for _, u := range byteSizeUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			f, err := strconv.ParseFloat(strings.TrimSpace(strings.TrimSuffix(s, u.suffix)), 64)
+			if err != nil || f < 0 {
+				return 0, fmt.Errorf("invalid byte size %q", s)
+			}
+			return uint64(f * u.mult), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown byte size %q", s)
+}
+
+// countIptablesRules counts the rules in the output of iptables-save.  It returns an error if the
+// output isn't in the iptables-save format.
+func countIptablesRules(save string) (n int, err error) {
+	inTable := false
+	for _, line := range strings.Split(save, "\n") {
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "*"):
+			inTable = true
+		case line == "COMMIT":
+			inTable = false
+		case inTable && strings.HasPrefix(line, ":"):
+		case inTable && strings.HasPrefix(line, "-A "):
+			n++
+		default:
+			return 0, fmt.Errorf("unexpected line in iptables-save output: %q", line)
+		}
+	}
+	if inTable {
+		return 0, fmt.Errorf("truncated iptables-save output, missing COMMIT")
+	}
+	return n, nil
+}
+
+// countIPSets counts the IP sets and their members in the output of ipset save.  It returns an
+// error if the output isn't in the ipset save format.
+func countIPSets(save string) (sets, members int, err error) {
+	for _, line := range strings.Split(save, "\n") {
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "create "):
+			sets++
+		case strings.HasPrefix(line, "add "):
+			members++
+		default:
+			return 0, 0, fmt.Errorf("unexpected line in ipset save output: %q", line)
+		}
+	}
+	return sets, members, nil
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package infrastructure
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = DescribeTable("parseDockerStats",
+	func(out string, expectedCPU float64, expectedMem uint64, expectErr bool) {
+		cpu, mem, err := parseDockerStats(out)
+		if expectErr {
+			Expect(err).To(HaveOccurred())
+			return
+		}
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cpu).To(Equal(expectedCPU))
+		Expect(mem).To(Equal(expectedMem))
+	},
+	Entry("binary units", "1.5%;45MiB / 7.7GiB\n", 1.5, uint64(45<<20), false),
+	Entry("decimal units", "0.00%;512kB / 2GB", 0.0, uint64(512000), false),
+	Entry("bytes", "100%;10B / 1GiB", 100.0, uint64(10), false),
+	Entry("empty output", "", 0.0, uint64(0), true),
+	Entry("missing memory", "1.5%", 0.0, uint64(0), true),
+	Entry("stopped container", "--;-- / --", 0.0, uint64(0), true),
+	Entry("CPU without percent sign", "1.5;45MiB / 7.7GiB", 0.0, uint64(0), true),
+	Entry("negative CPU", "-1%;45MiB / 7.7GiB", 0.0, uint64(0), true),
+	Entry("missing memory limit", "1.5%;45MiB", 0.0, uint64(0), true),
+	Entry("unknown memory unit", "1.5%;45PiB / 7.7GiB", 0.0, uint64(0), true),
+	Entry("bad memory value", "1.5%;4.5.6MiB / 7.7GiB", 0.0, uint64(0), true),
+	Entry("negative memory", "1.5%;-45MiB / 7.7GiB", 0.0, uint64(0), true),
+)
+
+var _ = DescribeTable("countIptablesRules",
+	func(save string, expected int, expectErr bool) {
+		n, err := countIptablesRules(save)
+		if expectErr {
+			Expect(err).To(HaveOccurred())
+			return
+		}
+		Expect(err).NotTo(HaveOccurred())
+		Expect(n).To(Equal(expected))
+	},
+	Entry("empty output", "", 0, false),
+	Entry("two tables",
+		"# Generated by iptables-save\n"+
+			"*filter\n"+
+			":INPUT ACCEPT [0:0]\n"+
+			":cali-INPUT - [0:0]\n"+
+			"-A INPUT -j cali-INPUT\n"+
+			"-A cali-INPUT -j ACCEPT\n"+
+			"COMMIT\n"+
+			"*nat\n"+
+			"-A POSTROUTING -j MASQUERADE\n"+
+			"COMMIT\n",
+		3, false),
+	Entry("missing COMMIT", "*filter\n-A INPUT -j ACCEPT\n", 0, true),
+	Entry("rule outside a table", "-A INPUT -j ACCEPT\n", 0, true),
+	Entry("error message", "iptables-save: command not found\n", 0, true),
+)
+
+var _ = DescribeTable("countIPSets",
+	func(save string, expectedSets, expectedMembers int, expectErr bool) {
+		sets, members, err := countIPSets(save)
+		if expectErr {
+			Expect(err).To(HaveOccurred())
+			return
+		}
+		Expect(err).NotTo(HaveOccurred())
+		Expect(sets).To(Equal(expectedSets))
+		Expect(members).To(Equal(expectedMembers))
+	},
+	Entry("empty output", "", 0, 0, false),
+	Entry("sets and members",
+		"create cali40all-hosts hash:ip family inet hashsize 1024 maxelem 1048576\n"+
+			"add cali40all-hosts 10.0.0.1\n"+
+			"add cali40all-hosts 10.0.0.2\n"+
+			"create cali40masq-ipam-pools hash:net family inet hashsize 1024 maxelem 1048576\n",
+		2, 2, false),
+	Entry("error message", "ipset v7.1: Kernel error received: Operation not permitted\n", 0, 0, true),
+)
@@ -58,6 +58,9 @@ type TopologyOptions struct {
 	ExternalIPs               bool
 	UseIPPools                bool
 	NeedNodeIP                bool
+	// ProtoInjection makes Felix's dataplane driver take its updates from the test (via
+	// Felix.InjectProtoMessages()) instead of from the calculation graph.
+	ProtoInjection bool
 }
 
 func DefaultTopologyOptions() TopologyOptions {
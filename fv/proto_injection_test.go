@@ -0,0 +1,81 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// +build fvtests
+
+package fv_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/libcalico-go/lib/apiconfig"
+
+	"github.com/projectcalico/felix/fv/infrastructure"
+	"github.com/projectcalico/felix/proto"
+)
+
+var _ = infrastructure.DatastoreDescribe("proto injection", []apiconfig.DatastoreType{apiconfig.EtcdV3}, func(getInfra infrastructure.InfraFactory) {
+	var (
+		infra infrastructure.DatastoreInfra
+		felix *infrastructure.Felix
+	)
+
+	BeforeEach(func() {
+		infra = getInfra()
+		opts := infrastructure.DefaultTopologyOptions()
+		opts.ProtoInjection = true
+		felix, _ = infrastructure.StartSingleNodeTopology(opts, infra)
+	})
+
+	AfterEach(func() {
+		if CurrentGinkgoTestDescription().Failed {
+			felix.Exec("ipset", "list")
+		}
+		felix.Stop()
+		if CurrentGinkgoTestDescription().Failed {
+			infra.DumpErrorData()
+		}
+		infra.Stop()
+	})
+
+	ipSetMembers := func() (string, error) {
+		return felix.ExecOutput("ipset", "list", "cali40s:fv-injected")
+	}
+
+	It("should program the dataplane from injected messages", func() {
+		felix.InjectProtoMessages(
+			&proto.IPSetUpdate{
+				Id:      "s:fv-injected",
+				Type:    proto.IPSetUpdate_IP,
+				Members: []string{"10.65.0.1"},
+			},
+			&proto.InSync{},
+		)
+		Eventually(ipSetMembers, "10s", "100ms").Should(ContainSubstring("10.65.0.1"))
+
+		// A later connection carries on from where the last one left off.
+		felix.InjectProtoMessages(
+			&proto.IPSetDeltaUpdate{
+				Id:             "s:fv-injected",
+				AddedMembers:   []string{"10.65.0.2"},
+				RemovedMembers: []string{"10.65.0.1"},
+			},
+		)
+		Eventually(ipSetMembers, "10s", "100ms").Should(And(
+			ContainSubstring("10.65.0.2"),
+			Not(ContainSubstring("10.65.0.1")),
+		))
+	})
+})
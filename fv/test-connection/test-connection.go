@@ -18,16 +18,21 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"math"
 	"net"
+	"net/http"
 	"os"
 	"os/exec"
 	"strconv"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/containernetworking/plugins/pkg/ns"
@@ -36,6 +41,7 @@ import (
 	"github.com/ishidawataru/sctp"
 	reuse "github.com/libp2p/go-reuseport"
 	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
 
 	"github.com/projectcalico/felix/fv/cgroup"
 	"github.com/projectcalico/felix/fv/connectivity"
@@ -45,19 +51,28 @@ import (
 const usage = `test-connection: test connection to some target, for Felix FV testing.
 
 Usage:
-  test-connection <namespace-path> <ip-address> <port> [--source-ip=<source_ip>] [--source-port=<source>] [--protocol=<protocol>] [--duration=<seconds>] [--loop-with-file=<file>] [--sendlen=<bytes>] [--recvlen=<bytes>] [--log-pongs] [--stdin]
+  test-connection <namespace-path> <ip-address> <port> [--source-ip=<source_ip>] [--source-port=<source>] [--source-iface=<iface>] [--protocol=<protocol>] [--duration=<seconds>] [--probes=<n>] [--loop-with-file=<file>] [--sendlen=<bytes>] [--recvlen=<bytes>] [--log-pongs] [--stdin] [--http-path=<path>] [--sni=<server_name>] [--expected-status=<code>]
 
 Options:
   --source-ip=<source_ip>  Source IP to use for the connection [default: 0.0.0.0].
   --source-port=<source>   Source port to use for the connection [default: 0].
-  --protocol=<protocol>    Protocol to test tcp (default), udp (connected) udp-noconn (unconnected).
+  --source-iface=<iface>   Bind the socket to this interface (SO_BINDTODEVICE) so that traffic egresses through it
+                           regardless of routing.
+  --protocol=<protocol>    Protocol to test tcp (default), udp (connected) udp-noconn (unconnected), or
+                           http/https (a single HTTP GET request over TCP).
   --duration=<seconds>     Total seconds test should run. 0 means run a one off connectivity check. Non-Zero means packets loss test.[default: 0]
+  --probes=<n>             Send n requests, one at a time, and report the round-trip time of each.  A request
+                           without a response within a second counts as lost. [default: 0]
   --loop-with-file=<file>  Whether to send messages repeatedly, file is used for synchronization
   --log-pongs              Whether to log every response
   --debug                  Enable debug logging
   --sendlen=<bytes>        How many additional bytes to send
   --recvlen=<bytes>        Tell the other side to send this many additional bytes
   --stdin                  Read and send data from stdin
+  --http-path=<path>       Path to request in http/https mode [default: /].
+  --sni=<server_name>      In https mode, the server name to send in the TLS handshake (and in the Host
+                           header).  Defaults to the target IP.
+  --expected-status=<code>  In http/https mode, the HTTP status that counts as success [default: 200].
 
 If connection is successful, test-connection exits successfully.
 
@@ -103,6 +118,7 @@ func main() {
 		sourcePort = arguments["--source-port"].(string)
 	}
 	sourceIpAddress := arguments["--source-ip"].(string)
+	sourceIface, _ := arguments["--source-iface"].(string)
 	if debug, err := arguments.Bool("--debug"); err == nil && debug {
 		log.SetLevel(log.DebugLevel)
 		log.Debug("Debug logging enabled")
@@ -133,6 +149,10 @@ func main() {
 		// panic on error
 		log.WithField("duration", duration).Fatal("Invalid duration argument")
 	}
+	probes, err := strconv.Atoi(arguments["--probes"].(string))
+	if err != nil || probes < 0 {
+		log.WithField("probes", arguments["--probes"]).Fatal("Invalid probes argument")
+	}
 	loopFile := ""
 	if arg, ok := arguments["--loop-with-file"]; ok && arg != nil {
 		loopFile = arg.(string)
@@ -148,16 +168,25 @@ func main() {
 		log.WithError(err).Fatal("Invalid --stdin")
 	}
 
-	log.Infof("Test connection from namespace %v IP %v port %v to IP %v port %v proto %v "+
+	httpOpts := httpOptions{
+		path: arguments["--http-path"].(string),
+	}
+	httpOpts.sni, _ = arguments["--sni"].(string)
+	httpOpts.expectedStatus, err = strconv.Atoi(arguments["--expected-status"].(string))
+	if err != nil {
+		log.WithError(err).Fatal("Invalid --expected-status")
+	}
+
+	log.Infof("Test connection from namespace %v IP %v port %v iface %q to IP %v port %v proto %v "+
 		"max duration %d seconds, logging pongs (%v), stdin %v",
-		namespacePath, sourceIpAddress, sourcePort, ipAddress, port, protocol, seconds, logPongs, stdin)
+		namespacePath, sourceIpAddress, sourcePort, sourceIface, ipAddress, port, protocol, seconds, logPongs, stdin)
 
 	if loopFile == "" {
 		// I found that configuring the timeouts on all the network calls was a bit fiddly.  Since
 		// it leaves the process hung if one of them is missed, use a global timeout instead.
 		go func() {
-			timeout := time.Duration(seconds + 2)
-			time.Sleep(timeout * time.Second)
+			timeout := time.Duration(seconds+2)*time.Second + time.Duration(probes)*probeTimeout
+			time.Sleep(timeout)
 			log.Fatal("Timed out")
 		}()
 	}
@@ -167,8 +196,8 @@ func main() {
 		err = maybeAddAddr(sourceIpAddress)
 		// Test connection from wherever we are already running.
 		if err == nil {
-			err = tryConnect(ipAddress, port, sourceIpAddress, sourcePort, protocol,
-				seconds, loopFile, sendLen, recvLen, logPongs, stdin)
+			err = tryConnect(ipAddress, port, sourceIpAddress, sourcePort, sourceIface, protocol,
+				seconds, probes, loopFile, sendLen, recvLen, logPongs, stdin, httpOpts)
 		}
 	} else {
 		// Get the specified network namespace (representing a workload).
@@ -186,8 +215,8 @@ func main() {
 			if e != nil {
 				return e
 			}
-			return tryConnect(ipAddress, port, sourceIpAddress, sourcePort, protocol,
-				seconds, loopFile, sendLen, recvLen, logPongs, stdin)
+			return tryConnect(ipAddress, port, sourceIpAddress, sourcePort, sourceIface, protocol,
+				seconds, probes, loopFile, sendLen, recvLen, logPongs, stdin, httpOpts)
 		})
 	}
 
@@ -230,6 +259,7 @@ type testConn struct {
 	config   connectivity.ConnConfig
 	protocol protocolDriver
 	duration time.Duration
+	probes   int
 
 	sendLen int
 	recvLen int
@@ -240,12 +270,13 @@ type protocolDriver interface {
 	Connect() error
 	Send(msg []byte) error
 	Receive() ([]byte, error)
+	SetReadDeadline(t time.Time) error
 	Close() error
 
 	MTU() (int, error)
 }
 
-func NewTestConn(remoteIpAddr, remotePort, sourceIpAddr, sourcePort, protocol string,
+func NewTestConn(remoteIpAddr, remotePort, sourceIpAddr, sourcePort, sourceIface, protocol string,
 	duration time.Duration, sendLen, recvLen int, stdin bool) (*testConn, error) {
 	err := utils.RunCommand("ip", "r")
 	if err != nil {
@@ -269,43 +300,52 @@ func NewTestConn(remoteIpAddr, remotePort, sourceIpAddr, sourcePort, protocol st
 	}
 
 	log.Infof("Connecting from %v to %v over %s", localAddr, remoteAddr, protocol)
+	if sourceIface != "" {
+		log.Infof("Binding socket to interface %s", sourceIface)
+	}
 
 	var driver protocolDriver
 
 	if strings.HasPrefix(protocol, "ip") {
 		driver = &rawIP{
-			localAddr:  localAddr,
-			remoteAddr: remoteAddr,
-			protocol:   protocol,
+			localAddr:   localAddr,
+			remoteAddr:  remoteAddr,
+			sourceIface: sourceIface,
+			protocol:    protocol,
 		}
 	} else {
 		switch protocol {
 		case "udp":
 			driver = &connectedUDP{
-				localAddr:  localAddr,
-				remoteAddr: remoteAddr,
+				localAddr:   localAddr,
+				remoteAddr:  remoteAddr,
+				sourceIface: sourceIface,
 			}
 		case "udp-recvmsg":
 			driver = &connectedUDP{
 				localAddr:   localAddr,
 				remoteAddr:  remoteAddr,
+				sourceIface: sourceIface,
 				useReadFrom: true,
 			}
 		case "udp-noconn":
 			driver = &unconnectedUDP{
-				localAddr:  localAddr,
-				remoteAddr: remoteAddr,
+				localAddr:   localAddr,
+				remoteAddr:  remoteAddr,
+				sourceIface: sourceIface,
 			}
 		case "sctp":
 			driver = &connectedSCTP{
 				sourcePort:   sourcePort,
+				sourceIface:  sourceIface,
 				remoteIpAddr: remoteIpAddr,
 				remotePort:   remotePort,
 			}
 		default:
 			driver = &connectedTCP{
-				localAddr:  localAddr,
-				remoteAddr: remoteAddr,
+				localAddr:   localAddr,
+				remoteAddr:  remoteAddr,
+				sourceIface: sourceIface,
 			}
 		}
 	}
@@ -337,10 +377,15 @@ func NewTestConn(remoteIpAddr, remotePort, sourceIpAddr, sourcePort, protocol st
 
 }
 
-func tryConnect(remoteIPAddr, remotePort, sourceIPAddr, sourcePort, protocol string,
-	seconds int, loopFile string, sendLen, recvLen int, logPongs, stdin bool) error {
+func tryConnect(remoteIPAddr, remotePort, sourceIPAddr, sourcePort, sourceIface, protocol string,
+	seconds, probes int, loopFile string, sendLen, recvLen int, logPongs, stdin bool, httpOpts httpOptions) error {
+
+	if protocol == "http" || protocol == "https" {
+		// HTTP has its own request/response, so it doesn't fit the protocolDriver model.
+		return tryHTTP(remoteIPAddr, remotePort, sourceIPAddr, sourcePort, sourceIface, protocol, httpOpts)
+	}
 
-	tc, err := NewTestConn(remoteIPAddr, remotePort, sourceIPAddr, sourcePort, protocol,
+	tc, err := NewTestConn(remoteIPAddr, remotePort, sourceIPAddr, sourcePort, sourceIface, protocol,
 		time.Duration(seconds)*time.Second, sendLen, recvLen, stdin)
 	if err != nil {
 		tc.sendErrorResp(err)
@@ -349,6 +394,7 @@ func tryConnect(remoteIPAddr, remotePort, sourceIPAddr, sourcePort, protocol str
 	defer func() {
 		_ = tc.Close()
 	}()
+	tc.probes = probes
 
 	if remotePort == "6443" {
 		// Testing for connectivity to the Kubernetes API server.  If we reach here, we're
@@ -396,6 +442,10 @@ func tryConnect(remoteIPAddr, remotePort, sourceIPAddr, sourcePort, protocol str
 		return tc.tryLoopFile(loopFile, logPongs)
 	}
 
+	if tc.probes > 0 {
+		return tc.tryConnectWithProbes()
+	}
+
 	if tc.config.ConnType == connectivity.ConnectionTypePing {
 		return tc.tryConnectOnceOff()
 	}
@@ -403,6 +453,94 @@ func tryConnect(remoteIPAddr, remotePort, sourceIPAddr, sourcePort, protocol str
 	return tc.tryConnectWithPacketLoss()
 }
 
+// httpOptions are the parameters of the request in http/https mode.
+type httpOptions struct {
+	path           string
+	sni            string
+	expectedStatus int
+}
+
+// tryHTTP makes a single HTTP GET request to the target and checks the status of the response.
+// Unlike the other protocols, the server needn't be our test-workload; any web server or proxy
+// will do.  The certificate of an HTTPS server is not verified, since FV servers use self-signed
+// certificates.
+func tryHTTP(remoteIPAddr, remotePort, sourceIPAddr, sourcePort, sourceIface, protocol string,
+	opts httpOptions) error {
+
+	localAddr := net.JoinHostPort(sourceIPAddr, sourcePort)
+	remoteAddr := net.JoinHostPort(remoteIPAddr, remotePort)
+	log.Infof("Connecting from %v to %v over %s", localAddr, remoteAddr, protocol)
+
+	var conn net.Conn
+	transport := &http.Transport{
+		// Always dial the target, with our source address, whatever the URL's host.
+		DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+			c, err := dial("tcp", localAddr, remoteAddr, sourceIface)
+			if err == nil {
+				conn = c
+			}
+			return c, err
+		},
+		TLSClientConfig: &tls.Config{
+			ServerName:         opts.sni,
+			InsecureSkipVerify: true,
+		},
+		DisableKeepAlives: true,
+	}
+	client := &http.Client{
+		Transport: transport,
+		// Report redirects as they are, rather than following them somewhere else.
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	host := remoteAddr
+	if opts.sni != "" {
+		host = opts.sni
+	}
+	url := fmt.Sprintf("%s://%s%s", protocol, host, opts.path)
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(ioutil.Discard, resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	log.WithFields(log.Fields{
+		"url":    url,
+		"status": resp.StatusCode,
+	}).Info("Received HTTP response")
+
+	now := time.Now()
+	res := connectivity.Result{
+		LastResponse: connectivity.Response{
+			Timestamp:  now,
+			SourceAddr: conn.LocalAddr().String(),
+			ServerAddr: conn.RemoteAddr().String(),
+			Request: connectivity.Request{
+				Timestamp: now,
+				Payload:   "GET " + url,
+			},
+		},
+		Stats: connectivity.Stats{
+			RequestsSent:      1,
+			ResponsesReceived: 1,
+		},
+	}
+	if resp.StatusCode != opts.expectedStatus {
+		res.LastResponse.ErrorStr = fmt.Sprintf("unexpected HTTP status %d, expected %d",
+			resp.StatusCode, opts.expectedStatus)
+		res.Stats.ResponsesReceived = 0
+		res.PrintToStdout()
+		return errors.New(res.LastResponse.ErrorStr)
+	}
+	res.PrintToStdout()
+	return nil
+}
+
 func (tc *testConn) GetTestMessage(sequence int) connectivity.Request {
 	req := tc.config.GetTestMessage(sequence)
 	req.SendSize = tc.sendLen
@@ -687,6 +825,86 @@ func (tc *testConn) tryConnectWithPacketLoss() error {
 	return nil
 }
 
+// probeTimeout is how long probe mode waits for the response to each request.
+const probeTimeout = time.Second
+
+// tryConnectWithProbes sends tc.probes requests, one at a time, and records the round-trip time
+// of each.  Unlike the packet loss test, which streams requests as fast as it can, each request is
+// only sent once the previous one has been answered (or has timed out) so the round-trip times
+// aren't inflated by queueing.
+func (tc *testConn) tryConnectWithProbes() error {
+	log.Infof("Sending %d probes...", tc.probes)
+
+	var lastResponse connectivity.Response
+	probes := make([]connectivity.Probe, 0, tc.probes)
+	for seq := 0; seq < tc.probes; seq++ {
+		req := tc.GetTestMessage(seq)
+		msg, err := json.Marshal(req)
+		if err != nil {
+			log.WithError(err).Panic("Failed to marshall request")
+		}
+
+		start := time.Now()
+		err = tc.protocol.Send(msg)
+		if err != nil {
+			log.WithError(err).Fatal("Failed to send")
+		}
+		tc.stat.totalReq++
+
+		resp, err := tc.receiveResponseTo(req, start.Add(probeTimeout))
+		if err != nil {
+			log.WithError(err).WithField("seq", seq).Info("Probe lost")
+			probes = append(probes, connectivity.Probe{Lost: true})
+			continue
+		}
+		rtt := time.Since(start)
+		log.WithFields(log.Fields{"seq": seq, "rtt": rtt}).Debug("Probe succeeded")
+		tc.stat.totalReply++
+		lastResponse = resp
+		probes = append(probes, connectivity.Probe{RTT: rtt})
+	}
+
+	res := connectivity.Result{
+		LastResponse: lastResponse,
+		Stats: connectivity.Stats{
+			RequestsSent:      tc.stat.totalReq,
+			ResponsesReceived: tc.stat.totalReply,
+			Probes:            probes,
+		},
+	}
+	res.PrintToStdout()
+
+	return nil
+}
+
+// receiveResponseTo waits until the deadline for the response to the given request.  It discards
+// anything else that it receives, such as a late response to an earlier request.
+func (tc *testConn) receiveResponseTo(req connectivity.Request, deadline time.Time) (connectivity.Response, error) {
+	if err := tc.protocol.SetReadDeadline(deadline); err != nil {
+		log.WithError(err).Fatal("Failed to set read deadline")
+	}
+	defer func() {
+		_ = tc.protocol.SetReadDeadline(time.Time{})
+	}()
+
+	for {
+		respRaw, err := tc.protocol.Receive()
+		if err != nil {
+			return connectivity.Response{}, err
+		}
+		var resp connectivity.Response
+		if err := json.Unmarshal(respRaw, &resp); err != nil {
+			log.WithError(err).Warning("Failed to unmarshall response")
+			continue
+		}
+		if !resp.Request.Equal(req) {
+			log.WithField("reply", resp).Info("Ignoring response to an earlier request")
+			continue
+		}
+		return resp, nil
+	}
+}
+
 func (tc *testConn) Close() error {
 	return tc.protocol.Close()
 }
@@ -746,6 +964,7 @@ type connectedUDP struct {
 	r           *bufio.Reader
 	localAddr   string
 	remoteAddr  string
+	sourceIface string
 	useReadFrom bool
 }
 
@@ -763,7 +982,7 @@ func (d *connectedUDP) Connect() error {
 	// another call to this program, the original port is in post-close wait
 	// state and bind fails.  The reuse library implements a Dial() that sets
 	// these options.
-	conn, err := reuse.Dial("udp", d.localAddr, d.remoteAddr)
+	conn, err := dial("udp", d.localAddr, d.remoteAddr, d.sourceIface)
 	if err != nil {
 		return err
 	}
@@ -795,6 +1014,10 @@ func (d *connectedUDP) Receive() ([]byte, error) {
 	}
 }
 
+func (d *connectedUDP) SetReadDeadline(t time.Time) error {
+	return d.conn.SetReadDeadline(t)
+}
+
 func (d *connectedUDP) MTU() (int, error) {
 	return utils.ConnMTU(d.conn)
 }
@@ -805,6 +1028,7 @@ type unconnectedUDP struct {
 	conn               net.PacketConn
 	localAddr          string
 	remoteAddr         string
+	sourceIface        string
 	remoteAddrResolved *net.UDPAddr
 }
 
@@ -817,7 +1041,7 @@ func (d *unconnectedUDP) Close() error {
 
 func (d *unconnectedUDP) Connect() error {
 	log.Info("'Connecting' unconnected UDP")
-	conn, err := net.ListenPacket("udp", d.localAddr)
+	conn, err := listenPacket("udp", d.localAddr, d.sourceIface)
 	if err != nil {
 		log.WithError(err).Fatal("Failed to listen UDP")
 	}
@@ -854,6 +1078,10 @@ func (d *unconnectedUDP) Receive() ([]byte, error) {
 	return bufIn[:n], err
 }
 
+func (d *unconnectedUDP) SetReadDeadline(t time.Time) error {
+	return d.conn.SetReadDeadline(t)
+}
+
 func (d *unconnectedUDP) MTU() (int, error) {
 	return 0, nil
 }
@@ -861,6 +1089,7 @@ func (d *unconnectedUDP) MTU() (int, error) {
 // connectedSCTP abstracts an SCTP stream.
 type connectedSCTP struct {
 	sourcePort   string
+	sourceIface  string
 	remoteIpAddr string
 	remotePort   string
 
@@ -874,6 +1103,7 @@ type connectedSCTP struct {
 type rawIP struct {
 	localAddr          string
 	remoteAddr         string
+	sourceIface        string
 	protocol           string
 	remoteAddrResolved net.Addr
 
@@ -896,7 +1126,7 @@ func (d *rawIP) Connect() error {
 		return err
 	}
 
-	d.conn, err = net.ListenPacket(d.protocol, d.localAddr)
+	d.conn, err = listenPacket(d.protocol, d.localAddr, d.sourceIface)
 	if err != nil {
 		return fmt.Errorf("failed to connect: %w", err)
 	}
@@ -924,6 +1154,10 @@ func (d *rawIP) Receive() ([]byte, error) {
 	return bufIn[:n], err
 }
 
+func (d *rawIP) SetReadDeadline(t time.Time) error {
+	return d.conn.SetReadDeadline(t)
+}
+
 func (d *rawIP) MTU() (int, error) {
 	return 0, nil
 }
@@ -953,7 +1187,7 @@ func (d *connectedSCTP) Connect() error {
 	// state and bind fails. The reuse.Dial() does not support SCTP, but the
 	// SCTP library has a SocketConfig that accepts a Control function
 	// (provided by reuse) that sets these options.
-	sCfg := sctp.SocketConfig{Control: reuse.Control}
+	sCfg := sctp.SocketConfig{Control: socketControl(d.sourceIface)}
 	d.conn, err = sCfg.Dial("sctp", laddr, raddr)
 	if err != nil {
 		return err
@@ -977,6 +1211,10 @@ func (d *connectedSCTP) Receive() ([]byte, error) {
 	return d.r.ReadSlice('\n')
 }
 
+func (d *connectedSCTP) SetReadDeadline(t time.Time) error {
+	return d.conn.SetReadDeadline(t)
+}
+
 func (d *connectedSCTP) Close() error {
 	if d.conn == nil {
 		return nil
@@ -990,12 +1228,16 @@ func (d *connectedSCTP) MTU() (int, error) {
 
 // connectedTCP abstracts an SCTP stream.
 type connectedTCP struct {
-	localAddr  string
-	remoteAddr string
+	localAddr   string
+	remoteAddr  string
+	sourceIface string
 
 	conn net.Conn
 	r    *bufio.Reader
 	w    *bufio.Writer
+
+	// readDeadline, if set, overrides the default timeout for each Receive().
+	readDeadline time.Time
 }
 
 func (d *connectedTCP) Connect() error {
@@ -1004,7 +1246,7 @@ func (d *connectedTCP) Connect() error {
 	// another call to this program, the original port is in post-close wait
 	// state and bind fails.  The reuse library implements a Dial() that sets
 	// these options.
-	conn, err := reuse.Dial("tcp", d.localAddr, d.remoteAddr)
+	conn, err := dial("tcp", d.localAddr, d.remoteAddr, d.sourceIface)
 	if err != nil {
 		return err
 	}
@@ -1024,13 +1266,22 @@ func (d *connectedTCP) Send(msg []byte) error {
 }
 
 func (d *connectedTCP) Receive() ([]byte, error) {
-	err := d.conn.SetReadDeadline(time.Now().Add(10 * time.Second))
+	deadline := d.readDeadline
+	if deadline.IsZero() {
+		deadline = time.Now().Add(10 * time.Second)
+	}
+	err := d.conn.SetReadDeadline(deadline)
 	if err != nil {
 		return nil, err
 	}
 	return d.r.ReadSlice('\n')
 }
 
+func (d *connectedTCP) SetReadDeadline(t time.Time) error {
+	d.readDeadline = t
+	return nil
+}
+
 func (d *connectedTCP) Close() error {
 	if d.conn == nil {
 		return nil
@@ -1041,3 +1292,71 @@ func (d *connectedTCP) Close() error {
 func (d *connectedTCP) MTU() (int, error) {
 	return utils.ConnMTU(d.conn.(utils.HasSyscallConn))
 }
+
+// socketControl returns a Control function for net.Dialer and friends that sets SO_REUSEADDR and
+// SO_REUSEPORT and, if sourceIface is non-empty, binds the socket to that interface with
+// SO_BINDTODEVICE.  Binding to the interface makes the socket egress through it even if the
+// routing table would choose a different interface, which matters on multi-NIC hosts.
+func socketControl(sourceIface string) func(network, address string, c syscall.RawConn) error {
+	if sourceIface == "" {
+		return reuse.Control
+	}
+	return func(network, address string, c syscall.RawConn) error {
+		if err := reuse.Control(network, address, c); err != nil {
+			return err
+		}
+		return bindToDevice(c, sourceIface)
+	}
+}
+
+func bindToDevice(c syscall.RawConn, iface string) error {
+	var bindErr error
+	err := c.Control(func(fd uintptr) {
+		bindErr = unix.BindToDevice(int(fd), iface)
+	})
+	if err != nil {
+		return err
+	}
+	if bindErr != nil {
+		return fmt.Errorf("failed to bind socket to %s: %w", iface, bindErr)
+	}
+	return nil
+}
+
+// dial is like reuse.Dial() but also binds the socket to sourceIface, if set.
+func dial(network, localAddr, remoteAddr, sourceIface string) (net.Conn, error) {
+	if sourceIface == "" {
+		return reuse.Dial(network, localAddr, remoteAddr)
+	}
+	var laddr net.Addr
+	var err error
+	switch network {
+	case "tcp":
+		laddr, err = net.ResolveTCPAddr(network, localAddr)
+	case "udp":
+		laddr, err = net.ResolveUDPAddr(network, localAddr)
+	default:
+		return nil, fmt.Errorf("unsupported network %s", network)
+	}
+	if err != nil {
+		return nil, err
+	}
+	dialer := net.Dialer{
+		LocalAddr: laddr,
+		Control:   socketControl(sourceIface),
+	}
+	return dialer.Dial(network, remoteAddr)
+}
+
+// listenPacket is like net.ListenPacket() but binds the socket to sourceIface, if set.
+func listenPacket(network, localAddr, sourceIface string) (net.PacketConn, error) {
+	if sourceIface == "" {
+		return net.ListenPacket(network, localAddr)
+	}
+	lc := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			return bindToDevice(c, sourceIface)
+		},
+	}
+	return lc.ListenPacket(context.Background(), network, localAddr)
+}
@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"os/exec"
+	"regexp"
 	"strings"
 	"syscall"
 	"time"
@@ -184,6 +185,21 @@ func LogOutput(cmd *exec.Cmd, name string) error {
 	return nil
 }
 
+var unsafeFileNameChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// SanitizeFileName converts a test description (or similar) into a string that is safe to use as
+// a file name.
+func SanitizeFileName(s string) string {
+	s = unsafeFileNameChars.ReplaceAllString(s, "_")
+	if len(s) > 150 {
+		s = s[:150]
+	}
+	if s == "" {
+		s = "unknown-test"
+	}
+	return s
+}
+
 func GetEtcdClient(etcdIP string) client.Interface {
 	client, err := client.New(apiconfig.CalicoAPIConfig{
 		Spec: apiconfig.CalicoAPIConfigSpec{
@@ -58,6 +58,10 @@ type Workload struct {
 	SpoofName             string
 	SpoofWorkloadEndpoint *api.WorkloadEndpoint
 	MTU                   int
+
+	// netemLatency and netemLoss are the impairments currently applied to the workload's eth0.
+	netemLatency time.Duration
+	netemLoss    float64
 }
 
 var workloadIdx = 0
@@ -574,9 +578,19 @@ func (w *Workload) ToMatcher(explicitPort ...uint16) *connectivity.Matcher {
 		Port:       port,
 		TargetName: fmt.Sprintf("%s on port %s", w.Name, port),
 		Protocol:   "tcp",
+		Diags:      w.DiagsContainer(),
 	}
 }
 
+// DiagsContainer returns the container that hosts the workload, so that the connectivity checker
+// can collect diagnostics from it.
+func (w *Workload) DiagsContainer() connectivity.DiagsContainer {
+	if w.C == nil {
+		return nil
+	}
+	return w.C
+}
+
 const nsprefix = "/var/run/netns/"
 
 func (w *Workload) netns() string {
@@ -630,6 +644,49 @@ func (w *Workload) PathMTU(ip string) (int, error) {
 	}
 }
 
+// SetLatency delays all packets that the workload sends by the given amount, in addition to any
+// packet loss set by SetPacketLoss.  0 removes the delay.
+func (w *Workload) SetLatency(latency time.Duration) {
+	w.netemLatency = latency
+	w.applyNetem()
+}
+
+// SetPacketLoss drops the given percentage of the packets that the workload sends, in addition to
+// any latency set by SetLatency.  0 stops the drops.
+func (w *Workload) SetPacketLoss(percent float64) {
+	w.netemLoss = percent
+	w.applyNetem()
+}
+
+// ClearImpairments removes any latency and packet loss from the workload's interface.
+func (w *Workload) ClearImpairments() {
+	w.netemLatency = 0
+	w.netemLoss = 0
+	w.applyNetem()
+}
+
+func (w *Workload) applyNetem() {
+	if w.netemLatency == 0 && w.netemLoss == 0 {
+		// Fails if there's no qdisc to remove, which is fine.
+		_, _ = w.ExecCombinedOutput("tc", "qdisc", "del", "dev", "eth0", "root")
+		return
+	}
+	args := []string{"tc", "qdisc", "replace", "dev", "eth0", "root", "netem"}
+	if w.netemLatency != 0 {
+		args = append(args, "delay", fmt.Sprintf("%dus", w.netemLatency.Microseconds()))
+	}
+	if w.netemLoss != 0 {
+		args = append(args, "loss", fmt.Sprintf("%g%%", w.netemLoss))
+	}
+	w.Exec(args...)
+}
+
+// SetMTU changes the MTU of the workload's interface (inside the workload's namespace).
+func (w *Workload) SetMTU(mtu int) {
+	w.Exec("ip", "link", "set", "dev", "eth0", "mtu", strconv.Itoa(mtu))
+	w.MTU = mtu
+}
+
 // AttachTCPDump returns tcpdump attached to the workload
 func (w *Workload) AttachTCPDump() *tcpdump.TCPDump {
 	netns := w.netns()
@@ -706,6 +763,7 @@ func (p *Port) ToMatcher(explicitPort ...uint16) *connectivity.Matcher {
 		IP:         p.Workload.IP,
 		Port:       fmt.Sprint(p.Port),
 		TargetName: fmt.Sprintf("%s on port %d", p.Workload.Name, p.Port),
+		Diags:      p.Workload.DiagsContainer(),
 	}
 }
 
@@ -15,9 +15,13 @@
 package idalloc
 
 import (
+	"encoding/json"
 	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 
-	"github.com/golang-collections/collections/stack"
+	log "github.com/sirupsen/logrus"
 
 	"github.com/projectcalico/libcalico-go/lib/set"
 )
@@ -26,30 +30,104 @@ type IndexRange struct {
 	Min, Max int
 }
 
+// Contains returns true if idx is within the range (inclusive).
+func (r IndexRange) Contains(idx int) bool {
+	return idx >= r.Min && idx <= r.Max
+}
+
 type IndexAllocator struct {
-	indexStack *stack.Stack
+	// free holds the indices that are available, the next index to hand out is at the end.
+	free []int
+	// owners maps from owner name to the index allocated to that owner.  If statePath is set,
+	// it's persisted so that owners get the same index back after a restart.
+	owners    map[string]int
+	statePath string
 }
 
-func NewIndexAllocator(indexRange IndexRange) *IndexAllocator {
+// NewIndexAllocator creates an allocator that hands out the indices in indexRange, apart from
+// those in any of the exclusions.
+func NewIndexAllocator(indexRange IndexRange, exclusions ...IndexRange) *IndexAllocator {
 	r := &IndexAllocator{
-		indexStack: stack.New(),
+		owners: map[string]int{},
 	}
-	// Push in reverse order so that the lowest index will come out first.
+	// Add in reverse order so that the lowest index will come out first.
+indexLoop:
 	for i := indexRange.Max; i >= indexRange.Min; i-- {
-		r.indexStack.Push(i)
+		for _, excl := range exclusions {
+			if excl.Contains(i) {
+				continue indexLoop
+			}
+		}
+		r.free = append(r.free, i)
 	}
 	return r
 }
 
+// LoadState loads the owned allocations from the given file and enables persistence of future
+// owned allocations to that file.  A missing file isn't an error, it just means that nothing
+// has been allocated yet.
+func (r *IndexAllocator) LoadState(path string) error {
+	r.statePath = path
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	owners := map[string]int{}
+	if err := json.Unmarshal(data, &owners); err != nil {
+		return err
+	}
+	r.owners = owners
+	return nil
+}
+
 func (r *IndexAllocator) GrabIndex() (int, error) {
-	if r.indexStack.Len() == 0 {
+	if len(r.free) == 0 {
 		return 0, errors.New("No more indices available")
 	}
-	return r.indexStack.Pop().(int), nil
+	// Prefer indices that aren't remembered as belonging to an owner, which may not have asked
+	// for its index back yet.
+	remembered := set.New()
+	for _, idx := range r.owners {
+		remembered.Add(idx)
+	}
+	for i := len(r.free) - 1; i >= 0; i-- {
+		if !remembered.Contains(r.free[i]) {
+			return r.take(i), nil
+		}
+	}
+	idx := r.take(len(r.free) - 1)
+	r.forgetOwnerOf(idx)
+	return idx, nil
+}
+
+// GrabIndexFor allocates an index to the named owner.  If the owner had an index before (and
+// allocations are persisted) and that index is still available, it gets the same index back.
+func (r *IndexAllocator) GrabIndexFor(owner string) (int, error) {
+	if idx, ok := r.owners[owner]; ok {
+		for i, free := range r.free {
+			if free == idx {
+				log.WithFields(log.Fields{"owner": owner, "index": idx}).Debug("Reusing previous index.")
+				return r.take(i), nil
+			}
+		}
+		log.WithFields(log.Fields{"owner": owner, "index": idx}).Warn(
+			"Previously-allocated index is no longer available, allocating a new one.")
+		delete(r.owners, owner)
+	}
+	idx, err := r.GrabIndex()
+	if err != nil {
+		return 0, err
+	}
+	r.owners[owner] = idx
+	r.saveState()
+	return idx, nil
 }
 
 func (r *IndexAllocator) ReleaseIndex(index int) {
-	r.indexStack.Push(index)
+	r.free = append(r.free, index)
+	r.forgetOwnerOf(index)
 }
 
 func (r *IndexAllocator) GrabAllRemainingIndices() set.Set {
@@ -61,3 +139,42 @@ func (r *IndexAllocator) GrabAllRemainingIndices() set.Set {
 	}
 	return remainingIndices
 }
+
+func (r *IndexAllocator) take(i int) int {
+	idx := r.free[i]
+	r.free = append(r.free[:i], r.free[i+1:]...)
+	return idx
+}
+
+func (r *IndexAllocator) forgetOwnerOf(idx int) {
+	for owner, ownedIdx := range r.owners {
+		if ownedIdx == idx {
+			delete(r.owners, owner)
+			r.saveState()
+		}
+	}
+}
+
+// saveState writes the owned allocations to the state file, if there is one.  Failures are
+// logged; the worst case is that an owner gets a different index after a restart.
+func (r *IndexAllocator) saveState() {
+	if r.statePath == "" {
+		return
+	}
+	data, err := json.Marshal(r.owners)
+	if err != nil {
+		log.WithError(err).Panic("Failed to marshal index allocations")
+	}
+	if err := os.MkdirAll(filepath.Dir(r.statePath), 0755); err != nil {
+		log.WithError(err).Warn("Failed to create directory for index allocations.")
+		return
+	}
+	tmpPath := r.statePath + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, data, 0644); err != nil {
+		log.WithError(err).Warn("Failed to write index allocations.")
+		return
+	}
+	if err := os.Rename(tmpPath, r.statePath); err != nil {
+		log.WithError(err).Warn("Failed to write index allocations.")
+	}
+}
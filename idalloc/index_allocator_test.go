@@ -15,6 +15,10 @@
 package idalloc_test
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
 	. "github.com/projectcalico/felix/idalloc"
 
 	. "github.com/onsi/ginkgo"
@@ -71,4 +75,87 @@ var _ = Describe("IndexAllocator", func() {
 		_, err = r.GrabIndex()
 		Expect(err).To(HaveOccurred())
 	})
+
+	It("skips excluded indices", func() {
+		r = NewIndexAllocator(IndexRange{Min: 43, Max: 47}, IndexRange{Min: 44, Max: 45}, IndexRange{Min: 47, Max: 47})
+		remaining := r.GrabAllRemainingIndices()
+		Expect(remaining.Len()).To(Equal(2))
+		Expect(remaining.Contains(43)).To(BeTrue())
+		Expect(remaining.Contains(46)).To(BeTrue())
+	})
+
+	Describe("with persistence", func() {
+		var statePath string
+
+		BeforeEach(func() {
+			dir, err := ioutil.TempDir("", "idalloc")
+			Expect(err).NotTo(HaveOccurred())
+			statePath = filepath.Join(dir, "state", "route-table-indices")
+			Expect(r.LoadState(statePath)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			_ = os.RemoveAll(filepath.Dir(filepath.Dir(statePath)))
+		})
+
+		It("gives owners the same index after a restart", func() {
+			idx, err := r.GrabIndexFor("a")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(idx).To(Equal(43))
+			idx, err = r.GrabIndexFor("b")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(idx).To(Equal(44))
+
+			By("restarting and asking in the opposite order")
+			r = NewIndexAllocator(IndexRange{Min: 43, Max: 47})
+			Expect(r.LoadState(statePath)).To(Succeed())
+			idx, err = r.GrabIndexFor("b")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(idx).To(Equal(44))
+			idx, err = r.GrabIndexFor("a")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(idx).To(Equal(43))
+		})
+
+		It("doesn't hand out remembered indices to anonymous callers", func() {
+			_, err := r.GrabIndexFor("a")
+			Expect(err).NotTo(HaveOccurred())
+
+			r = NewIndexAllocator(IndexRange{Min: 43, Max: 47})
+			Expect(r.LoadState(statePath)).To(Succeed())
+			idx, err := r.GrabIndex()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(idx).To(Equal(44))
+			idx, err = r.GrabIndexFor("a")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(idx).To(Equal(43))
+		})
+
+		It("allocates a new index if the old one is now excluded", func() {
+			_, err := r.GrabIndexFor("a")
+			Expect(err).NotTo(HaveOccurred())
+
+			r = NewIndexAllocator(IndexRange{Min: 43, Max: 47}, IndexRange{Min: 43, Max: 43})
+			Expect(r.LoadState(statePath)).To(Succeed())
+			idx, err := r.GrabIndexFor("a")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(idx).To(Equal(44))
+		})
+
+		It("forgets the owner of a released index", func() {
+			idx, err := r.GrabIndexFor("a")
+			Expect(err).NotTo(HaveOccurred())
+			r.ReleaseIndex(idx)
+
+			data, err := ioutil.ReadFile(statePath)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(Equal("{}"))
+		})
+
+		It("fails to load a corrupt state file", func() {
+			Expect(os.MkdirAll(filepath.Dir(statePath), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(statePath+".bad", []byte("garbage"), 0644)).To(Succeed())
+			Expect(r.LoadState(statePath + ".bad")).NotTo(Succeed())
+		})
+	})
 })
@@ -0,0 +1,82 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idalloc
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// RTTable is a routing table that the user has named in one of iproute2's rt_tables files.
+type RTTable struct {
+	Index int
+	Name  string
+	File  string
+}
+
+// ReadRTTables reads the named routing tables from the rt_tables file in the given iproute2
+// config directory (usually /etc/iproute2) and from the *.conf files in its rt_tables.d
+// subdirectory.  Missing files are ignored, as are lines that can't be parsed.
+func ReadRTTables(dir string) ([]RTTable, error) {
+	files := []string{filepath.Join(dir, "rt_tables")}
+	extraFiles, err := filepath.Glob(filepath.Join(dir, "rt_tables.d", "*.conf"))
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, extraFiles...)
+
+	var tables []RTTable
+	for _, file := range files {
+		fileTables, err := readRTTablesFile(file)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, fileTables...)
+	}
+	return tables, nil
+}
+
+func readRTTablesFile(file string) ([]RTTable, error) {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var tables []RTTable
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := sc.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		// Format: "<index> <name>", the index may be in hex.
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		idx, err := strconv.ParseInt(fields[0], 0, 64)
+		if err != nil {
+			continue
+		}
+		tables = append(tables, RTTable{Index: int(idx), Name: fields[1], File: file})
+	}
+	return tables, sc.Err()
+}
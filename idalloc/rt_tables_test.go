@@ -0,0 +1,64 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package idalloc_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/projectcalico/felix/idalloc"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ReadRTTables", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "iproute2")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		_ = os.RemoveAll(dir)
+	})
+
+	It("returns nothing if there are no files", func() {
+		tables, err := ReadRTTables(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tables).To(BeEmpty())
+	})
+
+	It("reads rt_tables and rt_tables.d", func() {
+		Expect(ioutil.WriteFile(filepath.Join(dir, "rt_tables"), []byte(
+			"#\n# reserved values\n#\n255\tlocal\n254\tmain\n0x64 vpn # Our VPN\nbogus line here\n",
+		), 0644)).To(Succeed())
+		Expect(os.Mkdir(filepath.Join(dir, "rt_tables.d"), 0755)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(dir, "rt_tables.d", "isp.conf"), []byte("7 isp2\n"), 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(dir, "rt_tables.d", "ignored"), []byte("8 other\n"), 0644)).To(Succeed())
+
+		tables, err := ReadRTTables(dir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(tables).To(Equal([]RTTable{
+			{Index: 255, Name: "local", File: filepath.Join(dir, "rt_tables")},
+			{Index: 254, Name: "main", File: filepath.Join(dir, "rt_tables")},
+			{Index: 100, Name: "vpn", File: filepath.Join(dir, "rt_tables")},
+			{Index: 7, Name: "isp2", File: filepath.Join(dir, "rt_tables.d", "isp.conf")},
+		}))
+	})
+})
@@ -21,6 +21,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 	"github.com/vishvananda/netlink"
 	"golang.org/x/sys/unix"
@@ -28,6 +29,16 @@ import (
 	"github.com/projectcalico/libcalico-go/lib/set"
 )
 
+var countVecPolledChanges = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "felix_iface_monitor_polled_changes",
+	Help: "Number of interface changes that were only spotted by polling the interfaces, rather " +
+		"than from netlink updates, by kind of change (state, addrs or mtu).",
+}, []string{"kind"})
+
+func init() {
+	prometheus.MustRegister(countVecPolledChanges)
+}
+
 type netlinkStub interface {
 	Subscribe(
 		linkUpdates chan netlink.LinkUpdate,
@@ -47,12 +58,17 @@ const (
 
 type InterfaceStateCallback func(ifaceName string, ifaceState State, ifIndex int)
 type AddrStateCallback func(ifaceName string, addrs set.Set)
+type MTUCallback func(ifaceName string, mtu int)
 
 type Config struct {
 	// InterfaceExcludes is a list of interface names that we don't want callbacks for.
 	InterfaceExcludes []*regexp.Regexp
 	// ResyncInterval is the interval at which we rescan all the interfaces.  If <0 rescan is disabled.
 	ResyncInterval time.Duration
+	// PollingFallbackEnabled makes us fall back to polling the interfaces every ResyncInterval,
+	// rather than reporting a fatal error, if we fail to subscribe to netlink.  We retry the
+	// subscription before each poll.
+	PollingFallbackEnabled bool
 }
 type InterfaceMonitor struct {
 	Config
@@ -65,6 +81,20 @@ type InterfaceMonitor struct {
 	ifaceName        map[int]string
 	ifaceAddrs       map[int]set.Set
 	fatalErrCallback func(error)
+
+	// ExcludedAddrCallback, if set, is called with the addresses of excluded interfaces, which
+	// AddrCallback never sees.  For example, in IPVS mode, kube-proxy binds the service IPs
+	// to kube-ipvs0.
+	ExcludedAddrCallback AddrStateCallback
+
+	// MTUCallback, if set, is called with the MTU of each interface when we first see it and
+	// whenever it changes, and with MTU 0 when the interface is removed.
+	MTUCallback MTUCallback
+	ifaceMTUs   map[int]int
+
+	// polling is set while we're polling the interfaces, so that we count the changes that
+	// netlink didn't tell us about.
+	polling bool
 }
 
 func New(config Config, fatalErrCallback func(error)) *InterfaceMonitor {
@@ -87,6 +117,7 @@ func NewWithStubs(config Config, netlinkStub netlinkStub, resyncC <-chan time.Ti
 		upIfaces:         map[string]int{},
 		ifaceName:        map[int]string{},
 		ifaceAddrs:       map[int]set.Set{},
+		ifaceMTUs:        map[int]int{},
 		fatalErrCallback: fatalErrCallback,
 	}
 }
@@ -110,6 +141,13 @@ func (m *InterfaceMonitor) MonitorInterfaces() {
 			routeUpdates := make(chan netlink.RouteUpdate, 10)
 			var err error
 			if nlCancelC, err = m.netlinkStub.Subscribe(updates, routeUpdates); err != nil {
+				if m.PollingFallbackEnabled && m.resyncC != nil {
+					log.WithError(err).Warn(
+						"Failed to subscribe to netlink, falling back to polling the interfaces.")
+					filterUpdatesCancel()
+					m.pollUntilResync()
+					continue
+				}
 				// If we can't even subscribe, something must have gone very wrong.  Bail.
 				m.fatalErrCallback(fmt.Errorf("failed to subscribe to netlink: %w", err))
 			}
@@ -149,7 +187,7 @@ func (m *InterfaceMonitor) MonitorInterfaces() {
 				m.handleNetlinkRouteUpdate(routeUpdate)
 			case <-m.resyncC:
 				log.Debug("Resync trigger")
-				err := m.resync()
+				err := m.poll()
 				if err != nil {
 					m.fatalErrCallback(fmt.Errorf("failed to read from netlink (resync): %w", err))
 				}
@@ -161,6 +199,30 @@ func (m *InterfaceMonitor) MonitorInterfaces() {
 	}
 }
 
+// pollUntilResync brings our interface state up to date by polling and then waits until the next
+// resync is due.  Used when we have no netlink subscription.
+func (m *InterfaceMonitor) pollUntilResync() {
+	if err := m.poll(); err != nil {
+		m.fatalErrCallback(fmt.Errorf("failed to read from netlink (poll): %w", err))
+	}
+	<-m.resyncC
+}
+
+// poll resyncs the interface state, counting any changes that it finds, which netlink updates
+// should have told us about.
+func (m *InterfaceMonitor) poll() error {
+	m.polling = true
+	defer func() { m.polling = false }()
+	return m.resync()
+}
+
+// notePolledChange counts a change of the given kind if we spotted it by polling.
+func (m *InterfaceMonitor) notePolledChange(kind string) {
+	if m.polling {
+		countVecPolledChanges.WithLabelValues(kind).Inc()
+	}
+}
+
 func (m *InterfaceMonitor) isExcludedInterface(ifName string) bool {
 	for _, nameExp := range m.InterfaceExcludes {
 		if nameExp.Match([]byte(ifName)) {
@@ -170,6 +232,19 @@ func (m *InterfaceMonitor) isExcludedInterface(ifName string) bool {
 	return false
 }
 
+// monitorAddrs returns true if we should track the addresses of the given interface.
+func (m *InterfaceMonitor) monitorAddrs(ifName string) bool {
+	return m.ExcludedAddrCallback != nil || !m.isExcludedInterface(ifName)
+}
+
+// addrCallback returns the callback for the given interface's addresses.
+func (m *InterfaceMonitor) addrCallback(ifName string) AddrStateCallback {
+	if m.isExcludedInterface(ifName) {
+		return m.ExcludedAddrCallback
+	}
+	return m.AddrCallback
+}
+
 func (m *InterfaceMonitor) handleNetlinkUpdate(update netlink.LinkUpdate) {
 	attrs := update.Attrs()
 	linkAttrs := update.Link.Attrs()
@@ -187,7 +262,7 @@ func (m *InterfaceMonitor) handleNetlinkUpdate(update netlink.LinkUpdate) {
 func (m *InterfaceMonitor) handleNetlinkRouteUpdate(update netlink.RouteUpdate) {
 	ifIndex := update.LinkIndex
 	if ifName, known := m.ifaceName[ifIndex]; known {
-		if m.isExcludedInterface(ifName) {
+		if !m.monitorAddrs(ifName) {
 			return
 		}
 	}
@@ -241,7 +316,8 @@ func (m *InterfaceMonitor) notifyIfaceAddrs(ifIndex int) {
 			// ours.
 			addrs = addrs.Copy()
 		}
-		m.AddrCallback(name, addrs)
+		m.notePolledChange("addrs")
+		m.addrCallback(name)(name, addrs)
 	}
 }
 
@@ -292,14 +368,16 @@ func (m *InterfaceMonitor) storeAndNotifyLinkInner(ifaceExists bool, ifaceName s
 	if ifaceExists {
 		m.ifaceName[ifIndex] = ifaceName
 	} else {
-		if !m.isExcludedInterface(ifaceName) {
-			// for excluded interfaces, e.g. kube-ipvs0, we ignore all ip address changes.
+		if m.monitorAddrs(ifaceName) {
+			// Unless asked, we ignore all ip address changes for excluded interfaces, e.g.
+			// kube-ipvs0.
 			log.Debug("Notify link non-existence to address callback consumers")
 			delete(m.ifaceAddrs, ifIndex)
 			m.notifyIfaceAddrs(ifIndex)
 		}
 		delete(m.ifaceName, ifIndex)
 	}
+	m.storeAndNotifyMTU(ifaceExists, ifaceName, ifIndex, attrs.MTU)
 
 	// We need the operstate of the interface; this is carried in the IFF_RUNNING flag.  The
 	// IFF_UP flag contains the admin state, which doesn't tell us whether we can program routes
@@ -310,10 +388,12 @@ func (m *InterfaceMonitor) storeAndNotifyLinkInner(ifaceExists bool, ifaceName s
 	if ifaceIsUp && !ifaceWasUp {
 		logCxt.Debug("Interface now up")
 		m.upIfaces[ifaceName] = ifIndex
+		m.notePolledChange("state")
 		m.StateCallback(ifaceName, StateUp, ifIndex)
 	} else if ifaceWasUp && !ifaceIsUp {
 		logCxt.Debug("Interface now down")
 		delete(m.upIfaces, ifaceName)
+		m.notePolledChange("state")
 		m.StateCallback(ifaceName, StateDown, oldIfIndex)
 	} else {
 		logCxt.WithField("ifaceIsUp", ifaceIsUp).Debug("Nothing to notify")
@@ -324,8 +404,8 @@ func (m *InterfaceMonitor) storeAndNotifyLinkInner(ifaceExists bool, ifaceName s
 	// channels.  We deliberately do this regardless of the link state, as in some cases this
 	// will allow us to secure a Host Endpoint interface _before_ it comes up, and so eliminate
 	// a small window of insecurity.
-	if ifaceExists && !m.isExcludedInterface(ifaceName) {
-		// Notify address changes for non excluded interfaces.
+	if ifaceExists && m.monitorAddrs(ifaceName) {
+		// Notify address changes for non excluded interfaces (and excluded ones, if asked).
 		newAddrs := set.New()
 		for _, family := range [2]int{netlink.FAMILY_V4, netlink.FAMILY_V6} {
 			routes, err := m.netlinkStub.ListLocalRoutes(link, family)
@@ -352,6 +432,32 @@ func (m *InterfaceMonitor) storeAndNotifyLinkInner(ifaceExists bool, ifaceName s
 	}
 }
 
+func (m *InterfaceMonitor) storeAndNotifyMTU(ifaceExists bool, ifaceName string, ifIndex int, mtu int) {
+	if m.MTUCallback == nil {
+		return
+	}
+	oldMTU, known := m.ifaceMTUs[ifIndex]
+	if !ifaceExists {
+		if known {
+			delete(m.ifaceMTUs, ifIndex)
+			m.notePolledChange("mtu")
+			m.MTUCallback(ifaceName, 0)
+		}
+		return
+	}
+	if known && oldMTU == mtu {
+		return
+	}
+	log.WithFields(log.Fields{
+		"ifaceName": ifaceName,
+		"oldMTU":    oldMTU,
+		"mtu":       mtu,
+	}).Debug("Interface MTU changed")
+	m.ifaceMTUs[ifIndex] = mtu
+	m.notePolledChange("mtu")
+	m.MTUCallback(ifaceName, mtu)
+}
+
 func (m *InterfaceMonitor) resync() error {
 	log.Debug("Resyncing interface state.")
 	links, err := m.netlinkStub.LinkList()
@@ -360,6 +466,7 @@ func (m *InterfaceMonitor) resync() error {
 		return err
 	}
 	currentIfaces := set.New()
+	currentIfIndexes := set.New()
 	for _, link := range links {
 		attrs := link.Attrs()
 		if attrs == nil {
@@ -369,15 +476,28 @@ func (m *InterfaceMonitor) resync() error {
 			continue
 		}
 		currentIfaces.Add(attrs.Name)
+		currentIfIndexes.Add(attrs.Index)
 		m.storeAndNotifyLink(true, link)
 	}
+	for ifIndex := range m.ifaceMTUs {
+		if currentIfIndexes.Contains(ifIndex) {
+			continue
+		}
+		delete(m.ifaceMTUs, ifIndex)
+		m.notePolledChange("mtu")
+		m.MTUCallback(m.ifaceName[ifIndex], 0)
+	}
 	for name, ifIndex := range m.upIfaces {
 		if currentIfaces.Contains(name) {
 			continue
 		}
 		log.WithField("ifaceName", name).Info("Spotted interface removal on resync.")
+		m.notePolledChange("state")
 		m.StateCallback(name, StateDown, ifIndex)
-		m.AddrCallback(name, nil)
+		if m.monitorAddrs(name) {
+			m.notePolledChange("addrs")
+			m.addrCallback(name)(name, nil)
+		}
 		delete(m.upIfaces, name)
 		delete(m.ifaceAddrs, ifIndex)
 		delete(m.ifaceName, ifIndex)
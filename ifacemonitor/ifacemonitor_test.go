@@ -25,6 +25,7 @@ import (
 
 	"golang.org/x/sys/unix"
 
+	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 	"github.com/vishvananda/netlink"
 
@@ -40,6 +41,7 @@ type linkModel struct {
 	index int
 	state string
 	addrs set.Set
+	mtu   int
 }
 
 type netlinkTest struct {
@@ -57,6 +59,9 @@ type netlinkTest struct {
 	// in the same function).
 	linksMutex  sync.Mutex
 	LinkListErr error
+	// SubscribeErr, if set, is returned by Subscribe.  Updates that the test code signals
+	// until the next Subscribe are lost.
+	SubscribeErr error
 }
 
 type addrState struct {
@@ -70,9 +75,15 @@ type linkUpdate struct {
 	index int
 }
 
+type mtuUpdate struct {
+	name string
+	mtu  int
+}
+
 type mockDataplane struct {
 	linkC chan linkUpdate
 	addrC chan addrState
+	mtuC  chan mtuUpdate
 }
 
 func (nl *netlinkTest) addLink(name string) {
@@ -91,6 +102,7 @@ func (nl *netlinkTest) addLinkNoSignal(name string) {
 		index: nl.nextIndex,
 		state: "down",
 		addrs: set.New(),
+		mtu:   1500,
 	}
 	nl.nextIndex++
 	nl.linksMutex.Unlock()
@@ -116,6 +128,16 @@ func (nl *netlinkTest) changeLinkState(name string, state string) {
 	nl.signalLink(name, 0)
 }
 
+func (nl *netlinkTest) changeLinkMTU(name string, mtu int) {
+	log.WithFields(log.Fields{"name": name, "mtu": mtu}).Info("CHANGELINKMTU")
+	nl.linksMutex.Lock()
+	link := nl.links[name]
+	link.mtu = mtu
+	nl.links[name] = link
+	nl.linksMutex.Unlock()
+	nl.signalLink(name, 0)
+}
+
 func (nl *netlinkTest) delLink(name string) {
 	oldIndex := nl.delLinkNoSignal(name)
 	nl.signalLink(name, oldIndex)
@@ -139,6 +161,7 @@ func (nl *netlinkTest) signalLink(name string, oldIndex int) {
 	// Values for a link that does not exist...
 	index := oldIndex
 	var rawFlags uint32 = 0
+	var mtu int
 	var msgType uint16 = syscall.RTM_DELLINK
 
 	// If the link does exist, overwrite appropriately.
@@ -147,6 +170,7 @@ func (nl *netlinkTest) signalLink(name string, oldIndex int) {
 	if prs {
 		msgType = syscall.RTM_NEWLINK
 		index = link.index
+		mtu = link.mtu
 		if link.state == "up" {
 			rawFlags = syscall.IFF_RUNNING
 		}
@@ -163,6 +187,7 @@ func (nl *netlinkTest) signalLink(name string, oldIndex int) {
 				Name:     name,
 				Index:    index,
 				RawFlags: rawFlags,
+				MTU:      mtu,
 			},
 		},
 	}
@@ -230,6 +255,9 @@ func (nl *netlinkTest) Subscribe(
 	nl.routeUpdates = routeUpdates
 	nl.cancel = make(chan struct{})
 	nl.userSubscribed <- 1
+	if nl.SubscribeErr != nil {
+		return nil, nl.SubscribeErr
+	}
 	return nl.cancel, nil
 }
 
@@ -250,6 +278,7 @@ func (nl *netlinkTest) LinkList() ([]netlink.Link, error) {
 				Name:     name,
 				Index:    link.index,
 				RawFlags: rawFlags,
+				MTU:      link.mtu,
 			},
 		})
 	}
@@ -356,6 +385,17 @@ func (dp *mockDataplane) addrStateCallback(ifaceName string, addrs set.Set) {
 	log.Info("mock dataplane reported address callback")
 }
 
+func (dp *mockDataplane) mtuCallback(ifaceName string, mtu int) {
+	log.WithFields(log.Fields{"ifaceName": ifaceName, "mtu": mtu}).Info("CALLBACK MTU")
+	dp.mtuC <- mtuUpdate{name: ifaceName, mtu: mtu}
+}
+
+func (dp *mockDataplane) expectMTUCb(ifaceName string, mtu int) {
+	var upd mtuUpdate
+	Eventually(dp.mtuC).Should(Receive(&upd))
+	ExpectWithOffset(1, upd).To(Equal(mtuUpdate{name: ifaceName, mtu: mtu}), "Received unexpected MTU callback.")
+}
+
 func (dp *mockDataplane) notExpectAddrStateCb() {
 	Consistently(dp.addrC, "50ms", "5ms").ShouldNot(Receive())
 }
@@ -393,6 +433,26 @@ func (dp *mockDataplane) expectAddrStateCb(ifaceName string, addr string, presen
 
 var errFatal = errors.New("fatal error")
 
+// polledChanges returns the number of changes of the given kind that the monitor has only
+// spotted by polling.
+func polledChanges(kind string) float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	Expect(err).NotTo(HaveOccurred())
+	for _, f := range families {
+		if f.GetName() != "felix_iface_monitor_polled_changes" {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "kind" && l.GetValue() == kind {
+					return m.GetCounter().GetValue()
+				}
+			}
+		}
+	}
+	return 0
+}
+
 var _ = Describe("ifacemonitor", func() {
 	var nl *netlinkTest
 	var resyncC chan time.Time
@@ -469,6 +529,57 @@ var _ = Describe("ifacemonitor", func() {
 		})
 	})
 
+	Context("with a failing netlink subscription and the polling fallback enabled", func() {
+		BeforeEach(func() {
+			nl.SubscribeErr = fmt.Errorf("dummy subscribe err")
+			im.PollingFallbackEnabled = true
+		})
+
+		It("should poll for changes until it can subscribe", func() {
+			stateChanges := polledChanges("state")
+			addrChanges := polledChanges("addrs")
+
+			// Netlink doesn't tell the monitor about these.
+			idx := nl.nextIndex
+			nl.addLink("eth0")
+			nl.changeLinkState("eth0", "up")
+
+			// On the next resync, the monitor retries the subscription and then polls.
+			resyncC <- time.Time{}
+			Eventually(nl.userSubscribed).Should(Receive())
+			dp.expectLinkStateCb("eth0", ifacemonitor.StateUp, idx)
+			dp.expectAddrStateCb("eth0", "", true)
+			Expect(polledChanges("state")).To(Equal(stateChanges + 1))
+			Expect(polledChanges("addrs")).To(Equal(addrChanges + 1))
+
+			// Once the subscription works, netlink updates come through again.
+			nl.SubscribeErr = nil
+			resyncC <- time.Time{}
+			Eventually(nl.userSubscribed).Should(Receive())
+			nl.addAddr("eth0", "10.0.240.10/24")
+			dp.expectAddrStateCb("eth0", "10.0.240.10", true)
+			Expect(polledChanges("addrs")).To(Equal(addrChanges + 1))
+			Expect(fatalErrC).ToNot(BeClosed())
+		})
+	})
+
+	It("should count the changes that only a resync spots", func() {
+		stateChanges := polledChanges("state")
+		idx := nl.nextIndex
+		nl.addLink("eth0")
+		resyncC <- time.Time{}
+		dp.expectAddrStateCb("eth0", "", true)
+		nl.changeLinkState("eth0", "up")
+		dp.expectLinkStateCb("eth0", ifacemonitor.StateUp, idx)
+		Expect(polledChanges("state")).To(Equal(stateChanges))
+
+		_ = nl.delLinkNoSignal("eth0")
+		resyncC <- time.Time{}
+		dp.expectLinkStateCb("eth0", ifacemonitor.StateDown, idx)
+		dp.expectAddrStateCb("eth0", "", false)
+		Expect(polledChanges("state")).To(Equal(stateChanges + 1))
+	})
+
 	It("should skip netlink address updates for ipvs", func() {
 		var netlinkUpdates = func(iface string) {
 			// Should not receive any address callbacks.
@@ -520,6 +631,78 @@ var _ = Describe("ifacemonitor", func() {
 		Expect(fatalErrC).ToNot(BeClosed())
 	})
 
+	Context("with an excluded address callback", func() {
+		var excludedDp *mockDataplane
+
+		BeforeEach(func() {
+			excludedDp = &mockDataplane{
+				addrC: make(chan addrState, 2),
+			}
+			im.ExcludedAddrCallback = excludedDp.addrStateCallback
+		})
+
+		It("should report the addresses of excluded interfaces to it", func() {
+			nl.addLink("kube-ipvs0")
+			resyncC <- time.Time{}
+			excludedDp.expectAddrStateCb("kube-ipvs0", "", true)
+			nl.addAddr("kube-ipvs0", "10.100.0.1/32")
+			excludedDp.expectAddrStateCb("kube-ipvs0", "10.100.0.1", true)
+			dp.notExpectAddrStateCb()
+
+			nl.delAddr("kube-ipvs0", "10.100.0.1/32")
+			excludedDp.expectAddrStateCb("kube-ipvs0", "10.100.0.1", false)
+
+			nl.delLink("kube-ipvs0")
+			excludedDp.expectAddrStateCb("kube-ipvs0", "", false)
+			dp.notExpectAddrStateCb()
+		})
+
+		It("should still report other interfaces to the main callback", func() {
+			nl.addLink("eth0")
+			resyncC <- time.Time{}
+			dp.expectAddrStateCb("eth0", "", true)
+			nl.addAddr("eth0", "10.0.240.10/24")
+			dp.expectAddrStateCb("eth0", "10.0.240.10", true)
+			excludedDp.notExpectAddrStateCb()
+		})
+	})
+
+	Context("with an MTU callback", func() {
+		BeforeEach(func() {
+			dp.mtuC = make(chan mtuUpdate, 1)
+			im.MTUCallback = dp.mtuCallback
+		})
+
+		It("should report MTU changes", func() {
+			nl.addLink("eth0")
+			resyncC <- time.Time{}
+			dp.expectMTUCb("eth0", 1500)
+			dp.expectAddrStateCb("eth0", "", true)
+
+			nl.changeLinkMTU("eth0", 9000)
+			dp.expectMTUCb("eth0", 9000)
+
+			By("not reporting the MTU again if it hasn't changed")
+			nl.changeLinkState("eth0", "up")
+			dp.expectLinkStateCb("eth0", ifacemonitor.StateUp, 10)
+			Consistently(dp.mtuC, "50ms", "5ms").ShouldNot(Receive())
+
+			nl.delLink("eth0")
+			dp.expectMTUCb("eth0", 0)
+		})
+
+		It("should report the removal of an interface that is spotted on resync", func() {
+			nl.addLink("eth0")
+			resyncC <- time.Time{}
+			dp.expectMTUCb("eth0", 1500)
+			dp.expectAddrStateCb("eth0", "", true)
+
+			nl.delLinkNoSignal("eth0")
+			resyncC <- time.Time{}
+			dp.expectMTUCb("eth0", 0)
+		})
+	})
+
 	It("should handle mainline netlink updates", func() {
 		// Add a link and an address.  No link callback expected because the link is not up
 		// yet.  But we do get an address callback because those are independent of link
@@ -0,0 +1,106 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipsets
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/logutils"
+)
+
+const (
+	// LargeDeltaMinSetSize is the size above which an IP set counts as big for the purposes of
+	// the large delta warning.  Churn in small sets is normal and cheap.
+	LargeDeltaMinSetSize = 1000
+	// LargeDeltaPercent is the percentage of a big IP set that a single update has to add or
+	// remove to trigger the large delta warning.
+	LargeDeltaPercent = 50
+)
+
+var (
+	countVecIPSetMemberAdds = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "felix_ipset_member_adds",
+		Help: "Number of members added to each IP set.",
+	}, []string{"ip_version", "set_id"})
+	countVecIPSetMemberDeletes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "felix_ipset_member_deletes",
+		Help: "Number of members removed from each IP set.",
+	}, []string{"ip_version", "set_id"})
+)
+
+func init() {
+	prometheus.MustRegister(countVecIPSetMemberAdds)
+	prometheus.MustRegister(countVecIPSetMemberDeletes)
+}
+
+// memberChurn is the number of members that a single update added to and removed from an IP set.
+type memberChurn struct {
+	// numBefore is the number of members in the dataplane before the update, or -1 if that
+	// isn't known, for example because the update rewrote the whole set.
+	numBefore  int
+	numAdds    int
+	numDeletes int
+}
+
+// IsLargeMemberDelta returns true if an update that adds numAdds members to, and removes
+// numDeletes members from, an IP set with numBefore members replaces more than
+// LargeDeltaPercent of a big set.  That usually means that a selector is matching far more (or
+// fewer) endpoints than intended.
+func IsLargeMemberDelta(numBefore, numAdds, numDeletes int) bool {
+	if numBefore < 0 {
+		return false
+	}
+	size := numBefore
+	if numAfter := numBefore + numAdds - numDeletes; numAfter > size {
+		size = numAfter
+	}
+	if size < LargeDeltaMinSetSize {
+		return false
+	}
+	changed := numAdds
+	if numDeletes > changed {
+		changed = numDeletes
+	}
+	return changed*100 > size*LargeDeltaPercent
+}
+
+// record updates the churn metrics of the IP set, reports the churn to the OpRecorder and warns
+// if the update was unusually large.
+func (c memberChurn) record(logCxt *log.Entry, family IPFamily, meta IPSetMetadata, opRecorder logutils.OpRecorder) {
+	opRecorder.RecordCount(logutils.CountIPSetMembersAdded, c.numAdds)
+	opRecorder.RecordCount(logutils.CountIPSetMembersRemoved, c.numDeletes)
+	if c.numAdds > 0 {
+		countVecIPSetMemberAdds.WithLabelValues(string(family), meta.SetID).Add(float64(c.numAdds))
+	}
+	if c.numDeletes > 0 {
+		countVecIPSetMemberDeletes.WithLabelValues(string(family), meta.SetID).Add(float64(c.numDeletes))
+	}
+	if IsLargeMemberDelta(c.numBefore, c.numAdds, c.numDeletes) {
+		logCxt.WithFields(log.Fields{
+			"setID":      meta.SetID,
+			"selector":   meta.Selector,
+			"numBefore":  c.numBefore,
+			"numAdds":    c.numAdds,
+			"numDeletes": c.numDeletes,
+		}).Warn("Large change to IP set, check that the selector matches the intended endpoints.")
+	}
+}
+
+// forgetMemberChurn removes the churn metrics of an IP set that has been removed.
+func forgetMemberChurn(family IPFamily, setID string) {
+	countVecIPSetMemberAdds.DeleteLabelValues(string(family), setID)
+	countVecIPSetMemberDeletes.DeleteLabelValues(string(family), setID)
+}
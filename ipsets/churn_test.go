@@ -0,0 +1,35 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipsets_test
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	. "github.com/projectcalico/felix/ipsets"
+)
+
+var _ = DescribeTable("IsLargeMemberDelta",
+	func(numBefore, numAdds, numDeletes int, expected bool) {
+		Expect(IsLargeMemberDelta(numBefore, numAdds, numDeletes)).To(Equal(expected))
+	},
+	Entry("unknown previous contents", -1, 5000, 0, false),
+	Entry("small set replaced", 10, 10, 10, false),
+	Entry("big set, small change", 2000, 100, 100, false),
+	Entry("big set, exactly half replaced", 2000, 1000, 1000, false),
+	Entry("big set, most members replaced", 2000, 1500, 1500, true),
+	Entry("big set, most members removed", 2000, 0, 1500, true),
+	Entry("small set grown into a big one", 10, 5000, 0, true),
+)
@@ -0,0 +1,93 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ipsets
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// EnableDryRun stops the IPSets from modifying the dataplane.  The input that they would have
+// passed to "ipset restore", and any "ipset destroy" commands (rendered in the same format), are
+// passed to report instead.  The IP sets are still read from the kernel, so each report is the
+// update needed to bring the kernel into line with the desired state.
+func (s *IPSets) EnableDryRun(report func(commands []byte)) {
+	newCmd := s.newCmd
+	s.newCmd = func(name string, arg ...string) CmdIface {
+		if name == "ipset" && len(arg) > 0 && (arg[0] == "restore" || arg[0] == "destroy") {
+			return &dryRunCmd{args: arg, report: report}
+		}
+		return newCmd(name, arg...)
+	}
+}
+
+// dryRunCmd stands in for an ipset command that would modify the dataplane.
+type dryRunCmd struct {
+	args   []string
+	report func(commands []byte)
+	stdin  bytes.Buffer
+}
+
+func (c *dryRunCmd) StdinPipe() (WriteCloserFlusher, error) {
+	return dryRunStdin{&c.stdin}, nil
+}
+
+func (c *dryRunCmd) StdoutPipe() (io.ReadCloser, error) {
+	return ioutil.NopCloser(&bytes.Buffer{}), nil
+}
+
+func (c *dryRunCmd) SetStdin(r io.Reader) {
+	_, _ = c.stdin.ReadFrom(r)
+}
+
+func (c *dryRunCmd) SetStdout(io.Writer) {}
+
+func (c *dryRunCmd) SetStderr(io.Writer) {}
+
+func (c *dryRunCmd) Start() error {
+	return nil
+}
+
+func (c *dryRunCmd) Wait() error {
+	if c.args[0] == "restore" {
+		c.report(c.stdin.Bytes())
+	} else {
+		// Render other commands as they'd appear in "ipset restore" input.
+		c.report([]byte(strings.Join(c.args, " ") + "\n"))
+	}
+	return nil
+}
+
+func (c *dryRunCmd) Output() ([]byte, error) {
+	return nil, c.Wait()
+}
+
+func (c *dryRunCmd) CombinedOutput() ([]byte, error) {
+	return nil, c.Wait()
+}
+
+type dryRunStdin struct {
+	*bytes.Buffer
+}
+
+func (dryRunStdin) Flush() error {
+	return nil
+}
+
+func (dryRunStdin) Close() error {
+	return nil
+}
@@ -221,6 +221,15 @@ type IPSetMetadata struct {
 	SetID   string
 	Type    IPSetType
 	MaxSize int
+
+	// Selector is the selector that the IP set represents, if any.  It is only used in
+	// diagnostics.
+	Selector string
+
+	// ExternalMembers is set for IP sets whose members are managed by another component.  We
+	// create and delete such IP sets but leave their members alone: if the IP set already
+	// exists with the right size, we adopt it as-is instead of rewriting it.
+	ExternalMembers bool
 }
 
 // ipSet holds the state for a particular IP set.
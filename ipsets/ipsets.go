@@ -19,6 +19,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"strconv"
 	"strings"
 	"time"
 
@@ -43,6 +44,15 @@ type IPSets struct {
 	// dirtyIPSetIDs contains IDs of IP sets that need updating.
 	dirtyIPSetIDs  set.Set // <string>
 	resyncRequired bool
+	// ipSetIDsToResync contains IDs of IP sets that were part of a failed update.  Their state in
+	// the dataplane is unknown so we re-read them, and only them, before the next update.
+	ipSetIDsToResync set.Set // <string>
+	// tempIPSetNamesToResync contains the names of the temporary IP sets that a failed update
+	// used.  They may have been left behind so the targeted resync checks for them too.
+	tempIPSetNamesToResync set.Set // <string>
+	// tempIPSetNamesInUpdate contains the names of the temporary IP sets used by the current
+	// update.
+	tempIPSetNamesInUpdate set.Set // <string>
 
 	// pendingTempIPSetDeletions contains names of temporary IP sets that need to be deleted.  We use it to
 	// attempt an early deletion of temporary IP sets, if possible.
@@ -77,7 +87,7 @@ func NewIPSets(ipVersionConfig *IPVersionConfig, recorder logutils.OpRecorder) *
 	return NewIPSetsWithShims(
 		ipVersionConfig,
 		recorder,
-		newRealCmd,
+		NewRealCmd,
 		time.Sleep,
 	)
 }
@@ -97,6 +107,9 @@ func NewIPSetsWithShims(
 		mainIPSetNameToIPSet: map[string]*ipSet{},
 
 		dirtyIPSetIDs:             set.New(),
+		ipSetIDsToResync:          set.New(),
+		tempIPSetNamesToResync:    set.New(),
+		tempIPSetNamesInUpdate:    set.New(),
 		pendingTempIPSetDeletions: set.New(),
 		pendingIPSetDeletions:     set.New(),
 		newCmd:                    cmdFactory,
@@ -150,6 +163,7 @@ func (s *IPSets) AddOrReplaceIPSet(setMetadata IPSetMetadata, members []string)
 func (s *IPSets) RemoveIPSet(setID string) {
 	s.logCxt.WithField("setID", setID).Info("Queueing IP set for removal")
 	delete(s.ipSetIDToIPSet, setID)
+	forgetMemberChurn(s.IPVersionConfig.Family, setID)
 	mainIPSetName := s.IPVersionConfig.NameForMainIPSet(setID)
 	delete(s.mainIPSetNameToIPSet, mainIPSetName)
 	s.dirtyIPSetIDs.Discard(setID)
@@ -303,6 +317,7 @@ func (s *IPSets) ApplyUpdates() {
 		s.sleep(retryDelay)
 		retryDelay *= 2
 	}
+	numUpdateFailures := 0
 	for attempt := 0; attempt < 10; attempt++ {
 		if attempt > 0 {
 			s.logCxt.Info("Retrying after an ipsets update failure...")
@@ -324,6 +339,23 @@ func (s *IPSets) ApplyUpdates() {
 					"Found inconsistencies in IP sets in dataplane")
 			}
 			s.resyncRequired = false
+			s.ipSetIDsToResync.Clear()
+			s.tempIPSetNamesToResync.Clear()
+		} else if s.ipSetIDsToResync.Len() > 0 || s.tempIPSetNamesToResync.Len() > 0 {
+			// Only re-read the IP sets that were involved in a failed update.
+			s.logCxt.WithField("numIPSets", s.ipSetIDsToResync.Len()).Debug(
+				"Resyncing IP sets that failed to update.")
+			numProblems, err := s.tryTargetedResync()
+			if err != nil {
+				s.logCxt.WithError(err).Warning("Failed to resync IP sets, falling back to a full resync")
+				s.resyncRequired = true
+				backOff()
+				continue
+			}
+			if numProblems > 0 {
+				s.logCxt.WithField("numProblems", numProblems).Warn(
+					"Found inconsistencies in IP sets in dataplane")
+			}
 		}
 
 		numTempSets := s.pendingTempIPSetDeletions.Len()
@@ -335,9 +367,24 @@ func (s *IPSets) ApplyUpdates() {
 
 		if err := s.tryUpdates(); err != nil {
 			// While failed deletions don't cause immediate problems, update failures may mean that our iptables
-			// updates fail.  We need to do an immediate resync.
-			s.logCxt.WithError(err).Warning("Failed to update IP sets. Marking dataplane for resync.")
-			s.resyncRequired = true
+			// updates fail.  We need to do an immediate resync.  After the first failure, we only re-read the
+			// IP sets that we were updating, which is much cheaper than a full resync when there are many IP
+			// sets.  If that doesn't fix things, we fall back to a full resync.
+			numUpdateFailures++
+			if numUpdateFailures == 1 {
+				s.logCxt.WithError(err).Warning("Failed to update IP sets. Marking updated IP sets for resync.")
+				s.dirtyIPSetIDs.Iter(func(item interface{}) error {
+					s.ipSetIDsToResync.Add(item)
+					return nil
+				})
+				s.tempIPSetNamesInUpdate.Iter(func(item interface{}) error {
+					s.tempIPSetNamesToResync.Add(item)
+					return nil
+				})
+			} else {
+				s.logCxt.WithError(err).Warning("Failed to update IP sets. Marking dataplane for resync.")
+				s.resyncRequired = true
+			}
 			countNumIPSetErrors.Inc()
 			backOff()
 			continue
@@ -365,6 +412,99 @@ func (s *IPSets) tryResync() (numProblems int, err error) {
 		}).Debug("Finished IPSets resync")
 	}()
 
+	numProblems, err = s.listAndCompareIPSets("")
+	if err != nil {
+		return
+	}
+
+	// Scan for IP sets that need to be cleaned up.  Create a whitelist containing the IP sets
+	// that we expect to be there.
+	expectedIPSets := set.New()
+	for _, ipSet := range s.ipSetIDToIPSet {
+		expectedIPSets.Add(ipSet.MainIPSetName)
+		s.logCxt.WithFields(log.Fields{
+			"ID":       ipSet.SetID,
+			"mainName": ipSet.MainIPSetName,
+		}).Debug("Whitelisting IP sets.")
+	}
+
+	// Include any pending deletions in the whitelist; this is mainly to separate cleanup logs
+	// from explicit deletion logs.
+	s.pendingIPSetDeletions.Iter(func(item interface{}) error {
+		expectedIPSets.Add(item)
+		return nil
+	})
+
+	// Now look for any left-over IP sets that we should delete and queue up the deletions.
+	s.existingIPSetNames.Iter(func(item interface{}) error {
+		setName := item.(string)
+		if !s.IPVersionConfig.OwnsIPSet(setName) {
+			s.logCxt.WithField("setName", setName).Debug(
+				"Skipping IP set: non Calico or wrong IP version for this pass.")
+			return nil
+		}
+		if expectedIPSets.Contains(setName) {
+			s.logCxt.WithField("setName", setName).Debug("Skipping expected Calico IP set.")
+			return nil
+		}
+		if s.IPVersionConfig.IsTempIPSetName(setName) {
+			// Temporary IP sets get leaked after a failure but they should never be in use by iptables so
+			// we try to delete them early in the processing to free up IP set space.
+			s.logCxt.WithField("setName", setName).Info(
+				"Resync found left-over temporary IP set. Queueing early deletion.")
+			s.pendingTempIPSetDeletions.Add(setName)
+		}
+		s.logCxt.WithField("setName", setName).Info(
+			"Resync found left-over Calico IP set. Queueing deletion.")
+		s.pendingIPSetDeletions.Add(setName)
+		return nil
+	})
+
+	return
+}
+
+// parseMaxElem extracts the maximum size of an IP set from the "Header:" line of 'ipset list'.
+// Returns 0 if the line doesn't include it.
+func parseMaxElem(line string) int {
+	fields := strings.Fields(line)
+	for i := 0; i < len(fields)-1; i++ {
+		if fields[i] == "maxelem" {
+			maxElem, err := strconv.Atoi(fields[i+1])
+			if err != nil {
+				return 0
+			}
+			return maxElem
+		}
+	}
+	return 0
+}
+
+// adoptExternalIPSet handles an IP set with externally-managed members that we found in the
+// dataplane.  If we were about to create it but it already exists with the right size, we take
+// it as-is so that we don't wipe out its members (for example, after a restart).  If its size
+// is wrong, we go ahead with the rewrite; the owner of the members has to repopulate it.
+func (s *IPSets) adoptExternalIPSet(ipSet *ipSet, maxSize int) {
+	if ipSet.pendingReplace == nil {
+		return
+	}
+	logCxt := s.logCxt.WithFields(log.Fields{
+		"setID":          ipSet.SetID,
+		"dataplaneSize":  maxSize,
+		"configuredSize": ipSet.MaxSize,
+	})
+	if maxSize != ipSet.MaxSize {
+		logCxt.Info("Externally-managed IP set has the wrong size, will recreate it.")
+		return
+	}
+	logCxt.Debug("Adopting existing externally-managed IP set.")
+	ipSet.pendingReplace = nil
+	ipSet.members = set.New()
+	s.dirtyIPSetIDs.Discard(ipSet.SetID)
+}
+
+// listAndCompareIPSets runs 'ipset list', for the given IP set or, if setName is "", for all IP
+// sets, and queues up updates to any of our IP sets that are out-of-sync.
+func (s *IPSets) listAndCompareIPSets(setName string) (numProblems int, err error) {
 	// Start an 'ipset list' child process, which will emit output of the following form:
 	//
 	// 	Name: test-100
@@ -389,7 +529,11 @@ func (s *IPSets) tryResync() (numProblems int, err error) {
 	//
 	// As we stream through the data, we extract the name of the IP set and its members. We
 	// use the IP set's metadata to convert each member to its canonical form for comparison.
-	cmd := s.newCmd("ipset", "list")
+	args := []string{"list"}
+	if setName != "" {
+		args = append(args, setName)
+	}
+	cmd := s.newCmd("ipset", args...)
 	// Grab stdout as a pipe so we can stream through the (potentially very large) output.
 	out, err := cmd.StdoutPipe()
 	if err != nil {
@@ -406,11 +550,14 @@ func (s *IPSets) tryResync() (numProblems int, err error) {
 		return
 	}
 	summaryExecStart.Observe(float64(time.Since(execStartTime).Nanoseconds()) / 1000.0)
-	// Clear the set of known IP sets names, we'll fill it back in as we scan.
-	s.existingIPSetNames.Clear()
+	if setName == "" {
+		// Clear the set of known IP sets names, we'll fill it back in as we scan.
+		s.existingIPSetNames.Clear()
+	}
 	// Use a scanner to chunk the input into lines.
 	scanner := bufio.NewScanner(out)
 	ipSetName := ""
+	ipSetMaxSize := 0
 
 	// Figure out if debug logging is enabled so we can disable some expensive-to-calculate logs
 	// in the tight loop below if they're not going to be emitted.  This speeds up the loop
@@ -422,8 +569,12 @@ func (s *IPSets) tryResync() (numProblems int, err error) {
 		if strings.HasPrefix(line, "Name:") {
 			ipSetName = strings.Split(line, " ")[1]
 			s.existingIPSetNames.Add(ipSetName)
+			ipSetMaxSize = 0
 			s.logCxt.WithField("setName", ipSetName).Debug("Parsing IP set.")
 		}
+		if strings.HasPrefix(line, "Header:") {
+			ipSetMaxSize = parseMaxElem(line)
+		}
 		if strings.HasPrefix(line, "Members:") {
 			// Start of a Members entry, following this, there'll be one member per
 			// line then EOF or a blank line.
@@ -431,10 +582,13 @@ func (s *IPSets) tryResync() (numProblems int, err error) {
 			// Look up to see if this is one of our IP sets.
 			ipSet := s.mainIPSetNameToIPSet[ipSetName]
 			logCxt := s.logCxt.WithField("setName", ipSetName)
-			if ipSet == nil || ipSet.members == nil {
+			if ipSet != nil && ipSet.ExternalMembers {
+				s.adoptExternalIPSet(ipSet, ipSetMaxSize)
+			}
+			if ipSet == nil || ipSet.members == nil || ipSet.ExternalMembers {
 				// Either this is not one of our IP sets, or it's one that we're
-				// about to rewrite.  Either way, we don't care about its members
-				// so simply scan past them.
+				// about to rewrite, or its members aren't ours to manage.  Either
+				// way, we don't care about its members so simply scan past them.
 				logCxt.Debug("Skipping IP set, either not ours or about to rewrite")
 				for scanner.Scan() {
 					line := scanner.Bytes()
@@ -567,6 +721,12 @@ func (s *IPSets) tryResync() (numProblems int, err error) {
 		return
 	}
 	if err != nil {
+		if setName != "" && strings.Contains(stderr.String(), "does not exist") {
+			s.onIPSetMissing(setName)
+			numProblems++
+			err = nil
+			return
+		}
 		logCxt.WithError(err).Error("Bad return code from 'ipset list'.")
 		return
 	}
@@ -576,52 +736,76 @@ func (s *IPSets) tryResync() (numProblems int, err error) {
 		return
 	}
 
-	// Scan for IP sets that need to be cleaned up.  Create a whitelist containing the IP sets
-	// that we expect to be there.
-	expectedIPSets := set.New()
-	for _, ipSet := range s.ipSetIDToIPSet {
-		expectedIPSets.Add(ipSet.MainIPSetName)
-		s.logCxt.WithFields(log.Fields{
-			"ID":       ipSet.SetID,
-			"mainName": ipSet.MainIPSetName,
-		}).Debug("Whitelisting IP sets.")
-	}
+	return
+}
 
-	// Include any pending deletions in the whitelist; this is mainly to separate cleanup logs
-	// from explicit deletion logs.
-	s.pendingIPSetDeletions.Iter(func(item interface{}) error {
-		expectedIPSets.Add(item)
-		return nil
+// tryTargetedResync re-reads the IP sets in ipSetIDsToResync and queues up updates to any that are
+// out-of-sync.  Unlike tryResync, it doesn't look for left-over IP sets to clean up.
+func (s *IPSets) tryTargetedResync() (numProblems int, err error) {
+	s.ipSetIDsToResync.Iter(func(item interface{}) error {
+		ipSet := s.ipSetIDToIPSet[item.(string)]
+		if ipSet == nil {
+			// IP set has since been removed.
+			return set.RemoveItem
+		}
+		var n int
+		n, err = s.listAndCompareIPSets(ipSet.MainIPSetName)
+		if err != nil {
+			return set.StopIteration
+		}
+		numProblems += n
+		return set.RemoveItem
 	})
-
-	// Now look for any left-over IP sets that we should delete and queue up the deletions.
-	s.existingIPSetNames.Iter(func(item interface{}) error {
+	if err != nil {
+		return
+	}
+	s.tempIPSetNamesToResync.Iter(func(item interface{}) error {
 		setName := item.(string)
-		if !s.IPVersionConfig.OwnsIPSet(setName) {
-			s.logCxt.WithField("setName", setName).Debug(
-				"Skipping IP set: non Calico or wrong IP version for this pass.")
-			return nil
-		}
-		if expectedIPSets.Contains(setName) {
-			s.logCxt.WithField("setName", setName).Debug("Skipping expected Calico IP set.")
-			return nil
+		_, err = s.listAndCompareIPSets(setName)
+		if err != nil {
+			return set.StopIteration
 		}
-		if s.IPVersionConfig.IsTempIPSetName(setName) {
-			// Temporary IP sets get leaked after a failure but they should never be in use by iptables so
-			// we try to delete them early in the processing to free up IP set space.
+		if s.existingIPSetNames.Contains(setName) {
 			s.logCxt.WithField("setName", setName).Info(
 				"Resync found left-over temporary IP set. Queueing early deletion.")
 			s.pendingTempIPSetDeletions.Add(setName)
+			s.pendingIPSetDeletions.Add(setName)
 		}
-		s.logCxt.WithField("setName", setName).Info(
-			"Resync found left-over Calico IP set. Queueing deletion.")
-		s.pendingIPSetDeletions.Add(setName)
-		return nil
+		return set.RemoveItem
 	})
-
 	return
 }
 
+// onIPSetMissing handles one of our IP sets being missing from the dataplane, which we only find
+// out about in a targeted resync.  If we were planning a delta update, we switch to a full rewrite,
+// which recreates the IP set.
+func (s *IPSets) onIPSetMissing(setName string) {
+	s.existingIPSetNames.Discard(setName)
+	ipSet := s.mainIPSetNameToIPSet[setName]
+	if ipSet == nil || ipSet.pendingReplace != nil {
+		// Not ours, or we're about to rewrite it anyway.
+		return
+	}
+	s.logCxt.WithField("setID", ipSet.SetID).Warning(
+		"Resync found IP set missing from dataplane. Queueing a rewrite to recreate it.")
+	desiredMembers := set.New()
+	ipSet.members.Iter(func(m interface{}) error {
+		desiredMembers.Add(m)
+		return nil
+	})
+	ipSet.pendingAdds.Iter(func(m interface{}) error {
+		desiredMembers.Add(m)
+		return set.RemoveItem
+	})
+	ipSet.pendingDeletions.Iter(func(m interface{}) error {
+		desiredMembers.Discard(m)
+		return set.RemoveItem
+	})
+	ipSet.members = nil
+	ipSet.pendingReplace = desiredMembers
+	s.dirtyIPSetIDs.Add(ipSet.SetID)
+}
+
 // tryUpdates attempts to create and/or update IP sets.  It attempts to do the updates as a single
 // 'ipset restore' session in order to minimise process forking overhead.  Note: unlike
 // 'iptables-restore', 'ipset restore' is not atomic, updates are applied individually.
@@ -632,6 +816,7 @@ func (s *IPSets) tryUpdates() error {
 	}
 
 	s.opReporter.RecordOperation(fmt.Sprint("update-ipsets-", s.IPVersionConfig.Family.Version()))
+	s.tempIPSetNamesInUpdate.Clear()
 
 	// Set up an ipset restore session.
 	countNumIPSetCalls.Inc()
@@ -667,16 +852,30 @@ func (s *IPSets) tryUpdates() error {
 	}
 	summaryExecStart.Observe(float64(time.Since(startTime).Nanoseconds()) / 1000.0)
 
-	// Ask each dirty IP set to write its updates to the stream.
+	// Ask each dirty IP set to write its deletions to the stream, then its other updates.  Doing
+	// all the deletions first means that, when an IP moves from one endpoint to another, it is
+	// removed from the old endpoint's IP sets before it is added to the new endpoint's.
 	var writeErr error
 	s.dirtyIPSetIDs.Iter(func(item interface{}) error {
 		ipSet := s.ipSetIDToIPSet[item.(string)]
-		writeErr = s.writeUpdates(ipSet, stdin)
+		if ipSet.pendingReplace == nil {
+			writeErr = s.writeDeltaDeletions(ipSet, stdin, s.logCxt.WithField("setID", ipSet.SetID))
+		}
 		if writeErr != nil {
 			return set.StopIteration
 		}
 		return nil
 	})
+	if writeErr == nil {
+		s.dirtyIPSetIDs.Iter(func(item interface{}) error {
+			ipSet := s.ipSetIDToIPSet[item.(string)]
+			writeErr = s.writeUpdates(ipSet, stdin)
+			if writeErr != nil {
+				return set.StopIteration
+			}
+			return nil
+		})
+	}
 	// Finish off the input, then flush and close the input, or the command won't terminate.
 	// We need to close and wait whether we hit a write error or not so we defer the error
 	// handling.
@@ -703,6 +902,7 @@ func (s *IPSets) tryUpdates() error {
 	// and figure out how much of our update succeeded.
 	s.dirtyIPSetIDs.Iter(func(item interface{}) error {
 		ipSet := s.ipSetIDToIPSet[item.(string)]
+		s.memberChurn(ipSet).record(s.logCxt, s.IPVersionConfig.Family, ipSet.IPSetMetadata, s.opReporter)
 		if ipSet.pendingReplace != nil {
 			ipSet.members = ipSet.pendingReplace
 			ipSet.pendingReplace = nil
@@ -725,6 +925,20 @@ func (s *IPSets) tryUpdates() error {
 	return nil
 }
 
+// memberChurn returns the number of members that the pending update of the IP set adds and
+// removes.
+func (s *IPSets) memberChurn(ipSet *ipSet) memberChurn {
+	if ipSet.pendingReplace != nil {
+		// Full rewrite, we don't know what was in the dataplane before.
+		return memberChurn{numBefore: -1, numAdds: ipSet.pendingReplace.Len()}
+	}
+	return memberChurn{
+		numBefore:  ipSet.members.Len(),
+		numAdds:    ipSet.pendingAdds.Len(),
+		numDeletes: ipSet.pendingDeletions.Len(),
+	}
+}
+
 func (s *IPSets) writeUpdates(ipSet *ipSet, w io.Writer) error {
 	logCxt := s.logCxt.WithField("setID", ipSet.SetID)
 	if ipSet.members != nil {
@@ -751,7 +965,8 @@ func (s *IPSets) writeUpdates(ipSet *ipSet, w io.Writer) error {
 			return nil
 		}
 		logCxt.Info("Calculating deltas to IP set")
-		return s.writeDeltas(ipSet, w, logCxt)
+		// Deletions have already been written by writeDeltaDeletions.
+		return s.writeDeltaAdds(ipSet, w, logCxt)
 	}
 	// In full-rewrite mode.
 	// - pendingReplace is non-nil
@@ -796,6 +1011,7 @@ func (s *IPSets) writeFullRewrite(ipSet *ipSet, out io.Writer, logCxt log.FieldL
 			mainSetName, ipSet.Type, s.IPVersionConfig.Family, ipSet.MaxSize)
 	}
 	tempSetName := s.nextFreeTempIPSetName()
+	s.tempIPSetNamesInUpdate.Add(tempSetName)
 	// Create the temporary IP set with the current parameters.
 	writeLine("create %s %s family %s maxelem %d",
 		tempSetName, ipSet.Type, s.IPVersionConfig.Family, ipSet.MaxSize)
@@ -829,9 +1045,9 @@ func (s *IPSets) nextFreeTempIPSetName() string {
 	}
 }
 
-// writeDeltas calculates the ipset restore input required to apply the pending adds/deletes to the
-// main IP set.
-func (s *IPSets) writeDeltas(ipSet *ipSet, out io.Writer, logCxt log.FieldLogger) (err error) {
+// writeDeltaDeletions calculates the ipset restore input required to apply the pending deletes to
+// the given IP set.  It is used in delta mode, before writeDeltaAdds.
+func (s *IPSets) writeDeltaDeletions(ipSet *ipSet, out io.Writer, logCxt log.FieldLogger) (err error) {
 	mainSetName := ipSet.MainIPSetName
 	ipSet.pendingDeletions.Iter(func(item interface{}) error {
 		member := item.(ipSetMember)
@@ -843,9 +1059,13 @@ func (s *IPSets) writeDeltas(ipSet *ipSet, out io.Writer, logCxt log.FieldLogger
 		countNumIPSetLinesExecuted.Inc()
 		return nil
 	})
-	if err != nil {
-		return
-	}
+	return
+}
+
+// writeDeltaAdds calculates the ipset restore input required to apply the pending adds to the
+// given IP set.
+func (s *IPSets) writeDeltaAdds(ipSet *ipSet, out io.Writer, logCxt log.FieldLogger) (err error) {
+	mainSetName := ipSet.MainIPSetName
 	ipSet.pendingAdds.Iter(func(item interface{}) error {
 		member := item.(ipSetMember)
 		logCxt.WithField("member", member).Debug("Writing add")
@@ -859,8 +1079,6 @@ func (s *IPSets) writeDeltas(ipSet *ipSet, out io.Writer, logCxt log.FieldLogger
 	return
 }
 
-// ApplyDeletions tries to delete any IP sets that are no longer needed.
-// Failures are ignored, deletions will be retried the next time we do a resync.
 func (s *IPSets) ApplyDeletions() {
 	s.pendingIPSetDeletions.Iter(func(item interface{}) error {
 		setName := item.(string)
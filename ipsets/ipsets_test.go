@@ -217,11 +217,14 @@ var _ = Describe("IP sets dataplane", func() {
 		apply()
 	}
 
+	var summarizer *logutils.Summarizer
+
 	BeforeEach(func() {
 		dataplane = newMockDataplane()
+		summarizer = logutils.NewSummarizer("test loop")
 		ipsets = NewIPSetsWithShims(
 			v4VersionConf,
-			logutils.NewSummarizer("test loop"),
+			summarizer,
 			dataplane.newCmd,
 			dataplane.sleep,
 		)
@@ -247,6 +250,18 @@ var _ = Describe("IP sets dataplane", func() {
 		})
 	})
 
+	It("should report the members that it added and removed", func() {
+		ipsets.AddOrReplaceIPSet(meta, []string{"10.0.0.1", "10.0.0.2"})
+		apply()
+		ipsets.AddMembers(ipSetID, []string{"10.0.0.3"})
+		ipsets.RemoveMembers(ipSetID, []string{"10.0.0.1"})
+		apply()
+		Expect(summarizer.CurrentCounts()).To(Equal(map[string]int{
+			logutils.CountIPSetMembersAdded:   3,
+			logutils.CountIPSetMembersRemoved: 1,
+		}))
+	})
+
 	It("mainline: should ignore IPs of wrong version", func() {
 		ipsets.AddOrReplaceIPSet(meta, []string{"10.0.0.1", "10.0.0.2", "fe80::1", "fe80::2"})
 		ipsets.AddMembers(ipSetID, []string{"10.0.0.3", "10.0.0.4", "fe80::2", "fe80::3"})
@@ -496,6 +511,29 @@ var _ = Describe("IP sets dataplane", func() {
 				apply()
 			})
 
+			It("should write all deletions before any adds when an IP moves between sets", func() {
+				dataplane.RestoreLines = nil
+				ipsets.AddMembers(ipSetID2, []string{"10.0.0.2"})
+				ipsets.RemoveMembers(ipSetID, []string{"10.0.0.2"})
+				ipsets.AddMembers(ipSetID, []string{"10.0.0.3"})
+				ipsets.RemoveMembers(ipSetID2, []string{"10.0.0.3"})
+				apply()
+				Expect(dataplane.RestoreLines).To(HaveLen(5))
+				Expect(dataplane.RestoreLines[:2]).To(ConsistOf(
+					"del "+v4MainIPSetName+" 10.0.0.2 --exist",
+					"del "+v4MainIPSetName2+" 10.0.0.3 --exist",
+				))
+				Expect(dataplane.RestoreLines[2:4]).To(ConsistOf(
+					"add "+v4MainIPSetName2+" 10.0.0.2",
+					"add "+v4MainIPSetName+" 10.0.0.3",
+				))
+				Expect(dataplane.RestoreLines[4]).To(Equal("COMMIT"))
+				dataplane.ExpectMembers(map[string][]string{
+					v4MainIPSetName:  {"10.0.0.1", "10.0.0.3"},
+					v4MainIPSetName2: {"10.0.0.1", "10.0.0.2"},
+				})
+			})
+
 			It("should update the dataplane", func() {
 				dataplane.ExpectMembers(map[string][]string{
 					v4MainIPSetName:  {"10.0.0.1", "10.0.0.2"},
@@ -574,6 +612,58 @@ var _ = Describe("IP sets dataplane", func() {
 			})
 		})
 
+		Describe("after a failed update", func() {
+			listedSets := func() []string {
+				var names []string
+				for _, cmd := range dataplane.Cmds {
+					if l, ok := cmd.(*listCmd); ok {
+						names = append(names, l.SetName)
+					}
+				}
+				return names
+			}
+
+			BeforeEach(func() {
+				ipsets.AddOrReplaceIPSet(meta2, []string{"10.0.0.3"})
+				apply()
+				dataplane.Cmds = nil
+			})
+
+			It("should only re-read the IP sets that it was updating", func() {
+				dataplane.RestoreOpFailures = []string{"post-update"}
+				ipsets.AddMembers(ipSetID, []string{"10.0.0.3"})
+				apply()
+				Expect(listedSets()).To(Equal([]string{v4MainIPSetName}))
+				dataplane.ExpectMembers(map[string][]string{
+					v4MainIPSetName:  {"10.0.0.1", "10.0.0.2", "10.0.0.3"},
+					v4MainIPSetName2: {"10.0.0.3"},
+				})
+				Expect(dataplane.TriedToAddExistent).To(BeFalse())
+			})
+
+			It("should recreate an IP set that was deleted from under it", func() {
+				delete(dataplane.IPSetMembers, v4MainIPSetName)
+				ipsets.AddMembers(ipSetID, []string{"10.0.0.3"})
+				apply()
+				Expect(listedSets()).To(Equal([]string{v4MainIPSetName}))
+				dataplane.ExpectMembers(map[string][]string{
+					v4MainIPSetName:  {"10.0.0.1", "10.0.0.2", "10.0.0.3"},
+					v4MainIPSetName2: {"10.0.0.3"},
+				})
+			})
+
+			It("should fall back to a full resync if the update fails again", func() {
+				dataplane.RestoreOpFailures = []string{"pre-update", "pre-update"}
+				ipsets.AddMembers(ipSetID, []string{"10.0.0.3"})
+				apply()
+				Expect(listedSets()).To(Equal([]string{v4MainIPSetName, ""}))
+				dataplane.ExpectMembers(map[string][]string{
+					v4MainIPSetName:  {"10.0.0.1", "10.0.0.2", "10.0.0.3"},
+					v4MainIPSetName2: {"10.0.0.3"},
+				})
+			})
+		})
+
 		Describe("with a persistent ipset restore failure", func() {
 			BeforeEach(func() {
 				dataplane.FailAllRestores = true
@@ -786,6 +876,94 @@ var _ = Describe("IP sets dataplane", func() {
 		resyncAndApply()
 		dataplane.ExpectMembers(map[string][]string{"noncali": v4Members1And2})
 	})
+
+	Describe("with externally-managed members", func() {
+		var externalMeta IPSetMetadata
+
+		BeforeEach(func() {
+			externalMeta = meta
+			externalMeta.ExternalMembers = true
+		})
+
+		It("should create the IP set empty", func() {
+			ipsets.AddOrReplaceIPSet(externalMeta, nil)
+			apply()
+			dataplane.ExpectMembers(map[string][]string{v4MainIPSetName: {}})
+			Expect(dataplane.IPSetMetadata[v4MainIPSetName].MaxSize).To(Equal(1234))
+		})
+
+		Describe("with the IP set already in the dataplane", func() {
+			BeforeEach(func() {
+				dataplane.IPSetMembers[v4MainIPSetName] = set.From("10.0.0.1", "10.0.0.2")
+				dataplane.IPSetMetadata[v4MainIPSetName] = setMetadata{
+					Name: v4MainIPSetName, Family: IPFamilyV4, Type: IPSetTypeHashIP, MaxSize: 1234,
+				}
+				ipsets.AddOrReplaceIPSet(externalMeta, nil)
+				apply()
+			})
+
+			It("should adopt it without touching its members", func() {
+				dataplane.ExpectMembers(map[string][]string{v4MainIPSetName: v4Members1And2})
+				Expect(dataplane.CmdNames).NotTo(ContainElement("restore"))
+			})
+
+			It("should leave members alone on resync", func() {
+				dataplane.IPSetMembers[v4MainIPSetName].Add("10.0.0.3")
+				resyncAndApply()
+				dataplane.ExpectMembers(map[string][]string{
+					v4MainIPSetName: {"10.0.0.1", "10.0.0.2", "10.0.0.3"},
+				})
+			})
+
+			It("should delete it when removed", func() {
+				ipsets.RemoveIPSet(ipSetID)
+				apply()
+				dataplane.ExpectMembers(map[string][]string{})
+			})
+		})
+
+		It("should recreate an existing IP set of the wrong size", func() {
+			dataplane.IPSetMembers[v4MainIPSetName] = set.From("10.0.0.1")
+			dataplane.IPSetMetadata[v4MainIPSetName] = setMetadata{
+				Name: v4MainIPSetName, Family: IPFamilyV4, Type: IPSetTypeHashIP, MaxSize: 100,
+			}
+			ipsets.AddOrReplaceIPSet(externalMeta, nil)
+			apply()
+			dataplane.ExpectMembers(map[string][]string{v4MainIPSetName: {}})
+			Expect(dataplane.IPSetMetadata[v4MainIPSetName].MaxSize).To(Equal(1234))
+		})
+	})
+
+	Describe("in dry-run mode", func() {
+		var reported []string
+
+		BeforeEach(func() {
+			reported = nil
+			ipsets.EnableDryRun(func(commands []byte) {
+				reported = append(reported, string(commands))
+			})
+		})
+
+		It("should report the updates instead of applying them", func() {
+			ipsets.AddOrReplaceIPSet(meta, v4Members1And2)
+			apply()
+			dataplane.ExpectMembers(map[string][]string{})
+			Expect(reported).To(HaveLen(1))
+			Expect(reported[0]).To(ContainSubstring("add " + v4TempIPSetName0 + " 10.0.0.1"))
+			Expect(reported[0]).To(ContainSubstring("swap " + v4MainIPSetName + " " + v4TempIPSetName0))
+			Expect(reported[0]).To(HaveSuffix("COMMIT\n"))
+		})
+
+		It("should report deletions of IP sets that are in the kernel", func() {
+			dataplane.IPSetMembers[v4MainIPSetName] = set.From("10.0.0.1")
+			dataplane.IPSetMetadata[v4MainIPSetName] = setMetadata{
+				Name: v4MainIPSetName, Family: IPFamilyV4, Type: IPSetTypeHashIP, MaxSize: 1234,
+			}
+			apply()
+			Expect(reported).To(ContainElement("destroy " + v4MainIPSetName + "\n"))
+			dataplane.ExpectMembers(map[string][]string{v4MainIPSetName: {"10.0.0.1"}})
+		})
+	})
 })
 
 var _ = Describe("Standard IPv4 IPVersionConfig", func() {
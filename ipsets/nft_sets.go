@@ -0,0 +1,414 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package ipsets
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/libcalico-go/lib/set"
+
+	"github.com/projectcalico/felix/logutils"
+)
+
+// NFTTableName is the name of the nftables table that holds our sets when the nft backend is in
+// use.  A separate table is used for each IP family.
+const NFTTableName = "calico-ipsets"
+
+// NFTSets is an alternative to IPSets that stores IP sets as named sets in nftables.  It is used
+// on hosts whose kernel lacks the ipset modules.  It supports the same operations as IPSets but
+// since nft applies each script as a transaction, it updates sets in place rather than via
+// temporary sets.
+type NFTSets struct {
+	IPVersionConfig *IPVersionConfig
+
+	ipSetIDToSet map[string]*nftSet
+
+	// existingSetNames contains the names of our sets that are present in the dataplane.
+	existingSetNames set.Set
+	// pendingDeletions contains the names of sets that should be removed from the dataplane.
+	pendingDeletions set.Set
+	dirtyIPSetIDs    set.Set
+	resyncRequired   bool
+
+	// Factory for command objects; shimmed for UT mocking.
+	newCmd cmdFactory
+	// Shim for time.Sleep()
+	sleep func(time.Duration)
+
+	gaugeNumIpsets prometheus.Gauge
+	logCxt         *log.Entry
+	opReporter     logutils.OpRecorder
+}
+
+type nftSet struct {
+	IPSetMetadata
+	Name string
+
+	// members is the desired content of the set.
+	members set.Set
+	// dataplaneMembers is the content that we last wrote to the dataplane, or nil if that is
+	// unknown and the set needs to be rewritten in full.
+	dataplaneMembers set.Set
+}
+
+func NewNFTSets(ipVersionConfig *IPVersionConfig, recorder logutils.OpRecorder) *NFTSets {
+	return NewNFTSetsWithShims(ipVersionConfig, recorder, NewRealCmd, time.Sleep)
+}
+
+// NewNFTSetsWithShims is an internal test constructor.
+func NewNFTSetsWithShims(
+	ipVersionConfig *IPVersionConfig,
+	recorder logutils.OpRecorder,
+	cmdFactory cmdFactory,
+	sleep func(time.Duration),
+) *NFTSets {
+	return &NFTSets{
+		IPVersionConfig:  ipVersionConfig,
+		ipSetIDToSet:     map[string]*nftSet{},
+		existingSetNames: set.New(),
+		pendingDeletions: set.New(),
+		dirtyIPSetIDs:    set.New(),
+		resyncRequired:   true,
+		newCmd:           cmdFactory,
+		sleep:            sleep,
+		gaugeNumIpsets:   gaugeVecNumCalicoIpsets.WithLabelValues(string(ipVersionConfig.Family)),
+		logCxt: log.WithFields(log.Fields{
+			"family":  ipVersionConfig.Family,
+			"backend": "nft",
+		}),
+		opReporter: recorder,
+	}
+}
+
+func (s *NFTSets) AddOrReplaceIPSet(setMetadata IPSetMetadata, members []string) {
+	s.logCxt.WithFields(log.Fields{
+		"setID":   setMetadata.SetID,
+		"setType": setMetadata.Type,
+	}).Info("Queueing IP set for creation")
+	name := s.IPVersionConfig.NameForMainIPSet(setMetadata.SetID)
+	var dataplaneMembers set.Set
+	if existing, ok := s.ipSetIDToSet[setMetadata.SetID]; ok && existing.Type == setMetadata.Type {
+		dataplaneMembers = existing.dataplaneMembers
+	}
+	s.ipSetIDToSet[setMetadata.SetID] = &nftSet{
+		IPSetMetadata:    setMetadata,
+		Name:             name,
+		members:          s.filterAndCanonicaliseMembers(setMetadata.Type, members),
+		dataplaneMembers: dataplaneMembers,
+	}
+	s.dirtyIPSetIDs.Add(setMetadata.SetID)
+	s.pendingDeletions.Discard(name)
+}
+
+func (s *NFTSets) RemoveIPSet(setID string) {
+	s.logCxt.WithField("setID", setID).Info("Queueing IP set for removal")
+	delete(s.ipSetIDToSet, setID)
+	forgetMemberChurn(s.IPVersionConfig.Family, setID)
+	s.dirtyIPSetIDs.Discard(setID)
+	s.pendingDeletions.Add(s.IPVersionConfig.NameForMainIPSet(setID))
+}
+
+func (s *NFTSets) AddMembers(setID string, newMembers []string) {
+	ns := s.ipSetIDToSet[setID]
+	s.filterAndCanonicaliseMembers(ns.Type, newMembers).Iter(func(m interface{}) error {
+		ns.members.Add(m)
+		return nil
+	})
+	s.dirtyIPSetIDs.Add(setID)
+}
+
+func (s *NFTSets) RemoveMembers(setID string, removedMembers []string) {
+	ns := s.ipSetIDToSet[setID]
+	s.filterAndCanonicaliseMembers(ns.Type, removedMembers).Iter(func(m interface{}) error {
+		ns.members.Discard(m)
+		return nil
+	})
+	s.dirtyIPSetIDs.Add(setID)
+}
+
+func (s *NFTSets) QueueResync() {
+	s.logCxt.Debug("Asked to resync with the dataplane on next update.")
+	s.resyncRequired = true
+}
+
+func (s *NFTSets) GetIPFamily() IPFamily {
+	return s.IPVersionConfig.Family
+}
+
+func (s *NFTSets) GetTypeOf(setID string) (IPSetType, error) {
+	ns, ok := s.ipSetIDToSet[setID]
+	if !ok {
+		return "", fmt.Errorf("ipset %s not found", setID)
+	}
+	return ns.Type, nil
+}
+
+func (s *NFTSets) GetMembers(setID string) (set.Set, error) {
+	ns, ok := s.ipSetIDToSet[setID]
+	if !ok {
+		return nil, fmt.Errorf("ipset %s not found", setID)
+	}
+	return ipSetMemberSetToStringSet(ns.members), nil
+}
+
+func (s *NFTSets) filterAndCanonicaliseMembers(ipSetType IPSetType, members []string) set.Set {
+	filtered := set.New()
+	wantIPV6 := s.IPVersionConfig.Family == IPFamilyV6
+	for _, member := range members {
+		if ipSetType.IsMemberIPV6(member) != wantIPV6 {
+			continue
+		}
+		filtered.Add(ipSetType.CanonicaliseMember(member))
+	}
+	return filtered
+}
+
+// nftFamily returns the nftables address family that corresponds to our IP version.
+func (s *NFTSets) nftFamily() string {
+	if s.IPVersionConfig.Family == IPFamilyV6 {
+		return "ip6"
+	}
+	return "ip"
+}
+
+func (s *NFTSets) ApplyUpdates() {
+	retryDelay := 1 * time.Millisecond
+	for attempt := 0; attempt < 10; attempt++ {
+		if attempt > 0 {
+			s.logCxt.Info("Retrying after an nft sets update failure...")
+			s.sleep(retryDelay)
+			retryDelay *= 2
+		}
+		if s.resyncRequired {
+			s.opReporter.RecordOperation(fmt.Sprint("resync-nft-sets-v", s.IPVersionConfig.Family.Version()))
+			if err := s.tryResync(); err != nil {
+				s.logCxt.WithError(err).Warning("Failed to resync with dataplane")
+				continue
+			}
+			s.resyncRequired = false
+		}
+		if err := s.tryUpdates(); err != nil {
+			s.logCxt.WithError(err).Warning("Failed to update nft sets. Marking dataplane for resync.")
+			s.resyncRequired = true
+			countNumIPSetErrors.Inc()
+			continue
+		}
+		s.gaugeNumIpsets.Set(float64(len(s.ipSetIDToSet)))
+		return
+	}
+	s.logCxt.Panic("Failed to update nft sets after multiple retries.")
+}
+
+// tryResync loads the names of the sets in our table.  Since listing the members of every set is
+// expensive, it then marks all our sets for a full rewrite, which nft does atomically.
+func (s *NFTSets) tryResync() error {
+	out, err := s.runNFT(nil, "list", "table", s.nftFamily(), NFTTableName)
+	if err != nil {
+		if strings.Contains(string(out), "No such file or directory") {
+			// Our table doesn't exist yet; ApplyUpdates will create it.
+			out = nil
+		} else {
+			return err
+		}
+	}
+	s.existingSetNames = parseNFTSetNames(out)
+	s.existingSetNames.Iter(func(item interface{}) error {
+		if !s.IPVersionConfig.OwnsIPSet(item.(string)) {
+			return nil
+		}
+		s.pendingDeletions.Add(item)
+		return nil
+	})
+	for setID, ns := range s.ipSetIDToSet {
+		s.pendingDeletions.Discard(ns.Name)
+		ns.dataplaneMembers = nil
+		s.dirtyIPSetIDs.Add(setID)
+	}
+	return nil
+}
+
+// parseNFTSetNames extracts the set names from the output of "nft list table".
+func parseNFTSetNames(out []byte) set.Set {
+	names := set.New()
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 && fields[0] == "set" && fields[2] == "{" {
+			names.Add(strings.Trim(fields[1], "\""))
+		}
+	}
+	return names
+}
+
+func (s *NFTSets) tryUpdates() error {
+	if s.dirtyIPSetIDs.Len() == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	family := s.nftFamily()
+	fmt.Fprintf(&buf, "add table %s %s\n", family, NFTTableName)
+	churn := map[string]memberChurn{}
+	s.dirtyIPSetIDs.Iter(func(item interface{}) error {
+		ns := s.ipSetIDToSet[item.(string)]
+		churn[ns.SetID] = s.writeSetUpdate(&buf, family, ns)
+		return nil
+	})
+	if _, err := s.runNFT(buf.Bytes(), "-f", "-"); err != nil {
+		return err
+	}
+	s.dirtyIPSetIDs.Iter(func(item interface{}) error {
+		ns := s.ipSetIDToSet[item.(string)]
+		churn[ns.SetID].record(s.logCxt, s.IPVersionConfig.Family, ns.IPSetMetadata, s.opReporter)
+		ns.dataplaneMembers = ns.members.Copy()
+		s.existingSetNames.Add(ns.Name)
+		return set.RemoveItem
+	})
+	return nil
+}
+
+// writeSetUpdate writes the nft commands to bring the set in line with its desired members and
+// returns the number of members that they add and remove.
+func (s *NFTSets) writeSetUpdate(buf *bytes.Buffer, family string, ns *nftSet) memberChurn {
+	var adds, dels []string
+	numBefore := -1
+	if ns.dataplaneMembers == nil {
+		if s.existingSetNames.Contains(ns.Name) {
+			// Delete first in case the type has changed; nft applies the whole script
+			// atomically so the set is never missing from the dataplane.
+			fmt.Fprintf(buf, "delete set %s %s %q\n", family, NFTTableName, ns.Name)
+		}
+		fmt.Fprintf(buf, "add set %s %s %q { %s }\n", family, NFTTableName, ns.Name, s.nftSetSpec(ns.Type))
+		ns.members.Iter(func(item interface{}) error {
+			adds = append(adds, nftMember(item.(ipSetMember)))
+			return nil
+		})
+	} else {
+		numBefore = ns.dataplaneMembers.Len()
+		ns.members.Iter(func(item interface{}) error {
+			if !ns.dataplaneMembers.Contains(item) {
+				adds = append(adds, nftMember(item.(ipSetMember)))
+			}
+			return nil
+		})
+		ns.dataplaneMembers.Iter(func(item interface{}) error {
+			if !ns.members.Contains(item) {
+				dels = append(dels, nftMember(item.(ipSetMember)))
+			}
+			return nil
+		})
+	}
+	if len(dels) > 0 {
+		fmt.Fprintf(buf, "delete element %s %s %q { %s }\n", family, NFTTableName, ns.Name, strings.Join(dels, ", "))
+	}
+	if len(adds) > 0 {
+		fmt.Fprintf(buf, "add element %s %s %q { %s }\n", family, NFTTableName, ns.Name, strings.Join(adds, ", "))
+	}
+	countNumIPSetLinesExecuted.Add(float64(len(adds) + len(dels)))
+	return memberChurn{numBefore: numBefore, numAdds: len(adds), numDeletes: len(dels)}
+}
+
+func (s *NFTSets) nftSetSpec(t IPSetType) string {
+	addrType := "ipv4_addr"
+	if s.IPVersionConfig.Family == IPFamilyV6 {
+		addrType = "ipv6_addr"
+	}
+	switch t {
+	case IPSetTypeHashNet:
+		return fmt.Sprintf("type %s; flags interval; auto-merge;", addrType)
+	case IPSetTypeHashIPPort:
+		return fmt.Sprintf("type %s . inet_proto . inet_service;", addrType)
+	}
+	return fmt.Sprintf("type %s;", addrType)
+}
+
+// nftMember converts a canonical IP set member to nft's syntax.
+func nftMember(m ipSetMember) string {
+	switch m := m.(type) {
+	case V4IPPort:
+		return fmt.Sprintf("%s . %s . %d", m.IP, m.Protocol, m.Port)
+	case V6IPPort:
+		return fmt.Sprintf("%s . %s . %d", m.IP, m.Protocol, m.Port)
+	}
+	return m.String()
+}
+
+func (s *NFTSets) ApplyDeletions() {
+	if s.pendingDeletions.Len() == 0 {
+		return
+	}
+	family := s.nftFamily()
+	var buf bytes.Buffer
+	s.pendingDeletions.Iter(func(item interface{}) error {
+		name := item.(string)
+		if s.existingSetNames.Contains(name) {
+			fmt.Fprintf(&buf, "delete set %s %s %q\n", family, NFTTableName, name)
+		}
+		return nil
+	})
+	if buf.Len() > 0 {
+		if _, err := s.runNFT(buf.Bytes(), "-f", "-"); err != nil {
+			// Failed deletions don't cause immediate problems; resync to find out what's left.
+			s.logCxt.WithError(err).Warning("Failed to delete nft sets. Marking dataplane for resync.")
+			s.resyncRequired = true
+			countNumIPSetErrors.Inc()
+			return
+		}
+	}
+	s.pendingDeletions.Iter(func(item interface{}) error {
+		s.existingSetNames.Discard(item)
+		return set.RemoveItem
+	})
+}
+
+func (s *NFTSets) runNFT(stdin []byte, args ...string) ([]byte, error) {
+	countNumIPSetCalls.Inc()
+	cmd := s.newCmd("nft", args...)
+	if stdin != nil {
+		cmd.SetStdin(bytes.NewReader(stdin))
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		s.logCxt.WithFields(log.Fields{
+			"args":   args,
+			"output": string(out),
+			"input":  string(stdin),
+		}).WithError(err).Warn("nft command failed")
+		return out, err
+	}
+	return out, nil
+}
+
+// KernelSupportsIPSets returns true if the ipset command is able to talk to the kernel.  On hosts
+// whose kernel lacks the ipset modules, NFTSets should be used instead of IPSets.
+func KernelSupportsIPSets() bool {
+	return KernelSupportsIPSetsWithShim(NewRealCmd)
+}
+
+// KernelSupportsIPSetsWithShim is an internal test function.
+func KernelSupportsIPSetsWithShim(cmdFactory cmdFactory) bool {
+	out, err := cmdFactory("ipset", "list", "-n").CombinedOutput()
+	if err != nil {
+		log.WithError(err).WithField("output", string(out)).Info(
+			"ipset command failed, assuming that the kernel doesn't support IP sets.")
+		return false
+	}
+	return true
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package ipsets_test
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/projectcalico/felix/ipsets"
+	"github.com/projectcalico/felix/logutils"
+)
+
+var _ = Describe("NFTSets", func() {
+	var (
+		dataplane *mockNFT
+		nftSets   *NFTSets
+		v4Config  *IPVersionConfig
+	)
+
+	meta := func(setType IPSetType) IPSetMetadata {
+		return IPSetMetadata{SetID: "s:qMt7iLlGDhvLnCjM0l9nzxb", Type: setType, MaxSize: 1024}
+	}
+	setName := "cali40s:qMt7iLlGDhvLnCjM0l9nzxb"
+
+	BeforeEach(func() {
+		dataplane = &mockNFT{listOutput: "table ip calico-ipsets {\n}\n"}
+		v4Config = NewIPVersionConfig(IPFamilyV4, "cali", nil, nil)
+		nftSets = NewNFTSetsWithShims(v4Config, logutils.NewSummarizer("test loop"), dataplane.newCmd,
+			func(time.Duration) {})
+	})
+
+	It("should create a set with its members", func() {
+		nftSets.AddOrReplaceIPSet(meta(IPSetTypeHashIP), []string{"10.0.0.1", "fe80::1"})
+		nftSets.ApplyUpdates()
+		Expect(dataplane.scripts).To(Equal([]string{
+			"add table ip calico-ipsets\n" +
+				"add set ip calico-ipsets \"" + setName + "\" { type ipv4_addr; }\n" +
+				"add element ip calico-ipsets \"" + setName + "\" { 10.0.0.1 }\n",
+		}))
+	})
+
+	It("should render IP,port and net sets", func() {
+		nftSets.AddOrReplaceIPSet(meta(IPSetTypeHashIPPort), []string{"10.0.0.1,tcp:80"})
+		nftSets.AddOrReplaceIPSet(IPSetMetadata{SetID: "n", Type: IPSetTypeHashNet}, []string{"10.0.0.0/8"})
+		nftSets.ApplyUpdates()
+		Expect(dataplane.scripts).To(HaveLen(1))
+		Expect(dataplane.scripts[0]).To(ContainSubstring(
+			"add set ip calico-ipsets \"" + setName + "\" { type ipv4_addr . inet_proto . inet_service; }\n" +
+				"add element ip calico-ipsets \"" + setName + "\" { 10.0.0.1 . tcp . 80 }\n"))
+		Expect(dataplane.scripts[0]).To(ContainSubstring(
+			"add set ip calico-ipsets \"cali40n\" { type ipv4_addr; flags interval; auto-merge; }\n" +
+				"add element ip calico-ipsets \"cali40n\" { 10.0.0.0/8 }\n"))
+	})
+
+	It("should apply deltas to an existing set", func() {
+		nftSets.AddOrReplaceIPSet(meta(IPSetTypeHashIP), []string{"10.0.0.1", "10.0.0.2"})
+		nftSets.ApplyUpdates()
+		nftSets.AddMembers(meta(IPSetTypeHashIP).SetID, []string{"10.0.0.3"})
+		nftSets.RemoveMembers(meta(IPSetTypeHashIP).SetID, []string{"10.0.0.1"})
+		nftSets.ApplyUpdates()
+		Expect(dataplane.scripts).To(HaveLen(2))
+		Expect(dataplane.scripts[1]).To(Equal("add table ip calico-ipsets\n" +
+			"delete element ip calico-ipsets \"" + setName + "\" { 10.0.0.1 }\n" +
+			"add element ip calico-ipsets \"" + setName + "\" { 10.0.0.3 }\n"))
+		members, err := nftSets.GetMembers(meta(IPSetTypeHashIP).SetID)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(members.Contains("10.0.0.2")).To(BeTrue())
+		Expect(members.Contains("10.0.0.3")).To(BeTrue())
+		Expect(members.Len()).To(Equal(2))
+	})
+
+	It("should rewrite sets and clean up stale ones after a resync", func() {
+		dataplane.listOutput = "table ip calico-ipsets {\n" +
+			"\tset \"" + setName + "\" {\n\t\ttype ipv4_addr\n\t}\n" +
+			"\tset \"cali40stale\" {\n\t\ttype ipv4_addr\n\t}\n" +
+			"\tset \"not-ours\" {\n\t\ttype ipv4_addr\n\t}\n" +
+			"}\n"
+		nftSets.AddOrReplaceIPSet(meta(IPSetTypeHashIP), []string{"10.0.0.1"})
+		nftSets.ApplyUpdates()
+		nftSets.ApplyDeletions()
+		Expect(dataplane.scripts).To(Equal([]string{
+			"add table ip calico-ipsets\n" +
+				"delete set ip calico-ipsets \"" + setName + "\"\n" +
+				"add set ip calico-ipsets \"" + setName + "\" { type ipv4_addr; }\n" +
+				"add element ip calico-ipsets \"" + setName + "\" { 10.0.0.1 }\n",
+			"delete set ip calico-ipsets \"cali40stale\"\n",
+		}))
+	})
+
+	It("should handle a missing table", func() {
+		dataplane.listOutput = "Error: No such file or directory"
+		dataplane.listErr = errors.New("exit status 1")
+		nftSets.AddOrReplaceIPSet(meta(IPSetTypeHashIP), nil)
+		nftSets.ApplyUpdates()
+		Expect(dataplane.scripts).To(HaveLen(1))
+	})
+
+	It("should delete removed sets", func() {
+		nftSets.AddOrReplaceIPSet(meta(IPSetTypeHashIP), []string{"10.0.0.1"})
+		nftSets.ApplyUpdates()
+		nftSets.RemoveIPSet(meta(IPSetTypeHashIP).SetID)
+		nftSets.ApplyUpdates()
+		nftSets.ApplyDeletions()
+		Expect(dataplane.scripts).To(HaveLen(2))
+		Expect(dataplane.scripts[1]).To(Equal("delete set ip calico-ipsets \"" + setName + "\"\n"))
+	})
+
+	It("should retry and resync after a failure", func() {
+		nftSets.AddOrReplaceIPSet(meta(IPSetTypeHashIP), []string{"10.0.0.1"})
+		dataplane.failNextScript = true
+		nftSets.ApplyUpdates()
+		Expect(dataplane.scripts).To(HaveLen(2))
+		Expect(dataplane.numLists).To(Equal(2))
+	})
+})
+
+var _ = Describe("KernelSupportsIPSets", func() {
+	It("should return true if ipset works", func() {
+		Expect(KernelSupportsIPSetsWithShim((&mockNFT{}).newCmd)).To(BeTrue())
+	})
+
+	It("should return false if ipset fails", func() {
+		Expect(KernelSupportsIPSetsWithShim((&mockNFT{ipsetErr: errors.New("exit status 1")}).newCmd)).To(BeFalse())
+	})
+})
+
+// mockNFT fakes the nft command (and "ipset list -n" for feature detection).
+type mockNFT struct {
+	listOutput     string
+	listErr        error
+	ipsetErr       error
+	failNextScript bool
+
+	scripts  []string
+	numLists int
+}
+
+func (d *mockNFT) newCmd(name string, arg ...string) CmdIface {
+	return &mockNFTCmd{dataplane: d, name: name, args: arg}
+}
+
+type mockNFTCmd struct {
+	dataplane *mockNFT
+	name      string
+	args      []string
+	stdin     io.Reader
+}
+
+func (c *mockNFTCmd) CombinedOutput() ([]byte, error) {
+	d := c.dataplane
+	if c.name == "ipset" {
+		return nil, d.ipsetErr
+	}
+	Expect(c.name).To(Equal("nft"))
+	switch strings.Join(c.args, " ") {
+	case "list table ip calico-ipsets":
+		d.numLists++
+		return []byte(d.listOutput), d.listErr
+	case "-f -":
+		script, err := ioutil.ReadAll(c.stdin)
+		Expect(err).NotTo(HaveOccurred())
+		d.scripts = append(d.scripts, string(script))
+		if d.failNextScript {
+			d.failNextScript = false
+			return []byte("Error: dummy"), errors.New("exit status 1")
+		}
+		return nil, nil
+	}
+	Fail("Unexpected nft command: " + strings.Join(c.args, " "))
+	return nil, nil
+}
+
+func (c *mockNFTCmd) SetStdin(r io.Reader)                   { c.stdin = r }
+func (c *mockNFTCmd) SetStdout(io.Writer)                    {}
+func (c *mockNFTCmd) SetStderr(io.Writer)                    {}
+func (c *mockNFTCmd) StdinPipe() (WriteCloserFlusher, error) { panic("not implemented") }
+func (c *mockNFTCmd) StdoutPipe() (io.ReadCloser, error)     { panic("not implemented") }
+func (c *mockNFTCmd) Start() error                           { panic("not implemented") }
+func (c *mockNFTCmd) Wait() error                            { panic("not implemented") }
+func (c *mockNFTCmd) Output() ([]byte, error)                { panic("not implemented") }
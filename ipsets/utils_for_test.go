@@ -69,6 +69,8 @@ type mockDataplane struct {
 	TriedToAddExistent       bool
 
 	AttemptedDestroys []string
+	// RestoreLines records every line that was passed to ipset restore.
+	RestoreLines []string
 
 	CumulativeSleep time.Duration
 }
@@ -108,11 +110,15 @@ func (d *mockDataplane) newCmd(name string, arg ...string) CmdIface {
 			SetName:   name,
 		}
 	case "list":
-		Expect(len(arg)).To(Equal(1))
-		cmd = &listCmd{
+		Expect(len(arg)).To(BeNumerically("<=", 2))
+		listCmd := &listCmd{
 			Dataplane: d,
 			resultC:   make(chan error),
 		}
+		if len(arg) == 2 {
+			listCmd.SetName = arg[1]
+		}
+		cmd = listCmd
 	default:
 		Fail(fmt.Sprintf("Unexpected command %v", arg))
 	}
@@ -258,6 +264,7 @@ func (c *restoreCmd) main() {
 	for scanner.Scan() {
 		line := scanner.Text()
 		i++
+		c.Dataplane.RestoreLines = append(c.Dataplane.RestoreLines, line)
 		if line == "" {
 			continue
 		}
@@ -491,6 +498,7 @@ type listCmd struct {
 	Dataplane *mockDataplane
 	SetName   string
 	Stdout    *io.PipeWriter
+	Stderr    io.Writer
 	resultC   chan error
 }
 
@@ -498,8 +506,8 @@ func (c *listCmd) SetStdin(_ io.Reader) {
 	Fail("listNamesCmd expects no input")
 }
 
-func (c *listCmd) SetStderr(r io.Writer) {
-
+func (c *listCmd) SetStderr(w io.Writer) {
+	c.Stderr = w
 }
 
 func (c *listCmd) SetStdout(r io.Writer) {
@@ -659,13 +667,29 @@ func (c *listCmd) main() {
 		return
 	}
 
+	if c.SetName != "" {
+		if _, ok := c.Dataplane.IPSetMembers[c.SetName]; !ok {
+			if c.Stderr != nil {
+				fmt.Fprintln(c.Stderr, "ipset v7.1: The set with the given name does not exist")
+			}
+			result = transientFailure
+			return
+		}
+	}
+
 	first := true
 	for setName, members := range c.Dataplane.IPSetMembers {
+		if c.SetName != "" && setName != c.SetName {
+			continue
+		}
 		if !first {
 			fmt.Fprint(c.Stdout, "\n")
 		}
 		fmt.Fprintf(c.Stdout, "Name: %s\n", setName)
 		fmt.Fprint(c.Stdout, "Field: foobar\n") // Dummy field, should get ignored.
+		if meta, ok := c.Dataplane.IPSetMetadata[setName]; ok {
+			fmt.Fprintf(c.Stdout, "Header: family %s hashsize 1024 maxelem %d\n", meta.Family, meta.MaxSize)
+		}
 		fmt.Fprint(c.Stdout, "Members:\n")
 		members.Iter(func(member interface{}) error {
 			fmt.Fprintf(c.Stdout, "%s\n", member)
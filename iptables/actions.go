@@ -111,6 +111,22 @@ func (g LogAction) String() string {
 	return "Log"
 }
 
+// NflogAction sends a copy of the packet to userspace via the given NFLOG group.  The number of
+// bytes copied is controlled by the listener.
+type NflogAction struct {
+	Group     uint16
+	Prefix    string
+	TypeNflog struct{}
+}
+
+func (n NflogAction) ToFragment(features *Features) string {
+	return fmt.Sprintf(`--jump NFLOG --nflog-group %d --nflog-prefix "%s"`, n.Group, n.Prefix)
+}
+
+func (n NflogAction) String() string {
+	return fmt.Sprintf("Nflog:g=%d,p=%s", n.Group, n.Prefix)
+}
+
 type AcceptAction struct {
 	TypeAccept struct{}
 }
@@ -30,6 +30,7 @@ var _ = DescribeTable("Actions",
 	Entry("ReturnAction", Features{}, ReturnAction{}, "--jump RETURN"),
 	Entry("DropAction", Features{}, DropAction{}, "--jump DROP"),
 	Entry("AcceptAction", Features{}, AcceptAction{}, "--jump ACCEPT"),
+	Entry("NflogAction", Features{}, NflogAction{Group: 3, Prefix: "DROP|abcd"}, `--jump NFLOG --nflog-group 3 --nflog-prefix "DROP|abcd"`),
 	Entry("LogAction", Features{}, LogAction{Prefix: "prefix"}, `--jump LOG --log-prefix "prefix: " --log-level 5`),
 	Entry("DNATAction", Features{}, DNATAction{DestAddr: "10.0.0.1", DestPort: 8081}, "--jump DNAT --to-destination 10.0.0.1:8081"),
 	Entry("SNATAction", Features{}, SNATAction{ToAddr: "10.0.0.1"}, "--jump SNAT --to-source 10.0.0.1"),
@@ -0,0 +1,30 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package iptables contains Felix's iptables programming engine; see Table for the details of
+// how it works.
+//
+// The package doesn't depend on the rest of Felix so it can be embedded in other components.
+// The supported entry points are:
+//
+//   - NewFeatureDetector, to detect the iptables version and backend (legacy or nft),
+//   - NewSharedLock, to take the xtables lock in the same way as the iptables commands,
+//   - NewTable and TableOptions, to manage one iptables table,
+//   - Chain, Rule, Match() and the Action types, to describe the desired state.
+//
+// An embedding application must choose its own rule hash prefix and chain name prefixes;
+// they tell the Table which rules and chains it owns and may therefore modify or remove.  If
+// they overlap with those used by Felix ("cali:" and "cali-"), the two will fight over each
+// other's rules.  Only one Table should manage a given table and set of prefixes on a host.
+package iptables
@@ -31,6 +31,8 @@ import (
 
 var (
 	vXDotYDotZRegexp = regexp.MustCompile(`v(\d+\.\d+\.\d+)`)
+	// nftBackendRegexp matches the backend suffix that iptables >= 1.8 adds to its version string.
+	nftBackendRegexp = regexp.MustCompile(`\(nf_tables\)`)
 
 	// iptables versions:
 	// v1Dot4Dot7 is the oldest version we've ever supported.
@@ -39,6 +41,8 @@ var (
 	v1Dot6Dot0 = versionparse.MustParseVersion("1.6.0")
 	// v1Dot6Dot2 added --random-fully to MASQUERADE and the xtables lock to iptables-restore.
 	v1Dot6Dot2 = versionparse.MustParseVersion("1.6.2")
+	// v1Dot8Dot5 is the first version where iptables-restore reliably honours --wait-interval.
+	v1Dot8Dot5 = versionparse.MustParseVersion("1.8.5")
 
 	// Linux kernel versions:
 	// v3Dot10Dot0 is the oldest version we support at time of writing.
@@ -57,6 +61,12 @@ type Features struct {
 	// RestoreSupportsLock is true if the iptables-restore command supports taking the xtables lock and the
 	// associated -w and -W arguments.
 	RestoreSupportsLock bool
+	// RestoreSupportsWaitInterval is true if iptables-restore honours --wait-interval, allowing us to poll
+	// for the xtables lock at a finer granularity than its default of once per second.
+	RestoreSupportsWaitInterval bool
+	// RestoreLockFree is true if iptables is using the nftables backend.  iptables-nft-restore updates
+	// the ruleset in a single kernel transaction so it doesn't need the xtables lock at all.
+	RestoreLockFree bool
 	// ChecksumOffloadBroken is true for kernels that have broken checksum offload for packets with SNATted source
 	// ports. See https://github.com/projectcalico/calico/issues/3145.  On such kernels we disable checksum offload
 	// on our VXLAN device.
@@ -105,15 +115,17 @@ func (d *FeatureDetector) refreshFeaturesLockHeld() {
 	// Get the versions.  If we fail to detect a version for some reason, we use a safe default.
 	log.Debug("Refreshing detected iptables features")
 
-	iptV := d.getIptablesVersion()
+	iptV, nftBackend := d.getIptablesVersion()
 	kerV := d.getKernelVersion()
 
 	// Calculate the features.
 	features := Features{
-		SNATFullyRandom:       iptV.Compare(v1Dot6Dot0) >= 0 && kerV.Compare(v3Dot14Dot0) >= 0,
-		MASQFullyRandom:       iptV.Compare(v1Dot6Dot2) >= 0 && kerV.Compare(v3Dot14Dot0) >= 0,
-		RestoreSupportsLock:   iptV.Compare(v1Dot6Dot2) >= 0,
-		ChecksumOffloadBroken: kerV.Compare(v5Dot7Dot0) < 0,
+		SNATFullyRandom:             iptV.Compare(v1Dot6Dot0) >= 0 && kerV.Compare(v3Dot14Dot0) >= 0,
+		MASQFullyRandom:             iptV.Compare(v1Dot6Dot2) >= 0 && kerV.Compare(v3Dot14Dot0) >= 0,
+		RestoreSupportsLock:         iptV.Compare(v1Dot6Dot2) >= 0,
+		RestoreSupportsWaitInterval: iptV.Compare(v1Dot8Dot5) >= 0,
+		RestoreLockFree:             nftBackend,
+		ChecksumOffloadBroken:       kerV.Compare(v5Dot7Dot0) < 0,
 	}
 
 	for k, v := range d.featureOverride {
@@ -150,17 +162,20 @@ func (d *FeatureDetector) refreshFeaturesLockHeld() {
 			"features":        features,
 			"kernelVersion":   kerV,
 			"iptablesVersion": iptV,
+			"nftBackend":      nftBackend,
 		}).Info("Updating detected iptables features")
 		d.featureCache = &features
 	}
 }
 
-func (d *FeatureDetector) getIptablesVersion() *versionparse.Version {
+// getIptablesVersion returns the version of iptables and whether it reports that it is using the
+// nftables backend.
+func (d *FeatureDetector) getIptablesVersion() (*versionparse.Version, bool) {
 	cmd := d.NewCmd("iptables", "--version")
 	out, err := cmd.Output()
 	if err != nil {
 		log.WithError(err).Warn("Failed to get iptables version, assuming old version with no optional features")
-		return v1Dot4Dot7
+		return v1Dot4Dot7, false
 	}
 	s := string(out)
 	log.WithField("rawVersion", s).Debug("Ran iptables --version")
@@ -168,16 +183,20 @@ func (d *FeatureDetector) getIptablesVersion() *versionparse.Version {
 	if len(matches) == 0 {
 		log.WithField("rawVersion", s).Warn(
 			"Failed to parse iptables version, assuming old version with no optional features")
-		return v1Dot4Dot7
+		return v1Dot4Dot7, false
 	}
 	parsedVersion, err := versionparse.NewVersion(matches[1])
 	if err != nil {
 		log.WithField("rawVersion", s).WithError(err).Warn(
 			"Failed to parse iptables version, assuming old version with no optional features")
-		return v1Dot4Dot7
+		return v1Dot4Dot7, false
 	}
-	log.WithField("version", parsedVersion).Debug("Parsed iptables version")
-	return parsedVersion
+	nftBackend := nftBackendRegexp.MatchString(s)
+	log.WithFields(log.Fields{
+		"version":    parsedVersion,
+		"nftBackend": nftBackend,
+	}).Debug("Parsed iptables version")
+	return parsedVersion, nftBackend
 }
 
 func (d *FeatureDetector) getKernelVersion() *versionparse.Version {
@@ -125,6 +125,50 @@ func TestFeatureDetection(t *testing.T) {
 				ChecksumOffloadBroken: false,
 			},
 		},
+		{
+			"iptables v1.8.4 (legacy)",
+			"Linux version 5.7.0",
+			Features{
+				RestoreSupportsLock:   true,
+				SNATFullyRandom:       true,
+				MASQFullyRandom:       true,
+				ChecksumOffloadBroken: false,
+			},
+		},
+		{
+			"iptables v1.8.5 (legacy)",
+			"Linux version 5.7.0",
+			Features{
+				RestoreSupportsLock:         true,
+				RestoreSupportsWaitInterval: true,
+				SNATFullyRandom:             true,
+				MASQFullyRandom:             true,
+				ChecksumOffloadBroken:       false,
+			},
+		},
+		{
+			"iptables v1.8.4 (nf_tables)",
+			"Linux version 5.7.0",
+			Features{
+				RestoreSupportsLock:   true,
+				RestoreLockFree:       true,
+				SNATFullyRandom:       true,
+				MASQFullyRandom:       true,
+				ChecksumOffloadBroken: false,
+			},
+		},
+		{
+			"iptables v1.8.7 (nf_tables)",
+			"Linux version 5.7.0",
+			Features{
+				RestoreSupportsLock:         true,
+				RestoreSupportsWaitInterval: true,
+				RestoreLockFree:             true,
+				SNATFullyRandom:             true,
+				MASQFullyRandom:             true,
+				ChecksumOffloadBroken:       false,
+			},
+		},
 	} {
 		tst := tst
 		t.Run("iptables version "+tst.iptablesVersion+" kernel "+tst.kernelVersion, func(t *testing.T) {
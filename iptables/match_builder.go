@@ -20,12 +20,17 @@ import (
 	"strings"
 
 	log "github.com/sirupsen/logrus"
-
-	"github.com/projectcalico/felix/proto"
 )
 
 type MatchCriteria []string
 
+// PortRange is an inclusive range of ports; a single port has First == Last.  It is defined here,
+// rather than using Felix's protobuf type, so that this package can be used outside Felix.
+type PortRange struct {
+	First uint16
+	Last  uint16
+}
+
 func Match() MatchCriteria {
 	return nil
 }
@@ -156,6 +161,13 @@ func (m MatchCriteria) NotConntrackState(stateNames string) MatchCriteria {
 	return append(m, fmt.Sprintf("-m conntrack ! --ctstate %s", stateNames))
 }
 
+// HashLimitAbove matches packets once the rate of matching packets exceeds ratePerSec, after an
+// initial burst.  All packets that reach the rule share the single bucket called name.
+func (m MatchCriteria) HashLimitAbove(name string, ratePerSec, burst int) MatchCriteria {
+	return append(m, fmt.Sprintf("-m hashlimit --hashlimit-name %s --hashlimit-above %d/sec --hashlimit-burst %d",
+		name, ratePerSec, burst))
+}
+
 func (m MatchCriteria) Protocol(name string) MatchCriteria {
 	return append(m, fmt.Sprintf("-p %s", name))
 }
@@ -180,6 +192,10 @@ func (m MatchCriteria) NotSourceNet(net string) MatchCriteria {
 	return append(m, fmt.Sprintf("! --source %s", net))
 }
 
+func (m MatchCriteria) NotSourceMAC(mac string) MatchCriteria {
+	return append(m, fmt.Sprintf("-m mac ! --mac-source %s", mac))
+}
+
 func (m MatchCriteria) DestNet(net string) MatchCriteria {
 	return append(m, fmt.Sprintf("--destination %s", net))
 }
@@ -240,22 +256,22 @@ func (m MatchCriteria) NotDestPorts(ports ...uint16) MatchCriteria {
 	return append(m, fmt.Sprintf("-m multiport ! --destination-ports %s", portsString))
 }
 
-func (m MatchCriteria) SourcePortRanges(ports []*proto.PortRange) MatchCriteria {
+func (m MatchCriteria) SourcePortRanges(ports []PortRange) MatchCriteria {
 	portsString := PortRangessToMultiport(ports)
 	return append(m, fmt.Sprintf("-m multiport --source-ports %s", portsString))
 }
 
-func (m MatchCriteria) NotSourcePortRanges(ports []*proto.PortRange) MatchCriteria {
+func (m MatchCriteria) NotSourcePortRanges(ports []PortRange) MatchCriteria {
 	portsString := PortRangessToMultiport(ports)
 	return append(m, fmt.Sprintf("-m multiport ! --source-ports %s", portsString))
 }
 
-func (m MatchCriteria) DestPortRanges(ports []*proto.PortRange) MatchCriteria {
+func (m MatchCriteria) DestPortRanges(ports []PortRange) MatchCriteria {
 	portsString := PortRangessToMultiport(ports)
 	return append(m, fmt.Sprintf("-m multiport --destination-ports %s", portsString))
 }
 
-func (m MatchCriteria) NotDestPortRanges(ports []*proto.PortRange) MatchCriteria {
+func (m MatchCriteria) NotDestPortRanges(ports []PortRange) MatchCriteria {
 	portsString := PortRangessToMultiport(ports)
 	return append(m, fmt.Sprintf("-m multiport ! --destination-ports %s", portsString))
 }
@@ -315,7 +331,7 @@ func PortsToMultiport(ports []uint16) string {
 	return portsString
 }
 
-func PortRangessToMultiport(ports []*proto.PortRange) string {
+func PortRangessToMultiport(ports []PortRange) string {
 	portFragments := make([]string, len(ports))
 	for i, port := range ports {
 		if port.First == port.Last {
@@ -20,11 +20,9 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
-
-	"github.com/projectcalico/felix/proto"
 )
 
-var portRanges = []*proto.PortRange{
+var portRanges = []PortRange{
 	{First: 1234, Last: 1234},
 	{First: 5678, Last: 6000},
 }
@@ -59,6 +57,8 @@ var _ = DescribeTable("MatchBuilder",
 	Entry("NotMarkMatchesWithMask", Match().NotMarkMatchesWithMask(0x400a, 0xf00f), "-m mark ! --mark 0x400a/0xf00f"),
 	// Conntrack.
 	Entry("ConntrackState", Match().ConntrackState("INVALID"), "-m conntrack --ctstate INVALID"),
+	Entry("HashLimitAbove", Match().HashLimitAbove("cali1234", 10, 20),
+		"-m hashlimit --hashlimit-name cali1234 --hashlimit-above 10/sec --hashlimit-burst 20"),
 	// Interfaces.
 	Entry("InInterface", Match().InInterface("tap1234abcd"), "--in-interface tap1234abcd"),
 	Entry("OutInterface", Match().OutInterface("tap1234abcd"), "--out-interface tap1234abcd"),
@@ -76,6 +76,7 @@ var _ = DescribeTable("MatchBuilder",
 	// CIDRs.
 	Entry("SourceNet", Match().SourceNet("10.0.0.4"), "--source 10.0.0.4"),
 	Entry("NotSourceNet", Match().NotSourceNet("10.0.0.4"), "! --source 10.0.0.4"),
+	Entry("NotSourceMAC", Match().NotSourceMAC("ee:ee:ee:ee:ee:ee"), "-m mac ! --mac-source ee:ee:ee:ee:ee:ee"),
 	Entry("DestNet", Match().DestNet("10.0.0.4"), "--destination 10.0.0.4"),
 	Entry("NotDestNet", Match().NotDestNet("10.0.0.4"), "! --destination 10.0.0.4"),
 	// IP sets.
@@ -0,0 +1,252 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/libcalico-go/lib/set"
+)
+
+// failuresBeforeTriage is the number of consecutive iptables-restore failures after which Apply()
+// looks for rejected rules.  The first few failures are more likely to be due to a concurrent
+// update, which a retry will fix.
+const failuresBeforeTriage = 3
+
+// QuarantinedChain describes a chain that the Table has stopped updating because iptables-restore
+// rejected one of its rules.
+type QuarantinedChain struct {
+	Table     string
+	IPVersion uint8
+	Chain     string
+	// RuleNum is the (1-indexed) position of the rejected rule in the chain.
+	RuleNum int
+	// Rule is the rejected rule, as rendered for iptables-restore.
+	Rule string
+	// Comment is the rule's comment, which usually names the policy or endpoint that the rule
+	// came from.
+	Comment []string
+	// ErrorOutput is what iptables-restore said when it rejected the rule.
+	ErrorOutput string
+
+	// ruleHashes are the hashes of the rules of the rejected version of the chain.
+	ruleHashes []string
+}
+
+// QuarantinedChains returns the chains that are currently quarantined, sorted by name.
+func (t *Table) QuarantinedChains() []QuarantinedChain {
+	chains := make([]QuarantinedChain, 0, len(t.quarantinedChains))
+	for _, q := range t.quarantinedChains {
+		chains = append(chains, *q)
+	}
+	sort.Slice(chains, func(i, j int) bool {
+		return chains[i].Chain < chains[j].Chain
+	})
+	return chains
+}
+
+// quarantineRejectedChains tries to find the rules that iptables-restore is rejecting.  It tests
+// each dirty chain using iptables-restore's --test mode, which checks the input against the
+// kernel without committing it.  For each chain that fails, it bisects the chain's rules to find
+// the first one that iptables-restore rejects, logs it and quarantines the chain.  Subsequent
+// updates skip quarantined chains so that the rest of the table can still be programmed.  A
+// chain stays quarantined until its rules change or it is removed.
+//
+// Returns the number of chains that were newly quarantined.
+func (t *Table) quarantineRejectedChains(features *Features) int {
+	var candidates []string
+	t.dirtyChains.Iter(func(item interface{}) error {
+		chainName := item.(string)
+		if _, ok := t.quarantinedChains[chainName]; ok {
+			return nil
+		}
+		if _, ok := t.desiredStateOfChain(chainName); ok {
+			candidates = append(candidates, chainName)
+		}
+		return nil
+	})
+	if len(candidates) == 0 {
+		t.logCxt.Info("iptables-restore is failing but there are no chain updates to check.")
+		return 0
+	}
+	candidates = t.orderChainsByReferences(candidates)
+	t.logCxt.WithField("numChains", len(candidates)).Warn(
+		"iptables-restore keeps failing, checking updated chains for rejected rules.")
+
+	// Each test creates/flushes all the candidate chains so that rules can jump to chains that
+	// don't exist in the dataplane yet.  If that alone fails then the problem isn't with any
+	// particular rule.
+	if errOutput, err := t.testChainPrefix(candidates, nil, 0, features); err != nil {
+		t.logCxt.WithError(err).WithField("errorOutput", errOutput).Warn(
+			"iptables-restore rejected chain creation, unable to isolate a rejected rule.")
+		return 0
+	}
+
+	numQuarantined := 0
+	for _, chainName := range candidates {
+		t.onStillAlive()
+		chain, _ := t.desiredStateOfChain(chainName)
+		errOutput, err := t.testChainPrefix(candidates, chain, len(chain.Rules), features)
+		if err == nil {
+			continue
+		}
+
+		// Find the shortest prefix of the chain that is rejected; its last rule is the culprit.
+		good, bad := 0, len(chain.Rules)
+		for bad-good > 1 {
+			mid := (good + bad) / 2
+			if out, err := t.testChainPrefix(candidates, chain, mid, features); err != nil {
+				bad = mid
+				errOutput = out
+			} else {
+				good = mid
+			}
+		}
+
+		rule := chain.Rules[bad-1]
+		q := &QuarantinedChain{
+			Table:       t.Name,
+			IPVersion:   t.IPVersion,
+			Chain:       chainName,
+			RuleNum:     bad,
+			Rule:        rule.RenderAppend(chainName, "", features),
+			Comment:     rule.Comment,
+			ErrorOutput: strings.TrimSpace(errOutput),
+			ruleHashes:  chain.RuleHashes(features),
+		}
+		t.logCxt.WithFields(log.Fields{
+			"chainName":   q.Chain,
+			"ruleNum":     q.RuleNum,
+			"rule":        q.Rule,
+			"comment":     strings.Join(q.Comment, "; "),
+			"errorOutput": q.ErrorOutput,
+		}).Error("iptables-restore rejected rule, quarantining its chain.  The rest of the table " +
+			"will be programmed but the chain won't be updated until its rules change.")
+		t.quarantinedChains[chainName] = q
+		numQuarantined++
+	}
+	t.gaugeNumQuarantinedChains.Set(float64(len(t.quarantinedChains)))
+	return numQuarantined
+}
+
+// testChainPrefix uses iptables-restore --test to check whether the kernel would accept the
+// first numRules rules of the given chain (which may be nil), after creating/flushing the
+// forward-referenced chains.  Returns iptables-restore's error output along with the error.
+func (t *Table) testChainPrefix(forwardRefs []string, chain *Chain, numRules int, features *Features) (string, error) {
+	// Use our own buffer; the table's buffer counts the lines that we actually execute.
+	var buf RestoreInputBuilder
+	buf.StartTransaction(t.Name)
+	for _, chainName := range forwardRefs {
+		buf.WriteForwardReference(chainName)
+	}
+	if chain != nil {
+		hashes := chain.RuleHashes(features)
+		for i := 0; i < numRules; i++ {
+			buf.WriteLine(chain.Rules[i].RenderAppend(chain.Name, t.commentFrag(hashes[i]), features))
+		}
+	}
+	buf.EndTransaction()
+
+	var outputBuf, errBuf bytes.Buffer
+	err := t.runRestore(buf.GetBytesAndReset(), features, &outputBuf, &errBuf, "--test")
+	return errBuf.String(), err
+}
+
+// skipQuarantinedChains removes quarantined chains that are already in the dataplane from the
+// dirty set, leaving the last version that we programmed in place.  Quarantined chains that
+// don't exist yet stay dirty; programmableChain() makes sure that they're created with a single
+// DROP rule so that references to them still resolve.
+func (t *Table) skipQuarantinedChains() {
+	if len(t.quarantinedChains) == 0 {
+		return
+	}
+	t.dirtyChains.Iter(func(item interface{}) error {
+		chainName := item.(string)
+		if _, ok := t.quarantinedChains[chainName]; !ok {
+			return nil
+		}
+		if _, ok := t.chainToDataplaneHashes[chainName]; ok {
+			t.logCxt.WithField("chainName", chainName).Debug("Skipping update of quarantined chain.")
+			return set.RemoveItem
+		}
+		return nil
+	})
+}
+
+// programmableChain returns the version of the chain that we should write to the dataplane;
+// for a quarantined chain, that's a chain that drops all traffic.  We fail closed because the
+// chain may be a policy or endpoint chain, which must not let through traffic that the rejected
+// rule would have dropped.
+func (t *Table) programmableChain(chain *Chain) *Chain {
+	if q, ok := t.quarantinedChains[chain.Name]; ok {
+		return q.DropChain()
+	}
+	return chain
+}
+
+// DropChain returns the chain to program in place of a quarantined chain that isn't in the
+// dataplane yet: a single DROP rule, with a comment that names the quarantine.
+func (q *QuarantinedChain) DropChain() *Chain {
+	return &Chain{
+		Name: q.Chain,
+		Rules: []Rule{{
+			Action:  DropAction{},
+			Comment: []string{fmt.Sprintf("Quarantined: rule %d of this chain was rejected", q.RuleNum)},
+		}},
+	}
+}
+
+// liftQuarantineIfChanged releases the chain from quarantine if it was quarantined and its rules
+// differ from the version that was rejected, since the change may have fixed the problem.
+// Updates that resend the rejected rules leave the chain quarantined.
+func (t *Table) liftQuarantineIfChanged(chain *Chain) {
+	q, ok := t.quarantinedChains[chain.Name]
+	if !ok {
+		return
+	}
+	if hashesEqual(q.ruleHashes, chain.RuleHashes(t.featureDetector.GetFeatures())) {
+		t.logCxt.WithField("chainName", chain.Name).Debug("Quarantined chain updated with the same rules.")
+		return
+	}
+	t.liftQuarantine(chain.Name)
+}
+
+// liftQuarantine releases the chain from quarantine, if it was quarantined.  Called when the
+// chain's rules change or it is removed, since that may have fixed the problem.
+func (t *Table) liftQuarantine(chainName string) {
+	if _, ok := t.quarantinedChains[chainName]; !ok {
+		return
+	}
+	t.logCxt.WithField("chainName", chainName).Info("Quarantined chain updated, will try to program it again.")
+	delete(t.quarantinedChains, chainName)
+	t.gaugeNumQuarantinedChains.Set(float64(len(t.quarantinedChains)))
+}
+
+func hashesEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
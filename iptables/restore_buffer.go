@@ -42,6 +42,12 @@ type RestoreInputBuilder struct {
 	currentTableName string
 	txnOpenerWritten bool
 	NumLinesWritten  counter
+
+	// numChainsUpdated and numRulesWritten count the chains and rules that the buffered input
+	// changes, as reported by the writer, so that they can be reported once the input has been
+	// applied.
+	numChainsUpdated int
+	numRulesWritten  int
 }
 
 // Empty returns true if there is nothing in the buffer (i.e. all the transactions stored in the buffer were no-ops).
@@ -54,6 +60,23 @@ func (b *RestoreInputBuilder) Reset() {
 	b.buf.Reset()
 	b.currentTableName = ""
 	b.txnOpenerWritten = false
+	b.numChainsUpdated = 0
+	b.numRulesWritten = 0
+}
+
+// CountChainUpdate records that the buffered input creates, changes or deletes a chain.
+func (b *RestoreInputBuilder) CountChainUpdate() {
+	b.numChainsUpdated++
+}
+
+// CountRulesWritten records that the buffered input writes n rules.
+func (b *RestoreInputBuilder) CountRulesWritten(n int) {
+	b.numRulesWritten += n
+}
+
+// Counts returns the number of chains and rules that have been recorded since the last reset.
+func (b *RestoreInputBuilder) Counts() (numChainsUpdated, numRulesWritten int) {
+	return b.numChainsUpdated, b.numRulesWritten
 }
 
 // StartTransaction opens a new transaction context for the named table.
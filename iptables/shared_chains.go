@@ -0,0 +1,151 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package iptables
+
+import (
+	"fmt"
+	"sort"
+)
+
+// This file contains the bookkeeping for chains that several parts of Felix contribute rules to:
+// Felix-owned chains that are shared via chain fragments and kernel chains that we insert rules
+// into via insert groups.  It is shared by this package's Table and by the nftables Table.
+
+// ChainFragment is the set of rules that one owner contributes to a shared chain.
+type ChainFragment struct {
+	Owner    string
+	Priority int
+	Rules    []Rule
+}
+
+// ChainFragments are the fragments of one shared chain, indexed by owner.
+type ChainFragments map[string]ChainFragment
+
+// Merge returns the chain that the fragments make up: the concatenation of the fragments' rules,
+// in order of increasing priority, then owner, so the result doesn't depend on the order of the
+// updates.
+func (f ChainFragments) Merge(chainName string) *Chain {
+	fragments := make([]ChainFragment, 0, len(f))
+	for _, frag := range f {
+		fragments = append(fragments, frag)
+	}
+	sort.Slice(fragments, func(i, j int) bool {
+		if fragments[i].Priority != fragments[j].Priority {
+			return fragments[i].Priority < fragments[j].Priority
+		}
+		return fragments[i].Owner < fragments[j].Owner
+	})
+	chain := &Chain{Name: chainName}
+	for _, frag := range fragments {
+		chain.Rules = append(chain.Rules, frag.Rules...)
+	}
+	return chain
+}
+
+// InsertPosition says where an insert group's rules go, relative to the rules that other tools
+// have added to the same chain.
+type InsertPosition int
+
+const (
+	// InsertAtDefaultPosition puts the rules with those added by InsertOrAppendRules: at the top
+	// of the chain or, if the table is in append mode, after other tools' rules.
+	InsertAtDefaultPosition InsertPosition = iota
+	// InsertAtEnd puts the rules with those added by AppendRules: after other tools' rules
+	// (for example, after kube-proxy's jumps), whatever the insert mode.
+	InsertAtEnd
+)
+
+func (p InsertPosition) String() string {
+	switch p {
+	case InsertAtDefaultPosition:
+		return "default"
+	case InsertAtEnd:
+		return "end"
+	default:
+		return fmt.Sprintf("InsertPosition(%d)", int(p))
+	}
+}
+
+// The InsertOrAppendRules and AppendRules rules are held in groups with these reserved names.
+// defaultAppendGroup is also the seed of the appended rules' hashes so it must not change.
+const (
+	defaultInsertGroup = "*inserts*"
+	defaultAppendGroup = "*appends*"
+)
+
+// InsertGroup is a named set of rules that we insert into (or append to) a chain that we don't
+// own.
+type InsertGroup struct {
+	Name     string
+	Position InsertPosition
+	Priority int
+	Rules    []Rule
+}
+
+// InsertGroups are the insert groups of one chain, indexed by name.
+type InsertGroups map[string]InsertGroup
+
+// CheckInsertGroupName returns an error if the given name can't be used for an insert group.
+func CheckInsertGroupName(name string) error {
+	switch name {
+	case "":
+		return fmt.Errorf("insert group name must not be empty")
+	case defaultInsertGroup, defaultAppendGroup:
+		return fmt.Errorf("insert group name %q is reserved", name)
+	}
+	return nil
+}
+
+// SetInserts sets the rules of the group used by InsertOrAppendRules.
+func (g InsertGroups) SetInserts(rules []Rule) {
+	g[defaultInsertGroup] = InsertGroup{Name: defaultInsertGroup, Position: InsertAtDefaultPosition, Rules: rules}
+}
+
+// SetAppends sets the rules of the group used by AppendRules.
+func (g InsertGroups) SetAppends(rules []Rule) {
+	g[defaultAppendGroup] = InsertGroup{Name: defaultAppendGroup, Position: InsertAtEnd, Rules: rules}
+}
+
+// Merge returns the rules to insert (in the default position) and to append.  Within each
+// position, groups are ordered by priority (lowest first) and then by name; the rules set by
+// SetInserts and SetAppends come before those of any other group with priority 0.
+func (g InsertGroups) Merge() (inserts, appends []Rule) {
+	groups := make([]InsertGroup, 0, len(g))
+	for _, group := range g {
+		groups = append(groups, group)
+	}
+	isDefault := func(group InsertGroup) bool {
+		return group.Name == defaultInsertGroup || group.Name == defaultAppendGroup
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Priority != groups[j].Priority {
+			return groups[i].Priority < groups[j].Priority
+		}
+		if isDefault(groups[i]) != isDefault(groups[j]) {
+			return isDefault(groups[i])
+		}
+		return groups[i].Name < groups[j].Name
+	})
+	inserts = []Rule{}
+	appends = []Rule{}
+	for _, group := range groups {
+		if group.Position == InsertAtEnd {
+			appends = append(appends, group.Rules...)
+		} else {
+			inserts = append(inserts, group.Rules...)
+		}
+	}
+	return
+}
@@ -23,6 +23,8 @@ import (
 	"os/exec"
 	"reflect"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -31,8 +33,6 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/projectcalico/libcalico-go/lib/set"
-
-	"github.com/projectcalico/felix/logutils"
 )
 
 const (
@@ -54,6 +54,12 @@ var (
 	chainCreateRegexp = regexp.MustCompile(`^:(\S+)`)
 	// appendRegexp matches an iptables-save output line for an append operation.
 	appendRegexp = regexp.MustCompile(`^-A (\S+)`)
+	// counterAppendRegexp matches an iptables-save -c output line for an append operation.  It
+	// captures the rule's packet and byte counts and the name of the chain.
+	counterAppendRegexp = regexp.MustCompile(`^\[(\d+):(\d+)\] -A (\S+)`)
+	// commentRegexp matches a rule comment, capturing its text in one of two groups, depending on
+	// whether it was quoted.
+	commentRegexp = regexp.MustCompile(`--comment (?:"([^"]*)"|(\S+))`)
 	// nftErrorRegexp matches a particular error emitted if iptables-nft is run on a system that
 	// uses nft features that iptables-nft doesn't understand.
 	nftErrorRegexp = regexp.MustCompile(`^# Table .* is incompatible, use 'nft' tool.`)
@@ -87,6 +93,20 @@ var (
 		Name: "felix_iptables_lines_executed",
 		Help: "Number of iptables rule updates executed.",
 	}, []string{"ip_version", "table"})
+	gaugeNumQuarantinedChains = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "felix_iptables_quarantined_chains",
+		Help: "Number of iptables chains that aren't being programmed because iptables-restore rejected one of their rules.",
+	}, []string{"ip_version", "table"})
+	countNumRestoreDeadlinesExceeded = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "felix_iptables_restore_deadlines_exceeded",
+		Help: "Number of iptables-restore calls that were aborted because they ran past the restore deadline.",
+	}, []string{"ip_version", "table"})
+	countNumChunkedApplies = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "felix_iptables_chunked_applies",
+		Help: "Number of updates that had to be split into smaller transactions after exceeding the restore deadline.",
+	}, []string{"ip_version", "table"})
+
+	errRestoreDeadlineExceeded = errors.New("iptables-restore exceeded its deadline")
 )
 
 func init() {
@@ -97,6 +117,9 @@ func init() {
 	prometheus.MustRegister(gaugeNumChains)
 	prometheus.MustRegister(gaugeNumRules)
 	prometheus.MustRegister(countNumLinesExecuted)
+	prometheus.MustRegister(gaugeNumQuarantinedChains)
+	prometheus.MustRegister(countNumRestoreDeadlinesExceeded)
+	prometheus.MustRegister(countNumChunkedApplies)
 }
 
 // Table represents a single one of the iptables tables i.e. "raw", "nat", "filter", etc.  It
@@ -114,12 +137,21 @@ func init() {
 // applications.  In addition, rule insertions are harder to clean up after an upgrade to a new
 // version of Felix (because we need a way to recognise our rules in a crowded chain).
 //
+// Insertions can be split into named, prioritised "insert groups" via UpdateInsertGroup.  Each
+// group is placed either in the default position (per the insert mode) or at the end of the
+// chain, after other applications' rules, so that Felix can order its hooks relative to those
+// of tools such as kube-proxy or a service mesh.
+//
 // Full chain updates replace the entire contents of a Felix-owned chain with a new set of rules.
 // Limiting the operation to "replace whole chain" in this way significantly simplifies the API.
 // Although the API operates on full chains, the dataplane write logic tries to avoid rewriting
 // a whole chain if only part of it has changed (this was not the case in Felix 1.4).  This
 // prevents iptables counters from being reset unnecessarily.
 //
+// As a variant of full chain updates, a Felix-owned chain can be shared by several owners, each
+// of which contributes a prioritised "fragment" of rules via UpdateChainFragment.  The Table
+// merges the fragments into the full chain, so owners don't need to coordinate with each other.
+//
 // In either case, the actual dataplane updates are deferred until the next call to Apply() so
 // chain updates and insertions may occur in any order as long as they are consistent (i.e. there
 // are no references to non-existent chains) by the time Apply() is called.
@@ -199,6 +231,10 @@ type Table struct {
 	// of that chain.
 	chainToAppendedRules map[string][]Rule
 	dirtyInsertAppend    set.Set
+	// chainToInsertGroups contains the insert groups of each chain, indexed by chain name and
+	// then group name.  chainToInsertedRules and chainToAppendedRules are calculated by
+	// merging the groups.
+	chainToInsertGroups map[string]InsertGroups
 
 	// chainToRuleFragments contains the desired state of our iptables chains, indexed by
 	// chain name.  The values are slices of iptables fragments, such as
@@ -212,7 +248,18 @@ type Table struct {
 	chainRefCounts map[string]int
 	dirtyChains    set.Set
 
+	// chainNameToFragments contains the rule fragments that owners have contributed to shared
+	// chains, indexed by chain name and then owner.  The desired state of a shared chain (in
+	// chainNameToChain) is calculated by merging its fragments.
+	chainNameToFragments map[string]ChainFragments
+
+	// quarantinedChains contains the chains that we've stopped updating because
+	// iptables-restore rejected one of their rules.  See quarantineRejectedChains().
+	quarantinedChains map[string]*QuarantinedChain
+
 	inSyncWithDataPlane bool
+	// lastRestoreFailed is set if our most recent iptables-restore failed.  See onRestoreFailure().
+	lastRestoreFailed bool
 
 	// chainToDataplaneHashes contains the rule hashes that we think are in the dataplane.
 	// it is updated when we write to the dataplane but it can also be read back and compared
@@ -259,11 +306,32 @@ type Table struct {
 	// implementation.
 	lockProbeInterval time.Duration
 
+	// maxChainsPerRestore, if non-zero, limits the number of chain updates that we send to
+	// iptables-restore in one transaction.  See applyChainUpdatesInBatches().
+	maxChainsPerRestore int
+	// restoreDeadline, if non-zero, is how long we let an iptables-restore call run before we
+	// kill it.  After a deadline is exceeded, we retry with chunkedChainsPerRestore, which
+	// shrinks each time, in place of maxChainsPerRestore until the update succeeds.
+	restoreDeadline         time.Duration
+	chunkedChainsPerRestore int
+
+	// onRuleCounters, if non-nil, is passed the counts of all our commented rules after an
+	// Apply(), at most once per ruleCountersInterval.
+	onRuleCounters           func(counts map[string]map[string]RuleCounts)
+	ruleCountersInterval     time.Duration
+	nextRuleCountersReadTime time.Time
+
+	// dryRun, if non-nil, is passed our iptables-restore input in place of running it.
+	dryRun func(restoreInput []byte)
+
 	logCxt *log.Entry
 
-	gaugeNumChains        prometheus.Gauge
-	gaugeNumRules         prometheus.Gauge
-	countNumLinesExecuted prometheus.Counter
+	gaugeNumChains                   prometheus.Gauge
+	gaugeNumRules                    prometheus.Gauge
+	gaugeNumQuarantinedChains        prometheus.Gauge
+	countNumLinesExecuted            prometheus.Counter
+	countNumRestoreDeadlinesExceeded prometheus.Counter
+	countNumChunkedApplies           prometheus.Counter
 
 	// Reusable buffer for writing to iptables.
 	restoreInputBuffer RestoreInputBuilder
@@ -277,10 +345,37 @@ type Table struct {
 	lookPath func(file string) (string, error)
 
 	onStillAlive func()
-	opReporter   logutils.OpRecorder
+	opReporter   OpRecorder
+}
+
+// OpRecorder is told about significant operations, such as resyncs, that a Table performs.
+type OpRecorder interface {
+	RecordOperation(name string)
+}
+
+// CountRecorder is implemented by OpRecorders that also want to know the number of chains and
+// rules that each update writes.
+type CountRecorder interface {
+	RecordCount(name string, n int)
 }
 
+// Names of the counts that a Table reports to a CountRecorder.
+const (
+	CountChainsUpdated = "chainsUpdated"
+	CountRulesWritten  = "rulesWritten"
+)
+
+type noOpRecorder struct{}
+
+func (noOpRecorder) RecordOperation(string) {}
+
+// TableOptions holds the optional parameters to NewTable, apart from HistoricChainPrefixes,
+// which is required.
 type TableOptions struct {
+	// HistoricChainPrefixes lists the prefixes of the chains that the Table owns, including
+	// any prefixes that were used by previous versions of the application.  Chains with these
+	// prefixes, and rules that jump to them, are cleaned up if they're not in the desired
+	// state.  At least one prefix must be supplied.
 	HistoricChainPrefixes    []string
 	ExtraCleanupRegexPattern string
 	BackendMode              string
@@ -293,7 +388,30 @@ type TableOptions struct {
 	// LockProbeInterval is the probe interval to use for iptables-restore's native xtables lock.
 	LockProbeInterval time.Duration
 
-	// NewCmdOverride for tests, if non-nil, factory to use instead of the real exec.Command()
+	// MaxChainsPerRestore, if non-zero, limits the number of chains that are updated in each
+	// iptables-restore transaction.  A large update, such as the first one after a restart
+	// on a node with many endpoints, is split into several transactions so that the chains
+	// that were programmed before a failure don't need to be reprogrammed.
+	MaxChainsPerRestore int
+
+	// RestoreDeadline, if non-zero, is how long an iptables-restore call may run before it is
+	// killed.  The update is then retried in smaller transactions (see MaxChainsPerRestore) so
+	// that a pathological update can't stall the caller indefinitely.
+	RestoreDeadline time.Duration
+
+	// OnRuleCounters, if non-nil, is called after a successful Apply() with the counts of the
+	// table's rules, as read by ReadAllRuleCounters().  To limit the cost of reading the whole
+	// table, the counts are read at most once per RuleCountersInterval.
+	OnRuleCounters       func(counts map[string]map[string]RuleCounts)
+	RuleCountersInterval time.Duration
+
+	// DryRun, if non-nil, is called with the input that we would have passed to
+	// iptables-restore, in place of running it.  The table is still read from the kernel, so
+	// the input is the update needed to bring the kernel into line with the desired state.
+	DryRun func(restoreInput []byte)
+
+	// NewCmdOverride, if non-nil, factory to use instead of the real exec.Command().  Used by
+	// tests, and to run the commands with the dataplane thread's scheduling settings.
 	NewCmdOverride cmdFactory
 	// SleepOverride for tests, if non-nil, replacement for time.Sleep()
 	SleepOverride func(d time.Duration)
@@ -303,10 +421,19 @@ type TableOptions struct {
 	LookPathOverride func(file string) (string, error)
 	// Thunk to call periodically when doing a long-running operation.
 	OnStillAlive func()
-	// OpRecorder to tell when we do resyncs etc.
-	OpRecorder logutils.OpRecorder
+	// OpRecorder to tell when we do resyncs etc.  Optional.
+	OpRecorder OpRecorder
 }
 
+// NewTable creates a Table that manages the given iptables table (such as "filter").
+//
+// hashPrefix is prepended to the rule-tracking comment on each rule that the Table writes;
+// together with options.HistoricChainPrefixes it defines which rules and chains the Table owns
+// so it must be unique to the application.  iptablesWriteLock is held while writing to the
+// dataplane; use NewSharedLock() to cooperate with other users of the xtables lock, or a dummy
+// lock if iptables-restore's own locking is sufficient.  The detector is used to choose between
+// iptables-legacy and iptables-nft and to adapt to the available features; it can be shared by
+// all the Tables in an application.
 func NewTable(
 	name string,
 	ipVersion uint8,
@@ -315,14 +442,33 @@ func NewTable(
 	detector *FeatureDetector,
 	options TableOptions,
 ) *Table {
+	// An empty prefix would match everything, resulting in the Table removing other
+	// applications' rules and chains.
+	if hashPrefix == "" {
+		log.Panic("NewTable called with empty hash prefix")
+	}
+	if len(options.HistoricChainPrefixes) == 0 {
+		log.Panic("NewTable called without any chain prefixes")
+	}
+	for _, prefix := range options.HistoricChainPrefixes {
+		if prefix == "" {
+			log.Panic("NewTable called with empty chain prefix")
+		}
+	}
+
 	// Calculate the regex used to match the hash comment.  The comment looks like this:
 	// --comment "cali:abcd1234_-".
-	hashCommentRegexp := regexp.MustCompile(`--comment "?` + hashPrefix + `([a-zA-Z0-9_-]+)"?`)
-	ourChainsPattern := "^(" + strings.Join(options.HistoricChainPrefixes, "|") + ")"
+	// The prefixes are literal strings, quote them in case they contain regex metacharacters.
+	hashCommentRegexp := regexp.MustCompile(`--comment "?` + regexp.QuoteMeta(hashPrefix) + `([a-zA-Z0-9_-]+)"?`)
+	quotedChainPrefixes := make([]string, len(options.HistoricChainPrefixes))
+	for i, prefix := range options.HistoricChainPrefixes {
+		quotedChainPrefixes[i] = regexp.QuoteMeta(prefix)
+	}
+	ourChainsPattern := "^(" + strings.Join(quotedChainPrefixes, "|") + ")"
 	ourChainsRegexp := regexp.MustCompile(ourChainsPattern)
 
 	oldInsertRegexpParts := []string{}
-	for _, prefix := range options.HistoricChainPrefixes {
+	for _, prefix := range quotedChainPrefixes {
 		part := fmt.Sprintf("(?:-j|--jump) %s", prefix)
 		oldInsertRegexpParts = append(oldInsertRegexpParts, part)
 	}
@@ -391,9 +537,12 @@ func NewTable(
 		chainToInsertedRules:   inserts,
 		chainToAppendedRules:   appends,
 		dirtyInsertAppend:      dirtyInsertAppend,
+		chainToInsertGroups:    map[string]InsertGroups{},
 		chainNameToChain:       map[string]*Chain{},
 		chainRefCounts:         refcounts,
 		dirtyChains:            set.New(),
+		chainNameToFragments:   map[string]ChainFragments{},
+		quarantinedChains:      map[string]*QuarantinedChain{},
 		chainToDataplaneHashes: map[string][]string{},
 		chainToFullRules:       map[string][]string{},
 		logCxt: log.WithFields(log.Fields{
@@ -421,15 +570,30 @@ func NewTable(
 		lockTimeout:       options.LockTimeout,
 		lockProbeInterval: options.LockProbeInterval,
 
+		maxChainsPerRestore: options.MaxChainsPerRestore,
+		restoreDeadline:     options.RestoreDeadline,
+
+		onRuleCounters:       options.OnRuleCounters,
+		ruleCountersInterval: options.RuleCountersInterval,
+
+		dryRun: options.DryRun,
+
 		newCmd:    newCmd,
 		timeSleep: sleep,
 		timeNow:   now,
 		lookPath:  lookPath,
 
-		gaugeNumChains:        gaugeNumChains.WithLabelValues(fmt.Sprintf("%d", ipVersion), name),
-		gaugeNumRules:         gaugeNumRules.WithLabelValues(fmt.Sprintf("%d", ipVersion), name),
-		countNumLinesExecuted: countNumLinesExecuted.WithLabelValues(fmt.Sprintf("%d", ipVersion), name),
-		opReporter:            options.OpRecorder,
+		gaugeNumChains:            gaugeNumChains.WithLabelValues(fmt.Sprintf("%d", ipVersion), name),
+		gaugeNumRules:             gaugeNumRules.WithLabelValues(fmt.Sprintf("%d", ipVersion), name),
+		gaugeNumQuarantinedChains: gaugeNumQuarantinedChains.WithLabelValues(fmt.Sprintf("%d", ipVersion), name),
+		countNumLinesExecuted:     countNumLinesExecuted.WithLabelValues(fmt.Sprintf("%d", ipVersion), name),
+		countNumRestoreDeadlinesExceeded: countNumRestoreDeadlinesExceeded.WithLabelValues(
+			fmt.Sprintf("%d", ipVersion), name),
+		countNumChunkedApplies: countNumChunkedApplies.WithLabelValues(fmt.Sprintf("%d", ipVersion), name),
+		opReporter:             options.OpRecorder,
+	}
+	if table.opReporter == nil {
+		table.opReporter = noOpRecorder{}
 	}
 	table.restoreInputBuffer.NumLinesWritten = table.countNumLinesExecuted
 
@@ -454,44 +618,117 @@ func NewTable(
 	return table
 }
 
+// GetIPVersion returns the IP version of the table.
+func (t *Table) GetIPVersion() uint8 {
+	return t.IPVersion
+}
+
+// SetRefreshInterval changes the interval at which the Table re-reads the dataplane to check for
+// out-of-band changes.  Zero disables the periodic refresh.
+func (t *Table) SetRefreshInterval(interval time.Duration) {
+	t.refreshInterval = interval
+}
+
+// SetPostWriteInterval changes the delay before the Table first re-checks the dataplane after a
+// write.  It takes effect from the next write.
+func (t *Table) SetPostWriteInterval(interval time.Duration) {
+	if interval <= minPostWriteInterval {
+		interval = minPostWriteInterval
+	}
+	t.initialPostWriteInterval = interval
+}
+
+// SaveCommand returns the iptables-save binary that the table uses to read the dataplane.
+func (t *Table) SaveCommand() string {
+	return t.iptablesSaveCmd
+}
+
 // Insert or Append rules based on insert mode configuration.
 func (t *Table) InsertOrAppendRules(chainName string, rules []Rule) {
 	t.logCxt.WithField("chainName", chainName).Debug("Updating rule insertions")
-	oldRules := t.chainToInsertedRules[chainName]
-	t.chainToInsertedRules[chainName] = rules
-	numRulesDelta := len(rules) - len(oldRules)
-	t.gaugeNumRules.Add(float64(numRulesDelta))
-	t.dirtyInsertAppend.Add(chainName)
-
-	// Incref any newly-referenced chains, then decref the old ones.  By incrementing first we
-	// avoid marking a still-referenced chain as dirty.
-	t.increfReferredChains(rules)
-	t.decrefReferredChains(oldRules)
-
-	// Defensive: make sure we re-read the dataplane state before we make updates.  While the
-	// code was originally designed not to need this, we found that other users of
-	// iptables-restore can still clobber our updates so it's safest to re-read the state before
-	// each write.
-	t.InvalidateDataplaneCache("insertion")
+	t.insertGroups(chainName).SetInserts(rules)
+	t.mergeInsertGroups(chainName)
 }
 
 // Append rules.
 func (t *Table) AppendRules(chainName string, rules []Rule) {
 	t.logCxt.WithField("chainName", chainName).Debug("Updating rule appends")
-	oldRules := t.chainToAppendedRules[chainName]
-	t.chainToAppendedRules[chainName] = rules
-	numRulesDelta := len(rules) - len(oldRules)
+	t.insertGroups(chainName).SetAppends(rules)
+	t.mergeInsertGroups(chainName)
+}
+
+// UpdateInsertGroup sets the rules of the named insert group of the given chain.  Within each
+// position, groups are ordered by priority (lowest first) and then by name; the rules set by
+// InsertOrAppendRules and AppendRules belong to priority 0 groups that come before any other
+// priority 0 group.  Groups allow independent parts of Felix to place their rules relative to
+// those of other tools (such as a service mesh) that also hook the same chain.  Group names
+// must be unique within the chain, whatever their position, and must pass
+// CheckInsertGroupName.
+func (t *Table) UpdateInsertGroup(chainName string, group string, position InsertPosition, priority int, rules []Rule) {
+	logCxt := t.logCxt.WithFields(log.Fields{
+		"chainName": chainName,
+		"group":     group,
+		"position":  position,
+		"priority":  priority,
+	})
+	if err := CheckInsertGroupName(group); err != nil {
+		logCxt.WithError(err).Panic("Bug: invalid insert group")
+	}
+	logCxt.Debug("Updating insert group")
+	t.insertGroups(chainName)[group] = InsertGroup{
+		Name:     group,
+		Position: position,
+		Priority: priority,
+		Rules:    rules,
+	}
+	t.mergeInsertGroups(chainName)
+}
+
+// RemoveInsertGroup removes the named insert group's rules from the given chain.
+func (t *Table) RemoveInsertGroup(chainName string, group string) {
+	if err := CheckInsertGroupName(group); err != nil {
+		t.logCxt.WithError(err).WithField("chainName", chainName).Panic("Bug: invalid insert group")
+	}
+	if _, ok := t.chainToInsertGroups[chainName][group]; !ok {
+		return
+	}
+	t.logCxt.WithFields(log.Fields{
+		"chainName": chainName,
+		"group":     group,
+	}).Debug("Removing insert group")
+	delete(t.chainToInsertGroups[chainName], group)
+	t.mergeInsertGroups(chainName)
+}
+
+func (t *Table) insertGroups(chainName string) InsertGroups {
+	if t.chainToInsertGroups[chainName] == nil {
+		t.chainToInsertGroups[chainName] = InsertGroups{}
+	}
+	return t.chainToInsertGroups[chainName]
+}
+
+// mergeInsertGroups recalculates the rules that we insert into and append to the given chain
+// from its insert groups.
+func (t *Table) mergeInsertGroups(chainName string) {
+	inserts, appends := t.chainToInsertGroups[chainName].Merge()
+	oldInserts := t.chainToInsertedRules[chainName]
+	oldAppends := t.chainToAppendedRules[chainName]
+	t.chainToInsertedRules[chainName] = inserts
+	t.chainToAppendedRules[chainName] = appends
+	numRulesDelta := len(inserts) + len(appends) - len(oldInserts) - len(oldAppends)
 	t.gaugeNumRules.Add(float64(numRulesDelta))
 	t.dirtyInsertAppend.Add(chainName)
 
 	// Incref any newly-referenced chains, then decref the old ones.  By incrementing first we
 	// avoid marking a still-referenced chain as dirty.
-	t.increfReferredChains(rules)
-	t.decrefReferredChains(oldRules)
+	t.increfReferredChains(inserts)
+	t.increfReferredChains(appends)
+	t.decrefReferredChains(oldInserts)
+	t.decrefReferredChains(oldAppends)
 
 	// Defensive: make sure we re-read the dataplane state before we make updates.  While the
 	// code was originally designed not to need this, we found that other users of
-	// iptables-restore can still clobber out updates so it's safest to re-read the state before
+	// iptables-restore can still clobber our updates so it's safest to re-read the state before
 	// each write.
 	t.InvalidateDataplaneCache("insertion")
 }
@@ -503,7 +740,16 @@ func (t *Table) UpdateChains(chains []*Chain) {
 }
 
 func (t *Table) UpdateChain(chain *Chain) {
+	if _, ok := t.chainNameToFragments[chain.Name]; ok {
+		t.logCxt.WithField("chainName", chain.Name).Panic(
+			"Chain is shared, its fragments must be updated with UpdateChainFragment.")
+	}
+	t.updateChain(chain)
+}
+
+func (t *Table) updateChain(chain *Chain) {
 	t.logCxt.WithField("chainName", chain.Name).Info("Queueing update of chain.")
+	t.liftQuarantineIfChanged(chain)
 	oldNumRules := 0
 
 	// Incref any newly-referenced chains, then decref the old ones.  By incrementing first we
@@ -534,7 +780,16 @@ func (t *Table) RemoveChains(chains []*Chain) {
 }
 
 func (t *Table) RemoveChainByName(name string) {
+	if _, ok := t.chainNameToFragments[name]; ok {
+		t.logCxt.WithField("chainName", name).Panic(
+			"Chain is shared, its fragments must be removed with RemoveChainFragment.")
+	}
+	t.removeChainByName(name)
+}
+
+func (t *Table) removeChainByName(name string) {
 	t.logCxt.WithField("chainName", name).Info("Queuing deletion of chain.")
+	t.liftQuarantine(name)
 	if oldChain, known := t.chainNameToChain[name]; known {
 		t.gaugeNumRules.Sub(float64(len(oldChain.Rules)))
 		delete(t.chainNameToChain, name)
@@ -551,6 +806,55 @@ func (t *Table) RemoveChainByName(name string) {
 	t.InvalidateDataplaneCache("chain removal")
 }
 
+// UpdateChainFragment sets the rules that the given owner contributes to the named chain,
+// replacing any rules that it contributed previously.  This allows several managers to share a
+// chain without knowing about each other.  The chain's rules are the concatenation of its
+// owners' fragments, in order of increasing priority, then owner, so the result doesn't depend
+// on the order of the updates.
+//
+// A shared chain exists for as long as at least one owner has a fragment in it.  It may not be
+// updated or removed directly with UpdateChain or RemoveChainByName.
+func (t *Table) UpdateChainFragment(chainName string, owner string, priority int, rules []Rule) {
+	t.logCxt.WithFields(log.Fields{
+		"chainName": chainName,
+		"owner":     owner,
+		"priority":  priority,
+	}).Debug("Updating chain fragment.")
+	if _, ok := t.chainNameToFragments[chainName]; !ok {
+		if _, ok := t.chainNameToChain[chainName]; ok {
+			t.logCxt.WithField("chainName", chainName).Panic(
+				"Chain was set with UpdateChain, it can't also have fragments.")
+		}
+		t.chainNameToFragments[chainName] = ChainFragments{}
+	}
+	t.chainNameToFragments[chainName][owner] = ChainFragment{
+		Owner:    owner,
+		Priority: priority,
+		Rules:    rules,
+	}
+	t.updateChain(t.chainNameToFragments[chainName].Merge(chainName))
+}
+
+// RemoveChainFragment removes the rules that the given owner contributes to the named chain.
+// The chain is removed once it has no fragments left.
+func (t *Table) RemoveChainFragment(chainName string, owner string) {
+	fragments := t.chainNameToFragments[chainName]
+	if _, ok := fragments[owner]; !ok {
+		return
+	}
+	t.logCxt.WithFields(log.Fields{
+		"chainName": chainName,
+		"owner":     owner,
+	}).Debug("Removing chain fragment.")
+	delete(fragments, owner)
+	if len(fragments) == 0 {
+		delete(t.chainNameToFragments, chainName)
+		t.removeChainByName(chainName)
+		return
+	}
+	t.updateChain(t.chainNameToFragments[chainName].Merge(chainName))
+}
+
 // increfReferredChains finds all the chains that the given rules refer to  (i.e. have jumps/gotos to) and
 // increments their refcount.
 func (t *Table) increfReferredChains(rules []Rule) {
@@ -721,7 +1025,7 @@ func (t *Table) expectedHashesForInsertAppendChain(
 	if len(appendedRules) > 0 {
 		// Add *append* to chainName to produce a unique hash in case append chain/rules are same
 		// as insert chain/rules above.
-		ourAppendedHashes = calculateRuleHashes(chainName+"*appends*", appendedRules, features)
+		ourAppendedHashes = calculateRuleHashes(chainName+defaultAppendGroup, appendedRules, features)
 	}
 	offset := 0
 	if t.insertMode == "append" {
@@ -933,6 +1237,114 @@ func (t *Table) readHashesAndRulesFrom(r io.ReadCloser) (hashes map[string][]str
 	return hashes, rules, nil
 }
 
+// RuleCounts holds the packet and byte counts of a rule (or of several rules that share a comment).
+type RuleCounts struct {
+	Packets uint64
+	Bytes   uint64
+}
+
+// ReadRuleCounters runs iptables-save to read the packet counts of the rules in the given chain.
+// The counts are keyed by the rule's comment (ignoring our rule-hash comment); rules that share a
+// comment have their counts summed and rules without a comment are skipped.
+func (t *Table) ReadRuleCounters(chainName string) (map[string]uint64, error) {
+	out, err := t.saveWithCounters()
+	if err != nil {
+		return nil, err
+	}
+	counts, err := t.readRuleCountersFrom(bytes.NewReader(out), chainName)
+	if err != nil {
+		return nil, err
+	}
+	packets := map[string]uint64{}
+	for comment, c := range counts[chainName] {
+		packets[comment] = c.Packets
+	}
+	return packets, nil
+}
+
+// ReadAllRuleCounters runs iptables-save to read the packet and byte counts of the rules in all
+// chains of the table, keyed by chain name and then by comment, as for ReadRuleCounters().
+func (t *Table) ReadAllRuleCounters() (map[string]map[string]RuleCounts, error) {
+	out, err := t.saveWithCounters()
+	if err != nil {
+		return nil, err
+	}
+	return t.readRuleCountersFrom(bytes.NewReader(out), "")
+}
+
+func (t *Table) saveWithCounters() ([]byte, error) {
+	cmd := t.newCmd(t.iptablesSaveCmd, "-c", "-t", t.Name)
+	countNumSaveCalls.Inc()
+	out, err := cmd.Output()
+	if err != nil {
+		countNumSaveErrors.Inc()
+		return nil, err
+	}
+	return out, nil
+}
+
+// readRuleCountersFrom parses the output of iptables-save -c.  If chainName is non-empty, only
+// that chain's rules are included.
+func (t *Table) readRuleCountersFrom(r io.Reader, chainName string) (map[string]map[string]RuleCounts, error) {
+	counters := map[string]map[string]RuleCounts{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		captures := counterAppendRegexp.FindStringSubmatch(line)
+		if captures == nil || (chainName != "" && captures[3] != chainName) {
+			continue
+		}
+		packets, err := strconv.ParseUint(captures[1], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		byteCount, err := strconv.ParseUint(captures[2], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range commentRegexp.FindAllStringSubmatch(line, -1) {
+			comment := c[1] + c[2]
+			if strings.HasPrefix(comment, t.hashCommentPrefix) {
+				continue
+			}
+			chainCounts := counters[captures[3]]
+			if chainCounts == nil {
+				chainCounts = map[string]RuleCounts{}
+				counters[captures[3]] = chainCounts
+			}
+			counts := chainCounts[comment]
+			counts.Packets += packets
+			counts.Bytes += byteCount
+			chainCounts[comment] = counts
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return counters, nil
+}
+
+// maybeReportRuleCounters passes the table's rule counts to the OnRuleCounters callback if it's
+// been at least RuleCountersInterval since we last did so.  Failures are logged and retried on
+// the next Apply(); the counts are only informational.
+func (t *Table) maybeReportRuleCounters() {
+	if t.onRuleCounters == nil {
+		return
+	}
+	now := t.timeNow()
+	if now.Before(t.nextRuleCountersReadTime) {
+		return
+	}
+	counts, err := t.ReadAllRuleCounters()
+	if err != nil {
+		t.logCxt.WithError(err).Warn("Failed to read rule counters.")
+		return
+	}
+	t.nextRuleCountersReadTime = now.Add(t.ruleCountersInterval)
+	t.onRuleCounters(counts)
+}
+
 func (t *Table) InvalidateDataplaneCache(reason string) {
 	logCxt := t.logCxt.WithField("reason", reason)
 	if !t.inSyncWithDataPlane {
@@ -978,11 +1390,14 @@ func (t *Table) Apply() (rescheduleAfter time.Duration) {
 	//   the other process did.
 	// - Random transient failure.
 	//
-	// It's also possible that we're bugged and trying to write bad data so we give up
+	// It's also possible that we're bugged and trying to write bad data.  If the failures
+	// persist, we look for the rules that iptables-restore is rejecting and quarantine their
+	// chains so that we can program the rest of the table.  If that doesn't help, we give up
 	// eventually.
 	retries := 10
 	backoffTime := 1 * time.Millisecond
 	failedAtLeastOnce := false
+	numFailures := 0
 	for {
 		if !t.inSyncWithDataPlane {
 			// We have reason to believe that our picture of the dataplane is out of
@@ -992,6 +1407,16 @@ func (t *Table) Apply() (rescheduleAfter time.Duration) {
 		t.onStillAlive()
 
 		if err := t.applyUpdates(); err != nil {
+			if err == errRestoreDeadlineExceeded {
+				// Retry in smaller transactions.  Don't count this towards triage; the
+				// input wasn't rejected, it just took too long.
+				t.shrinkChunkSize()
+			} else {
+				numFailures++
+				if numFailures == failuresBeforeTriage {
+					t.quarantineRejectedChains(t.featureDetector.GetFeatures())
+				}
+			}
 			if retries > 0 {
 				retries--
 				t.logCxt.WithError(err).Warn("Failed to program iptables, will retry")
@@ -1017,8 +1442,15 @@ func (t *Table) Apply() (rescheduleAfter time.Duration) {
 		}
 		break
 	}
+	if t.chunkedChainsPerRestore > 0 {
+		t.logCxt.WithField("chainsPerRestore", t.chunkedChainsPerRestore).Warn(
+			"Update succeeded after splitting it into smaller transactions.")
+		t.countNumChunkedApplies.Inc()
+		t.chunkedChainsPerRestore = 0
+	}
 
 	t.gaugeNumChains.Set(float64(len(t.chainRefCounts)))
+	t.maybeReportRuleCounters()
 
 	// Check whether we need to be rescheduled and how soon.
 	if t.refreshInterval > 0 {
@@ -1042,6 +1474,14 @@ func (t *Table) applyUpdates() error {
 	// If needed, detect the dataplane features.
 	features := t.featureDetector.GetFeatures()
 
+	// Leave alone any quarantined chains that are already in the dataplane.
+	t.skipQuarantinedChains()
+
+	// If there are a lot of chain updates, apply all but the last batch of them separately.
+	if err := t.applyChainUpdatesInBatches(features); err != nil {
+		return err
+	}
+
 	// Build up the iptables-restore input in an in-memory buffer.  This allows us to log out the exact input after
 	// a failure, which has proven to be a very useful diagnostic tool.
 	buf := &t.restoreInputBuffer
@@ -1054,30 +1494,9 @@ func (t *Table) applyUpdates() error {
 	// Writing a forward reference ensures that the chain exists and that it is empty.
 	t.dirtyChains.Iter(func(item interface{}) error {
 		chainName := item.(string)
-		chainNeedsToBeFlushed := false
-		if t.nftablesMode {
-			// iptables-nft-restore <v1.8.3 has a bug (https://bugzilla.netfilter.org/show_bug.cgi?id=1348)
-			// where only the first replace command sets the rule index.  Work around that by refreshing the
-			// whole chain using a flush.
-			chain, _ := t.desiredStateOfChain(chainName)
-			currentHashes := chain.RuleHashes(features)
-			previousHashes := t.chainToDataplaneHashes[chainName]
-			t.logCxt.WithFields(log.Fields{
-				"previous": previousHashes,
-				"current":  currentHashes,
-			}).Debug("Comparing old to new hashes.")
-			if len(previousHashes) > 0 && reflect.DeepEqual(currentHashes, previousHashes) {
-				// Chain is already correct, skip it.
-				log.Debug("Chain already correct")
-				return set.RemoveItem
-			}
-			chainNeedsToBeFlushed = true
-		} else if _, present := t.desiredStateOfChain(chainName); !present {
-			// About to delete this chain, flush it first to sever dependencies.
-			chainNeedsToBeFlushed = true
-		} else if _, ok := t.chainToDataplaneHashes[chainName]; !ok {
-			// Chain doesn't exist in dataplane, mark it for creation.
-			chainNeedsToBeFlushed = true
+		chainNeedsToBeFlushed, alreadyCorrect := t.chainNeedsFlush(chainName, features)
+		if alreadyCorrect {
+			return set.RemoveItem
 		}
 		if chainNeedsToBeFlushed {
 			buf.WriteForwardReference(chainName)
@@ -1090,39 +1509,7 @@ func (t *Table) applyUpdates() error {
 	t.dirtyChains.Iter(func(item interface{}) error {
 		chainName := item.(string)
 		if chain, ok := t.desiredStateOfChain(chainName); ok {
-			// Chain update or creation.  Scan the chain against its previous hashes
-			// and replace/append/delete as appropriate.
-			var previousHashes []string
-			if t.nftablesMode {
-				// Due to a bug in iptables nft mode, force a whole-chain rewrite.  (See above.)
-				previousHashes = nil
-			} else {
-				// In iptables legacy mode, we compare the rules one by one and apply deltas rule by rule.
-				previousHashes = t.chainToDataplaneHashes[chainName]
-			}
-			currentHashes := chain.RuleHashes(features)
-			newHashes[chainName] = currentHashes
-			for i := 0; i < len(previousHashes) || i < len(currentHashes); i++ {
-				var line string
-				if i < len(previousHashes) && i < len(currentHashes) {
-					if previousHashes[i] == currentHashes[i] {
-						continue
-					}
-					// Hash doesn't match, replace the rule.
-					ruleNum := i + 1 // 1-indexed.
-					prefixFrag := t.commentFrag(currentHashes[i])
-					line = chain.Rules[i].RenderReplace(chainName, ruleNum, prefixFrag, features)
-				} else if i < len(previousHashes) {
-					// previousHashes was longer, remove the old rules from the end.
-					ruleNum := len(currentHashes) + 1 // 1-indexed
-					line = t.renderDeleteByIndexLine(chainName, ruleNum)
-				} else {
-					// currentHashes was longer.  Append.
-					prefixFrag := t.commentFrag(currentHashes[i])
-					line = chain.Rules[i].RenderAppend(chainName, prefixFrag, features)
-				}
-				buf.WriteLine(line)
-			}
+			newHashes[chainName] = t.writeChainUpdate(buf, chainName, t.programmableChain(chain), features)
 		}
 		return nil // Delay clearing the set until we've programmed iptables.
 	})
@@ -1151,6 +1538,7 @@ func (t *Table) applyUpdates() error {
 			// Chain is in sync, skip to next one.
 			return nil
 		}
+		buf.CountChainUpdate()
 
 		// For simplicity, if we've discovered that we're out-of-sync, remove all our
 		// rules from this chain, then re-insert/re-append them below.
@@ -1174,6 +1562,7 @@ func (t *Table) applyUpdates() error {
 		newRules = copyOfNewRules
 		rules := t.chainToInsertedRules[chainName]
 		insertRuleLines := make([]string, len(rules))
+		buf.CountRulesWritten(len(rules))
 
 		// Add inserted rules if there is any
 		if len(rules) > 0 {
@@ -1204,6 +1593,7 @@ func (t *Table) applyUpdates() error {
 		// Add appended rules if there is any
 		rules = t.chainToAppendedRules[chainName]
 		appendRuleLines := make([]string, len(rules))
+		buf.CountRulesWritten(len(rules))
 
 		if len(rules) > 0 {
 			t.logCxt.Debug("Rendering specific append rules.")
@@ -1256,6 +1646,7 @@ func (t *Table) applyUpdates() error {
 			// Chain deletion
 			buf.WriteLine(fmt.Sprintf("--delete-chain %s", chainName))
 			newHashes[chainName] = nil
+			buf.CountChainUpdate()
 		}
 		return nil // Delay clearing the set until we've programmed iptables.
 	})
@@ -1264,70 +1655,8 @@ func (t *Table) applyUpdates() error {
 
 	if buf.Empty() {
 		t.logCxt.Debug("Update ended up being no-op, skipping call to ip(6)tables-restore.")
-	} else {
-		// Get the contents of the buffer ready to send to iptables-restore.  Warning: for perf, this is directly
-		// accessing the buffer's internal array; don't touch the buffer after this point.
-		t.opReporter.RecordOperation(fmt.Sprintf("update-%v-v%d", t.Name, t.IPVersion))
-
-		inputBytes := buf.GetBytesAndReset()
-
-		if log.GetLevel() >= log.DebugLevel {
-			// Only convert (potentially very large slice) to string at debug level.
-			inputStr := string(inputBytes)
-			t.logCxt.WithField("iptablesInput", inputStr).Debug("Writing to iptables")
-		}
-
-		var outputBuf, errBuf bytes.Buffer
-		args := []string{"--noflush", "--verbose"}
-		if features.RestoreSupportsLock {
-			// Versions of iptables-restore that support the xtables lock also make it impossible to disable.  Make
-			// sure that we configure it to retry and configure for a short retry interval (the default is to try to
-			// acquire the lock only once).
-			lockTimeout := t.lockTimeout.Seconds()
-			if lockTimeout <= 0 {
-				// Before iptables-restore added lock support, we were able to disable the lock completely, which
-				// was indicated by a value <=0 (and was our default).  Newer versions of iptables-restore require the
-				// lock so we override the default and set it to 10s.
-				lockTimeout = 10
-			}
-			lockProbeMicros := t.lockProbeInterval.Nanoseconds() / 1000
-			timeoutStr := fmt.Sprintf("%.0f", lockTimeout)
-			intervalStr := fmt.Sprintf("%d", lockProbeMicros)
-			args = append(args,
-				"--wait", timeoutStr, // seconds
-				"--wait-interval", intervalStr, // microseconds
-			)
-			log.WithFields(log.Fields{
-				"timeoutSecs":         timeoutStr,
-				"probeIntervalMicros": intervalStr,
-			}).Debug("Using native iptables-restore xtables lock.")
-		}
-		cmd := t.newCmd(t.iptablesRestoreCmd, args...)
-		cmd.SetStdin(bytes.NewReader(inputBytes))
-		cmd.SetStdout(&outputBuf)
-		cmd.SetStderr(&errBuf)
-		countNumRestoreCalls.Inc()
-		// Note: calicoXtablesLock will be a dummy lock if our xtables lock is disabled (i.e. if iptables-restore
-		// supports the xtables lock itself, or if our implementation is disabled by config.
-		t.calicoXtablesLock.Lock()
-		err := cmd.Run()
-		t.calicoXtablesLock.Unlock()
-		if err != nil {
-			// To log out the input, we must convert to string here since, after we return, the buffer can be re-used
-			// (and the logger may convert to string on a background thread).
-			inputStr := string(inputBytes)
-			t.logCxt.WithFields(log.Fields{
-				"output":      outputBuf.String(),
-				"errorOutput": errBuf.String(),
-				"error":       err,
-				"input":       inputStr,
-			}).Warn("Failed to execute ip(6)tables-restore command")
-			t.inSyncWithDataPlane = false
-			countNumRestoreErrors.Inc()
-			return err
-		}
-		t.lastWriteTime = t.timeNow()
-		t.postWriteInterval = t.initialPostWriteInterval
+	} else if err := t.execRestore(buf, features); err != nil {
+		return err
 	}
 
 	// Now we've successfully updated iptables, clear the dirty sets.  We do this even if we
@@ -1349,6 +1678,361 @@ func (t *Table) applyUpdates() error {
 	return nil
 }
 
+// applyChainUpdatesInBatches applies updates to dirty chains in transactions of at most
+// maxChainsPerRestore chains, until there are few enough left to go in the same transaction as the
+// rest of applyUpdates()'s work (i.e. inserts, appends and chain deletions).  Chains are removed
+// from the dirty set as each batch succeeds so that, if a batch fails, the retry only has to
+// reprogram the chains that weren't done yet.
+//
+// Since a chain can't refer to a chain that doesn't exist yet, chains are ordered so that each
+// chain comes after the dirty chains that it refers to.
+func (t *Table) applyChainUpdatesInBatches(features *Features) error {
+	maxChainsPerRestore := t.maxChainsPerRestore
+	if t.chunkedChainsPerRestore > 0 {
+		maxChainsPerRestore = t.chunkedChainsPerRestore
+	}
+	if maxChainsPerRestore <= 0 {
+		return nil
+	}
+
+	var updates []string
+	t.dirtyChains.Iter(func(item interface{}) error {
+		chainName := item.(string)
+		if _, ok := t.desiredStateOfChain(chainName); ok {
+			updates = append(updates, chainName)
+		}
+		return nil
+	})
+	if len(updates) <= maxChainsPerRestore {
+		return nil
+	}
+	updates = t.orderChainsByReferences(updates)
+
+	numBatches := (len(updates) - 1) / maxChainsPerRestore
+	t.logCxt.WithFields(log.Fields{
+		"numChains":  len(updates),
+		"numBatches": numBatches,
+	}).Info("Large update, applying chain updates in batches.")
+	buf := &t.restoreInputBuffer
+	for batch := 1; len(updates) > maxChainsPerRestore; batch++ {
+		chainNames := updates[:maxChainsPerRestore]
+		updates = updates[maxChainsPerRestore:]
+
+		buf.Reset()
+		buf.StartTransaction(t.Name)
+		var todo []string
+		for _, chainName := range chainNames {
+			needsFlush, alreadyCorrect := t.chainNeedsFlush(chainName, features)
+			if alreadyCorrect {
+				t.dirtyChains.Discard(chainName)
+				continue
+			}
+			if needsFlush {
+				buf.WriteForwardReference(chainName)
+			}
+			todo = append(todo, chainName)
+		}
+		newHashes := map[string][]string{}
+		for _, chainName := range todo {
+			chain, _ := t.desiredStateOfChain(chainName)
+			newHashes[chainName] = t.writeChainUpdate(buf, chainName, t.programmableChain(chain), features)
+		}
+		buf.EndTransaction()
+
+		if !buf.Empty() {
+			if err := t.execRestore(buf, features); err != nil {
+				t.logCxt.WithError(err).WithFields(log.Fields{
+					"batch":      batch,
+					"numBatches": numBatches,
+				}).Warn("Failed to apply batch of chain updates.")
+				return err
+			}
+		}
+		for chainName, hashes := range newHashes {
+			t.chainToDataplaneHashes[chainName] = hashes
+			t.dirtyChains.Discard(chainName)
+		}
+		t.logCxt.WithFields(log.Fields{
+			"batch":         batch,
+			"numBatches":    numBatches,
+			"chainsInBatch": len(chainNames),
+			"chainsLeft":    len(updates),
+		}).Info("Applied batch of chain updates.")
+		t.onStillAlive()
+	}
+	return nil
+}
+
+// shrinkChunkSize halves the number of chains that we update per iptables-restore transaction, after
+// an iptables-restore call exceeded the restore deadline.
+func (t *Table) shrinkChunkSize() {
+	chunkSize := t.chunkedChainsPerRestore
+	if chunkSize <= 0 {
+		chunkSize = t.maxChainsPerRestore
+	}
+	if chunkSize <= 0 || chunkSize > t.dirtyChains.Len() {
+		chunkSize = t.dirtyChains.Len()
+	}
+	chunkSize /= 2
+	if chunkSize < 1 {
+		chunkSize = 1
+	}
+	t.logCxt.WithFields(log.Fields{
+		"deadline":         t.restoreDeadline,
+		"chainsPerRestore": chunkSize,
+	}).Warn("iptables-restore exceeded its deadline, will retry in smaller transactions.")
+	t.chunkedChainsPerRestore = chunkSize
+}
+
+// orderChainsByReferences returns the chains sorted so that each chain comes after any chains in
+// the list that it refers to.
+func (t *Table) orderChainsByReferences(chainNames []string) []string {
+	sort.Strings(chainNames)
+	inList := set.FromArray(chainNames)
+	visited := set.New()
+	ordered := make([]string, 0, len(chainNames))
+	var visit func(chainName string)
+	visit = func(chainName string) {
+		if visited.Contains(chainName) {
+			return
+		}
+		visited.Add(chainName)
+		chain, _ := t.desiredStateOfChain(chainName)
+		for _, r := range chain.Rules {
+			if ref, ok := r.Action.(Referrer); ok && inList.Contains(ref.ReferencedChain()) {
+				visit(ref.ReferencedChain())
+			}
+		}
+		ordered = append(ordered, chainName)
+	}
+	for _, chainName := range chainNames {
+		visit(chainName)
+	}
+	return ordered
+}
+
+// chainNeedsFlush returns whether the dirty chain needs a forward reference to create it or flush
+// it before we write its rules.  It also returns whether, in nftables mode, the chain turned out
+// to be correct already, in which case it shouldn't be written at all.
+func (t *Table) chainNeedsFlush(chainName string, features *Features) (needsFlush, alreadyCorrect bool) {
+	if t.nftablesMode {
+		// iptables-nft-restore <v1.8.3 has a bug (https://bugzilla.netfilter.org/show_bug.cgi?id=1348)
+		// where only the first replace command sets the rule index.  Work around that by refreshing the
+		// whole chain using a flush.
+		chain, _ := t.desiredStateOfChain(chainName)
+		currentHashes := chain.RuleHashes(features)
+		previousHashes := t.chainToDataplaneHashes[chainName]
+		t.logCxt.WithFields(log.Fields{
+			"previous": previousHashes,
+			"current":  currentHashes,
+		}).Debug("Comparing old to new hashes.")
+		if len(previousHashes) > 0 && reflect.DeepEqual(currentHashes, previousHashes) {
+			// Chain is already correct, skip it.
+			log.Debug("Chain already correct")
+			return false, true
+		}
+		return true, false
+	} else if _, present := t.desiredStateOfChain(chainName); !present {
+		// About to delete this chain, flush it first to sever dependencies.
+		return true, false
+	} else if _, ok := t.chainToDataplaneHashes[chainName]; !ok {
+		// Chain doesn't exist in dataplane, mark it for creation.
+		return true, false
+	}
+	return false, false
+}
+
+// writeChainUpdate writes the lines that update or create the chain to the buffer.  It scans the
+// chain against its previous hashes and replaces/appends/deletes as appropriate.  Returns the
+// chain's new hashes.
+func (t *Table) writeChainUpdate(buf *RestoreInputBuilder, chainName string, chain *Chain, features *Features) []string {
+	var previousHashes []string
+	if t.nftablesMode {
+		// Due to a bug in iptables nft mode, force a whole-chain rewrite.  (See chainNeedsFlush.)
+		previousHashes = nil
+	} else {
+		// In iptables legacy mode, we compare the rules one by one and apply deltas rule by rule.
+		previousHashes = t.chainToDataplaneHashes[chainName]
+	}
+	currentHashes := chain.RuleHashes(features)
+	numLines, numRules := 0, 0
+	for i := 0; i < len(previousHashes) || i < len(currentHashes); i++ {
+		var line string
+		if i < len(previousHashes) && i < len(currentHashes) {
+			if previousHashes[i] == currentHashes[i] {
+				continue
+			}
+			// Hash doesn't match, replace the rule.
+			ruleNum := i + 1 // 1-indexed.
+			prefixFrag := t.commentFrag(currentHashes[i])
+			line = chain.Rules[i].RenderReplace(chainName, ruleNum, prefixFrag, features)
+			numRules++
+		} else if i < len(previousHashes) {
+			// previousHashes was longer, remove the old rules from the end.
+			ruleNum := len(currentHashes) + 1 // 1-indexed
+			line = t.renderDeleteByIndexLine(chainName, ruleNum)
+		} else {
+			// currentHashes was longer.  Append.
+			prefixFrag := t.commentFrag(currentHashes[i])
+			line = chain.Rules[i].RenderAppend(chainName, prefixFrag, features)
+			numRules++
+		}
+		buf.WriteLine(line)
+		numLines++
+	}
+	if numLines > 0 {
+		buf.CountChainUpdate()
+		buf.CountRulesWritten(numRules)
+	}
+	return currentHashes
+}
+
+// execRestore sends the contents of the buffer to iptables-restore.
+func (t *Table) execRestore(buf *RestoreInputBuilder, features *Features) error {
+	// Get the contents of the buffer ready to send to iptables-restore.  Warning: for perf, this is directly
+	// accessing the buffer's internal array; don't touch the buffer after this point.
+	t.opReporter.RecordOperation(fmt.Sprintf("update-%v-v%d", t.Name, t.IPVersion))
+
+	numChainsUpdated, numRulesWritten := buf.Counts()
+	inputBytes := buf.GetBytesAndReset()
+
+	if log.GetLevel() >= log.DebugLevel {
+		// Only convert (potentially very large slice) to string at debug level.
+		inputStr := string(inputBytes)
+		t.logCxt.WithField("iptablesInput", inputStr).Debug("Writing to iptables")
+	}
+
+	var outputBuf, errBuf bytes.Buffer
+	err := t.runRestore(inputBytes, features, &outputBuf, &errBuf)
+	if err != nil {
+		// To log out the input, we must convert to string here since, after we return, the buffer can be re-used
+		// (and the logger may convert to string on a background thread).
+		inputStr := string(inputBytes)
+		t.logCxt.WithFields(log.Fields{
+			"output":      outputBuf.String(),
+			"errorOutput": errBuf.String(),
+			"error":       err,
+			"input":       inputStr,
+		}).Warn("Failed to execute ip(6)tables-restore command")
+		t.onRestoreFailure()
+		countNumRestoreErrors.Inc()
+		if err == errRestoreDeadlineExceeded {
+			t.countNumRestoreDeadlinesExceeded.Inc()
+		}
+		return err
+	}
+	t.lastRestoreFailed = false
+	t.lastWriteTime = t.timeNow()
+	t.postWriteInterval = t.initialPostWriteInterval
+	if r, ok := t.opReporter.(CountRecorder); ok {
+		r.RecordCount(CountChainsUpdated, numChainsUpdated)
+		r.RecordCount(CountRulesWritten, numRulesWritten)
+	}
+	return nil
+}
+
+// onRestoreFailure marks the state that a failed iptables-restore may have left inconsistent for
+// resync.  Re-reading the whole table is expensive when there are tens of thousands of chains so,
+// if the restore only touched our own chains, we forget what we think is in those chains instead.
+// The retry then flushes and rewrites them in full, which doesn't depend on their current contents.
+// Our inserts into, and appends to, other chains are positional so, if there are any of those, or if
+// the targeted retry fails too, we fall back to re-reading the whole table.
+func (t *Table) onRestoreFailure() {
+	if t.lastRestoreFailed || t.dirtyInsertAppend.Len() > 0 {
+		t.inSyncWithDataPlane = false
+	} else {
+		t.logCxt.WithField("numChains", t.dirtyChains.Len()).Info(
+			"Will rewrite the chains that we failed to update.")
+		t.dirtyChains.Iter(func(item interface{}) error {
+			delete(t.chainToDataplaneHashes, item.(string))
+			return nil
+		})
+	}
+	t.lastRestoreFailed = true
+}
+
+// runRestore runs iptables-restore with the given input, adding extraArgs to the usual arguments.
+func (t *Table) runRestore(input []byte, features *Features, stdout, stderr io.Writer, extraArgs ...string) error {
+	if t.dryRun != nil && len(extraArgs) == 0 {
+		// Dry run: report the update instead of making it.  (Calls with extra args are
+		// "--test" runs, which don't modify the dataplane so we let them through.)
+		t.dryRun(input)
+		return nil
+	}
+	args := []string{"--noflush", "--verbose"}
+	// iptables-nft-restore applies its update in a single nftables transaction so it doesn't need the
+	// xtables lock at all.  Only trust that if we're actually using the nft variant.
+	lockFree := features.RestoreLockFree && t.nftablesMode
+	if features.RestoreSupportsLock && !lockFree {
+		// Versions of iptables-restore that support the xtables lock also make it impossible to disable.  Make
+		// sure that we configure it to retry and, where supported, configure for a short retry interval (the
+		// default is to try to acquire the lock only once).
+		lockTimeout := t.lockTimeout.Seconds()
+		if lockTimeout <= 0 {
+			// Before iptables-restore added lock support, we were able to disable the lock completely, which
+			// was indicated by a value <=0 (and was our default).  Newer versions of iptables-restore require the
+			// lock so we override the default and set it to 10s.
+			lockTimeout = 10
+		}
+		timeoutStr := fmt.Sprintf("%.0f", lockTimeout)
+		args = append(args, "--wait", timeoutStr) // seconds
+		logCxt := log.WithField("timeoutSecs", timeoutStr)
+		if features.RestoreSupportsWaitInterval {
+			lockProbeMicros := t.lockProbeInterval.Nanoseconds() / 1000
+			intervalStr := fmt.Sprintf("%d", lockProbeMicros)
+			args = append(args, "--wait-interval", intervalStr) // microseconds
+			logCxt = logCxt.WithField("probeIntervalMicros", intervalStr)
+		}
+		logCxt.Debug("Using native iptables-restore xtables lock.")
+	}
+	args = append(args, extraArgs...)
+	cmd := t.newCmd(t.iptablesRestoreCmd, args...)
+	cmd.SetStdin(bytes.NewReader(input))
+	cmd.SetStdout(stdout)
+	cmd.SetStderr(stderr)
+	countNumRestoreCalls.Inc()
+	// Note: calicoXtablesLock will be a dummy lock if our xtables lock is disabled (i.e. if iptables-restore
+	// supports the xtables lock itself, or if our implementation is disabled by config.  We skip it entirely
+	// if iptables-restore doesn't need a lock.
+	if !lockFree {
+		t.calicoXtablesLock.Lock()
+	}
+	var err error
+	if t.restoreDeadline > 0 {
+		err = t.runWithDeadline(cmd)
+	} else {
+		err = cmd.Run()
+	}
+	if !lockFree {
+		t.calicoXtablesLock.Unlock()
+	}
+	return err
+}
+
+// runWithDeadline runs the command, killing it if it runs for longer than the restore deadline.
+func (t *Table) runWithDeadline(cmd CmdIface) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	timer := time.NewTimer(t.restoreDeadline)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		t.logCxt.WithField("deadline", t.restoreDeadline).Warn("iptables-restore exceeded its deadline, killing it.")
+		if err := cmd.Kill(); err != nil {
+			t.logCxt.WithError(err).Warn("Failed to kill iptables-restore.")
+		}
+		<-done
+		return errRestoreDeadlineExceeded
+	}
+}
+
 // desiredStateOfChain returns the given chain, if and only if it exists in the cache and it is referenced by some
 // other chain.  If the chain doesn't exist or it is not referenced, returns nil and false.
 func (t *Table) desiredStateOfChain(chainName string) (chain *Chain, present bool) {
@@ -15,7 +15,9 @@
 package iptables_test
 
 import (
+	"fmt"
 	"os/exec"
+	"regexp"
 	"strings"
 	"time"
 
@@ -23,10 +25,14 @@ import (
 	"github.com/projectcalico/felix/logutils"
 
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 
 	"github.com/projectcalico/felix/rules"
 
+	"github.com/projectcalico/libcalico-go/lib/set"
+	"github.com/prometheus/client_golang/prometheus"
+
 	log "github.com/sirupsen/logrus"
 )
 
@@ -71,12 +77,64 @@ var _ = Describe("Table with an empty dataplane (legacy)", func() {
 	})
 })
 
+var _ = DescribeTable("iptables-restore locking",
+	func(dataplaneMode, version string, expectedArgs []string, expectLockTaken bool) {
+		dataplane := newMockDataplane("filter", map[string][]string{
+			"FORWARD": {},
+			"INPUT":   {},
+			"OUTPUT":  {},
+		}, dataplaneMode)
+		dataplane.Version = version
+		dataplane.RestoreArgs = expectedArgs
+		iptLock := &mockMutex{}
+		featureDetector := NewFeatureDetector(nil)
+		featureDetector.NewCmd = dataplane.newCmd
+		featureDetector.GetKernelVersionReader = dataplane.getKernelVersionReader
+		table := NewTable(
+			"filter",
+			4,
+			rules.RuleHashPrefix,
+			iptLock,
+			featureDetector,
+			TableOptions{
+				HistoricChainPrefixes: rules.AllHistoricChainNamePrefixes,
+				NewCmdOverride:        dataplane.newCmd,
+				SleepOverride:         dataplane.sleep,
+				NowOverride:           dataplane.now,
+				BackendMode:           dataplaneMode,
+				LookPathOverride:      lookPathNoLegacy,
+				LockProbeInterval:     50 * time.Millisecond,
+				OpRecorder:            logutils.NewSummarizer("test loop"),
+			},
+		)
+
+		table.InsertOrAppendRules("FORWARD", []Rule{
+			{Action: DropAction{}},
+		})
+		table.Apply()
+		Expect(dataplane.Chains["FORWARD"]).To(HaveLen(1))
+		Expect(iptLock.WasTaken).To(Equal(expectLockTaken))
+	},
+	Entry("old iptables uses our lock", "legacy", "iptables v1.6.1\n",
+		[]string{"--noflush", "--verbose"}, true),
+	Entry("iptables without --wait-interval support", "legacy", "iptables v1.8.4 (legacy)\n",
+		[]string{"--noflush", "--verbose", "--wait", "10"}, true),
+	Entry("iptables with --wait-interval support", "legacy", "iptables v1.8.5 (legacy)\n",
+		[]string{"--noflush", "--verbose", "--wait", "10", "--wait-interval", "50000"}, true),
+	Entry("iptables-nft is lock-free", "nft", "iptables v1.8.7 (nf_tables)\n",
+		[]string{"--noflush", "--verbose"}, false),
+	Entry("nf_tables iptables but legacy mode uses the lock", "legacy", "iptables v1.8.7 (nf_tables)\n",
+		[]string{"--noflush", "--verbose", "--wait", "10", "--wait-interval", "50000"}, true),
+)
+
 func describeEmptyDataplaneTests(dataplaneMode string) {
 	var dataplane *mockDataplane
 	var table *Table
 	var iptLock *mockMutex
 	var featureDetector *FeatureDetector
+	var summarizer *logutils.Summarizer
 	BeforeEach(func() {
+		summarizer = logutils.NewSummarizer("test loop")
 		dataplane = newMockDataplane("filter", map[string][]string{
 			"FORWARD": {},
 			"INPUT":   {},
@@ -99,7 +157,7 @@ func describeEmptyDataplaneTests(dataplaneMode string) {
 				NowOverride:           dataplane.now,
 				BackendMode:           dataplaneMode,
 				LookPathOverride:      lookPathNoLegacy,
-				OpRecorder:            logutils.NewSummarizer("test loop"),
+				OpRecorder:            summarizer,
 			},
 		)
 	})
@@ -163,6 +221,62 @@ func describeEmptyDataplaneTests(dataplaneMode string) {
 		}
 	})
 
+	It("should report the number of chains and rules that it wrote", func() {
+		table.InsertOrAppendRules("FORWARD", []Rule{
+			{Action: JumpAction{Target: "cali-foobar"}},
+		})
+		table.UpdateChains([]*Chain{
+			{Name: "cali-foobar", Rules: []Rule{{Action: AcceptAction{}}, {Action: DropAction{}}}},
+		})
+		table.Apply()
+		Expect(summarizer.CurrentCounts()).To(Equal(map[string]int{
+			logutils.CountChainsUpdated: 2,
+			logutils.CountRulesWritten:  3,
+		}))
+	})
+
+	Describe("after a failed restore", func() {
+		var saveCmd, restoreCmd string
+
+		BeforeEach(func() {
+			saveCmd, restoreCmd = "iptables-save", "iptables-restore"
+			if dataplaneMode == "nft" {
+				saveCmd, restoreCmd = "iptables-nft-save", "iptables-nft-restore"
+			}
+			table.InsertOrAppendRules("FORWARD", []Rule{
+				{Action: JumpAction{Target: "cali-foobar"}},
+			})
+			table.UpdateChains([]*Chain{
+				{Name: "cali-foobar", Rules: []Rule{{Action: AcceptAction{}}}},
+			})
+			table.Apply()
+			dataplane.CmdNames = nil
+
+			table.UpdateChains([]*Chain{
+				{Name: "cali-foobar", Rules: []Rule{{Action: DropAction{}}, {Action: AcceptAction{}}}},
+			})
+			dataplane.FailNextRestore = true
+		})
+
+		It("should rewrite the chain without re-reading the table", func() {
+			table.Apply()
+			// The chain update itself triggers a load but the retry shouldn't need another.
+			Expect(dataplane.CmdNames).To(Equal([]string{"iptables", saveCmd, restoreCmd, restoreCmd}))
+			Expect(dataplane.Chains["cali-foobar"]).To(HaveLen(2))
+		})
+
+		It("should re-read the table if the retry fails too", func() {
+			dataplane.OnPreRestore = func() {
+				dataplane.FailRestoreNum = dataplane.numRestores + 1
+			}
+			table.Apply()
+			Expect(dataplane.CmdNames).To(Equal([]string{
+				"iptables", saveCmd, restoreCmd, restoreCmd, "iptables", saveCmd, restoreCmd,
+			}))
+			Expect(dataplane.Chains["cali-foobar"]).To(HaveLen(2))
+		})
+	})
+
 	It("should ignore delete of non-existent chain", func() {
 		table.RemoveChains([]*Chain{
 			{Name: "cali-foobar", Rules: []Rule{{Action: AcceptAction{}}}},
@@ -171,6 +285,123 @@ func describeEmptyDataplaneTests(dataplaneMode string) {
 		Expect(dataplane.DeletedChains).To(BeEmpty())
 	})
 
+	It("should read the packet counts of a chain's rules by comment", func() {
+		table.InsertOrAppendRules("FORWARD", []Rule{
+			{Action: JumpAction{Target: "cali-foobar"}},
+			{Action: JumpAction{Target: "cali-other"}},
+		})
+		table.UpdateChains([]*Chain{
+			{Name: "cali-foobar", Rules: []Rule{
+				{Action: DropAction{}, Comment: []string{"limit-a"}},
+				{Action: DropAction{}, Comment: []string{"limit b"}},
+				{Action: DropAction{}, Comment: []string{"limit-a"}},
+				{Action: DropAction{}},
+			}},
+			{Name: "cali-other", Rules: []Rule{
+				{Action: DropAction{}, Comment: []string{"limit-a"}},
+			}},
+		})
+		table.Apply()
+		dataplane.PacketCounts = map[string][]uint64{
+			"cali-foobar": {10, 20, 5, 100},
+			"cali-other":  {1000},
+		}
+
+		counters, err := table.ReadRuleCounters("cali-foobar")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(counters).To(Equal(map[string]uint64{
+			"limit-a": 15,
+			"limit b": 20,
+		}))
+	})
+
+	It("should return an error if it fails to read the rule counters", func() {
+		dataplane.FailAllSaves = true
+		_, err := table.ReadRuleCounters("cali-foobar")
+		Expect(err).To(HaveOccurred())
+	})
+
+	Describe("with a shared chain", func() {
+		// sharedChainActions returns the actions of the shared chain's rules, in order.
+		sharedChainActions := func() []string {
+			var actions []string
+			for _, r := range dataplane.Chains["cali-shared"] {
+				actions = append(actions, r[strings.Index(r, "--jump"):])
+			}
+			return actions
+		}
+
+		BeforeEach(func() {
+			table.InsertOrAppendRules("FORWARD", []Rule{
+				{Action: JumpAction{Target: "cali-shared"}},
+			})
+			table.UpdateChainFragment("cali-shared", "owner-b", 10, []Rule{
+				{Action: JumpAction{Target: "cali-b"}},
+			})
+			table.UpdateChainFragment("cali-shared", "owner-a", 20, []Rule{
+				{Action: JumpAction{Target: "cali-a1"}},
+				{Action: JumpAction{Target: "cali-a2"}},
+			})
+			table.UpdateChainFragment("cali-shared", "owner-c", 10, []Rule{
+				{Action: JumpAction{Target: "cali-c"}},
+			})
+			table.UpdateChains([]*Chain{
+				{Name: "cali-a1"}, {Name: "cali-a2"}, {Name: "cali-b"}, {Name: "cali-c"},
+			})
+			table.Apply()
+		})
+
+		It("should merge the fragments by priority, then owner", func() {
+			Expect(sharedChainActions()).To(Equal([]string{
+				"--jump cali-b",
+				"--jump cali-c",
+				"--jump cali-a1",
+				"--jump cali-a2",
+			}))
+			Expect(dataplane.Chains).To(HaveKey("cali-a1"))
+		})
+
+		It("should replace an owner's fragment", func() {
+			table.UpdateChainFragment("cali-shared", "owner-a", 0, []Rule{
+				{Action: JumpAction{Target: "cali-a2"}},
+			})
+			table.Apply()
+			Expect(sharedChainActions()).To(Equal([]string{
+				"--jump cali-a2",
+				"--jump cali-b",
+				"--jump cali-c",
+			}))
+			Expect(dataplane.Chains).NotTo(HaveKey("cali-a1"))
+		})
+
+		It("should only remove the chain once all its fragments are removed", func() {
+			table.RemoveChainFragment("cali-shared", "owner-a")
+			table.RemoveChainFragment("cali-shared", "owner-b")
+			table.RemoveChainFragment("cali-shared", "unknown-owner")
+			table.Apply()
+			Expect(sharedChainActions()).To(Equal([]string{"--jump cali-c"}))
+
+			table.RemoveChainFragment("cali-shared", "owner-c")
+			table.InsertOrAppendRules("FORWARD", nil)
+			table.Apply()
+			Expect(dataplane.Chains).NotTo(HaveKey("cali-shared"))
+
+			// Once all the fragments are gone, the chain can be updated directly again.
+			table.UpdateChain(&Chain{Name: "cali-shared"})
+		})
+
+		It("should panic if the chain is updated or removed directly", func() {
+			Expect(func() { table.UpdateChain(&Chain{Name: "cali-shared"}) }).To(Panic())
+			Expect(func() { table.RemoveChainByName("cali-shared") }).To(Panic())
+		})
+
+		It("should panic if a directly-updated chain is given fragments", func() {
+			Expect(func() {
+				table.UpdateChainFragment("cali-b", "owner-a", 0, nil)
+			}).To(Panic())
+		})
+	})
+
 	It("should police the insert mode", func() {
 		Expect(func() {
 			NewTable(
@@ -1041,6 +1272,16 @@ func describePostUpdateCheckTests(enableRefresh bool, dataplaneMode string) {
 					// Now waiting for the next refresh interval.
 					It("should request correct delay", assertDelayMillis(30000))
 				})
+				Describe("after shortening the refresh interval and advancing time 60s", func() {
+					BeforeEach(func() {
+						table.SetRefreshInterval(10 * time.Second)
+						resetAndAdvance(60 * time.Second)()
+					})
+					It("should recheck", assertRecheck)
+
+					// Now waiting for the new refresh interval.
+					It("should request correct delay", assertDelayMillis(10000))
+				})
 				Describe("after advancing time by an hour", func() {
 					BeforeEach(resetAndAdvance(time.Hour))
 					It("should recheck", assertRecheck)
@@ -1214,6 +1455,85 @@ func describeDirtyDataplaneTests(appendMode bool, dataplaneMode string) {
 		}))
 	})
 
+	Describe("with insert groups", func() {
+		commentRule := func(comment string) Rule {
+			return Rule{Action: AcceptAction{}, Comment: []string{comment}}
+		}
+		// forwardComments returns the comments of the FORWARD chain's rules, using the
+		// rule itself for non-Calico rules.
+		forwardComments := func() (comments []string) {
+			commentRegexp := regexp.MustCompile(`--comment "([^"]*)" --jump`)
+			for _, r := range dataplane.Chains["FORWARD"] {
+				if m := commentRegexp.FindStringSubmatch(r); m != nil {
+					comments = append(comments, m[1])
+				} else {
+					comments = append(comments, r)
+				}
+			}
+			return
+		}
+
+		BeforeEach(func() {
+			table.UpdateInsertGroup("FORWARD", "mesh-b", InsertAtDefaultPosition, 10, []Rule{commentRule("b insert")})
+			table.UpdateInsertGroup("FORWARD", "mesh-a", InsertAtDefaultPosition, 10, []Rule{commentRule("a insert")})
+			table.UpdateInsertGroup("FORWARD", "late", InsertAtEnd, 5, []Rule{commentRule("end rule")})
+			table.InsertOrAppendRules("FORWARD", []Rule{commentRule("legacy insert")})
+			table.AppendRules("FORWARD", []Rule{commentRule("legacy append")})
+			table.Apply()
+		})
+
+		It("should order the groups by position, priority and name", func() {
+			if appendMode {
+				Expect(forwardComments()).To(Equal([]string{
+					"--jump RETURN", "--jump ACCEPT", "--jump foo-bar",
+					"legacy insert", "a insert", "b insert",
+					"legacy append", "end rule",
+				}))
+			} else {
+				Expect(forwardComments()).To(Equal([]string{
+					"legacy insert", "a insert", "b insert",
+					"--jump RETURN", "--jump ACCEPT", "--jump foo-bar",
+					"legacy append", "end rule",
+				}))
+			}
+		})
+
+		It("should reject empty and reserved group names", func() {
+			for _, name := range []string{"", "*inserts*", "*appends*"} {
+				Expect(func() {
+					table.UpdateInsertGroup("FORWARD", name, InsertAtEnd, 0, nil)
+				}).To(Panic(), name)
+				Expect(func() {
+					table.RemoveInsertGroup("FORWARD", name)
+				}).To(Panic(), name)
+			}
+		})
+
+		Describe("after removing groups", func() {
+			BeforeEach(func() {
+				table.RemoveInsertGroup("FORWARD", "mesh-a")
+				table.RemoveInsertGroup("FORWARD", "late")
+				table.RemoveInsertGroup("FORWARD", "unknown")
+				table.Apply()
+			})
+
+			It("should remove only their rules", func() {
+				if appendMode {
+					Expect(forwardComments()).To(Equal([]string{
+						"--jump RETURN", "--jump ACCEPT", "--jump foo-bar",
+						"legacy insert", "b insert", "legacy append",
+					}))
+				} else {
+					Expect(forwardComments()).To(Equal([]string{
+						"legacy insert", "b insert",
+						"--jump RETURN", "--jump ACCEPT", "--jump foo-bar",
+						"legacy append",
+					}))
+				}
+			})
+		})
+	})
+
 	Describe("with pre-cleanup inserts, appends and updates", func() {
 		// These tests inject some chains and insertions before the first call to Apply().
 		// That should mean that the Table does a sync operation, avoiding updates to
@@ -1679,3 +1999,603 @@ func lookPathNoLegacy(p string) (string, error) {
 func lookPathAll(p string) (string, error) {
 	return p, nil
 }
+
+var _ = Describe("Table embedded with non-Calico prefixes", func() {
+	var dataplane *mockDataplane
+	var table *Table
+
+	newTable := func(hashPrefix string, chainPrefixes []string) *Table {
+		featureDetector := NewFeatureDetector(nil)
+		featureDetector.NewCmd = dataplane.newCmd
+		featureDetector.GetKernelVersionReader = dataplane.getKernelVersionReader
+		return NewTable(
+			"filter",
+			4,
+			hashPrefix,
+			&mockMutex{},
+			featureDetector,
+			TableOptions{
+				HistoricChainPrefixes: chainPrefixes,
+				NewCmdOverride:        dataplane.newCmd,
+				SleepOverride:         dataplane.sleep,
+				NowOverride:           dataplane.now,
+				BackendMode:           "legacy",
+				LookPathOverride:      lookPathNoLegacy,
+				// No OpRecorder, it is optional.
+			},
+		)
+	}
+
+	BeforeEach(func() {
+		dataplane = newMockDataplane("filter", map[string][]string{
+			"FORWARD": {
+				"-m comment --comment \"cali:hecdSCslEjdBPBPo\" --jump cali-FORWARD",
+				"-m comment --comment \"acme.v1:hecdSCslEjdBPBPo\" --jump acme.v1-old",
+			},
+			"INPUT":        {},
+			"OUTPUT":       {},
+			"cali-FORWARD": {"-m comment --comment \"cali:42h7Q64_2XDzpwKe\" --jump ACCEPT"},
+			"acme.v1-old":  {"-m comment --comment \"acme.v1:42h7Q64_2XDzpwKe\" --jump ACCEPT"},
+			"acmeXv1-keep": {"--jump ACCEPT"},
+		}, "legacy")
+		table = newTable("acme.v1:", []string{"acme.v1-"})
+	})
+
+	It("should only clean up its own rules and chains", func() {
+		table.InsertOrAppendRules("FORWARD", []Rule{
+			{Action: JumpAction{Target: "acme.v1-fwd"}},
+		})
+		table.UpdateChain(&Chain{
+			Name:  "acme.v1-fwd",
+			Rules: []Rule{{Action: AcceptAction{}}},
+		})
+		table.Apply()
+
+		Expect(dataplane.Chains).To(Equal(map[string][]string{
+			"FORWARD": {
+				"-m comment --comment \"acme.v1:WPjzokCDg2jtbtBF\" --jump acme.v1-fwd",
+				"-m comment --comment \"cali:hecdSCslEjdBPBPo\" --jump cali-FORWARD",
+			},
+			"INPUT":        {},
+			"OUTPUT":       {},
+			"cali-FORWARD": {"-m comment --comment \"cali:42h7Q64_2XDzpwKe\" --jump ACCEPT"},
+			"acmeXv1-keep": {"--jump ACCEPT"},
+			"acme.v1-fwd":  {"-m comment --comment \"acme.v1:TBZGYVEXxlmx6hZZ\" --jump ACCEPT"},
+		}))
+	})
+
+	It("should refuse empty prefixes", func() {
+		Expect(func() { newTable("", []string{"acme-"}) }).To(Panic())
+		Expect(func() { newTable("acme:", nil) }).To(Panic())
+		Expect(func() { newTable("acme:", []string{"acme-", ""}) }).To(Panic())
+	})
+})
+
+var _ = Describe("Table with MaxChainsPerRestore (legacy)", func() {
+	describeBatchedRestoreTests("legacy")
+})
+var _ = Describe("Table with MaxChainsPerRestore (nft)", func() {
+	describeBatchedRestoreTests("nft")
+})
+
+func describeBatchedRestoreTests(dataplaneMode string) {
+	var dataplane *mockDataplane
+	var table *Table
+
+	BeforeEach(func() {
+		dataplane = newMockDataplane("filter", map[string][]string{
+			"FORWARD": {},
+			"INPUT":   {},
+			"OUTPUT":  {},
+		}, dataplaneMode)
+		featureDetector := NewFeatureDetector(nil)
+		featureDetector.NewCmd = dataplane.newCmd
+		featureDetector.GetKernelVersionReader = dataplane.getKernelVersionReader
+		table = NewTable(
+			"filter",
+			4,
+			rules.RuleHashPrefix,
+			&mockMutex{},
+			featureDetector,
+			TableOptions{
+				HistoricChainPrefixes: rules.AllHistoricChainNamePrefixes,
+				NewCmdOverride:        dataplane.newCmd,
+				SleepOverride:         dataplane.sleep,
+				NowOverride:           dataplane.now,
+				BackendMode:           dataplaneMode,
+				LookPathOverride:      lookPathNoLegacy,
+				OpRecorder:            logutils.NewSummarizer("test loop"),
+				MaxChainsPerRestore:   4,
+			},
+		)
+
+		// 10 endpoint chains, each of which jumps to its own policy chain, so 20 chains in all.
+		var forwardRules []Rule
+		for i := 0; i < 10; i++ {
+			epChain := fmt.Sprintf("cali-ep-%d", i)
+			polChain := fmt.Sprintf("cali-pol-%d", i)
+			table.UpdateChain(&Chain{
+				Name:  polChain,
+				Rules: []Rule{{Action: AcceptAction{}}},
+			})
+			table.UpdateChain(&Chain{
+				Name:  epChain,
+				Rules: []Rule{{Action: JumpAction{Target: polChain}}, {Action: DropAction{}}},
+			})
+			forwardRules = append(forwardRules, Rule{Action: JumpAction{Target: epChain}})
+		}
+		table.InsertOrAppendRules("FORWARD", forwardRules)
+	})
+
+	restoreInputs := func() []string {
+		var inputs []string
+		for _, cmd := range dataplane.Cmds {
+			if rc, ok := cmd.(*restoreCmd); ok {
+				inputs = append(inputs, rc.CapturedStdin)
+			}
+		}
+		return inputs
+	}
+
+	checkFinalState := func() {
+		Expect(dataplane.Chains).To(HaveLen(23))
+		Expect(dataplane.Chains["FORWARD"]).To(HaveLen(10))
+		for i := 0; i < 10; i++ {
+			Expect(dataplane.Chains[fmt.Sprintf("cali-ep-%d", i)]).To(HaveLen(2))
+			Expect(dataplane.Chains[fmt.Sprintf("cali-pol-%d", i)]).To(HaveLen(1))
+		}
+	}
+
+	It("should apply the chains in batches", func() {
+		table.Apply()
+		checkFinalState()
+		// 16 chains in 4 batches, then the last 4 chains and the FORWARD rules together.
+		Expect(restoreInputs()).To(HaveLen(5))
+	})
+
+	It("should create chains before the chains that refer to them", func() {
+		table.Apply()
+		created := set.New()
+		for _, input := range restoreInputs() {
+			for _, line := range strings.Split(input, "\n") {
+				if strings.HasPrefix(line, ":") {
+					created.Add(strings.Split(line[1:], " ")[0])
+				}
+			}
+			for _, line := range strings.Split(input, "\n") {
+				parts := strings.Split(line, " ")
+				if len(parts) >= 2 && parts[len(parts)-2] == "--jump" && strings.HasPrefix(parts[len(parts)-1], "cali-") {
+					Expect(created.Contains(parts[len(parts)-1])).To(BeTrue(),
+						"Jump to chain that wasn't created yet: "+line)
+				}
+			}
+		}
+	})
+
+	It("should keep the batches that succeeded when a later batch fails", func() {
+		dataplane.FailRestoreNum = 2
+		table.Apply()
+		checkFinalState()
+		inputs := restoreInputs()
+		// The first batch was applied once, the failed batch is retried.
+		Expect(inputs).To(HaveLen(6))
+		Expect(inputs[0]).To(ContainSubstring("cali-pol-0"))
+		for _, input := range inputs[1:] {
+			Expect(input).NotTo(ContainSubstring("cali-pol-0"))
+		}
+	})
+}
+
+var _ = Describe("Table with RestoreDeadline (legacy)", func() {
+	describeRestoreDeadlineTests("legacy")
+})
+var _ = Describe("Table with RestoreDeadline (nft)", func() {
+	describeRestoreDeadlineTests("nft")
+})
+
+func describeRestoreDeadlineTests(dataplaneMode string) {
+	var dataplane *mockDataplane
+	var table *Table
+
+	BeforeEach(func() {
+		dataplane = newMockDataplane("filter", map[string][]string{
+			"FORWARD": {},
+			"INPUT":   {},
+			"OUTPUT":  {},
+		}, dataplaneMode)
+		featureDetector := NewFeatureDetector(nil)
+		featureDetector.NewCmd = dataplane.newCmd
+		featureDetector.GetKernelVersionReader = dataplane.getKernelVersionReader
+		table = NewTable(
+			"filter",
+			4,
+			rules.RuleHashPrefix,
+			&mockMutex{},
+			featureDetector,
+			TableOptions{
+				HistoricChainPrefixes: rules.AllHistoricChainNamePrefixes,
+				NewCmdOverride:        dataplane.newCmd,
+				SleepOverride:         dataplane.sleep,
+				NowOverride:           dataplane.now,
+				BackendMode:           dataplaneMode,
+				LookPathOverride:      lookPathNoLegacy,
+				OpRecorder:            logutils.NewSummarizer("test loop"),
+				RestoreDeadline:       10 * time.Millisecond,
+			},
+		)
+
+		var forwardRules []Rule
+		for i := 0; i < 10; i++ {
+			polChain := fmt.Sprintf("cali-pol-%d", i)
+			table.UpdateChain(&Chain{
+				Name:  polChain,
+				Rules: []Rule{{Action: AcceptAction{}}, {Action: DropAction{}}},
+			})
+			forwardRules = append(forwardRules, Rule{Action: JumpAction{Target: polChain}})
+		}
+		table.InsertOrAppendRules("FORWARD", forwardRules)
+	})
+
+	restoreInputs := func() []string {
+		var inputs []string
+		for _, cmd := range dataplane.Cmds {
+			if rc, ok := cmd.(*restoreCmd); ok {
+				inputs = append(inputs, rc.CapturedStdin)
+			}
+		}
+		return inputs
+	}
+
+	It("should apply the update in one transaction if it's quick enough", func() {
+		table.Apply()
+		Expect(dataplane.Chains).To(HaveLen(13))
+		Expect(restoreInputs()).To(HaveLen(1))
+	})
+
+	It("should retry in smaller transactions after exceeding the deadline", func() {
+		deadlinesBefore := counterValue("felix_iptables_restore_deadlines_exceeded", "filter")
+		chunkedBefore := counterValue("felix_iptables_chunked_applies", "filter")
+		dataplane.HangRestoresLongerThan = 25
+
+		table.Apply()
+		Expect(dataplane.Chains).To(HaveLen(13))
+		Expect(dataplane.Chains["FORWARD"]).To(HaveLen(10))
+		for i := 0; i < 10; i++ {
+			Expect(dataplane.Chains[fmt.Sprintf("cali-pol-%d", i)]).To(HaveLen(2))
+		}
+		Expect(len(restoreInputs())).To(BeNumerically(">", 2))
+		Expect(counterValue("felix_iptables_restore_deadlines_exceeded", "filter")).To(
+			BeNumerically(">", deadlinesBefore))
+		Expect(counterValue("felix_iptables_chunked_applies", "filter")).To(Equal(chunkedBefore + 1))
+
+		// Once the update has gone through, we go back to using a single transaction.
+		dataplane.ResetCmds()
+		for i := 0; i < 10; i++ {
+			table.UpdateChain(&Chain{
+				Name:  fmt.Sprintf("cali-pol-%d", i),
+				Rules: []Rule{{Action: DropAction{}}},
+			})
+		}
+		dataplane.HangRestoresLongerThan = 0
+		table.Apply()
+		Expect(restoreInputs()).To(HaveLen(1))
+	})
+}
+
+// counterValue returns the current value of the IPv4 table's counter with the given name.
+func counterValue(name, table string) float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	Expect(err).NotTo(HaveOccurred())
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			labels := map[string]string{}
+			for _, l := range m.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+			if labels["ip_version"] == "4" && labels["table"] == table {
+				return m.GetCounter().GetValue()
+			}
+		}
+	}
+	return 0
+}
+
+var _ = Describe("Table with OnRuleCounters", func() {
+	var dataplane *mockDataplane
+	var table *Table
+	var reported []map[string]map[string]RuleCounts
+
+	BeforeEach(func() {
+		reported = nil
+		dataplane = newMockDataplane("filter", map[string][]string{
+			"FORWARD": {},
+			"INPUT":   {},
+			"OUTPUT":  {},
+		}, "legacy")
+		featureDetector := NewFeatureDetector(nil)
+		featureDetector.NewCmd = dataplane.newCmd
+		featureDetector.GetKernelVersionReader = dataplane.getKernelVersionReader
+		table = NewTable(
+			"filter",
+			4,
+			rules.RuleHashPrefix,
+			&mockMutex{},
+			featureDetector,
+			TableOptions{
+				HistoricChainPrefixes: rules.AllHistoricChainNamePrefixes,
+				NewCmdOverride:        dataplane.newCmd,
+				SleepOverride:         dataplane.sleep,
+				NowOverride:           dataplane.now,
+				BackendMode:           "legacy",
+				LookPathOverride:      lookPathNoLegacy,
+				OpRecorder:            logutils.NewSummarizer("test loop"),
+				OnRuleCounters: func(counts map[string]map[string]RuleCounts) {
+					reported = append(reported, counts)
+				},
+				RuleCountersInterval: 10 * time.Second,
+			},
+		)
+		table.InsertOrAppendRules("FORWARD", []Rule{{Action: JumpAction{Target: "cali-pi-foo"}}})
+		table.UpdateChain(&Chain{Name: "cali-pi-foo", Rules: []Rule{
+			{Action: DropAction{}, Comment: []string{"policy-rule=0"}},
+			{Action: SetMarkAction{Mark: 0x10}},
+			{Action: ReturnAction{}, Comment: []string{"policy-rule=1", "owner=alice"}},
+		}})
+		dataplane.PacketCounts = map[string][]uint64{
+			"cali-pi-foo": {3, 7, 7},
+		}
+	})
+
+	It("should report the counts of all chains after Apply, at most once per interval", func() {
+		table.Apply()
+		Expect(reported).To(Equal([]map[string]map[string]RuleCounts{{
+			"cali-pi-foo": {
+				"policy-rule=0": {Packets: 3, Bytes: 180},
+				"policy-rule=1": {Packets: 7, Bytes: 420},
+			},
+		}}))
+
+		dataplane.AdvanceTimeBy(5 * time.Second)
+		table.Apply()
+		Expect(reported).To(HaveLen(1))
+
+		dataplane.AdvanceTimeBy(5 * time.Second)
+		dataplane.PacketCounts["cali-pi-foo"][0] = 4
+		table.Apply()
+		Expect(reported).To(HaveLen(2))
+		Expect(reported[1]["cali-pi-foo"]["policy-rule=0"]).To(Equal(RuleCounts{Packets: 4, Bytes: 240}))
+	})
+
+	It("should return an error if it fails to read the rule counters", func() {
+		dataplane.FailAllSaves = true
+		_, err := table.ReadAllRuleCounters()
+		Expect(err).To(HaveOccurred())
+	})
+})
+
+var _ = Describe("Table quarantining rejected rules (legacy)", func() {
+	describeQuarantineTests("legacy")
+})
+var _ = Describe("Table quarantining rejected rules (nft)", func() {
+	describeQuarantineTests("nft")
+})
+
+func describeQuarantineTests(dataplaneMode string) {
+	var dataplane *mockDataplane
+	var table *Table
+
+	goodPolicy := &Chain{
+		Name:  "cali-pol-good",
+		Rules: []Rule{{Match: Match().Protocol("tcp"), Action: AcceptAction{}}},
+	}
+	badPolicy := &Chain{
+		Name: "cali-pol-bad",
+		Rules: []Rule{
+			{Match: Match().Protocol("tcp"), Action: AcceptAction{}},
+			{Match: Match().Protocol("udp"), Action: AcceptAction{}},
+			{Match: Match().Protocol("sctp"), Action: AcceptAction{}, Comment: []string{"Policy default/bad"}},
+			{Action: DropAction{}},
+		},
+	}
+	fixedPolicy := &Chain{
+		Name:  "cali-pol-bad",
+		Rules: []Rule{{Match: Match().Protocol("udp"), Action: AcceptAction{}}},
+	}
+
+	BeforeEach(func() {
+		dataplane = newMockDataplane("filter", map[string][]string{
+			"FORWARD": {},
+			"INPUT":   {},
+			"OUTPUT":  {},
+		}, dataplaneMode)
+		featureDetector := NewFeatureDetector(nil)
+		featureDetector.NewCmd = dataplane.newCmd
+		featureDetector.GetKernelVersionReader = dataplane.getKernelVersionReader
+		table = NewTable(
+			"filter",
+			4,
+			rules.RuleHashPrefix,
+			&mockMutex{},
+			featureDetector,
+			TableOptions{
+				HistoricChainPrefixes: rules.AllHistoricChainNamePrefixes,
+				NewCmdOverride:        dataplane.newCmd,
+				SleepOverride:         dataplane.sleep,
+				NowOverride:           dataplane.now,
+				BackendMode:           dataplaneMode,
+				LookPathOverride:      lookPathNoLegacy,
+				OpRecorder:            logutils.NewSummarizer("test loop"),
+			},
+		)
+		table.InsertOrAppendRules("FORWARD", []Rule{{Action: JumpAction{Target: "cali-FORWARD"}}})
+		table.UpdateChain(&Chain{
+			Name: "cali-FORWARD",
+			Rules: []Rule{
+				{Action: JumpAction{Target: "cali-pol-good"}},
+				{Action: JumpAction{Target: "cali-pol-bad"}},
+			},
+		})
+		table.UpdateChain(goodPolicy)
+		dataplane.RejectRulesContaining = "sctp"
+	})
+
+	Describe("with a new chain that has a rejected rule", func() {
+		BeforeEach(func() {
+			table.UpdateChain(badPolicy)
+			table.Apply()
+		})
+
+		It("should quarantine the chain and report the rejected rule", func() {
+			quarantined := table.QuarantinedChains()
+			Expect(quarantined).To(HaveLen(1))
+			Expect(quarantined[0].Table).To(Equal("filter"))
+			Expect(quarantined[0].Chain).To(Equal("cali-pol-bad"))
+			Expect(quarantined[0].RuleNum).To(Equal(3))
+			Expect(quarantined[0].Rule).To(ContainSubstring("sctp"))
+			Expect(quarantined[0].Comment).To(Equal([]string{"Policy default/bad"}))
+			Expect(quarantined[0].ErrorOutput).To(ContainSubstring("line failed"))
+			Expect(dataplane.NumTestRestores).To(BeNumerically(">", 0))
+		})
+
+		It("should program the rest of the table and create the quarantined chain with a DROP rule", func() {
+			Expect(dataplane.Chains["cali-FORWARD"]).To(HaveLen(2))
+			Expect(dataplane.Chains["cali-pol-good"]).To(HaveLen(1))
+			expectQuarantineDrop(dataplane.Chains["cali-pol-bad"], 3)
+		})
+
+		It("should keep the chain quarantined on later applies", func() {
+			dataplane.ResetCmds()
+			table.InvalidateDataplaneCache("test")
+			table.Apply()
+			Expect(table.QuarantinedChains()).To(HaveLen(1))
+			expectQuarantineDrop(dataplane.Chains["cali-pol-bad"], 3)
+		})
+
+		It("should keep the chain quarantined when it is updated with the same rules", func() {
+			table.UpdateChain(&Chain{Name: badPolicy.Name, Rules: badPolicy.Rules})
+			Expect(table.QuarantinedChains()).To(HaveLen(1))
+			numTestRestores := dataplane.NumTestRestores
+			table.Apply()
+			Expect(dataplane.NumTestRestores).To(Equal(numTestRestores))
+			expectQuarantineDrop(dataplane.Chains["cali-pol-bad"], 3)
+		})
+
+		It("should lift the quarantine when the chain is fixed", func() {
+			table.UpdateChain(fixedPolicy)
+			Expect(table.QuarantinedChains()).To(BeEmpty())
+			table.Apply()
+			Expect(table.QuarantinedChains()).To(BeEmpty())
+			Expect(dataplane.Chains["cali-pol-bad"]).To(HaveLen(1))
+		})
+	})
+
+	Describe("with a programmed chain that is updated with a rejected rule", func() {
+		BeforeEach(func() {
+			table.UpdateChain(fixedPolicy)
+			table.Apply()
+			table.UpdateChain(badPolicy)
+			table.Apply()
+		})
+
+		It("should leave the previous version of the chain in place", func() {
+			Expect(table.QuarantinedChains()).To(HaveLen(1))
+			Expect(dataplane.Chains["cali-pol-bad"]).To(HaveLen(1))
+			Expect(dataplane.Chains["cali-pol-bad"][0]).To(ContainSubstring("udp"))
+		})
+	})
+
+	Describe("with a new workload endpoint chain that has a rejected rule", func() {
+		BeforeEach(func() {
+			table.UpdateChain(&Chain{
+				Name: "cali-FORWARD",
+				Rules: []Rule{
+					{Action: JumpAction{Target: "cali-pol-good"}},
+					{Action: JumpAction{Target: "cali-tw-cali1234"}},
+				},
+			})
+			table.UpdateChain(&Chain{
+				Name: "cali-tw-cali1234",
+				Rules: []Rule{
+					{Match: Match().Protocol("sctp"), Action: AcceptAction{}},
+					{Action: JumpAction{Target: "cali-pol-good"}},
+				},
+			})
+			table.Apply()
+		})
+
+		It("should drop traffic rather than letting it through", func() {
+			Expect(table.QuarantinedChains()).To(HaveLen(1))
+			expectQuarantineDrop(dataplane.Chains["cali-tw-cali1234"], 1)
+		})
+	})
+}
+
+func expectQuarantineDrop(chain []string, ruleNum int) {
+	ExpectWithOffset(1, chain).To(HaveLen(1))
+	ExpectWithOffset(1, chain[0]).To(HaveSuffix("--jump DROP"))
+	ExpectWithOffset(1, chain[0]).To(ContainSubstring(fmt.Sprintf(
+		`"Quarantined: rule %d of this chain was rejected"`, ruleNum)))
+}
+
+var _ = Describe("Table in dry-run mode", func() {
+	var dataplane *mockDataplane
+	var table *Table
+	var reported []string
+
+	BeforeEach(func() {
+		reported = nil
+		dataplane = newMockDataplane("filter", map[string][]string{
+			"FORWARD": {},
+			"INPUT":   {},
+			"OUTPUT":  {},
+		}, "legacy")
+		featureDetector := NewFeatureDetector(nil)
+		featureDetector.NewCmd = dataplane.newCmd
+		featureDetector.GetKernelVersionReader = dataplane.getKernelVersionReader
+		table = NewTable(
+			"filter",
+			4,
+			rules.RuleHashPrefix,
+			&mockMutex{},
+			featureDetector,
+			TableOptions{
+				HistoricChainPrefixes: rules.AllHistoricChainNamePrefixes,
+				NewCmdOverride:        dataplane.newCmd,
+				SleepOverride:         dataplane.sleep,
+				NowOverride:           dataplane.now,
+				BackendMode:           "legacy",
+				LookPathOverride:      lookPathNoLegacy,
+				OpRecorder:            logutils.NewSummarizer("test loop"),
+				DryRun: func(restoreInput []byte) {
+					reported = append(reported, string(restoreInput))
+				},
+			},
+		)
+		table.InsertOrAppendRules("FORWARD", []Rule{{Action: JumpAction{Target: "cali-foo"}}})
+		table.UpdateChain(&Chain{Name: "cali-foo", Rules: []Rule{{Action: DropAction{}}}})
+	})
+
+	It("should report the update instead of applying it", func() {
+		table.Apply()
+		Expect(reported).To(HaveLen(1))
+		Expect(reported[0]).To(ContainSubstring(":cali-foo - -"))
+		Expect(reported[0]).To(ContainSubstring("-A cali-foo"))
+		Expect(reported[0]).To(ContainSubstring("-I FORWARD"))
+		Expect(dataplane.Chains).To(Equal(map[string][]string{
+			"FORWARD": {},
+			"INPUT":   {},
+			"OUTPUT":  {},
+		}))
+	})
+
+	It("should report the update again after a refresh finds that the kernel still differs", func() {
+		table.Apply()
+		table.InvalidateDataplaneCache("test")
+		table.Apply()
+		Expect(reported).To(HaveLen(2))
+		Expect(reported[1]).To(ContainSubstring("-A cali-foo"))
+	})
+})
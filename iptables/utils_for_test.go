@@ -79,6 +79,22 @@ type mockDataplane struct {
 	Version                        string
 	KernelVersion                  string
 	NftablesMode                   bool
+	// RestoreArgs, if set, are the arguments that iptables-restore is expected to be called with.
+	RestoreArgs []string
+	// PacketCounts holds the packet counts that iptables-save -c reports for each chain's rules.
+	PacketCounts map[string][]uint64
+	// FailRestoreNum, if non-zero, is the number (counting from 1) of the iptables-restore call
+	// that should fail.
+	FailRestoreNum int
+	numRestores    int
+	// RejectRulesContaining, if non-empty, makes iptables-restore reject any input that has a line
+	// containing this string, as the real iptables-restore does for rules the kernel won't accept.
+	RejectRulesContaining string
+	// NumTestRestores counts the iptables-restore --test calls, which check the input but don't apply it.
+	NumTestRestores int
+	// HangRestoresLongerThan, if non-zero, makes iptables-restore hang, until it is killed, if its
+	// input has more than this many lines.
+	HangRestoresLongerThan int
 }
 
 func (d *mockDataplane) ResetCmds() {
@@ -111,16 +127,30 @@ func (d *mockDataplane) newCmd(name string, arg ...string) CmdIface {
 	case "iptables-restore", "ip6tables-restore",
 		"iptables-legacy-restore", "ip6tables-legacy-restore",
 		"iptables-nft-restore", "ip6tables-nft-restore":
-		Expect(arg).To(Equal([]string{"--noflush", "--verbose"}))
+		expectedArgs := d.RestoreArgs
+		if expectedArgs == nil {
+			expectedArgs = []string{"--noflush", "--verbose"}
+		}
+		testOnly := len(arg) > 0 && arg[len(arg)-1] == "--test"
+		if testOnly {
+			arg = arg[:len(arg)-1]
+		}
+		Expect(arg).To(Equal(expectedArgs))
 		cmd = &restoreCmd{
 			Dataplane: d,
+			TestOnly:  testOnly,
 		}
 	case "iptables-save", "ip6tables-save",
 		"iptables-legacy-save", "ip6tables-legacy-save",
 		"iptables-nft-save", "ip6tables-nft-save":
+		withCounters := len(arg) > 0 && arg[0] == "-c"
+		if withCounters {
+			arg = arg[1:]
+		}
 		Expect(arg).To(Equal([]string{"-t", d.Table}))
 		cmd = &saveCmd{
-			Dataplane: d,
+			Dataplane:    d,
+			withCounters: withCounters,
 		}
 	case "iptables":
 		Expect(arg).To(Equal([]string{"--version"}))
@@ -171,10 +201,15 @@ func (d *mockDataplane) RuleTouched(chainName string, ruleNum int) bool {
 
 type restoreCmd struct {
 	Dataplane     *mockDataplane
+	TestOnly      bool
 	Stdin         io.Reader
 	CapturedStdin string
 	Stdout        io.Writer
 	Stderr        io.Writer
+
+	// For Start/Wait/Kill.
+	result error
+	killed chan struct{}
 }
 
 func (d *restoreCmd) SetStdin(r io.Reader) {
@@ -208,16 +243,28 @@ func (d *restoreCmd) StdoutPipe() (io.ReadCloser, error) {
 }
 
 func (d *restoreCmd) Start() error {
-	Fail("Not implemented")
-	return errors.New("Not implemented")
+	if d.Dataplane.HangRestoresLongerThan > 0 &&
+		strings.Count(d.CapturedStdin, "\n") > d.Dataplane.HangRestoresLongerThan {
+		log.Warn("Simulating iptables-restore hanging")
+		d.killed = make(chan struct{})
+		return nil
+	}
+	d.result = d.Run()
+	return nil
 }
 
 func (d *restoreCmd) Wait() error {
-	Fail("Not implemented")
-	return errors.New("Not implemented")
+	if d.killed != nil {
+		<-d.killed
+		return errors.New("signal: killed")
+	}
+	return d.result
 }
 
 func (d *restoreCmd) Kill() error {
+	if d.killed != nil {
+		close(d.killed)
+	}
 	return nil
 }
 
@@ -233,6 +280,22 @@ func (d *restoreCmd) Run() error {
 	Expect(err).NotTo(HaveOccurred())
 	input := buf.String()
 
+	if d.Dataplane.RejectRulesContaining != "" {
+		for _, line := range strings.Split(input, "\n") {
+			if strings.Contains(line, d.Dataplane.RejectRulesContaining) {
+				log.WithField("line", line).Warn("Simulating iptables-restore rejecting a rule")
+				if d.Stderr != nil {
+					_, _ = fmt.Fprintf(d.Stderr, "iptables-restore: line failed: %s\n", line)
+				}
+				return errors.New("Simulated rejection")
+			}
+		}
+	}
+	if d.TestOnly {
+		d.Dataplane.NumTestRestores++
+		return nil
+	}
+
 	if d.Dataplane.OnPreRestore != nil {
 		log.Warn("OnPreRestore set, calling it")
 		d.Dataplane.OnPreRestore()
@@ -243,6 +306,11 @@ func (d *restoreCmd) Run() error {
 		d.Dataplane.FailNextRestore = false
 		return errors.New("Simulated failure")
 	}
+	d.Dataplane.numRestores++
+	if d.Dataplane.numRestores == d.Dataplane.FailRestoreNum {
+		log.Warn("Simulating an iptables-restore failure")
+		return errors.New("Simulated failure")
+	}
 	if d.Dataplane.FailAllRestores {
 		log.Warn("Simulating an iptables-restore failure")
 		return errors.New("Simulated failure")
@@ -408,8 +476,9 @@ func prependLine(src []string, line string) []string {
 }
 
 type saveCmd struct {
-	Dataplane  *mockDataplane
-	stdoutPipe *closableBuffer
+	Dataplane    *mockDataplane
+	stdoutPipe   *closableBuffer
+	withCounters bool
 }
 
 func (d *saveCmd) String() string {
@@ -468,7 +537,14 @@ func (d *saveCmd) Output() ([]byte, error) {
 	}
 
 	for chainName, chain := range d.Dataplane.Chains {
-		for _, rule := range chain {
+		for i, rule := range chain {
+			if d.withCounters {
+				var packets uint64
+				if i < len(d.Dataplane.PacketCounts[chainName]) {
+					packets = d.Dataplane.PacketCounts[chainName][i]
+				}
+				buf.WriteString(fmt.Sprintf("[%d:%d] ", packets, packets*60))
+			}
 			buf.WriteString(fmt.Sprintf("-A %s %s\n", chainName, rule))
 		}
 	}
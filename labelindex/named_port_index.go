@@ -19,6 +19,7 @@ import (
 
 	"reflect"
 
+	"strconv"
 	"strings"
 
 	"fmt"
@@ -88,6 +89,10 @@ func (p IPSetPortProtocol) MatchesModelProtocol(protocol numorstring.Protocol) b
 		}
 		return protocol.NumVal == uint8(p)
 	}
+	if num, err := strconv.ParseUint(protocol.StrVal, 10, 8); err == nil {
+		// Numeric protocol that was passed to us as a string.
+		return uint8(num) == uint8(p)
+	}
 	switch p {
 	case ProtocolTCP:
 		return strings.ToLower(protocol.StrVal) == "tcp"
@@ -18,10 +18,12 @@ import (
 	. "github.com/projectcalico/felix/labelindex"
 
 	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
 	. "github.com/onsi/gomega"
 
 	"net"
 
+	"github.com/projectcalico/api/pkg/lib/numorstring"
 	"github.com/projectcalico/libcalico-go/lib/backend/api"
 	"github.com/projectcalico/libcalico-go/lib/backend/model"
 	calinet "github.com/projectcalico/libcalico-go/lib/net"
@@ -111,6 +113,21 @@ var _ = Describe("SelectorAndNamedPortIndex", func() {
 	})
 })
 
+var _ = DescribeTable("IPSetPortProtocol.MatchesModelProtocol",
+	func(p IPSetPortProtocol, protocol numorstring.Protocol, expected bool) {
+		Expect(p.MatchesModelProtocol(protocol)).To(Equal(expected))
+	},
+	Entry("TCP name", ProtocolTCP, numorstring.ProtocolFromString("TCP"), true),
+	Entry("TCP number", ProtocolTCP, numorstring.ProtocolFromInt(6), true),
+	Entry("TCP numeric string", ProtocolTCP, numorstring.Protocol{Type: numorstring.NumOrStringString, StrVal: "6"}, true),
+	Entry("unspecified defaults to TCP", ProtocolTCP, numorstring.ProtocolFromInt(0), true),
+	Entry("UDP vs TCP", ProtocolUDP, numorstring.ProtocolFromString("tcp"), false),
+	Entry("SCTP name", ProtocolSCTP, numorstring.ProtocolFromString("sctp"), true),
+	Entry("SCTP number", ProtocolSCTP, numorstring.ProtocolFromInt(132), true),
+	Entry("SCTP numeric string", ProtocolSCTP, numorstring.Protocol{Type: numorstring.NumOrStringString, StrVal: "132"}, true),
+	Entry("SCTP vs other number", ProtocolSCTP, numorstring.ProtocolFromInt(112), false),
+)
+
 type testRecorder struct {
 	ipsets map[string]map[IPSetMember]bool
 }
@@ -33,7 +33,7 @@ func DumpHeapMemoryProfile(fileName string) {
 	logCxt := log.WithField("file", fileName)
 	logCxt.Info("Asked to create a memory profile.")
 
-	fileName = renderFileName(fileName)
+	fileName = RenderFileName(fileName)
 
 	// Open a file with that name.
 	f, err := os.Create(fileName)
@@ -52,7 +52,7 @@ func DumpHeapMemoryProfile(fileName string) {
 func DumpCPUProfile(fileName string) {
 	logCxt := log.WithField("file", fileName)
 	logCxt.Info("Asked to create a CPU profile.")
-	fileName = renderFileName(fileName)
+	fileName = RenderFileName(fileName)
 
 	// Open a file with that name.
 	f, err := os.Create(fileName)
@@ -73,9 +73,8 @@ func DumpCPUProfile(fileName string) {
 	logCxt.Info("Finished writing CPU profile")
 }
 
-func renderFileName(template string) string {
-	// If the configured file name includes "<timestamp>", replace that with the current
-	// time.
+// RenderFileName replaces "<timestamp>", if present in the given file name, with the current time.
+func RenderFileName(template string) string {
 	if strings.Contains(template, "<timestamp>") {
 		timestamp := time.Now().Format("2006-01-02-15:04:05")
 		return strings.Replace(template, "<timestamp>", timestamp, 1)
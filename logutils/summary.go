@@ -25,6 +25,32 @@ import (
 
 type OpRecorder interface {
 	RecordOperation(name string)
+	// RecordCount adds n to the named count of dataplane objects that the current iteration
+	// changed.  The names are the Count... constants.
+	RecordCount(name string, n int)
+}
+
+// Names of the counts that the dataplane components report to their OpRecorder.  The iptables
+// package defines its own copies of the chain and rule counts, which must match.
+const (
+	CountChainsUpdated       = "chainsUpdated"
+	CountRulesWritten        = "rulesWritten"
+	CountIPSetMembersAdded   = "ipSetMembersAdded"
+	CountIPSetMembersRemoved = "ipSetMembersRemoved"
+	CountRoutesAdded         = "routesAdded"
+	CountRoutesDeleted       = "routesDeleted"
+	CountBPFMapWrites        = "bpfMapWrites"
+)
+
+// AllCounts lists the Count... constants in the order that they should be logged.
+var AllCounts = []string{
+	CountChainsUpdated,
+	CountRulesWritten,
+	CountIPSetMembersAdded,
+	CountIPSetMembersRemoved,
+	CountRoutesAdded,
+	CountRoutesDeleted,
+	CountBPFMapWrites,
 }
 
 type Summarizer struct {
@@ -44,6 +70,7 @@ var _ OpRecorder = (*Summarizer)(nil)
 
 type iteration struct {
 	Operations []string
+	Counts     map[string]int
 	Duration   time.Duration
 }
 
@@ -51,6 +78,16 @@ func (i *iteration) RecordOperation(name string) {
 	i.Operations = append(i.Operations, name)
 }
 
+func (i *iteration) RecordCount(name string, n int) {
+	if n == 0 {
+		return
+	}
+	if i.Counts == nil {
+		i.Counts = map[string]int{}
+	}
+	i.Counts[name] += n
+}
+
 func NewSummarizer(loopName string) *Summarizer {
 	return &Summarizer{
 		currentIteration: &iteration{},
@@ -66,6 +103,26 @@ func (l *Summarizer) RecordOperation(name string) {
 	l.currentIteration.RecordOperation(name)
 }
 
+func (l *Summarizer) RecordCount(name string, n int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.currentIteration.RecordCount(name, n)
+}
+
+// CurrentCounts returns a copy of the counts that have been recorded so far in the current
+// iteration.
+func (l *Summarizer) CurrentCounts() map[string]int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	counts := map[string]int{}
+	for name, n := range l.currentIteration.Counts {
+		counts[name] = n
+	}
+	return counts
+}
+
 // EndOfIteration should be called at the end of the loop, it will trigger logging of noteworthy logs.
 func (l *Summarizer) EndOfIteration(duration time.Duration) {
 	l.lock.Lock()
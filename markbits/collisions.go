@@ -0,0 +1,86 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package markbits
+
+import "fmt"
+
+// Mark is a mark value and mask, along with a description of what uses them.  Software that
+// owns a range of bits (such as Felix) or uses single-bit flags (such as kube-proxy) has a Value
+// equal to its Mask.  Software that uses a particular mark value (such as Istio) has a Mask that
+// covers the whole mark.
+type Mark struct {
+	Owner string
+	Value uint32
+	Mask  uint32
+}
+
+// MaskMark returns the Mark of an owner that uses all the given bits.
+func MaskMark(owner string, bits uint32) Mark {
+	return Mark{Owner: owner, Value: bits, Mask: bits}
+}
+
+// Bits returns the bits that the owner may set.
+func (m Mark) Bits() uint32 {
+	return m.Value & m.Mask
+}
+
+func (m Mark) String() string {
+	if m.Value == m.Mask {
+		return fmt.Sprintf("%s (%#x)", m.Owner, m.Mask)
+	}
+	return fmt.Sprintf("%s (%#x/%#x)", m.Owner, m.Value, m.Mask)
+}
+
+// ThirdPartyMarks are the marks used by other software that commonly runs alongside Felix.  If
+// Felix uses any of their bits, it'll corrupt their marks, or they'll corrupt Felix's.
+var ThirdPartyMarks = []Mark{
+	MaskMark("kube-proxy masquerade mark", 0x4000),
+	MaskMark("kube-proxy drop mark", 0x8000),
+	{Owner: "Istio sidecar mark", Value: 0x539, Mask: 0xffffffff},
+}
+
+// Collision records that two marks share some bits.
+type Collision struct {
+	A, B Mark
+}
+
+// Bits returns the bits that the two marks share.  Only the bits that a mark sets count: Istio,
+// for example, matches its mark value exactly, but the packets it marks only look like Felix's
+// (and Felix's updates only corrupt Istio's mark) in the bits that its value sets.
+func (c Collision) Bits() uint32 {
+	return c.A.Bits() & c.B.Bits()
+}
+
+func (c Collision) String() string {
+	return fmt.Sprintf("%v overlaps with %v in bits %#x", c.A, c.B, c.Bits())
+}
+
+// FindCollisions checks the given marks against each other and against the third-party marks.
+// In each returned Collision, A is one of the given marks.
+func FindCollisions(marks []Mark, thirdParty []Mark) (collisions []Collision) {
+	for i, a := range marks {
+		for _, b := range marks[i+1:] {
+			if a.Bits()&b.Bits() != 0 {
+				collisions = append(collisions, Collision{A: a, B: b})
+			}
+		}
+		for _, b := range thirdParty {
+			if a.Bits()&b.Bits() != 0 {
+				collisions = append(collisions, Collision{A: a, B: b})
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package markbits_test
+
+import (
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/markbits"
+)
+
+var (
+	felixMark   = markbits.MaskMark("felix", 0xffff0000)
+	bpfMark     = markbits.MaskMark("bpf", 0xfff00000)
+	connmark    = markbits.MaskMark("connmark", 0x4000)
+	lowMark     = markbits.MaskMark("low", 0xff)
+	istioValue  = markbits.MaskMark("istio value", 0x539)
+	istioZeros  = markbits.MaskMark("istio zeros", 0x2c6)
+	kubeProxyMk = markbits.ThirdPartyMarks[0]
+	istioMark   = markbits.ThirdPartyMarks[2]
+)
+
+var _ = DescribeTable("Mark collisions",
+	func(marks []markbits.Mark, expected []markbits.Collision) {
+		Expect(markbits.FindCollisions(marks, markbits.ThirdPartyMarks)).To(Equal(expected))
+	},
+
+	Entry("no collisions with the default mask", []markbits.Mark{felixMark}, nil),
+	Entry("configured marks colliding with each other", []markbits.Mark{felixMark, bpfMark},
+		[]markbits.Collision{{A: felixMark, B: bpfMark}}),
+	Entry("configured mark colliding with kube-proxy", []markbits.Mark{felixMark, connmark},
+		[]markbits.Collision{{A: connmark, B: kubeProxyMk}}),
+	Entry("configured mark colliding with Istio", []markbits.Mark{lowMark},
+		[]markbits.Collision{{A: lowMark, B: istioMark}}),
+	Entry("configured mark that can match Istio's value", []markbits.Mark{istioValue},
+		[]markbits.Collision{{A: istioValue, B: istioMark}}),
+	Entry("configured mark that only shares Istio's mask", []markbits.Mark{istioZeros}, nil),
+)
+
+var _ = DescribeTable("Collision bits",
+	func(c markbits.Collision, bits uint32, desc string) {
+		Expect(c.Bits()).To(Equal(bits))
+		Expect(c.String()).To(Equal(desc))
+	},
+
+	Entry("overlapping masks", markbits.Collision{A: felixMark, B: bpfMark}, uint32(0xfff00000),
+		"felix (0xffff0000) overlaps with bpf (0xfff00000) in bits 0xfff00000"),
+	Entry("Istio", markbits.Collision{A: lowMark, B: istioMark}, uint32(0x39),
+		"low (0xff) overlaps with Istio sidecar mark (0x539/0xffffffff) in bits 0x39"),
+	Entry("Istio value match", markbits.Collision{A: istioValue, B: istioMark}, uint32(0x539),
+		"istio value (0x539) overlaps with Istio sidecar mark (0x539/0xffffffff) in bits 0x539"),
+)
@@ -0,0 +1,245 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netlinkshim
+
+import (
+	"math/rand"
+	"sync"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// defaultFaults is the FaultInjector used by NewRealNetlink and NewRealWireguard, if any.
+var defaultFaults *FaultInjector
+
+// EnableFaultInjection makes the handles returned by NewRealNetlink and NewRealWireguard fail a
+// random fraction of their calls; see NewFaultInjector.  It must be called before any handles
+// are created.  For use in tests only.
+func EnableFaultInjection(timeoutRate, busyRate float64) {
+	log.WithFields(log.Fields{
+		"timeoutRate": timeoutRate,
+		"busyRate":    busyRate,
+	}).Warn("Netlink fault injection enabled, netlink calls will fail at random.")
+	defaultFaults = NewFaultInjector(timeoutRate, busyRate, time.Now().UnixNano())
+}
+
+// FaultInjector makes netlink and wireguard calls fail at random so that tests can exercise the
+// retry logic of their callers.  A timeoutRate fraction of calls fails with EAGAIN, which is what
+// netlink returns when the socket times out, and a busyRate fraction fails with EBUSY.  Failed
+// calls have no effect.
+type FaultInjector struct {
+	lock        sync.Mutex
+	rand        *rand.Rand
+	timeoutRate float64
+	busyRate    float64
+}
+
+func NewFaultInjector(timeoutRate, busyRate float64, seed int64) *FaultInjector {
+	return &FaultInjector{
+		rand:        rand.New(rand.NewSource(seed)),
+		timeoutRate: timeoutRate,
+		busyRate:    busyRate,
+	}
+}
+
+// WrapNetlink returns a netlink handle factory that makes handles with newHandle, then injects
+// faults into handle creation and into the calls made on the handles.
+func (f *FaultInjector) WrapNetlink(newHandle func() (Interface, error)) func() (Interface, error) {
+	return func() (Interface, error) {
+		if err := f.maybeFail("NewHandle"); err != nil {
+			return nil, err
+		}
+		nl, err := newHandle()
+		if err != nil {
+			return nil, err
+		}
+		return &faultyNetlink{Interface: nl, faults: f}, nil
+	}
+}
+
+// WrapWireguard is the equivalent of WrapNetlink for wireguard clients.
+func (f *FaultInjector) WrapWireguard(newClient func() (Wireguard, error)) func() (Wireguard, error) {
+	return func() (Wireguard, error) {
+		if err := f.maybeFail("NewWireguard"); err != nil {
+			return nil, err
+		}
+		wg, err := newClient()
+		if err != nil {
+			return nil, err
+		}
+		return &faultyWireguard{Wireguard: wg, faults: f}, nil
+	}
+}
+
+func (f *FaultInjector) maybeFail(op string) error {
+	f.lock.Lock()
+	r := f.rand.Float64()
+	f.lock.Unlock()
+
+	var err error
+	switch {
+	case r < f.timeoutRate:
+		err = syscall.EAGAIN
+	case r < f.timeoutRate+f.busyRate:
+		err = syscall.EBUSY
+	default:
+		return nil
+	}
+	log.WithError(err).WithField("op", op).Debug("Injecting netlink fault.")
+	return err
+}
+
+// faultyNetlink passes calls through to the real handle unless the FaultInjector decides that
+// they should fail.  Setting the socket timeout and closing the handle always succeed.
+type faultyNetlink struct {
+	Interface
+	faults *FaultInjector
+}
+
+func (n *faultyNetlink) LinkList() ([]netlink.Link, error) {
+	if err := n.faults.maybeFail("LinkList"); err != nil {
+		return nil, err
+	}
+	return n.Interface.LinkList()
+}
+
+func (n *faultyNetlink) LinkByName(name string) (netlink.Link, error) {
+	if err := n.faults.maybeFail("LinkByName"); err != nil {
+		return nil, err
+	}
+	return n.Interface.LinkByName(name)
+}
+
+func (n *faultyNetlink) LinkAdd(link netlink.Link) error {
+	if err := n.faults.maybeFail("LinkAdd"); err != nil {
+		return err
+	}
+	return n.Interface.LinkAdd(link)
+}
+
+func (n *faultyNetlink) LinkDel(link netlink.Link) error {
+	if err := n.faults.maybeFail("LinkDel"); err != nil {
+		return err
+	}
+	return n.Interface.LinkDel(link)
+}
+
+func (n *faultyNetlink) LinkSetMTU(link netlink.Link, mtu int) error {
+	if err := n.faults.maybeFail("LinkSetMTU"); err != nil {
+		return err
+	}
+	return n.Interface.LinkSetMTU(link, mtu)
+}
+
+func (n *faultyNetlink) LinkSetUp(link netlink.Link) error {
+	if err := n.faults.maybeFail("LinkSetUp"); err != nil {
+		return err
+	}
+	return n.Interface.LinkSetUp(link)
+}
+
+func (n *faultyNetlink) RouteListFiltered(family int, filter *netlink.Route, filterMask uint64) ([]netlink.Route, error) {
+	if err := n.faults.maybeFail("RouteListFiltered"); err != nil {
+		return nil, err
+	}
+	return n.Interface.RouteListFiltered(family, filter, filterMask)
+}
+
+func (n *faultyNetlink) RouteAdd(route *netlink.Route) error {
+	if err := n.faults.maybeFail("RouteAdd"); err != nil {
+		return err
+	}
+	return n.Interface.RouteAdd(route)
+}
+
+func (n *faultyNetlink) RouteDel(route *netlink.Route) error {
+	if err := n.faults.maybeFail("RouteDel"); err != nil {
+		return err
+	}
+	return n.Interface.RouteDel(route)
+}
+
+func (n *faultyNetlink) AddrList(link netlink.Link, family int) ([]netlink.Addr, error) {
+	if err := n.faults.maybeFail("AddrList"); err != nil {
+		return nil, err
+	}
+	return n.Interface.AddrList(link, family)
+}
+
+func (n *faultyNetlink) AddrAdd(link netlink.Link, addr *netlink.Addr) error {
+	if err := n.faults.maybeFail("AddrAdd"); err != nil {
+		return err
+	}
+	return n.Interface.AddrAdd(link, addr)
+}
+
+func (n *faultyNetlink) AddrDel(link netlink.Link, addr *netlink.Addr) error {
+	if err := n.faults.maybeFail("AddrDel"); err != nil {
+		return err
+	}
+	return n.Interface.AddrDel(link, addr)
+}
+
+func (n *faultyNetlink) RuleList(family int) ([]netlink.Rule, error) {
+	if err := n.faults.maybeFail("RuleList"); err != nil {
+		return nil, err
+	}
+	return n.Interface.RuleList(family)
+}
+
+func (n *faultyNetlink) RuleAdd(rule *netlink.Rule) error {
+	if err := n.faults.maybeFail("RuleAdd"); err != nil {
+		return err
+	}
+	return n.Interface.RuleAdd(rule)
+}
+
+func (n *faultyNetlink) RuleDel(rule *netlink.Rule) error {
+	if err := n.faults.maybeFail("RuleDel"); err != nil {
+		return err
+	}
+	return n.Interface.RuleDel(rule)
+}
+
+// faultyWireguard is the equivalent of faultyNetlink for wireguard clients.
+type faultyWireguard struct {
+	Wireguard
+	faults *FaultInjector
+}
+
+func (w *faultyWireguard) DeviceByName(name string) (*wgtypes.Device, error) {
+	if err := w.faults.maybeFail("DeviceByName"); err != nil {
+		return nil, err
+	}
+	return w.Wireguard.DeviceByName(name)
+}
+
+func (w *faultyWireguard) Devices() ([]*wgtypes.Device, error) {
+	if err := w.faults.maybeFail("Devices"); err != nil {
+		return nil, err
+	}
+	return w.Wireguard.Devices()
+}
+
+func (w *faultyWireguard) ConfigureDevice(name string, cfg wgtypes.Config) error {
+	if err := w.faults.maybeFail("ConfigureDevice"); err != nil {
+		return err
+	}
+	return w.Wireguard.ConfigureDevice(name, cfg)
+}
@@ -633,6 +633,11 @@ func (d *MockNetlinkDataplane) RouteListFiltered(family int, filter *netlink.Rou
 			log.Debugf("Does not match table %d", filter.Table)
 			continue
 		}
+		if filter != nil && filterMask&netlink.RT_FILTER_DST != 0 && route.Dst.String() != filter.Dst.String() {
+			// Filtering by destination and destinations do not match.
+			log.Debug("Does not match destination")
+			continue
+		}
 		routes = append(routes, route)
 	}
 	return routes, nil
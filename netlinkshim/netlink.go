@@ -42,5 +42,12 @@ type Interface interface {
 }
 
 func NewRealNetlink() (Interface, error) {
+	if defaultFaults != nil {
+		return defaultFaults.WrapNetlink(newRealNetlink)()
+	}
+	return newRealNetlink()
+}
+
+func newRealNetlink() (Interface, error) {
 	return netlink.NewHandle(syscall.NETLINK_ROUTE)
 }
@@ -28,6 +28,13 @@ type Wireguard interface {
 }
 
 func NewRealWireguard() (Wireguard, error) {
+	if defaultFaults != nil {
+		return defaultFaults.WrapWireguard(newRealWireguard)()
+	}
+	return newRealWireguard()
+}
+
+func newRealWireguard() (Wireguard, error) {
 	client, err := wgctrl.New()
 	if err != nil {
 		return nil, err
@@ -0,0 +1,806 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package nftables programs the chains and rules that Felix's rules renderer produces natively
+// with nft, rather than through iptables-restore.  See Table for the details.
+package nftables
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/ipsets"
+	"github.com/projectcalico/felix/iptables"
+)
+
+// baseChain describes the nft base chain that stands in for one of the kernel's iptables chains.
+type baseChain struct {
+	Type     string
+	Hook     string
+	Priority int
+}
+
+// baseChains maps from iptables table and chain to the equivalent nft base chain.  The
+// priorities are the ones that the iptables tables use so that our chains run at the same point
+// in the packet path as the iptables ones would.
+var baseChains = map[string]map[string]baseChain{
+	"raw": {
+		"PREROUTING": {Type: "filter", Hook: "prerouting", Priority: -300},
+		"OUTPUT":     {Type: "filter", Hook: "output", Priority: -300},
+	},
+	"mangle": {
+		"PREROUTING":  {Type: "filter", Hook: "prerouting", Priority: -150},
+		"INPUT":       {Type: "filter", Hook: "input", Priority: -150},
+		"FORWARD":     {Type: "filter", Hook: "forward", Priority: -150},
+		"OUTPUT":      {Type: "route", Hook: "output", Priority: -150},
+		"POSTROUTING": {Type: "filter", Hook: "postrouting", Priority: -150},
+	},
+	"nat": {
+		"PREROUTING":  {Type: "nat", Hook: "prerouting", Priority: -100},
+		"INPUT":       {Type: "nat", Hook: "input", Priority: 100},
+		"OUTPUT":      {Type: "nat", Hook: "output", Priority: -100},
+		"POSTROUTING": {Type: "nat", Hook: "postrouting", Priority: 100},
+	},
+	"filter": {
+		"INPUT":   {Type: "filter", Hook: "input", Priority: 0},
+		"FORWARD": {Type: "filter", Hook: "forward", Priority: 0},
+		"OUTPUT":  {Type: "filter", Hook: "output", Priority: 0},
+	},
+}
+
+// hashFeatures is used to calculate rule hashes.  Hashes only need to be stable, so the real
+// features of the dataplane don't matter.
+var hashFeatures = &iptables.Features{}
+
+// Table is an alternative to iptables.Table that programs one iptables table's worth of chains
+// (such as "filter") as nftables chains, using nft's JSON API.  It supports the same chain and
+// rule model as iptables.Table so the rules renderer and the managers can use either.
+//
+// All our chains live in the nft table that holds our IP sets (see ipsets.NFTSets), so that rules
+// can refer to the sets.  Each chain's name is prefixed with the iptables table's name, so
+// "cali-FORWARD" in the filter table becomes "filter-cali-FORWARD".  Rules that are inserted into
+// or appended to kernel chains are rendered into base chains of our own, such as
+// "filter-FORWARD", which hook into the kernel at the same priority as the iptables table.  Since
+// the base chains are ours alone, InsertOrAppendRules and AppendRules differ only in the order of
+// the rules.
+//
+// Like iptables.Table, the Table tracks the rules in the dataplane by a hash that it stores in
+// each rule's comment, rewrites only the chains that have changed and only programs chains that
+// are reachable from a base chain.  Each Apply() is a single nft transaction, so the dataplane
+// never sees a partial update.
+//
+// Rules that nftables can't express (see translator) cause their chain to be quarantined, in the
+// same way as iptables.Table quarantines chains that iptables-restore rejects.
+//
+// Table doesn't do any internal synchronization, its methods should only be called from one
+// thread.
+type Table struct {
+	Name      string
+	IPVersion uint8
+
+	family     string
+	hashPrefix string
+	translator translator
+
+	chainNameToChain     map[string]*iptables.Chain
+	chainNameToFragments map[string]iptables.ChainFragments
+	chainToInsertGroups  map[string]iptables.InsertGroups
+	chainToInsertedRules map[string][]iptables.Rule
+	chainToAppendedRules map[string][]iptables.Rule
+
+	// renderedChains caches the translation of our chains, keyed by iptables chain name.  Base
+	// chains are keyed by the kernel chain's name.  Entries are removed when the chain changes.
+	renderedChains map[string]*renderedChain
+
+	// quarantinedChains contains the chains that we aren't updating because one of their rules
+	// can't be translated.  It is recalculated on each Apply().
+	quarantinedChains map[string]*iptables.QuarantinedChain
+
+	// chainToDataplaneHashes contains the rule hashes of our chains in the dataplane, keyed by
+	// nft chain name.
+	chainToDataplaneHashes map[string][]string
+	inSyncWithDataPlane    bool
+	lastReadTime           time.Time
+	refreshInterval        time.Duration
+
+	logCxt *log.Entry
+
+	newCmd       func(name string, arg ...string) iptables.CmdIface
+	timeSleep    func(d time.Duration)
+	timeNow      func() time.Time
+	onStillAlive func()
+	opReporter   iptables.OpRecorder
+}
+
+// TableOptions contains the optional parameters of a Table.
+type TableOptions struct {
+	// RefreshInterval is the interval at which the Table re-reads the dataplane to check for
+	// changes made by other processes.  Zero disables the periodic refresh.
+	RefreshInterval time.Duration
+
+	// NewCmdOverride, if non-nil, factory to use instead of the real exec.Command().  Used by
+	// tests, and to run the commands with the dataplane thread's scheduling settings.
+	NewCmdOverride func(name string, arg ...string) iptables.CmdIface
+	// SleepOverride for tests, if non-nil, replacement for time.Sleep()
+	SleepOverride func(d time.Duration)
+	// NowOverride for tests, if non-nil, replacement for time.Now()
+	NowOverride func() time.Time
+
+	OnStillAlive func()
+	OpRecorder   iptables.OpRecorder
+}
+
+// renderedChain is the translation of one of our chains into nft expressions.
+type renderedChain struct {
+	chain  *iptables.Chain
+	hashes []string
+	// rules contains the expressions of each rule.  It is nil if a rule can't be translated.
+	rules [][]interface{}
+	// references contains the chains that the chain's rules jump to.  It is filled in even if
+	// the chain is quarantined, since the version of the chain in the dataplane probably refers
+	// to the same chains.
+	references []chainReference
+	// quarantine is set if one of the rules can't be translated.
+	quarantine *iptables.QuarantinedChain
+}
+
+type chainReference struct {
+	Target  string
+	RuleNum int
+}
+
+// NewTable creates a Table that manages the nftables equivalent of the given iptables table.
+// hashPrefix is prepended to the rule-tracking comment on each rule; see iptables.NewTable.
+func NewTable(name string, ipVersion uint8, hashPrefix string, options TableOptions) *Table {
+	if _, ok := baseChains[name]; !ok {
+		log.WithField("table", name).Panic("Unknown table")
+	}
+	if hashPrefix == "" {
+		log.Panic("NewTable called with empty hash prefix")
+	}
+	family := "ip"
+	if ipVersion == 6 {
+		family = "ip6"
+	}
+
+	t := &Table{
+		Name:                   name,
+		IPVersion:              ipVersion,
+		family:                 family,
+		hashPrefix:             hashPrefix,
+		chainNameToChain:       map[string]*iptables.Chain{},
+		chainNameToFragments:   map[string]iptables.ChainFragments{},
+		chainToInsertGroups:    map[string]iptables.InsertGroups{},
+		chainToInsertedRules:   map[string][]iptables.Rule{},
+		chainToAppendedRules:   map[string][]iptables.Rule{},
+		renderedChains:         map[string]*renderedChain{},
+		quarantinedChains:      map[string]*iptables.QuarantinedChain{},
+		chainToDataplaneHashes: map[string][]string{},
+		refreshInterval:        options.RefreshInterval,
+		logCxt: log.WithFields(log.Fields{
+			"ipVersion": ipVersion,
+			"table":     name,
+			"backend":   "nftables",
+		}),
+		newCmd:       iptables.NewRealCmd,
+		timeSleep:    time.Sleep,
+		timeNow:      time.Now,
+		onStillAlive: func() {},
+		opReporter:   options.OpRecorder,
+	}
+	t.translator = translator{ipVersion: ipVersion, chainName: t.nftChainName}
+	if options.NewCmdOverride != nil {
+		t.newCmd = options.NewCmdOverride
+	}
+	if options.SleepOverride != nil {
+		t.timeSleep = options.SleepOverride
+	}
+	if options.NowOverride != nil {
+		t.timeNow = options.NowOverride
+	}
+	if options.OnStillAlive != nil {
+		t.onStillAlive = options.OnStillAlive
+	}
+	if t.opReporter == nil {
+		t.opReporter = noOpRecorder{}
+	}
+	return t
+}
+
+type noOpRecorder struct{}
+
+func (noOpRecorder) RecordOperation(string) {}
+
+func (t *Table) GetIPVersion() uint8 {
+	return t.IPVersion
+}
+
+// SetRefreshInterval changes the interval at which the Table re-reads the dataplane to check for
+// out-of-band changes.  Zero disables the periodic refresh.
+func (t *Table) SetRefreshInterval(interval time.Duration) {
+	t.refreshInterval = interval
+}
+
+// nftChainName returns the name of the nft chain that holds the given iptables chain.
+func (t *Table) nftChainName(chainName string) string {
+	return t.Name + "-" + chainName
+}
+
+func (t *Table) InsertOrAppendRules(chainName string, rules []iptables.Rule) {
+	t.logCxt.WithField("chainName", chainName).Debug("Updating rule insertions")
+	t.checkBaseChain(chainName)
+	t.insertGroups(chainName).SetInserts(rules)
+	t.mergeInsertGroups(chainName)
+}
+
+func (t *Table) AppendRules(chainName string, rules []iptables.Rule) {
+	t.logCxt.WithField("chainName", chainName).Debug("Updating rule appends")
+	t.checkBaseChain(chainName)
+	t.insertGroups(chainName).SetAppends(rules)
+	t.mergeInsertGroups(chainName)
+}
+
+// UpdateInsertGroup sets the rules of the named insert group of the given base chain.  Groups
+// are ordered as in iptables.Table.UpdateInsertGroup.
+func (t *Table) UpdateInsertGroup(chainName string, group string, position iptables.InsertPosition, priority int, rules []iptables.Rule) {
+	logCxt := t.logCxt.WithFields(log.Fields{
+		"chainName": chainName,
+		"group":     group,
+		"position":  position,
+		"priority":  priority,
+	})
+	if err := iptables.CheckInsertGroupName(group); err != nil {
+		logCxt.WithError(err).Panic("Bug: invalid insert group")
+	}
+	t.checkBaseChain(chainName)
+	logCxt.Debug("Updating insert group")
+	t.insertGroups(chainName)[group] = iptables.InsertGroup{
+		Name:     group,
+		Position: position,
+		Priority: priority,
+		Rules:    rules,
+	}
+	t.mergeInsertGroups(chainName)
+}
+
+// RemoveInsertGroup removes the named insert group's rules from the given base chain.
+func (t *Table) RemoveInsertGroup(chainName string, group string) {
+	if err := iptables.CheckInsertGroupName(group); err != nil {
+		t.logCxt.WithError(err).WithField("chainName", chainName).Panic("Bug: invalid insert group")
+	}
+	if _, ok := t.chainToInsertGroups[chainName][group]; !ok {
+		return
+	}
+	t.logCxt.WithFields(log.Fields{
+		"chainName": chainName,
+		"group":     group,
+	}).Debug("Removing insert group")
+	delete(t.chainToInsertGroups[chainName], group)
+	t.mergeInsertGroups(chainName)
+}
+
+func (t *Table) insertGroups(chainName string) iptables.InsertGroups {
+	if t.chainToInsertGroups[chainName] == nil {
+		t.chainToInsertGroups[chainName] = iptables.InsertGroups{}
+	}
+	return t.chainToInsertGroups[chainName]
+}
+
+// mergeInsertGroups recalculates the rules of the given base chain from its insert groups.
+func (t *Table) mergeInsertGroups(chainName string) {
+	inserts, appends := t.chainToInsertGroups[chainName].Merge()
+	t.chainToInsertedRules[chainName] = inserts
+	t.chainToAppendedRules[chainName] = appends
+	delete(t.renderedChains, chainName)
+}
+
+func (t *Table) checkBaseChain(chainName string) {
+	if _, ok := baseChains[t.Name][chainName]; !ok {
+		t.logCxt.WithField("chainName", chainName).Panic(
+			"Bug: rules can only be inserted into kernel chains")
+	}
+}
+
+func (t *Table) UpdateChains(chains []*iptables.Chain) {
+	for _, chain := range chains {
+		t.UpdateChain(chain)
+	}
+}
+
+func (t *Table) UpdateChain(chain *iptables.Chain) {
+	if _, ok := t.chainNameToFragments[chain.Name]; ok {
+		t.logCxt.WithField("chainName", chain.Name).Panic(
+			"Chain is shared, its fragments must be updated with UpdateChainFragment.")
+	}
+	t.updateChain(chain)
+}
+
+func (t *Table) updateChain(chain *iptables.Chain) {
+	t.logCxt.WithField("chainName", chain.Name).Info("Queueing update of chain.")
+	t.chainNameToChain[chain.Name] = chain
+	delete(t.renderedChains, chain.Name)
+}
+
+func (t *Table) RemoveChains(chains []*iptables.Chain) {
+	for _, chain := range chains {
+		t.RemoveChainByName(chain.Name)
+	}
+}
+
+func (t *Table) RemoveChainByName(name string) {
+	if _, ok := t.chainNameToFragments[name]; ok {
+		t.logCxt.WithField("chainName", name).Panic(
+			"Chain is shared, its fragments must be removed with RemoveChainFragment.")
+	}
+	t.removeChainByName(name)
+}
+
+func (t *Table) removeChainByName(name string) {
+	t.logCxt.WithField("chainName", name).Info("Queuing deletion of chain.")
+	delete(t.chainNameToChain, name)
+	delete(t.renderedChains, name)
+}
+
+// UpdateChainFragment sets the rules that the given owner contributes to the named chain; see
+// iptables.Table.UpdateChainFragment.
+func (t *Table) UpdateChainFragment(chainName string, owner string, priority int, rules []iptables.Rule) {
+	t.logCxt.WithFields(log.Fields{
+		"chainName": chainName,
+		"owner":     owner,
+		"priority":  priority,
+	}).Debug("Updating chain fragment.")
+	if _, ok := t.chainNameToFragments[chainName]; !ok {
+		if _, ok := t.chainNameToChain[chainName]; ok {
+			t.logCxt.WithField("chainName", chainName).Panic(
+				"Chain was set with UpdateChain, it can't also have fragments.")
+		}
+		t.chainNameToFragments[chainName] = iptables.ChainFragments{}
+	}
+	t.chainNameToFragments[chainName][owner] = iptables.ChainFragment{
+		Owner:    owner,
+		Priority: priority,
+		Rules:    rules,
+	}
+	t.updateChain(t.chainNameToFragments[chainName].Merge(chainName))
+}
+
+// RemoveChainFragment removes the rules that the given owner contributes to the named chain.
+// The chain is removed once it has no fragments left.
+func (t *Table) RemoveChainFragment(chainName string, owner string) {
+	fragments := t.chainNameToFragments[chainName]
+	if _, ok := fragments[owner]; !ok {
+		return
+	}
+	t.logCxt.WithFields(log.Fields{
+		"chainName": chainName,
+		"owner":     owner,
+	}).Debug("Removing chain fragment.")
+	delete(fragments, owner)
+	if len(fragments) == 0 {
+		delete(t.chainNameToFragments, chainName)
+		t.removeChainByName(chainName)
+		return
+	}
+	t.updateChain(fragments.Merge(chainName))
+}
+
+// QuarantinedChains returns the chains that are currently quarantined, sorted by name.
+func (t *Table) QuarantinedChains() []iptables.QuarantinedChain {
+	chains := make([]iptables.QuarantinedChain, 0, len(t.quarantinedChains))
+	for _, q := range t.quarantinedChains {
+		chains = append(chains, *q)
+	}
+	sort.Slice(chains, func(i, j int) bool {
+		return chains[i].Chain < chains[j].Chain
+	})
+	return chains
+}
+
+func (t *Table) InvalidateDataplaneCache(reason string) {
+	if !t.inSyncWithDataPlane {
+		return
+	}
+	t.logCxt.WithField("reason", reason).Debug("Invalidating dataplane cache")
+	t.inSyncWithDataPlane = false
+}
+
+func (t *Table) Apply() (rescheduleAfter time.Duration) {
+	now := t.timeNow()
+	if t.refreshInterval > 0 && now.Sub(t.lastReadTime) > t.refreshInterval {
+		t.InvalidateDataplaneCache("refresh timer")
+	}
+
+	// As with iptables.Table, retry in case another process raced with us or clobbered our
+	// state; since each update is a single transaction, a failed update has no effect.
+	backoffTime := 1 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			if attempt > 10 {
+				t.logCxt.Panic("Failed to program nftables, giving up after retries")
+			}
+			t.timeSleep(backoffTime)
+			backoffTime *= 2
+			t.logCxt.Warn("Retrying...")
+		}
+		if !t.inSyncWithDataPlane {
+			if err := t.loadDataplaneState(); err != nil {
+				t.logCxt.WithError(err).Warn("Failed to load nftables state, will retry")
+				continue
+			}
+		}
+		t.onStillAlive()
+		if err := t.applyUpdates(); err != nil {
+			t.logCxt.WithError(err).Warn("Failed to program nftables, will retry")
+			t.InvalidateDataplaneCache("update failed")
+			continue
+		}
+		break
+	}
+
+	if t.refreshInterval > 0 {
+		rescheduleAfter = t.refreshInterval - now.Sub(t.lastReadTime)
+	}
+	return
+}
+
+// loadDataplaneState reads the rule hashes of our chains from the dataplane.
+func (t *Table) loadDataplaneState() error {
+	t.opReporter.RecordOperation(fmt.Sprintf("resync-nft-%v-v%d", t.Name, t.IPVersion))
+	out, err := t.runNFT(nil, "-j", "list", "table", t.family, ipsets.NFTTableName)
+	if err != nil {
+		if !strings.Contains(string(out), "No such file or directory") {
+			return err
+		}
+		// Our table doesn't exist yet; applyUpdates will create it.
+		out = []byte(`{"nftables": []}`)
+	}
+	objs, err := parseNFTOutput(out)
+	if err != nil {
+		return err
+	}
+	hashes := map[string][]string{}
+	ourPrefix := t.Name + "-"
+	for _, obj := range objs {
+		if c := obj.Chain; c != nil && strings.HasPrefix(c.Name, ourPrefix) {
+			if hashes[c.Name] == nil {
+				hashes[c.Name] = []string{}
+			}
+		}
+		if r := obj.Rule; r != nil && strings.HasPrefix(r.Chain, ourPrefix) {
+			hashes[r.Chain] = append(hashes[r.Chain], t.hashFromComment(r.Comment))
+		}
+	}
+	t.chainToDataplaneHashes = hashes
+	t.inSyncWithDataPlane = true
+	t.lastReadTime = t.timeNow()
+	return nil
+}
+
+// hashFromComment returns the rule hash from our rule comment, or "" if the comment isn't ours.
+func (t *Table) hashFromComment(comment string) string {
+	if !strings.HasPrefix(comment, t.hashPrefix) {
+		return ""
+	}
+	return strings.TrimPrefix(comment, t.hashPrefix)
+}
+
+// desiredChains calculates the chains that should be in the dataplane, keyed by nft chain name,
+// starting from the base chains and following jumps.  It also updates the set of quarantined
+// chains.
+func (t *Table) desiredChains() map[string]*renderedChain {
+	desired := map[string]*renderedChain{}
+	quarantined := map[string]*iptables.QuarantinedChain{}
+	var toVisit []string
+	for chainName := range baseChains[t.Name] {
+		rules := append(append([]iptables.Rule(nil), t.chainToInsertedRules[chainName]...),
+			t.chainToAppendedRules[chainName]...)
+		if len(rules) == 0 {
+			continue
+		}
+		if _, ok := t.renderedChains[chainName]; !ok {
+			t.renderedChains[chainName] = t.renderChain(&iptables.Chain{Name: chainName, Rules: rules})
+		}
+		toVisit = append(toVisit, chainName)
+	}
+	for len(toVisit) > 0 {
+		chainName := toVisit[0]
+		toVisit = toVisit[1:]
+		nftName := t.nftChainName(chainName)
+		if _, ok := desired[nftName]; ok {
+			continue
+		}
+		rendered := t.renderedChains[chainName]
+		if rendered == nil {
+			rendered = t.renderChain(t.chainNameToChain[chainName])
+			t.renderedChains[chainName] = rendered
+		}
+		desired[nftName] = rendered
+		if rendered.quarantine != nil {
+			quarantined[chainName] = rendered.quarantine
+		}
+		for _, ref := range rendered.references {
+			if _, ok := t.chainNameToChain[ref.Target]; !ok {
+				if quarantined[chainName] == nil {
+					quarantined[chainName] = t.quarantine(rendered.chain, ref.RuleNum,
+						fmt.Errorf("jump to unknown chain %q", ref.Target))
+				}
+				continue
+			}
+			toVisit = append(toVisit, ref.Target)
+		}
+	}
+
+	for chainName, q := range quarantined {
+		if _, ok := t.quarantinedChains[chainName]; !ok {
+			t.logCxt.WithFields(log.Fields{
+				"chainName":   q.Chain,
+				"ruleNum":     q.RuleNum,
+				"rule":        q.Rule,
+				"comment":     strings.Join(q.Comment, "; "),
+				"errorOutput": q.ErrorOutput,
+			}).Error("Can't program rule with nftables, quarantining its chain.  The rest of the " +
+				"table will be programmed but the chain won't be updated until its rules change.")
+		}
+	}
+	t.quarantinedChains = quarantined
+	return desired
+}
+
+// renderChain translates the given chain, which should be non-nil.
+func (t *Table) renderChain(chain *iptables.Chain) *renderedChain {
+	rendered := &renderedChain{
+		chain:  chain,
+		hashes: chain.RuleHashes(hashFeatures),
+	}
+	for i, rule := range chain.Rules {
+		if ref, ok := rule.Action.(iptables.Referrer); ok {
+			rendered.references = append(rendered.references, chainReference{
+				Target:  ref.ReferencedChain(),
+				RuleNum: i + 1,
+			})
+		}
+		if rendered.quarantine != nil {
+			continue
+		}
+		exprs, err := t.translator.translateRule(rule)
+		if err != nil {
+			rendered.quarantine = t.quarantine(chain, i+1, err)
+			continue
+		}
+		rendered.rules = append(rendered.rules, exprs)
+	}
+	return rendered
+}
+
+func (t *Table) quarantine(chain *iptables.Chain, ruleNum int, err error) *iptables.QuarantinedChain {
+	rule := chain.Rules[ruleNum-1]
+	return &iptables.QuarantinedChain{
+		Table:       t.Name,
+		IPVersion:   t.IPVersion,
+		Chain:       chain.Name,
+		RuleNum:     ruleNum,
+		Rule:        rule.RenderAppend(chain.Name, "", hashFeatures),
+		Comment:     rule.Comment,
+		ErrorOutput: err.Error(),
+	}
+}
+
+func (t *Table) applyUpdates() error {
+	desired := t.desiredChains()
+
+	var newChains, updates, stale []interface{}
+	newHashes := map[string][]string{}
+	for nftName, rendered := range desired {
+		dataplaneHashes, exists := t.chainToDataplaneHashes[nftName]
+		hashes := rendered.hashes
+		chainName := strings.TrimPrefix(nftName, t.Name+"-")
+		if q, ok := t.quarantinedChains[chainName]; ok {
+			if exists {
+				// Leave the last version that we programmed in place.
+				continue
+			}
+			if _, isBase := baseChains[t.Name][chainName]; isBase {
+				// Base chains correspond to the kernel's chains in the iptables dataplane,
+				// which we never flush; leave it empty so that its accept policy applies.
+				hashes = []string{}
+			} else {
+				// Create the chain so that jumps to it still resolve but, as in the iptables
+				// dataplane, have it drop all traffic rather than fail open.
+				rendered = t.renderChain(q.DropChain())
+				hashes = rendered.hashes
+			}
+		}
+		if exists && stringSlicesEqual(dataplaneHashes, hashes) {
+			continue
+		}
+		chainObj := t.chainObject(nftName)
+		if !exists {
+			newChains = append(newChains, expr{"add": expr{"chain": chainObj}})
+		} else {
+			updates = append(updates, expr{"flush": expr{"chain": t.chainRef(nftName)}})
+		}
+		for i := range hashes {
+			updates = append(updates, expr{"add": expr{"rule": expr{
+				"family":  t.family,
+				"table":   ipsets.NFTTableName,
+				"chain":   nftName,
+				"comment": t.hashPrefix + hashes[i],
+				"expr":    rendered.rules[i],
+			}}})
+		}
+		newHashes[nftName] = hashes
+	}
+	var staleNames []string
+	for nftName := range t.chainToDataplaneHashes {
+		if _, ok := desired[nftName]; ok {
+			continue
+		}
+		// Flush all the stale chains before deleting any of them, in case they refer to each
+		// other.
+		stale = append(stale, expr{"flush": expr{"chain": t.chainRef(nftName)}})
+		staleNames = append(staleNames, nftName)
+	}
+	for _, nftName := range staleNames {
+		stale = append(stale, expr{"delete": expr{"chain": t.chainRef(nftName)}})
+	}
+
+	if len(newChains)+len(updates)+len(stale) == 0 {
+		return nil
+	}
+	cmds := []interface{}{expr{"add": expr{"table": expr{"family": t.family, "name": ipsets.NFTTableName}}}}
+	cmds = append(cmds, newChains...)
+	cmds = append(cmds, updates...)
+	cmds = append(cmds, stale...)
+	input, err := json.Marshal(expr{"nftables": cmds})
+	if err != nil {
+		return err
+	}
+	if _, err := t.runNFT(input, "-j", "-f", "-"); err != nil {
+		return err
+	}
+
+	numRules := 0
+	for nftName, hashes := range newHashes {
+		t.chainToDataplaneHashes[nftName] = hashes
+		numRules += len(hashes)
+	}
+	for _, nftName := range staleNames {
+		delete(t.chainToDataplaneHashes, nftName)
+	}
+	if r, ok := t.opReporter.(iptables.CountRecorder); ok {
+		r.RecordCount(iptables.CountChainsUpdated, len(newHashes)+len(staleNames))
+		r.RecordCount(iptables.CountRulesWritten, numRules)
+	}
+	return nil
+}
+
+// chainObject returns the nft object that creates the given chain.
+func (t *Table) chainObject(nftName string) expr {
+	chain := t.chainRef(nftName)
+	if base, ok := baseChains[t.Name][strings.TrimPrefix(nftName, t.Name+"-")]; ok {
+		chain["type"] = base.Type
+		chain["hook"] = base.Hook
+		chain["prio"] = base.Priority
+		chain["policy"] = "accept"
+	}
+	return chain
+}
+
+func (t *Table) chainRef(nftName string) expr {
+	return expr{"family": t.family, "table": ipsets.NFTTableName, "name": nftName}
+}
+
+// ReadRuleCounters reads the packet counts of the rules in the given chain from the dataplane.
+// As with iptables.Table.ReadRuleCounters, the counts are keyed by the rule's comment; rules
+// that share a comment have their counts summed and rules without a comment are skipped.
+func (t *Table) ReadRuleCounters(chainName string) (map[string]uint64, error) {
+	chain := t.chainNameToChain[chainName]
+	if chain == nil {
+		return map[string]uint64{}, nil
+	}
+	hashToComment := map[string]string{}
+	for i, hash := range chain.RuleHashes(hashFeatures) {
+		if len(chain.Rules[i].Comment) > 0 {
+			hashToComment[hash] = chain.Rules[i].Comment[0]
+		}
+	}
+
+	out, err := t.runNFT(nil, "-j", "list", "chain", t.family, ipsets.NFTTableName, t.nftChainName(chainName))
+	if err != nil {
+		return nil, err
+	}
+	objs, err := parseNFTOutput(out)
+	if err != nil {
+		return nil, err
+	}
+	counters := map[string]uint64{}
+	for _, obj := range objs {
+		if obj.Rule == nil {
+			continue
+		}
+		comment, ok := hashToComment[t.hashFromComment(obj.Rule.Comment)]
+		if !ok {
+			continue
+		}
+		for _, e := range obj.Rule.Expr {
+			if e.Counter != nil {
+				counters[comment] += e.Counter.Packets
+			}
+		}
+	}
+	return counters, nil
+}
+
+func (t *Table) runNFT(stdin []byte, args ...string) ([]byte, error) {
+	cmd := t.newCmd("nft", args...)
+	if stdin != nil {
+		cmd.SetStdin(bytes.NewReader(stdin))
+	}
+	var stderr bytes.Buffer
+	cmd.SetStderr(&stderr)
+	out, err := cmd.Output()
+	if err != nil {
+		t.logCxt.WithFields(log.Fields{
+			"args":   args,
+			"output": stderr.String(),
+			"input":  string(stdin),
+		}).WithError(err).Warn("nft command failed")
+		return stderr.Bytes(), err
+	}
+	return out, nil
+}
+
+// nftObject is one of the objects in nft's JSON output.  We only decode the fields that we need.
+type nftObject struct {
+	Chain *struct {
+		Name string `json:"name"`
+	} `json:"chain"`
+	Rule *struct {
+		Chain   string `json:"chain"`
+		Comment string `json:"comment"`
+		Expr    []struct {
+			Counter *struct {
+				Packets uint64 `json:"packets"`
+			} `json:"counter"`
+		} `json:"expr"`
+	} `json:"rule"`
+}
+
+func parseNFTOutput(out []byte) ([]nftObject, error) {
+	var parsed struct {
+		Nftables []nftObject `json:"nftables"`
+	}
+	if err := json.Unmarshal(out, &parsed); err != nil {
+		return nil, fmt.Errorf("failed to parse nft output: %w", err)
+	}
+	return parsed.Nftables, nil
+}
+
+func stringSlicesEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
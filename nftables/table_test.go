@@ -0,0 +1,339 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nftables
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/iptables"
+)
+
+var _ = Describe("Table", func() {
+	var (
+		dataplane *mockNFT
+		table     *Table
+	)
+
+	BeforeEach(func() {
+		dataplane = newMockNFT()
+		table = NewTable("filter", 4, "cali:", TableOptions{
+			NewCmdOverride: dataplane.newCmd,
+			SleepOverride:  func(time.Duration) {},
+		})
+	})
+
+	hookForward := func() {
+		table.InsertOrAppendRules("FORWARD", []iptables.Rule{{
+			Action: iptables.JumpAction{Target: "cali-FORWARD"},
+		}})
+	}
+
+	It("should program only the chains that are reachable from a base chain", func() {
+		hookForward()
+		table.UpdateChain(&iptables.Chain{Name: "cali-FORWARD", Rules: []iptables.Rule{
+			{Match: iptables.Match().InInterface("cali+"), Action: iptables.AcceptAction{}},
+		}})
+		table.UpdateChain(&iptables.Chain{Name: "cali-unused", Rules: []iptables.Rule{
+			{Action: iptables.DropAction{}},
+		}})
+		table.Apply()
+
+		Expect(dataplane.transactions).To(HaveLen(1))
+		Expect(dataplane.chainNames()).To(ConsistOf("filter-FORWARD", "filter-cali-FORWARD"))
+		Expect(dataplane.chains["filter-FORWARD"].hook).To(Equal("forward"))
+		Expect(dataplane.chains["filter-FORWARD"].rules).To(HaveLen(1))
+		Expect(dataplane.chains["filter-cali-FORWARD"].hook).To(Equal(""))
+		Expect(dataplane.chains["filter-cali-FORWARD"].rules).To(HaveLen(1))
+	})
+
+	It("should only rewrite chains that have changed", func() {
+		hookForward()
+		table.UpdateChain(&iptables.Chain{Name: "cali-FORWARD", Rules: []iptables.Rule{
+			{Action: iptables.AcceptAction{}},
+		}})
+		table.Apply()
+		table.Apply()
+		Expect(dataplane.transactions).To(HaveLen(1))
+
+		table.UpdateChain(&iptables.Chain{Name: "cali-FORWARD", Rules: []iptables.Rule{
+			{Action: iptables.DropAction{}},
+		}})
+		table.Apply()
+		Expect(dataplane.transactions).To(HaveLen(2))
+		Expect(dataplane.transactions[1]).To(ContainSubstring(`"flush":{"chain":{"family":"ip","name":"filter-cali-FORWARD"`))
+		Expect(dataplane.transactions[1]).NotTo(ContainSubstring(`"name":"filter-FORWARD"`))
+	})
+
+	It("should remove our stale chains, leaving other tables' chains alone", func() {
+		dataplane.chains["filter-cali-old"] = &mockChain{rules: []string{"cali:abcd"}}
+		dataplane.chains["nat-cali-foo"] = &mockChain{}
+		hookForward()
+		table.UpdateChain(&iptables.Chain{Name: "cali-FORWARD"})
+		table.Apply()
+
+		Expect(dataplane.chainNames()).To(ConsistOf("filter-FORWARD", "filter-cali-FORWARD", "nat-cali-foo"))
+	})
+
+	It("should quarantine a chain with a rule that can't be translated", func() {
+		hookForward()
+		table.UpdateChain(&iptables.Chain{Name: "cali-FORWARD", Rules: []iptables.Rule{
+			{Action: iptables.AcceptAction{}},
+			{Match: iptables.Match().IPVSConnection(), Action: iptables.AcceptAction{}, Comment: []string{"ipvs"}},
+		}})
+		table.Apply()
+
+		quarantined := table.QuarantinedChains()
+		Expect(quarantined).To(HaveLen(1))
+		Expect(quarantined[0].Table).To(Equal("filter"))
+		Expect(quarantined[0].Chain).To(Equal("cali-FORWARD"))
+		Expect(quarantined[0].RuleNum).To(Equal(2))
+		Expect(quarantined[0].Comment).To(Equal([]string{"ipvs"}))
+		// Created with a single drop rule so that the jump to it works but traffic fails
+		// closed.
+		Expect(dataplane.chains["filter-cali-FORWARD"].rules).To(HaveLen(1))
+		Expect(dataplane.transactions[0]).To(MatchRegexp(
+			`"chain":"filter-cali-FORWARD","comment":"cali:[^"]+","expr":\[\{"counter":null\},\{"drop":null\}\]`))
+
+		table.UpdateChain(&iptables.Chain{Name: "cali-FORWARD", Rules: []iptables.Rule{
+			{Action: iptables.AcceptAction{}},
+		}})
+		table.Apply()
+		Expect(table.QuarantinedChains()).To(BeEmpty())
+		Expect(dataplane.chains["filter-cali-FORWARD"].rules).To(HaveLen(1))
+	})
+
+	It("should quarantine a chain that jumps to an unknown chain", func() {
+		hookForward()
+		table.Apply()
+
+		Expect(table.QuarantinedChains()).To(HaveLen(1))
+		Expect(table.QuarantinedChains()[0].Chain).To(Equal("FORWARD"))
+		Expect(dataplane.chains["filter-FORWARD"].rules).To(BeEmpty())
+	})
+
+	It("should retry after a failure", func() {
+		hookForward()
+		table.UpdateChain(&iptables.Chain{Name: "cali-FORWARD"})
+		dataplane.failNextTransaction = true
+		table.Apply()
+
+		Expect(dataplane.transactions).To(HaveLen(2))
+		Expect(dataplane.chainNames()).To(ConsistOf("filter-FORWARD", "filter-cali-FORWARD"))
+	})
+
+	It("should order insert groups by position, priority and name", func() {
+		table.AppendRules("FORWARD", []iptables.Rule{{Action: iptables.JumpAction{Target: "cali-appended"}}})
+		table.UpdateInsertGroup("FORWARD", "early", iptables.InsertAtEnd, -1, []iptables.Rule{
+			{Action: iptables.JumpAction{Target: "cali-early"}},
+		})
+		table.UpdateInsertGroup("FORWARD", "late", iptables.InsertAtDefaultPosition, 1, []iptables.Rule{
+			{Action: iptables.JumpAction{Target: "cali-late"}},
+		})
+		hookForward()
+		for _, name := range []string{"cali-FORWARD", "cali-appended", "cali-early", "cali-late"} {
+			table.UpdateChain(&iptables.Chain{Name: name})
+		}
+		table.Apply()
+
+		Expect(dataplane.chains["filter-FORWARD"].rules).To(HaveLen(4))
+		Expect(dataplane.transactions[0]).To(MatchRegexp(
+			`filter-cali-FORWARD"}.*filter-cali-late"}.*filter-cali-early"}.*filter-cali-appended"}`))
+
+		table.RemoveInsertGroup("FORWARD", "early")
+		table.Apply()
+		Expect(dataplane.chains["filter-FORWARD"].rules).To(HaveLen(3))
+		Expect(dataplane.chainNames()).NotTo(ContainElement("filter-cali-early"))
+	})
+
+	It("should reject invalid insert groups", func() {
+		Expect(func() {
+			table.UpdateInsertGroup("FORWARD", "", iptables.InsertAtEnd, 0, nil)
+		}).To(Panic())
+		Expect(func() {
+			table.UpdateInsertGroup("FORWARD", "*appends*", iptables.InsertAtEnd, 0, nil)
+		}).To(Panic())
+		Expect(func() {
+			table.UpdateInsertGroup("cali-FORWARD", "mesh", iptables.InsertAtEnd, 0, nil)
+		}).To(Panic())
+	})
+
+	It("should merge chain fragments and remove the chain with the last fragment", func() {
+		hookForward()
+		table.UpdateChainFragment("cali-FORWARD", "second", 1, []iptables.Rule{
+			{Action: iptables.AcceptAction{}},
+		})
+		table.UpdateChainFragment("cali-FORWARD", "first", 0, []iptables.Rule{
+			{Action: iptables.DropAction{}},
+		})
+		table.Apply()
+		Expect(dataplane.chains["filter-cali-FORWARD"].rules).To(HaveLen(2))
+		Expect(dataplane.transactions[0]).To(MatchRegexp(`"drop":null.*"accept":null`))
+		Expect(func() { table.UpdateChain(&iptables.Chain{Name: "cali-FORWARD"}) }).To(Panic())
+		Expect(func() { table.RemoveChainByName("cali-FORWARD") }).To(Panic())
+
+		table.RemoveChainFragment("cali-FORWARD", "first")
+		table.Apply()
+		Expect(dataplane.chains["filter-cali-FORWARD"].rules).To(HaveLen(1))
+
+		table.RemoveChainFragment("cali-FORWARD", "second")
+		table.Apply()
+		Expect(dataplane.chainNames()).NotTo(ContainElement("filter-cali-FORWARD"))
+	})
+
+	It("should read rule counters by comment", func() {
+		hookForward()
+		table.UpdateChain(&iptables.Chain{Name: "cali-FORWARD", Rules: []iptables.Rule{
+			{Action: iptables.DropAction{}, Comment: []string{"eth0"}},
+			{Action: iptables.DropAction{}, Comment: []string{"eth1"}},
+			{Action: iptables.AcceptAction{}},
+		}})
+		table.Apply()
+		dataplane.packets = 7
+
+		counters, err := table.ReadRuleCounters("cali-FORWARD")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(counters).To(Equal(map[string]uint64{"eth0": 7, "eth1": 7}))
+	})
+})
+
+type mockChain struct {
+	hook  string
+	rules []string
+}
+
+// mockNFT fakes nft's JSON API, tracking the chains and rule comments in our table.
+type mockNFT struct {
+	chains              map[string]*mockChain
+	transactions        []string
+	failNextTransaction bool
+	packets             uint64
+}
+
+func newMockNFT() *mockNFT {
+	return &mockNFT{chains: map[string]*mockChain{}}
+}
+
+func (d *mockNFT) chainNames() []string {
+	var names []string
+	for name := range d.chains {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (d *mockNFT) newCmd(name string, arg ...string) iptables.CmdIface {
+	Expect(name).To(Equal("nft"))
+	return &mockNFTCmd{dataplane: d, args: arg}
+}
+
+func (d *mockNFT) listOutput(chainName string) []byte {
+	objs := []interface{}{}
+	for name, c := range d.chains {
+		if chainName != "" && name != chainName {
+			continue
+		}
+		objs = append(objs, expr{"chain": expr{"name": name}})
+		for _, comment := range c.rules {
+			objs = append(objs, expr{"rule": expr{
+				"chain":   name,
+				"comment": comment,
+				"expr":    []interface{}{expr{"counter": expr{"packets": d.packets}}},
+			}})
+		}
+	}
+	out, err := json.Marshal(expr{"nftables": objs})
+	Expect(err).NotTo(HaveOccurred())
+	return out
+}
+
+func (d *mockNFT) apply(input []byte) error {
+	d.transactions = append(d.transactions, string(input))
+	if d.failNextTransaction {
+		d.failNextTransaction = false
+		return errors.New("exit status 1")
+	}
+	var parsed struct {
+		Nftables []map[string]map[string]struct {
+			Name    string `json:"name"`
+			Hook    string `json:"hook"`
+			Chain   string `json:"chain"`
+			Comment string `json:"comment"`
+		} `json:"nftables"`
+	}
+	Expect(json.Unmarshal(input, &parsed)).To(Succeed())
+	for _, cmd := range parsed.Nftables {
+		for verb, objs := range cmd {
+			for objType, obj := range objs {
+				switch verb + " " + objType {
+				case "add table":
+				case "add chain":
+					Expect(d.chains).NotTo(HaveKey(obj.Name))
+					d.chains[obj.Name] = &mockChain{hook: obj.Hook}
+				case "flush chain":
+					d.chains[obj.Name].rules = nil
+				case "delete chain":
+					Expect(d.chains[obj.Name].rules).To(BeEmpty())
+					delete(d.chains, obj.Name)
+				case "add rule":
+					c := d.chains[obj.Chain]
+					c.rules = append(c.rules, obj.Comment)
+				default:
+					Fail("Unexpected nft command: " + verb + " " + objType)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+type mockNFTCmd struct {
+	dataplane *mockNFT
+	args      []string
+	stdin     io.Reader
+}
+
+func (c *mockNFTCmd) Output() ([]byte, error) {
+	args := strings.Join(c.args, " ")
+	switch {
+	case args == "-j list table ip calico-ipsets":
+		return c.dataplane.listOutput(""), nil
+	case strings.HasPrefix(args, "-j list chain ip calico-ipsets "):
+		return c.dataplane.listOutput(c.args[len(c.args)-1]), nil
+	case args == "-j -f -":
+		input, err := ioutil.ReadAll(c.stdin)
+		Expect(err).NotTo(HaveOccurred())
+		return nil, c.dataplane.apply(input)
+	}
+	Fail("Unexpected nft command: " + args)
+	return nil, nil
+}
+
+func (c *mockNFTCmd) SetStdin(r io.Reader)               { c.stdin = r }
+func (c *mockNFTCmd) SetStdout(io.Writer)                {}
+func (c *mockNFTCmd) SetStderr(io.Writer)                {}
+func (c *mockNFTCmd) Run() error                         { panic("not implemented") }
+func (c *mockNFTCmd) Start() error                       { panic("not implemented") }
+func (c *mockNFTCmd) Kill() error                        { panic("not implemented") }
+func (c *mockNFTCmd) Wait() error                        { panic("not implemented") }
+func (c *mockNFTCmd) StdoutPipe() (io.ReadCloser, error) { panic("not implemented") }
+func (c *mockNFTCmd) String() string                     { return "nft " + strings.Join(c.args, " ") }
@@ -0,0 +1,476 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nftables
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/projectcalico/felix/iptables"
+)
+
+// expr is a JSON object in nft's JSON schema (see libnftables-json(5)).
+type expr = map[string]interface{}
+
+// vxlanVNIU32Regexp matches the u32 program that iptables.MatchCriteria.VXLANVNI() generates,
+// capturing the VNI.
+var vxlanVNIU32Regexp = regexp.MustCompile(`^0>>22&0x3C@12>>8=0x([0-9a-fA-F]+)$`)
+
+// translator converts the iptables-syntax matches and actions that the rules renderer produces
+// into nft expressions.  Matches are translated from the fragments that the iptables.MatchCriteria
+// builder methods produce; actions from the typed iptables.Action values.  Anything that nftables
+// can't express returns an error, so that the Table can quarantine the chain rather than
+// programming a rule that means something different.
+type translator struct {
+	ipVersion uint8
+	// chainName maps an iptables chain name to the name of the corresponding nft chain.
+	chainName func(string) string
+}
+
+func (t *translator) translateRule(rule iptables.Rule) ([]interface{}, error) {
+	var exprs []interface{}
+	for _, frag := range rule.Match {
+		words, err := splitWords(frag)
+		if err != nil {
+			return nil, err
+		}
+		fragExprs, err := t.translateMatch(words)
+		if err != nil {
+			return nil, fmt.Errorf("can't translate match %q: %w", frag, err)
+		}
+		exprs = append(exprs, fragExprs...)
+	}
+	// Count every rule; ReadRuleCounters relies on it and iptables counts every rule too.
+	exprs = append(exprs, expr{"counter": nil})
+	if rule.Action == nil {
+		return exprs, nil
+	}
+	actionExprs, err := t.translateAction(rule.Action)
+	if err != nil {
+		return nil, fmt.Errorf("can't translate action %v: %w", rule.Action, err)
+	}
+	return append(exprs, actionExprs...), nil
+}
+
+func (t *translator) translateMatch(words []string) ([]interface{}, error) {
+	op := "=="
+	if len(words) > 0 && words[0] == "!" {
+		op = "!="
+		words = words[1:]
+	}
+	if len(words) < 2 {
+		return nil, fmt.Errorf("unexpected match")
+	}
+	switch words[0] {
+	case "-p":
+		return one(match(op, meta("l4proto"), protocol(words[1]))), nil
+	case "--source":
+		return one(match(op, payload(t.ipProto(), "saddr"), address(words[1]))), nil
+	case "--destination":
+		return one(match(op, payload(t.ipProto(), "daddr"), address(words[1]))), nil
+	case "--in-interface":
+		return one(match(op, meta("iifname"), ifaceName(words[1]))), nil
+	case "--out-interface":
+		return one(match(op, meta("oifname"), ifaceName(words[1]))), nil
+	case "-m":
+		return t.translateModule(words[1], words[2:])
+	}
+	return nil, fmt.Errorf("unknown match %q", words[0])
+}
+
+// translateModule translates a "-m <module> <options>" match.
+func (t *translator) translateModule(module string, words []string) ([]interface{}, error) {
+	opts, flags, op, err := parseOptions(words)
+	if err != nil {
+		return nil, err
+	}
+	switch module {
+	case "mark":
+		mark, mask, err := parseMarkAndMask(opts["--mark"])
+		if err != nil {
+			return nil, err
+		}
+		return one(match(op, binop("&", meta("mark"), mask), mark)), nil
+	case "set":
+		parts := strings.Fields(opts["--match-set"])
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("unexpected set match")
+		}
+		return t.translateSetMatch(op, parts[0], parts[1])
+	case "multiport":
+		if ports, ok := opts["--source-ports"]; ok {
+			return t.translatePortsMatch(op, "sport", ports)
+		}
+		return t.translatePortsMatch(op, "dport", opts["--destination-ports"])
+	case "icmp":
+		return t.translateICMPMatch(op, "icmp", opts["--icmp-type"])
+	case "icmp6":
+		return t.translateICMPMatch(op, "icmpv6", opts["--icmpv6-type"])
+	case "conntrack":
+		var states []interface{}
+		for _, s := range strings.Split(opts["--ctstate"], ",") {
+			switch s {
+			case "INVALID", "ESTABLISHED", "RELATED", "NEW", "UNTRACKED":
+				states = append(states, strings.ToLower(s))
+			default:
+				return nil, fmt.Errorf("unsupported conntrack state %q", s)
+			}
+		}
+		return one(match(op, expr{"ct": expr{"key": "state"}}, expr{"set": states})), nil
+	case "addrtype":
+		if addrType, ok := opts["--src-type"]; ok {
+			fibFlags := []string{"saddr"}
+			if flags["--limit-iface-out"] {
+				fibFlags = append(fibFlags, "oif")
+			}
+			return one(match(op, fib("type", fibFlags...), strings.ToLower(addrType))), nil
+		}
+		return one(match(op, fib("type", "daddr"), strings.ToLower(opts["--dst-type"]))), nil
+	case "rpfilter":
+		if flags["--accept-local"] {
+			return nil, fmt.Errorf("nftables has no equivalent of rpfilter --accept-local")
+		}
+		fibFlags := []string{"saddr", "iif"}
+		if flags["--validmark"] {
+			fibFlags = []string{"saddr", "mark", "iif"}
+		}
+		// A missing result means that the RPF check failed.
+		return one(match("==", fib("oif", fibFlags...), !flags["--invert"])), nil
+	case "mac":
+		return one(match(op, payload("ether", "saddr"), strings.ToLower(opts["--mac-source"]))), nil
+	case "hashlimit":
+		// Unlike hashlimit's named bucket, nft's limit statement has a bucket per rule.  The
+		// rules renderer only uses each name in one rule so the behaviour is the same.
+		rate := strings.TrimSuffix(opts["--hashlimit-above"], "/sec")
+		ratePerSec, err := strconv.Atoi(rate)
+		if err != nil || op != "==" {
+			return nil, fmt.Errorf("unsupported hashlimit")
+		}
+		burst, err := strconv.Atoi(opts["--hashlimit-burst"])
+		if err != nil {
+			return nil, fmt.Errorf("invalid hashlimit burst: %w", err)
+		}
+		return one(expr{"limit": expr{"rate": ratePerSec, "per": "second", "burst": burst, "inv": true}}), nil
+	case "u32":
+		captures := vxlanVNIU32Regexp.FindStringSubmatch(opts["--u32"])
+		if captures == nil {
+			return nil, fmt.Errorf("unsupported u32 program")
+		}
+		vni, err := strconv.ParseUint(captures[1], 16, 32)
+		if err != nil {
+			return nil, err
+		}
+		// The VNI is the 3 bytes after the 8-byte UDP header and the first 4 bytes of VXLAN.
+		vniPayload := expr{"payload": expr{"base": "th", "offset": 96, "len": 24}}
+		return one(match(op, vniPayload, vni)), nil
+	}
+	return nil, fmt.Errorf("unsupported match module %q", module)
+}
+
+func (t *translator) translateSetMatch(op, setName, dirs string) ([]interface{}, error) {
+	setRef := "@" + setName
+	switch dirs {
+	case "src":
+		return one(match(op, payload(t.ipProto(), "saddr"), setRef)), nil
+	case "dst":
+		return one(match(op, payload(t.ipProto(), "daddr"), setRef)), nil
+	case "src,src", "dst,dst":
+		addr, port := "saddr", "sport"
+		if dirs == "dst,dst" {
+			addr, port = "daddr", "dport"
+		}
+		concat := expr{"concat": []interface{}{
+			payload(t.ipProto(), addr),
+			meta("l4proto"),
+			payload("th", port),
+		}}
+		return one(match(op, concat, setRef)), nil
+	}
+	return nil, fmt.Errorf("unsupported set directions %q", dirs)
+}
+
+func (t *translator) translatePortsMatch(op, field, ports string) ([]interface{}, error) {
+	var elems []interface{}
+	for _, p := range strings.Split(ports, ",") {
+		parts := strings.SplitN(p, ":", 2)
+		first, err := strconv.ParseUint(parts[0], 10, 16)
+		if err != nil {
+			return nil, err
+		}
+		if len(parts) == 1 {
+			elems = append(elems, first)
+			continue
+		}
+		last, err := strconv.ParseUint(parts[1], 10, 16)
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, expr{"range": []interface{}{first, last}})
+	}
+	return one(match(op, payload("th", field), expr{"set": elems})), nil
+}
+
+func (t *translator) translateICMPMatch(op, proto, typeAndCode string) ([]interface{}, error) {
+	parts := strings.SplitN(typeAndCode, "/", 2)
+	icmpType, err := strconv.ParseUint(parts[0], 10, 8)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) == 1 {
+		return one(match(op, payload(proto, "type"), icmpType)), nil
+	}
+	icmpCode, err := strconv.ParseUint(parts[1], 10, 8)
+	if err != nil {
+		return nil, err
+	}
+	typeAndCodeExpr := expr{"concat": []interface{}{payload(proto, "type"), payload(proto, "code")}}
+	return one(match(op, typeAndCodeExpr, expr{"concat": []interface{}{icmpType, icmpCode}})), nil
+}
+
+func (t *translator) translateAction(action iptables.Action) ([]interface{}, error) {
+	switch a := action.(type) {
+	case iptables.AcceptAction:
+		return one(expr{"accept": nil}), nil
+	case iptables.DropAction:
+		return one(expr{"drop": nil}), nil
+	case iptables.ReturnAction:
+		return one(expr{"return": nil}), nil
+	case iptables.RejectAction:
+		return one(expr{"reject": nil}), nil
+	case iptables.JumpAction:
+		return one(expr{"jump": expr{"target": t.chainName(a.Target)}}), nil
+	case iptables.GotoAction:
+		return one(expr{"goto": expr{"target": t.chainName(a.Target)}}), nil
+	case iptables.LogAction:
+		return one(expr{"log": expr{"prefix": a.Prefix + ": ", "level": "notice"}}), nil
+	case iptables.NflogAction:
+		return one(expr{"log": expr{"prefix": a.Prefix, "group": a.Group}}), nil
+	case iptables.NoTrackAction:
+		return one(expr{"notrack": nil}), nil
+	case iptables.DNATAction:
+		dnat := expr{"addr": a.DestAddr}
+		if a.DestPort != 0 {
+			dnat["port"] = a.DestPort
+		}
+		return one(expr{"dnat": dnat}), nil
+	case iptables.SNATAction:
+		snat := expr{"flags": []string{"fully-random"}}
+		if first, last, ok := splitRange(a.ToAddr); ok {
+			snat["addr"] = expr{"range": []interface{}{first, last}}
+		} else {
+			snat["addr"] = a.ToAddr
+		}
+		return one(expr{"snat": snat}), nil
+	case iptables.MasqAction:
+		masq := expr{"flags": []string{"fully-random"}}
+		if a.ToPorts != "" {
+			first, last, ok := splitRange(a.ToPorts)
+			if !ok {
+				first, last = a.ToPorts, a.ToPorts
+			}
+			firstPort, err1 := strconv.ParseUint(first, 10, 16)
+			lastPort, err2 := strconv.ParseUint(last, 10, 16)
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("invalid masquerade ports %q", a.ToPorts)
+			}
+			masq["port"] = expr{"range": []interface{}{firstPort, lastPort}}
+		}
+		return one(expr{"masquerade": masq}), nil
+	case iptables.ClearMarkAction:
+		return one(setMark(meta("mark"), 0, a.Mark)), nil
+	case iptables.SetMarkAction:
+		return one(setMark(meta("mark"), a.Mark, a.Mark)), nil
+	case iptables.SetMaskedMarkAction:
+		return one(setMark(meta("mark"), a.Mark, a.Mask)), nil
+	case iptables.SetConnMarkAction:
+		mask := a.Mask
+		if mask == 0 {
+			mask = 0xffffffff
+		}
+		return one(setMark(expr{"ct": expr{"key": "mark"}}, a.Mark, mask)), nil
+	case iptables.SaveConnMarkAction:
+		// nft can't combine two packet-dependent values in one statement so, unlike CONNMARK,
+		// it can only copy the whole mark.
+		if a.SaveMask != 0 && a.SaveMask != 0xffffffff {
+			return nil, fmt.Errorf("nftables can't save a partial connmark")
+		}
+		return one(expr{"mangle": expr{"key": expr{"ct": expr{"key": "mark"}}, "value": meta("mark")}}), nil
+	case iptables.RestoreConnMarkAction:
+		if a.RestoreMask != 0 && a.RestoreMask != 0xffffffff {
+			return nil, fmt.Errorf("nftables can't restore a partial connmark")
+		}
+		return one(expr{"mangle": expr{"key": meta("mark"), "value": expr{"ct": expr{"key": "mark"}}}}), nil
+	}
+	return nil, fmt.Errorf("unsupported action type %T", action)
+}
+
+func (t *translator) ipProto() string {
+	if t.ipVersion == 6 {
+		return "ip6"
+	}
+	return "ip"
+}
+
+// setMark returns a statement that sets the bits of key that are in mask to the value of mark.
+func setMark(key expr, mark, mask uint32) expr {
+	var value interface{} = mark
+	if mask != 0xffffffff {
+		value = binop("|", binop("&", key, ^mask), mark)
+	}
+	return expr{"mangle": expr{"key": key, "value": value}}
+}
+
+func one(e expr) []interface{} {
+	return []interface{}{e}
+}
+
+func match(op string, left, right interface{}) expr {
+	return expr{"match": expr{"op": op, "left": left, "right": right}}
+}
+
+func meta(key string) expr {
+	return expr{"meta": expr{"key": key}}
+}
+
+func payload(protocol, field string) expr {
+	return expr{"payload": expr{"protocol": protocol, "field": field}}
+}
+
+func binop(op string, left, right interface{}) expr {
+	return expr{op: []interface{}{left, right}}
+}
+
+func fib(result string, flags ...string) expr {
+	return expr{"fib": expr{"result": result, "flags": flags}}
+}
+
+func protocol(p string) interface{} {
+	if n, err := strconv.ParseUint(p, 10, 8); err == nil {
+		return n
+	}
+	return p
+}
+
+func address(addr string) interface{} {
+	if _, cidr, err := net.ParseCIDR(addr); err == nil {
+		ones, _ := cidr.Mask.Size()
+		return expr{"prefix": expr{"addr": cidr.IP.String(), "len": ones}}
+	}
+	return addr
+}
+
+// ifaceName converts an iptables interface match to nft's syntax, which uses "*" rather than "+"
+// as the wildcard.
+func ifaceName(name string) string {
+	if strings.HasSuffix(name, "+") {
+		return strings.TrimSuffix(name, "+") + "*"
+	}
+	return name
+}
+
+func splitRange(s string) (first, last string, ok bool) {
+	parts := strings.SplitN(s, "-", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+func parseMarkAndMask(s string) (mark, mask uint32, err error) {
+	parts := strings.SplitN(s, "/", 2)
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid mark %q", s)
+	}
+	markVal, err := strconv.ParseUint(parts[0], 0, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	maskVal, err := strconv.ParseUint(parts[1], 0, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	return uint32(markVal), uint32(maskVal), nil
+}
+
+// flagOptions are the module options that don't take a value.
+var flagOptions = map[string]bool{
+	"--validmark":       true,
+	"--invert":          true,
+	"--accept-local":    true,
+	"--limit-iface-out": true,
+}
+
+// parseOptions parses the options of a match module.  Options that take several words, such as
+// "--match-set <name> src", have their values joined with spaces.  A "!" anywhere negates the
+// match.
+func parseOptions(words []string) (opts map[string]string, flags map[string]bool, op string, err error) {
+	opts = map[string]string{}
+	flags = map[string]bool{}
+	op = "=="
+	var current string
+	for _, w := range words {
+		switch {
+		case w == "!":
+			op = "!="
+		case flagOptions[w]:
+			flags[w] = true
+			current = ""
+		case strings.HasPrefix(w, "--"):
+			current = w
+			opts[current] = ""
+		case current != "":
+			if opts[current] != "" {
+				opts[current] += " "
+			}
+			opts[current] += w
+		default:
+			return nil, nil, "", fmt.Errorf("unexpected option %q", w)
+		}
+	}
+	return
+}
+
+// splitWords splits a match fragment into words, honouring double quotes.
+func splitWords(frag string) ([]string, error) {
+	var words []string
+	var current strings.Builder
+	inWord, inQuotes := false, false
+	for _, r := range frag {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			inWord = true
+		case r == ' ' && !inQuotes:
+			if inWord {
+				words = append(words, current.String())
+				current.Reset()
+				inWord = false
+			}
+		default:
+			current.WriteRune(r)
+			inWord = true
+		}
+	}
+	if inQuotes {
+		return nil, fmt.Errorf("unterminated quote in %q", frag)
+	}
+	if inWord {
+		words = append(words, current.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nftables
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/iptables"
+)
+
+var testTranslator = translator{
+	ipVersion: 4,
+	chainName: func(name string) string { return "filter-" + name },
+}
+
+var _ = DescribeTable("Match translation",
+	func(match iptables.MatchCriteria, expected string) {
+		exprs, err := testTranslator.translateRule(iptables.Rule{Match: match})
+		Expect(err).NotTo(HaveOccurred())
+		// Drop the counter.
+		exprs = exprs[:len(exprs)-1]
+		rendered, err := json.Marshal(exprs)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(rendered)).To(MatchJSON(expected))
+	},
+
+	Entry("mark", iptables.Match().MarkMatchesWithMask(0x10, 0x30),
+		`[{"match":{"op":"==","left":{"&":[{"meta":{"key":"mark"}},48]},"right":16}}]`),
+	Entry("mark clear", iptables.Match().MarkClear(0x10),
+		`[{"match":{"op":"==","left":{"&":[{"meta":{"key":"mark"}},16]},"right":0}}]`),
+	Entry("mark not clear", iptables.Match().MarkNotClear(0x10),
+		`[{"match":{"op":"!=","left":{"&":[{"meta":{"key":"mark"}},16]},"right":0}}]`),
+	Entry("interface wildcard", iptables.Match().InInterface("cali+"),
+		`[{"match":{"op":"==","left":{"meta":{"key":"iifname"}},"right":"cali*"}}]`),
+	Entry("out interface", iptables.Match().OutInterface("eth0"),
+		`[{"match":{"op":"==","left":{"meta":{"key":"oifname"}},"right":"eth0"}}]`),
+	Entry("protocol", iptables.Match().Protocol("tcp"),
+		`[{"match":{"op":"==","left":{"meta":{"key":"l4proto"}},"right":"tcp"}}]`),
+	Entry("not protocol number", iptables.Match().NotProtocolNum(4),
+		`[{"match":{"op":"!=","left":{"meta":{"key":"l4proto"}},"right":4}}]`),
+	Entry("source net", iptables.Match().SourceNet("10.0.0.0/8"),
+		`[{"match":{"op":"==","left":{"payload":{"protocol":"ip","field":"saddr"}},"right":{"prefix":{"addr":"10.0.0.0","len":8}}}}]`),
+	Entry("not dest IP", iptables.Match().NotDestNet("10.0.0.1"),
+		`[{"match":{"op":"!=","left":{"payload":{"protocol":"ip","field":"daddr"}},"right":"10.0.0.1"}}]`),
+	Entry("source IP set", iptables.Match().SourceIPSet("cali40s:abc"),
+		`[{"match":{"op":"==","left":{"payload":{"protocol":"ip","field":"saddr"}},"right":"@cali40s:abc"}}]`),
+	Entry("not dest IP,port set", iptables.Match().NotDestIPPortSet("cali40s:abc"),
+		`[{"match":{"op":"!=","left":{"concat":[{"payload":{"protocol":"ip","field":"daddr"}},{"meta":{"key":"l4proto"}},{"payload":{"protocol":"th","field":"dport"}}]},"right":"@cali40s:abc"}}]`),
+	Entry("port ranges", iptables.Match().DestPortRanges([]iptables.PortRange{{First: 80, Last: 80}, {First: 1000, Last: 2000}}),
+		`[{"match":{"op":"==","left":{"payload":{"protocol":"th","field":"dport"}},"right":{"set":[80,{"range":[1000,2000]}]}}}]`),
+	Entry("not source ports", iptables.Match().NotSourcePorts(53),
+		`[{"match":{"op":"!=","left":{"payload":{"protocol":"th","field":"sport"}},"right":{"set":[53]}}}]`),
+	Entry("ICMP type", iptables.Match().ICMPType(8),
+		`[{"match":{"op":"==","left":{"payload":{"protocol":"icmp","field":"type"}},"right":8}}]`),
+	Entry("ICMPv6 type and code", iptables.Match().NotICMPV6TypeAndCode(1, 2),
+		`[{"match":{"op":"!=","left":{"concat":[{"payload":{"protocol":"icmpv6","field":"type"}},{"payload":{"protocol":"icmpv6","field":"code"}}]},"right":{"concat":[1,2]}}}]`),
+	Entry("conntrack state", iptables.Match().ConntrackState("RELATED,ESTABLISHED"),
+		`[{"match":{"op":"==","left":{"ct":{"key":"state"}},"right":{"set":["related","established"]}}}]`),
+	Entry("dest addr type", iptables.Match().DestAddrType(iptables.AddrTypeLocal),
+		`[{"match":{"op":"==","left":{"fib":{"result":"type","flags":["daddr"]}},"right":"local"}}]`),
+	Entry("not source addr type limited to out iface", iptables.Match().NotSrcAddrType(iptables.AddrTypeLocal, true),
+		`[{"match":{"op":"!=","left":{"fib":{"result":"type","flags":["saddr","oif"]}},"right":"local"}}]`),
+	Entry("RPF check failed", iptables.Match().RPFCheckFailed(false),
+		`[{"match":{"op":"==","left":{"fib":{"result":"oif","flags":["saddr","mark","iif"]}},"right":false}}]`),
+	Entry("not source MAC", iptables.Match().NotSourceMAC("AA:BB:CC:DD:EE:FF"),
+		`[{"match":{"op":"!=","left":{"payload":{"protocol":"ether","field":"saddr"}},"right":"aa:bb:cc:dd:ee:ff"}}]`),
+	Entry("hashlimit", iptables.Match().HashLimitAbove("cali1234", 10, 20),
+		`[{"limit":{"rate":10,"per":"second","burst":20,"inv":true}}]`),
+	Entry("VXLAN VNI", iptables.Match().VXLANVNI(4096),
+		`[{"match":{"op":"==","left":{"payload":{"base":"th","offset":96,"len":24}},"right":4096}}]`),
+)
+
+var _ = DescribeTable("Unsupported matches",
+	func(match iptables.MatchCriteria) {
+		_, err := testTranslator.translateRule(iptables.Rule{Match: match})
+		Expect(err).To(HaveOccurred())
+	},
+
+	Entry("IPVS", iptables.Match().IPVSConnection()),
+	Entry("RPF accept local", iptables.Match().RPFCheckPassed(true)),
+	Entry("conntrack status", iptables.Match().ConntrackState("DNAT")),
+)
+
+var _ = DescribeTable("Action translation",
+	func(action iptables.Action, expected string) {
+		exprs, err := testTranslator.translateRule(iptables.Rule{Action: action})
+		Expect(err).NotTo(HaveOccurred())
+		// Drop the counter.
+		exprs = exprs[1:]
+		rendered, err := json.Marshal(exprs)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(rendered)).To(MatchJSON(expected))
+	},
+
+	Entry("accept", iptables.AcceptAction{}, `[{"accept":null}]`),
+	Entry("drop", iptables.DropAction{}, `[{"drop":null}]`),
+	Entry("return", iptables.ReturnAction{}, `[{"return":null}]`),
+	Entry("jump", iptables.JumpAction{Target: "cali-foo"}, `[{"jump":{"target":"filter-cali-foo"}}]`),
+	Entry("goto", iptables.GotoAction{Target: "cali-foo"}, `[{"goto":{"target":"filter-cali-foo"}}]`),
+	Entry("log", iptables.LogAction{Prefix: "calico-drop"}, `[{"log":{"prefix":"calico-drop: ","level":"notice"}}]`),
+	Entry("nflog", iptables.NflogAction{Group: 1, Prefix: "DPI"}, `[{"log":{"prefix":"DPI","group":1}}]`),
+	Entry("DNAT", iptables.DNATAction{DestAddr: "10.0.0.1", DestPort: 8080}, `[{"dnat":{"addr":"10.0.0.1","port":8080}}]`),
+	Entry("SNAT range", iptables.SNATAction{ToAddr: "10.0.0.1-10.0.0.5"},
+		`[{"snat":{"addr":{"range":["10.0.0.1","10.0.0.5"]},"flags":["fully-random"]}}]`),
+	Entry("masquerade with ports", iptables.MasqAction{ToPorts: "1000-2000"},
+		`[{"masquerade":{"port":{"range":[1000,2000]},"flags":["fully-random"]}}]`),
+	Entry("clear mark", iptables.ClearMarkAction{Mark: 0x10},
+		`[{"mangle":{"key":{"meta":{"key":"mark"}},"value":{"|":[{"&":[{"meta":{"key":"mark"}},4294967279]},0]}}}]`),
+	Entry("set masked mark", iptables.SetMaskedMarkAction{Mark: 0x10, Mask: 0x30},
+		`[{"mangle":{"key":{"meta":{"key":"mark"}},"value":{"|":[{"&":[{"meta":{"key":"mark"}},4294967247]},16]}}}]`),
+	Entry("set conn mark", iptables.SetConnMarkAction{Mark: 0x10},
+		`[{"mangle":{"key":{"ct":{"key":"mark"}},"value":16}}]`),
+	Entry("save conn mark", iptables.SaveConnMarkAction{},
+		`[{"mangle":{"key":{"ct":{"key":"mark"}},"value":{"meta":{"key":"mark"}}}}]`),
+	Entry("notrack", iptables.NoTrackAction{}, `[{"notrack":null}]`),
+)
+
+var _ = DescribeTable("Unsupported actions",
+	func(action iptables.Action) {
+		_, err := testTranslator.translateRule(iptables.Rule{Action: action})
+		Expect(err).To(HaveOccurred())
+	},
+
+	Entry("partial connmark save", iptables.SaveConnMarkAction{SaveMask: 0xff}),
+	Entry("partial connmark restore", iptables.RestoreConnMarkAction{RestoreMask: 0xff}),
+)
@@ -0,0 +1,253 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package policyapplied implements a local service that the CNI plugin can use to wait, before it
+// returns, until Felix has programmed the policy for a new workload endpoint.  That closes the
+// race where a pod's containers start (and try to use the network) before its policy exists.
+//
+// The service is plain HTTP over a Unix socket:
+//
+//	GET /v1/policy-applied?orchestrator=k8s&workload=<namespace>/<pod>&endpoint=eth0&timeout=10s
+//
+// blocks until the dataplane reports the endpoint as "up", which it only does once the endpoint's
+// policy has been programmed, and then returns 200.  If the timeout expires first, it returns 504.
+package policyapplied
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/felix/proto"
+)
+
+const (
+	Path = "/v1/policy-applied"
+
+	// DefaultTimeout is used if the request doesn't specify a timeout.
+	DefaultTimeout = 10 * time.Second
+	// MaxTimeout caps the timeout of a request so that a misbehaving client can't hold on to a
+	// connection forever.
+	MaxTimeout = 5 * time.Minute
+
+	statusUp = "up"
+)
+
+// Server tracks the status of the local workload endpoints and serves the requests of clients
+// that are waiting for an endpoint to come up.  OnEndpointStatusUpdate must be fed the
+// WorkloadEndpointStatusUpdate and WorkloadEndpointStatusRemove messages from the dataplane and
+// OnDataplaneUpdate must be fed the messages to the dataplane, before they're sent.
+type Server struct {
+	lock     sync.Mutex
+	statuses map[proto.WorkloadEndpointID]string
+	// endpoints holds the most recent update that we've queued for each endpoint, so that we
+	// know which endpoints use a policy or profile that is being removed.
+	endpoints map[proto.WorkloadEndpointID]*proto.WorkloadEndpoint
+	// waiters holds, for each endpoint that clients are waiting for, a channel that is closed
+	// when it comes up.
+	waiters map[proto.WorkloadEndpointID]*waiter
+}
+
+type waiter struct {
+	c        chan struct{}
+	refCount int
+}
+
+func NewServer() *Server {
+	return &Server{
+		statuses:  map[proto.WorkloadEndpointID]string{},
+		endpoints: map[proto.WorkloadEndpointID]*proto.WorkloadEndpoint{},
+		waiters:   map[proto.WorkloadEndpointID]*waiter{},
+	}
+}
+
+// OnDataplaneUpdate invalidates the status of any endpoint whose policy the message changes.  It
+// must be called before the message is sent to the dataplane: otherwise, a client could see the
+// endpoint's old "up" status, from before the change, and go ahead before the dataplane has
+// programmed the change.  The dataplane reports the endpoint's status again once it has.
+func (s *Server) OnDataplaneUpdate(msg interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	switch msg := msg.(type) {
+	case *proto.WorkloadEndpointUpdate:
+		s.endpoints[*msg.Id] = msg.Endpoint
+		delete(s.statuses, *msg.Id)
+	case *proto.WorkloadEndpointRemove:
+		delete(s.endpoints, *msg.Id)
+		delete(s.statuses, *msg.Id)
+	case *proto.ActivePolicyRemove:
+		for id, ep := range s.endpoints {
+			if endpointUsesPolicy(ep, msg.Id) {
+				delete(s.statuses, id)
+			}
+		}
+	case *proto.ActiveProfileRemove:
+		for id, ep := range s.endpoints {
+			if endpointUsesProfile(ep, msg.Id) {
+				delete(s.statuses, id)
+			}
+		}
+	}
+}
+
+func endpointUsesPolicy(ep *proto.WorkloadEndpoint, policyID *proto.PolicyID) bool {
+	for _, tiers := range [][]*proto.TierInfo{ep.Tiers, ep.UntrackedTiers} {
+		for _, tier := range tiers {
+			if tier.Name != policyID.Tier {
+				continue
+			}
+			for _, policies := range [][]string{tier.IngressPolicies, tier.EgressPolicies} {
+				for _, name := range policies {
+					if name == policyID.Name {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+func endpointUsesProfile(ep *proto.WorkloadEndpoint, profileID *proto.ProfileID) bool {
+	for _, name := range ep.ProfileIds {
+		if name == profileID.Name {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) OnEndpointStatusUpdate(msg interface{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	switch msg := msg.(type) {
+	case *proto.WorkloadEndpointStatusUpdate:
+		id := *msg.Id
+		s.statuses[id] = msg.Status.Status
+		if msg.Status.Status != statusUp {
+			return
+		}
+		if wtr, ok := s.waiters[id]; ok {
+			log.WithField("id", id).Debug("Endpoint is up, waking up waiting clients.")
+			close(wtr.c)
+			delete(s.waiters, id)
+		}
+	case *proto.WorkloadEndpointStatusRemove:
+		// Leave any waiters in place, the endpoint may be recreated before they time out.
+		delete(s.statuses, *msg.Id)
+	}
+}
+
+// WaitForPolicy blocks until the given endpoint is up or the context is done.
+func (s *Server) WaitForPolicy(ctx context.Context, id proto.WorkloadEndpointID) error {
+	s.lock.Lock()
+	if s.statuses[id] == statusUp {
+		s.lock.Unlock()
+		return nil
+	}
+	wtr, ok := s.waiters[id]
+	if !ok {
+		wtr = &waiter{c: make(chan struct{})}
+		s.waiters[id] = wtr
+	}
+	wtr.refCount++
+	s.lock.Unlock()
+
+	select {
+	case <-wtr.c:
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	wtr.refCount--
+	if wtr.refCount == 0 && s.waiters[id] == wtr {
+		delete(s.waiters, id)
+	}
+	return ctx.Err()
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "only GET is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	q := r.URL.Query()
+	id := proto.WorkloadEndpointID{
+		OrchestratorId: q.Get("orchestrator"),
+		WorkloadId:     q.Get("workload"),
+		EndpointId:     q.Get("endpoint"),
+	}
+	if id.OrchestratorId == "" || id.WorkloadId == "" || id.EndpointId == "" {
+		http.Error(w, "orchestrator, workload and endpoint must all be specified", http.StatusBadRequest)
+		return
+	}
+	timeout := DefaultTimeout
+	if t := q.Get("timeout"); t != "" {
+		var err error
+		timeout, err = time.ParseDuration(t)
+		if err != nil || timeout <= 0 {
+			http.Error(w, fmt.Sprintf("invalid timeout %q", t), http.StatusBadRequest)
+			return
+		}
+		if timeout > MaxTimeout {
+			timeout = MaxTimeout
+		}
+	}
+
+	logCxt := log.WithFields(log.Fields{"id": id, "timeout": timeout})
+	logCxt.Debug("Client waiting for endpoint policy.")
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	if err := s.WaitForPolicy(ctx, id); err != nil {
+		logCxt.WithError(err).Info("Timed out waiting for endpoint policy to be applied.")
+		http.Error(w, "timed out waiting for policy to be applied", http.StatusGatewayTimeout)
+		return
+	}
+	logCxt.Debug("Endpoint policy applied.")
+	_, _ = fmt.Fprintln(w, "policy applied")
+}
+
+// ListenAndServe serves the API on a Unix socket at the given path, replacing any stale socket
+// left over from a previous run.  It only returns if the server fails.
+func (s *Server) ListenAndServe(socketPath string) error {
+	if err := os.MkdirAll(path.Dir(socketPath), 0700); err != nil {
+		return err
+	}
+	if err := os.Remove(socketPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	l, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(socketPath, 0600); err != nil {
+		_ = l.Close()
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle(Path, s)
+	log.WithField("path", socketPath).Info("Serving policy applied API.")
+	return http.Serve(l, mux)
+}
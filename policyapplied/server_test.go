@@ -0,0 +1,188 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policyapplied_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	. "github.com/projectcalico/felix/policyapplied"
+	"github.com/projectcalico/felix/proto"
+)
+
+var _ = Describe("Policy applied server", func() {
+	var server *Server
+
+	id := proto.WorkloadEndpointID{OrchestratorId: "k8s", WorkloadId: "default/pod-1", EndpointId: "eth0"}
+
+	BeforeEach(func() {
+		server = NewServer()
+	})
+
+	sendStatus := func(status string) {
+		server.OnEndpointStatusUpdate(&proto.WorkloadEndpointStatusUpdate{
+			Id:     &id,
+			Status: &proto.EndpointStatus{Status: status},
+		})
+	}
+
+	waitAsync := func(timeout time.Duration) chan error {
+		c := make(chan error, 1)
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			c <- server.WaitForPolicy(ctx, id)
+		}()
+		return c
+	}
+
+	It("should return immediately for an endpoint that is already up", func() {
+		sendStatus("up")
+		Expect(server.WaitForPolicy(context.Background(), id)).To(Succeed())
+	})
+
+	It("should wake up all waiting clients when the endpoint comes up", func() {
+		c1 := waitAsync(10 * time.Second)
+		c2 := waitAsync(10 * time.Second)
+		sendStatus("down")
+		Consistently(c1, "100ms").ShouldNot(Receive())
+		sendStatus("up")
+		Eventually(c1).Should(Receive(BeNil()))
+		Eventually(c2).Should(Receive(BeNil()))
+	})
+
+	It("should time out if the endpoint doesn't come up", func() {
+		c := waitAsync(50 * time.Millisecond)
+		Eventually(c).Should(Receive(Equal(context.DeadlineExceeded)))
+	})
+
+	It("should wait again after an endpoint is removed", func() {
+		sendStatus("up")
+		server.OnEndpointStatusUpdate(&proto.WorkloadEndpointStatusRemove{Id: &id})
+		c := waitAsync(10 * time.Second)
+		Consistently(c, "100ms").ShouldNot(Receive())
+		sendStatus("up")
+		Eventually(c).Should(Receive(BeNil()))
+	})
+
+	Describe("with updates queued to the dataplane", func() {
+		BeforeEach(func() {
+			server.OnDataplaneUpdate(&proto.WorkloadEndpointUpdate{
+				Id: &id,
+				Endpoint: &proto.WorkloadEndpoint{
+					Tiers:      []*proto.TierInfo{{Name: "default", IngressPolicies: []string{"pol-1"}}},
+					ProfileIds: []string{"prof-1"},
+				},
+			})
+			sendStatus("up")
+			Expect(server.WaitForPolicy(context.Background(), id)).To(Succeed())
+		})
+
+		expectWaitUntilUp := func() {
+			c := waitAsync(10 * time.Second)
+			Consistently(c, "100ms").ShouldNot(Receive())
+			sendStatus("up")
+			Eventually(c).Should(Receive(BeNil()))
+		}
+
+		It("should wait as soon as an endpoint update is queued", func() {
+			server.OnDataplaneUpdate(&proto.WorkloadEndpointUpdate{Id: &id, Endpoint: &proto.WorkloadEndpoint{}})
+			expectWaitUntilUp()
+		})
+
+		It("should wait as soon as the endpoint's removal is queued", func() {
+			server.OnDataplaneUpdate(&proto.WorkloadEndpointRemove{Id: &id})
+			expectWaitUntilUp()
+		})
+
+		It("should wait as soon as the removal of the endpoint's policy is queued", func() {
+			server.OnDataplaneUpdate(&proto.ActivePolicyRemove{Id: &proto.PolicyID{Tier: "default", Name: "pol-1"}})
+			expectWaitUntilUp()
+		})
+
+		It("should wait as soon as the removal of the endpoint's profile is queued", func() {
+			server.OnDataplaneUpdate(&proto.ActiveProfileRemove{Id: &proto.ProfileID{Name: "prof-1"}})
+			expectWaitUntilUp()
+		})
+
+		It("should ignore the removal of other policies", func() {
+			server.OnDataplaneUpdate(&proto.ActivePolicyRemove{Id: &proto.PolicyID{Tier: "other", Name: "pol-1"}})
+			server.OnDataplaneUpdate(&proto.ActiveProfileRemove{Id: &proto.ProfileID{Name: "prof-2"}})
+			Expect(server.WaitForPolicy(context.Background(), id)).To(Succeed())
+		})
+	})
+
+	Describe("HTTP API", func() {
+		get := func(query string) *httptest.ResponseRecorder {
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, httptest.NewRequest("GET", Path+"?"+query, nil))
+			return rec
+		}
+
+		It("should return 200 once the policy is applied", func() {
+			sendStatus("up")
+			Expect(get("orchestrator=k8s&workload=default/pod-1&endpoint=eth0").Code).To(Equal(http.StatusOK))
+		})
+
+		It("should return 504 on timeout", func() {
+			rec := get("orchestrator=k8s&workload=default/pod-1&endpoint=eth0&timeout=10ms")
+			Expect(rec.Code).To(Equal(http.StatusGatewayTimeout))
+		})
+
+		It("should reject incomplete endpoint IDs", func() {
+			Expect(get("orchestrator=k8s&workload=default/pod-1").Code).To(Equal(http.StatusBadRequest))
+		})
+
+		It("should reject a bad timeout", func() {
+			rec := get("orchestrator=k8s&workload=default/pod-1&endpoint=eth0&timeout=soon")
+			Expect(rec.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+
+	It("should serve on a Unix socket", func() {
+		dir, err := ioutil.TempDir("", "policyapplied")
+		Expect(err).NotTo(HaveOccurred())
+		defer os.RemoveAll(dir)
+		socketPath := path.Join(dir, "sub", "policyapplied.sock")
+		go func() {
+			defer GinkgoRecover()
+			_ = server.ListenAndServe(socketPath)
+		}()
+		sendStatus("up")
+
+		client := http.Client{Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+			},
+		}}
+		Eventually(func() (int, error) {
+			resp, err := client.Get("http://unix" + Path + "?orchestrator=k8s&workload=default/pod-1&endpoint=eth0")
+			if err != nil {
+				return 0, err
+			}
+			defer resp.Body.Close()
+			return resp.StatusCode, nil
+		}).Should(Equal(http.StatusOK))
+	})
+})
@@ -0,0 +1,89 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package proto
+
+// ProtocolVersion is the version of the protocol that this calculation engine speaks.  It is
+// sent in the ProtocolCapabilities handshake message.  Bump it when changing the meaning of an
+// existing message; adding a message only needs a new capability.
+const ProtocolVersion = 1
+
+// Capabilities name the groups of messages that the calculation engine may send to the
+// dataplane driver.  A driver lists the capabilities that it supports in its reply to the
+// handshake; the calculation engine doesn't send messages for the other capabilities.
+const (
+	// CapabilityCore covers the messages that every driver must handle: config, in-sync,
+	// IP sets, policies, profiles, endpoints and host metadata.
+	CapabilityCore            = "core"
+	CapabilityIPAMPools       = "ipam-pools"
+	CapabilityServiceAccounts = "service-accounts"
+	CapabilityNamespaces      = "namespaces"
+	CapabilityRoutes          = "routes"
+	CapabilityVXLAN           = "vxlan"
+	CapabilityWireguard       = "wireguard"
+	CapabilityGlobalBGPConfig = "global-bgp-config"
+	CapabilityStaticRoutes    = "static-routes"
+)
+
+// AllCapabilities lists every capability that this calculation engine may send.
+var AllCapabilities = []string{
+	CapabilityCore,
+	CapabilityIPAMPools,
+	CapabilityServiceAccounts,
+	CapabilityNamespaces,
+	CapabilityRoutes,
+	CapabilityVXLAN,
+	CapabilityWireguard,
+	CapabilityGlobalBGPConfig,
+	CapabilityStaticRoutes,
+}
+
+// LegacyCapabilities lists the capabilities that we assume a driver supports if it doesn't
+// reply to the handshake.  Such drivers predate the handshake so they only get the messages
+// that existed before it.
+var LegacyCapabilities = []string{
+	CapabilityCore,
+	CapabilityIPAMPools,
+	CapabilityServiceAccounts,
+	CapabilityNamespaces,
+	CapabilityRoutes,
+	CapabilityVXLAN,
+	CapabilityWireguard,
+	CapabilityGlobalBGPConfig,
+}
+
+// MessageCapability returns the capability that a driver needs in order to be sent the given
+// message.  Messages that aren't part of the protocol, such as the ProtocolCapabilities message
+// itself, belong to CapabilityCore.
+func MessageCapability(msg interface{}) string {
+	switch msg.(type) {
+	case *IPAMPoolUpdate, *IPAMPoolRemove:
+		return CapabilityIPAMPools
+	case *ServiceAccountUpdate, *ServiceAccountRemove:
+		return CapabilityServiceAccounts
+	case *NamespaceUpdate, *NamespaceRemove:
+		return CapabilityNamespaces
+	case *RouteUpdate, *RouteRemove:
+		return CapabilityRoutes
+	case *VXLANTunnelEndpointUpdate, *VXLANTunnelEndpointRemove:
+		return CapabilityVXLAN
+	case *WireguardEndpointUpdate, *WireguardEndpointRemove:
+		return CapabilityWireguard
+	case *GlobalBGPConfigUpdate:
+		return CapabilityGlobalBGPConfig
+	case *StaticRouteUpdate, *StaticRouteRemove:
+		return CapabilityStaticRoutes
+	}
+	return CapabilityCore
+}
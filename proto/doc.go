@@ -133,6 +133,16 @@
 //
 // Handshake
 //
+// An external dataplane driver first receives a ProtocolCapabilities message, which
+// carries the protocol version and the capabilities (groups of messages, such as
+// "wireguard" or "static-routes") that the calculation engine may send.  The driver
+// should reply with its own ProtocolCapabilities message listing the capabilities that
+// it supports; the calculation engine then skips the messages for any other
+// capability.  The "core" messages are always sent.  If the driver doesn't reply within
+// DataplaneDriverHandshakeTimeout, the calculation engine assumes that the driver
+// predates the handshake and only sends it the messages that existed at that time.
+// The in-process driver supports everything so it doesn't take part in the handshake.
+//
 // Before sending its stream of updates, the calculation engine loads and resolves
 // the configuration (from file, environment variables and the datastore) and
 // then sends a ConfigUpdate message with the resolved configuration.  This
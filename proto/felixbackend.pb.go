@@ -67,6 +67,9 @@
 		WireguardEndpointUpdate
 		WireguardEndpointRemove
 		GlobalBGPConfigUpdate
+		StaticRouteUpdate
+		StaticRouteRemove
+		ProtocolCapabilities
 */
 package proto
 
@@ -253,6 +256,9 @@ type ToDataplane struct {
 	//	*ToDataplane_WireguardEndpointUpdate
 	//	*ToDataplane_WireguardEndpointRemove
 	//	*ToDataplane_GlobalBgpConfigUpdate
+	//	*ToDataplane_StaticRouteUpdate
+	//	*ToDataplane_StaticRouteRemove
+	//	*ToDataplane_Capabilities
 	Payload isToDataplane_Payload `protobuf_oneof:"payload"`
 }
 
@@ -351,6 +357,15 @@ type ToDataplane_WireguardEndpointRemove struct {
 type ToDataplane_GlobalBgpConfigUpdate struct {
 	GlobalBgpConfigUpdate *GlobalBGPConfigUpdate `protobuf:"bytes,29,opt,name=global_bgp_config_update,json=globalBgpConfigUpdate,oneof"`
 }
+type ToDataplane_StaticRouteUpdate struct {
+	StaticRouteUpdate *StaticRouteUpdate `protobuf:"bytes,30,opt,name=static_route_update,json=staticRouteUpdate,oneof"`
+}
+type ToDataplane_StaticRouteRemove struct {
+	StaticRouteRemove *StaticRouteRemove `protobuf:"bytes,31,opt,name=static_route_remove,json=staticRouteRemove,oneof"`
+}
+type ToDataplane_Capabilities struct {
+	Capabilities *ProtocolCapabilities `protobuf:"bytes,32,opt,name=capabilities,oneof"`
+}
 
 func (*ToDataplane_InSync) isToDataplane_Payload()                  {}
 func (*ToDataplane_IpsetUpdate) isToDataplane_Payload()             {}
@@ -380,6 +395,9 @@ func (*ToDataplane_VtepRemove) isToDataplane_Payload()              {}
 func (*ToDataplane_WireguardEndpointUpdate) isToDataplane_Payload() {}
 func (*ToDataplane_WireguardEndpointRemove) isToDataplane_Payload() {}
 func (*ToDataplane_GlobalBgpConfigUpdate) isToDataplane_Payload()   {}
+func (*ToDataplane_StaticRouteUpdate) isToDataplane_Payload()       {}
+func (*ToDataplane_StaticRouteRemove) isToDataplane_Payload()       {}
+func (*ToDataplane_Capabilities) isToDataplane_Payload()            {}
 
 func (m *ToDataplane) GetPayload() isToDataplane_Payload {
 	if m != nil {
@@ -591,6 +609,27 @@ func (m *ToDataplane) GetGlobalBgpConfigUpdate() *GlobalBGPConfigUpdate {
 	return nil
 }
 
+func (m *ToDataplane) GetStaticRouteUpdate() *StaticRouteUpdate {
+	if x, ok := m.GetPayload().(*ToDataplane_StaticRouteUpdate); ok {
+		return x.StaticRouteUpdate
+	}
+	return nil
+}
+
+func (m *ToDataplane) GetStaticRouteRemove() *StaticRouteRemove {
+	if x, ok := m.GetPayload().(*ToDataplane_StaticRouteRemove); ok {
+		return x.StaticRouteRemove
+	}
+	return nil
+}
+
+func (m *ToDataplane) GetCapabilities() *ProtocolCapabilities {
+	if x, ok := m.GetPayload().(*ToDataplane_Capabilities); ok {
+		return x.Capabilities
+	}
+	return nil
+}
+
 // XXX_OneofFuncs is for the internal use of the proto package.
 func (*ToDataplane) XXX_OneofFuncs() (func(msg proto1.Message, b *proto1.Buffer) error, func(msg proto1.Message, tag, wire int, b *proto1.Buffer) (bool, error), func(msg proto1.Message) (n int), []interface{}) {
 	return _ToDataplane_OneofMarshaler, _ToDataplane_OneofUnmarshaler, _ToDataplane_OneofSizer, []interface{}{
@@ -622,6 +661,9 @@ func (*ToDataplane) XXX_OneofFuncs() (func(msg proto1.Message, b *proto1.Buffer)
 		(*ToDataplane_WireguardEndpointUpdate)(nil),
 		(*ToDataplane_WireguardEndpointRemove)(nil),
 		(*ToDataplane_GlobalBgpConfigUpdate)(nil),
+		(*ToDataplane_StaticRouteUpdate)(nil),
+		(*ToDataplane_StaticRouteRemove)(nil),
+		(*ToDataplane_Capabilities)(nil),
 	}
 }
 
@@ -769,6 +811,21 @@ func _ToDataplane_OneofMarshaler(msg proto1.Message, b *proto1.Buffer) error {
 		if err := b.EncodeMessage(x.GlobalBgpConfigUpdate); err != nil {
 			return err
 		}
+	case *ToDataplane_StaticRouteUpdate:
+		_ = b.EncodeVarint(30<<3 | proto1.WireBytes)
+		if err := b.EncodeMessage(x.StaticRouteUpdate); err != nil {
+			return err
+		}
+	case *ToDataplane_StaticRouteRemove:
+		_ = b.EncodeVarint(31<<3 | proto1.WireBytes)
+		if err := b.EncodeMessage(x.StaticRouteRemove); err != nil {
+			return err
+		}
+	case *ToDataplane_Capabilities:
+		_ = b.EncodeVarint(32<<3 | proto1.WireBytes)
+		if err := b.EncodeMessage(x.Capabilities); err != nil {
+			return err
+		}
 	case nil:
 	default:
 		return fmt.Errorf("ToDataplane.Payload has unexpected type %T", x)
@@ -1003,6 +1060,30 @@ func _ToDataplane_OneofUnmarshaler(msg proto1.Message, tag, wire int, b *proto1.
 		err := b.DecodeMessage(msg)
 		m.Payload = &ToDataplane_GlobalBgpConfigUpdate{msg}
 		return true, err
+	case 30: // payload.static_route_update
+		if wire != proto1.WireBytes {
+			return true, proto1.ErrInternalBadWireType
+		}
+		msg := new(StaticRouteUpdate)
+		err := b.DecodeMessage(msg)
+		m.Payload = &ToDataplane_StaticRouteUpdate{msg}
+		return true, err
+	case 31: // payload.static_route_remove
+		if wire != proto1.WireBytes {
+			return true, proto1.ErrInternalBadWireType
+		}
+		msg := new(StaticRouteRemove)
+		err := b.DecodeMessage(msg)
+		m.Payload = &ToDataplane_StaticRouteRemove{msg}
+		return true, err
+	case 32: // payload.capabilities
+		if wire != proto1.WireBytes {
+			return true, proto1.ErrInternalBadWireType
+		}
+		msg := new(ProtocolCapabilities)
+		err := b.DecodeMessage(msg)
+		m.Payload = &ToDataplane_Capabilities{msg}
+		return true, err
 	default:
 		return false, nil
 	}
@@ -1152,6 +1233,21 @@ func _ToDataplane_OneofSizer(msg proto1.Message) (n int) {
 		n += proto1.SizeVarint(29<<3 | proto1.WireBytes)
 		n += proto1.SizeVarint(uint64(s))
 		n += s
+	case *ToDataplane_StaticRouteUpdate:
+		s := proto1.Size(x.StaticRouteUpdate)
+		n += proto1.SizeVarint(30<<3 | proto1.WireBytes)
+		n += proto1.SizeVarint(uint64(s))
+		n += s
+	case *ToDataplane_StaticRouteRemove:
+		s := proto1.Size(x.StaticRouteRemove)
+		n += proto1.SizeVarint(31<<3 | proto1.WireBytes)
+		n += proto1.SizeVarint(uint64(s))
+		n += s
+	case *ToDataplane_Capabilities:
+		s := proto1.Size(x.Capabilities)
+		n += proto1.SizeVarint(32<<3 | proto1.WireBytes)
+		n += proto1.SizeVarint(uint64(s))
+		n += s
 	case nil:
 	default:
 		panic(fmt.Sprintf("proto: unexpected type %T in oneof", x))
@@ -1168,6 +1264,7 @@ type FromDataplane struct {
 	//	*FromDataplane_WorkloadEndpointStatusUpdate
 	//	*FromDataplane_WorkloadEndpointStatusRemove
 	//	*FromDataplane_WireguardStatusUpdate
+	//	*FromDataplane_Capabilities
 	Payload isFromDataplane_Payload `protobuf_oneof:"payload"`
 }
 
@@ -1200,6 +1297,9 @@ type FromDataplane_WorkloadEndpointStatusRemove struct {
 type FromDataplane_WireguardStatusUpdate struct {
 	WireguardStatusUpdate *WireguardStatusUpdate `protobuf:"bytes,9,opt,name=wireguard_status_update,json=wireguardStatusUpdate,oneof"`
 }
+type FromDataplane_Capabilities struct {
+	Capabilities *ProtocolCapabilities `protobuf:"bytes,10,opt,name=capabilities,oneof"`
+}
 
 func (*FromDataplane_ProcessStatusUpdate) isFromDataplane_Payload()          {}
 func (*FromDataplane_HostEndpointStatusUpdate) isFromDataplane_Payload()     {}
@@ -1207,6 +1307,7 @@ func (*FromDataplane_HostEndpointStatusRemove) isFromDataplane_Payload()     {}
 func (*FromDataplane_WorkloadEndpointStatusUpdate) isFromDataplane_Payload() {}
 func (*FromDataplane_WorkloadEndpointStatusRemove) isFromDataplane_Payload() {}
 func (*FromDataplane_WireguardStatusUpdate) isFromDataplane_Payload()        {}
+func (*FromDataplane_Capabilities) isFromDataplane_Payload()                 {}
 
 func (m *FromDataplane) GetPayload() isFromDataplane_Payload {
 	if m != nil {
@@ -1264,6 +1365,13 @@ func (m *FromDataplane) GetWireguardStatusUpdate() *WireguardStatusUpdate {
 	return nil
 }
 
+func (m *FromDataplane) GetCapabilities() *ProtocolCapabilities {
+	if x, ok := m.GetPayload().(*FromDataplane_Capabilities); ok {
+		return x.Capabilities
+	}
+	return nil
+}
+
 // XXX_OneofFuncs is for the internal use of the proto package.
 func (*FromDataplane) XXX_OneofFuncs() (func(msg proto1.Message, b *proto1.Buffer) error, func(msg proto1.Message, tag, wire int, b *proto1.Buffer) (bool, error), func(msg proto1.Message) (n int), []interface{}) {
 	return _FromDataplane_OneofMarshaler, _FromDataplane_OneofUnmarshaler, _FromDataplane_OneofSizer, []interface{}{
@@ -1273,6 +1381,7 @@ func (*FromDataplane) XXX_OneofFuncs() (func(msg proto1.Message, b *proto1.Buffe
 		(*FromDataplane_WorkloadEndpointStatusUpdate)(nil),
 		(*FromDataplane_WorkloadEndpointStatusRemove)(nil),
 		(*FromDataplane_WireguardStatusUpdate)(nil),
+		(*FromDataplane_Capabilities)(nil),
 	}
 }
 
@@ -1310,6 +1419,11 @@ func _FromDataplane_OneofMarshaler(msg proto1.Message, b *proto1.Buffer) error {
 		if err := b.EncodeMessage(x.WireguardStatusUpdate); err != nil {
 			return err
 		}
+	case *FromDataplane_Capabilities:
+		_ = b.EncodeVarint(10<<3 | proto1.WireBytes)
+		if err := b.EncodeMessage(x.Capabilities); err != nil {
+			return err
+		}
 	case nil:
 	default:
 		return fmt.Errorf("FromDataplane.Payload has unexpected type %T", x)
@@ -1368,6 +1482,14 @@ func _FromDataplane_OneofUnmarshaler(msg proto1.Message, tag, wire int, b *proto
 		err := b.DecodeMessage(msg)
 		m.Payload = &FromDataplane_WireguardStatusUpdate{msg}
 		return true, err
+	case 10: // payload.capabilities
+		if wire != proto1.WireBytes {
+			return true, proto1.ErrInternalBadWireType
+		}
+		msg := new(ProtocolCapabilities)
+		err := b.DecodeMessage(msg)
+		m.Payload = &FromDataplane_Capabilities{msg}
+		return true, err
 	default:
 		return false, nil
 	}
@@ -1407,6 +1529,11 @@ func _FromDataplane_OneofSizer(msg proto1.Message) (n int) {
 		n += proto1.SizeVarint(9<<3 | proto1.WireBytes)
 		n += proto1.SizeVarint(uint64(s))
 		n += s
+	case *FromDataplane_Capabilities:
+		s := proto1.Size(x.Capabilities)
+		n += proto1.SizeVarint(10<<3 | proto1.WireBytes)
+		n += proto1.SizeVarint(uint64(s))
+		n += s
 	case nil:
 	default:
 		panic(fmt.Sprintf("proto: unexpected type %T in oneof", x))
@@ -1442,6 +1569,8 @@ type IPSetUpdate struct {
 	Id      string                `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
 	Members []string              `protobuf:"bytes,2,rep,name=members" json:"members,omitempty"`
 	Type    IPSetUpdate_IPSetType `protobuf:"varint,3,opt,name=type,proto3,enum=felix.IPSetUpdate_IPSetType" json:"type,omitempty"`
+	// The selector that the IP set represents, if any.  Only used for diagnostics.
+	Selector string `protobuf:"bytes,4,opt,name=selector,proto3" json:"selector,omitempty"`
 }
 
 func (m *IPSetUpdate) Reset()                    { *m = IPSetUpdate{} }
@@ -1470,6 +1599,13 @@ func (m *IPSetUpdate) GetType() IPSetUpdate_IPSetType {
 	return IPSetUpdate_IP
 }
 
+func (m *IPSetUpdate) GetSelector() string {
+	if m != nil {
+		return m.Selector
+	}
+	return ""
+}
+
 type IPSetDeltaUpdate struct {
 	Id             string   `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
 	AddedMembers   []string `protobuf:"bytes,2,rep,name=added_members,json=addedMembers" json:"added_members,omitempty"`
@@ -1670,6 +1806,16 @@ type Policy struct {
 	OutboundRules []*Rule `protobuf:"bytes,2,rep,name=outbound_rules,json=outboundRules" json:"outbound_rules,omitempty"`
 	Untracked     bool    `protobuf:"varint,3,opt,name=untracked,proto3" json:"untracked,omitempty"`
 	PreDnat       bool    `protobuf:"varint,4,opt,name=pre_dnat,json=preDnat,proto3" json:"pre_dnat,omitempty"`
+	// Action for packets that reach the end of the policy's rules without matching one: "allow",
+	// "deny" or "next-tier".  If empty, such packets continue to the next policy, as normal.
+	DefaultAction string `protobuf:"bytes,6,opt,name=default_action,json=defaultAction,proto3" json:"default_action,omitempty"`
+	// If true, packets that reach the end of the policy's rules are logged before applying
+	// default_action.  Together with an "allow" default action, this gives an "audit mode" policy.
+	LogDefaultAction bool `protobuf:"varint,7,opt,name=log_default_action,json=logDefaultAction,proto3" json:"log_default_action,omitempty"`
+	// If not empty, the policy is only active during these windows, each of the form
+	// "<minute> <hour> <day-of-month> <month> <day-of-week> <duration>".  Outside the windows, the
+	// dataplane treats the policy as if it had no rules.
+	ActivationWindows []string `protobuf:"bytes,8,rep,name=activation_windows,json=activationWindows" json:"activation_windows,omitempty"`
 }
 
 func (m *Policy) Reset()                    { *m = Policy{} }
@@ -1712,6 +1858,27 @@ func (m *Policy) GetPreDnat() bool {
 	return false
 }
 
+func (m *Policy) GetDefaultAction() string {
+	if m != nil {
+		return m.DefaultAction
+	}
+	return ""
+}
+
+func (m *Policy) GetLogDefaultAction() bool {
+	if m != nil {
+		return m.LogDefaultAction
+	}
+	return false
+}
+
+func (m *Policy) GetActivationWindows() []string {
+	if m != nil {
+		return m.ActivationWindows
+	}
+	return nil
+}
+
 type Rule struct {
 	Action    string    `protobuf:"bytes,1,opt,name=action,proto3" json:"action,omitempty"`
 	IpVersion IPVersion `protobuf:"varint,2,opt,name=ip_version,json=ipVersion,proto3,enum=felix.IPVersion" json:"ip_version,omitempty"`
@@ -2573,15 +2740,35 @@ func (m *WorkloadEndpointUpdate) GetEndpoint() *WorkloadEndpoint {
 }
 
 type WorkloadEndpoint struct {
-	State      string      `protobuf:"bytes,1,opt,name=state,proto3" json:"state,omitempty"`
-	Name       string      `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
-	Mac        string      `protobuf:"bytes,3,opt,name=mac,proto3" json:"mac,omitempty"`
-	ProfileIds []string    `protobuf:"bytes,4,rep,name=profile_ids,json=profileIds" json:"profile_ids,omitempty"`
-	Ipv4Nets   []string    `protobuf:"bytes,5,rep,name=ipv4_nets,json=ipv4Nets" json:"ipv4_nets,omitempty"`
-	Ipv6Nets   []string    `protobuf:"bytes,6,rep,name=ipv6_nets,json=ipv6Nets" json:"ipv6_nets,omitempty"`
-	Tiers      []*TierInfo `protobuf:"bytes,7,rep,name=tiers" json:"tiers,omitempty"`
-	Ipv4Nat    []*NatInfo  `protobuf:"bytes,8,rep,name=ipv4_nat,json=ipv4Nat" json:"ipv4_nat,omitempty"`
-	Ipv6Nat    []*NatInfo  `protobuf:"bytes,9,rep,name=ipv6_nat,json=ipv6Nat" json:"ipv6_nat,omitempty"`
+	State          string      `protobuf:"bytes,1,opt,name=state,proto3" json:"state,omitempty"`
+	Name           string      `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Mac            string      `protobuf:"bytes,3,opt,name=mac,proto3" json:"mac,omitempty"`
+	ProfileIds     []string    `protobuf:"bytes,4,rep,name=profile_ids,json=profileIds" json:"profile_ids,omitempty"`
+	Ipv4Nets       []string    `protobuf:"bytes,5,rep,name=ipv4_nets,json=ipv4Nets" json:"ipv4_nets,omitempty"`
+	Ipv6Nets       []string    `protobuf:"bytes,6,rep,name=ipv6_nets,json=ipv6Nets" json:"ipv6_nets,omitempty"`
+	Tiers          []*TierInfo `protobuf:"bytes,7,rep,name=tiers" json:"tiers,omitempty"`
+	Ipv4Nat        []*NatInfo  `protobuf:"bytes,8,rep,name=ipv4_nat,json=ipv4Nat" json:"ipv4_nat,omitempty"`
+	Ipv6Nat        []*NatInfo  `protobuf:"bytes,9,rep,name=ipv6_nat,json=ipv6Nat" json:"ipv6_nat,omitempty"`
+	UntrackedTiers []*TierInfo `protobuf:"bytes,10,rep,name=untracked_tiers,json=untrackedTiers" json:"untracked_tiers,omitempty"`
+	// Source address to use when SNATing the endpoint's egress traffic, if the
+	// endpoint requested a specific one.
+	EgressSnatAddress string `protobuf:"bytes,11,opt,name=egress_snat_address,json=egressSnatAddress,proto3" json:"egress_snat_address,omitempty"`
+	// Per-endpoint override of the new connection rate limit (in connections
+	// per second), if the endpoint has one.
+	ConnectionRateLimit string `protobuf:"bytes,12,opt,name=connection_rate_limit,json=connectionRateLimit,proto3" json:"connection_rate_limit,omitempty"`
+	// Additional source CIDRs that the endpoint is allowed to send from, on top of
+	// its ipv4_nets and ipv6_nets.  For example, VMs that own extra addresses.
+	AllowedSourcePrefixes []string `protobuf:"bytes,13,rep,name=allowed_source_prefixes,json=allowedSourcePrefixes" json:"allowed_source_prefixes,omitempty"`
+	// Per-endpoint override of workload MAC enforcement ("true" or "false"), if
+	// the endpoint has one.
+	EnforceMac string `protobuf:"bytes,14,opt,name=enforce_mac,json=enforceMac,proto3" json:"enforce_mac,omitempty"`
+	// The endpoint's namespace and service account, if known.  Only used to
+	// identify the endpoint in logs and diagnostics.
+	Namespace      string `protobuf:"bytes,15,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	ServiceAccount string `protobuf:"bytes,16,opt,name=service_account,json=serviceAccount,proto3" json:"service_account,omitempty"`
+	// Per-endpoint override of DefaultEndpointToHostAction ("DROP", "RETURN" or
+	// "ACCEPT"), if the endpoint has one.
+	EndpointToHostAction string `protobuf:"bytes,17,opt,name=endpoint_to_host_action,json=endpointToHostAction,proto3" json:"endpoint_to_host_action,omitempty"`
 }
 
 func (m *WorkloadEndpoint) Reset()                    { *m = WorkloadEndpoint{} }
@@ -2652,6 +2839,62 @@ func (m *WorkloadEndpoint) GetIpv6Nat() []*NatInfo {
 	return nil
 }
 
+func (m *WorkloadEndpoint) GetUntrackedTiers() []*TierInfo {
+	if m != nil {
+		return m.UntrackedTiers
+	}
+	return nil
+}
+
+func (m *WorkloadEndpoint) GetEgressSnatAddress() string {
+	if m != nil {
+		return m.EgressSnatAddress
+	}
+	return ""
+}
+
+func (m *WorkloadEndpoint) GetConnectionRateLimit() string {
+	if m != nil {
+		return m.ConnectionRateLimit
+	}
+	return ""
+}
+
+func (m *WorkloadEndpoint) GetAllowedSourcePrefixes() []string {
+	if m != nil {
+		return m.AllowedSourcePrefixes
+	}
+	return nil
+}
+
+func (m *WorkloadEndpoint) GetEnforceMac() string {
+	if m != nil {
+		return m.EnforceMac
+	}
+	return ""
+}
+
+func (m *WorkloadEndpoint) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
+func (m *WorkloadEndpoint) GetServiceAccount() string {
+	if m != nil {
+		return m.ServiceAccount
+	}
+	return ""
+}
+
+func (m *WorkloadEndpoint) GetEndpointToHostAction() string {
+	if m != nil {
+		return m.EndpointToHostAction
+	}
+	return ""
+}
+
 type WorkloadEndpointRemove struct {
 	Id *WorkloadEndpointID `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
 }
@@ -3006,6 +3249,8 @@ func (m *WireguardStatusUpdate) GetPublicKey() string {
 type HostMetadataUpdate struct {
 	Hostname string `protobuf:"bytes,1,opt,name=hostname,proto3" json:"hostname,omitempty"`
 	Ipv4Addr string `protobuf:"bytes,2,opt,name=ipv4_addr,json=ipv4Addr,proto3" json:"ipv4_addr,omitempty"`
+	// The host's IPv6 address, if it has one.  Either address may be empty, but not both.
+	Ipv6Addr string `protobuf:"bytes,3,opt,name=ipv6_addr,json=ipv6Addr,proto3" json:"ipv6_addr,omitempty"`
 }
 
 func (m *HostMetadataUpdate) Reset()                    { *m = HostMetadataUpdate{} }
@@ -3027,6 +3272,13 @@ func (m *HostMetadataUpdate) GetIpv4Addr() string {
 	return ""
 }
 
+func (m *HostMetadataUpdate) GetIpv6Addr() string {
+	if m != nil {
+		return m.Ipv6Addr
+	}
+	return ""
+}
+
 type HostMetadataRemove struct {
 	Hostname string `protobuf:"bytes,1,opt,name=hostname,proto3" json:"hostname,omitempty"`
 	Ipv4Addr string `protobuf:"bytes,2,opt,name=ipv4_addr,json=ipv4Addr,proto3" json:"ipv4_addr,omitempty"`
@@ -3436,6 +3688,8 @@ type WireguardEndpointUpdate struct {
 	PublicKey string `protobuf:"bytes,2,opt,name=public_key,json=publicKey,proto3" json:"public_key,omitempty"`
 	// The IP address of the wireguard interface.
 	InterfaceIpv4Addr string `protobuf:"bytes,3,opt,name=interface_ipv4_addr,json=interfaceIpv4Addr,proto3" json:"interface_ipv4_addr,omitempty"`
+	// Whether the node carries the label configured by WireguardEncryptionLabel.
+	HasEncryptionLabel bool `protobuf:"varint,4,opt,name=has_encryption_label,json=hasEncryptionLabel,proto3" json:"has_encryption_label,omitempty"`
 }
 
 func (m *WireguardEndpointUpdate) Reset()         { *m = WireguardEndpointUpdate{} }
@@ -3466,6 +3720,13 @@ func (m *WireguardEndpointUpdate) GetInterfaceIpv4Addr() string {
 	return ""
 }
 
+func (m *WireguardEndpointUpdate) GetHasEncryptionLabel() bool {
+	if m != nil {
+		return m.HasEncryptionLabel
+	}
+	return false
+}
+
 type WireguardEndpointRemove struct {
 	// The name of the wireguard host.
 	Hostname string `protobuf:"bytes,1,opt,name=hostname,proto3" json:"hostname,omitempty"`
@@ -3519,6 +3780,100 @@ func (m *GlobalBGPConfigUpdate) GetServiceLoadbalancerCidrs() []string {
 	return nil
 }
 
+type StaticRouteUpdate struct {
+	Id      string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Dst     string `protobuf:"bytes,2,opt,name=dst,proto3" json:"dst,omitempty"`
+	NextHop string `protobuf:"bytes,3,opt,name=next_hop,json=nextHop,proto3" json:"next_hop,omitempty"`
+	Device  string `protobuf:"bytes,4,opt,name=device,proto3" json:"device,omitempty"`
+	Table   uint32 `protobuf:"varint,5,opt,name=table,proto3" json:"table,omitempty"`
+}
+
+func (m *StaticRouteUpdate) Reset()         { *m = StaticRouteUpdate{} }
+func (m *StaticRouteUpdate) String() string { return proto1.CompactTextString(m) }
+func (*StaticRouteUpdate) ProtoMessage()    {}
+func (*StaticRouteUpdate) Descriptor() ([]byte, []int) {
+	return fileDescriptorFelixbackend, []int{59}
+}
+
+func (m *StaticRouteUpdate) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+func (m *StaticRouteUpdate) GetDst() string {
+	if m != nil {
+		return m.Dst
+	}
+	return ""
+}
+
+func (m *StaticRouteUpdate) GetNextHop() string {
+	if m != nil {
+		return m.NextHop
+	}
+	return ""
+}
+
+func (m *StaticRouteUpdate) GetDevice() string {
+	if m != nil {
+		return m.Device
+	}
+	return ""
+}
+
+func (m *StaticRouteUpdate) GetTable() uint32 {
+	if m != nil {
+		return m.Table
+	}
+	return 0
+}
+
+type StaticRouteRemove struct {
+	Id string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+}
+
+func (m *StaticRouteRemove) Reset()         { *m = StaticRouteRemove{} }
+func (m *StaticRouteRemove) String() string { return proto1.CompactTextString(m) }
+func (*StaticRouteRemove) ProtoMessage()    {}
+func (*StaticRouteRemove) Descriptor() ([]byte, []int) {
+	return fileDescriptorFelixbackend, []int{60}
+}
+
+func (m *StaticRouteRemove) GetId() string {
+	if m != nil {
+		return m.Id
+	}
+	return ""
+}
+
+type ProtocolCapabilities struct {
+	ProtocolVersion uint32   `protobuf:"varint,1,opt,name=protocol_version,json=protocolVersion,proto3" json:"protocol_version,omitempty"`
+	Capabilities    []string `protobuf:"bytes,2,rep,name=capabilities" json:"capabilities,omitempty"`
+}
+
+func (m *ProtocolCapabilities) Reset()         { *m = ProtocolCapabilities{} }
+func (m *ProtocolCapabilities) String() string { return proto1.CompactTextString(m) }
+func (*ProtocolCapabilities) ProtoMessage()    {}
+func (*ProtocolCapabilities) Descriptor() ([]byte, []int) {
+	return fileDescriptorFelixbackend, []int{61}
+}
+
+func (m *ProtocolCapabilities) GetProtocolVersion() uint32 {
+	if m != nil {
+		return m.ProtocolVersion
+	}
+	return 0
+}
+
+func (m *ProtocolCapabilities) GetCapabilities() []string {
+	if m != nil {
+		return m.Capabilities
+	}
+	return nil
+}
+
 func init() {
 	proto1.RegisterType((*SyncRequest)(nil), "felix.SyncRequest")
 	proto1.RegisterType((*ToDataplane)(nil), "felix.ToDataplane")
@@ -3580,6 +3935,9 @@ func init() {
 	proto1.RegisterType((*WireguardEndpointUpdate)(nil), "felix.WireguardEndpointUpdate")
 	proto1.RegisterType((*WireguardEndpointRemove)(nil), "felix.WireguardEndpointRemove")
 	proto1.RegisterType((*GlobalBGPConfigUpdate)(nil), "felix.GlobalBGPConfigUpdate")
+	proto1.RegisterType((*StaticRouteUpdate)(nil), "felix.StaticRouteUpdate")
+	proto1.RegisterType((*StaticRouteRemove)(nil), "felix.StaticRouteRemove")
+	proto1.RegisterType((*ProtocolCapabilities)(nil), "felix.ProtocolCapabilities")
 	proto1.RegisterEnum("felix.IPVersion", IPVersion_name, IPVersion_value)
 	proto1.RegisterEnum("felix.RouteType", RouteType_name, RouteType_value)
 	proto1.RegisterEnum("felix.IPPoolType", IPPoolType_name, IPPoolType_value)
@@ -4191,6 +4549,54 @@ func (m *ToDataplane_GlobalBgpConfigUpdate) MarshalTo(dAtA []byte) (int, error)
 	}
 	return i, nil
 }
+func (m *ToDataplane_StaticRouteUpdate) MarshalTo(dAtA []byte) (int, error) {
+	i := 0
+	if m.StaticRouteUpdate != nil {
+		dAtA[i] = 0xf2
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(m.StaticRouteUpdate.Size()))
+		n30, err := m.StaticRouteUpdate.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n30
+	}
+	return i, nil
+}
+func (m *ToDataplane_StaticRouteRemove) MarshalTo(dAtA []byte) (int, error) {
+	i := 0
+	if m.StaticRouteRemove != nil {
+		dAtA[i] = 0xfa
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(m.StaticRouteRemove.Size()))
+		n31, err := m.StaticRouteRemove.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n31
+	}
+	return i, nil
+}
+func (m *ToDataplane_Capabilities) MarshalTo(dAtA []byte) (int, error) {
+	i := 0
+	if m.Capabilities != nil {
+		dAtA[i] = 0x82
+		i++
+		dAtA[i] = 0x2
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(m.Capabilities.Size()))
+		n73, err := m.Capabilities.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n73
+	}
+	return i, nil
+}
 func (m *FromDataplane) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
@@ -4305,6 +4711,20 @@ func (m *FromDataplane_WireguardStatusUpdate) MarshalTo(dAtA []byte) (int, error
 	}
 	return i, nil
 }
+func (m *FromDataplane_Capabilities) MarshalTo(dAtA []byte) (int, error) {
+	i := 0
+	if m.Capabilities != nil {
+		dAtA[i] = 0x52
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(m.Capabilities.Size()))
+		n74, err := m.Capabilities.MarshalTo(dAtA[i:])
+		if err != nil {
+			return 0, err
+		}
+		i += n74
+	}
+	return i, nil
+}
 func (m *ConfigUpdate) Marshal() (dAtA []byte, err error) {
 	size := m.Size()
 	dAtA = make([]byte, size)
@@ -4399,6 +4819,12 @@ func (m *IPSetUpdate) MarshalTo(dAtA []byte) (int, error) {
 		i++
 		i = encodeVarintFelixbackend(dAtA, i, uint64(m.Type))
 	}
+	if len(m.Selector) > 0 {
+		dAtA[i] = 0x22
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(len(m.Selector)))
+		i += copy(dAtA[i:], m.Selector)
+	}
 	return i, nil
 }
 
@@ -4773,29 +5199,60 @@ func (m *Policy) MarshalTo(dAtA []byte) (int, error) {
 		i = encodeVarintFelixbackend(dAtA, i, uint64(len(m.Namespace)))
 		i += copy(dAtA[i:], m.Namespace)
 	}
-	return i, nil
-}
-
-func (m *Rule) Marshal() (dAtA []byte, err error) {
-	size := m.Size()
-	dAtA = make([]byte, size)
-	n, err := m.MarshalTo(dAtA)
-	if err != nil {
-		return nil, err
-	}
-	return dAtA[:n], nil
-}
-
-func (m *Rule) MarshalTo(dAtA []byte) (int, error) {
-	var i int
-	_ = i
-	var l int
-	_ = l
-	if len(m.Action) > 0 {
-		dAtA[i] = 0xa
+	if len(m.DefaultAction) > 0 {
+		dAtA[i] = 0x32
 		i++
-		i = encodeVarintFelixbackend(dAtA, i, uint64(len(m.Action)))
-		i += copy(dAtA[i:], m.Action)
+		i = encodeVarintFelixbackend(dAtA, i, uint64(len(m.DefaultAction)))
+		i += copy(dAtA[i:], m.DefaultAction)
+	}
+	if m.LogDefaultAction {
+		dAtA[i] = 0x38
+		i++
+		if m.LogDefaultAction {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
+	if len(m.ActivationWindows) > 0 {
+		for _, s := range m.ActivationWindows {
+			dAtA[i] = 0x42
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
+		}
+	}
+	return i, nil
+}
+
+func (m *Rule) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *Rule) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Action) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(len(m.Action)))
+		i += copy(dAtA[i:], m.Action)
 	}
 	if m.IpVersion != 0 {
 		dAtA[i] = 0x10
@@ -5679,6 +6136,73 @@ func (m *WorkloadEndpoint) MarshalTo(dAtA []byte) (int, error) {
 			i += n
 		}
 	}
+	if len(m.UntrackedTiers) > 0 {
+		for _, msg := range m.UntrackedTiers {
+			dAtA[i] = 0x52
+			i++
+			i = encodeVarintFelixbackend(dAtA, i, uint64(msg.Size()))
+			n, err := msg.MarshalTo(dAtA[i:])
+			if err != nil {
+				return 0, err
+			}
+			i += n
+		}
+	}
+	if len(m.EgressSnatAddress) > 0 {
+		dAtA[i] = 0x5a
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(len(m.EgressSnatAddress)))
+		i += copy(dAtA[i:], m.EgressSnatAddress)
+	}
+	if len(m.ConnectionRateLimit) > 0 {
+		dAtA[i] = 0x62
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(len(m.ConnectionRateLimit)))
+		i += copy(dAtA[i:], m.ConnectionRateLimit)
+	}
+	if len(m.AllowedSourcePrefixes) > 0 {
+		for _, s := range m.AllowedSourcePrefixes {
+			dAtA[i] = 0x6a
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
+		}
+	}
+	if len(m.EnforceMac) > 0 {
+		dAtA[i] = 0x72
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(len(m.EnforceMac)))
+		i += copy(dAtA[i:], m.EnforceMac)
+	}
+	if len(m.Namespace) > 0 {
+		dAtA[i] = 0x7a
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(len(m.Namespace)))
+		i += copy(dAtA[i:], m.Namespace)
+	}
+	if len(m.ServiceAccount) > 0 {
+		dAtA[i] = 0x82
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(len(m.ServiceAccount)))
+		i += copy(dAtA[i:], m.ServiceAccount)
+	}
+	if len(m.EndpointToHostAction) > 0 {
+		dAtA[i] = 0x8a
+		i++
+		dAtA[i] = 0x1
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(len(m.EndpointToHostAction)))
+		i += copy(dAtA[i:], m.EndpointToHostAction)
+	}
 	return i, nil
 }
 
@@ -6238,6 +6762,12 @@ func (m *HostMetadataUpdate) MarshalTo(dAtA []byte) (int, error) {
 		i = encodeVarintFelixbackend(dAtA, i, uint64(len(m.Ipv4Addr)))
 		i += copy(dAtA[i:], m.Ipv4Addr)
 	}
+	if len(m.Ipv6Addr) > 0 {
+		dAtA[i] = 0x1a
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(len(m.Ipv6Addr)))
+		i += copy(dAtA[i:], m.Ipv6Addr)
+	}
 	return i, nil
 }
 
@@ -6820,6 +7350,16 @@ func (m *WireguardEndpointUpdate) MarshalTo(dAtA []byte) (int, error) {
 		i = encodeVarintFelixbackend(dAtA, i, uint64(len(m.InterfaceIpv4Addr)))
 		i += copy(dAtA[i:], m.InterfaceIpv4Addr)
 	}
+	if m.HasEncryptionLabel {
+		dAtA[i] = 0x20
+		i++
+		if m.HasEncryptionLabel {
+			dAtA[i] = 1
+		} else {
+			dAtA[i] = 0
+		}
+		i++
+	}
 	return i, nil
 }
 
@@ -6910,6 +7450,115 @@ func (m *GlobalBGPConfigUpdate) MarshalTo(dAtA []byte) (int, error) {
 	return i, nil
 }
 
+func (m *StaticRouteUpdate) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *StaticRouteUpdate) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Id) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(len(m.Id)))
+		i += copy(dAtA[i:], m.Id)
+	}
+	if len(m.Dst) > 0 {
+		dAtA[i] = 0x12
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(len(m.Dst)))
+		i += copy(dAtA[i:], m.Dst)
+	}
+	if len(m.NextHop) > 0 {
+		dAtA[i] = 0x1a
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(len(m.NextHop)))
+		i += copy(dAtA[i:], m.NextHop)
+	}
+	if len(m.Device) > 0 {
+		dAtA[i] = 0x22
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(len(m.Device)))
+		i += copy(dAtA[i:], m.Device)
+	}
+	if m.Table != 0 {
+		dAtA[i] = 0x28
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(m.Table))
+	}
+	return i, nil
+}
+
+func (m *StaticRouteRemove) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *StaticRouteRemove) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if len(m.Id) > 0 {
+		dAtA[i] = 0xa
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(len(m.Id)))
+		i += copy(dAtA[i:], m.Id)
+	}
+	return i, nil
+}
+
+func (m *ProtocolCapabilities) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalTo(dAtA)
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *ProtocolCapabilities) MarshalTo(dAtA []byte) (int, error) {
+	var i int
+	_ = i
+	var l int
+	_ = l
+	if m.ProtocolVersion != 0 {
+		dAtA[i] = 0x8
+		i++
+		i = encodeVarintFelixbackend(dAtA, i, uint64(m.ProtocolVersion))
+	}
+	if len(m.Capabilities) > 0 {
+		for _, s := range m.Capabilities {
+			dAtA[i] = 0x12
+			i++
+			l = len(s)
+			for l >= 1<<7 {
+				dAtA[i] = uint8(uint64(l)&0x7f | 0x80)
+				l >>= 7
+				i++
+			}
+			dAtA[i] = uint8(l)
+			i++
+			i += copy(dAtA[i:], s)
+		}
+	}
+	return i, nil
+}
+
 func encodeVarintFelixbackend(dAtA []byte, offset int, v uint64) int {
 	for v >= 1<<7 {
 		dAtA[offset] = uint8(v&0x7f | 0x80)
@@ -7189,6 +7838,33 @@ func (m *ToDataplane_GlobalBgpConfigUpdate) Size() (n int) {
 	}
 	return n
 }
+func (m *ToDataplane_StaticRouteUpdate) Size() (n int) {
+	var l int
+	_ = l
+	if m.StaticRouteUpdate != nil {
+		l = m.StaticRouteUpdate.Size()
+		n += 2 + l + sovFelixbackend(uint64(l))
+	}
+	return n
+}
+func (m *ToDataplane_StaticRouteRemove) Size() (n int) {
+	var l int
+	_ = l
+	if m.StaticRouteRemove != nil {
+		l = m.StaticRouteRemove.Size()
+		n += 2 + l + sovFelixbackend(uint64(l))
+	}
+	return n
+}
+func (m *ToDataplane_Capabilities) Size() (n int) {
+	var l int
+	_ = l
+	if m.Capabilities != nil {
+		l = m.Capabilities.Size()
+		n += 2 + l + sovFelixbackend(uint64(l))
+	}
+	return n
+}
 func (m *FromDataplane) Size() (n int) {
 	var l int
 	_ = l
@@ -7255,6 +7931,15 @@ func (m *FromDataplane_WireguardStatusUpdate) Size() (n int) {
 	}
 	return n
 }
+func (m *FromDataplane_Capabilities) Size() (n int) {
+	var l int
+	_ = l
+	if m.Capabilities != nil {
+		l = m.Capabilities.Size()
+		n += 1 + l + sovFelixbackend(uint64(l))
+	}
+	return n
+}
 func (m *ConfigUpdate) Size() (n int) {
 	var l int
 	_ = l
@@ -7291,6 +7976,10 @@ func (m *IPSetUpdate) Size() (n int) {
 	if m.Type != 0 {
 		n += 1 + sovFelixbackend(uint64(m.Type))
 	}
+	l = len(m.Selector)
+	if l > 0 {
+		n += 1 + l + sovFelixbackend(uint64(l))
+	}
 	return n
 }
 
@@ -7441,6 +8130,19 @@ func (m *Policy) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovFelixbackend(uint64(l))
 	}
+	l = len(m.DefaultAction)
+	if l > 0 {
+		n += 1 + l + sovFelixbackend(uint64(l))
+	}
+	if m.LogDefaultAction {
+		n += 2
+	}
+	if len(m.ActivationWindows) > 0 {
+		for _, s := range m.ActivationWindows {
+			l = len(s)
+			n += 1 + l + sovFelixbackend(uint64(l))
+		}
+	}
 	return n
 }
 
@@ -7841,28 +8543,64 @@ func (m *WorkloadEndpoint) Size() (n int) {
 			n += 1 + l + sovFelixbackend(uint64(l))
 		}
 	}
-	return n
-}
-
-func (m *WorkloadEndpointRemove) Size() (n int) {
-	var l int
-	_ = l
-	if m.Id != nil {
-		l = m.Id.Size()
+	if len(m.UntrackedTiers) > 0 {
+		for _, e := range m.UntrackedTiers {
+			l = e.Size()
+			n += 1 + l + sovFelixbackend(uint64(l))
+		}
+	}
+	l = len(m.EgressSnatAddress)
+	if l > 0 {
 		n += 1 + l + sovFelixbackend(uint64(l))
 	}
-	return n
-}
-
-func (m *HostEndpointID) Size() (n int) {
-	var l int
-	_ = l
-	l = len(m.EndpointId)
+	l = len(m.ConnectionRateLimit)
 	if l > 0 {
 		n += 1 + l + sovFelixbackend(uint64(l))
 	}
-	return n
-}
+	if len(m.AllowedSourcePrefixes) > 0 {
+		for _, s := range m.AllowedSourcePrefixes {
+			l = len(s)
+			n += 1 + l + sovFelixbackend(uint64(l))
+		}
+	}
+	l = len(m.EnforceMac)
+	if l > 0 {
+		n += 1 + l + sovFelixbackend(uint64(l))
+	}
+	l = len(m.Namespace)
+	if l > 0 {
+		n += 1 + l + sovFelixbackend(uint64(l))
+	}
+	l = len(m.ServiceAccount)
+	if l > 0 {
+		n += 2 + l + sovFelixbackend(uint64(l))
+	}
+	l = len(m.EndpointToHostAction)
+	if l > 0 {
+		n += 2 + l + sovFelixbackend(uint64(l))
+	}
+	return n
+}
+
+func (m *WorkloadEndpointRemove) Size() (n int) {
+	var l int
+	_ = l
+	if m.Id != nil {
+		l = m.Id.Size()
+		n += 1 + l + sovFelixbackend(uint64(l))
+	}
+	return n
+}
+
+func (m *HostEndpointID) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.EndpointId)
+	if l > 0 {
+		n += 1 + l + sovFelixbackend(uint64(l))
+	}
+	return n
+}
 
 func (m *HostEndpointUpdate) Size() (n int) {
 	var l int
@@ -8068,6 +8806,10 @@ func (m *HostMetadataUpdate) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovFelixbackend(uint64(l))
 	}
+	l = len(m.Ipv6Addr)
+	if l > 0 {
+		n += 1 + l + sovFelixbackend(uint64(l))
+	}
 	return n
 }
 
@@ -8311,6 +9053,9 @@ func (m *WireguardEndpointUpdate) Size() (n int) {
 	if l > 0 {
 		n += 1 + l + sovFelixbackend(uint64(l))
 	}
+	if m.HasEncryptionLabel {
+		n += 2
+	}
 	return n
 }
 
@@ -8348,6 +9093,56 @@ func (m *GlobalBGPConfigUpdate) Size() (n int) {
 	return n
 }
 
+func (m *StaticRouteUpdate) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Id)
+	if l > 0 {
+		n += 1 + l + sovFelixbackend(uint64(l))
+	}
+	l = len(m.Dst)
+	if l > 0 {
+		n += 1 + l + sovFelixbackend(uint64(l))
+	}
+	l = len(m.NextHop)
+	if l > 0 {
+		n += 1 + l + sovFelixbackend(uint64(l))
+	}
+	l = len(m.Device)
+	if l > 0 {
+		n += 1 + l + sovFelixbackend(uint64(l))
+	}
+	if m.Table != 0 {
+		n += 1 + sovFelixbackend(uint64(m.Table))
+	}
+	return n
+}
+
+func (m *StaticRouteRemove) Size() (n int) {
+	var l int
+	_ = l
+	l = len(m.Id)
+	if l > 0 {
+		n += 1 + l + sovFelixbackend(uint64(l))
+	}
+	return n
+}
+
+func (m *ProtocolCapabilities) Size() (n int) {
+	var l int
+	_ = l
+	if m.ProtocolVersion != 0 {
+		n += 1 + sovFelixbackend(uint64(m.ProtocolVersion))
+	}
+	if len(m.Capabilities) > 0 {
+		for _, s := range m.Capabilities {
+			l = len(s)
+			n += 1 + l + sovFelixbackend(uint64(l))
+		}
+	}
+	return n
+}
+
 func sovFelixbackend(x uint64) (n int) {
 	for {
 		n++
@@ -9355,6 +10150,102 @@ func (m *ToDataplane) Unmarshal(dAtA []byte) error {
 			}
 			m.Payload = &ToDataplane_GlobalBgpConfigUpdate{v}
 			iNdEx = postIndex
+		case 30:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StaticRouteUpdate", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			v := &StaticRouteUpdate{}
+			if err := v.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			m.Payload = &ToDataplane_StaticRouteUpdate{v}
+			iNdEx = postIndex
+		case 31:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field StaticRouteRemove", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			v := &StaticRouteRemove{}
+			if err := v.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			m.Payload = &ToDataplane_StaticRouteRemove{v}
+			iNdEx = postIndex
+		case 32:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Capabilities", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			v := &ProtocolCapabilities{}
+			if err := v.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			m.Payload = &ToDataplane_Capabilities{v}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipFelixbackend(dAtA[iNdEx:])
@@ -9616,6 +10507,38 @@ func (m *FromDataplane) Unmarshal(dAtA []byte) error {
 			}
 			m.Payload = &FromDataplane_WireguardStatusUpdate{v}
 			iNdEx = postIndex
+		case 10:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Capabilities", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			v := &ProtocolCapabilities{}
+			if err := v.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			m.Payload = &FromDataplane_Capabilities{v}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipFelixbackend(dAtA[iNdEx:])
@@ -9961,6 +10884,35 @@ func (m *IPSetUpdate) Unmarshal(dAtA []byte) error {
 					break
 				}
 			}
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Selector", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Selector = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipFelixbackend(dAtA[iNdEx:])
@@ -11055,21 +12007,99 @@ func (m *Policy) Unmarshal(dAtA []byte) error {
 			}
 			m.Namespace = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipFelixbackend(dAtA[iNdEx:])
-			if err != nil {
-				return err
+		case 6:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field DefaultAction", wireType)
 			}
-			if skippy < 0 {
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthFelixbackend
 			}
-			if (iNdEx + skippy) > l {
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			iNdEx += skippy
-		}
-	}
+			m.DefaultAction = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 7:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field LogDefaultAction", wireType)
+			}
+			var v int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				v |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			m.LogDefaultAction = bool(v != 0)
+		case 8:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ActivationWindows", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ActivationWindows = append(m.ActivationWindows, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipFelixbackend(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
 
 	if iNdEx > l {
 		return io.ErrUnexpectedEOF
@@ -13469,59 +14499,9 @@ func (m *WorkloadEndpoint) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipFelixbackend(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
-				return ErrInvalidLengthFelixbackend
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			iNdEx += skippy
-		}
-	}
-
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
-	}
-	return nil
-}
-func (m *WorkloadEndpointRemove) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowFelixbackend
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= (uint64(b) & 0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: WorkloadEndpointRemove: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: WorkloadEndpointRemove: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
+		case 10:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Id", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field UntrackedTiers", wireType)
 			}
 			var msglen int
 			for shift := uint(0); ; shift += 7 {
@@ -13545,66 +14525,14 @@ func (m *WorkloadEndpointRemove) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.Id == nil {
-				m.Id = &WorkloadEndpointID{}
-			}
-			if err := m.Id.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+			m.UntrackedTiers = append(m.UntrackedTiers, &TierInfo{})
+			if err := m.UntrackedTiers[len(m.UntrackedTiers)-1].Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
 				return err
 			}
 			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipFelixbackend(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
-				return ErrInvalidLengthFelixbackend
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			iNdEx += skippy
-		}
-	}
-
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
-	}
-	return nil
-}
-func (m *HostEndpointID) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowFelixbackend
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= (uint64(b) & 0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: HostEndpointID: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: HostEndpointID: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 2:
+		case 11:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field EndpointId", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field EgressSnatAddress", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -13629,63 +14557,13 @@ func (m *HostEndpointID) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.EndpointId = string(dAtA[iNdEx:postIndex])
+			m.EgressSnatAddress = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		default:
-			iNdEx = preIndex
-			skippy, err := skipFelixbackend(dAtA[iNdEx:])
-			if err != nil {
-				return err
-			}
-			if skippy < 0 {
-				return ErrInvalidLengthFelixbackend
-			}
-			if (iNdEx + skippy) > l {
-				return io.ErrUnexpectedEOF
-			}
-			iNdEx += skippy
-		}
-	}
-
-	if iNdEx > l {
-		return io.ErrUnexpectedEOF
-	}
-	return nil
-}
-func (m *HostEndpointUpdate) Unmarshal(dAtA []byte) error {
-	l := len(dAtA)
-	iNdEx := 0
-	for iNdEx < l {
-		preIndex := iNdEx
-		var wire uint64
-		for shift := uint(0); ; shift += 7 {
-			if shift >= 64 {
-				return ErrIntOverflowFelixbackend
-			}
-			if iNdEx >= l {
-				return io.ErrUnexpectedEOF
-			}
-			b := dAtA[iNdEx]
-			iNdEx++
-			wire |= (uint64(b) & 0x7F) << shift
-			if b < 0x80 {
-				break
-			}
-		}
-		fieldNum := int32(wire >> 3)
-		wireType := int(wire & 0x7)
-		if wireType == 4 {
-			return fmt.Errorf("proto: HostEndpointUpdate: wiretype end group for non-group")
-		}
-		if fieldNum <= 0 {
-			return fmt.Errorf("proto: HostEndpointUpdate: illegal tag %d (wire type %d)", fieldNum, wire)
-		}
-		switch fieldNum {
-		case 1:
+		case 12:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Id", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field ConnectionRateLimit", wireType)
 			}
-			var msglen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowFelixbackend
@@ -13695,30 +14573,26 @@ func (m *HostEndpointUpdate) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= (int(b) & 0x7F) << shift
+				stringLen |= (uint64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthFelixbackend
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + intStringLen
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.Id == nil {
-				m.Id = &HostEndpointID{}
-			}
-			if err := m.Id.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
-				return err
-			}
+			m.ConnectionRateLimit = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		case 3:
+		case 13:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Endpoint", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field AllowedSourcePrefixes", wireType)
 			}
-			var msglen int
+			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowFelixbackend
@@ -13728,19 +14602,409 @@ func (m *HostEndpointUpdate) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				msglen |= (int(b) & 0x7F) << shift
+				stringLen |= (uint64(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			if msglen < 0 {
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
 				return ErrInvalidLengthFelixbackend
 			}
-			postIndex := iNdEx + msglen
+			postIndex := iNdEx + intStringLen
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			if m.Endpoint == nil {
+			m.AllowedSourcePrefixes = append(m.AllowedSourcePrefixes, string(dAtA[iNdEx:postIndex]))
+			iNdEx = postIndex
+		case 14:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field EnforceMac", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.EnforceMac = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 15:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Namespace", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Namespace = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 16:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ServiceAccount", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ServiceAccount = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 17:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field EndpointToHostAction", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.EndpointToHostAction = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipFelixbackend(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *WorkloadEndpointRemove) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowFelixbackend
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: WorkloadEndpointRemove: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: WorkloadEndpointRemove: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Id", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Id == nil {
+				m.Id = &WorkloadEndpointID{}
+			}
+			if err := m.Id.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipFelixbackend(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *HostEndpointID) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowFelixbackend
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: HostEndpointID: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: HostEndpointID: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field EndpointId", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.EndpointId = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipFelixbackend(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *HostEndpointUpdate) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowFelixbackend
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: HostEndpointUpdate: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: HostEndpointUpdate: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Id", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Id == nil {
+				m.Id = &HostEndpointID{}
+			}
+			if err := m.Id.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Endpoint", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= (int(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + msglen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if m.Endpoint == nil {
 				m.Endpoint = &HostEndpoint{}
 			}
 			if err := m.Endpoint.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
@@ -15063,7 +16327,36 @@ func (m *HostMetadataUpdate) Unmarshal(dAtA []byte) error {
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Hostname", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Hostname", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Hostname = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Ipv4Addr", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -15088,11 +16381,11 @@ func (m *HostMetadataUpdate) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Hostname = string(dAtA[iNdEx:postIndex])
+			m.Ipv4Addr = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		case 2:
+		case 3:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Ipv4Addr", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Ipv6Addr", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -15117,7 +16410,7 @@ func (m *HostMetadataUpdate) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Ipv4Addr = string(dAtA[iNdEx:postIndex])
+			m.Ipv6Addr = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -16671,7 +17964,252 @@ func (m *RouteUpdate) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *RouteRemove) Unmarshal(dAtA []byte) error {
+func (m *RouteRemove) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowFelixbackend
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: RouteRemove: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: RouteRemove: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Dst", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Dst = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipFelixbackend(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *VXLANTunnelEndpointUpdate) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowFelixbackend
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: VXLANTunnelEndpointUpdate: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: VXLANTunnelEndpointUpdate: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Node", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Node = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Mac", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Mac = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Ipv4Addr", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Ipv4Addr = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ParentDeviceIp", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.ParentDeviceIp = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		default:
+			iNdEx = preIndex
+			skippy, err := skipFelixbackend(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *VXLANTunnelEndpointRemove) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -16694,15 +18232,15 @@ func (m *RouteRemove) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: RouteRemove: wiretype end group for non-group")
+			return fmt.Errorf("proto: VXLANTunnelEndpointRemove: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: RouteRemove: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: VXLANTunnelEndpointRemove: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
-		case 2:
+		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Dst", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Node", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -16727,7 +18265,7 @@ func (m *RouteRemove) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Dst = string(dAtA[iNdEx:postIndex])
+			m.Node = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -16750,7 +18288,7 @@ func (m *RouteRemove) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *VXLANTunnelEndpointUpdate) Unmarshal(dAtA []byte) error {
+func (m *WireguardEndpointUpdate) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -16773,15 +18311,15 @@ func (m *VXLANTunnelEndpointUpdate) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: VXLANTunnelEndpointUpdate: wiretype end group for non-group")
+			return fmt.Errorf("proto: WireguardEndpointUpdate: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: VXLANTunnelEndpointUpdate: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: WireguardEndpointUpdate: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Node", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Hostname", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -16806,11 +18344,11 @@ func (m *VXLANTunnelEndpointUpdate) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Node = string(dAtA[iNdEx:postIndex])
+			m.Hostname = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Mac", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field PublicKey", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -16835,11 +18373,11 @@ func (m *VXLANTunnelEndpointUpdate) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Mac = string(dAtA[iNdEx:postIndex])
+			m.PublicKey = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		case 3:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Ipv4Addr", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field InterfaceIpv4Addr", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -16864,13 +18402,13 @@ func (m *VXLANTunnelEndpointUpdate) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Ipv4Addr = string(dAtA[iNdEx:postIndex])
+			m.InterfaceIpv4Addr = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		case 4:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ParentDeviceIp", wireType)
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field HasEncryptionLabel", wireType)
 			}
-			var stringLen uint64
+			var v int
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowFelixbackend
@@ -16880,21 +18418,12 @@ func (m *VXLANTunnelEndpointUpdate) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= (uint64(b) & 0x7F) << shift
+				v |= (int(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
-				return ErrInvalidLengthFelixbackend
-			}
-			postIndex := iNdEx + intStringLen
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.ParentDeviceIp = string(dAtA[iNdEx:postIndex])
-			iNdEx = postIndex
+			m.HasEncryptionLabel = bool(v != 0)
 		default:
 			iNdEx = preIndex
 			skippy, err := skipFelixbackend(dAtA[iNdEx:])
@@ -16916,7 +18445,7 @@ func (m *VXLANTunnelEndpointUpdate) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *VXLANTunnelEndpointRemove) Unmarshal(dAtA []byte) error {
+func (m *WireguardEndpointRemove) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -16939,15 +18468,15 @@ func (m *VXLANTunnelEndpointRemove) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: VXLANTunnelEndpointRemove: wiretype end group for non-group")
+			return fmt.Errorf("proto: WireguardEndpointRemove: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: VXLANTunnelEndpointRemove: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: WireguardEndpointRemove: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Node", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Hostname", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -16972,7 +18501,7 @@ func (m *VXLANTunnelEndpointRemove) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Node = string(dAtA[iNdEx:postIndex])
+			m.Hostname = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -16995,7 +18524,7 @@ func (m *VXLANTunnelEndpointRemove) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *WireguardEndpointUpdate) Unmarshal(dAtA []byte) error {
+func (m *GlobalBGPConfigUpdate) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -17018,15 +18547,15 @@ func (m *WireguardEndpointUpdate) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: WireguardEndpointUpdate: wiretype end group for non-group")
+			return fmt.Errorf("proto: GlobalBGPConfigUpdate: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: WireguardEndpointUpdate: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: GlobalBGPConfigUpdate: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Hostname", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field ServiceClusterCidrs", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -17051,11 +18580,11 @@ func (m *WireguardEndpointUpdate) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Hostname = string(dAtA[iNdEx:postIndex])
+			m.ServiceClusterCidrs = append(m.ServiceClusterCidrs, string(dAtA[iNdEx:postIndex]))
 			iNdEx = postIndex
 		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field PublicKey", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field ServiceExternalCidrs", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -17080,11 +18609,11 @@ func (m *WireguardEndpointUpdate) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.PublicKey = string(dAtA[iNdEx:postIndex])
+			m.ServiceExternalCidrs = append(m.ServiceExternalCidrs, string(dAtA[iNdEx:postIndex]))
 			iNdEx = postIndex
 		case 3:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field InterfaceIpv4Addr", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field ServiceLoadbalancerCidrs", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -17109,7 +18638,7 @@ func (m *WireguardEndpointUpdate) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.InterfaceIpv4Addr = string(dAtA[iNdEx:postIndex])
+			m.ServiceLoadbalancerCidrs = append(m.ServiceLoadbalancerCidrs, string(dAtA[iNdEx:postIndex]))
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -17132,7 +18661,7 @@ func (m *WireguardEndpointUpdate) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *WireguardEndpointRemove) Unmarshal(dAtA []byte) error {
+func (m *StaticRouteUpdate) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -17155,15 +18684,15 @@ func (m *WireguardEndpointRemove) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: WireguardEndpointRemove: wiretype end group for non-group")
+			return fmt.Errorf("proto: StaticRouteUpdate: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: WireguardEndpointRemove: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: StaticRouteUpdate: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field Hostname", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Id", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -17188,8 +18717,114 @@ func (m *WireguardEndpointRemove) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.Hostname = string(dAtA[iNdEx:postIndex])
+			m.Id = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 2:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Dst", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Dst = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 3:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field NextHop", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.NextHop = string(dAtA[iNdEx:postIndex])
+			iNdEx = postIndex
+		case 4:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Device", wireType)
+			}
+			var stringLen uint64
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				stringLen |= (uint64(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			intStringLen := int(stringLen)
+			if intStringLen < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			postIndex := iNdEx + intStringLen
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			m.Device = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
+		case 5:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field Table", wireType)
+			}
+			m.Table = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowFelixbackend
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.Table |= (uint32(b) & 0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
 		default:
 			iNdEx = preIndex
 			skippy, err := skipFelixbackend(dAtA[iNdEx:])
@@ -17211,7 +18846,7 @@ func (m *WireguardEndpointRemove) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
-func (m *GlobalBGPConfigUpdate) Unmarshal(dAtA []byte) error {
+func (m *StaticRouteRemove) Unmarshal(dAtA []byte) error {
 	l := len(dAtA)
 	iNdEx := 0
 	for iNdEx < l {
@@ -17234,15 +18869,15 @@ func (m *GlobalBGPConfigUpdate) Unmarshal(dAtA []byte) error {
 		fieldNum := int32(wire >> 3)
 		wireType := int(wire & 0x7)
 		if wireType == 4 {
-			return fmt.Errorf("proto: GlobalBGPConfigUpdate: wiretype end group for non-group")
+			return fmt.Errorf("proto: StaticRouteRemove: wiretype end group for non-group")
 		}
 		if fieldNum <= 0 {
-			return fmt.Errorf("proto: GlobalBGPConfigUpdate: illegal tag %d (wire type %d)", fieldNum, wire)
+			return fmt.Errorf("proto: StaticRouteRemove: illegal tag %d (wire type %d)", fieldNum, wire)
 		}
 		switch fieldNum {
 		case 1:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ServiceClusterCidrs", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Id", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -17267,13 +18902,63 @@ func (m *GlobalBGPConfigUpdate) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.ServiceClusterCidrs = append(m.ServiceClusterCidrs, string(dAtA[iNdEx:postIndex]))
+			m.Id = string(dAtA[iNdEx:postIndex])
 			iNdEx = postIndex
-		case 2:
-			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ServiceExternalCidrs", wireType)
+		default:
+			iNdEx = preIndex
+			skippy, err := skipFelixbackend(dAtA[iNdEx:])
+			if err != nil {
+				return err
 			}
-			var stringLen uint64
+			if skippy < 0 {
+				return ErrInvalidLengthFelixbackend
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+func (m *ProtocolCapabilities) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowFelixbackend
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= (uint64(b) & 0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: ProtocolCapabilities: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: ProtocolCapabilities: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field ProtocolVersion", wireType)
+			}
+			m.ProtocolVersion = 0
 			for shift := uint(0); ; shift += 7 {
 				if shift >= 64 {
 					return ErrIntOverflowFelixbackend
@@ -17283,24 +18968,14 @@ func (m *GlobalBGPConfigUpdate) Unmarshal(dAtA []byte) error {
 				}
 				b := dAtA[iNdEx]
 				iNdEx++
-				stringLen |= (uint64(b) & 0x7F) << shift
+				m.ProtocolVersion |= (uint32(b) & 0x7F) << shift
 				if b < 0x80 {
 					break
 				}
 			}
-			intStringLen := int(stringLen)
-			if intStringLen < 0 {
-				return ErrInvalidLengthFelixbackend
-			}
-			postIndex := iNdEx + intStringLen
-			if postIndex > l {
-				return io.ErrUnexpectedEOF
-			}
-			m.ServiceExternalCidrs = append(m.ServiceExternalCidrs, string(dAtA[iNdEx:postIndex]))
-			iNdEx = postIndex
-		case 3:
+		case 2:
 			if wireType != 2 {
-				return fmt.Errorf("proto: wrong wireType = %d for field ServiceLoadbalancerCidrs", wireType)
+				return fmt.Errorf("proto: wrong wireType = %d for field Capabilities", wireType)
 			}
 			var stringLen uint64
 			for shift := uint(0); ; shift += 7 {
@@ -17325,7 +19000,7 @@ func (m *GlobalBGPConfigUpdate) Unmarshal(dAtA []byte) error {
 			if postIndex > l {
 				return io.ErrUnexpectedEOF
 			}
-			m.ServiceLoadbalancerCidrs = append(m.ServiceLoadbalancerCidrs, string(dAtA[iNdEx:postIndex]))
+			m.Capabilities = append(m.Capabilities, string(dAtA[iNdEx:postIndex]))
 			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
@@ -17457,220 +19132,220 @@ func init() { proto1.RegisterFile("felixbackend.proto", fileDescriptorFelixbacke
 
 var fileDescriptorFelixbackend = []byte{
 	// 3453 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xbc, 0x5a, 0xdd, 0x6e, 0x1c, 0x47,
-	0x76, 0x66, 0x0f, 0xc9, 0xe1, 0xcc, 0x99, 0x1f, 0xb6, 0x8a, 0x22, 0x39, 0xa4, 0x24, 0x4a, 0x6e,
-	0x59, 0x10, 0xad, 0xc0, 0x92, 0x40, 0x4b, 0x94, 0xe5, 0x04, 0x32, 0x48, 0x0e, 0x2d, 0x8e, 0x4d,
-	0x0d, 0x89, 0x26, 0x2d, 0xc7, 0x81, 0x81, 0x4e, 0xb3, 0xbb, 0x48, 0x76, 0xd4, 0xd3, 0xdd, 0xee,
-	0xae, 0xe1, 0x4f, 0x72, 0x17, 0xe4, 0x22, 0x09, 0x10, 0x24, 0x57, 0xc1, 0x3e, 0xc0, 0x62, 0xaf,
-	0xf6, 0x0d, 0xf6, 0x62, 0xaf, 0x16, 0xb0, 0xef, 0xf6, 0x05, 0x16, 0xd8, 0xf5, 0x3e, 0xc1, 0xbe,
-	0xc1, 0xa2, 0x7e, 0xfb, 0x67, 0x7a, 0x28, 0x69, 0xb1, 0xd8, 0x2b, 0x4e, 0x9d, 0xf3, 0x9d, 0xaf,
-	0x4e, 0x9d, 0x3a, 0x5d, 0x75, 0xaa, 0x8a, 0x80, 0x8e, 0xb1, 0xef, 0x5d, 0x1c, 0xd9, 0xce, 0x1b,
-	0x1c, 0xb8, 0x0f, 0xa3, 0x38, 0x24, 0x21, 0x9a, 0x66, 0x32, 0xa3, 0x05, 0x8d, 0x83, 0xcb, 0xc0,
-	0x31, 0xf1, 0xf7, 0x43, 0x9c, 0x10, 0xe3, 0x0f, 0x3a, 0x34, 0x0e, 0xc3, 0xae, 0x4d, 0xec, 0xc8,
-	0xb7, 0x03, 0x8c, 0x56, 0x61, 0xc6, 0x0b, 0xac, 0xe4, 0x32, 0x70, 0x3a, 0xda, 0x1d, 0x6d, 0xb5,
-	0xb1, 0xd6, 0x7a, 0xc8, 0xec, 0x1e, 0xf6, 0x02, 0x6a, 0xb6, 0x33, 0x61, 0x56, 0x3d, 0xf6, 0x0b,
-	0x3d, 0x83, 0xa6, 0x17, 0x25, 0x98, 0x58, 0xc3, 0xc8, 0xb5, 0x09, 0xee, 0x54, 0x18, 0x1c, 0x49,
-	0xf8, 0xfe, 0x01, 0x26, 0x5f, 0x33, 0xcd, 0xce, 0x84, 0xd9, 0x60, 0x48, 0xde, 0x44, 0x2f, 0x01,
-	0x71, 0x43, 0x17, 0xfb, 0xc4, 0x96, 0xe6, 0x93, 0xcc, 0x7c, 0x31, 0x6b, 0xde, 0xa5, 0x7a, 0xc5,
-	0xa1, 0x33, 0xa3, 0x8c, 0x2c, 0xf5, 0x20, 0xc6, 0x83, 0xf0, 0x0c, 0x77, 0xa6, 0x46, 0x3d, 0x30,
-	0x99, 0x46, 0x79, 0xc0, 0x9b, 0x68, 0x1f, 0xe6, 0x6d, 0x87, 0x78, 0x67, 0xd8, 0x8a, 0xe2, 0xf0,
-	0xd8, 0xf3, 0xb1, 0x74, 0x62, 0x9a, 0x31, 0x2c, 0x0b, 0x86, 0x0d, 0x86, 0xd9, 0xe7, 0x10, 0xe5,
-	0xc7, 0x9c, 0x3d, 0x2a, 0x2e, 0x61, 0x14, 0x3e, 0x55, 0xc7, 0x33, 0x2a, 0xdf, 0xf2, 0x8c, 0xc2,
-	0xc7, 0x57, 0x70, 0x5d, 0x32, 0x86, 0xbe, 0xe7, 0x5c, 0x4a, 0x17, 0x67, 0x18, 0xe1, 0x52, 0x9e,
-	0x90, 0x21, 0x94, 0x87, 0xc8, 0x1e, 0x91, 0x8e, 0xd2, 0x09, 0xff, 0x6a, 0x63, 0xe9, 0x94, 0x7b,
-	0x39, 0xba, 0xd4, 0xbb, 0xd3, 0x30, 0x21, 0x16, 0x0e, 0xdc, 0x28, 0xf4, 0x02, 0x95, 0x04, 0xf5,
-	0x1c, 0xdd, 0x4e, 0x98, 0x90, 0x6d, 0x81, 0x48, 0xbd, 0x3b, 0x1d, 0x91, 0x8e, 0xd2, 0x09, 0xef,
-	0x60, 0x2c, 0x5d, 0xea, 0xdd, 0xe9, 0x88, 0x14, 0x7d, 0x0b, 0x9d, 0xf3, 0x30, 0x7e, 0xe3, 0x87,
-	0xb6, 0x3b, 0xe2, 0x61, 0x83, 0x51, 0xde, 0x12, 0x94, 0xdf, 0x08, 0xd8, 0x88, 0x97, 0x0b, 0xe7,
-	0xa5, 0x9a, 0x72, 0x6a, 0xe1, 0x6d, 0xf3, 0x4a, 0x6a, 0xe5, 0xf1, 0x08, 0xb5, 0xf0, 0xfa, 0x33,
-	0x68, 0x39, 0x61, 0x70, 0xec, 0x9d, 0x48, 0x57, 0x5b, 0x8c, 0x6f, 0x4e, 0xf0, 0x6d, 0x31, 0x9d,
-	0x72, 0xb0, 0xe9, 0x64, 0xda, 0x2a, 0x80, 0x03, 0x4c, 0x6c, 0xd7, 0x4e, 0xbf, 0xaa, 0xf6, 0x48,
-	0x00, 0x5f, 0x09, 0x44, 0x7e, 0x3e, 0xf2, 0x52, 0x74, 0x1f, 0x66, 0x13, 0xba, 0x40, 0x04, 0x0e,
-	0xb6, 0x82, 0xe1, 0xe0, 0x08, 0xc7, 0x9d, 0xd9, 0x3b, 0xda, 0xea, 0x94, 0xd9, 0x96, 0xe2, 0x3e,
-	0x93, 0xa2, 0x0d, 0xd0, 0xbd, 0xc8, 0x1e, 0x58, 0x51, 0x18, 0xfa, 0xb2, 0x4f, 0x9d, 0xf5, 0x39,
-	0xaf, 0x3e, 0xc3, 0x8d, 0x57, 0xfb, 0x61, 0xe8, 0xab, 0xfe, 0xda, 0xd4, 0x20, 0x95, 0xe4, 0x29,
-	0x44, 0x24, 0xaf, 0x95, 0x52, 0xa8, 0x08, 0x2a, 0x8a, 0x42, 0x36, 0xaa, 0xd1, 0x0b, 0x1a, 0x34,
-	0x76, 0xf4, 0xf9, 0xf4, 0xc9, 0x4b, 0xd1, 0x01, 0x2c, 0x24, 0x38, 0x3e, 0xf3, 0x1c, 0x6c, 0xd9,
-	0x8e, 0x13, 0x0e, 0xd3, 0xe4, 0x99, 0x63, 0x84, 0x37, 0x04, 0xe1, 0x01, 0x07, 0x6d, 0x70, 0x8c,
-	0x1a, 0xe0, 0xf5, 0xa4, 0x44, 0x5e, 0x46, 0x2a, 0xbc, 0xbc, 0x7e, 0x05, 0xa9, 0xf2, 0xb3, 0x40,
-	0x2a, 0x3c, 0xdd, 0x02, 0x3d, 0xb0, 0x07, 0x38, 0x89, 0x6c, 0x47, 0xad, 0x61, 0xf3, 0x8c, 0x6e,
-	0x41, 0xd0, 0xf5, 0xa5, 0x5a, 0xb9, 0x37, 0x1b, 0xe4, 0x45, 0x79, 0x12, 0xe1, 0xd3, 0x42, 0x39,
-	0x89, 0x72, 0x27, 0x25, 0x11, 0x9e, 0x3c, 0x83, 0x66, 0x1c, 0x0e, 0x89, 0xf2, 0x62, 0x31, 0xb7,
-	0x16, 0x9b, 0x54, 0x95, 0xee, 0x06, 0x71, 0xda, 0x4c, 0x0d, 0x45, 0xcf, 0x9d, 0x51, 0xc3, 0x74,
-	0x11, 0x8f, 0xd3, 0x26, 0xda, 0x82, 0xc6, 0x19, 0xc1, 0x91, 0xec, 0x70, 0x89, 0xd9, 0xdd, 0x11,
-	0x76, 0xaf, 0xff, 0x71, 0x77, 0xa3, 0x7f, 0x38, 0x0c, 0x02, 0xec, 0x8f, 0x7c, 0xda, 0x40, 0xcd,
-	0xd4, 0xd8, 0x39, 0x89, 0xe8, 0x7c, 0xf9, 0x6d, 0x24, 0xca, 0x15, 0x46, 0x22, 0x3c, 0xf9, 0x0e,
-	0x96, 0xce, 0xbd, 0x18, 0x9f, 0x0c, 0xed, 0x78, 0x74, 0xbd, 0xb9, 0xc1, 0x28, 0x57, 0xe4, 0xa2,
-	0x20, 0x71, 0x23, 0x5e, 0x2d, 0x9e, 0x97, 0xab, 0xc6, 0xb0, 0x0b, 0x87, 0x6f, 0x5e, 0xcd, 0xae,
-	0xdc, 0x1d, 0x65, 0x17, 0xbe, 0x7f, 0x03, 0x9d, 0x13, 0x3f, 0x3c, 0xb2, 0x7d, 0xeb, 0xe8, 0x24,
-	0xb2, 0xf2, 0xeb, 0xcf, 0x2d, 0x46, 0x7e, 0x53, 0x90, 0xbf, 0x64, 0xb0, 0xcd, 0x97, 0xfb, 0x85,
-	0x85, 0x68, 0x9e, 0xdb, 0x6f, 0x9e, 0x44, 0x59, 0xc5, 0x66, 0x1d, 0x66, 0x22, 0xfb, 0x92, 0x2e,
-	0x73, 0xc6, 0xff, 0x4c, 0x43, 0xeb, 0x8b, 0x38, 0x1c, 0xa4, 0x55, 0xc6, 0x3e, 0xcc, 0x47, 0x71,
-	0xe8, 0xe0, 0x24, 0xb1, 0x12, 0x62, 0x93, 0x61, 0x92, 0xaf, 0x02, 0xe4, 0x76, 0xb9, 0xcf, 0x31,
-	0x07, 0x0c, 0x92, 0x6e, 0xc0, 0xd1, 0xa8, 0x18, 0xfd, 0x33, 0xdc, 0xc8, 0xef, 0x20, 0x79, 0x5e,
-	0x5e, 0x1a, 0xdc, 0x2e, 0xd9, 0x48, 0x0a, 0xe4, 0x9d, 0xd3, 0x31, 0xba, 0xb1, 0x3d, 0x88, 0x99,
-	0x98, 0x7e, 0x4b, 0x0f, 0x6a, 0x2a, 0x4a, 0x7a, 0x10, 0x73, 0xe1, 0xc3, 0xed, 0xd1, 0xbd, 0x25,
-	0x3f, 0x0e, 0x5e, 0x4e, 0xdc, 0x1d, 0xb3, 0xc5, 0x14, 0xc6, 0x72, 0xf3, 0xfc, 0x0a, 0xfd, 0x95,
-	0xbd, 0x89, 0x31, 0xcd, 0xbc, 0x43, 0x6f, 0x6a, 0x5c, 0x63, 0x7a, 0x13, 0x63, 0x2b, 0xd9, 0x51,
-	0x6a, 0xa5, 0x3b, 0xca, 0x6b, 0x48, 0x73, 0xb5, 0x30, 0xf8, 0x7a, 0x2e, 0x1f, 0x55, 0xb2, 0x17,
-	0x46, 0x3d, 0x7f, 0x5e, 0xa6, 0xc8, 0xe6, 0xe3, 0xbf, 0x6b, 0xd0, 0xcc, 0xe6, 0x2a, 0x7a, 0x06,
-	0x55, 0x9e, 0xf9, 0x1d, 0xed, 0xce, 0x64, 0x66, 0x16, 0xb3, 0x20, 0xd1, 0xd8, 0x0e, 0x48, 0x7c,
-	0x69, 0x0a, 0xf8, 0xf2, 0x73, 0x68, 0x64, 0xc4, 0x48, 0x87, 0xc9, 0x37, 0xf8, 0x92, 0x15, 0xce,
-	0x75, 0x93, 0xfe, 0x44, 0xd7, 0x61, 0xfa, 0xcc, 0xf6, 0x87, 0xbc, 0x3a, 0xae, 0x9b, 0xbc, 0xf1,
-	0x59, 0xe5, 0x53, 0xcd, 0xa8, 0x41, 0x95, 0x97, 0xd4, 0xc6, 0xcf, 0x34, 0x68, 0x64, 0xca, 0x65,
-	0xd4, 0x86, 0x8a, 0xe7, 0x0a, 0x92, 0x8a, 0xe7, 0xa2, 0x0e, 0xcc, 0x0c, 0x30, 0x8d, 0x4d, 0xd2,
-	0xa9, 0xdc, 0x99, 0x5c, 0xad, 0x9b, 0xb2, 0x89, 0x1e, 0xc3, 0x14, 0xb9, 0x8c, 0xf8, 0x57, 0xd3,
-	0x56, 0x81, 0xc9, 0x70, 0xf1, 0xdf, 0x87, 0x97, 0x11, 0x36, 0x19, 0xd2, 0xf8, 0x18, 0xea, 0x4a,
-	0x84, 0xaa, 0x50, 0xe9, 0xed, 0xeb, 0x13, 0x68, 0x96, 0xf6, 0x6f, 0x6d, 0xf4, 0xbb, 0xd6, 0xfe,
-	0x9e, 0x79, 0xa8, 0x6b, 0x68, 0x06, 0x26, 0xfb, 0xdb, 0x87, 0x7a, 0xc5, 0x88, 0x40, 0x2f, 0x56,
-	0xe2, 0x23, 0xee, 0xdd, 0x85, 0x96, 0xed, 0xba, 0xd8, 0xb5, 0xf2, 0x4e, 0x36, 0x99, 0xf0, 0x95,
-	0xf0, 0xf4, 0x3e, 0xcc, 0xf2, 0x9c, 0x4a, 0x61, 0x93, 0x0c, 0xd6, 0x16, 0x62, 0x01, 0x34, 0x6e,
-	0x89, 0x58, 0x88, 0xb4, 0x29, 0x74, 0x66, 0xd8, 0x30, 0x57, 0x52, 0x95, 0xa3, 0x3b, 0x0a, 0xd6,
-	0x58, 0xd3, 0xd3, 0xc5, 0x83, 0x22, 0x7a, 0x5d, 0xe6, 0xe5, 0x2a, 0xcc, 0x88, 0xca, 0x5c, 0x1c,
-	0x54, 0xda, 0x79, 0x98, 0x29, 0xd5, 0xc6, 0xb3, 0x42, 0x17, 0xc2, 0x93, 0xb7, 0x76, 0x61, 0xdc,
-	0x86, 0xba, 0x12, 0x20, 0x04, 0x53, 0x74, 0x8b, 0x14, 0xae, 0xb3, 0xdf, 0x46, 0x08, 0x33, 0x02,
-	0x80, 0x1e, 0x43, 0xcb, 0x0b, 0x8e, 0xc2, 0x61, 0xe0, 0x5a, 0xf1, 0xd0, 0xc7, 0x89, 0x48, 0xbc,
-	0x86, 0xdc, 0xf6, 0x86, 0x3e, 0x36, 0x9b, 0x02, 0x41, 0x1b, 0x09, 0x5a, 0x83, 0x76, 0x38, 0x24,
-	0x59, 0x93, 0xca, 0xa8, 0x49, 0x4b, 0x42, 0x98, 0x8d, 0xf1, 0x1d, 0xa0, 0xd1, 0x03, 0x02, 0xba,
-	0x9d, 0x19, 0xc9, 0xac, 0x1c, 0x09, 0x03, 0x88, 0x58, 0xdd, 0x83, 0x2a, 0x3f, 0x24, 0x88, 0x50,
-	0xb5, 0x72, 0x20, 0x53, 0x28, 0x8d, 0xa7, 0x79, 0x76, 0x11, 0xa7, 0xb7, 0xb1, 0x1b, 0x6b, 0x50,
-	0x93, 0x6d, 0x1a, 0x25, 0xe2, 0xe1, 0x58, 0x46, 0x89, 0xfe, 0x56, 0x91, 0xab, 0x64, 0x22, 0xf7,
-	0x1b, 0x0d, 0xaa, 0xdc, 0xe8, 0x6f, 0x13, 0x39, 0x74, 0x13, 0xea, 0xc3, 0x80, 0xc4, 0xf4, 0x00,
-	0xed, 0xb2, 0xcf, 0xab, 0x66, 0xa6, 0x02, 0xb4, 0x04, 0xb5, 0x28, 0xc6, 0x96, 0x1b, 0xd8, 0x84,
-	0xed, 0x2c, 0x35, 0x9a, 0x3d, 0xb8, 0x1b, 0xd8, 0x84, 0x1a, 0xaa, 0xd2, 0x88, 0xed, 0x09, 0x75,
-	0x33, 0x15, 0x18, 0xff, 0xdd, 0x86, 0x29, 0xda, 0x01, 0x5a, 0x80, 0x2a, 0x3d, 0x55, 0x85, 0x81,
-	0x18, 0xba, 0x68, 0xa1, 0x47, 0x00, 0x5e, 0x64, 0x9d, 0xe1, 0x38, 0xa1, 0xba, 0x0a, 0xfb, 0xae,
-	0x75, 0xf5, 0x5d, 0xbf, 0xe6, 0x72, 0xb3, 0xee, 0x45, 0xe2, 0x27, 0xfa, 0x3b, 0xea, 0x4a, 0x48,
-	0x42, 0x27, 0xf4, 0xc5, 0xe6, 0x39, 0x9b, 0x26, 0x27, 0x13, 0x9b, 0x0a, 0x80, 0x16, 0x61, 0x26,
-	0x89, 0x1d, 0x2b, 0xc0, 0xd4, 0x6d, 0xfa, 0xf5, 0x55, 0x93, 0xd8, 0xe9, 0x63, 0x82, 0x3e, 0x86,
-	0x3a, 0x55, 0x44, 0x61, 0x4c, 0x92, 0xce, 0x34, 0x8b, 0x8e, 0xca, 0xf1, 0x30, 0x26, 0xa6, 0x1d,
-	0x9c, 0x60, 0xb3, 0x96, 0xc4, 0x0e, 0x6d, 0x25, 0x94, 0xc7, 0x4d, 0x08, 0xe3, 0xa9, 0x72, 0x1e,
-	0x37, 0x21, 0x82, 0x87, 0x2a, 0x38, 0xcf, 0xcc, 0x38, 0x1e, 0x37, 0x21, 0x9c, 0xe7, 0x16, 0xd4,
-	0x3d, 0x67, 0x10, 0x59, 0x6c, 0x11, 0xa3, 0xdb, 0xc1, 0xf4, 0xce, 0x84, 0x59, 0xa3, 0x22, 0xb6,
-	0x3e, 0xbd, 0x80, 0xb6, 0x52, 0x5b, 0x4e, 0xe8, 0xca, 0x1d, 0x40, 0x96, 0xa5, 0x3d, 0x01, 0xdc,
-	0x08, 0xdc, 0xad, 0xd0, 0x65, 0x87, 0x22, 0x69, 0x4b, 0xdb, 0xe8, 0x2e, 0xb4, 0xe9, 0xa8, 0xbc,
-	0xc8, 0x4a, 0x30, 0xb1, 0x3c, 0x37, 0xe9, 0x00, 0xf3, 0xb6, 0x91, 0xc4, 0x4e, 0x2f, 0x3a, 0xc0,
-	0xa4, 0xe7, 0x26, 0x14, 0x44, 0x5d, 0xce, 0x80, 0x1a, 0x1c, 0xe4, 0x26, 0x44, 0x81, 0x9e, 0xc1,
-	0x12, 0x0b, 0x9c, 0x3d, 0xc0, 0x2e, 0x1b, 0x5d, 0x16, 0xdf, 0x64, 0xf8, 0xeb, 0x34, 0x94, 0x54,
-	0x4f, 0x87, 0x96, 0x35, 0x64, 0x91, 0x2a, 0x35, 0x6c, 0x71, 0x43, 0x1a, 0xbb, 0x11, 0xc3, 0x35,
-	0x68, 0x06, 0x21, 0xb1, 0xd4, 0xdc, 0x1e, 0x97, 0xcf, 0x6d, 0x23, 0x08, 0x89, 0x6c, 0xa0, 0x15,
-	0xa0, 0x4d, 0x4b, 0x4e, 0xf1, 0x09, 0xa3, 0xaf, 0x07, 0x21, 0x39, 0xe0, 0xb3, 0xfc, 0x04, 0x5a,
-	0x52, 0xcf, 0x67, 0xe8, 0x74, 0xcc, 0x0c, 0x35, 0xb8, 0x0d, 0x9f, 0x24, 0xc1, 0x2a, 0x27, 0xdc,
-	0x53, 0xac, 0x5d, 0x3e, 0xe7, 0x82, 0x35, 0x9d, 0xf7, 0x7f, 0xb9, 0x82, 0xb5, 0x2b, 0xa7, 0xfe,
-	0x43, 0x6e, 0x95, 0x4e, 0xff, 0x1b, 0x36, 0xfd, 0x1a, 0x43, 0xc9, 0x89, 0x45, 0xdb, 0x80, 0x72,
-	0x28, 0x9e, 0x05, 0xfe, 0x95, 0x59, 0xa0, 0x99, 0xb3, 0x19, 0x0a, 0x96, 0x08, 0x0f, 0x38, 0x4d,
-	0x21, 0x19, 0x06, 0x7c, 0x03, 0xe2, 0x63, 0x55, 0x81, 0x17, 0xd8, 0x42, 0x4e, 0x04, 0x0a, 0xdb,
-	0xcd, 0xa4, 0xc5, 0x0b, 0xb8, 0xa5, 0x02, 0x5e, 0x3a, 0xc3, 0x11, 0x33, 0x5b, 0x14, 0x53, 0x30,
-	0x32, 0xc9, 0xc2, 0x7e, 0x7c, 0x86, 0x7c, 0xaf, 0xec, 0xbb, 0xe5, 0x49, 0x32, 0x1f, 0xc6, 0xde,
-	0x89, 0x17, 0xd8, 0x3e, 0x73, 0x22, 0xc1, 0x3e, 0x76, 0x48, 0x18, 0x77, 0x62, 0xb6, 0xa8, 0xcc,
-	0x49, 0xe5, 0x41, 0xec, 0x1c, 0x08, 0x55, 0xce, 0x86, 0x76, 0xac, 0x6c, 0x92, 0xbc, 0x4d, 0x37,
-	0x21, 0xca, 0x66, 0x1b, 0x6e, 0xe7, 0xfa, 0x49, 0x8f, 0x8b, 0xca, 0x9a, 0x30, 0xeb, 0x9b, 0x99,
-	0x1e, 0xd5, 0xa1, 0xb1, 0x94, 0x46, 0x8e, 0xb9, 0x40, 0x33, 0xcc, 0xd3, 0x88, 0x51, 0xe7, 0x69,
-	0x9e, 0xc3, 0x92, 0xa2, 0x91, 0xe1, 0x57, 0x04, 0x67, 0x8c, 0x60, 0x41, 0x02, 0xfa, 0x2c, 0xf2,
-	0x63, 0x4d, 0x73, 0x01, 0x38, 0x1f, 0x31, 0xcd, 0xc6, 0xe0, 0x6b, 0xbe, 0x04, 0x14, 0xcf, 0xf0,
-	0x03, 0x9b, 0x38, 0xa7, 0x9d, 0x8b, 0xdc, 0xb1, 0x25, 0x7f, 0x84, 0x7f, 0x45, 0x11, 0xe6, 0x42,
-	0x42, 0xdd, 0x18, 0x91, 0x53, 0x5a, 0xee, 0x44, 0x19, 0xed, 0xe5, 0xdb, 0x69, 0x5d, 0xea, 0xe2,
-	0x28, 0xed, 0x23, 0x80, 0x53, 0x42, 0x22, 0xc1, 0xf3, 0xaf, 0xb9, 0xaa, 0x65, 0xe7, 0xf0, 0x70,
-	0x9f, 0x5b, 0xd7, 0x29, 0x46, 0x1a, 0xd4, 0xe4, 0xed, 0x49, 0xe7, 0xdf, 0x72, 0xf7, 0x4e, 0x74,
-	0xbf, 0x52, 0x17, 0x24, 0x0a, 0x44, 0xab, 0x52, 0xba, 0x99, 0x5a, 0x9e, 0xdb, 0xf9, 0x51, 0xec,
-	0x61, 0xb4, 0xdd, 0x73, 0x37, 0xab, 0x30, 0x45, 0x3f, 0xd8, 0x4d, 0x80, 0x9a, 0xfc, 0x78, 0xbf,
-	0xac, 0xd6, 0x7e, 0xd0, 0xf4, 0x1f, 0x35, 0x13, 0xfc, 0xf0, 0xc4, 0x8a, 0x62, 0x7c, 0xec, 0x5d,
-	0x18, 0x2f, 0x61, 0xae, 0xcc, 0xf5, 0x65, 0xa8, 0xa9, 0x29, 0xe1, 0xc4, 0xaa, 0x4d, 0xcb, 0x69,
-	0x96, 0x34, 0xa2, 0xc6, 0xe4, 0x0d, 0xe3, 0xe7, 0x1a, 0xd4, 0xd5, 0xa0, 0x78, 0xb9, 0x4c, 0x4e,
-	0x43, 0x97, 0x97, 0x06, 0xac, 0x5c, 0x66, 0x4d, 0xf4, 0x18, 0xa6, 0x23, 0x9b, 0x9c, 0xca, 0xfd,
-	0x7f, 0xb9, 0x18, 0x8f, 0x87, 0xfb, 0x36, 0x39, 0xe5, 0x91, 0xe1, 0xc0, 0xe5, 0xaf, 0xa0, 0xae,
-	0x64, 0x68, 0x01, 0xa6, 0xf1, 0x85, 0xed, 0x10, 0xee, 0xd5, 0xce, 0x84, 0xc9, 0x9b, 0xa8, 0x03,
-	0x55, 0x3e, 0x22, 0x5e, 0xb2, 0xec, 0x4c, 0x98, 0xa2, 0xbd, 0xd9, 0x04, 0xa0, 0x3c, 0x7c, 0x16,
-	0x8c, 0xff, 0xd7, 0xa0, 0x99, 0x0d, 0x26, 0xfa, 0x02, 0x1a, 0x76, 0x10, 0x84, 0xc4, 0xa6, 0x5b,
-	0xbf, 0x2c, 0x64, 0x3e, 0x2c, 0x09, 0xfb, 0xc3, 0x8d, 0x14, 0xc6, 0x0f, 0x20, 0x59, 0xc3, 0xe5,
-	0x17, 0xa0, 0x17, 0x01, 0xef, 0x75, 0x14, 0x79, 0x0e, 0xb3, 0x85, 0x45, 0x94, 0x15, 0x66, 0x74,
-	0x55, 0xa6, 0xf6, 0xd3, 0xfc, 0xec, 0x40, 0x65, 0x6c, 0xf9, 0xad, 0x70, 0x19, 0xfd, 0x6d, 0xec,
-	0x42, 0x4d, 0x6d, 0x3f, 0x1d, 0xa8, 0x8a, 0x93, 0x9d, 0x26, 0xb6, 0x72, 0xd1, 0x46, 0xd7, 0xb3,
-	0x25, 0xdd, 0xce, 0x04, 0x2f, 0xea, 0x36, 0x75, 0x68, 0x73, 0xbd, 0x15, 0xc6, 0x6c, 0x2d, 0x30,
-	0x9e, 0x42, 0x5d, 0x6d, 0x17, 0xd4, 0xdf, 0x63, 0x2f, 0x4e, 0x88, 0xf0, 0x81, 0x37, 0xa8, 0x13,
-	0xbe, 0x9d, 0x10, 0xe9, 0x04, 0xfd, 0x6d, 0xfc, 0xaf, 0x06, 0xa8, 0x78, 0x38, 0xed, 0x75, 0xe9,
-	0x99, 0x23, 0x8c, 0x9d, 0x53, 0x9c, 0x90, 0xd8, 0x26, 0x61, 0x4c, 0x33, 0x95, 0x0f, 0xbd, 0x9d,
-	0x15, 0xf7, 0x5c, 0x74, 0x1b, 0x1a, 0xea, 0x24, 0xec, 0xf1, 0x72, 0xaf, 0x6e, 0x82, 0x14, 0x71,
-	0x80, 0x3a, 0x21, 0x7b, 0x2e, 0x2b, 0xf9, 0xea, 0x26, 0x48, 0x51, 0xcf, 0xfd, 0x72, 0xaa, 0xa6,
-	0xe9, 0x15, 0xb3, 0x46, 0x4f, 0xf6, 0x6c, 0x20, 0x17, 0xb0, 0x50, 0x7e, 0xb3, 0x8c, 0x3e, 0xca,
-	0x94, 0xc7, 0x4b, 0x63, 0x0e, 0xd6, 0xa2, 0x0c, 0xff, 0x04, 0x6a, 0xb2, 0x0b, 0x71, 0xbb, 0xb0,
-	0x38, 0xee, 0x6a, 0x59, 0x01, 0x8d, 0x5f, 0x54, 0x40, 0x2f, 0xaa, 0x69, 0x28, 0xe9, 0x49, 0x5a,
-	0x9e, 0x46, 0x78, 0xa3, 0xac, 0xd0, 0xa6, 0x69, 0x33, 0xb0, 0x1d, 0x11, 0x02, 0xfa, 0x93, 0x8e,
-	0x5d, 0x3e, 0x69, 0xd0, 0x1d, 0x89, 0xd7, 0x8d, 0x20, 0x44, 0x74, 0x13, 0xba, 0x01, 0x75, 0x2f,
-	0x3a, 0x7b, 0x42, 0x8b, 0x03, 0x5e, 0x3b, 0xd6, 0xcd, 0x1a, 0x15, 0xf4, 0x31, 0x91, 0xca, 0x75,
-	0xae, 0xac, 0x2a, 0xe5, 0x3a, 0x53, 0xde, 0x83, 0x69, 0x5a, 0xf1, 0xcb, 0x4a, 0x51, 0x16, 0x37,
-	0x87, 0x1e, 0x8e, 0x7b, 0xc1, 0x71, 0x68, 0x72, 0x2d, 0xfa, 0x08, 0x6a, 0xbc, 0x03, 0x9b, 0x74,
-	0x6a, 0x0c, 0xd9, 0x56, 0xf7, 0x92, 0x84, 0x01, 0x67, 0x58, 0x7f, 0x36, 0x11, 0xd0, 0x75, 0x06,
-	0xad, 0x8f, 0x85, 0xae, 0xf7, 0x6d, 0x62, 0x6c, 0x8d, 0x4e, 0x91, 0x38, 0xc1, 0xbc, 0xfb, 0x14,
-	0x19, 0x1b, 0xd0, 0xce, 0xde, 0xf4, 0xf4, 0xba, 0xc5, 0x54, 0xa9, 0xbc, 0x35, 0x55, 0x7c, 0x40,
-	0xa3, 0xcf, 0x24, 0xe8, 0x5e, 0xc6, 0x87, 0xf9, 0x92, 0x3b, 0x25, 0x91, 0x22, 0x8f, 0x32, 0x29,
-	0x32, 0x99, 0x5b, 0xb5, 0x73, 0x6f, 0x25, 0x69, 0x7a, 0xfc, 0xa9, 0x02, 0xcd, 0xac, 0xaa, 0xec,
-	0x9c, 0x5a, 0x9c, 0xf2, 0xca, 0xc8, 0x94, 0xab, 0x89, 0x9b, 0xbc, 0x72, 0xe2, 0x1e, 0xc2, 0x1c,
-	0xbe, 0x88, 0xb0, 0x43, 0xb0, 0x6b, 0xb1, 0x19, 0xb4, 0x5d, 0x37, 0x96, 0x29, 0x74, 0x4d, 0xaa,
-	0x7a, 0xd1, 0xd9, 0x93, 0x0d, 0xaa, 0x28, 0xe2, 0xd7, 0x05, 0x7e, 0x7a, 0x04, 0xbf, 0xce, 0xf1,
-	0x9f, 0xc2, 0xac, 0x3a, 0x93, 0x59, 0xdc, 0xa1, 0x6a, 0xb9, 0x43, 0x6d, 0x85, 0x3b, 0x64, 0x9e,
-	0x3d, 0x85, 0xb6, 0x3c, 0xc0, 0x59, 0x57, 0xa6, 0x60, 0x53, 0x9c, 0xeb, 0xb8, 0xd9, 0x13, 0x68,
-	0x1d, 0x87, 0xf1, 0xb9, 0x1d, 0xcb, 0xee, 0x6a, 0x63, 0xac, 0x04, 0x8a, 0x59, 0x19, 0x7f, 0x9f,
-	0x9f, 0x61, 0x91, 0x65, 0xef, 0x36, 0xc3, 0x46, 0x0c, 0x35, 0x49, 0x5b, 0x3a, 0x57, 0x1f, 0x81,
-	0xee, 0x05, 0x27, 0x31, 0x4e, 0x12, 0xfe, 0xb0, 0xe7, 0xa9, 0xcd, 0x71, 0x56, 0xc8, 0xf7, 0x85,
-	0x98, 0xae, 0x87, 0xb8, 0x80, 0x14, 0x77, 0x30, 0x38, 0x07, 0x34, 0x9e, 0xc1, 0x8c, 0xf8, 0x5c,
-	0xd0, 0x3c, 0x54, 0xf1, 0x05, 0x2d, 0x49, 0xe5, 0xd2, 0x81, 0x2f, 0x48, 0x2f, 0xa2, 0x62, 0x96,
-	0xe0, 0x91, 0xdc, 0x4c, 0xa8, 0xc3, 0x91, 0x61, 0xc2, 0x5c, 0xc9, 0x95, 0x2d, 0xba, 0x0b, 0x2d,
-	0x2f, 0x09, 0x2d, 0xe2, 0x0d, 0x70, 0x42, 0xec, 0x81, 0xe4, 0x6a, 0x7a, 0x49, 0x78, 0x28, 0x65,
-	0xf4, 0x44, 0x3c, 0x8c, 0x28, 0x84, 0x51, 0x6a, 0xa6, 0x68, 0x19, 0x11, 0x74, 0xc6, 0x5d, 0xd7,
-	0xbe, 0xeb, 0x57, 0xf2, 0x31, 0x54, 0xf9, 0x45, 0xa2, 0xb8, 0xcf, 0x90, 0xd0, 0xc2, 0x45, 0xa5,
-	0x00, 0x19, 0xab, 0xd0, 0xce, 0x6b, 0xa8, 0x6f, 0x82, 0x40, 0x54, 0x3a, 0x02, 0xb9, 0x51, 0xe6,
-	0xdb, 0xfb, 0xcd, 0xef, 0x05, 0xdc, 0xbc, 0xea, 0x16, 0xf7, 0x7d, 0xf6, 0x8b, 0xf7, 0x1c, 0x66,
-	0x6f, 0x5c, 0xcf, 0xef, 0xbf, 0x0c, 0xae, 0xc3, 0x7c, 0xe9, 0x6d, 0x2c, 0xba, 0x05, 0x10, 0x0d,
-	0x8f, 0x7c, 0xcf, 0xb1, 0xd2, 0x62, 0xa4, 0xce, 0x25, 0x5f, 0xe1, 0x4b, 0xe3, 0x15, 0xff, 0x32,
-	0x0a, 0x8f, 0x8f, 0xcb, 0xa0, 0x56, 0x47, 0x59, 0x00, 0xca, 0xb6, 0xda, 0x6c, 0xe8, 0xca, 0x20,
-	0x72, 0x8f, 0x6d, 0x0e, 0x74, 0x41, 0x28, 0xd2, 0x89, 0x71, 0xfc, 0xc5, 0x74, 0xdb, 0xd0, 0xce,
-	0x3f, 0x5e, 0x96, 0x5c, 0x7d, 0x4e, 0x45, 0x61, 0xe8, 0x8b, 0x78, 0xcf, 0x16, 0x9f, 0x2b, 0x99,
-	0xd2, 0xb8, 0x93, 0xd2, 0x8c, 0xb9, 0xd4, 0x7c, 0x01, 0x35, 0x89, 0x60, 0x45, 0x96, 0xe7, 0xaa,
-	0x1b, 0x31, 0xfa, 0x1b, 0xad, 0x00, 0x0c, 0xec, 0xe4, 0xfb, 0x21, 0x8e, 0x6d, 0x51, 0x7e, 0xd5,
-	0xcc, 0x8c, 0xc4, 0xf8, 0x95, 0x06, 0xd7, 0xcb, 0xde, 0x22, 0xd1, 0xfd, 0xcc, 0x14, 0x2e, 0x96,
-	0x9e, 0x22, 0x44, 0xea, 0x7c, 0x0e, 0x55, 0xdf, 0x3e, 0xc2, 0xbe, 0x2c, 0x8d, 0xef, 0x5f, 0xf1,
-	0xc2, 0xf9, 0x70, 0x97, 0x21, 0xc5, 0x45, 0x38, 0x37, 0x5b, 0x7e, 0x0e, 0x8d, 0x8c, 0xf8, 0xbd,
-	0xaa, 0xcf, 0xcf, 0x8b, 0xce, 0xab, 0x17, 0x83, 0x77, 0x73, 0xde, 0xe8, 0x82, 0x5e, 0x94, 0xe7,
-	0xaf, 0xe1, 0xb4, 0xc2, 0x35, 0x5c, 0xe9, 0x15, 0xe3, 0x2f, 0x35, 0x98, 0x2d, 0x3c, 0x96, 0x22,
-	0x23, 0xe3, 0x02, 0x2a, 0xbe, 0x85, 0x8a, 0xd0, 0x7d, 0x56, 0x08, 0x9d, 0x51, 0xfe, 0xf0, 0xfa,
-	0xd7, 0x8e, 0xda, 0xd3, 0x8c, 0xb7, 0x22, 0x60, 0xef, 0xe0, 0xad, 0xf1, 0x01, 0x34, 0x32, 0xa2,
-	0xd2, 0x5b, 0xea, 0x43, 0x00, 0xfe, 0xe6, 0x79, 0x28, 0x8a, 0x7e, 0x2f, 0x12, 0xcb, 0x7f, 0xcd,
-	0x64, 0xbf, 0x99, 0x57, 0x17, 0xbe, 0x1d, 0x88, 0x54, 0xe4, 0x0d, 0x1a, 0x72, 0xf5, 0xf2, 0x22,
-	0xaf, 0x4c, 0x95, 0xc0, 0xf8, 0x5d, 0x05, 0x1a, 0x99, 0x57, 0x60, 0xf4, 0x61, 0xe6, 0x80, 0x91,
-	0x5e, 0x71, 0x32, 0x44, 0xfa, 0x5c, 0x81, 0x3e, 0x81, 0xa6, 0x17, 0xf1, 0xff, 0x0c, 0x60, 0x68,
-	0x7e, 0x21, 0x7a, 0x4d, 0x7d, 0x68, 0xf4, 0x93, 0x61, 0x70, 0xf0, 0x22, 0xf9, 0x9b, 0x86, 0xd1,
-	0x4d, 0x88, 0xac, 0x61, 0xdd, 0x84, 0x20, 0x03, 0x5a, 0xec, 0xbe, 0x21, 0x74, 0x31, 0x3b, 0x68,
-	0x88, 0x0a, 0xbe, 0xe1, 0x26, 0xa4, 0x1f, 0xba, 0x98, 0x46, 0x04, 0xad, 0x40, 0x43, 0x61, 0xbc,
-	0x48, 0x5e, 0xdd, 0x0a, 0x44, 0x2f, 0xa2, 0x45, 0x51, 0x62, 0x0f, 0xb0, 0x95, 0x0c, 0x8f, 0x02,
-	0x4c, 0xd8, 0xd3, 0x58, 0xcd, 0x04, 0x2a, 0x3a, 0x60, 0x12, 0xf4, 0x01, 0x34, 0x69, 0x39, 0x11,
-	0x0e, 0xc9, 0x49, 0xe8, 0x05, 0x27, 0xec, 0x3e, 0xb3, 0x66, 0x36, 0x02, 0x9b, 0xec, 0x09, 0x11,
-	0xba, 0x07, 0x6d, 0x3f, 0x74, 0x6c, 0xdf, 0x92, 0x67, 0x0b, 0x76, 0xa1, 0x59, 0x33, 0x5b, 0x4c,
-	0x2a, 0x17, 0x57, 0xb4, 0x06, 0x0d, 0xc2, 0x66, 0x80, 0x0f, 0x9a, 0xff, 0x13, 0x8c, 0x1c, 0x74,
-	0x3a, 0x37, 0x26, 0x10, 0xf5, 0xdb, 0xb8, 0x2d, 0xc2, 0x2b, 0x72, 0x41, 0xc4, 0xa0, 0xa2, 0x62,
-	0x60, 0xfc, 0xa7, 0x06, 0x4b, 0x63, 0x5f, 0xc5, 0x59, 0x22, 0xd0, 0xb3, 0x9d, 0x4c, 0x04, 0x7a,
-	0x06, 0x14, 0x67, 0x81, 0x4a, 0x7a, 0x16, 0xc8, 0x2d, 0x97, 0x93, 0xf9, 0xe5, 0x12, 0xad, 0x82,
-	0x1e, 0xd9, 0x31, 0x0e, 0x88, 0xe5, 0x62, 0x76, 0x97, 0xe1, 0x45, 0x22, 0xce, 0x6d, 0x2e, 0xef,
-	0x32, 0x71, 0x2f, 0x32, 0x1e, 0x95, 0x7a, 0x22, 0x3c, 0x2f, 0xf1, 0xc4, 0xf8, 0x0f, 0x0d, 0x16,
-	0xc7, 0xbc, 0x9c, 0x5f, 0xb9, 0xbc, 0xe7, 0xb7, 0x9f, 0x4a, 0x61, 0xfb, 0xa1, 0xf5, 0xa6, 0x17,
-	0x10, 0x1c, 0x1f, 0xdb, 0xcc, 0xdb, 0xfc, 0xc0, 0xae, 0x29, 0x95, 0x2c, 0x50, 0x8d, 0xa7, 0x25,
-	0x5e, 0xbc, 0x7d, 0x93, 0x31, 0x7e, 0xad, 0xc1, 0x7c, 0xe9, 0xe3, 0x39, 0x5a, 0x83, 0x79, 0x79,
-	0xf1, 0xe3, 0xf8, 0xc3, 0x84, 0xe0, 0xd8, 0xa2, 0x0b, 0xbe, 0xbc, 0xb8, 0x98, 0x13, 0xca, 0x2d,
-	0xae, 0xdb, 0xa2, 0x2a, 0xf4, 0x24, 0xfd, 0x3f, 0x12, 0x7c, 0x41, 0x70, 0x1c, 0xd8, 0xbe, 0x30,
-	0xaa, 0x88, 0x7b, 0x68, 0xae, 0xdd, 0x16, 0x4a, 0x6e, 0xf5, 0x0f, 0xb0, 0x2c, 0xad, 0x68, 0x8a,
-	0x1d, 0xd9, 0xbe, 0x1d, 0x38, 0xaa, 0x3b, 0x5e, 0x06, 0x76, 0x04, 0x62, 0x37, 0x03, 0x60, 0xd6,
-	0x0f, 0x56, 0xa1, 0xae, 0x1e, 0x1f, 0xd0, 0x0c, 0x4c, 0x6e, 0xf4, 0xbf, 0xd5, 0x27, 0x50, 0x0d,
-	0xa6, 0x7a, 0xfb, 0xaf, 0x9f, 0xe8, 0x53, 0xe2, 0xd7, 0xba, 0x5e, 0x7d, 0xf0, 0x5f, 0x1a, 0xd4,
-	0xd5, 0x47, 0x8c, 0x5a, 0x50, 0xdf, 0xea, 0x75, 0x4d, 0xab, 0xd7, 0xff, 0x62, 0x4f, 0x9f, 0x40,
-	0x73, 0x30, 0x6b, 0x6e, 0xbf, 0xda, 0x3b, 0xdc, 0xb6, 0xbe, 0xd9, 0x33, 0xbf, 0xda, 0xdd, 0xdb,
-	0xe8, 0xea, 0x1a, 0x9a, 0x85, 0x86, 0x10, 0xee, 0xec, 0x1d, 0x1c, 0xea, 0x15, 0x84, 0xa0, 0xbd,
-	0xbb, 0xb7, 0xb5, 0xb1, 0x9b, 0x82, 0x26, 0x51, 0x1b, 0x80, 0xcb, 0x18, 0x66, 0x0a, 0x5d, 0x83,
-	0x96, 0x30, 0x3a, 0xfc, 0xba, 0xdf, 0xdf, 0xde, 0xd5, 0xa7, 0x91, 0x0e, 0x4d, 0x0e, 0x11, 0x92,
-	0xea, 0x83, 0xe7, 0x00, 0xe9, 0x0a, 0x41, 0x7d, 0xec, 0xef, 0xf5, 0xb7, 0xf5, 0x09, 0xd4, 0x84,
-	0x5a, 0x7f, 0xcf, 0xda, 0xee, 0x6f, 0x6d, 0xec, 0xeb, 0x1a, 0xaa, 0xc3, 0x34, 0x4b, 0x46, 0xbd,
-	0xc2, 0x87, 0xd1, 0xdb, 0xd7, 0x27, 0xd7, 0x5e, 0x00, 0xf0, 0xe7, 0x26, 0xf6, 0x9f, 0x8e, 0x8f,
-	0x61, 0x8a, 0xfd, 0x95, 0x8b, 0x6a, 0xe6, 0xff, 0x27, 0x97, 0xa5, 0x2c, 0xf3, 0x3f, 0x94, 0x8f,
-	0xb5, 0xcd, 0xc5, 0x1f, 0x7e, 0x5a, 0xd1, 0x7e, 0xfb, 0xd3, 0x8a, 0xf6, 0xfb, 0x9f, 0x56, 0xb4,
-	0xff, 0xfb, 0xe3, 0xca, 0xc4, 0x3f, 0x4d, 0xb3, 0x9b, 0xfc, 0xa3, 0x2a, 0xfb, 0xf3, 0xc9, 0x9f,
-	0x03, 0x00, 0x00, 0xff, 0xff, 0x82, 0xf1, 0x6b, 0xf7, 0xa1, 0x29, 0x00, 0x00,
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xbc, 0x5a, 0x5b, 0x6f, 0x1c, 0xc7,
+	0x95, 0x66, 0x0f, 0x39, 0xc3, 0x99, 0x33, 0xc3, 0x61, 0xab, 0x78, 0x1b, 0x52, 0x37, 0xba, 0x6d,
+	0x41, 0xb4, 0x16, 0x96, 0x05, 0x59, 0xa2, 0x2c, 0xef, 0x42, 0x06, 0xc9, 0xa1, 0xc5, 0xb1, 0xa9,
+	0x21, 0xd1, 0xa4, 0xe5, 0xf5, 0xc2, 0x40, 0x6f, 0xb3, 0xbb, 0x48, 0xf6, 0xaa, 0xa7, 0xbb, 0xdd,
+	0x5d, 0xc3, 0xcb, 0xee, 0xdb, 0x22, 0x0f, 0x49, 0x80, 0x20, 0x79, 0x0a, 0xf2, 0x03, 0xf2, 0x98,
+	0x7f, 0x90, 0x87, 0x3c, 0x05, 0xb0, 0xdf, 0xf2, 0x07, 0x02, 0x38, 0xce, 0x2f, 0xc8, 0x3f, 0x08,
+	0xea, 0xda, 0x97, 0xe9, 0xa1, 0xa4, 0x20, 0xc8, 0xd3, 0x74, 0x9d, 0xcb, 0x57, 0xa7, 0x4e, 0x9d,
+	0xaa, 0x3a, 0x75, 0x6a, 0x00, 0x1d, 0x63, 0xdf, 0xbb, 0x38, 0xb2, 0x9d, 0x57, 0x38, 0x70, 0xef,
+	0x47, 0x71, 0x48, 0x42, 0x54, 0x65, 0x34, 0x63, 0x06, 0x9a, 0x07, 0x97, 0x81, 0x63, 0xe2, 0x6f,
+	0x87, 0x38, 0x21, 0xc6, 0x5f, 0x74, 0x68, 0x1e, 0x86, 0x5d, 0x9b, 0xd8, 0x91, 0x6f, 0x07, 0x18,
+	0xad, 0xc1, 0xb4, 0x17, 0x58, 0xc9, 0x65, 0xe0, 0x74, 0xb4, 0x55, 0x6d, 0xad, 0xf9, 0x70, 0xe6,
+	0x3e, 0xd3, 0xbb, 0xdf, 0x0b, 0xa8, 0xda, 0xce, 0x84, 0x59, 0xf3, 0xd8, 0x17, 0x7a, 0x02, 0x2d,
+	0x2f, 0x4a, 0x30, 0xb1, 0x86, 0x91, 0x6b, 0x13, 0xdc, 0xa9, 0x30, 0x71, 0x24, 0xc5, 0xf7, 0x0f,
+	0x30, 0xf9, 0x92, 0x71, 0x76, 0x26, 0xcc, 0x26, 0x93, 0xe4, 0x4d, 0xf4, 0x1c, 0x10, 0x57, 0x74,
+	0xb1, 0x4f, 0x6c, 0xa9, 0x3e, 0xc9, 0xd4, 0x97, 0xb2, 0xea, 0x5d, 0xca, 0x57, 0x18, 0x3a, 0x53,
+	0xca, 0xd0, 0x52, 0x0b, 0x62, 0x3c, 0x08, 0xcf, 0x70, 0x67, 0x6a, 0xd4, 0x02, 0x93, 0x71, 0x94,
+	0x05, 0xbc, 0x89, 0xf6, 0x61, 0xc1, 0x76, 0x88, 0x77, 0x86, 0xad, 0x28, 0x0e, 0x8f, 0x3d, 0x1f,
+	0x4b, 0x23, 0xaa, 0x0c, 0x61, 0x45, 0x20, 0x6c, 0x30, 0x99, 0x7d, 0x2e, 0xa2, 0xec, 0x98, 0xb3,
+	0x47, 0xc9, 0x25, 0x88, 0xc2, 0xa6, 0xda, 0x78, 0x44, 0x65, 0xdb, 0x9c, 0x3d, 0x4a, 0x46, 0x2f,
+	0x60, 0x5e, 0x22, 0x86, 0xbe, 0xe7, 0x5c, 0x4a, 0x13, 0xa7, 0x19, 0xe0, 0x72, 0x1e, 0x90, 0x49,
+	0x28, 0x0b, 0x91, 0x3d, 0x42, 0x1d, 0x85, 0x13, 0xf6, 0xd5, 0xc7, 0xc2, 0x29, 0xf3, 0x90, 0x3d,
+	0x42, 0xa5, 0x70, 0xa7, 0x61, 0x42, 0x2c, 0x1c, 0xb8, 0x51, 0xe8, 0x05, 0x2a, 0x08, 0x1a, 0x39,
+	0xb8, 0x9d, 0x30, 0x21, 0xdb, 0x42, 0x22, 0xb5, 0xee, 0x74, 0x84, 0x3a, 0x0a, 0x27, 0xac, 0x83,
+	0xb1, 0x70, 0xa9, 0x75, 0xa7, 0x23, 0x54, 0xf4, 0x35, 0x74, 0xce, 0xc3, 0xf8, 0x95, 0x1f, 0xda,
+	0xee, 0x88, 0x85, 0x4d, 0x06, 0x79, 0x53, 0x40, 0x7e, 0x25, 0xc4, 0x46, 0xac, 0x5c, 0x3c, 0x2f,
+	0xe5, 0x94, 0x43, 0x0b, 0x6b, 0x5b, 0x57, 0x42, 0x2b, 0x8b, 0x17, 0xcf, 0x4b, 0x39, 0xe8, 0x13,
+	0x98, 0x71, 0xc2, 0xe0, 0xd8, 0x3b, 0x91, 0xa6, 0xce, 0x30, 0xbc, 0x39, 0x81, 0xb7, 0xc5, 0x78,
+	0xca, 0xc0, 0x96, 0x93, 0x69, 0x2b, 0x07, 0x0e, 0x30, 0xb1, 0x5d, 0x3b, 0x5d, 0x55, 0xed, 0x11,
+	0x07, 0xbe, 0x10, 0x12, 0xf9, 0xf9, 0xc8, 0x53, 0xd1, 0x5d, 0x98, 0x4d, 0xe8, 0x06, 0x11, 0x38,
+	0xd8, 0x0a, 0x86, 0x83, 0x23, 0x1c, 0x77, 0x66, 0x57, 0xb5, 0xb5, 0x29, 0xb3, 0x2d, 0xc9, 0x7d,
+	0x46, 0x45, 0x1b, 0xa0, 0x7b, 0x91, 0x3d, 0xb0, 0xa2, 0x30, 0xf4, 0x65, 0x9f, 0x3a, 0xeb, 0x73,
+	0x41, 0x2d, 0xc3, 0x8d, 0x17, 0xfb, 0x61, 0xe8, 0xab, 0xfe, 0xda, 0x54, 0x21, 0xa5, 0xe4, 0x21,
+	0x84, 0x27, 0xaf, 0x95, 0x42, 0x28, 0x0f, 0x2a, 0x88, 0x42, 0x34, 0xaa, 0xd1, 0x0b, 0x18, 0x34,
+	0x76, 0xf4, 0xf9, 0xf0, 0xc9, 0x53, 0xd1, 0x01, 0x2c, 0x26, 0x38, 0x3e, 0xf3, 0x1c, 0x6c, 0xd9,
+	0x8e, 0x13, 0x0e, 0xd3, 0xe0, 0x99, 0x63, 0x80, 0xd7, 0x05, 0xe0, 0x01, 0x17, 0xda, 0xe0, 0x32,
+	0x6a, 0x80, 0xf3, 0x49, 0x09, 0xbd, 0x0c, 0x54, 0x58, 0x39, 0x7f, 0x05, 0xa8, 0xb2, 0x73, 0x3e,
+	0x29, 0xa1, 0xa3, 0x2d, 0xd0, 0x03, 0x7b, 0x80, 0x93, 0xc8, 0x76, 0xd4, 0x1e, 0xb6, 0xc0, 0xe0,
+	0x16, 0x05, 0x5c, 0x5f, 0xb2, 0x95, 0x79, 0xb3, 0x41, 0x9e, 0x94, 0x07, 0x11, 0x36, 0x2d, 0x96,
+	0x83, 0x28, 0x73, 0x66, 0x83, 0x3c, 0x89, 0xee, 0xc5, 0x71, 0x38, 0x24, 0xca, 0x8a, 0xa5, 0xdc,
+	0x5e, 0x6c, 0x52, 0x56, 0x7a, 0x1a, 0xc4, 0x69, 0x33, 0x55, 0x14, 0x3d, 0x77, 0x46, 0x15, 0xd3,
+	0x4d, 0x3c, 0x4e, 0x9b, 0x68, 0x0b, 0x9a, 0x67, 0x04, 0x47, 0xb2, 0xc3, 0x65, 0xa6, 0xb7, 0x2a,
+	0xf4, 0x5e, 0xfe, 0xe7, 0xee, 0x46, 0xff, 0x70, 0x18, 0x04, 0xd8, 0x1f, 0x59, 0xda, 0x40, 0xd5,
+	0xd4, 0xd8, 0x39, 0x88, 0xe8, 0x7c, 0xe5, 0x75, 0x20, 0xca, 0x14, 0x06, 0x22, 0x2c, 0xf9, 0x06,
+	0x96, 0xcf, 0xbd, 0x18, 0x9f, 0x0c, 0xed, 0x78, 0x74, 0xbf, 0xb9, 0xce, 0x20, 0x6f, 0xc9, 0x4d,
+	0x41, 0xca, 0x8d, 0x58, 0xb5, 0x74, 0x5e, 0xce, 0x1a, 0x83, 0x2e, 0x0c, 0xbe, 0x71, 0x35, 0xba,
+	0x32, 0x77, 0xe9, 0xbc, 0x9c, 0x85, 0xbe, 0x82, 0xce, 0x89, 0x1f, 0x1e, 0xd9, 0xbe, 0x75, 0x74,
+	0x12, 0x59, 0xf9, 0xfd, 0xe7, 0x26, 0x03, 0xbf, 0x21, 0xc0, 0x9f, 0x33, 0xb1, 0xcd, 0xe7, 0xfb,
+	0x85, 0x8d, 0x68, 0x81, 0xeb, 0x6f, 0x9e, 0x44, 0x59, 0xc6, 0x66, 0x03, 0xa6, 0x23, 0xfb, 0x92,
+	0x6e, 0x73, 0xc6, 0x2f, 0xaa, 0x30, 0xf3, 0x59, 0x1c, 0x0e, 0xd2, 0x2c, 0x63, 0x1f, 0x16, 0xa2,
+	0x38, 0x74, 0x70, 0x92, 0x58, 0x09, 0xb1, 0xc9, 0x30, 0xc9, 0x67, 0x01, 0xf2, 0xb8, 0xdc, 0xe7,
+	0x32, 0x07, 0x4c, 0x24, 0x3d, 0x80, 0xa3, 0x51, 0x32, 0xfa, 0x6f, 0xb8, 0x9e, 0x3f, 0x41, 0xf2,
+	0xb8, 0x3c, 0x35, 0xb8, 0x5d, 0x72, 0x90, 0x14, 0xc0, 0x3b, 0xa7, 0x63, 0x78, 0x63, 0x7b, 0x10,
+	0x33, 0x51, 0x7d, 0x4d, 0x0f, 0x6a, 0x2a, 0x3a, 0xa7, 0x63, 0x78, 0xc8, 0x87, 0xdb, 0xa3, 0x67,
+	0x4b, 0x7e, 0x1c, 0x3c, 0x9d, 0x78, 0x77, 0xcc, 0x11, 0x53, 0x18, 0xcb, 0x8d, 0xf3, 0x2b, 0xf8,
+	0x57, 0xf6, 0x26, 0xc6, 0x34, 0xfd, 0x06, 0xbd, 0xa9, 0x71, 0xdd, 0x38, 0xbf, 0x82, 0x5f, 0x76,
+	0xa2, 0xd4, 0x4b, 0x4f, 0x94, 0x97, 0x90, 0xc6, 0x6a, 0x61, 0xf0, 0x8d, 0x5c, 0x3c, 0xaa, 0x60,
+	0x2f, 0x8c, 0x7a, 0xe1, 0xbc, 0x8c, 0x91, 0x8d, 0xc7, 0xff, 0xd7, 0xa0, 0x95, 0x8d, 0x55, 0xf4,
+	0x04, 0x6a, 0x3c, 0xf2, 0x3b, 0xda, 0xea, 0x64, 0x66, 0x16, 0xb3, 0x42, 0xa2, 0xb1, 0x1d, 0x90,
+	0xf8, 0xd2, 0x14, 0xe2, 0x2b, 0x4f, 0xa1, 0x99, 0x21, 0x23, 0x1d, 0x26, 0x5f, 0xe1, 0x4b, 0x96,
+	0x38, 0x37, 0x4c, 0xfa, 0x89, 0xe6, 0xa1, 0x7a, 0x66, 0xfb, 0x43, 0x9e, 0x1d, 0x37, 0x4c, 0xde,
+	0xf8, 0xa4, 0xf2, 0xb1, 0x66, 0xd4, 0xa1, 0xc6, 0x53, 0x6a, 0xe3, 0x37, 0x1a, 0x34, 0x33, 0xe9,
+	0x32, 0x6a, 0x43, 0xc5, 0x73, 0x05, 0x48, 0xc5, 0x73, 0x51, 0x07, 0xa6, 0x07, 0x98, 0xfa, 0x26,
+	0xe9, 0x54, 0x56, 0x27, 0xd7, 0x1a, 0xa6, 0x6c, 0xa2, 0x07, 0x30, 0x45, 0x2e, 0x23, 0xbe, 0x6a,
+	0xda, 0xca, 0x31, 0x19, 0x2c, 0xfe, 0x7d, 0x78, 0x19, 0x61, 0x93, 0x49, 0x1a, 0x1f, 0x40, 0x43,
+	0x91, 0x50, 0x0d, 0x2a, 0xbd, 0x7d, 0x7d, 0x02, 0xcd, 0xd2, 0xfe, 0xad, 0x8d, 0x7e, 0xd7, 0xda,
+	0xdf, 0x33, 0x0f, 0x75, 0x0d, 0x4d, 0xc3, 0x64, 0x7f, 0xfb, 0x50, 0xaf, 0x18, 0x11, 0xe8, 0xc5,
+	0x4c, 0x7c, 0xc4, 0xbc, 0x77, 0x61, 0xc6, 0x76, 0x5d, 0xec, 0x5a, 0x79, 0x23, 0x5b, 0x8c, 0xf8,
+	0x42, 0x58, 0x7a, 0x17, 0x66, 0x79, 0x4c, 0xa5, 0x62, 0x93, 0x4c, 0xac, 0x2d, 0xc8, 0x42, 0xd0,
+	0xb8, 0x29, 0x7c, 0x21, 0xc2, 0xa6, 0xd0, 0x99, 0x61, 0xc3, 0x5c, 0x49, 0x56, 0x8e, 0x56, 0x95,
+	0x58, 0xf3, 0xa1, 0x9e, 0x6e, 0x1e, 0x54, 0xa2, 0xd7, 0x65, 0x56, 0xae, 0xc1, 0xb4, 0xc8, 0xcc,
+	0xc5, 0x45, 0xa5, 0x9d, 0x17, 0x33, 0x25, 0xdb, 0x78, 0x52, 0xe8, 0x42, 0x58, 0xf2, 0xda, 0x2e,
+	0x8c, 0xdb, 0xd0, 0x50, 0x04, 0x84, 0x60, 0x8a, 0x1e, 0x91, 0xc2, 0x74, 0xf6, 0x6d, 0x84, 0x30,
+	0x2d, 0x04, 0xd0, 0x03, 0x98, 0xf1, 0x82, 0xa3, 0x70, 0x18, 0xb8, 0x56, 0x3c, 0xf4, 0x71, 0x22,
+	0x02, 0xaf, 0x29, 0x8f, 0xbd, 0xa1, 0x8f, 0xcd, 0x96, 0x90, 0xa0, 0x8d, 0x04, 0x3d, 0x84, 0x76,
+	0x38, 0x24, 0x59, 0x95, 0xca, 0xa8, 0xca, 0x8c, 0x14, 0x61, 0x3a, 0xc6, 0x37, 0x80, 0x46, 0x2f,
+	0x08, 0xe8, 0x76, 0x66, 0x24, 0xb3, 0x72, 0x24, 0x4c, 0x40, 0xf8, 0xea, 0x0e, 0xd4, 0xf8, 0x25,
+	0xa1, 0x53, 0xc9, 0x5d, 0x01, 0xb9, 0x90, 0x29, 0x98, 0xc6, 0xe3, 0x3c, 0xba, 0xf0, 0xd3, 0xeb,
+	0xd0, 0x8d, 0x87, 0x50, 0x97, 0x6d, 0xea, 0x25, 0xe2, 0xe1, 0x58, 0x7a, 0x89, 0x7e, 0x2b, 0xcf,
+	0x55, 0x32, 0x9e, 0xfb, 0xa3, 0x06, 0x35, 0xae, 0xf4, 0xaf, 0xf1, 0x1c, 0xba, 0x01, 0x8d, 0x61,
+	0x40, 0x62, 0x7a, 0x81, 0x76, 0xd9, 0xf2, 0xaa, 0x9b, 0x29, 0x01, 0x2d, 0x43, 0x3d, 0x8a, 0xb1,
+	0xe5, 0x06, 0x36, 0x61, 0x27, 0x4b, 0x9d, 0x46, 0x0f, 0xee, 0x06, 0x36, 0xa1, 0x8a, 0x2a, 0x35,
+	0x62, 0x67, 0x42, 0xc3, 0x4c, 0x09, 0xc6, 0xcf, 0xdb, 0x30, 0x45, 0x3b, 0x40, 0x8b, 0x50, 0xa3,
+	0xb7, 0xaa, 0x30, 0x10, 0x43, 0x17, 0x2d, 0xf4, 0x21, 0x80, 0x17, 0x59, 0x67, 0x38, 0x4e, 0x28,
+	0xaf, 0xc2, 0xd6, 0xb5, 0xae, 0xd6, 0xf5, 0x4b, 0x4e, 0x37, 0x1b, 0x5e, 0x24, 0x3e, 0xd1, 0xbf,
+	0x51, 0x53, 0x42, 0x12, 0x3a, 0xa1, 0xdf, 0x99, 0xcc, 0x3b, 0x5d, 0x90, 0x4d, 0x25, 0x80, 0x96,
+	0x60, 0x3a, 0x89, 0x1d, 0x2b, 0xc0, 0xd4, 0x6c, 0xba, 0xfa, 0x6a, 0x49, 0xec, 0xf4, 0x31, 0x41,
+	0x1f, 0x40, 0x83, 0x32, 0xa2, 0x30, 0x26, 0x49, 0xa7, 0xca, 0xbc, 0xa3, 0x62, 0x3c, 0x8c, 0x89,
+	0x69, 0x07, 0x27, 0xd8, 0xac, 0x27, 0xb1, 0x43, 0x5b, 0x09, 0xc5, 0x71, 0x13, 0xc2, 0x70, 0x6a,
+	0x1c, 0xc7, 0x4d, 0x88, 0xc0, 0xa1, 0x0c, 0x8e, 0x33, 0x3d, 0x0e, 0xc7, 0x4d, 0x08, 0xc7, 0xb9,
+	0x09, 0x0d, 0xcf, 0x19, 0x44, 0x16, 0xdb, 0xc4, 0xe8, 0x71, 0x50, 0xdd, 0x99, 0x30, 0xeb, 0x94,
+	0xc4, 0xf6, 0xa7, 0x67, 0xd0, 0x56, 0x6c, 0xcb, 0x09, 0x5d, 0x79, 0x02, 0xc8, 0xb4, 0xb4, 0x27,
+	0x04, 0x37, 0x02, 0x77, 0x2b, 0x74, 0xd9, 0xa5, 0x48, 0xea, 0xd2, 0x36, 0x7a, 0x17, 0xda, 0x74,
+	0x54, 0x5e, 0x64, 0xd1, 0x22, 0x81, 0xe7, 0x26, 0x1d, 0x60, 0xd6, 0x36, 0x93, 0xd8, 0xe9, 0x45,
+	0x07, 0x98, 0xf4, 0xdc, 0x84, 0x0a, 0x51, 0x93, 0x33, 0x42, 0x4d, 0x2e, 0xe4, 0x26, 0x44, 0x09,
+	0x3d, 0x81, 0x65, 0xe6, 0x38, 0x7b, 0x80, 0x5d, 0x36, 0xba, 0xac, 0x7c, 0x8b, 0xc9, 0xcf, 0x53,
+	0x57, 0x52, 0x3e, 0x1d, 0x5a, 0x56, 0x91, 0x79, 0xaa, 0x54, 0x71, 0x86, 0x2b, 0x52, 0xdf, 0x8d,
+	0x28, 0x3e, 0x84, 0x56, 0x10, 0x12, 0x4b, 0xcd, 0xed, 0x71, 0xf9, 0xdc, 0x36, 0x83, 0x90, 0xc8,
+	0x06, 0xba, 0x05, 0xb4, 0x69, 0xc9, 0x29, 0x3e, 0x61, 0xf0, 0x8d, 0x20, 0x24, 0x07, 0x7c, 0x96,
+	0x1f, 0xc1, 0x8c, 0xe4, 0xf3, 0x19, 0x3a, 0x1d, 0x33, 0x43, 0x4d, 0xae, 0xc3, 0x27, 0x49, 0xa0,
+	0xca, 0x09, 0xf7, 0x14, 0x6a, 0x37, 0x21, 0x19, 0xd4, 0x74, 0xde, 0xff, 0xe7, 0x0a, 0xd4, 0xae,
+	0x9c, 0xfa, 0xf7, 0xb8, 0x56, 0x3a, 0xfd, 0xaf, 0xd8, 0xf4, 0x6b, 0x4c, 0x4a, 0x4e, 0x2c, 0xda,
+	0x06, 0x94, 0x93, 0xe2, 0x51, 0xe0, 0x5f, 0x19, 0x05, 0x9a, 0x39, 0x9b, 0x81, 0xa0, 0x24, 0x74,
+	0x0f, 0x90, 0x1c, 0x78, 0xc6, 0xfd, 0x03, 0x7e, 0x00, 0xf1, 0xb1, 0x2a, 0xc7, 0x0b, 0xd9, 0x42,
+	0x4c, 0x04, 0x4a, 0xb6, 0x9b, 0x09, 0x8b, 0x67, 0x70, 0x53, 0x39, 0xbc, 0x74, 0x86, 0x23, 0xa6,
+	0xb6, 0x24, 0xa6, 0x60, 0x64, 0x92, 0x85, 0xfe, 0xf8, 0x08, 0xf9, 0x56, 0xe9, 0x77, 0xcb, 0x83,
+	0x64, 0x21, 0x8c, 0xbd, 0x13, 0x2f, 0xb0, 0x7d, 0x66, 0x44, 0x82, 0x7d, 0xec, 0x90, 0x30, 0xee,
+	0xc4, 0x6c, 0x53, 0x99, 0x93, 0xcc, 0x83, 0xd8, 0x39, 0x10, 0xac, 0x9c, 0x0e, 0xed, 0x58, 0xe9,
+	0x24, 0x79, 0x9d, 0x6e, 0x42, 0x94, 0xce, 0x36, 0xdc, 0xce, 0xf5, 0x93, 0x5e, 0x17, 0x95, 0x36,
+	0x61, 0xda, 0x37, 0x32, 0x3d, 0xaa, 0x4b, 0x63, 0x29, 0x8c, 0x1c, 0x73, 0x01, 0x66, 0x98, 0x87,
+	0x11, 0xa3, 0xce, 0xc3, 0x3c, 0x85, 0x65, 0x05, 0x23, 0xdd, 0xaf, 0x00, 0xce, 0x18, 0xc0, 0xa2,
+	0x14, 0xe8, 0x33, 0xcf, 0x8f, 0x55, 0xcd, 0x39, 0xe0, 0x7c, 0x44, 0x35, 0xeb, 0x83, 0x2f, 0xf9,
+	0x16, 0x50, 0xbc, 0xc3, 0x0f, 0x6c, 0xe2, 0x9c, 0x76, 0x2e, 0x72, 0xd7, 0x96, 0xfc, 0x15, 0xfe,
+	0x05, 0x95, 0x30, 0x17, 0x93, 0xd8, 0x29, 0xa1, 0x53, 0x58, 0x6e, 0x44, 0x19, 0xec, 0xe5, 0xeb,
+	0x61, 0xdd, 0x84, 0x94, 0xd0, 0xe9, 0x39, 0x72, 0x4a, 0x48, 0x24, 0x70, 0xfe, 0x37, 0x97, 0xb5,
+	0xec, 0x1c, 0x1e, 0xee, 0x73, 0xed, 0x06, 0x95, 0x91, 0x0a, 0x75, 0x59, 0x3d, 0xe9, 0xfc, 0x5f,
+	0xae, 0xee, 0x44, 0xcf, 0x2b, 0x55, 0x20, 0x51, 0x42, 0x34, 0x2b, 0xa5, 0x87, 0xa9, 0xe5, 0xb9,
+	0x9d, 0xef, 0xc5, 0x19, 0x46, 0xdb, 0x3d, 0x77, 0xb3, 0x06, 0x53, 0x74, 0xc1, 0x6e, 0x02, 0xd4,
+	0xe5, 0xe2, 0xfd, 0xbc, 0x56, 0xff, 0x4e, 0xd3, 0xbf, 0xd7, 0x4c, 0xf0, 0xc3, 0x13, 0x2b, 0x8a,
+	0xf1, 0xb1, 0x77, 0x61, 0x3c, 0x87, 0xb9, 0x32, 0xd3, 0x57, 0xa0, 0xae, 0xa6, 0x84, 0x03, 0xab,
+	0x36, 0x4d, 0xa7, 0x59, 0xd0, 0x88, 0x1c, 0x93, 0x37, 0x8c, 0xdf, 0x6a, 0xd0, 0x50, 0x83, 0xe2,
+	0xe9, 0x32, 0x39, 0x0d, 0x5d, 0x9e, 0x1a, 0x34, 0x4c, 0xd9, 0x44, 0x0f, 0xa0, 0x1a, 0xd9, 0xe4,
+	0x54, 0x9e, 0xff, 0x2b, 0x45, 0x7f, 0xdc, 0xdf, 0xb7, 0xc9, 0x29, 0xfb, 0x32, 0xb9, 0xe0, 0xca,
+	0x17, 0xd0, 0x50, 0x34, 0xb4, 0x08, 0x55, 0x7c, 0x61, 0x3b, 0x84, 0x5b, 0xb5, 0x33, 0x61, 0xf2,
+	0x26, 0xea, 0x40, 0x8d, 0x8f, 0x88, 0xa7, 0x2c, 0xb4, 0x44, 0xce, 0xdb, 0x9b, 0x2d, 0x00, 0x8a,
+	0xc3, 0x67, 0xc1, 0xf8, 0xb5, 0x06, 0xad, 0xac, 0x33, 0xd1, 0x67, 0xd0, 0xb4, 0x83, 0x20, 0x24,
+	0x36, 0x3d, 0xfa, 0x65, 0x22, 0xf3, 0x5e, 0x89, 0xdb, 0xef, 0x6f, 0xa4, 0x62, 0xfc, 0x02, 0x92,
+	0x55, 0x5c, 0x79, 0x06, 0x7a, 0x51, 0xe0, 0xad, 0xae, 0x22, 0x4f, 0x61, 0xb6, 0xb0, 0x89, 0xb2,
+	0xc4, 0x8c, 0xee, 0xca, 0x54, 0xbf, 0xca, 0xef, 0x0e, 0x94, 0xc6, 0xb6, 0xdf, 0x0a, 0xa7, 0xd1,
+	0x6f, 0x63, 0x17, 0xea, 0xea, 0xf8, 0xe9, 0x40, 0x4d, 0xdc, 0xec, 0x34, 0x71, 0x94, 0x8b, 0x36,
+	0x9a, 0xcf, 0xa6, 0x74, 0x3b, 0x13, 0x3c, 0xa9, 0xdb, 0xd4, 0xa1, 0xcd, 0xf9, 0x56, 0x18, 0xb3,
+	0xbd, 0xc0, 0x78, 0x0c, 0x0d, 0x75, 0x5c, 0x50, 0x7b, 0x8f, 0xbd, 0x38, 0x21, 0xc2, 0x06, 0xde,
+	0xa0, 0x46, 0xf8, 0x76, 0x42, 0xa4, 0x11, 0xf4, 0xdb, 0xf8, 0xa5, 0x06, 0xa8, 0x78, 0x39, 0xed,
+	0x75, 0xe9, 0x9d, 0x23, 0x8c, 0x9d, 0x53, 0x9c, 0x90, 0xd8, 0x26, 0x61, 0x4c, 0x23, 0x95, 0x0f,
+	0xbd, 0x9d, 0x25, 0xf7, 0x5c, 0x74, 0x1b, 0x9a, 0xea, 0x26, 0xec, 0xf1, 0x74, 0xaf, 0x61, 0x82,
+	0x24, 0x71, 0x01, 0x75, 0x43, 0xf6, 0x5c, 0x96, 0xf2, 0x35, 0x4c, 0x90, 0xa4, 0x9e, 0xfb, 0xf9,
+	0x54, 0x5d, 0xd3, 0x2b, 0x66, 0x9d, 0xde, 0xec, 0xd9, 0x40, 0x2e, 0x60, 0xb1, 0xbc, 0xb2, 0x8c,
+	0xde, 0xcf, 0xa4, 0xc7, 0xcb, 0x63, 0x2e, 0xd6, 0x22, 0x0d, 0xff, 0x08, 0xea, 0xb2, 0x8b, 0x4e,
+	0x35, 0xf7, 0x3a, 0x52, 0x54, 0x30, 0x95, 0xa0, 0xf1, 0x43, 0x05, 0xf4, 0x22, 0x9b, 0xba, 0x92,
+	0xde, 0xa4, 0xe5, 0x6d, 0x84, 0x37, 0xca, 0x12, 0x6d, 0x1a, 0x36, 0x03, 0xdb, 0x11, 0x2e, 0xa0,
+	0x9f, 0x74, 0xec, 0xf2, 0x49, 0x83, 0x9e, 0x48, 0x3c, 0x6f, 0x04, 0x41, 0xa2, 0x87, 0xd0, 0x75,
+	0x68, 0x78, 0xd1, 0xd9, 0x23, 0x9a, 0x1c, 0xf0, 0xdc, 0xb1, 0x61, 0xd6, 0x29, 0xa1, 0x8f, 0x89,
+	0x64, 0xae, 0x73, 0x66, 0x4d, 0x31, 0xd7, 0x19, 0xf3, 0x0e, 0x54, 0x89, 0x87, 0x63, 0x99, 0x29,
+	0xca, 0xe4, 0xe6, 0xd0, 0xc3, 0x71, 0x2f, 0x38, 0x0e, 0x4d, 0xce, 0x45, 0xef, 0x43, 0x9d, 0x77,
+	0x60, 0x93, 0x4e, 0x7d, 0x75, 0x32, 0x73, 0x77, 0xeb, 0xdb, 0x84, 0x09, 0x4e, 0xb3, 0xfe, 0x6c,
+	0x22, 0x44, 0xd7, 0x99, 0x68, 0x63, 0xac, 0xe8, 0x3a, 0x15, 0xfd, 0x18, 0x66, 0x55, 0x42, 0x6f,
+	0x71, 0x33, 0xa0, 0xdc, 0x8c, 0xb6, 0x92, 0xa3, 0xa4, 0xc4, 0xd8, 0x1a, 0x9d, 0x5c, 0x71, 0xf7,
+	0x79, 0xf3, 0xc9, 0x35, 0x36, 0xa0, 0x9d, 0xad, 0x11, 0xf5, 0xba, 0xc5, 0x20, 0xab, 0xbc, 0x36,
+	0xc8, 0x7c, 0x40, 0xa3, 0x0f, 0x2c, 0xe8, 0x4e, 0xc6, 0x86, 0x85, 0x92, 0x6a, 0x94, 0x08, 0xae,
+	0x0f, 0x33, 0xc1, 0x35, 0x99, 0xdb, 0xef, 0xb3, 0xc2, 0x99, 0xc0, 0xfa, 0x5b, 0x05, 0x5a, 0x59,
+	0x56, 0xd9, 0x0d, 0xb7, 0x18, 0x2c, 0x95, 0x91, 0x60, 0x51, 0x53, 0x3e, 0x79, 0xe5, 0x94, 0xdf,
+	0x87, 0x39, 0x7c, 0x11, 0x61, 0x87, 0x60, 0xd7, 0x62, 0x73, 0x6f, 0xbb, 0x6e, 0x2c, 0x83, 0xef,
+	0x9a, 0x64, 0xf5, 0xa2, 0xb3, 0x47, 0x1b, 0xae, 0x3b, 0x2a, 0xbf, 0x2e, 0xe4, 0xab, 0x23, 0xf2,
+	0xeb, 0x5c, 0xbe, 0x64, 0xf2, 0x6b, 0x6f, 0x34, 0xf9, 0xe8, 0x31, 0xb4, 0xe5, 0xd5, 0xcf, 0xba,
+	0x32, 0x78, 0x5b, 0xe2, 0x46, 0xc8, 0xd5, 0x1e, 0xc1, 0xcc, 0x71, 0x18, 0x9f, 0xd3, 0x9a, 0x16,
+	0xd7, 0xaa, 0x8f, 0xd1, 0x12, 0x52, 0x3c, 0xd2, 0xfe, 0x3d, 0x3f, 0xc3, 0x22, 0xca, 0xde, 0x6c,
+	0x86, 0x8d, 0x18, 0xea, 0x12, 0xb6, 0x74, 0xae, 0xde, 0x07, 0xdd, 0x0b, 0x4e, 0x62, 0x5a, 0x83,
+	0x65, 0x17, 0x7a, 0x4f, 0x1d, 0xab, 0xb3, 0x82, 0xbe, 0x2f, 0xc8, 0x74, 0x27, 0xc5, 0x05, 0x49,
+	0x51, 0xbd, 0xc1, 0x39, 0x41, 0xe3, 0x09, 0x4c, 0x8b, 0x85, 0x86, 0x16, 0xa0, 0x86, 0x2f, 0x68,
+	0x32, 0x2b, 0x37, 0x1d, 0x7c, 0x41, 0x7a, 0x11, 0x25, 0xb3, 0x00, 0x8f, 0xe4, 0x31, 0x44, 0x0d,
+	0x8e, 0x0c, 0x13, 0xe6, 0x4a, 0x8a, 0xbd, 0xb4, 0xb6, 0xe4, 0x25, 0xa1, 0x45, 0xbc, 0x01, 0x4e,
+	0x88, 0x3d, 0x90, 0x58, 0x2d, 0x2f, 0x09, 0x0f, 0x25, 0x8d, 0xde, 0xa5, 0x87, 0x11, 0x15, 0x61,
+	0x90, 0x9a, 0x29, 0x5a, 0x46, 0x04, 0x9d, 0x71, 0x85, 0xde, 0x37, 0x5d, 0x25, 0x1f, 0x40, 0x8d,
+	0x97, 0x20, 0x3b, 0x95, 0x9c, 0x68, 0x1e, 0xd3, 0x14, 0x42, 0xc6, 0x1a, 0xb4, 0xf3, 0x1c, 0x6a,
+	0x9b, 0x00, 0x10, 0x39, 0x92, 0x90, 0xdc, 0x28, 0xb3, 0xed, 0xed, 0xe6, 0xf7, 0x02, 0x6e, 0x5c,
+	0x55, 0xff, 0x7d, 0x9b, 0x93, 0xe6, 0x2d, 0x87, 0xd9, 0x1b, 0xd7, 0xf3, 0xdb, 0x6f, 0x83, 0xeb,
+	0xb0, 0x50, 0x5a, 0xc7, 0x45, 0x37, 0x01, 0xa2, 0xe1, 0x91, 0xef, 0x39, 0x56, 0x9a, 0xc6, 0x34,
+	0x38, 0xe5, 0x0b, 0x7c, 0x69, 0xbc, 0xe0, 0x2b, 0xa3, 0xf0, 0x6c, 0xb9, 0x02, 0x6a, 0x77, 0x94,
+	0xa9, 0xa3, 0x6c, 0xab, 0x63, 0x8a, 0xee, 0x0c, 0x22, 0xf6, 0xd8, 0xb1, 0x42, 0x37, 0x84, 0x22,
+	0x9c, 0x18, 0xc7, 0x3f, 0x0c, 0xb7, 0x0d, 0xed, 0xfc, 0xb3, 0x67, 0x49, 0xd1, 0x74, 0x2a, 0x0a,
+	0x43, 0x5f, 0xf8, 0x7b, 0xb6, 0xf8, 0xd0, 0xc9, 0x98, 0xc6, 0x6a, 0x0a, 0x33, 0xa6, 0x1c, 0xfa,
+	0x0c, 0xea, 0x52, 0x82, 0xa5, 0x67, 0x9e, 0xab, 0x6a, 0x69, 0xf4, 0x1b, 0xdd, 0x02, 0x18, 0xd8,
+	0xc9, 0xb7, 0x43, 0x1c, 0xdb, 0x22, 0x71, 0xab, 0x9b, 0x19, 0x8a, 0xf1, 0x7b, 0x0d, 0xe6, 0xcb,
+	0x5e, 0x31, 0xd1, 0xdd, 0xcc, 0x14, 0x2e, 0x95, 0xde, 0x3f, 0x44, 0xe8, 0x7c, 0x0a, 0x35, 0xdf,
+	0x3e, 0xc2, 0xbe, 0x4c, 0xaa, 0xef, 0x5e, 0xf1, 0x36, 0x7a, 0x7f, 0x97, 0x49, 0x8a, 0x12, 0x3a,
+	0x57, 0xa3, 0x25, 0xf4, 0x0c, 0xf9, 0xad, 0xf2, 0xd6, 0x4f, 0x8b, 0xc6, 0xab, 0xb7, 0x86, 0x37,
+	0x33, 0xde, 0xe8, 0x82, 0x5e, 0xa4, 0xe7, 0x0b, 0x78, 0x5a, 0xa1, 0x80, 0x57, 0x5a, 0x9c, 0xfc,
+	0x9d, 0x06, 0xb3, 0x85, 0x67, 0x56, 0x64, 0x64, 0x4c, 0x40, 0xc5, 0x57, 0x54, 0xe1, 0xba, 0x4f,
+	0x0a, 0xae, 0x33, 0xca, 0x9f, 0x6c, 0xff, 0xd9, 0x5e, 0x7b, 0x9c, 0xb1, 0x56, 0x38, 0xec, 0x0d,
+	0xac, 0x35, 0xde, 0x81, 0x66, 0x86, 0x54, 0x5a, 0xdf, 0x3e, 0x04, 0xe0, 0xaf, 0xa5, 0x87, 0xe2,
+	0xba, 0xe0, 0x45, 0x62, 0xfb, 0xaf, 0x9b, 0xec, 0x9b, 0x59, 0x75, 0xe1, 0xdb, 0x81, 0x08, 0x45,
+	0xde, 0xa0, 0x2e, 0x57, 0x6f, 0x36, 0xb2, 0xd8, 0xaa, 0x08, 0xc6, 0x9f, 0x2b, 0xd0, 0xcc, 0xbc,
+	0x1f, 0xa3, 0xf7, 0x32, 0x57, 0x93, 0xb4, 0x38, 0xca, 0x24, 0xd2, 0x87, 0x0e, 0xf4, 0x11, 0xfd,
+	0x6f, 0x10, 0xff, 0x4f, 0x01, 0x93, 0xe6, 0xa5, 0xd4, 0x6b, 0x6a, 0xa1, 0xd1, 0x25, 0xc3, 0xc4,
+	0xc1, 0x8b, 0xe4, 0x37, 0x75, 0xa3, 0x9b, 0x10, 0x99, 0xfd, 0xba, 0x09, 0x41, 0x06, 0xcc, 0xb0,
+	0x4a, 0x45, 0xe8, 0x62, 0x76, 0x45, 0x11, 0xb9, 0x3f, 0x2d, 0x0e, 0xf6, 0x43, 0x17, 0x53, 0x8f,
+	0xd0, 0x02, 0x99, 0x92, 0xf1, 0x22, 0x59, 0xf4, 0x15, 0x12, 0xbd, 0x88, 0x26, 0x45, 0x89, 0x3d,
+	0xc0, 0x56, 0x32, 0x3c, 0xa2, 0x05, 0xb4, 0x69, 0xbe, 0x0a, 0x29, 0xe9, 0x80, 0x51, 0xd0, 0x3b,
+	0xd0, 0xa2, 0xe9, 0x44, 0x38, 0x24, 0x27, 0xa1, 0x17, 0x9c, 0xb0, 0x4a, 0x68, 0xdd, 0x6c, 0x06,
+	0x36, 0xd9, 0x13, 0x24, 0x74, 0x07, 0xda, 0x7e, 0xe8, 0xd8, 0xbe, 0x25, 0x6f, 0x25, 0xac, 0x14,
+	0x5a, 0x37, 0x67, 0x18, 0x55, 0x6e, 0xae, 0xe8, 0x21, 0x34, 0x09, 0x9b, 0x01, 0x3e, 0x68, 0xfe,
+	0xf7, 0x19, 0x39, 0xe8, 0x74, 0x6e, 0x4c, 0x20, 0xea, 0xdb, 0xb8, 0x2d, 0xdc, 0x2b, 0x62, 0x41,
+	0xf8, 0xa0, 0xa2, 0x7c, 0x60, 0xfc, 0x54, 0x83, 0xe5, 0xb1, 0xef, 0xe9, 0x2c, 0x10, 0x42, 0x97,
+	0x4f, 0x07, 0x0d, 0x84, 0xd0, 0x55, 0xb7, 0x88, 0x4a, 0x7a, 0x8b, 0xc8, 0x6d, 0x97, 0x93, 0xf9,
+	0xed, 0x12, 0xad, 0x81, 0x1e, 0xd9, 0x31, 0x0e, 0x88, 0xe5, 0x62, 0x56, 0x05, 0xf1, 0x22, 0xe1,
+	0xe7, 0x36, 0xa7, 0x77, 0x19, 0xb9, 0x17, 0x19, 0x1f, 0x96, 0x5a, 0x22, 0x2c, 0x2f, 0xb1, 0xc4,
+	0xf8, 0x89, 0x06, 0x4b, 0x63, 0xde, 0xdc, 0xaf, 0xdc, 0xde, 0xf3, 0xc7, 0x4f, 0xa5, 0x70, 0xfc,
+	0xd0, 0x7c, 0xd3, 0x0b, 0x08, 0x8e, 0x8f, 0x6d, 0x66, 0x6d, 0x7e, 0x60, 0xd7, 0x14, 0x4b, 0x26,
+	0xa8, 0xc6, 0xe3, 0x12, 0x2b, 0x5e, 0x7f, 0xc8, 0x18, 0x7f, 0xd0, 0x60, 0xa1, 0xf4, 0xd9, 0x9d,
+	0x56, 0xf1, 0x64, 0xc9, 0xc8, 0xf1, 0x87, 0x09, 0xc1, 0xb1, 0x45, 0x37, 0x7c, 0x59, 0xf2, 0x98,
+	0x13, 0xcc, 0x2d, 0xce, 0xdb, 0xa2, 0x2c, 0xf4, 0x28, 0xfd, 0x07, 0x0a, 0xbe, 0x20, 0x38, 0xa6,
+	0x45, 0x30, 0xae, 0x54, 0x11, 0x15, 0x6c, 0xce, 0xdd, 0x16, 0x4c, 0xae, 0xf5, 0x1f, 0xb0, 0x22,
+	0xb5, 0x68, 0x88, 0x1d, 0xd9, 0xbe, 0x1d, 0x38, 0xaa, 0x3b, 0x9e, 0x06, 0x76, 0x84, 0xc4, 0x6e,
+	0x46, 0x80, 0x69, 0xdf, 0x5b, 0xa3, 0xef, 0x8d, 0xf2, 0xad, 0x62, 0x1a, 0x26, 0x37, 0xfa, 0x5f,
+	0xeb, 0x13, 0xa8, 0x0e, 0x53, 0xbd, 0xfd, 0x97, 0x8f, 0xf4, 0x29, 0xf1, 0xb5, 0xae, 0xd7, 0xee,
+	0xfd, 0x4c, 0x83, 0x86, 0x5a, 0xc4, 0x68, 0x06, 0x1a, 0x5b, 0xbd, 0xae, 0x69, 0xf5, 0xfa, 0x9f,
+	0xed, 0xe9, 0x13, 0x68, 0x0e, 0x66, 0xcd, 0xed, 0x17, 0x7b, 0x87, 0xdb, 0xd6, 0x57, 0x7b, 0xe6,
+	0x17, 0xbb, 0x7b, 0x1b, 0x5d, 0x5d, 0xa3, 0xcf, 0x96, 0x82, 0xb8, 0xb3, 0x77, 0x70, 0xa8, 0x57,
+	0x10, 0x82, 0xf6, 0xee, 0xde, 0xd6, 0xc6, 0x6e, 0x2a, 0x34, 0x89, 0xda, 0x00, 0x9c, 0xc6, 0x64,
+	0xa6, 0xd0, 0x35, 0x98, 0x11, 0x4a, 0x87, 0x5f, 0xf6, 0xfb, 0xdb, 0xbb, 0x7a, 0x15, 0xe9, 0xd0,
+	0xe2, 0x22, 0x82, 0x52, 0xbb, 0xf7, 0x14, 0x20, 0xdd, 0x21, 0xa8, 0x8d, 0xfd, 0xbd, 0xfe, 0xb6,
+	0x3e, 0x81, 0x5a, 0x50, 0xef, 0xef, 0x59, 0xdb, 0xfd, 0xad, 0x8d, 0x7d, 0x5d, 0x43, 0x0d, 0xa8,
+	0xb2, 0x60, 0xd4, 0x2b, 0x7c, 0x18, 0xbd, 0x7d, 0x7d, 0xf2, 0xe1, 0x33, 0x00, 0xfe, 0x50, 0xc5,
+	0xfe, 0x23, 0xf9, 0x00, 0xa6, 0xd8, 0xaf, 0xdc, 0x54, 0x33, 0xff, 0xbc, 0x5c, 0x91, 0xb4, 0xcc,
+	0xbf, 0x2f, 0x1f, 0x68, 0x9b, 0x4b, 0xff, 0x55, 0x65, 0x35, 0xff, 0xef, 0x7e, 0xbc, 0xa5, 0xfd,
+	0xe9, 0xc7, 0x5b, 0xda, 0x0f, 0x3f, 0xde, 0xd2, 0x7e, 0xf5, 0xd7, 0x5b, 0x13, 0x47, 0x35, 0x46,
+	0xfe, 0xe8, 0xef, 0x03, 0x00, 0x1d, 0x8c, 0x68, 0xc6, 0xdb, 0x29, 0x00, 0x00,
 }
@@ -24,7 +24,7 @@ import (
 )
 
 // Rule is a wrapper structure around netlink rule.
-// Currently it supports FWMark, Source match and table action.
+// Currently it supports FWMark, Source, Destination and incoming interface match and table action.
 type Rule struct {
 	nlRule *netlink.Rule
 }
@@ -45,10 +45,13 @@ func (r *Rule) NetLinkRule() *netlink.Rule {
 }
 
 func (r *Rule) LogCxt() *log.Entry {
-	var src interface{}
+	var src, dst interface{}
 	if r.nlRule.Src != nil {
 		src = r.nlRule.Src
 	}
+	if r.nlRule.Dst != nil {
+		dst = r.nlRule.Dst
+	}
 	return log.WithFields(log.Fields{
 		"ipFamily": r.nlRule.Family,
 		"priority": r.nlRule.Priority,
@@ -56,6 +59,8 @@ func (r *Rule) LogCxt() *log.Entry {
 		"Mark":     r.nlRule.Mark,
 		"Mask":     r.nlRule.Mask,
 		"src":      src,
+		"dst":      dst,
+		"iif":      r.nlRule.IifName,
 		"Table":    r.nlRule.Table,
 	})
 }
@@ -90,6 +95,18 @@ func (r *Rule) MatchSrcAddress(ip net.IPNet) *Rule {
 	return r
 }
 
+func (r *Rule) MatchDstAddress(ip net.IPNet) *Rule {
+	r.nlRule.Dst = &ip
+	return r
+}
+
+// MatchIncomingInterface matches packets that arrived on the given interface.  Locally-originated
+// packets are matched by the name "lo".
+func (r *Rule) MatchIncomingInterface(ifaceName string) *Rule {
+	r.nlRule.IifName = ifaceName
+	return r
+}
+
 func (r *Rule) Not() *Rule {
 	r.nlRule.Invert = true
 	return r
@@ -120,3 +137,12 @@ func RulesMatchSrcFWMark(r, p *Rule) bool {
 func RulesMatchSrcFWMarkTable(r, p *Rule) bool {
 	return RulesMatchSrcFWMark(r, p) && (r.nlRule.Table == p.nlRule.Table)
 }
+
+func RulesMatchDstIifTable(r, p *Rule) bool {
+	return (r.nlRule.Priority == p.nlRule.Priority) &&
+		(r.nlRule.Family == p.nlRule.Family) &&
+		(r.nlRule.Invert == p.nlRule.Invert) &&
+		(r.nlRule.IifName == p.nlRule.IifName) &&
+		ip.IPNetsEqual(r.nlRule.Dst, p.nlRule.Dst) &&
+		(r.nlRule.Table == p.nlRule.Table)
+}
@@ -49,6 +49,8 @@ var _ = Describe("RouteRule Rule build cases", func() {
 		Expect(NewRule(4, 100).Not().NetLinkRule().Invert).To(Equal(true))
 		Expect(NewRule(4, 100).GoToTable(10).NetLinkRule().Table).To(Equal(10))
 		Expect(NewRule(4, 100).MatchSrcAddress(*ip).NetLinkRule().Src.String()).To(Equal("10.0.1.0/26"))
+		Expect(NewRule(4, 100).MatchDstAddress(*ip).NetLinkRule().Dst.String()).To(Equal("10.0.1.0/26"))
+		Expect(NewRule(4, 100).MatchIncomingInterface("lo").NetLinkRule().IifName).To(Equal("lo"))
 		Expect(NewRule(4, 100).Not().
 			MatchFWMark(0x400).
 			MatchSrcAddress(*ip).
@@ -125,4 +127,25 @@ var _ = Describe("RouteRule Rule match cases", func() {
 		Expect(RulesMatchSrcFWMark(r0, new)).To(Equal(false))
 		Expect(RulesMatchSrcFWMarkTable(r0, new)).To(Equal(false))
 	})
+
+	It("should match on dst iif table", func() {
+		r0.NetLinkRule().Dst = mustParseCIDR("10.96.0.0/12")
+		r0.NetLinkRule().IifName = "lo"
+		new := r0.Copy()
+		new.NetLinkRule().Src = nil
+		new.NetLinkRule().Mark = -1
+		Expect(RulesMatchDstIifTable(r0, new)).To(Equal(true))
+
+		new = r0.Copy()
+		new.NetLinkRule().Dst = mustParseCIDR("10.97.0.0/16")
+		Expect(RulesMatchDstIifTable(r0, new)).To(Equal(false))
+
+		new = r0.Copy()
+		new.NetLinkRule().IifName = ""
+		Expect(RulesMatchDstIifTable(r0, new)).To(Equal(false))
+
+		new = r0.Copy()
+		new.NetLinkRule().Table = 20
+		Expect(RulesMatchDstIifTable(r0, new)).To(Equal(false))
+	})
 })
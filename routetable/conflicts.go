@@ -0,0 +1,149 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routetable
+
+import (
+	"net"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+
+	"github.com/projectcalico/felix/ip"
+	"github.com/projectcalico/felix/netlinkshim"
+)
+
+var gaugeRouteConflicts = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "felix_route_table_conflicts",
+	Help: "Number of routes that Felix wants but that another routing daemon has programmed for the same CIDR.",
+})
+
+func init() {
+	prometheus.MustRegister(gaugeRouteConflicts)
+}
+
+// routeConflict is a route that another routing daemon (identified by its route protocol) has
+// programmed for a CIDR that Felix also wants to route.
+//
+// Conflicts are only tracked when external route removal is disabled.  Felix then leaves the
+// other daemon's route in place instead of fighting over the CIDR, but it reports the conflict in
+// the log, the felix_route_table_conflicts metric and the debug endpoint so that an operator can
+// see that the CIDR's ownership is ambiguous.
+type routeConflict struct {
+	linkIndex int
+	gw        net.IP
+	protocol  int
+}
+
+func conflictForRoute(route netlink.Route) routeConflict {
+	return routeConflict{
+		linkIndex: route.LinkIndex,
+		gw:        route.Gw,
+		protocol:  route.Protocol,
+	}
+}
+
+// isForeignRoute returns true if the route was programmed by another routing daemon that Felix
+// leaves alone.
+func (r *RouteTable) isForeignRoute(route netlink.Route) bool {
+	return !r.removeExternalRoutes && route.Protocol != r.deviceRouteProtocol
+}
+
+// findConflictingRoute looks for another daemon's route for the CIDR anywhere in our routing
+// table.  Called when adding one of our routes fails, since the kernel refuses to add a second
+// route for the same destination and metric.
+func (r *RouteTable) findConflictingRoute(nl netlinkshim.Interface, cidr ip.CIDR) (routeConflict, bool) {
+	if r.removeExternalRoutes {
+		return routeConflict{}, false
+	}
+	ipNet := cidr.ToIPNet()
+	routeFilter := &netlink.Route{
+		Dst:   &ipNet,
+		Table: r.tableIndex,
+	}
+	routeFilterFlags := netlink.RT_FILTER_DST
+	if r.tableIndex != 0 {
+		routeFilterFlags |= netlink.RT_FILTER_TABLE
+	}
+	routes, err := nl.RouteListFiltered(r.netlinkFamily, routeFilter, routeFilterFlags)
+	if err != nil {
+		r.logCxt.WithError(err).WithField("cidr", cidr).Debug("Failed to look for conflicting routes")
+		return routeConflict{}, false
+	}
+	for _, route := range routes {
+		if r.isForeignRoute(route) {
+			return conflictForRoute(route), true
+		}
+	}
+	return routeConflict{}, false
+}
+
+// recordConflict records a conflict for one of the interface's CIDRs, logging it if it is new.
+func (r *RouteTable) recordConflict(ifaceName string, cidr ip.CIDR, conflict routeConflict) {
+	conflicts := r.ifaceNameToConflicts[ifaceName]
+	if conflicts == nil {
+		conflicts = map[ip.CIDR]routeConflict{}
+		r.ifaceNameToConflicts[ifaceName] = conflicts
+	}
+	if _, ok := conflicts[cidr]; !ok {
+		r.logCxt.WithFields(log.Fields{
+			"ifaceName":      ifaceName,
+			"cidr":           cidr,
+			"otherProtocol":  conflict.protocol,
+			"otherLinkIndex": conflict.linkIndex,
+			"otherGateway":   conflict.gw,
+		}).Warn("Another routing daemon has a route for a CIDR that Felix wants to route; " +
+			"leaving it in place since external route removal is disabled.")
+	}
+	conflicts[cidr] = conflict
+}
+
+// setIfaceConflicts replaces the interface's conflicts with those found by a full resync.
+func (r *RouteTable) setIfaceConflicts(ifaceName string, conflicts map[ip.CIDR]routeConflict) {
+	for cidr, conflict := range conflicts {
+		r.recordConflict(ifaceName, cidr, conflict)
+	}
+	for cidr := range r.ifaceNameToConflicts[ifaceName] {
+		if _, ok := conflicts[cidr]; !ok {
+			r.logCxt.WithFields(log.Fields{
+				"ifaceName": ifaceName,
+				"cidr":      cidr,
+			}).Info("Route conflict resolved.")
+			delete(r.ifaceNameToConflicts[ifaceName], cidr)
+		}
+	}
+	if len(r.ifaceNameToConflicts[ifaceName]) == 0 {
+		delete(r.ifaceNameToConflicts, ifaceName)
+	}
+}
+
+// updateConflictMetrics drops conflicts for CIDRs that we no longer want to route and updates
+// the metric.  The metric is shared by all route tables so each one adds its change in count.
+func (r *RouteTable) updateConflictMetrics() {
+	numConflicts := 0
+	for ifaceName, conflicts := range r.ifaceNameToConflicts {
+		for cidr := range conflicts {
+			if _, ok := r.ifaceNameToTargets[ifaceName][cidr]; !ok {
+				delete(conflicts, cidr)
+			}
+		}
+		if len(conflicts) == 0 {
+			delete(r.ifaceNameToConflicts, ifaceName)
+		}
+		numConflicts += len(conflicts)
+	}
+	gaugeRouteConflicts.Add(float64(numConflicts - r.numConflicts))
+	r.numConflicts = numConflicts
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routetable
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vishvananda/netlink"
+
+	"github.com/projectcalico/felix/ip"
+	"github.com/projectcalico/felix/logutils"
+	mocknetlink "github.com/projectcalico/felix/netlinkshim/mocknetlink"
+	"github.com/projectcalico/felix/timeshim/mocktime"
+)
+
+const bgpRouteProtocol = 186
+
+var _ = Describe("RouteTable conflicts with external routes", func() {
+	var (
+		dataplane *mocknetlink.MockNetlinkDataplane
+		rt        *RouteTable
+		registry  *DebugRegistry
+		bgpRoute  netlink.Route
+	)
+
+	newRouteTable := func(removeExternalRoutes bool) {
+		t := mocktime.New()
+		// Disable the grace period.
+		t.SetAutoIncrement(11 * time.Second)
+		rt = NewWithShims(
+			[]string{"^cali.*"},
+			4,
+			dataplane.NewMockNetlink,
+			false,
+			10*time.Second,
+			dataplane.AddStaticArpEntry,
+			dataplane,
+			t,
+			nil,
+			syscall.RTPROT_BOOT,
+			removeExternalRoutes,
+			0,
+			logutils.NewSummarizer("test"),
+		)
+		registry = NewDebugRegistry()
+		registry.Register("ipv4", rt)
+	}
+
+	getConflicts := func() []DebugRoute {
+		// The debug endpoint opens its own handle; the mock only allows one at a time.
+		rt.closeNetlink()
+		w := httptest.NewRecorder()
+		registry.ServeHTTP(w, httptest.NewRequest(http.MethodGet, DebugPath+"?iface=cali1", nil))
+		Expect(w.Code).To(Equal(http.StatusOK))
+		var tables []DebugTable
+		Expect(json.Unmarshal(w.Body.Bytes(), &tables)).To(Succeed())
+		return tables[0].Interfaces[0].Conflicts
+	}
+
+	BeforeEach(func() {
+		dataplane = mocknetlink.New()
+		cali1 := dataplane.AddIface(1, "cali1", true, true)
+		dst := ip.MustParseCIDROrIP("10.0.0.9/32").ToIPNet()
+		bgpRoute = netlink.Route{
+			LinkIndex: cali1.LinkAttrs.Index,
+			Dst:       &dst,
+			Type:      syscall.RTN_UNICAST,
+			Protocol:  bgpRouteProtocol,
+			Scope:     netlink.SCOPE_LINK,
+		}
+		dataplane.AddMockRoute(&bgpRoute)
+	})
+
+	Describe("with external route removal disabled", func() {
+		BeforeEach(func() {
+			newRouteTable(false)
+			rt.SetRoutes("cali1", []Target{
+				{CIDR: ip.MustParseCIDROrIP("10.0.0.1/32")},
+				{CIDR: ip.MustParseCIDROrIP("10.0.0.9/32")},
+			})
+			Expect(rt.Apply()).To(Succeed())
+		})
+
+		It("should leave the other daemon's route in place", func() {
+			Expect(dataplane.RouteKeyToRoute).To(HaveKeyWithValue(mocknetlink.KeyForRoute(&bgpRoute), bgpRoute))
+			Expect(dataplane.DeletedRouteKeys).To(BeEmpty())
+		})
+
+		It("should report the conflict", func() {
+			Expect(rt.numConflicts).To(Equal(1))
+			Expect(getConflicts()).To(Equal([]DebugRoute{{CIDR: "10.0.0.9/32", Protocol: bgpRouteProtocol}}))
+		})
+
+		It("should stop reporting the conflict once the other route goes away", func() {
+			dataplane.RemoveMockRoute(&bgpRoute)
+			rt.QueueResync()
+			Expect(rt.Apply()).To(Succeed())
+			Expect(dataplane.RouteKeyToRoute[mocknetlink.KeyForRoute(&bgpRoute)].Protocol).To(
+				Equal(syscall.RTPROT_BOOT))
+			Expect(rt.numConflicts).To(Equal(0))
+			Expect(getConflicts()).To(BeEmpty())
+		})
+
+		It("should stop reporting the conflict once we no longer want the CIDR", func() {
+			rt.RouteRemove("cali1", ip.MustParseCIDROrIP("10.0.0.9/32"))
+			Expect(rt.Apply()).To(Succeed())
+			Expect(rt.numConflicts).To(Equal(0))
+		})
+	})
+
+	Describe("with external route removal enabled", func() {
+		BeforeEach(func() {
+			newRouteTable(true)
+			rt.SetRoutes("cali1", []Target{
+				{CIDR: ip.MustParseCIDROrIP("10.0.0.9/32")},
+			})
+			Expect(rt.Apply()).To(Succeed())
+		})
+
+		It("should replace the other daemon's route without reporting a conflict", func() {
+			Expect(dataplane.RouteKeyToRoute[mocknetlink.KeyForRoute(&bgpRoute)].Protocol).To(
+				Equal(syscall.RTPROT_BOOT))
+			Expect(rt.numConflicts).To(Equal(0))
+			Expect(getConflicts()).To(BeEmpty())
+		})
+	})
+})
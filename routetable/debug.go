@@ -0,0 +1,418 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routetable
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+
+	"github.com/projectcalico/felix/ip"
+	"github.com/projectcalico/felix/netlinkshim"
+)
+
+// DebugPath is the path of the debug HTTP endpoint that serves the state of the registered route
+// tables.  For example:
+//
+//	curl http://localhost:9091/debug/route-tables?table=ipv4&iface=cali1234
+const DebugPath = "/debug/route-tables"
+
+// DebugRegistry serves, for each registered route table and each interface that it owns, the
+// routes that Felix wants, the routes that are currently in the kernel, the difference between
+// the two and the reason that any outstanding changes haven't been applied yet.
+//
+// The desired state is a snapshot taken at the end of each RouteTable.Apply() whereas the kernel
+// routes are listed when the request is served, so a route that was removed from the kernel
+// behind Felix's back shows up as missing straight away.
+type DebugRegistry struct {
+	lock   sync.Mutex
+	tables map[string]*RouteTable
+}
+
+func NewDebugRegistry() *DebugRegistry {
+	return &DebugRegistry{
+		tables: map[string]*RouteTable{},
+	}
+}
+
+// Register adds a route table to the registry under the given name and enables its debug
+// snapshots.  It must be called before the route table's first Apply().
+func (d *DebugRegistry) Register(name string, r *RouteTable) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	r.debugEnabled = true
+	d.tables[name] = r
+}
+
+// DebugRoute is a route as reported by the debug endpoint.
+type DebugRoute struct {
+	CIDR     string `json:"cidr"`
+	GW       string `json:"gw,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Protocol int    `json:"protocol,omitempty"`
+}
+
+// DebugInterface is the state of a single interface's routes.
+type DebugInterface struct {
+	Name string `json:"name"`
+	// Desired are the routes that Felix wants, including the pending changes.
+	Desired []DebugRoute `json:"desired"`
+	// Kernel are the routes that are currently programmed.  Nil if listing them failed, in which
+	// case KernelError says why.
+	Kernel      []DebugRoute `json:"kernel"`
+	KernelError string       `json:"kernelError,omitempty"`
+	// Missing are desired routes that aren't in the kernel (or have the wrong gateway) and
+	// Unexpected are kernel routes that Felix would remove.
+	Missing    []DebugRoute `json:"missing"`
+	Unexpected []DebugRoute `json:"unexpected"`
+	// Conflicts are other routing daemons' routes for desired CIDRs, which Felix leaves in place
+	// because external route removal is disabled.
+	Conflicts []DebugRoute `json:"conflicts"`
+	// PendingAdds and PendingDeletes are updates that Felix has received but not yet tried to
+	// program.
+	PendingAdds    []DebugRoute `json:"pendingAdds"`
+	PendingDeletes []string     `json:"pendingDeletes"`
+	// Dirty is true if Felix still needs to sync the interface; PendingReason says why it
+	// hasn't done so.
+	Dirty         bool      `json:"dirty"`
+	PendingReason string    `json:"pendingReason,omitempty"`
+	LastSyncTime  time.Time `json:"lastSyncTime,omitempty"`
+	LastErrorTime time.Time `json:"lastErrorTime,omitempty"`
+}
+
+// DebugTable is the state of a single route table.
+type DebugTable struct {
+	Name       string           `json:"name"`
+	IPVersion  uint8            `json:"ipVersion"`
+	TableIndex int              `json:"tableIndex"`
+	LastApply  time.Time        `json:"lastApply"`
+	Interfaces []DebugInterface `json:"interfaces"`
+}
+
+// ServeHTTP serves the state of the registered route tables as JSON.  The output can be limited to
+// a single table or interface with the ?table= and ?iface= query parameters.
+func (d *DebugRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	tableFilter := req.URL.Query().Get("table")
+	ifaceFilter := req.URL.Query().Get("iface")
+
+	d.lock.Lock()
+	var names []string
+	for name := range d.tables {
+		if tableFilter == "" || tableFilter == name {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	tables := make([]*RouteTable, len(names))
+	for i, name := range names {
+		tables[i] = d.tables[name]
+	}
+	d.lock.Unlock()
+
+	output := []DebugTable{}
+	for i, r := range tables {
+		output = append(output, r.debugState(names[i], ifaceFilter))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(output); err != nil {
+		log.WithError(err).Warn("Failed to write route table debug JSON.")
+	}
+}
+
+// debugIfaceState is the part of an interface's state that is captured at the end of Apply().
+type debugIfaceState struct {
+	desired        map[ip.CIDR]Target
+	pendingAdds    []Target
+	pendingDeletes []ip.CIDR
+	conflicts      map[ip.CIDR]routeConflict
+	dirty          bool
+	lastErr        error
+	lastErrTime    time.Time
+	lastSyncTime   time.Time
+}
+
+// recordSyncResult records the outcome of an attempt to sync an interface for the debug endpoint.
+func (r *RouteTable) recordSyncResult(ifaceName string, err error) {
+	if !r.debugEnabled {
+		return
+	}
+	if err == nil {
+		delete(r.ifaceNameToSyncErr, ifaceName)
+		r.ifaceNameToLastSync[ifaceName] = r.time.Now()
+		return
+	}
+	r.ifaceNameToSyncErr[ifaceName] = syncErr{err: err, time: r.time.Now()}
+}
+
+type syncErr struct {
+	err  error
+	time time.Time
+}
+
+// updateDebugSnapshot copies the desired state of each interface for the debug endpoint, which
+// runs on a different goroutine.
+func (r *RouteTable) updateDebugSnapshot() {
+	if !r.debugEnabled {
+		return
+	}
+	ifaceNames := map[string]bool{}
+	for ifaceName := range r.ifaceNameToTargets {
+		ifaceNames[ifaceName] = true
+	}
+	for ifaceName := range r.pendingIfaceNameToDeltaTargets {
+		ifaceNames[ifaceName] = true
+	}
+	for ifaceName := range r.ifaceNameToUpdateType {
+		ifaceNames[ifaceName] = true
+	}
+	for ifaceName := range r.ifaceNameToConflicts {
+		ifaceNames[ifaceName] = true
+	}
+	for ifaceName := range r.ifaceNameToSyncErr {
+		if !ifaceNames[ifaceName] {
+			// Interface has gone away and we no longer care about it.
+			delete(r.ifaceNameToSyncErr, ifaceName)
+		}
+	}
+	for ifaceName := range r.ifaceNameToLastSync {
+		if !ifaceNames[ifaceName] {
+			delete(r.ifaceNameToLastSync, ifaceName)
+		}
+	}
+
+	snapshot := map[string]*debugIfaceState{}
+	for ifaceName := range ifaceNames {
+		state := &debugIfaceState{
+			desired:      map[ip.CIDR]Target{},
+			conflicts:    map[ip.CIDR]routeConflict{},
+			lastSyncTime: r.ifaceNameToLastSync[ifaceName],
+		}
+		for cidr, conflict := range r.ifaceNameToConflicts[ifaceName] {
+			state.conflicts[cidr] = conflict
+		}
+		for cidr, target := range r.ifaceNameToTargets[ifaceName] {
+			state.desired[cidr] = target
+		}
+		for cidr, target := range r.pendingIfaceNameToDeltaTargets[ifaceName] {
+			if target == nil {
+				delete(state.desired, cidr)
+				state.pendingDeletes = append(state.pendingDeletes, cidr)
+			} else {
+				state.desired[cidr] = *target
+				state.pendingAdds = append(state.pendingAdds, *target)
+			}
+		}
+		_, state.dirty = r.ifaceNameToUpdateType[ifaceName]
+		if e, ok := r.ifaceNameToSyncErr[ifaceName]; ok {
+			state.lastErr = e.err
+			state.lastErrTime = e.time
+		}
+		snapshot[ifaceName] = state
+	}
+
+	r.debugLock.Lock()
+	defer r.debugLock.Unlock()
+	r.debugSnapshot = snapshot
+	r.debugLastApply = r.time.Now()
+}
+
+// debugState combines the snapshot from the last Apply() with the routes that are currently in
+// the kernel.  It uses its own netlink handle since it is called from the HTTP server's goroutine.
+func (r *RouteTable) debugState(name, ifaceFilter string) DebugTable {
+	r.debugLock.Lock()
+	snapshot := r.debugSnapshot
+	lastApply := r.debugLastApply
+	r.debugLock.Unlock()
+
+	output := DebugTable{
+		Name:       name,
+		IPVersion:  r.ipVersion,
+		TableIndex: r.tableIndex,
+		LastApply:  lastApply,
+		Interfaces: []DebugInterface{},
+	}
+
+	var ifaceNames []string
+	for ifaceName := range snapshot {
+		if ifaceFilter == "" || ifaceFilter == ifaceName {
+			ifaceNames = append(ifaceNames, ifaceName)
+		}
+	}
+	if len(ifaceNames) == 0 {
+		return output
+	}
+	sort.Strings(ifaceNames)
+
+	nl, nlErr := r.newNetlinkHandle()
+	if nlErr == nil {
+		defer nl.Delete()
+	}
+
+	for _, ifaceName := range ifaceNames {
+		state := snapshot[ifaceName]
+		iface := DebugInterface{
+			Name:           ifaceName,
+			Desired:        []DebugRoute{},
+			Missing:        []DebugRoute{},
+			Unexpected:     []DebugRoute{},
+			Conflicts:      []DebugRoute{},
+			PendingAdds:    []DebugRoute{},
+			PendingDeletes: []string{},
+			Dirty:          state.dirty,
+			PendingReason:  debugPendingReason(state),
+			LastSyncTime:   state.lastSyncTime,
+			LastErrorTime:  state.lastErrTime,
+		}
+		for _, target := range state.desired {
+			iface.Desired = append(iface.Desired, debugRouteForTarget(target))
+		}
+		for _, target := range state.pendingAdds {
+			iface.PendingAdds = append(iface.PendingAdds, debugRouteForTarget(target))
+		}
+		for _, cidr := range state.pendingDeletes {
+			iface.PendingDeletes = append(iface.PendingDeletes, cidr.String())
+		}
+		for cidr, conflict := range state.conflicts {
+			debugRoute := DebugRoute{
+				CIDR:     cidr.String(),
+				Protocol: conflict.protocol,
+			}
+			if conflict.gw != nil {
+				debugRoute.GW = conflict.gw.String()
+			}
+			iface.Conflicts = append(iface.Conflicts, debugRoute)
+		}
+
+		var kernelRoutes []netlink.Route
+		var err error = nlErr
+		if err == nil {
+			kernelRoutes, err = r.debugListKernelRoutes(nl, ifaceName)
+		}
+		if err != nil {
+			iface.KernelError = err.Error()
+		} else {
+			iface.Kernel = []DebugRoute{}
+			kernelCIDRs := map[ip.CIDR]netlink.Route{}
+			for _, route := range kernelRoutes {
+				var dest ip.CIDR
+				if route.Dst != nil {
+					dest = ip.CIDRFromIPNet(route.Dst)
+				}
+				kernelCIDRs[dest] = route
+				debugRoute := debugRouteForKernelRoute(dest, route)
+				iface.Kernel = append(iface.Kernel, debugRoute)
+				if _, ok := state.desired[dest]; ok {
+					continue
+				}
+				if r.ipVersion == 6 && dest == ipV6LinkLocalCIDR {
+					continue
+				}
+				if r.isForeignRoute(route) {
+					// Not ours, Felix will leave it alone.
+					continue
+				}
+				iface.Unexpected = append(iface.Unexpected, debugRoute)
+			}
+			for cidr, target := range state.desired {
+				route, ok := kernelCIDRs[cidr]
+				if !ok || (target.GW != nil && !target.GW.AsNetIP().Equal(route.Gw)) {
+					iface.Missing = append(iface.Missing, debugRouteForTarget(target))
+				}
+			}
+		}
+
+		sortDebugRoutes(iface.Desired)
+		sortDebugRoutes(iface.Kernel)
+		sortDebugRoutes(iface.Missing)
+		sortDebugRoutes(iface.Unexpected)
+		sortDebugRoutes(iface.Conflicts)
+		sortDebugRoutes(iface.PendingAdds)
+		sort.Strings(iface.PendingDeletes)
+		output.Interfaces = append(output.Interfaces, iface)
+	}
+	return output
+}
+
+func (r *RouteTable) debugListKernelRoutes(nl netlinkshim.Interface, ifaceName string) ([]netlink.Route, error) {
+	// Same filter as fullResyncRoutesForLink().
+	routeFilter := &netlink.Route{
+		Table: r.tableIndex,
+	}
+	routeFilterFlags := netlink.RT_FILTER_OIF
+	if r.tableIndex != 0 {
+		routeFilterFlags |= netlink.RT_FILTER_TABLE
+	}
+	if ifaceName != InterfaceNone {
+		link, err := nl.LinkByName(ifaceName)
+		if err != nil {
+			return nil, err
+		}
+		routeFilter.LinkIndex = link.Attrs().Index
+	}
+	return nl.RouteListFiltered(r.netlinkFamily, routeFilter, routeFilterFlags)
+}
+
+func debugPendingReason(state *debugIfaceState) string {
+	switch state.lastErr {
+	case nil:
+		if state.dirty || len(state.pendingAdds) > 0 || len(state.pendingDeletes) > 0 {
+			return "waiting for next apply"
+		}
+		return ""
+	case IfaceNotPresent:
+		return "interface not present, will retry when it appears"
+	case IfaceDown:
+		return "interface down, will retry when it comes up"
+	case IfaceGrace:
+		return "interface in cleanup grace period, will retry after it expires"
+	default:
+		return state.lastErr.Error() + ", will retry with a full resync"
+	}
+}
+
+func debugRouteForTarget(target Target) DebugRoute {
+	route := DebugRoute{
+		CIDR: target.CIDR.String(),
+		Type: string(target.Type),
+	}
+	if target.GW != nil {
+		route.GW = target.GW.String()
+	}
+	return route
+}
+
+func debugRouteForKernelRoute(dest ip.CIDR, route netlink.Route) DebugRoute {
+	debugRoute := DebugRoute{
+		Protocol: route.Protocol,
+	}
+	if dest != nil {
+		debugRoute.CIDR = dest.String()
+	}
+	if route.Gw != nil {
+		debugRoute.GW = route.Gw.String()
+	}
+	return debugRoute
+}
+
+func sortDebugRoutes(routes []DebugRoute) {
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].CIDR < routes[j].CIDR
+	})
+}
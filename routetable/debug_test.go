@@ -0,0 +1,152 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routetable
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"syscall"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/vishvananda/netlink"
+
+	"github.com/projectcalico/felix/ip"
+	"github.com/projectcalico/felix/logutils"
+	mocknetlink "github.com/projectcalico/felix/netlinkshim/mocknetlink"
+	"github.com/projectcalico/felix/timeshim/mocktime"
+)
+
+var _ = Describe("RouteTable debug registry", func() {
+	var (
+		dataplane *mocknetlink.MockNetlinkDataplane
+		rt        *RouteTable
+		registry  *DebugRegistry
+	)
+
+	BeforeEach(func() {
+		dataplane = mocknetlink.New()
+		t := mocktime.New()
+		// Disable the grace period.
+		t.SetAutoIncrement(11 * time.Second)
+		rt = NewWithShims(
+			[]string{"^cali.*"},
+			4,
+			dataplane.NewMockNetlink,
+			false,
+			10*time.Second,
+			dataplane.AddStaticArpEntry,
+			dataplane,
+			t,
+			nil,
+			syscall.RTPROT_BOOT,
+			true,
+			0,
+			logutils.NewSummarizer("test"),
+		)
+		registry = NewDebugRegistry()
+		registry.Register("ipv4", rt)
+
+		dataplane.AddIface(1, "cali1", true, true)
+		rt.SetRoutes("cali1", []Target{
+			{CIDR: ip.MustParseCIDROrIP("10.0.0.1/32")},
+			{CIDR: ip.MustParseCIDROrIP("10.0.0.2/32")},
+		})
+		rt.SetRoutes("cali2", []Target{
+			{CIDR: ip.MustParseCIDROrIP("10.0.0.3/32")},
+		})
+		Expect(rt.Apply()).To(Succeed())
+	})
+
+	getState := func(query string) []DebugTable {
+		// The debug endpoint opens its own handle; the mock only allows one at a time.
+		rt.closeNetlink()
+		w := httptest.NewRecorder()
+		registry.ServeHTTP(w, httptest.NewRequest(http.MethodGet, DebugPath+query, nil))
+		Expect(w.Code).To(Equal(http.StatusOK))
+		var tables []DebugTable
+		Expect(json.Unmarshal(w.Body.Bytes(), &tables)).To(Succeed())
+		return tables
+	}
+
+	It("should report an in-sync interface", func() {
+		tables := getState("?iface=cali1")
+		Expect(tables).To(HaveLen(1))
+		Expect(tables[0].Name).To(Equal("ipv4"))
+		Expect(tables[0].Interfaces).To(HaveLen(1))
+		iface := tables[0].Interfaces[0]
+		Expect(iface.Name).To(Equal("cali1"))
+		Expect(iface.Desired).To(Equal([]DebugRoute{{CIDR: "10.0.0.1/32"}, {CIDR: "10.0.0.2/32"}}))
+		Expect(iface.Kernel).To(Equal([]DebugRoute{
+			{CIDR: "10.0.0.1/32", Protocol: syscall.RTPROT_BOOT},
+			{CIDR: "10.0.0.2/32", Protocol: syscall.RTPROT_BOOT},
+		}))
+		Expect(iface.Missing).To(BeEmpty())
+		Expect(iface.Unexpected).To(BeEmpty())
+		Expect(iface.Dirty).To(BeFalse())
+		Expect(iface.PendingReason).To(BeEmpty())
+	})
+
+	It("should report routes that were changed behind our back", func() {
+		for key, route := range dataplane.RouteKeyToRoute {
+			if route.Dst.String() == "10.0.0.2/32" {
+				delete(dataplane.RouteKeyToRoute, key)
+			}
+		}
+		externalDst := ip.MustParseCIDROrIP("10.0.0.9/32").ToIPNet()
+		dataplane.AddMockRoute(&netlink.Route{
+			LinkIndex: 1,
+			Dst:       &externalDst,
+			Protocol:  syscall.RTPROT_BOOT,
+		})
+
+		iface := getState("?iface=cali1")[0].Interfaces[0]
+		Expect(iface.Missing).To(Equal([]DebugRoute{{CIDR: "10.0.0.2/32"}}))
+		Expect(iface.Unexpected).To(Equal([]DebugRoute{{CIDR: "10.0.0.9/32", Protocol: syscall.RTPROT_BOOT}}))
+	})
+
+	It("should report why routes for a missing interface aren't programmed", func() {
+		iface := getState("?iface=cali2")[0].Interfaces[0]
+		Expect(iface.Desired).To(Equal([]DebugRoute{{CIDR: "10.0.0.3/32"}}))
+		Expect(iface.Kernel).To(BeNil())
+		Expect(iface.KernelError).NotTo(BeEmpty())
+		Expect(iface.PendingReason).To(Equal("interface not present, will retry when it appears"))
+	})
+
+	It("should report pending updates", func() {
+		rt.RouteRemove("cali1", ip.MustParseCIDROrIP("10.0.0.1/32"))
+		rt.RouteUpdate("cali1", Target{CIDR: ip.MustParseCIDROrIP("10.0.0.4/32")})
+		// Updates are only captured by Apply(); force a failure so that they stay pending.
+		dataplane.FailuresToSimulate = mocknetlink.FailNextNewNetlink
+		rt.closeNetlink()
+		rt.QueueResync()
+		Expect(rt.Apply()).To(HaveOccurred())
+
+		iface := getState("?iface=cali1")[0].Interfaces[0]
+		Expect(iface.PendingAdds).To(Equal([]DebugRoute{{CIDR: "10.0.0.4/32"}}))
+		Expect(iface.PendingDeletes).To(Equal([]string{"10.0.0.1/32"}))
+		Expect(iface.Desired).To(Equal([]DebugRoute{{CIDR: "10.0.0.2/32"}, {CIDR: "10.0.0.4/32"}}))
+		Expect(iface.Missing).To(Equal([]DebugRoute{{CIDR: "10.0.0.4/32"}}))
+		Expect(iface.Unexpected).To(Equal([]DebugRoute{{CIDR: "10.0.0.1/32", Protocol: syscall.RTPROT_BOOT}}))
+		Expect(iface.PendingReason).To(Equal("waiting for next apply"))
+	})
+
+	It("should filter by table", func() {
+		Expect(getState("?table=ipv6")).To(BeEmpty())
+		Expect(getState("?table=ipv4")[0].Interfaces).To(HaveLen(2))
+	})
+})
@@ -0,0 +1,100 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routetable
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+
+	"github.com/vishvananda/netlink"
+
+	"github.com/projectcalico/felix/ip"
+	"github.com/projectcalico/felix/netlinkshim"
+)
+
+// EnableDryRun stops the RouteTable from modifying the dataplane.  Each change that it would
+// have made (route additions and deletions, static ARP entries and conntrack cleanups) is passed
+// to report instead, as an operation name and a description of its target.  Routes are still
+// read from the kernel, so the reports are the changes needed to bring the kernel into line with
+// the desired state.
+func (r *RouteTable) EnableDryRun(report func(op, target string)) {
+	newNetlinkHandle := r.newNetlinkHandle
+	r.newNetlinkHandle = func() (netlinkshim.Interface, error) {
+		nl, err := newNetlinkHandle()
+		if err != nil {
+			return nil, err
+		}
+		return &dryRunNetlink{Interface: nl, report: report}, nil
+	}
+	r.addStaticARPEntry = func(cidr ip.CIDR, destMAC net.HardwareAddr, ifaceName string) error {
+		report("arp-add", fmt.Sprintf("%s lladdr %s dev %s", cidr.Addr(), destMAC, ifaceName))
+		return nil
+	}
+	r.conntrack = dryRunConntrack{report: report}
+}
+
+// dryRunNetlink passes through reads to the real netlink handle but reports route updates
+// instead of making them.
+type dryRunNetlink struct {
+	netlinkshim.Interface
+	report func(op, target string)
+}
+
+func (d *dryRunNetlink) RouteAdd(route *netlink.Route) error {
+	d.report("route-add", describeRoute(route))
+	return nil
+}
+
+func (d *dryRunNetlink) RouteDel(route *netlink.Route) error {
+	d.report("route-del", describeRoute(route))
+	return nil
+}
+
+// describeRoute renders the route in the style of "ip route".  The interface is given by index
+// since the caller only has the route.
+func describeRoute(route *netlink.Route) string {
+	desc := "default"
+	if route.Dst != nil {
+		desc = route.Dst.String()
+	}
+	if route.Type != 0 && route.Type != syscall.RTN_UNICAST {
+		desc = fmt.Sprintf("type %d %s", route.Type, desc)
+	}
+	if route.Gw != nil {
+		desc += " via " + route.Gw.String()
+	}
+	if route.LinkIndex != 0 {
+		desc += fmt.Sprintf(" dev-index %d", route.LinkIndex)
+	}
+	if route.Src != nil {
+		desc += " src " + route.Src.String()
+	}
+	if route.Table != 0 {
+		desc += fmt.Sprintf(" table %d", route.Table)
+	}
+	if route.Protocol != 0 {
+		desc += fmt.Sprintf(" proto %d", route.Protocol)
+	}
+	return desc
+}
+
+type dryRunConntrack struct {
+	report func(op, target string)
+}
+
+func (d dryRunConntrack) RemoveConntrackFlows(ipVersion uint8, ipAddr net.IP) {
+	d.report("conntrack-flush", ipAddr.String())
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package routetable
+
+import (
+	"sync"
+
+	"github.com/vishvananda/netlink"
+
+	"github.com/projectcalico/felix/netlinkshim"
+)
+
+// minRoutesPerWorker is the smallest number of route updates that is worth handing to an extra
+// worker; below that, the cost of the goroutine and its netlink socket outweighs the gain.
+const minRoutesPerWorker = 16
+
+// SetMaxParallelUpdates sets the number of netlink sockets that the RouteTable may use in
+// parallel to add or delete the routes of one interface.  With one netlink call per route, the
+// round trips dominate the time to apply tens of thousands of routes; spreading them over several
+// sockets lets the kernel work on them concurrently.  1 (the default) makes the updates one at a
+// time.
+func (r *RouteTable) SetMaxParallelUpdates(n int) {
+	if n < 1 {
+		n = 1
+	}
+	if n == r.maxParallelUpdates {
+		return
+	}
+	r.logCxt.WithField("maxParallelUpdates", n).Info("Updated maximum number of parallel route updates.")
+	r.maxParallelUpdates = n
+	r.closeWorkerNetlink()
+}
+
+// updateRoutes calls update (RouteAdd or RouteDel) for each of the routes, in parallel if
+// enabled, and returns the error for each route, in the same order.  The first worker uses nl;
+// the others use their own netlink handles, since a handle can't be shared between goroutines.
+func (r *RouteTable) updateRoutes(
+	nl netlinkshim.Interface,
+	routes []netlink.Route,
+	update func(nl netlinkshim.Interface, route *netlink.Route) error,
+) []error {
+	errs := make([]error, len(routes))
+	handles := []netlinkshim.Interface{nl}
+	numWorkers := r.maxParallelUpdates
+	if max := len(routes) / minRoutesPerWorker; max < numWorkers {
+		numWorkers = max
+	}
+	for i := 1; i < numWorkers; i++ {
+		h, err := r.getWorkerNetlink(i - 1)
+		if err != nil {
+			// Make do with the workers that we have.
+			break
+		}
+		handles = append(handles, h)
+	}
+
+	if len(handles) == 1 {
+		for i := range routes {
+			errs[i] = update(nl, &routes[i])
+		}
+		return errs
+	}
+
+	var wg sync.WaitGroup
+	for w, h := range handles {
+		wg.Add(1)
+		go func(w int, h netlinkshim.Interface) {
+			defer wg.Done()
+			// Each worker writes to distinct elements of errs so no locking is needed.
+			for i := w; i < len(routes); i += len(handles) {
+				errs[i] = update(h, &routes[i])
+			}
+		}(w, h)
+	}
+	wg.Wait()
+	return errs
+}
+
+// getWorkerNetlink returns the netlink handle of the given extra worker, connecting if needed.
+func (r *RouteTable) getWorkerNetlink(i int) (netlinkshim.Interface, error) {
+	for len(r.workerNetlinkHandles) <= i {
+		r.workerNetlinkHandles = append(r.workerNetlinkHandles, nil)
+	}
+	if r.workerNetlinkHandles[i] != nil {
+		return r.workerNetlinkHandles[i], nil
+	}
+	nlHandle, err := r.newNetlinkHandle()
+	if err != nil {
+		r.logCxt.WithError(err).Warn("Failed to connect to netlink for parallel route updates")
+		return nil, err
+	}
+	if err := nlHandle.SetSocketTimeout(r.netlinkTimeout); err != nil {
+		r.logCxt.WithError(err).Warn("Failed to set netlink timeout for parallel route updates")
+		nlHandle.Delete()
+		return nil, err
+	}
+	r.workerNetlinkHandles[i] = nlHandle
+	return nlHandle, nil
+}
+
+func (r *RouteTable) closeWorkerNetlink() {
+	for _, h := range r.workerNetlinkHandles {
+		if h != nil {
+			h.Delete()
+		}
+	}
+	r.workerNetlinkHandles = nil
+}
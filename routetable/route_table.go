@@ -21,6 +21,7 @@ import (
 	"reflect"
 	"regexp"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -63,10 +64,14 @@ var (
 		Name: "felix_route_table_per_iface_sync_seconds",
 		Help: "Time taken to sync each interface",
 	})
+	applyTime = cprometheus.NewSummary(prometheus.SummaryOpts{
+		Name: "felix_route_table_apply_seconds",
+		Help: "Time taken to apply the pending updates of a route table, including any resync.",
+	})
 )
 
 func init() {
-	prometheus.MustRegister(listIfaceTime, perIfaceSyncTime)
+	prometheus.MustRegister(listIfaceTime, perIfaceSyncTime, applyTime)
 }
 
 const (
@@ -79,6 +84,10 @@ type TargetType string
 const (
 	TargetTypeVXLAN   TargetType = "vxlan"
 	TargetTypeNoEncap TargetType = "noencap"
+	TargetTypeOnLink  TargetType = "onlink"
+	// TargetTypeGateway is a route via a gateway that is reachable through the interface's
+	// existing routes, such as the operator-defined static routes.
+	TargetTypeGateway TargetType = "gateway"
 
 	// The following target types should be used with InterfaceNone.
 	TargetTypeBlackhole TargetType = "blackhole"
@@ -106,6 +115,9 @@ type Target struct {
 	CIDR    ip.CIDR
 	GW      ip.Addr
 	DestMAC net.HardwareAddr
+	// MTU, if non-zero, is set on the route, overriding the MTU of the interface for traffic to
+	// the CIDR.
+	MTU int
 }
 
 func (t Target) Equal(t2 Target) bool {
@@ -133,6 +145,8 @@ func (t Target) RouteScope() netlink.Scope {
 		return netlink.SCOPE_UNIVERSE
 	case TargetTypeProhibit:
 		return netlink.SCOPE_UNIVERSE
+	case TargetTypeGateway:
+		return netlink.SCOPE_UNIVERSE
 	default:
 		return netlink.SCOPE_LINK
 	}
@@ -156,6 +170,10 @@ type RouteTable struct {
 	numConsistentNetlinkFailures int
 	// Current netlink handle, or nil if we need to reconnect.
 	cachedNetlinkHandle netlinkshim.Interface
+	// maxParallelUpdates is the number of netlink handles that we use in parallel to update
+	// routes; workerNetlinkHandles are the handles that we use in addition to the main one.
+	maxParallelUpdates   int
+	workerNetlinkHandles []netlinkshim.Interface
 
 	// Interface update tracking.
 	reSync                bool
@@ -179,6 +197,10 @@ type RouteTable struct {
 	deviceRouteProtocol  int
 	removeExternalRoutes bool
 
+	// ifaceNameToConflicts tracks other daemons' routes for our CIDRs, see routeConflict.
+	ifaceNameToConflicts map[string]map[ip.CIDR]routeConflict
+	numConflicts         int
+
 	// The route table index. A value of 0 defaults to the main table.
 	tableIndex int
 
@@ -189,6 +211,15 @@ type RouteTable struct {
 	time              timeshim.Interface
 
 	opReporter logutils.OpRecorder
+
+	// Debug state, only maintained once the route table has been registered with a DebugRegistry.
+	debugEnabled        bool
+	ifaceNameToSyncErr  map[string]syncErr
+	ifaceNameToLastSync map[string]time.Time
+	// debugLock protects the snapshot, which is read by the debug endpoint's goroutine.
+	debugLock      sync.Mutex
+	debugSnapshot  map[string]*debugIfaceState
+	debugLastApply time.Time
 }
 
 func New(
@@ -274,6 +305,7 @@ func NewWithShims(
 		pendingConntrackCleanups:       map[ip.Addr]chan struct{}{},
 		newNetlinkHandle:               newNetlinkHandle,
 		netlinkTimeout:                 netlinkTimeout,
+		maxParallelUpdates:             1,
 		addStaticARPEntry:              addStaticARPEntry,
 		conntrack:                      conntrack,
 		time:                           timeShim,
@@ -281,8 +313,11 @@ func NewWithShims(
 		deviceRouteSourceAddress:       deviceRouteSourceAddress,
 		deviceRouteProtocol:            deviceRouteProtocol,
 		removeExternalRoutes:           removeExternalRoutes,
+		ifaceNameToConflicts:           map[string]map[ip.CIDR]routeConflict{},
 		tableIndex:                     tableIndex,
 		opReporter:                     opReporter,
+		ifaceNameToSyncErr:             map[string]syncErr{},
+		ifaceNameToLastSync:            map[string]time.Time{},
 	}
 }
 
@@ -403,6 +438,11 @@ func (r *RouteTable) SetL2Routes(ifaceName string, targets []L2Target) {
 	r.markIfaceForUpdate(ifaceName, false)
 }
 
+// IPVersion returns the IP version of the routes in this table.
+func (r *RouteTable) IPVersion() uint8 {
+	return r.ipVersion
+}
+
 func (r *RouteTable) QueueResync() {
 	r.logCxt.Debug("Queueing a resync of routing table.")
 	r.reSync = true
@@ -441,6 +481,7 @@ func (r *RouteTable) getNetlink() (netlinkshim.Interface, error) {
 }
 
 func (r *RouteTable) closeNetlink() {
+	r.closeWorkerNetlink()
 	if r.cachedNetlinkHandle == nil {
 		return
 	}
@@ -449,6 +490,12 @@ func (r *RouteTable) closeNetlink() {
 }
 
 func (r *RouteTable) Apply() error {
+	defer r.updateDebugSnapshot()
+	startTime := r.time.Now()
+	defer func() {
+		applyTime.Observe(r.time.Since(startTime).Seconds())
+	}()
+
 	if r.reSync {
 		r.opReporter.RecordOperation(fmt.Sprint("resync-routes-v", r.ipVersion))
 
@@ -529,6 +576,7 @@ func (r *RouteTable) Apply() error {
 			}
 
 			// Handle errors from syncing either L2 or L3 routes.
+			r.recordSyncResult(ifaceName, err)
 			switch err {
 			case nil:
 				logCxt.Debug("Synchronised routes on interface")
@@ -560,6 +608,7 @@ func (r *RouteTable) Apply() error {
 	}
 
 	r.cleanUpPendingConntrackDeletions()
+	r.updateConflictMetrics()
 
 	// Don't return a failure if there are only interfaces in the cleanup grace period.
 	// They'll be retried on the next invocation (the route refresh timer), and we mustn't
@@ -648,28 +697,48 @@ func (r *RouteTable) syncRoutesForLink(ifaceName string, fullSync bool, firstTry
 		routesToDelete = append(routesToDelete, r.createL3Route(linkAttrs, target))
 	}
 
-	// Delete the combined set of routes.
-	for _, route := range routesToDelete {
-		if err := nl.RouteDel(&route); err != nil {
+	// Delete the combined set of routes.  The deletions must finish before we add any routes,
+	// since a changed route is deleted and then re-added.
+	delErrs := r.updateRoutes(nl, routesToDelete, func(nl netlinkshim.Interface, route *netlink.Route) error {
+		return nl.RouteDel(route)
+	})
+	for _, err := range delErrs {
+		if err != nil {
 			logCxt.WithError(err).Warn("Failed to delete route")
 			updatesFailed = true
+		} else {
+			r.opReporter.RecordCount(logutils.CountRoutesDeleted, 1)
 		}
 	}
 
 	// Now add target routes.
-	for _, target := range targetsToCreate {
-		route := r.createL3Route(linkAttrs, target)
+	routesToCreate := make([]netlink.Route, len(targetsToCreate))
+	for i, target := range targetsToCreate {
+		routesToCreate[i] = r.createL3Route(linkAttrs, target)
 
 		// In case this IP is being re-used, wait for any previous conntrack entry
 		// to be cleaned up.  (No-op if there are no pending deletes.)
 		r.waitForPendingConntrackDeletion(target.CIDR.Addr())
-		if err := nl.RouteAdd(&route); err != nil {
-			if firstTry {
-				logCxt.WithError(err).Debug("Failed to add route on first attempt, retrying...")
+	}
+	addErrs := r.updateRoutes(nl, routesToCreate, func(nl netlinkshim.Interface, route *netlink.Route) error {
+		return nl.RouteAdd(route)
+	})
+	for i, target := range targetsToCreate {
+		if err := addErrs[i]; err != nil {
+			if conflict, ok := r.findConflictingRoute(nl, target.CIDR); ok {
+				// Another routing daemon owns the CIDR; report that rather than fighting over it.
+				// The next full resync will try again.
+				r.recordConflict(ifaceName, target.CIDR, conflict)
 			} else {
-				logCxt.WithError(err).Warn("Failed to add route")
+				if firstTry {
+					logCxt.WithError(err).Debug("Failed to add route on first attempt, retrying...")
+				} else {
+					logCxt.WithError(err).Warn("Failed to add route")
+				}
+				updatesFailed = true
 			}
-			updatesFailed = true
+		} else {
+			r.opReporter.RecordCount(logutils.CountRoutesAdded, 1)
 		}
 		if r.ipVersion == 4 && target.DestMAC != nil {
 			// TODO(smc) clean up/sync old ARP entries
@@ -758,7 +827,11 @@ func (r *RouteTable) createL3Route(linkAttrs *netlink.LinkAttrs, target Target)
 		route.Gw = target.GW.AsNetIP()
 	}
 
-	if target.Type == TargetTypeVXLAN || target.Type == TargetTypeNoEncap {
+	if target.MTU != 0 {
+		route.MTU = target.MTU
+	}
+
+	if target.Type == TargetTypeVXLAN || target.Type == TargetTypeNoEncap || target.Type == TargetTypeOnLink {
 		route.Scope = netlink.SCOPE_UNIVERSE
 		route.SetFlag(syscall.RTNH_F_ONLINK)
 	}
@@ -826,6 +899,7 @@ func (r *RouteTable) fullResyncRoutesForLink(logCxt *log.Entry, ifaceName string
 		r.pendingIfaceNameToDeltaTargets[ifaceName] = pendingDeltaTargets
 	}
 	alreadyCorrectCIDRs := set.New()
+	conflicts := map[ip.CIDR]routeConflict{}
 	leaveDirty := false
 	for _, route := range programmedRoutes {
 		logCxt.Debugf("Processing route: %v %v %v", route.Table, route.LinkIndex, route.Dst)
@@ -835,8 +909,11 @@ func (r *RouteTable) fullResyncRoutesForLink(logCxt *log.Entry, ifaceName string
 		}
 		logCxt := logCxt.WithField("dest", dest)
 		// Check if we should remove routes not added by us
-		if !r.removeExternalRoutes && route.Protocol != r.deviceRouteProtocol {
+		if r.isForeignRoute(route) {
 			logCxt.Debug("Syncing routes: not removing route as it is not marked as Felix route")
+			if _, ok := expectedTargets[dest]; ok {
+				conflicts[dest] = conflictForRoute(route)
+			}
 			continue
 		}
 
@@ -861,6 +938,9 @@ func (r *RouteTable) fullResyncRoutesForLink(logCxt *log.Entry, ifaceName string
 				(route.Gw != nil && expectedTarget.GW != nil && !route.Gw.Equal(expectedTarget.GW.AsNetIP())) {
 				routeProblems = append(routeProblems, "incorrect gateway")
 			}
+			if expectedTargetFound && expectedTarget.MTU != route.MTU {
+				routeProblems = append(routeProblems, "incorrect MTU")
+			}
 		}
 		if len(routeProblems) == 0 {
 			logCxt.Debug("Route is correct")
@@ -903,6 +983,8 @@ func (r *RouteTable) fullResyncRoutesForLink(logCxt *log.Entry, ifaceName string
 		}
 	}
 
+	r.setIfaceConflicts(ifaceName, conflicts)
+
 	if leaveDirty {
 		// Superfluous routes on a recently created interface.  We'll recheck later.
 		return routesToDelete, IfaceGrace
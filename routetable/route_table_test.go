@@ -30,6 +30,7 @@ import (
 
 	"github.com/projectcalico/felix/ifacemonitor"
 	"github.com/projectcalico/felix/ip"
+	"github.com/projectcalico/felix/netlinkshim"
 	mocknetlink "github.com/projectcalico/felix/netlinkshim/mocknetlink"
 	"github.com/projectcalico/felix/testutils"
 	"github.com/projectcalico/felix/timeshim/mocktime"
@@ -248,6 +249,29 @@ var _ = Describe("RouteTable", func() {
 			Expect(dataplane.RouteKeyToRoute[mocknetlink.KeyForRoute(&updateRoute)]).To(Equal(fixedRoute))
 
 		})
+		It("Should set the MTU of a route that has one", func() {
+			updateLink := dataplane.AddIface(5, "cali5", true, true)
+			updateRoute := netlink.Route{
+				LinkIndex: updateLink.LinkAttrs.Index,
+				Dst:       mustParseCIDR("10.0.0.5/32"),
+				Type:      syscall.RTN_UNICAST,
+				Protocol:  FelixRouteProtocol,
+				Scope:     netlink.SCOPE_LINK,
+				MTU:       1500,
+			}
+			dataplane.AddMockRoute(&updateRoute)
+			rt.SetRoutes(updateLink.LinkAttrs.Name, []Target{
+				{CIDR: ip.MustParseCIDROrIP("10.0.0.5"), MTU: 1400},
+			})
+
+			fixedRoute := updateRoute
+			fixedRoute.MTU = 1400
+
+			err := rt.Apply()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(dataplane.UpdatedRouteKeys).To(HaveKey(mocknetlink.KeyForRoute(&updateRoute)))
+			Expect(dataplane.RouteKeyToRoute[mocknetlink.KeyForRoute(&updateRoute)]).To(Equal(fixedRoute))
+		})
 		Describe("With a device route source address set", func() {
 			deviceRouteSource := "192.168.0.1"
 			deviceRouteSourceAddress := net.ParseIP(deviceRouteSource)
@@ -409,6 +433,22 @@ var _ = Describe("RouteTable", func() {
 					Scope:     netlink.SCOPE_LINK,
 				}))
 			})
+			It("Should add gateway routes with universe scope", func() {
+				addLink := dataplane.AddIface(6, "cali6", true, true)
+				rt.SetRoutes(addLink.LinkAttrs.Name, []Target{
+					{Type: TargetTypeGateway, CIDR: ip.MustParseCIDROrIP("10.1.0.0/16"), GW: ip.FromString("10.0.0.6")},
+				})
+				err := rt.Apply()
+				Expect(err).ToNot(HaveOccurred())
+				Expect(dataplane.RouteKeyToRoute["254-6-10.1.0.0/16"]).To(Equal(netlink.Route{
+					LinkIndex: addLink.LinkAttrs.Index,
+					Dst:       mustParseCIDR("10.1.0.0/16"),
+					Gw:        net.ParseIP("10.0.0.6").To4(),
+					Type:      syscall.RTN_UNICAST,
+					Protocol:  deviceRouteProtocol,
+					Scope:     netlink.SCOPE_UNIVERSE,
+				}))
+			})
 			It("Should add multiple routes with a protocol", func() {
 				// Route that needs to be added
 				addLink := dataplane.AddIface(6, "cali6", true, true)
@@ -1315,3 +1355,180 @@ func mustParseCIDR(cidr string) *net.IPNet {
 	Expect(err).NotTo(HaveOccurred())
 	return c
 }
+
+var _ = Describe("RouteTable in dry-run mode", func() {
+	var dataplane *mocknetlink.MockNetlinkDataplane
+	var rt *RouteTable
+	var reported []string
+
+	BeforeEach(func() {
+		reported = nil
+		dataplane = mocknetlink.New()
+		t := mocktime.New()
+		t.SetAutoIncrement(11 * time.Second)
+		rt = NewWithShims(
+			[]string{"^cali.*"},
+			4,
+			dataplane.NewMockNetlink,
+			false,
+			10*time.Second,
+			dataplane.AddStaticArpEntry,
+			dataplane,
+			t,
+			nil,
+			FelixRouteProtocol,
+			true,
+			0,
+			logutils.NewSummarizer("test"),
+		)
+		rt.EnableDryRun(func(op, target string) {
+			reported = append(reported, op+" "+target)
+		})
+	})
+
+	It("should report route changes instead of making them", func() {
+		cali1 := dataplane.AddIface(4, "cali1", true, true)
+		staleRoute := netlink.Route{
+			LinkIndex: cali1.LinkAttrs.Index,
+			Dst:       mustParseCIDR("10.0.0.9/32"),
+			Type:      syscall.RTN_UNICAST,
+			Protocol:  FelixRouteProtocol,
+			Scope:     netlink.SCOPE_LINK,
+		}
+		dataplane.AddMockRoute(&staleRoute)
+		rt.SetRoutes("cali1", []Target{
+			{CIDR: ip.MustParseCIDROrIP("10.0.0.4/32"), DestMAC: mac1},
+		})
+
+		Expect(rt.Apply()).To(Succeed())
+
+		Expect(reported).To(ContainElements(
+			"route-del 10.0.0.9/32 dev-index 4 table 254 proto 3",
+			"route-add 10.0.0.4/32 dev-index 4 proto 3",
+			"arp-add 10.0.0.4 lladdr 00:11:22:33:44:51 dev cali1",
+		))
+		Expect(dataplane.AddedRouteKeys).To(BeEmpty())
+		Expect(dataplane.DeletedRouteKeys).To(BeEmpty())
+		Expect(dataplane.RouteKeyToRoute).To(HaveLen(1))
+	})
+})
+
+var _ = Describe("RouteTable with netlink fault injection", func() {
+	var dataplane *mocknetlink.MockNetlinkDataplane
+	var rt *RouteTable
+
+	BeforeEach(func() {
+		dataplane = mocknetlink.New()
+		t := mocktime.New()
+		t.SetAutoIncrement(11 * time.Second)
+		faults := netlinkshim.NewFaultInjector(0.2, 0.2, 1)
+		rt = NewWithShims(
+			[]string{"^cali.*"},
+			4,
+			faults.WrapNetlink(dataplane.NewMockNetlink),
+			false,
+			10*time.Second,
+			dataplane.AddStaticArpEntry,
+			dataplane,
+			t,
+			nil,
+			FelixRouteProtocol,
+			true,
+			0,
+			logutils.NewSummarizer("test"),
+		)
+	})
+
+	It("should program the routes after retries", func() {
+		dataplane.AddIface(4, "cali1", true, true)
+		dataplane.AddIface(5, "cali2", true, true)
+		rt.SetRoutes("cali1", []Target{
+			{CIDR: ip.MustParseCIDROrIP("10.0.0.4/32"), DestMAC: mac1},
+		})
+		rt.SetRoutes("cali2", []Target{
+			{CIDR: ip.MustParseCIDROrIP("10.0.0.5/32"), DestMAC: mac2},
+		})
+
+		var err error
+		for i := 0; i < 100; i++ {
+			err = rt.Apply()
+			if err == nil && len(dataplane.RouteKeyToRoute) == 2 {
+				break
+			}
+		}
+		Expect(err).NotTo(HaveOccurred())
+		Expect(dataplane.RouteKeyToRoute).To(HaveLen(2))
+		Expect(dataplane.NumNewNetlinkCalls).To(BeNumerically(">", 1),
+			"Expected failures to force netlink reconnections")
+	})
+})
+
+var _ = Describe("RouteTable with parallel updates", func() {
+	var dataplane *mocknetlink.MockNetlinkDataplane
+	var rt *RouteTable
+	var numHandles int
+
+	targets := func(thirdOctet, n int) []Target {
+		var ts []Target
+		for i := 0; i < n; i++ {
+			ts = append(ts, Target{
+				CIDR: ip.MustParseCIDROrIP(fmt.Sprintf("10.0.%d.%d/32", thirdOctet, i)),
+			})
+		}
+		return ts
+	}
+
+	BeforeEach(func() {
+		dataplane = mocknetlink.New()
+		numHandles = 0
+		t := mocktime.New()
+		t.SetAutoIncrement(11 * time.Second)
+		rt = NewWithShims(
+			[]string{"^cali.*"},
+			4,
+			func() (netlinkshim.Interface, error) {
+				// The mock dataplane only allows one handle at a time, but it is thread safe
+				// so share it between the workers.
+				numHandles++
+				if !dataplane.NetlinkOpen {
+					return dataplane.NewMockNetlink()
+				}
+				return dataplane, nil
+			},
+			false,
+			10*time.Second,
+			dataplane.AddStaticArpEntry,
+			dataplane,
+			t,
+			nil,
+			FelixRouteProtocol,
+			true,
+			0,
+			logutils.NewSummarizer("test"),
+		)
+		rt.SetMaxParallelUpdates(4)
+		dataplane.AddIface(4, "cali1", true, true)
+	})
+
+	It("should add and replace many routes using several handles", func() {
+		rt.SetRoutes("cali1", targets(1, 100))
+		Expect(rt.Apply()).To(Succeed())
+		Expect(dataplane.RouteKeyToRoute).To(HaveLen(100))
+		Expect(numHandles).To(Equal(4))
+
+		rt.SetRoutes("cali1", targets(2, 50))
+		Expect(rt.Apply()).To(Succeed())
+		Expect(dataplane.RouteKeyToRoute).To(HaveLen(50))
+		for _, r := range dataplane.RouteKeyToRoute {
+			Expect(r.Dst.IP.To4()[2]).To(BeEquivalentTo(2))
+		}
+		Expect(numHandles).To(Equal(4), "Expected the worker handles to be reused")
+	})
+
+	It("should use a single handle for a few routes", func() {
+		rt.SetRoutes("cali1", targets(1, 10))
+		Expect(rt.Apply()).To(Succeed())
+		Expect(dataplane.RouteKeyToRoute).To(HaveLen(10))
+		Expect(numHandles).To(Equal(1))
+	})
+})
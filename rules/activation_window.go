@@ -0,0 +1,170 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxActivationWindowDuration is the longest activation window that we support.  It bounds the
+// work that ActivationWindow.Contains does.
+const MaxActivationWindowDuration = 7 * 24 * time.Hour
+
+// ActivationWindow is a parsed policy activation window.  It has the form
+//
+//	<minute> <hour> <day-of-month> <month> <day-of-week> <duration>
+//
+// where the first five fields are as for cron, and give the start times of the window, and the
+// duration is a Go duration, such as "2h30m".  The cron fields accept "*", numbers, ranges
+// ("1-5"), steps ("*/15" or "0-30/10") and comma-separated lists of those.  Day-of-week is 0-7,
+// where both 0 and 7 are Sunday.  As for cron, if both day-of-month and day-of-week are
+// restricted, a day that matches either field matches.  Times are in the host's time zone.
+//
+// For example, "0 2 * * 6 4h" is active from 02:00 to 06:00 every Saturday.
+type ActivationWindow struct {
+	minutes, hours, daysOfMonth, months, daysOfWeek uint64
+	anyDayOfMonth, anyDayOfWeek                     bool
+	duration                                        time.Duration
+}
+
+var cronFieldRanges = [5]struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day-of-month", 1, 31},
+	{"month", 1, 12},
+	{"day-of-week", 0, 7},
+}
+
+// ParseActivationWindow parses an activation window; see ActivationWindow for the format.
+func ParseActivationWindow(s string) (*ActivationWindow, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 6 {
+		return nil, fmt.Errorf("activation window %q should have 6 fields, not %d", s, len(fields))
+	}
+	var bits [5]uint64
+	for i, r := range cronFieldRanges {
+		var err error
+		bits[i], err = parseCronField(fields[i], r.min, r.max)
+		if err != nil {
+			return nil, fmt.Errorf("activation window %q has invalid %s field: %w", s, r.name, err)
+		}
+	}
+	duration, err := time.ParseDuration(fields[5])
+	if err != nil || duration < time.Minute || duration > MaxActivationWindowDuration {
+		return nil, fmt.Errorf("activation window %q has invalid duration %q, must be between %v and %v",
+			s, fields[5], time.Minute, MaxActivationWindowDuration)
+	}
+	// Sunday can be either 0 or 7.
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+	return &ActivationWindow{
+		minutes:       bits[0],
+		hours:         bits[1],
+		daysOfMonth:   bits[2],
+		months:        bits[3],
+		daysOfWeek:    bits[4],
+		anyDayOfMonth: fields[2] == "*",
+		anyDayOfWeek:  fields[4] == "*",
+		duration:      duration,
+	}, nil
+}
+
+// parseCronField parses a single cron field into a bitmap of the values that it matches.
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			rng = part[:i]
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step in %q", part)
+			}
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			bounds := strings.SplitN(rng, "-", 2)
+			var err error
+			lo, err = strconv.Atoi(bounds[0])
+			if err != nil {
+				return 0, fmt.Errorf("invalid value in %q", part)
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				hi, err = strconv.Atoi(bounds[1])
+				if err != nil {
+					return 0, fmt.Errorf("invalid value in %q", part)
+				}
+			} else if step != 1 {
+				// As for cron, "a/n" means every n from a to the maximum.
+				hi = max
+			}
+			if lo < min || hi > max || lo > hi {
+				return 0, fmt.Errorf("%q is out of range %d-%d", part, min, max)
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// startsAt returns true if the window starts at the given time, which should be on a minute
+// boundary.
+func (w *ActivationWindow) startsAt(t time.Time) bool {
+	if w.minutes&(1<<uint(t.Minute())) == 0 ||
+		w.hours&(1<<uint(t.Hour())) == 0 ||
+		w.months&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+	domMatch := w.daysOfMonth&(1<<uint(t.Day())) != 0
+	dowMatch := w.daysOfWeek&(1<<uint(t.Weekday())) != 0
+	if w.anyDayOfMonth || w.anyDayOfWeek {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+// Contains returns true if the window is active at the given time; that is, if the window
+// started less than its duration before t.
+func (w *ActivationWindow) Contains(t time.Time) bool {
+	start := t.Truncate(time.Minute)
+	for t.Sub(start) < w.duration {
+		if w.startsAt(start) {
+			return true
+		}
+		start = start.Add(-time.Minute)
+	}
+	return false
+}
+
+// ActivationWindowsContain returns true if any of the given windows is active at the given time.
+func ActivationWindowsContain(windows []*ActivationWindow, t time.Time) bool {
+	for _, w := range windows {
+		if w.Contains(t) {
+			return true
+		}
+	}
+	return false
+}
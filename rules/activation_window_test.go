@@ -0,0 +1,65 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rules_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	. "github.com/projectcalico/felix/rules"
+)
+
+// Saturday 16th October 2021.
+func sat(hour, minute int) time.Time {
+	return time.Date(2021, time.October, 16, hour, minute, 30, 0, time.UTC)
+}
+
+var _ = DescribeTable("ActivationWindow.Contains",
+	func(window string, t time.Time, expected bool) {
+		w, err := ParseActivationWindow(window)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(w.Contains(t)).To(Equal(expected))
+	},
+	Entry("at the start", "0 2 * * 6 4h", sat(2, 0), true),
+	Entry("in the middle", "0 2 * * 6 4h", sat(4, 15), true),
+	Entry("just before the end", "0 2 * * 6 4h", sat(5, 59), true),
+	Entry("at the end", "0 2 * * 6 4h", sat(6, 0), false),
+	Entry("before the start", "0 2 * * 6 4h", sat(1, 59), false),
+	Entry("wrong day of the week", "0 2 * * 1-5 4h", sat(3, 0), false),
+	Entry("spanning midnight", "0 22 * * 5 4h", sat(1, 0), true),
+	Entry("day of month or day of week", "0 2 16 * 1 1h", sat(2, 30), true),
+	Entry("wrong month", "0 2 * 1-9 * 1h", sat(2, 30), false),
+	Entry("steps", "*/20 * * * * 5m", sat(10, 44), true),
+	Entry("outside steps", "*/20 * * * * 5m", sat(10, 45), false),
+	Entry("Sunday as 7, started the previous week", "0 0 * * 7 168h", sat(23, 0), true),
+)
+
+var _ = DescribeTable("ParseActivationWindow errors",
+	func(window string, expectedErr string) {
+		_, err := ParseActivationWindow(window)
+		Expect(err).To(MatchError(expectedErr))
+	},
+	Entry("too few fields", "0 2 * * 6", `activation window "0 2 * * 6" should have 6 fields, not 5`),
+	Entry("out of range", "0 24 * * 6 1h",
+		`activation window "0 24 * * 6 1h" has invalid hour field: "24" is out of range 0-23`),
+	Entry("bad step", "*/0 * * * * 1h",
+		`activation window "*/0 * * * * 1h" has invalid minute field: invalid step in "*/0"`),
+	Entry("bad value", "0 2 * * sat 1h",
+		`activation window "0 2 * * sat 1h" has invalid day-of-week field: invalid value in "sat"`),
+	Entry("duration too long", "0 2 * * 6 200h",
+		`activation window "0 2 * * 6 200h" has invalid duration "200h", must be between 1m0s and 168h0m0s`),
+)
@@ -54,6 +54,57 @@ func (r *DefaultRuleRenderer) WorkloadDispatchChains(
 	)
 }
 
+// WorkloadRawDispatchChains renders the raw table dispatch chains for workload endpoints that have
+// untracked policy.  The raw table is traversed before the routing decision so there is no
+// outgoing interface to match on; traffic to a workload is dispatched on its destination IP
+// instead.
+func (r *DefaultRuleRenderer) WorkloadRawDispatchChains(
+	endpoints map[proto.WorkloadEndpointID]*proto.WorkloadEndpoint,
+	ipVersion uint8,
+) []*Chain {
+	log.WithField("numEndpoints", len(endpoints)).Debug("Rendering raw workload dispatch chains")
+	names := make([]string, 0, len(endpoints))
+	nameToEndpoint := map[string]*proto.WorkloadEndpoint{}
+	for _, endpoint := range endpoints {
+		names = append(names, endpoint.Name)
+		nameToEndpoint[endpoint.Name] = endpoint
+	}
+
+	// Packets from interfaces without untracked policy fall through to the tracked policy in
+	// the filter table.
+	chains := r.interfaceNameDispatchChains(
+		names,
+		WorkloadFromEndpointPfx,
+		"",
+		ChainFromWorkloadDispatch,
+		"",
+		nil,
+		nil,
+	)
+
+	// interfaceNameDispatchChains sorted the names for us.
+	toRules := []Rule{}
+	for _, name := range names {
+		ep := nameToEndpoint[name]
+		nets := ep.Ipv4Nets
+		if ipVersion == 6 {
+			nets = ep.Ipv6Nets
+		}
+		for _, n := range nets {
+			toRules = append(toRules, Rule{
+				Match:  Match().DestNet(n),
+				Action: GotoAction{Target: EndpointChainName(r.ChainName(WorkloadToEndpointPfx), name)},
+			})
+		}
+	}
+	chains = append(chains, &Chain{
+		Name:  r.ChainName(ChainToWorkloadDispatch),
+		Rules: toRules,
+	})
+
+	return chains
+}
+
 func (r *DefaultRuleRenderer) WorkloadInterfaceAllowChains(
 	endpoints map[proto.WorkloadEndpointID]*proto.WorkloadEndpoint,
 ) []*Chain {
@@ -177,12 +228,12 @@ func (r *DefaultRuleRenderer) hostDispatchChains(
 		// interface in the `endpoints` map.
 		fromEndRules = []Rule{
 			Rule{
-				Action: GotoAction{Target: EndpointChainName(HostFromEndpointPfx, defaultIfaceName)},
+				Action: GotoAction{Target: EndpointChainName(r.ChainName(HostFromEndpointPfx), defaultIfaceName)},
 			},
 		}
 		fromEndForwardRules = []Rule{
 			Rule{
-				Action: GotoAction{Target: EndpointChainName(HostFromEndpointForwardPfx, defaultIfaceName)},
+				Action: GotoAction{Target: EndpointChainName(r.ChainName(HostFromEndpointForwardPfx), defaultIfaceName)},
 			},
 		}
 
@@ -202,11 +253,11 @@ func (r *DefaultRuleRenderer) hostDispatchChains(
 		}
 
 		toEndRules = append(toEndRules, Rule{
-			Action: GotoAction{Target: EndpointChainName(HostToEndpointPfx, defaultIfaceName)},
+			Action: GotoAction{Target: EndpointChainName(r.ChainName(HostToEndpointPfx), defaultIfaceName)},
 		})
 		toEndForwardRules = []Rule{
 			Rule{
-				Action: GotoAction{Target: EndpointChainName(HostToEndpointForwardPfx, defaultIfaceName)},
+				Action: GotoAction{Target: EndpointChainName(r.ChainName(HostToEndpointForwardPfx), defaultIfaceName)},
 			},
 		}
 	}
@@ -297,7 +348,7 @@ func (r *DefaultRuleRenderer) interfaceNameDispatchChains(
 			func(name string) MatchCriteria { return Match().InInterface(name) },
 			func(pfx, name string) Action {
 				return GotoAction{
-					Target: EndpointChainName(pfx, name),
+					Target: EndpointChainName(r.ChainName(pfx), name),
 				}
 			},
 			fromEndRules,
@@ -317,7 +368,7 @@ func (r *DefaultRuleRenderer) interfaceNameDispatchChains(
 			func(name string) MatchCriteria { return Match().OutInterface(name) },
 			func(pfx, name string) Action {
 				return GotoAction{
-					Target: EndpointChainName(pfx, name),
+					Target: EndpointChainName(r.ChainName(pfx), name),
 				}
 			},
 			toEndRules,
@@ -365,7 +416,7 @@ func (r *DefaultRuleRenderer) endpointMarkDispatchChains(
 				func(name string) MatchCriteria { return Match().InInterface(name) },
 				func(pfx, name string) Action {
 					return GotoAction{
-						Target: EndpointChainName(pfx, name),
+						Target: EndpointChainName(r.ChainName(pfx), name),
 					}
 				},
 				nil,
@@ -418,7 +469,7 @@ func (r *DefaultRuleRenderer) endpointMarkDispatchChains(
 				rootFromMarkRules = append(rootFromMarkRules, Rule{
 					Match: Match().MarkMatchesWithMask(endPointMark, epMarkMapper.GetMask()),
 					Action: GotoAction{
-						Target: EndpointChainName(fromMarkPrefixes[index], name),
+						Target: EndpointChainName(r.ChainName(fromMarkPrefixes[index]), name),
 					},
 				})
 			}
@@ -436,11 +487,11 @@ func (r *DefaultRuleRenderer) endpointMarkDispatchChains(
 
 	// return set mark and from mark chains.
 	setMarkDispatchChain := &Chain{
-		Name:  dispatchSetMarkEndpointChainName,
+		Name:  r.ChainName(dispatchSetMarkEndpointChainName),
 		Rules: rootSetMarkRules,
 	}
 	fromMarkDispatchChain := &Chain{
-		Name:  dispatchFromMarkEndpointChainName,
+		Name:  r.ChainName(dispatchFromMarkEndpointChainName),
 		Rules: rootFromMarkRules,
 	}
 	chains = append(chains, setMarkDispatchChain, fromMarkDispatchChain)
@@ -478,7 +529,7 @@ func (r *DefaultRuleRenderer) buildSingleDispatchChains(
 			// More than one name, render a prefix match in the root chain...
 			nextChar := prefix[len(commonPrefix):]
 			ifaceMatch := prefix + "+"
-			childChainName := chainName + "-" + nextChar
+			childChainName := r.ChainName(chainName) + "-" + nextChar
 			logCxt := logCxt.WithFields(log.Fields{
 				"childChainName": childChainName,
 				"ifaceMatch":     ifaceMatch,
@@ -536,7 +587,7 @@ func (r *DefaultRuleRenderer) buildSingleDispatchChains(
 	rootRules = append(rootRules, endRules...)
 
 	rootChain := &Chain{
-		Name:  chainName,
+		Name:  r.ChainName(chainName),
 		Rules: rootRules,
 	}
 
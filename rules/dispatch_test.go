@@ -78,6 +78,44 @@ var _ = Describe("Dispatch chains", func() {
 			Expect(func() { renderer.WorkloadDispatchChains(input) }).To(Panic())
 		})
 
+		It("should render raw workload dispatch chains", func() {
+			input := map[proto.WorkloadEndpointID]*proto.WorkloadEndpoint{
+				{OrchestratorId: "foobar", WorkloadId: "workload-1", EndpointId: "ep2"}: {
+					Name:     "cali5678",
+					Ipv4Nets: []string{"10.0.0.2/32"},
+					Ipv6Nets: []string{"fd00::2/128"},
+				},
+				{OrchestratorId: "foobar", WorkloadId: "workload-0", EndpointId: "ep1"}: {
+					Name:     "cali1234",
+					Ipv4Nets: []string{"10.0.0.1/32", "10.0.1.0/24"},
+				},
+			}
+			Expect(renderer.WorkloadRawDispatchChains(input, 4)).To(Equal([]*iptables.Chain{
+				{
+					Name: "cali-from-wl-dispatch",
+					Rules: []iptables.Rule{
+						inboundGotoRule("cali1234", "cali-fw-cali1234"),
+						inboundGotoRule("cali5678", "cali-fw-cali5678"),
+					},
+				},
+				{
+					Name: "cali-to-wl-dispatch",
+					Rules: []iptables.Rule{
+						{Match: iptables.Match().DestNet("10.0.0.1/32"),
+							Action: iptables.GotoAction{Target: "cali-tw-cali1234"}},
+						{Match: iptables.Match().DestNet("10.0.1.0/24"),
+							Action: iptables.GotoAction{Target: "cali-tw-cali1234"}},
+						{Match: iptables.Match().DestNet("10.0.0.2/32"),
+							Action: iptables.GotoAction{Target: "cali-tw-cali5678"}},
+					},
+				},
+			}))
+			Expect(renderer.WorkloadRawDispatchChains(input, 6)[1].Rules).To(Equal([]iptables.Rule{
+				{Match: iptables.Match().DestNet("fd00::2/128"),
+					Action: iptables.GotoAction{Target: "cali-tw-cali5678"}},
+			}))
+		})
+
 		DescribeTable("workload rendering tests",
 			func(names []string, expectedChains map[bool][]*iptables.Chain) {
 				var input map[proto.WorkloadEndpointID]*proto.WorkloadEndpoint
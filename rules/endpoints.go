@@ -34,6 +34,8 @@ func (r *DefaultRuleRenderer) WorkloadEndpointToIptablesChains(
 	ingressPolicies []string,
 	egressPolicies []string,
 	profileIDs []string,
+	untrackedIngressPolicies []string,
+	untrackedEgressPolicies []string,
 ) []*Chain {
 	allowVXLANEncapFromWorkloads := r.Config.AllowVXLANPacketsFromWorkloads
 	allowIPIPEncapFromWorkloads := r.Config.AllowIPIPPacketsFromWorkloads
@@ -42,6 +44,7 @@ func (r *DefaultRuleRenderer) WorkloadEndpointToIptablesChains(
 		// Chain for traffic _to_ the endpoint.
 		r.endpointIptablesChain(
 			ingressPolicies,
+			untrackedIngressPolicies,
 			profileIDs,
 			ifaceName,
 			PolicyInboundPfx,
@@ -59,6 +62,7 @@ func (r *DefaultRuleRenderer) WorkloadEndpointToIptablesChains(
 		// unless explicitly overridden.
 		r.endpointIptablesChain(
 			egressPolicies,
+			untrackedEgressPolicies,
 			profileIDs,
 			ifaceName,
 			PolicyOutboundPfx,
@@ -87,6 +91,50 @@ func (r *DefaultRuleRenderer) WorkloadEndpointToIptablesChains(
 	return result
 }
 
+// WorkloadEndpointToRawChains renders the raw table chains that apply untracked policy to a
+// workload endpoint.  Only used when WorkloadUntrackedPolicyEnabled is set.
+func (r *DefaultRuleRenderer) WorkloadEndpointToRawChains(
+	ifaceName string,
+	ingressPolicyNames []string,
+	egressPolicyNames []string,
+) []*Chain {
+	log.WithField("ifaceName", ifaceName).Debug("Rendering raw (untracked) workload endpoint chain.")
+	return []*Chain{
+		// Chain for traffic _to_ the endpoint.
+		r.endpointIptablesChain(
+			ingressPolicyNames,
+			nil, // Only workload chains re-check untracked policy.
+			nil, // We don't render profiles into the raw table.
+			ifaceName,
+			PolicyInboundPfx,
+			ProfileInboundPfx,
+			WorkloadToEndpointPfx,
+			"", // No fail-safe chains for workloads.
+			chainTypeUntracked,
+			true, // Admin-down endpoints don't get raw chains.
+			AcceptAction{},
+			alwaysAllowVXLANEncap,
+			alwaysAllowIPIPEncap,
+		),
+		// Chain for traffic _from_ the endpoint.
+		r.endpointIptablesChain(
+			egressPolicyNames,
+			nil, // Only workload chains re-check untracked policy.
+			nil, // We don't render profiles into the raw table.
+			ifaceName,
+			PolicyOutboundPfx,
+			ProfileOutboundPfx,
+			WorkloadFromEndpointPfx,
+			"", // No fail-safe chains for workloads.
+			chainTypeUntracked,
+			true, // Admin-down endpoints don't get raw chains.
+			AcceptAction{},
+			r.Config.AllowVXLANPacketsFromWorkloads,
+			r.Config.AllowIPIPPacketsFromWorkloads,
+		),
+	}
+}
+
 func (r *DefaultRuleRenderer) HostEndpointToFilterChains(
 	ifaceName string,
 	epMarkMapper EndpointMarkMapper,
@@ -102,6 +150,7 @@ func (r *DefaultRuleRenderer) HostEndpointToFilterChains(
 		// Chain for output traffic _to_ the endpoint.
 		r.endpointIptablesChain(
 			egressPolicyNames,
+			nil, // Only workload chains re-check untracked policy.
 			profileIDs,
 			ifaceName,
 			PolicyOutboundPfx,
@@ -117,6 +166,7 @@ func (r *DefaultRuleRenderer) HostEndpointToFilterChains(
 		// Chain for input traffic _from_ the endpoint.
 		r.endpointIptablesChain(
 			ingressPolicyNames,
+			nil, // Only workload chains re-check untracked policy.
 			profileIDs,
 			ifaceName,
 			PolicyInboundPfx,
@@ -132,6 +182,7 @@ func (r *DefaultRuleRenderer) HostEndpointToFilterChains(
 		// Chain for forward traffic _to_ the endpoint.
 		r.endpointIptablesChain(
 			egressForwardPolicyNames,
+			nil, // Only workload chains re-check untracked policy.
 			profileIDs,
 			ifaceName,
 			PolicyOutboundPfx,
@@ -147,6 +198,7 @@ func (r *DefaultRuleRenderer) HostEndpointToFilterChains(
 		// Chain for forward traffic _from_ the endpoint.
 		r.endpointIptablesChain(
 			ingressForwardPolicyNames,
+			nil, // Only workload chains re-check untracked policy.
 			profileIDs,
 			ifaceName,
 			PolicyInboundPfx,
@@ -187,6 +239,7 @@ func (r *DefaultRuleRenderer) HostEndpointToMangleEgressChains(
 		// manipulations that might need to apply to our allowed traffic.
 		r.endpointIptablesChain(
 			egressPolicyNames,
+			nil, // Only workload chains re-check untracked policy.
 			profileIDs,
 			ifaceName,
 			PolicyOutboundPfx,
@@ -212,6 +265,7 @@ func (r *DefaultRuleRenderer) HostEndpointToRawChains(
 		// Chain for traffic _to_ the endpoint.
 		r.endpointIptablesChain(
 			egressPolicyNames,
+			nil, // Only workload chains re-check untracked policy.
 			nil, // We don't render profiles into the raw table.
 			ifaceName,
 			PolicyOutboundPfx,
@@ -227,6 +281,7 @@ func (r *DefaultRuleRenderer) HostEndpointToRawChains(
 		// Chain for traffic _from_ the endpoint.
 		r.endpointIptablesChain(
 			ingressPolicyNames,
+			nil, // Only workload chains re-check untracked policy.
 			nil, // We don't render profiles into the raw table.
 			ifaceName,
 			PolicyInboundPfx,
@@ -252,6 +307,7 @@ func (r *DefaultRuleRenderer) HostEndpointToMangleIngressChains(
 		// outgoing traffic through a host endpoint.
 		r.endpointIptablesChain(
 			preDNATPolicyNames,
+			nil, // Only workload chains re-check untracked policy.
 			nil, // We don't render profiles into the raw table.
 			ifaceName,
 			PolicyInboundPfx,
@@ -282,7 +338,7 @@ func (r *DefaultRuleRenderer) endpointSetMarkChain(
 	endpointPrefix string,
 ) *Chain {
 	rules := []Rule{}
-	chainName := EndpointChainName(endpointPrefix, name)
+	chainName := EndpointChainName(r.ChainName(endpointPrefix), name)
 
 	if endPointMark, err := epMarkMapper.GetEndpointMark(name); err == nil {
 		// Set endpoint mark.
@@ -298,8 +354,66 @@ func (r *DefaultRuleRenderer) endpointSetMarkChain(
 	}
 }
 
+// appendEgressAllowlistRules appends the rules that give traffic from a workload a last chance
+// to be accepted by the workload egress allowlist chain before it is dropped by default.  The
+// match selects the traffic that is about to be dropped.
+func (r *DefaultRuleRenderer) appendEgressAllowlistRules(
+	rules []Rule,
+	endpointPrefix string,
+	chainType endpointChainType,
+	match MatchCriteria,
+) []Rule {
+	if !r.WorkloadEgressAllowlistEnabled || endpointPrefix != WorkloadFromEndpointPfx ||
+		chainType != chainTypeNormal {
+		return rules
+	}
+	return append(rules,
+		Rule{
+			Match:  match,
+			Action: JumpAction{Target: r.ChainName(ChainWorkloadEgressAllowlist)},
+		},
+		Rule{
+			Match:   Match().MarkSingleBitSet(r.IptablesMarkAccept),
+			Action:  ReturnAction{},
+			Comment: []string{"Return if egress allowlist accepted"},
+		},
+	)
+}
+
+// appendLinkLocalRules appends the rules that apply WorkloadLinkLocalAccess to traffic from a
+// workload before any policy: the workload link-local chain drops the traffic or sets the accept
+// mark on it, in which case we return straight away.
+func (r *DefaultRuleRenderer) appendLinkLocalRules(
+	rules []Rule,
+	endpointPrefix string,
+	chainType endpointChainType,
+) []Rule {
+	if endpointPrefix != WorkloadFromEndpointPfx || chainType != chainTypeNormal {
+		return rules
+	}
+	switch r.WorkloadLinkLocalAccess {
+	case "Deny":
+		return append(rules, Rule{
+			Action: JumpAction{Target: r.ChainName(ChainWorkloadLinkLocal)},
+		})
+	case "Allow":
+		return append(rules,
+			Rule{
+				Action: JumpAction{Target: r.ChainName(ChainWorkloadLinkLocal)},
+			},
+			Rule{
+				Match:   Match().MarkSingleBitSet(r.IptablesMarkAccept),
+				Action:  ReturnAction{},
+				Comment: []string{"Return if link-local service allowed"},
+			},
+		)
+	}
+	return rules
+}
+
 func (r *DefaultRuleRenderer) endpointIptablesChain(
 	policyNames []string,
+	untrackedPolicyNames []string,
 	profileIds []string,
 	name string,
 	policyPrefix PolicyChainNamePrefix,
@@ -313,7 +427,7 @@ func (r *DefaultRuleRenderer) endpointIptablesChain(
 	allowIPIPEncap bool,
 ) *Chain {
 	rules := []Rule{}
-	chainName := EndpointChainName(endpointPrefix, name)
+	chainName := EndpointChainName(r.ChainName(endpointPrefix), name)
 
 	if !adminUp {
 		// Endpoint is admin-down, drop all traffic to/from it.
@@ -337,7 +451,7 @@ func (r *DefaultRuleRenderer) endpointIptablesChain(
 	// First set up failsafes.
 	if failsafeChain != "" {
 		rules = append(rules, Rule{
-			Action: JumpAction{Target: failsafeChain},
+			Action: JumpAction{Target: r.ChainName(failsafeChain)},
 		})
 	}
 
@@ -365,6 +479,35 @@ func (r *DefaultRuleRenderer) endpointIptablesChain(
 		})
 	}
 
+	rules = r.appendLinkLocalRules(rules, endpointPrefix, chainType)
+
+	if len(untrackedPolicyNames) > 0 {
+		// The packet may have been accepted by untracked policy in the raw table, in which case
+		// it has no conntrack entry.  Re-run the untracked policies for such packets so that
+		// they aren't dropped by the normal policies below.
+		rules = append(rules, Rule{
+			Comment: []string{"Start of untracked policies"},
+			Action: ClearMarkAction{
+				Mark: r.IptablesMarkPass,
+			},
+		})
+		for _, polID := range untrackedPolicyNames {
+			polChainName := r.PolicyChainName(
+				policyPrefix,
+				&proto.PolicyID{Name: polID},
+			)
+			rules = append(rules, Rule{
+				Match:  Match().ConntrackState("UNTRACKED").MarkClear(r.IptablesMarkPass),
+				Action: JumpAction{Target: polChainName},
+			})
+		}
+		rules = append(rules, Rule{
+			Match:   Match().MarkSingleBitSet(r.IptablesMarkAccept),
+			Action:  ReturnAction{},
+			Comment: []string{"Return if untracked policy accepted"},
+		})
+	}
+
 	if len(policyNames) > 0 {
 		// Clear the "pass" mark.  If a policy sets that mark, we'll skip the rest of the policies and
 		// continue processing the profiles, if there are any.
@@ -377,7 +520,7 @@ func (r *DefaultRuleRenderer) endpointIptablesChain(
 
 		// Then, jump to each policy in turn.
 		for _, polID := range policyNames {
-			polChainName := PolicyChainName(
+			polChainName := r.PolicyChainName(
 				policyPrefix,
 				&proto.PolicyID{Name: polID},
 			)
@@ -411,6 +554,14 @@ func (r *DefaultRuleRenderer) endpointIptablesChain(
 			//
 			// For untracked and pre-DNAT rules, we don't do that because there may be
 			// normal rules still to be applied to the packet in the filter table.
+			rules = r.appendEgressAllowlistRules(rules, endpointPrefix, chainType,
+				Match().MarkClear(r.IptablesMarkPass))
+			if r.DropCaptureEnabled {
+				rules = append(rules, Rule{
+					Match:  Match().MarkClear(r.IptablesMarkPass),
+					Action: r.dropCaptureAction(DropCaptureReasonNoPolicyPassed),
+				})
+			}
 			rules = append(rules, Rule{
 				Match:   Match().MarkClear(r.IptablesMarkPass),
 				Action:  DropAction{},
@@ -434,7 +585,7 @@ func (r *DefaultRuleRenderer) endpointIptablesChain(
 	if chainType == chainTypeNormal {
 		// Then, jump to each profile in turn.
 		for _, profileID := range profileIds {
-			profChainName := ProfileChainName(profilePrefix, &proto.ProfileID{Name: profileID})
+			profChainName := r.ProfileChainName(profilePrefix, &proto.ProfileID{Name: profileID})
 			rules = append(rules,
 				Rule{Action: JumpAction{Target: profChainName}},
 				// If policy marked packet as accepted, it returns, setting the
@@ -452,6 +603,13 @@ func (r *DefaultRuleRenderer) endpointIptablesChain(
 		// For untracked rules, we don't do that because there may be tracked rules
 		// still to be applied to the packet in the filter table.
 		//if dropIfNoProfilesMatched {
+		rules = r.appendEgressAllowlistRules(rules, endpointPrefix, chainType, Match())
+		if r.DropCaptureEnabled {
+			rules = append(rules, Rule{
+				Match:  Match(),
+				Action: r.dropCaptureAction(DropCaptureReasonNoProfileMatch),
+			})
+		}
 		rules = append(rules, Rule{
 			Match:   Match(),
 			Action:  DropAction{},
@@ -466,6 +624,15 @@ func (r *DefaultRuleRenderer) endpointIptablesChain(
 	}
 }
 
+// dropCaptureAction returns an action that copies the packet to the drop capture, tagged with the
+// given rule ID or drop reason.
+func (r *DefaultRuleRenderer) dropCaptureAction(reason string) Action {
+	return NflogAction{
+		Group:  r.DropCaptureNFLOGGroup,
+		Prefix: DropCapturePrefix + reason,
+	}
+}
+
 func (r *DefaultRuleRenderer) appendConntrackRules(rules []Rule, allowAction Action) []Rule {
 	// Allow return packets for established connections.
 	if allowAction != (AcceptAction{}) {
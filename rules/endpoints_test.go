@@ -99,6 +99,8 @@ var _ = Describe("Endpoints", func() {
 					true,
 					nil,
 					nil,
+					nil,
+					nil,
 					nil)).To(Equal(trimSMChain(kubeIPVSEnabled, []*Chain{
 					{
 						Name: "cali-tw-cali1234",
@@ -146,6 +148,8 @@ var _ = Describe("Endpoints", func() {
 					nil,
 					nil,
 					nil,
+					nil,
+					nil,
 				)).To(Equal(trimSMChain(kubeIPVSEnabled, []*Chain{
 					{
 						Name: "cali-tw-cali1234",
@@ -170,6 +174,124 @@ var _ = Describe("Endpoints", func() {
 				})))
 			})
 
+			It("should capture default drops when drop capture is enabled", func() {
+				conf := rrConfigNormalMangleReturn
+				conf.DropCaptureEnabled = true
+				conf.DropCaptureNFLOGGroup = 3
+				renderer = NewRenderer(conf)
+				chains := renderer.WorkloadEndpointToIptablesChains(
+					"cali1234",
+					epMarkMapper,
+					true,
+					[]string{"ai"},
+					nil,
+					[]string{"prof1"},
+					nil,
+					nil,
+				)
+				Expect(chains[0].Name).To(Equal("cali-tw-cali1234"))
+				Expect(chains[0].Rules).To(ContainElements(
+					Rule{
+						Match:  Match().MarkClear(0x10),
+						Action: NflogAction{Group: 3, Prefix: "DROP|no-policy-passed"},
+					},
+					Rule{
+						Match:   Match().MarkClear(0x10),
+						Action:  DropAction{},
+						Comment: []string{"Drop if no policies passed packet"},
+					},
+				))
+				Expect(chains[0].Rules[len(chains[0].Rules)-2:]).To(Equal([]Rule{
+					{
+						Match:  Match(),
+						Action: NflogAction{Group: 3, Prefix: "DROP|no-profile-matched"},
+					},
+					{
+						Match:   Match(),
+						Action:  DropAction{},
+						Comment: []string{"Drop if no profiles matched"},
+					},
+				}))
+			})
+
+			It("should consult the egress allowlist before default drops when it is enabled", func() {
+				conf := rrConfigNormalMangleReturn
+				conf.WorkloadEgressAllowlistEnabled = true
+				renderer = NewRenderer(conf)
+				chains := renderer.WorkloadEndpointToIptablesChains(
+					"cali1234",
+					epMarkMapper,
+					true,
+					[]string{"ai"},
+					[]string{"ae"},
+					[]string{"prof1"},
+					nil,
+					nil,
+				)
+				allowlistRules := func(match MatchCriteria) []Rule {
+					return []Rule{
+						{
+							Match:  match,
+							Action: JumpAction{Target: "cali-wl-egress-allow"},
+						},
+						{
+							Match:   Match().MarkSingleBitSet(0x8),
+							Action:  ReturnAction{},
+							Comment: []string{"Return if egress allowlist accepted"},
+						},
+					}
+				}
+				Expect(chains[0].Name).To(Equal("cali-tw-cali1234"))
+				Expect(chains[0].Rules).NotTo(ContainElement(allowlistRules(Match())[0]))
+				Expect(chains[1].Name).To(Equal("cali-fw-cali1234"))
+				Expect(chains[1].Rules[len(chains[1].Rules)-3:]).To(Equal(append(allowlistRules(Match()), Rule{
+					Match:   Match(),
+					Action:  DropAction{},
+					Comment: []string{"Drop if no profiles matched"},
+				})))
+				Expect(chains[1].Rules).To(ContainElement(allowlistRules(Match().MarkClear(0x10))[0]))
+			})
+
+			It("should jump to the link-local chain before policy when link-local access is set", func() {
+				conf := rrConfigNormalMangleReturn
+				conf.WorkloadLinkLocalAccess = "Allow"
+				renderer = NewRenderer(conf)
+				chains := renderer.WorkloadEndpointToIptablesChains(
+					"cali1234",
+					epMarkMapper,
+					true,
+					nil,
+					[]string{"ae"},
+					nil,
+					nil,
+					nil,
+				)
+				linkLocalRules := []Rule{
+					{Action: JumpAction{Target: "cali-wl-link-local"}},
+					{
+						Match:   Match().MarkSingleBitSet(0x8),
+						Action:  ReturnAction{},
+						Comment: []string{"Return if link-local service allowed"},
+					},
+				}
+				Expect(chains[0].Name).To(Equal("cali-tw-cali1234"))
+				Expect(chains[0].Rules).NotTo(ContainElement(linkLocalRules[0]))
+				Expect(chains[1].Name).To(Equal("cali-fw-cali1234"))
+				Expect(chains[1].Rules[5:8]).To(Equal(append(linkLocalRules, Rule{
+					Comment: []string{"Start of policies"},
+					Action:  ClearMarkAction{Mark: 0x10},
+				})))
+
+				conf.WorkloadLinkLocalAccess = "Deny"
+				renderer = NewRenderer(conf)
+				chains = renderer.WorkloadEndpointToIptablesChains(
+					"cali1234", epMarkMapper, true, nil, []string{"ae"}, nil, nil, nil)
+				Expect(chains[1].Rules[5:7]).To(Equal([]Rule{linkLocalRules[0], {
+					Comment: []string{"Start of policies"},
+					Action:  ClearMarkAction{Mark: 0x10},
+				}}))
+			})
+
 			It("should render a fully-loaded workload endpoint", func() {
 				Expect(renderer.WorkloadEndpointToIptablesChains(
 					"cali1234",
@@ -178,6 +300,8 @@ var _ = Describe("Endpoints", func() {
 					[]string{"ai", "bi"},
 					[]string{"ae", "be"},
 					[]string{"prof1", "prof2"},
+					nil,
+					nil,
 				)).To(Equal(trimSMChain(kubeIPVSEnabled, []*Chain{
 					{
 						Name: "cali-tw-cali1234",
@@ -476,6 +600,103 @@ var _ = Describe("Endpoints", func() {
 				}))
 			})
 
+			It("should render workload endpoint raw chains with untracked policies", func() {
+				Expect(renderer.WorkloadEndpointToRawChains("cali1234", []string{"c"}, []string{"d"})).To(Equal([]*Chain{
+					{
+						Name: "cali-tw-cali1234",
+						Rules: []Rule{
+							{Action: ClearMarkAction{Mark: 0x8}},
+
+							{Comment: []string{"Start of policies"},
+								Action: ClearMarkAction{Mark: 0x10}},
+							{Match: Match().MarkClear(0x10),
+								Action: JumpAction{Target: "cali-pi-c"}},
+							{Match: Match().MarkSingleBitSet(0x8),
+								Action: NoTrackAction{}},
+							{Match: Match().MarkSingleBitSet(0x8),
+								Action:  ReturnAction{},
+								Comment: []string{"Return if policy accepted"}},
+						},
+					},
+					{
+						Name: "cali-fw-cali1234",
+						Rules: []Rule{
+							{Action: ClearMarkAction{Mark: 0x8}},
+							dropVXLANRule,
+							dropIPIPRule,
+
+							{Comment: []string{"Start of policies"},
+								Action: ClearMarkAction{Mark: 0x10}},
+							{Match: Match().MarkClear(0x10),
+								Action: JumpAction{Target: "cali-po-d"}},
+							{Match: Match().MarkSingleBitSet(0x8),
+								Action: NoTrackAction{}},
+							{Match: Match().MarkSingleBitSet(0x8),
+								Action:  ReturnAction{},
+								Comment: []string{"Return if policy accepted"}},
+						},
+					},
+				}))
+			})
+
+			It("should re-check untracked policies in the workload endpoint filter chains", func() {
+				Expect(renderer.WorkloadEndpointToIptablesChains(
+					"cali1234", epMarkMapper,
+					true,
+					nil,
+					nil,
+					nil,
+					[]string{"c"},
+					nil,
+				)).To(Equal(trimSMChain(kubeIPVSEnabled, []*Chain{
+					{
+						Name: "cali-tw-cali1234",
+						Rules: []Rule{
+							// conntrack rules.
+							{Match: Match().ConntrackState("RELATED,ESTABLISHED"),
+								Action: AcceptAction{}},
+							{Match: Match().ConntrackState("INVALID"),
+								Action: DropAction{}},
+
+							{Action: ClearMarkAction{Mark: 0x8}},
+
+							{Comment: []string{"Start of untracked policies"},
+								Action: ClearMarkAction{Mark: 0x10}},
+							{Match: Match().ConntrackState("UNTRACKED").MarkClear(0x10),
+								Action: JumpAction{Target: "cali-pi-c"}},
+							{Match: Match().MarkSingleBitSet(0x8),
+								Action:  ReturnAction{},
+								Comment: []string{"Return if untracked policy accepted"}},
+
+							{Action: DropAction{},
+								Comment: []string{"Drop if no profiles matched"}},
+						},
+					},
+					{
+						Name: "cali-fw-cali1234",
+						Rules: []Rule{
+							// conntrack rules.
+							{Match: Match().ConntrackState("RELATED,ESTABLISHED"),
+								Action: AcceptAction{}},
+							{Match: Match().ConntrackState("INVALID"),
+								Action: DropAction{}},
+
+							{Action: ClearMarkAction{Mark: 0x8}},
+							dropVXLANRule,
+							dropIPIPRule,
+							{Action: DropAction{},
+								Comment: []string{"Drop if no profiles matched"}},
+						},
+					},
+					{
+						Name: "cali-sm-cali1234",
+						Rules: []Rule{
+							{Action: SetMaskedMarkAction{Mark: 0xd400, Mask: 0xff00}},
+						},
+					},
+				})))
+			})
+
 			It("should render host endpoint mangle chains with pre-DNAT policies", func() {
 				Expect(renderer.HostEndpointToMangleIngressChains(
 					"eth0",
@@ -527,6 +748,8 @@ var _ = Describe("Endpoints", func() {
 					nil,
 					nil,
 					nil,
+					nil,
+					nil,
 				)).To(Equal(trimSMChain(kubeIPVSEnabled, []*Chain{
 					{
 						Name: "cali-tw-cali1234",
@@ -613,6 +836,8 @@ var _ = Describe("Endpoints", func() {
 						nil,
 						nil,
 						nil,
+						nil,
+						nil,
 					)).To(Equal(trimSMChain(kubeIPVSEnabled, []*Chain{
 						{
 							Name: "cali-tw-cali1234",
@@ -664,6 +889,8 @@ var _ = Describe("Endpoints", func() {
 						nil,
 						nil,
 						nil,
+						nil,
+						nil,
 					)).To(Equal(trimSMChain(kubeIPVSEnabled, []*Chain{
 						{
 							Name: "cali-tw-cali1234",
@@ -716,6 +943,8 @@ var _ = Describe("Endpoints", func() {
 						nil,
 						nil,
 						nil,
+						nil,
+						nil,
 					)).To(Equal(trimSMChain(kubeIPVSEnabled, []*Chain{
 						{
 							Name: "cali-tw-cali1234",
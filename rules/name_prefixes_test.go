@@ -0,0 +1,135 @@
+// Copyright (c) 2021 Tigera, Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rules_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+
+	"github.com/projectcalico/felix/ipsets"
+	. "github.com/projectcalico/felix/iptables"
+	"github.com/projectcalico/felix/proto"
+	. "github.com/projectcalico/felix/rules"
+)
+
+var _ = Describe("Name prefixes", func() {
+	polID := &proto.PolicyID{Tier: "default", Name: "pol"}
+	profID := &proto.ProfileID{Name: "prof"}
+
+	It("should default to the historic names", func() {
+		conf := Config{}
+		Expect(conf.ChainName(ChainFilterForward)).To(Equal("cali-FORWARD"))
+		Expect(conf.PolicyChainName(PolicyInboundPfx, polID)).To(Equal(PolicyChainName(PolicyInboundPfx, polID)))
+		Expect(conf.HashPrefix()).To(Equal("cali:"))
+		Expect(conf.HistoricChainNamePrefixes()).To(ContainElement("felix-"))
+
+		prefixes, legacyNames := HistoricIPSetNames(IPSetNamePrefix)
+		Expect(prefixes).To(ConsistOf("felix-", "cali"))
+		Expect(legacyNames).NotTo(BeEmpty())
+	})
+
+	It("should derive all the names from custom prefixes", func() {
+		conf := Config{IptablesChainNamePrefix: "blu-", IptablesRuleHashPrefix: "blu:"}
+		Expect(conf.ChainName(ChainFilterForward)).To(Equal("blu-FORWARD"))
+		Expect(conf.ChainName(ChainSetWireguardIncomingMark)).To(Equal("blu-wireguard-incoming-mark"))
+		Expect(conf.ChainName(WorkloadToEndpointPfx)).To(Equal("blu-tw-"))
+		Expect(conf.ChainName("INPUT")).To(Equal("INPUT"))
+		Expect(conf.PolicyChainName(PolicyInboundPfx, polID)).To(Equal("blu-pi-pol"))
+		Expect(conf.ProfileChainName(ProfileOutboundPfx, profID)).To(Equal("blu-pro-prof"))
+		Expect(conf.HashPrefix()).To(Equal("blu:"))
+	})
+
+	It("should only clean up chains and IP sets with the custom prefixes", func() {
+		conf := Config{IptablesChainNamePrefix: "blu-"}
+		Expect(conf.HistoricChainNamePrefixes()).To(Equal([]string{"blu-"}))
+
+		prefixes, legacyNames := HistoricIPSetNames("blu")
+		Expect(prefixes).To(Equal([]string{"blu"}))
+		Expect(legacyNames).To(BeEmpty())
+	})
+
+	It("should render all the chains with the custom chain name prefix", func() {
+		conf := Config{
+			IptablesChainNamePrefix:     "blu-",
+			WorkloadIfacePrefixes:       []string{"cali"},
+			IPSetConfigV4:               ipsets.NewIPVersionConfig(ipsets.IPFamilyV4, "blu", nil, nil),
+			IPSetConfigV6:               ipsets.NewIPVersionConfig(ipsets.IPFamilyV6, "blu", nil, nil),
+			IptablesMarkAccept:          0x10,
+			IptablesMarkPass:            0x20,
+			IptablesMarkScratch0:        0x40,
+			IptablesMarkScratch1:        0x80,
+			IptablesMarkEndpoint:        0xff00,
+			IptablesMarkNonCaliEndpoint: 0x100,
+			KubeIPVSSupportEnabled:      true,
+			WorkloadLinkLocalAccess:     "Deny",
+		}
+		renderer := NewRenderer(conf)
+		epMarkMapper := NewEndpointMarkMapper(conf.IptablesMarkEndpoint, conf.IptablesMarkNonCaliEndpoint)
+		wlEndpoints := map[proto.WorkloadEndpointID]*proto.WorkloadEndpoint{
+			{WorkloadId: "wl1", EndpointId: "ep1"}: {Name: "cali1234"},
+			{WorkloadId: "wl2", EndpointId: "ep2"}: {Name: "cali1235"},
+		}
+		hostEndpoints := map[string]proto.HostEndpointID{"eth0": {EndpointId: "hep1"}}
+
+		var chains []*Chain
+		for _, ipVersion := range []uint8{4, 6} {
+			chains = append(chains, renderer.StaticFilterTableChains(ipVersion)...)
+			chains = append(chains, renderer.StaticNATTableChains(ipVersion)...)
+			chains = append(chains, renderer.StaticRawTableChains(ipVersion)...)
+			chains = append(chains, renderer.StaticMangleTableChains(ipVersion)...)
+			chains = append(chains, renderer.PolicyToIptablesChains(polID, &proto.Policy{}, ipVersion)...)
+		}
+		chains = append(chains, renderer.WorkloadDispatchChains(wlEndpoints)...)
+		chains = append(chains, renderer.EndpointMarkDispatchChains(epMarkMapper, wlEndpoints, hostEndpoints)...)
+		chains = append(chains, renderer.HostDispatchChains(hostEndpoints, "eth0", true)...)
+		chains = append(chains, renderer.WorkloadEndpointToIptablesChains(
+			"cali1234", epMarkMapper, true, []string{"pol"}, []string{"pol"}, []string{"prof"}, nil, nil)...)
+		chains = append(chains, renderer.HostEndpointToFilterChains(
+			"eth0", epMarkMapper, []string{"pol"}, []string{"pol"}, []string{"pol"}, []string{"pol"},
+			[]string{"prof"})...)
+
+		for _, chain := range chains {
+			Expect(chain.Name).To(HavePrefix("blu-"))
+			for _, rule := range chain.Rules {
+				switch action := rule.Action.(type) {
+				case JumpAction:
+					Expect(action.Target).To(HavePrefix("blu-"), "in chain "+chain.Name)
+				case GotoAction:
+					Expect(action.Target).To(HavePrefix("blu-"), "in chain "+chain.Name)
+				}
+			}
+		}
+	})
+})
+
+var _ = DescribeTable("ValidateNamePrefixes",
+	func(chainPrefix, ruleHashPrefix, ipSetPrefix, expectedErr string) {
+		err := ValidateNamePrefixes(chainPrefix, ruleHashPrefix, ipSetPrefix)
+		if expectedErr == "" {
+			Expect(err).NotTo(HaveOccurred())
+		} else {
+			Expect(err).To(MatchError(expectedErr))
+		}
+	},
+	Entry("defaults", "cali-", "cali:", "cali", ""),
+	Entry("custom prefixes", "blu-", "blu:", "blu", ""),
+	Entry("chain prefix overlapping a historic prefix", "calit", "blu:", "blu",
+		`chain name prefix "calit" overlaps with the default chain prefix "calitw-"`),
+	Entry("rule hash prefix overlapping the default", "blu-", "ca", "blu",
+		`rule hash prefix "ca" overlaps with the default rule hash prefix "cali:"`),
+	Entry("IP set prefix too long", "blu-", "blu:", "felix",
+		`IP set name prefix "felix" is too long, it must be at most 4 characters`),
+)
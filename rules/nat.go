@@ -24,7 +24,9 @@ import (
 )
 
 func (r *DefaultRuleRenderer) MakeNatOutgoingRule(protocol string, action iptables.Action, ipVersion uint8) iptables.Rule {
-	if r.Config.BPFEnabled {
+	// The BPF programs only handle IPv4 so, even in BPF mode, IPv6 traffic is matched using the
+	// IP sets that the masquerade manager maintains.
+	if r.Config.BPFEnabled && ipVersion == 4 {
 		return r.makeNATOutgoingRuleBPF(ipVersion, protocol, action)
 	} else {
 		return r.makeNATOutgoingRuleIPTables(ipVersion, protocol, action)
@@ -58,6 +60,10 @@ func (r *DefaultRuleRenderer) makeNATOutgoingRuleIPTables(ipVersion uint8, proto
 		SourceIPSet(masqIPsSetName).
 		NotDestIPSet(allIPsSetName)
 
+	if r.Config.ExternalNetworksEnabled {
+		match = match.NotDestIPSet(ipConf.NameForMainIPSet(IPSetIDExternalNetsNoMasquerade))
+	}
+
 	if protocol != "" {
 		match = match.Protocol(protocol)
 	}
@@ -76,16 +82,24 @@ func (r *DefaultRuleRenderer) makeNATOutgoingRuleIPTables(ipVersion uint8, proto
 func (r *DefaultRuleRenderer) NATOutgoingChain(natOutgoingActive bool, ipVersion uint8) *iptables.Chain {
 	var rules []iptables.Rule
 	if natOutgoingActive {
+		natOutgoingAddress := r.Config.NATOutgoingAddress
+		if ipVersion == 6 {
+			natOutgoingAddress = r.Config.NATOutgoingAddressV6
+		}
 		var defaultSnatRule iptables.Action = iptables.MasqAction{}
-		if r.Config.NATOutgoingAddress != nil {
-			defaultSnatRule = iptables.SNATAction{ToAddr: r.Config.NATOutgoingAddress.String()}
+		if natOutgoingAddress != nil {
+			defaultSnatRule = iptables.SNATAction{ToAddr: natOutgoingAddress.String()}
 		}
 
 		if r.Config.NATPortRange.MaxPort > 0 {
 			toPorts := fmt.Sprintf("%d-%d", r.Config.NATPortRange.MinPort, r.Config.NATPortRange.MaxPort)
 			var portRangeSnatRule iptables.Action = iptables.MasqAction{ToPorts: toPorts}
-			if r.Config.NATOutgoingAddress != nil {
-				toAddress := fmt.Sprintf("%s:%s", r.Config.NATOutgoingAddress.String(), toPorts)
+			if natOutgoingAddress != nil {
+				// An IPv6 address needs brackets to separate it from the ports.
+				toAddress := fmt.Sprintf("%s:%s", natOutgoingAddress.String(), toPorts)
+				if ipVersion == 6 {
+					toAddress = fmt.Sprintf("[%s]:%s", natOutgoingAddress.String(), toPorts)
+				}
 				portRangeSnatRule = iptables.SNATAction{ToAddr: toAddress}
 			}
 			rules = []iptables.Rule{
@@ -102,7 +116,38 @@ func (r *DefaultRuleRenderer) NATOutgoingChain(natOutgoingActive bool, ipVersion
 		}
 	}
 	return &iptables.Chain{
-		Name:  ChainNATOutgoing,
+		Name:  r.ChainName(ChainNATOutgoing),
+		Rules: rules,
+	}
+}
+
+// EgressSNATChain renders the chain that SNATs traffic from workloads that have their own egress
+// source address.  snatAddrs maps from workload IP to source address.  As for NAT outgoing, only
+// traffic to destinations outside the IP pools is SNATed.
+func (r *DefaultRuleRenderer) EgressSNATChain(snatAddrs map[string]string, ipVersion uint8) *iptables.Chain {
+	allIPsSetName := r.ipSetConfig(ipVersion).NameForMainIPSet(IPSetIDNATOutgoingAllPools)
+
+	workloadIPs := make([]string, 0, len(snatAddrs))
+	for workloadIP := range snatAddrs {
+		workloadIPs = append(workloadIPs, workloadIP)
+	}
+	sort.Strings(workloadIPs)
+
+	var rules []iptables.Rule
+	for _, workloadIP := range workloadIPs {
+		match := iptables.Match().
+			SourceNet(workloadIP).
+			NotDestIPSet(allIPsSetName)
+		if r.Config.IptablesNATOutgoingInterfaceFilter != "" {
+			match = match.OutInterface(r.Config.IptablesNATOutgoingInterfaceFilter)
+		}
+		rules = append(rules, iptables.Rule{
+			Match:  match,
+			Action: iptables.SNATAction{ToAddr: snatAddrs[workloadIP]},
+		})
+	}
+	return &iptables.Chain{
+		Name:  r.ChainName(ChainNATEgressSNAT),
 		Rules: rules,
 	}
 }
@@ -124,7 +169,7 @@ func (r *DefaultRuleRenderer) DNATsToIptablesChains(dnats map[string]string) []*
 		})
 	}
 	return []*iptables.Chain{{
-		Name:  ChainFIPDnat,
+		Name:  r.ChainName(ChainFIPDnat),
 		Rules: rules,
 	}}
 }
@@ -146,7 +191,7 @@ func (r *DefaultRuleRenderer) SNATsToIptablesChains(snats map[string]string) []*
 		})
 	}
 	return []*iptables.Chain{{
-		Name:  ChainFIPSnat,
+		Name:  r.ChainName(ChainFIPSnat),
 		Rules: rules,
 	}}
 }
@@ -166,7 +211,7 @@ func (r *DefaultRuleRenderer) BlockedCIDRsToIptablesChains(cidrs []string, ipVer
 		}
 	}
 	return []*iptables.Chain{{
-		Name:  ChainCIDRBlock,
+		Name:  r.ChainName(ChainCIDRBlock),
 		Rules: rules,
 	}}
 }
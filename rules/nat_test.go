@@ -59,6 +59,29 @@ var _ = Describe("NAT", func() {
 			},
 		}))
 	})
+	It("should render egress SNAT rules in order of workload IP", func() {
+		Expect(renderer.EgressSNATChain(map[string]string{
+			"10.65.0.3": "192.168.0.11",
+			"10.65.0.2": "192.168.0.10",
+		}, 4)).To(Equal(&Chain{
+			Name: "cali-egress-snat",
+			Rules: []Rule{
+				{
+					Action: SNATAction{ToAddr: "192.168.0.10"},
+					Match: Match().
+						SourceNet("10.65.0.2").
+						NotDestIPSet("cali40all-ipam-pools"),
+				},
+				{
+					Action: SNATAction{ToAddr: "192.168.0.11"},
+					Match: Match().
+						SourceNet("10.65.0.3").
+						NotDestIPSet("cali40all-ipam-pools"),
+				},
+			},
+		}))
+		Expect(renderer.EgressSNATChain(nil, 4)).To(Equal(&Chain{Name: "cali-egress-snat"}))
+	})
 	It("should render rules when active with an explicit SNAT address", func() {
 		snatAddress := "192.168.0.1"
 		localConfig := rrConfigNormal
@@ -216,6 +239,60 @@ var _ = Describe("NAT", func() {
 			},
 		}))
 	})
+	It("should render IPv6 rules when active", func() {
+		Expect(renderer.NATOutgoingChain(true, 6)).To(Equal(&Chain{
+			Name: "cali-nat-outgoing",
+			Rules: []Rule{
+				{
+					Action: MasqAction{},
+					Match: Match().
+						SourceIPSet("cali60masq-ipam-pools").
+						NotDestIPSet("cali60all-ipam-pools"),
+				},
+			},
+		}))
+	})
+	It("should not use the IPv4 SNAT address for IPv6", func() {
+		localConfig := rrConfigNormal
+		localConfig.NATOutgoingAddress = net.ParseIP("192.168.0.1")
+		renderer = NewRenderer(localConfig)
+
+		Expect(renderer.NATOutgoingChain(true, 6).Rules).To(Equal([]Rule{
+			{
+				Action: MasqAction{},
+				Match: Match().
+					SourceIPSet("cali60masq-ipam-pools").
+					NotDestIPSet("cali60all-ipam-pools"),
+			},
+		}))
+	})
+	It("should render IPv6 rules with explicit port range and an explicit SNAT address", func() {
+		localConfig := rrConfigNormal
+		localConfig.NATPortRange, _ = numorstring.PortFromRange(99, 100)
+		localConfig.NATOutgoingAddressV6 = net.ParseIP("fd00::1")
+		renderer = NewRenderer(localConfig)
+
+		rules := renderer.NATOutgoingChain(true, 6).Rules
+		Expect(rules).To(HaveLen(5))
+		Expect(rules[0].Action).To(Equal(SNATAction{ToAddr: "[fd00::1]:99-100"}))
+		Expect(rules[4].Action).To(Equal(SNATAction{ToAddr: "fd00::1"}))
+	})
+	It("should use the IP sets for IPv6 in BPF mode", func() {
+		localConfig := rrConfigNormal
+		localConfig.BPFEnabled = true
+		renderer = NewRenderer(localConfig)
+
+		Expect(renderer.NATOutgoingChain(true, 6).Rules).To(Equal([]Rule{
+			{
+				Action: MasqAction{},
+				Match: Match().
+					SourceIPSet("cali60masq-ipam-pools").
+					NotDestIPSet("cali60all-ipam-pools"),
+			},
+		}))
+		Expect(renderer.NATOutgoingChain(true, 4).Rules[0].Match).NotTo(Equal(
+			Match().SourceIPSet("cali40masq-ipam-pools").NotDestIPSet("cali40all-ipam-pools")))
+	})
 	It("should render nothing when inactive", func() {
 		Expect(renderer.NATOutgoingChain(false, 4)).To(Equal(&Chain{
 			Name:  "cali-nat-outgoing",
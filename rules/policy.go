@@ -16,6 +16,7 @@ package rules
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
@@ -29,24 +30,77 @@ import (
 // ruleRenderer defined in rules_defs.go.
 
 func (r *DefaultRuleRenderer) PolicyToIptablesChains(policyID *proto.PolicyID, policy *proto.Policy, ipVersion uint8) []*iptables.Chain {
+	defaultRules := PolicyDefaultActionRules(policy)
 	inbound := iptables.Chain{
-		Name:  PolicyChainName(PolicyInboundPfx, policyID),
-		Rules: r.ProtoRulesToIptablesRules(policy.InboundRules, ipVersion),
+		Name:  r.PolicyChainName(PolicyInboundPfx, policyID),
+		Rules: r.policyRulesToIptablesRules(append(policy.InboundRules, defaultRules...), ipVersion),
 	}
 	outbound := iptables.Chain{
-		Name:  PolicyChainName(PolicyOutboundPfx, policyID),
-		Rules: r.ProtoRulesToIptablesRules(policy.OutboundRules, ipVersion),
+		Name:  r.PolicyChainName(PolicyOutboundPfx, policyID),
+		Rules: r.policyRulesToIptablesRules(append(policy.OutboundRules, defaultRules...), ipVersion),
 	}
 	return []*iptables.Chain{&inbound, &outbound}
 }
 
+func (r *DefaultRuleRenderer) policyRulesToIptablesRules(protoRules []*proto.Rule, ipVersion uint8) []iptables.Rule {
+	if !r.PolicyRuleCountersEnabled {
+		return r.ProtoRulesToIptablesRules(protoRules, ipVersion)
+	}
+	var rules []iptables.Rule
+	for i, protoRule := range protoRules {
+		rs := r.ProtoRuleToIptablesRules(protoRule, ipVersion)
+		if len(rs) == 0 {
+			continue
+		}
+		// Every packet that matches the policy rule passes through its final iptables rule,
+		// which carries out (part of) the rule's action.  The counter comment goes first so
+		// that it's the one that iptables.Table keys the rule's counts by.
+		last := &rs[len(rs)-1]
+		last.Comment = append([]string{PolicyRuleCounterComment(i)}, last.Comment...)
+		rules = append(rules, rs...)
+	}
+	return rules
+}
+
+// PolicyRuleCounterComment returns the comment that identifies the iptables rule that counts the
+// packets that match the policy rule with the given index.  Indexes past the end of the policy's
+// own rules refer to the rules that implement its default action.
+func PolicyRuleCounterComment(ruleIdx int) string {
+	return fmt.Sprintf("%s%d", PolicyRuleCounterCommentPrefix, ruleIdx)
+}
+
+// ParsePolicyRuleCounterComment is the inverse of PolicyRuleCounterComment.
+func ParsePolicyRuleCounterComment(comment string) (ruleIdx int, ok bool) {
+	if !strings.HasPrefix(comment, PolicyRuleCounterCommentPrefix) {
+		return 0, false
+	}
+	ruleIdx, err := strconv.Atoi(comment[len(PolicyRuleCounterCommentPrefix):])
+	if err != nil || ruleIdx < 0 {
+		return 0, false
+	}
+	return ruleIdx, true
+}
+
+// PolicyDefaultActionRules returns the catch-all rules that implement the policy's default action,
+// to go after its own rules.  Returns nil if the policy has no default action.
+func PolicyDefaultActionRules(policy *proto.Policy) []*proto.Rule {
+	var rules []*proto.Rule
+	if policy.LogDefaultAction {
+		rules = append(rules, &proto.Rule{Action: "log"})
+	}
+	if policy.DefaultAction != "" {
+		rules = append(rules, &proto.Rule{Action: policy.DefaultAction})
+	}
+	return rules
+}
+
 func (r *DefaultRuleRenderer) ProfileToIptablesChains(profileID *proto.ProfileID, profile *proto.Profile, ipVersion uint8) (inbound, outbound *iptables.Chain) {
 	inbound = &iptables.Chain{
-		Name:  ProfileChainName(ProfileInboundPfx, profileID),
+		Name:  r.ProfileChainName(ProfileInboundPfx, profileID),
 		Rules: r.ProtoRulesToIptablesRules(profile.InboundRules, ipVersion),
 	}
 	outbound = &iptables.Chain{
-		Name:  ProfileChainName(ProfileOutboundPfx, profileID),
+		Name:  r.ProfileChainName(ProfileOutboundPfx, profileID),
 		Rules: r.ProtoRulesToIptablesRules(profile.OutboundRules, ipVersion),
 	}
 	return
@@ -451,9 +505,9 @@ func (sod srcOrDst) MatchNet(cidr string) iptables.MatchCriteria {
 func (sod srcOrDst) AppendMatchPorts(m iptables.MatchCriteria, pr []*proto.PortRange) iptables.MatchCriteria {
 	switch sod {
 	case src:
-		return m.SourcePortRanges(pr)
+		return m.SourcePortRanges(iptablesPortRanges(pr))
 	case dst:
-		return m.DestPortRanges(pr)
+		return m.DestPortRanges(iptablesPortRanges(pr))
 	}
 	log.WithField("srcOrDst", sod).Panic("Unknown source or dest type.")
 	return nil
@@ -470,6 +524,19 @@ func (sod srcOrDst) MatchIPPortIPSet(setID string) iptables.MatchCriteria {
 	return nil
 }
 
+// iptablesPortRanges converts port ranges from the protobuf API to the iptables package's
+// equivalent.
+func iptablesPortRanges(ports []*proto.PortRange) []iptables.PortRange {
+	if ports == nil {
+		return nil
+	}
+	prs := make([]iptables.PortRange, len(ports))
+	for i, p := range ports {
+		prs[i] = iptables.PortRange{First: uint16(p.First), Last: uint16(p.Last)}
+	}
+	return prs
+}
+
 // SplitPortList splits the input list of ports into groups containing up to 15 port numbers.
 // If the input list is empty, it returns an empty slice.
 //
@@ -517,7 +584,10 @@ func (r *DefaultRuleRenderer) CalculateActions(pRule *proto.Rule, ipVersion uint
 		mark = r.IptablesMarkPass
 		actions = append(actions, iptables.ReturnAction{})
 	case "deny":
-		// Deny maps to DROP.
+		// Deny maps to DROP, optionally copying the packet to the drop capture first.
+		if r.DropCaptureEnabled {
+			actions = append(actions, r.dropCaptureAction(pRule.RuleId))
+		}
 		actions = append(actions, iptables.DropAction{})
 	case "log":
 		// This rule should log.
@@ -587,7 +657,7 @@ func (r *DefaultRuleRenderer) CalculateRuleMatch(pRule *proto.Rule, ipVersion ui
 		logCxt.WithFields(log.Fields{
 			"ports": pRule.SrcPorts,
 		}).Debug("Adding src port match")
-		match = match.SourcePortRanges(pRule.SrcPorts)
+		match = match.SourcePortRanges(iptablesPortRanges(pRule.SrcPorts))
 	}
 
 	if len(pRule.SrcNamedPortIpSetIds) > 1 {
@@ -624,7 +694,7 @@ func (r *DefaultRuleRenderer) CalculateRuleMatch(pRule *proto.Rule, ipVersion ui
 		logCxt.WithFields(log.Fields{
 			"ports": pRule.SrcPorts,
 		}).Debug("Adding dst port match")
-		match = match.DestPortRanges(pRule.DstPorts)
+		match = match.DestPortRanges(iptablesPortRanges(pRule.DstPorts))
 	}
 
 	if len(pRule.DstNamedPortIpSetIds) > 1 {
@@ -696,7 +766,7 @@ func (r *DefaultRuleRenderer) CalculateRuleMatch(pRule *proto.Rule, ipVersion ui
 			"ports": pRule.NotSrcPorts,
 		}).Debug("Adding src port match")
 		for _, portSplit := range SplitPortList(pRule.NotSrcPorts) {
-			match = match.NotSourcePortRanges(portSplit)
+			match = match.NotSourcePortRanges(iptablesPortRanges(portSplit))
 		}
 	}
 
@@ -730,7 +800,7 @@ func (r *DefaultRuleRenderer) CalculateRuleMatch(pRule *proto.Rule, ipVersion ui
 			"ports": pRule.NotSrcPorts,
 		}).Debug("Adding dst port match")
 		for _, portSplit := range SplitPortList(pRule.NotDstPorts) {
-			match = match.NotDestPortRanges(portSplit)
+			match = match.NotDestPortRanges(iptablesPortRanges(portSplit))
 		}
 	}
 
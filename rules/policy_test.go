@@ -288,6 +288,27 @@ var _ = Describe("Protobuf rule to iptables rule conversion", func() {
 		ruleTestData...,
 	)
 
+	DescribeTable(
+		"Deny rules should be correctly rendered with drop capture enabled",
+		func(ipVer int, in proto.Rule, expMatch string) {
+			rrConfigCapture := rrConfigNormal
+			rrConfigCapture.DropCaptureEnabled = true
+			rrConfigCapture.DropCaptureNFLOGGroup = 3
+			renderer := NewRenderer(rrConfigCapture)
+			denyRule := in
+			denyRule.Action = "deny"
+			denyRule.RuleId = "abcd1234"
+			rules := renderer.ProtoRuleToIptablesRules(&denyRule, uint8(ipVer))
+			// Should copy the packet to the capture NFLOG group, tagged with the rule ID, then DROP.
+			Expect(len(rules)).To(Equal(2))
+			Expect(rules[0].Match.Render()).To(Equal(expMatch))
+			Expect(rules[0].Action).To(Equal(iptables.NflogAction{Group: 3, Prefix: "DROP|abcd1234"}))
+			Expect(rules[1].Match.Render()).To(Equal(expMatch))
+			Expect(rules[1].Action).To(Equal(iptables.DropAction{}))
+		},
+		ruleTestData...,
+	)
+
 	const (
 		clearBothMarksRule       = "-A test --jump MARK --set-mark 0x0/0x600"
 		preSetAllBlocksMarkRule  = "-A test --jump MARK --set-mark 0x200/0x600"
@@ -1063,3 +1084,131 @@ var _ = Describe("rule metadata tests", func() {
 		}
 	})
 })
+
+var _ = Describe("policy default action tests", func() {
+	rrConfigNormal := Config{
+		IPIPEnabled:          true,
+		IPIPTunnelAddress:    nil,
+		IPSetConfigV4:        ipsets.NewIPVersionConfig(ipsets.IPFamilyV4, "cali", nil, nil),
+		IPSetConfigV6:        ipsets.NewIPVersionConfig(ipsets.IPFamilyV6, "cali", nil, nil),
+		IptablesMarkAccept:   0x80,
+		IptablesMarkPass:     0x100,
+		IptablesMarkScratch0: 0x200,
+		IptablesMarkScratch1: 0x400,
+		IptablesMarkEndpoint: 0xff000,
+		IptablesLogPrefix:    "calico-packet",
+	}
+	policyID := &proto.PolicyID{Tier: "default", Name: "audit"}
+	denyRule := &proto.Rule{
+		Action: "deny",
+		SrcNet: []string{"10.0.0.1/32"},
+	}
+
+	It("should render nothing extra without a default action", func() {
+		renderer := NewRenderer(rrConfigNormal)
+		chains := renderer.PolicyToIptablesChains(policyID, &proto.Policy{
+			InboundRules: []*proto.Rule{denyRule},
+		}, 4)
+		Expect(chains[0].Rules).To(Equal(renderer.ProtoRuleToIptablesRules(denyRule, 4)))
+		Expect(chains[1].Rules).To(BeEmpty())
+	})
+
+	It("should log and allow at the end of an audit mode policy", func() {
+		renderer := NewRenderer(rrConfigNormal)
+		chains := renderer.PolicyToIptablesChains(policyID, &proto.Policy{
+			InboundRules:     []*proto.Rule{denyRule},
+			DefaultAction:    "allow",
+			LogDefaultAction: true,
+		}, 4)
+		expectedDefault := []iptables.Rule{
+			{Match: iptables.Match(), Action: iptables.LogAction{Prefix: "calico-packet"}},
+			{Match: iptables.Match(), Action: iptables.SetMarkAction{Mark: 0x80}},
+			{Match: iptables.Match().MarkSingleBitSet(0x80), Action: iptables.ReturnAction{}},
+		}
+		Expect(chains[0].Rules).To(Equal(append(renderer.ProtoRuleToIptablesRules(denyRule, 4), expectedDefault...)))
+		Expect(chains[1].Rules).To(Equal(expectedDefault))
+	})
+
+	It("should drop at the end of a policy with a deny default action", func() {
+		renderer := NewRenderer(rrConfigNormal)
+		chains := renderer.PolicyToIptablesChains(policyID, &proto.Policy{
+			DefaultAction: "deny",
+		}, 6)
+		Expect(chains[0].Rules).To(Equal([]iptables.Rule{{Match: iptables.Match(), Action: iptables.DropAction{}}}))
+	})
+})
+
+var _ = Describe("policy rule counter tests", func() {
+	rrConfig := Config{
+		IPSetConfigV4:             ipsets.NewIPVersionConfig(ipsets.IPFamilyV4, "cali", nil, nil),
+		IPSetConfigV6:             ipsets.NewIPVersionConfig(ipsets.IPFamilyV6, "cali", nil, nil),
+		IptablesMarkAccept:        0x80,
+		IptablesMarkPass:          0x100,
+		IptablesMarkScratch0:      0x200,
+		IptablesMarkScratch1:      0x400,
+		IptablesMarkEndpoint:      0xff000,
+		PolicyRuleCountersEnabled: true,
+	}
+	policyID := &proto.PolicyID{Tier: "default", Name: "counted"}
+
+	It("should tag the last iptables rule of each policy rule", func() {
+		renderer := NewRenderer(rrConfig)
+		chains := renderer.PolicyToIptablesChains(policyID, &proto.Policy{
+			InboundRules: []*proto.Rule{
+				{Action: "allow", SrcNet: []string{"10.0.0.1/32"}, Metadata: &proto.RuleMetadata{
+					Annotations: map[string]string{"owner": "alice"},
+				}},
+				{Action: "allow", SrcNet: []string{"feed::1/128"}},
+				{Action: "deny"},
+			},
+			DefaultAction: "allow",
+		}, 4)
+		Expect(chains[0].Rules).To(Equal([]iptables.Rule{
+			{
+				Match:   iptables.Match().SourceNet("10.0.0.1/32"),
+				Action:  iptables.SetMarkAction{Mark: 0x80},
+				Comment: []string{"owner=alice"},
+			},
+			{
+				Match:   iptables.Match().MarkSingleBitSet(0x80),
+				Action:  iptables.ReturnAction{},
+				Comment: []string{"policy-rule=0", "owner=alice"},
+			},
+			// Rule 1 is IPv6-only so it isn't rendered.
+			{
+				Match:   iptables.Match(),
+				Action:  iptables.DropAction{},
+				Comment: []string{"policy-rule=2"},
+			},
+			{
+				Match:  iptables.Match(),
+				Action: iptables.SetMarkAction{Mark: 0x80},
+			},
+			{
+				Match:   iptables.Match().MarkSingleBitSet(0x80),
+				Action:  iptables.ReturnAction{},
+				Comment: []string{"policy-rule=3"},
+			},
+		}))
+	})
+
+	It("should not tag rules when disabled", func() {
+		cfg := rrConfig
+		cfg.PolicyRuleCountersEnabled = false
+		renderer := NewRenderer(cfg)
+		chains := renderer.PolicyToIptablesChains(policyID, &proto.Policy{
+			InboundRules: []*proto.Rule{{Action: "deny"}},
+		}, 4)
+		Expect(chains[0].Rules).To(Equal([]iptables.Rule{{Match: iptables.Match(), Action: iptables.DropAction{}}}))
+	})
+
+	It("should parse its own comments", func() {
+		idx, ok := ParsePolicyRuleCounterComment(PolicyRuleCounterComment(12))
+		Expect(ok).To(BeTrue())
+		Expect(idx).To(Equal(12))
+		_, ok = ParsePolicyRuleCounterComment("policy-rule=x")
+		Expect(ok).To(BeFalse())
+		_, ok = ParsePolicyRuleCounterComment("owner=alice")
+		Expect(ok).To(BeFalse())
+	})
+})
@@ -15,6 +15,7 @@
 package rules
 
 import (
+	"fmt"
 	"net"
 	"reflect"
 	"strings"
@@ -23,6 +24,7 @@ import (
 
 	"github.com/projectcalico/api/pkg/lib/numorstring"
 	"github.com/projectcalico/felix/config"
+	"github.com/projectcalico/felix/ip"
 	"github.com/projectcalico/felix/ipsets"
 	"github.com/projectcalico/felix/iptables"
 	"github.com/projectcalico/felix/proto"
@@ -31,7 +33,8 @@ import (
 const (
 	// ChainNamePrefix is a prefix used for all our iptables chain names.  We include a '-' at
 	// the end to reduce clashes with other apps.  Our OpenStack DHCP agent uses prefix
-	// 'calico-dhcp-', for example.
+	// 'calico-dhcp-', for example.  The chain names below use this prefix; Config's
+	// IptablesChainNamePrefix replaces it, if set, see Config.ChainName().
 	ChainNamePrefix = "cali-"
 	// IPSetNamePrefix: similarly for IP sets, we use the following prefix; the IP sets layer
 	// adds its own "-" so it isn't included here.
@@ -51,6 +54,7 @@ const (
 	ChainNATPostrouting = ChainNamePrefix + "POSTROUTING"
 	ChainNATOutput      = ChainNamePrefix + "OUTPUT"
 	ChainNATOutgoing    = ChainNamePrefix + "nat-outgoing"
+	ChainNATEgressSNAT  = ChainNamePrefix + "egress-snat"
 
 	ChainManglePrerouting  = ChainNamePrefix + "PREROUTING"
 	ChainManglePostrouting = ChainNamePrefix + "POSTROUTING"
@@ -62,17 +66,45 @@ const (
 	IPSetIDAllVXLANSourceNets = "all-vxlan-net"
 	IPSetIDThisHostIPs        = "this-host"
 
+	// IPSetIDKubeIPVSServiceIPs is the IP set of the service IPs that kube-proxy, in IPVS mode,
+	// has bound to the kube-ipvs0 interface.
+	IPSetIDKubeIPVSServiceIPs = "ipvs-svc-ips"
+
+	// IP sets of the CIDRs of the external networks that have each forwarding treatment.
+	IPSetIDExternalNetsTunnel       = "extnets-tunnel"
+	IPSetIDExternalNetsRPFExempt    = "extnets-rpf"
+	IPSetIDExternalNetsNoMasquerade = "extnets-nomasq"
+
+	// IPSetIDEgressAllowlistPrefix is the prefix of the IDs of the per-workload egress allowlist
+	// IP sets; see EgressAllowlistIPSetID().
+	IPSetIDEgressAllowlistPrefix = "ea:"
+
 	ChainFIPDnat = ChainNamePrefix + "fip-dnat"
 	ChainFIPSnat = ChainNamePrefix + "fip-snat"
 
 	ChainCIDRBlock = ChainNamePrefix + "cidr-block"
 
+	ChainCNIGate = ChainNamePrefix + "cni-gate"
+
+	ChainWorkloadDrain = ChainNamePrefix + "wl-drain"
+
+	ChainWorkloadConnRateLimit = ChainNamePrefix + "wl-conn-rate-limit"
+
+	ChainWorkloadAllowedSources = ChainNamePrefix + "wl-allowed-src"
+
+	ChainWorkloadEgressAllowlist = ChainNamePrefix + "wl-egress-allow"
+
+	ChainWorkloadLinkLocal = ChainNamePrefix + "wl-link-local"
+
+	ChainWorkloadMACCheck = ChainNamePrefix + "wl-mac-check"
+
 	PolicyInboundPfx   PolicyChainNamePrefix  = ChainNamePrefix + "pi-"
 	PolicyOutboundPfx  PolicyChainNamePrefix  = ChainNamePrefix + "po-"
 	ProfileInboundPfx  ProfileChainNamePrefix = ChainNamePrefix + "pri-"
 	ProfileOutboundPfx ProfileChainNamePrefix = ChainNamePrefix + "pro-"
 
 	ChainWorkloadToHost       = ChainNamePrefix + "wl-to-host"
+	ChainWorkloadToHostAction = ChainNamePrefix + "wl-to-host-act"
 	ChainFromWorkloadDispatch = ChainNamePrefix + "from-wl-dispatch"
 	ChainToWorkloadDispatch   = ChainNamePrefix + "to-wl-dispatch"
 
@@ -99,8 +131,21 @@ const (
 	HostToEndpointForwardPfx   = ChainNamePrefix + "thfw-"
 	HostFromEndpointForwardPfx = ChainNamePrefix + "fhfw-"
 
+	// RuleHashPrefix is the prefix of the hash comment on each of our rules; Config's
+	// IptablesRuleHashPrefix replaces it, if set.
 	RuleHashPrefix = "cali:"
 
+	// DropCapturePrefix is the NFLOG prefix used for captured drops.  It is followed by the ID
+	// of the policy rule that dropped the packet or one of the DropCaptureReason values below.
+	DropCapturePrefix = "DROP|"
+
+	DropCaptureReasonNoPolicyPassed = "no-policy-passed"
+	DropCaptureReasonNoProfileMatch = "no-profile-matched"
+
+	// PolicyRuleCounterCommentPrefix is the prefix of the comment that identifies the rules
+	// that count each policy rule's packets; see PolicyRuleCounterComment().
+	PolicyRuleCounterCommentPrefix = "policy-rule="
+
 	// HistoricNATRuleInsertRegex is a regex pattern to match to match
 	// special-case rules inserted by old versions of felix.  Specifically,
 	// Python felix used to insert a masquerade rule directly into the
@@ -158,6 +203,11 @@ var (
 		"KUBE-SEP-",
 		"KUBE-FW-",
 		"KUBE-XLB-",
+		// IPVS mode.
+		"KUBE-NODE-PORT",
+		"KUBE-LOAD-BALANCER",
+		"KUBE-IPVS-",
+		"KUBE-SOURCE-RANGES-FIREWALL",
 	}
 )
 
@@ -177,6 +227,14 @@ type RuleRenderer interface {
 		ingressPolicies []string,
 		egressPolicies []string,
 		profileIDs []string,
+		untrackedIngressPolicies []string,
+		untrackedEgressPolicies []string,
+	) []*iptables.Chain
+	WorkloadRawDispatchChains(map[proto.WorkloadEndpointID]*proto.WorkloadEndpoint, uint8) []*iptables.Chain
+	WorkloadEndpointToRawChains(
+		ifaceName string,
+		ingressPolicyNames []string,
+		egressPolicyNames []string,
 	) []*iptables.Chain
 
 	WorkloadInterfaceAllowChains(endpoints map[proto.WorkloadEndpointID]*proto.WorkloadEndpoint) []*iptables.Chain
@@ -220,20 +278,32 @@ type RuleRenderer interface {
 
 	MakeNatOutgoingRule(protocol string, action iptables.Action, ipVersion uint8) iptables.Rule
 	NATOutgoingChain(active bool, ipVersion uint8) *iptables.Chain
+	EgressSNATChain(snatAddrs map[string]string, ipVersion uint8) *iptables.Chain
 
 	DNATsToIptablesChains(dnats map[string]string) []*iptables.Chain
 	SNATsToIptablesChains(snats map[string]string) []*iptables.Chain
 	BlockedCIDRsToIptablesChains(cidrs []string, ipVersion uint8) []*iptables.Chain
 
 	WireguardIncomingMarkChain() *iptables.Chain
+	CNIGateChain(open bool) *iptables.Chain
+	WorkloadDrainChain(draining bool) *iptables.Chain
+	WorkloadConnRateLimitChain(limits []WorkloadConnRateLimit) *iptables.Chain
+	WorkloadAllowedSourcesChain(allowed []WorkloadAllowedSources) *iptables.Chain
+	WorkloadEgressAllowlistChain(ipVersion uint8, ifaceNames []string) *iptables.Chain
+	WorkloadLinkLocalChain(ipVersion uint8, exceptIfaceNames []string) *iptables.Chain
+	WorkloadMACCheckChain(macs []WorkloadMAC) *iptables.Chain
+	WorkloadToHostActionChain(overrides []WorkloadToHostAction) *iptables.Chain
+
+	ChainName(name string) string
+	PolicyChainName(prefix PolicyChainNamePrefix, polID *proto.PolicyID) string
+	ProfileChainName(prefix ProfileChainNamePrefix, profID *proto.ProfileID) string
 }
 
 type DefaultRuleRenderer struct {
 	Config
-	inputAcceptActions []iptables.Action
-	filterAllowAction  iptables.Action
-	mangleAllowAction  iptables.Action
-	blockCIDRAction    iptables.Action
+	filterAllowAction iptables.Action
+	mangleAllowAction iptables.Action
+	blockCIDRAction   iptables.Action
 }
 
 func (r *DefaultRuleRenderer) ipSetConfig(ipVersion uint8) *ipsets.IPVersionConfig {
@@ -251,6 +321,12 @@ type Config struct {
 	IPSetConfigV4 *ipsets.IPVersionConfig
 	IPSetConfigV6 *ipsets.IPVersionConfig
 
+	// IptablesChainNamePrefix and IptablesRuleHashPrefix, if set, replace ChainNamePrefix and
+	// RuleHashPrefix in the names of our chains and the hash comments on our rules.  The IP set
+	// name prefix is part of IPSetConfigV4/V6.
+	IptablesChainNamePrefix string
+	IptablesRuleHashPrefix  string
+
 	WorkloadIfacePrefixes []string
 
 	IptablesMarkAccept   uint32
@@ -272,6 +348,8 @@ type Config struct {
 	VXLANEnabled bool
 	VXLANPort    int
 	VXLANVNI     int
+	// VXLANPoolVNIs maps the IP pools that have their own VNI to that VNI.
+	VXLANPoolVNIs map[ip.V4CIDR]int
 
 	IPIPEnabled bool
 	// IPIPTunnelAddress is an address chosen from an IPAM pool, used as a source address
@@ -299,13 +377,200 @@ type Config struct {
 
 	DisableConntrackInvalid bool
 
+	// WorkloadUntrackedPolicyEnabled enables the raw table dispatch chains for workload
+	// endpoints that have untracked policy.
+	WorkloadUntrackedPolicyEnabled bool
+
 	NATPortRange                       numorstring.Port
 	IptablesNATOutgoingInterfaceFilter string
 
-	NATOutgoingAddress net.IP
-	BPFEnabled         bool
+	NATOutgoingAddress   net.IP
+	NATOutgoingAddressV6 net.IP
+	BPFEnabled           bool
 
 	ServiceLoopPrevention string
+
+	// CNIReadinessGateEnabled adds a jump to the CNI gate chain, which blocks new connections
+	// from workloads until the CNI plugin is ready.
+	CNIReadinessGateEnabled bool
+
+	// WorkloadDrainSupportEnabled adds a jump to the workload drain chain, which rejects new
+	// connections to workloads while the node is being drained.
+	WorkloadDrainSupportEnabled bool
+
+	// WorkloadConnRateLimitEnabled adds a jump to the workload connection rate limit chain, which
+	// drops new connections from workloads that exceed their rate limit.
+	WorkloadConnRateLimitEnabled bool
+
+	// WorkloadAllowedSourcesEnabled adds a jump to the workload allowed sources chain ahead of
+	// the workload RPF check, which lets workloads send from their additional allowed source
+	// prefixes.
+	WorkloadAllowedSourcesEnabled bool
+
+	// WorkloadEgressAllowlistEnabled makes workload egress chains consult the workload egress
+	// allowlist chain before dropping traffic that no policy or profile allowed.  The chain
+	// accepts traffic from each workload to the members of its egress allowlist IP set, which
+	// an external controller (for example, a DNS policy controller) populates.
+	WorkloadEgressAllowlistEnabled bool
+
+	// EndpointToHostActionOverridesEnabled makes the workload to host chain apply
+	// EndpointToHostAction in the workload to host action chain, which also applies workloads'
+	// overrides of it.
+	EndpointToHostActionOverridesEnabled bool
+
+	// WorkloadLinkLocalAccess, if "Allow" or "Deny", makes workload egress chains jump to the
+	// workload link-local chain before applying policy.  That chain accepts or drops traffic to
+	// WorkloadLinkLocalServices, except from the interfaces of workloads in the exception
+	// namespaces.
+	WorkloadLinkLocalAccess   string
+	WorkloadLinkLocalServices []string
+
+	// WorkloadMACEnforcementEnabled adds a jump to the workload MAC check chain, which drops
+	// frames from workloads that don't come from the workload's MAC address.
+	WorkloadMACEnforcementEnabled bool
+
+	// DropCaptureEnabled sends a copy of packets that are dropped by policy (or by the default
+	// drop at the end of a tier or profile list) to DropCaptureNFLOGGroup before dropping them.
+	DropCaptureEnabled    bool
+	DropCaptureNFLOGGroup uint16
+
+	// EgressSNATEnabled adds a jump to the egress SNAT chain, which SNATs traffic from
+	// workloads that have their own egress source address, ahead of NAT outgoing.
+	EgressSNATEnabled bool
+
+	// ExternalNetworksEnabled adds rules that match on the external network IP sets: tunnel
+	// packets from IPSetIDExternalNetsTunnel are accepted, workloads may send from
+	// IPSetIDExternalNetsRPFExempt despite the RPF check and traffic to
+	// IPSetIDExternalNetsNoMasquerade isn't NATed by NAT outgoing.
+	ExternalNetworksEnabled bool
+
+	// KubeProxyPrecedenceEnabled (BPF mode only) accepts packets that the BPF programs have
+	// already handled at the end of the NAT PREROUTING chain so that they skip a live
+	// kube-proxy's service NAT rules, which would otherwise NAT them a second time.
+	KubeProxyPrecedenceEnabled bool
+
+	// PolicyRuleCountersEnabled tags the last iptables rule rendered for each policy rule with
+	// PolicyRuleCounterComment() so that the policy's per-rule packet and byte counts can be
+	// read back from the dataplane.
+	PolicyRuleCountersEnabled bool
+}
+
+// VXLANPoolDeviceName returns the name of the VXLAN device for IP pools that have their own VNI.
+// It has to fit in the kernel's 15-character limit on interface names, even for the largest VNI.
+func VXLANPoolDeviceName(vni int) string {
+	return fmt.Sprintf("vx%d.cali", vni)
+}
+
+// ChainName returns the name of one of our chains, or the prefix of a family of our chains, given
+// its default name, such as ChainFilterInput.  It replaces ChainNamePrefix with
+// IptablesChainNamePrefix, if that's set.
+func (c *Config) ChainName(name string) string {
+	if c.IptablesChainNamePrefix == "" || !strings.HasPrefix(name, ChainNamePrefix) {
+		return name
+	}
+	return c.IptablesChainNamePrefix + name[len(ChainNamePrefix):]
+}
+
+// PolicyChainName is PolicyChainName() with the configured chain name prefix.
+func (c *Config) PolicyChainName(prefix PolicyChainNamePrefix, polID *proto.PolicyID) string {
+	return PolicyChainName(PolicyChainNamePrefix(c.ChainName(string(prefix))), polID)
+}
+
+// ProfileChainName is ProfileChainName() with the configured chain name prefix.
+func (c *Config) ProfileChainName(prefix ProfileChainNamePrefix, profID *proto.ProfileID) string {
+	return ProfileChainName(ProfileChainNamePrefix(c.ChainName(string(prefix))), profID)
+}
+
+// HashPrefix returns the prefix of the hash comment on each of our rules.
+func (c *Config) HashPrefix() string {
+	if c.IptablesRuleHashPrefix == "" {
+		return RuleHashPrefix
+	}
+	return c.IptablesRuleHashPrefix
+}
+
+// HistoricChainNamePrefixes returns the prefixes of the chains that we clean up.  With a
+// custom IptablesChainNamePrefix, we only clean up chains with that prefix, leaving those with
+// the default and historic prefixes to their owner.
+func (c *Config) HistoricChainNamePrefixes() []string {
+	if c.IptablesChainNamePrefix == "" || c.IptablesChainNamePrefix == ChainNamePrefix {
+		return AllHistoricChainNamePrefixes
+	}
+	return []string{c.IptablesChainNamePrefix}
+}
+
+// HistoricIPSetNames returns the prefixes of the IP sets that we clean up, and the legacy IPv4
+// IP set names, for the given IP set name prefix.  As for chains, with a custom prefix, we only
+// clean up IP sets with that prefix.
+func HistoricIPSetNames(ipSetPrefix string) (prefixes, legacyV4Names []string) {
+	if ipSetPrefix == IPSetNamePrefix {
+		return AllHistoricIPSetNamePrefixes, LegacyV4IPSetNames
+	}
+	return []string{ipSetPrefix}, nil
+}
+
+// maxIPSetNamePrefixLen is the longest IP set name prefix that leaves room for the IP version,
+// the main/temp token and the hashed ID within the kernel's 31-character limit on IP set names.
+// Since the IP version follows the prefix, a prefix that is this short can't overlap with the
+// default IP set names unless it is the default.
+const maxIPSetNamePrefixLen = 4
+
+// ValidateNamePrefixes checks that custom chain and rule hash prefixes don't overlap with the
+// default and historic ones.  If they did, Felix would treat another instance's chains and rules
+// as its own and clean them up.  It also checks that the IP set name prefix is short enough.
+func ValidateNamePrefixes(chainPrefix, ruleHashPrefix, ipSetPrefix string) error {
+	overlaps := func(a, b string) bool {
+		return strings.HasPrefix(a, b) || strings.HasPrefix(b, a)
+	}
+
+	if chainPrefix != ChainNamePrefix {
+		for _, historic := range AllHistoricChainNamePrefixes {
+			if overlaps(chainPrefix, historic) {
+				return fmt.Errorf("chain name prefix %q overlaps with the default chain prefix %q",
+					chainPrefix, historic)
+			}
+		}
+	}
+	if ruleHashPrefix != RuleHashPrefix && overlaps(ruleHashPrefix, RuleHashPrefix) {
+		return fmt.Errorf("rule hash prefix %q overlaps with the default rule hash prefix %q",
+			ruleHashPrefix, RuleHashPrefix)
+	}
+	if len(ipSetPrefix) > maxIPSetNamePrefixLen {
+		return fmt.Errorf("IP set name prefix %q is too long, it must be at most %d characters",
+			ipSetPrefix, maxIPSetNamePrefixLen)
+	}
+
+	numCustom := 0
+	for _, custom := range []bool{
+		chainPrefix != ChainNamePrefix,
+		ruleHashPrefix != RuleHashPrefix,
+		ipSetPrefix != IPSetNamePrefix,
+	} {
+		if custom {
+			numCustom++
+		}
+	}
+	if numCustom > 0 && numCustom < 3 {
+		log.WithFields(log.Fields{
+			"chainNamePrefix": chainPrefix,
+			"ruleHashPrefix":  ruleHashPrefix,
+			"ipSetNamePrefix": ipSetPrefix,
+		}).Warn("Only some of the name prefixes are customised, Felix will still clash with other " +
+			"instances that use the default names.")
+	}
+	return nil
+}
+
+// VXLANPoolDevices returns the names of the per-pool VXLAN devices, mapped to their VNIs.  Pools
+// that use VXLANVNI share the main VXLAN device.
+func (c *Config) VXLANPoolDevices() map[string]int {
+	devices := map[string]int{}
+	for _, vni := range c.VXLANPoolVNIs {
+		if vni != c.VXLANVNI {
+			devices[VXLANPoolDeviceName(vni)] = vni
+		}
+	}
+	return devices
 }
 
 var unusedBitsInBPFMode = map[string]bool{
@@ -348,22 +613,31 @@ func (c *Config) validate() {
 	}
 }
 
+// endpointToHostActions converts an EndpointToHostAction setting into the actions to apply to
+// workload to host packets once they've been allowed by policy.
+func endpointToHostActions(action string) []iptables.Action {
+	switch action {
+	case "DROP":
+		return []iptables.Action{iptables.DropAction{}}
+	case "ACCEPT":
+		return []iptables.Action{iptables.AcceptAction{}}
+	default:
+		return []iptables.Action{iptables.ReturnAction{}}
+	}
+}
+
 func NewRenderer(config Config) RuleRenderer {
 	log.WithField("config", config).Info("Creating rule renderer.")
 	config.validate()
 	// Convert configured actions to rule slices.
 	// First, what should we do with packets that come from workloads to the host itself.
-	var inputAcceptActions []iptables.Action
 	switch config.EndpointToHostAction {
 	case "DROP":
 		log.Info("Workload to host packets will be dropped.")
-		inputAcceptActions = []iptables.Action{iptables.DropAction{}}
 	case "ACCEPT":
 		log.Info("Workload to host packets will be accepted.")
-		inputAcceptActions = []iptables.Action{iptables.AcceptAction{}}
 	default:
 		log.Info("Workload to host packets will be returned to INPUT chain.")
-		inputAcceptActions = []iptables.Action{iptables.ReturnAction{}}
 	}
 
 	// What should we do with packets that are accepted in the forwarding chain
@@ -399,10 +673,9 @@ func NewRenderer(config Config) RuleRenderer {
 	}
 
 	return &DefaultRuleRenderer{
-		Config:             config,
-		inputAcceptActions: inputAcceptActions,
-		filterAllowAction:  filterAllowAction,
-		mangleAllowAction:  mangleAllowAction,
-		blockCIDRAction:    blockCIDRAction,
+		Config:            config,
+		filterAllowAction: filterAllowAction,
+		mangleAllowAction: mangleAllowAction,
+		blockCIDRAction:   blockCIDRAction,
 	}
 }
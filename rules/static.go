@@ -16,9 +16,11 @@ package rules
 
 import (
 	"fmt"
+	"sort"
 
 	log "github.com/sirupsen/logrus"
 
+	"github.com/projectcalico/felix/bpf/tc"
 	. "github.com/projectcalico/felix/iptables"
 	"github.com/projectcalico/felix/proto"
 	cnet "github.com/projectcalico/libcalico-go/lib/net"
@@ -99,16 +101,16 @@ func (r *DefaultRuleRenderer) StaticFilterInputForwardCheckChain(ipVersion uint8
 		fwRules = append(fwRules,
 			Rule{
 				Match: Match().Protocol("tcp").
-					DestPortRanges(portSplit).
+					DestPortRanges(iptablesPortRanges(portSplit)).
 					DestIPSet(hostIPSet),
-				Action:  GotoAction{Target: ChainDispatchSetEndPointMark},
+				Action:  GotoAction{Target: r.ChainName(ChainDispatchSetEndPointMark)},
 				Comment: []string{"To kubernetes NodePort service"},
 			},
 			Rule{
 				Match: Match().Protocol("udp").
-					DestPortRanges(portSplit).
+					DestPortRanges(iptablesPortRanges(portSplit)).
 					DestIPSet(hostIPSet),
-				Action:  GotoAction{Target: ChainDispatchSetEndPointMark},
+				Action:  GotoAction{Target: r.ChainName(ChainDispatchSetEndPointMark)},
 				Comment: []string{"To kubernetes NodePort service"},
 			},
 		)
@@ -118,13 +120,13 @@ func (r *DefaultRuleRenderer) StaticFilterInputForwardCheckChain(ipVersion uint8
 		// If packet is accessing non local host ip, it belongs to a forwarded traffic.
 		Rule{
 			Match:   Match().NotDestIPSet(hostIPSet),
-			Action:  JumpAction{Target: ChainDispatchSetEndPointMark},
+			Action:  JumpAction{Target: r.ChainName(ChainDispatchSetEndPointMark)},
 			Comment: []string{"To kubernetes service"},
 		},
 	)
 
 	return &Chain{
-		Name:  ChainForwardCheck,
+		Name:  r.ChainName(ChainForwardCheck),
 		Rules: fwRules,
 	}
 }
@@ -147,7 +149,7 @@ func (r *DefaultRuleRenderer) StaticFilterOutputForwardEndpointMarkChain() *Chai
 		// traffic.
 		Rule{
 			Match:  Match().NotMarkMatchesWithMask(r.IptablesMarkNonCaliEndpoint, r.IptablesMarkEndpoint),
-			Action: JumpAction{Target: ChainDispatchFromEndPointMark},
+			Action: JumpAction{Target: r.ChainName(ChainDispatchFromEndPointMark)},
 		},
 	)
 
@@ -159,7 +161,7 @@ func (r *DefaultRuleRenderer) StaticFilterOutputForwardEndpointMarkChain() *Chai
 		fwRules = append(fwRules,
 			Rule{
 				Match:  Match().OutInterface(ifaceMatch),
-				Action: JumpAction{Target: ChainToWorkloadDispatch},
+				Action: JumpAction{Target: r.ChainName(ChainToWorkloadDispatch)},
 			},
 		)
 	}
@@ -169,7 +171,7 @@ func (r *DefaultRuleRenderer) StaticFilterOutputForwardEndpointMarkChain() *Chai
 		// apply-on-forward dispatch chain. That chain returns any packets that are not going to a
 		// known host endpoint for further processing.
 		Rule{
-			Action: JumpAction{Target: ChainDispatchToHostEndpointForward},
+			Action: JumpAction{Target: r.ChainName(ChainDispatchToHostEndpointForward)},
 		},
 
 		// Before we ACCEPT the packet, clear the per-interface mark bit.  This is required because
@@ -199,7 +201,7 @@ func (r *DefaultRuleRenderer) StaticFilterOutputForwardEndpointMarkChain() *Chai
 	)
 
 	return &Chain{
-		Name:  ChainForwardEndpointMark,
+		Name:  r.ChainName(ChainForwardEndpointMark),
 		Rules: fwRules,
 	}
 }
@@ -219,6 +221,17 @@ func (r *DefaultRuleRenderer) filterInputChain(ipVersion uint8) *Chain {
 				Action:  r.filterAllowAction,
 				Comment: []string{"Allow IPIP packets from Calico hosts"},
 			},
+		)
+		if r.ExternalNetworksEnabled {
+			inputRules = append(inputRules, Rule{
+				Match: Match().ProtocolNum(ProtoIPIP).
+					SourceIPSet(r.IPSetConfigV4.NameForMainIPSet(IPSetIDExternalNetsTunnel)).
+					DestAddrType(AddrTypeLocal),
+				Action:  r.filterAllowAction,
+				Comment: []string{"Allow IPIP packets from external networks"},
+			})
+		}
+		inputRules = append(inputRules,
 			Rule{
 				Match:   Match().ProtocolNum(ProtoIPIP),
 				Action:  DropAction{},
@@ -239,6 +252,18 @@ func (r *DefaultRuleRenderer) filterInputChain(ipVersion uint8) *Chain {
 				Action:  r.filterAllowAction,
 				Comment: []string{"Allow VXLAN packets from whitelisted hosts"},
 			},
+		)
+		if r.ExternalNetworksEnabled {
+			inputRules = append(inputRules, Rule{
+				Match: Match().ProtocolNum(ProtoUDP).
+					DestPorts(uint16(r.Config.VXLANPort)).
+					SourceIPSet(r.IPSetConfigV4.NameForMainIPSet(IPSetIDExternalNetsTunnel)).
+					DestAddrType(AddrTypeLocal),
+				Action:  r.filterAllowAction,
+				Comment: []string{"Allow VXLAN packets from external networks"},
+			})
+		}
+		inputRules = append(inputRules,
 			Rule{
 				Match: Match().ProtocolNum(ProtoUDP).
 					DestPorts(uint16(r.Config.VXLANPort)).
@@ -260,7 +285,7 @@ func (r *DefaultRuleRenderer) filterInputChain(ipVersion uint8) *Chain {
 				Action: ClearMarkAction{Mark: r.IptablesMarkEndpoint},
 			},
 			Rule{
-				Action: JumpAction{Target: ChainForwardCheck},
+				Action: JumpAction{Target: r.ChainName(ChainForwardCheck)},
 			},
 			Rule{
 				Match:  Match().MarkNotClear(r.IptablesMarkEndpoint),
@@ -270,12 +295,14 @@ func (r *DefaultRuleRenderer) filterInputChain(ipVersion uint8) *Chain {
 	}
 
 	// Apply our policy to packets coming from workload endpoints.
+	inputRules = append(inputRules, r.cniGateJumpRules()...)
+	inputRules = append(inputRules, r.connRateLimitJumpRules()...)
 	for _, prefix := range r.WorkloadIfacePrefixes {
 		log.WithField("ifacePrefix", prefix).Debug("Adding workload match rules")
 		ifaceMatch := prefix + "+"
 		inputRules = append(inputRules, Rule{
 			Match:  Match().InInterface(ifaceMatch),
-			Action: GotoAction{Target: ChainWorkloadToHost},
+			Action: GotoAction{Target: r.ChainName(ChainWorkloadToHost)},
 		})
 	}
 
@@ -290,7 +317,7 @@ func (r *DefaultRuleRenderer) filterInputChain(ipVersion uint8) *Chain {
 			Action: ClearMarkAction{Mark: r.allCalicoMarkBits()},
 		},
 		Rule{
-			Action: JumpAction{Target: ChainDispatchFromHostEndpoint},
+			Action: JumpAction{Target: r.ChainName(ChainDispatchFromHostEndpoint)},
 		},
 		Rule{
 			Match:   Match().MarkSingleBitSet(r.IptablesMarkAccept),
@@ -300,7 +327,7 @@ func (r *DefaultRuleRenderer) filterInputChain(ipVersion uint8) *Chain {
 	)
 
 	return &Chain{
-		Name:  ChainFilterInput,
+		Name:  r.ChainName(ChainFilterInput),
 		Rules: inputRules,
 	}
 }
@@ -380,22 +407,30 @@ func (r *DefaultRuleRenderer) filterWorkloadToHostChain(ipVersion uint8) *Chain
 
 	// Now send traffic to the policy chains to apply the egress policy.
 	rules = append(rules, Rule{
-		Action: JumpAction{Target: ChainFromWorkloadDispatch},
+		Action: JumpAction{Target: r.ChainName(ChainFromWorkloadDispatch)},
 	})
 
 	// If the dispatch chain accepts the packet, it returns to us here.  Apply the configured
 	// action.  Note: we may have done work above to allow the packet and then end up dropping
 	// it here.  We can't optimize that away because there may be other rules (such as log
 	// rules in the policy).
-	for _, action := range r.inputAcceptActions {
+	if r.EndpointToHostActionOverridesEnabled {
+		// Workloads may override the action, so apply it in the (dynamic) action chain.  We
+		// goto the action chain so that a RETURN there returns to the INPUT chain.
 		rules = append(rules, Rule{
-			Action:  action,
-			Comment: []string{"Configured DefaultEndpointToHostAction"},
+			Action: GotoAction{Target: r.ChainName(ChainWorkloadToHostAction)},
 		})
+	} else {
+		for _, action := range endpointToHostActions(r.EndpointToHostAction) {
+			rules = append(rules, Rule{
+				Action:  action,
+				Comment: []string{"Configured DefaultEndpointToHostAction"},
+			})
+		}
 	}
 
 	return &Chain{
-		Name:  ChainWorkloadToHost,
+		Name:  r.ChainName(ChainWorkloadToHost),
 		Rules: rules,
 	}
 }
@@ -458,7 +493,7 @@ func (r *DefaultRuleRenderer) failsafeInChain(table string, ipVersion uint8) *Ch
 	}
 
 	return &Chain{
-		Name:  ChainFailsafeIn,
+		Name:  r.ChainName(ChainFailsafeIn),
 		Rules: rules,
 	}
 }
@@ -521,7 +556,7 @@ func (r *DefaultRuleRenderer) failsafeOutChain(table string, ipVersion uint8) *C
 	}
 
 	return &Chain{
-		Name:  ChainFailsafeOut,
+		Name:  r.ChainName(ChainFailsafeOut),
 		Rules: rules,
 	}
 }
@@ -549,22 +584,25 @@ func (r *DefaultRuleRenderer) StaticFilterForwardChains() []*Chain {
 			// Apply forward policy for the incoming Host endpoint if accept bit is clear which means the packet
 			// was not accepted in a previous raw or pre-DNAT chain.
 			Match:  Match().MarkClear(r.IptablesMarkAccept),
-			Action: JumpAction{Target: ChainDispatchFromHostEndPointForward},
+			Action: JumpAction{Target: r.ChainName(ChainDispatchFromHostEndPointForward)},
 		},
 	)
 
 	// Jump to workload dispatch chains.
+	rules = append(rules, r.cniGateJumpRules()...)
+	rules = append(rules, r.connRateLimitJumpRules()...)
+	rules = append(rules, r.workloadDrainJumpRules()...)
 	for _, prefix := range r.WorkloadIfacePrefixes {
 		log.WithField("ifacePrefix", prefix).Debug("Adding workload match rules")
 		ifaceMatch := prefix + "+"
 		rules = append(rules,
 			Rule{
 				Match:  Match().InInterface(ifaceMatch),
-				Action: JumpAction{Target: ChainFromWorkloadDispatch},
+				Action: JumpAction{Target: r.ChainName(ChainFromWorkloadDispatch)},
 			},
 			Rule{
 				Match:  Match().OutInterface(ifaceMatch),
-				Action: JumpAction{Target: ChainToWorkloadDispatch},
+				Action: JumpAction{Target: r.ChainName(ChainToWorkloadDispatch)},
 			},
 		)
 	}
@@ -573,19 +611,12 @@ func (r *DefaultRuleRenderer) StaticFilterForwardChains() []*Chain {
 	rules = append(rules,
 		Rule{
 			// Apply forward policy for the outgoing host endpoint.
-			Action: JumpAction{Target: ChainDispatchToHostEndpointForward},
-		},
-	)
-
-	// Jump to chain for blocking service CIDR loops.
-	rules = append(rules,
-		Rule{
-			Action: JumpAction{Target: ChainCIDRBlock},
+			Action: JumpAction{Target: r.ChainName(ChainDispatchToHostEndpointForward)},
 		},
 	)
 
 	return []*Chain{{
-		Name:  ChainFilterForward,
+		Name:  r.ChainName(ChainFilterForward),
 		Rules: rules,
 	}}
 }
@@ -639,11 +670,15 @@ func (r *DefaultRuleRenderer) filterOutputChain(ipVersion uint8) *Chain {
 		rules = append(rules,
 			Rule{
 				Match:  Match().MarkNotClear(r.IptablesMarkEndpoint),
-				Action: GotoAction{Target: ChainForwardEndpointMark},
+				Action: GotoAction{Target: r.ChainName(ChainForwardEndpointMark)},
 			},
 		)
 	}
 
+	// Even though we don't police host -> endpoint traffic, we do reject new connections while
+	// draining.
+	rules = append(rules, r.workloadDrainJumpRules()...)
+
 	// We don't currently police host -> endpoint according to the endpoint's ingress policy.
 	// That decision is based on pragmatism; it's generally very useful to be able to contact
 	// any local workload from the host and policing the traffic doesn't really protect
@@ -713,7 +748,7 @@ func (r *DefaultRuleRenderer) filterOutputChain(ipVersion uint8) *Chain {
 		},
 		Rule{
 			Match:  Match().NotConntrackState("DNAT"),
-			Action: JumpAction{Target: ChainDispatchToHostEndpoint},
+			Action: JumpAction{Target: r.ChainName(ChainDispatchToHostEndpoint)},
 		},
 		Rule{
 			Match:   Match().MarkSingleBitSet(r.IptablesMarkAccept),
@@ -723,7 +758,7 @@ func (r *DefaultRuleRenderer) filterOutputChain(ipVersion uint8) *Chain {
 	)
 
 	return &Chain{
-		Name:  ChainFilterOutput,
+		Name:  r.ChainName(ChainFilterOutput),
 		Rules: rules,
 	}
 }
@@ -738,7 +773,7 @@ func (r *DefaultRuleRenderer) StaticNATTableChains(ipVersion uint8) (chains []*C
 func (r *DefaultRuleRenderer) StaticNATPreroutingChains(ipVersion uint8) []*Chain {
 	rules := []Rule{
 		{
-			Action: JumpAction{Target: ChainFIPDnat},
+			Action: JumpAction{Target: r.ChainName(ChainFIPDnat)},
 		},
 	}
 
@@ -755,8 +790,18 @@ func (r *DefaultRuleRenderer) StaticNATPreroutingChains(ipVersion uint8) []*Chai
 		})
 	}
 
+	if ipVersion == 4 && r.BPFEnabled && r.KubeProxyPrecedenceEnabled {
+		// The BPF programs have already done any service NAT for packets that they've seen,
+		// don't let kube-proxy's KUBE-SERVICES chain (if it's still running) NAT them again.
+		rules = append(rules, Rule{
+			Match:   Match().MarkMatchesWithMask(tc.MarkSeen, tc.MarkSeenMask),
+			Action:  AcceptAction{},
+			Comment: []string{"BPF NAT takes precedence over kube-proxy"},
+		})
+	}
+
 	chains := []*Chain{{
-		Name:  ChainNATPrerouting,
+		Name:  r.ChainName(ChainNATPrerouting),
 		Rules: rules,
 	}}
 
@@ -766,12 +811,17 @@ func (r *DefaultRuleRenderer) StaticNATPreroutingChains(ipVersion uint8) []*Chai
 func (r *DefaultRuleRenderer) StaticNATPostroutingChains(ipVersion uint8) []*Chain {
 	rules := []Rule{
 		{
-			Action: JumpAction{Target: ChainFIPSnat},
-		},
-		{
-			Action: JumpAction{Target: ChainNATOutgoing},
+			Action: JumpAction{Target: r.ChainName(ChainFIPSnat)},
 		},
 	}
+	if ipVersion == 4 && r.EgressSNATEnabled {
+		rules = append(rules, Rule{
+			Action: JumpAction{Target: r.ChainName(ChainNATEgressSNAT)},
+		})
+	}
+	rules = append(rules, Rule{
+		Action: JumpAction{Target: r.ChainName(ChainNATOutgoing)},
+	})
 
 	var tunnelIfaces []string
 
@@ -780,6 +830,12 @@ func (r *DefaultRuleRenderer) StaticNATPostroutingChains(ipVersion uint8) []*Cha
 	}
 	if ipVersion == 4 && r.VXLANEnabled && len(r.VXLANTunnelAddress) > 0 {
 		tunnelIfaces = append(tunnelIfaces, "vxlan.calico")
+		var poolDevices []string
+		for name := range r.VXLANPoolDevices() {
+			poolDevices = append(poolDevices, name)
+		}
+		sort.Strings(poolDevices)
+		tunnelIfaces = append(tunnelIfaces, poolDevices...)
 	}
 	if ipVersion == 4 && r.WireguardEnabled && len(r.WireguardInterfaceName) > 0 {
 		// Wireguard is assigned an IP dynamically and without restarting Felix. Just add the interface if we have
@@ -821,7 +877,7 @@ func (r *DefaultRuleRenderer) StaticNATPostroutingChains(ipVersion uint8) []*Cha
 		})
 	}
 	return []*Chain{{
-		Name:  ChainNATPostrouting,
+		Name:  r.ChainName(ChainNATPostrouting),
 		Rules: rules,
 	}}
 }
@@ -829,12 +885,12 @@ func (r *DefaultRuleRenderer) StaticNATPostroutingChains(ipVersion uint8) []*Cha
 func (r *DefaultRuleRenderer) StaticNATOutputChains(ipVersion uint8) []*Chain {
 	rules := []Rule{
 		{
-			Action: JumpAction{Target: ChainFIPDnat},
+			Action: JumpAction{Target: r.ChainName(ChainFIPDnat)},
 		},
 	}
 
 	return []*Chain{{
-		Name:  ChainNATOutput,
+		Name:  r.ChainName(ChainNATOutput),
 		Rules: rules,
 	}}
 }
@@ -882,7 +938,7 @@ func (r *DefaultRuleRenderer) StaticManglePreroutingChain(ipVersion uint8) *Chai
 	// Now dispatch to host endpoint chain for the incoming interface.
 	rules = append(rules,
 		Rule{
-			Action: JumpAction{Target: ChainDispatchFromHostEndpoint},
+			Action: JumpAction{Target: r.ChainName(ChainDispatchFromHostEndpoint)},
 		},
 		// Following that...  If the packet was explicitly allowed by a pre-DNAT policy, it
 		// will have MarkAccept set.  If the packet was denied, it will have been dropped
@@ -900,7 +956,7 @@ func (r *DefaultRuleRenderer) StaticManglePreroutingChain(ipVersion uint8) *Chai
 	)
 
 	return &Chain{
-		Name:  ChainManglePrerouting,
+		Name:  r.ChainName(ChainManglePrerouting),
 		Rules: rules,
 	}
 }
@@ -961,7 +1017,7 @@ func (r *DefaultRuleRenderer) StaticManglePostroutingChain(ipVersion uint8) *Cha
 		},
 		Rule{
 			Match:  Match().ConntrackState("DNAT"),
-			Action: JumpAction{Target: ChainDispatchToHostEndpoint},
+			Action: JumpAction{Target: r.ChainName(ChainDispatchToHostEndpoint)},
 		},
 		Rule{
 			Match:   Match().MarkSingleBitSet(r.IptablesMarkAccept),
@@ -971,7 +1027,7 @@ func (r *DefaultRuleRenderer) StaticManglePostroutingChain(ipVersion uint8) *Cha
 	)
 
 	return &Chain{
-		Name:  ChainManglePostrouting,
+		Name:  r.ChainName(ChainManglePostrouting),
 		Rules: rules,
 	}
 }
@@ -1000,7 +1056,7 @@ func (r *DefaultRuleRenderer) StaticRawPreroutingChain(ipVersion uint8) *Chain {
 		log.Debug("Adding Wireguard iptables rule")
 		rules = append(rules, Rule{
 			Match:  nil,
-			Action: JumpAction{Target: ChainSetWireguardIncomingMark},
+			Action: JumpAction{Target: r.ChainName(ChainSetWireguardIncomingMark)},
 		})
 	}
 
@@ -1013,17 +1069,49 @@ func (r *DefaultRuleRenderer) StaticRawPreroutingChain(ipVersion uint8) *Chain {
 		})
 	}
 
+	// If enabled, drop frames from workloads that don't come from their workload's MAC address.
+	// This prevents privileged workloads from spoofing other workloads' (or the host's) MACs.
+	if r.WorkloadMACEnforcementEnabled {
+		rules = append(rules, Rule{
+			Match:  Match().MarkSingleBitSet(markFromWorkload),
+			Action: JumpAction{Target: r.ChainName(ChainWorkloadMACCheck)},
+		})
+	}
+
 	// Apply strict RPF check to packets from workload interfaces.  This prevents
 	// workloads from spoofing their IPs.  Note: non-privileged containers can't
 	// usually spoof but privileged containers and VMs can.
 	//
+	// If enabled, the allowed sources chain first marks packets that come from one of their
+	// workload's additional allowed source prefixes, and packets from the RPF-exempt external
+	// networks are marked in the same way, which exempts them from the check.
+	rpfMask := markFromWorkload
+	markAllowedSource := r.IptablesMarkScratch1
+	if r.WorkloadAllowedSourcesEnabled {
+		rules = append(rules, Rule{
+			Match:  Match().MarkSingleBitSet(markFromWorkload),
+			Action: JumpAction{Target: r.ChainName(ChainWorkloadAllowedSources)},
+		})
+		rpfMask |= markAllowedSource
+	}
+	if r.ExternalNetworksEnabled {
+		rules = append(rules, Rule{
+			Match: Match().MarkSingleBitSet(markFromWorkload).
+				SourceIPSet(r.ipSetConfig(ipVersion).NameForMainIPSet(IPSetIDExternalNetsRPFExempt)),
+			Action: SetMarkAction{Mark: markAllowedSource},
+		})
+		rpfMask |= markAllowedSource
+	}
 	rules = append(rules,
-		RPFilter(ipVersion, markFromWorkload, markFromWorkload, r.OpenStackSpecialCasesEnabled, false)...)
+		RPFilter(ipVersion, markFromWorkload, rpfMask, r.OpenStackSpecialCasesEnabled, false)...)
+	if rpfMask&markAllowedSource != 0 {
+		rules = append(rules, Rule{Action: ClearMarkAction{Mark: markAllowedSource}})
+	}
 
 	rules = append(rules,
 		// Send non-workload traffic to the untracked policy chains.
 		Rule{Match: Match().MarkClear(markFromWorkload),
-			Action: JumpAction{Target: ChainDispatchFromHostEndpoint}},
+			Action: JumpAction{Target: r.ChainName(ChainDispatchFromHostEndpoint)}},
 		// Then, if the packet was marked as allowed, accept it.  Packets also return here
 		// without the mark bit set if the interface wasn't one that we're policing.  We
 		// let those packets fall through to the user's policy.
@@ -1031,8 +1119,22 @@ func (r *DefaultRuleRenderer) StaticRawPreroutingChain(ipVersion uint8) *Chain {
 			Action: AcceptAction{}},
 	)
 
+	if r.WorkloadUntrackedPolicyEnabled {
+		// Apply untracked policy for workloads.  Unlike host endpoints, we don't accept the
+		// packet here; the policy chains only mark it as NOTRACK and the workload chains in
+		// the filter table re-check the untracked policy before applying the normal policy.
+		rules = append(rules,
+			Rule{Match: Match().MarkSingleBitSet(markFromWorkload),
+				Action: JumpAction{Target: r.ChainName(ChainFromWorkloadDispatch)}},
+			Rule{Action: JumpAction{Target: r.ChainName(ChainToWorkloadDispatch)}},
+			// Don't leak the accept bit into the filter table, where it would be taken
+			// to mean that host endpoint policy accepted the packet.
+			Rule{Action: ClearMarkAction{Mark: r.IptablesMarkAccept}},
+		)
+	}
+
 	return &Chain{
-		Name:  ChainRawPrerouting,
+		Name:  r.ChainName(ChainRawPrerouting),
 		Rules: rules,
 	}
 }
@@ -1088,6 +1190,302 @@ func (r *DefaultRuleRenderer) allCalicoMarkBits() uint32 {
 		r.IptablesMarkScratch1
 }
 
+// cniGateJumpRules returns the rules that send traffic from workloads to the CNI gate chain, if
+// the gate is enabled.
+func (r *DefaultRuleRenderer) cniGateJumpRules() []Rule {
+	if !r.CNIReadinessGateEnabled {
+		return nil
+	}
+	var rules []Rule
+	for _, prefix := range r.WorkloadIfacePrefixes {
+		rules = append(rules, Rule{
+			Match:  Match().InInterface(prefix + "+"),
+			Action: JumpAction{Target: r.ChainName(ChainCNIGate)},
+		})
+	}
+	return rules
+}
+
+// CNIGateChain returns the chain that blocks new connections from workloads while the CNI plugin
+// isn't ready.  Once the gate is open the chain is empty.
+func (r *DefaultRuleRenderer) CNIGateChain(open bool) *Chain {
+	var rules []Rule
+	if !open {
+		rules = append(rules, Rule{
+			Match:   Match().ConntrackState("NEW"),
+			Action:  DropAction{},
+			Comment: []string{"Drop new workload connections until CNI plugin is ready"},
+		})
+	}
+	return &Chain{
+		Name:  r.ChainName(ChainCNIGate),
+		Rules: rules,
+	}
+}
+
+// workloadDrainJumpRules returns the rules that send traffic to workloads to the workload drain
+// chain, if drain support is enabled.
+func (r *DefaultRuleRenderer) workloadDrainJumpRules() []Rule {
+	if !r.WorkloadDrainSupportEnabled {
+		return nil
+	}
+	var rules []Rule
+	for _, prefix := range r.WorkloadIfacePrefixes {
+		rules = append(rules, Rule{
+			Match:  Match().OutInterface(prefix + "+"),
+			Action: JumpAction{Target: r.ChainName(ChainWorkloadDrain)},
+		})
+	}
+	return rules
+}
+
+// WorkloadDrainChain returns the chain that rejects new connections to workloads while the node
+// is being drained.  Established connections are unaffected.  When not draining the chain is
+// empty.
+func (r *DefaultRuleRenderer) WorkloadDrainChain(draining bool) *Chain {
+	var rules []Rule
+	if draining {
+		rules = append(rules, Rule{
+			Match:   Match().ConntrackState("NEW"),
+			Action:  RejectAction{},
+			Comment: []string{"Reject new workload connections while draining"},
+		})
+	}
+	return &Chain{
+		Name:  r.ChainName(ChainWorkloadDrain),
+		Rules: rules,
+	}
+}
+
+// connRateLimitJumpRules returns the rules that send traffic from workloads to the connection
+// rate limit chain, if rate limiting is enabled.
+func (r *DefaultRuleRenderer) connRateLimitJumpRules() []Rule {
+	if !r.WorkloadConnRateLimitEnabled {
+		return nil
+	}
+	var rules []Rule
+	for _, prefix := range r.WorkloadIfacePrefixes {
+		rules = append(rules, Rule{
+			Match:  Match().InInterface(prefix + "+"),
+			Action: JumpAction{Target: r.ChainName(ChainWorkloadConnRateLimit)},
+		})
+	}
+	return rules
+}
+
+// WorkloadConnRateLimit is the new connection rate limit for a workload interface.
+type WorkloadConnRateLimit struct {
+	IfaceName  string
+	RatePerSec int
+	Burst      int
+}
+
+// WorkloadConnRateLimitComment returns the comment on the rule that drops new connections from
+// the given workload interface once it exceeds its rate limit.  The comment allows the rule's
+// counters to be attributed to the workload.
+func WorkloadConnRateLimitComment(ifaceName string) string {
+	return "Rate limit new connections from " + ifaceName
+}
+
+// WorkloadConnRateLimitChain returns the chain that drops new connections from workloads once they
+// exceed their rate limit.  Each workload interface has its own bucket, named after the interface.
+func (r *DefaultRuleRenderer) WorkloadConnRateLimitChain(limits []WorkloadConnRateLimit) *Chain {
+	sorted := make([]WorkloadConnRateLimit, len(limits))
+	copy(sorted, limits)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].IfaceName < sorted[j].IfaceName
+	})
+	var rules []Rule
+	for _, l := range sorted {
+		rules = append(rules, Rule{
+			Match: Match().InInterface(l.IfaceName).
+				ConntrackState("NEW").
+				HashLimitAbove(l.IfaceName, l.RatePerSec, l.Burst),
+			Action:  DropAction{},
+			Comment: []string{WorkloadConnRateLimitComment(l.IfaceName)},
+		})
+	}
+	return &Chain{
+		Name:  r.ChainName(ChainWorkloadConnRateLimit),
+		Rules: rules,
+	}
+}
+
+// WorkloadAllowedSources holds the additional source prefixes that a workload interface is
+// allowed to send from, on top of the workload's own IPs.
+type WorkloadAllowedSources struct {
+	IfaceName string
+	CIDRs     []string
+}
+
+// WorkloadAllowedSourcesChain returns the raw chain that marks packets from workloads that come from
+// one of the workload's additional allowed source prefixes, which exempts them from the workload
+// RPF check.  The CIDRs should all be of the IP version of the table that the chain is for.
+func (r *DefaultRuleRenderer) WorkloadAllowedSourcesChain(allowed []WorkloadAllowedSources) *Chain {
+	sorted := make([]WorkloadAllowedSources, len(allowed))
+	copy(sorted, allowed)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].IfaceName < sorted[j].IfaceName
+	})
+	var rules []Rule
+	for _, a := range sorted {
+		for _, cidr := range a.CIDRs {
+			rules = append(rules, Rule{
+				Match:  Match().InInterface(a.IfaceName).SourceNet(cidr),
+				Action: SetMarkAction{Mark: r.IptablesMarkScratch1},
+			})
+		}
+	}
+	return &Chain{
+		Name:  r.ChainName(ChainWorkloadAllowedSources),
+		Rules: rules,
+	}
+}
+
+// EgressAllowlistIPSetID returns the ID of the egress allowlist IP set of the given workload
+// interface.  Felix creates the IP set, with a size limit from config, while the workload exists;
+// integrations add the destinations that the workload may send to.  The IP set's name is
+// NameForMainIPSet(ID), for example "cali40ea:cali1234567890a" for IPv4.
+func EgressAllowlistIPSetID(ifaceName string) string {
+	return IPSetIDEgressAllowlistPrefix + ifaceName
+}
+
+// WorkloadEgressAllowlistChain returns the filter chain that accepts traffic from each of the
+// given workload interfaces to the members of the interface's egress allowlist IP set.  The
+// workload egress chains jump to it just before they would drop traffic that no policy or
+// profile allowed, so the allowlist can't override explicit deny rules.
+func (r *DefaultRuleRenderer) WorkloadEgressAllowlistChain(ipVersion uint8, ifaceNames []string) *Chain {
+	sorted := make([]string, len(ifaceNames))
+	copy(sorted, ifaceNames)
+	sort.Strings(sorted)
+	var rules []Rule
+	for _, ifaceName := range sorted {
+		setName := r.ipSetConfig(ipVersion).NameForMainIPSet(EgressAllowlistIPSetID(ifaceName))
+		rules = append(rules, Rule{
+			Match:  Match().InInterface(ifaceName).DestIPSet(setName),
+			Action: SetMarkAction{Mark: r.IptablesMarkAccept},
+		})
+	}
+	return &Chain{
+		Name:  r.ChainName(ChainWorkloadEgressAllowlist),
+		Rules: rules,
+	}
+}
+
+// WorkloadLinkLocalChain returns the filter chain that applies WorkloadLinkLocalAccess to traffic
+// from workloads to the configured link-local services: it sets the accept mark on the traffic
+// ("Allow") or drops it ("Deny").  Traffic from the given interfaces, which belong to workloads in
+// the exception namespaces, returns straight away so that it is left to policy.  The link-local
+// services are all IPv4 so the IPv6 chain is empty.
+func (r *DefaultRuleRenderer) WorkloadLinkLocalChain(ipVersion uint8, exceptIfaceNames []string) *Chain {
+	if ipVersion != 4 || len(r.WorkloadLinkLocalServices) == 0 {
+		return &Chain{Name: r.ChainName(ChainWorkloadLinkLocal)}
+	}
+	sorted := make([]string, len(exceptIfaceNames))
+	copy(sorted, exceptIfaceNames)
+	sort.Strings(sorted)
+	var rules []Rule
+	for _, ifaceName := range sorted {
+		rules = append(rules, Rule{
+			Match:  Match().InInterface(ifaceName),
+			Action: ReturnAction{},
+		})
+	}
+	for _, cidr := range r.WorkloadLinkLocalServices {
+		if r.WorkloadLinkLocalAccess == "Deny" {
+			rules = append(rules, Rule{
+				Match:   Match().DestNet(cidr),
+				Action:  DropAction{},
+				Comment: []string{"Drop traffic to link-local service"},
+			})
+		} else {
+			rules = append(rules, Rule{
+				Match:   Match().DestNet(cidr),
+				Action:  SetMarkAction{Mark: r.IptablesMarkAccept},
+				Comment: []string{"Allow traffic to link-local service"},
+			})
+		}
+	}
+	return &Chain{
+		Name:  r.ChainName(ChainWorkloadLinkLocal),
+		Rules: rules,
+	}
+}
+
+// WorkloadMAC holds the MAC address that frames from a workload interface must come from.
+type WorkloadMAC struct {
+	IfaceName string
+	MAC       string
+}
+
+// WorkloadMACCheckChain returns the raw chain that drops frames from workload interfaces that don't
+// come from the MAC address of the interface's workload.  Interfaces that aren't listed aren't
+// checked.
+func (r *DefaultRuleRenderer) WorkloadMACCheckChain(macs []WorkloadMAC) *Chain {
+	sorted := make([]WorkloadMAC, len(macs))
+	copy(sorted, macs)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].IfaceName < sorted[j].IfaceName
+	})
+	var rules []Rule
+	for _, m := range sorted {
+		rules = append(rules, Rule{
+			Match:   Match().InInterface(m.IfaceName).NotSourceMAC(m.MAC),
+			Action:  DropAction{},
+			Comment: []string{"Drop frames with spoofed MAC"},
+		})
+	}
+	return &Chain{
+		Name:  r.ChainName(ChainWorkloadMACCheck),
+		Rules: rules,
+	}
+}
+
+// WorkloadToHostAction holds a workload interface's override of DefaultEndpointToHostAction.
+type WorkloadToHostAction struct {
+	IfaceName string
+	Action    string
+}
+
+// WorkloadToHostActionChain returns the filter chain that applies DefaultEndpointToHostAction to
+// workload to host packets that have been allowed by policy, after applying any per-interface
+// overrides.
+func (r *DefaultRuleRenderer) WorkloadToHostActionChain(overrides []WorkloadToHostAction) *Chain {
+	sorted := make([]WorkloadToHostAction, len(overrides))
+	copy(sorted, overrides)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].IfaceName < sorted[j].IfaceName
+	})
+	actions := func(action string) []Action {
+		if r.BPFEnabled && action == "DROP" {
+			// DROP is compiled into the BPF programs; packets that they let through (such
+			// as responses to the host's own connections) mustn't be dropped here.
+			action = "RETURN"
+		}
+		return endpointToHostActions(action)
+	}
+	var rules []Rule
+	for _, o := range sorted {
+		for _, action := range actions(o.Action) {
+			rules = append(rules, Rule{
+				Match:   Match().InInterface(o.IfaceName),
+				Action:  action,
+				Comment: []string{"Workload's EndpointToHostAction override"},
+			})
+		}
+	}
+	for _, action := range actions(r.EndpointToHostAction) {
+		rules = append(rules, Rule{
+			Action:  action,
+			Comment: []string{"Configured DefaultEndpointToHostAction"},
+		})
+	}
+	return &Chain{
+		Name:  r.ChainName(ChainWorkloadToHostAction),
+		Rules: rules,
+	}
+}
+
 func (r *DefaultRuleRenderer) WireguardIncomingMarkChain() *Chain {
 	rules := []Rule{
 		{
@@ -1110,25 +1508,36 @@ func (r *DefaultRuleRenderer) WireguardIncomingMarkChain() *Chain {
 	rules = append(rules, Rule{Match: nil, Action: SetMarkAction{Mark: r.WireguardIptablesMark}})
 
 	return &Chain{
-		Name:  ChainSetWireguardIncomingMark,
+		Name:  r.ChainName(ChainSetWireguardIncomingMark),
 		Rules: rules,
 	}
 }
 
 func (r *DefaultRuleRenderer) StaticRawOutputChain() *Chain {
+	rules := []Rule{
+		// For safety, clear all our mark bits before we start.  (We could be in
+		// append mode and another process' rules could have left the mark bit set.)
+		{Action: ClearMarkAction{Mark: r.allCalicoMarkBits()}},
+		// Then, jump to the untracked policy chains.
+		{Action: JumpAction{Target: r.ChainName(ChainDispatchToHostEndpoint)}},
+		// Then, if the packet was marked as allowed, accept it.  Packets also
+		// return here without the mark bit set if the interface wasn't one that
+		// we're policing.
+		{Match: Match().MarkSingleBitSet(r.IptablesMarkAccept),
+			Action: AcceptAction{}},
+	}
+
+	if r.WorkloadUntrackedPolicyEnabled {
+		// Apply the untracked ingress policy of local workloads to host-originated traffic,
+		// as in the PREROUTING chain.
+		rules = append(rules,
+			Rule{Action: JumpAction{Target: r.ChainName(ChainToWorkloadDispatch)}},
+			Rule{Action: ClearMarkAction{Mark: r.IptablesMarkAccept}},
+		)
+	}
+
 	return &Chain{
-		Name: ChainRawOutput,
-		Rules: []Rule{
-			// For safety, clear all our mark bits before we start.  (We could be in
-			// append mode and another process' rules could have left the mark bit set.)
-			{Action: ClearMarkAction{Mark: r.allCalicoMarkBits()}},
-			// Then, jump to the untracked policy chains.
-			{Action: JumpAction{Target: ChainDispatchToHostEndpoint}},
-			// Then, if the packet was marked as allowed, accept it.  Packets also
-			// return here without the mark bit set if the interface wasn't one that
-			// we're policing.
-			{Match: Match().MarkSingleBitSet(r.IptablesMarkAccept),
-				Action: AcceptAction{}},
-		},
+		Name:  r.ChainName(ChainRawOutput),
+		Rules: rules,
 	}
 }
@@ -24,10 +24,11 @@ import (
 	. "github.com/onsi/gomega"
 
 	"github.com/projectcalico/api/pkg/lib/numorstring"
+	"github.com/projectcalico/felix/bpf/tc"
 	"github.com/projectcalico/felix/config"
+	"github.com/projectcalico/felix/ip"
 	"github.com/projectcalico/felix/ipsets"
 	. "github.com/projectcalico/felix/iptables"
-	"github.com/projectcalico/felix/proto"
 )
 
 var _ = Describe("Static", func() {
@@ -144,14 +145,54 @@ var _ = Describe("Static", func() {
 				})
 			})
 
+			Context("with workload untracked policy enabled", func() {
+				BeforeEach(func() {
+					conf.WorkloadUntrackedPolicyEnabled = true
+				})
+
+				It("IPv4: Should return expected raw PREROUTING chain", func() {
+					Expect(findChain(rr.StaticRawTableChains(4), "cali-PREROUTING")).To(Equal(&Chain{
+						Name: "cali-PREROUTING",
+						Rules: []Rule{
+							{Action: ClearMarkAction{Mark: 0xf0}},
+							{Match: Match().InInterface("cali+"),
+								Action: SetMarkAction{Mark: 0x40}},
+							{Match: Match().MarkSingleBitSet(0x40).RPFCheckFailed(false),
+								Action: DropAction{}},
+							{Match: Match().MarkClear(0x40),
+								Action: JumpAction{Target: ChainDispatchFromHostEndpoint}},
+							{Match: Match().MarkSingleBitSet(0x10),
+								Action: AcceptAction{}},
+							{Match: Match().MarkSingleBitSet(0x40),
+								Action: JumpAction{Target: ChainFromWorkloadDispatch}},
+							{Action: JumpAction{Target: ChainToWorkloadDispatch}},
+							{Action: ClearMarkAction{Mark: 0x10}},
+						},
+					}))
+				})
+
+				It("IPv4: Should return expected raw OUTPUT chain", func() {
+					Expect(findChain(rr.StaticRawTableChains(4), "cali-OUTPUT")).To(Equal(&Chain{
+						Name: "cali-OUTPUT",
+						Rules: []Rule{
+							{Action: ClearMarkAction{Mark: 0xf0}},
+							{Action: JumpAction{Target: ChainDispatchToHostEndpoint}},
+							{Match: Match().MarkSingleBitSet(0x10),
+								Action: AcceptAction{}},
+							{Action: JumpAction{Target: ChainToWorkloadDispatch}},
+							{Action: ClearMarkAction{Mark: 0x10}},
+						},
+					}))
+				})
+			})
 			for _, ipVersion := range []uint8{4, 6} {
 				Describe(fmt.Sprintf("IPv%d", ipVersion), func() {
 					// Capture current value of ipVersion.
 					ipVersion := ipVersion
 					ipSetThisHost := fmt.Sprintf("cali%d0this-host", ipVersion)
 
-					var portRanges []*proto.PortRange
-					portRange := &proto.PortRange{
+					var portRanges []PortRange
+					portRange := PortRange{
 						First: 30030,
 						Last:  30040,
 					}
@@ -302,7 +343,6 @@ var _ = Describe("Static", func() {
 									Action: JumpAction{Target: ChainToWorkloadDispatch}},
 								// Outgoing host endpoint chains.
 								{Action: JumpAction{Target: ChainDispatchToHostEndpointForward}},
-								{Action: JumpAction{Target: ChainCIDRBlock}},
 							},
 						}))
 					})
@@ -980,6 +1020,28 @@ var _ = Describe("Static", func() {
 							},
 						}))
 					})
+
+					Describe("and per-pool VNIs", func() {
+						BeforeEach(func() {
+							conf.VXLANVNI = 4096
+							conf.VXLANPoolVNIs = map[ip.V4CIDR]int{
+								ip.MustParseCIDROrIP("10.1.0.0/16").(ip.V4CIDR): 4097,
+								ip.MustParseCIDROrIP("10.2.0.0/16").(ip.V4CIDR): 4096,
+							}
+						})
+
+						It("IPv4: Should masquerade host traffic down the per-pool VXLAN devices too", func() {
+							rules := rr.StaticNATPostroutingChains(4)[0].Rules
+							Expect(rules).To(HaveLen(5))
+							Expect(rules[4]).To(Equal(Rule{
+								Match: Match().
+									OutInterface("vx4097.cali").
+									NotSrcAddrType(AddrTypeLocal, true).
+									SrcAddrType(AddrTypeLocal, false),
+								Action: MasqAction{},
+							}))
+						})
+					})
 				})
 			})
 
@@ -1028,7 +1090,7 @@ var _ = Describe("Static", func() {
 			ipVersion := ipVersion
 			ipSetThisHost := fmt.Sprintf("cali%d0this-host", ipVersion)
 
-			portRanges1 := []*proto.PortRange{
+			portRanges1 := []PortRange{
 				{First: 30030, Last: 30040},
 				{First: 30130, Last: 30140},
 				{First: 30230, Last: 30240},
@@ -1038,7 +1100,7 @@ var _ = Describe("Static", func() {
 				{First: 30630, Last: 30640},
 			}
 
-			portRanges2 := []*proto.PortRange{
+			portRanges2 := []PortRange{
 				{First: 30730, Last: 30740},
 				{First: 30830, Last: 30840},
 			}
@@ -1301,7 +1363,6 @@ var _ = Describe("Static", func() {
 							Action: JumpAction{Target: ChainToWorkloadDispatch}},
 						// Outgoing host endpoint chains.
 						{Action: JumpAction{Target: ChainDispatchToHostEndpointForward}},
-						{Action: JumpAction{Target: ChainCIDRBlock}},
 					},
 				}))
 			})
@@ -1412,6 +1473,561 @@ var _ = Describe("Static", func() {
 			}))
 		})
 	})
+
+	Describe("with the CNI readiness gate enabled", func() {
+		BeforeEach(func() {
+			conf = Config{
+				WorkloadIfacePrefixes:       []string{"cali", "tap"},
+				IPSetConfigV4:               ipsets.NewIPVersionConfig(ipsets.IPFamilyV4, "cali", nil, nil),
+				IPSetConfigV6:               ipsets.NewIPVersionConfig(ipsets.IPFamilyV6, "cali", nil, nil),
+				IptablesMarkAccept:          0x10,
+				IptablesMarkPass:            0x20,
+				IptablesMarkScratch0:        0x40,
+				IptablesMarkScratch1:        0x80,
+				IptablesMarkEndpoint:        0xff00,
+				IptablesMarkNonCaliEndpoint: 0x100,
+				CNIReadinessGateEnabled:     true,
+			}
+		})
+
+		gateJumps := []Rule{
+			{Match: Match().InInterface("cali+"), Action: JumpAction{Target: "cali-cni-gate"}},
+			{Match: Match().InInterface("tap+"), Action: JumpAction{Target: "cali-cni-gate"}},
+		}
+
+		It("should jump to the gate chain before the workload dispatch chains", func() {
+			fwd := findChain(rr.StaticFilterTableChains(4), "cali-FORWARD")
+			Expect(fwd.Rules[2:4]).To(Equal(gateJumps))
+			Expect(fwd.Rules[4].Action).To(Equal(JumpAction{Target: "cali-from-wl-dispatch"}))
+
+			input := findChain(rr.StaticFilterTableChains(4), "cali-INPUT")
+			Expect(input.Rules[0:2]).To(Equal(gateJumps))
+			Expect(input.Rules[2].Action).To(Equal(GotoAction{Target: "cali-wl-to-host"}))
+		})
+
+		It("should render the gate chain", func() {
+			Expect(rr.CNIGateChain(false)).To(Equal(&Chain{
+				Name: "cali-cni-gate",
+				Rules: []Rule{{
+					Match:   Match().ConntrackState("NEW"),
+					Action:  DropAction{},
+					Comment: []string{"Drop new workload connections until CNI plugin is ready"},
+				}},
+			}))
+			Expect(rr.CNIGateChain(true)).To(Equal(&Chain{Name: "cali-cni-gate"}))
+		})
+	})
+
+	Describe("with workload drain support enabled", func() {
+		BeforeEach(func() {
+			conf = Config{
+				WorkloadIfacePrefixes:       []string{"cali", "tap"},
+				IPSetConfigV4:               ipsets.NewIPVersionConfig(ipsets.IPFamilyV4, "cali", nil, nil),
+				IPSetConfigV6:               ipsets.NewIPVersionConfig(ipsets.IPFamilyV6, "cali", nil, nil),
+				IptablesMarkAccept:          0x10,
+				IptablesMarkPass:            0x20,
+				IptablesMarkScratch0:        0x40,
+				IptablesMarkScratch1:        0x80,
+				IptablesMarkEndpoint:        0xff00,
+				IptablesMarkNonCaliEndpoint: 0x100,
+				WorkloadDrainSupportEnabled: true,
+			}
+		})
+
+		drainJumps := []Rule{
+			{Match: Match().OutInterface("cali+"), Action: JumpAction{Target: "cali-wl-drain"}},
+			{Match: Match().OutInterface("tap+"), Action: JumpAction{Target: "cali-wl-drain"}},
+		}
+
+		It("should jump to the drain chain before the workload dispatch chains", func() {
+			fwd := findChain(rr.StaticFilterTableChains(4), "cali-FORWARD")
+			Expect(fwd.Rules[2:4]).To(Equal(drainJumps))
+			Expect(fwd.Rules[4].Action).To(Equal(JumpAction{Target: "cali-from-wl-dispatch"}))
+		})
+
+		It("should jump to the drain chain for host to workload traffic", func() {
+			output := findChain(rr.StaticFilterTableChains(4), "cali-OUTPUT")
+			Expect(output.Rules[1:3]).To(Equal(drainJumps))
+			Expect(output.Rules[3]).To(Equal(Rule{Match: Match().OutInterface("cali+"), Action: ReturnAction{}}))
+		})
+
+		It("should render the drain chain", func() {
+			Expect(rr.WorkloadDrainChain(true)).To(Equal(&Chain{
+				Name: "cali-wl-drain",
+				Rules: []Rule{{
+					Match:   Match().ConntrackState("NEW"),
+					Action:  RejectAction{},
+					Comment: []string{"Reject new workload connections while draining"},
+				}},
+			}))
+			Expect(rr.WorkloadDrainChain(false)).To(Equal(&Chain{Name: "cali-wl-drain"}))
+		})
+	})
+
+	Describe("with workload connection rate limiting enabled", func() {
+		BeforeEach(func() {
+			conf = Config{
+				WorkloadIfacePrefixes:        []string{"cali", "tap"},
+				IPSetConfigV4:                ipsets.NewIPVersionConfig(ipsets.IPFamilyV4, "cali", nil, nil),
+				IPSetConfigV6:                ipsets.NewIPVersionConfig(ipsets.IPFamilyV6, "cali", nil, nil),
+				IptablesMarkAccept:           0x10,
+				IptablesMarkPass:             0x20,
+				IptablesMarkScratch0:         0x40,
+				IptablesMarkScratch1:         0x80,
+				IptablesMarkEndpoint:         0xff00,
+				IptablesMarkNonCaliEndpoint:  0x100,
+				WorkloadConnRateLimitEnabled: true,
+			}
+		})
+
+		limitJumps := []Rule{
+			{Match: Match().InInterface("cali+"), Action: JumpAction{Target: "cali-wl-conn-rate-limit"}},
+			{Match: Match().InInterface("tap+"), Action: JumpAction{Target: "cali-wl-conn-rate-limit"}},
+		}
+
+		It("should jump to the rate limit chain before the workload dispatch chains", func() {
+			fwd := findChain(rr.StaticFilterTableChains(4), "cali-FORWARD")
+			Expect(fwd.Rules[2:4]).To(Equal(limitJumps))
+			Expect(fwd.Rules[4].Action).To(Equal(JumpAction{Target: "cali-from-wl-dispatch"}))
+
+			input := findChain(rr.StaticFilterTableChains(4), "cali-INPUT")
+			Expect(input.Rules[0:2]).To(Equal(limitJumps))
+			Expect(input.Rules[2].Action).To(Equal(GotoAction{Target: "cali-wl-to-host"}))
+		})
+
+		It("should render the rate limit chain in interface order", func() {
+			Expect(rr.WorkloadConnRateLimitChain([]WorkloadConnRateLimit{
+				{IfaceName: "cali5678", RatePerSec: 5, Burst: 10},
+				{IfaceName: "cali1234", RatePerSec: 100, Burst: 50},
+			})).To(Equal(&Chain{
+				Name: "cali-wl-conn-rate-limit",
+				Rules: []Rule{
+					{
+						Match: Match().InInterface("cali1234").ConntrackState("NEW").
+							HashLimitAbove("cali1234", 100, 50),
+						Action:  DropAction{},
+						Comment: []string{"Rate limit new connections from cali1234"},
+					},
+					{
+						Match: Match().InInterface("cali5678").ConntrackState("NEW").
+							HashLimitAbove("cali5678", 5, 10),
+						Action:  DropAction{},
+						Comment: []string{"Rate limit new connections from cali5678"},
+					},
+				},
+			}))
+			Expect(rr.WorkloadConnRateLimitChain(nil)).To(Equal(&Chain{Name: "cali-wl-conn-rate-limit"}))
+		})
+	})
+
+	Describe("with workload allowed sources enabled", func() {
+		BeforeEach(func() {
+			conf = Config{
+				WorkloadIfacePrefixes:         []string{"cali"},
+				IPSetConfigV4:                 ipsets.NewIPVersionConfig(ipsets.IPFamilyV4, "cali", nil, nil),
+				IPSetConfigV6:                 ipsets.NewIPVersionConfig(ipsets.IPFamilyV6, "cali", nil, nil),
+				IptablesMarkAccept:            0x10,
+				IptablesMarkPass:              0x20,
+				IptablesMarkScratch0:          0x40,
+				IptablesMarkScratch1:          0x80,
+				IptablesMarkEndpoint:          0xff00,
+				IptablesMarkNonCaliEndpoint:   0x100,
+				WorkloadAllowedSourcesEnabled: true,
+			}
+		})
+
+		for _, ipVersion := range []uint8{4, 6} {
+			ipVersion := ipVersion
+			It(fmt.Sprintf("IPv%d: should exempt allowed sources from the RPF check", ipVersion), func() {
+				Expect(findChain(rr.StaticRawTableChains(ipVersion), "cali-PREROUTING")).To(Equal(&Chain{
+					Name: "cali-PREROUTING",
+					Rules: []Rule{
+						{Match: nil,
+							Action: ClearMarkAction{Mark: 0xf0}},
+						{Match: Match().InInterface("cali+"),
+							Action: SetMarkAction{Mark: 0x40}},
+						{Match: Match().MarkMatchesWithMask(0x40, 0x40),
+							Action: JumpAction{Target: "cali-wl-allowed-src"}},
+						{Match: Match().MarkMatchesWithMask(0x40, 0xc0).RPFCheckFailed(false),
+							Action: DropAction{}},
+						{Match: nil,
+							Action: ClearMarkAction{Mark: 0x80}},
+						{Match: Match().MarkClear(0x40),
+							Action: JumpAction{Target: "cali-from-host-endpoint"}},
+						{Match: Match().MarkMatchesWithMask(0x10, 0x10),
+							Action: AcceptAction{}},
+					},
+				}))
+			})
+		}
+
+		It("should render the allowed sources chain in interface order", func() {
+			Expect(rr.WorkloadAllowedSourcesChain([]WorkloadAllowedSources{
+				{IfaceName: "cali5678", CIDRs: []string{"10.65.0.0/24"}},
+				{IfaceName: "cali1234", CIDRs: []string{"10.66.0.1/32", "10.66.0.2/32"}},
+			})).To(Equal(&Chain{
+				Name: "cali-wl-allowed-src",
+				Rules: []Rule{
+					{Match: Match().InInterface("cali1234").SourceNet("10.66.0.1/32"),
+						Action: SetMarkAction{Mark: 0x80}},
+					{Match: Match().InInterface("cali1234").SourceNet("10.66.0.2/32"),
+						Action: SetMarkAction{Mark: 0x80}},
+					{Match: Match().InInterface("cali5678").SourceNet("10.65.0.0/24"),
+						Action: SetMarkAction{Mark: 0x80}},
+				},
+			}))
+			Expect(rr.WorkloadAllowedSourcesChain(nil)).To(Equal(&Chain{Name: "cali-wl-allowed-src"}))
+		})
+	})
+
+	Describe("with the workload egress allowlist enabled", func() {
+		BeforeEach(func() {
+			conf = Config{
+				WorkloadIfacePrefixes:          []string{"cali"},
+				IPSetConfigV4:                  ipsets.NewIPVersionConfig(ipsets.IPFamilyV4, "cali", nil, nil),
+				IPSetConfigV6:                  ipsets.NewIPVersionConfig(ipsets.IPFamilyV6, "cali", nil, nil),
+				IptablesMarkAccept:             0x10,
+				IptablesMarkPass:               0x20,
+				IptablesMarkScratch0:           0x40,
+				IptablesMarkScratch1:           0x80,
+				IptablesMarkEndpoint:           0xff00,
+				IptablesMarkNonCaliEndpoint:    0x100,
+				WorkloadEgressAllowlistEnabled: true,
+			}
+		})
+
+		It("should name the IP sets after the workload interface", func() {
+			Expect(EgressAllowlistIPSetID("cali1234")).To(Equal("ea:cali1234"))
+		})
+
+		It("should render the allowlist chain in interface order", func() {
+			Expect(rr.WorkloadEgressAllowlistChain(6, []string{"cali5678", "cali1234"})).To(Equal(&Chain{
+				Name: "cali-wl-egress-allow",
+				Rules: []Rule{
+					{Match: Match().InInterface("cali1234").DestIPSet("cali60ea:cali1234"),
+						Action: SetMarkAction{Mark: 0x10}},
+					{Match: Match().InInterface("cali5678").DestIPSet("cali60ea:cali5678"),
+						Action: SetMarkAction{Mark: 0x10}},
+				},
+			}))
+			Expect(rr.WorkloadEgressAllowlistChain(4, nil)).To(Equal(&Chain{Name: "cali-wl-egress-allow"}))
+		})
+	})
+
+	Describe("with workload link-local access set", func() {
+		BeforeEach(func() {
+			conf = Config{
+				WorkloadIfacePrefixes:       []string{"cali"},
+				IPSetConfigV4:               ipsets.NewIPVersionConfig(ipsets.IPFamilyV4, "cali", nil, nil),
+				IPSetConfigV6:               ipsets.NewIPVersionConfig(ipsets.IPFamilyV6, "cali", nil, nil),
+				IptablesMarkAccept:          0x10,
+				IptablesMarkPass:            0x20,
+				IptablesMarkScratch0:        0x40,
+				IptablesMarkScratch1:        0x80,
+				IptablesMarkEndpoint:        0xff00,
+				IptablesMarkNonCaliEndpoint: 0x100,
+				WorkloadLinkLocalAccess:     "Deny",
+				WorkloadLinkLocalServices:   []string{"169.254.169.254/32", "169.254.170.2/32"},
+			}
+		})
+
+		It("should render the link-local chain with the exceptions first", func() {
+			Expect(rr.WorkloadLinkLocalChain(4, []string{"cali5678", "cali1234"})).To(Equal(&Chain{
+				Name: "cali-wl-link-local",
+				Rules: []Rule{
+					{Match: Match().InInterface("cali1234"), Action: ReturnAction{}},
+					{Match: Match().InInterface("cali5678"), Action: ReturnAction{}},
+					{Match: Match().DestNet("169.254.169.254/32"), Action: DropAction{},
+						Comment: []string{"Drop traffic to link-local service"}},
+					{Match: Match().DestNet("169.254.170.2/32"), Action: DropAction{},
+						Comment: []string{"Drop traffic to link-local service"}},
+				},
+			}))
+			Expect(rr.WorkloadLinkLocalChain(6, []string{"cali1234"})).To(Equal(&Chain{Name: "cali-wl-link-local"}))
+		})
+
+		It("should set the accept mark in Allow mode", func() {
+			conf.WorkloadLinkLocalAccess = "Allow"
+			rr = NewRenderer(conf).(*DefaultRuleRenderer)
+			Expect(rr.WorkloadLinkLocalChain(4, nil).Rules[0]).To(Equal(Rule{
+				Match:   Match().DestNet("169.254.169.254/32"),
+				Action:  SetMarkAction{Mark: 0x10},
+				Comment: []string{"Allow traffic to link-local service"},
+			}))
+		})
+	})
+
+	Describe("with workload MAC enforcement enabled", func() {
+		BeforeEach(func() {
+			conf = Config{
+				WorkloadIfacePrefixes:         []string{"cali"},
+				IPSetConfigV4:                 ipsets.NewIPVersionConfig(ipsets.IPFamilyV4, "cali", nil, nil),
+				IPSetConfigV6:                 ipsets.NewIPVersionConfig(ipsets.IPFamilyV6, "cali", nil, nil),
+				IptablesMarkAccept:            0x10,
+				IptablesMarkPass:              0x20,
+				IptablesMarkScratch0:          0x40,
+				IptablesMarkScratch1:          0x80,
+				IptablesMarkEndpoint:          0xff00,
+				IptablesMarkNonCaliEndpoint:   0x100,
+				WorkloadMACEnforcementEnabled: true,
+			}
+		})
+
+		for _, ipVersion := range []uint8{4, 6} {
+			ipVersion := ipVersion
+			It(fmt.Sprintf("IPv%d: should check MACs ahead of the RPF check", ipVersion), func() {
+				Expect(findChain(rr.StaticRawTableChains(ipVersion), "cali-PREROUTING")).To(Equal(&Chain{
+					Name: "cali-PREROUTING",
+					Rules: []Rule{
+						{Match: nil,
+							Action: ClearMarkAction{Mark: 0xf0}},
+						{Match: Match().InInterface("cali+"),
+							Action: SetMarkAction{Mark: 0x40}},
+						{Match: Match().MarkMatchesWithMask(0x40, 0x40),
+							Action: JumpAction{Target: "cali-wl-mac-check"}},
+						{Match: Match().MarkMatchesWithMask(0x40, 0x40).RPFCheckFailed(false),
+							Action: DropAction{}},
+						{Match: Match().MarkClear(0x40),
+							Action: JumpAction{Target: "cali-from-host-endpoint"}},
+						{Match: Match().MarkMatchesWithMask(0x10, 0x10),
+							Action: AcceptAction{}},
+					},
+				}))
+			})
+		}
+
+		It("should render the MAC check chain in interface order", func() {
+			Expect(rr.WorkloadMACCheckChain([]WorkloadMAC{
+				{IfaceName: "cali5678", MAC: "ee:ee:ee:ee:ee:02"},
+				{IfaceName: "cali1234", MAC: "ee:ee:ee:ee:ee:01"},
+			})).To(Equal(&Chain{
+				Name: "cali-wl-mac-check",
+				Rules: []Rule{
+					{Match: Match().InInterface("cali1234").NotSourceMAC("ee:ee:ee:ee:ee:01"),
+						Action:  DropAction{},
+						Comment: []string{"Drop frames with spoofed MAC"}},
+					{Match: Match().InInterface("cali5678").NotSourceMAC("ee:ee:ee:ee:ee:02"),
+						Action:  DropAction{},
+						Comment: []string{"Drop frames with spoofed MAC"}},
+				},
+			}))
+			Expect(rr.WorkloadMACCheckChain(nil)).To(Equal(&Chain{Name: "cali-wl-mac-check"}))
+		})
+	})
+
+	Describe("with endpoint to host action overrides enabled", func() {
+		BeforeEach(func() {
+			conf = Config{
+				WorkloadIfacePrefixes:                []string{"cali"},
+				IPSetConfigV4:                        ipsets.NewIPVersionConfig(ipsets.IPFamilyV4, "cali", nil, nil),
+				IPSetConfigV6:                        ipsets.NewIPVersionConfig(ipsets.IPFamilyV6, "cali", nil, nil),
+				IptablesMarkAccept:                   0x10,
+				IptablesMarkPass:                     0x20,
+				IptablesMarkScratch0:                 0x40,
+				IptablesMarkScratch1:                 0x80,
+				IptablesMarkEndpoint:                 0xff00,
+				IptablesMarkNonCaliEndpoint:          0x100,
+				EndpointToHostAction:                 "RETURN",
+				EndpointToHostActionOverridesEnabled: true,
+			}
+		})
+
+		It("should goto the workload to host action chain", func() {
+			Expect(findChain(rr.StaticFilterTableChains(4), "cali-wl-to-host")).To(Equal(&Chain{
+				Name: "cali-wl-to-host",
+				Rules: []Rule{
+					{Action: JumpAction{Target: "cali-from-wl-dispatch"}},
+					{Action: GotoAction{Target: "cali-wl-to-host-act"}},
+				},
+			}))
+		})
+
+		It("should render the workload to host action chain with overrides before the default", func() {
+			Expect(rr.WorkloadToHostActionChain([]WorkloadToHostAction{
+				{IfaceName: "cali5678", Action: "DROP"},
+				{IfaceName: "cali1234", Action: "ACCEPT"},
+			})).To(Equal(&Chain{
+				Name: "cali-wl-to-host-act",
+				Rules: []Rule{
+					{Match: Match().InInterface("cali1234"),
+						Action:  AcceptAction{},
+						Comment: []string{"Workload's EndpointToHostAction override"}},
+					{Match: Match().InInterface("cali5678"),
+						Action:  DropAction{},
+						Comment: []string{"Workload's EndpointToHostAction override"}},
+					{Action: ReturnAction{},
+						Comment: []string{"Configured DefaultEndpointToHostAction"}},
+				},
+			}))
+		})
+	})
+
+	Describe("with external networks enabled", func() {
+		BeforeEach(func() {
+			conf = Config{
+				WorkloadIfacePrefixes:       []string{"cali"},
+				IPSetConfigV4:               ipsets.NewIPVersionConfig(ipsets.IPFamilyV4, "cali", nil, nil),
+				IPSetConfigV6:               ipsets.NewIPVersionConfig(ipsets.IPFamilyV6, "cali", nil, nil),
+				IptablesMarkAccept:          0x10,
+				IptablesMarkPass:            0x20,
+				IptablesMarkScratch0:        0x40,
+				IptablesMarkScratch1:        0x80,
+				IptablesMarkEndpoint:        0xff00,
+				IptablesMarkNonCaliEndpoint: 0x100,
+				IPIPEnabled:                 true,
+				VXLANEnabled:                true,
+				VXLANPort:                   4789,
+				ExternalNetworksEnabled:     true,
+			}
+		})
+
+		for _, ipVersion := range []uint8{4, 6} {
+			ipVersion := ipVersion
+			It(fmt.Sprintf("IPv%d: should exempt RPF-exempt external networks from the RPF check", ipVersion), func() {
+				Expect(findChain(rr.StaticRawTableChains(ipVersion), "cali-PREROUTING")).To(Equal(&Chain{
+					Name: "cali-PREROUTING",
+					Rules: []Rule{
+						{Match: nil,
+							Action: ClearMarkAction{Mark: 0xf0}},
+						{Match: Match().InInterface("cali+"),
+							Action: SetMarkAction{Mark: 0x40}},
+						{Match: Match().MarkMatchesWithMask(0x40, 0x40).
+							SourceIPSet(fmt.Sprintf("cali%d0extnets-rpf", ipVersion)),
+							Action: SetMarkAction{Mark: 0x80}},
+						{Match: Match().MarkMatchesWithMask(0x40, 0xc0).RPFCheckFailed(false),
+							Action: DropAction{}},
+						{Match: nil,
+							Action: ClearMarkAction{Mark: 0x80}},
+						{Match: Match().MarkClear(0x40),
+							Action: JumpAction{Target: "cali-from-host-endpoint"}},
+						{Match: Match().MarkMatchesWithMask(0x10, 0x10),
+							Action: AcceptAction{}},
+					},
+				}))
+			})
+		}
+
+		It("should accept tunnel packets from tunnel external networks ahead of the drop rules", func() {
+			inputRules := findChain(rr.StaticFilterTableChains(4), "cali-INPUT").Rules
+			Expect(inputRules[0:4]).To(Equal([]Rule{
+				{Match: Match().ProtocolNum(ProtoIPIP).
+					SourceIPSet("cali40all-hosts-net").
+					DestAddrType(AddrTypeLocal),
+					Action:  AcceptAction{},
+					Comment: []string{"Allow IPIP packets from Calico hosts"}},
+				{Match: Match().ProtocolNum(ProtoIPIP).
+					SourceIPSet("cali40extnets-tunnel").
+					DestAddrType(AddrTypeLocal),
+					Action:  AcceptAction{},
+					Comment: []string{"Allow IPIP packets from external networks"}},
+				{Match: Match().ProtocolNum(ProtoIPIP),
+					Action:  DropAction{},
+					Comment: []string{"Drop IPIP packets from non-Calico hosts"}},
+				{Match: Match().ProtocolNum(ProtoUDP).
+					DestPorts(4789).
+					SourceIPSet("cali40all-vxlan-net").
+					DestAddrType(AddrTypeLocal),
+					Action:  AcceptAction{},
+					Comment: []string{"Allow VXLAN packets from whitelisted hosts"}},
+			}))
+			Expect(inputRules[4]).To(Equal(Rule{
+				Match: Match().ProtocolNum(ProtoUDP).
+					DestPorts(4789).
+					SourceIPSet("cali40extnets-tunnel").
+					DestAddrType(AddrTypeLocal),
+				Action:  AcceptAction{},
+				Comment: []string{"Allow VXLAN packets from external networks"},
+			}))
+		})
+
+		It("should exclude no-masquerade external networks from NAT outgoing", func() {
+			Expect(rr.NATOutgoingChain(true, 4)).To(Equal(&Chain{
+				Name: "cali-nat-outgoing",
+				Rules: []Rule{
+					{Match: Match().
+						SourceIPSet("cali40masq-ipam-pools").
+						NotDestIPSet("cali40all-ipam-pools").
+						NotDestIPSet("cali40extnets-nomasq"),
+						Action: MasqAction{}},
+				},
+			}))
+		})
+	})
+
+	Describe("with egress SNAT enabled", func() {
+		BeforeEach(func() {
+			conf = Config{
+				WorkloadIfacePrefixes:       []string{"cali"},
+				IPSetConfigV4:               ipsets.NewIPVersionConfig(ipsets.IPFamilyV4, "cali", nil, nil),
+				IPSetConfigV6:               ipsets.NewIPVersionConfig(ipsets.IPFamilyV6, "cali", nil, nil),
+				IptablesMarkAccept:          0x10,
+				IptablesMarkPass:            0x20,
+				IptablesMarkScratch0:        0x40,
+				IptablesMarkScratch1:        0x80,
+				IptablesMarkEndpoint:        0xff00,
+				IptablesMarkNonCaliEndpoint: 0x100,
+				EgressSNATEnabled:           true,
+			}
+		})
+
+		It("IPv4: should jump to the egress SNAT chain before NAT outgoing", func() {
+			Expect(rr.StaticNATPostroutingChains(4)).To(Equal([]*Chain{{
+				Name: "cali-POSTROUTING",
+				Rules: []Rule{
+					{Action: JumpAction{Target: "cali-fip-snat"}},
+					{Action: JumpAction{Target: "cali-egress-snat"}},
+					{Action: JumpAction{Target: "cali-nat-outgoing"}},
+				},
+			}}))
+		})
+
+		It("IPv6: should not jump to the egress SNAT chain", func() {
+			Expect(rr.StaticNATPostroutingChains(6)).To(Equal([]*Chain{{
+				Name: "cali-POSTROUTING",
+				Rules: []Rule{
+					{Action: JumpAction{Target: "cali-fip-snat"}},
+					{Action: JumpAction{Target: "cali-nat-outgoing"}},
+				},
+			}}))
+		})
+	})
+
+	Describe("with kube-proxy precedence enabled in BPF mode", func() {
+		BeforeEach(func() {
+			conf = Config{
+				WorkloadIfacePrefixes:      []string{"cali"},
+				IPSetConfigV4:              ipsets.NewIPVersionConfig(ipsets.IPFamilyV4, "cali", nil, nil),
+				IPSetConfigV6:              ipsets.NewIPVersionConfig(ipsets.IPFamilyV6, "cali", nil, nil),
+				IptablesMarkAccept:         0x10,
+				IptablesMarkScratch0:       0x40,
+				BPFEnabled:                 true,
+				KubeProxyPrecedenceEnabled: true,
+			}
+		})
+
+		It("IPv4: should accept packets seen by BPF after the FIP DNAT", func() {
+			Expect(rr.StaticNATPreroutingChains(4)).To(Equal([]*Chain{{
+				Name: "cali-PREROUTING",
+				Rules: []Rule{
+					{Action: JumpAction{Target: "cali-fip-dnat"}},
+					{
+						Match:   Match().MarkMatchesWithMask(tc.MarkSeen, tc.MarkSeenMask),
+						Action:  AcceptAction{},
+						Comment: []string{"BPF NAT takes precedence over kube-proxy"},
+					},
+				},
+			}}))
+		})
+
+		It("IPv6: should not add the precedence rule", func() {
+			Expect(rr.StaticNATPreroutingChains(6)).To(Equal([]*Chain{{
+				Name: "cali-PREROUTING",
+				Rules: []Rule{
+					{Action: JumpAction{Target: "cali-fip-dnat"}},
+				},
+			}}))
+		})
+	})
 })
 
 func findChain(chains []*Chain, name string) *Chain {
@@ -23,10 +23,10 @@ import (
 	"strings"
 )
 
-var rex = regexp.MustCompile(`\s*(\w+)=(.*)`)
+var rex = regexp.MustCompile(`^\s*([\w.-]+)=(.*)`)
 
 // ParseKeyValueList parses a comma-separated key=value list to a map.
-// Keys must contain only word characters (leading spaces ignored).
+// Keys must contain only word characters, '.' and '-' (leading spaces ignored).
 // Spaces in the value are preserved.
 func ParseKeyValueList(param string) (map[string]string, error) {
 	res := make(map[string]string)
@@ -53,4 +53,9 @@ var _ = DescribeTable("ParseKeyValueList tests",
 	Entry("Empty item, tailing ','", ",  key=value,", map[string]string{
 		"key": "value",
 	}),
+	Entry("Keys with dashes and dots", "kube-system=a,example.com=b", map[string]string{
+		"kube-system": "a",
+		"example.com": "b",
+	}),
+	Entry("A key with other characters", "key=value, key:2=value", nil),
 )
@@ -1,5 +1,22 @@
 package wireguard
 
+import (
+	"time"
+
+	"github.com/projectcalico/felix/ip"
+)
+
+const (
+	// EncryptionScopeAll encrypts traffic to all wireguard peers.
+	EncryptionScopeAll = "All"
+	// EncryptionScopeCrossSubnet only encrypts traffic to peers whose node IP is outside the subnet of this
+	// node's IP.
+	EncryptionScopeCrossSubnet = "CrossSubnet"
+	// EncryptionScopeLabelled only encrypts traffic between nodes where at least one of the two carries the
+	// configured encryption label, so that both ends agree on whether to encrypt.
+	EncryptionScopeLabelled = "Labelled"
+)
+
 type Config struct {
 	// Wireguard configuration
 	Enabled             bool
@@ -10,4 +27,16 @@ type Config struct {
 	InterfaceName       string
 	MTU                 int
 	RouteSource         string
+	EncryptionScope     string
+
+	// PersistentKeepAlive is the persistent-keepalive interval programmed for all peers; 0 disables
+	// keepalives.
+	PersistentKeepAlive time.Duration
+	// NATPersistentKeepAlive overrides PersistentKeepAlive for peers that appear to be behind NAT
+	// (see natHandshakeTimeout); 0 disables NAT detection.
+	NATPersistentKeepAlive time.Duration
+
+	// RouteMTUs maps destination CIDRs to the MTU of the wireguard routes within them; the most
+	// specific CIDR wins.  Routes to other destinations use the device MTU.
+	RouteMTUs map[ip.CIDR]int
 }
@@ -49,6 +49,11 @@ const (
 	ipVersion           = 4
 	ipPrefixLen         = 32
 	allSrcValidMarkPath = "/proc/sys/net/ipv4/conf/all/src_valid_mark"
+
+	// natHandshakeTimeout is how long we allow a peer that we are sending traffic to to go without a handshake
+	// before we assume that a NAT gateway between us has dropped its mapping for the tunnel.  Handshakes are
+	// renewed every 2 minutes while there is traffic and the kernel gives up on a session after 3 minutes.
+	natHandshakeTimeout = 3 * time.Minute
 )
 
 var (
@@ -68,9 +73,14 @@ func (*noOpConnTrack) RemoveConntrackFlows(ipVersion uint8, ipAddr net.IP) {}
 type nodeData struct {
 	ipv4EndpointAddr      ip.Addr
 	publicKey             wgtypes.Key
+	hasEncryptionLabel    bool
 	cidrs                 set.Set
 	programmedInWireguard bool
 	routingToWireguard    bool
+
+	// NAT detection state, updated on each resync; see detectNAT.
+	behindNAT         bool
+	lastTransmitBytes int64
 }
 
 func newNodeData() *nodeData {
@@ -94,9 +104,14 @@ type nodeUpdateData struct {
 	cidrsDeleted set.Set
 
 	// Only used for nodes.
-	deleted          bool
-	ipv4EndpointAddr *ip.Addr
-	publicKey        *wgtypes.Key
+	deleted            bool
+	ipv4EndpointAddr   *ip.Addr
+	publicKey          *wgtypes.Key
+	hasEncryptionLabel *bool
+
+	// Set when the encryption scope may have changed for reasons other than the node's own data (for example,
+	// our own subnet has changed).
+	rescope bool
 }
 
 func newNodeUpdateData() *nodeUpdateData {
@@ -130,6 +145,13 @@ type Wireguard struct {
 	ourIPv4InterfaceAddr               ip.Addr
 	ourPublicKeyAgreesWithDataplaneMsg bool
 
+	// Our own node IP and the subnet containing it; only tracked when limiting encryption to cross-subnet peers.
+	ourIPv4EndpointAddr ip.Addr
+	ourSubnet           *net.IPNet
+	inSyncOurSubnet     bool
+	// Whether our own node carries the encryption label; only used when limiting encryption to labelled nodes.
+	ourHasEncryptionLabel bool
+
 	// Local workload information
 	localIPs          set.Set
 	localCIDRs        set.Set
@@ -270,6 +292,41 @@ func (w *Wireguard) OnIfaceStateChanged(ifaceName string, state ifacemonitor.Sta
 	w.routetable.OnIfaceStateChanged(ifaceName, state)
 }
 
+// SetMTU updates the MTU of the wireguard device, for example after the MTU of the underlying
+// interfaces has changed.
+func (w *Wireguard) SetMTU(mtu int) {
+	if mtu == w.config.MTU {
+		return
+	}
+	log.WithFields(log.Fields{
+		"oldMTU": w.config.MTU,
+		"mtu":    mtu,
+	}).Info("Wireguard device MTU changed")
+	w.config.MTU = mtu
+	w.inSyncLink = false
+}
+
+// routeMTU returns the MTU for the route to the given CIDR: the MTU of the most specific of the
+// configured RouteMTUs that contains it, or 0 to use the device MTU.
+func (w *Wireguard) routeMTU(cidr ip.CIDR) int {
+	mtu := 0
+	bestPrefix := -1
+	for routeCIDR, routeMTU := range w.config.RouteMTUs {
+		if routeCIDR.Version() != cidr.Version() || routeCIDR.Prefix() > cidr.Prefix() {
+			continue
+		}
+		ipNet := routeCIDR.ToIPNet()
+		if !ipNet.Contains(cidr.Addr().AsNetIP()) {
+			continue
+		}
+		if int(routeCIDR.Prefix()) > bestPrefix {
+			mtu = routeMTU
+			bestPrefix = int(routeCIDR.Prefix())
+		}
+	}
+	return mtu
+}
+
 func (w *Wireguard) EndpointUpdate(name string, ipv4Addr ip.Addr) {
 	logCxt := log.WithFields(log.Fields{"name": name, "ipv4Addr": ipv4Addr})
 	logCxt.Debug("EndpointUpdate")
@@ -277,7 +334,12 @@ func (w *Wireguard) EndpointUpdate(name string, ipv4Addr ip.Addr) {
 		logCxt.Debug("Not enabled - ignoring")
 		return
 	} else if name == w.hostname {
-		// We don't need our own IP address, just interested in the nodes.
+		// We only need our own IP address to determine our subnet when encrypting to cross-subnet peers.
+		if w.config.EncryptionScope == EncryptionScopeCrossSubnet && w.ourIPv4EndpointAddr != ipv4Addr {
+			logCxt.Debug("Local IPv4 address updated")
+			w.ourIPv4EndpointAddr = ipv4Addr
+			w.inSyncOurSubnet = false
+		}
 		return
 	}
 
@@ -474,8 +536,18 @@ func (w *Wireguard) peerAllowedCIDRRemove(name string, cidr ip.CIDR) {
 	w.setNodeUpdate(name, update)
 }
 
-func (w *Wireguard) EndpointWireguardUpdate(name string, publicKey wgtypes.Key, ipv4InterfaceAddr ip.Addr) {
-	logCxt := log.WithFields(log.Fields{"node": name, "publicKey": publicKey, "ipv4InterfaceAddr": ipv4InterfaceAddr})
+// EndpointWireguardUpdate updates the wireguard configuration of a node, which may be our own node. hasEncryptionLabel
+// indicates whether the node carries the encryption label; it is only used when the encryption scope is
+// EncryptionScopeLabelled.
+func (w *Wireguard) EndpointWireguardUpdate(
+	name string, publicKey wgtypes.Key, ipv4InterfaceAddr ip.Addr, hasEncryptionLabel bool,
+) {
+	logCxt := log.WithFields(log.Fields{
+		"node":               name,
+		"publicKey":          publicKey,
+		"ipv4InterfaceAddr":  ipv4InterfaceAddr,
+		"hasEncryptionLabel": hasEncryptionLabel,
+	})
 	logCxt.Debug("EndpointWireguardUpdate")
 	if !w.config.Enabled {
 		log.Debug("Not enabled - ignoring")
@@ -495,6 +567,13 @@ func (w *Wireguard) EndpointWireguardUpdate(name string, publicKey wgtypes.Key,
 			w.ourIPv4InterfaceAddr = ipv4InterfaceAddr
 			w.inSyncInterfaceAddr = false
 		}
+		if w.ourHasEncryptionLabel != hasEncryptionLabel {
+			logCxt.Debug("Local encryption label updated")
+			w.ourHasEncryptionLabel = hasEncryptionLabel
+			if w.config.EncryptionScope == EncryptionScopeLabelled {
+				w.rescopePeers()
+			}
+		}
 		return
 	}
 
@@ -510,6 +589,12 @@ func (w *Wireguard) EndpointWireguardUpdate(name string, publicKey wgtypes.Key,
 		logCxt.Debug("Storing updated public key")
 		update.publicKey = &publicKey
 	}
+	if existing, ok := w.nodes[name]; ok && existing.hasEncryptionLabel == hasEncryptionLabel {
+		update.hasEncryptionLabel = nil
+	} else {
+		logCxt.Debug("Storing updated encryption label")
+		update.hasEncryptionLabel = &hasEncryptionLabel
+	}
 	w.setNodeUpdate(name, update)
 }
 
@@ -521,7 +606,7 @@ func (w *Wireguard) EndpointWireguardRemove(name string) {
 		return
 	}
 	if name == w.hostname {
-		w.EndpointWireguardUpdate(name, zeroKey, nil)
+		w.EndpointWireguardUpdate(name, zeroKey, nil, false)
 		return
 	}
 
@@ -534,8 +619,10 @@ func (w *Wireguard) EndpointWireguardRemove(name string) {
 	}
 
 	// Create update to remove the public key.
+	hasEncryptionLabel := false
 	update := w.getOrInitNodeUpdateData(name)
 	update.publicKey = &zeroKey
+	update.hasEncryptionLabel = &hasEncryptionLabel
 	w.setNodeUpdate(name, update)
 }
 
@@ -608,6 +695,12 @@ func (w *Wireguard) Apply() (err error) {
 
 	// --- Wireguard is enabled ---
 
+	// If we only encrypt to cross-subnet peers then make sure we know our own subnet. A change of subnet may change
+	// the routing decision for any of the peers.
+	if w.config.EncryptionScope == EncryptionScopeCrossSubnet && !w.inSyncOurSubnet {
+		w.updateOurSubnet(netlinkClient)
+	}
+
 	// Process local CIDR updates. This may result in node deltas for the local node.
 	if w.localCIDRsUpdated {
 		w.nodeUpdates[w.hostname] = w.getLocalNodeCIDRUpdates()
@@ -896,9 +989,13 @@ func (w *Wireguard) handlePeerAndRouteDeletionFromNodeUpdates(conflictingKeys se
 			// Delete all of the node routes for the nodeData and remove CIDR->node association. Note that we always
 			// update the routing table routes using delta updates even during a full resync. The routetable component
 			// takes care of its own kernel-cache synchronization.
+			ifaceName := routetable.InterfaceNone
+			if node.routingToWireguard {
+				ifaceName = w.config.InterfaceName
+			}
 			node.cidrs.Iter(func(item interface{}) error {
 				cidr := item.(ip.CIDR)
-				w.routetable.RouteRemove(w.config.InterfaceName, cidr)
+				w.routetable.RouteRemove(ifaceName, cidr)
 				delete(w.cidrToNodeName, cidr)
 				logCxt.WithField("cidr", cidr).Debug("Deleting route")
 				return nil
@@ -962,6 +1059,9 @@ func (w *Wireguard) updateCacheFromNodeUpdates(conflictingKeys set.Set) {
 		if update.ipv4EndpointAddr != nil {
 			logCxt.WithField("ipv4EndpointAddr", *update.ipv4EndpointAddr).Debug("Store IPv4 address")
 			node.ipv4EndpointAddr = *update.ipv4EndpointAddr
+			// The node may no longer be behind NAT at its new address.
+			node.behindNAT = false
+			node.lastTransmitBytes = 0
 			updated = true
 		}
 		if update.publicKey != nil {
@@ -979,6 +1079,15 @@ func (w *Wireguard) updateCacheFromNodeUpdates(conflictingKeys set.Set) {
 			}
 			updated = true
 		}
+		if update.hasEncryptionLabel != nil {
+			logCxt.WithField("hasEncryptionLabel", *update.hasEncryptionLabel).Debug("Store encryption label")
+			node.hasEncryptionLabel = *update.hasEncryptionLabel
+			updated = true
+		}
+		if update.rescope {
+			logCxt.Debug("Encryption scope needs recalculating")
+			updated = true
+		}
 		update.cidrsDeleted.Iter(func(item interface{}) error {
 			cidr := item.(ip.CIDR)
 			logCxt.WithField("cidr", cidr).Debug("Discarding CIDR")
@@ -1015,7 +1124,7 @@ func (w *Wireguard) updateRouteTableFromNodeUpdates() {
 		// Delete routes that are no longer required in routing.
 		node := w.getOrInitNodeData(name)
 		ifaceName := routetable.InterfaceNone
-		if node != nil && node.programmedInWireguard {
+		if node != nil && node.routingToWireguard {
 			ifaceName = w.config.InterfaceName
 		}
 		logCxt := log.WithFields(log.Fields{"node": name, "ifaceName": ifaceName})
@@ -1037,7 +1146,7 @@ func (w *Wireguard) updateRouteTableFromNodeUpdates() {
 		// If the node routing to wireguard does not match with whether we should route then we need to do a full
 		// route update, otherwise do an incremental update.
 		var updateSet set.Set
-		shouldRouteToWireguard := w.shouldProgramWireguardPeer(name, node)
+		shouldRouteToWireguard := w.shouldRouteToWireguard(name, node)
 		if node.routingToWireguard != shouldRouteToWireguard {
 			logCxt.WithField("shouldNowRouteToWireguard", shouldRouteToWireguard).Debug("Wireguard routing decision has changed - need to update full set of CIDRs")
 			updateSet = node.cidrs
@@ -1075,10 +1184,14 @@ func (w *Wireguard) updateRouteTableFromNodeUpdates() {
 				updateLogCxt.WithField("ifacename", deleteIfaceName).Debug("Wireguard routing has changed - delete previous route for interface")
 				w.routetable.RouteRemove(deleteIfaceName, cidr)
 			}
-			w.routetable.RouteUpdate(ifaceName, routetable.Target{
+			target := routetable.Target{
 				Type: targetType,
 				CIDR: cidr,
-			})
+			}
+			if shouldRouteToWireguard {
+				target.MTU = w.routeMTU(cidr)
+			}
+			w.routetable.RouteUpdate(ifaceName, target)
 			return nil
 		})
 		node.routingToWireguard = shouldRouteToWireguard
@@ -1110,6 +1223,9 @@ func (w *Wireguard) constructWireguardDeltaFromNodeUpdates(conflictingKeys set.S
 					PublicKey:  peer.publicKey,
 				}
 				updatePeer := false
+				if !peer.programmedInWireguard {
+					wgpeer.PersistentKeepaliveInterval = w.persistentKeepAlive(peer)
+				}
 				if !peer.programmedInWireguard || update.cidrsDeleted.Len() > 0 {
 					logCxt.Debug("Peer not programmed or CIDRs were deleted - need to replace full set of CIDRs")
 					wgpeer.ReplaceAllowedIPs = true
@@ -1174,9 +1290,10 @@ func (w *Wireguard) constructWireguardDeltaFromNodeUpdates(conflictingKeys set.S
 					// The peer is not programmed and should be.  Add a delta create.
 					nodeLogCxt.Debug("Not programmed in wireguard, needs to be added now")
 					wireguardUpdate.Peers = append(wireguardUpdate.Peers, wgtypes.PeerConfig{
-						PublicKey:  peer.publicKey,
-						Endpoint:   w.endpointUDPAddr(peer.ipv4EndpointAddr.AsNetIP()),
-						AllowedIPs: peer.allowedCidrsForWireguard(),
+						PublicKey:                   peer.publicKey,
+						Endpoint:                    w.endpointUDPAddr(peer.ipv4EndpointAddr.AsNetIP()),
+						PersistentKeepaliveInterval: w.persistentKeepAlive(peer),
+						AllowedIPs:                  peer.allowedCidrsForWireguard(),
 					})
 				}
 				return nil
@@ -1262,6 +1379,11 @@ func (w *Wireguard) constructWireguardDeltaForResync(wireguardClient netlinkshim
 		configuredAddr := device.Peers[peerIdx].Endpoint
 		replaceCidrs := false
 
+		// Check whether the peer has become unreachable behind NAT before working out its keepalive.
+		w.detectNAT(logCxt, node, device.Peers[peerIdx])
+		expectedKeepAlive := w.persistentKeepAlive(node)
+		replaceKeepAlive := device.Peers[peerIdx].PersistentKeepaliveInterval != *expectedKeepAlive
+
 		// Need to check programmed CIDRs against expected to see if any need deleting.
 		logCxt.Debug("Check programmed CIDRs for required deletions")
 		expectedAllowedCidrs := node.allowedCidrsForWireguard()
@@ -1294,7 +1416,7 @@ func (w *Wireguard) constructWireguardDeltaForResync(wireguardClient netlinkshim
 		expectedEndpointIP := node.ipv4EndpointAddr.AsNetIP()
 		replaceEndpointAddr := expectedEndpointIP != nil &&
 			(configuredAddr == nil || configuredAddr.Port != w.config.ListeningPort || !configuredAddr.IP.Equal(expectedEndpointIP))
-		if replaceEndpointAddr || allowedCidrsForUpdateMsg != nil {
+		if replaceEndpointAddr || replaceKeepAlive || allowedCidrsForUpdateMsg != nil {
 			peer := wgtypes.PeerConfig{
 				PublicKey:         key,
 				UpdateOnly:        true,
@@ -1306,6 +1428,10 @@ func (w *Wireguard) constructWireguardDeltaForResync(wireguardClient netlinkshim
 				logCxt.Info("Endpoint address needs updating")
 				peer.Endpoint = w.endpointUDPAddr(expectedEndpointIP)
 			}
+			if replaceKeepAlive {
+				logCxt.WithField("persistentKeepAlive", *expectedKeepAlive).Info("Persistent keepalive needs updating")
+				peer.PersistentKeepaliveInterval = expectedKeepAlive
+			}
 
 			wireguardUpdate.Peers = append(wireguardUpdate.Peers, peer)
 			wireguardUpdateRequired = true
@@ -1326,9 +1452,10 @@ func (w *Wireguard) constructWireguardDeltaForResync(wireguardClient netlinkshim
 
 		logCxt.WithField("ipv4EndpointAddr", node.ipv4EndpointAddr).Info("Add peer to wireguard")
 		wireguardUpdate.Peers = append(wireguardUpdate.Peers, wgtypes.PeerConfig{
-			PublicKey:  node.publicKey,
-			Endpoint:   w.endpointUDPAddr(node.ipv4EndpointAddr.AsNetIP()),
-			AllowedIPs: node.allowedCidrsForWireguard(),
+			PublicKey:                   node.publicKey,
+			Endpoint:                    w.endpointUDPAddr(node.ipv4EndpointAddr.AsNetIP()),
+			PersistentKeepaliveInterval: w.persistentKeepAlive(node),
+			AllowedIPs:                  node.allowedCidrsForWireguard(),
 		})
 		wireguardUpdateRequired = true
 	}
@@ -1566,6 +1693,90 @@ func (w *Wireguard) shouldProgramWireguardPeer(name string, node *nodeData) bool
 	return true
 }
 
+// shouldRouteToWireguard returns true if traffic to the peer's CIDRs should be routed to wireguard. This requires the
+// peer to be programmed in wireguard and to be within the configured encryption scope. Traffic to peers that are
+// programmed but outside the scope uses plain routes; we still program the peer so that we can decrypt traffic from
+// peers whose scope includes us.
+func (w *Wireguard) shouldRouteToWireguard(name string, node *nodeData) bool {
+	if !w.shouldProgramWireguardPeer(name, node) {
+		return false
+	}
+	logCxt := log.WithField("node", name)
+	switch w.config.EncryptionScope {
+	case EncryptionScopeCrossSubnet:
+		if w.ourSubnet != nil && w.ourSubnet.Contains(node.ipv4EndpointAddr.AsNetIP()) {
+			logCxt.Debug("Peer is in our subnet, not routing to wireguard")
+			return false
+		}
+	case EncryptionScopeLabelled:
+		// The peer makes the same decision about us, so encrypt if either end is labelled; otherwise one end
+		// would send encrypted traffic that the other end replies to in the clear.
+		if !node.hasEncryptionLabel && !w.ourHasEncryptionLabel {
+			logCxt.Debug("Neither we nor the peer have the encryption label, not routing to wireguard")
+			return false
+		}
+	}
+	return true
+}
+
+// updateOurSubnet determines the subnet of our own node IP from the interface addresses. If the subnet has changed
+// then all peers are flagged for an update so that their routing is recalculated. Until our subnet is known, traffic
+// to all peers is encrypted.
+func (w *Wireguard) updateOurSubnet(netlinkClient netlinkshim.Interface) {
+	var subnet *net.IPNet
+	if w.ourIPv4EndpointAddr != nil {
+		links, err := netlinkClient.LinkList()
+		if err != nil {
+			log.WithError(err).Warn("Failed to list links, will retry determining local subnet")
+			return
+		}
+		ourIP := w.ourIPv4EndpointAddr.AsNetIP()
+		// Our endpoint address may be IPv6 on an IPv6-only host.
+		family := netlink.FAMILY_V4
+		if w.ourIPv4EndpointAddr.Version() == 6 {
+			family = netlink.FAMILY_V6
+		}
+	linkLoop:
+		for _, link := range links {
+			addrs, err := netlinkClient.AddrList(link, family)
+			if err != nil {
+				log.WithError(err).WithField("link", link.Attrs().Name).Debug("Failed to list addresses for link")
+				continue
+			}
+			for _, addr := range addrs {
+				if addr.IPNet != nil && addr.IP.Equal(ourIP) {
+					subnet = &net.IPNet{IP: addr.IP.Mask(addr.Mask), Mask: addr.Mask}
+					break linkLoop
+				}
+			}
+		}
+		if subnet == nil {
+			log.WithField("ip", ourIP).Info("Node IP not found on any interface, encrypting traffic to all peers")
+		}
+	}
+	w.inSyncOurSubnet = true
+
+	if (subnet == nil && w.ourSubnet == nil) ||
+		(subnet != nil && w.ourSubnet != nil && subnet.String() == w.ourSubnet.String()) {
+		return
+	}
+	log.WithField("subnet", subnet).Info("Local subnet updated, recalculating wireguard encryption scope")
+	w.ourSubnet = subnet
+	w.rescopePeers()
+}
+
+// rescopePeers flags all peers for an update so that their routing is recalculated.
+func (w *Wireguard) rescopePeers() {
+	for name := range w.nodes {
+		if name == w.hostname {
+			continue
+		}
+		update := w.getOrInitNodeUpdateData(name)
+		update.rescope = true
+		w.setNodeUpdate(name, update)
+	}
+}
+
 // getWireguardClient returns a wireguard client for managing wireguard devices.
 func (w *Wireguard) getWireguardClient() (netlinkshim.Wireguard, error) {
 	if w.cachedWireguardClient == nil {
@@ -1659,6 +1870,33 @@ func (w *Wireguard) applyWireguardConfig(wireguardClient netlinkshim.Wireguard,
 	return wireguardClient.ConfigureDevice(w.config.InterfaceName, *c)
 }
 
+// persistentKeepAlive returns the persistent keepalive interval that should be programmed for the node.
+func (w *Wireguard) persistentKeepAlive(node *nodeData) *time.Duration {
+	keepAlive := w.config.PersistentKeepAlive
+	if node.behindNAT {
+		keepAlive = w.config.NATPersistentKeepAlive
+	}
+	return &keepAlive
+}
+
+// detectNAT checks whether a peer appears to be behind a NAT gateway: we have been sending it traffic since the previous
+// resync but we haven't completed a handshake with it for longer than natHandshakeTimeout.  Without keepalives, a NAT
+// gateway drops its mapping for an idle tunnel and the peer's handshakes no longer reach us.  Once detected, the node is
+// treated as behind NAT until its endpoint address changes.
+func (w *Wireguard) detectNAT(logCxt *log.Entry, node *nodeData, peer wgtypes.Peer) {
+	sending := node.lastTransmitBytes != 0 && peer.TransmitBytes > node.lastTransmitBytes
+	node.lastTransmitBytes = peer.TransmitBytes
+	if w.config.NATPersistentKeepAlive == 0 || node.behindNAT || !sending || peer.Endpoint == nil {
+		return
+	}
+	if !peer.LastHandshakeTime.IsZero() && w.time.Since(peer.LastHandshakeTime) < natHandshakeTimeout {
+		return
+	}
+	logCxt.WithField("lastHandshake", peer.LastHandshakeTime).Warn(
+		"Handshake with peer timed out; assuming it is behind NAT and enabling NAT keepalives")
+	node.behindNAT = true
+}
+
 // endpointUDPAddr converts the net IP and the configured listening port to a net UDP address.
 func (w *Wireguard) endpointUDPAddr(ip net.IP) *net.UDPAddr {
 	if ip == nil {
@@ -1675,6 +1913,7 @@ func (w *Wireguard) setAllInSync(inSync bool) {
 	w.inSyncWireguard = inSync
 	w.inSyncLink = inSync
 	w.inSyncInterfaceAddr = inSync
+	w.inSyncOurSubnet = inSync
 }
 
 // getOnlyItemInSet returns the only item in the set, or nil if the set is nil or the set does not contain only one
@@ -142,6 +142,7 @@ var _ = Describe("Enable wireguard", func() {
 	var t *mocktime.MockTime
 	var s *mockStatus
 	var wg *Wireguard
+	var wgConfig *Config
 	var rule *netlink.Rule
 
 	BeforeEach(func() {
@@ -154,17 +155,18 @@ var _ = Describe("Enable wireguard", func() {
 		// disables the grace period for these tests.
 		t.SetAutoIncrement(11 * time.Second)
 
+		wgConfig = &Config{
+			Enabled:             true,
+			ListeningPort:       listeningPort,
+			FirewallMark:        firewallMark,
+			RoutingRulePriority: rulePriority,
+			RoutingTableIndex:   tableIndex,
+			InterfaceName:       ifaceName,
+			MTU:                 mtu,
+		}
 		wg = NewWithShims(
 			hostname,
-			&Config{
-				Enabled:             true,
-				ListeningPort:       listeningPort,
-				FirewallMark:        firewallMark,
-				RoutingRulePriority: rulePriority,
-				RoutingTableIndex:   tableIndex,
-				InterfaceName:       ifaceName,
-				MTU:                 mtu,
-			},
+			wgConfig,
 			rtDataplane.NewMockNetlink,
 			rrDataplane.NewMockNetlink,
 			wgDataplane.NewMockNetlink,
@@ -263,6 +265,13 @@ var _ = Describe("Enable wireguard", func() {
 				Expect(s.key).To(Equal(link.WireguardPublicKey))
 			})
 
+			It("should update the device MTU when it changes", func() {
+				wg.SetMTU(1400)
+				err := wg.Apply()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(wgDataplane.NameToLink[ifaceName].LinkAttrs.MTU).To(Equal(1400))
+			})
+
 			It("should add the routing rule when wireguard device is configured", func() {
 				wgDataplane.ResetDeltas()
 				err := wg.Apply()
@@ -300,7 +309,7 @@ var _ = Describe("Enable wireguard", func() {
 				Expect(s.key).To(Equal(key.PublicKey()))
 
 				ipv4 := ip.FromString("1.2.3.4")
-				wg.EndpointWireguardUpdate(hostname, zeroKey, ipv4)
+				wg.EndpointWireguardUpdate(hostname, zeroKey, ipv4, false)
 				err := wg.Apply()
 				Expect(err).NotTo(HaveOccurred())
 				link = wgDataplane.NameToLink[ifaceName]
@@ -322,7 +331,7 @@ var _ = Describe("Enable wireguard", func() {
 				key := link.WireguardPrivateKey
 
 				ipv4 := ip.FromString("1.2.3.4")
-				wg.EndpointWireguardUpdate(hostname, key.PublicKey(), ipv4)
+				wg.EndpointWireguardUpdate(hostname, key.PublicKey(), ipv4, false)
 				err := wg.Apply()
 				Expect(err).NotTo(HaveOccurred())
 				link = wgDataplane.NameToLink[ifaceName]
@@ -405,12 +414,12 @@ var _ = Describe("Enable wireguard", func() {
 				var link *mocknetlink.MockLink
 				BeforeEach(func() {
 					Expect(s.numCallbacks).To(Equal(1))
-					wg.EndpointWireguardUpdate(hostname, s.key, nil)
+					wg.EndpointWireguardUpdate(hostname, s.key, nil, false)
 					key_peer1 = mustGeneratePrivateKey().PublicKey()
-					wg.EndpointWireguardUpdate(peer1, key_peer1, nil)
+					wg.EndpointWireguardUpdate(peer1, key_peer1, nil, false)
 					wg.EndpointUpdate(peer1, ipv4_peer1)
 					key_peer2 = mustGeneratePrivateKey().PublicKey()
-					wg.EndpointWireguardUpdate(peer2, key_peer2, nil)
+					wg.EndpointWireguardUpdate(peer2, key_peer2, nil, false)
 					wg.EndpointUpdate(peer2, ipv4_peer2)
 					wg.RouteUpdate(hostname, cidr_local)
 					err := wg.Apply()
@@ -470,8 +479,8 @@ var _ = Describe("Enable wireguard", func() {
 				It("should have no updates for backing out a peer key update", func() {
 					wgDataplane.ResetDeltas()
 					rtDataplane.ResetDeltas()
-					wg.EndpointWireguardUpdate(peer1, key_peer2, nil)
-					wg.EndpointWireguardUpdate(peer1, key_peer1, nil)
+					wg.EndpointWireguardUpdate(peer1, key_peer2, nil, false)
+					wg.EndpointWireguardUpdate(peer1, key_peer1, nil, false)
 					err := wg.Apply()
 					Expect(err).NotTo(HaveOccurred())
 					Expect(wgDataplane.WireguardConfigUpdated).To(BeFalse())
@@ -481,7 +490,7 @@ var _ = Describe("Enable wireguard", func() {
 					wgDataplane.ResetDeltas()
 					rtDataplane.ResetDeltas()
 					wg.EndpointUpdate(peer3, ipv4_peer3)
-					wg.EndpointWireguardUpdate(peer3, key_peer1, nil)
+					wg.EndpointWireguardUpdate(peer3, key_peer1, nil, false)
 					wg.EndpointRemove(peer3)
 					wg.EndpointWireguardRemove(peer3)
 					err := wg.Apply()
@@ -520,7 +529,7 @@ var _ = Describe("Enable wireguard", func() {
 							wgPeers[k] = p
 						}
 
-						wg.EndpointWireguardUpdate(peer2, key_peer1, nil)
+						wg.EndpointWireguardUpdate(peer2, key_peer1, nil, false)
 						rtDataplane.ResetDeltas()
 						err := wg.Apply()
 						Expect(err).NotTo(HaveOccurred())
@@ -545,7 +554,7 @@ var _ = Describe("Enable wireguard", func() {
 					})
 
 					It("should add both nodes when conflicting public keys updated to no longer conflict", func() {
-						wg.EndpointWireguardUpdate(peer2, key_peer2, nil)
+						wg.EndpointWireguardUpdate(peer2, key_peer2, nil, false)
 						err := wg.Apply()
 						Expect(err).NotTo(HaveOccurred())
 						Expect(link.WireguardPeers).To(HaveKey(key_peer1))
@@ -670,6 +679,26 @@ var _ = Describe("Enable wireguard", func() {
 							}))
 						})
 
+						It("should set the MTU of routes within the configured route MTUs", func() {
+							wgConfig.RouteMTUs = map[ip.CIDR]int{
+								ip.MustParseCIDROrIP("192.168.0.0/16"): 1400,
+								ip.MustParseCIDROrIP("192.168.7.0/24"): 1300,
+							}
+							cidr_5 := ip.MustParseCIDROrIP("192.168.7.0/26")
+							cidr_6 := ip.MustParseCIDROrIP("192.168.8.0/24")
+							wg.RouteUpdate(peer1, cidr_5)
+							wg.RouteUpdate(peer2, cidr_6)
+							rtDataplane.ResetDeltas()
+							err := wg.Apply()
+							Expect(err).NotTo(HaveOccurred())
+
+							routekey_5 := fmt.Sprintf("%d-%d-%s", tableIndex, link.LinkAttrs.Index, cidr_5)
+							routekey_6 := fmt.Sprintf("%d-%d-%s", tableIndex, link.LinkAttrs.Index, cidr_6)
+							Expect(rtDataplane.AddedRouteKeys).To(HaveLen(2))
+							Expect(rtDataplane.RouteKeyToRoute[routekey_5].MTU).To(Equal(1300))
+							Expect(rtDataplane.RouteKeyToRoute[routekey_6].MTU).To(Equal(1400))
+						})
+
 						It("should remove a route from the peer", func() {
 							wgDataplane.ResetDeltas()
 							rtDataplane.ResetDeltas()
@@ -799,7 +828,7 @@ var _ = Describe("Enable wireguard", func() {
 							var key_peer3 wgtypes.Key
 							BeforeEach(func() {
 								key_peer3 = mustGeneratePrivateKey()
-								wg.EndpointWireguardUpdate(peer3, key_peer3, nil)
+								wg.EndpointWireguardUpdate(peer3, key_peer3, nil, false)
 								rtDataplane.ResetDeltas()
 								err := wg.Apply()
 								Expect(err).NotTo(HaveOccurred())
@@ -885,7 +914,7 @@ var _ = Describe("Enable wireguard", func() {
 		link.WireguardFirewallMark = 11
 
 		ipv4 := ip.FromString("1.2.3.4")
-		wg.EndpointWireguardUpdate(hostname, key, ipv4)
+		wg.EndpointWireguardUpdate(hostname, key, ipv4, false)
 
 		err = wg.Apply()
 		Expect(err).NotTo(HaveOccurred())
@@ -910,7 +939,7 @@ var _ = Describe("Enable wireguard", func() {
 			wgDataplane.FailuresToSimulate = mocknetlink.FailNextLinkAddNotSupported
 
 			// Set the wireguard interface ip address
-			wg.EndpointWireguardUpdate(hostname, zeroKey, ipv4_peer1)
+			wg.EndpointWireguardUpdate(hostname, zeroKey, ipv4_peer1, false)
 
 			// No error should occur
 			err := wg.Apply()
@@ -971,7 +1000,7 @@ var _ = Describe("Enable wireguard", func() {
 				apply := newApplyWithErrors(wg, 1)
 
 				// Set the wireguard interface ip address
-				wg.EndpointWireguardUpdate(hostname, zeroKey, ipv4_int1)
+				wg.EndpointWireguardUpdate(hostname, zeroKey, ipv4_int1, false)
 				err := apply.Apply()
 				Expect(err).NotTo(HaveOccurred())
 
@@ -990,11 +1019,11 @@ var _ = Describe("Enable wireguard", func() {
 				Expect(err).NotTo(HaveOccurred())
 
 				// Change the wireguard interface ip address
-				wg.EndpointWireguardUpdate(hostname, zeroKey, ipv4_int2)
+				wg.EndpointWireguardUpdate(hostname, zeroKey, ipv4_int2, false)
 
 				// Add a single wireguard peer with a single route
 				key_peer1 = mustGeneratePrivateKey()
-				wg.EndpointWireguardUpdate(peer1, key_peer1, nil)
+				wg.EndpointWireguardUpdate(peer1, key_peer1, nil, false)
 				wg.EndpointUpdate(peer1, ipv4_peer1)
 				wg.RouteUpdate(peer1, cidr_1)
 				wg.RouteUpdate(peer1, cidr_2)
@@ -1062,7 +1091,7 @@ var _ = Describe("Enable wireguard", func() {
 
 						// Add peer2 with one of the same CIDRs as the previous peer1, and one different CIDR
 						key_peer2 = mustGeneratePrivateKey()
-						wg.EndpointWireguardUpdate(peer2, key_peer2, nil)
+						wg.EndpointWireguardUpdate(peer2, key_peer2, nil, false)
 						wg.EndpointUpdate(peer2, ipv4_peer2)
 						wg.RouteUpdate(peer2, cidr_1)
 						wg.RouteUpdate(peer2, cidr_3)
@@ -1114,7 +1143,7 @@ var _ = Describe("Enable wireguard", func() {
 
 				// Set the wireguard interface ip address. No error should occur because "not supported" is perfectly
 				// valid.
-				wg.EndpointWireguardUpdate(hostname, zeroKey, ipv4_peer1)
+				wg.EndpointWireguardUpdate(hostname, zeroKey, ipv4_peer1, false)
 				err := wg.Apply()
 				Expect(err).NotTo(HaveOccurred())
 
@@ -1175,10 +1204,10 @@ var _ = Describe("Enable wireguard", func() {
 				wg.EndpointUpdate(peer2, ipv4_peer2)
 				wg.EndpointUpdate(peer3, ipv4_peer3)
 				wg.EndpointUpdate(peer4, ipv4_peer4)
-				wg.EndpointWireguardUpdate(peer1, key_peer1, nil)
-				wg.EndpointWireguardUpdate(peer2, key_peer2, nil)
-				wg.EndpointWireguardUpdate(peer3, key_peer3, nil)
-				wg.EndpointWireguardUpdate(peer4, key_peer3, nil) // Peer 3 and 4 declaring same public key
+				wg.EndpointWireguardUpdate(peer1, key_peer1, nil, false)
+				wg.EndpointWireguardUpdate(peer2, key_peer2, nil, false)
+				wg.EndpointWireguardUpdate(peer3, key_peer3, nil, false)
+				wg.EndpointWireguardUpdate(peer4, key_peer3, nil, false) // Peer 3 and 4 declaring same public key
 				wg.RouteUpdate(peer1, cidr_1)
 				wg.RouteUpdate(peer2, cidr_2)
 				wg.RouteUpdate(peer3, cidr_3)
@@ -1329,7 +1358,7 @@ var _ = Describe("Wireguard (disabled)", func() {
 	Describe("With some endpoint updates", func() {
 		BeforeEach(func() {
 			wg.EndpointUpdate(peer1, ipv4_peer1)
-			wg.EndpointWireguardUpdate(peer1, mustGeneratePrivateKey().PublicKey(), nil)
+			wg.EndpointWireguardUpdate(peer1, mustGeneratePrivateKey().PublicKey(), nil, false)
 			wg.RouteUpdate(peer1, cidr_1)
 			err := wg.Apply()
 			Expect(err).NotTo(HaveOccurred())
@@ -1496,3 +1525,278 @@ var _ = Describe("Wireguard (with no table index)", func() {
 		Expect(func() { wgFn(false) }).NotTo(Panic())
 	})
 })
+
+var _ = Describe("Wireguard encryption scope", func() {
+	var wgDataplane, rtDataplane, rrDataplane *mocknetlink.MockNetlinkDataplane
+	var s *mockStatus
+	var wg *Wireguard
+	var link, eth0 *mocknetlink.MockLink
+	var key_peer1, key_peer3 wgtypes.Key
+	var routekey_1, routekey_3, routekey_1_throw string
+
+	ipv4_local := ip.FromString("1.2.3.4")
+
+	setup := func(scope string) {
+		wgDataplane = mocknetlink.New()
+		rtDataplane = mocknetlink.New()
+		rrDataplane = mocknetlink.New()
+		t := mocktime.New()
+		t.SetAutoIncrement(11 * time.Second)
+		s = &mockStatus{}
+
+		wg = NewWithShims(
+			hostname,
+			&Config{
+				Enabled:             true,
+				ListeningPort:       listeningPort,
+				FirewallMark:        firewallMark,
+				RoutingRulePriority: rulePriority,
+				RoutingTableIndex:   tableIndex,
+				InterfaceName:       ifaceName,
+				MTU:                 mtu,
+				EncryptionScope:     scope,
+			},
+			rtDataplane.NewMockNetlink,
+			rrDataplane.NewMockNetlink,
+			wgDataplane.NewMockNetlink,
+			wgDataplane.NewMockWireguard,
+			10*time.Second,
+			t,
+			FelixRouteProtocol,
+			s.status,
+			logutils.NewSummarizer("test loop"),
+		)
+
+		// Host interface holding our node IP.
+		eth0 = wgDataplane.AddIface(2, "eth0", true, true)
+		eth0.Addrs = []netlink.Addr{{IPNet: &net.IPNet{IP: ipv4_local.AsNetIP(), Mask: net.CIDRMask(24, 32)}}}
+
+		// Create the wireguard link and bring it up.
+		Expect(wg.Apply()).NotTo(HaveOccurred())
+		wgDataplane.SetIface(ifaceName, true, true)
+		wg.OnIfaceStateChanged(ifaceName, ifacemonitor.StateUp)
+		Expect(wg.Apply()).NotTo(HaveOccurred())
+		link = wgDataplane.NameToLink[ifaceName]
+		Expect(link).NotTo(BeNil())
+		rtDataplane.NameToLink[ifaceName] = link
+		routekey_1 = fmt.Sprintf("%d-%d-%s", tableIndex, link.LinkAttrs.Index, cidr_1)
+		routekey_3 = fmt.Sprintf("%d-%d-%s", tableIndex, link.LinkAttrs.Index, cidr_3)
+		routekey_1_throw = fmt.Sprintf("%d-%d-%s", tableIndex, 0, cidr_1)
+
+		// peer1 is in our subnet, peer3 is not.
+		wg.EndpointUpdate(hostname, ipv4_local)
+		key_peer1 = mustGeneratePrivateKey().PublicKey()
+		key_peer3 = mustGeneratePrivateKey().PublicKey()
+		wg.EndpointUpdate(peer1, ipv4_peer1)
+		wg.EndpointUpdate(peer3, ipv4_peer3)
+		wg.RouteUpdate(peer1, cidr_1)
+		wg.RouteUpdate(peer3, cidr_3)
+	}
+
+	Describe("with CrossSubnet scope", func() {
+		BeforeEach(func() {
+			setup(EncryptionScopeCrossSubnet)
+			wg.EndpointWireguardUpdate(peer1, key_peer1, nil, false)
+			wg.EndpointWireguardUpdate(peer3, key_peer3, nil, false)
+			Expect(wg.Apply()).NotTo(HaveOccurred())
+		})
+
+		It("should program both peers in wireguard", func() {
+			Expect(link.WireguardPeers).To(HaveLen(2))
+			Expect(link.WireguardPeers[key_peer1].AllowedIPs).To(Equal([]net.IPNet{ipnet_1}))
+			Expect(link.WireguardPeers[key_peer3].AllowedIPs).To(Equal([]net.IPNet{ipnet_3}))
+		})
+
+		It("should only route to wireguard for the peer in a different subnet", func() {
+			Expect(rtDataplane.RouteKeyToRoute).To(HaveKey(routekey_1_throw))
+			Expect(rtDataplane.RouteKeyToRoute).NotTo(HaveKey(routekey_1))
+			Expect(rtDataplane.RouteKeyToRoute).To(HaveKey(routekey_3))
+			Expect(rtDataplane.RouteKeyToRoute).NotTo(HaveKey(routekey_3_throw))
+		})
+
+		It("should recalculate routes when our subnet changes", func() {
+			ipv4_moved := ip.FromString("10.10.20.1")
+			eth0.Addrs = []netlink.Addr{{IPNet: &net.IPNet{IP: ipv4_moved.AsNetIP(), Mask: net.CIDRMask(24, 32)}}}
+			wg.EndpointUpdate(hostname, ipv4_moved)
+			Expect(wg.Apply()).NotTo(HaveOccurred())
+
+			Expect(rtDataplane.RouteKeyToRoute).To(HaveKey(routekey_1))
+			Expect(rtDataplane.RouteKeyToRoute).NotTo(HaveKey(routekey_1_throw))
+			Expect(rtDataplane.RouteKeyToRoute).To(HaveKey(routekey_3_throw))
+			Expect(rtDataplane.RouteKeyToRoute).NotTo(HaveKey(routekey_3))
+			Expect(link.WireguardPeers).To(HaveLen(2))
+		})
+	})
+
+	Describe("with Labelled scope", func() {
+		BeforeEach(func() {
+			setup(EncryptionScopeLabelled)
+			wg.EndpointWireguardUpdate(peer1, key_peer1, nil, true)
+			wg.EndpointWireguardUpdate(peer3, key_peer3, nil, false)
+			Expect(wg.Apply()).NotTo(HaveOccurred())
+		})
+
+		It("should program both peers in wireguard", func() {
+			Expect(link.WireguardPeers).To(HaveLen(2))
+		})
+
+		It("should only route to wireguard for the labelled peer", func() {
+			Expect(rtDataplane.RouteKeyToRoute).To(HaveKey(routekey_1))
+			Expect(rtDataplane.RouteKeyToRoute).NotTo(HaveKey(routekey_1_throw))
+			Expect(rtDataplane.RouteKeyToRoute).To(HaveKey(routekey_3_throw))
+			Expect(rtDataplane.RouteKeyToRoute).NotTo(HaveKey(routekey_3))
+		})
+
+		It("should update routes when the label changes", func() {
+			wg.EndpointWireguardUpdate(peer1, key_peer1, nil, false)
+			wg.EndpointWireguardUpdate(peer3, key_peer3, nil, true)
+			Expect(wg.Apply()).NotTo(HaveOccurred())
+
+			Expect(rtDataplane.RouteKeyToRoute).To(HaveKey(routekey_1_throw))
+			Expect(rtDataplane.RouteKeyToRoute).NotTo(HaveKey(routekey_1))
+			Expect(rtDataplane.RouteKeyToRoute).To(HaveKey(routekey_3))
+			Expect(rtDataplane.RouteKeyToRoute).NotTo(HaveKey(routekey_3_throw))
+			Expect(link.WireguardPeers).To(HaveLen(2))
+		})
+
+		It("should route to wireguard for all peers while our node is labelled", func() {
+			wg.EndpointWireguardUpdate(hostname, zeroKey, nil, true)
+			Expect(wg.Apply()).NotTo(HaveOccurred())
+
+			Expect(rtDataplane.RouteKeyToRoute).To(HaveKey(routekey_1))
+			Expect(rtDataplane.RouteKeyToRoute).To(HaveKey(routekey_3))
+			Expect(rtDataplane.RouteKeyToRoute).NotTo(HaveKey(routekey_1_throw))
+			Expect(rtDataplane.RouteKeyToRoute).NotTo(HaveKey(routekey_3_throw))
+
+			wg.EndpointWireguardUpdate(hostname, zeroKey, nil, false)
+			Expect(wg.Apply()).NotTo(HaveOccurred())
+
+			Expect(rtDataplane.RouteKeyToRoute).To(HaveKey(routekey_1))
+			Expect(rtDataplane.RouteKeyToRoute).To(HaveKey(routekey_3_throw))
+			Expect(rtDataplane.RouteKeyToRoute).NotTo(HaveKey(routekey_3))
+		})
+
+		It("should remove throw routes when a peer is deleted", func() {
+			wg.EndpointRemove(peer3)
+			wg.RouteRemove(cidr_3)
+			Expect(wg.Apply()).NotTo(HaveOccurred())
+
+			Expect(rtDataplane.RouteKeyToRoute).NotTo(HaveKey(routekey_3_throw))
+			Expect(rtDataplane.RouteKeyToRoute).To(HaveKey(routekey_1))
+		})
+	})
+})
+
+var _ = Describe("Wireguard persistent keepalives", func() {
+	var wgDataplane, rtDataplane, rrDataplane *mocknetlink.MockNetlinkDataplane
+	var t *mocktime.MockTime
+	var s *mockStatus
+	var wg *Wireguard
+	var key_peer1 wgtypes.Key
+	var link *mocknetlink.MockLink
+
+	BeforeEach(func() {
+		wgDataplane = mocknetlink.New()
+		rtDataplane = mocknetlink.New()
+		rrDataplane = mocknetlink.New()
+		t = mocktime.New()
+		s = &mockStatus{}
+		t.SetAutoIncrement(11 * time.Second)
+
+		wg = NewWithShims(
+			hostname,
+			&Config{
+				Enabled:                true,
+				ListeningPort:          listeningPort,
+				FirewallMark:           firewallMark,
+				RoutingRulePriority:    rulePriority,
+				RoutingTableIndex:      tableIndex,
+				InterfaceName:          ifaceName,
+				MTU:                    mtu,
+				PersistentKeepAlive:    10 * time.Second,
+				NATPersistentKeepAlive: 25 * time.Second,
+			},
+			rtDataplane.NewMockNetlink,
+			rrDataplane.NewMockNetlink,
+			wgDataplane.NewMockNetlink,
+			wgDataplane.NewMockWireguard,
+			10*time.Second,
+			t,
+			FelixRouteProtocol,
+			s.status,
+			logutils.NewSummarizer("test loop"),
+		)
+
+		// Create the link and bring it up.
+		Expect(wg.Apply()).To(Succeed())
+		wgDataplane.SetIface(ifaceName, true, true)
+		wg.OnIfaceStateChanged(ifaceName, ifacemonitor.StateUp)
+		Expect(wg.Apply()).To(Succeed())
+
+		wg.EndpointWireguardUpdate(hostname, s.key, nil, false)
+		key_peer1 = mustGeneratePrivateKey().PublicKey()
+		wg.EndpointWireguardUpdate(peer1, key_peer1, nil, false)
+		wg.EndpointUpdate(peer1, ipv4_peer1)
+		Expect(wg.Apply()).To(Succeed())
+		link = wgDataplane.NameToLink[ifaceName]
+	})
+
+	// resyncWithStats updates the peer's traffic statistics and handshake time, as the kernel would, and resyncs.
+	resyncWithStats := func(transmitBytes int64, lastHandshake time.Time) {
+		peer := link.WireguardPeers[key_peer1]
+		peer.TransmitBytes = transmitBytes
+		peer.LastHandshakeTime = lastHandshake
+		link.WireguardPeers[key_peer1] = peer
+		wg.QueueResync()
+		Expect(wg.Apply()).To(Succeed())
+	}
+
+	It("should program the global keepalive on new peers", func() {
+		Expect(link.WireguardPeers[key_peer1].PersistentKeepaliveInterval).To(Equal(10 * time.Second))
+	})
+
+	It("should correct the keepalive on resync", func() {
+		peer := link.WireguardPeers[key_peer1]
+		peer.PersistentKeepaliveInterval = 0
+		link.WireguardPeers[key_peer1] = peer
+		wg.QueueResync()
+		Expect(wg.Apply()).To(Succeed())
+		Expect(link.WireguardPeers[key_peer1].PersistentKeepaliveInterval).To(Equal(10 * time.Second))
+	})
+
+	It("should keep the global keepalive while handshakes succeed", func() {
+		resyncWithStats(100, t.Now())
+		resyncWithStats(200, t.Now())
+		Expect(link.WireguardPeers[key_peer1].PersistentKeepaliveInterval).To(Equal(10 * time.Second))
+	})
+
+	It("should keep the global keepalive for an idle peer with an old handshake", func() {
+		resyncWithStats(100, t.Now().Add(-10*time.Minute))
+		resyncWithStats(100, t.Now().Add(-10*time.Minute))
+		Expect(link.WireguardPeers[key_peer1].PersistentKeepaliveInterval).To(Equal(10 * time.Second))
+	})
+
+	Describe("when handshakes time out while sending traffic", func() {
+		BeforeEach(func() {
+			resyncWithStats(100, t.Now().Add(-10*time.Minute))
+			Expect(link.WireguardPeers[key_peer1].PersistentKeepaliveInterval).To(Equal(10 * time.Second))
+			resyncWithStats(200, t.Now().Add(-10*time.Minute))
+		})
+
+		It("should program the NAT keepalive", func() {
+			Expect(link.WireguardPeers[key_peer1].PersistentKeepaliveInterval).To(Equal(25 * time.Second))
+		})
+
+		It("should keep the NAT keepalive once handshakes recover", func() {
+			resyncWithStats(300, t.Now())
+			Expect(link.WireguardPeers[key_peer1].PersistentKeepaliveInterval).To(Equal(25 * time.Second))
+		})
+
+		It("should revert to the global keepalive when the peer's endpoint changes", func() {
+			wg.EndpointUpdate(peer1, ipv4_peer2)
+			Expect(wg.Apply()).To(Succeed())
+			resyncWithStats(300, t.Now())
+			Expect(link.WireguardPeers[key_peer1].PersistentKeepaliveInterval).To(Equal(10 * time.Second))
+		})
+	})
+})